@@ -8,7 +8,9 @@
 //
 // Synopsis:
 //
-//	boot [-v][-no-load][-no-exec]
+//	boot [-v][-no-load][-no-exec][-luks-key-file FILE][-luks-tpm-nv INDEX][-lvm]
+//	     [-verity-policy FILE -verity-key FILE][-measure][-measure-log FILE]
+//	     [-kernel-keys DIR][-fullscreen][-timeout DURATION]
 //
 // Description:
 //
@@ -17,6 +19,31 @@
 //	-v prints messages
 //	-no-load prints the boot image paths it was going to load, but doesn't load + exec them
 //	-no-exec loads the boot image, but doesn't exec it
+//	-luks-key-file unlocks LUKS volumes with the contents of FILE
+//	-luks-tpm-nv unlocks LUKS volumes with a passphrase stored in TPM NV INDEX
+//	-luks-prompt asks for a LUKS passphrase on the console (default true)
+//	-lvm activates LVM logical volumes and scans them (default true)
+//	-verity-policy boots only from the dm-verity device described by the
+//	               signed boot policy FILE; its signature is FILE.sig
+//	-verity-key is the PEM Ed25519 public key boot policies are signed with
+//	-measure extends the kernel, initramfs and command line into TPM PCRs
+//	         9 and 8 before loading them
+//	-measure-log writes the TCG event log of the measurements to FILE
+//	-kernel-keys only loads kernels signed by a key in DIR: .pem or .crt
+//	             certificates for Authenticode signed PE kernels, .gpg or
+//	             .asc OpenPGP keys for detached KERNEL.sig signatures
+//	-fullscreen shows a full-screen menu: entries are chosen with the arrow
+//	            keys, and 'e' edits the kernel command line in place
+//	-timeout is how long the menu waits before booting the default entries
+//
+//	Encrypted volumes are opened as /dev/mapper/luks-<device> and scanned
+//	for boot configurations like any other device. Key sources are tried
+//	in the order key file, TPM, prompt. LVM volume groups are looked for
+//	on the decrypted volumes too.
+//
+//	If the kernel command line has roothash= and systemd.verity_root_data=,
+//	or a boot policy is given, the dm-verity device is set up and only it
+//	is scanned. boot fails rather than fall back to unverified devices.
 //
 // Notes:
 //
@@ -31,15 +58,21 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/u-root/u-root/pkg/boot"
 	"github.com/u-root/u-root/pkg/boot/bootcmd"
 	"github.com/u-root/u-root/pkg/boot/localboot"
 	"github.com/u-root/u-root/pkg/boot/menu"
+	"github.com/u-root/u-root/pkg/boot/verity"
 	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/crypto"
+	"github.com/u-root/u-root/pkg/luks"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/ulog"
@@ -54,8 +87,69 @@ var (
 	reuseCmdlineItem  = flag.String("reuse", "console", "comma separated list of kernel params value to reuse from current kernel (default to console)")
 	appendCmdline     = flag.String("append", "", "Additional kernel params")
 	blockList         = flag.String("block", "", "comma separated list of pci vendor and device ids to ignore (format vendor:device). E.g. 0x8086:0x1234,0x8086:0xabcd")
+
+	luksKeyFile = flag.String("luks-key-file", "", "file containing the passphrase of LUKS volumes")
+	luksTPMNV   = flag.Uint("luks-tpm-nv", 0, "TPM NV index containing the passphrase of LUKS volumes")
+	luksTPMSize = flag.Uint("luks-tpm-nv-size", 64, "size of the passphrase in the TPM NV index")
+	luksTPMPass = flag.String("luks-tpm-password", "", "password of the TPM NV index")
+	luksPrompt  = flag.Bool("luks-prompt", true, "ask for the passphrase of LUKS volumes on the console")
+
+	activateLVM = flag.Bool("lvm", true, "activate LVM logical volumes and scan them for boot configurations")
+
+	verityPolicy = flag.String("verity-policy", "", "signed boot policy describing the dm-verity device to boot from")
+	verityKey    = flag.String("verity-key", "", "PEM file with the Ed25519 public key of boot policies")
+
+	measure    = flag.Bool("measure", false, "measure kernel, initramfs and command line into the TPM before loading them")
+	measureLog = flag.String("measure-log", "", "write the TCG event log of the measurements to FILE")
+
+	fullScreen  = flag.Bool("fullscreen", false, "show a full-screen boot menu navigated with the arrow keys")
+	menuTimeout = flag.Duration("timeout", 10*time.Second, "time the boot menu waits before booting the default entries")
+
+	kernelKeys = flag.String("kernel-keys", "", "directory of keys and certificates kernels must be signed with")
 )
 
+// verityConfig returns the dm-verity device to boot from, if any.
+func verityConfig() (*verity.Config, error) {
+	if *verityPolicy == "" {
+		cfg, err := verity.FromCmdline(cmdline.NewCmdLine())
+		if errors.Is(err, verity.ErrNotConfigured) {
+			return nil, nil
+		}
+		return cfg, err
+	}
+	if *verityKey == "" {
+		return nil, errors.New("-verity-policy needs -verity-key")
+	}
+	key, err := crypto.LoadPublicKeyFromFile(*verityKey)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := os.ReadFile(*verityPolicy)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := os.ReadFile(*verityPolicy + ".sig")
+	if err != nil {
+		return nil, err
+	}
+	return verity.ParsePolicy(policy, sig, key)
+}
+
+// luksKeySources returns the LUKS key sources selected by flags.
+func luksKeySources() []luks.KeySource {
+	var s []luks.KeySource
+	if *luksKeyFile != "" {
+		s = append(s, luks.KeyFile(*luksKeyFile))
+	}
+	if *luksTPMNV != 0 {
+		s = append(s, luks.TPMNV(uint32(*luksTPMNV), uint32(*luksTPMSize), *luksTPMPass))
+	}
+	if *luksPrompt {
+		s = append(s, luks.Prompt(os.Stdin, os.Stdout))
+	}
+	return s
+}
+
 // updateBootCmdline get the kernel command line parameters and filter it:
 // it removes parameters listed in 'remove' and append extra parameters from
 // the 'append' and 'reuse' flags
@@ -93,7 +187,18 @@ func main() {
 		l = ulog.Log
 	}
 	mountPool := &mount.Pool{}
-	images, err := localboot.Localboot(l, blockDevs, mountPool)
+	opts := []localboot.Option{localboot.WithLUKSKeys(luksKeySources()...)}
+	if *activateLVM {
+		opts = append(opts, localboot.WithLVM())
+	}
+	vc, err := verityConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if vc != nil {
+		opts = append(opts, localboot.WithVerity(vc))
+	}
+	images, err := localboot.Localboot(l, blockDevs, mountPool, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -101,9 +206,26 @@ func main() {
 	boot.ApplyLinuxModifiers(images, cmdlineModifier)
 
 	menuEntries := menu.OSImages(*verbose, images...)
+	if *measure {
+		m, err := boot.NewTPMMeasurer(*measureLog)
+		if err != nil {
+			log.Fatalf("Cannot measure boot images: %v", err)
+		}
+		menu.ApplyLoadOptions(menuEntries, boot.WithMeasurer(m))
+	}
+	if *kernelKeys != "" {
+		p, err := boot.ReadSignaturePolicy(*kernelKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		menu.ApplyLoadOptions(menuEntries, boot.WithKernelVerifier(p))
+	}
 	menuEntries = append(menuEntries, menu.Reboot{})
 	menuEntries = append(menuEntries, menu.StartShell{})
 
+	menu.SetFullScreen(*fullScreen)
+	menu.SetInitialTimeout(*menuTimeout)
+
 	// Boot does not return.
 	bootcmd.ShowMenuAndBoot(menuEntries, mountPool, *noLoad, *noExec)
 }
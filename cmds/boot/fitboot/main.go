@@ -9,10 +9,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/acpi"
 	"github.com/u-root/u-root/pkg/boot"
 	"github.com/u-root/u-root/pkg/boot/fit"
+	"github.com/u-root/u-root/pkg/dt"
 	"github.com/u-root/u-root/pkg/vfile"
 )
 
@@ -21,6 +23,7 @@ var (
 	debug      = flag.Bool("d", false, "Print debug output")
 	cmdline    = flag.String("c", "earlyprintk=ttyS0,115200,keep console=ttyS0", "command line")
 	config     = flag.String("config", "", "FIT configuration to use")
+	compatible = flag.String("compatible", "", "Use the FIT configuration best matching these comma-separated compatible strings, or \"auto\" for those of the running system's device tree")
 	kernel     = flag.String("k", "", "Kernel image node name.")
 	initramfs  = flag.String("i", "", "InitRAMFS node name -- default none")
 	ringPath   = flag.String("r", "", "Path to PGP keyring. Enforces signature if non-empty path")
@@ -46,18 +49,34 @@ func main() {
 
 	f.Cmdline, f.Kernel, f.InitRAMFS, f.ConfigOverride = *cmdline, *kernel, *initramfs, *config
 
-	kn, in, err := f.LoadConfig()
-	if err == nil {
-		f.Kernel, f.InitRAMFS = kn, in
-	} else {
+	switch *compatible {
+	case "":
+	case "auto":
+		fdt, err := dt.LoadFDT(nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if f.Compatible, err = fit.BoardCompatible(fdt); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		f.Compatible = strings.Split(*compatible, ",")
+	}
+
+	if c, err := f.GetConfigName(); err != nil {
 		v("Configuration is not available: %v", err)
+	} else if cfg, err := f.ReadConfig(c); err != nil {
+		v("Configuration %q is not available: %v", c, err)
+	} else {
+		v("Configuration %q: %s", c, cfg.Description)
+		f.Kernel, f.InitRAMFS, f.FDT, f.Overlays = cfg.Kernel, cfg.Ramdisk, cfg.FDT, cfg.Overlays
 	}
 
 	if f.Kernel == "" {
 		log.Fatal("kernel name is not found in fit configuration or pass through -k.")
 	}
 
-	v("Kernel name=%s, initramfs=%s", f.Kernel, f.InitRAMFS)
+	v("Kernel name=%s, initramfs=%s, fdt=%s", f.Kernel, f.InitRAMFS, f.FDT)
 
 	kernelCmd := *cmdline
 	if *rsdpLookup {
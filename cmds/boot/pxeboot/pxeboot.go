@@ -17,10 +17,52 @@
 //
 //   - a pxelinux.0, in which case we will ignore the pxelinux and try to parse
 //     pxelinux.cfg/<files>
+//
+// https:// URLs are only fetched if -ca-certs names the root certificates to
+// trust.
+//
+// With -measure, the kernel, initramfs and command line are extended into TPM
+// PCRs 9 and 8 before they are loaded, and -measure-log names a file to
+// write the TCG event log of these measurements to.
+//
+// With -kernel-keys DIR, only kernels signed by a key in DIR are loaded: .pem
+// or .crt certificates for Authenticode signed PE kernels, .gpg or .asc
+// OpenPGP keys for detached signatures fetched from the kernel URL plus
+// ".sig".
+//
+// With -fullscreen, the boot menu is shown full-screen: entries are chosen
+// with the arrow keys and 'e' edits the kernel command line in place.
+// -timeout is how long the menu waits before booting the default entries.
+//
+// On IPv6-only networks, -slaac solicits a router advertisement first: the
+// address is configured by SLAAC and the boot file URL (DHCPv6 option 59)
+// and parameters (option 60) are requested by stateless DHCPv6, unless the
+// router asks hosts to get addresses by DHCPv6. -duid sets the DHCPv6 client
+// DUID as hex bytes; it defaults to a DUID-LL of the interface's MAC address.
+//
+// With -http-boot, DHCP requests are those of UEFI HTTP Boot clients, to which
+// servers reply with the URL of a kernel, unified kernel image or boot
+// script, fetched over HTTP or HTTPS. Redirects are followed, except from
+// HTTPS to plain HTTP. -proxy names the HTTP or SOCKS5 proxy to fetch
+// through, as in http://proxy:3128 or socks5://proxy:1080; by default, the
+// HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY environment variables are
+// used, or else the uroot.http_proxy, uroot.https_proxy, uroot.all_proxy and
+// uroot.no_proxy kernel command line flags.
+//
+// With -segments N, large http:// and https:// boot files are fetched with up
+// to N concurrent range requests of -segment-size bytes each, which is much
+// faster from distant servers.
+//
+// With -cache DIR, boot files are kept in DIR, such as on a scratch partition
+// or tmpfs, and taken from there when booting again after they are verified
+// against their SHA-256 digest. A boot file URL may end in #sha256=HEX to
+// name the digest its file must have. -cache-size bounds how many bytes the
+// files take; those used least recently are evicted.
 package main
 
 import (
 	"context"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
@@ -38,6 +80,7 @@ import (
 	"github.com/u-root/u-root/pkg/ulog"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
 )
 
 var (
@@ -52,6 +95,20 @@ var (
 	cmdAppend   = flag.String("cmd", "", "Kernel command to append for each image")
 	bootfile    = flag.String("file", "", "Boot file name (default tftp) or full URI to use instead of DHCP.")
 	server      = flag.String("server", "0.0.0.0", "Server IP (Requires -file for effect)")
+	caCerts     = flag.String("ca-certs", "", "PEM file of root certificates trusted for https:// boot URLs (https is disabled if unset)")
+	measure     = flag.Bool("measure", false, "measure kernel, initramfs and command line into the TPM before loading them")
+	measureLog  = flag.String("measure-log", "", "write the TCG event log of the measurements to FILE")
+	kernelKeys  = flag.String("kernel-keys", "", "directory of keys and certificates kernels must be signed with")
+	fullScreen  = flag.Bool("fullscreen", false, "show a full-screen boot menu navigated with the arrow keys")
+	menuTimeout = flag.Duration("timeout", 10*time.Second, "time the boot menu waits before booting the default entries")
+	slaac       = flag.Bool("slaac", false, "solicit an IPv6 router advertisement and use SLAAC and stateless DHCPv6 as it says")
+	duid        = flag.String("duid", "", "DHCPv6 client DUID in hex (default: DUID-LL of the interface's MAC address)")
+	httpBoot    = flag.Bool("http-boot", false, "request boot file URLs like UEFI HTTP Boot clients")
+	proxy       = flag.String("proxy", "", "HTTP or SOCKS5 proxy URL to fetch http:// and https:// boot files through")
+	segments    = flag.Int("segments", 0, "fetch http:// and https:// boot files with this many concurrent range requests")
+	segmentSize = flag.Int64("segment-size", curl.DefaultSegmentSize, "size in bytes of the range requests of -segments")
+	cacheDir    = flag.String("cache", "", "directory to keep boot files in across boots")
+	cacheSize   = flag.Int64("cache-size", 0, "most bytes the files in -cache may take (0: no limit)")
 )
 
 const (
@@ -75,8 +132,19 @@ func NetbootImages(ifaceNames string) ([]boot.OSImage, error) {
 	defer cancel()
 
 	c := dhclient.Config{
-		Timeout: dhcpTimeout,
-		Retries: dhcpTries,
+		Timeout:  dhcpTimeout,
+		Retries:  dhcpTries,
+		V6SLAAC:  *slaac,
+		HTTPBoot: *httpBoot,
+	}
+	if *duid != "" {
+		b, err := hex.DecodeString(strings.ReplaceAll(*duid, ":", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DUID %q: %w", *duid, err)
+		}
+		if c.V6DUID, err = dhcpv6.DUIDFromBytes(b); err != nil {
+			return nil, fmt.Errorf("invalid DUID %q: %w", *duid, err)
+		}
 	}
 	if *verbose {
 		c.LogLevel = dhclient.LogSummary
@@ -157,6 +225,38 @@ func main() {
 		ifName = flag.Args()[0]
 	}
 
+	var httpOpts []curl.HTTPOption
+	if *proxy != "" {
+		p := &curl.ProxyConfig{HTTPProxy: *proxy, HTTPSProxy: *proxy}
+		if err := p.Check(); err != nil {
+			log.Fatalf("Invalid proxy URL: %v", err)
+		}
+		httpOpts = append(httpOpts, curl.WithProxyConfig(p))
+	} else if err := curl.DefaultProxy().Check(); err != nil {
+		log.Fatalf("Invalid proxy: %v", err)
+	}
+	segmented := func(c *curl.HTTPClient) *curl.HTTPClient {
+		if *segments > 0 {
+			return c.Segmented(*segmentSize, *segments)
+		}
+		return c
+	}
+	curl.DefaultSchemes.Register("http", segmented(curl.NewHTTPClientWithOptions(httpOpts...)))
+	if *caCerts != "" {
+		roots, err := curl.LoadCertPool(*caCerts)
+		if err != nil {
+			log.Fatalf("Failed to load root certificates: %v", err)
+		}
+		curl.DefaultSchemes.Register("https", segmented(curl.NewHTTPSClient(roots, httpOpts...)))
+	}
+	if *cacheDir != "" {
+		c, err := curl.NewCache(*cacheDir, *cacheSize)
+		if err != nil {
+			log.Fatalf("Failed to open the boot file cache: %v", err)
+		}
+		curl.DefaultSchemes = c.Schemes(curl.DefaultSchemes)
+	}
+
 	var images []boot.OSImage
 	var err error
 	if *bootfile == "" {
@@ -184,9 +284,26 @@ func main() {
 	}
 
 	menuEntries := menu.OSImages(*verbose, images...)
+	if *measure {
+		m, err := boot.NewTPMMeasurer(*measureLog)
+		if err != nil {
+			log.Fatalf("Cannot measure boot images: %v", err)
+		}
+		menu.ApplyLoadOptions(menuEntries, boot.WithMeasurer(m))
+	}
+	if *kernelKeys != "" {
+		p, err := boot.ReadSignaturePolicy(*kernelKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		menu.ApplyLoadOptions(menuEntries, boot.WithKernelVerifier(p))
+	}
 	menuEntries = append(menuEntries, menu.Reboot{})
 	menuEntries = append(menuEntries, menu.StartShell{})
 
+	menu.SetFullScreen(*fullScreen)
+	menu.SetInitialTimeout(*menuTimeout)
+
 	// Boot does not return.
 	bootcmd.ShowMenuAndBoot(menuEntries, nil, *noLoad, *noExec)
 }
@@ -0,0 +1,43 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// b2sum prints or checks BLAKE2b-512 digests of files.
+//
+// Synopsis:
+//
+//	b2sum [-bt] [--tag] [-j JOBS] [FILE...]
+//	b2sum -c [--quiet] [--status] [--strict] [-w] [--ignore-missing] [FILE...]
+//
+// Description:
+//
+//	Without -c, b2sum prints the digest of each FILE, or of stdin if
+//	there is none or FILE is -, as GNU coreutils does. With -c, the FILEs
+//	are lists of digests, as b2sum prints them in either form, and the
+//	files listed are checked against them. Files are hashed in parallel.
+//
+// Options:
+//
+//	-b: mark files as read in binary mode
+//	-t: mark files as read in text mode (default)
+//	--tag: print BSD-style lines, as in "BLAKE2b (FILE) = DIGEST"
+//	-j: hash JOBS files at once, one per CPU by default
+//	-c: check files against the digests listed in FILEs
+//	--quiet: do not print files which match
+//	--status: print nothing; the exit status tells
+//	--strict: fail for improperly formatted lines
+//	-w: warn about improperly formatted lines
+//	--ignore-missing: skip files which do not exist
+package main
+
+import (
+	"os"
+
+	"github.com/u-root/u-root/pkg/checksum"
+)
+
+func main() {
+	if err := checksum.Run(checksum.BLAKE2b, os.Stdout, os.Stderr, os.Stdin, os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
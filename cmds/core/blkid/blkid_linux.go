@@ -2,39 +2,267 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Blkid prints information about blocks.
+// blkid prints the file system type, UUID and label of block devices.
+//
+// Synopsis:
+//
+//	blkid [-o full|value|device|export] [-s TAG]... [-t TAG=VALUE] [DEVICE...]
+//	blkid -U UUID
+//	blkid -L LABEL
+//
+// Description:
+//
+//	Without DEVICE arguments, blkid probes all block devices. DEVICE may
+//	also be a file system image.
+//
+//	The tags are TYPE, UUID and LABEL of the file system, swap area or
+//	container, and PARTUUID and PARTLABEL of GPT partitions.
+//
+//	-U and -L print the device with the given file system UUID or label,
+//	so scripts do not need to depend on device names. blkid exits with
+//	status 2 if no device matches.
+//
+// Options:
+//
+//	-o:        output format (default: full)
+//	-s:        only show the given tag; may be repeated
+//	-t:        only show devices with the given tag value
+//	-U:        print the device with file system UUID
+//	-L:        print the device with file system LABEL
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
-func run(getBlock func() (block.BlockDevices, error), out io.ReadWriter) error {
-	devices, err := getBlock()
+var (
+	errNotFound = errors.New("no matching device")
+	errUsage    = errors.New("usage: blkid [-o full|value|device|export] [-s TAG]... [-t TAG=VALUE] [DEVICE...]")
+)
+
+// tag is a NAME="value" pair of blkid output.
+type tag struct {
+	name, value string
+}
+
+type device struct {
+	path string
+	tags []tag
+}
+
+func (d *device) get(name string) (string, bool) {
+	for _, t := range d.tags {
+		if t.name == name {
+			return t.value, true
+		}
+	}
+	return "", false
+}
+
+// tagList collects repeated -s flags.
+type tagList []string
+
+func (l *tagList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *tagList) Set(s string) error {
+	*l = append(*l, strings.ToUpper(s))
+	return nil
+}
+
+type cmd struct {
+	stdout io.Writer
+	output string
+	show   tagList
+	match  string
+	paths  []string
+
+	// devices lists the block device paths probed without arguments.
+	devices func() ([]string, error)
+}
+
+// listDevices returns all block devices with a non-zero size.
+func listDevices() ([]string, error) {
+	attrs, err := block.ListAttrs()
 	if err != nil {
-		return fmt.Errorf("error getting Block devices: %v", err)
+		return nil, err
+	}
+	var paths []string
+	for _, a := range attrs {
+		if a.Size != 0 {
+			paths = append(paths, filepath.Join("/dev", a.Name))
+		}
+	}
+	return paths, nil
+}
+
+// probe returns the tags of the device or image at path, or nil if it
+// has none.
+func probe(path string) (*device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &device{path: path}
+	fs, err := block.Probe(f)
+	switch {
+	case err == nil:
+		for _, t := range []tag{{"LABEL", fs.Label}, {"UUID", fs.UUID}, {"TYPE", fs.Type}} {
+			if t.value != "" {
+				d.tags = append(d.tags, t)
+			}
+		}
+	case !errors.Is(err, block.ErrNoFS):
+		return nil, err
+	}
+
+	if filepath.Dir(path) == "/dev" {
+		if a, err := block.ReadAttrs(filepath.Base(path)); err == nil {
+			for _, t := range []tag{{"PARTLABEL", a.PartLabel}, {"PARTUUID", a.PartUUID}} {
+				if t.value != "" {
+					d.tags = append(d.tags, t)
+				}
+			}
+		}
+	}
+	if len(d.tags) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+func (c *cmd) print(d *device) {
+	var tags []tag
+	for _, t := range d.tags {
+		if len(c.show) == 0 {
+			tags = append(tags, t)
+			continue
+		}
+		for _, s := range c.show {
+			if t.name == s {
+				tags = append(tags, t)
+			}
+		}
+	}
+
+	switch c.output {
+	case "device":
+		fmt.Fprintln(c.stdout, d.path)
+	case "value":
+		for _, t := range tags {
+			fmt.Fprintln(c.stdout, t.value)
+		}
+	case "export":
+		fmt.Fprintf(c.stdout, "DEVNAME=%s\n", d.path)
+		for _, t := range tags {
+			fmt.Fprintf(c.stdout, "%s=%s\n", t.name, t.value)
+		}
+		fmt.Fprintln(c.stdout)
+	default:
+		fmt.Fprintf(c.stdout, "%s:", d.path)
+		for _, t := range tags {
+			fmt.Fprintf(c.stdout, " %s=%q", t.name, t.value)
+		}
+		fmt.Fprintln(c.stdout)
+	}
+}
+
+func (c *cmd) run() error {
+	name, value, ok := strings.Cut(c.match, "=")
+	if c.match != "" && !ok {
+		return errUsage
 	}
+	name = strings.ToUpper(name)
 
-	for _, device := range devices {
-		fmt.Print(device.DevicePath())
-		if device.FsUUID != "" {
-			fmt.Fprintf(out, ` UUID="%s"`, device.FsUUID)
+	paths := c.paths
+	if len(paths) == 0 {
+		var err error
+		if paths, err = c.devices(); err != nil {
+			return err
 		}
-		if device.FSType != "" {
-			fmt.Fprintf(out, ` TYPE="%s"`, device.FSType)
+	}
+
+	found := false
+	for _, p := range paths {
+		d, err := probe(p)
+		if err != nil {
+			// Like blkid, skip devices that cannot be read, such as
+			// empty drives, unless they were asked for.
+			if len(c.paths) != 0 {
+				return err
+			}
+			continue
+		}
+		if d == nil {
+			continue
+		}
+		if c.match != "" {
+			if v, _ := d.get(name); v != value {
+				continue
+			}
 		}
-		fmt.Println()
+		found = true
+		c.print(d)
+	}
+	if !found && (c.match != "" || len(c.paths) != 0) {
+		return errNotFound
 	}
 	return nil
 }
 
+func command(stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, devices: listDevices}
+	var uuid, label string
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.StringVar(&c.output, "o", "full", "output format: full, value, device or export")
+	f.Var(&c.show, "s", "only show the given tag")
+	f.StringVar(&c.match, "t", "", "only show devices with TAG=VALUE")
+	f.StringVar(&uuid, "U", "", "print the device with file system UUID")
+	f.StringVar(&label, "L", "", "print the device with file system LABEL")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return nil, err
+	}
+	switch c.output {
+	case "full", "value", "device", "export":
+	default:
+		return nil, errUsage
+	}
+	c.paths = f.Args()
+
+	switch {
+	case uuid != "" && label != "":
+		return nil, errUsage
+	case uuid != "":
+		c.match, c.output = "UUID="+uuid, "device"
+	case label != "":
+		c.match, c.output = "LABEL="+label, "device"
+	}
+	return c, nil
+}
+
 func main() {
-	if err := run(block.GetBlockDevices, os.Stdout); err != nil {
+	c, err := command(os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		if errors.Is(err, errNotFound) {
+			os.Exit(2)
+		}
 		log.Fatal(err)
 	}
 }
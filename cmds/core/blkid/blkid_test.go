@@ -6,75 +6,123 @@ package main
 
 import (
 	"bytes"
-	"fmt"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mkfs"
 )
 
 func TestBlkid(t *testing.T) {
+	dir := t.TempDir()
+	img := func(name string, format func(f *os.File) error) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := format(f); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	esp := img("esp", func(f *os.File) error {
+		return mkfs.FAT(f, 8<<20, mkfs.FATOptions{Label: "EFI", VolumeID: 0x0badcafe})
+	})
+	root := img("root", func(f *os.File) error {
+		u := [16]byte{0x51, 0x82, 0x0b, 0x9c, 0xd6, 0x40, 0x4c, 0x8c, 0x85, 0x97, 0x18, 0x86, 0x89, 0x25, 0x3e, 0x69}
+		return mkfs.Ext4(f, 4<<20, mkfs.Ext4Options{Label: "rootfs", UUID: u})
+	})
+	empty := img("empty", func(f *os.File) error { return f.Truncate(1 << 20) })
+
+	devices := func() ([]string, error) { return []string{esp, root, empty}, nil }
+
 	for _, tt := range []struct {
-		name         string
-		BlockDevices []*block.BlockDev
-		wantString   string
-		want         error
+		name string
+		args []string
+		want string
+		err  error
 	}{
 		{
-			name: "Mixed Block Devices",
-			BlockDevices: []*block.BlockDev{
-				{
-					Name:   "nvme0n1p1",
-					FsUUID: "51820b9c-d640-4c8c-8597-188689253e69",
-				}, {
-					Name:   "sda",
-					FsUUID: "4c8c-8597",
-				},
-			},
-			wantString: ` UUID="51820b9c-d640-4c8c-8597-188689253e69" UUID="4c8c-8597"`,
-			want:       nil,
-		}, {
-			name: "Error Block Devices",
-			BlockDevices: []*block.BlockDev{
-				{
-					Name:   "nvme0n1p1",
-					FsUUID: "51820b9c-d640-4c8c-8597-188689253e69",
-				}, {
-					Name:   "sda",
-					FsUUID: "4c8c-8597",
-				},
-			},
-			want: fmt.Errorf("random error"),
+			name: "all",
+			want: esp + `: LABEL="EFI" UUID="0BAD-CAFE" TYPE="vfat"` + "\n" +
+				root + `: LABEL="rootfs" UUID="51820b9c-d640-4c8c-8597-188689253e69" TYPE="ext4"` + "\n",
+		},
+		{
+			name: "one device",
+			args: []string{root},
+			want: root + `: LABEL="rootfs" UUID="51820b9c-d640-4c8c-8597-188689253e69" TYPE="ext4"` + "\n",
+		},
+		{
+			name: "value of tag",
+			args: []string{"-o", "value", "-s", "uuid", esp},
+			want: "0BAD-CAFE\n",
+		},
+		{
+			name: "export",
+			args: []string{"-o", "export", "-s", "TYPE", "-s", "LABEL", esp},
+			want: "DEVNAME=" + esp + "\nLABEL=EFI\nTYPE=vfat\n\n",
+		},
+		{
+			name: "match",
+			args: []string{"-t", "TYPE=ext4", "-o", "device"},
+			want: root + "\n",
+		},
+		{
+			name: "by UUID",
+			args: []string{"-U", "51820b9c-d640-4c8c-8597-188689253e69"},
+			want: root + "\n",
+		},
+		{
+			name: "by label",
+			args: []string{"-L", "EFI"},
+			want: esp + "\n",
+		},
+		{
+			name: "label not found",
+			args: []string{"-L", "home"},
+			err:  errNotFound,
+		},
+		{
+			name: "nothing on device",
+			args: []string{empty},
+			err:  errNotFound,
 		},
 		{
-			name: "Got FS Type",
-			BlockDevices: []*block.BlockDev{
-				{
-					Name:   "nvme0n1p1",
-					FSType: "Ext4",
-					FsUUID: "51820b9c-d640-4c8c-8597-188689253e69",
-				}, {
-					Name:   "sda",
-					FsUUID: "4c8c-8597",
-				},
-			},
-			wantString: ` UUID="51820b9c-d640-4c8c-8597-188689253e69" TYPE="Ext4" UUID="4c8c-8597"`,
-			want:       nil,
+			name: "bad match",
+			args: []string{"-t", "TYPE"},
+			err:  errUsage,
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			blockGetBlockDevices := func() (block.BlockDevices, error) {
-				return tt.BlockDevices, tt.want
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, &stderr, append([]string{"blkid"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
 			}
-			var outBuf bytes.Buffer
-			err := run(blockGetBlockDevices, &outBuf)
-			if err != nil && !strings.Contains(err.Error(), tt.want.Error()) {
-				t.Errorf("%q failed. Got '%v', want '%v'", tt.name, err, tt.want)
+			c.devices = devices
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
 			}
-			if outBuf.String() != tt.wantString {
-				t.Errorf("Blkid.run() = '%s', want: '%s'", outBuf.String(), tt.wantString)
+			if stdout.String() != tt.want {
+				t.Errorf("run() printed\n%s\nwant\n%s", stdout.String(), tt.want)
 			}
-
 		})
 	}
 }
+
+func TestBlkidUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"-o", "json"},
+		{"-U", "x", "-L", "y"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if _, err := command(&stdout, &stderr, append([]string{"blkid"}, args...)); !errors.Is(err, errUsage) {
+			t.Errorf("command(%s) = %v, want %v", strings.Join(args, " "), err, errUsage)
+		}
+	}
+}
@@ -11,6 +11,9 @@
 // Desription:
 //
 //	MODE is a three character octal value or a string like a=rwx
+//
+//	With -recursive, symlinks in the trees are not followed, even if they
+//	are swapped in while the trees are walked.
 package main
 
 import (
@@ -19,11 +22,11 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/u-root/u-root/pkg/fts"
 	"github.com/u-root/u-root/pkg/uroot/util"
 )
 
@@ -66,15 +69,40 @@ func changeMode(path string, mode os.FileMode, octval uint64, mask uint64) error
 	if err != nil {
 		return err
 	}
-	mode = info.Mode() & os.FileMode(mask)
-	mode = mode | os.FileMode(octval)
-
-	if err := os.Chmod(path, mode); err != nil {
+	if err := os.Chmod(path, newMode(info.Mode(), mode, octval, mask)); err != nil {
 		return err
 	}
 	return nil
 }
 
+// newMode returns the mode of a file with mode cur after the change.
+func newMode(cur, mode os.FileMode, octval uint64, mask uint64) os.FileMode {
+	if mask == special {
+		return mode
+	}
+	return cur&os.FileMode(mask) | os.FileMode(octval)
+}
+
+// changeTree changes the mode of the tree at root. Symlinks in the tree are
+// skipped, and the one at root followed, as chmod -R does.
+func changeTree(root string, mode os.FileMode, octval uint64, mask uint64) error {
+	return fts.Walk(root, func(e *fts.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.Post:
+			return nil
+		case e.Mode&os.ModeSymlink != 0:
+			if e.Depth == 0 {
+				return changeMode(e.Path, mode, octval, mask)
+			}
+			return nil
+		}
+		return e.Chmod(newMode(e.Mode, mode, octval, mask))
+	})
+}
+
 func calculateMode(modeString string) (mode os.FileMode, octval uint64, mask uint64, err error) {
 	octval, err = strconv.ParseUint(modeString, 8, 32)
 	if err == nil {
@@ -183,14 +211,7 @@ func (c *cmd) run(args ...string) error {
 
 	for _, name := range fileList {
 		if c.recursive {
-			err := filepath.Walk(name, func(path string, _ os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				err = changeMode(path, mode, octval, mask)
-				return err
-			})
-			if err != nil {
+			if err := changeTree(name, mode, octval, mask); err != nil {
 				finalErr = err
 				fmt.Fprintln(c.stderr, err)
 			}
@@ -304,3 +304,40 @@ func TestMultipleFiles(t *testing.T) {
 		t.Errorf("expected stderr to be 'chmod filenotexists: no such file or directory', got %q", stderr.String())
 	}
 }
+
+func TestRecursiveSymlink(t *testing.T) {
+	d := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(d, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(d, "sub", "f"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(d, "sub", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := command(io.Discard, true, "").run("go+r", d); err != nil {
+		t.Fatalf("chmod(true, \"\", [go+r %s]) = %v", d, err)
+	}
+	for _, tt := range []struct {
+		path string
+		want os.FileMode
+	}{
+		{path: filepath.Join(d, "sub"), want: 0o744},
+		{path: filepath.Join(d, "sub", "f"), want: 0o644},
+		{path: outside, want: 0o600},
+	} {
+		fi, err := os.Stat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != tt.want {
+			t.Errorf("mode of %s = %o, want %o", tt.path, fi.Mode().Perm(), tt.want)
+		}
+	}
+}
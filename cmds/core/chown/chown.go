@@ -0,0 +1,167 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// chown changes the owner and group of files.
+//
+// Synopsis:
+//
+//	chown [-Rh] OWNER[:GROUP] FILE...
+//	chown [-Rh] :GROUP FILE...
+//
+// Description:
+//
+//	OWNER and GROUP are names or numeric IDs. The group is not changed if
+//	it is not given.
+//
+// Options:
+//
+//	-R: change file hierarchies
+//	-h: change symlinks rather than the files they point to
+//
+// With -R, symlinks in the hierarchies are changed and not followed, even if
+// they are swapped in while the hierarchies are walked.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/fts"
+	"github.com/u-root/u-root/pkg/uroot/util"
+)
+
+const usage = "chown [-Rh] OWNER[:GROUP] FILE..."
+
+var (
+	errBadUsage = errors.New(usage)
+	errOwner    = errors.New("invalid owner")
+)
+
+func init() {
+	flag.Usage = util.Usage(flag.Usage, usage)
+}
+
+type cmd struct {
+	stderr      io.Writer
+	recursive   bool
+	noDeref     bool
+	lookupUser  func(string) (*user.User, error)
+	lookupGroup func(string) (*user.Group, error)
+}
+
+func command(stderr io.Writer, recursive, noDeref bool) *cmd {
+	return &cmd{
+		stderr:      stderr,
+		recursive:   recursive,
+		noDeref:     noDeref,
+		lookupUser:  user.Lookup,
+		lookupGroup: user.LookupGroup,
+	}
+}
+
+// parseOwner parses OWNER[:GROUP] into IDs, -1 for those not given.
+func (c *cmd) parseOwner(s string) (int, int, error) {
+	owner, group, _ := strings.Cut(s, ":")
+	if owner == "" && group == "" {
+		return -1, -1, fmt.Errorf("%w: %q", errOwner, s)
+	}
+	uid, gid := -1, -1
+	if owner != "" {
+		id, err := strconv.Atoi(owner)
+		if err != nil {
+			u, lerr := c.lookupUser(owner)
+			if lerr != nil {
+				return -1, -1, fmt.Errorf("%w: %w", errOwner, lerr)
+			}
+			if id, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("%w: user %q has uid %q", errOwner, owner, u.Uid)
+			}
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			g, lerr := c.lookupGroup(group)
+			if lerr != nil {
+				return -1, -1, fmt.Errorf("%w: %w", errOwner, lerr)
+			}
+			if id, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("%w: group %q has gid %q", errOwner, group, g.Gid)
+			}
+		}
+		gid = id
+	}
+	return uid, gid, nil
+}
+
+// chown changes the owner of path.
+func (c *cmd) chown(path string, uid, gid int) error {
+	if c.noDeref {
+		return os.Lchown(path, uid, gid)
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// chownTree changes the owner of the tree at root. Symlinks in the tree are
+// changed themselves, and the one at root followed unless -h is set.
+func (c *cmd) chownTree(root string, uid, gid int) error {
+	return fts.Walk(root, func(e *fts.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.Post:
+			return nil
+		case e.Depth == 0 && e.Mode&os.ModeSymlink != 0:
+			return c.chown(e.Path, uid, gid)
+		}
+		return e.Chown(uid, gid)
+	})
+}
+
+func (c *cmd) run(args ...string) error {
+	if len(args) < 2 {
+		return errBadUsage
+	}
+	uid, gid, err := c.parseOwner(args[0])
+	if err != nil {
+		return err
+	}
+
+	var finalErr error
+	for _, name := range args[1:] {
+		var err error
+		if c.recursive {
+			err = c.chownTree(name, uid, gid)
+		} else {
+			err = c.chown(name, uid, gid)
+		}
+		if err != nil {
+			finalErr = err
+			fmt.Fprintln(c.stderr, err)
+		}
+	}
+	return finalErr
+}
+
+func main() {
+	var (
+		recursive = flag.Bool("R", false, "change file hierarchies")
+		noDeref   = flag.Bool("h", false, "change symlinks rather than the files they point to")
+	)
+	flag.Parse()
+	if err := command(os.Stderr, *recursive, *noDeref).run(flag.Args()...); err != nil {
+		if errors.Is(err, errBadUsage) || errors.Is(err, errOwner) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
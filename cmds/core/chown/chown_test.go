@@ -0,0 +1,131 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestParseOwner(t *testing.T) {
+	c := command(io.Discard, false, false)
+	c.lookupUser = func(name string) (*user.User, error) {
+		if name == "alice" {
+			return &user.User{Uid: "1000"}, nil
+		}
+		return nil, user.UnknownUserError(name)
+	}
+	c.lookupGroup = func(name string) (*user.Group, error) {
+		if name == "wheel" {
+			return &user.Group{Gid: "10"}, nil
+		}
+		return nil, user.UnknownGroupError(name)
+	}
+	for _, tt := range []struct {
+		in       string
+		uid, gid int
+		err      error
+	}{
+		{in: "0", uid: 0, gid: -1},
+		{in: "0:0", uid: 0, gid: 0},
+		{in: "alice", uid: 1000, gid: -1},
+		{in: "alice:wheel", uid: 1000, gid: 10},
+		{in: ":wheel", uid: -1, gid: 10},
+		{in: "alice:", uid: 1000, gid: -1},
+		{in: ":", uid: -1, gid: -1, err: errOwner},
+		{in: "bob", uid: -1, gid: -1, err: errOwner},
+		{in: "alice:staff", uid: -1, gid: -1, err: errOwner},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			uid, gid, err := c.parseOwner(tt.in)
+			if !errors.Is(err, tt.err) || uid != tt.uid || gid != tt.gid {
+				t.Errorf("parseOwner(%q) = %d, %d, %v, want %d, %d, %v", tt.in, uid, gid, err, tt.uid, tt.gid, tt.err)
+			}
+		})
+	}
+}
+
+func TestChown(t *testing.T) {
+	d := t.TempDir()
+	f := filepath.Join(d, "sub", "f")
+	if err := os.Mkdir(filepath.Dir(f), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(d, "sub", "link")); err != nil {
+		t.Fatal(err)
+	}
+	own := strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid())
+
+	for _, tt := range []struct {
+		name      string
+		recursive bool
+		args      []string
+		err       error
+	}{
+		{name: "no args", err: errBadUsage},
+		{name: "no files", args: []string{own}, err: errBadUsage},
+		{name: "file", args: []string{own, f}},
+		{name: "recursive", recursive: true, args: []string{own, d}},
+		{name: "missing", args: []string{own, filepath.Join(d, "missing")}, err: os.ErrNotExist},
+		{name: "missing recursive", recursive: true, args: []string{own, filepath.Join(d, "missing")}, err: os.ErrNotExist},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := command(io.Discard, tt.recursive, false).run(tt.args...); !errors.Is(err, tt.err) {
+				t.Errorf("chown(%v, %q) = %v, want %v", tt.recursive, tt.args, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChownRoot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing owners needs root")
+	}
+	d := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(d, "f"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(d, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := command(io.Discard, true, false).run("1234:5678", d); err != nil {
+		t.Fatalf("chown(true, 1234:5678 %s) = %v", d, err)
+	}
+	for _, tt := range []struct {
+		path     string
+		uid, gid uint32
+	}{
+		{path: d, uid: 1234, gid: 5678},
+		{path: filepath.Join(d, "f"), uid: 1234, gid: 5678},
+		{path: link, uid: 1234, gid: 5678},
+		{path: outside, uid: 0, gid: uint32(os.Getgid())},
+	} {
+		fi, err := os.Lstat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		if st.Uid != tt.uid || st.Gid != tt.gid {
+			t.Errorf("owner of %s = %d:%d, want %d:%d", tt.path, st.Uid, st.Gid, tt.uid, tt.gid)
+		}
+	}
+}
@@ -20,30 +20,56 @@ import (
 	"syscall"
 )
 
+// userSpec is the user and group to run as. Names are looked up when the
+// new root is known; numbers need no lookup.
 type userSpec struct {
 	uid uint32
 	gid uint32
+	// user and group are the names given, if any.
+	user  string
+	group string
+	// set is whether -u was given; otherwise the IDs are those of the
+	// caller.
+	set bool
+	// primary is whether the group is the user's primary group, as
+	// with USER or USER:.
+	primary bool
 }
 
-var defaults = "  -g value\n  	specify supplementary group ids as g1,g2,..,gN\n  -s	Use this option to not changethe working directory to / after changing the root directory to newroot, i.e., inside the chroot. This option is only permitted when newroot is the old / directory.\n  -u value\n    	specify user and group (ID only) as USER:GROUP (default 1000:1000)"
+var defaults = "  -g value\n  \tspecify supplementary groups as g1,g2,..,gN\n  -s\tUse this option to not changethe working directory to / after changing the root directory to newroot, i.e., inside the chroot. This option is only permitted when newroot is the old / directory.\n  -u value\n    \tspecify user and group as USER:GROUP, USER: or USER for the user's group, or :GROUP (default 1000:1000)"
 
+// Set parses USER:GROUP, USER:, USER or :GROUP, where the user and group
+// are names or IDs.
 func (u *userSpec) Set(s string) error {
-	var err error
 	userspecSplit := strings.Split(s, ":")
-	if len(userspecSplit) != 2 || userspecSplit[1] == "" {
+	if len(userspecSplit) > 2 || s == ":" {
 		return fmt.Errorf("expected user spec flag to be \":\" separated values received %s", s)
 	}
-
-	u.uid, err = stringToUint32(userspecSplit[0])
-	if err != nil {
-		return err
+	u.set = true
+	name := userspecSplit[0]
+	if name != "" {
+		if err := parseID(name, &u.uid, &u.user); err != nil {
+			return err
+		}
 	}
+	if len(userspecSplit) == 1 || userspecSplit[1] == "" {
+		u.primary = true
+		return nil
+	}
+	return parseID(userspecSplit[1], &u.gid, &u.group)
+}
 
-	u.gid, err = stringToUint32(userspecSplit[1])
-	if err != nil {
+// parseID parses a numeric ID into id, or a name into name.
+func parseID(s string, id *uint32, name *string) error {
+	n, err := stringToUint32(s)
+	if err == nil {
+		*id, *name = n, ""
+		return nil
+	}
+	if s == "" || strings.ContainsAny(s, ", \t") {
 		return err
 	}
-
+	*name = s
 	return nil
 }
 
@@ -52,7 +78,14 @@ func (u *userSpec) Get() interface{} {
 }
 
 func (u *userSpec) String() string {
-	return fmt.Sprintf("%d:%d", u.uid, u.gid)
+	user, group := fmt.Sprint(u.uid), fmt.Sprint(u.gid)
+	if u.user != "" {
+		user = u.user
+	}
+	if u.group != "" {
+		group = u.group
+	}
+	return user + ":" + group
 }
 
 func defaultUser() userSpec {
@@ -62,22 +95,24 @@ func defaultUser() userSpec {
 	}
 }
 
+// groupsSpec is the supplementary groups. Names are looked up when the
+// new root is known.
 type groupsSpec struct {
 	groups []uint32
+	names  []string
+	set    bool
 }
 
 func (g *groupsSpec) Set(s string) error {
 	groupStrs := strings.Split(s, ",")
 	g.groups = make([]uint32, len(groupStrs))
+	g.names = make([]string, len(groupStrs))
+	g.set = true
 
 	for index, group := range groupStrs {
-
-		gid, err := stringToUint32(group)
-		if err != nil {
+		if err := parseID(group, &g.groups[index], &g.names[index]); err != nil {
 			return err
 		}
-
-		g.groups[index] = gid
 	}
 
 	return nil
@@ -91,7 +126,11 @@ func (g *groupsSpec) String() string {
 	var buffer bytes.Buffer
 
 	for index, gid := range g.groups {
-		buffer.WriteString(fmt.Sprint(gid))
+		if index < len(g.names) && g.names[index] != "" {
+			buffer.WriteString(g.names[index])
+		} else {
+			buffer.WriteString(fmt.Sprint(gid))
+		}
 		if index < len(g.groups)-1 {
 			buffer.WriteString(",")
 		}
@@ -107,11 +146,15 @@ var (
 )
 
 func init() {
-	flag.Var(&user, "u", "specify user and group (ID only) as USER:GROUP")
-	flag.Var(&groups, "g", "specify supplementary group ids as g1,g2,..,gN")
-	flag.BoolVar(&skipchdirFlag, "s", false, fmt.Sprint("Use this option to not change",
+	flag.Var(&user, "u", "specify user and group as USER:GROUP, USER: or USER for the user's group, or :GROUP")
+	flag.Var(&user, "userspec", "same as -u")
+	flag.Var(&groups, "g", "specify supplementary groups as g1,g2,..,gN")
+	flag.Var(&groups, "groups", "same as -g")
+	skip := fmt.Sprint("Use this option to not change",
 		"the working directory to / after changing the root directory to newroot, i.e., ",
-		"inside the chroot. This option is only permitted when newroot is the old / directory."))
+		"inside the chroot. This option is only permitted when newroot is the old / directory.")
+	flag.BoolVar(&skipchdirFlag, "s", false, skip)
+	flag.BoolVar(&skipchdirFlag, "skip-chdir", false, skip)
 }
 
 func stringToUint32(str string) (uint32, error) {
@@ -178,13 +221,15 @@ func chroot(w io.Writer, args ...string) (err error) {
 
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
 	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Credential: &syscall.Credential{
-			Uid:    user.uid,
-			Gid:    user.gid,
-			Groups: groups.groups,
-		},
 		Chroot: newRoot,
 	}
+	if user.set || groups.set {
+		cred, err := credential(newRoot, user, groups)
+		if err != nil {
+			return err
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
 
 	return cmd.Run()
 }
@@ -8,7 +8,10 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"syscall"
 	"testing"
 
 	"github.com/hugelgupf/vmtest/guest"
@@ -18,21 +21,23 @@ func TestUserSpecSet(t *testing.T) {
 	for _, tt := range []struct {
 		name  string
 		input string
+		spec  userSpec
 		want  string
 	}{
 		{
 			name:  "1000:1001",
 			input: "1000:1001",
+			spec:  userSpec{uid: 1000, gid: 1001, set: true},
 		},
 		{
-			name:  "test:1000",
-			input: "test:1000",
-			want:  fmt.Sprintf("strconv.ParseUint: parsing %q: invalid syntax", "test"),
+			name:  "test:1001",
+			input: "test:1001",
+			spec:  userSpec{user: "test", gid: 1001, set: true},
 		},
 		{
 			name:  "1000:test",
 			input: "1000:test",
-			want:  fmt.Sprintf("strconv.ParseUint: parsing %q: invalid syntax", "test"),
+			spec:  userSpec{uid: 1000, group: "test", set: true},
 		},
 		{
 			name:  "1000:1001:",
@@ -40,14 +45,29 @@ func TestUserSpecSet(t *testing.T) {
 			want:  fmt.Sprintf("expected user spec flag to be %q separated values received %s", ":", "1000:1001:"),
 		},
 		{
-			name:  ":1000",
-			input: ":1000",
-			want:  fmt.Sprintf("strconv.ParseUint: parsing %q: invalid syntax", ""),
+			name:  ":1001",
+			input: ":1001",
+			spec:  userSpec{gid: 1001, set: true},
 		},
 		{
 			name:  "1000:",
 			input: "1000:",
-			want:  fmt.Sprintf("expected user spec flag to be %q separated values received %s", ":", "1000:"),
+			spec:  userSpec{uid: 1000, set: true, primary: true},
+		},
+		{
+			name:  "test",
+			input: "test",
+			spec:  userSpec{user: "test", set: true, primary: true},
+		},
+		{
+			name:  ":",
+			input: ":",
+			want:  fmt.Sprintf("expected user spec flag to be %q separated values received %s", ":", ":"),
+		},
+		{
+			name:  "a b:1",
+			input: "a b:1",
+			want:  fmt.Sprintf("strconv.ParseUint: parsing %q: invalid syntax", "a b"),
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
@@ -56,8 +76,10 @@ func TestUserSpecSet(t *testing.T) {
 				if got.Error() != tt.want {
 					t.Errorf("user.Set()= %q, want: %q", got.Error(), tt.want)
 				}
-			} else if user.uid != 1000 || user.gid != 1001 {
-				t.Errorf("Expected uid 1000, gid 1001, got: uid %d, gid %d", user.uid, user.gid)
+			} else if tt.want != "" {
+				t.Errorf("user.Set() = nil, want: %q", tt.want)
+			} else if user != tt.spec {
+				t.Errorf("user.Set() gave %+v, want %+v", user, tt.spec)
 			}
 		})
 	}
@@ -98,8 +120,7 @@ func TestGroupsSet(t *testing.T) {
 		{
 			name:     "test,1001",
 			input:    "test,1001",
-			expected: []uint32{},
-			want:     fmt.Sprintf("strconv.ParseUint: parsing %q: invalid syntax", "test"),
+			expected: []uint32{0, 1001},
 		},
 		{
 			name:     ",1000",
@@ -138,7 +159,7 @@ func TestGroupsSpecGet(t *testing.T) {
 	want := groups
 	got := groups.Get()
 	if !reflect.DeepEqual(got, want) {
-		t.Errorf("Get() = %q, want: %q", got, want)
+		t.Errorf("Get() = %v, want: %v", got, want)
 	}
 
 }
@@ -204,3 +225,87 @@ func TestChroot(t *testing.T) {
 		})
 	}
 }
+
+func TestCredential(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"passwd": "root:x:0:0:root:/root:/bin/sh\n# comment\nalice:x:1000:1000::/home/alice:/bin/sh\nbob:x:1001:100::/home/bob:/bin/sh\n",
+		"group":  "root:x:0:\nusers:x:100:alice\nalice:x:1000:\nwheel:x:10:alice,bob\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, "etc", name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		name   string
+		user   string
+		groups string
+		want   syscall.Credential
+		err    string
+	}{
+		{
+			name: "name",
+			user: "alice",
+			want: syscall.Credential{Uid: 1000, Gid: 1000, Groups: []uint32{100, 10}},
+		},
+		{
+			name: "ID with primary group",
+			user: "1001:",
+			want: syscall.Credential{Uid: 1001, Gid: 100, Groups: []uint32{10}},
+		},
+		{
+			name: "names",
+			user: "bob:wheel",
+			want: syscall.Credential{Uid: 1001, Gid: 10, Groups: []uint32{}},
+		},
+		{
+			name: "group",
+			user: "alice:wheel",
+			want: syscall.Credential{Uid: 1000, Gid: 10, Groups: []uint32{100}},
+		},
+		{
+			name: "IDs",
+			user: "2000:2000",
+			want: syscall.Credential{Uid: 2000, Gid: 2000, Groups: []uint32{}},
+		},
+		{
+			name:   "groups",
+			user:   "alice",
+			groups: "wheel,5",
+			want:   syscall.Credential{Uid: 1000, Gid: 1000, Groups: []uint32{10, 5}},
+		},
+		{name: "unknown user", user: "nosuchuser", err: `unknown user "nosuchuser"`},
+		{name: "unknown group", user: "alice:nosuchgroup", err: `unknown group "nosuchgroup"`},
+		{name: "unknown ID", user: "2000", err: "no group for user ID 2000"},
+		{name: "unknown supplementary group", user: "alice", groups: "nosuchgroup", err: `unknown group "nosuchgroup"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var u userSpec
+			var g groupsSpec
+			if err := u.Set(tt.user); err != nil {
+				t.Fatal(err)
+			}
+			if tt.groups != "" {
+				if err := g.Set(tt.groups); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cred, err := credential(root, u, g)
+			if err != nil {
+				if err.Error() != tt.err {
+					t.Fatalf("credential() = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("credential() = nil, want %v", tt.err)
+			}
+			if !reflect.DeepEqual(*cred, tt.want) {
+				t.Errorf("credential() = %+v, want %+v", *cred, tt.want)
+			}
+		})
+	}
+}
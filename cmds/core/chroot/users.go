@@ -0,0 +1,160 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// entry is a line of a passwd or group file.
+type entry struct {
+	name    string
+	id      uint32
+	gid     uint32   // of a passwd entry
+	members []string // of a group entry
+}
+
+// readDB reads a passwd or group file: name:password:ID:..., with the GID
+// of passwd entries and the members of group entries in the fourth field.
+func readDB(path string, group bool) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var db []entry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		id, err := stringToUint32(fields[2])
+		if err != nil {
+			continue
+		}
+		e := entry{name: fields[0], id: id}
+		if group {
+			if fields[3] != "" {
+				e.members = strings.Split(fields[3], ",")
+			}
+		} else if e.gid, err = stringToUint32(fields[3]); err != nil {
+			continue
+		}
+		db = append(db, e)
+	}
+	return db, s.Err()
+}
+
+// db is the users and groups of the new root, which are those that count
+// for a rescued system, and of the current one.
+type db struct {
+	users  []entry
+	groups []entry
+}
+
+func loadDB(root string) *db {
+	d := &db{}
+	for _, dir := range []string{filepath.Join(root, "etc"), "/etc"} {
+		// Missing files are just empty.
+		u, _ := readDB(filepath.Join(dir, "passwd"), false)
+		g, _ := readDB(filepath.Join(dir, "group"), true)
+		d.users = append(d.users, u...)
+		d.groups = append(d.groups, g...)
+	}
+	return d
+}
+
+func (d *db) user(name string, uid uint32) (entry, bool) {
+	for _, e := range d.users {
+		if (name != "" && e.name == name) || (name == "" && e.id == uid) {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+func (d *db) group(name string) (uint32, bool) {
+	for _, e := range d.groups {
+		if e.name == name {
+			return e.id, true
+		}
+	}
+	return 0, false
+}
+
+// memberOf returns the groups the user is a member of, besides gid.
+func (d *db) memberOf(name string, gid uint32) []uint32 {
+	groups := []uint32{}
+	seen := map[uint32]bool{gid: true}
+	for _, e := range d.groups {
+		for _, m := range e.members {
+			if m == name && !seen[e.id] {
+				seen[e.id] = true
+				groups = append(groups, e.id)
+			}
+		}
+	}
+	return groups
+}
+
+// credential returns the credential for the user and groups given, with
+// names looked up in root, then in the current root.
+//
+// A user's group is their primary group, and without -g, a user given by
+// name or found by ID also gets their supplementary groups.
+func credential(root string, u userSpec, g groupsSpec) (*syscall.Credential, error) {
+	d := loadDB(root)
+	cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+	supplementary := []uint32{}
+
+	if u.set {
+		cred.Uid, cred.Gid = u.uid, u.gid
+		if u.group != "" {
+			gid, ok := d.group(u.group)
+			if !ok {
+				return nil, fmt.Errorf("unknown group %q", u.group)
+			}
+			cred.Gid = gid
+		}
+		if u.user != "" || u.primary {
+			e, ok := d.user(u.user, u.uid)
+			switch {
+			case ok:
+				cred.Uid = e.id
+				if u.primary {
+					cred.Gid = e.gid
+				}
+				supplementary = d.memberOf(e.name, cred.Gid)
+			case u.user != "":
+				return nil, fmt.Errorf("unknown user %q", u.user)
+			case u.primary:
+				return nil, fmt.Errorf("no group for user ID %d", u.uid)
+			}
+		}
+	}
+
+	if g.set {
+		supplementary = make([]uint32, len(g.groups))
+		for i, gid := range g.groups {
+			if i < len(g.names) && g.names[i] != "" {
+				var ok bool
+				if gid, ok = d.group(g.names[i]); !ok {
+					return nil, fmt.Errorf("unknown group %q", g.names[i])
+				}
+			}
+			supplementary[i] = gid
+		}
+	}
+	cred.Groups = supplementary
+	return cred, nil
+}
@@ -6,7 +6,7 @@
 //
 // Synopsis:
 //
-//	cp [-rRfivwP] FROM... TO
+//	cp [-rRfivPpa] [--reflink=auto|always|never] FROM... TO
 //
 // Options:
 //
@@ -17,6 +17,12 @@
 //	-f: force overwrite files
 //	-v: verbose copy mode
 //	-P: don't follow symlinks
+//	-p: preserve owners, modes, timestamps and extended attributes
+//	-a: archive mode, same as -R -P -p
+//	--reflink: share data with copies where the file system supports it
+//	           (auto), never, or fail where it does not (always)
+//
+// Holes in files stay holes in their copies.
 package main
 
 import (
@@ -39,6 +45,9 @@ type flags struct {
 	force            bool
 	verbose          bool
 	noFollowSymlinks bool
+	preserve         bool
+	archive          bool
+	reflink          string
 }
 
 // promptOverwrite ask if the user wants overwrite file
@@ -124,8 +133,16 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 	fs.BoolVar(&f.noFollowSymlinks, "no-dereference", false, "don't follow symlinks")
 	fs.BoolVar(&f.noFollowSymlinks, "P", false, "don't follow symlinks (shorthand)")
 
+	fs.BoolVar(&f.preserve, "preserve", false, "preserve owners, modes, timestamps and extended attributes")
+	fs.BoolVar(&f.preserve, "p", false, "preserve owners, modes, timestamps and extended attributes (shorthand)")
+
+	fs.BoolVar(&f.archive, "archive", false, "same as -R -P -p")
+	fs.BoolVar(&f.archive, "a", false, "same as -R -P -p (shorthand)")
+
+	fs.StringVar(&f.reflink, "reflink", "auto", "share data with copies: auto, always or never")
+
 	fs.Usage = func() {
-		fmt.Fprintf(fs.Output(), "Usage: cp [-RrifvP] file[s] ... dest\n\n")
+		fmt.Fprintf(fs.Output(), "Usage: cp [-RrifvPpa] [--reflink=auto|always|never] file[s] ... dest\n\n")
 		fs.PrintDefaults()
 	}
 
@@ -136,6 +153,14 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 		os.Exit(1)
 	}
 
+	if f.archive {
+		f.recursive, f.noFollowSymlinks, f.preserve = true, true, true
+	}
+	reflink, err := cp.ParseReflink(f.reflink)
+	if err != nil {
+		return err
+	}
+
 	todir := false
 	from, to := fs.Args()[:fs.NArg()-1], fs.Args()[fs.NArg()-1]
 	toStat, err := os.Stat(to)
@@ -157,6 +182,9 @@ func run(args []string, w io.Writer, i *bufio.Reader) error {
 		PreCallback: setupPreCallback(f.recursive, f.ask, f.force, w, *i),
 
 		PostCallback: setupPostCallback(f.verbose, w),
+
+		Preserve: f.preserve,
+		Reflink:  reflink,
 	}
 
 	var lastErr error
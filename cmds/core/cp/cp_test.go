@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/u-root/u-root/pkg/cp"
 	"github.com/u-root/uio/uio"
@@ -352,6 +353,59 @@ func TestCpSymlink(t *testing.T) {
 	})
 }
 
+func TestCpArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(src, "sub", "file")
+	if err := os.WriteFile(file, []byte("archive"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, 0o751); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	for _, p := range []string{file, filepath.Join(src, "sub"), src} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(tempDir, "dst")
+	var out bytes.Buffer
+	var in bufio.Reader
+	if err := run([]string{"cp", "-a", "--reflink=auto", src, dst}, &out, &in); err != nil {
+		t.Fatalf("cp -a = %v", err)
+	}
+	if err := IsEqualTree(cp.NoFollowSymlinks, src, dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"sub/file", "sub", "."} {
+		fi, err := os.Stat(filepath.Join(dst, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(old) {
+			t.Errorf("%s modified %v, want %v", p, fi.ModTime(), old)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "sub/file")); err != nil || fi.Mode().Perm() != 0o751 {
+		t.Errorf("mode = %v, %v, want %v", fi.Mode(), err, os.FileMode(0o751))
+	}
+	if fi, err := os.Lstat(filepath.Join(dst, "link")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link is not a symlink: %v", err)
+	}
+
+	if err := run([]string{"cp", "--reflink=sometimes", file, dst}, &out, &in); !errors.Is(err, cp.ErrReflink) {
+		t.Errorf("cp --reflink=sometimes = %v, want %v", err, cp.ErrReflink)
+	}
+}
+
 // isEqualFile compare two files by checksum
 func isEqualFile(fpath1, fpath2 string) error {
 	file1, err := os.Open(fpath1)
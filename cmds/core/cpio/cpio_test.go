@@ -103,13 +103,13 @@ func TestCpioList(t *testing.T) {
 		t.Fatalf("failed to create temporary archive file: %v", err)
 	}
 
-	err = run([]string{"o"}, inputFile, archive, false, "newc")
+	err = run([]string{"o"}, inputFile, archive, false, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to build archive from filepaths: %v", err)
 	}
 
 	stdout := &bytes.Buffer{}
-	err = run([]string{"t"}, archive, stdout, false, "newc")
+	err = run([]string{"t"}, archive, stdout, false, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to list archive: %v", err)
 	}
@@ -129,7 +129,7 @@ func TestCpio(t *testing.T) {
 	targets, inputFile := prepareTestDir(t, tempDir)
 
 	archive := &bytes.Buffer{}
-	err := run([]string{"o"}, inputFile, archive, true, "newc")
+	err := run([]string{"o"}, inputFile, archive, true, "newc", false)
 	if err != nil {
 		t.Fatalf("failed to build archive from filepaths: %v", err)
 	}
@@ -161,7 +161,7 @@ func TestCpio(t *testing.T) {
 		t.Fatalf("Change to extraction directory %v failed: %#v", tempExtractDir, err)
 	}
 
-	err = run([]string{"i"}, archiveFile, out, true, "newc")
+	err = run([]string{"i"}, archiveFile, out, true, "newc", false)
 	if err != nil {
 		t.Fatalf("Extraction failed:\n%#v\n%v\n", out, err)
 	}
@@ -209,9 +209,42 @@ func TestDirectoryHardLink(t *testing.T) {
 	}
 
 	want := &bytes.Buffer{}
-	err = run([]string{"i"}, archiveFile, want, true, "newc")
+	err = run([]string{"i"}, archiveFile, want, true, "newc", false)
 
 	if err != nil {
 		t.Fatalf("Extraction failed:\n%v\n%v\n", want, err)
 	}
 }
+
+func TestCpioReproducible(t *testing.T) {
+	tempDir := t.TempDir()
+	targets, _ := prepareTestDir(t, tempDir)
+
+	archive := func(reverse bool) []byte {
+		t.Helper()
+		in, err := os.CreateTemp(t.TempDir(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		for i := range targets {
+			ent := targets[i]
+			if reverse {
+				ent = targets[len(targets)-1-i]
+			}
+			fmt.Fprintln(in, filepath.Join(tempDir, ent.Name))
+		}
+		in.Seek(0, 0)
+
+		out := &bytes.Buffer{}
+		if err := run([]string{"o"}, in, out, false, "newc", true); err != nil {
+			t.Fatalf("failed to build archive: %v", err)
+		}
+		return out.Bytes()
+	}
+
+	a, b := archive(false), archive(true)
+	if !bytes.Equal(a, b) {
+		t.Errorf("reproducible archives differ with the input order")
+	}
+}
@@ -2,11 +2,35 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// date prints the date.
+// date prints or sets the date.
 //
 // Synopsis:
 //
-//	date [-u] [+format] | date [-u] [MMDDhhmm[CC]YY[.ss]]
+//	date [-u] [-r FILE | -d STRING] [+format]
+//	date [-u] [-w] [-s STRING | MMDDhhmm[CC]YY[.ss]] [+format]
+//
+// Description:
+//
+//	The format is that of strftime(3), with the GNU extensions: %-d, %_d
+//	and %0d change the padding, %^a upper cases, a width may be given as
+//	in %10s, %N is nanoseconds (%3N milliseconds), and %:z is +hh:mm.
+//
+//	A STRING may be @SECONDS since the epoch, a date such as 2006-01-02,
+//	"2006-01-02 15:04:05" or RFC 3339, or items relative to it or to now,
+//	such as "yesterday", "2 hours ago", "next friday 10:00" or "+1 month".
+//
+//	The time zone is that named by $TZ, which may be a POSIX TZ string such
+//	as CET-1CEST,M3.5.0,M10.5.0/3. Names such as Europe/Berlin need the
+//	zoneinfo database, which an initramfs often lacks; build with -tags
+//	timetzdata to embed it.
+//
+// Options:
+//
+//	-u: use UTC
+//	-r: print the modification time of FILE
+//	-d: print the time described by STRING
+//	-s: set the time described by STRING
+//	-w: also set the hardware clock (RTC) to the new time, in UTC
 package main
 
 import (
@@ -15,7 +39,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -31,36 +54,17 @@ func (r RealClock) Now() time.Time {
 	return time.Now()
 }
 
-var (
-	// default format map from format.go on time lib
-	// Help to make format of date with posix compliant
-	fmtMap = map[string]string{
-		"%a": "Mon",
-		"%A": "Monday",
-		"%b": "Jan",
-		"%h": "Jan",
-		"%B": "January",
-		"%c": time.UnixDate,
-		"%d": "02",
-		"%e": "_2",
-		"%H": "15",
-		"%I": "03",
-		"%m": "1",
-		"%M": "04",
-		"%p": "PM",
-		"%S": "05",
-		"%y": "06",
-		"%Y": "2006",
-		"%z": "-0700",
-		"%Z": "MST",
-	}
-	flags struct {
-		universal bool
-		reference string
-	}
-)
+type options struct {
+	universal bool
+	reference string
+	date      string
+	set       string
+	rtc       bool
+}
+
+var flags options
 
-const cmd = "date [-u] [-d FILE] [+format] | date [-u] [-d FILE] [MMDDhhmm[CC]YY[.ss]]"
+const cmd = "date [-u] [-r FILE | -d STRING] [+format] | date [-u] [-w] [-s STRING | MMDDhhmm[CC]YY[.ss]]"
 
 func init() {
 	defUsage := flag.Usage
@@ -70,96 +74,14 @@ func init() {
 	}
 	flag.BoolVar(&flags.universal, "u", false, "Coordinated Universal Time (UTC)")
 	flag.StringVar(&flags.reference, "r", "", "Display the last modification time of FILE")
+	flag.StringVar(&flags.date, "d", "", "Display the time described by STRING, not now")
+	flag.StringVar(&flags.set, "s", "", "Set the time described by STRING")
+	flag.BoolVar(&flags.rtc, "w", false, "Also set the hardware clock (RTC) to the new time, in UTC")
 }
 
-// regex search for +format POSIX patterns
-func formatParser(args string) []string {
-	pattern := regexp.MustCompile("%[a-zA-Z]")
-	match := pattern.FindAll([]byte(args), -1)
-
-	var results []string
-	for _, m := range match {
-		results = append(results, string(m[:]))
-	}
-
-	return results
-}
-
-// replace map for the format patterns according POSIX and GNU implementations
+// dateMap formats t in the zone z like strftime(3).
 func dateMap(t time.Time, z *time.Location, format string) string {
-	d := t.In(z)
-	var toReplace string
-	for _, match := range formatParser(format) {
-		translate, exists := fmtMap[match]
-		switch {
-		case exists:
-			// Values defined by fmtMap
-			toReplace = d.Format(translate)
-		case match == "%C":
-			// Century (a year divided by 100 and truncated to an integer)
-			// as a decimal number [00,99].
-			toReplace = strconv.Itoa(d.Year() / 100)
-		case match == "%D":
-			// Date in the format mm/dd/yy.
-			toReplace = dateMap(t, z, "%m/%d/%y")
-		case match == "%j":
-			// Day of the year as a decimal number [001,366]."
-			year, weekYear := d.ISOWeek()
-			firstWeekDay := time.Date(year, 1, 1, 1, 1, 1, 1, time.UTC).Weekday()
-			weekDay := d.Weekday()
-			dayYear := int(weekYear)*7 - (int(firstWeekDay) - 1) + int(weekDay)
-			toReplace = strconv.Itoa(dayYear)
-		case match == "%n":
-			// A <newline>.
-			toReplace = "\n"
-		case match == "%r":
-			// 12-hour clock time [01,12] using the AM/PM notation;
-			// in the POSIX locale, this shall be equivalent to %I : %M : %S %p.
-			toReplace = dateMap(t, z, "%I:%M:%S %p")
-		case match == "%t":
-			// A <tab>.
-			toReplace = "\t"
-		case match == "%T":
-			toReplace = dateMap(t, z, "%H:%M:%S")
-		case match == "%W":
-			// Week of the year (Sunday as the first day of the week)
-			// as a decimal number [00,53]. All days in a new year preceding
-			// the first Sunday shall be considered to be in week 0.
-			_, weekYear := d.ISOWeek()
-			weekDay := int(d.Weekday())
-			isNotSunday := 1
-			if weekDay == 0 {
-				isNotSunday = 0
-			}
-			toReplace = strconv.Itoa(weekYear - (isNotSunday))
-		case match == "%w":
-			// Weekday as a decimal number [0,6] (0=Sunday).
-			toReplace = strconv.Itoa(int(d.Weekday()))
-		case match == "%V":
-			// Week of the year (Monday as the first day of the week)
-			// as a decimal number [01,53]. If the week containing January 1
-			// has four or more days in the new year, then it shall be
-			// considered week 1; otherwise, it shall be the last week
-			// of the previous year, and the next week shall be week 1.
-			_, weekYear := d.ISOWeek()
-			toReplace = strconv.Itoa(int(weekYear))
-		case match == "%x":
-			// Locale's appropriate date representation.
-			toReplace = dateMap(t, z, "%m/%d/%y") // TODO: decision algorithm
-		case match == "%F":
-			// Date yyyy-mm-dd defined by GNU implementation
-			toReplace = dateMap(t, z, "%Y-%m-%d")
-		case match == "%X":
-			// Locale's appropriate time representation.
-			toReplace = dateMap(t, z, "%I:%M:%S %p") // TODO: decision algorithm
-		default:
-			continue
-		}
-
-		format = strings.Replace(format, match, toReplace, 1)
-		// fmt.Printf("Iteration[%d]: %v\n", iter, format)
-	}
-	return format
+	return strftime(t.In(z), format)
 }
 
 func ints(s string, i ...*int) error {
@@ -186,6 +108,9 @@ func getTime(z *time.Location, s string, clocksource Clock) (t time.Time, err er
 	YY := clocksource.Now().Year() % 100
 	CC := clocksource.Now().Year() / 100
 	SS := clocksource.Now().Second()
+	if len(s) < 8 {
+		return t, fmt.Errorf("%v is not MMDDhhmm[[CC]YY][.ss]", s)
+	}
 	if err = ints(s, &MM, &DD, &hh, &mm); err != nil {
 		return
 	}
@@ -221,43 +146,64 @@ func date(t time.Time, z *time.Location) string {
 	return t.In(z).Format(time.UnixDate)
 }
 
-func run(args []string, univ bool, ref string, clocksource Clock, w io.Writer) error {
+func run(args []string, opts options, clocksource Clock, w io.Writer) error {
 	t := clocksource.Now()
-	z := time.Local
-	if univ {
-		z = time.UTC
-	}
-	if ref != "" {
-		stat, err := os.Stat(ref)
+	z := location(opts.universal)
+	switch {
+	case opts.reference != "" && opts.date != "":
+		return fmt.Errorf("-r and -d are exclusive")
+	case opts.reference != "":
+		stat, err := os.Stat(opts.reference)
 		if err != nil {
-			return fmt.Errorf("unable to gather stats of file %v", ref)
+			return fmt.Errorf("unable to gather stats of file %v", opts.reference)
 		}
 		t = stat.ModTime()
+	case opts.date != "":
+		d, err := parseDate(opts.date, t, z)
+		if err != nil {
+			return err
+		}
+		t = d
 	}
 
-	switch len(args) {
-	case 0:
-		fmt.Fprintf(w, "%v\n", date(t, z))
-	case 1:
-		a0 := args[0]
-		if strings.HasPrefix(a0, "+") {
-			fmt.Fprintf(w, "%v\n", dateMap(t, z, a0[1:]))
-		} else {
-			if err := setDate(args[0], z, clocksource); err != nil {
-				return fmt.Errorf("%v: %v", a0, err)
-			}
-		}
-	default:
+	format := "%a %b %e %H:%M:%S %Z %Y"
+	if len(args) > 0 && strings.HasPrefix(args[0], "+") {
+		format, args = args[0][1:], args[1:]
+	}
+
+	switch {
+	case len(args) > 1 || len(args) == 1 && opts.set != "":
 		flag.Usage()
 		return nil
+	case opts.set != "":
+		d, err := parseDate(opts.set, t, z)
+		if err != nil {
+			return err
+		}
+		if err := setDate(d, opts.rtc); err != nil {
+			return fmt.Errorf("%v: %w", opts.set, err)
+		}
+		t = d
+	case len(args) == 1:
+		d, err := getTime(z, args[0], clocksource)
+		if err != nil {
+			return fmt.Errorf("%v: %w", args[0], err)
+		}
+		if err := setDate(d, opts.rtc); err != nil {
+			return fmt.Errorf("%v: %w", args[0], err)
+		}
+		t = d
+	case opts.rtc:
+		return fmt.Errorf("-w needs a time to set")
 	}
+	fmt.Fprintf(w, "%v\n", dateMap(t, z, format))
 	return nil
 }
 
 func main() {
 	flag.Parse()
 	rc := RealClock{}
-	if err := run(flag.Args(), flags.universal, flags.reference, rc, os.Stdout); err != nil {
+	if err := run(flag.Args(), flags, rc, os.Stdout); err != nil {
 		log.Fatalf("date: %v", err)
 	}
 }
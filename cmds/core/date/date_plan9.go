@@ -9,6 +9,6 @@ import (
 	"time"
 )
 
-func setDate(d string, z *time.Location, clocksource Clock) error {
+func setDate(t time.Time, hw bool) error {
 	return fmt.Errorf("Can not set the date")
 }
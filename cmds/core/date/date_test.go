@@ -8,7 +8,9 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -48,18 +50,6 @@ func TestDateUniversal(t *testing.T) {
 	}
 }
 
-func TestFormatParser(t *testing.T) {
-	test := "%d %w %x %D %% asdf qwer qwe s sd fqwer % qwer"
-	expected := []string{"%d", "%w", "%x", "%D"}
-	t.Log(":: Test of FormatParser greping the n flags")
-	for index, match := range formatParser(test) {
-		t.Logf(":: Parsed on iteration %d: %v", index, match)
-		if match != expected[index] {
-			t.Errorf("Parsing Error; Want %v, got %v\n", expected[index], match)
-		}
-	}
-}
-
 func TestDateMap(t *testing.T) {
 	t.Log(":: Test of DateMap formatting")
 	posixFormat := "%a %b %e %H:%M:%S %Z %Y"
@@ -243,7 +233,7 @@ func TestRun(t *testing.T) {
 			// bytes.Buffer will make it more convenient.
 			var stderr bytes.Buffer
 			flag.CommandLine.SetOutput(&stderr)
-			if err := run(tt.arg, tt.univ, tt.fileref, rc, &buf); err != nil {
+			if err := run(tt.arg, options{universal: tt.univ, reference: tt.fileref}, rc, &buf); err != nil {
 				if !strings.Contains(err.Error(), tt.wantErr) {
 					t.Errorf("%q failed: %q", tt.name, err)
 				}
@@ -371,3 +361,134 @@ func TestGetTime(t *testing.T) {
 		})
 	}
 }
+
+func TestStrftime(t *testing.T) {
+	z := time.FixedZone("XST", -(5*3600 + 30*60))
+	d := time.Date(2024, time.March, 3, 7, 4, 5, 123456789, z)
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{"%a %A %b %h %B", "Sun Sunday Mar Mar March"},
+		{"%d %e %-d %_m %m %0e", "03  3 3  3 03 03"},
+		{"%H %I %k %l %p %P %M %S", "07 07  7  7 AM am 04 05"},
+		{"%Y %y %C %G %g", "2024 24 20 2024 24"},
+		{"%j %U %W %V %u %w", "063 09 09 09 7 0"},
+		{"%D|%F|%T|%R|%r|%x|%X", "03/03/24|2024-03-03|07:04:05|07:04|07:04:05 AM|03/03/24|07:04:05 AM"},
+		{"%c", "Sun Mar  3 07:04:05 2024"},
+		{"%s %N %3N %6N", "1709469245 123456789 123 123456"},
+		{"%z %:z %::z %:::z %Z", "-0530 -05:30 -05:30:00 -05:30 XST"},
+		{"%^a %#b %10B|%-10A|%5d|%_5H", "SUN MAR      March|Sunday|00003|    7"},
+		{"%q %n%t%%", "1 \n\t%"},
+		{"%Q %:d 100%", "%Q %:d 100%"},
+	} {
+		if got := strftime(d, tt.format); got != tt.want {
+			t.Errorf("strftime(%q): got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, time.March, 6, 15, 30, 0, 0, time.UTC) // A Wednesday.
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"", "2024-03-06T00:00:00Z"},
+		{"now", "2024-03-06T15:30:00Z"},
+		{"@1700000000", "2023-11-14T22:13:20Z"},
+		{"@1.5", "1970-01-01T00:00:01.5Z"},
+		{"2024-01-02T03:04:05+01:00", "2024-01-02T02:04:05Z"},
+		{"2024-01-02", "2024-01-02T00:00:00Z"},
+		{"2024-01-02 03:04", "2024-01-02T03:04:00Z"},
+		{"Tue Jan  2 03:04:05 UTC 2024", "2024-01-02T03:04:05Z"},
+		{"Jan 2 2024", "2024-01-02T00:00:00Z"},
+		{"yesterday", "2024-03-05T15:30:00Z"},
+		{"tomorrow 10:00", "2024-03-07T10:00:00Z"},
+		{"2 hours ago", "2024-03-06T13:30:00Z"},
+		{"+1 month -2 days", "2024-04-04T15:30:00Z"},
+		{"1 week 3 days ago", "2024-02-25T15:30:00Z"},
+		{"2024-01-31 +1 day", "2024-02-01T00:00:00Z"},
+		{"next year", "2025-03-06T15:30:00Z"},
+		{"last minute", "2024-03-06T15:29:00Z"},
+		{"friday", "2024-03-08T00:00:00Z"},
+		{"wednesday", "2024-03-06T00:00:00Z"},
+		{"next wednesday", "2024-03-13T00:00:00Z"},
+		{"last wed noon", "2024-02-28T12:00:00Z"},
+		{"midnight 3pm", "2024-03-06T15:00:00Z"},
+	} {
+		got, err := parseDate(tt.in, now, time.UTC)
+		if err != nil {
+			t.Errorf("parseDate(%q): got %v, want nil", tt.in, err)
+			continue
+		}
+		if got.Format(time.RFC3339Nano) != tt.want {
+			t.Errorf("parseDate(%q): got %v, want %v", tt.in, got.Format(time.RFC3339Nano), tt.want)
+		}
+	}
+	for _, in := range []string{"@x", "soon", "3", "next", "2024-13-01"} {
+		if _, err := parseDate(in, now, time.UTC); !errors.Is(err, errDate) {
+			t.Errorf("parseDate(%q): got %v, want %v", in, err, errDate)
+		}
+	}
+}
+
+func TestPosixLocation(t *testing.T) {
+	l, err := posixLocation("CET-1CEST,M3.5.0,M10.5.0/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC), "13:00 CET +0100"},
+		{time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC), "14:00 CEST +0200"},
+	} {
+		if got := tt.t.In(l).Format("15:04 MST -0700"); got != tt.want {
+			t.Errorf("%v in %v: got %q, want %q", tt.t, l, got, tt.want)
+		}
+	}
+	l, err = posixLocation("<+0330>-3:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Unix(0, 0).In(l).Format("15:04 MST"); got != "03:30 +0330" {
+		t.Errorf("got %q, want 03:30 +0330", got)
+	}
+	for _, tz := range []string{"Europe/Nowhere", "X1", "CET"} {
+		if _, err := posixLocation(tz); err == nil {
+			t.Errorf("posixLocation(%q): got nil, want error", tz)
+		}
+	}
+}
+
+func TestRunDate(t *testing.T) {
+	t.Setenv("TZ", "EST5EDT,M3.2.0,M11.1.0")
+	rc := fakeClock{time.Date(2024, time.July, 4, 16, 0, 0, 0, time.UTC)}
+	for _, tt := range []struct {
+		args []string
+		opts options
+		want string
+	}{
+		{opts: options{}, want: "Thu Jul  4 12:00:00 EDT 2024\n"},
+		{opts: options{universal: true}, want: "Thu Jul  4 16:00:00 UTC 2024\n"},
+		{args: []string{"+%F %T %Z"}, opts: options{date: "2024-01-15 08:00"}, want: "2024-01-15 08:00:00 EST\n"},
+		{args: []string{"+%s"}, opts: options{date: "1 day ago"}, want: "1720022400\n"},
+	} {
+		var buf bytes.Buffer
+		if err := run(tt.args, tt.opts, rc, &buf); err != nil {
+			t.Errorf("run(%q, %+v): got %v, want nil", tt.args, tt.opts, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("run(%q, %+v): got %q, want %q", tt.args, tt.opts, buf.String(), tt.want)
+		}
+	}
+	if err := run(nil, options{date: "x", reference: "y"}, rc, io.Discard); err == nil {
+		t.Errorf("-r with -d: got nil, want error")
+	}
+	if err := run(nil, options{rtc: true}, rc, io.Discard); err == nil {
+		t.Errorf("-w without a time: got nil, want error")
+	}
+}
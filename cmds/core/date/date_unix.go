@@ -8,16 +8,25 @@
 package main
 
 import (
-	"log"
 	"syscall"
 	"time"
+
+	"github.com/u-root/u-root/pkg/rtc"
 )
 
-func setDate(d string, z *time.Location, clocksource Clock) error {
-	t, err := getTime(z, d, clocksource)
+// setDate sets the system clock, and the RTC if hw is set.
+func setDate(t time.Time, hw bool) error {
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	if err := syscall.Settimeofday(&tv); err != nil {
+		return err
+	}
+	if !hw {
+		return nil
+	}
+	r, err := rtc.OpenRTC()
 	if err != nil {
-		log.Fatalf("%v: %v", d, err)
+		return err
 	}
-	tv := syscall.NsecToTimeval(t.UnixNano())
-	return syscall.Settimeofday(&tv)
+	defer r.Close()
+	return r.Set(t.UTC())
 }
@@ -0,0 +1,242 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// number is the default padding of a numeric conversion.
+type number struct {
+	width int
+	pad   byte
+}
+
+var numbers = map[byte]number{
+	'C': {2, '0'},
+	'd': {2, '0'},
+	'e': {2, ' '},
+	'g': {2, '0'},
+	'G': {1, '0'},
+	'H': {2, '0'},
+	'I': {2, '0'},
+	'j': {3, '0'},
+	'k': {2, ' '},
+	'l': {2, ' '},
+	'm': {2, '0'},
+	'M': {2, '0'},
+	'q': {1, '0'},
+	's': {1, '0'},
+	'S': {2, '0'},
+	'u': {1, '0'},
+	'U': {2, '0'},
+	'V': {2, '0'},
+	'w': {1, '0'},
+	'W': {2, '0'},
+	'y': {2, '0'},
+	'Y': {1, '0'},
+}
+
+// composite conversions are made of others, as in the C locale, except
+// that %X has always had AM or PM here.
+var composite = map[byte]string{
+	'c': "%a %b %e %H:%M:%S %Y",
+	'D': "%m/%d/%y",
+	'F': "%Y-%m-%d",
+	'r': "%I:%M:%S %p",
+	'R': "%H:%M",
+	'T': "%H:%M:%S",
+	'x': "%m/%d/%y",
+	'X': "%I:%M:%S %p",
+}
+
+// convert returns the value of the conversion c for t, without padding.
+// The colons count those between the flags and the z of %:z.
+func convert(t time.Time, c byte, colons int) (string, bool) {
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	isoYear, isoWeek := t.ISOWeek()
+	switch c {
+	case 'a':
+		return t.Format("Mon"), true
+	case 'A':
+		return t.Format("Monday"), true
+	case 'b', 'h':
+		return t.Format("Jan"), true
+	case 'B':
+		return t.Format("January"), true
+	case 'C':
+		return strconv.Itoa(t.Year() / 100), true
+	case 'd', 'e':
+		return strconv.Itoa(t.Day()), true
+	case 'g':
+		return strconv.Itoa(isoYear % 100), true
+	case 'G':
+		return strconv.Itoa(isoYear), true
+	case 'H', 'k':
+		return strconv.Itoa(t.Hour()), true
+	case 'I', 'l':
+		return strconv.Itoa(hour12), true
+	case 'j':
+		return strconv.Itoa(t.YearDay()), true
+	case 'm':
+		return strconv.Itoa(int(t.Month())), true
+	case 'M':
+		return strconv.Itoa(t.Minute()), true
+	case 'n':
+		return "\n", true
+	case 'p':
+		return t.Format("PM"), true
+	case 'P':
+		return strings.ToLower(t.Format("PM")), true
+	case 'q':
+		return strconv.Itoa((int(t.Month())-1)/3 + 1), true
+	case 's':
+		return strconv.FormatInt(t.Unix(), 10), true
+	case 'S':
+		return strconv.Itoa(t.Second()), true
+	case 't':
+		return "\t", true
+	case 'u':
+		return strconv.Itoa((int(t.Weekday())+6)%7 + 1), true
+	case 'U':
+		// Weeks start on Sunday, and days before the first are in week 0.
+		return strconv.Itoa((t.YearDay() + 6 - int(t.Weekday())) / 7), true
+	case 'V':
+		return strconv.Itoa(isoWeek), true
+	case 'w':
+		return strconv.Itoa(int(t.Weekday())), true
+	case 'W':
+		// The same, for weeks starting on Monday.
+		return strconv.Itoa((t.YearDay() + 6 - (int(t.Weekday())+6)%7) / 7), true
+	case 'y':
+		return strconv.Itoa(t.Year() % 100), true
+	case 'Y':
+		return strconv.Itoa(t.Year()), true
+	case 'z':
+		_, off := t.Zone()
+		sign := '+'
+		if off < 0 {
+			sign, off = '-', -off
+		}
+		h, m, s := off/3600, off/60%60, off%60
+		switch colons {
+		case 0:
+			return fmt.Sprintf("%c%02d%02d", sign, h, m), true
+		case 1:
+			return fmt.Sprintf("%c%02d:%02d", sign, h, m), true
+		case 2:
+			return fmt.Sprintf("%c%02d:%02d:%02d", sign, h, m, s), true
+		case 3:
+			// As few fields as are needed.
+			switch {
+			case s != 0:
+				return fmt.Sprintf("%c%02d:%02d:%02d", sign, h, m, s), true
+			case m != 0:
+				return fmt.Sprintf("%c%02d:%02d", sign, h, m), true
+			}
+			return fmt.Sprintf("%c%02d", sign, h), true
+		}
+	case 'Z':
+		name, _ := t.Zone()
+		return name, true
+	case '%':
+		return "%", true
+	}
+	return "", false
+}
+
+// strftime formats t like strftime(3) and GNU date. A conversion may have
+// the flags - (no padding), _ (pad with spaces), 0 (pad with zeros) and ^
+// or # (upper case), and a width.
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		var pad byte
+		upper := false
+		for ; j < len(format) && strings.IndexByte("-_0^#", format[j]) >= 0; j++ {
+			if format[j] == '^' || format[j] == '#' {
+				upper = true
+			} else {
+				pad = format[j]
+			}
+		}
+		width := -1
+		for ; j < len(format) && format[j] >= '0' && format[j] <= '9'; j++ {
+			width = max(width, 0)*10 + int(format[j]-'0')
+		}
+		colons := 0
+		for ; j < len(format) && format[j] == ':'; j++ {
+			colons++
+		}
+		if j == len(format) {
+			b.WriteString(format[i:])
+			break
+		}
+		c := format[j]
+
+		var s string
+		ok := true
+		switch {
+		case c == 'N':
+			// The width is the number of digits.
+			s = fmt.Sprintf("%09d", t.Nanosecond())
+			if width > 0 && width < 9 {
+				s = s[:width]
+			}
+			width = -1
+		case composite[c] != "":
+			s = strftime(t, composite[c])
+		case colons > 0 && c != 'z', colons > 3:
+			ok = false
+		default:
+			s, ok = convert(t, c, colons)
+		}
+		if !ok {
+			// Unknown conversions are printed as they are.
+			b.WriteString(format[i : j+1])
+			i = j
+			continue
+		}
+		if upper {
+			s = strings.ToUpper(s)
+		}
+
+		n, numeric := numbers[c]
+		if !numeric {
+			n = number{0, ' '}
+			if pad == '0' {
+				n.pad = '0'
+			}
+		}
+		if width >= 0 {
+			n.width = width
+		}
+		switch pad {
+		case '-':
+			n.width = 0
+		case '_':
+			n.pad = ' '
+		case '0':
+			n.pad = '0'
+		}
+		if len(s) < n.width {
+			s = strings.Repeat(string(n.pad), n.width-len(s)) + s
+		}
+		b.WriteString(s)
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errDate = errors.New("invalid date")
+
+// layouts are the absolute dates understood by -d and -s.
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006",
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 January 2006",
+}
+
+// clocks are times of day, which apply to the date so far.
+var clocks = []string{
+	"15:04:05.999999999",
+	"15:04:05",
+	"15:04",
+	"3:04pm",
+	"3:04:05pm",
+	"3pm",
+}
+
+// units are the units of relative items.
+var units = map[string]struct {
+	years, months, days int
+	d                   time.Duration
+}{
+	"year":      {years: 1},
+	"month":     {months: 1},
+	"fortnight": {days: 14},
+	"week":      {days: 7},
+	"day":       {days: 1},
+	"hour":      {d: time.Hour},
+	"minute":    {d: time.Minute},
+	"min":       {d: time.Minute},
+	"second":    {d: time.Second},
+	"sec":       {d: time.Second},
+}
+
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		weekdays[strings.ToLower(d.String())] = d
+		weekdays[strings.ToLower(d.String()[:3])] = d
+	}
+}
+
+// unit returns the unit for a word, which may be plural.
+func unit(word string) (string, bool) {
+	if _, ok := units[word]; ok {
+		return word, true
+	}
+	word = strings.TrimSuffix(word, "s")
+	_, ok := units[word]
+	return word, ok
+}
+
+// midnight returns the start of the day of t.
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// parseDate parses a date like GNU date -d: @SECONDS, an absolute date in
+// one of the layouts, or items relative to now or to an absolute date,
+// such as "yesterday", "2 hours ago", "next friday 10:00" or "+1 month".
+func parseDate(s string, now time.Time, z *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return midnight(now.In(z)), nil
+	}
+	if secs, ok := strings.CutPrefix(s, "@"); ok {
+		f, err := strconv.ParseFloat(secs, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w %q", errDate, s)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).In(z), nil
+	}
+
+	// Try the longest prefix of words which is an absolute date.
+	words := strings.Fields(s)
+	t := now.In(z)
+	for n := len(words); n > 0; n-- {
+		prefix := strings.Join(words[:n], " ")
+		if d, ok := absolute(prefix, z); ok {
+			t, words = d, words[n:]
+			break
+		}
+	}
+
+	var years, months, days int
+	var d time.Duration
+	for i := 0; i < len(words); i++ {
+		w := strings.ToLower(words[i])
+		switch w {
+		case "now", "today":
+			continue
+		case "yesterday":
+			days--
+			continue
+		case "tomorrow":
+			days++
+			continue
+		case "midnight":
+			t = midnight(t)
+			continue
+		case "noon":
+			t = midnight(t).Add(12 * time.Hour)
+			continue
+		case "utc", "z", "gmt":
+			y, m, day := t.Date()
+			h, min, sec := t.Clock()
+			t = time.Date(y, m, day, h, min, sec, t.Nanosecond(), time.UTC).In(z)
+			continue
+		case "ago":
+			years, months, days, d = -years, -months, -days, -d
+			continue
+		}
+
+		if c, ok := clock(w, t); ok {
+			t = c
+			continue
+		}
+
+		// An item is [N|next|last|this] UNIT or a weekday.
+		n, counted := 1, false
+		switch w {
+		case "next":
+			counted = true
+		case "last":
+			n, counted = -1, true
+		case "this":
+			n, counted = 0, true
+		default:
+			if v, err := strconv.Atoi(w); err == nil {
+				n, counted = v, true
+			}
+		}
+		if counted {
+			if i++; i == len(words) {
+				return time.Time{}, fmt.Errorf("%w %q: missing unit after %q", errDate, s, words[i-1])
+			}
+			w = strings.ToLower(words[i])
+		}
+		if day, ok := weekdays[w]; ok {
+			t = midnight(weekday(t, day, n, counted))
+			continue
+		}
+		u, ok := unit(w)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%w %q: unknown word %q", errDate, s, words[i])
+		}
+		years += n * units[u].years
+		months += n * units[u].months
+		days += n * units[u].days
+		d += time.Duration(n) * units[u].d
+	}
+	return t.AddDate(years, months, days).Add(d), nil
+}
+
+// absolute parses a date in one of the layouts.
+func absolute(s string, z *time.Location) (time.Time, bool) {
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, s, z); err == nil {
+			return t.In(z), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// clock sets the time of day of t if s is one.
+func clock(s string, t time.Time) (time.Time, bool) {
+	for _, l := range clocks {
+		c, err := time.Parse(l, s)
+		if err != nil {
+			continue
+		}
+		y, m, d := t.Date()
+		return time.Date(y, m, d, c.Hour(), c.Minute(), c.Second(), c.Nanosecond(), t.Location()), true
+	}
+	return time.Time{}, false
+}
+
+// weekday moves t to the day of the week. Without a count, that is today
+// or the next such day; next is the next one after today, and last the
+// one before.
+func weekday(t time.Time, day time.Weekday, n int, counted bool) time.Time {
+	diff := (int(day) - int(t.Weekday()) + 7) % 7
+	switch {
+	case !counted || n == 0:
+	case n > 0:
+		if diff == 0 {
+			diff = 7
+		}
+		diff += 7 * (n - 1)
+	default:
+		diff -= 7
+		diff += 7 * (n + 1)
+	}
+	return t.AddDate(0, 0, diff)
+}
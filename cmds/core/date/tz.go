@@ -0,0 +1,79 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// posixTZ matches the start of a POSIX TZ string like CET-1CEST,M3.5.0,M10.5.0/3.
+var posixTZ = regexp.MustCompile(`^([A-Za-z]{3,}|<[-+0-9A-Za-z]+>)[-+]?[0-9]`)
+
+// location returns the time zone to use: UTC for -u, or that named by
+// $TZ, or the local one.
+//
+// Names like Europe/Berlin are looked up in the zoneinfo database, which
+// is often missing from an initramfs; building with -tags timetzdata
+// embeds it. $TZ may also be a POSIX TZ string, which needs no database.
+func location(univ bool) *time.Location {
+	if univ {
+		return time.UTC
+	}
+	tz := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if tz == "" {
+		return time.Local
+	}
+	if l, err := time.LoadLocation(tz); err == nil {
+		return l
+	}
+	if l, err := posixLocation(tz); err == nil {
+		return l
+	}
+	return time.Local
+}
+
+// posixLocation returns the location for a POSIX TZ string. It is loaded
+// as zoneinfo data with no transitions, and the string as the footer that
+// gives the rules for times after them.
+func posixLocation(tz string) (*time.Location, error) {
+	if !posixTZ.MatchString(tz) || strings.ContainsAny(tz, "\n") {
+		return nil, fmt.Errorf("%q is not a POSIX TZ string", tz)
+	}
+	var b bytes.Buffer
+	header := func() {
+		b.WriteString("TZif2")
+		b.Write(make([]byte, 15))
+		// isutcnt, isstdcnt, leapcnt, timecnt, typecnt, charcnt
+		for _, n := range []uint32{0, 0, 0, 0, 1, 4} {
+			binary.Write(&b, binary.BigEndian, n)
+		}
+	}
+	data := func() {
+		// One type, UTC, for times before the first transition.
+		b.Write([]byte{0, 0, 0, 0, 0, 0})
+		b.WriteString("UTC\x00")
+	}
+	header()
+	data()
+	header()
+	data()
+	b.WriteString("\n" + tz + "\n")
+
+	l, err := time.LoadLocationFromTZData(tz, b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// The rules are only checked when they are used.
+	if name, _ := time.Date(2000, 1, 1, 0, 0, 0, 0, l).Zone(); name == "UTC" && !strings.HasPrefix(tz, "UTC") {
+		return nil, fmt.Errorf("%q is not a valid POSIX TZ string", tz)
+	}
+	return l, nil
+}
@@ -12,6 +12,19 @@
 //
 //	dd is modeled after dd(1).
 //
+//	conv=sync pads short input blocks with NULs, conv=fsync and
+//	conv=fdatasync flush the output file before dd exits, and conv=sparse
+//	seeks over output blocks of NULs instead of writing them. iflag=direct
+//	and oflag=direct bypass the page cache on systems that support it.
+//
+//	Copies between files and devices which need no conversion keep many
+//	large reads and writes in flight, with io_uring on Linux, so that fast
+//	devices such as NVMe drives are kept busy.
+//
+//	Sizes and counts may carry a multiplier suffix: c=1, w=2, b=512,
+//	k or K, M, G, ... for powers of 1024, and KB, MB, GB, ... for powers of
+//	1000.
+//
 // Options:
 //
 //	-ibs n:   input block size (default=1)
@@ -19,11 +32,15 @@
 //	-bs n:    input and output block size (default=0)
 //	-skip n:  skip n ibs-sized input blocks before reading (default=0)
 //	-seek n:  seek n obs-sized output blocks before writing (default=0)
-//	-conv s:  comma separated list of conversions (none|notrunc)
+//	-conv s:  comma separated list of conversions
+//	          (none|notrunc|sync|fsync|fdatasync|sparse)
 //	-count n: copy only n ibs-sized input blocks
 //	-if:      defaults to stdin
 //	-of:      defaults to stdout
-//	-oflag:   comma separated list of out flags (none|sync|dsync)
+//	-iflag:   comma separated list of in flags
+//	          (none|direct|fullblock|skip_bytes|count_bytes)
+//	-oflag:   comma separated list of out flags
+//	          (none|sync|dsync|direct|seek_bytes)
 //	-status:  print transfer stats to stderr, can be one of:
 //	    none:     do not display
 //	    xfer:     print on completion (default)
@@ -37,18 +54,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"math"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"unsafe"
 
 	"github.com/rck/unit"
 	"github.com/u-root/u-root/pkg/progress"
+	"github.com/u-root/u-root/pkg/uio"
 )
 
 type bitClearAndSet struct {
@@ -67,8 +90,33 @@ var flagMap = map[string]bitClearAndSet{
 	"sync": {set: os.O_SYNC},
 }
 
+var iflagMap = map[string]bitClearAndSet{}
+
 var allowedFlags = os.O_TRUNC | os.O_SYNC
 
+// directFlag is the open flag for direct I/O, or 0 if there is none.
+var directFlag int
+
+// directAlign is the alignment of buffers used for direct I/O.
+const directAlign = 4096
+
+// clearDirect turns direct I/O off for f.
+var clearDirect = func(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+// options are the conversions and flags that change how blocks are copied,
+// rather than how files are opened.
+type options struct {
+	sync       bool // conv=sync
+	fsync      bool // conv=fsync and conv=fdatasync
+	sparse     bool // conv=sparse
+	fullblock  bool // iflag=fullblock
+	skipBytes  bool // iflag=skip_bytes
+	countBytes bool // iflag=count_bytes
+	seekBytes  bool // oflag=seek_bytes
+}
+
 // intermediateBuffer is a buffer that one can write to and read from.
 type intermediateBuffer interface {
 	io.ReaderFrom
@@ -85,15 +133,32 @@ type chunkedBuffer struct {
 
 // newChunkedBuffer returns an intermediateBuffer that stores inChunkSize-sized
 // chunks of data and writes them to writers in outChunkSize-sized chunks.
+//
+// If flags ask for direct I/O, the data is aligned as the kernel requires.
 func newChunkedBuffer(inChunkSize int64, outChunkSize int64, flags int) intermediateBuffer {
+	data := make([]byte, inChunkSize)
+	if directFlag != 0 && flags&directFlag != 0 && inChunkSize > 0 {
+		data = alignedBuffer(inChunkSize)
+	}
 	return &chunkedBuffer{
 		outChunk: outChunkSize,
 		length:   0,
-		data:     make([]byte, inChunkSize),
+		data:     data,
 		flags:    flags,
 	}
 }
 
+// alignedBuffer returns a buffer of size bytes starting at a multiple of
+// directAlign.
+func alignedBuffer(size int64) []byte {
+	b := make([]byte, size+directAlign)
+	off := directAlign - int(uintptr(unsafe.Pointer(&b[0]))&(directAlign-1))
+	if off == directAlign {
+		off = 0
+	}
+	return b[off : int64(off)+size : int64(off)+size]
+}
+
 // ReadFrom reads an inChunkSize-sized chunk from r into the buffer.
 func (cb *chunkedBuffer) ReadFrom(r io.Reader) (int64, error) {
 	n, err := r.Read(cb.data)
@@ -247,6 +312,121 @@ func parallelChunkedCopy(r io.Reader, w io.Writer, inBufSize, outBufSize int64,
 	}
 }
 
+// blockReader reads one input block per Read call, as chunkedBuffer does,
+// and counts full and partial blocks.
+type blockReader struct {
+	io.Reader
+	// fullblock makes Read retry short reads until the block is full.
+	fullblock bool
+	// pad makes Read fill partial blocks with NULs.
+	pad bool
+
+	full, partial int64
+}
+
+// Read implements io.Reader.
+func (b *blockReader) Read(p []byte) (int, error) {
+	var n int
+	var err error
+	if b.fullblock {
+		n, err = io.ReadFull(b.Reader, p)
+		if err == io.ErrUnexpectedEOF {
+			// The next read reports io.EOF.
+			err = nil
+		}
+	} else {
+		n, err = b.Reader.Read(p)
+	}
+	if n == 0 {
+		return 0, err
+	}
+	if n == len(p) {
+		b.full++
+		return n, err
+	}
+	b.partial++
+	if b.pad {
+		clear(p[n:])
+		n = len(p)
+	}
+	return n, err
+}
+
+// blockWriter writes output blocks and counts full and partial blocks.
+type blockWriter struct {
+	io.Writer
+	blockSize int64
+	// sparse makes Write seek over blocks of NULs if the output is a file.
+	sparse bool
+	// hole is set if the last block was skipped rather than written.
+	hole bool
+
+	full, partial int64
+}
+
+// Write implements io.Writer.
+func (b *blockWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) == b.blockSize {
+		b.full++
+	} else {
+		b.partial++
+	}
+	f, ok := b.Writer.(*os.File)
+	if b.sparse && ok && isZero(p) {
+		if _, err := f.Seek(int64(len(p)), io.SeekCurrent); err == nil {
+			b.hole = true
+			return len(p), nil
+		}
+	}
+	b.hole = false
+	n, err := b.Writer.Write(p)
+	if ok && n == 0 && errors.Is(err, syscall.EINVAL) && directFlag != 0 {
+		// Direct I/O only takes whole, aligned blocks, so the last
+		// partial block goes through the page cache.
+		if clearDirect(f) == nil {
+			return f.Write(p)
+		}
+	}
+	return n, err
+}
+
+// finish extends the output over a trailing hole and flushes it if sync is
+// set.
+func (b *blockWriter) finish(sync bool) error {
+	f, ok := b.Writer.(*os.File)
+	if !ok {
+		return nil
+	}
+	if b.hole {
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if fi.Size() < off {
+			if err := f.Truncate(off); err != nil {
+				return err
+			}
+		}
+	}
+	if sync {
+		return f.Sync()
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // sectionReader implements a SectionReader on an underlying implementation of
 // io.Reader (as opposed to io.SectionReader which uses io.ReaderAt).
 type sectionReader struct {
@@ -300,7 +480,7 @@ func (s *sectionReader) Read(p []byte) (int, error) {
 }
 
 // inFile opens the input file and seeks to the right position.
-func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count int64) (io.Reader, error) {
+func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count int64, flags int) (io.Reader, error) {
 	maxRead := int64(math.MaxInt64)
 	if count != math.MaxInt64 {
 		maxRead = count * inputBytes
@@ -312,11 +492,51 @@ func inFile(stdin io.Reader, name string, inputBytes int64, skip int64, count in
 		return newStreamSectionReader(stdin, inputBytes*skip, maxRead), nil
 	}
 
-	in, err := os.Open(name)
+	in, err := os.OpenFile(name, os.O_RDONLY|(flags&allowedFlags), 0)
 	if err != nil {
 		return nil, fmt.Errorf("error opening input file %q: %v", name, err)
 	}
-	return io.NewSectionReader(in, inputBytes*skip, maxRead), nil
+	return &fileSection{
+		SectionReader: io.NewSectionReader(in, inputBytes*skip, maxRead),
+		f:             in,
+		off:           inputBytes * skip,
+		n:             maxRead,
+	}, nil
+}
+
+// fileSection is the section of an input file to copy.
+type fileSection struct {
+	*io.SectionReader
+	f      *os.File
+	off, n int64
+}
+
+// maxFileBlock bounds the requests of copies between files, of which
+// uio keeps several in flight.
+const maxFileBlock = 16 << 20
+
+// copyFiles copies in to out with uio, which keeps many large requests in
+// flight to saturate fast devices, if both are files. It reports whether
+// they were.
+func copyFiles(in io.Reader, out io.Writer, bs int64, bytesWritten *int64) (int64, bool, error) {
+	fin, ok := in.(*fileSection)
+	if !ok {
+		return 0, false, nil
+	}
+	fout, ok := out.(*os.File)
+	if !ok {
+		return 0, false, nil
+	}
+	off, err := fout.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false, nil
+	}
+	c := uio.Copier{
+		BlockSize: int(min(max(bs, uio.DefaultBlockSize), maxFileBlock)),
+		Written:   bytesWritten,
+	}
+	n, err := c.CopyAt(fout, off, fin.f, fin.off, fin.n)
+	return n, true, err
 }
 
 // outFile opens the output file and seeks to the right position.
@@ -340,12 +560,45 @@ func outFile(stdout io.WriteSeeker, name string, outputBytes int64, seek int64,
 }
 
 func usage() {
-	log.Fatal(`Usage: dd [if=file] [of=file] [conv=none|notrunc] [seek=#] [skip=#]
-			     [count=#] [bs=#] [ibs=#] [obs=#] [status=none|xfer|progress] [oflag=none|sync|dsync]
+	log.Fatal(`Usage: dd [if=file] [of=file] [conv=none|notrunc|sync|fsync|fdatasync|sparse] [seek=#] [skip=#]
+			     [count=#] [bs=#] [ibs=#] [obs=#] [status=none|xfer|progress]
+			     [iflag=none|direct|fullblock|skip_bytes|count_bytes] [oflag=none|sync|dsync|direct|seek_bytes]
 		options may also be invoked Go-style as -opt value or -opt=value
 		bs, if specified, overrides ibs and obs`)
 }
 
+// parseFlags applies the comma separated list of names given for the option
+// kind to the open flags in m, or sets the matching options.
+func parseFlags(kind, list string, flags int, m map[string]bitClearAndSet, opts map[string]*bool) int {
+	if list == "none" {
+		return flags
+	}
+	for _, name := range strings.Split(list, ",") {
+		if v, ok := m[name]; ok {
+			flags &= ^v.clear
+			flags |= v.set
+		} else if o, ok := opts[name]; ok {
+			*o = true
+		} else {
+			log.Printf("unknown argument %s=%s", kind, name)
+			usage()
+		}
+	}
+	return flags
+}
+
+// bytesOf returns n blocks of size bytes in bytes, or n itself if inBytes
+// is set. Counts that do not fit are clamped to math.MaxInt64.
+func bytesOf(n, size int64, inBytes bool) int64 {
+	if inBytes || n == 0 {
+		return n
+	}
+	if n > math.MaxInt64/size {
+		return math.MaxInt64
+	}
+	return n * size
+}
+
 func convertArgs(osArgs []string) []string {
 	// EVERYTHING in dd follows x=y. So blindly split and convert.
 	var args []string
@@ -368,33 +621,62 @@ func main() {
 	}
 }
 
+// size is a unit.Value printed in bytes, so that the usage shows defaults
+// like 0 and 512 rather than 0E and 1b.
+type size unit.Value
+
+func newSize(u *unit.Unit, v int64) *size {
+	return (*size)(u.MustNewValue(v, unit.None))
+}
+
+// Set implements flag.Value.Set.
+func (s *size) Set(v string) error {
+	return (*unit.Value)(s).Set(v)
+}
+
+// String implements flag.Value.String.
+func (s *size) String() string {
+	// The zero size, which flag uses to tell whether a default is worth
+	// printing, has no unit and prints as "".
+	if (*unit.Value)(s).String() == "" {
+		return ""
+	}
+	return strconv.FormatInt(s.Value, 10)
+}
+
 func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string, args []string) error {
 	var f = flag.NewFlagSet(name, flag.ExitOnError)
 
 	var (
-		skip    = f.Int64("skip", 0, "skip N ibs-sized blocks before reading")
-		seek    = f.Int64("seek", 0, "seek N obs-sized blocks before writing")
-		conv    = f.String("conv", "none", "comma separated list of conversions (none|notrunc)")
-		count   = f.Int64("count", math.MaxInt64, "copy only N input blocks")
+		conv    = f.String("conv", "none", "comma separated list of conversions (none|notrunc|sync|fsync|fdatasync|sparse)")
 		inName  = f.String("if", "", "Input file")
 		outName = f.String("of", "", "Output file")
-		oFlag   = f.String("oflag", "none", "comma separated list of out flags (none|sync|dsync)")
+		iFlag   = f.String("iflag", "none", "comma separated list of in flags (none|direct|fullblock|skip_bytes|count_bytes)")
+		oFlag   = f.String("oflag", "none", "comma separated list of out flags (none|sync|dsync|direct|seek_bytes)")
 		status  = f.String("status", "xfer", "display status of transfer (none|xfer|progress)")
 	)
-	ddUnits := unit.DefaultUnits
+	ddUnits := maps.Clone(unit.DefaultUnits)
+	ddUnits["k"] = unit.K
 	ddUnits["c"] = 1
 	ddUnits["w"] = 2
 	ddUnits["b"] = 512
 	delete(ddUnits, "B")
 
+	u := unit.MustNewUnit(ddUnits)
 	var (
-		ibs = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
-		obs = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
-		bs  = unit.MustNewUnit(ddUnits).MustNewValue(512, unit.None)
+		ibs   = newSize(u, 512)
+		obs   = newSize(u, 512)
+		bs    = newSize(u, 512)
+		skip  = newSize(u, 0)
+		seek  = newSize(u, 0)
+		count = newSize(u, 0)
 	)
 	f.Var(ibs, "ibs", "Default input block size")
 	f.Var(obs, "obs", "Default output block size")
 	f.Var(bs, "bs", "Default input and output block size")
+	f.Var(skip, "skip", "skip N ibs-sized blocks before reading")
+	f.Var(seek, "seek", "seek N obs-sized blocks before writing")
+	f.Var(count, "count", "copy only N input blocks")
 
 	// rather than, in essence, recreating all the apparatus of flag.xxxx
 	// with the if= bits, including dup checking, conversion, etc. we just
@@ -407,32 +689,22 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		usage()
 	}
 
-	// Convert conv argument to bit set.
-	flags := os.O_TRUNC
-	if *conv != "none" {
-		for _, c := range strings.Split(*conv, ",") {
-			if v, ok := convMap[c]; ok {
-				flags &= ^v.clear
-				flags |= v.set
-			} else {
-				log.Printf("unknown argument conv=%s", c)
-				usage()
-			}
-		}
-	}
-
-	// Convert oflag argument to bit set.
-	if *oFlag != "none" {
-		for _, f := range strings.Split(*oFlag, ",") {
-			if v, ok := flagMap[f]; ok {
-				flags &= ^v.clear
-				flags |= v.set
-			} else {
-				log.Printf("unknown argument oflag=%s", f)
-				usage()
-			}
-		}
-	}
+	// Convert the conv, iflag and oflag arguments to bit sets and options.
+	var opts options
+	flags := parseFlags("conv", *conv, os.O_TRUNC, convMap, map[string]*bool{
+		"sync":      &opts.sync,
+		"fsync":     &opts.fsync,
+		"fdatasync": &opts.fsync,
+		"sparse":    &opts.sparse,
+	})
+	flags = parseFlags("oflag", *oFlag, flags, flagMap, map[string]*bool{
+		"seek_bytes": &opts.seekBytes,
+	})
+	iflags := parseFlags("iflag", *iFlag, 0, iflagMap, map[string]*bool{
+		"fullblock":   &opts.fullblock,
+		"skip_bytes":  &opts.skipBytes,
+		"count_bytes": &opts.countBytes,
+	})
 
 	if *status != "none" && *status != "xfer" && *status != "progress" {
 		usage()
@@ -447,19 +719,50 @@ func run(stdin io.Reader, stdout io.WriteSeeker, stderr io.Writer, name string,
 		ibs = bs
 		obs = bs
 	}
+	if ibs.Value <= 0 || obs.Value <= 0 {
+		return fmt.Errorf("block sizes must be positive")
+	}
 
-	in, err := inFile(stdin, *inName, ibs.Value, *skip, *count)
+	maxRead := int64(math.MaxInt64)
+	if count.IsSet {
+		maxRead = bytesOf(count.Value, ibs.Value, opts.countBytes)
+	}
+	in, err := inFile(stdin, *inName, 1, bytesOf(skip.Value, ibs.Value, opts.skipBytes), maxRead, iflags)
 	if err != nil {
 		return err
 	}
-	out, err := outFile(stdout, *outName, obs.Value, *seek, flags)
+	out, err := outFile(stdout, *outName, 1, bytesOf(seek.Value, obs.Value, opts.seekBytes), flags)
 	if err != nil {
 		return err
 	}
-	if err := parallelChunkedCopy(in, out, ibs.Value, obs.Value, &bytesWritten, flags); err != nil {
-		return err
+
+	r := &blockReader{Reader: in, fullblock: opts.fullblock, pad: opts.sync}
+	w := &blockWriter{Writer: out, blockSize: obs.Value, sparse: opts.sparse}
+	// Blocks between files which need no conversion are copied in bulk.
+	copied := false
+	if *outName != "" && ibs.Value == obs.Value && !opts.sync && !opts.sparse {
+		n, ok, err := copyFiles(in, out, ibs.Value, &bytesWritten)
+		if err != nil {
+			return fmt.Errorf("copy error: %w", err)
+		}
+		if ok {
+			copied = true
+			r.full, r.partial = n/ibs.Value, min(n%ibs.Value, 1)
+			w.full, w.partial = r.full, r.partial
+		}
+	}
+	if !copied {
+		if err := parallelChunkedCopy(r, w, ibs.Value, obs.Value, &bytesWritten, flags|iflags); err != nil {
+			return err
+		}
+	}
+	if err := w.finish(opts.fsync); err != nil {
+		return fmt.Errorf("output error: %w", err)
 	}
 
-	progress.End()
+	progress.EndWith(
+		fmt.Sprintf("%d+%d records in", r.full, r.partial),
+		fmt.Sprintf("%d+%d records out", w.full, w.partial),
+	)
 	return nil
 }
@@ -4,9 +4,27 @@
 
 package main
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 func init() {
 	flagMap["dsync"] = bitClearAndSet{set: syscall.O_DSYNC}
 	allowedFlags |= syscall.O_DSYNC
+
+	flagMap["direct"] = bitClearAndSet{set: syscall.O_DIRECT}
+	iflagMap["direct"] = bitClearAndSet{set: syscall.O_DIRECT}
+	allowedFlags |= syscall.O_DIRECT
+	directFlag = syscall.O_DIRECT
+	clearDirect = func(f *os.File) error {
+		fl, err := unix.FcntlInt(f.Fd(), unix.F_GETFL, 0)
+		if err != nil {
+			return err
+		}
+		_, err = unix.FcntlInt(f.Fd(), unix.F_SETFL, fl&^unix.O_DIRECT)
+		return err
+	}
 }
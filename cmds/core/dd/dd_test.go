@@ -7,14 +7,20 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"unsafe"
+
+	"github.com/rck/unit"
 )
 
 type ws struct {
@@ -334,7 +340,7 @@ func TestInFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := inFile(&bytes.Buffer{}, p, tt.outputBytes, tt.seek, tt.count)
+			_, err := inFile(&bytes.Buffer{}, p, tt.outputBytes, tt.seek, tt.count, 0)
 			if err != nil && !tt.wantErr {
 				t.Errorf("outFile failed with %v", err)
 			}
@@ -453,6 +459,21 @@ func TestConvertArgs(t *testing.T) {
 	}
 }
 
+func TestSizeDefaults(t *testing.T) {
+	u := unit.MustNewUnit(unit.DefaultUnits)
+	f := flag.NewFlagSet("dd", flag.ContinueOnError)
+	f.Var(newSize(u, 0), "count", "")
+	f.Var(newSize(u, 512), "bs", "")
+	var out strings.Builder
+	f.SetOutput(&out)
+	f.PrintDefaults()
+	for _, want := range []string{"(default 0)", "(default 512)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage %q does not contain %q", out.String(), want)
+		}
+	}
+}
+
 // TestDd implements a table-driven test.
 func TestDd(t *testing.T) {
 	tests := []struct {
@@ -508,6 +529,22 @@ func TestDd(t *testing.T) {
 			count:   64 * 1024,
 			compare: byteCount,
 		},
+		{
+			name:    "Create a 64KiB zeroed file in 4k blocks",
+			flags:   []string{"if=/dev/zero", "bs=4k", "count=16"},
+			stdin:   "",
+			stdout:  []byte("\x00"),
+			count:   64 * 1024,
+			compare: byteCount,
+		},
+		{
+			name:    "Create a 1MiB zeroed file with count=1M bytes",
+			flags:   []string{"if=/dev/zero", "bs=4k", "count=1M", "iflag=count_bytes"},
+			stdin:   "",
+			stdout:  []byte("\x00"),
+			count:   1024 * 1024,
+			compare: byteCount,
+		},
 		{
 			name:    "Use skip and count",
 			flags:   []string{"skip=6", "bs=1", "count=5"},
@@ -648,6 +685,19 @@ func TestFiles(t *testing.T) {
 			outFile:  []byte("abcde"),
 			expected: []byte("1234e"),
 		},
+		{
+			name:     "several requests",
+			flags:    []string{"bs=1M"},
+			inFile:   bytes.Repeat([]byte("0123456789abcdef"), 3<<16+1),
+			expected: bytes.Repeat([]byte("0123456789abcdef"), 3<<16+1),
+		},
+		{
+			name:     "skip, seek and count of several requests",
+			flags:    []string{"bs=512", "skip=1", "seek=2", "count=5000", "conv=notrunc"},
+			inFile:   bytes.Repeat([]byte("0123456789abcdef"), 200000),
+			outFile:  bytes.Repeat([]byte("x"), 1024),
+			expected: append(bytes.Repeat([]byte("x"), 1024), bytes.Repeat([]byte("0123456789abcdef"), 200000)[512:512+5000*512]...),
+		},
 		{
 			// Fully testing the file is synchronous would require something more.
 			name:     "sync",
@@ -699,6 +749,131 @@ func TestFiles(t *testing.T) {
 	}
 }
 
+// TestConversions checks conversions, flags and suffixes on stdin and stdout.
+func TestConversions(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		stdin string
+		want  string
+		stats string
+	}{
+		{
+			name:  "sync pads the last block",
+			flags: []string{"bs=4", "conv=sync"},
+			stdin: "hello",
+			want:  "hell" + "o\x00\x00\x00",
+			stats: "1+1 records in\n2+0 records out\n",
+		},
+		{
+			name:  "skip and count take suffixes",
+			flags: []string{"ibs=1c", "skip=1w", "count=1w"},
+			stdin: "hello",
+			want:  "ll",
+			stats: "2+0 records in\n0+2 records out\n",
+		},
+		{
+			name:  "skip_bytes and count_bytes",
+			flags: []string{"bs=4", "skip=3", "count=5", "iflag=skip_bytes,count_bytes"},
+			stdin: "hello world",
+			want:  "lo wo",
+			stats: "1+1 records in\n1+1 records out\n",
+		},
+		{
+			name:  "fullblock",
+			flags: []string{"bs=8", "iflag=fullblock"},
+			stdin: "hello world",
+			want:  "hello world",
+			stats: "1+1 records in\n1+1 records out\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// A reader returning one byte at a time makes every read short.
+			stdin := iotest.OneByteReader(strings.NewReader(tt.stdin))
+			if !slices.Contains(tt.flags, "iflag=fullblock") {
+				stdin = strings.NewReader(tt.stdin)
+			}
+			var stdout, stderr bytes.Buffer
+			if err := run(stdin, &ws{Writer: &stdout}, &stderr, "dd", tt.flags); err != nil {
+				t.Fatalf("run: got %v, want nil", err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if got := stderr.String(); !strings.HasPrefix(got, tt.stats) {
+				t.Errorf("statistics = %q, want prefix %q", got, tt.stats)
+			}
+		})
+	}
+}
+
+// TestSparse checks that conv=sparse leaves holes, including a trailing one.
+func TestSparse(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	data := append(append(bytes.Repeat([]byte{0}, 4096), "data"...), make([]byte, 8192)...)
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"bs=4K", "conv=sparse,fsync", "seek=1", "if=" + in, "of=" + out}
+	if err := run(&bytes.Buffer{}, &ws{Writer: io.Discard}, io.Discard, "dd", args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(make([]byte, 4096), data...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("sparse copy has %d bytes, want %d bytes of the input", len(got), len(want))
+	}
+}
+
+// TestDirect copies a file with direct I/O, if the file system allows it.
+func TestDirect(t *testing.T) {
+	if directFlag == 0 {
+		t.Skipf("no direct I/O on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(in, os.O_RDONLY|directFlag, 0)
+	if err != nil {
+		t.Skipf("file system does not support direct I/O: %v", err)
+	}
+	f.Close()
+
+	args := []string{"bs=4K", "iflag=direct", "oflag=direct", "if=" + in, "of=" + out}
+	if err := run(&bytes.Buffer{}, &ws{Writer: io.Discard}, io.Discard, "dd", args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("direct copy has %d bytes, want the %d input bytes", len(got), len(data))
+	}
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, size := range []int64{1, 512, 4096, 65536} {
+		b := alignedBuffer(size)
+		if int64(len(b)) != size || int64(cap(b)) != size {
+			t.Errorf("alignedBuffer(%d) has len %d and cap %d, want %d", size, len(b), cap(b), size)
+		}
+		if p := uintptr(unsafe.Pointer(&b[0])); p%directAlign != 0 {
+			t.Errorf("alignedBuffer(%d) starts at %#x, want a multiple of %d", size, p, directAlign)
+		}
+	}
+}
+
 // BenchmarkDd benchmarks the dd command. Each "op" unit is a 1MiB block.
 func BenchmarkDd(b *testing.B) {
 	const bytesPerOp = 1024 * 1024
@@ -8,7 +8,7 @@
 //
 // Synopsis
 //
-//	df [-k] [-m]
+//	df [-k] [-m] [-h] [-i] [--total] [-x TYPE]... [--json] [FILE...]
 //
 // Description
 //
@@ -17,14 +17,23 @@
 //	mount points that have a non-zero block count.
 //	Users can choose to see the diplay in KB or MB.
 //
+//	With --json, one JSON object is written, with sizes in bytes, for
+//	scripts to read rather than the columns.
+//
 // Options
 //
 //	-k: display values in KB (default)
 //	-m: dispaly values in MB
+//	-h: display values in human-readable form, in powers of 1024
+//	-i: display inode usage rather than block usage
+//	--total: display a total of the filesystems shown
+//	-x: exclude filesystems of TYPE; may be given more than once
+//	--json: display JSON, with both block and inode usage
 package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -32,12 +41,22 @@ import (
 	"log"
 	"math"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"syscall"
+
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
 type flags struct {
-	k bool
-	m bool
+	k       bool
+	m       bool
+	h       bool
+	i       bool
+	total   bool
+	json    bool
+	exclude unixflag.StringArray
 }
 
 var (
@@ -45,11 +64,17 @@ var (
 	units uint64
 
 	errKMExclusiv = errors.New("options -k and -m are mutually exclusive")
+	errHExclusive = errors.New("option -h is mutually exclusive with -k and -m")
 )
 
 func init() {
 	flag.BoolVar(&fargs.k, "k", false, "Express the values in kilobytes (default)")
 	flag.BoolVar(&fargs.m, "m", false, "Express the values in megabytes")
+	flag.BoolVar(&fargs.h, "h", false, "Express the values in human-readable form")
+	flag.BoolVar(&fargs.i, "i", false, "Show inode usage rather than block usage")
+	flag.BoolVar(&fargs.total, "total", false, "Show a total of the filesystems shown")
+	flag.BoolVar(&fargs.json, "json", false, "Use JSON for output")
+	flag.Var(&fargs.exclude, "x", "Exclude filesystems of `type`")
 }
 
 const (
@@ -65,16 +90,20 @@ const (
 
 // Mount is a structure used to contain mount point data
 type mount struct {
-	Device         string
-	MountPoint     string
-	FileSystemType string
-	Flags          string
-	Bsize          int64
-	Blocks         uint64
-	Total          uint64
-	Used           uint64
-	Avail          uint64
-	PCT            uint8
+	Device         string `json:"filesystem"`
+	MountPoint     string `json:"mounted_on"`
+	FileSystemType string `json:"type"`
+	Flags          string `json:"-"`
+	Bsize          int64  `json:"block_size"`
+	// Size, Used and Avail are in bytes.
+	Size   uint64 `json:"size"`
+	Used   uint64 `json:"used"`
+	Avail  uint64 `json:"available"`
+	PCT    uint8  `json:"use_percent"`
+	Inodes uint64 `json:"inodes"`
+	IUsed  uint64 `json:"inodes_used"`
+	IFree  uint64 `json:"inodes_free"`
+	IPCT   uint8  `json:"inodes_use_percent"`
 }
 
 type mountinfomap map[string]mount
@@ -110,7 +139,7 @@ func mountinfoFromBytes(buf []byte) (mountinfomap, error) {
 		if err := diskUsage(&mnt); err != nil {
 			return nil, err
 		}
-		if mnt.Blocks == 0 {
+		if mnt.Size == 0 {
 			continue
 		}
 		ret[key] = mnt
@@ -129,16 +158,44 @@ func diskUsage(mnt *mount) error {
 		}
 		return err
 	}
-	mnt.Blocks = fs.Blocks * uint64(fs.Bsize) / units
 	mnt.Bsize = int64(fs.Bsize)
-	mnt.Total = fs.Blocks * uint64(fs.Bsize) / units
-	mnt.Avail = fs.Bavail * uint64(fs.Bsize) / units
-	mnt.Used = (fs.Blocks - fs.Bfree) * uint64(fs.Bsize) / units
-	pct := float64((fs.Blocks - fs.Bfree)) * 100 / float64(fs.Blocks)
-	mnt.PCT = uint8(math.Ceil(pct))
+	mnt.Size = fs.Blocks * uint64(fs.Bsize)
+	// Bavail and Ffree are signed on FreeBSD, and Bavail is negative when
+	// root uses the reserved blocks.
+	mnt.Avail = uint64(max(fs.Bavail, 0)) * uint64(fs.Bsize)
+	mnt.Used = (fs.Blocks - fs.Bfree) * uint64(fs.Bsize)
+	mnt.PCT = percent(fs.Blocks-fs.Bfree, fs.Blocks)
+	mnt.Inodes = fs.Files
+	mnt.IFree = uint64(max(fs.Ffree, 0))
+	mnt.IUsed = fs.Files - mnt.IFree
+	mnt.IPCT = percent(mnt.IUsed, mnt.Inodes)
 	return nil
 }
 
+// percent returns how many percent of total used is, rounded up.
+func percent(used, total uint64) uint8 {
+	if total == 0 {
+		return 0
+	}
+	return uint8(math.Ceil(float64(used) * 100 / float64(total)))
+}
+
+// total returns the sum of mnts.
+func total(mnts []mount) mount {
+	t := mount{Device: "total", MountPoint: "-", FileSystemType: "-"}
+	for _, mnt := range mnts {
+		t.Size += mnt.Size
+		t.Used += mnt.Used
+		t.Avail += mnt.Avail
+		t.Inodes += mnt.Inodes
+		t.IUsed += mnt.IUsed
+		t.IFree += mnt.IFree
+	}
+	t.PCT = percent(t.Used, t.Size)
+	t.IPCT = percent(t.IUsed, t.Inodes)
+	return t
+}
+
 // setUnits takes the command line flags and configures
 // the correct units used to calculate display values
 func setUnits(inKB, inMB bool) error {
@@ -153,78 +210,156 @@ func setUnits(inKB, inMB bool) error {
 	return nil
 }
 
-func printHeader(w io.Writer, blockSize string) {
-	fmt.Fprintf(w, "Filesystem           Type         %v-blocks       Used    Available  Use%% Mounted on\n", blockSize)
+var suffixes = [...]string{"K", "M", "G", "T", "P", "E"}
+
+// human returns n bytes in powers of 1024 with a suffix, rounded up, with
+// one decimal below 10, as in 1.5G or 20M.
+func human(n uint64) string {
+	if n < KB {
+		return strconv.FormatUint(n, 10)
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(suffixes)-1 {
+		v /= 1024
+		i++
+	}
+	if v < 10 {
+		if v = math.Ceil(v*10) / 10; v < 10 {
+			return fmt.Sprintf("%.1f%s", v, suffixes[i])
+		}
+	}
+	return fmt.Sprintf("%.0f%s", math.Ceil(v), suffixes[i])
+}
+
+func (f flags) size(n uint64) string {
+	if f.h {
+		return human(n)
+	}
+	return strconv.FormatUint(n/units, 10)
 }
 
-func printMount(w io.Writer, mnt mount) {
+func printHeader(w io.Writer, fargs flags) {
+	size := "1K-blocks"
+	switch {
+	case fargs.i:
+		fmt.Fprintf(w, "%-20v %-9v %12v %10v %12v %5v %v\n", "Filesystem", "Type", "Inodes", "IUsed", "IFree", "IUse%", "Mounted on")
+		return
+	case fargs.h:
+		size = "Size"
+	case fargs.m:
+		size = "1M-blocks"
+	}
+	fmt.Fprintf(w, "%-20v %-9v %12v %10v %12v %5v %v\n", "Filesystem", "Type", size, "Used", "Available", "Use%", "Mounted on")
+}
+
+func printMount(w io.Writer, fargs flags, mnt mount) {
+	if fargs.i {
+		fmt.Fprintf(w, "%-20v %-9v %12v %10v %12v %4v%% %-13v\n",
+			mnt.Device,
+			mnt.FileSystemType,
+			mnt.Inodes,
+			mnt.IUsed,
+			mnt.IFree,
+			mnt.IPCT,
+			mnt.MountPoint)
+		return
+	}
 	fmt.Fprintf(w, "%-20v %-9v %12v %10v %12v %4v%% %-13v\n",
 		mnt.Device,
 		mnt.FileSystemType,
-		mnt.Blocks,
-		mnt.Used,
-		mnt.Avail,
+		fargs.size(mnt.Size),
+		fargs.size(mnt.Used),
+		fargs.size(mnt.Avail),
 		mnt.PCT,
 		mnt.MountPoint)
 }
 
+// report is the JSON output of df.
+type report struct {
+	Filesystems []mount `json:"filesystems"`
+	Total       *mount  `json:"total,omitempty"`
+}
+
 func df(w io.Writer, fargs flags, args []string) error {
 	if err := setUnits(fargs.k, fargs.m); err != nil {
 		return err
 	}
+	if fargs.h && (fargs.k || fargs.m) {
+		return errHExclusive
+	}
 	mounts, err := mountinfo()
 	if err != nil {
 		return fmt.Errorf("mountinfo()=_,%q, want: _,nil", err)
 	}
-	blocksize := "1K"
-	if fargs.m {
-		blocksize = "1M"
-	}
 
-	if len(args) == 0 {
-		printHeader(w, blocksize)
-		for _, mnt := range mounts {
-			printMount(w, mnt)
+	var points []string
+	for point, mnt := range mounts {
+		if !slices.Contains(fargs.exclude, mnt.FileSystemType) {
+			points = append(points, point)
 		}
-
-		return nil
 	}
+	sort.Strings(points)
 
-	var fileDevs []uint64
-	for _, arg := range args {
-		fileDev, err := deviceNumber(arg)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "df: %v\n", err)
-			continue
+	var shown []mount
+	if len(args) == 0 {
+		for _, point := range points {
+			shown = append(shown, mounts[point])
 		}
+	} else {
+		var fileDevs []uint64
+		for _, arg := range args {
+			fileDev, err := deviceNumber(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "df: %v\n", err)
+				continue
+			}
 
-		fileDevs = append(fileDevs, fileDev)
-	}
-
-	showHeader := true
-	for _, mnt := range mounts {
-		stDev, err := deviceNumber(mnt.MountPoint)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "df: %v\n", err)
-			continue
+			fileDevs = append(fileDevs, fileDev)
 		}
 
-		for _, fDev := range fileDevs {
-			if fDev == stDev {
-				if showHeader {
-					printHeader(w, blocksize)
-					showHeader = false
+		for _, point := range points {
+			stDev, err := deviceNumber(point)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "df: %v\n", err)
+				continue
+			}
+
+			for _, fDev := range fileDevs {
+				if fDev == stDev {
+					shown = append(shown, mounts[point])
 				}
-				printMount(w, mnt)
 			}
 		}
 	}
 
+	if fargs.json {
+		r := report{Filesystems: shown}
+		if r.Filesystems == nil {
+			r.Filesystems = []mount{}
+		}
+		if fargs.total {
+			t := total(shown)
+			r.Total = &t
+		}
+		return json.NewEncoder(w).Encode(r)
+	}
+
+	if len(shown) == 0 {
+		return nil
+	}
+	printHeader(w, fargs)
+	for _, mnt := range shown {
+		printMount(w, fargs, mnt)
+	}
+	if fargs.total {
+		printMount(w, fargs, total(shown))
+	}
 	return nil
 }
 
 func main() {
-	flag.Parse()
+	flag.CommandLine.Parse(unixflag.OSArgsToGoArgs())
 	if err := df(os.Stdout, fargs, flag.Args()); err != nil {
 		log.Fatal(err)
 	}
@@ -8,6 +8,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"os"
 	"testing"
@@ -51,6 +52,28 @@ func TestRunDF(t *testing.T) {
 			name: "Dir as argument",
 			args: []string{os.TempDir()},
 		},
+		{
+			name: "Human-Total",
+			fargs: flags{
+				h:     true,
+				total: true,
+			},
+		},
+		{
+			name: "Inodes-Exclude",
+			fargs: flags{
+				i:       true,
+				exclude: []string{"tmpfs", "ext4"},
+			},
+		},
+		{
+			name: "Human-M-Flag",
+			fargs: flags{
+				h: true,
+				m: true,
+			},
+			wantErr: errHExclusive,
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			var buf bytes.Buffer
@@ -60,3 +83,60 @@ func TestRunDF(t *testing.T) {
 		})
 	}
 }
+
+func TestHuman(t *testing.T) {
+	for _, tt := range []struct {
+		in   uint64
+		want string
+	}{
+		{in: 0, want: "0"},
+		{in: 1023, want: "1023"},
+		{in: 1024, want: "1.0K"},
+		{in: 1536, want: "1.5K"},
+		{in: 1537, want: "1.6K"},
+		{in: 10 * KB, want: "10K"},
+		{in: 10*KB - 1, want: "10K"},
+		{in: 20*MB + 1, want: "21M"},
+		{in: 3 * 1024 * MB, want: "3.0G"},
+	} {
+		if got := human(tt.in); got != tt.want {
+			t.Errorf("human(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTotal(t *testing.T) {
+	got := total([]mount{
+		{Size: 100, Used: 30, Avail: 70, Inodes: 10, IUsed: 1, IFree: 9},
+		{Size: 300, Used: 71, Avail: 229, Inodes: 0},
+	})
+	want := mount{
+		Device: "total", MountPoint: "-", FileSystemType: "-",
+		Size: 400, Used: 101, Avail: 299, PCT: 26,
+		Inodes: 10, IUsed: 1, IFree: 9, IPCT: 10,
+	}
+	if got != want {
+		t.Errorf("total() = %+v, want %+v", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := df(&buf, flags{json: true, total: true}, []string{os.TempDir()}); err != nil {
+		t.Fatalf("df(--json --total) = %v", err)
+	}
+	var r report
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatalf("df(--json) wrote %q: %v", buf.String(), err)
+	}
+	if r.Total == nil {
+		t.Fatalf("df(--json --total) has no total")
+	}
+	var size uint64
+	for _, mnt := range r.Filesystems {
+		size += mnt.Size
+	}
+	if r.Total.Size != size {
+		t.Errorf("total size = %d, want %d", r.Total.Size, size)
+	}
+}
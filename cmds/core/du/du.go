@@ -0,0 +1,270 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9 && !windows
+
+// du reports the disk usage of files.
+//
+// Synopsis:
+//
+//	du [-a] [-s] [-c] [-h] [-k] [-x] [--exclude PATTERN]... [--json] [FILE...]
+//
+// Description:
+//
+//	du reports the disk usage of each FILE, the current directory by
+//	default, and of each directory in them, in kilobytes. Symlinks are not
+//	followed, and files with several links are counted once.
+//
+//	With --json, one JSON object is written, with sizes in bytes, for
+//	scripts to read rather than the columns.
+//
+// Options:
+//
+//	-a: report files as well as directories
+//	-s: report only the total of each FILE
+//	-c: report the total of all FILEs
+//	-h: report sizes in human-readable form, in powers of 1024
+//	-k: report sizes in kilobytes (default)
+//	-x: skip directories on other file systems
+//	--exclude: skip files whose name or path matches PATTERN; may be given
+//	more than once
+//	--json: report JSON
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+const (
+	// KB is kilobytes
+	KB = 1024
+
+	// blockSize is the unit of st_blocks.
+	blockSize = 512
+)
+
+var errASExclusive = errors.New("options -a and -s are mutually exclusive")
+
+type flags struct {
+	all       bool
+	summarize bool
+	total     bool
+	human     bool
+	k         bool
+	oneFS     bool
+	json      bool
+	exclude   unixflag.StringArray
+}
+
+// file identifies a file, to count files with several links once.
+type file struct {
+	dev, ino uint64
+}
+
+// usage is the disk usage of a file, and of what is in it.
+type usage struct {
+	Path string `json:"path"`
+	// Size is in bytes.
+	Size uint64 `json:"size"`
+}
+
+// report is the JSON output of du.
+type report struct {
+	Files []usage `json:"files"`
+	Total *uint64 `json:"total,omitempty"`
+}
+
+type cmd struct {
+	stdout io.Writer
+	stderr io.Writer
+	flags
+	seen  map[file]bool
+	files []usage
+}
+
+func command(stdout, stderr io.Writer, f flags) *cmd {
+	return &cmd{
+		stdout: stdout,
+		stderr: stderr,
+		flags:  f,
+		seen:   map[file]bool{},
+	}
+}
+
+// excluded reports whether path, named name, matches an exclude pattern.
+func (c *cmd) excluded(path, name string) bool {
+	for _, pattern := range c.exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// report adds the usage of path, at depth below a FILE, to the output.
+func (c *cmd) report(path string, size uint64, dir bool, depth int) {
+	if depth > 0 && (c.summarize || (!dir && !c.all)) {
+		return
+	}
+	c.files = append(c.files, usage{Path: path, Size: size})
+}
+
+// du returns the disk usage of path and of what is in it, and reports it.
+// dev is the device of the FILE path is in, for -x. Errors are written to
+// stderr as they happen, and the first is returned.
+func (c *cmd) du(path string, depth int, dev uint64) (uint64, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "du: %v\n", err)
+		return 0, err
+	}
+	var size uint64
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		id := file{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		if depth == 0 {
+			dev = id.dev
+		}
+		if c.oneFS && id.dev != dev {
+			return 0, nil
+		}
+		if c.seen[id] {
+			return 0, nil
+		}
+		if st.Nlink > 1 || fi.IsDir() {
+			c.seen[id] = true
+		}
+		size = uint64(st.Blocks) * blockSize
+	}
+	if !fi.IsDir() {
+		c.report(path, size, false, depth)
+		return size, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "du: %v\n", err)
+	}
+	var firstErr error
+	for _, e := range entries {
+		p := filepath.Join(path, e.Name())
+		if c.excluded(p, e.Name()) {
+			continue
+		}
+		n, err := c.du(p, depth+1, dev)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		size += n
+	}
+	c.report(path, size, true, depth)
+	if firstErr == nil {
+		firstErr = err
+	}
+	return size, firstErr
+}
+
+var suffixes = [...]string{"K", "M", "G", "T", "P", "E"}
+
+// human returns n bytes in powers of 1024 with a suffix, rounded up, with
+// one decimal below 10, as in 1.5G or 20M.
+func human(n uint64) string {
+	if n < KB {
+		return strconv.FormatUint(n, 10)
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(suffixes)-1 {
+		v /= 1024
+		i++
+	}
+	if v < 10 {
+		if v = math.Ceil(v*10) / 10; v < 10 {
+			return fmt.Sprintf("%.1f%s", v, suffixes[i])
+		}
+	}
+	return fmt.Sprintf("%.0f%s", math.Ceil(v), suffixes[i])
+}
+
+func (c *cmd) size(n uint64) string {
+	if c.human {
+		return human(n)
+	}
+	return strconv.FormatUint((n+KB-1)/KB, 10)
+}
+
+func (c *cmd) run(args ...string) error {
+	if c.all && c.summarize {
+		return errASExclusive
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var (
+		total    uint64
+		finalErr error
+	)
+	for _, path := range args {
+		n, err := c.du(path, 0, 0)
+		if err != nil {
+			finalErr = err
+		}
+		total += n
+	}
+
+	if c.json {
+		r := report{Files: c.files}
+		if r.Files == nil {
+			r.Files = []usage{}
+		}
+		if c.total {
+			r.Total = &total
+		}
+		if err := json.NewEncoder(c.stdout).Encode(r); err != nil {
+			return err
+		}
+		return finalErr
+	}
+	for _, u := range c.files {
+		fmt.Fprintf(c.stdout, "%s\t%s\n", c.size(u.Size), u.Path)
+	}
+	if c.total {
+		fmt.Fprintf(c.stdout, "%s\ttotal\n", c.size(total))
+	}
+	return finalErr
+}
+
+func main() {
+	var f flags
+	flag.BoolVar(&f.all, "a", false, "Report files as well as directories")
+	flag.BoolVar(&f.summarize, "s", false, "Report only the total of each file")
+	flag.BoolVar(&f.total, "c", false, "Report the total of all files")
+	flag.BoolVar(&f.total, "total", false, "Report the total of all files")
+	flag.BoolVar(&f.human, "h", false, "Report sizes in human-readable form")
+	flag.BoolVar(&f.k, "k", false, "Report sizes in kilobytes (default)")
+	flag.BoolVar(&f.oneFS, "x", false, "Skip directories on other file systems")
+	flag.BoolVar(&f.json, "json", false, "Use JSON for output")
+	flag.Var(&f.exclude, "exclude", "Skip files matching `pattern`")
+	flag.CommandLine.Parse(unixflag.OSArgsToGoArgs())
+	if err := command(os.Stdout, os.Stderr, f).run(flag.Args()...); err != nil {
+		if errors.Is(err, errASExclusive) {
+			fmt.Fprintf(os.Stderr, "du: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9 && !windows
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tree(t *testing.T) string {
+	t.Helper()
+	d := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(d, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a/f", "a/b/g.o", "h"} {
+		if err := os.WriteFile(filepath.Join(d, f), bytes.Repeat([]byte{1}, 8192), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(d, "h"), filepath.Join(d, "a", "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/", filepath.Join(d, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDu(t *testing.T) {
+	d := tree(t)
+	for _, tt := range []struct {
+		name  string
+		flags flags
+		args  []string
+		want  []string
+		err   error
+	}{
+		{
+			name: "dirs",
+			args: []string{d},
+			want: []string{"a/b", "a", "."},
+		},
+		{
+			name:  "all",
+			flags: flags{all: true},
+			args:  []string{d},
+			// h was counted through its other link in a.
+			want: []string{"a/b/g.o", "a/b", "a/f", "a/hardlink", "a/link", "a", "."},
+		},
+		{
+			name:  "summarize",
+			flags: flags{summarize: true},
+			args:  []string{filepath.Join(d, "a", "b"), filepath.Join(d, "h")},
+			want:  []string{"a/b", "h"},
+		},
+		{
+			name:  "exclude",
+			flags: flags{all: true, exclude: []string{"*.o", filepath.Join(d, "a", "f")}},
+			args:  []string{filepath.Join(d, "a")},
+			want:  []string{"a/b", "a/hardlink", "a/link", "a"},
+		},
+		{
+			name:  "all and summarize",
+			flags: flags{all: true, summarize: true},
+			err:   errASExclusive,
+		},
+		{
+			name: "missing",
+			args: []string{filepath.Join(d, "missing")},
+			err:  os.ErrNotExist,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := command(&out, io.Discard, tt.flags).run(tt.args...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("du(%+v, %q) = %v, want %v", tt.flags, tt.args, err, tt.err)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if _, path, ok := strings.Cut(line, "\t"); ok {
+					rel, err := filepath.Rel(d, path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, rel)
+				}
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("du(%+v, %q) reported %q, want %q", tt.flags, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuJSON(t *testing.T) {
+	d := tree(t)
+	var out bytes.Buffer
+	c := command(&out, io.Discard, flags{summarize: true, total: true, json: true})
+	if err := c.run(filepath.Join(d, "a", "b"), filepath.Join(d, "h")); err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("du --json wrote %q: %v", out.String(), err)
+	}
+	if len(r.Files) != 2 || r.Total == nil {
+		t.Fatalf("du --json = %+v, want 2 files and a total", r)
+	}
+	if got := r.Files[0].Size + r.Files[1].Size; *r.Total != got {
+		t.Errorf("total = %d, want %d", *r.Total, got)
+	}
+	if r.Files[1].Size < 8192 {
+		t.Errorf("size of h = %d, want at least 8192", r.Files[1].Size)
+	}
+}
+
+func TestHuman(t *testing.T) {
+	for _, tt := range []struct {
+		in   uint64
+		want string
+	}{
+		{in: 0, want: "0"},
+		{in: 1023, want: "1023"},
+		{in: 1024, want: "1.0K"},
+		{in: 1537, want: "1.6K"},
+		{in: 10*KB - 1, want: "10K"},
+		{in: 1 << 30, want: "1.0G"},
+	} {
+		if got := human(tt.in); got != tt.want {
+			t.Errorf("human(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -12,26 +12,56 @@
 //	-type: match against a file type, e.g. -type f will match files
 //	-name: glob to match against file
 //	-l: long listing. It's not very good, yet, but it's useful enough.
+//	-size [+-]n[bcwkMG]: match files of more (+), less (-) or exactly n
+//	    units, rounded up; the default unit is 512-byte blocks
+//	-mtime [+-]n: match files modified more, less or exactly n days ago
+//	-newer file: match files modified more recently than file
+//	-maxdepth n: descend at most n levels below the starting point
+//	-prune: skip matching files and directories, with all they contain,
+//	    and list all others, like find -name X -prune -o -print
+//	-exec cmd {} ;: run cmd for each match, with {} replaced by its name
+//	-exec cmd {} +: run cmd with as many matches as possible at a time
+//
+// -exec may appear anywhere on the command line; the ; usually has to be
+// quoted from the shell. Matches are not printed when -exec is given.
 package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/u-root/u-root/pkg/find"
 )
 
+// maxBatch is the largest number of names passed to one -exec ... + command.
+const maxBatch = 1024
+
+var errExec = errors.New("-exec: missing terminating ';' or '+'")
+
 type params struct {
 	fileType string
 	name     string
 	perm     int
 	long     bool
 	debug    bool
+	size     string
+	mtime    string
+	newer    string
+	// maxDepth limits the depth of the search, if it is not nil.
+	maxDepth *int
+	prune    bool
+	// exec is the -exec command, and execBatch is set if it ends in +.
+	exec      []string
+	execBatch bool
 }
 
 type cmd struct {
@@ -99,36 +129,222 @@ func (c *cmd) run() error {
 	if c.params.debug {
 		debugLog = log.Printf
 	}
-	names := find.Find(context.Background(),
+	opts := []find.Set{
 		find.WithRoot(root),
 		find.WithModeMatch(mode, mask),
 		find.WithFilenameMatch(c.params.name),
 		find.WithDebugLog(debugLog),
-	)
+	}
+	if c.params.maxDepth != nil {
+		opts = append(opts, find.WithMaxDepth(*c.params.maxDepth))
+	}
+	if c.params.prune {
+		opts = append(opts, find.WithPrune())
+	}
+	if c.params.size != "" {
+		f, err := sizeFilter(c.params.size)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, find.WithFilter(f))
+	}
+	if c.params.mtime != "" {
+		f, err := mtimeFilter(c.params.mtime, time.Now())
+		if err != nil {
+			return err
+		}
+		opts = append(opts, find.WithFilter(f))
+	}
+	if c.params.newer != "" {
+		fi, err := os.Stat(c.params.newer)
+		if err != nil {
+			return fmt.Errorf("-newer: %w", err)
+		}
+		opts = append(opts, find.WithFilter(func(f *find.File) bool {
+			return f.ModTime().After(fi.ModTime())
+		}))
+	}
+	names := find.Find(context.Background(), opts...)
 
+	var batch []string
+	var execErr error
 	for l := range names {
 		if l.Err != nil {
 			fmt.Fprintf(c.stderr, "%s: %v\n", l.Name, l.Err)
 			continue
 		}
+		switch {
+		case c.params.exec == nil:
+		case c.params.execBatch:
+			if batch = append(batch, l.Name); len(batch) == maxBatch {
+				execErr = errors.Join(execErr, c.execute(batch))
+				batch = nil
+			}
+			continue
+		default:
+			// As for find(1), a failing command only means that the
+			// file does not match.
+			var ee *exec.ExitError
+			if err := c.execute([]string{l.Name}); err != nil && !errors.As(err, &ee) {
+				fmt.Fprintf(c.stderr, "%s: %v\n", l.Name, err)
+			}
+			continue
+		}
 		if c.params.long {
 			fmt.Fprintf(c.stdout, "%s\n", l)
 			continue
 		}
 		fmt.Fprintf(c.stdout, "%s\n", l.Name)
 	}
+	if len(batch) > 0 {
+		execErr = errors.Join(execErr, c.execute(batch))
+	}
+	return execErr
+}
 
+// execute runs the -exec command for names. A command ending in ; gets one
+// name, which replaces every {} in its arguments; a command ending in +
+// gets all names in place of its final {}.
+func (c *cmd) execute(names []string) error {
+	var args []string
+	if c.params.execBatch {
+		n := len(c.params.exec) - 1
+		args = append(c.params.exec[:n:n], names...)
+	} else {
+		for _, a := range c.params.exec {
+			args = append(args, strings.ReplaceAll(a, "{}", names[0]))
+		}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, c.stdout, c.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("-exec %s: %w", args[0], err)
+	}
 	return nil
 }
 
+// splitExec removes a -exec command, terminated by ";" or by "{} +", from
+// args.
+func splitExec(args []string) (rest, cmd []string, batch bool, err error) {
+	for i, a := range args {
+		if a != "-exec" && a != "--exec" {
+			continue
+		}
+		for j := i + 1; j < len(args); j++ {
+			end := args[j] == ";" || (args[j] == "+" && args[j-1] == "{}")
+			if !end {
+				continue
+			}
+			if j == i+1 {
+				return nil, nil, false, errExec
+			}
+			rest = append(append([]string{}, args[:i]...), args[j+1:]...)
+			return rest, args[i+1 : j], args[j] == "+", nil
+		}
+		return nil, nil, false, errExec
+	}
+	return args, nil, false, nil
+}
+
+// parseNumber parses the argument of -size and -mtime: a number, preceded
+// by + to match more or - to match less than it. It returns the number and
+// 1, -1 or 0 respectively.
+func parseNumber(arg string) (n int64, cmp int, err error) {
+	switch {
+	case strings.HasPrefix(arg, "+"):
+		cmp, arg = 1, arg[1:]
+	case strings.HasPrefix(arg, "-"):
+		cmp, arg = -1, arg[1:]
+	}
+	n, err = strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, 0, fmt.Errorf("invalid number %q", arg)
+	}
+	return n, cmp, nil
+}
+
+func compare(v, n int64, cmp int) bool {
+	switch cmp {
+	case 1:
+		return v > n
+	case -1:
+		return v < n
+	}
+	return v == n
+}
+
+// sizeUnits are the unit suffixes of -size.
+var sizeUnits = map[byte]int64{
+	'b': 512,
+	'c': 1,
+	'w': 2,
+	'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+}
+
+// sizeFilter matches files by their size, rounded up to the unit of arg.
+func sizeFilter(arg string) (func(*find.File) bool, error) {
+	unit := int64(512)
+	if l := len(arg); l > 0 {
+		if u, ok := sizeUnits[arg[l-1]]; ok {
+			unit, arg = u, arg[:l-1]
+		}
+	}
+	n, cmp, err := parseNumber(arg)
+	if err != nil {
+		return nil, fmt.Errorf("-size: %w", err)
+	}
+	return func(f *find.File) bool {
+		return compare((f.Size()+unit-1)/unit, n, cmp)
+	}, nil
+}
+
+// mtimeFilter matches files by the number of whole days since they were
+// last modified.
+func mtimeFilter(arg string, now time.Time) (func(*find.File) bool, error) {
+	n, cmp, err := parseNumber(arg)
+	if err != nil {
+		return nil, fmt.Errorf("-mtime: %w", err)
+	}
+	return func(f *find.File) bool {
+		return compare(int64(now.Sub(f.ModTime())/(24*time.Hour)), n, cmp)
+	}, nil
+}
+
 func main() {
 	perm := flag.Int("mode", -1, "permissions")
 	fileType := flag.String("type", "", "file type")
 	name := flag.String("name", "", "glob for name")
 	long := flag.Bool("l", false, "long listing")
 	debug := flag.Bool("d", false, "enable debugging in the find package")
-	flag.Parse()
-	p := params{perm: *perm, fileType: *fileType, name: *name, long: *long, debug: *debug}
+	size := flag.String("size", "", "match by size, e.g. +10M")
+	mtime := flag.String("mtime", "", "match by days since modification, e.g. -7")
+	newer := flag.String("newer", "", "match files modified after this file")
+	maxDepth := flag.Int("maxdepth", -1, "descend at most this many levels")
+	prune := flag.Bool("prune", false, "skip matching files and directories and list all others")
+
+	args, execCmd, batch, err := splitExec(os.Args[1:])
+	if err != nil {
+		log.Fatalf("find: %v", err)
+	}
+	flag.CommandLine.Parse(args)
+	p := params{
+		perm:      *perm,
+		fileType:  *fileType,
+		name:      *name,
+		long:      *long,
+		debug:     *debug,
+		size:      *size,
+		mtime:     *mtime,
+		newer:     *newer,
+		prune:     *prune,
+		exec:      execCmd,
+		execBatch: batch,
+	}
+	if *maxDepth >= 0 {
+		p.maxDepth = maxDepth
+	}
 	if err := command(os.Stdout, os.Stderr, p, flag.Args()).run(); err != nil {
 		log.Fatalf("find: %v", err)
 	}
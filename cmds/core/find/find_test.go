@@ -6,9 +6,13 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func prepareDirLayout(t *testing.T) {
@@ -147,3 +151,123 @@ func TestFindLong(t *testing.T) {
 		t.Errorf("want suffix: file1, got suffix: %s", res[len(res)-5:])
 	}
 }
+
+func TestFindPredicates(t *testing.T) {
+	prepareDirLayout(t)
+	if err := os.WriteFile("big", make([]byte, 3000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes("file2", old, old); err != nil {
+		t.Fatal(err)
+	}
+	depth := 1
+
+	for _, tt := range []struct {
+		name       string
+		params     params
+		args       []string
+		wantStdout string
+	}{
+		{
+			name:       "size in blocks",
+			params:     params{perm: -1, fileType: "f", size: "+1"},
+			wantStdout: "big\n",
+		},
+		{
+			name:       "size in bytes",
+			params:     params{perm: -1, fileType: "f", size: "3000c"},
+			wantStdout: "big\n",
+		},
+		{
+			name:       "mtime",
+			params:     params{perm: -1, mtime: "+7"},
+			wantStdout: "file2\n",
+		},
+		{
+			name:       "newer",
+			params:     params{perm: -1, fileType: "f", newer: "file2", name: "file2"},
+			wantStdout: "dir1/file2\n",
+		},
+		{
+			name:       "maxdepth",
+			params:     params{perm: -1, maxDepth: &depth, name: "file1"},
+			wantStdout: "file1\n",
+		},
+		{
+			name:       "prune",
+			params:     params{perm: -1, name: "dir1", prune: true},
+			wantStdout: ".\nbig\ndir2\ndir2/file1\ndir2/file3\nfile1\nfile2\n",
+		},
+		{
+			name:       "prune files and directories",
+			params:     params{perm: -1, name: "*1", prune: true},
+			wantStdout: ".\nbig\ndir2\ndir2/file3\nfile2\n",
+		},
+		{
+			name:       "exec per file",
+			params:     params{perm: -1, name: "file3", exec: []string{"echo", "found:{}"}},
+			wantStdout: "found:dir2/file3\n",
+		},
+		{
+			name:       "exec batch",
+			params:     params{perm: -1, name: "file1", exec: []string{"echo", "found", "{}"}, execBatch: true},
+			wantStdout: "found dir1/file1 dir2/file1 file1\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := command(&stdout, &stderr, tt.params, []string{"."}).run(); err != nil {
+				t.Fatalf("run: got %v, want nil", err)
+			}
+			if got := strings.TrimPrefix(stdout.String(), "./"); got != tt.wantStdout {
+				t.Errorf("got %q, want %q", got, tt.wantStdout)
+			}
+		})
+	}
+
+	for _, p := range []params{{perm: -1, size: "x"}, {perm: -1, mtime: "+-1"}, {perm: -1, newer: "nonexistent"}} {
+		if err := command(io.Discard, io.Discard, p, []string{"."}).run(); err == nil {
+			t.Errorf("run(%+v): got nil, want error", p)
+		}
+	}
+}
+
+func TestSplitExec(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		rest  []string
+		cmd   []string
+		batch bool
+		err   error
+	}{
+		{
+			args: []string{"-name", "x", "."},
+			rest: []string{"-name", "x", "."},
+		},
+		{
+			args: []string{"-exec", "rm", "{}", ";", "-name", "x", "."},
+			rest: []string{"-name", "x", "."},
+			cmd:  []string{"rm", "{}"},
+		},
+		{
+			args:  []string{"-name", "x", ".", "-exec", "ls", "+", "{}", "+"},
+			rest:  []string{"-name", "x", "."},
+			cmd:   []string{"ls", "+", "{}"},
+			batch: true,
+		},
+		{
+			args: []string{".", "-exec", "rm", "{}"},
+			err:  errExec,
+		},
+		{
+			args: []string{".", "-exec", ";"},
+			err:  errExec,
+		},
+	} {
+		rest, cmd, batch, err := splitExec(tt.args)
+		if !errors.Is(err, tt.err) || !slices.Equal(rest, tt.rest) || !slices.Equal(cmd, tt.cmd) || batch != tt.batch {
+			t.Errorf("splitExec(%q) = %q, %q, %t, %v, want %q, %q, %t, %v", tt.args, rest, cmd, batch, err, tt.rest, tt.cmd, tt.batch, tt.err)
+		}
+	}
+}
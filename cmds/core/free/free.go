@@ -9,12 +9,14 @@
 //
 // Synopsis:
 //
-//	free [-k] [-m] [-g] [-t] [-h] [-json]
+//	free [-k] [-m] [-g] [-t] [-h] [-json] [-s SECONDS] [-c COUNT]
 //
 // Description:
 //
 //	Read memory information from /proc/meminfo and display a summary for
 //	physical memory and swap space. The unit options use powers of 1024.
+//	With -s or -c, the summary is repeated every SECONDS, COUNT times or
+//	until interrupted.
 //
 // Options:
 //
@@ -23,7 +25,9 @@
 //	-g: display the values in gibibytes
 //	-t: display the values in tebibytes
 //	-h: display the values in human-readable form
-//	-json: use JSON output
+//	-json: use JSON output, one object per line
+//	-s: repeat every SECONDS, which may be fractional
+//	-c: repeat COUNT times, every second unless -s is given
 package main
 
 import (
@@ -33,6 +37,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 )
 
 var (
@@ -43,6 +48,8 @@ var (
 	inGB        = flag.Bool("g", false, "Express the values in gibibytes")
 	inTB        = flag.Bool("t", false, "Express the values in tebibytes")
 	toJSON      = flag.Bool("json", false, "Use JSON for output")
+	delay       = flag.Float64("s", 0, "Repeat every `seconds`")
+	count       = flag.Int("c", 0, "Repeat `count` times")
 )
 
 type unit uint
@@ -62,7 +69,10 @@ const (
 
 var units = [...]string{"B", "K", "M", "G", "T"}
 
-var errMultipleUnits = fmt.Errorf("multiple unit options doesn't make sense")
+var (
+	errMultipleUnits = fmt.Errorf("multiple unit options doesn't make sense")
+	errRepeat        = fmt.Errorf("the delay and count must not be negative")
+)
 
 // the following types are used for JSON serialization
 type mainMemInfo struct {
@@ -143,7 +153,7 @@ func (c *cmd) formatValueByConfig(value uint64) string {
 
 func main() {
 	flag.Parse()
-	o := options{human: *humanOutput, bytes: *inBytes, kbytes: *inKB, mbytes: *inMB, gbytes: *inGB, tbytes: *inTB, json: *toJSON}
+	o := options{human: *humanOutput, bytes: *inBytes, kbytes: *inKB, mbytes: *inMB, gbytes: *inGB, tbytes: *inTB, json: *toJSON, delay: *delay, count: *count}
 	cmd, err := command(os.Stdout, o)
 	if err != nil {
 		log.Fatal(err)
@@ -154,10 +164,14 @@ func main() {
 }
 
 type cmd struct {
-	stdout io.Writer
-	unit   unit
-	human  bool
-	toJSON bool
+	stdout   io.Writer
+	unit     unit
+	human    bool
+	toJSON   bool
+	interval time.Duration
+	count    int
+	meminfo  func() (meminfomap, error)
+	sleep    func(time.Duration)
 }
 
 type options struct {
@@ -168,6 +182,8 @@ type options struct {
 	gbytes bool
 	tbytes bool
 	json   bool
+	delay  float64
+	count  int
 }
 
 func countTrue(b ...bool) int {
@@ -188,9 +204,20 @@ func command(stdout io.Writer, o options) (*cmd, error) {
 		return nil, errMultipleUnits
 	}
 
+	if o.delay < 0 || o.count < 0 {
+		return nil, errRepeat
+	}
+
 	c := &cmd{
-		stdout: stdout,
-		toJSON: o.json,
+		stdout:   stdout,
+		toJSON:   o.json,
+		interval: time.Duration(o.delay * float64(time.Second)),
+		count:    o.count,
+		meminfo:  meminfo,
+		sleep:    time.Sleep,
+	}
+	if c.count > 0 && c.interval == 0 {
+		c.interval = time.Second
 	}
 
 	if o.human {
@@ -214,14 +241,25 @@ func command(stdout io.Writer, o options) (*cmd, error) {
 }
 
 // run prints physical memory and swap space information. The fields will be
-// expressed with the specified unit (e.g. KB, MB)
+// expressed with the specified unit (e.g. KB, MB). With an interval, it is
+// printed again after each, count times or forever.
 func (c *cmd) run() error {
-	m, err := meminfo()
-	if err != nil {
-		return err
+	for i := 1; ; i++ {
+		m, err := c.meminfo()
+		if err != nil {
+			return err
+		}
+		if err := c.parse(m); err != nil {
+			return err
+		}
+		if c.interval == 0 || (c.count > 0 && i >= c.count) {
+			return nil
+		}
+		if !c.toJSON {
+			fmt.Fprintln(c.stdout)
+		}
+		c.sleep(c.interval)
 	}
-
-	return c.parse(m)
 }
 
 func (c *cmd) parse(m meminfomap) error {
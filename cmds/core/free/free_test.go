@@ -6,8 +6,10 @@ package main
 
 import (
 	"bytes"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMeminfoFromBytes(t *testing.T) {
@@ -234,3 +236,54 @@ func TestOptionError(t *testing.T) {
 		t.Errorf("expected error: %v, got %v", errMultipleUnits, err)
 	}
 }
+
+func TestRepeat(t *testing.T) {
+	input := []byte(`MemTotal:        8052976 kB
+MemFree:          721716 kB
+MemAvailable:    2774100 kB
+Buffers:          244880 kB
+Cached:          3462124 kB
+Shmem:           1617788 kB
+SwapTotal:       8265724 kB
+SwapFree:        8264956 kB
+SReclaimable:     179852 kB`)
+	for _, tt := range []struct {
+		name   string
+		o      options
+		lines  int
+		sleeps []time.Duration
+	}{
+		{name: "once", lines: 3},
+		{name: "count", o: options{count: 3}, lines: 11, sleeps: []time.Duration{time.Second, time.Second}},
+		{name: "delay and count", o: options{delay: 0.5, count: 2}, lines: 7, sleeps: []time.Duration{time.Second / 2}},
+		{name: "json", o: options{json: true, count: 2}, lines: 2, sleeps: []time.Duration{time.Second}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c, err := command(&stdout, tt.o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.meminfo = func() (meminfomap, error) {
+				return meminfoFromBytes(input)
+			}
+			var sleeps []time.Duration
+			c.sleep = func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			}
+			if err := c.run(); err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(stdout.String(), "\n"); n != tt.lines {
+				t.Errorf("got %d lines, want %d:\n%s", n, tt.lines, stdout.String())
+			}
+			if !reflect.DeepEqual(sleeps, tt.sleeps) {
+				t.Errorf("got sleeps %v, want %v", sleeps, tt.sleeps)
+			}
+		})
+	}
+
+	if _, err := command(nil, options{delay: -1}); err != errRepeat {
+		t.Errorf("expected error: %v, got %v", errRepeat, err)
+	}
+}
@@ -15,8 +15,8 @@ import (
 	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/knz/bubbline"
 	"github.com/knz/bubbline/complete"
@@ -27,10 +27,6 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
-// HistFile is the history file.
-// This might, possibly, use GetPid to avoid gosh'es writing over each other
-var HistFile = filepath.Join(os.TempDir(), "bubble-sh.history")
-
 var completion = flag.Bool("comp", true, "Enable tabcompletion and a more feature rich editline implementation")
 
 type candidate struct {
@@ -62,34 +58,31 @@ func (m *multiComplete) Candidate(e complete.Entry) editline.Candidate {
 }
 
 func autocompleteBubb(val [][]rune, line, col int) (msg string, completions editline.Completions) {
-	word, wstart, wend := computil.FindWord(val, line, col)
-	var candidates []string
-	if wstart == 0 && !(strings.HasPrefix(word, ".") || strings.HasPrefix(word, "/")) {
-		candidates = commandCompleter(word)
-	} else {
-		candidates = filepathCompleter(word)
-	}
+	_, _, wend := computil.FindWord(val, line, col)
+	text := string(val[line][:col])
+	pos, candidates := completeLine(syntax.NewParser(), text)
 
 	if len(candidates) != 0 {
 		return "", &multiComplete{
 			Values:     complete.StringValues("suggestions", candidates),
 			moveRight:  wend - col,
-			deleteLeft: wend - wstart,
+			deleteLeft: wend - utf8.RuneCountInString(text[:pos]),
 		}
 	}
 	return "", nil
 }
 
-func runInteractive(runner *interp.Runner, parser *syntax.Parser, stdout, stderr io.Writer) error {
+func runInteractive(runner *interp.Runner, jobs *jobTable, parser *syntax.Parser, stdout, stderr io.Writer) error {
 	input := bubbline.New()
 	// Set default window size to 80x24 in case ioctl isn't able to detect the actual window size
 	input.Model.SetSize(80, 24)
 
-	if err := input.LoadHistory(HistFile); err != nil {
+	hist := histFile(runner)
+	if err := input.LoadHistory(hist); err != nil {
 		return err
 	}
 
-	input.SetAutoSaveHistory(HistFile, true)
+	input.SetAutoSaveHistory(hist, true)
 
 	if *completion {
 		input.AutoComplete = autocompleteBubb
@@ -154,7 +147,7 @@ func runInteractive(runner *interp.Runner, parser *syntax.Parser, stdout, stderr
 				return true
 			}
 
-			runErr = runner.Run(context.Background(), stmt)
+			runErr = jobs.run(context.Background(), runner, stmt)
 			return !runner.Exited()
 		}); err != nil {
 			fmt.Fprintf(stderr, "error: %s\n", err.Error())
@@ -8,7 +8,6 @@
 package main
 
 import (
-	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
@@ -16,10 +15,21 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/u-root/u-root/pkg/complete"
 	"mvdan.cc/sh/v3/expand"
 	"mvdan.cc/sh/v3/syntax"
 )
 
+// shellBuiltins are the builtins of the interpreter and of gosh, which are
+// completed along with the commands in $PATH.
+var shellBuiltins = []string{
+	".", ":", "[", "alias", "bg", "break", "builtin", "cd", "command",
+	"continue", "dirs", "echo", "eval", "exec", "exit", "false", "fg",
+	"getopts", "jobs", "mapfile", "popd", "printf", "pushd", "pwd", "read",
+	"readarray", "return", "set", "shift", "shopt", "source", "test",
+	"trap", "true", "type", "umask", "unalias", "unset", "wait",
+}
+
 func lastStmt(parser *syntax.Parser, line string) *syntax.Stmt {
 	var s *syntax.Stmt
 	parser.Stmts(strings.NewReader(line), func(stmt *syntax.Stmt) bool {
@@ -112,18 +122,52 @@ func addPrefix(s string, t []string) []string {
 	return q
 }
 
+// completeLine returns the position of the word to complete in line and its
+// candidates: commands, the arguments offered by the completion function
+// registered for the command, or file names.
+func completeLine(parser *syntax.Parser, line string) (int, []string) {
+	isCmd, pos, word := lastWord(parser, line)
+	if pos == -1 {
+		return -1, nil
+	}
+	if isCmd && !strings.HasPrefix(word, ".") && !strings.HasPrefix(word, "/") {
+		return pos, commandCompleter(word)
+	}
+	if args := callArgs(parser, line[:pos]); len(args) > 0 {
+		if f := complete.Lookup(args[0]); f != nil {
+			if c := f(args[1:], word); c != nil {
+				return pos, c
+			}
+		}
+	}
+	return pos, filepathCompleter(word)
+}
+
+// callArgs returns the literal arguments of the command line ends in. It
+// returns nil if the command name is not a literal.
+func callArgs(parser *syntax.Parser, line string) []string {
+	stmt := lastStmt(parser, line)
+	if stmt == nil || stmt.Semicolon.IsValid() {
+		return nil
+	}
+	callExpr, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok || len(callExpr.Args) == 0 || callExpr.Args[0].Lit() == "" {
+		return nil
+	}
+	args := make([]string, 0, len(callExpr.Args))
+	for _, w := range callExpr.Args {
+		args = append(args, w.Lit())
+	}
+	return args
+}
+
 func autocompleteLiner(parser *syntax.Parser) func(line string) []string {
 	return func(line string) []string {
-		isCmd, pos, word := lastWord(parser, line)
+		pos, candidates := completeLine(parser, line)
 		if pos == -1 {
 			return nil
 		}
-		prefix := line[:pos]
-
-		if isCmd && !strings.HasPrefix(word, ".") && !strings.HasPrefix(word, "/") {
-			return addPrefix(prefix, commandCompleter(word))
-		}
-		return addPrefix(prefix, filepathCompleter(word))
+		return addPrefix(line[:pos], candidates)
 	}
 }
 
@@ -158,22 +202,29 @@ func filepathCompleter(input string) []string {
 	return candidates
 }
 
+// commandCompleter completes shell builtins and the executables in $PATH,
+// such as the u-root commands in /bbin.
 func commandCompleter(input string) []string {
-	var candidates []string
+	candidates := append([]string(nil), shellBuiltins...)
 
-	for _, path := range strings.Split(os.Getenv("PATH"), ":") {
-		if err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
-			if d != nil && !d.IsDir() && strings.HasPrefix(d.Name(), input) {
-				// Is executable?
-				if fi, err := d.Info(); err == nil && fi.Mode().Perm()&0o111 != 0 {
-					candidates = append(candidates, d.Name())
-				}
-			}
-			return nil
-		}); err != nil {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
 			continue
 		}
+		for _, e := range entries {
+			if !strings.HasPrefix(e.Name(), input) {
+				continue
+			}
+			// Follow symlinks, busybox commands are links to bb.
+			if fi, err := os.Stat(filepath.Join(dir, e.Name())); err == nil && !fi.IsDir() && fi.Mode().Perm()&0o111 != 0 {
+				candidates = append(candidates, e.Name())
+			}
+		}
 	}
 
-	return candidates
+	if c := complete.Filter(input, candidates); len(c) > 0 {
+		return c
+	}
+	return nil
 }
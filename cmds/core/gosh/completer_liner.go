@@ -15,7 +15,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/peterh/liner"
@@ -23,20 +22,17 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
-// HistFile is the history file.
-// This might, possibly, use GetPid to avoid gosh'es writing over each other
-var HistFile = filepath.Join(os.TempDir(), "gosh.history")
-
 var completion = flag.Bool("comp", true, "Enable tabcompletion and a more feature rich editline implementation")
 
-func runInteractive(runner *interp.Runner, parser *syntax.Parser, stdout, stderr io.Writer) error {
+func runInteractive(runner *interp.Runner, jobs *jobTable, parser *syntax.Parser, stdout, stderr io.Writer) error {
 	input := liner.NewLiner()
 	defer input.Close()
 
-	f, err := os.OpenFile(HistFile, os.O_RDWR|os.O_CREATE, 0)
+	hist := histFile(runner)
+	f, err := os.OpenFile(hist, os.O_RDWR|os.O_CREATE, 0o600)
 	if err == nil {
 		input.ReadHistory(f)
-	} else if f, err = os.Open(HistFile); err != nil {
+	} else if f, err = os.Open(hist); err != nil {
 		log.Printf("Failed to open or create history file: %v", err)
 	}
 	if f != nil {
@@ -94,7 +90,7 @@ func runInteractive(runner *interp.Runner, parser *syntax.Parser, stdout, stderr
 				return true
 			}
 
-			runErr = runner.Run(context.Background(), stmt)
+			runErr = jobs.run(context.Background(), runner, stmt)
 			return !runner.Exited()
 		}); err != nil {
 			fmt.Fprintf(stderr, "error: %s\n", err.Error())
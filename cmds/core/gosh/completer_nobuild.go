@@ -14,6 +14,6 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
-func runInteractive(runner *interp.Runner, parser *syntax.Parser, stdout, stderr io.Writer) error {
+func runInteractive(runner *interp.Runner, jobs *jobTable, parser *syntax.Parser, stdout, stderr io.Writer) error {
 	return errNotImplemented
 }
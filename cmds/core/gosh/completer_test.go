@@ -86,6 +86,10 @@ func TestAutocomplete(t *testing.T) {
 
 	t.Setenv("GOSH_TEST", "1")
 
+	// Only complete the commands in PATH.
+	defer func(b []string) { shellBuiltins = b }(shellBuiltins)
+	shellBuiltins = nil
+
 	for _, tt := range []struct {
 		name  string
 		input string
@@ -244,6 +248,39 @@ func TestAutocomplete(t *testing.T) {
 			name:  "nocomplete",
 			input: `echo "./co`,
 		},
+		{
+			name:  "hook subcommand",
+			input: "ip li",
+			want:  []string{"ip link"},
+		},
+		{
+			name:  "hook subcommands",
+			input: "ip link ",
+			want:  []string{"ip link add", "ip link set", "ip link show"},
+		},
+		{
+			name:  "hook abbreviated subcommand",
+			input: "ip r add ",
+			want:  []string{"ip r add default"},
+		},
+		{
+			name:  "hook flag value",
+			input: "tftp -m ",
+			want:  []string{"tftp -m ascii", "tftp -m binary"},
+		},
+		{
+			name:  "hook no candidates",
+			input: "tftp -m x",
+		},
+		{
+			name:  "hook falls back to files",
+			input: "tftp host -c get ",
+			cwd:   dir2Files,
+			want: []string{
+				"tftp host -c get ./bar.txt",
+				"tftp host -c get ./foo.txt",
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.cwd != "" {
@@ -258,3 +295,15 @@ func TestAutocomplete(t *testing.T) {
 		})
 	}
 }
+
+func TestCommandCompleterBuiltins(t *testing.T) {
+	p := t.TempDir()
+	os.WriteFile(filepath.Join(p, "jobsctl"), nil, 0o777)
+	os.WriteFile(filepath.Join(p, "jobsdata"), nil, 0o666)
+	t.Setenv("PATH", p)
+
+	want := []string{"jobs", "jobsctl"}
+	if got := commandCompleter("jobs"); !reflect.DeepEqual(got, want) {
+		t.Errorf("commandCompleter(jobs) = %q, want %q", got, want)
+	}
+}
@@ -43,7 +43,8 @@ func main() {
 var errNotImplemented = errors.New("fancy interactive interpreter not implemented")
 
 func run(stdin io.Reader, stdout, stderr io.Writer, command string, args ...string) error {
-	runner, err := interp.New(interp.StdIO(stdin, stdout, stderr))
+	jobs := newJobTable(stderr)
+	runner, err := interp.New(interp.StdIO(stdin, stdout, stderr), interp.CallHandler(jobs.callHandler), interp.ExecHandlers(jobs.execHandler))
 	if err != nil {
 		return err
 	}
@@ -53,10 +54,16 @@ func run(stdin io.Reader, stdout, stderr io.Writer, command string, args ...stri
 	}
 	if len(args) == 0 {
 		if r, ok := stdin.(*os.File); ok && term.IsTerminal(int(r.Fd())) {
-			if err := runInteractive(runner, syntax.NewParser(), stdout, stderr); !errors.Is(err, errNotImplemented) {
+			jobs.setup(r)
+			if !*noProfile {
+				if err := loadProfile(runner, stderr, startupScripts(*rcFile)); err != nil || runner.Exited() {
+					return err
+				}
+			}
+			if err := runInteractive(runner, jobs, syntax.NewParser(), stdout, stderr); !errors.Is(err, errNotImplemented) {
 				return err
 			}
-			return runInteractiveSimple(runner, stdin, stdout)
+			return runInteractiveSimple(runner, jobs, stdin, stdout)
 		}
 		return runReader(runner, stdin, "")
 	}
@@ -81,7 +88,7 @@ func runReader(runner *interp.Runner, reader io.Reader, name string) error {
 	return runner.Run(context.Background(), prog)
 }
 
-func runInteractiveSimple(runner *interp.Runner, stdin io.Reader, stdout io.Writer) error {
+func runInteractiveSimple(runner *interp.Runner, jobs *jobTable, stdin io.Reader, stdout io.Writer) error {
 	parser := syntax.NewParser()
 	fmt.Fprintf(stdout, "$ ")
 
@@ -105,7 +112,7 @@ func runInteractiveSimple(runner *interp.Runner, stdin io.Reader, stdout io.Writ
 				return true
 			}
 			for _, stmt := range stmts {
-				runErr = runner.Run(context.Background(), stmt)
+				runErr = jobs.run(context.Background(), runner, stmt)
 				if runner.Exited() {
 					return false
 				}
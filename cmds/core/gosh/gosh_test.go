@@ -52,6 +52,11 @@ func TestRun(t *testing.T) {
 			command: "echo foo",
 			wantOut: "foo\n",
 		},
+		{
+			name:    "pipeline status",
+			command: "false | true; echo $?; set -o pipefail; false | true; echo $?",
+			wantOut: "0\n1\n",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			var in, out, err bytes.Buffer
@@ -170,7 +175,7 @@ func TestRunInteractive(t *testing.T) {
 				t.Errorf("Failed creating runner: %v", err)
 			}
 
-			if err := runInteractive(runner, syntax.NewParser(), outWriter, outWriter); err != nil && tt.wantErr == nil {
+			if err := runInteractive(runner, newJobTable(outWriter), syntax.NewParser(), outWriter, outWriter); err != nil && tt.wantErr == nil {
 				t.Errorf("Unexpected error: %v", err)
 			} else if tt.wantErr != nil && fmt.Sprint(err) != tt.wantErr.Error() {
 				t.Errorf("Want error %q, got: %v", tt.wantErr, err)
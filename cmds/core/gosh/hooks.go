@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9 && !goshsmall
+// +build !tinygo,!plan9,!goshsmall
+
+package main
+
+import (
+	"github.com/u-root/u-root/pkg/complete"
+)
+
+// Completions for the arguments of u-root commands.
+func init() {
+	complete.Register("ip", (&complete.Command{Sub: map[string]*complete.Command{
+		"address": {Sub: map[string]*complete.Command{
+			"add": {Args: ipAddress},
+			"del": {Args: ipAddress},
+		}},
+		"link": {Sub: map[string]*complete.Command{
+			"show": {Args: complete.Interfaces},
+			"set": {Args: ipDev(complete.Options{
+				"address": complete.None,
+				"up":      nil,
+				"down":    nil,
+				"master":  complete.Interfaces,
+			}.Complete)},
+			"add": {Args: ipLinkAdd},
+		}},
+		"route": {Sub: map[string]*complete.Command{
+			"show": {Args: complete.None},
+			"add":  {Args: ipRouteAdd},
+			"del":  {Args: ipAddress},
+		}},
+		"neigh": {Args: complete.None},
+	}}).Complete)
+
+	complete.Register("tftp", tftp)
+}
+
+// ipDev completes the device argument of ip, in front of which "dev" is
+// optional, and the arguments after it with next.
+func ipDev(next complete.Func) complete.Func {
+	return func(args []string, word string) []string {
+		switch {
+		case len(args) == 0:
+			return complete.Filter(word, append(complete.Interfaces(nil, ""), "dev"))
+		case args[0] == "dev" && len(args) == 1:
+			return complete.Interfaces(nil, word)
+		case args[0] == "dev":
+			args = args[1:]
+		}
+		return next(args[1:], word)
+	}
+}
+
+// ipAddress completes "CIDR [dev] device".
+func ipAddress(args []string, word string) []string {
+	if len(args) == 0 {
+		return complete.None(args, word)
+	}
+	return ipDev(complete.None)(args[1:], word)
+}
+
+// ipLinkAdd completes "[name] name type bridge".
+func ipLinkAdd(args []string, word string) []string {
+	if len(args) > 0 && args[0] == "name" {
+		args = args[1:]
+	}
+	switch len(args) {
+	case 0:
+		return complete.None(args, word)
+	case 1:
+		return complete.Filter(word, []string{"type"})
+	case 2:
+		return complete.Filter(word, []string{"bridge"})
+	}
+	return complete.None(args, word)
+}
+
+// ipRouteAdd completes "default via gateway [dev] device" and
+// "CIDR [dev] device".
+func ipRouteAdd(args []string, word string) []string {
+	switch {
+	case len(args) == 0:
+		return complete.Filter(word, []string{"default"})
+	case args[0] != "default":
+		return ipDev(complete.None)(args[1:], word)
+	case len(args) == 1:
+		return complete.Filter(word, []string{"via"})
+	case len(args) == 2:
+		return complete.None(args, word)
+	}
+	return ipDev(complete.None)(args[3:], word)
+}
+
+// tftpCommands are the commands of tftp -c.
+var tftpCommands = []string{
+	"ascii", "binary", "connect", "get", "help", "literal", "mode", "put",
+	"quit", "rexmt", "status", "timeout", "trace", "verbose",
+}
+
+// tftp completes "[-m mode] [host [port]] [-c command args...]".
+func tftp(args []string, word string) []string {
+	for i, a := range args {
+		if a != "-c" {
+			continue
+		}
+		switch cmd := args[i+1:]; {
+		case len(cmd) == 0:
+			return complete.Filter(word, tftpCommands)
+		case cmd[0] == "mode" && len(cmd) == 1:
+			return complete.Filter(word, []string{"ascii", "binary"})
+		case cmd[0] == "get" || cmd[0] == "put":
+			// Local file names.
+			return nil
+		}
+		return complete.None(args, word)
+	}
+	if n := len(args); n > 0 && args[n-1] == "-m" {
+		return complete.Filter(word, []string{"ascii", "binary"})
+	}
+	return complete.Filter(word, []string{"-c", "-m"})
+}
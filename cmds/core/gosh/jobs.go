@@ -0,0 +1,401 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9
+// +build !tinygo,!plan9
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var errNoJob = errors.New("no such job")
+
+// jobState is the state of a job or of one of its processes.
+type jobState int
+
+const (
+	running jobState = iota
+	stopped
+	done
+)
+
+// process is a command started on behalf of a job.
+type process struct {
+	pid   int
+	state jobState
+	// status is the exit status, or 128 plus the number of the signal
+	// that stopped or killed the process.
+	status uint8
+}
+
+// A job is a statement run from the interactive prompt, together with the
+// processes it started.
+type job struct {
+	id  int
+	cmd string
+	// pgid is the process group of the job's processes, or 0 while none
+	// of them is alive.
+	pgid  int
+	procs []*process
+	// fg is set while the shell waits for the job in the foreground.
+	fg bool
+	// running is set while the interpreter executes the statement.
+	running bool
+	// exit is the exit status of the statement, if hasExit is set.
+	exit    uint8
+	hasExit bool
+}
+
+func (j *job) state() jobState {
+	for _, p := range j.procs {
+		if p.state == stopped {
+			return stopped
+		}
+	}
+	if j.running {
+		return running
+	}
+	for _, p := range j.procs {
+		if p.state == running {
+			return running
+		}
+	}
+	return done
+}
+
+// status returns the exit status of the statement or, if the statement was
+// stopped before it finished, the one of the job's last process.
+func (j *job) status() uint8 {
+	if j.hasExit {
+		return j.exit
+	}
+	if n := len(j.procs); n > 0 {
+		return j.procs[n-1].status
+	}
+	return 0
+}
+
+func (j *job) describe() string {
+	switch j.state() {
+	case running:
+		return "Running"
+	case stopped:
+		return "Stopped"
+	}
+	if s := j.status(); s != 0 {
+		return fmt.Sprintf("Exit %d", s)
+	}
+	return "Done"
+}
+
+type jobKey struct{}
+
+// jobTable implements job control for the interactive shell: statements
+// ending in & run in the background, stopped foreground statements are kept
+// around, and the jobs, fg and bg builtins manage both.
+type jobTable struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// jobs are the background and stopped jobs, ordered by id.
+	jobs []*job
+	// fg is the job the shell currently waits for.
+	fg     *job
+	stderr io.Writer
+
+	// control is set if jobs get process groups of their own.
+	control bool
+	// tty is the controlling terminal handed to foreground jobs, or -1.
+	tty int
+	// pgrp is the process group of the shell.
+	pgrp int
+}
+
+func newJobTable(stderr io.Writer) *jobTable {
+	jt := &jobTable{stderr: stderr, tty: -1}
+	jt.cond = sync.NewCond(&jt.mu)
+	return jt
+}
+
+// add assigns j the next free job id and adds it to the table.
+func (jt *jobTable) add(j *job) {
+	j.id = 1
+	if n := len(jt.jobs); n > 0 {
+		j.id = jt.jobs[n-1].id + 1
+	}
+	jt.jobs = append(jt.jobs, j)
+}
+
+func (jt *jobTable) remove(j *job) {
+	for i, k := range jt.jobs {
+		if k == j {
+			jt.jobs = append(jt.jobs[:i], jt.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// mark returns "+" for the current job, "-" for the previous one and " "
+// for all others.
+func (jt *jobTable) mark(j *job) string {
+	n := len(jt.jobs)
+	switch {
+	case n > 0 && jt.jobs[n-1] == j:
+		return "+"
+	case n > 1 && jt.jobs[n-2] == j:
+		return "-"
+	}
+	return " "
+}
+
+// lookup returns the job named by a job spec like %2, %% or %-.
+func (jt *jobTable) lookup(spec string) (*job, error) {
+	n := len(jt.jobs)
+	switch spec {
+	case "", "%", "%%", "%+":
+		if n > 0 {
+			return jt.jobs[n-1], nil
+		}
+		return nil, fmt.Errorf("current: %w", errNoJob)
+	case "%-":
+		if n > 1 {
+			return jt.jobs[n-2], nil
+		}
+		return nil, fmt.Errorf("previous: %w", errNoJob)
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, errNoJob)
+	}
+	for _, j := range jt.jobs {
+		if j.id == id {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", spec, errNoJob)
+}
+
+// run runs stmt as a job. Background statements run in a subshell of
+// runner and are only announced; foreground statements are waited for and
+// kept in the table if they get stopped. Jobs that finished since the last
+// statement are reported first.
+func (jt *jobTable) run(ctx context.Context, runner *interp.Runner, stmt *syntax.Stmt) error {
+	jt.notify()
+
+	s := *stmt
+	s.Background = false
+	var b bytes.Buffer
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&b, &s); err != nil {
+		return err
+	}
+	j := &job{cmd: strings.TrimSpace(b.String()), running: true}
+	ctx = context.WithValue(ctx, jobKey{}, j)
+
+	if stmt.Background {
+		r := runner.Subshell()
+		jt.mu.Lock()
+		jt.add(j)
+		jt.mu.Unlock()
+		go func() {
+			jt.finish(j, r.Run(ctx, &s))
+		}()
+
+		jt.mu.Lock()
+		defer jt.mu.Unlock()
+		for j.pgid == 0 && j.running {
+			jt.cond.Wait()
+		}
+		if j.pgid != 0 {
+			fmt.Fprintf(jt.stderr, "[%d] %d\n", j.id, j.pgid)
+		} else {
+			fmt.Fprintf(jt.stderr, "[%d]\n", j.id)
+		}
+		return nil
+	}
+
+	jt.mu.Lock()
+	j.fg, jt.fg = true, j
+	jt.mu.Unlock()
+
+	err := runner.Run(ctx, &s)
+
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j.fg, jt.fg = false, nil
+	j.running = false
+	jt.reclaim()
+	if j.state() == stopped {
+		jt.add(j)
+		fmt.Fprintf(jt.stderr, "\n[%d]%s  %-24s%s\n", j.id, jt.mark(j), j.describe(), j.cmd)
+	}
+	return err
+}
+
+// finish records the result of a background statement.
+func (jt *jobTable) finish(j *job, err error) {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j.running = false
+	j.hasExit = true
+	if status, ok := interp.IsExitStatus(err); ok {
+		j.exit = status
+	} else if err != nil {
+		j.exit = 1
+	}
+	jt.cond.Broadcast()
+}
+
+// notify reports and forgets the jobs that finished in the background.
+func (jt *jobTable) notify() {
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	for _, j := range append([]*job(nil), jt.jobs...) {
+		if !j.fg && j.state() == done {
+			fmt.Fprintf(jt.stderr, "[%d]%s  %-24s%s\n", j.id, jt.mark(j), j.describe(), j.cmd)
+			jt.remove(j)
+		}
+	}
+}
+
+// builtinPrefix is prepended to the names of the job control builtins, so
+// that they reach the exec handler instead of the interpreter's own fg and
+// bg, which are not implemented. ulimit, which the interpreter lacks, is
+// handled the same way.
+const builtinPrefix = "gosh:"
+
+// callHandler renames the job control builtins and ulimit.
+func (jt *jobTable) callHandler(_ context.Context, args []string) ([]string, error) {
+	switch args[0] {
+	case "jobs", "fg", "bg", "ulimit":
+		args = append([]string{builtinPrefix + args[0]}, args[1:]...)
+	}
+	return args, nil
+}
+
+// execHandler implements the job control builtins and starts the commands
+// of jobs in their process group.
+func (jt *jobTable) execHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		var err error
+		switch args[0] {
+		case builtinPrefix + "jobs":
+			err = jt.jobsCmd(ctx, args[1:])
+		case builtinPrefix + "fg":
+			err = jt.fgCmd(ctx, args[1:])
+		case builtinPrefix + "bg":
+			err = jt.bgCmd(ctx, args[1:])
+		case builtinPrefix + "ulimit":
+			err = ulimitCmd(ctx, args[1:])
+		default:
+			if j, ok := ctx.Value(jobKey{}).(*job); ok {
+				return jt.start(ctx, j, args, next)
+			}
+			return next(ctx, args)
+		}
+		if _, ok := interp.IsExitStatus(err); err != nil && !ok {
+			fmt.Fprintf(interp.HandlerCtx(ctx).Stderr, "%s: %v\n", strings.TrimPrefix(args[0], builtinPrefix), err)
+			return interp.NewExitStatus(1)
+		}
+		return err
+	}
+}
+
+func (jt *jobTable) jobsCmd(ctx context.Context, args []string) error {
+	hc := interp.HandlerCtx(ctx)
+	pids := len(args) > 0 && args[0] == "-p"
+	if pids {
+		args = args[1:]
+	}
+
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	list := jt.jobs
+	if len(args) > 0 {
+		list = nil
+		for _, spec := range args {
+			j, err := jt.lookup(spec)
+			if err != nil {
+				return err
+			}
+			list = append(list, j)
+		}
+	}
+	for _, j := range list {
+		if pids {
+			fmt.Fprintln(hc.Stdout, j.pgid)
+			continue
+		}
+		fmt.Fprintf(hc.Stdout, "[%d]%s  %-24s%s\n", j.id, jt.mark(j), j.describe(), j.cmd)
+	}
+	return nil
+}
+
+// fgCmd resumes a job in the foreground and waits for it to finish or stop.
+func (jt *jobTable) fgCmd(ctx context.Context, args []string) error {
+	hc := interp.HandlerCtx(ctx)
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	j, err := jt.lookup(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(hc.Stdout, j.cmd)
+
+	prev := jt.fg
+	j.fg, jt.fg = true, j
+	if err := jt.resume(j); err != nil {
+		return err
+	}
+	for j.state() == running {
+		jt.cond.Wait()
+	}
+	j.fg, jt.fg = false, prev
+	jt.reclaim()
+
+	if j.state() == stopped {
+		fmt.Fprintf(jt.stderr, "\n[%d]%s  %-24s%s\n", j.id, jt.mark(j), j.describe(), j.cmd)
+		return interp.NewExitStatus(j.procs[len(j.procs)-1].status)
+	}
+	jt.remove(j)
+	if s := j.status(); s != 0 {
+		return interp.NewExitStatus(s)
+	}
+	return nil
+}
+
+// bgCmd resumes stopped jobs in the background.
+func (jt *jobTable) bgCmd(ctx context.Context, args []string) error {
+	hc := interp.HandlerCtx(ctx)
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	for _, spec := range args {
+		j, err := jt.lookup(spec)
+		if err != nil {
+			return err
+		}
+		if j.state() != stopped {
+			fmt.Fprintf(hc.Stderr, "bg: job %d already in background\n", j.id)
+			continue
+		}
+		if err := jt.resume(j); err != nil {
+			return err
+		}
+		fmt.Fprintf(hc.Stdout, "[%d]%s %s &\n", j.id, jt.mark(j), j.cmd)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo
+// +build !tinygo
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// setup enables job control if f is a terminal.
+//
+// Jobs then run in process groups of their own. If f is the controlling
+// terminal of the shell, foreground jobs are handed the terminal, so that
+// the keyboard signals reach them directly. Otherwise, as on a console
+// without a session, the shell forwards the signals it receives to the
+// foreground job.
+func (jt *jobTable) setup(f *os.File) {
+	fd := int(f.Fd())
+	if !term.IsTerminal(fd) {
+		return
+	}
+	jt.pgrp = unix.Getpgrp()
+	if fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP); err == nil {
+		if fg != jt.pgrp {
+			// We were started in the background of another shell.
+			return
+		}
+		jt.tty = fd
+	}
+	jt.control = true
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, unix.SIGINT, unix.SIGQUIT, unix.SIGTSTP, unix.SIGTTIN, unix.SIGTTOU)
+	go jt.forward(ch)
+}
+
+// forward sends the keyboard signals the shell receives to the foreground
+// job. The shell itself is never stopped.
+func (jt *jobTable) forward(ch chan os.Signal) {
+	for sig := range ch {
+		if sig == unix.SIGTTIN || sig == unix.SIGTTOU {
+			continue
+		}
+		jt.mu.Lock()
+		if jt.fg != nil && jt.fg.pgid != 0 {
+			_ = unix.Kill(-jt.fg.pgid, sig.(syscall.Signal))
+		}
+		jt.mu.Unlock()
+	}
+}
+
+// execEnv returns the exported variables of env, as the default exec
+// handler passes them to commands.
+func execEnv(env expand.Environ) []string {
+	var list []string
+	env.Each(func(name string, vr expand.Variable) bool {
+		if !vr.IsSet() {
+			for i, kv := range list {
+				if strings.HasPrefix(kv, name+"=") {
+					list[i] = ""
+				}
+			}
+		}
+		if vr.Exported && vr.Kind == expand.String {
+			list = append(list, name+"="+vr.String())
+		}
+		return true
+	})
+	return list
+}
+
+// stdio returns the standard streams of hc if they are all files, so that
+// commands can be started without copying goroutines.
+func stdio(hc interp.HandlerContext) (stdin, stdout, stderr *os.File, ok bool) {
+	if hc.Stdin != nil {
+		if stdin, ok = hc.Stdin.(*os.File); !ok {
+			return
+		}
+	}
+	if stdout, ok = hc.Stdout.(*os.File); !ok {
+		return
+	}
+	stderr, ok = hc.Stderr.(*os.File)
+	return
+}
+
+// start runs a command of j in the job's process group and waits for it to
+// exit, or to stop while j is in the foreground.
+func (jt *jobTable) start(ctx context.Context, j *job, args []string, next interp.ExecHandlerFunc) error {
+	hc := interp.HandlerCtx(ctx)
+	stdin, stdout, stderr, ok := stdio(hc)
+	if !jt.control || !ok {
+		return next(ctx, args)
+	}
+	path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+	if err != nil {
+		fmt.Fprintln(hc.Stderr, err)
+		return interp.NewExitStatus(127)
+	}
+
+	jt.mu.Lock()
+	defer jt.mu.Unlock()
+	cmd := &exec.Cmd{
+		Path:        path,
+		Args:        args,
+		Env:         execEnv(hc.Env),
+		Dir:         hc.Dir,
+		SysProcAttr: &syscall.SysProcAttr{Setpgid: true, Pgid: j.pgid},
+	}
+	// Assigning typed nil files would make the command read from a nil
+	// file instead of /dev/null.
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if j.pgid == 0 && j.fg && jt.tty >= 0 {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = jt.tty
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(hc.Stderr, err)
+		return interp.NewExitStatus(127)
+	}
+	if j.pgid == 0 {
+		j.pgid = cmd.Process.Pid
+	}
+	p := &process{pid: cmd.Process.Pid}
+	j.procs = append(j.procs, p)
+	jt.cond.Broadcast()
+	go jt.wait(j, p, cmd.Process)
+
+	stop := context.AfterFunc(ctx, func() {
+		jt.mu.Lock()
+		defer jt.mu.Unlock()
+		if p.state != done {
+			_ = unix.Kill(p.pid, unix.SIGKILL)
+		}
+	})
+	defer stop()
+
+	for p.state == running || (p.state == stopped && !j.fg) {
+		jt.cond.Wait()
+	}
+	if p.state == done && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if p.status != 0 {
+		return interp.NewExitStatus(p.status)
+	}
+	return nil
+}
+
+// wait tracks the state of p until it exits.
+func (jt *jobTable) wait(j *job, p *process, proc *os.Process) {
+	for {
+		var ws unix.WaitStatus
+		_, err := unix.Wait4(p.pid, &ws, unix.WUNTRACED|unix.WCONTINUED, nil)
+		if err == unix.EINTR {
+			continue
+		}
+
+		jt.mu.Lock()
+		switch {
+		case err != nil:
+			p.state, p.status = done, 1
+		case ws.Exited():
+			p.state, p.status = done, uint8(ws.ExitStatus())
+		case ws.Signaled():
+			p.state, p.status = done, uint8(128+ws.Signal())
+		case ws.Stopped():
+			p.state, p.status = stopped, uint8(128+ws.StopSignal())
+		case ws.Continued():
+			p.state = running
+		}
+		state := p.state
+		if state == done && j.alive() == 0 {
+			// The process group is gone; the next command of the job
+			// starts a new one.
+			j.pgid = 0
+		}
+		jt.cond.Broadcast()
+		jt.mu.Unlock()
+
+		if state == done {
+			_ = proc.Release()
+			return
+		}
+	}
+}
+
+// alive returns the number of processes of j that have not exited.
+func (j *job) alive() int {
+	var n int
+	for _, p := range j.procs {
+		if p.state != done {
+			n++
+		}
+	}
+	return n
+}
+
+// resume continues the stopped processes of j. A job resumed in the
+// foreground is handed the terminal first.
+func (jt *jobTable) resume(j *job) error {
+	if j.pgid == 0 {
+		return nil
+	}
+	if j.fg {
+		jt.setForeground(j.pgid)
+	}
+	var cont bool
+	for _, p := range j.procs {
+		if p.state == stopped {
+			p.state, cont = running, true
+		}
+	}
+	if !cont {
+		return nil
+	}
+	return unix.Kill(-j.pgid, unix.SIGCONT)
+}
+
+// reclaim makes the shell the foreground process group again.
+func (jt *jobTable) reclaim() {
+	jt.setForeground(jt.pgrp)
+}
+
+// setForeground makes pgid the foreground process group of the terminal.
+//
+// SIGTTOU is blocked meanwhile: the shell is usually in the background when
+// it takes the terminal back, and the kernel would signal it instead of
+// changing the process group.
+func (jt *jobTable) setForeground(pgid int) {
+	if jt.tty < 0 {
+		return
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var set, old unix.Sigset_t
+	n, w := int(unix.SIGTTOU)-1, int(8*unsafe.Sizeof(set.Val[0]))
+	set.Val[n/w] |= 1 << (n % w)
+	if err := unix.PthreadSigmask(unix.SIG_BLOCK, &set, &old); err != nil {
+		return
+	}
+	_ = unix.IoctlSetPointerInt(jt.tty, unix.TIOCSPGRP, pgid)
+	_ = unix.PthreadSigmask(unix.SIG_SETMASK, &old, nil)
+}
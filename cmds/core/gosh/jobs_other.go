@@ -0,0 +1,28 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9 && !linux
+// +build !tinygo,!plan9,!linux
+
+package main
+
+import (
+	"context"
+	"os"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// setup does nothing: jobs only get process groups of their own on Linux.
+// Background jobs and the job builtins still work, but jobs cannot be
+// stopped.
+func (jt *jobTable) setup(*os.File) {}
+
+func (jt *jobTable) start(ctx context.Context, _ *job, args []string, next interp.ExecHandlerFunc) error {
+	return next(ctx, args)
+}
+
+func (jt *jobTable) resume(*job) error { return nil }
+
+func (jt *jobTable) reclaim() {}
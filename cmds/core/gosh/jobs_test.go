@@ -0,0 +1,219 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && linux
+// +build !tinygo,linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// newTestJobs returns a job table with job control enabled, as it would be
+// on a console without a session, and a runner using it.
+func newTestJobs(t *testing.T, stdout, stderr *os.File) (*jobTable, *interp.Runner) {
+	t.Helper()
+	jt := newJobTable(stderr)
+	jt.control = true
+	runner, err := interp.New(interp.StdIO(nil, stdout, stderr), interp.CallHandler(jt.callHandler), interp.ExecHandlers(jt.execHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jt, runner
+}
+
+func runJob(t *testing.T, jt *jobTable, runner *interp.Runner, line string) error {
+	t.Helper()
+	f, err := syntax.NewParser().Parse(strings.NewReader(line), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runErr error
+	for _, stmt := range f.Stmts {
+		runErr = jt.run(context.Background(), runner, stmt)
+	}
+	return runErr
+}
+
+func readAll(t *testing.T, f *os.File) string {
+	t.Helper()
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func tempFile(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Create(t.TempDir() + "/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestJobsBackground(t *testing.T) {
+	stdout, stderr := tempFile(t, "stdout"), tempFile(t, "stderr")
+	jt, runner := newTestJobs(t, stdout, stderr)
+
+	if err := runJob(t, jt, runner, "sleep 0.3 &"); err != nil {
+		t.Fatalf("background job: %v", err)
+	}
+	if got := readAll(t, stderr); !regexp.MustCompile(`^\[1\] \d+\n$`).MatchString(got) {
+		t.Errorf("background job announced as %q, want [1] <pid>", got)
+	}
+
+	if err := runJob(t, jt, runner, "jobs"); err != nil {
+		t.Fatalf("jobs: %v", err)
+	}
+	if got, want := readAll(t, stdout), "[1]+  Running                 sleep 0.3\n"; got != want {
+		t.Errorf("jobs = %q, want %q", got, want)
+	}
+
+	if err := runJob(t, jt, runner, "fg %1"); err != nil {
+		t.Fatalf("fg: %v", err)
+	}
+	if got, want := readAll(t, stdout), "sleep 0.3\n"; got != want {
+		t.Errorf("fg printed %q, want %q", got, want)
+	}
+	if len(jt.jobs) != 0 {
+		t.Errorf("jobs left after fg: %d, want 0", len(jt.jobs))
+	}
+
+	// Finished background jobs are reported before the next statement.
+	if err := runJob(t, jt, runner, "false &"); err != nil {
+		t.Fatal(err)
+	}
+	readAll(t, stderr)
+	if err := runJob(t, jt, runner, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readAll(t, stderr), "[1]+  Exit 1                  false\n"; got != want {
+		t.Errorf("notification = %q, want %q", got, want)
+	}
+}
+
+func TestJobsStop(t *testing.T) {
+	stdout, stderr := tempFile(t, "stdout"), tempFile(t, "stderr")
+	jt, runner := newTestJobs(t, stdout, stderr)
+
+	errc := make(chan error)
+	go func() { errc <- runJob(t, jt, runner, "sleep 10") }()
+
+	var pgid int
+	for pgid == 0 {
+		time.Sleep(10 * time.Millisecond)
+		jt.mu.Lock()
+		if jt.fg != nil {
+			pgid = jt.fg.pgid
+		}
+		jt.mu.Unlock()
+	}
+	if err := unix.Kill(-pgid, unix.SIGTSTP); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err == nil || err.Error() != "exit status 148" {
+		t.Errorf("stopped foreground job: got %v, want exit status 148", err)
+	}
+	if got, want := readAll(t, stderr), "\n[1]+  Stopped                 sleep 10\n"; got != want {
+		t.Errorf("stop notification = %q, want %q", got, want)
+	}
+
+	if err := runJob(t, jt, runner, "bg"); err != nil {
+		t.Fatalf("bg: %v", err)
+	}
+	if got, want := readAll(t, stdout), "[1]+ sleep 10 &\n"; got != want {
+		t.Errorf("bg printed %q, want %q", got, want)
+	}
+	if err := runJob(t, jt, runner, "jobs -p"); err != nil {
+		t.Fatalf("jobs -p: %v", err)
+	}
+	if got, want := strings.TrimSpace(readAll(t, stdout)), strconv.Itoa(pgid); got != want {
+		t.Errorf("jobs -p = %q, want %q", got, want)
+	}
+
+	if err := unix.Kill(-pgid, unix.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		jt.mu.Lock()
+		state := jt.jobs[0].state()
+		jt.mu.Unlock()
+		if state == done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := runJob(t, jt, runner, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readAll(t, stderr), "[1]+  Exit 143                sleep 10\n"; got != want {
+		t.Errorf("notification = %q, want %q", got, want)
+	}
+}
+
+func TestJobsLookup(t *testing.T) {
+	jt := newJobTable(nil)
+	for _, cmd := range []string{"a", "b", "c"} {
+		jt.add(&job{cmd: cmd})
+	}
+	for _, tt := range []struct {
+		spec string
+		want string
+		err  error
+	}{
+		{spec: "", want: "c"},
+		{spec: "%%", want: "c"},
+		{spec: "%+", want: "c"},
+		{spec: "%-", want: "b"},
+		{spec: "%1", want: "a"},
+		{spec: "2", want: "b"},
+		{spec: "%4", err: errNoJob},
+		{spec: "%x", err: errNoJob},
+	} {
+		j, err := jt.lookup(tt.spec)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("lookup(%q) = %v, want %v", tt.spec, err, tt.err)
+			continue
+		}
+		if err == nil && j.cmd != tt.want {
+			t.Errorf("lookup(%q) = job %q, want %q", tt.spec, j.cmd, tt.want)
+		}
+	}
+
+	if _, err := newJobTable(nil).lookup(""); !errors.Is(err, errNoJob) {
+		t.Errorf("lookup in empty table = %v, want %v", err, errNoJob)
+	}
+}
+
+func TestJobsBuiltinErrors(t *testing.T) {
+	stdout, stderr := tempFile(t, "stdout"), tempFile(t, "stderr")
+	jt, runner := newTestJobs(t, stdout, stderr)
+	if err := runJob(t, jt, runner, "fg %3"); err == nil || err.Error() != "exit status 1" {
+		t.Errorf("fg %%3: got %v, want exit status 1", err)
+	}
+	if got, want := readAll(t, stderr), "fg: %3: no such job\n"; got != want {
+		t.Errorf("fg %%3 printed %q, want %q", got, want)
+	}
+}
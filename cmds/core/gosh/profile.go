@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9
+// +build !tinygo,!plan9
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var (
+	noProfile = flag.Bool("noprofile", false, "Do not read the startup scripts of the interactive shell")
+	rcFile    = flag.String("rcfile", "", "Read this file instead of ~/.goshrc when the interactive shell starts")
+)
+
+// profiles are the system-wide startup scripts of interactive shells. They
+// may be patterns.
+var profiles = []string{"/etc/profile", "/etc/profile.d/*.sh"}
+
+// startupScripts returns the startup scripts to read, in order: the system
+// profiles and the user's rc file, which is either rc or ~/.goshrc.
+func startupScripts(rc string) []string {
+	var files []string
+	for _, p := range profiles {
+		m, err := filepath.Glob(p)
+		if err != nil {
+			continue
+		}
+		sort.Strings(m)
+		files = append(files, m...)
+	}
+	if rc != "" {
+		return append(files, rc)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rc := filepath.Join(home, ".goshrc"); fileExists(rc) {
+			files = append(files, rc)
+		}
+	}
+	return files
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// loadProfile prepares runner for interactive use and runs the startup
+// scripts in it, so that they can set variables, functions and aliases for
+// the session. Failing scripts are reported to stderr and skipped.
+func loadProfile(runner *interp.Runner, stderr io.Writer, files []string) error {
+	// Like other shells, expand aliases when interactive.
+	if err := runStmts(runner, strings.NewReader("shopt -s expand_aliases"), ""); err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		err := runProfile(runner, name)
+		if runner.Exited() {
+			return err
+		}
+		if _, ok := interp.IsExitStatus(err); err != nil && !ok {
+			fmt.Fprintf(stderr, "gosh: %s: %v\n", name, err)
+		}
+	}
+	return nil
+}
+
+func runProfile(runner *interp.Runner, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return runStmts(runner, f, name)
+}
+
+// runStmts runs the statements read from r one by one. Unlike running a
+// whole file, this does not exit the shell at the end.
+func runStmts(runner *interp.Runner, r io.Reader, name string) error {
+	prog, err := syntax.NewParser().Parse(r, name)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range prog.Stmts {
+		err = runner.Run(context.Background(), stmt)
+		if runner.Exited() {
+			break
+		}
+	}
+	return err
+}
+
+// shellVar returns the value of the variable name in runner, which the
+// startup scripts may have set.
+func shellVar(runner *interp.Runner, name string) string {
+	if vr, ok := runner.Vars[name]; ok && vr.IsSet() {
+		return vr.String()
+	}
+	return runner.Env.Get(name).String()
+}
+
+// histFile returns the file the interactive shell keeps its history in:
+// $HISTFILE, ~/.gosh_history, or a file in the temporary directory if there
+// is no home directory.
+func histFile(runner *interp.Runner) string {
+	if f := shellVar(runner, "HISTFILE"); f != "" {
+		return f
+	}
+	if home := shellVar(runner, "HOME"); home != "" {
+		return filepath.Join(home, ".gosh_history")
+	}
+	return filepath.Join(os.TempDir(), "gosh.history")
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9
+// +build !tinygo,!plan9
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+func TestStartupScripts(t *testing.T) {
+	etc := t.TempDir()
+	home := t.TempDir()
+	for _, f := range []string{"profile", "profile.d/b.sh", "profile.d/a.sh", "profile.d/c.txt"} {
+		p := filepath.Join(etc, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(p []string) { profiles = p }(profiles)
+	profiles = []string{filepath.Join(etc, "profile"), filepath.Join(etc, "profile.d", "*.sh")}
+	t.Setenv("HOME", home)
+
+	system := []string{
+		filepath.Join(etc, "profile"),
+		filepath.Join(etc, "profile.d", "a.sh"),
+		filepath.Join(etc, "profile.d", "b.sh"),
+	}
+	if got := startupScripts(""); !reflect.DeepEqual(got, system) {
+		t.Errorf("startupScripts() without ~/.goshrc = %q, want %q", got, system)
+	}
+
+	rc := filepath.Join(home, ".goshrc")
+	if err := os.WriteFile(rc, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := startupScripts(""), append(system, rc); !reflect.DeepEqual(got, want) {
+		t.Errorf("startupScripts() = %q, want %q", got, want)
+	}
+	if got, want := startupScripts("/other/rc"), append(system, "/other/rc"); !reflect.DeepEqual(got, want) {
+		t.Errorf("startupScripts(/other/rc) = %q, want %q", got, want)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	d := t.TempDir()
+	profile := filepath.Join(d, "profile")
+	broken := filepath.Join(d, "broken")
+	rc := filepath.Join(d, "rc")
+	for name, script := range map[string]string{
+		profile: "export PATH=/sbin:$PATH\nalias hi='echo hello'\nfalse\n",
+		broken:  "if then\n",
+		rc:      "HISTFILE=/var/log/history\ngreet() { hi \"$@\"; }\n",
+	} {
+		if err := os.WriteFile(name, []byte(script), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	runner, err := interp.New(interp.StdIO(nil, &stdout, &stderr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadProfile(runner, &stderr, []string{profile, broken, filepath.Join(d, "missing"), rc}); err != nil {
+		t.Fatalf("loadProfile() = %v, want nil", err)
+	}
+	if got := stderr.String(); !strings.Contains(got, broken) || !strings.Contains(got, "missing") || strings.Contains(got, profile+":") {
+		t.Errorf("loadProfile() reported %q, want errors for the broken and missing scripts only", got)
+	}
+
+	if err := runStmts(runner, strings.NewReader("greet world\necho $PATH"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); !strings.HasPrefix(got, "hello world\n/sbin:") {
+		t.Errorf("alias, function and PATH from the profile: got %q, want hello world and /sbin:...", got)
+	}
+}
+
+func TestLoadProfileExit(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "profile")
+	if err := os.WriteFile(profile, []byte("exit 3\necho unreachable\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	runner, err := interp.New(interp.StdIO(nil, &out, &out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loadProfile(runner, &out, []string{profile})
+	if status, ok := interp.IsExitStatus(err); !ok || status != 3 || !runner.Exited() {
+		t.Errorf("loadProfile() = %v, want exit status 3", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("loadProfile() printed %q, want nothing", out.String())
+	}
+}
+
+func TestHistFile(t *testing.T) {
+	t.Setenv("HISTFILE", "")
+	t.Setenv("HOME", "/home/op")
+	runner, err := interp.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := histFile(runner), "/home/op/.gosh_history"; got != want {
+		t.Errorf("histFile() = %q, want %q", got, want)
+	}
+
+	var out bytes.Buffer
+	runner, err = interp.New(interp.StdIO(nil, &out, &out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loadProfile(runner, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := runStmts(runner, strings.NewReader("HISTFILE=/persist/history"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := histFile(runner), "/persist/history"; got != want {
+		t.Errorf("histFile() after setting HISTFILE = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo
+// +build !tinygo
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// ulimitCmd implements ulimit [-SHa] [-RESOURCE]... [LIMIT], as in bash.
+// The resource is -f if none is given, and LIMIT is in its units, a number
+// or unlimited, hard or soft for the current limit. -S and -H choose the
+// soft or hard limit; without either, both are set and the soft one is
+// shown.
+//
+// The limits are those of the shell, which the commands it starts
+// inherit. The Go runtime raises its own soft limit of open files, so
+// ulimit -n shows the hard limit until it is set.
+func ulimitCmd(ctx context.Context, args []string) error {
+	hc := interp.HandlerCtx(ctx)
+	var soft, hard, all bool
+	var resources []rlimit.Resource
+	var value string
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '-' {
+			if value != "" {
+				return fmt.Errorf("too many arguments")
+			}
+			value = arg
+			continue
+		}
+		for i := 1; i < len(arg); i++ {
+			switch c := arg[i]; c {
+			case 'S':
+				soft = true
+			case 'H':
+				hard = true
+			case 'a':
+				all = true
+			default:
+				r, ok := rlimit.ByOption(c)
+				if !ok {
+					return fmt.Errorf("-%c: invalid option", c)
+				}
+				resources = append(resources, r)
+			}
+		}
+	}
+	if all {
+		if value != "" {
+			return fmt.Errorf("-a takes no limit")
+		}
+		resources = rlimit.Resources
+	}
+	if len(resources) == 0 {
+		r, _ := rlimit.ByOption('f')
+		resources = append(resources, r)
+	}
+
+	if value != "" {
+		if len(resources) > 1 {
+			return fmt.Errorf("a limit can only be set for one resource")
+		}
+		r := resources[0]
+		l, err := rlimit.Get(0, r.Resource)
+		if err != nil {
+			return err
+		}
+		var v uint64
+		switch value {
+		case "soft":
+			v = l.Cur
+		case "hard":
+			v = l.Max
+		default:
+			if v, err = rlimit.ParseValue(value, r.Scale); err != nil {
+				return err
+			}
+		}
+		if soft || !hard {
+			l.Cur = v
+		}
+		if hard || !soft {
+			l.Max = v
+		}
+		return rlimit.Set(0, r.Resource, l)
+	}
+
+	for _, r := range resources {
+		l, err := rlimit.Get(0, r.Resource)
+		if err != nil {
+			return err
+		}
+		v := l.Cur
+		if hard && !soft {
+			v = l.Max
+		}
+		if len(resources) == 1 {
+			fmt.Fprintln(hc.Stdout, rlimit.FormatValue(v, r.Scale))
+			continue
+		}
+		opt := fmt.Sprintf("(-%c)", r.Option)
+		if r.ULimitUnits != "" {
+			opt = fmt.Sprintf("(%s, -%c)", r.ULimitUnits, r.Option)
+		}
+		fmt.Fprintf(hc.Stdout, "%-35s %20s %s\n", r.Description, opt, rlimit.FormatValue(v, r.Scale))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo
+// +build !tinygo
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+func TestUlimit(t *testing.T) {
+	old, err := rlimit.Get(0, unix.RLIMIT_CORE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rlimit.Set(0, unix.RLIMIT_CORE, old)
+	hard := rlimit.FormatValue(old.Max, 1024)
+
+	for _, tt := range []struct {
+		name    string
+		command string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "set soft",
+			command: "ulimit -S -c 0; ulimit -c; ulimit -Hc",
+			wantOut: "0\n" + hard + "\n",
+		},
+		{
+			name:    "soft from hard",
+			command: "ulimit -S -c 0; ulimit -Sc hard; ulimit -c",
+			wantOut: hard + "\n",
+		},
+		{
+			name:    "several",
+			command: "ulimit -S -c 0; ulimit -c -c",
+			wantOut: strings.Repeat(fmt.Sprintf("%-35s %20s 0\n", "max core file size", "(blocks, -c)"), 2),
+		},
+		{
+			name:    "inherited",
+			command: "ulimit -S -c 1; grep core /proc/self/limits",
+			wantOut: fmt.Sprintf("%-26s%-21s%-21s%-10s\n", "Max core file size", "1024", rlimit.FormatValue(old.Max, 1), "bytes"),
+		},
+		{
+			name:    "bad option",
+			command: "ulimit -z; echo $?",
+			wantOut: "1\n",
+			wantErr: "ulimit: -z: invalid option\n",
+		},
+		{
+			name:    "bad limit",
+			command: "ulimit -c lots",
+			wantErr: "ulimit: invalid limit \"lots\"\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var in, out, stderr bytes.Buffer
+			run(&in, &out, &stderr, tt.command)
+			if out.String() != tt.wantOut {
+				t.Errorf("Stdout = %q, want %q", out.String(), tt.wantOut)
+			}
+			if stderr.String() != tt.wantErr {
+				t.Errorf("Stderr = %q, want %q", stderr.String(), tt.wantErr)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	run(&bytes.Buffer{}, &out, &bytes.Buffer{}, "ulimit -a")
+	if n := strings.Count(out.String(), "\n"); n != len(rlimit.Resources) || !strings.Contains(out.String(), "(-n)") {
+		t.Errorf("ulimit -a printed %q", out.String())
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo && !plan9 && !linux
+// +build !tinygo,!plan9,!linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// ulimitCmd is only implemented on Linux.
+func ulimitCmd(context.Context, []string) error {
+	return errors.New("not supported")
+}
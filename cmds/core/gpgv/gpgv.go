@@ -13,6 +13,10 @@
 //	It prints "OK\n" to stdout if the check succeeds and exits with 0. It
 //	prints an error message and exits with non-0 otherwise.
 //
+//	KEY may hold several RSA, ECDSA or Ed25519 keys, e.g. an old and a new
+//	key while rolling keys over; SIG must be made by one of them. KEY and
+//	SIG may be binary or ASCII-armored.
+//
 //	The openpgp package ReadKeyRing function does not completely implement
 //	RFC4880 in that it can't use a PublicSigningKey with 0 signatures. We
 //	use one from Eric Grosse instead.
@@ -27,7 +31,6 @@
 package main
 
 import (
-	"crypto"
 	"errors"
 	"flag"
 	"fmt"
@@ -37,6 +40,7 @@ import (
 
 	gpgerror "github.com/ProtonMail/go-crypto/openpgp/errors"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/u-root/u-root/pkg/vfile"
 )
 
 var (
@@ -49,46 +53,82 @@ func init() {
 	flag.BoolVar(&verbose, "v", false, "verbose")
 }
 
-func readPublicSigningKey(keyf io.Reader) (*packet.PublicKey, error) {
-	keypackets := packet.NewReader(keyf)
-	p, err := keypackets.Next()
+// readPublicSigningKeys reads all public keys and subkeys from a binary or
+// ASCII-armored key file, so that keys can be rolled over by shipping the
+// old and new key in one file.
+func readPublicSigningKeys(keyf io.Reader) ([]*packet.PublicKey, error) {
+	r, err := vfile.Dearmor(keyf)
 	if err != nil {
 		return nil, err
 	}
-	switch pkt := p.(type) {
-	case *packet.PublicKey:
-		debug("key: ", pkt)
-		return pkt, nil
-	default:
-		log.Printf("ReadPublicSigningKey: got %T, want *packet.PublicKey", pkt)
+	keypackets := packet.NewReader(r)
+	var keys []*packet.PublicKey
+	for {
+		p, err := keypackets.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch pkt := p.(type) {
+		case *packet.PublicKey:
+			debug("key: %v", pkt.KeyIdString())
+			keys = append(keys, pkt)
+		default:
+			if len(keys) == 0 {
+				log.Printf("ReadPublicSigningKey: got %T, want *packet.PublicKey", pkt)
+				return nil, gpgerror.StructuralError("expected first packet to be PublicKey")
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, gpgerror.StructuralError("no public keys")
 	}
-	return nil, gpgerror.StructuralError("expected first packet to be PublicKey")
+	return keys, nil
 }
 
-func verifyDetachedSignature(key *packet.PublicKey, contentf, sigf io.Reader) error {
-	var hashFunc crypto.Hash
-
-	packets := packet.NewReader(sigf)
+// verifyDetachedSignature checks a binary or ASCII-armored detached signature
+// of content against the key that issued it or, if the signature does not
+// say, against all keys.
+func verifyDetachedSignature(keys []*packet.PublicKey, contentf, sigf io.Reader) error {
+	r, err := vfile.Dearmor(sigf)
+	if err != nil {
+		return err
+	}
+	packets := packet.NewReader(r)
 	p, err := packets.Next()
 	if err != nil {
 		return err
 	}
-	switch sig := p.(type) {
-	case *packet.Signature:
-		hashFunc = sig.Hash
-	default:
+	sig, ok := p.(*packet.Signature)
+	if !ok {
 		return gpgerror.UnsupportedError("unrecognized signature")
 	}
 
-	h := hashFunc.New()
-	if _, err := io.Copy(h, contentf); err != nil && err != io.EOF {
+	var candidates []*packet.PublicKey
+	for _, key := range keys {
+		if sig.IssuerKeyId == nil || *sig.IssuerKeyId == key.KeyId {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) == 0 {
+		return gpgerror.ErrUnknownIssuer
+	}
+
+	// Each attempt consumes the hash, so keep the content around for more
+	// than one key.
+	content, err := io.ReadAll(contentf)
+	if err != nil {
 		return err
 	}
-	switch sig := p.(type) {
-	case *packet.Signature:
-		err = key.VerifySignature(h, sig)
-	default:
-		return fmt.Errorf("unknown signature")
+	for _, key := range candidates {
+		h := sig.Hash.New()
+		h.Write(content)
+		if err = key.VerifySignature(h, sig); err == nil {
+			debug("signed by key %v", key.KeyIdString())
+			return nil
+		}
 	}
 	return err
 }
@@ -97,6 +137,9 @@ func runGPGV(w io.Writer, verbose bool, keyfile, sigfile, datafile string) error
 	if keyfile == "" || sigfile == "" || datafile == "" {
 		return errUsage
 	}
+	if verbose {
+		debug = log.Printf
+	}
 
 	keyf, err := os.Open(keyfile)
 	if err != nil {
@@ -116,13 +159,13 @@ func runGPGV(w io.Writer, verbose bool, keyfile, sigfile, datafile string) error
 	}
 	defer contentf.Close()
 
-	key, err := readPublicSigningKey(keyf)
+	keys, err := readPublicSigningKeys(keyf)
 	if err != nil {
-		return fmt.Errorf("key: %v ", err)
+		return fmt.Errorf("key: %w", err)
 	}
 
-	if err = verifyDetachedSignature(key, contentf, sigf); err != nil {
-		return fmt.Errorf("verify: %v", err)
+	if err = verifyDetachedSignature(keys, contentf, sigf); err != nil {
+		return fmt.Errorf("verify: %w", err)
 	}
 	fmt.Fprintf(w, "OK")
 	return nil
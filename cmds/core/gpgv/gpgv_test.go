@@ -7,8 +7,16 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	gpgerror "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 )
 
 func TestRunGPGV(t *testing.T) {
@@ -95,3 +103,75 @@ func TestRunGPGV(t *testing.T) {
 		})
 	}
 }
+
+func TestRunGPGVRollover(t *testing.T) {
+	var ents []*openpgp.Entity
+	for _, c := range []*packet.Config{
+		{Algorithm: packet.PubKeyAlgoEdDSA, Curve: packet.Curve25519},
+		{Algorithm: packet.PubKeyAlgoECDSA, Curve: packet.CurveNistP256},
+		{Algorithm: packet.PubKeyAlgoEdDSA, Curve: packet.Curve25519},
+	} {
+		e, err := openpgp.NewEntity("uroot", "", "uroot@uroot.org", c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ents = append(ents, e)
+	}
+	oldKey, newKey, otherKey := ents[0], ents[1], ents[2]
+
+	dir := t.TempDir()
+	write := func(name string, f func(io.Writer) error) string {
+		t.Helper()
+		var b bytes.Buffer
+		if err := f(&b); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	const content = "kernel"
+	data := write("data", func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	})
+	keys := write("keys.asc", func(w io.Writer) error {
+		aw, err := armor.Encode(w, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return err
+		}
+		for _, e := range []*openpgp.Entity{oldKey, newKey} {
+			if err := e.Serialize(aw); err != nil {
+				return err
+			}
+		}
+		return aw.Close()
+	})
+	sign := func(e *openpgp.Entity, armored bool) string {
+		return write(fmt.Sprintf("%s-%t.sig", e.PrimaryKey.KeyIdString(), armored), func(w io.Writer) error {
+			if armored {
+				return openpgp.ArmoredDetachSign(w, e, strings.NewReader(content), nil)
+			}
+			return openpgp.DetachSign(w, e, strings.NewReader(content), nil)
+		})
+	}
+
+	for _, tt := range []struct {
+		name    string
+		sigfile string
+		wantErr error
+	}{
+		{name: "OldKeyEd25519Armored", sigfile: sign(oldKey, true)},
+		{name: "NewKeyECDSA", sigfile: sign(newKey, false)},
+		{name: "UntrustedKey", sigfile: sign(otherKey, true), wantErr: gpgerror.ErrUnknownIssuer},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := runGPGV(&buf, false, keys, tt.sigfile, data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("runGPGV(%s) = %v, want %v", tt.sigfile, err, tt.wantErr)
+			}
+		})
+	}
+}
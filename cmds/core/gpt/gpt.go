@@ -7,76 +7,361 @@
 // Synopsis:
 //
 //	gpt [-w] file
+//	gpt -J|-d file
+//	gpt -c [-n] [-a ALIGN] file
+//	gpt -b BACKUP file
+//	gpt -r BACKUP [-g disk|parts|all] file
+//	gpt -m protective|N[*],... file
 //
 // Description:
 //
 //	For -w, it reads a JSON formatted GPT from stdin, and writes 'file'
 //	which is usually a device. It writes both primary and secondary headers.
 //
+//	For -J and -d, it dumps the partition layout in the JSON and script
+//	formats of sfdisk.
+//
+//	For -c, it reads a partition layout in either format from stdin and
+//	writes a new partition table with a protective MBR to 'file'. In
+//	scripts, partition lines have the fields start, size, type, uuid,
+//	name and attrs, e.g.
+//
+//		size=512MiB, type=U, name="EFI"
+//		type=L, name="root"
+//
+//	Sizes are in sectors, or in bytes with a K, M, G or T suffix. Omitted
+//	starts are aligned to ALIGN sectors, an omitted size takes up the rest
+//	of the disk, and types can be GUIDs or the sfdisk shortcuts U (EFI
+//	system), L (Linux), S (swap), H (home), R (RAID) and V (LVM).
+//
+//	For -b, it saves the partition table to BACKUP: as JSON if its name
+//	ends in .json, otherwise in the binary format of sgdisk --backup.
+//	For -r, it restores a backup in either format to 'file', which may be
+//	another disk of a different size; the backup GPT is moved to its end.
+//	With -g, the disk, the partitions or both get new random GUIDs, so
+//	that the copy can be used next to the original.
+//
+//	For -m, it writes a new MBR: a protective MBR, or a hybrid MBR which
+//	mirrors up to three GPT partitions, by number, for firmware which does
+//	not read GPTs. A partition followed by * is marked active. The boot
+//	code of the old MBR is kept.
+//
 //	Otherwise it just writes the headers to stdout in JSON format.
+//
+// Options:
+//
+//	-w: write a raw JSON formatted GPT
+//	-J: dump the partition layout as JSON
+//	-d: dump the partition layout as an sfdisk script
+//	-c: create a partition table from a script or JSON layout
+//	-n: with -c, print the new layout as JSON instead of writing it
+//	-a: with -c, align partitions to this many sectors (default 2048)
+//	-b: save the partition table to a backup file
+//	-r: restore the partition table from a backup file
+//	-g: with -r, give the disk, parts or all new random GUIDs
+//	-m: write a protective or hybrid MBR
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/mount/gpt"
 )
 
 const cmd = "gpt [options] file"
 
-var write = flag.Bool("w", false, "Write GPT to file")
+var (
+	errUsage = errors.New("usage: " + cmd)
+	errGUIDs = errors.New("-g must be disk, parts or all")
+)
 
-func init() {
-	defUsage := flag.Usage
-	flag.Usage = func() {
-		os.Args[0] = cmd
-		defUsage()
-		os.Exit(1)
-	}
+// jsonLayout is the JSON form of a layout that sfdisk uses.
+type jsonLayout struct {
+	PartitionTable *gpt.Layout `json:"partitiontable"`
 }
 
-func main() {
-	flag.Parse()
-	if flag.NArg() != 1 {
-		flag.Usage()
+type params struct {
+	write   bool
+	json    bool
+	dump    bool
+	create  bool
+	dryRun  bool
+	align   uint64
+	backup  string
+	restore string
+	guids   string
+	mbr     string
+}
+
+func run(stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	var p params
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&p.write, "w", false, "Write GPT to file")
+	f.BoolVar(&p.json, "J", false, "Dump the partition layout as JSON")
+	f.BoolVar(&p.dump, "d", false, "Dump the partition layout as an sfdisk script")
+	f.BoolVar(&p.create, "c", false, "Create a partition table from a script or JSON layout on stdin")
+	f.BoolVar(&p.dryRun, "n", false, "With -c, print the new layout instead of writing it")
+	f.Uint64Var(&p.align, "a", 2048, "With -c, align partitions to this many sectors")
+	f.StringVar(&p.backup, "b", "", "Save the partition table to a backup file")
+	f.StringVar(&p.restore, "r", "", "Restore the partition table from a backup file")
+	f.StringVar(&p.guids, "g", "", "With -r, give the disk, parts or all new random GUIDs")
+	f.StringVar(&p.mbr, "m", "", "Write a protective MBR, or a hybrid MBR of partitions N[*],...")
+	if err := f.Parse(args[1:]); err != nil {
+		return err
+	}
+	if f.NArg() != 1 {
+		return errUsage
 	}
 
 	m := os.O_RDONLY
-	if *write {
+	if p.write || (p.create && !p.dryRun) || p.restore != "" || p.mbr != "" {
 		m = os.O_RDWR
 	}
+	n := f.Arg(0)
+	file, err := os.OpenFile(n, m, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch {
+	case p.write:
+		t := &gpt.PartitionTable{}
+		if err := json.NewDecoder(stdin).Decode(&t); err != nil {
+			return fmt.Errorf("reading in JSON: %w", err)
+		}
+		if err := gpt.Write(file, t); err != nil {
+			return fmt.Errorf("writing %v: %w", n, err)
+		}
+		return nil
+
+	case p.create:
+		return create(stdin, stdout, file, p)
+
+	case p.backup != "":
+		return backup(file, p.backup)
+
+	case p.restore != "":
+		return restore(file, p.restore, p.guids)
+
+	case p.mbr != "":
+		return writeMBR(file, p.mbr)
 
-	n := flag.Args()[0]
-	f, err := os.OpenFile(n, m, 0)
+	case p.json, p.dump:
+		t, err := gpt.New(file)
+		if t.Primary == nil {
+			return fmt.Errorf("reading %v: %w", n, err)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: %v: %v\n", n, err)
+		}
+		l := t.Layout(n)
+		if p.dump {
+			return writeScript(stdout, l)
+		}
+		b, err := json.MarshalIndent(jsonLayout{l}, "", "   ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", b)
+		return err
+	}
+
+	// We might get one back, we might get both.
+	// In the event of an error, we show what we can
+	// so you can at least see what went wrong.
+	t, err := gpt.New(file)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintf(stderr, "Error reading %v: %v\n", n, err)
+	}
+	// Emit this as a JSON array. Suggestions welcome on better ways to do this.
+	_, err = fmt.Fprintf(stdout, "%s\n", t)
+	return err
+}
+
+// create writes a new partition table for the layout read from stdin.
+func create(stdin io.Reader, stdout io.Writer, file *os.File, p params) error {
+	in, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	var l *gpt.Layout
+	if trimmed := bytes.TrimSpace(in); len(trimmed) > 0 && trimmed[0] == '{' {
+		var j jsonLayout
+		if err := json.Unmarshal(in, &j); err != nil {
+			return fmt.Errorf("reading in JSON: %w", err)
+		}
+		if l = j.PartitionTable; l == nil {
+			return fmt.Errorf("reading in JSON: no partitiontable")
+		}
+	} else {
+		var align uint64
+		if l, align, err = parseScript(bytes.NewReader(in)); err != nil {
+			return err
+		}
+		if align != 0 {
+			p.align = align
+		}
+	}
+
+	// Seeking to the end also tells the size of block devices.
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	t, err := gpt.NewPartitionTable(uint64(size)/gpt.BlockSize, l, p.align)
+	if err != nil {
+		return err
+	}
+	if p.dryRun {
+		b, err := json.MarshalIndent(jsonLayout{t.Layout(l.Device)}, "", "   ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", b)
+		return err
+	}
+
+	// Keep the boot code of the old MBR.
+	if _, err := file.ReadAt(t.MasterBootRecord[:440], 0); err != nil {
+		return err
 	}
+	if err := gpt.Write(file, t); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return reread(file)
+}
 
-	switch *write {
-	case true:
-		p := &gpt.PartitionTable{}
-		if err := json.NewDecoder(os.Stdin).Decode(&p); err != nil {
-			log.Fatalf("Reading in JSON: %v", err)
+// backup saves the partition table of file to name.
+func backup(file *os.File, name string) error {
+	t, err := gpt.New(file)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if strings.HasSuffix(name, ".json") {
+		fmt.Fprintf(&b, "%s\n", t)
+	} else if err := gpt.SaveBackup(&b, t); err != nil {
+		return err
+	}
+	return os.WriteFile(name, b.Bytes(), 0o644)
+}
+
+// restore writes the partition table backed up in name to file.
+func restore(file *os.File, name, guids string) error {
+	in, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var t *gpt.PartitionTable
+	if trimmed := bytes.TrimSpace(in); len(trimmed) > 0 && trimmed[0] == '{' {
+		t = &gpt.PartitionTable{}
+		if err := json.Unmarshal(in, t); err != nil {
+			return fmt.Errorf("reading in JSON: %w", err)
 		}
-		if err := gpt.Write(f, p); err != nil {
-			log.Fatalf("Writing %v: %v", n, err)
+		if t.MasterBootRecord == nil || t.Primary == nil {
+			return fmt.Errorf("%w: no MBR or primary GPT", gpt.ErrBackup)
+		}
+	} else if t, err = gpt.LoadBackup(bytes.NewReader(in)); err != nil {
+		return err
+	}
+
+	switch guids {
+	case "":
+	case "disk", "parts", "all":
+		if err := t.NewGUIDs(guids != "parts", guids != "disk"); err != nil {
+			return err
 		}
 	default:
-		// We might get one back, we might get both.
-		// In the event of an error, we show what we can
-		// so you can at least see what went wrong.
-		p, err := gpt.New(f)
+		return errGUIDs
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if t, err = t.Relocate(uint64(size) / gpt.BlockSize); err != nil {
+		return err
+	}
+	if err := gpt.Write(file, t); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return reread(file)
+}
+
+// parseMBR parses the partitions of a hybrid MBR, e.g. "1*,3".
+func parseMBR(s string) (parts []int, active int, err error) {
+	for _, f := range strings.Split(s, ",") {
+		n, ok := strings.CutSuffix(f, "*")
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: partition %q", gpt.ErrMBR, f)
+		}
+		if ok {
+			if active != 0 {
+				return nil, 0, fmt.Errorf("%w: more than one active partition", gpt.ErrMBR)
+			}
+			active = i
+		}
+		parts = append(parts, i)
+	}
+	return parts, active, nil
+}
+
+// writeMBR writes a protective or hybrid MBR for the GPT of file.
+func writeMBR(file *os.File, s string) error {
+	t, err := gpt.New(file)
+	if err != nil {
+		return err
+	}
+	var m *gpt.MBR
+	if s == "protective" {
+		size, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		m = gpt.ProtectiveMBR(uint64(size) / gpt.BlockSize)
+	} else {
+		parts, active, err := parseMBR(s)
 		if err != nil {
-			log.Printf("Error reading %v: %v", n, err)
+			return err
 		}
-		// Emit this as a JSON array. Suggestions welcome on better ways to do this.
-		if _, err := fmt.Printf("%s\n", p); err != nil {
-			log.Fatal(err)
+		if m, err = gpt.HybridMBR(t.Primary, parts, active); err != nil {
+			return err
 		}
+	}
+	// Keep the boot code of the old MBR.
+	copy(m[:440], t.MasterBootRecord[:440])
+	if _, err := file.WriteAt(m[:], 0); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return reread(file)
+}
 
+func main() {
+	if err := run(os.Stdin, os.Stdout, os.Stderr, os.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(1)
+		}
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,20 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reread makes the kernel read the new partition table of a block device.
+func reread(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeDevice == 0 {
+		return err
+	}
+	return unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0)
+}
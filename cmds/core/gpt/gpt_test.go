@@ -0,0 +1,274 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-root/u-root/pkg/mount/gpt"
+)
+
+func TestParseSectors(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want uint64
+		err  bool
+	}{
+		{in: "", want: 0},
+		{in: "+", want: 0},
+		{in: "2048", want: 2048},
+		{in: "1M", want: 2048},
+		{in: "512MiB", want: 1 << 20},
+		{in: "1G", want: 1 << 21},
+		{in: "1T", want: 1 << 31},
+		{in: "3K", want: 6},
+		{in: "1KB", err: true},
+		{in: "1X", err: true},
+		{in: "iB", err: true},
+	} {
+		got, err := parseSectors(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseSectors(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := `# An installer layout.
+label: gpt
+label-id: 01234567-89AB-CDEF-0123-456789ABCDEF
+unit: sectors
+grain: 4MiB
+
+/dev/sda1 : start=8192, size=512MiB, type=U, name="EFI, system: boot", attrs="RequiredPartition"
+size=1G, type=S
+,,L
+`
+	l, align, err := parseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if align != 8192 {
+		t.Errorf("align = %d, want 8192", align)
+	}
+	want := &gpt.Layout{
+		Label: "gpt",
+		ID:    "01234567-89AB-CDEF-0123-456789ABCDEF",
+		Unit:  "sectors",
+		Partitions: []gpt.LayoutPart{
+			{Node: "/dev/sda1", Start: 8192, Size: 1 << 20, Type: "U", Name: "EFI, system: boot", Attrs: "RequiredPartition"},
+			{Size: 1 << 21, Type: "S"},
+			{Type: "L"},
+		},
+	}
+	if diff := cmp.Diff(want, l); diff != "" {
+		t.Errorf("parseScript (-want +got):\n%s", diff)
+	}
+
+	for _, bad := range []string{
+		"label: dos\n",
+		"unit: cylinders\n",
+		"sector-size: 4096\n",
+		"foo: bar\n",
+		"start=1, size=2, colour=red\n",
+		"size=12Q\n",
+	} {
+		if _, _, err := parseScript(strings.NewReader(bad)); !errors.Is(err, errScript) {
+			t.Errorf("parseScript(%q) = %v, want %v", bad, err, errScript)
+		}
+	}
+}
+
+func TestCreateDumpRestore(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "disk")
+	if err := os.WriteFile(img, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(img, 64<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	script := "size=100MiB, type=U\ntype=L, name=\"root\"\n"
+	if err := run(strings.NewReader(script), io.Discard, io.Discard, []string{"gpt", "-c", img}); err == nil {
+		t.Errorf("creating a 100 MiB partition on a 64 MiB disk succeeded")
+	}
+	script = "size=16MiB, type=U, name=\"EFI\"\ntype=L, name=\"root\"\n"
+	if err := run(strings.NewReader(script), io.Discard, io.Discard, []string{"gpt", "-c", img}); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump bytes.Buffer
+	if err := run(nil, &dump, io.Discard, []string{"gpt", "-d", img}); err != nil {
+		t.Fatal(err)
+	}
+	l, _, err := parseScript(&dump)
+	if err != nil {
+		t.Fatalf("parsing the dump: %v", err)
+	}
+	if len(l.Partitions) != 2 {
+		t.Fatalf("dump has %d partitions, want 2", len(l.Partitions))
+	}
+	esp, root := l.Partitions[0], l.Partitions[1]
+	if esp.Start != 2048 || esp.Size != 32768 || esp.Type != gpt.Types["esp"] || esp.Name != "EFI" {
+		t.Errorf("EFI partition: %+v", esp)
+	}
+	if root.Start != 34816 || root.Size != 131072-34816-2048 || root.Type != gpt.Types["linux"] || root.Name != "root" {
+		t.Errorf("root partition: %+v", root)
+	}
+
+	var js bytes.Buffer
+	if err := run(nil, &js, io.Discard, []string{"gpt", "-J", img}); err != nil {
+		t.Fatal(err)
+	}
+	var before jsonLayout
+	if err := json.Unmarshal(js.Bytes(), &before); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring the JSON dump onto a fresh disk recreates the layout.
+	fresh := filepath.Join(t.TempDir(), "fresh")
+	if err := os.WriteFile(fresh, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(fresh, 64<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(bytes.NewReader(js.Bytes()), io.Discard, io.Discard, []string{"gpt", "-c", fresh}); err != nil {
+		t.Fatal(err)
+	}
+	var after bytes.Buffer
+	if err := run(nil, &after, io.Discard, []string{"gpt", "-J", fresh}); err != nil {
+		t.Fatal(err)
+	}
+	var restored jsonLayout
+	if err := json.Unmarshal(after.Bytes(), &restored); err != nil {
+		t.Fatal(err)
+	}
+	restored.PartitionTable.Device = img
+	for i := range restored.PartitionTable.Partitions {
+		restored.PartitionTable.Partitions[i].Node = before.PartitionTable.Partitions[i].Node
+	}
+	if diff := cmp.Diff(before, restored); diff != "" {
+		t.Errorf("restored layout (-want +got):\n%s", diff)
+	}
+}
+
+func TestBackupRestoreMBR(t *testing.T) {
+	dir := t.TempDir()
+	disk := func(name string, size int64) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(p, size); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	img := disk("disk", 32<<20)
+	script := "size=8MiB, type=U, name=\"EFI\"\ntype=L, name=\"root\"\n"
+	if err := run(strings.NewReader(script), io.Discard, io.Discard, []string{"gpt", "-c", img}); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := os.Open(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	want, err := gpt.New(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		backup string
+		guids  string
+	}{
+		{backup: "table.bin"},
+		{backup: "table.json"},
+		{backup: "table.bin", guids: "all"},
+	} {
+		b := filepath.Join(dir, tt.backup)
+		if err := run(nil, io.Discard, io.Discard, []string{"gpt", "-b", b, img}); err != nil {
+			t.Fatalf("%s: %v", tt.backup, err)
+		}
+		args := []string{"gpt", "-r", b}
+		if tt.guids != "" {
+			args = append(args, "-g", tt.guids)
+		}
+		larger := disk("larger", 64<<20)
+		if err := run(nil, io.Discard, io.Discard, append(args, larger)); err != nil {
+			t.Fatalf("%s: restoring: %v", tt.backup, err)
+		}
+		f, err := os.Open(larger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := gpt.New(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: reading the restored table: %v", tt.backup, err)
+		}
+		if got.Backup.CurrentLBA != 64<<20/gpt.BlockSize-1 {
+			t.Errorf("%s: backup GPT at %d, not at the end of the disk", tt.backup, got.Backup.CurrentLBA)
+		}
+		if sameGUID := got.Primary.DiskGUID == want.Primary.DiskGUID; sameGUID != (tt.guids == "") {
+			t.Errorf("%s: disk GUID %v, original %v, -g %q", tt.backup, got.Primary.DiskGUID.String(), want.Primary.DiskGUID.String(), tt.guids)
+		}
+		for i := range got.Primary.Parts {
+			g, w := got.Primary.Parts[i], want.Primary.Parts[i]
+			if g.FirstLBA != w.FirstLBA || g.LastLBA != w.LastLBA || g.PartGUID != w.PartGUID || g.Name != w.Name {
+				t.Errorf("%s: partition %d is %+v, want %+v", tt.backup, i+1, g, w)
+			}
+		}
+	}
+
+	smaller := disk("smaller", 16<<20)
+	if err := run(nil, io.Discard, io.Discard, []string{"gpt", "-r", filepath.Join(dir, "table.bin"), smaller}); !errors.Is(err, gpt.ErrLayout) {
+		t.Errorf("restoring to a smaller disk = %v, want %v", err, gpt.ErrLayout)
+	}
+	if err := run(nil, io.Discard, io.Discard, []string{"gpt", "-r", filepath.Join(dir, "table.bin"), "-g", "some", smaller}); !errors.Is(err, errGUIDs) {
+		t.Errorf("-g some = %v, want %v", err, errGUIDs)
+	}
+
+	for _, tt := range []struct {
+		mbr  string
+		want [2]byte // type and status of the second MBR entry
+		err  error
+	}{
+		{mbr: "1*,2", want: [2]byte{0xef, 0x80}},
+		{mbr: "2", want: [2]byte{0x83, 0}},
+		{mbr: "protective"},
+		{mbr: "1*,2*", err: gpt.ErrMBR},
+		{mbr: "x", err: gpt.ErrMBR},
+		{mbr: "3", err: gpt.ErrMBR},
+	} {
+		if err := run(nil, io.Discard, io.Discard, []string{"gpt", "-m", tt.mbr, img}); !errors.Is(err, tt.err) {
+			t.Errorf("-m %s = %v, want %v", tt.mbr, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		m := make([]byte, gpt.BlockSize)
+		if _, err := orig.ReadAt(m, 0); err != nil {
+			t.Fatal(err)
+		}
+		if m[450] != 0xee || m[510] != 0x55 {
+			t.Errorf("-m %s: no 0xEE entry", tt.mbr)
+		}
+		if got := [2]byte{m[466], m[462]}; got != tt.want {
+			t.Errorf("-m %s: second entry has type and status %x, want %x", tt.mbr, got, tt.want)
+		}
+	}
+}
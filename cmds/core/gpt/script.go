@@ -0,0 +1,210 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/gpt"
+)
+
+var errScript = errors.New("invalid script")
+
+// parseSectors parses a start or size: a number of sectors or, with a
+// K, M, G or T suffix (optionally followed by iB), of bytes. Empty values
+// and "+" mean the default.
+func parseSectors(s string) (uint64, error) {
+	if s == "" || s == "+" {
+		return 0, nil
+	}
+	num := strings.TrimSuffix(s, "iB")
+	shift := 0
+	if i := strings.IndexAny(num, "KMGT"); i >= 0 && i == len(num)-1 {
+		shift = 10 * (1 + strings.IndexByte("KMGT", num[i]))
+		num = num[:i]
+	} else if num != s {
+		return 0, fmt.Errorf("%w: size %q", errScript, s)
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n > 1<<(64-shift)-1 {
+		return 0, fmt.Errorf("%w: size %q", errScript, s)
+	}
+	if shift == 0 {
+		return n, nil
+	}
+	return (n<<shift + gpt.BlockSize - 1) / gpt.BlockSize, nil
+}
+
+// splitFields splits a partition line at commas outside of quotes.
+func splitFields(line string) []string {
+	var fields []string
+	var quoted bool
+	start := 0
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			fields = append(fields, strings.TrimSpace(line[start:i]))
+			start = i + 1
+		}
+	}
+	return append(fields, strings.TrimSpace(line[start:]))
+}
+
+// parseScript reads a partition layout in the script format of sfdisk:
+// header lines like "label-id: GUID" followed by a line per partition,
+// like "/dev/sda1 : start=2048, size=1GiB, type=U, name="EFI"" or, with
+// positional fields, "2048,1GiB,U". It returns the layout and the
+// alignment in sectors given by a "grain" header, or 0.
+func parseScript(r io.Reader) (*gpt.Layout, uint64, error) {
+	l := &gpt.Layout{Label: "gpt", Unit: "sectors"}
+	var align uint64
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if key, val, ok := strings.Cut(line, ":"); ok && isHeaderKey(key) {
+			val = strings.TrimSpace(val)
+			var err error
+			switch key {
+			case "label":
+				if val != "gpt" {
+					err = fmt.Errorf("%w: line %d: only gpt labels are supported", errScript, n)
+				}
+			case "label-id":
+				l.ID = val
+			case "device":
+				l.Device = val
+			case "unit":
+				if val != "sectors" {
+					err = fmt.Errorf("%w: line %d: unit %q", errScript, n, val)
+				}
+			case "first-lba":
+				l.FirstLBA, err = strconv.ParseUint(val, 10, 64)
+			case "last-lba":
+				l.LastLBA, err = strconv.ParseUint(val, 10, 64)
+			case "sector-size":
+				if val != strconv.Itoa(gpt.BlockSize) {
+					err = fmt.Errorf("%w: line %d: sector size %q", errScript, n, val)
+				}
+			case "grain":
+				// Grains are in bytes unless they have a suffix.
+				if strings.ContainsAny(val, "KMGT") {
+					align, err = parseSectors(val)
+				} else {
+					align, err = strconv.ParseUint(val, 10, 64)
+					align /= gpt.BlockSize
+				}
+			case "table-length":
+				if val != strconv.Itoa(gpt.MaxNPart) {
+					err = fmt.Errorf("%w: line %d: table length %q", errScript, n, val)
+				}
+			default:
+				err = fmt.Errorf("%w: line %d: unknown header %q", errScript, n, key)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		p, err := parsePart(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", n, err)
+		}
+		l.Partitions = append(l.Partitions, p)
+	}
+	return l, align, s.Err()
+}
+
+// isHeaderKey reports whether key is the key of a header line. Partition
+// lines have no colon but the one after the device.
+func isHeaderKey(key string) bool {
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && c != '-' {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// positional are the fields of partition lines without field names.
+var positional = []string{"start", "size", "type"}
+
+func parsePart(line string) (gpt.LayoutPart, error) {
+	var p gpt.LayoutPart
+	if node, rest, ok := strings.Cut(line, " : "); ok {
+		p.Node, line = strings.TrimSpace(node), rest
+	}
+	var err error
+	for i, f := range splitFields(line) {
+		key, val, named := strings.Cut(f, "=")
+		if !named {
+			if i >= len(positional) {
+				// The bootable flag of MBR partitions.
+				continue
+			}
+			key, val = positional[i], f
+		}
+		key, val = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(val), "\"")
+		switch key {
+		case "start":
+			p.Start, err = parseSectors(val)
+		case "size":
+			p.Size, err = parseSectors(val)
+		case "type", "Id":
+			p.Type = val
+		case "uuid":
+			p.UUID = val
+		case "name":
+			p.Name = val
+		case "attrs":
+			p.Attrs = val
+		default:
+			err = fmt.Errorf("%w: unknown field %q", errScript, key)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// writeScript writes l in the dump format of sfdisk.
+func writeScript(w io.Writer, l *gpt.Layout) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "label: %s\n", l.Label)
+	fmt.Fprintf(b, "label-id: %s\n", l.ID)
+	if l.Device != "" {
+		fmt.Fprintf(b, "device: %s\n", l.Device)
+	}
+	fmt.Fprintf(b, "unit: %s\n", l.Unit)
+	fmt.Fprintf(b, "first-lba: %d\n", l.FirstLBA)
+	fmt.Fprintf(b, "last-lba: %d\n", l.LastLBA)
+	fmt.Fprintf(b, "sector-size: %d\n\n", l.SectorSize)
+	for _, p := range l.Partitions {
+		if p.Node != "" {
+			fmt.Fprintf(b, "%s : ", p.Node)
+		}
+		fmt.Fprintf(b, "start=%12d, size=%12d, type=%s, uuid=%s", p.Start, p.Size, p.Type, p.UUID)
+		if p.Name != "" {
+			fmt.Fprintf(b, ", name=%q", p.Name)
+		}
+		if p.Attrs != "" {
+			fmt.Fprintf(b, ", attrs=%q", p.Attrs)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
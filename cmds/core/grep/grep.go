@@ -6,20 +6,35 @@
 //
 // Synopsis:
 //
-//	grep [-clFivnhqre] [FILE]...
+//	grep [-clEFivnhoqre] [-A NUM] [-B NUM] [-C NUM] [PATTERN] [FILE]...
+//
+// Description:
+//
+//	Patterns use the RE2 syntax of Go's regexp package, which is a superset
+//	of POSIX extended regular expressions; -E is accepted for
+//	compatibility. --include, --exclude and --exclude-dir match the base
+//	names of files and directories against shell globs and may be repeated.
 //
 // Options:
 //
 //  -c, --count                Just show counts
 //  -l, --files-with-matches   list only files
+//  -E, --extended-regexp      Match using extended regular expressions
 //  -F, --fixed-strings        Match using fixed strings
 //  -i, --ignore-case          case-insensitive matching
 //  -v, --invert-match         Print only non-matching lines
 //  -n, --line-number          Show line numbers
 //  -h, --no-filename          Suppress file name prefixes on output
+//  -o, --only-matching        Print only the matching parts of lines
 //  -q, --quiet                Don't print matches; exit on first match
 //  -r, --recursive            recursive
-//  -e, --regexp string        Pattern to match
+//  -e, --regexp PATTERN       Pattern to match; may be repeated to match any
+//  -A, --after-context NUM    Print NUM lines of context after matches
+//  -B, --before-context NUM   Print NUM lines of context before matches
+//  -C, --context NUM          Print NUM lines of context around matches
+//      --include GLOB         Search only files matching GLOB
+//      --exclude GLOB         Skip files matching GLOB
+//      --exclude-dir GLOB     Skip directories matching GLOB
 
 package main
 
@@ -29,6 +44,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -42,9 +58,11 @@ import (
 var errQuiet = fmt.Errorf("not found")
 
 type params struct {
-	expr string
+	exprs []string
 	headers, invert, recursive, caseInsensitive, fixed,
-	noShowMatch, quiet, count, number bool
+	noShowMatch, quiet, count, number, onlyMatching, extended bool
+	after, before, context       int
+	include, exclude, excludeDir []string
 }
 
 type grepCommand struct {
@@ -57,8 +75,8 @@ func run(stdin io.ReadCloser, stdout io.Writer, stderr io.Writer, args []string)
 	var c cmd
 
 	f := flag.NewFlagSet(args[0], flag.ExitOnError)
-	f.StringVar(&c.params.expr, "regexp", "", "Pattern to match")
-	f.StringVar(&c.expr, "e", "", "Pattern to match (shorthand)")
+	f.Var((*unixflag.StringArray)(&c.params.exprs), "regexp", "Pattern to match; may be repeated to match any")
+	f.Var((*unixflag.StringArray)(&c.params.exprs), "e", "Pattern to match; may be repeated to match any (shorthand)")
 
 	f.BoolVar(&c.params.headers, "no-filename", false, "Suppress file name prefixes on output")
 	f.BoolVar(&c.params.headers, "h", false, "Suppress file name prefixes on output (shorthand)")
@@ -90,12 +108,32 @@ func run(stdin io.ReadCloser, stdout io.Writer, stderr io.Writer, args []string)
 	f.BoolVar(&c.params.quiet, "silent", false, "Don't print matches; exit on first match")
 	f.BoolVar(&c.params.quiet, "s", false, "Don't print matches; exit on first match (shorthand)")
 
+	f.BoolVar(&c.params.onlyMatching, "only-matching", false, "Print only the matching parts of lines")
+	f.BoolVar(&c.params.onlyMatching, "o", false, "Print only the matching parts of lines (shorthand)")
+
+	f.BoolVar(&c.params.extended, "extended-regexp", false, "Match using extended regular expressions")
+	f.BoolVar(&c.params.extended, "E", false, "Match using extended regular expressions (shorthand)")
+
+	f.IntVar(&c.params.after, "after-context", 0, "Print NUM lines of context after matches")
+	f.IntVar(&c.params.after, "A", 0, "Print NUM lines of context after matches (shorthand)")
+
+	f.IntVar(&c.params.before, "before-context", 0, "Print NUM lines of context before matches")
+	f.IntVar(&c.params.before, "B", 0, "Print NUM lines of context before matches (shorthand)")
+
+	f.IntVar(&c.params.context, "context", 0, "Print NUM lines of context around matches")
+	f.IntVar(&c.params.context, "C", 0, "Print NUM lines of context around matches (shorthand)")
+
+	f.Var((*unixflag.StringArray)(&c.params.include), "include", "Search only files matching GLOB")
+	f.Var((*unixflag.StringArray)(&c.params.exclude), "exclude", "Skip files matching GLOB")
+	f.Var((*unixflag.StringArray)(&c.params.excludeDir), "exclude-dir", "Skip directories matching GLOB")
+
 	f.Usage = func() {
-		fmt.Fprint(f.Output(), "Usage: grep [-clFivnhqre] [FILE]...\n\n")
+		fmt.Fprint(f.Output(), "Usage: grep [-clEFivnhoqre] [-A NUM] [-B NUM] [-C NUM] [PATTERN] [FILE]...\n\n")
 		f.PrintDefaults()
 	}
 
-	f.Parse(unixflag.ArgsToGoArgs(args[1:]))
+	// Switches given a number, like -A1, are split before parsing.
+	f.Parse(unixflag.FlagSetArgsToGoArgs(f, args[1:]))
 
 	c.args = f.Args()
 	c.stdin = stdin
@@ -123,6 +161,14 @@ type cmd struct {
 	params
 	matchCount int
 	showName   bool
+	// grouped is set once a group of lines with context has been printed.
+	grouped bool
+}
+
+// contextLine is a line kept for printing as context before a match.
+type contextLine struct {
+	text string
+	num  int
 }
 
 // grep reads data from the os.File embedded in grepCommand.
@@ -132,15 +178,23 @@ type cmd struct {
 func (c *cmd) grep(f *grepCommand, re *regexp.Regexp) (ok bool) {
 	r := bufio.NewScanner(f.rc)
 	defer f.rc.Close()
-	var lineNum int
+	showContext := (c.before > 0 || c.after > 0) && !c.count && !c.noShowMatch
+	var (
+		lineNum int
+		// before holds up to c.before lines preceding the next match.
+		before []contextLine
+		// after is the number of lines still to print after a match.
+		after int
+		// last is the number of the last line printed, or 0.
+		last int
+	)
 	for r.Scan() {
 		line := r.Text()
+		lineNum++
 		var m bool
 		switch {
-		case c.fixed && c.caseInsensitive:
-			m = strings.Contains(strings.ToLower(line), strings.ToLower(c.expr))
-		case c.fixed && !c.caseInsensitive:
-			m = strings.Contains(line, c.expr)
+		case c.fixed:
+			m = c.containsAny(line)
 		default:
 			m = re.MatchString(line)
 		}
@@ -149,88 +203,199 @@ func (c *cmd) grep(f *grepCommand, re *regexp.Regexp) (ok bool) {
 			if c.quiet {
 				return false
 			}
-			c.printMatch(f, line, lineNum+1, m)
+			if showContext {
+				first := lineNum - len(before)
+				if c.grouped && (last == 0 || first > last+1) {
+					c.stdout.WriteString("--\n")
+				}
+				for _, b := range before {
+					c.printLine(f, b.text, b.num, '-')
+				}
+				before = before[:0]
+				c.grouped = true
+				last, after = lineNum, c.after
+			}
+			c.printMatch(f, line, lineNum, m, re)
 			if c.noShowMatch {
 				break
 			}
+			continue
+		}
+		switch {
+		case !showContext:
+		case after > 0:
+			c.printLine(f, line, lineNum, '-')
+			last = lineNum
+			after--
+		case c.before > 0:
+			before = append(before, contextLine{line, lineNum})
+			if len(before) > c.before {
+				before = before[1:]
+			}
 		}
-		lineNum++
 	}
 	c.stdout.Flush()
 	return true
 }
 
-func (c *cmd) printMatch(cmd *grepCommand, line string, lineNum int, match bool) {
+func (c *cmd) printMatch(cmd *grepCommand, line string, lineNum int, match bool, re *regexp.Regexp) {
 	if match == !c.invert {
 		c.matchCount++
 	}
 	if c.count {
 		return
 	}
-	// at this point, we have committed to writing a line
-	defer func() {
+	// if dont show match, then write the name and a newline, we are done
+	if c.noShowMatch {
+		if c.showName {
+			c.stdout.WriteString(cmd.name)
+		}
 		c.stdout.WriteByte('\n')
-	}()
-	// if showName, write name to stdout
+		return
+	}
+	if !c.onlyMatching {
+		c.printLine(cmd, line, lineNum, ':')
+		return
+	}
+	// Inverted matches have no matching parts to print.
+	if c.invert {
+		return
+	}
+	for _, loc := range re.FindAllStringIndex(line, -1) {
+		if loc[0] != loc[1] {
+			c.printLine(cmd, line[loc[0]:loc[1]], lineNum, ':')
+		}
+	}
+}
+
+// printLine writes text, prefixed by the file name and line number if
+// asked for. sep separates the prefixes: ':' for matches and '-' for
+// context lines.
+func (c *cmd) printLine(cmd *grepCommand, text string, lineNum int, sep byte) {
 	if c.showName {
 		c.stdout.WriteString(cmd.name)
+		c.stdout.WriteByte(sep)
 	}
-	// if dont show match, then newline and return, we are done
-	if c.noShowMatch {
-		return
+	if c.number {
+		c.stdout.Write(strconv.AppendUint(nil, uint64(lineNum), 10))
+		c.stdout.WriteByte(sep)
 	}
-	if match == !c.invert {
-		// if showName, need a :
-		if c.showName {
-			c.stdout.WriteByte(':')
+	c.stdout.WriteString(text)
+	c.stdout.WriteByte('\n')
+}
+
+// containsAny reports whether line contains any of the fixed strings.
+func (c *cmd) containsAny(line string) bool {
+	if c.caseInsensitive {
+		line = strings.ToLower(line)
+	}
+	for _, e := range c.exprs {
+		if c.caseInsensitive {
+			e = strings.ToLower(e)
 		}
-		// if showing line number, print the line number then a :
-		if c.number {
-			c.stdout.Write(strconv.AppendUint(nil, uint64(lineNum), 10))
-			c.stdout.WriteByte(':')
+		if strings.Contains(line, e) {
+			return true
 		}
-		// now write the line to stdout
-		c.stdout.WriteString(line)
 	}
+	return false
+}
+
+// alternate returns a regular expression matching any of the patterns,
+// which are quoted first if fixed is set.
+func alternate(patterns []string, fixed bool) string {
+	alts := make([]string, len(patterns))
+	for i, p := range patterns {
+		if fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		alts[i] = p
+	}
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	for i, a := range alts {
+		alts[i] = "(?:" + a + ")"
+	}
+	return strings.Join(alts, "|")
+}
+
+// skip reports whether the file or directory name is excluded by the
+// --include, --exclude and --exclude-dir globs.
+func (c *cmd) skip(name string, dir bool) bool {
+	base := filepath.Base(name)
+	matchAny := func(globs []string) bool {
+		for _, g := range globs {
+			if ok, _ := filepath.Match(g, base); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if dir {
+		return matchAny(c.excludeDir)
+	}
+	if len(c.include) > 0 && !matchAny(c.include) {
+		return true
+	}
+	return matchAny(c.exclude)
 }
 
 func (c *cmd) run() error {
 	defer c.stdout.Flush()
-	// parse the expression into valid regex
-	if c.expr != "" {
-		c.args = append([]string{c.expr}, c.args...)
+	for _, n := range []int{c.after, c.before, c.context} {
+		if n < 0 {
+			return fmt.Errorf("%d: invalid context length argument", n)
+		}
+	}
+	// Without -e, the first argument is the pattern.
+	if len(c.exprs) == 0 && len(c.args) > 0 {
+		c.exprs, c.args = c.args[:1], c.args[1:]
 	}
+	// parse the expressions into valid regex; for fixed strings it is
+	// only used to find the matching parts for -o.
 	r := ".*"
-	if len(c.args) > 0 {
-		r = c.args[0]
+	if len(c.exprs) > 0 {
+		r = alternate(c.exprs, c.fixed)
 	}
-	if c.caseInsensitive && !bytes.HasPrefix([]byte(r), []byte("(?i)")) && !c.fixed {
+	if c.caseInsensitive && !bytes.HasPrefix([]byte(r), []byte("(?i)")) {
 		r = "(?i)" + r
 	}
-	var re *regexp.Regexp
-	if !c.fixed {
-		re = regexp.MustCompile(r)
-	} else if c.expr == "" {
-		c.expr = c.args[0]
+	re := regexp.MustCompile(r)
+	if c.context > 0 {
+		if c.after == 0 {
+			c.after = c.context
+		}
+		if c.before == 0 {
+			c.before = c.context
+		}
 	}
 
-	// if len(c.args) < 2, then we read from stdin
-	if len(c.args) < 2 {
+	// if there are no files, then we read from stdin
+	if len(c.args) == 0 {
 		if !c.grep(&grepCommand{c.stdin, "<stdin>"}, re) {
 			return nil
 		}
 	} else {
-		c.showName = (len(c.args[1:]) > 1 || c.recursive || c.noShowMatch) && !c.headers
+		c.showName = (len(c.args) > 1 || c.recursive || c.noShowMatch) && !c.headers
 		var ok bool
-		for _, v := range c.args[1:] {
-			err := filepath.Walk(v, func(name string, fi os.FileInfo, err error) error {
+		for _, v := range c.args {
+			err := filepath.WalkDir(v, func(name string, d fs.DirEntry, err error) error {
 				if err != nil {
 					fmt.Fprintf(c.stderr, "grep: %v: %v\n", name, err)
 					return nil
 				}
-				if fi.IsDir() && !c.recursive {
-					fmt.Fprintf(c.stderr, "grep: %v: Is a directory\n", name)
-					return filepath.SkipDir
+				if d.IsDir() {
+					if !c.recursive {
+						fmt.Fprintf(c.stderr, "grep: %v: Is a directory\n", name)
+						return filepath.SkipDir
+					}
+					if name != v && c.skip(name, true) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if c.skip(name, false) {
+					return nil
 				}
 				fp, err := os.Open(name)
 				if err != nil {
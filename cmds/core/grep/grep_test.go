@@ -72,7 +72,7 @@ func TestStdinGrep(t *testing.T) {
 			input:  "hix\n",
 			output: "hix\n",
 			err:    nil,
-			p:      params{expr: "hix"},
+			p:      params{exprs: []string{"hix"}},
 		},
 		{
 			input:  "hix\n",
@@ -143,7 +143,7 @@ func TestStdinGrep(t *testing.T) {
 			input:  "a\nb\nc\n",
 			output: "b\n",
 			err:    nil,
-			p:      params{fixed: true, expr: "b"},
+			p:      params{fixed: true, exprs: []string{"b"}},
 		},
 	}
 
@@ -293,6 +293,176 @@ func TestFilesGrep(t *testing.T) {
 	}
 }
 
+func TestContextGrep(t *testing.T) {
+	const input = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	for _, tt := range []struct {
+		name   string
+		p      params
+		args   []string
+		output string
+	}{
+		{
+			name:   "after",
+			p:      params{after: 1},
+			args:   []string{"two|six"},
+			output: "two\nthree\n--\nsix\nseven\n",
+		},
+		{
+			name:   "before with line numbers",
+			p:      params{before: 2, number: true},
+			args:   []string{"four"},
+			output: "2-two\n3-three\n4:four\n",
+		},
+		{
+			name:   "overlapping context is merged",
+			p:      params{context: 1},
+			args:   []string{"three|five"},
+			output: "two\nthree\nfour\nfive\nsix\n",
+		},
+		{
+			name:   "only matching",
+			p:      params{onlyMatching: true, number: true},
+			args:   []string{"e[a-z]"},
+			output: "3:ee\n7:ev\n7:en\n8:ei\n",
+		},
+		{
+			name:   "only matching fixed strings",
+			p:      params{onlyMatching: true, fixed: true, caseInsensitive: true},
+			args:   []string{"E"},
+			output: "e\ne\ne\ne\ne\ne\ne\n",
+		},
+		{
+			name:   "extended",
+			p:      params{extended: true, count: true},
+			args:   []string{"^(t|f)[a-z]+$"},
+			output: "4\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			cmd := cmd{
+				stdin:  io.NopCloser(strings.NewReader(input)),
+				stdout: bufio.NewWriter(&stdout),
+				params: tt.p,
+				args:   tt.args,
+			}
+			if err := cmd.run(); err != nil {
+				t.Fatalf("got err %v, want nil", err)
+			}
+			if got := stdout.String(); got != tt.output {
+				t.Errorf("got out %q, want %q", got, tt.output)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	const input = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	for _, tt := range []struct {
+		args   []string
+		output string
+		err    string
+	}{
+		{
+			args:   []string{"grep", "-A1", "two"},
+			output: "two\nthree\n",
+		},
+		{
+			args:   []string{"grep", "-nB1", "four"},
+			output: "3-three\n4:four\n",
+		},
+		{
+			args:   []string{"grep", "-C2", "-n", "five"},
+			output: "3-three\n4-four\n5:five\n6-six\n7-seven\n",
+		},
+		{
+			args:   []string{"grep", "-e", "two", "-e", "six"},
+			output: "two\nsix\n",
+		},
+		{
+			args:   []string{"grep", "-F", "-i", "-e", "TWO", "--regexp=e.g"},
+			output: "two\n",
+		},
+		{
+			args:   []string{"grep", "-o", "-e", "^t", "-e", "e$"},
+			output: "e\nt\nt\ne\ne\n",
+		},
+		{
+			args: []string{"grep", "-A", "-1", "two"},
+			err:  "-1: invalid context length argument",
+		},
+	} {
+		t.Run(strings.Join(tt.args[1:], " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(io.NopCloser(strings.NewReader(input)), &stdout, &stderr, tt.args)
+			if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+				t.Fatalf("got err %v, want %q", err, tt.err)
+			}
+			if got := stdout.String(); got != tt.output {
+				t.Errorf("got out %q, want %q", got, tt.output)
+			}
+		})
+	}
+}
+
+func TestRecursiveGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.log":          "error: disk\n",
+		"b.txt":          "error: net\n",
+		"sub/c.log":      "error: usb\n",
+		"skip/d.log":     "error: pci\n",
+		"sub/deep/e.log": "fine\n",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		args   []string
+		output []string
+	}{
+		{
+			name:   "include",
+			args:   []string{"grep", "-r", "--include", "*.log", "error", dir},
+			output: []string{"a.log:error: disk", "skip/d.log:error: pci", "sub/c.log:error: usb"},
+		},
+		{
+			name:   "exclude and exclude-dir",
+			args:   []string{"grep", "-r", "--exclude=*.txt", "--exclude-dir=skip", "error", dir},
+			output: []string{"a.log:error: disk", "sub/c.log:error: usb"},
+		},
+		{
+			name:   "only matching",
+			args:   []string{"grep", "-r", "-o", "--include=*.txt", "n[a-z]+", dir},
+			output: []string{"b.txt:net"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(nil, &stdout, &stderr, tt.args); err != nil {
+				t.Fatalf("got err %v, want nil", err)
+			}
+			var want string
+			for _, l := range tt.output {
+				want += filepath.Join(dir, l) + "\n"
+			}
+			if got := stdout.String(); got != want {
+				t.Errorf("got out %q, want %q", got, want)
+			}
+			if stderr.Len() != 0 {
+				t.Errorf("got stderr %q, want none", stderr.String())
+			}
+		})
+	}
+}
+
 func TestDefaultParams(t *testing.T) {
 	var stdout bytes.Buffer
 	rc := io.NopCloser(strings.NewReader("hix\n"))
@@ -0,0 +1,129 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// minCalibration is the shortest time between two calibrations over
+	// which the drift is worked out; over less, the error of reading and
+	// setting the RTC to the second would swamp it.
+	minCalibration = 4 * time.Hour
+
+	// maxDrift is the most seconds per day an RTC is believed to drift.
+	// More means the RTC or the system clock was changed by something
+	// else, and the drift is started again.
+	maxDrift = 600
+)
+
+// adjtime is the state kept in /etc/adjtime, in the format of util-linux:
+//
+//	DRIFT LAST_ADJUST 0.0
+//	LAST_CALIBRATION
+//	UTC|LOCAL
+//
+// The drift is the seconds per day the RTC gains, and the times are Unix
+// seconds, 0 for never.
+type adjtime struct {
+	drift      float64
+	lastAdjust int64
+	lastCalib  int64
+	local      bool
+}
+
+// readAdjtime reads an adjtime file. A missing one is a UTC RTC that has
+// never been calibrated.
+func readAdjtime(path string) (*adjtime, error) {
+	a := &adjtime{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	bad := func(err error) error {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(lines) > 0 {
+		f := strings.Fields(lines[0])
+		if len(f) > 0 {
+			if a.drift, err = strconv.ParseFloat(f[0], 64); err != nil {
+				return nil, bad(err)
+			}
+		}
+		if len(f) > 1 {
+			if a.lastAdjust, err = strconv.ParseInt(f[1], 10, 64); err != nil {
+				return nil, bad(err)
+			}
+		}
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		if a.lastCalib, err = strconv.ParseInt(strings.TrimSpace(lines[1]), 10, 64); err != nil {
+			return nil, bad(err)
+		}
+	}
+	if len(lines) > 2 {
+		switch mode := strings.TrimSpace(lines[2]); mode {
+		case "UTC", "":
+		case "LOCAL":
+			a.local = true
+		default:
+			return nil, bad(fmt.Errorf("unknown RTC mode %q", mode))
+		}
+	}
+	return a, nil
+}
+
+// write writes the adjtime file.
+func (a *adjtime) write(path string) error {
+	mode := "UTC"
+	if a.local {
+		mode = "LOCAL"
+	}
+	s := fmt.Sprintf("%f %d 0.000000\n%d\n%s\n", a.drift, a.lastAdjust, a.lastCalib, mode)
+	return os.WriteFile(path, []byte(s), 0o644)
+}
+
+// correct returns the time the RTC should show, given the drift since it
+// was last set or adjusted.
+func (a *adjtime) correct(t time.Time) time.Time {
+	if a.lastAdjust == 0 || a.drift == 0 {
+		return t
+	}
+	days := t.Sub(time.Unix(a.lastAdjust, 0)).Hours() / 24
+	return t.Add(-time.Duration(a.drift * days * float64(time.Second)))
+}
+
+// calibrate notes that the RTC is about to be set to the system time now,
+// when it read rtc after correction. What it gained since the last
+// calibration is added to the drift.
+func (a *adjtime) calibrate(rtc, now time.Time) {
+	if a.lastCalib != 0 {
+		if elapsed := now.Sub(time.Unix(a.lastCalib, 0)); elapsed >= minCalibration {
+			a.drift += rtc.Sub(now).Seconds() / (elapsed.Hours() / 24)
+			if math.Abs(a.drift) > maxDrift {
+				a.drift = 0
+			}
+		}
+	}
+	a.lastCalib = now.Unix()
+	a.lastAdjust = now.Unix()
+}
@@ -2,54 +2,266 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// hwclock reads or changes the hardware clock (RTC) in UTC format.
+// hwclock reads or changes the hardware clock (RTC).
 //
 // Synopsis:
 //
-//	hwclock [-w]
+//	hwclock [-f DEVICE] [-u|-l] [-r|-w|-s|-a|-set -date DATE]
 //
 // Description:
 //
-//	It prints the current hwclock time in UTC if called without any flags.
-//	It sets the hwclock to the system clock in UTC if called with -w.
+//	It prints the time of the hwclock if called without any of -w, -s,
+//	-a and -set.
+//
+//	The RTC may keep UTC or local time; -u and -l say which, and are
+//	remembered in /etc/adjtime. Without either, the mode in /etc/adjtime
+//	is used, or UTC.
+//
+//	Each -w, as after the system clock was set by ntpdate, also works out
+//	how fast the RTC drifts, from how far off it is. The drift is kept in
+//	/etc/adjtime, and the time read is corrected for it. -a sets the RTC
+//	to the corrected time.
 //
 // Options:
 //
-//	-w: set hwclock to system clock in UTC
+//	-r, -show:      print the time of the hwclock
+//	-w, -systohc:   set hwclock to the system clock, then print it
+//	-s, -hctosys:   set the system clock to the hwclock
+//	-a, -adjust:    correct the hwclock for its drift
+//	-set -date:     set the hwclock to DATE, such as "2006-01-02 15:04:05"
+//	-u, -utc:       the hwclock keeps UTC
+//	-l, -localtime: the hwclock keeps local time
+//	-f, -rtc:       the RTC device, rather than the first found
+//	-adjfile:       the adjtime file, /etc/adjtime
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/u-root/u-root/pkg/rtc"
 )
 
-var write = flag.Bool("w", false, "Set hwclock from system clock in UTC")
+var errUsage = errors.New("usage: hwclock [-f DEVICE] [-u|-l] [-r|-w|-s|-a|-set -date DATE]")
 
-func main() {
-	flag.Parse()
+// clock is an RTC, which has no time zone: it reads as UTC, and is set
+// to the fields of the time as given.
+type clock interface {
+	Read() (time.Time, error)
+	Set(time.Time) error
+}
 
-	r, err := rtc.OpenRTC()
-	if err != nil {
-		log.Fatal(err)
+type cmd struct {
+	rtc       clock
+	stdout    io.Writer
+	loc       *time.Location
+	now       func() time.Time
+	setSystem func(time.Time) error
+
+	dev     string
+	adjfile string
+	date    string
+	show    bool
+	systohc bool
+	hctosys bool
+	adjust  bool
+	set     bool
+	utc     bool
+	local   bool
+}
+
+// layouts are the dates -date takes, besides @SECONDS.
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func command(stdout io.Writer, args []string) (*cmd, error) {
+	c := &cmd{
+		stdout:    stdout,
+		loc:       time.Local,
+		now:       time.Now,
+		setSystem: setSystem,
+	}
+	f := flag.NewFlagSet("hwclock", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	for _, b := range []struct {
+		p           *bool
+		short, long string
+		usage       string
+	}{
+		{&c.show, "r", "show", "Print the hwclock time"},
+		{&c.systohc, "w", "systohc", "Set hwclock from system clock"},
+		{&c.hctosys, "s", "hctosys", "Set system clock from hwclock"},
+		{&c.adjust, "a", "adjust", "Correct hwclock for its drift"},
+		{&c.utc, "u", "utc", "hwclock keeps UTC"},
+		{&c.local, "l", "localtime", "hwclock keeps local time"},
+	} {
+		f.BoolVar(b.p, b.short, false, b.usage)
+		f.BoolVar(b.p, b.long, false, b.usage)
+	}
+	f.BoolVar(&c.set, "set", false, "Set hwclock to -date")
+	f.StringVar(&c.date, "date", "", "Date for -set")
+	f.StringVar(&c.dev, "f", "", "RTC device")
+	f.StringVar(&c.dev, "rtc", "", "RTC device")
+	f.StringVar(&c.adjfile, "adjfile", "/etc/adjtime", "Adjtime file")
+	if err := f.Parse(args); err != nil || f.NArg() > 0 {
+		return nil, errUsage
 	}
-	defer r.Close()
 
-	if *write {
-		tu := time.Now().UTC()
-		if err := r.Set(tu); err != nil {
-			log.Fatal(err)
+	actions := 0
+	for _, b := range []bool{c.systohc, c.hctosys, c.adjust, c.set} {
+		if b {
+			actions++
 		}
 	}
+	switch {
+	case actions > 1 || (actions > 0 && c.show):
+		return nil, fmt.Errorf("%w: only one of -r, -w, -s, -a and -set", errUsage)
+	case c.utc && c.local:
+		return nil, fmt.Errorf("%w: only one of -u and -l", errUsage)
+	case c.set != (c.date != ""):
+		return nil, fmt.Errorf("%w: -set needs -date, and -date -set", errUsage)
+	}
+	return c, nil
+}
+
+// parseDate parses the date for -set, in local time unless it has a zone.
+func (c *cmd) parseDate() (time.Time, error) {
+	if s, ok := strings.CutPrefix(c.date, "@"); ok {
+		sec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", c.date)
+		}
+		return time.Unix(sec, 0), nil
+	}
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, c.date, c.loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", c.date)
+}
 
-	t, err := r.Read()
+// read reads the RTC, which keeps local time in LOCAL mode.
+func (c *cmd) read(a *adjtime) (time.Time, error) {
+	t, err := c.rtc.Read()
+	if err != nil || !a.local {
+		return t, err
+	}
+	y, m, d := t.Date()
+	h, min, s := t.Clock()
+	return time.Date(y, m, d, h, min, s, 0, c.loc), nil
+}
+
+// write sets the RTC, to the nearest second.
+func (c *cmd) write(a *adjtime, t time.Time) error {
+	t = t.Round(time.Second)
+	if a.local {
+		return c.rtc.Set(t.In(c.loc))
+	}
+	return c.rtc.Set(t.UTC())
+}
+
+func (c *cmd) run() error {
+	a, err := readAdjtime(c.adjfile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	switch {
+	case c.utc:
+		a.local = false
+	case c.local:
+		a.local = true
+	}
+
+	switch {
+	case c.systohc:
+		now := c.now()
+		t, err := c.read(a)
+		if err != nil {
+			return err
+		}
+		a.calibrate(a.correct(t), now)
+		if err := c.write(a, now); err != nil {
+			return err
+		}
+		if err := a.write(c.adjfile); err != nil {
+			return err
+		}
+
+	case c.hctosys:
+		t, err := c.read(a)
+		if err != nil {
+			return err
+		}
+		return c.setSystem(a.correct(t))
 
+	case c.adjust:
+		t, err := c.read(a)
+		if err != nil {
+			return err
+		}
+		ct := a.correct(t)
+		if d := ct.Sub(t); d >= time.Second || d <= -time.Second {
+			if err := c.write(a, ct); err != nil {
+				return err
+			}
+			a.lastAdjust = ct.Unix()
+		}
+		return a.write(c.adjfile)
+
+	case c.set:
+		t, err := c.parseDate()
+		if err != nil {
+			return err
+		}
+		if err := c.write(a, t); err != nil {
+			return err
+		}
+		// The RTC no longer follows the system clock, so there is
+		// nothing to calibrate against until the next -w.
+		a.lastAdjust, a.lastCalib = t.Unix(), 0
+		return a.write(c.adjfile)
+	}
+
+	t, err := c.read(a)
+	if err != nil {
+		return err
+	}
 	// Print local time. Match the format of util-linux' hwclock.
-	fmt.Println(t.Local().Format("Mon 2 Jan 2006 15:04:05 AM MST"))
+	_, err = fmt.Fprintln(c.stdout, a.correct(t).In(c.loc).Format("Mon 2 Jan 2006 03:04:05 PM MST"))
+	return err
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	var r *rtc.RTC
+	if c.dev != "" {
+		r, err = rtc.OpenRTCDevice(c.dev)
+	} else {
+		r, err = rtc.OpenRTC()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	c.rtc = r
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
 }
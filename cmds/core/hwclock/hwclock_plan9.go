@@ -0,0 +1,16 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// setSystem is not supported on Plan 9, where the clock is set by writing
+// to /dev/time.
+func setSystem(t time.Time) error {
+	return errors.New("not supported")
+}
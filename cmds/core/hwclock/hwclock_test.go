@@ -0,0 +1,250 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRTC keeps the fields of a time, like an RTC, as UTC.
+type fakeRTC struct {
+	t time.Time
+}
+
+func (f *fakeRTC) Read() (time.Time, error) {
+	return f.t, nil
+}
+
+func (f *fakeRTC) Set(t time.Time) error {
+	y, m, d := t.Date()
+	h, min, s := t.Clock()
+	f.t = time.Date(y, m, d, h, min, s, 0, time.UTC)
+	return nil
+}
+
+func TestAdjtime(t *testing.T) {
+	dir := t.TempDir()
+	a, err := readAdjtime(filepath.Join(dir, "none"))
+	if err != nil || *a != (adjtime{}) {
+		t.Fatalf("missing file: got %+v, %v, want zero and nil", a, err)
+	}
+
+	p := filepath.Join(dir, "adjtime")
+	want := adjtime{drift: 1.5, lastAdjust: 100, lastCalib: 90, local: true}
+	if err := want.write(p); err != nil {
+		t.Fatal(err)
+	}
+	a, err = readAdjtime(p)
+	if err != nil || *a != want {
+		t.Errorf("got %+v, %v, want %+v, nil", a, err, want)
+	}
+
+	if err := os.WriteFile(p, []byte("0 0 0\n0\nMARS\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAdjtime(p); err == nil {
+		t.Errorf("unknown mode: got nil, want error")
+	}
+}
+
+func TestDrift(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &adjtime{}
+	a.calibrate(start, start)
+	if a.drift != 0 || a.lastCalib != start.Unix() || a.lastAdjust != start.Unix() {
+		t.Fatalf("first calibration: got %+v", a)
+	}
+
+	// Two days later the RTC is 4s fast: 2s a day.
+	now := start.AddDate(0, 0, 2)
+	a.calibrate(a.correct(now.Add(4*time.Second)), now)
+	if a.drift != 2 {
+		t.Errorf("got drift %v, want 2", a.drift)
+	}
+	// Which is taken off what it reads a day later.
+	if got, want := a.correct(now.AddDate(0, 0, 1).Add(2*time.Second)).Round(time.Millisecond), now.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Too soon to say anything.
+	a.calibrate(now.Add(time.Hour+time.Minute), now.Add(time.Hour))
+	if a.drift != 2 {
+		t.Errorf("got drift %v, want 2", a.drift)
+	}
+
+	// Implausible drift starts again.
+	now = now.AddDate(0, 0, 1)
+	a.calibrate(now.Add(time.Hour), now)
+	if a.drift != 0 {
+		t.Errorf("got drift %v, want 0", a.drift)
+	}
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 3600)
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		rtc     time.Time
+		adjtime string
+		out     string
+		wantRTC time.Time
+		system  time.Time
+		wantAdj string
+		err     error
+	}{
+		{
+			name:    "show",
+			rtc:     now,
+			out:     "Fri 1 Mar 2024 01:00:00 PM CET\n",
+			wantRTC: now,
+		},
+		{
+			name:    "show local",
+			args:    []string{"-l"},
+			rtc:     now,
+			out:     "Fri 1 Mar 2024 12:00:00 PM CET\n",
+			wantRTC: now,
+		},
+		{
+			name:    "show mode from adjtime",
+			rtc:     now,
+			adjtime: "0.0 0 0.0\n0\nLOCAL\n",
+			out:     "Fri 1 Mar 2024 12:00:00 PM CET\n",
+			wantRTC: now,
+		},
+		{
+			name:    "show corrected",
+			rtc:     now.Add(time.Minute),
+			adjtime: "10.0 1708776060 0.0\n1708776060\nUTC\n",
+			out:     "Fri 1 Mar 2024 01:00:00 PM CET\n",
+			wantRTC: now.Add(time.Minute),
+		},
+		{
+			name:    "systohc",
+			args:    []string{"-w"},
+			rtc:     now.Add(-time.Hour),
+			out:     "Fri 1 Mar 2024 01:00:00 PM CET\n",
+			wantRTC: now,
+			wantAdj: "0.000000 1709294400 0.000000\n1709294400\nUTC\n",
+		},
+		{
+			name:    "systohc calibrates",
+			args:    []string{"-systohc"},
+			rtc:     now.Add(5 * time.Second),
+			adjtime: "0.0 1708862400 0.0\n1708862400\nUTC\n",
+			out:     "Fri 1 Mar 2024 01:00:00 PM CET\n",
+			wantRTC: now,
+			wantAdj: "1.000000 1709294400 0.000000\n1709294400\nUTC\n",
+		},
+		{
+			name:    "systohc local",
+			args:    []string{"--localtime", "-w"},
+			rtc:     now,
+			out:     "Fri 1 Mar 2024 01:00:00 PM CET\n",
+			wantRTC: now.Add(time.Hour),
+			wantAdj: "0.000000 1709294400 0.000000\n1709294400\nLOCAL\n",
+		},
+		{
+			name:    "hctosys",
+			args:    []string{"-s"},
+			rtc:     now.Add(time.Minute),
+			adjtime: "10.0 1708776060 0.0\n1708776060\nUTC\n",
+			wantRTC: now.Add(time.Minute),
+			system:  now,
+		},
+		{
+			name:    "adjust",
+			args:    []string{"-a"},
+			rtc:     now.Add(time.Minute),
+			adjtime: "10.0 1708776060 0.0\n1708776060\nUTC\n",
+			wantRTC: now,
+			wantAdj: "10.000000 1709294400 0.000000\n1708776060\nUTC\n",
+		},
+		{
+			name:    "set",
+			args:    []string{"-set", "-date", "2024-03-01 20:30:00"},
+			rtc:     now,
+			adjtime: "10.0 1708862400 0.0\n1708862400\nUTC\n",
+			wantRTC: time.Date(2024, 3, 1, 19, 30, 0, 0, time.UTC),
+			wantAdj: "10.000000 1709321400 0.000000\n0\nUTC\n",
+		},
+		{
+			name:    "set seconds",
+			args:    []string{"-u", "-set", "-date", "@0"},
+			rtc:     now,
+			wantRTC: time.Unix(0, 0).UTC(),
+			wantAdj: "0.000000 0 0.000000\n0\nUTC\n",
+		},
+		{
+			name:    "bad date",
+			args:    []string{"-set", "-date", "soon"},
+			rtc:     now,
+			wantRTC: now,
+			err:     errors.New(`invalid date "soon"`),
+		},
+		{name: "two actions", args: []string{"-w", "-s"}, err: errUsage},
+		{name: "two modes", args: []string{"-u", "-l"}, err: errUsage},
+		{name: "set without date", args: []string{"-set"}, err: errUsage},
+		{name: "argument", args: []string{"x"}, err: errUsage},
+		{name: "bad flag", args: []string{"-x"}, err: errUsage},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			adjfile := filepath.Join(t.TempDir(), "adjtime")
+			if tt.adjtime != "" {
+				if err := os.WriteFile(adjfile, []byte(tt.adjtime), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out bytes.Buffer
+			c, err := command(&out, append([]string{"-adjfile", adjfile}, tt.args...))
+			if err == nil {
+				r := &fakeRTC{t: tt.rtc}
+				var system time.Time
+				c.rtc, c.loc = r, berlin
+				c.now = func() time.Time { return now }
+				c.setSystem = func(t time.Time) error {
+					system = t
+					return nil
+				}
+				err = c.run()
+				if !r.t.Equal(tt.wantRTC) {
+					t.Errorf("got RTC %v, want %v", r.t, tt.wantRTC)
+				}
+				if !system.Equal(tt.system) {
+					t.Errorf("got system clock %v, want %v", system, tt.system)
+				}
+			}
+			if tt.err != nil {
+				if err == nil || !(errors.Is(err, tt.err) || strings.Contains(err.Error(), tt.err.Error())) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.out {
+				t.Errorf("got %q, want %q", out.String(), tt.out)
+			}
+			if tt.wantAdj != "" {
+				b, err := os.ReadFile(adjfile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tt.wantAdj {
+					t.Errorf("got adjtime %q, want %q", b, tt.wantAdj)
+				}
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/exec"
@@ -12,9 +13,12 @@ import (
 	"syscall"
 
 	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/console"
 	"github.com/u-root/u-root/pkg/libinit"
+	"github.com/u-root/u-root/pkg/metrics"
 	"github.com/u-root/u-root/pkg/uflag"
 	"github.com/u-root/u-root/pkg/ulog"
+	"github.com/u-root/u-root/pkg/watchdogd"
 )
 
 func quiet() {
@@ -26,6 +30,15 @@ func quiet() {
 	}
 }
 
+// withModifiers combines several CommandModifiers into one.
+func withModifiers(m ...libinit.CommandModifier) libinit.CommandModifier {
+	return func(c *exec.Cmd) {
+		for _, mod := range m {
+			mod(c)
+		}
+	}
+}
+
 func osInitGo() *initCmds {
 	// Backwards compatibility for the transition from uroot.nohwrng to
 	// UROOT_NOHWRNG=1 on kernel commandline.
@@ -37,6 +50,36 @@ func osInitGo() *initCmds {
 	// Turn off job control when test mode is on.
 	ctty := libinit.WithTTYControl(!*test)
 
+	// Mirror init and shell I/O to all consoles set with uroot.console or
+	// console=, rather than only to the last one.
+	if mux, err := console.FromCmdline(); err != nil {
+		log.Printf("Could not open consoles: %v", err)
+	} else if mux != nil {
+		if pts, err := mux.Attach(); err != nil {
+			log.Printf("Could not attach to consoles %v: %v", mux.Names(), err)
+		} else {
+			log.SetOutput(mux)
+			stdio := []libinit.CommandModifier{libinit.WithStdin(pts), libinit.WithStdout(pts), libinit.WithStderr(pts)}
+			ctty = withModifiers(append(stdio, ctty)...)
+		}
+	}
+
+	// Shut down cleanly, rather than with an abrupt reboot(2), on requests
+	// of shutdown, reboot and poweroff, and on busybox' signals.
+	if os.Getpid() == 1 && !*test {
+		libinit.HandleShutdownSignals(libinit.ShutdownGrace)
+		if l, err := libinit.ListenShutdown(); err != nil {
+			log.Printf("Could not listen for shutdown requests: %v", err)
+		} else {
+			go libinit.ServeShutdown(l, func(a libinit.ShutdownAction) error {
+				return libinit.Shutdown(a, libinit.ShutdownGrace)
+			})
+		}
+		// Tell a watchdogd started with --heartbeat that init is
+		// alive, so that the machine is reset if init hangs.
+		go watchdogd.SendHeartbeats(context.Background(), watchdogd.DefaultUDS, watchdogd.HeartbeatInterval, nil)
+	}
+
 	// Install modules before exec-ing into user mode below
 	if err := libinit.InstallAllModules(); err != nil {
 		log.Println(err)
@@ -57,6 +100,28 @@ func osInitGo() *initCmds {
 		}
 	}
 
+	// Serve the metrics of init's services on the address set with
+	// uroot.metrics, e.g. uroot.metrics=:9100.
+	if addr, ok := cmdline.Flag("uroot.metrics"); ok {
+		go func() {
+			log.Printf("Could not serve metrics: %v", metrics.ListenAndServe(addr))
+		}()
+	}
+
+	// Start the services declared in /etc/init/services.json, or in the
+	// file set with uroot.services, before uinit and the shell.
+	servicesFile := libinit.ServicesFile
+	if f, ok := cmdline.Flag("uroot.services"); ok {
+		servicesFile = f
+	}
+	if c, err := libinit.LoadServices(servicesFile); err == nil {
+		if _, err := libinit.StartServices(c, debug); err != nil {
+			log.Printf("Could not start services: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Could not load services: %v", err)
+	}
+
 	// Allows passing args to uinit via kernel parameters, for example:
 	//
 	// uroot.uinitargs="-v --foobar"
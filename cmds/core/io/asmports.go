@@ -24,6 +24,9 @@ io (xout{b,w,l} address value)...
 	addCmd(writeCmds, "xoutb", &cmd{xout, 16, 8})
 	addCmd(writeCmds, "xoutw", &cmd{xout, 16, 16})
 	addCmd(writeCmds, "xoutl", &cmd{xout, 16, 32})
+	addRMW("xoutb", "xinb")
+	addRMW("xoutw", "xinw")
+	addRMW("xoutl", "xinl")
 }
 
 func xin(addr int64, data memio.UintN) error {
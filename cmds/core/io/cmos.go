@@ -25,6 +25,8 @@ io (rtcw index value)... # write value to RTC register index [0-13]
 	addCmd(readCmds, "rtcr", &cmd{rtcRead, 7, 8})
 	addCmd(writeCmds, "cw", &cmd{cmosWrite, 7, 8})
 	addCmd(writeCmds, "rtcw", &cmd{rtcWrite, 7, 8})
+	addRMW("cw", "cr")
+	addRMW("rtcw", "rtcr")
 }
 
 func cmosRead(reg int64, data memio.UintN) error {
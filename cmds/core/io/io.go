@@ -6,8 +6,13 @@
 //
 // Synopsis:
 //
+//	io [-n COUNT] [-i INTERVAL] COMMAND...
+//
 //	io (r{b,w,l,q} address)...
 //	io (w{b,w,l,q} address value)...
+//	io (d{b,w,l,q} address length)...
+//	io (f{b,w,l,q} address length value)...
+//	io ({set,clear} WRITECOMMAND address mask)...
 //	# x86 only:
 //	io (in{b,w,l} address)
 //	io (out{b,w,l} address value)
@@ -19,23 +24,50 @@
 //	io lets you read/write 1/2/4/8-bytes to memory with the {r,w}{b,w,l,q}
 //	commands respectively.
 //
+//	d{b,w,l,q} dumps length bytes of memory in the format of hexdump -C,
+//	and f{b,w,l,q} fills them with value, both with accesses of the
+//	given width, as device registers may require.
+//
+//	set and clear read a value with the read command that belongs to
+//	WRITECOMMAND, e.g. rl for wl or inb for outb, set or clear the bits
+//	of mask, and write it back.
+//
 //	On x86 platforms, {in,out}{b,w,l} allow for port io.
 //
 //	Use cr / cw to write to cmos registers
 //
+//	All commands are parsed before any is run. With -n, they are run
+//	COUNT times, or forever for 0, INTERVAL apart, to poll registers.
+//
+// Options:
+//
+//	-n: run the commands this many times, 0 for ever (default 1)
+//	-i: interval between runs (default 1s)
+//
 // Examples:
 //
 //	# Read 8-bytes from address 0x10000 and 0x10000
 //	io rq 0x10000 rq 0x10008
 //	# Write to the serial port on x86
 //	io outb 0x3f8 50
+//	# Dump the HPET registers with 32 bit reads
+//	io dl 0xfed00000 0x100
+//	# Enable the HPET counter
+//	io set wl 0xfed00010 1
+//	# Watch the HPET counter every 100ms
+//	io -n 0 -i 100ms rq 0xfed000f0
 package main
 
 import (
+	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/u-root/u-root/pkg/memio"
 )
@@ -51,7 +83,14 @@ type (
 var (
 	readCmds  = map[string]*cmd{}
 	writeCmds = map[string]*cmd{}
-	usageMsg  string
+	dumpCmds  = map[string]*cmd{}
+	fillCmds  = map[string]*cmd{}
+	// readFor maps write commands to the read commands of the same
+	// registers, for set and clear.
+	readFor  = map[string]string{}
+	usageMsg string
+
+	errUsage = errors.New("usage")
 )
 
 func addCmd(cmds map[string]*cmd, n string, f *cmd) {
@@ -61,8 +100,14 @@ func addCmd(cmds map[string]*cmd, n string, f *cmd) {
 	cmds[n] = f
 }
 
+// addRMW allows set and clear with the write command w, which read the
+// value with the read command r.
+func addRMW(w, r string) {
+	readFor[w] = r
+}
+
 func usage() {
-	fmt.Print(usageMsg)
+	fmt.Print("io [-n count] [-i interval] command...\n" + usageMsg)
 	os.Exit(1)
 }
 
@@ -86,69 +131,214 @@ func newInt(val uint64, bits int) memio.UintN {
 	}
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		usage()
+// value returns the value of a UintN made by newInt.
+func value(data memio.UintN) uint64 {
+	switch d := data.(type) {
+	case *memio.Uint8:
+		return uint64(*d)
+	case *memio.Uint16:
+		return uint64(*d)
+	case *memio.Uint32:
+		return uint64(*d)
+	case *memio.Uint64:
+		return uint64(*d)
+	default:
+		panic(fmt.Sprintf("invalid type %T", data))
 	}
-	os.Args = os.Args[1:]
-
-	// To avoid the command list from being partially executed when the
-	// args fail to parse, queue them up and run all at once at the end.
-	queue := []func(){}
-
-	for len(os.Args) > 0 {
-		var cmdStr string
-		cmdStr, os.Args = os.Args[0], os.Args[1:]
-		if c, ok := readCmds[cmdStr]; ok {
-			// Parse arguments.
-			if len(os.Args) < 1 {
-				usage()
+}
+
+// appendValue appends the bits low bits of val to b in native byte order,
+// as they are in memory.
+func appendValue(b []byte, val uint64, bits int) []byte {
+	switch bits {
+	case 8:
+		return append(b, byte(val))
+	case 16:
+		return binary.NativeEndian.AppendUint16(b, uint16(val))
+	case 32:
+		return binary.NativeEndian.AppendUint32(b, uint32(val))
+	default:
+		return binary.NativeEndian.AppendUint64(b, val)
+	}
+}
+
+// hexdump writes b, which was read from addr, in the format of hexdump -C.
+func hexdump(w io.Writer, addr uint64, b []byte) error {
+	for off := 0; off < len(b); off += 16 {
+		line := b[off:min(off+16, len(b))]
+		s := fmt.Sprintf("%016x ", addr+uint64(off))
+		for i := 0; i < 16; i++ {
+			if i%8 == 0 {
+				s += " "
 			}
-			var addrStr string
-			addrStr, os.Args = os.Args[0], os.Args[1:]
-			addr, err := strconv.ParseUint(addrStr, 0, c.addrBits)
-			if err != nil {
-				log.Fatal(err)
+			if i < len(line) {
+				s += fmt.Sprintf("%02x ", line[i])
+			} else {
+				s += "   "
+			}
+		}
+		s += " |"
+		for _, c := range line {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
 			}
+			s += string(c)
+		}
+		if _, err := fmt.Fprintf(w, "%s|\n", s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			queue = append(queue, func() {
+// parseUint parses the arguments of a command, which are all numbers,
+// with the given number of bits.
+func parseUint(args []string, bits ...int) ([]uint64, []string, error) {
+	if len(args) < len(bits) {
+		return nil, nil, errUsage
+	}
+	var v []uint64
+	for i, b := range bits {
+		n, err := strconv.ParseUint(args[i], 0, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		v = append(v, n)
+	}
+	return v, args[len(bits):], nil
+}
+
+// parse parses the commands of args into functions which run them and
+// print the values they read to w. To avoid the command list from being
+// partially executed when the args fail to parse, they are only run once
+// all are parsed.
+func parse(args []string, w io.Writer) ([]func() error, error) {
+	var queue []func() error
+	for len(args) > 0 {
+		var (
+			name = args[0]
+			v    []uint64
+			err  error
+		)
+		args = args[1:]
+		if c, ok := readCmds[name]; ok {
+			if v, args, err = parseUint(args, c.addrBits); err != nil {
+				return nil, err
+			}
+			queue = append(queue, func() error {
 				// Read from addr and print.
 				data := newInt(0, c.valBits)
-				if err := c.f(int64(addr), data); err != nil {
-					log.Fatal(err)
+				if err := c.f(int64(v[0]), data); err != nil {
+					return err
 				}
-				fmt.Printf("%s\n", data)
+				_, err := fmt.Fprintf(w, "%s\n", data)
+				return err
 			})
-		} else if c, ok := writeCmds[cmdStr]; ok {
-			// Parse arguments.
-			if len(os.Args) < 2 {
-				usage()
+		} else if c, ok := writeCmds[name]; ok {
+			if v, args, err = parseUint(args, c.addrBits, c.valBits); err != nil {
+				return nil, err
 			}
-			var addrStr, dataStr string
-			addrStr, dataStr, os.Args = os.Args[0], os.Args[1], os.Args[2:]
-			addr, err := strconv.ParseUint(addrStr, 0, c.addrBits)
-			if err != nil {
-				log.Fatal(err)
+			queue = append(queue, func() error {
+				// Write data to addr.
+				return c.f(int64(v[0]), newInt(v[1], c.valBits))
+			})
+		} else if c, ok := dumpCmds[name]; ok {
+			if v, args, err = parseUint(args, c.addrBits, 32); err != nil {
+				return nil, err
 			}
-			value, err := strconv.ParseUint(dataStr, 0, c.valBits)
-			if err != nil {
-				log.Fatal(err)
+			if v[1]%uint64(c.valBits/8) != 0 {
+				return nil, fmt.Errorf("%s: length %#x is not a multiple of %d bytes", name, v[1], c.valBits/8)
 			}
-
-			queue = append(queue, func() {
-				// Write data to addr.
-				data := newInt(value, c.valBits)
-				if err := c.f(int64(addr), data); err != nil {
-					log.Fatal(err)
+			queue = append(queue, func() error {
+				var b []byte
+				for off := uint64(0); off < v[1]; off += uint64(c.valBits / 8) {
+					data := newInt(0, c.valBits)
+					if err := c.f(int64(v[0]+off), data); err != nil {
+						return err
+					}
+					b = appendValue(b, value(data), c.valBits)
+				}
+				return hexdump(w, v[0], b)
+			})
+		} else if c, ok := fillCmds[name]; ok {
+			if v, args, err = parseUint(args, c.addrBits, 32, c.valBits); err != nil {
+				return nil, err
+			}
+			if v[1]%uint64(c.valBits/8) != 0 {
+				return nil, fmt.Errorf("%s: length %#x is not a multiple of %d bytes", name, v[1], c.valBits/8)
+			}
+			queue = append(queue, func() error {
+				for off := uint64(0); off < v[1]; off += uint64(c.valBits / 8) {
+					if err := c.f(int64(v[0]+off), newInt(v[2], c.valBits)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		} else if name == "set" || name == "clear" {
+			if len(args) < 1 {
+				return nil, errUsage
+			}
+			wc, ok := writeCmds[args[0]]
+			r, rok := readCmds[readFor[args[0]]]
+			if !ok || !rok {
+				return nil, fmt.Errorf("%s: %q is not a write command with a matching read command", name, args[0])
+			}
+			if v, args, err = parseUint(args[1:], wc.addrBits, wc.valBits); err != nil {
+				return nil, err
+			}
+			set := name == "set"
+			queue = append(queue, func() error {
+				data := newInt(0, r.valBits)
+				if err := r.f(int64(v[0]), data); err != nil {
+					return err
+				}
+				val := value(data)
+				if set {
+					val |= v[1]
+				} else {
+					val &^= v[1]
 				}
+				return wc.f(int64(v[0]), newInt(val, wc.valBits))
 			})
 		} else {
-			usage()
+			return nil, fmt.Errorf("%w: unknown command %q", errUsage, name)
 		}
 	}
+	return queue, nil
+}
 
-	// Run all commands.
-	for _, c := range queue {
-		c()
+// run runs the commands count times, or forever for 0, interval apart.
+func run(queue []func() error, count int, interval time.Duration) error {
+	for i := 0; count <= 0 || i < count; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		for _, c := range queue {
+			if err := c(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	count := flag.Int("n", 1, "Run the commands this many times, 0 for ever")
+	interval := flag.Duration("i", time.Second, "Interval between runs")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 2 {
+		usage()
+	}
+	queue, err := parse(flag.Args(), os.Stdout)
+	if errors.Is(err, errUsage) {
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(queue, *count, *interval); err != nil {
+		log.Fatal(err)
 	}
 }
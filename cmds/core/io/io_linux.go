@@ -19,7 +19,25 @@ func init() {
 	addCmd(writeCmds, "wl", &cmd{memio.Write, 64, 32})
 	addCmd(writeCmds, "wq", &cmd{memio.Write, 64, 64})
 
+	addCmd(dumpCmds, "db", &cmd{memio.Read, 64, 8})
+	addCmd(dumpCmds, "dw", &cmd{memio.Read, 64, 16})
+	addCmd(dumpCmds, "dl", &cmd{memio.Read, 64, 32})
+	addCmd(dumpCmds, "dq", &cmd{memio.Read, 64, 64})
+
+	addCmd(fillCmds, "fb", &cmd{memio.Write, 64, 8})
+	addCmd(fillCmds, "fw", &cmd{memio.Write, 64, 16})
+	addCmd(fillCmds, "fl", &cmd{memio.Write, 64, 32})
+	addCmd(fillCmds, "fq", &cmd{memio.Write, 64, 64})
+
+	addRMW("wb", "rb")
+	addRMW("ww", "rw")
+	addRMW("wl", "rl")
+	addRMW("wq", "rq")
+
 	usageMsg += `io (r{b,w,l,q} address)...
 io (w{b,w,l,q} address value)...
+io (d{b,w,l,q} address length)... # hexdump length bytes
+io (f{b,w,l,q} address length value)... # fill length bytes with value
+io ({set,clear} writecommand address mask)... # set or clear bits
 `
 }
@@ -0,0 +1,110 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/memio"
+)
+
+// mem is the memory of the test commands tr, tw, tdw, tfw.
+var mem [64]byte
+
+func memRead(addr int64, data memio.UintN) error {
+	switch d := data.(type) {
+	case *memio.Uint8:
+		*d = memio.Uint8(mem[addr])
+	case *memio.Uint16:
+		*d = memio.Uint16(binary.NativeEndian.Uint16(mem[addr:]))
+	}
+	return nil
+}
+
+func memWrite(addr int64, data memio.UintN) error {
+	switch d := data.(type) {
+	case *memio.Uint8:
+		mem[addr] = byte(*d)
+	case *memio.Uint16:
+		binary.NativeEndian.PutUint16(mem[addr:], uint16(*d))
+	}
+	return nil
+}
+
+func init() {
+	addCmd(readCmds, "tr", &cmd{memRead, 6, 8})
+	addCmd(writeCmds, "tw", &cmd{memWrite, 6, 8})
+	addCmd(dumpCmds, "tdw", &cmd{memRead, 6, 16})
+	addCmd(fillCmds, "tfw", &cmd{memWrite, 6, 16})
+	addRMW("tw", "tr")
+}
+
+func TestParseRun(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		count int
+		want  string
+		mem   []byte
+		err   error
+	}{
+		{
+			name: "read write",
+			args: []string{"tw", "1", "0x41", "tr", "1"},
+			want: "0x41\n",
+			mem:  []byte{0, 0x41},
+		},
+		{
+			name: "fill dump",
+			args: []string{"tfw", "0", "20", "0x4241", "tdw", "0", "18"},
+			want: "0000000000000000  41 42 41 42 41 42 41 42  41 42 41 42 41 42 41 42  |ABABABABABABABAB|\n" +
+				"0000000000000010  41 42                                             |AB|\n",
+			mem: bytes.Repeat([]byte("AB"), 10),
+		},
+		{
+			name: "set clear",
+			args: []string{"tw", "0", "0xf0", "set", "tw", "0", "0x03", "clear", "tw", "0", "0x30"},
+			mem:  []byte{0xc3},
+		},
+		{
+			name:  "loop",
+			args:  []string{"set", "tw", "0", "1", "tr", "0", "clear", "tw", "0", "1"},
+			count: 3,
+			want:  "0x01\n0x01\n0x01\n",
+			mem:   []byte{0},
+		},
+		{name: "unknown", args: []string{"tx", "0"}, err: errUsage},
+		{name: "missing value", args: []string{"tw", "0"}, err: errUsage},
+		{name: "address too large", args: []string{"tr", "64"}},
+		{name: "odd length", args: []string{"tdw", "0", "3"}},
+		{name: "set without read", args: []string{"set", "tr", "0", "1"}},
+	} {
+		mem = [64]byte{}
+		var out bytes.Buffer
+		queue, err := parse(tt.args, &out)
+		if tt.mem == nil {
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("%s: parse = %v, want error %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parse = %v", tt.name, err)
+			continue
+		}
+		if err := run(queue, max(tt.count, 1), 0); err != nil {
+			t.Errorf("%s: run = %v", tt.name, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: output %q, want %q", tt.name, out.String(), tt.want)
+		}
+		if !bytes.Equal(mem[:len(tt.mem)], tt.mem) {
+			t.Errorf("%s: memory % x, want % x", tt.name, mem[:len(tt.mem)], tt.mem)
+		}
+	}
+}
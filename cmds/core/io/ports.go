@@ -20,6 +20,9 @@ io (out{b,w,l} address value)...
 	addCmd(writeCmds, "outb", &cmd{out, 16, 8})
 	addCmd(writeCmds, "outw", &cmd{out, 16, 16})
 	addCmd(writeCmds, "outl", &cmd{out, 16, 32})
+	addRMW("outb", "inb")
+	addRMW("outw", "inw")
+	addRMW("outl", "inl")
 }
 
 func in(addr int64, data memio.UintN) error {
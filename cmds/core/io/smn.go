@@ -28,6 +28,7 @@ io ws index value # write value to system management network on newer AMD CPUs.
 `
 	addCmd(readCmds, "rs", &cmd{smnRead, 32, 32})
 	addCmd(writeCmds, "ws", &cmd{smnWrite, 32, 32})
+	addRMW("ws", "rs")
 }
 
 func do(addr int64, data memio.UintN, op func(int64, memio.UintN) error) error {
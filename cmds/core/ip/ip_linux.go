@@ -11,14 +11,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/vishvananda/netlink"
 )
 
-var inet6 bool
+var (
+	inet6   bool
+	details bool
+)
 
 // The language implemented by the standard 'ip' is not super consistent
 // and has lots of convenience shortcuts.
@@ -105,7 +110,7 @@ func addrip(w io.Writer) error {
 	var err error
 	var addr *netlink.Addr
 	if len(arg) == 1 {
-		return showLinks(w, true)
+		return showLinks(w, true, details)
 	}
 	cursor++
 	whatIWant = []string{"add", "del"}
@@ -150,12 +155,22 @@ func neigh(w io.Writer) error {
 }
 
 func linkshow(w io.Writer) error {
-	cursor++
-	whatIWant = []string{"<nothing>", "<device name>"}
-	if len(arg[cursor:]) == 0 {
-		return showLinks(w, false)
+	var links []netlink.Link
+	for cursor++; cursor < len(arg); cursor++ {
+		whatIWant = []string{"<nothing>", "-d", "details", "dev", "<device name>"}
+		switch arg[cursor] {
+		case "-d", "-details", "details":
+			details = true
+		case "dev":
+		default:
+			l, err := netlink.LinkByName(arg[cursor])
+			if err != nil {
+				return fmt.Errorf("%v: %v", arg[cursor], err)
+			}
+			links = append(links, l)
+		}
 	}
-	return nil
+	return showLinks(w, false, details, links...)
 }
 
 func setHardwareAddress(iface netlink.Link) error {
@@ -178,7 +193,7 @@ func linkset() error {
 	}
 
 	cursor++
-	whatIWant = []string{"address", "up", "down", "master"}
+	whatIWant = []string{"address", "up", "down", "master", "vf"}
 	switch one(arg[cursor], whatIWant) {
 	case "address":
 		return setHardwareAddress(iface)
@@ -198,12 +213,192 @@ func linkset() error {
 			return err
 		}
 		return netlink.LinkSetMaster(iface, master)
+	case "vf":
+		return linksetvf(iface)
 	default:
 		return usage()
 	}
 	return nil
 }
 
+// onoff parses the on or off after the setting of a VF.
+func onoff() (bool, error) {
+	cursor++
+	whatIWant = []string{"on", "off"}
+	switch arg[cursor] {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, usage()
+}
+
+// number parses the number after the setting of a VF, up to limit.
+func number(what string, limit int) (int, error) {
+	cursor++
+	whatIWant = []string{what}
+	n, err := strconv.Atoi(arg[cursor])
+	if err != nil || n < 0 || n > limit {
+		return 0, usage()
+	}
+	return n, nil
+}
+
+// vfsettings parses the settings of VF vf of iface, and returns what
+// applies them, so that nothing is changed if any of them is wrong.
+func vfsettings(iface netlink.Link, vf int) ([]func() error, error) {
+	name := iface.Attrs().Name
+	var (
+		ops              []func() error
+		minRate, maxRate = -1, -1
+	)
+	for more := true; more; more = cursor+1 < len(arg) {
+		cursor++
+		whatIWant = []string{"mac", "vlan", "rate", "max_tx_rate", "min_tx_rate", "spoofchk", "trust", "state"}
+		switch c := one(arg[cursor], whatIWant); c {
+		case "mac":
+			cursor++
+			whatIWant = []string{"MAC address"}
+			hwAddr, err := net.ParseMAC(arg[cursor])
+			if err != nil {
+				return nil, fmt.Errorf("%v vf %d: can't parse mac addr %v: %v", name, vf, arg[cursor], err)
+			}
+			ops = append(ops, func() error {
+				if err := netlink.LinkSetVfHardwareAddr(iface, vf, hwAddr); err != nil {
+					return fmt.Errorf("%v vf %d: can't set mac addr %v: %v", name, vf, hwAddr, err)
+				}
+				return nil
+			})
+		case "vlan":
+			vlan, err := number("VLAN ID", 4095)
+			if err != nil {
+				return nil, err
+			}
+			qos := -1
+			if cursor+1 < len(arg) && arg[cursor+1] == "qos" {
+				cursor++
+				if qos, err = number("VLAN QoS", 7); err != nil {
+					return nil, err
+				}
+			}
+			ops = append(ops, func() error {
+				var err error
+				if qos < 0 {
+					err = netlink.LinkSetVfVlan(iface, vf, vlan)
+				} else {
+					err = netlink.LinkSetVfVlanQos(iface, vf, vlan, qos)
+				}
+				if err != nil {
+					return fmt.Errorf("%v vf %d: can't set vlan %d: %v", name, vf, vlan, err)
+				}
+				return nil
+			})
+		case "rate":
+			rate, err := number("rate in Mbps", math.MaxInt32)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, func() error {
+				if err := netlink.LinkSetVfTxRate(iface, vf, rate); err != nil {
+					return fmt.Errorf("%v vf %d: can't set rate %d: %v", name, vf, rate, err)
+				}
+				return nil
+			})
+		case "max_tx_rate", "min_tx_rate":
+			rate, err := number("rate in Mbps", math.MaxInt32)
+			if err != nil {
+				return nil, err
+			}
+			if c == "max_tx_rate" {
+				maxRate = rate
+			} else {
+				minRate = rate
+			}
+		case "spoofchk", "trust":
+			on, err := onoff()
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, func() error {
+				var err error
+				if c == "spoofchk" {
+					err = netlink.LinkSetVfSpoofchk(iface, vf, on)
+				} else {
+					err = netlink.LinkSetVfTrust(iface, vf, on)
+				}
+				if err != nil {
+					return fmt.Errorf("%v vf %d: can't set %v: %v", name, vf, c, err)
+				}
+				return nil
+			})
+		case "state":
+			cursor++
+			whatIWant = []string{"auto", "enable", "disable"}
+			var state uint32
+			s := arg[cursor]
+			switch s {
+			case "auto":
+				state = netlink.VF_LINK_STATE_AUTO
+			case "enable":
+				state = netlink.VF_LINK_STATE_ENABLE
+			case "disable":
+				state = netlink.VF_LINK_STATE_DISABLE
+			default:
+				return nil, usage()
+			}
+			ops = append(ops, func() error {
+				if err := netlink.LinkSetVfState(iface, vf, state); err != nil {
+					return fmt.Errorf("%v vf %d: can't set state %v: %v", name, vf, s, err)
+				}
+				return nil
+			})
+		default:
+			return nil, usage()
+		}
+	}
+
+	if minRate >= 0 || maxRate >= 0 {
+		// The kernel sets both rates at once, so keep the one not given.
+		for _, v := range iface.Attrs().Vfs {
+			if v.ID != vf {
+				continue
+			}
+			if minRate < 0 {
+				minRate = int(v.MinTxRate)
+			}
+			if maxRate < 0 {
+				maxRate = int(v.MaxTxRate)
+			}
+		}
+		minRate, maxRate = max(minRate, 0), max(maxRate, 0)
+		ops = append(ops, func() error {
+			if err := netlink.LinkSetVfRate(iface, vf, minRate, maxRate); err != nil {
+				return fmt.Errorf("%v vf %d: can't set min_tx_rate %d max_tx_rate %d: %v", name, vf, minRate, maxRate, err)
+			}
+			return nil
+		})
+	}
+	return ops, nil
+}
+
+func linksetvf(iface netlink.Link) error {
+	vf, err := number("VF number", math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	ops, err := vfsettings(iface, vf)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if err := op(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func linkadd() error {
 	name, err := maybename()
 	if err != nil {
@@ -394,6 +589,7 @@ func run(out io.Writer) error {
 
 func main() {
 	flag.BoolVar(&inet6, "6", false, "use inet6")
+	flag.BoolVar(&details, "d", false, "show details, such as the VFs of links")
 	flag.Parse()
 	arg = flag.Args()
 	if err := run(os.Stdout); err != nil {
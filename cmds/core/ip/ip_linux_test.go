@@ -0,0 +1,68 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestFormatVf(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	for _, tt := range []struct {
+		vf   netlink.VfInfo
+		want string
+	}{
+		{
+			vf:   netlink.VfInfo{ID: 0, Mac: mac, Spoofchk: true},
+			want: "vf 0 link/ether 52:54:00:12:34:56, spoof checking on, link-state auto, trust off",
+		},
+		{
+			vf: netlink.VfInfo{
+				ID: 3, Mac: mac, Vlan: 10, Qos: 2, MaxTxRate: 1000, MinTxRate: 100,
+				LinkState: netlink.VF_LINK_STATE_DISABLE, Trust: 1,
+			},
+			want: "vf 3 link/ether 52:54:00:12:34:56, vlan 10, qos 2, max_tx_rate 1000Mbps, min_tx_rate 100Mbps, spoof checking off, link-state disable, trust on",
+		},
+	} {
+		if got := formatVf(tt.vf); got != tt.want {
+			t.Errorf("formatVf(%+v) = %q, want %q", tt.vf, got, tt.want)
+		}
+	}
+}
+
+func TestVfSettings(t *testing.T) {
+	iface := &netlink.Device{LinkAttrs: netlink.LinkAttrs{
+		Name: "eth0",
+		Vfs:  []netlink.VfInfo{{ID: 3, MaxTxRate: 1000}},
+	}}
+	for _, tt := range []struct {
+		args string
+		ops  int
+		ok   bool
+	}{
+		{"mac 52:54:00:12:34:56 vlan 10 spoofchk off trust on", 4, true},
+		{"vlan 10 qos 3 state enable", 2, true},
+		{"rate 100", 1, true},
+		{"min_tx_rate 10 max_tx_rate 100", 1, true},
+		{"mac 52:54:00:12:34", 0, false},
+		{"vlan 4096", 0, false},
+		{"vlan 10 qos 8", 0, false},
+		{"spoofchk maybe", 0, false},
+		{"state down", 0, false},
+		{"mtu 9000", 0, false},
+	} {
+		// Settings follow: ip link set dev eth0 vf 3.
+		arg = append(strings.Fields("link set dev eth0 vf 3"), strings.Fields(tt.args)...)
+		cursor = 5
+		ops, err := vfsettings(iface, 3)
+		if (err == nil) != tt.ok || len(ops) != tt.ops {
+			t.Errorf("vfsettings(%q) = %d ops, %v, want %d ops, ok %v", tt.args, len(ops), err, tt.ops, tt.ok)
+		}
+	}
+}
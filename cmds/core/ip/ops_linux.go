@@ -15,10 +15,14 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func showLinks(w io.Writer, withAddresses bool) error {
-	ifaces, err := netlink.LinkList()
-	if err != nil {
-		return fmt.Errorf("can't enumerate interfaces: %v", err)
+// showLinks shows links, or all links if none is given.
+func showLinks(w io.Writer, withAddresses, details bool, links ...netlink.Link) error {
+	ifaces := links
+	if len(ifaces) == 0 {
+		var err error
+		if ifaces, err = netlink.LinkList(); err != nil {
+			return fmt.Errorf("can't enumerate interfaces: %v", err)
+		}
 	}
 
 	for _, v := range ifaces {
@@ -38,6 +42,12 @@ func showLinks(w io.Writer, withAddresses bool) error {
 
 		fmt.Fprintf(w, "    link/%s %s\n", l.EncapType, l.HardwareAddr)
 
+		if details {
+			for _, vf := range l.Vfs {
+				fmt.Fprintf(w, "    %s\n", formatVf(vf))
+			}
+		}
+
 		if withAddresses {
 			showLinkAddresses(w, v)
 		}
@@ -45,6 +55,43 @@ func showLinks(w io.Writer, withAddresses bool) error {
 	return nil
 }
 
+var vfLinkStates = map[uint32]string{
+	netlink.VF_LINK_STATE_AUTO:    "auto",
+	netlink.VF_LINK_STATE_ENABLE:  "enable",
+	netlink.VF_LINK_STATE_DISABLE: "disable",
+}
+
+// formatVf formats the state of an SR-IOV VF like ip -d link show.
+func formatVf(vf netlink.VfInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "vf %d link/ether %s", vf.ID, vf.Mac)
+	if vf.Vlan != 0 {
+		fmt.Fprintf(&b, ", vlan %d", vf.Vlan)
+		if vf.Qos != 0 {
+			fmt.Fprintf(&b, ", qos %d", vf.Qos)
+		}
+	}
+	if vf.MaxTxRate != 0 {
+		fmt.Fprintf(&b, ", max_tx_rate %dMbps", vf.MaxTxRate)
+	}
+	if vf.MinTxRate != 0 {
+		fmt.Fprintf(&b, ", min_tx_rate %dMbps", vf.MinTxRate)
+	}
+	state, ok := vfLinkStates[vf.LinkState]
+	if !ok {
+		state = fmt.Sprintf("%d", vf.LinkState)
+	}
+	fmt.Fprintf(&b, ", spoof checking %s, link-state %s, trust %s", onOff(vf.Spoofchk), state, onOff(vf.Trust != 0))
+	return b.String()
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
 func showLinkAddresses(w io.Writer, link netlink.Link) error {
 	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
 	if err != nil {
@@ -5,21 +5,36 @@
 // kexec executes a new kernel over the running kernel (u-root).
 //
 // Synopsis:
-//     kexec [--initrd=FILE] [--command-line=STRING] [-l] [-e] [KERNELIMAGE]
+//     kexec [--initrd=FILE] [--command-line=STRING] [--measure] [-l] [-e] [KERNELIMAGE]
 //
 // Description:
 //		 Loads a kernel for later execution.
 //
+//		 kexec_file_load is used by default so that signed kernel policies,
+//		 IMA appraisal and kernel lockdown are honoured. kexec_load is only
+//		 used if kexec_file_load is not supported, or if -L is given.
+//
+//		 With --dtbo, device tree overlays are applied to the --dtb file, or
+//		 to the device tree of the running system, before it is passed to
+//		 the new kernel.
+//
+//		 With --measure, the kernel, initramfs and command line are extended
+//		 into TPM PCRs 9 and 8 before they are loaded.
+//
 // Options:
 //      --append string        Append to the kernel command line
 //  -c, --cmdline string       Append to the kernel command line
 //  -d, --debug                Print debug info (default true)
+//      --dtb string           FILE used as the flatten device tree blob
+//      --dtbo stringArray     Apply the device tree overlay FILE to the device tree
 //  -e, --exec                 Execute a currently loaded kernel
 //  -x, --extra string         Add a cpio containing extra files
 //      --initramfs string     Use file as the kernel's initial ramdisk
 //  -i, --initrd string        Use file as the kernel's initial ramdisk
 //  -l, --load                 Load the new kernel into the current kernel
-//  -L, --loadsyscall          Use the kexec load syscall (not file_load) (default true)
+//  -L, --loadsyscall          Use the kexec_load syscall instead of kexec_file_load
+//      --measure              Measure kernel, initramfs and command line into the TPM
+//      --measure-log string   Write the TCG event log of the measurements to file
 //      --module stringArray   Load multiboot module with command line args (e.g --module="mod arg1")
 //  -p, --purgatory string     pick a purgatory, use '-p xyz' to get a list (default "default")
 //      --reuse-cmdline        Use the kernel command line from running system
@@ -27,6 +42,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -41,6 +57,7 @@ import (
 	"github.com/u-root/u-root/pkg/boot/multiboot"
 	"github.com/u-root/u-root/pkg/boot/purgatory"
 	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/dt"
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 	"github.com/u-root/uio/uio"
 )
@@ -52,16 +69,46 @@ type options struct {
 	cmdline      string
 	debug        bool
 	dtb          string
+	dtbo         []string
 	exec         bool
 	extra        string
 	initramfs    string
 	load         bool
 	loadSyscall  bool
+	measure      bool
+	measureLog   string
 	modules      []string
 	purgatory    string
 	reuseCmdline bool
 }
 
+// applyOverlays applies overlays to the device tree in base, or that of
+// the running system if base is empty.
+func applyOverlays(base string, overlays []string) (io.ReaderAt, error) {
+	var names []string
+	if base != "" {
+		names = append(names, base)
+	}
+	fdt, err := dt.LoadFDT(nil, names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device tree %s: %w", base, err)
+	}
+	for _, o := range overlays {
+		overlay, err := dt.ReadFile(o)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read overlay %s: %w", o, err)
+		}
+		if err := fdt.ApplyOverlay(overlay); err != nil {
+			return nil, fmt.Errorf("failed to apply overlay %s: %w", o, err)
+		}
+	}
+	b, err := fdt.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
 func (o *options) parseCmdline(args []string, f *flag.FlagSet) {
 	f.StringVar(&o.cmdline, "cmdline", "", "Append to the kernel command line")
 	f.StringVar(&o.cmdline, "c", "", "Append to the kernel command line (shorthand)")
@@ -72,6 +119,7 @@ func (o *options) parseCmdline(args []string, f *flag.FlagSet) {
 	f.BoolVar(&o.debug, "d", false, "Print debug info (shorthand)")
 
 	f.StringVar(&o.dtb, "dtb", "", "FILE used as the flatten device tree blob")
+	f.Var((*unixflag.StringArray)(&o.dtbo), "dtbo", "Apply the device tree overlay FILE to the device tree")
 
 	f.BoolVar(&o.exec, "exec", false, "Execute a currently loaded kernel")
 	f.BoolVar(&o.exec, "e", false, "Execute a currently loaded kernel (shorthand)")
@@ -94,8 +142,11 @@ func (o *options) parseCmdline(args []string, f *flag.FlagSet) {
 	f.StringVar(&loadFlagPath, "load", "", "Load the new kernel into the current kernel")
 	f.StringVar(&loadFlagPath, "l", "", "Load the new kernel into the current kernel (shorthand)")
 
-	f.BoolVar(&o.loadSyscall, "loadsyscall", false, "Use the kexec_load syscall (not kexec_file_load)")
-	f.BoolVar(&o.loadSyscall, "L", false, "Use the kexec_load syscall (not kexec_file_load) (shorthand)")
+	f.BoolVar(&o.loadSyscall, "loadsyscall", false, "Use the kexec_load syscall instead of kexec_file_load")
+	f.BoolVar(&o.loadSyscall, "L", false, "Use the kexec_load syscall instead of kexec_file_load (shorthand)")
+
+	f.BoolVar(&o.measure, "measure", false, "Measure kernel, initramfs and command line into the TPM")
+	f.StringVar(&o.measureLog, "measure-log", "", "Write the TCG event log of the measurements to file")
 
 	f.Var((*unixflag.StringArray)(&o.modules), "module", `Load multiboot module with command line args (e.g --module="mod arg1")`)
 
@@ -227,7 +278,12 @@ func run(args []string) error {
 			}
 
 			var dtb io.ReaderAt
-			if len(opts.dtb) > 0 {
+			if len(opts.dtbo) > 0 {
+				dtb, err = applyOverlays(opts.dtb, opts.dtbo)
+				if err != nil {
+					return err
+				}
+			} else if len(opts.dtb) > 0 {
 				dtb, err = os.Open(opts.dtb)
 				if err != nil {
 					return fmt.Errorf("failed to open dtb file %s: %w", opts.dtb, err)
@@ -241,7 +297,16 @@ func run(args []string) error {
 				DTB:         dtb,
 			}
 		}
-		if err := image.Load(boot.WithVerbose(opts.debug)); err != nil {
+		loadOpts := []boot.LoadOption{boot.WithVerbose(opts.debug)}
+		if opts.measure {
+			m, err := boot.NewTPMMeasurer(opts.measureLog)
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			loadOpts = append(loadOpts, boot.WithMeasurer(m))
+		}
+		if err := image.Load(loadOpts...); err != nil {
 			return err
 		}
 	}
@@ -6,8 +6,13 @@ package main
 
 import (
 	"flag"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/dt"
 )
 
 func TestParseCmdline(t *testing.T) {
@@ -106,6 +111,16 @@ func TestParseCmdline(t *testing.T) {
 				kernelpath: "/path/to/kernel",
 			},
 		},
+		{
+			name: "Test overlays",
+			args: []string{"kexec", "-l", "--dtb", "base.dtb", "--dtbo", "a.dtbo", "--dtbo", "b.dtbo", "/path/to/kernel"},
+			expected: options{
+				load:       true,
+				dtb:        "base.dtb",
+				dtbo:       []string{"a.dtbo", "b.dtbo"},
+				kernelpath: "/path/to/kernel",
+			},
+		},
 		{
 			name: "Test command line",
 			args: []string{"kexec", "-l", "-c", "${CMDLINE}", "/path/to/kernel"},
@@ -240,3 +255,44 @@ func TestHackLoadFlagValue(t *testing.T) {
 		}
 	}
 }
+
+func writeDTB(t *testing.T, name string, root *dt.Node) string {
+	t.Helper()
+	fdt := &dt.FDT{Header: dt.Header{Magic: dt.Magic, Version: 17, LastCompVersion: 16}, RootNode: root}
+	b, err := fdt.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestApplyOverlays(t *testing.T) {
+	base := writeDTB(t, "base.dtb", dt.NewNode("", dt.WithProperty(dt.PropertyString("model", "base"))))
+	overlay := writeDTB(t, "model.dtbo", dt.NewNode("", dt.WithChildren(
+		dt.NewNode("fragment@0",
+			dt.WithProperty(dt.PropertyString("target-path", "/")),
+			dt.WithChildren(dt.NewNode("__overlay__", dt.WithProperty(dt.PropertyString("model", "variant")))),
+		),
+	)))
+
+	r, err := applyOverlays(base, []string{overlay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdt, err := dt.ReadFDT(io.NewSectionReader(r, 0, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := fdt.RootNode.LookProperty("model")
+	if got, _ := p.AsString(); !ok || got != "variant" {
+		t.Errorf("model = %q, want variant", got)
+	}
+
+	if _, err := applyOverlays(base, []string{filepath.Join(t.TempDir(), "missing.dtbo")}); err == nil {
+		t.Errorf("applying a missing overlay succeeded, want an error")
+	}
+}
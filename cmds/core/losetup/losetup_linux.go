@@ -6,62 +6,211 @@
 //
 // Synopsis:
 //
-//	losetup [-Ad] FILE
-//	losetup [-Ad] DEV FILE
+//	losetup [-a]
+//	losetup [-fPr] [--show] [-o OFFSET] [--sizelimit SIZE] FILE
+//	losetup [-Pr] [--show] [-o OFFSET] [--sizelimit SIZE] DEV FILE
+//	losetup -f
+//	losetup -d DEV...
+//	losetup -D
+//
+// Description:
+//
+//	Without arguments or with -a, losetup lists the attached devices.
+//	With -P, the kernel scans the file for partitions and creates a
+//	/dev/loopNpM device for each, so that the partitions of a GPT image
+//	can be mounted one by one.
 //
 // Options:
 //
-//	-A: pick any device
-//	-d: detach the device
+//	-a, --all:        list all attached devices
+//	-A, -f, --find:   pick any free device; alone, print its name
+//	-d, --detach:     detach the devices
+//	-D, --detach-all: detach all devices
+//	-P, --partscan:   scan the file for partitions
+//	-r, --read-only:  attach the file read-only
+//	-o, --offset:     start the device at OFFSET bytes into the file
+//	--sizelimit:      limit the device to SIZE bytes
+//	--show:           print the name of the attached device
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/u-root/u-root/pkg/mount/loop"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+	"golang.org/x/sys/unix"
 )
 
-var detach = flag.Bool("d", false, "Detach the device")
+var errUsage = errors.New("usage: losetup [-a] | [-fPr] [--show] [-o OFFSET] [--sizelimit SIZE] [DEV] FILE | -f | -d DEV... | -D")
 
-func main() {
-	var (
-		filename, devicename string
-		err                  error
-	)
-
-	flag.Parse()
-	args := flag.Args()
-	if *detach {
-		if len(args) == 1 {
-			if err := loop.ClearFile(args[0]); err != nil {
-				log.Fatal("Error clearing device: ", err)
+type cmd struct {
+	stdout io.Writer
+	stderr io.Writer
+	args   []string
+
+	all       bool
+	find      bool
+	detach    bool
+	detachAll bool
+	partscan  bool
+	readOnly  bool
+	show      bool
+	offset    uint64
+	sizeLimit uint64
+}
+
+func command(stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, stderr: stderr}
+
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&c.all, "a", false, "list all attached devices")
+	f.BoolVar(&c.all, "all", false, "list all attached devices")
+	f.BoolVar(&c.find, "A", false, "pick any free device")
+	f.BoolVar(&c.find, "f", false, "pick any free device")
+	f.BoolVar(&c.find, "find", false, "pick any free device")
+	f.BoolVar(&c.detach, "d", false, "detach the devices")
+	f.BoolVar(&c.detach, "detach", false, "detach the devices")
+	f.BoolVar(&c.detachAll, "D", false, "detach all devices")
+	f.BoolVar(&c.detachAll, "detach-all", false, "detach all devices")
+	f.BoolVar(&c.partscan, "P", false, "scan the file for partitions")
+	f.BoolVar(&c.partscan, "partscan", false, "scan the file for partitions")
+	f.BoolVar(&c.readOnly, "r", false, "attach the file read-only")
+	f.BoolVar(&c.readOnly, "read-only", false, "attach the file read-only")
+	f.Uint64Var(&c.offset, "o", 0, "start the device at this offset into the file")
+	f.Uint64Var(&c.offset, "offset", 0, "start the device at this offset into the file")
+	f.Uint64Var(&c.sizeLimit, "sizelimit", 0, "limit the device to this size")
+	f.BoolVar(&c.show, "show", false, "print the name of the attached device")
+
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return nil, err
+	}
+	c.args = f.Args()
+	return c, nil
+}
+
+func (c *cmd) run() error {
+	switch {
+	case c.detachAll:
+		if len(c.args) != 0 {
+			return errUsage
+		}
+		devices, err := loop.List()
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			if err := loop.ClearFile(d.Name); err != nil {
+				return fmt.Errorf("detaching %s: %w", d.Name, err)
 			}
-			log.Println("Detached", args[0])
-			os.Exit(0)
 		}
-		flag.Usage()
-		log.Fatal("Syntax Error")
-	}
+		return nil
+
+	case c.detach:
+		if len(c.args) == 0 {
+			return errUsage
+		}
+		for _, dev := range c.args {
+			if err := loop.ClearFile(dev); err != nil {
+				return fmt.Errorf("detaching %s: %w", dev, err)
+			}
+		}
+		return nil
+
+	case c.all || len(c.args) == 0 && !c.find:
+		if len(c.args) != 0 {
+			return errUsage
+		}
+		devices, err := loop.List()
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			fmt.Fprintln(c.stdout, format(d))
+		}
+		return nil
 
-	if len(args) == 1 {
-		devicename, err = loop.FindDevice()
+	case len(c.args) == 0:
+		dev, err := loop.FindDevice()
 		if err != nil {
-			log.Fatalf("can't find a loop: %v", err)
+			return fmt.Errorf("can't find a loop: %w", err)
 		}
-		filename = args[0]
-	} else if len(args) == 2 {
-		devicename = args[0]
-		filename = args[1]
+		fmt.Fprintln(c.stdout, dev)
+		return nil
+	}
+
+	var dev, file string
+	switch {
+	case len(c.args) == 1:
+		file = c.args[0]
+	case len(c.args) == 2 && !c.find:
+		dev, file = c.args[0], c.args[1]
+	default:
+		return errUsage
+	}
+
+	config := loop.Config{Offset: c.offset, SizeLimit: c.sizeLimit}
+	if c.partscan {
+		config.Flags |= unix.LO_FLAGS_PARTSCAN
+	}
+	if c.readOnly {
+		config.Flags |= unix.LO_FLAGS_READ_ONLY
+	}
+	var device *os.File
+	var err error
+	if dev == "" {
+		device, err = loop.Attach(file, config)
 	} else {
-		flag.Usage()
-		log.Fatal("Syntax Error")
+		device, err = loop.AttachDevice(dev, file, config)
 	}
+	if err != nil {
+		return err
+	}
+	defer device.Close()
 
-	if err := loop.SetFile(devicename, filename); err != nil {
-		log.Fatal("Could not set loop device:", err)
+	if c.show {
+		fmt.Fprintln(c.stdout, device.Name())
+	} else {
+		fmt.Fprintf(c.stderr, "Attached %s to %s\n", device.Name(), file)
 	}
+	return nil
+}
+
+// format describes d the way losetup -a does.
+func format(d *loop.Device) string {
+	s := fmt.Sprintf("%s: (%s)", d.Name, d.BackingFile)
+	if d.Offset != 0 {
+		s += fmt.Sprintf(", offset %d", d.Offset)
+	}
+	if d.SizeLimit != 0 {
+		s += fmt.Sprintf(", sizelimit %d", d.SizeLimit)
+	}
+	for _, fl := range []struct {
+		flag uint32
+		name string
+	}{
+		{unix.LO_FLAGS_READ_ONLY, "read-only"},
+		{unix.LO_FLAGS_PARTSCAN, "partscan"},
+		{unix.LO_FLAGS_AUTOCLEAR, "autoclear"},
+	} {
+		if d.Flags&fl.flag != 0 {
+			s += ", " + fl.name
+		}
+	}
+	return s
+}
 
-	log.Printf("Attached %s to %s", devicename, filename)
+func main() {
+	c, err := command(os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		os.Exit(2)
+	}
+	if err := c.run(); err != nil {
+		log.Fatalf("losetup: %v", err)
+	}
 }
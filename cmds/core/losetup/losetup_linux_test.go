@@ -0,0 +1,73 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/loop"
+	"golang.org/x/sys/unix"
+)
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"losetup", "-d"},
+		{"losetup", "-D", "/dev/loop0"},
+		{"losetup", "-a", "disk.img"},
+		{"losetup", "-f", "/dev/loop0", "disk.img"},
+		{"losetup", "/dev/loop0", "disk.img", "extra"},
+	} {
+		c, err := command(io.Discard, io.Discard, args)
+		if err != nil {
+			t.Fatalf("command(%q): %v", args, err)
+		}
+		if err := c.run(); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	c, err := command(io.Discard, io.Discard, []string{"losetup", "-fPr", "--show", "-o", "1048576", "--sizelimit", "4096", "disk.img"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.find || !c.partscan || !c.readOnly || !c.show || c.offset != 1<<20 || c.sizeLimit != 4096 {
+		t.Errorf("command parsed %+v", c)
+	}
+	if len(c.args) != 1 || c.args[0] != "disk.img" {
+		t.Errorf("args = %q, want [disk.img]", c.args)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		d    loop.Device
+		want string
+	}{
+		{
+			d:    loop.Device{Name: "/dev/loop0", BackingFile: "/tmp/disk.img"},
+			want: "/dev/loop0: (/tmp/disk.img)",
+		},
+		{
+			d: loop.Device{
+				Name:        "/dev/loop3",
+				BackingFile: "/boot/root.img",
+				Config: loop.Config{
+					Flags:     unix.LO_FLAGS_READ_ONLY | unix.LO_FLAGS_PARTSCAN,
+					Offset:    512,
+					SizeLimit: 1024,
+				},
+			},
+			want: "/dev/loop3: (/boot/root.img), offset 512, sizelimit 1024, read-only, partscan",
+		},
+	} {
+		if got := format(&tt.d); got != tt.want {
+			t.Errorf("format(%+v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// lsblk lists block devices as a tree.
+//
+// Synopsis:
+//
+//	lsblk [-abdfJlp] [-o COLUMNS] [DEVICE...]
+//
+// Description:
+//
+//	Partitions are shown below their disk and devices built on others,
+//	such as RAID arrays and device mapper targets, below each of their
+//	members. Empty devices, such as unused loop devices, are left out
+//	unless -a is given. With DEVICE arguments, only those devices and what is below
+//	them are shown.
+//
+//	COLUMNS is a comma separated list of NAME, KNAME, PATH, MAJ:MIN, RM,
+//	RO, SIZE, TYPE, FSTYPE, LABEL, UUID, PARTLABEL, PARTUUID, MODEL,
+//	SERIAL and MOUNTPOINT.
+//
+// Options:
+//
+//	-a:        also show empty devices
+//	-b:        print sizes in bytes
+//	-d:        do not show partitions and holders
+//	-f:        show file system columns
+//	-J:        print JSON
+//	-l:        print a list instead of a tree
+//	-o:        columns to print
+//	-p:        print full device paths
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var (
+	errColumn = errors.New("unknown column")
+	errDevice = errors.New("not a block device")
+
+	defaultColumns = "NAME,MAJ:MIN,RM,SIZE,RO,TYPE,MOUNTPOINT"
+	fsColumns      = "NAME,FSTYPE,LABEL,UUID,MOUNTPOINT"
+	allColumns     = "NAME,KNAME,PATH,MAJ:MIN,RM,RO,SIZE,TYPE,FSTYPE,LABEL,UUID,PARTLABEL,PARTUUID,MODEL,SERIAL,MOUNTPOINT"
+)
+
+// rightAligned columns are numbers.
+var rightAligned = map[string]bool{"MAJ:MIN": true, "RM": true, "RO": true, "SIZE": true}
+
+// node is a block device in the tree.
+type node struct {
+	*block.Attrs
+	fs         *block.FSInfo
+	mountpoint string
+	children   []*node
+}
+
+type cmd struct {
+	stdout  io.Writer
+	all     bool
+	bytes   bool
+	nodeps  bool
+	json    bool
+	list    bool
+	paths   bool
+	columns []string
+	devices []string
+
+	// attrs, probe and mounts read the system state.
+	attrs  func() ([]*block.Attrs, error)
+	probe  func(name string) *block.FSInfo
+	mounts func() map[string]string
+}
+
+func probe(name string) *block.FSInfo {
+	fs, err := (&block.BlockDev{Name: name}).Probe()
+	if err != nil {
+		return nil
+	}
+	return fs
+}
+
+// unescape undoes the octal escapes of /proc/mounts.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// mounts maps device names to their first mount point.
+func mounts() map[string]string {
+	m := map[string]string{}
+	f, err := os.Open(block.LinuxMountsPath)
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		// Resolve names such as /dev/mapper/root or /dev/root.
+		dev := fields[0]
+		if p, err := filepath.EvalSymlinks(dev); err == nil {
+			dev = p
+		}
+		if _, ok := m[filepath.Base(dev)]; !ok {
+			m[filepath.Base(dev)] = unescape(fields[1])
+		}
+	}
+	return m
+}
+
+// humanSize formats a size like lsblk: 1024 based, with one decimal
+// place if that is not zero.
+func humanSize(n uint64) string {
+	const units = "BKMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f := float64(n)
+	u := 0
+	for f >= 1024 && u < len(units)-1 {
+		f /= 1024
+		u++
+	}
+	s := strconv.FormatFloat(f, 'f', 1, 64)
+	return strings.TrimSuffix(s, ".0") + units[u:u+1]
+}
+
+// value returns the column of n. Its second result is false for values
+// that are unset, which are null in JSON.
+func (c *cmd) value(n *node, col string) (interface{}, bool) {
+	str := func(s string) (interface{}, bool) { return s, s != "" }
+	switch col {
+	case "NAME":
+		if c.paths {
+			return "/dev/" + n.Name, true
+		}
+		return n.Name, true
+	case "KNAME":
+		return n.Name, true
+	case "PATH":
+		return "/dev/" + n.Name, true
+	case "MAJ:MIN":
+		return fmt.Sprintf("%d:%d", n.Major, n.Minor), true
+	case "RM":
+		return n.Removable, true
+	case "RO":
+		return n.ReadOnly, true
+	case "SIZE":
+		if c.bytes {
+			return n.Size, true
+		}
+		return humanSize(n.Size), true
+	case "TYPE":
+		return str(n.Type)
+	case "MODEL":
+		return str(n.Model)
+	case "SERIAL":
+		return str(n.Serial)
+	case "PARTLABEL":
+		return str(n.PartLabel)
+	case "PARTUUID":
+		return str(n.PartUUID)
+	case "MOUNTPOINT":
+		return str(n.mountpoint)
+	}
+	if n.fs == nil {
+		return "", false
+	}
+	switch col {
+	case "FSTYPE":
+		return str(n.fs.Type)
+	case "LABEL":
+		return str(n.fs.Label)
+	case "UUID":
+		return str(n.fs.UUID)
+	}
+	return "", false
+}
+
+// text returns the column of n as printed in tables.
+func (c *cmd) text(n *node, col string) string {
+	v, ok := c.value(n, col)
+	if !ok {
+		return ""
+	}
+	if b, ok := v.(bool); ok {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(v)
+}
+
+// tree links the devices and returns the roots to print.
+func (c *cmd) tree(attrs []*block.Attrs) ([]*node, error) {
+	nodes := map[string]*node{}
+	for _, a := range attrs {
+		nodes[a.Name] = &node{Attrs: a}
+	}
+	needFS := false
+	for _, col := range c.columns {
+		if col == "FSTYPE" || col == "LABEL" || col == "UUID" {
+			needFS = true
+		}
+	}
+	m := c.mounts()
+	for _, a := range attrs {
+		n := nodes[a.Name]
+		n.mountpoint = m[a.Name]
+		if needFS {
+			n.fs = c.probe(a.Name)
+		}
+		if c.nodeps {
+			continue
+		}
+		for _, name := range append(append([]string{}, a.Partitions...), a.Holders...) {
+			if child, ok := nodes[name]; ok {
+				n.children = append(n.children, child)
+			}
+		}
+	}
+
+	var roots []*node
+	if len(c.devices) != 0 {
+		for _, d := range c.devices {
+			n, ok := nodes[filepath.Base(d)]
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", d, errDevice)
+			}
+			roots = append(roots, n)
+		}
+		return roots, nil
+	}
+	for _, a := range attrs {
+		if a.Type == "part" || len(a.Slaves) != 0 || (a.Size == 0 && !c.all) {
+			continue
+		}
+		roots = append(roots, nodes[a.Name])
+	}
+	return roots, nil
+}
+
+// row is a printed line of the table.
+type row struct {
+	prefix string
+	n      *node
+}
+
+func (c *cmd) rows(nodes []*node, prefix string, rows []row, depth int) []row {
+	for i, n := range nodes {
+		last := i == len(nodes)-1
+		branch, cont := "├─", "│ "
+		if last {
+			branch, cont = "└─", "  "
+		}
+		switch {
+		case c.list:
+			rows = append(rows, row{n: n})
+		case depth == 0:
+			rows = append(rows, row{n: n})
+			cont = ""
+		default:
+			rows = append(rows, row{prefix: prefix + branch, n: n})
+		}
+		// Device mapper and md devices may be stacked, but never
+		// deeper than this.
+		if depth < 16 {
+			rows = c.rows(n.children, prefix+cont, rows, depth+1)
+		}
+	}
+	return rows
+}
+
+func (c *cmd) printTable(roots []*node) {
+	rows := c.rows(roots, "", nil, 0)
+	cells := make([][]string, len(rows)+1)
+	cells[0] = c.columns
+	for i, r := range rows {
+		for _, col := range c.columns {
+			s := c.text(r.n, col)
+			if col == "NAME" {
+				s = r.prefix + s
+			}
+			cells[i+1] = append(cells[i+1], s)
+		}
+	}
+	width := make([]int, len(c.columns))
+	for _, line := range cells {
+		for j, s := range line {
+			if l := len([]rune(s)); l > width[j] {
+				width[j] = l
+			}
+		}
+	}
+	for _, line := range cells {
+		var b strings.Builder
+		for j, s := range line {
+			pad := strings.Repeat(" ", width[j]-len([]rune(s)))
+			switch {
+			case rightAligned[c.columns[j]]:
+				b.WriteString(pad + s)
+			case j == len(line)-1:
+				b.WriteString(s)
+			default:
+				b.WriteString(s + pad)
+			}
+			if j != len(line)-1 {
+				b.WriteByte(' ')
+			}
+		}
+		fmt.Fprintln(c.stdout, strings.TrimRight(b.String(), " "))
+	}
+}
+
+func (c *cmd) jsonNode(b *bytes.Buffer, n *node, depth int) error {
+	b.WriteByte('{')
+	for i, col := range c.columns {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(strings.ToLower(col))
+		b.Write(k)
+		b.WriteByte(':')
+		v, ok := c.value(n, col)
+		if !ok {
+			b.WriteString("null")
+			continue
+		}
+		j, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(j)
+	}
+	if len(n.children) != 0 && !c.list && depth < 16 {
+		b.WriteString(`,"children":[`)
+		for i, child := range n.children {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			if err := c.jsonNode(b, child, depth+1); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	}
+	b.WriteByte('}')
+	return nil
+}
+
+func (c *cmd) printJSON(roots []*node) error {
+	if c.list {
+		var nodes []*node
+		for _, r := range c.rows(roots, "", nil, 0) {
+			nodes = append(nodes, r.n)
+		}
+		roots = nodes
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"blockdevices":[`)
+	for i, n := range roots {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		if err := c.jsonNode(&b, n, 0); err != nil {
+			return err
+		}
+	}
+	b.WriteString("]}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b.Bytes(), "", "   "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(c.stdout)
+	return err
+}
+
+func (c *cmd) run() error {
+	attrs, err := c.attrs()
+	if err != nil {
+		return err
+	}
+	roots, err := c.tree(attrs)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(roots)
+	}
+	c.printTable(roots)
+	return nil
+}
+
+func command(stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, attrs: block.ListAttrs, probe: probe, mounts: mounts}
+	var fs bool
+	var columns string
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&c.all, "a", false, "also show empty devices")
+	f.BoolVar(&c.bytes, "b", false, "print sizes in bytes")
+	f.BoolVar(&c.nodeps, "d", false, "do not show partitions and holders")
+	f.BoolVar(&fs, "f", false, "show file system columns")
+	f.BoolVar(&c.json, "J", false, "print JSON")
+	f.BoolVar(&c.list, "l", false, "print a list instead of a tree")
+	f.StringVar(&columns, "o", "", "columns to print")
+	f.BoolVar(&c.paths, "p", false, "print full device paths")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return nil, err
+	}
+	c.devices = f.Args()
+
+	switch {
+	case columns != "":
+	case fs:
+		columns = fsColumns
+	default:
+		columns = defaultColumns
+	}
+	for _, col := range strings.Split(columns, ",") {
+		col = strings.ToUpper(strings.TrimSpace(col))
+		if !strings.Contains(","+allColumns+",", ","+col+",") {
+			return nil, fmt.Errorf("%w: %q", errColumn, col)
+		}
+		c.columns = append(c.columns, col)
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
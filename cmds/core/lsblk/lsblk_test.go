@@ -0,0 +1,165 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+func testSystem(c *cmd) {
+	c.attrs = func() ([]*block.Attrs, error) {
+		return []*block.Attrs{
+			{Name: "loop0", Major: 7, Type: "loop"},
+			{Name: "sda", Major: 8, Size: 20 << 30, Type: "disk", Model: "QEMU HARDDISK", Partitions: []string{"sda1", "sda2"}},
+			{Name: "sda1", Major: 8, Minor: 1, Size: 512 << 20, Type: "part", PartLabel: "EFI System"},
+			{Name: "sda2", Major: 8, Minor: 2, Size: 19<<30 + 512<<20, Type: "part", Holders: []string{"md0"}},
+			{Name: "sdb", Major: 8, Minor: 16, Size: 20 << 30, Type: "disk", Removable: true, Partitions: []string{"sdb1"}},
+			{Name: "sdb1", Major: 8, Minor: 17, Size: 20 << 30, Type: "part", Holders: []string{"md0"}},
+			{Name: "md0", Major: 9, Size: 19 << 30, Type: "raid1", Slaves: []string{"sda2", "sdb1"}},
+		}, nil
+	}
+	c.probe = func(name string) *block.FSInfo {
+		switch name {
+		case "sda1":
+			return &block.FSInfo{Type: "vfat", UUID: "0BAD-CAFE", Label: "EFI"}
+		case "md0":
+			return &block.FSInfo{Type: "ext4", UUID: "51820b9c-d640-4c8c-8597-188689253e69"}
+		}
+		return nil
+	}
+	c.mounts = func() map[string]string {
+		return map[string]string{"md0": "/", "sda1": "/boot/efi"}
+	}
+}
+
+func TestLsblk(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "tree",
+			want: `NAME    MAJ:MIN RM  SIZE RO TYPE  MOUNTPOINT
+sda         8:0  0   20G  0 disk
+├─sda1      8:1  0  512M  0 part  /boot/efi
+└─sda2      8:2  0 19.5G  0 part
+  └─md0     9:0  0   19G  0 raid1 /
+sdb        8:16  1   20G  0 disk
+└─sdb1     8:17  0   20G  0 part
+  └─md0     9:0  0   19G  0 raid1 /
+`,
+		},
+		{
+			name: "file systems of one device",
+			args: []string{"-f", "-p", "/dev/sda"},
+			want: `NAME         FSTYPE LABEL UUID                                 MOUNTPOINT
+/dev/sda
+├─/dev/sda1  vfat   EFI   0BAD-CAFE                            /boot/efi
+└─/dev/sda2
+  └─/dev/md0 ext4         51820b9c-d640-4c8c-8597-188689253e69 /
+`,
+		},
+		{
+			name: "disks",
+			args: []string{"-d", "-a", "-b", "-o", "name,size,model"},
+			want: `NAME         SIZE MODEL
+loop0           0
+sda   21474836480 QEMU HARDDISK
+sdb   21474836480
+`,
+		},
+		{
+			name: "list",
+			args: []string{"-l", "-o", "NAME,PARTLABEL", "sda"},
+			want: `NAME PARTLABEL
+sda
+sda1 EFI System
+sda2
+md0
+`,
+		},
+		{
+			name: "json",
+			args: []string{"-J", "-o", "name,rm,fstype", "sdb"},
+			want: `{
+   "blockdevices": [
+      {
+         "name": "sdb",
+         "rm": true,
+         "fstype": null,
+         "children": [
+            {
+               "name": "sdb1",
+               "rm": false,
+               "fstype": null,
+               "children": [
+                  {
+                     "name": "md0",
+                     "rm": false,
+                     "fstype": "ext4"
+                  }
+               ]
+            }
+         ]
+      }
+   ]
+}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			c, err := command(&stdout, &stderr, append([]string{"lsblk"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testSystem(c)
+			if err := c.run(); err != nil {
+				t.Fatalf("run() = %v", err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("run() printed\n%s\nwant\n%s", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLsblkErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if _, err := command(&stdout, &stderr, []string{"lsblk", "-o", "NAME,COLOR"}); !errors.Is(err, errColumn) {
+		t.Errorf("command(-o NAME,COLOR) = %v, want %v", err, errColumn)
+	}
+	c, err := command(&stdout, &stderr, []string{"lsblk", "nvme0n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSystem(c)
+	if err := c.run(); !errors.Is(err, errDevice) {
+		t.Errorf("run(nvme0n1) = %v, want %v", err, errDevice)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for _, tt := range []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1K"},
+		{1536, "1.5K"},
+		{256 << 30, "256G"},
+		{500107862016, "465.8G"},
+	} {
+		if got := humanSize(tt.n); got != tt.want {
+			t.Errorf("humanSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -10,10 +10,25 @@
 // Synopsis:
 //
 //	mount [-r] [-o options] [-t FSTYPE] DEV PATH
+//	mount --bind|--rbind|--move OLDDIR NEWDIR
+//	mount -o remount[,options] PATH
+//
+// Description:
+//
+//	With -o loop, DEV is a file image that is attached to a free loop
+//	device first. The loop device is released when the file system is
+//	unmounted.
+//
+//	Bind, rbind and move mounts and remounts need no file system type.
+//	A read-only bind mount is remounted read-only after binding, as
+//	the kernel ignores the read-only flag of the bind itself.
 //
 // Options:
 //
-//	-r: read only
+//	-r:      read only
+//	--bind:  same as -o bind
+//	--rbind: same as -o rbind
+//	--move:  same as -o move
 package main
 
 import (
@@ -44,6 +59,9 @@ func (o *mountOptions) Set(value string) error {
 var (
 	ro      = flag.Bool("r", false, "Read only mount")
 	fsType  = flag.String("t", "", "File system type")
+	bind    = flag.Bool("bind", false, "Bind mount a directory")
+	rbind   = flag.Bool("rbind", false, "Recursively bind mount a directory")
+	move    = flag.Bool("move", false, "Move a mount to another directory")
 	options mountOptions
 )
 
@@ -51,15 +69,52 @@ func init() {
 	flag.Var(&options, "o", "Comma separated list of mount options")
 }
 
-func loopSetup(filename string) (loopDevice string, err error) {
-	loopDevice, err = loop.FindDevice()
-	if err != nil {
-		return "", err
+// mountFlags are the parsed mount options.
+type mountFlags struct {
+	flags uintptr
+	data  []string
+	loop  bool
+}
+
+// parseOptions splits options into mount flags and file system data.
+func parseOptions(options []string) mountFlags {
+	var m mountFlags
+	for _, option := range options {
+		switch option {
+		case "loop":
+			m.loop = true
+		case "ro":
+			m.flags |= unix.MS_RDONLY
+		case "rw":
+			m.flags &^= unix.MS_RDONLY
+		case "rbind":
+			m.flags |= unix.MS_BIND | unix.MS_REC
+		case "defaults", "":
+		default:
+			if f, ok := opts[option]; ok {
+				m.flags |= f
+			} else {
+				m.data = append(m.data, option)
+			}
+		}
 	}
-	if err := loop.SetFile(loopDevice, filename); err != nil {
-		return "", err
+	return m
+}
+
+// needsFSType reports whether a mount with flags creates a new file system
+// mount rather than changing an existing one.
+func needsFSType(flags uintptr) bool {
+	return flags&(unix.MS_BIND|unix.MS_MOVE|unix.MS_REMOUNT|unix.MS_SHARED|unix.MS_PRIVATE|unix.MS_SLAVE|unix.MS_UNBINDABLE) == 0
+}
+
+// loopSetup attaches filename to a loop device that is released once the
+// returned file is closed and the file system on it is unmounted.
+func loopSetup(filename string, flags uintptr) (*os.File, error) {
+	c := loop.Config{Flags: unix.LO_FLAGS_AUTOCLEAR}
+	if flags&unix.MS_RDONLY != 0 {
+		c.Flags |= unix.LO_FLAGS_READ_ONLY
 	}
-	return loopDevice, nil
+	return loop.Attach(filename, c)
 }
 
 // extended from boot.go
@@ -108,40 +163,58 @@ func main() {
 		log.Fatalf("Could not read %s to get namespace", n)
 	}
 	flag.Parse()
-	if len(flag.Args()) < 2 {
+	m := parseOptions(options)
+	if *ro {
+		m.flags |= unix.MS_RDONLY
+	}
+	switch {
+	case *bind:
+		m.flags |= unix.MS_BIND
+	case *rbind:
+		m.flags |= unix.MS_BIND | unix.MS_REC
+	case *move:
+		m.flags |= unix.MS_MOVE
+	}
+	a := flag.Args()
+	if len(a) == 1 && m.flags&unix.MS_REMOUNT != 0 {
+		// Remounts only name the mount point.
+		a = []string{"none", a[0]}
+	}
+	if len(a) != 2 {
 		flag.Usage()
 		os.Exit(1)
 	}
-	a := flag.Args()
-	dev := a[0]
-	path := a[1]
-	var flags uintptr
-	var data []string
-	var err error
-	for _, option := range options {
-		switch option {
-		case "loop":
-			dev, err = loopSetup(dev)
-			if err != nil {
-				log.Fatal("Error setting loop device:", err)
-			}
-		default:
-			if f, ok := opts[option]; ok {
-				flags |= f
-			} else {
-				data = append(data, option)
+	dev, path := a[0], a[1]
+	data := strings.Join(m.data, ",")
+
+	if !needsFSType(m.flags) {
+		if _, err := mount.Mount(dev, path, *fsType, data, m.flags); err != nil {
+			log.Fatal(err)
+		}
+		if m.flags&(unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY) == unix.MS_BIND|unix.MS_RDONLY {
+			if _, err := mount.Mount("none", path, "", "", m.flags|unix.MS_REMOUNT); err != nil {
+				log.Fatal(err)
 			}
 		}
+		return
 	}
-	if *ro {
-		flags |= unix.MS_RDONLY
+
+	if m.loop {
+		device, err := loopSetup(dev, m.flags)
+		if err != nil {
+			log.Fatal("Error setting loop device:", err)
+		}
+		// The device is kept open until it is mounted, or it would be
+		// released right away.
+		defer device.Close()
+		dev = device.Name()
 	}
 	if *fsType == "" {
-		if _, err := mount.TryMount(dev, path, strings.Join(data, ","), flags); err != nil {
+		if _, err := mount.TryMount(dev, path, data, m.flags); err != nil {
 			log.Fatalf("%v", err)
 		}
 	} else {
-		if _, err := mount.Mount(dev, path, *fsType, strings.Join(data, ","), flags); err != nil {
+		if _, err := mount.Mount(dev, path, *fsType, data, m.flags); err != nil {
 			log.Printf("%v", err)
 			informIfUnknownFS(*fsType)
 			os.Exit(1)
@@ -0,0 +1,62 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !tinygo
+// +build !tinygo
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseOptions(t *testing.T) {
+	for _, tt := range []struct {
+		options []string
+		want    mountFlags
+		fstype  bool
+	}{
+		{
+			options: []string{"loop", "ro", "uid=0"},
+			want:    mountFlags{flags: unix.MS_RDONLY, data: []string{"uid=0"}, loop: true},
+			fstype:  true,
+		},
+		{
+			options: []string{"ro", "rw", "nosuid", "defaults"},
+			want:    mountFlags{flags: unix.MS_NOSUID},
+			fstype:  true,
+		},
+		{
+			options: []string{"bind", "ro"},
+			want:    mountFlags{flags: unix.MS_BIND | unix.MS_RDONLY},
+		},
+		{
+			options: []string{"rbind"},
+			want:    mountFlags{flags: unix.MS_BIND | unix.MS_REC},
+		},
+		{
+			options: []string{"move"},
+			want:    mountFlags{flags: unix.MS_MOVE},
+		},
+		{
+			options: []string{"remount", "ro"},
+			want:    mountFlags{flags: unix.MS_REMOUNT | unix.MS_RDONLY},
+		},
+		{
+			options: []string{"private"},
+			want:    mountFlags{flags: unix.MS_PRIVATE},
+		},
+	} {
+		got := parseOptions(tt.options)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOptions(%q) = %+v, want %+v", tt.options, got, tt.want)
+		}
+		if fstype := needsFSType(got.flags); fstype != tt.fstype {
+			t.Errorf("needsFSType(%q) = %v, want %v", tt.options, fstype, tt.fstype)
+		}
+	}
+}
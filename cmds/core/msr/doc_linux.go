@@ -8,6 +8,8 @@
 //
 //	msr [OPTIONS] r glob MSR
 //	msr [OPTIONS] w glob MSR value
+//	msr [OPTIONS] d glob MSR
+//	msr list
 //	msr [OPTIONS] forth-word [forth-word ...]
 //
 // Description:
@@ -81,5 +83,19 @@
 // rminnich@xcpu:~/gopath/src/github.com/u-root/u-root/cmds/core/msr$ sudo ./msr w 0 0x3a 5
 // [5]
 //
+// Registers can be given by number or by name, e.g. IA32_FEATURE_CONTROL;
+// the IA32_ and MSR_ prefixes may be left out. msr list shows the known
+// names. For those registers, d shows the value on each core with its
+// fields decoded:
+//
+//	sudo msr d 0 PLATFORM_INFO
+//	cpu 0: MSR_PLATFORM_INFO (0xce): 0x0008080010001800
+//		[15:8] Maximum non-turbo ratio: 24 (2400 MHz)
+//		...
+//
+// MSRs of all cores are read and written in parallel. With -v, every
+// write is read back and cores on which the MSR does not hold the value
+// written, e.g. because it is locked, are reported.
+//
 // For a view of what Forth is doing, run with -d.
 package main
@@ -57,43 +57,44 @@ import (
 // let's just do MSRs for now
 
 var (
-	debug = flag.Bool("d", false, "debug messages")
-	words = []struct {
+	debug  = flag.Bool("d", false, "debug messages")
+	verify = flag.Bool("v", false, "read MSRs back after writing them and report those that differ")
+	words  = []struct {
 		name string
 		w    []forth.Cell
 	}{
 		// Architectural MSR. All systems.
 		// Enables features like VMX.
-		{name: "MSR_IA32_FEATURE_CONTROL", w: []forth.Cell{"'*", "cpu", "0x3a", "reg"}},
+		{name: "MSR_IA32_FEATURE_CONTROL", w: []forth.Cell{"'*", "cpu", "'IA32_FEATURE_CONTROL", "reg"}},
 		{name: "READ_MSR_IA32_FEATURE_CONTROL", w: []forth.Cell{"MSR_IA32_FEATURE_CONTROL", "rd"}},
 		{name: "LOCK_MSR_IA32_FEATURE_CONTROL", w: []forth.Cell{"MSR_IA32_FEATURE_CONTROL", "READ_MSR_IA32_FEATURE_CONTROL", "1", "u64", "or", "wr"}},
 		// Silvermont, Airmont, Nehalem...
 		// Controls Processor C States.
-		{name: "MSR_PKG_CST_CONFIG_CONTROL", w: []forth.Cell{"'*", "cpu", "0xe2", "reg"}},
+		{name: "MSR_PKG_CST_CONFIG_CONTROL", w: []forth.Cell{"'*", "cpu", "'MSR_PKG_CST_CONFIG_CONTROL", "reg"}},
 		{name: "READ_MSR_PKG_CST_CONFIG_CONTROL", w: []forth.Cell{"MSR_PKG_CST_CONFIG_CONTROL", "rd"}},
 		{name: "LOCK_MSR_PKG_CST_CONFIG_CONTROL", w: []forth.Cell{"MSR_PKG_CST_CONFIG_CONTROL", "READ_MSR_PKG_CST_CONFIG_CONTROL", uint64(1 << 15), "or", "wr"}},
 		// Westmere onwards.
 		// Note that this turns on AES instructions, however
 		// 3 will turn off AES until reset.
-		{name: "MSR_FEATURE_CONFIG", w: []forth.Cell{"'*", "cpu", "0x13c", "reg"}},
+		{name: "MSR_FEATURE_CONFIG", w: []forth.Cell{"'*", "cpu", "'MSR_FEATURE_CONFIG", "reg"}},
 		{name: "READ_MSR_FEATURE_CONFIG", w: []forth.Cell{"MSR_FEATURE_CONFIG", "rd"}},
 		{name: "LOCK_MSR_FEATURE_CONFIG", w: []forth.Cell{"MSR_FEATURE_CONFIG", "READ_MSR_FEATURE_CONFIG", uint64(1 << 0), "or", "wr"}},
 		// Goldmont, SandyBridge
 		// Controls DRAM power limits. See Intel SDM
-		{name: "MSR_DRAM_POWER_LIMIT", w: []forth.Cell{"'*", "cpu", "0x618", "reg"}},
+		{name: "MSR_DRAM_POWER_LIMIT", w: []forth.Cell{"'*", "cpu", "'MSR_DRAM_POWER_LIMIT", "reg"}},
 		{name: "READ_MSR_DRAM_POWER_LIMIT", w: []forth.Cell{"MSR_DRAM_POWER_LIMIT", "rd"}},
 		{name: "LOCK_MSR_DRAM_POWER_LIMIT", w: []forth.Cell{"MSR_DRAM_POWER_LIMIT", "READ_MSR_DRAM_POWER_LIMIT", uint64(1 << 31), "or", "wr"}},
 		// IvyBridge Onwards.
 		// Not much information in the SDM, seems to control power limits
-		{name: "MSR_CONFIG_TDP_CONTROL", w: []forth.Cell{"'*", "cpu", "0xe2", "reg"}},
+		{name: "MSR_CONFIG_TDP_CONTROL", w: []forth.Cell{"'*", "cpu", "'MSR_CONFIG_TDP_CONTROL", "reg"}},
 		{name: "READ_MSR_CONFIG_TDP_CONTROL", w: []forth.Cell{"MSR_CONFIG_TDP_CONTROL", "rd"}},
 		{name: "LOCK_MSR_CONFIG_TDP_CONTROL", w: []forth.Cell{"MSR_CONFIG_TDP_CONTROL", "READ_MSR_CONFIG_TDP_CONTROL", uint64(1 << 31), "or", "wr"}},
 		// Architectural MSR. All systems.
 		// This is the actual spelling of the MSR in the manual.
 		// Controls availability of silicon debug interfaces
-		{name: "IA32_DEBUG_INTERFACE", w: []forth.Cell{"'*", "cpu", "0xe2", "reg"}},
+		{name: "IA32_DEBUG_INTERFACE", w: []forth.Cell{"'*", "cpu", "'IA32_DEBUG_INTERFACE", "reg"}},
 		{name: "READ_IA32_DEBUG_INTERFACE", w: []forth.Cell{"IA32_DEBUG_INTERFACE", "rd"}},
-		{name: "LOCK_IA32_DEBUG_INTERFACE", w: []forth.Cell{"IA32_DEBUG_INTERFACE", "READ_IA32_DEBUG_INTERFACE", uint64(1 << 15), "or", "wr"}},
+		{name: "LOCK_IA32_DEBUG_INTERFACE", w: []forth.Cell{"IA32_DEBUG_INTERFACE", "READ_IA32_DEBUG_INTERFACE", uint64(1 << 30), "or", "wr"}},
 		// Locks all known msrs to lock
 		{name: "LOCK_KNOWN_MSRS", w: []forth.Cell{"LOCK_MSR_IA32_FEATURE_CONTROL", "LOCK_MSR_PKG_CST_CONFIG_CONTROL", "LOCK_MSR_FEATURE_CONFIG", "LOCK_MSR_DRAM_POWER_LIMIT", "LOCK_MSR_CONFIG_TDP_CONTROL", "LOCK_IA32_DEBUG_INTERFACE"}},
 	}
@@ -132,12 +133,13 @@ func cpus(f forth.Forth) {
 	f.Push(c)
 }
 
+// reg accepts a number or the name of a register, e.g. IA32_FEATURE_CONTROL.
 func reg(f forth.Forth) {
-	n, err := strconv.ParseUint(f.Pop().(string), 0, 32)
+	m, err := msr.ParseMSR(f.Pop().(string))
 	if err != nil {
 		panic(fmt.Sprintf("%v", err))
 	}
-	f.Push(msr.MSR(n))
+	f.Push(m)
 }
 
 func u64(f forth.Forth) {
@@ -174,13 +176,22 @@ func wr(f forth.Forth) {
 	r := f.Pop().(msr.MSR)
 	c := f.Pop().(msr.CPUs)
 	forth.Debug("wr: cpus %v, msr %v, values %v", c, r, v)
-	errs := r.Write(c, v...)
+	errs := write(r, c, v...)
 	forth.Debug("errs %v", errs)
 	if errs != nil {
 		f.Push(errs)
 	}
 }
 
+// write writes to the MSRs of all CPUs, in parallel, and with -v
+// reads them back.
+func write(r msr.MSR, c msr.CPUs, v ...uint64) []error {
+	if *verify {
+		return r.WriteVerify(c, v...)
+	}
+	return r.Write(c, v...)
+}
+
 // We had been counting on doing a rd, which would produce a nice
 // []u64 at TOS which we could use in a write. Problem is, some MSRs
 // can not be read. There are write-only MSRs.  This really
@@ -194,7 +205,7 @@ func swr(f forth.Forth) {
 	c := f.Pop().(msr.CPUs)
 
 	forth.Debug("swr: cpus %v, msr %v, %v", c, r, v)
-	errs := r.Write(c, v)
+	errs := write(r, c, v)
 	forth.Debug("errs %v", errs)
 	if errs != nil {
 		f.Push(errs)
@@ -221,6 +232,30 @@ func or(f forth.Forth) {
 	f.Push(m)
 }
 
+// decode prints the value of a register on each CPU with its fields.
+func decode(glob, register string) error {
+	m, err := msr.ParseMSR(register)
+	if err != nil {
+		return err
+	}
+	c, errs := msr.GlobCPUs(glob)
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	vals, errs := m.Read(c)
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	r, ok := msr.RegisterAt(m)
+	if !ok {
+		r = msr.Register{Name: "MSR", Addr: m}
+	}
+	for i, v := range vals {
+		fmt.Printf("cpu %d: %s", c[i], r.Decode(v))
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	if *debug {
@@ -242,6 +277,9 @@ func main() {
 		forth.NewWord(f, w.name, w.w[0], w.w[1:]...)
 	}
 	a := flag.Args()
+	if len(a) == 0 {
+		log.Fatal("Usage: msr [-d] [-v] r|w|d|lock|list ... or msr forth-word ...")
+	}
 	// If the first arg is r or w, we're going to assume they're not doing Forth.
 	// It is too confusing otherwise if they type a wrong r or w command and
 	// see the Forth stack and nothing else.
@@ -252,7 +290,7 @@ func main() {
 		}
 		// Because the msr arg is a glob and may have things like * in it (* being the
 		// most common) gratuitiously add a Forth ' before it (i.e. quote it).
-		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu %s reg rd", a[1], a[2])); err != nil {
+		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu '%s reg rd", a[1], a[2])); err != nil {
 			log.Fatal(err)
 		}
 	case "w":
@@ -261,16 +299,29 @@ func main() {
 		}
 		// Because the msr arg is a glob and may have things like * in it (* being the
 		// most common) gratuitiously add a Forth ' before it (i.e. quote it).
-		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu %s reg %s u64 swr", a[1], a[2], a[3])); err != nil {
+		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu '%s reg %s u64 swr", a[1], a[2], a[3])); err != nil {
 			log.Fatal(err)
 		}
 	case "lock":
 		if len(a) != 4 {
 			log.Fatal("Usage for lock: lock <msr-glob> <register> <bit>")
 		}
-		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu %s reg '%s msr %s reg rd %s u64 or wr", a[1], a[2], a[1], a[2], a[3])); err != nil {
+		if err := forth.EvalString(f, fmt.Sprintf("'%s cpu '%s reg '%s cpu '%s reg rd %s u64 or wr", a[1], a[2], a[1], a[2], a[3])); err != nil {
 			log.Fatal(err)
 		}
+	case "d":
+		if len(a) != 3 {
+			log.Fatal("Usage for d: d <msr-glob> <register>")
+		}
+		if err := decode(a[1], a[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "list":
+		for _, r := range msr.Registers {
+			fmt.Printf("%-28s %v\n", r.Name, r.Addr)
+		}
+		return
 	default:
 		for _, a := range flag.Args() {
 			if err := forth.EvalString(f, a); err != nil {
@@ -9,18 +9,26 @@
 //	mv SOURCE [-u] TARGET
 //	mv SOURCE... [-u] DIRECTORY
 //
+// Description:
+//
+//	Files moved to another file system are copied with their owners,
+//	modes, timestamps and extended attributes, sharing data or keeping
+//	holes as cp does, and then removed.
+//
 // Author:
 //
 //	Beletti (rhiguita@gmail.com)
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/u-root/u-root/pkg/cp"
 	"github.com/u-root/u-root/pkg/uroot/util"
 )
 
@@ -58,10 +66,20 @@ func moveFile(source string, dest string, update bool, noClobber bool) error {
 		}
 	}
 
-	if err := os.Rename(source, dest); err != nil {
+	err := os.Rename(source, dest)
+	if errors.Is(err, eXDev) {
+		return moveAcross(source, dest)
+	}
+	return err
+}
+
+// moveAcross moves source to dest on another file system.
+func moveAcross(source, dest string) error {
+	opts := cp.Options{NoFollowSymlinks: true, Preserve: true}
+	if err := opts.CopyTree(source, dest); err != nil {
 		return err
 	}
-	return nil
+	return os.RemoveAll(source)
 }
 
 func mv(files []string, update, noClobber, todir bool) error {
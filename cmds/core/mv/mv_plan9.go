@@ -0,0 +1,11 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "errors"
+
+// eXDev is never returned on Plan 9, where renames are within a
+// directory.
+var eXDev = errors.New("cross-device link")
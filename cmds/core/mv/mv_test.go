@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func setup(t *testing.T) string {
@@ -160,3 +161,39 @@ func TestMoveFile(t *testing.T) {
 		}
 	})
 }
+
+func TestMoveAcross(t *testing.T) {
+	d := setup(t)
+	src := filepath.Join(d, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(d, "hi2.txt"), filepath.Join(src, "sub", "hi2.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "sub", "hi2.txt"), 0o751); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "sub", "hi2.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(d, "dst")
+	if err := moveAcross(src, dst); err != nil {
+		t.Fatalf("moveAcross = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "sub", "hi2.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o751 || !fi.ModTime().Equal(old) {
+		t.Errorf("moved file has mode %v, modified %v, want %v, %v", fi.Mode(), fi.ModTime(), os.FileMode(0o751), old)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "sub", "hi2.txt")); err != nil || string(b) != "hi" {
+		t.Errorf("moved file = %q, %v, want %q", b, err, "hi")
+	}
+}
@@ -16,6 +16,16 @@
 //	-n: just show numbers
 //	-c: dump config space
 //	-s: specify glob for choosing devices.
+//	-i: use this pci.ids file, plain or gzipped, instead of the built-in one.
+//	-v: verbosity; 1 and up decode capabilities, including MSI-X, AER and SR-IOV.
+//
+// Arguments read or write config registers, like setpci:
+//
+//	REG[.WIDTH][=VALUE]
+//
+// REG is an offset, or a capability and an offset into it, e.g. CAP_MSIX+2
+// or ECAP_SRIOV+0x10. WIDTH is b, w, l (the default) or q for 8, 16, 32 or
+// 64 bits.
 package main
 
 import (
@@ -42,6 +52,7 @@ type cmd struct {
 	verbosity int
 	hexdump   int
 	readJSON  string
+	idsPath   string
 	flags     *flag.FlagSet
 }
 
@@ -65,12 +76,14 @@ func command(w io.Writer, args ...string) *cmd {
 	f.IntVar(&c.verbosity, "v", 0, "verbosity")
 	f.IntVar(&c.hexdump, "x", 0, "hexdump the config space")
 	f.StringVar(&c.readJSON, "J", "", "Read JSON in instead of /sys")
+	f.StringVar(&c.idsPath, "i", "", "Use this pci.ids file instead of the built-in one")
 	f.Parse(c.osargs)
 	c.args = f.Args()
 	return c
 }
 
 var format = map[int]string{
+	64: "%08x:%016x",
 	32: "%08x:%08x",
 	16: "%08x:%04x",
 	8:  "%08x:%02x",
@@ -107,6 +120,8 @@ func registers(d pci.Devices, cmds ...string) error {
 				err = errors.Join(err, fmt.Errorf("%v:bad size.%w", rs[1], strconv.ErrSyntax))
 				justCheck = true
 				continue
+			case "q":
+				s = 64
 			case "l":
 			case "w":
 				s = 16
@@ -117,42 +132,44 @@ func registers(d pci.Devices, cmds ...string) error {
 		if justCheck {
 			continue
 		}
-		reg, e := strconv.ParseUint(rs[0], 0, 16)
+		reg, e := pci.ParseRegister(rs[0])
 		if e != nil {
 			log.Printf("%v:%v. Due to this error no more commands will be issued", rs[0], e)
 			err = errors.Join(err, fmt.Errorf("%v:%w", rs[0], e))
 			justCheck = true
 			continue
 		}
-		if len(rv) == 1 {
-			v, e := d.ReadConfigRegister(int64(reg), int64(s))
-			if e != nil {
-				log.Printf("%v:%v. Due to this error no more commands will be issued", rv[0], e)
-				err = errors.Join(err, fmt.Errorf("%v:%w", c, e))
-				justCheck = true
-				continue
-			}
-			// Should this go in the package somewhere? Not sure.
-			for i := range v {
-				d[i].ExtraInfo = append(d[i].ExtraInfo, fmt.Sprintf(format[s], reg, v[i]))
-			}
-		}
+		var val uint64
 		if len(rv) == 2 {
-			val, e := strconv.ParseUint(rv[1], 0, s)
-			if e != nil {
+			if val, e = strconv.ParseUint(rv[1], 0, s); e != nil {
 				log.Printf("%v. Due to this error no more commands will be issued", e)
 				err = errors.Join(err, fmt.Errorf("%w", e))
 				justCheck = true
 				continue
 			}
-			if e := d.WriteConfigRegister(int64(reg), int64(s), val); e != nil {
-				log.Printf("%v:%v. Due to this error no more commands will be issued", rv[1], e)
-				err = errors.Join(err, fmt.Errorf("%v:%w", rv[1], e))
+		}
+		// Registers in capabilities may be at a different offset in
+		// every device.
+		for _, p := range d {
+			off, e := p.RegisterOffset(reg)
+			if e == nil {
+				if len(rv) == 1 {
+					var v uint64
+					if v, e = p.ReadConfigRegister(off, int64(s)); e == nil {
+						// Should this go in the package somewhere? Not sure.
+						p.ExtraInfo = append(p.ExtraInfo, fmt.Sprintf(format[s], off, v))
+					}
+				} else {
+					e = p.WriteConfigRegister(off, int64(s), val)
+				}
+			}
+			if e != nil {
+				log.Printf("%v:%v. Due to this error no more commands will be issued", c, e)
+				err = errors.Join(err, fmt.Errorf("%v:%w", c, e))
 				justCheck = true
-				continue
+				break
 			}
 		}
-
 	}
 	return err
 }
@@ -191,7 +208,13 @@ func (c *cmd) run() error {
 	}
 
 	if !c.numbers || c.dumpJSON {
-		d.SetVendorDeviceName(pci.IDs)
+		db := pci.DefaultIDs()
+		if c.idsPath != "" {
+			if db, err = pci.LoadIDs(c.idsPath); err != nil {
+				return err
+			}
+		}
+		d.SetNames(db)
 	}
 	if len(c.args) > 0 {
 		if err := registers(d, c.args...); err != nil {
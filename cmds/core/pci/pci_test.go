@@ -71,6 +71,11 @@ func TestRun(t *testing.T) {
 			name: "dumpJSON",
 			args: []string{"-J", "testdata/testfile1.json", "-j"},
 		},
+		{
+			name: "pci.ids not found",
+			args: []string{"-J", "testdata/testfile1.json", "-i", "testdata/pci.ids"},
+			err:  os.ErrNotExist,
+		},
 		{
 			name: "invoke registers",
 			args: []string{"examplearg"},
@@ -211,6 +216,25 @@ func TestRegisters(t *testing.T) {
 			cmds: []string{"0.w=10"},
 			err:  os.ErrNotExist,
 		},
+		{
+			name: "64-bit write",
+			devices: []*pci.PCI{
+				{
+					FullPath: dir,
+				},
+			},
+			cmds: []string{"0.q=0x7766554433221100"},
+		},
+		{
+			name: "capability not present",
+			devices: []*pci.PCI{
+				{
+					FullPath: dir,
+				},
+			},
+			cmds: []string{"ECAP_SRIOV+0x10.w"},
+			err:  pci.ErrNoCapability,
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			buf := &bytes.Buffer{}
@@ -10,17 +10,26 @@
 //
 // Description:
 //
-//	poweroff calls the kernel to power off the systems.
+//	poweroff asks u-root's init to stop all processes, unmount file
+//	systems and power off the system. Without u-root's init, it syncs and
+//	calls the kernel to power off the system.
 package main
 
 import (
+	"errors"
 	"log"
 
+	"github.com/u-root/u-root/pkg/libinit"
 	"golang.org/x/sys/unix"
 )
 
 func main() {
-	if err := unix.Reboot(unix.LINUX_REBOOT_CMD_POWER_OFF); err != nil {
+	err := libinit.RequestShutdown(libinit.PowerOff)
+	if errors.Is(err, libinit.ErrNoInit) {
+		unix.Sync()
+		err = unix.Reboot(unix.LINUX_REBOOT_CMD_POWER_OFF)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
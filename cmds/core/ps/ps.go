@@ -9,7 +9,7 @@
 //
 // Synopsis:
 //
-//	ps [-Aaex] [aux]
+//	ps [-AaeLx] [-o format] [aux]
 //
 // Description:
 //
@@ -23,6 +23,10 @@
 //	 -e: select all processes. Identical to -A.
 //	 -x: BSD-Like style, with STAT Column and long CommandLine
 //	 -a: print all process except whose are session leaders or unlinked with terminal
+//	 -L: print threads, with an LWP column
+//	 -o: print the comma separated columns, of:
+//	     pid, lwp, nlwp, ppid, pgrp, sid, tty, stat, time, vsz, rss, swap,
+//	     rchar, wchar, rbytes, wbytes, cgroup, cmd
 //	aux: see every process on the system using BSD syntax
 package main
 
@@ -33,6 +37,7 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
@@ -43,6 +48,8 @@ var (
 	every   bool
 	x       bool
 	nSidTty bool
+	threads bool
+	format  string
 	aux     = false
 )
 
@@ -60,6 +67,36 @@ func usage() {
 	flag.Usage()
 }
 
+// column is a column of -o.
+type column struct {
+	header string
+	field  string // field of process
+	left   bool   // left aligned
+}
+
+// columns are the columns -o can print. Memory sizes are in KiB, IO in
+// bytes.
+var columns = map[string]column{
+	"pid":    {header: "PID", field: "Pid"},
+	"lwp":    {header: "LWP", field: "Lwp"},
+	"nlwp":   {header: "NLWP", field: "NumThreads"},
+	"ppid":   {header: "PPID", field: "Ppid"},
+	"pgrp":   {header: "PGRP", field: "Pgrp"},
+	"sid":    {header: "SID", field: "Sid"},
+	"tty":    {header: "TTY", field: "Ctty", left: true},
+	"stat":   {header: "STAT", field: "State", left: true},
+	"time":   {header: "TIME", field: "Time"},
+	"vsz":    {header: "VSZ", field: "VmSize"},
+	"rss":    {header: "RSS", field: "VmRSS"},
+	"swap":   {header: "SWAP", field: "VmSwap"},
+	"rchar":  {header: "RCHAR", field: "Rchar"},
+	"wchar":  {header: "WCHAR", field: "Wchar"},
+	"rbytes": {header: "RBYTES", field: "ReadBytes"},
+	"wbytes": {header: "WBYTES", field: "WriteBytes"},
+	"cgroup": {header: "CGROUP", field: "Cgroup", left: true},
+	"cmd":    {header: "CMD", field: "Cmd", left: true},
+}
+
 // ProcessTable holds all the information needed for ps
 type ProcessTable struct {
 	table   []*Process
@@ -81,6 +118,9 @@ func (pT ProcessTable) Len() int {
 
 // to use on sort.Sort
 func (pT ProcessTable) Less(i, j int) bool {
+	if pT.table[i].Pidno == pT.table[j].Pidno {
+		return pT.table[i].Tidno < pT.table[j].Tidno
+	}
 	return pT.table[i].Pidno < pT.table[j].Pidno
 }
 
@@ -146,7 +186,7 @@ func (pT *ProcessTable) PrepareString() {
 		TIME     = pT.MaxLength("Time")
 		CMD      = pT.MaxLength("Cmd")
 	)
-	for _, f := range pT.headers {
+	for i, f := range pT.headers {
 		switch f {
 		case "PID":
 			formated = fmt.Sprintf("%%%dv ", PID)
@@ -158,6 +198,21 @@ func (pT *ProcessTable) PrepareString() {
 			formated = fmt.Sprintf("%%%dv ", TIME)
 		case "CMD":
 			formated = fmt.Sprintf("%%-%dv ", CMD)
+		default:
+			for _, c := range columns {
+				if c.header != f {
+					continue
+				}
+				width := len(f)
+				if l := pT.MaxLength(pT.fields[i]); l > width {
+					width = l
+				}
+				if c.left {
+					formated = fmt.Sprintf("%%-%dv ", width)
+				} else {
+					formated = fmt.Sprintf("%%%dv ", width)
+				}
+			}
 		}
 		fstring = append(fstring, formated)
 	}
@@ -165,6 +220,20 @@ func (pT *ProcessTable) PrepareString() {
 	pT.fstring = fstring
 }
 
+// parseFormat returns the headers and fields of the -o columns.
+func parseFormat(format string) ([]string, []string, error) {
+	var headers, fields []string
+	for _, name := range strings.Split(format, ",") {
+		c, ok := columns[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown column %q", name)
+		}
+		headers = append(headers, c.header)
+		fields = append(fields, c.field)
+	}
+	return headers, fields, nil
+}
+
 // For now, just read /proc/pid/stat and dump its brains.
 func ps(w io.Writer, args ...string) error {
 	// The original ps was designed before many flag conventions existed.
@@ -180,17 +249,12 @@ func ps(w io.Writer, args ...string) error {
 		}
 	}
 	pT := NewProcessTable()
-	if err := pT.LoadTable(); err != nil {
-		return err
-	}
-
-	if pT.Len() == 0 {
-		return nil
-	}
-	// sorting ProcessTable by PID
-	sort.Sort(pT)
-
 	switch {
+	case format != "":
+		var err error
+		if pT.headers, pT.fields, err = parseFormat(format); err != nil {
+			return err
+		}
 	case aux:
 		pT.headers = []string{"PID", "PGRP", "SID", "TTY", "STAT", "TIME", "COMMAND"}
 		pT.fields = []string{"Pid", "Pgrp", "Sid", "Ctty", "State", "Time", "Cmd"}
@@ -201,6 +265,21 @@ func ps(w io.Writer, args ...string) error {
 		pT.headers = []string{"PID", "TTY", "TIME", "CMD"}
 		pT.fields = []string{"Pid", "Ctty", "Time", "Cmd"}
 	}
+	if threads && format == "" {
+		// Like procps, add the LWP after the PID.
+		pT.headers = append([]string{"PID", "LWP"}, pT.headers[1:]...)
+		pT.fields = append([]string{"Pid", "Lwp"}, pT.fields[1:]...)
+	}
+
+	if err := pT.LoadTable(); err != nil {
+		return err
+	}
+
+	if pT.Len() == 0 {
+		return nil
+	}
+	// sorting ProcessTable by PID
+	sort.Sort(pT)
 
 	pT.PrepareString()
 	pT.PrintHeader(w)
@@ -250,6 +329,12 @@ func main() {
 	f.BoolVar(&nSidTty, "anSIDTTY", false, "Print all process except whose are session leaders or unlinked with terminal")
 	f.BoolVar(&nSidTty, "a", false, "Print all process except whose are session leaders or unlinked with terminal (shorthand)")
 
+	f.BoolVar(&threads, "threads", false, "Print threads, with an LWP column")
+	f.BoolVar(&threads, "L", false, "Print threads, with an LWP column (shorthand)")
+
+	f.StringVar(&format, "format", "", "Print the comma separated columns, e.g. pid,rss,cgroup,cmd")
+	f.StringVar(&format, "o", "", "Print the comma separated columns, e.g. pid,rss,cgroup,cmd (shorthand)")
+
 	f.Parse(unixflag.OSArgsToGoArgs())
 	if err := ps(os.Stdout, f.Args()...); err != nil {
 		log.Fatal(err)
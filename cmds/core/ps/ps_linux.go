@@ -12,6 +12,8 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/u-root/u-root/pkg/proc"
 )
 
 const (
@@ -32,7 +34,10 @@ type Process struct {
 	status  string
 	cmdline string
 	stat    string
+	io      string
+	cgroup  string
 	Pidno   int // process id #
+	Tidno   int // thread id #, with -L
 	uid     int
 }
 
@@ -94,6 +99,15 @@ type process struct {
 	ExitCode    string // the thread's exit_code in the form reported by the waitpid system call (end of stat)
 	Ctty        string // extra member (don't parsed from stat)
 	Time        string // extra member (don't parsed from stat)
+	Lwp         string // extra member: thread id, with -L
+	VmSize      string // extra member: virtual memory size in KiB, from status
+	VmRSS       string // extra member: resident set size in KiB, from status
+	VmSwap      string // extra member: swapped out memory in KiB, from status
+	Rchar       string // extra member: bytes read, from io
+	Wchar       string // extra member: bytes written, from io
+	ReadBytes   string // extra member: bytes read from storage, from io
+	WriteBytes  string // extra member: bytes written to storage, from io
+	Cgroup      string // extra member: cgroup path
 }
 
 // Parse all content of stat to a Process Struct
@@ -126,9 +140,31 @@ func (p *Process) Parse() error {
 	if p.uid, err = p.GetUID(); err != nil {
 		return err
 	}
+	p.readExtra()
 	return nil
 }
 
+// readExtra fills in the memory, IO and cgroup members. The io file can
+// only be read by the owner of a process, so they may be empty.
+func (p *Process) readExtra() {
+	if st, err := proc.ParseStatus(p.status); err == nil {
+		p.VmSize = fmt.Sprint(st.Size >> 10)
+		p.VmRSS = fmt.Sprint(st.RSS >> 10)
+		p.VmSwap = fmt.Sprint(st.Swap >> 10)
+	}
+	p.Rchar, p.Wchar, p.ReadBytes, p.WriteBytes = "-", "-", "-", "-"
+	if io, err := proc.ParseIO(p.io); err == nil && p.io != "" {
+		p.Rchar = fmt.Sprint(io.RChar)
+		p.Wchar = fmt.Sprint(io.WChar)
+		p.ReadBytes = fmt.Sprint(io.ReadBytes)
+		p.WriteBytes = fmt.Sprint(io.WriteBytes)
+	}
+	p.Cgroup = proc.ParseCgroup(p.cgroup)
+	if p.Cgroup == "" {
+		p.Cgroup = "-"
+	}
+}
+
 // ctty returns the ctty or "?" if none can be found.
 // TODO: an right way to get ctty by p.TTYNr and p.TTYPgrp
 func (p process) getCtty() string {
@@ -200,9 +236,13 @@ func getAllGlobNames() []string {
 
 // Create a set of stat file names from an array of globs
 func getAllStatNames(globs []string) ([]string, error) {
+	pattern := "[0-9]*/stat"
+	if threads {
+		pattern = "[0-9]*/task/[0-9]*/stat"
+	}
 	var list []string
 	for _, g := range globs {
-		l, err := filepath.Glob(filepath.Join(g, "[0-9]*/stat"))
+		l, err := filepath.Glob(filepath.Join(g, pattern))
 		if err != nil {
 			log.Printf("Glob err on %s: %v", g, err)
 			continue
@@ -237,7 +277,15 @@ func (pT *ProcessTable) doTable(statFileNames []string) error {
 			continue
 		}
 		d := filepath.Dir(stat)
-		pid := filepath.Base(d)
+		// With -L, d is the directory of a thread, pid/task/tid.
+		pd, tid := d, ""
+		if threads {
+			pd, tid = filepath.Dir(filepath.Dir(d)), filepath.Base(d)
+			if p.Tidno, err = strconv.Atoi(tid); err != nil {
+				return fmt.Errorf("last element of %v is not a number", tid)
+			}
+		}
+		pid := filepath.Base(pd)
 		pidno, err := strconv.Atoi(pid)
 		if err != nil {
 			return fmt.Errorf("last element of %v is not a number", pid)
@@ -252,9 +300,13 @@ func (pT *ProcessTable) doTable(statFileNames []string) error {
 				continue
 			}
 		}
+		// These are optional: io is only readable by the owner, and
+		// cgroup needs CONFIG_CGROUPS.
+		p.io, _ = file(filepath.Join(d, "io"))
+		p.cgroup, _ = file(filepath.Join(d, "cgroup"))
 		// if filepath.Base is *not* proc, then use it, else
 		// it's just the directory containing the pid.
-		proot := filepath.Dir(d)
+		proot := filepath.Dir(pd)
 		// log.Printf("procdir %v d %v proot %v", procdir, d, proot)
 		if proot != procdir {
 			pid = filepath.Join(filepath.Base(proot), pid)
@@ -264,6 +316,7 @@ func (pT *ProcessTable) doTable(statFileNames []string) error {
 			return err
 		}
 		p.Pid = pid
+		p.Lwp = tid
 		// log.Printf("stat is %v p is %v", stat,p)
 		if p.Pidno == os.Getpid() {
 			pT.mProc = p
@@ -162,3 +162,57 @@ nonvoluntary_ctxt_switches:	3746
 	}
 
 }
+
+func TestFormat(t *testing.T) {
+	defer func() { threads, format = false, "" }()
+	for _, tt := range []struct {
+		name    string
+		threads bool
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{name: "threads", threads: true, want: "PID LWP TTY TIME CMD"},
+		{name: "columns", format: "pid,rss,rbytes,cgroup,cmd", want: "PID RSS RBYTES CGROUP CMD"},
+		{name: "threads and columns", threads: true, format: "pid,lwp,nlwp", want: "PID LWP NLWP"},
+		{name: "unknown column", format: "pid,bogus", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			all, every, x, nSidTty, aux = true, false, false, false, false
+			threads, format = tt.threads, tt.format
+			buf := &bytes.Buffer{}
+			err := ps(buf, []string{}...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ps() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			header, _, _ := strings.Cut(buf.String(), "\n")
+			if got := strings.Join(strings.Fields(header), " "); got != tt.want {
+				t.Errorf("ps() header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadExtra(t *testing.T) {
+	p := &Process{
+		status: "Uid:\t0\t0\t0\t0\nVmSize:\t  225412 kB\nVmRSS:\t    9300 kB\nVmSwap:\t      12 kB\n",
+		io:     "rchar: 100\nwchar: 200\nread_bytes: 4096\nwrite_bytes: 8192\n",
+		cgroup: "0::/init.scope\n",
+	}
+	p.readExtra()
+	want := []string{"225412", "9300", "12", "100", "200", "4096", "8192", "/init.scope"}
+	got := []string{p.VmSize, p.VmRSS, p.VmSwap, p.Rchar, p.Wchar, p.ReadBytes, p.WriteBytes, p.Cgroup}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("readExtra() = %q, want %q", got, want)
+	}
+
+	// Without access to io, and without cgroups.
+	p = &Process{status: "Uid:\t0\t0\t0\t0\n"}
+	p.readExtra()
+	if p.ReadBytes != "-" || p.Cgroup != "-" {
+		t.Errorf("readExtra() = %q, %q, want -, -", p.ReadBytes, p.Cgroup)
+	}
+}
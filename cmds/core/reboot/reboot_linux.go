@@ -0,0 +1,47 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// reboot restarts the system, without delay.
+//
+// Synopsis:
+//
+//	reboot [-kexec]
+//
+// Description:
+//
+//	reboot asks u-root's init to stop all processes, unmount file systems
+//	and reboot the system. Without u-root's init, it syncs and calls the
+//	kernel to reboot the system.
+//
+// Options:
+//
+//	-kexec: boot the kernel loaded with kexec -l rather than reset
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/u-root/u-root/pkg/libinit"
+	"golang.org/x/sys/unix"
+)
+
+var kexec = flag.Bool("kexec", false, "boot the kernel loaded with kexec -l")
+
+func main() {
+	flag.Parse()
+	action, cmd := libinit.Reboot, unix.LINUX_REBOOT_CMD_RESTART
+	if *kexec {
+		action, cmd = libinit.Kexec, unix.LINUX_REBOOT_CMD_KEXEC
+	}
+	err := libinit.RequestShutdown(action)
+	if errors.Is(err, libinit.ErrNoInit) {
+		unix.Sync()
+		err = unix.Reboot(cmd)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
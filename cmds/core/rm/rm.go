@@ -15,10 +15,16 @@
 //	-R: remove file hierarchies
 //	-r: equivalent to -R
 //	-f: ignore nonexistent files and never prompt
+//
+// Description:
+//
+//	File hierarchies are removed without following symlinks in them, even
+//	if they are swapped in while the hierarchy is removed.
 package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -28,6 +34,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/u-root/u-root/pkg/fts"
 	"github.com/u-root/u-root/pkg/uroot/util"
 )
 
@@ -47,7 +54,7 @@ func rm(stdin io.Reader, files []string) error {
 	}
 	f := os.Remove
 	if *recursive || *r {
-		f = os.RemoveAll
+		f = removeAll
 	}
 
 	if *force {
@@ -87,6 +94,27 @@ func rm(stdin io.Reader, files []string) error {
 	return nil
 }
 
+// removeAll removes path and its contents, as os.RemoveAll does, relative to
+// the directories holding them.
+func removeAll(path string) error {
+	err := fts.Walk(path, func(e *fts.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() && !e.Post {
+			return nil
+		}
+		if err := e.Remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
 func main() {
 	flag.Usage = util.Usage(flag.Usage, usage)
 	flag.Parse()
@@ -143,3 +143,26 @@ func TestRm(t *testing.T) {
 		})
 	}
 }
+
+func TestRmRecursiveSymlink(t *testing.T) {
+	d := setup(t)
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	if err := os.WriteFile(secret, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(d, "hi", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	*interactive, *verbose, *recursive, *force = false, false, true, false
+	if err := rm(&bytes.Buffer{}, []string{filepath.Join(d, "hi")}); err != nil {
+		t.Fatalf("rm() = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(d, "hi")); !os.IsNotExist(err) {
+		t.Errorf("hi was not removed: %v", err)
+	}
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("file behind symlink was removed: %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+var (
+	errUsage     = errors.New("usage: scp [-r] [-p] [-O] [-P port] [-i key] source... target")
+	errNoRemote  = errors.New("either the sources or the target must be remote")
+	errNotDir    = errors.New("not a directory")
+	errIsDir     = errors.New("is a directory (use -r)")
+	errLegacyOne = errors.New("the legacy protocol can only copy one file")
+)
+
+// client copies files to or from a host.
+type client struct {
+	port       string
+	key        string
+	knownHosts string
+	recursive  bool
+	preserve   bool
+	legacy     bool
+	// password asks for the password of user@host, if keys fail.
+	password func(prompt string) (string, error)
+}
+
+// remote is a file on a host.
+type remote struct {
+	user, host, path string
+}
+
+// parseRemote parses [user@]host:path, or returns nil for a local path. As
+// with OpenSSH, a colon after a slash is part of a local path, and IPv6
+// hosts are in brackets.
+func parseRemote(arg string) *remote {
+	r := &remote{}
+	s := arg
+	if i := strings.Index(s, "@"); i > 0 && !strings.Contains(s[:i], "/") && !strings.Contains(s[:i], ":") {
+		r.user, s = s[:i], s[i+1:]
+	}
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]:")
+		if end < 0 {
+			return nil
+		}
+		r.host, r.path = s[1:end], s[end+2:]
+		return r
+	}
+	i := strings.Index(s, ":")
+	if i <= 0 || strings.Contains(s[:i], "/") {
+		return nil
+	}
+	r.host, r.path = s[:i], s[i+1:]
+	return r
+}
+
+func home(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		return filepath.Join(os.Getenv("HOME"), p[2:])
+	}
+	return p
+}
+
+// signers returns the private keys to authenticate with.
+func (c *client) signers() ([]ssh.Signer, error) {
+	files := []string{c.key}
+	if c.key == "" {
+		files = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+	}
+	var signers []ssh.Signer
+	for _, f := range files {
+		b, err := os.ReadFile(home(f))
+		if os.IsNotExist(err) && c.key == "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// hostKeyCallback checks host keys against the known hosts files which
+// exist.
+func (c *client) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, f := range []string{home(c.knownHosts), "/etc/ssh/ssh_known_hosts"} {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return func(host string, _ net.Addr, key ssh.PublicKey) error {
+			return fmt.Errorf("host key %s of %s is not known: add it to %s", ssh.FingerprintSHA256(key), host, c.knownHosts)
+		}, nil
+	}
+	return knownhosts.New(files...)
+}
+
+func (c *client) dial(r *remote) (*ssh.Client, error) {
+	name := r.user
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		name = u.Username
+	}
+	signers, err := c.signers()
+	if err != nil {
+		return nil, err
+	}
+	cb, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: cb,
+	}
+	if c.password != nil {
+		config.Auth = append(config.Auth, ssh.PasswordCallback(func() (string, error) {
+			return c.password(fmt.Sprintf("%s@%s's password: ", name, r.host))
+		}))
+	}
+	return ssh.Dial("tcp", net.JoinHostPort(r.host, c.port), config)
+}
+
+// readPassword reads a password from the terminal.
+func readPassword(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("no terminal to read a password from")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	return string(b), err
+}
+
+func (c *client) run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	srcs, dst := args[:len(args)-1], args[len(args)-1]
+	rdst := parseRemote(dst)
+	var rsrc *remote
+	for _, s := range srcs {
+		r := parseRemote(s)
+		if (r == nil) != (rdst != nil) {
+			return errNoRemote
+		}
+		if r != nil {
+			if rsrc != nil && (r.user != rsrc.user || r.host != rsrc.host) {
+				return fmt.Errorf("%w: all sources must be on one host", errUsage)
+			}
+			rsrc = r
+		}
+	}
+
+	r := rdst
+	if r == nil {
+		r = rsrc
+	}
+	conn, err := c.dial(r)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if c.legacy {
+		if len(srcs) != 1 || c.recursive {
+			return errLegacyOne
+		}
+		if rdst != nil {
+			return c.legacyPut(conn, srcs[0], rdst.path)
+		}
+		return c.legacyGet(conn, rsrc.path, dst)
+	}
+
+	s, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	w, err := s.StdinPipe()
+	if err != nil {
+		return err
+	}
+	rd, err := s.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := s.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf("sftp subsystem: %w", err)
+	}
+	sc, err := sftp.NewClient(struct {
+		io.Reader
+		io.WriteCloser
+	}{rd, w})
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	t := &transfer{client: c, sftp: sc}
+	if rdst != nil {
+		return t.put(srcs, remotePath(rdst.path))
+	}
+	var paths []string
+	for _, s := range srcs {
+		paths = append(paths, remotePath(parseRemote(s).path))
+	}
+	return t.get(paths, dst)
+}
+
+// remotePath returns the path for the server: relative paths are relative
+// to the home directory, where the server starts.
+func remotePath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// transfer copies files with sftp.
+type transfer struct {
+	*client
+	sftp *sftp.Client
+}
+
+// put copies local files to the host.
+func (t *transfer) put(srcs []string, dst string) error {
+	fi, err := t.sftp.Stat(dst)
+	isDir := err == nil && fi.IsDir()
+	if len(srcs) > 1 && !isDir {
+		return fmt.Errorf("%s: %w", dst, errNotDir)
+	}
+	for _, src := range srcs {
+		target := dst
+		if isDir {
+			target = path.Join(dst, filepath.Base(src))
+		}
+		if err := t.putFile(src, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transfer) putFile(src, dst string) error {
+	// Like scp, follow symlinks.
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if !t.recursive {
+			return fmt.Errorf("%s: %w", src, errIsDir)
+		}
+		if err := t.sftp.Mkdir(dst, fi.Mode().Perm()|0o700); err != nil {
+			if rfi, serr := t.sftp.Stat(dst); serr != nil || !rfi.IsDir() {
+				return err
+			}
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := t.putFile(filepath.Join(src, e.Name()), path.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+	} else {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := t.sftp.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	if t.preserve {
+		if err := t.sftp.Chmod(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		return t.sftp.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	}
+	return nil
+}
+
+// get copies files of the host to local files.
+func (t *transfer) get(srcs []string, dst string) error {
+	fi, err := os.Stat(dst)
+	isDir := err == nil && fi.IsDir()
+	if len(srcs) > 1 && !isDir {
+		return fmt.Errorf("%s: %w", dst, errNotDir)
+	}
+	for _, src := range srcs {
+		target := dst
+		if isDir {
+			target = filepath.Join(dst, path.Base(src))
+		}
+		if err := t.getFile(src, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transfer) getFile(src, dst string) error {
+	fi, err := t.sftp.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if !t.recursive {
+			return fmt.Errorf("%s: %w", src, errIsDir)
+		}
+		if err := os.Mkdir(dst, fi.Mode().Perm()|0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		entries, err := t.sftp.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := t.getFile(path.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+	} else {
+		in, err := t.sftp.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	if t.preserve {
+		if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	}
+	return nil
+}
+
+// quote quotes a path for the remote shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runLegacy runs scp on the host, and speaks the SCP protocol to it.
+func (c *client) runLegacy(conn *ssh.Client, cmd string, speak func(w io.Writer, r io.Reader) error) error {
+	s, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	w, err := s.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := s.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := s.Start(cmd); err != nil {
+		return err
+	}
+	if err := speak(w, r); err != nil {
+		return err
+	}
+	w.Close()
+	return s.Wait()
+}
+
+func (c *client) legacyPut(conn *ssh.Client, src, dst string) error {
+	return c.runLegacy(conn, "scp -t "+quote(remotePath(dst)), func(w io.Writer, r io.Reader) error {
+		return scpSource(w, r, src)
+	})
+}
+
+func (c *client) legacyGet(conn *ssh.Client, src, dst string) error {
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		dst = filepath.Join(dst, path.Base(src))
+	}
+	return c.runLegacy(conn, "scp -f "+quote(src), func(w io.Writer, r io.Reader) error {
+		return scpSink(w, r, dst)
+	})
+}
@@ -0,0 +1,304 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseRemote(t *testing.T) {
+	for _, tt := range []struct {
+		arg  string
+		want *remote
+	}{
+		{arg: "file"},
+		{arg: "./a:b"},
+		{arg: "/tmp/a:b"},
+		{arg: ":file"},
+		{arg: "host:", want: &remote{host: "host"}},
+		{arg: "host:/tmp/f", want: &remote{host: "host", path: "/tmp/f"}},
+		{arg: "me@host:f:g", want: &remote{user: "me", host: "host", path: "f:g"}},
+		{arg: "[::1]:f", want: &remote{host: "::1", path: "f"}},
+		{arg: "me@[fe80::1%eth0]:/f", want: &remote{user: "me", host: "fe80::1%eth0", path: "/f"}},
+		{arg: "[::1]"},
+	} {
+		got := parseRemote(tt.arg)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("parseRemote(%q) = %+v, want %+v", tt.arg, got, tt.want)
+		}
+	}
+}
+
+// server is an SSH server with the sftp subsystem, which runs scp -t and -f
+// in-process.
+func server(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(c, config)
+		}
+	}()
+	return l.Addr().String(), hostKey.PublicKey()
+}
+
+func serveConn(c net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				var run func() error
+				switch req.Type {
+				case "subsystem":
+					run = func() error { return sftp.NewServer(ch).Serve() }
+				case "exec":
+					var e struct{ Command string }
+					ssh.Unmarshal(req.Payload, &e)
+					args := strings.Fields(e.Command)
+					p := strings.Trim(args[2], "'")
+					if args[1] == "-t" {
+						run = func() error { return scpSink(ch, ch, p) }
+					} else {
+						run = func() error { return scpSource(ch, ch, p) }
+					}
+				}
+				req.Reply(run != nil, nil)
+				if run != nil {
+					go func() {
+						var status uint32
+						if run() != nil {
+							status = 1
+						}
+						ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+						ch.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(key, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, hostKey := server(t, sshPub)
+	host, port, _ := net.SplitHostPort(addr)
+	known := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, port))}, hostKey) + "\n"
+	if err := os.WriteFile(known, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &client{port: port, key: key, knownHosts: known}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		f := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		b, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil || string(b) != data {
+			t.Errorf("%s = %q, %v, want %q", name, b, err, data)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	files := map[string]string{
+		"d/a":   "a",
+		"d/e/b": "bb",
+	}
+	for _, tt := range []struct {
+		name      string
+		recursive bool
+		preserve  bool
+		legacy    bool
+		// args are relative to the local and the remote directory.
+		args func(local, remote string) []string
+		// in and out are the files before and after, in the local and
+		// remote directory.
+		localIn, remoteIn   map[string]string
+		localOut, remoteOut map[string]string
+		err                 error
+	}{
+		{
+			name:      "put",
+			args:      func(l, r string) []string { return []string{l + "/d/a", "me@127.0.0.1:" + r + "/x"} },
+			localIn:   files,
+			remoteOut: map[string]string{"x": "a"},
+		},
+		{
+			name:      "put into directory",
+			args:      func(l, r string) []string { return []string{l + "/d/a", l + "/d/e/b", "127.0.0.1:" + r} },
+			localIn:   files,
+			remoteOut: map[string]string{"a": "a", "b": "bb"},
+		},
+		{
+			name:      "put recursive",
+			recursive: true,
+			preserve:  true,
+			args:      func(l, r string) []string { return []string{l + "/d", "127.0.0.1:" + r} },
+			localIn:   files,
+			remoteOut: files,
+		},
+		{
+			name:    "put directory",
+			args:    func(l, r string) []string { return []string{l + "/d", "127.0.0.1:" + r} },
+			localIn: files,
+			err:     errIsDir,
+		},
+		{
+			name:    "put many to a file",
+			args:    func(l, r string) []string { return []string{l + "/d/a", l + "/d/a", "127.0.0.1:" + r + "/d/a"} },
+			localIn: files, remoteIn: files,
+			err: errNotDir,
+		},
+		{
+			name:      "get recursive",
+			recursive: true,
+			args:      func(l, r string) []string { return []string{"127.0.0.1:" + r + "/d", l} },
+			remoteIn:  files,
+			localOut:  files,
+		},
+		{
+			name:     "get",
+			args:     func(l, r string) []string { return []string{"127.0.0.1:" + r + "/d/e/b", l + "/y"} },
+			remoteIn: files,
+			localOut: map[string]string{"y": "bb"},
+		},
+		{
+			name:      "legacy put",
+			legacy:    true,
+			args:      func(l, r string) []string { return []string{l + "/d/a", "127.0.0.1:" + r + "/x"} },
+			localIn:   files,
+			remoteOut: map[string]string{"x": "a"},
+		},
+		{
+			name:     "legacy get",
+			legacy:   true,
+			args:     func(l, r string) []string { return []string{"127.0.0.1:" + r + "/d/e/b", l} },
+			remoteIn: files,
+			localOut: map[string]string{"b": "bb"},
+		},
+		{
+			name: "local",
+			args: func(l, r string) []string { return []string{l + "/a", l + "/b"} },
+			err:  errNoRemote,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(t)
+			c.recursive, c.preserve, c.legacy = tt.recursive, tt.preserve, tt.legacy
+			local, remote := t.TempDir(), t.TempDir()
+			writeFiles(t, local, tt.localIn)
+			writeFiles(t, remote, tt.remoteIn)
+			mtime := time.Unix(1700000000, 0)
+			if tt.preserve {
+				os.Chtimes(filepath.Join(local, "d/a"), mtime, mtime)
+			}
+
+			err := c.run(tt.args(local, remote))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("run = %v, want %v", err, tt.err)
+			}
+			checkFiles(t, local, tt.localOut)
+			checkFiles(t, remote, tt.remoteOut)
+			if tt.preserve {
+				fi, err := os.Stat(filepath.Join(remote, "d/a"))
+				if err != nil || fi.Mode().Perm() != 0o640 || !fi.ModTime().Equal(mtime) {
+					t.Errorf("d/a: %v, %v, want mode 0640 and time %v", fi, err, mtime)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownHost(t *testing.T) {
+	c := newClient(t)
+	c.knownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(c.knownHosts, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := c.run([]string{"127.0.0.1:/etc/hostname", t.TempDir()})
+	var ke *knownhosts.KeyError
+	if !errors.As(err, &ke) {
+		t.Errorf("run with an unknown host = %v, want a KeyError", err)
+	}
+}
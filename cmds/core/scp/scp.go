@@ -6,15 +6,31 @@
 //
 // Synopsis:
 //
+//	scp [-r] [-p] [-O] [-P PORT] [-i KEY] [-known_hosts FILE] SOURCE... TARGET
 //	scp [-t|-f] [FILE]
 //
 // Description:
 //
+//	Without -t or -f, copy files to or from a host over SSH. Remote files
+//	are written as [user@]host:path, where an empty path is the home
+//	directory of the user. Either all sources or the target must be
+//	remote. If the target is a directory, files are copied into it.
+//
+//	Files are copied with the sftp subsystem, as OpenSSH's scp does by
+//	default, unless -O is given. Host keys are checked against the
+//	known_hosts file and /etc/ssh/ssh_known_hosts.
+//
 //	If -t is given, decode SCP protocol from stdin and write to FILE.
 //	If -f is given, stream FILE over SCP protocol to stdout.
 //
 // Options:
 //
+//	-r: Copy directories recursively
+//	-p: Preserve modes and modification times
+//	-O: Use the legacy SCP protocol, which can only copy single files
+//	-P: Port of the remote host
+//	-i: Private key file, instead of ~/.ssh/id_ed25519, id_ecdsa and id_rsa
+//	-known_hosts: Known hosts file
 //	-t: Act as the target
 //	-f: Act as the source
 //	-v: Passed if SCP is verbose, ignored
@@ -37,6 +53,13 @@ var (
 	isTarget = flag.Bool("t", false, "Act as the target")
 	isSource = flag.Bool("f", false, "Act as the source")
 	_        = flag.Bool("v", false, "Ignored")
+
+	recursive  = flag.Bool("r", false, "Copy directories recursively")
+	preserve   = flag.Bool("p", false, "Preserve modes and modification times")
+	legacy     = flag.Bool("O", false, "Use the legacy SCP protocol instead of sftp")
+	port       = flag.String("P", "22", "Port of the remote host")
+	keyFile    = flag.String("i", "", "Private key file")
+	knownHosts = flag.String("known_hosts", "~/.ssh/known_hosts", "Known hosts file")
 )
 
 func scpSingleSource(w io.Writer, r io.Reader, pth string) error {
@@ -80,7 +103,7 @@ func scpSingleSink(w io.Writer, r io.Reader, path string) error {
 		}
 		return fmt.Errorf("fscanf: %v", err)
 	}
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, mode)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
 	if err != nil {
 		return fmt.Errorf("open error: %v", err)
 	}
@@ -136,8 +159,24 @@ func main() {
 		log.Fatalf("no file provided")
 	}
 
-	if *isSource == *isTarget {
-		log.Fatalf("-t or -f needs to be supplied, and not both")
+	if *isSource && *isTarget {
+		log.Fatalf("-t and -f cannot both be supplied")
+	}
+
+	if !*isSource && !*isTarget {
+		c := &client{
+			port:       *port,
+			key:        *keyFile,
+			knownHosts: *knownHosts,
+			recursive:  *recursive,
+			preserve:   *preserve,
+			legacy:     *legacy,
+			password:   readPassword,
+		}
+		if err := c.run(flag.Args()); err != nil {
+			log.Fatalf("scp: %v", err)
+		}
+		return
 	}
 
 	if *isSource {
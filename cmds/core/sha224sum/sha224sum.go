@@ -0,0 +1,24 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sha224sum prints or checks SHA224 digests of files. It is shasum -a 224;
+// see shasum for its options.
+//
+// Synopsis:
+//
+//	sha224sum [-bt] [--tag] [-j JOBS] [FILE...]
+//	sha224sum -c [--quiet] [--status] [--strict] [-w] [--ignore-missing] [FILE...]
+package main
+
+import (
+	"os"
+
+	"github.com/u-root/u-root/pkg/checksum"
+)
+
+func main() {
+	if err := checksum.Run(checksum.SHA224, os.Stdout, os.Stderr, os.Stdin, os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
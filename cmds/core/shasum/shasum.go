@@ -2,79 +2,46 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+// shasum prints or checks SHA1 and SHA2 digests of files.
+//
+// Synopsis:
+//
+//	shasum [-a ALGORITHM] [-bt] [--tag] [-j JOBS] [FILE...]
+//	shasum [-a ALGORITHM] -c [--quiet] [--status] [--strict] [-w] [--ignore-missing] [FILE...]
+//
+// Description:
+//
+//	Without -c, shasum prints the digest of each FILE, or of stdin if there
+//	is none or FILE is -, as GNU coreutils does. With -c, the FILEs are
+//	lists of digests, as shasum prints them in either form, and the files
+//	listed are checked against them. Files are hashed in parallel.
+//
+//	sha1sum, sha224sum, sha256sum, sha384sum and sha512sum are shasum with
+//	-a 1, 224, 256, 384 and 512.
+//
+// Options:
+//
+//	-a: algorithm, one of 1, 224, 256, 384 and 512 (default 1)
+//	-b: mark files as read in binary mode
+//	-t: mark files as read in text mode (default)
+//	--tag: print BSD-style lines, as in "SHA256 (FILE) = DIGEST"
+//	-j: hash JOBS files at once, one per CPU by default
+//	-c: check files against the digests listed in FILEs
+//	--quiet: do not print files which match
+//	--status: print nothing; the exit status tells
+//	--strict: fail for improperly formatted lines
+//	-w: warn about improperly formatted lines
+//	--ignore-missing: skip files which do not exist
 package main
 
 import (
-	"bufio"
-	"crypto/sha1"
-	"crypto/sha256"
-	"flag"
-	"fmt"
-	"hash"
-	"io"
-	"log"
 	"os"
-)
-
-var algorithm int
-
-var usage = "Usage:\nshasum -a <algorithm> <File Name>"
-
-// shaPrinter prints sha1/sha256 of given data. The
-// value of algorithm is expected to be 1 for SHA1
-// and 256 for SHA256
-func shaGenerator(w io.Writer, r io.Reader, algo int) ([]byte, error) {
-	var h hash.Hash
-	switch algo {
-	case 1:
-		h = sha1.New()
-	case 256:
-		h = sha256.New()
-	default:
-		return nil, fmt.Errorf("invalid algorithm, only 1 or 256 are valid")
-	}
-	if _, err := io.Copy(h, r); err != nil {
-		return nil, err
-	}
-	return h.Sum(nil), nil
-}
 
-func shasum(w io.Writer, r io.Reader, args ...string) error {
-	var hashbytes []byte
-	var err error
-	if len(args) == 0 {
-		buf := bufio.NewReader(r)
-		if hashbytes, err = shaGenerator(w, buf, algorithm); err != nil {
-			return err
-		}
-		fmt.Fprintf(w, "%x -\n", hashbytes)
-		return nil
-	}
-	for _, arg := range args {
-		file, err := os.Open(arg)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		if hashbytes, err = shaGenerator(w, file, algorithm); err != nil {
-			return err
-		}
-		fmt.Fprintf(w, "%x %s\n", hashbytes, arg)
-	}
-	return nil
-}
+	"github.com/u-root/u-root/pkg/checksum"
+)
 
 func main() {
-	flag.IntVar(&algorithm, "algorithm", 1, "SHA algorithm, valid args are 1 and 256")
-	flag.IntVar(&algorithm, "a", 1, "SHA algorithm, valid args are 1 and 256 (shorthand)")
-
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), usage+"\n")
-		flag.PrintDefaults()
-	}
-
-	flag.Parse()
-	if err := shasum(os.Stdout, os.Stdin, flag.Args()...); err != nil {
-		log.Fatal(err)
+	if err := checksum.Shasum(os.Stdout, os.Stderr, os.Stdin, os.Args[1:]); err != nil {
+		os.Exit(1)
 	}
 }
@@ -7,9 +7,13 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/checksum"
 )
 
 func TestSHASum(t *testing.T) {
@@ -32,6 +36,11 @@ func TestSHASum(t *testing.T) {
 		t.Errorf("failed to write string to file2: %v", err)
 	}
 
+	list := filepath.Join(tmpdir, "list")
+	if err := os.WriteFile(list, []byte("ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57  "+file1.Name()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
 	for _, tt := range []struct {
 		name      string
 		args      []string
@@ -42,70 +51,82 @@ func TestSHASum(t *testing.T) {
 			name:      "bufIn as input with sha1 sum",
 			args:      []string{},
 			algorithm: 1,
-			want:      "bdc37c074ec4ee6050d68bc133c6b912f36474df -\n",
+			want:      "bdc37c074ec4ee6050d68bc133c6b912f36474df  -\n",
 		},
 		{
 			name:      "bufIn as input with sha256 sum",
 			args:      []string{},
 			algorithm: 256,
-			want:      "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57 -\n",
+			want:      "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57  -\n",
 		},
 		{
-			name: "wrong path file",
-			args: []string{"testfile"},
-			want: "open testfile: no such file or directory",
+			name:      "wrong path file",
+			args:      []string{"testfile"},
+			algorithm: 1,
+			want:      "open testfile: no such file or directory",
 		},
 		{
 			name: "file1 as input with invalid algorithm",
 			args: []string{file1.Name()},
-			want: "invalid algorithm, only 1 or 256 are valid",
+			want: "invalid algorithm 0, only 1, 224, 256, 384 and 512 are valid",
 		},
 		{
 			name: "stdin as input with invalid algorithm",
 			args: []string{},
-			want: "invalid algorithm, only 1 or 256 are valid",
+			want: "invalid algorithm 0, only 1, 224, 256, 384 and 512 are valid",
 		},
 		{
 			name:      "file1 as input with sha1 sum",
 			args:      []string{file1.Name()},
 			algorithm: 1,
-			want:      fmt.Sprintf("%s %s\n", "bdc37c074ec4ee6050d68bc133c6b912f36474df", file1.Name()),
+			want:      fmt.Sprintf("%s  %s\n", "bdc37c074ec4ee6050d68bc133c6b912f36474df", file1.Name()),
 		},
 		{
 			name:      "file2 as input with sha1 sum",
 			args:      []string{file2.Name()},
 			algorithm: 1,
-			want:      fmt.Sprintf("%s %s\n", "e8ed2d487f1dc32152c8590f39c20b7703f9e159", file2.Name()),
+			want:      fmt.Sprintf("%s  %s\n", "e8ed2d487f1dc32152c8590f39c20b7703f9e159", file2.Name()),
 		},
 		{
 			name:      "file1 as input with sha256 sum",
 			args:      []string{file1.Name()},
 			algorithm: 256,
-			want:      fmt.Sprintf("%s %s\n", "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57", file1.Name()),
+			want:      fmt.Sprintf("%s  %s\n", "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57", file1.Name()),
 		},
 		{
 			name:      "file2 as input with sha256 sum",
 			args:      []string{file2.Name()},
 			algorithm: 256,
-			want:      fmt.Sprintf("%s %s\n", "db296dd0bcb796df9b327f44104029da142c8fff313a25bd1ac7c3b7562caea9", file2.Name()),
+			want:      fmt.Sprintf("%s  %s\n", "db296dd0bcb796df9b327f44104029da142c8fff313a25bd1ac7c3b7562caea9", file2.Name()),
 		},
 		{
 			name:      "file1 and file 2 as input with sha256 sum",
 			args:      []string{file1.Name(), file2.Name()},
 			algorithm: 256,
-			want: fmt.Sprintf("%s %s\n%s %s\n", "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57", file1.Name(),
+			want: fmt.Sprintf("%s  %s\n%s  %s\n", "ae0666f161fed1a5dde998bbd0e140550d2da0db27db1d0e31e370f2bd366a57", file1.Name(),
 				"db296dd0bcb796df9b327f44104029da142c8fff313a25bd1ac7c3b7562caea9", file2.Name()),
 		},
+		{
+			name:      "bufIn as input with sha512 sum and tag",
+			args:      []string{"--tag"},
+			algorithm: 512,
+			want:      "SHA512 (-) = 624eb88c6f2be3e77b1306f976bf1fb7b48855701d3ed2198a15f38bb12d76d26e8eefe6457bc036a3f93f28dd05512f5a399a319d48a58c38c590e182fe8159\n",
+		},
+		{
+			name:      "check list with sha256 sum",
+			args:      []string{"-c", list},
+			algorithm: 256,
+			want:      file1.Name() + ": OK\n",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			// Setting flags
-			algorithm = tt.algorithm
+			args := append([]string{"-a", strconv.Itoa(tt.algorithm)}, tt.args...)
 			bufIn := &bytes.Buffer{}
 			if _, err := bufIn.WriteString("abcdef\n"); err != nil {
 				t.Errorf("failed to write string to bufIn: %v", err)
 			}
 			bufOut := &bytes.Buffer{}
-			if got := shasum(bufOut, bufIn, tt.args...); got != nil {
+			if got := checksum.Shasum(bufOut, io.Discard, bufIn, args); got != nil {
 				if got.Error() != tt.want {
 					t.Errorf("shasum() = %q, want: %q", got.Error(), tt.want)
 				}
@@ -6,19 +6,24 @@
 //
 // Synopsis:
 //
-//	shutdown [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]
+//	shutdown [<-h|-r|-s|halt|reboot|suspend|kexec> [time [message...]]]
 //
 // Description:
 //
-//	current operations are reboot (-r), suspend, and halt [-h].
+//	current operations are reboot (-r), suspend, halt [-h] and kexec.
 //	If no operation is specified halt is assumed.
 //	If a time is given, an opcode is not optional.
 //
+//	Except for suspend, shutdown asks u-root's init to stop all
+//	processes and unmount file systems first. Without u-root's init,
+//	it syncs and calls the kernel directly.
+//
 // Options:
 //
 //	-r|reboot:	reboot the machine.
 //	-h|halt:		halt the machine.
 //	-s|suspend:	suspend the machine.
+//	kexec:		boot the kernel loaded with kexec -l.
 //
 // Time is specified as "now", +minutes, or RFC3339 format.
 // All other arguments past time are printed as a message.
@@ -26,15 +31,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"github.com/u-root/u-root/pkg/libinit"
 	"golang.org/x/sys/unix"
 )
 
-const usageMessage = "shutdown [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]"
+const usageMessage = "shutdown [<-h|-r|-s|halt|reboot|suspend|kexec> [time [message...]]]"
 
 var (
 	opcodes = map[string]uint{
@@ -44,6 +51,14 @@ var (
 		"-r":      unix.LINUX_REBOOT_CMD_RESTART,
 		"suspend": unix.LINUX_REBOOT_CMD_SW_SUSPEND,
 		"-s":      unix.LINUX_REBOOT_CMD_SW_SUSPEND,
+		"kexec":   unix.LINUX_REBOOT_CMD_KEXEC,
+	}
+
+	// actions are what init is asked to do for opcodes.
+	actions = map[uint]libinit.ShutdownAction{
+		unix.LINUX_REBOOT_CMD_POWER_OFF: libinit.PowerOff,
+		unix.LINUX_REBOOT_CMD_RESTART:   libinit.Reboot,
+		unix.LINUX_REBOOT_CMD_KEXEC:     libinit.Kexec,
 	}
 )
 
@@ -87,6 +102,13 @@ func shutdown(dryrun bool, args ...string) (uint, error) {
 		time.Sleep(time.Until(when))
 	}
 	if !dryrun {
+		if action, ok := actions[op]; ok {
+			err := libinit.RequestShutdown(action)
+			if !errors.Is(err, libinit.ErrNoInit) {
+				return op, err
+			}
+			unix.Sync()
+		}
 		if err := unix.Reboot(int(op)); err != nil {
 			return 0, err
 		}
@@ -0,0 +1,224 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errKey = errors.New("invalid key")
+
+// key is a sort key, as given with -k POS1[,POS2]. Fields and characters
+// count from 1; an end field of 0 is the end of the line and an end
+// character of 0 the end of the field.
+type key struct {
+	startField, startChar int
+	endField, endChar     int
+	// startBlanks and endBlanks skip leading blanks of the start and end
+	// fields before counting characters.
+	startBlanks, endBlanks bool
+	fold                   bool
+	numeric                bool
+	reverse                bool
+	// global is set for keys without options, which take the global
+	// ones.
+	global bool
+}
+
+// keys is the -k flag, which may be given more than once.
+type keys []key
+
+func (k *keys) String() string {
+	return fmt.Sprintf("%d keys", len(*k))
+}
+
+func (k *keys) Set(s string) error {
+	key, err := parseKey(s)
+	if err != nil {
+		return err
+	}
+	*k = append(*k, key)
+	return nil
+}
+
+// parsePos parses F[.C][OPTS] into the field, character and options of k.
+func parsePos(s string, k *key, end bool) error {
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	pos, opts := s, ""
+	if i >= 0 {
+		pos, opts = s[:i], s[i:]
+	}
+	f, c, hasC := strings.Cut(pos, ".")
+	field, err := strconv.Atoi(f)
+	if err != nil || field < 1 {
+		return fmt.Errorf("%w: %q: field must be a number from 1", errKey, s)
+	}
+	char := 0
+	if hasC {
+		if char, err = strconv.Atoi(c); err != nil || char < 0 || (!end && char < 1) {
+			return fmt.Errorf("%w: %q: bad character position", errKey, s)
+		}
+	} else if !end {
+		char = 1
+	}
+	for _, o := range opts {
+		switch o {
+		case 'b':
+			if end {
+				k.endBlanks = true
+			} else {
+				k.startBlanks = true
+			}
+		case 'f':
+			k.fold = true
+		case 'n':
+			k.numeric = true
+		case 'r':
+			k.reverse = true
+		default:
+			return fmt.Errorf("%w: %q: unknown option %q", errKey, s, o)
+		}
+		k.global = false
+	}
+	if end {
+		k.endField, k.endChar = field, char
+	} else {
+		k.startField, k.startChar = field, char
+	}
+	return nil
+}
+
+// parseKey parses a key definition of -k.
+func parseKey(s string) (key, error) {
+	k := key{global: true}
+	start, end, hasEnd := strings.Cut(s, ",")
+	if err := parsePos(start, &k, false); err != nil {
+		return k, err
+	}
+	if hasEnd {
+		if err := parsePos(end, &k, true); err != nil {
+			return k, err
+		}
+	}
+	return k, nil
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// field returns the start and end of field n, counted from 1, of line. If
+// sep is empty, a field is blanks followed by non-blanks.
+func field(line, sep string, n int) (int, int, bool) {
+	start := 0
+	for i := 1; ; i++ {
+		end := len(line)
+		if sep != "" {
+			if j := strings.Index(line[start:], sep); j >= 0 {
+				end = start + j
+			}
+		} else {
+			end = start
+			for end < len(line) && isBlank(line[end]) {
+				end++
+			}
+			for end < len(line) && !isBlank(line[end]) {
+				end++
+			}
+		}
+		if i == n {
+			return start, end, true
+		}
+		if end == len(line) {
+			return len(line), len(line), false
+		}
+		start = end + len(sep)
+	}
+}
+
+func skipBlanks(line string, i, end int) int {
+	for i < end && isBlank(line[i]) {
+		i++
+	}
+	return i
+}
+
+// extract returns the part of line that k selects.
+func (k *key) extract(line, sep string) string {
+	fs, fe, ok := field(line, sep, k.startField)
+	if !ok {
+		return ""
+	}
+	s := fs
+	if k.startBlanks {
+		s = skipBlanks(line, s, fe)
+	}
+	s = min(s+k.startChar-1, fe)
+
+	e := len(line)
+	if k.endField > 0 {
+		if fs, fe, ok := field(line, sep, k.endField); ok {
+			e = fe
+			if k.endChar > 0 {
+				p := fs
+				if k.endBlanks {
+					p = skipBlanks(line, p, fe)
+				}
+				e = min(p+k.endChar, fe)
+			}
+		}
+	}
+	if e < s {
+		return ""
+	}
+	return line[s:e]
+}
+
+// number returns the value of the number at the start of s after blanks,
+// or 0.
+func number(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	}
+	n, _ := strconv.ParseFloat(s[:i], 64)
+	return n
+}
+
+// compare compares the parts a and b of lines that k selects.
+func (k *key) compare(a, b string) int {
+	var r int
+	switch {
+	case k.numeric:
+		na, nb := number(a), number(b)
+		switch {
+		case na < nb:
+			r = -1
+		case na > nb:
+			r = 1
+		}
+	case k.fold:
+		r = strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+	default:
+		r = strings.Compare(a, b)
+	}
+	if k.reverse {
+		return -r
+	}
+	return r
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"slices"
+)
+
+const (
+	// lineOverhead approximates the memory a line takes besides its
+	// bytes, for -S.
+	lineOverhead = 32
+	// maxMerge is the most files merged at once, to stay below limits of
+	// open files.
+	maxMerge = 16
+)
+
+// readLine reads a line without its newline. The last line of an input
+// may lack the newline.
+func readLine(r *bufio.Reader) (string, error) {
+	l, err := r.ReadString('\n')
+	if err == io.EOF && l != "" {
+		return l, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return l[:len(l)-1], nil
+}
+
+// output writes lines, leaving out those equal to the previous one with
+// -u.
+type output struct {
+	c    *cmd
+	w    *bufio.Writer
+	last string
+	any  bool
+}
+
+func (o *output) write(l string) error {
+	if o.c.params.unique && o.any && o.c.compare(o.last, l) == 0 {
+		return nil
+	}
+	o.last, o.any = l, true
+	if _, err := o.w.WriteString(l); err != nil {
+		return err
+	}
+	return o.w.WriteByte('\n')
+}
+
+// source is an input of a merge with its next line.
+type source struct {
+	r    *bufio.Reader
+	line string
+	// n orders sources of equal lines, so that the merge is stable.
+	n int
+}
+
+type sources struct {
+	c *cmd
+	s []*source
+}
+
+func (s *sources) Len() int      { return len(s.s) }
+func (s *sources) Swap(i, j int) { s.s[i], s.s[j] = s.s[j], s.s[i] }
+func (s *sources) Less(i, j int) bool {
+	if r := s.c.compare(s.s[i].line, s.s[j].line); r != 0 {
+		return r < 0
+	}
+	return s.s[i].n < s.s[j].n
+}
+func (s *sources) Push(x any) { s.s = append(s.s, x.(*source)) }
+func (s *sources) Pop() any {
+	x := s.s[len(s.s)-1]
+	s.s = s.s[:len(s.s)-1]
+	return x
+}
+
+// merge merges the sorted inputs into write.
+func (c *cmd) merge(inputs []io.Reader, write func(string) error) error {
+	h := &sources{c: c}
+	for i, in := range inputs {
+		s := &source{r: bufio.NewReader(in), n: i}
+		l, err := readLine(s.r)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.line = l
+		h.s = append(h.s, s)
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		s := h.s[0]
+		if err := write(s.line); err != nil {
+			return err
+		}
+		l, err := readLine(s.r)
+		switch {
+		case err == io.EOF:
+			heap.Pop(h)
+		case err != nil:
+			return err
+		default:
+			s.line = l
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+// tempFile returns a new temporary file, which is removed when it is
+// closed by cleanup.
+func (c *cmd) tempFile() (*os.File, error) {
+	f, err := os.CreateTemp(c.params.tmpDir, "sort")
+	if err != nil {
+		return nil, err
+	}
+	c.temps = append(c.temps, f)
+	return f, nil
+}
+
+func (c *cmd) cleanup() {
+	for _, f := range c.temps {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	c.temps = nil
+}
+
+// spill sorts lines and writes them to a temporary file.
+func (c *cmd) spill(lines []string) (*os.File, error) {
+	c.sortLines(lines)
+	f, err := c.tempFile()
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	for _, l := range lines {
+		if _, err := w.WriteString(l); err != nil {
+			return nil, err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// mergeFiles merges sorted temporary files into write, first merging
+// them into fewer files if there are more than maxMerge.
+func (c *cmd) mergeFiles(files []*os.File, write func(string) error) error {
+	for len(files) > maxMerge {
+		f, err := c.tempFile()
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		var in []io.Reader
+		for _, f := range files[:maxMerge] {
+			in = append(in, f)
+		}
+		if err := c.merge(in, func(l string) error {
+			if _, err := w.WriteString(l); err != nil {
+				return err
+			}
+			return w.WriteByte('\n')
+		}); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		for _, f := range files[:maxMerge] {
+			f.Close()
+		}
+		// The merged file holds the earliest lines, so it goes first
+		// to keep the merge stable.
+		files = append([]*os.File{f}, files[maxMerge:]...)
+	}
+	var in []io.Reader
+	for _, f := range files {
+		in = append(in, f)
+	}
+	return c.merge(in, write)
+}
+
+// sortLines sorts lines stably.
+func (c *cmd) sortLines(lines []string) {
+	slices.SortStableFunc(lines, c.compare)
+}
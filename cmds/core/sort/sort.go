@@ -11,100 +11,83 @@
 // Description:
 //
 //	Sort copies lines from the input to the output, sorting them in the
-//	process. This does nothing fancy (no multi-threading, compression,
-//	optiminzations, ...); it simply uses Go's sort.Sort function.
+//	process. Lines are compared by the keys of -k, in order, and then,
+//	unless -s or -u is given, as a whole.
+//
+//	A key is given as POS1[,POS2], where a POS is F[.C][OPTS]: field F
+//	and character C in it, counted from 1. Without -t, fields are
+//	separated by the change from blanks to non-blanks and include the
+//	blanks before them. The key ends at the end of the line if POS2 is
+//	omitted, and at the end of field F if C is omitted or 0. OPTS are
+//	any of b, f, n and r, which apply to the key instead of the global
+//	options. For example, -k 3,3n -k 1,1 sorts by the number in the third
+//	field and then by the first field.
+//
+//	Inputs which do not fit in the buffer of -S are sorted in parts,
+//	which are written to temporary files in the directory of -T and
+//	merged, so that sort works on files larger than memory.
 //
 // Options:
 //
 //	-r:      Reverse the result of comparisons
+//	-c:      Check that the single input file is ordered and report the first unordered line.
 //	-C:      Check that the single input file is ordered. No warnings.
 //	-u:	     Unique keys. Suppress all lines that have a key that is equal to an already processed one.
 //	-f: 	 Fold lower case to upper case character.
 //	-b: 	 Ignore leading blank characters when comparing lines.
 //	-n:      Compare according to string numerical value.
 //	-o FILE: Specify the name of an output file to be used instead of the standard output.
+//	-k KEY:  Sort by KEY; may be given more than once.
+//	-t SEP:  Separate fields by SEP instead of blanks.
+//	-s:      Stable sort: keep lines with equal keys in input order.
+//	-m:      Merge inputs which are already sorted.
+//	-S SIZE: Memory for lines before they go to temporary files, with a K, M or G suffix (default 16M).
+//	-T DIR:  Directory for temporary files.
+//
+//	Options may be combined and given their values attached, as in
+//	sort -nr -k2,2n -t: FILE.
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
-	"unicode"
-)
 
-var (
-	reverse      = flag.Bool("r", false, "Reverse the result of comparisons.")
-	ordered      = flag.Bool("C", false, "Check that the single input file is ordered. No warnings.")
-	unique       = flag.Bool("u", false, "Unique keys. Suppress all lines that have a key that is equal to an already processed one.")
-	ignoreCase   = flag.Bool("f", false, "Fold lower case to upper case character.")
-	ignoreBlanks = flag.Bool("b", false, "Ignore leading blank characters when comparing lines.")
-	numeric      = flag.Bool("n", false, "Compare according to string numerical value.")
-	outputFile   = flag.String("o", "", "Specify the name of an output file to be used instead of the standard output.")
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
 
-type ignoreCaseSort []string
-
-func (a ignoreCaseSort) Len() int           { return len(a) }
-func (a ignoreCaseSort) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ignoreCaseSort) Less(i, j int) bool { return strings.ToUpper(a[i]) < strings.ToUpper(a[j]) }
-
-type ignoreBlanksSort []string
-
-func (a ignoreBlanksSort) Len() int      { return len(a) }
-func (a ignoreBlanksSort) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a ignoreBlanksSort) Less(i, j int) bool {
-	l := strings.TrimLeftFunc(a[i], unicode.IsSpace)
-	r := strings.TrimLeftFunc(a[j], unicode.IsSpace)
-	if l == r {
-		return len(a[i]) >= len(a[j])
-	}
-	return l < r
-}
-
-type ignoreBlanksCaseSort []string
-
-func (a ignoreBlanksCaseSort) Len() int      { return len(a) }
-func (a ignoreBlanksCaseSort) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a ignoreBlanksCaseSort) Less(i, j int) bool {
-	l := strings.ToUpper(strings.TrimLeftFunc(a[i], unicode.IsSpace))
-	r := strings.ToUpper(strings.TrimLeftFunc(a[j], unicode.IsSpace))
-	if l == r {
-		return len(a[i]) >= len(a[j])
-	}
-	return l < r
-}
+// defaultBufferSize is the default of -S.
+const defaultBufferSize = 16 << 20
 
-type numericSort []string
-
-func (a numericSort) Len() int      { return len(a) }
-func (a numericSort) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-func (a numericSort) Less(i, j int) bool {
-	// consider removing thousands separator and parsing LC_NUMERIC
-	l := strings.ToUpper(strings.TrimLeftFunc(a[i], unicode.IsSpace))
-	r := strings.ToUpper(strings.TrimLeftFunc(a[j], unicode.IsSpace))
-
-	// treat all non-numeric characters as zeros
-	ln, _ := strconv.ParseFloat(l, 64)
-	rn, _ := strconv.ParseFloat(r, 64)
-
-	return ln < rn
-}
-
-var errNotOrdered = errors.New("not ordered")
+var (
+	errNotOrdered = errors.New("not ordered")
+	errSize       = errors.New("invalid buffer size")
+)
 
 type params struct {
-	outputFile   string
-	reverse      bool
-	ordered      bool
+	outputFile string
+	reverse    bool
+	ordered    bool
+	// diagnose reports the first unordered line when checking.
+	diagnose     bool
 	unique       bool
 	ignoreCase   bool
 	ignoreBlanks bool
 	numeric      bool
+	keys         []key
+	separator    string
+	stable       bool
+	merge        bool
+	// bufferSize is the memory for lines before they are sorted into
+	// temporary files. 0 means no limit.
+	bufferSize int64
+	tmpDir     string
 }
 
 type cmd struct {
@@ -113,6 +96,10 @@ type cmd struct {
 	stderr io.Writer
 	params params
 	args   []string
+
+	// keys are the keys of params, with the global options applied.
+	keys  []key
+	temps []*os.File
 }
 
 func command(stdin io.ReadCloser, stdout, stderr io.Writer, p params, args []string) *cmd {
@@ -125,146 +112,267 @@ func command(stdin io.ReadCloser, stdout, stderr io.Writer, p params, args []str
 	}
 }
 
-func (c *cmd) run() error {
-	// Input files
-	from := []io.ReadCloser{}
-	for _, v := range c.args {
-		f, err := os.Open(v)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		from = append(from, f)
+// parseFlags parses the Unix-style args of sort into its params and
+// inputs.
+func parseFlags(args []string, stderr io.Writer) (params, []string, error) {
+	var (
+		p          params
+		sortKeys   keys
+		bufferSize string
+	)
+	f := flag.NewFlagSet("sort", flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&p.reverse, "r", false, "Reverse the result of comparisons.")
+	f.BoolVar(&p.diagnose, "c", false, "Check that the single input file is ordered and report the first unordered line.")
+	f.BoolVar(&p.ordered, "C", false, "Check that the single input file is ordered. No warnings.")
+	f.BoolVar(&p.unique, "u", false, "Unique keys. Suppress all lines that have a key that is equal to an already processed one.")
+	f.BoolVar(&p.ignoreCase, "f", false, "Fold lower case to upper case character.")
+	f.BoolVar(&p.ignoreBlanks, "b", false, "Ignore leading blank characters when comparing lines.")
+	f.BoolVar(&p.numeric, "n", false, "Compare according to string numerical value.")
+	f.StringVar(&p.outputFile, "o", "", "Specify the name of an output file to be used instead of the standard output.")
+	f.StringVar(&p.separator, "t", "", "Separate fields by SEP instead of blanks.")
+	f.BoolVar(&p.stable, "s", false, "Stable sort: keep lines with equal keys in input order.")
+	f.BoolVar(&p.merge, "m", false, "Merge inputs which are already sorted.")
+	f.StringVar(&bufferSize, "S", "16M", "Memory for lines before they go to temporary files.")
+	f.StringVar(&p.tmpDir, "T", "", "Directory for temporary files.")
+	f.Var(&sortKeys, "k", "Sort by KEY; may be given more than once.")
+	if err := f.Parse(unixflag.FlagSetArgsToGoArgs(f, args)); err != nil {
+		return params{}, nil, err
 	}
-
-	if len(c.args) == 0 {
-		from = append(from, c.stdin)
+	size, err := parseSize(bufferSize)
+	if err != nil {
+		return params{}, nil, err
 	}
+	p.bufferSize, p.keys = size, sortKeys
+	return p, f.Args(), nil
+}
 
-	// Read unicode string from input
-	fileContents := []string{}
-	for _, f := range from {
-		bytes, err := io.ReadAll(f)
-		if err != nil {
-			return err
-		}
-		s := string(bytes)
-		fileContents = append(fileContents, s)
-		// Add a separator between files if the file is not newline
-		// terminated. Prevents concatenating lines between files.
-		if len(s) > 0 && s[len(s)-1] != '\n' {
-			fileContents = append(fileContents, "\n")
-		}
+// parseSize parses the argument of -S.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
 	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q", errSize, s)
+	}
+	return n * mult, nil
+}
 
-	s := strings.Join(fileContents, "")
-	if len(s) > 0 && s[len(s)-1] == '\n' {
-		s = s[:len(s)-1] // remove newline terminator
+// setKeys applies the global options to keys without options. Without
+// keys, the whole line is the key.
+func (c *cmd) setKeys() {
+	p := c.params
+	c.keys = nil
+	keys := p.keys
+	if len(keys) == 0 {
+		keys = []key{{startField: 1, startChar: 1, global: true}}
 	}
+	for _, k := range keys {
+		if k.global {
+			k.startBlanks, k.endBlanks = p.ignoreBlanks, p.ignoreBlanks
+			k.fold, k.numeric, k.reverse = p.ignoreCase, p.numeric, p.reverse
+		}
+		c.keys = append(c.keys, k)
+	}
+}
 
-	if c.params.ordered {
-		// if ordered is true, set ignoreBlanks to false to be consistent with coreutils
-		// see https://github.com/coreutils/coreutils/blob/d53190ed46a55f599800ebb2d8ddfe38205dbd24/src/sort.c#L4147
-		c.params.ignoreBlanks = false
-		lines := strings.Split(s, "\n")
-		if !sort.IsSorted(c.sortInterface(lines)) {
-			return errNotOrdered
+// compare compares lines by their keys and then, unless the sort is
+// stable or unique, as a whole.
+func (c *cmd) compare(a, b string) int {
+	for i := range c.keys {
+		k := &c.keys[i]
+		if r := k.compare(k.extract(a, c.params.separator), k.extract(b, c.params.separator)); r != 0 {
+			return r
 		}
+	}
+	if c.params.stable || c.params.unique {
+		return 0
+	}
+	r := strings.Compare(a, b)
+	if c.params.reverse {
+		return -r
+	}
+	return r
+}
 
-		if c.params.unique && len(lines) > 1 {
-			for i := 1; i < len(lines); i++ {
-				if c.params.ignoreCase && strings.EqualFold(lines[i], lines[i-1]) {
-					return errNotOrdered
-				} else if lines[i] == lines[i-1] {
+// check checks that the lines of inputs are ordered and, with -u, unique.
+// With -c, the first line out of order is reported.
+func (c *cmd) check(inputs []io.Reader, names []string) error {
+	var prev string
+	first := true
+	for i, in := range inputs {
+		r := bufio.NewReader(in)
+		for n := 1; ; n++ {
+			l, err := readLine(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !first {
+				if r := c.compare(prev, l); r > 0 || (r == 0 && c.params.unique) {
+					if c.params.diagnose {
+						fmt.Fprintf(c.stderr, "sort: %s:%d: disorder: %s\n", names[i], n, l)
+					}
 					return errNotOrdered
 				}
 			}
+			prev, first = l, false
 		}
+	}
+	return nil
+}
 
+// sort sorts the lines of inputs into write. Lines beyond the buffer size
+// are sorted into temporary files, which are merged.
+func (c *cmd) sort(inputs []io.Reader, write func(string) error) error {
+	var (
+		lines []string
+		size  int64
+		files []*os.File
+	)
+	for _, in := range inputs {
+		r := bufio.NewReader(in)
+		for {
+			l, err := readLine(r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			lines = append(lines, l)
+			size += int64(len(l)) + lineOverhead
+			if c.params.bufferSize > 0 && size >= c.params.bufferSize {
+				f, err := c.spill(lines)
+				if err != nil {
+					return err
+				}
+				files = append(files, f)
+				lines, size = nil, 0
+			}
+		}
+	}
+	if len(files) == 0 {
+		c.sortLines(lines)
+		for _, l := range lines {
+			if err := write(l); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
-
-	if err := c.writeOutput(c.stdout, c.sortAlgorithm(s)); err != nil {
-		return err
+	if len(lines) > 0 {
+		f, err := c.spill(lines)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
 	}
-	return nil
+	return c.mergeFiles(files, write)
 }
 
-func (c *cmd) sortInterface(lines []string) sort.Interface {
-	var si sort.Interface
-	switch {
-	case c.params.ignoreBlanks && c.params.ignoreCase:
-		si = ignoreBlanksCaseSort(lines)
-	case c.params.ignoreBlanks:
-		si = ignoreBlanksSort(lines)
-	case c.params.ignoreCase:
-		si = ignoreCaseSort(lines)
-	case c.params.numeric:
-		si = numericSort(lines)
-	default:
-		si = sort.StringSlice(lines)
-	}
+func (c *cmd) run() error {
+	defer c.cleanup()
+	c.setKeys()
 
-	return si
-}
+	// Input files
+	inputs := []io.Reader{}
+	names := c.args
+	for _, v := range c.args {
+		if v == "-" {
+			inputs = append(inputs, c.stdin)
+			continue
+		}
+		f, err := os.Open(v)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f)
+	}
+	if len(c.args) == 0 {
+		inputs, names = append(inputs, c.stdin), []string{"-"}
+	}
 
-func (c *cmd) sortAlgorithm(s string) string {
-	if len(s) == 0 {
-		return "" // edge case mimics coreutils
+	if c.params.ordered || c.params.diagnose {
+		// if ordered is true, set ignoreBlanks to false to be consistent with coreutils
+		// see https://github.com/coreutils/coreutils/blob/d53190ed46a55f599800ebb2d8ddfe38205dbd24/src/sort.c#L4147
+		c.params.ignoreBlanks = false
+		c.setKeys()
+		return c.check(inputs, names)
 	}
-	lines := strings.Split(s, "\n")
-	si := c.sortInterface(lines)
 
-	if c.params.reverse {
-		sort.Sort(sort.Reverse(si))
-	} else {
-		sort.Sort(si)
+	if c.params.merge {
+		return c.writeOutput(func(write func(string) error) error {
+			return c.merge(inputs, write)
+		})
 	}
+	return c.writeOutput(func(write func(string) error) error {
+		return c.sort(inputs, write)
+	})
+}
 
-	if c.params.unique && len(lines) > 1 {
-		j := 1
-		for i := 1; i < len(lines); i++ {
-			if c.params.ignoreCase && c.params.ignoreBlanks {
-				l1 := strings.TrimLeftFunc(lines[i], unicode.IsSpace)
-				l2 := strings.TrimLeftFunc(lines[i-1], unicode.IsSpace)
-				if strings.EqualFold(l1, l2) {
-					continue
-				}
-			} else if c.params.ignoreCase && strings.EqualFold(lines[i], lines[j]) {
-				continue
-			} else if lines[i] == lines[i-1] {
-				continue
+// writeOutput writes the lines of gen to the output. The output file is
+// only opened with the first line, or at the end, since sort reads all
+// input before it writes and the output may be one of the inputs.
+func (c *cmd) writeOutput(gen func(write func(string) error) error) error {
+	o := &output{c: c}
+	var f *os.File
+	open := func() error {
+		to := c.stdout
+		if c.params.outputFile != "" {
+			var err error
+			if f, err = os.Create(c.params.outputFile); err != nil {
+				return err
 			}
-			lines[j] = lines[i]
-			j++
+			to = f
 		}
-
-		lines = lines[:j]
+		o.w = bufio.NewWriter(to)
+		return nil
 	}
-
-	return strings.Join(lines, "\n") + "\n" // append newline terminator
-}
-
-func (c *cmd) writeOutput(w io.Writer, s string) error {
-	to := w
-	if c.params.outputFile != "" {
-		f, err := os.Create(c.params.outputFile)
-		if err != nil {
-			return err
+	err := gen(func(l string) error {
+		if o.w == nil {
+			if err := open(); err != nil {
+				return err
+			}
+		}
+		return o.write(l)
+	})
+	if err == nil && o.w == nil {
+		err = open()
+	}
+	if err == nil {
+		err = o.w.Flush()
+	}
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
 		}
-		defer f.Close()
-		to = f
 	}
-
-	_, err := to.Write([]byte(s))
 	return err
 }
 
 func main() {
-	flag.Parse()
-	p := params{reverse: *reverse, ordered: *ordered, outputFile: *outputFile, unique: *unique,
-		ignoreCase: *ignoreCase, ignoreBlanks: *ignoreBlanks, numeric: *numeric}
-	if err := command(os.Stdin, os.Stdout, os.Stderr, p, flag.Args()).run(); err != nil {
+	p, args, err := parseFlags(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := command(os.Stdin, os.Stdout, os.Stderr, p, args).run(); err != nil {
 		if err == errNotOrdered {
 			os.Exit(1)
 		}
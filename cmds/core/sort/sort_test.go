@@ -7,6 +7,7 @@ package main
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -276,3 +277,202 @@ func TestSortFiles(t *testing.T) {
 		})
 	}
 }
+
+func mustKeys(t *testing.T, defs ...string) []key {
+	t.Helper()
+	var k keys
+	for _, d := range defs {
+		if err := k.Set(d); err != nil {
+			t.Fatalf("key %q: %v", d, err)
+		}
+	}
+	return k
+}
+
+func TestSortKeys(t *testing.T) {
+	const log = "web 10 GET\ndb 2 PUT\napi 10 DEL\ndb 30 GET\n"
+	for _, tt := range []struct {
+		name   string
+		params params
+		input  string
+		want   string
+	}{
+		{
+			name:   "numeric key",
+			params: params{keys: mustKeys(t, "2,2n")},
+			input:  log,
+			want:   "db 2 PUT\napi 10 DEL\nweb 10 GET\ndb 30 GET\n",
+		},
+		{
+			name:   "numeric key reversed then first field",
+			params: params{keys: mustKeys(t, "2,2nr", "1,1")},
+			input:  log,
+			want:   "db 30 GET\napi 10 DEL\nweb 10 GET\ndb 2 PUT\n",
+		},
+		{
+			name:   "global options apply to keys without options",
+			params: params{keys: mustKeys(t, "2,2"), numeric: true, reverse: true},
+			input:  log,
+			want:   "db 30 GET\nweb 10 GET\napi 10 DEL\ndb 2 PUT\n",
+		},
+		{
+			name:   "stable",
+			params: params{keys: mustKeys(t, "2,2n"), stable: true},
+			input:  log,
+			want:   "db 2 PUT\nweb 10 GET\napi 10 DEL\ndb 30 GET\n",
+		},
+		{
+			name:   "unique key",
+			params: params{keys: mustKeys(t, "1,1"), unique: true},
+			input:  log,
+			want:   "api 10 DEL\ndb 2 PUT\nweb 10 GET\n",
+		},
+		{
+			name:   "separator",
+			params: params{keys: mustKeys(t, "3n"), separator: ":"},
+			input:  "root:x:0:0\nnobody:x:65534:65534\nuser:x:1000:1000\n",
+			want:   "root:x:0:0\nuser:x:1000:1000\nnobody:x:65534:65534\n",
+		},
+		{
+			name:   "empty fields",
+			params: params{keys: mustKeys(t, "2,2"), separator: ","},
+			input:  "a,b\nb,,c\nc\n",
+			want:   "b,,c\nc\na,b\n",
+		},
+		{
+			name:   "characters",
+			params: params{keys: mustKeys(t, "1.3,1.4")},
+			input:  "xxba\nyyab\nzzaa\n",
+			want:   "zzaa\nyyab\nxxba\n",
+		},
+		{
+			name:   "blanks",
+			params: params{keys: mustKeys(t, "2.2b,2.2b")},
+			input:  "a    zb\nb ya\n",
+			want:   "b ya\na    zb\n",
+		},
+		{
+			name:   "numeric prefix",
+			params: params{numeric: true},
+			input:  "10M\n9K\n-1x\n",
+			want:   "-1x\n9K\n10M\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			if err := command(io.NopCloser(strings.NewReader(tt.input)), stdout, nil, tt.params, nil).run(); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("sort = %q, want: %q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "0", "a", "1.0", "1x", "1,2.x"} {
+		if _, err := parseKey(bad); !errors.Is(err, errKey) {
+			t.Errorf("parseKey(%q) = %v, want %v", bad, err, errKey)
+		}
+	}
+}
+
+func TestSortExternal(t *testing.T) {
+	tmpDir := t.TempDir()
+	var in, want []string
+	for i := 0; i < 2000; i++ {
+		in = append(in, fmt.Sprintf("%d x", (i*7919)%1000))
+	}
+	for i := 0; i < 1000; i++ {
+		want = append(want, fmt.Sprintf("%d x", i))
+	}
+
+	// A buffer of 50 lines makes 40 temporary files, which take two
+	// passes to merge.
+	p := params{numeric: true, unique: true, bufferSize: 50 * (5 + lineOverhead), tmpDir: tmpDir, outputFile: filepath.Join(tmpDir, "out")}
+	input := filepath.Join(tmpDir, "in")
+	if err := os.WriteFile(input, []byte(strings.Join(in, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := command(nil, nil, nil, p, []string{input}).run(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(p.outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != strings.Join(want, "\n")+"\n" {
+		t.Errorf("external sort gave %d bytes, want the numbers 0 to 999", len(got))
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 2 {
+		t.Errorf("temporary files were left in %s: %v", tmpDir, entries)
+	}
+
+	// Sorting a file onto itself.
+	p = params{outputFile: input, bufferSize: 100}
+	if err := command(nil, nil, nil, p, []string{input}).run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(input); len(got) != len(strings.Join(in, "\n"))+1 {
+		t.Errorf("sorting in place gave %d bytes, want %d", len(got), len(strings.Join(in, "\n"))+1)
+	}
+
+	if _, err := parseSize("1X"); !errors.Is(err, errSize) {
+		t.Errorf("parseSize(1X) = %v, want %v", err, errSize)
+	}
+	if n, err := parseSize("2M"); n != 2<<20 || err != nil {
+		t.Errorf("parseSize(2M) = %d, %v, want %d", n, err, 2<<20)
+	}
+}
+
+func TestSortMerge(t *testing.T) {
+	tmpDir := t.TempDir()
+	a, b := filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")
+	if err := os.WriteFile(a, []byte("1 a\n3 a\n5 a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("2 b\n3 b\n4 b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout := &bytes.Buffer{}
+	p := params{merge: true, keys: mustKeys(t, "1,1n"), stable: true}
+	if err := command(nil, stdout, nil, p, []string{a, b}).run(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 a\n2 b\n3 a\n3 b\n4 b\n5 a\n"; stdout.String() != want {
+		t.Errorf("sort -m = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	const input = "web:10\ndb:2\napi:10\nlb:30\n"
+	for _, tt := range []struct {
+		args   []string
+		want   string
+		stderr string
+		err    error
+	}{
+		{args: []string{"-t:", "-k2n"}, want: "db:2\napi:10\nweb:10\nlb:30\n"},
+		{args: []string{"-t:", "-k2,2nr"}, want: "lb:30\napi:10\nweb:10\ndb:2\n"},
+		{args: []string{"-t", ":", "-sk", "2,2nr"}, want: "lb:30\nweb:10\napi:10\ndb:2\n"},
+		{args: []string{"-t:", "-k1n"}, want: "api:10\ndb:2\nlb:30\nweb:10\n"},
+		{args: []string{"-r"}, want: "web:10\nlb:30\ndb:2\napi:10\n"},
+		{args: []string{"-nr", "-t:", "-k2"}, want: "lb:30\nweb:10\napi:10\ndb:2\n"},
+		{args: []string{"-c"}, stderr: "sort: -:2: disorder: db:2\n", err: errNotOrdered},
+		{args: []string{"-C"}, err: errNotOrdered},
+		{args: []string{"-S1X"}, err: errSize},
+	} {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			p, args, err := parseFlags(tt.args, &stderr)
+			if err == nil {
+				err = command(io.NopCloser(strings.NewReader(input)), &stdout, &stderr, p, args).run()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("sort %q = %v, want %v", tt.args, err, tt.err)
+			}
+			if stdout.String() != tt.want || stderr.String() != tt.stderr {
+				t.Errorf("sort %q printed %q and %q, want %q and %q", tt.args, stdout.String(), stderr.String(), tt.want, tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type (
+	// directTCPIPReq is the payload of a direct-tcpip channel, for local
+	// forwarding (ssh -L).
+	directTCPIPReq struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	// tcpipForwardReq is the payload of tcpip-forward and
+	// cancel-tcpip-forward requests, for remote forwarding (ssh -R).
+	tcpipForwardReq struct {
+		Addr string
+		Port uint32
+	}
+	tcpipForwardReply struct {
+		Port uint32
+	}
+	// forwardedTCPIPReq is the payload of a forwarded-tcpip channel.
+	forwardedTCPIPReq struct {
+		Addr     string
+		Port     uint32
+		OrigAddr string
+		OrigPort uint32
+	}
+)
+
+// forwarding returns whether local and remote forwarding are allowed.
+func (c *cmd) forwarding() (local, remote bool, err error) {
+	switch c.forward {
+	case "none", "":
+	case "local":
+		local = true
+	case "remote":
+		remote = true
+	case "all":
+		local, remote = true, true
+	default:
+		return false, false, fmt.Errorf("-forward %q: must be none, local, remote or all", c.forward)
+	}
+	return local, remote, nil
+}
+
+// permitted reports whether local forwarding may connect to a host and port.
+func (c *cmd) permitted(host string, port uint32) bool {
+	if local, _, _ := c.forwarding(); !local {
+		return false
+	}
+	if c.permit == "" {
+		return true
+	}
+	for _, p := range strings.Split(c.permit, ",") {
+		h, pt, err := net.SplitHostPort(strings.TrimSpace(p))
+		if err != nil {
+			continue
+		}
+		hok, _ := path.Match(h, host)
+		pok := pt == "*" || pt == strconv.Itoa(int(port))
+		if hok && pok {
+			return true
+		}
+	}
+	return false
+}
+
+// pipe copies between two connections until either is closed.
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	cp := func(dst io.Writer, src io.Reader) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	a.Close()
+	b.Close()
+}
+
+func (cn *conn) directTCPIP(newChannel ssh.NewChannel) {
+	req := &directTCPIPReq{}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), req); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "bad direct-tcpip request")
+		return
+	}
+	if !cn.permitted(req.Host, req.Port) {
+		log.Printf("%v: forwarding to %s:%d refused", cn.sc.RemoteAddr(), req.Host, req.Port)
+		newChannel.Reject(ssh.Prohibited, "forwarding refused")
+		return
+	}
+	dst, err := net.Dial("tcp", net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		dst.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	dprintf("forwarding %v to %v", cn.sc.RemoteAddr(), dst.RemoteAddr())
+	pipe(channel, dst)
+}
+
+// forwards are the listeners of the remote forwardings of a connection.
+type forwards struct {
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+}
+
+func (f *forwards) add(key string, l net.Listener) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.listeners == nil {
+		f.listeners = map[string]net.Listener{}
+	}
+	f.listeners[key] = l
+}
+
+func (f *forwards) remove(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.listeners[key]
+	if ok {
+		l.Close()
+		delete(f.listeners, key)
+	}
+	return ok
+}
+
+func (f *forwards) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range f.listeners {
+		l.Close()
+	}
+	f.listeners = nil
+}
+
+func (cn *conn) globalRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			port, err := cn.tcpipForward(req.Payload)
+			if err != nil {
+				log.Printf("%v: %v", cn.sc.RemoteAddr(), err)
+				req.Reply(false, nil)
+				break
+			}
+			req.Reply(true, ssh.Marshal(tcpipForwardReply{port}))
+		case "cancel-tcpip-forward":
+			r := &tcpipForwardReq{}
+			ok := ssh.Unmarshal(req.Payload, r) == nil && cn.fwd.remove(net.JoinHostPort(r.Addr, strconv.Itoa(int(r.Port))))
+			req.Reply(ok, nil)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// tcpipForward listens for a remote forwarding, and returns the port.
+//
+// As OpenSSH does without GatewayPorts, the listener is always on the
+// loopback interface, so other hosts cannot use the forwarding. As it
+// does, only root may forward privileged ports.
+func (cn *conn) tcpipForward(payload []byte) (uint32, error) {
+	r := &tcpipForwardReq{}
+	if err := ssh.Unmarshal(payload, r); err != nil {
+		return 0, err
+	}
+	if _, remote, _ := cn.forwarding(); !remote {
+		return 0, fmt.Errorf("remote forwarding of %s:%d refused", r.Addr, r.Port)
+	}
+	if cn.user != nil && cn.user.UID != 0 && r.Port != 0 && r.Port < 1024 {
+		return 0, fmt.Errorf("remote forwarding of %s:%d refused: only root may forward ports below 1024", r.Addr, r.Port)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(int(r.Port))))
+	if err != nil {
+		return 0, err
+	}
+	port := uint32(l.Addr().(*net.TCPAddr).Port)
+	// The client cancels with the port it asked for.
+	cn.fwd.add(net.JoinHostPort(r.Addr, strconv.Itoa(int(r.Port))), l)
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			orig := c.RemoteAddr().(*net.TCPAddr)
+			channel, reqs, err := cn.sc.OpenChannel("forwarded-tcpip", ssh.Marshal(forwardedTCPIPReq{
+				Addr:     r.Addr,
+				Port:     port,
+				OrigAddr: orig.IP.String(),
+				OrigPort: uint32(orig.Port),
+			}))
+			if err != nil {
+				c.Close()
+				continue
+			}
+			go ssh.DiscardRequests(reqs)
+			go pipe(channel, c)
+		}
+	}()
+	return port, nil
+}
@@ -5,6 +5,10 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -12,8 +16,12 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 
+	"github.com/u-root/u-root/pkg/login"
+	"github.com/u-root/u-root/pkg/metrics"
 	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -30,6 +38,9 @@ type (
 	execReq struct {
 		Command string
 	}
+	subsystemReq struct {
+		Name string
+	}
 	exitStatusReq struct {
 		ExitStatus uint32
 	}
@@ -41,18 +52,32 @@ var (
 	privkey = flag.String("privatekey", "id_rsa", "Path of private key")
 	ip      = flag.String("ip", "0.0.0.0", "ip address to listen on")
 	port    = flag.String("port", "2022", "port to listen on")
+	users   = flag.Bool("users", false, "Also accept the keys in the ~/.ssh/authorized_keys of users in /etc/passwd, and run their sessions as them")
+	genkey  = flag.Bool("genkey", false, "Generate the private key, and save it, if it does not exist")
+	forward = flag.String("forward", "none", "Port forwarding to allow: none, local, remote or all")
+	permit  = flag.String("permitopen", "", "Comma separated host:port patterns local forwarding may connect to, all if empty")
+	metric  = flag.String("metrics", "", "Address to serve Prometheus metrics on at /metrics, e.g. :9100; none if empty")
 	dprintf = func(string, ...interface{}) {}
 )
 
-// start a command
-// TODO: use /etc/passwd, but the Go support for that is incomplete
-func runCommand(c ssh.Channel, p *pty.Pty, cmd string, args ...string) error {
+var (
+	connections       = metrics.NewCounter("sshd_connections_total", "Number of connections accepted.")
+	handshakeFailures = metrics.NewCounter("sshd_handshake_failures_total", "Number of connections that failed to handshake or authenticate.")
+	loggedIn          = metrics.NewGauge("sshd_connections_active", "Number of connections logged in.")
+	channels          = metrics.NewCounterVec("sshd_channels_total", "Number of channels opened, by type.", "type")
+)
+
+// start a command, prepared by prep to run as the logged in user
+func runCommand(c ssh.Channel, p *pty.Pty, prep func(*exec.Cmd) error, cmd string, args ...string) error {
 	var ps *os.ProcessState
 	defer c.Close()
 
 	if p != nil {
 		log.Printf("Executing PTY command %s %v", cmd, args)
 		p.Command(cmd, args...)
+		if err := prep(p.C); err != nil {
+			return err
+		}
 		if err := p.C.Start(); err != nil {
 			dprintf("Failed to execute: %v", err)
 			return err
@@ -64,6 +89,9 @@ func runCommand(c ssh.Channel, p *pty.Pty, cmd string, args ...string) error {
 	} else {
 		e := exec.Command(cmd, args...)
 		e.Stdin, e.Stdout, e.Stderr = c, c, c
+		if err := prep(e); err != nil {
+			return err
+		}
 		log.Printf("Executing non-PTY command %s %v", cmd, args)
 		// execute command and wait for response
 		if err := e.Run(); err != nil {
@@ -125,54 +153,129 @@ func init() {
 	}
 }
 
-func session(chans <-chan ssh.NewChannel) {
+// conn is a connection of a logged in user.
+type conn struct {
+	*cmd
+	sc *ssh.ServerConn
+	// user is the user of the sessions, or nil to run them as sshd.
+	user *login.User
+	fwd  *forwards
+}
+
+// prep prepares a command to run as the user.
+func (cn *conn) prep(e *exec.Cmd) error {
+	if cn.user == nil {
+		return nil
+	}
+	return cn.asUser(e, cn.user)
+}
+
+func (cn *conn) shell() string {
+	if cn.user == nil {
+		return shell
+	}
+	return userShell(cn.user)
+}
+
+// sftp serves the files of the system on a channel. Since it runs in sshd
+// itself, only the user sshd runs as may use it.
+func (cn *conn) sftp(channel ssh.Channel) error {
+	defer channel.Close()
+	err := sftp.NewServer(channel).Serve()
+	var code uint32
+	if err != nil {
+		code = 1
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(exitStatusReq{code}))
+	return err
+}
+
+func (cn *conn) session(newChannel ssh.NewChannel) {
 	var p *pty.Pty
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		log.Printf("Could not accept channel: %v", err)
+		return
+	}
+
+	// Sessions have out-of-band requests such as "shell",
+	// "pty-req" and "env".
+	for req := range requests {
+		dprintf("Request %v", req.Type)
+		switch req.Type {
+		case "shell":
+			// Reply before the command runs: clients wait for the reply
+			// before they send input.
+			req.Reply(true, nil)
+			go func(p *pty.Pty) {
+				if err := runCommand(channel, p, cn.prep, cn.shell()); err != nil {
+					log.Printf("sshd: %v", err)
+				}
+			}(p)
+		case "exec":
+			e := &execReq{}
+			if err := ssh.Unmarshal(req.Payload, e); err != nil {
+				log.Printf("sshd: %v", err)
+				req.Reply(false, nil)
+				break
+			}
+			// Execute command using user's shell. This is what OpenSSH does
+			// so it's the least surprising to the user.
+			req.Reply(true, nil)
+			go func(p *pty.Pty) {
+				if err := runCommand(channel, p, cn.prep, cn.shell(), "-c", e.Command); err != nil {
+					log.Printf("sshd: %v", err)
+				}
+			}(p)
+		case "subsystem":
+			s := &subsystemReq{}
+			if err := ssh.Unmarshal(req.Payload, s); err != nil || s.Name != "sftp" {
+				log.Printf("sshd: unknown subsystem %q", s.Name)
+				req.Reply(false, nil)
+				break
+			}
+			if cn.user != nil && cn.user.UID != os.Getuid() {
+				log.Printf("sshd: sftp for %s refused: only UID %d may use it", cn.user.Name, os.Getuid())
+				req.Reply(false, nil)
+				break
+			}
+			req.Reply(true, nil)
+			go func() {
+				if err := cn.sftp(channel); err != nil {
+					log.Printf("sftp: %v", err)
+				}
+			}()
+		case "pty-req":
+			p, err = newPTY(req.Payload)
+			req.Reply(err == nil, nil)
+		default:
+			log.Printf("Not handling req %v %q", req, string(req.Payload))
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// serve services the channels and requests of a connection until it is
+// closed.
+func (cn *conn) serve(chans <-chan ssh.NewChannel, reqs <-chan *ssh.Request) {
+	defer cn.fwd.close()
+	go cn.globalRequests(reqs)
 	// Service the incoming Channel channel.
 	for newChannel := range chans {
 		// Channels have a type, depending on the application level
 		// protocol intended. In the case of a shell, the type is
 		// "session" and ServerShell may be used to present a simple
 		// terminal interface.
-		if newChannel.ChannelType() != "session" {
+		switch newChannel.ChannelType() {
+		case "session":
+			channels.With("session").Inc()
+			go cn.session(newChannel)
+		case "direct-tcpip":
+			channels.With("direct-tcpip").Inc()
+			go cn.directTCPIP(newChannel)
+		default:
 			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
-			continue
-		}
-		channel, requests, err := newChannel.Accept()
-		if err != nil {
-			log.Printf("Could not accept channel: %v", err)
-			continue
 		}
-
-		// Sessions have out-of-band requests such as "shell",
-		// "pty-req" and "env".  Here we handle only the
-		// "shell" request.
-		go func(in <-chan *ssh.Request) {
-			for req := range in {
-				dprintf("Request %v", req.Type)
-				switch req.Type {
-				case "shell":
-					err := runCommand(channel, p, shell)
-					req.Reply(true, []byte(fmt.Sprintf("%v", err)))
-				case "exec":
-					e := &execReq{}
-					if err := ssh.Unmarshal(req.Payload, e); err != nil {
-						log.Printf("sshd: %v", err)
-						break
-					}
-					// Execute command using user's shell. This is what OpenSSH does
-					// so it's the least surprising to the user.
-					err := runCommand(channel, p, shell, "-c", e.Command)
-					req.Reply(true, []byte(fmt.Sprintf("%v", err)))
-				case "pty-req":
-					p, err = newPTY(req.Payload)
-					req.Reply(err == nil, nil)
-				default:
-					log.Printf("Not handling req %v %q", req, string(req.Payload))
-					req.Reply(false, nil)
-				}
-			}
-		}(requests)
-
 	}
 }
 
@@ -182,6 +285,11 @@ type params struct {
 	ip      string
 	port    string
 	debug   bool
+	users   bool
+	genkey  bool
+	forward string
+	permit  string
+	metrics string
 }
 
 func parseParams() params {
@@ -191,98 +299,192 @@ func parseParams() params {
 		privkey: *privkey,
 		ip:      *ip,
 		port:    *port,
+		users:   *users,
+		genkey:  *genkey,
+		forward: *forward,
+		permit:  *permit,
+		metrics: *metric,
 	}
 }
 
 type cmd struct {
 	params
+	db *login.DB
 }
 
 func command(p params) *cmd {
 	return &cmd{
 		params: p,
+		db:     &login.DB{Root: "/"},
 	}
 }
 
-func (c *cmd) run() error {
-	if c.debug {
-		dprintf = log.Printf
+// hostKey reads the private key, or generates and saves one if it does not
+// exist and genkey is set, so clients see the same key after a restart.
+func (c *cmd) hostKey() (ssh.Signer, error) {
+	privateBytes, err := os.ReadFile(c.privkey)
+	if errors.Is(err, os.ErrNotExist) && c.genkey {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		block, err := ssh.MarshalPrivateKey(key, "")
+		if err != nil {
+			return nil, err
+		}
+		privateBytes = pem.EncodeToMemory(block)
+		if err := os.MkdirAll(filepath.Dir(c.privkey), 0o700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(c.privkey, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(privateBytes); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+		log.Printf("Generated host key %s", c.privkey)
+	} else if err != nil {
+		return nil, err
 	}
+
+	private, err := ssh.ParsePrivateKey(privateBytes)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Host key %s", ssh.FingerprintSHA256(private.PublicKey()))
+	return private, nil
+}
+
+// config returns the configuration of the server.
+func (c *cmd) config() (*ssh.ServerConfig, error) {
 	// Public key authentication is done by comparing
 	// the public key of a received connection
 	// with the entries in the authorized_keys file.
 	authorizedKeysBytes, err := os.ReadFile(c.keys)
+	if errors.Is(err, os.ErrNotExist) && c.users {
+		// The users' keys may be all there is.
+		authorizedKeysBytes, err = nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	authorizedKeysMap := map[string]bool{}
 	for len(authorizedKeysBytes) > 0 {
 		pubKey, _, _, rest, err := ssh.ParseAuthorizedKey(authorizedKeysBytes)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		authorizedKeysMap[string(pubKey.Marshal())] = true
 		authorizedKeysBytes = rest
 	}
 
+	if _, _, err := c.forwarding(); err != nil {
+		return nil, err
+	}
+
 	// An SSH server is represented by a ServerConfig, which holds
 	// certificate details and handles authentication of ServerConns.
 	config := &ssh.ServerConfig{
 		// Remove to disable public key auth.
-		PublicKeyCallback: func(c ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
+		PublicKeyCallback: func(meta ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
+			// Record the public key used for authentication.
+			perms := &ssh.Permissions{
+				Extensions: map[string]string{
+					"pubkey-fp": ssh.FingerprintSHA256(pubKey),
+				},
+			}
 			if authorizedKeysMap[string(pubKey.Marshal())] {
-				return &ssh.Permissions{
-					// Record the public key used for authentication.
-					Extensions: map[string]string{
-						"pubkey-fp": ssh.FingerprintSHA256(pubKey),
-					},
-				}, nil
+				return perms, nil
+			}
+			if c.users {
+				u, err := c.db.AuthenticateKey(meta.User(), pubKey)
+				if err == nil {
+					perms.Extensions["user"] = u.Name
+					return perms, nil
+				}
+				dprintf("key of %q: %v", meta.User(), err)
 			}
-			return nil, fmt.Errorf("unknown public key for %q", c.User())
+			return nil, fmt.Errorf("unknown public key for %q", meta.User())
 		},
 	}
 
-	privateBytes, err := os.ReadFile(c.privkey)
-	if err != nil {
-		return err
-	}
-
-	private, err := ssh.ParsePrivateKey(privateBytes)
+	private, err := c.hostKey()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	config.AddHostKey(private)
+	return config, nil
+}
 
-	// Once a ServerConfig has been configured, connections can be
-	// accepted.
-	listener, err := net.Listen("tcp", net.JoinHostPort(c.ip, c.port))
-	if err != nil {
-		return err
-	}
+// serve accepts connections until the listener is closed.
+func (c *cmd) serve(listener net.Listener, config *ssh.ServerConfig) error {
 	for {
 		nConn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return err
+		}
 		if err != nil {
 			log.Printf("failed to accept incoming connection: %s", err)
 			continue
 		}
 
-		// Before use, a handshake must be performed on the incoming
-		// net.Conn.
-		conn, chans, reqs, err := ssh.NewServerConn(nConn, config)
-		if err != nil {
-			log.Printf("failed to handshake: %v", err)
-			continue
+		connections.Inc()
+		go c.handshake(nConn, config)
+	}
+}
+
+func (c *cmd) handshake(nConn net.Conn, config *ssh.ServerConfig) {
+	// Before use, a handshake must be performed on the incoming
+	// net.Conn.
+	sc, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		handshakeFailures.Inc()
+		log.Printf("failed to handshake: %v", err)
+		return
+	}
+	cn := &conn{cmd: c, sc: sc, fwd: &forwards{}}
+	if name, ok := sc.Permissions.Extensions["user"]; ok {
+		if cn.user, err = c.db.Lookup(name); err != nil {
+			log.Printf("%v: %v", sc.RemoteAddr(), err)
+			sc.Close()
+			return
 		}
-		log.Printf("%v logged in with key %s", conn.RemoteAddr(), conn.Permissions.Extensions["pubkey-fp"])
+	}
+	log.Printf("%v logged in as %q with key %s", sc.RemoteAddr(), sc.User(), sc.Permissions.Extensions["pubkey-fp"])
+	loggedIn.Inc()
+	defer loggedIn.Dec()
+
+	cn.serve(chans, reqs)
+}
 
-		// The incoming Request channel must be serviced.
-		go ssh.DiscardRequests(reqs)
+func (c *cmd) run() error {
+	if c.debug {
+		dprintf = log.Printf
+	}
+	config, err := c.config()
+	if err != nil {
+		return err
+	}
+	if c.metrics != "" {
+		go func() {
+			log.Fatal(metrics.ListenAndServe(c.metrics))
+		}()
+	}
 
-		go session(chans)
+	// Once a ServerConfig has been configured, connections can be
+	// accepted.
+	listener, err := net.Listen("tcp", net.JoinHostPort(c.ip, c.port))
+	if err != nil {
+		return err
 	}
+	return c.serve(listener, config)
 }
 
 func main() {
@@ -5,12 +5,18 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/u-root/u-root/pkg/login"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -182,3 +188,299 @@ func TestSessionRun(t *testing.T) {
 		t.Errorf("expected hello u-root, got %q", string(b[:n]))
 	}
 }
+
+// startServer starts a server on a free port, and returns a client config
+// with the test key.
+func startServer(t *testing.T, c *cmd) (string, *ssh.ClientConfig) {
+	t.Helper()
+	config, err := c.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go c.serve(l, config)
+
+	pk, err := os.ReadFile("./testdata/id_rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.ParsePrivateKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.Addr().String(), &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	}
+}
+
+func TestGenKey(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "etc", "ssh", "host_key")
+	c := command(params{privkey: key, genkey: true})
+	s1, err := c.hostKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(key)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("host key: %v, %v", fi, err)
+	}
+	s2, err := c.hostKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s1.PublicKey().Marshal(), s2.PublicKey().Marshal()) {
+		t.Errorf("host key changed after a restart")
+	}
+}
+
+// echoServer echoes every connection.
+func echoServer(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+func echo(t *testing.T, c net.Conn) {
+	t.Helper()
+	defer c.Close()
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(c, b); err != nil || string(b) != "ping" {
+		t.Errorf("echo = %q, %v, want ping", b, err)
+	}
+}
+
+func TestLocalForward(t *testing.T) {
+	e := echoServer(t)
+	_, port, _ := net.SplitHostPort(e.Addr().String())
+	for _, tt := range []struct {
+		forward, permit string
+		ok              bool
+	}{
+		{forward: "none"},
+		{forward: "remote"},
+		{forward: "local", ok: true},
+		{forward: "all", permit: "127.0.0.*:" + port, ok: true},
+		{forward: "all", permit: "localhost:*, 127.0.0.1:*", ok: true},
+		{forward: "local", permit: "127.0.0.1:1"},
+	} {
+		t.Run(tt.forward+" "+tt.permit, func(t *testing.T) {
+			addr, cfg := startServer(t, command(params{
+				privkey: "./testdata/id_rsa",
+				keys:    "./testdata/id_rsa.pub",
+				forward: tt.forward,
+				permit:  tt.permit,
+			}))
+			clt := connect(t, addr, cfg)
+			defer clt.Close()
+			c, err := clt.Dial("tcp", e.Addr().String())
+			if !tt.ok {
+				if err == nil {
+					c.Close()
+					t.Fatalf("forwarding to %v allowed", e.Addr())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			echo(t, c)
+		})
+	}
+}
+
+func TestBadForward(t *testing.T) {
+	c := command(params{privkey: "./testdata/id_rsa", keys: "./testdata/id_rsa.pub", forward: "some"})
+	if _, err := c.config(); err == nil {
+		t.Errorf("config with -forward some succeeded")
+	}
+}
+
+func TestRemoteForward(t *testing.T) {
+	for _, forward := range []string{"local", "remote"} {
+		t.Run(forward, func(t *testing.T) {
+			addr, cfg := startServer(t, command(params{
+				privkey: "./testdata/id_rsa",
+				keys:    "./testdata/id_rsa.pub",
+				forward: forward,
+			}))
+			clt := connect(t, addr, cfg)
+			defer clt.Close()
+			l, err := clt.Listen("tcp", "0.0.0.0:0")
+			if forward == "local" {
+				if err == nil {
+					t.Errorf("remote forwarding allowed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			go func() {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				io.Copy(c, c)
+				c.Close()
+			}()
+			// The server listens on the loopback interface only.
+			_, port, _ := net.SplitHostPort(l.Addr().String())
+			c, err := net.Dial("tcp", net.JoinHostPort("localhost", port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			echo(t, c)
+		})
+	}
+}
+
+func TestRemoteForwardPrivileged(t *testing.T) {
+	cn := &conn{
+		cmd:  command(params{forward: "remote"}),
+		user: &login.User{Name: "user", UID: 1000},
+		fwd:  &forwards{},
+	}
+	defer cn.fwd.close()
+	payload := ssh.Marshal(tcpipForwardReq{Addr: "localhost", Port: 80})
+	if _, err := cn.tcpipForward(payload); err == nil || !strings.Contains(err.Error(), "below 1024") {
+		t.Errorf("forwarding port 80 for UID 1000: got %v, want a refusal", err)
+	}
+	// Any free port may be chosen, however.
+	payload = ssh.Marshal(tcpipForwardReq{Addr: "localhost", Port: 0})
+	if port, err := cn.tcpipForward(payload); err != nil || port == 0 {
+		t.Errorf("forwarding port 0 for UID 1000: got %d, %v, want a port", port, err)
+	}
+}
+
+func TestSFTPSubsystem(t *testing.T) {
+	addr, cfg := startServer(t, command(params{
+		privkey: "./testdata/id_rsa",
+		keys:    "./testdata/id_rsa.pub",
+	}))
+	clt := connect(t, addr, cfg)
+	defer clt.Close()
+	s, err := clt.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.RequestSubsystem("nope"); err == nil {
+		t.Errorf("subsystem nope accepted")
+	}
+
+	s, err = clt.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	w, err := s.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RequestSubsystem("sftp"); err != nil {
+		t.Fatal(err)
+	}
+	// INIT, version 3.
+	if _, err := w.Write([]byte{0, 0, 0, 5, 1, 0, 0, 0, 3}); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 9)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+	// VERSION, version 3.
+	if want := []byte{0, 0, 0, 5, 2, 0, 0, 0, 3}; !bytes.Equal(b, want) {
+		t.Errorf("reply %v, want %v", b, want)
+	}
+	// The server ends the session when the client closes its input.
+	w.Close()
+	if b, err := io.ReadAll(r); err != nil || len(b) != 0 {
+		t.Errorf("after close: %q, %v, want EOF", b, err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	root := t.TempDir()
+	pub, err := os.ReadFile("./testdata/id_rsa.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys are read from the home directory under root, but the shell
+	// starts in the home directory itself.
+	uid, gid := os.Getuid(), os.Getgid()
+	home := filepath.Join(root, "home/me")
+	if err := os.MkdirAll(home, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"etc/passwd": fmt.Sprintf("me:x:%d:%d::%s:/bin/sh\nyou:x:%d:%d::/home/you:/bin/sh\n", uid, gid, home, uid+1, gid),
+		"etc/shadow": "me:!:19000::::::\nyou:!:19000::::::\n",
+		filepath.Join(home, ".ssh/authorized_keys"): string(pub),
+	}
+	for name, data := range files {
+		f := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := command(params{privkey: "./testdata/id_rsa", keys: "nonexistent", users: true})
+	c.db.Root = root
+	addr, cfg := startServer(t, c)
+
+	cfg.User = "you"
+	if clt, err := ssh.Dial("tcp", addr, cfg); err == nil {
+		clt.Close()
+		t.Errorf("you logged in without an authorized key")
+	}
+
+	cfg.User = "me"
+	clt := connect(t, addr, cfg)
+	defer clt.Close()
+	s, err := clt.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	out, err := s.Output("echo $USER $HOME; pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("me %s\n%s\n", home, home); string(out) != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+}
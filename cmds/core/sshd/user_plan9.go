@@ -0,0 +1,20 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/u-root/u-root/pkg/login"
+)
+
+func (c *cmd) asUser(*exec.Cmd, *login.User) error {
+	return errors.New("sessions of other users are not supported on Plan 9")
+}
+
+func userShell(*login.User) string {
+	return shell
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/login"
+)
+
+// asUser makes a command run as a user, in their home directory.
+func (c *cmd) asUser(e *exec.Cmd, u *login.User) error {
+	cred, err := c.db.Credential(u)
+	if err != nil {
+		return err
+	}
+	if e.SysProcAttr == nil {
+		e.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	e.SysProcAttr.Credential = cred
+	if _, err := os.Stat(u.Home); err == nil {
+		e.Dir = u.Home
+	}
+	e.Env = login.Env(u)
+	if term := os.Getenv("TERM"); term != "" {
+		e.Env = append(e.Env, "TERM="+term)
+	}
+	return nil
+}
+
+func userShell(u *login.User) string {
+	if u.Shell == "" {
+		return login.DefaultShell
+	}
+	return u.Shell
+}
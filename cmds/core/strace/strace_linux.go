@@ -11,11 +11,22 @@
 //
 // Synopsis:
 //
-//	strace <command> [args...]
+//	strace [-f] [-o file] [-e trace=set] <command> [args...]
+//	strace [-f] [-o file] [-e trace=set] -p pid
 //
 // Description:
 //
-//	trace a single process given a command name.
+//	trace a process given a command name, or attach to a running process.
+//	Syscalls are printed with their decoded arguments; failing syscalls
+//	with their errno, e.g. -1 ENOENT (no such file or directory).
+//
+// Options:
+//
+//	-f: also trace the children and threads of the traced process
+//	-p: attach to the running process pid; interrupt to detach
+//	-o: write the trace to file instead of stderr
+//	-e: trace only a set of syscalls, e.g. trace=open,read or
+//	    trace=%file,%network; a leading ! excludes the set instead
 package main
 
 import (
@@ -29,41 +40,77 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/strace"
 )
 
-var errUsage = errors.New("usage: strace [-o <outputfile>] <command> [args...]")
+var (
+	errUsage     = errors.New("usage: strace [-f] [-o <outputfile>] [-e trace=<set>] {-p <pid> | <command> [args...]}")
+	errQualifier = errors.New("only trace= is supported by -e")
+)
 
 type params struct {
 	output string
+	follow bool
+	pid    int
+	expr   string
+}
+
+// filter parses an -e expression, trace=set or just set.
+func filter(expr string) (*strace.Filter, error) {
+	q, set, ok := strings.Cut(expr, "=")
+	if !ok {
+		set = q
+	} else if q != "trace" {
+		return nil, fmt.Errorf("%s: %w", q, errQualifier)
+	}
+	return strace.ParseFilter(set)
 }
 
 func run(stdin io.Reader, stdout, stderr io.Writer, p params, args ...string) error {
-	if len(args) < 1 {
+	if (p.pid == 0) == (len(args) == 0) {
 		return errUsage
 	}
 
-	c := exec.Command(args[0], args[1:]...)
-	c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
-
+	out := stderr
 	if p.output != "" {
 		f, err := os.Create(p.output)
 		if err != nil {
 			return fmt.Errorf("creating output file: %s: %w", p.output, err)
 		}
 		defer f.Close()
-		c.Stderr = f
+		out = f
 	}
 
-	return strace.Strace(c, c.Stderr)
+	cb := strace.PrintTraces(out)
+	if p.expr != "" {
+		f, err := filter(p.expr)
+		if err != nil {
+			return err
+		}
+		cb = f.Callback(cb)
+	}
+	o := strace.Options{Follow: p.follow}
+
+	if p.pid != 0 {
+		return strace.Attach(p.pid, o, cb)
+	}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+	return strace.TraceWith(c, o, cb)
 }
 
 func main() {
-	output := flag.String("o", "", "write output to file (if empty, stdout)")
+	var p params
+	flag.StringVar(&p.output, "o", "", "write output to file (if empty, stderr)")
+	flag.BoolVar(&p.follow, "f", false, "trace child processes and threads")
+	flag.IntVar(&p.pid, "p", 0, "attach to a running process")
+	flag.StringVar(&p.expr, "e", "", "syscalls to trace, trace=name,%class,...")
 	flag.Parse()
 
-	if err := run(os.Stdin, os.Stdout, os.Stderr, params{output: *output}, flag.Args()...); err != nil {
+	if err := run(os.Stdin, os.Stdout, os.Stderr, p, flag.Args()...); err != nil {
 		log.Fatal(err)
 	}
 }
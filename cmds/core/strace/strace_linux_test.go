@@ -11,9 +11,15 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/strace"
 )
 
 func TestRun(t *testing.T) {
@@ -57,3 +63,62 @@ func TestRun(t *testing.T) {
 	}
 
 }
+
+func TestFilter(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	p := params{output: out, follow: true, expr: "trace=execve,openat,%process"}
+	if err := run(nil, io.Discard, io.Discard, p, "sh", "-c", "cat /nonexistent; true"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The child cat is only traced with -f; the failing openat shows its
+	// errno, and write is filtered out.
+	for _, want := range []string{"/nonexistent, O_RDONLY", "-1 ENOENT (no such file or directory)", "spawned new child"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("trace does not contain %q:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), " write(") {
+		t.Errorf("trace contains write, which is filtered out:\n%s", b)
+	}
+}
+
+func TestBadFilter(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		err  error
+	}{
+		{expr: "trace=nosuchcall", err: strace.ErrUnknownSyscall},
+		{expr: "%nosuchclass", err: strace.ErrUnknownSyscall},
+		{expr: "signal=SIGINT", err: errQualifier},
+	} {
+		err := run(nil, io.Discard, io.Discard, params{expr: tt.expr}, "true")
+		if !errors.Is(err, tt.err) {
+			t.Errorf("run(-e %s) = %v, want %v", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestAttach(t *testing.T) {
+	c := exec.Command("sleep", "0.5")
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// The tracer reaps the process.
+	defer c.Process.Release()
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := run(nil, io.Discard, io.Discard, params{output: out, pid: c.Process.Pid}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("PID %d exited", c.Process.Pid); !strings.Contains(string(b), want) {
+		t.Errorf("trace does not contain %q:\n%s", want, b)
+	}
+}
@@ -25,19 +25,24 @@
 // people ever do anyway.
 
 // The command works like this:
-// stty [verb] [options]
+// stty [-F device] [verb] [options]
 // Verbs are:
 // dump -- dump the json of the struct to stdout
 // load -- read a json file from stdin and use it to set
 // raw -- convenience command to set raw
 // cooked -- convenience command to set cooked
+// size -- print the number of rows and columns
+// speed -- print the speed, or set it if followed by a baud rate
 // In common stty usage, options may be specified without a verb.
 //
 // any other verb, with a ~ or without, is taken to mean standard stty args, e.g.
 // stty ~echo
 // turns off echo. Flags with arguments work too:
 // stty intr 1
-// sets the interrupt character to ^A.
+// sets the interrupt character to ^A. A bare number sets the speed, and
+// rows and cols set the window size:
+// stty -F /dev/ttyS0 115200
+// stty rows 50 cols 132
 //
 // The JSON encoding lets you do things like this:
 // stty dump | sed whatever > file
@@ -51,66 +56,119 @@
 // stty -g
 // 4500:5:bf:8a3b:3:1c:7f:15:4:0:1:0:11:13:1a:0:12:f:17:16:0:0:0:0:0:0:0:0:0:0:0:0:0:0:0:0
 //
-// We always do our operations on fd 0, as that is standard, and we always do an initial
-// termios.GTTY to ensure we have access to fd 0.
+// We do our operations on fd 0, as that is standard, unless -F names a device,
+// such as a serial port, and we always do an initial termios.GTTY to ensure we
+// have access to it.
 package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
 )
 
-func main() {
-	t, err := termios.GTTY(0)
+var errUsage = errors.New("usage: stty [-F device] [pretty|dump|load file|raw|cooked|size|speed [baud]|options...]")
+
+func run(stdin *os.File, stdout io.Writer, args []string) error {
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	dev := f.String("F", "", "Use the device instead of stdin")
+	if err := f.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	args = f.Args()
+
+	fd := int(stdin.Fd())
+	if *dev != "" {
+		// Serial ports may wait for carrier on open, which we don't want
+		// just to configure them.
+		d, err := os.OpenFile(*dev, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		fd = int(d.Fd())
+	}
+
+	t, err := termios.GTTY(fd)
 	if err != nil {
-		log.Fatalf("termios.GTTY: %v", err)
+		return fmt.Errorf("termios.GTTY: %w", err)
 	}
 
-	if len(os.Args) == 1 {
-		os.Args = append(os.Args, "pretty")
+	if len(args) == 0 {
+		args = append(args, "pretty")
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "pretty":
-		fmt.Printf("%v\n", t.String())
+		fmt.Fprintf(stdout, "%v\n", t.String())
 	case "dump":
 		b, err := json.MarshalIndent(t, "", "\t")
 		if err != nil {
-			log.Fatalf("json marshal: %v", err)
+			return fmt.Errorf("json marshal: %w", err)
 		}
-		fmt.Printf("%s\n", b)
+		fmt.Fprintf(stdout, "%s\n", b)
 	case "load":
-		if len(os.Args) != 3 {
-			log.Fatalf("arg count")
+		if len(args) != 2 {
+			return fmt.Errorf("arg count: %w", errUsage)
 		}
-		b, err := os.ReadFile(os.Args[2])
+		b, err := os.ReadFile(args[1])
 		if err != nil {
-			log.Fatalf("stty load: %v", err)
+			return fmt.Errorf("stty load: %w", err)
 		}
 		if err := json.Unmarshal(b, t); err != nil {
-			log.Fatalf("stty load: %v", err)
+			return fmt.Errorf("stty load: %w", err)
 		}
-		n, err := t.STTY(0)
+		n, err := t.STTY(fd)
 		if err != nil {
-			log.Fatalf("stty: %v", err)
+			return fmt.Errorf("stty: %w", err)
 		}
-		fmt.Printf("%v\n", n.String())
+		fmt.Fprintf(stdout, "%v\n", n.String())
 	case "raw":
-		if _, err := termios.Raw(0); err != nil {
-			log.Fatalf("raw: %v", err)
+		if _, err := termios.Raw(fd); err != nil {
+			return fmt.Errorf("raw: %w", err)
+		}
+	case "cooked":
+		if _, err := termios.Cooked(fd); err != nil {
+			return fmt.Errorf("cooked: %w", err)
+		}
+	case "size":
+		fmt.Fprintf(stdout, "%d %d\n", t.Row, t.Col)
+	case "speed":
+		if len(args) == 1 {
+			fmt.Fprintf(stdout, "%d\n", t.Ospeed)
+			break
+		}
+		baud, err := strconv.Atoi(args[1])
+		if err != nil || len(args) != 2 {
+			return errUsage
+		}
+		if _, err := termios.SetSpeed(fd, baud); err != nil {
+			return fmt.Errorf("speed: %w", err)
 		}
 	default:
-		if err := t.SetOpts(os.Args[1:]); err != nil {
-			log.Fatalf("setting opts: %v", err)
+		if err := t.SetOpts(args); err != nil {
+			return fmt.Errorf("setting opts: %w", err)
 		}
-		n, err := t.STTY(0)
+		n, err := t.STTY(fd)
 		if err != nil {
-			log.Fatalf("stty: %v", err)
+			return fmt.Errorf("stty: %w", err)
 		}
-		fmt.Printf("%v\n", n.String())
+		fmt.Fprintf(stdout, "%v\n", n.String())
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Stdin, os.Stdout, os.Args); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,305 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sysctl reads and writes kernel parameters in /proc/sys.
+//
+// Synopsis:
+//
+//	sysctl [-n] [-e] [-q] [KEY...]
+//	sysctl [-q] [-e] -w KEY=VALUE...
+//	sysctl [-q] [-e] -p [FILE...]
+//	sysctl [-q] [-e] -system
+//	sysctl [-n] -a
+//
+// Description:
+//
+//	Keys are paths below /proc/sys with dots or slashes, e.g.
+//	net.ipv4.ip_forward or net/ipv4/conf/eth0.100/rp_filter. Keys may be
+//	glob patterns, e.g. net.ipv4.conf.*.rp_filter, which read or write
+//	every matching parameter.
+//
+//	Files given to -p, /etc/sysctl.conf by default, have a KEY = VALUE
+//	line for each parameter. Empty lines and lines starting with # or ;
+//	are skipped. Errors for keys starting with - are ignored.
+//
+//	-system applies the .conf files in /etc/sysctl.d, /run/sysctl.d,
+//	/usr/local/lib/sysctl.d, /usr/lib/sysctl.d and /lib/sysctl.d in the
+//	order of their names, where a file in an earlier directory replaces
+//	those of the same name in later ones, and then /etc/sysctl.conf.
+//
+//	All parameters are tried, even if some fail; sysctl fails if any did.
+//
+// Options:
+//
+//	-a:      print all parameters
+//	-n:      print values only
+//	-e:      ignore unknown keys
+//	-q:      do not print the values written
+//	-w:      write the parameters on the command line
+//	-p:      apply files
+//	-system: apply the system's files
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	errUnknown = errors.New("unknown key")
+	errSyntax  = errors.New("expected KEY=VALUE")
+)
+
+// systemDirs are the directories of -system, in order of precedence.
+var systemDirs = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/local/lib/sysctl.d", "/usr/lib/sysctl.d", "/lib/sysctl.d"}
+
+type sysctl struct {
+	// root is /proc/sys, and sysroot the root of the configuration files.
+	root, sysroot string
+	stdout        io.Writer
+
+	values, ignore, quiet bool
+}
+
+// path returns the file of a key: dots separate its components, unless it
+// has slashes, which allows dots in components.
+func (s *sysctl) path(key string) string {
+	if !strings.Contains(key, "/") {
+		key = strings.ReplaceAll(key, ".", "/")
+	}
+	return filepath.Join(s.root, filepath.Clean("/"+key))
+}
+
+// key returns the key of a file.
+func (s *sysctl) key(path string) string {
+	rel, _ := filepath.Rel(s.root, path)
+	return strings.ReplaceAll(rel, "/", ".")
+}
+
+// files returns the files of a key, which may be a pattern.
+func (s *sysctl) files(key string) ([]string, error) {
+	p := s.path(key)
+	files, err := filepath.Glob(p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: %w", key, errUnknown)
+	}
+	return files, nil
+}
+
+func (s *sysctl) print(path, value string) {
+	if s.values {
+		fmt.Fprintln(s.stdout, value)
+	} else {
+		fmt.Fprintf(s.stdout, "%s = %s\n", s.key(path), value)
+	}
+}
+
+func (s *sysctl) show(f string) error {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.key(f), err)
+	}
+	s.print(f, strings.TrimRight(string(b), "\n"))
+	return nil
+}
+
+// read prints the parameters of a key.
+func (s *sysctl) read(key string) error {
+	files, err := s.files(key)
+	if errors.Is(err, errUnknown) && s.ignore {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, f := range files {
+		errs = append(errs, s.show(f))
+	}
+	return errors.Join(errs...)
+}
+
+// write writes a value to the parameters of a key.
+func (s *sysctl) write(key, value string) error {
+	files, err := s.files(key)
+	if errors.Is(err, errUnknown) && s.ignore {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, f := range files {
+		if err := os.WriteFile(f, []byte(value), 0); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key(f), err))
+			continue
+		}
+		if !s.quiet {
+			s.print(f, value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// all prints every readable parameter.
+func (s *sysctl) all() error {
+	return filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Write-only parameters, such as vm.drop_caches, cannot be read,
+		// not even by root.
+		if fi, err := d.Info(); err != nil || fi.Mode().Perm()&0o444 == 0 {
+			return nil
+		}
+		if b, err := os.ReadFile(p); err == nil {
+			s.print(p, strings.TrimRight(string(b), "\n"))
+		}
+		return nil
+	})
+}
+
+// assignment splits a KEY=VALUE pair.
+func assignment(a string) (string, string, error) {
+	k, v, ok := strings.Cut(a, "=")
+	k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+	if !ok || k == "" {
+		return "", "", fmt.Errorf("%q: %w", a, errSyntax)
+	}
+	return k, v, nil
+}
+
+// apply writes the parameters of a configuration file.
+func (s *sysctl) apply(name string, r io.Reader) error {
+	var errs []error
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		k, v, err := assignment(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", name, n, err))
+			continue
+		}
+		optional := strings.HasPrefix(k, "-")
+		if err := s.write(strings.TrimPrefix(k, "-"), v); err != nil && !optional {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", name, n, err))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *sysctl) applyFile(name string) error {
+	f, err := os.Open(filepath.Join(s.sysroot, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.apply(name, f)
+}
+
+// systemFiles returns the files applied by -system, in order.
+func (s *sysctl) systemFiles() []string {
+	byName := map[string]string{}
+	for _, dir := range systemDirs {
+		matches, _ := filepath.Glob(filepath.Join(s.sysroot, dir, "*.conf"))
+		for _, m := range matches {
+			if _, ok := byName[filepath.Base(m)]; !ok {
+				byName[filepath.Base(m)] = filepath.Join(dir, filepath.Base(m))
+			}
+		}
+	}
+	var files []string
+	for _, f := range byName {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return filepath.Base(files[i]) < filepath.Base(files[j]) })
+	if _, err := os.Stat(filepath.Join(s.sysroot, "/etc/sysctl.conf")); err == nil {
+		files = append(files, "/etc/sysctl.conf")
+	}
+	return files
+}
+
+func (s *sysctl) run(args []string) error {
+	var all, write, load, system bool
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.BoolVar(&all, "a", false, "Print all parameters")
+	f.BoolVar(&s.values, "n", false, "Print values only")
+	f.BoolVar(&s.ignore, "e", false, "Ignore unknown keys")
+	f.BoolVar(&s.quiet, "q", false, "Do not print the values written")
+	f.BoolVar(&write, "w", false, "Write the parameters on the command line")
+	f.BoolVar(&load, "p", false, "Apply files, /etc/sysctl.conf by default")
+	f.BoolVar(&system, "system", false, "Apply the system's files")
+	if err := f.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var errs []error
+	switch {
+	case all:
+		return s.all()
+	case system:
+		for _, name := range s.systemFiles() {
+			if !s.quiet {
+				fmt.Fprintf(s.stdout, "* Applying %s ...\n", name)
+			}
+			errs = append(errs, s.applyFile(name))
+		}
+	case load:
+		files := f.Args()
+		if len(files) == 0 {
+			files = []string{"/etc/sysctl.conf"}
+		}
+		for _, name := range files {
+			errs = append(errs, s.applyFile(name))
+		}
+	case f.NArg() == 0:
+		f.Usage()
+		return flag.ErrHelp
+	default:
+		for _, a := range f.Args() {
+			// procps sysctl also writes KEY=VALUE without -w.
+			if !write && !strings.Contains(a, "=") {
+				errs = append(errs, s.read(a))
+				continue
+			}
+			k, v, err := assignment(a)
+			if err == nil {
+				err = s.write(k, v)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func main() {
+	s := &sysctl{root: "/proc/sys", sysroot: "/", stdout: os.Stdout}
+	if err := s.run(os.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
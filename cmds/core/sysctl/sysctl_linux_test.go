@@ -0,0 +1,167 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		f := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func setup(t *testing.T) (*sysctl, *strings.Builder) {
+	t.Helper()
+	root, sysroot := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"net/ipv4/ip_forward":              "0\n",
+		"net/ipv4/conf/all/rp_filter":      "0\n",
+		"net/ipv4/conf/eth0/rp_filter":     "0\n",
+		"net/ipv4/conf/eth0.100/rp_filter": "0\n",
+		"net/core/rmem_max":                "212992\n",
+		"net/ipv4/ip_local_port_range":     "32768\t60999\n",
+		"kernel/hostname":                  "localhost\n",
+		"vm/drop_caches":                   "",
+	})
+	// Write-only, like the real vm.drop_caches.
+	if err := os.Chmod(filepath.Join(root, "vm/drop_caches"), 0o200); err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	return &sysctl{root: root, sysroot: sysroot, stdout: out}, out
+}
+
+func (s *sysctl) value(t *testing.T, key string) string {
+	t.Helper()
+	b, err := os.ReadFile(s.path(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestRead(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{args: []string{"net.ipv4.ip_forward"}, want: "net.ipv4.ip_forward = 0\n"},
+		{args: []string{"-n", "net/core/rmem_max", "kernel.hostname"}, want: "212992\nlocalhost\n"},
+		{args: []string{"net.ipv4.conf.*.rp_filter"}, want: "net.ipv4.conf.all.rp_filter = 0\nnet.ipv4.conf.eth0.rp_filter = 0\nnet.ipv4.conf.eth0.100.rp_filter = 0\n"},
+		{args: []string{"net.ipv4.ip_local_port_range"}, want: "net.ipv4.ip_local_port_range = 32768\t60999\n"},
+		{args: []string{"net.ipv4.nope", "kernel.hostname"}, want: "kernel.hostname = localhost\n", err: errUnknown},
+		{args: []string{"-e", "net.ipv4.nope", "kernel.hostname"}, want: "kernel.hostname = localhost\n"},
+	} {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			s, out := setup(t)
+			if err := s.run(append([]string{"sysctl"}, tt.args...)); !errors.Is(err, tt.err) {
+				t.Errorf("run = %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	s, out := setup(t)
+	if err := s.run([]string{"sysctl", "-a"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "\n") != 7 || strings.Contains(out.String(), "drop_caches") {
+		t.Errorf("sysctl -a:\n%s", out)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s, out := setup(t)
+	err := s.run([]string{"sysctl", "-w", "net.ipv4.ip_forward=1", "net.ipv4.conf.*.rp_filter = 2", "bogus", "net.nope=1", "net.core.rmem_max=4194304"})
+	if !errors.Is(err, errSyntax) || !errors.Is(err, errUnknown) {
+		t.Errorf("run = %v, want %v and %v", err, errSyntax, errUnknown)
+	}
+	for key, want := range map[string]string{
+		"net.ipv4.ip_forward":              "1",
+		"net/ipv4/conf/eth0.100/rp_filter": "2",
+		"net.core.rmem_max":                "4194304",
+	} {
+		if got := s.value(t, key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if want := "net.ipv4.ip_forward = 1\n"; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("output %q, want %q first", out, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	s, out := setup(t)
+	writeFiles(t, s.sysroot, map[string]string{"etc/net.conf": `
+# Large transfers.
+net.core.rmem_max = 8388608
+; Comment.
+net.ipv4.conf.eth*.rp_filter=1
+-net.ipv4.tcp_nope = 1
+net.ipv4.udp_nope = 1
+not an assignment
+`})
+	err := s.run([]string{"sysctl", "-q", "-p", "/etc/net.conf"})
+	if err == nil || !strings.Contains(err.Error(), "/etc/net.conf:7: net.ipv4.udp_nope: unknown key") || !strings.Contains(err.Error(), "/etc/net.conf:8:") || strings.Contains(err.Error(), "tcp_nope") {
+		t.Errorf("run = %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("-q printed %q", out)
+	}
+	for key, want := range map[string]string{
+		"net.core.rmem_max":                "8388608",
+		"net.ipv4.conf.all.rp_filter":      "0",
+		"net/ipv4/conf/eth0.100/rp_filter": "1",
+	} {
+		if got := s.value(t, key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSystem(t *testing.T) {
+	s, out := setup(t)
+	writeFiles(t, s.sysroot, map[string]string{
+		"usr/lib/sysctl.d/10-net.conf":  "net.ipv4.ip_forward = 1\nnet.core.rmem_max = 1\n",
+		"etc/sysctl.d/10-net.conf":      "net.core.rmem_max = 2\n",
+		"usr/lib/sysctl.d/50-host.conf": "kernel.hostname = vendor\n",
+		"run/sysctl.d/20-host.conf":     "kernel.hostname = run\n",
+		"etc/sysctl.d/README":           "net.core.rmem_max = 3\n",
+		"etc/sysctl.conf":               "net.ipv4.ip_forward = 0\n",
+	})
+	if err := s.run([]string{"sysctl", "-system"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `* Applying /etc/sysctl.d/10-net.conf ...
+net.core.rmem_max = 2
+* Applying /run/sysctl.d/20-host.conf ...
+kernel.hostname = run
+* Applying /usr/lib/sysctl.d/50-host.conf ...
+kernel.hostname = vendor
+* Applying /etc/sysctl.conf ...
+net.ipv4.ip_forward = 0
+`
+	if out.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -14,15 +14,24 @@
 //	   tar -cvf x.tar directory/         # create
 //	   tar -cvf x.tar file1 file2 ...    # create
 //	   tar -tvf x.tar                    # list
-//	   tar -xvf x.tar directory/         # extract
+//	   tar -xvf x.tar [directory/]       # extract
+//
+//	Archives compressed with gzip, bzip2, xz, zstd or lz4 are detected and
+//	decompressed when listing or extracting them.
 //
 // Options:
 //
 //	-c: create a new tar archive from the given directory
-//	-x: extract a tar archive to the given directory
+//	-x: extract a tar archive to the given directory, by default .
 //	-v: verbose, print each filename (optional)
 //	-f: tar filename (required)
 //	-t: list the contents of an archive
+//	-z: compress the created archive with gzip
+//	-J: compress the created archive with xz
+//	--zstd: compress the created archive with zstd
+//	--exclude PATTERN: leave out files matching the shell pattern; may be repeated
+//	--numeric-owner: use user and group IDs instead of names
+//	--xattrs: store and restore extended attributes and file capabilities
 //
 // TODO: The arguments deviates slightly from gnu tar.
 package main
@@ -33,6 +42,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/u-root/u-root/pkg/compress"
 	"github.com/u-root/u-root/pkg/tarutil"
 	"github.com/u-root/u-root/pkg/uroot/unixflag"
 )
@@ -49,6 +59,11 @@ type params struct {
 	list        bool
 	noRecursion bool
 	verbose     bool
+	// compress is the format the created archive is compressed in.
+	compress     string
+	exclude      []string
+	numericOwner bool
+	xattrs       bool
 }
 
 var (
@@ -57,7 +72,7 @@ var (
 	errExtractAndList       = fmt.Errorf("cannot supply both -x and -t")
 	errEmptyFile            = fmt.Errorf("file is required")
 	errMissingMandatoryFlag = fmt.Errorf("must supply at least one of: -c, -x, -t")
-	errExtractArgsLen       = fmt.Errorf("args length should be 1")
+	errExtractArgsLen       = fmt.Errorf("args length should be at most 1")
 )
 
 func command(p params, args []string) (*cmd, error) {
@@ -70,7 +85,7 @@ func command(p params, args []string) (*cmd, error) {
 	if p.extract && p.list {
 		return nil, errExtractAndList
 	}
-	if p.extract && len(args) != 1 {
+	if p.extract && len(args) > 1 {
 		return nil, errExtractArgsLen
 	}
 	if !p.extract && !p.create && !p.list {
@@ -79,6 +94,9 @@ func command(p params, args []string) (*cmd, error) {
 	if p.file == "" {
 		return nil, errEmptyFile
 	}
+	if p.extract && len(args) == 0 {
+		args = []string{"."}
+	}
 
 	return &cmd{
 		p:    p,
@@ -88,7 +106,10 @@ func command(p params, args []string) (*cmd, error) {
 
 func (c *cmd) run() error {
 	opts := &tarutil.Opts{
-		NoRecursion: c.p.noRecursion,
+		NoRecursion:  c.p.noRecursion,
+		Exclude:      c.p.exclude,
+		NumericOwner: c.p.numericOwner,
+		Xattrs:       c.p.xattrs,
 	}
 	if c.p.verbose {
 		opts.Filters = []tarutil.Filter{tarutil.VerboseFilter}
@@ -100,7 +121,16 @@ func (c *cmd) run() error {
 		if err != nil {
 			return err
 		}
-		if err := tarutil.CreateTar(f, c.args, opts); err != nil {
+		w, err := compress.NewWriter(f, c.p.compress, compress.DefaultLevel)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if err := tarutil.CreateTar(w, c.args, opts); err != nil {
+			f.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
 			f.Close()
 			return err
 		}
@@ -138,6 +168,9 @@ func main() {
 		list        bool
 		noRecursion bool
 		verbose     bool
+		gzip, xz    bool
+		zstd, bzip2 bool
+		p           params
 	)
 	f := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
@@ -158,8 +191,31 @@ func main() {
 	f.BoolVar(&verbose, "verbose", false, "print each filename")
 	f.BoolVar(&verbose, "v", false, "print each filename (shorthand)")
 
+	f.BoolVar(&gzip, "gzip", false, "compress the archive with gzip")
+	f.BoolVar(&gzip, "z", false, "compress the archive with gzip (shorthand)")
+	f.BoolVar(&xz, "xz", false, "compress the archive with xz")
+	f.BoolVar(&xz, "J", false, "compress the archive with xz (shorthand)")
+	f.BoolVar(&zstd, "zstd", false, "compress the archive with zstd")
+	f.BoolVar(&bzip2, "bzip2", false, "accepted when extracting; bzip2 archives cannot be created")
+	f.BoolVar(&bzip2, "j", false, "accepted when extracting; bzip2 archives cannot be created (shorthand)")
+
+	f.Var((*unixflag.StringArray)(&p.exclude), "exclude", "leave out files matching the shell pattern")
+	f.BoolVar(&p.numericOwner, "numeric-owner", false, "use user and group IDs instead of names")
+	f.BoolVar(&p.xattrs, "xattrs", false, "store and restore extended attributes")
+
 	f.Parse(unixflag.OSArgsToGoArgs())
-	cmd, err := command(params{file: file, create: create, extract: extract, list: list, noRecursion: noRecursion, verbose: verbose}, flag.Args())
+	p.file, p.create, p.extract, p.list, p.noRecursion, p.verbose = file, create, extract, list, noRecursion, verbose
+	switch {
+	case gzip:
+		p.compress = compress.Gzip
+	case xz:
+		p.compress = compress.XZ
+	case zstd:
+		p.compress = compress.Zstd
+	case bzip2 && create:
+		log.Fatal("creating bzip2 archives is not supported")
+	}
+	cmd, err := command(p, f.Args())
 	if err != nil {
 		f.Usage()
 		log.Fatal(err)
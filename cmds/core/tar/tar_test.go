@@ -8,6 +8,8 @@ import (
 	"os"
 	"path"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/compress"
 )
 
 func TestTar(t *testing.T) {
@@ -143,3 +145,44 @@ func TestCommandErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestTarCompressedExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/keep", "dir/drop.log"} {
+		if err := os.MkdirAll(path.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create, err := command(params{file: "dir.tar.zst", create: true, compress: compress.Zstd, exclude: []string{"*.log"}}, []string{"dir"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := create.run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll("dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory defaults to the current one.
+	extract, err := command(params{file: "dir.tar.zst", extract: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := extract.run(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile("dir/keep"); err != nil || string(b) != "dir/keep" {
+		t.Errorf("dir/keep = %q, %v, want %q", b, err, "dir/keep")
+	}
+	if _, err := os.Stat("dir/drop.log"); !os.IsNotExist(err) {
+		t.Errorf("excluded dir/drop.log was archived")
+	}
+}
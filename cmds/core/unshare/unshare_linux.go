@@ -22,27 +22,57 @@
 //	it is not possible to use `syscall.Unshare` from Go with any reasonable
 //	expectation of success.
 //
+//	So PROGRAM is started in the new namespaces instead. When the mounts of
+//	a new mount namespace must be changed first, as for -mount-proc, it is
+//	unshare itself that is started there, which changes them and then
+//	executes PROGRAM.
+//
 //	If PROGRAM is not specified, unshare defaults to /bin/sh.
 //
 // Options:
 //
 //	-ipc:           Unshare the IPC namespace
 //	-mount:         Unshare the mount namespace
-//	-pid:           Unshare the pid namespace
+//	-pid:           Unshare the pid namespace; PROGRAM is its init
 //	-net:           Unshare the net namespace
 //	-uts:           Unshare the uts namespace
 //	-user:          Unshare the user namespace
-//	-map-root-user: Map current uid to root. Not working
+//	-cgroup:        Unshare the cgroup namespace
+//	-map-root-user: Map the current user and group to root in a new user
+//	                namespace, which lets an unprivileged user create the
+//	                others
+//	-map-user:      Map the current user to this UID in a new user namespace
+//	-map-group:     Map the current group to this GID in a new user namespace
+//	-mount-proc:    Mount a new /proc, as for a new pid namespace; implies
+//	                -mount
+//	-propagation:   The propagation of the mounts in a new mount namespace:
+//	                private (the default), slave, shared or unchanged
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 )
 
+// setupEnv passes what the mounts of a new mount namespace need to the
+// unshare started there.
+const setupEnv = "UNSHARE_SETUP"
+
+var propagations = map[string]uintptr{
+	"private":   syscall.MS_PRIVATE,
+	"slave":     syscall.MS_SLAVE,
+	"shared":    syscall.MS_SHARED,
+	"unchanged": 0,
+}
+
+var errPropagation = errors.New("propagation must be private, slave, shared or unchanged")
+
 func command(ipc, mount, pid, net, uts, user bool, args ...string) *exec.Cmd {
 	if len(args) == 0 {
 		args = []string{"/bin/sh"}
@@ -76,15 +106,110 @@ func command(ipc, mount, pid, net, uts, user bool, args ...string) *exec.Cmd {
 	return c
 }
 
+type options struct {
+	ipc, mount, pid, net, uts, user, cgroup bool
+	// mapUser and mapGroup are the IDs the current user and group are
+	// mapped to, or -1.
+	mapUser, mapGroup int
+	mountProc         bool
+	propagation       string
+}
+
+// command returns the command which runs args in the new namespaces.
+func (o *options) command(args ...string) (*exec.Cmd, error) {
+	prop, ok := propagations[o.propagation]
+	if !ok {
+		return nil, fmt.Errorf("%w, not %q", errPropagation, o.propagation)
+	}
+	mapped := o.mapUser >= 0 || o.mapGroup >= 0
+	mount := o.mount || o.mountProc
+	c := command(o.ipc, mount, o.pid, o.net, o.uts, o.user || mapped, args...)
+	if o.cgroup {
+		c.SysProcAttr.Cloneflags |= syscall.CLONE_NEWCGROUP
+	}
+	if o.mapUser >= 0 {
+		c.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: o.mapUser, HostID: os.Getuid(), Size: 1}}
+	}
+	if o.mapGroup >= 0 {
+		c.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: o.mapGroup, HostID: os.Getgid(), Size: 1}}
+		// An unprivileged user may only map a group once setgroups
+		// is denied.
+		c.SysProcAttr.GidMappingsEnableSetgroups = false
+	}
+
+	var setup []string
+	if mount && prop != 0 {
+		setup = append(setup, o.propagation)
+	}
+	if o.mountProc {
+		setup = append(setup, "proc")
+	}
+	if len(setup) > 0 {
+		c.Path, c.Err = "/proc/self/exe", nil
+		c.Args = append([]string{os.Args[0]}, c.Args...)
+		c.Env = append(os.Environ(), setupEnv+"="+strings.Join(setup, ","))
+	}
+	return c, nil
+}
+
+// setup changes the mounts of the new mount namespace, as the unshare
+// started in it, and executes the program.
+func setup(how string, args []string) error {
+	for _, s := range strings.Split(how, ",") {
+		if s == "proc" {
+			if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+				return fmt.Errorf("mounting /proc: %w", err)
+			}
+			continue
+		}
+		prop, ok := propagations[s]
+		if !ok {
+			return fmt.Errorf("%w, not %q", errPropagation, s)
+		}
+		if err := syscall.Mount("none", "/", "", syscall.MS_REC|prop, ""); err != nil {
+			return fmt.Errorf("making mounts %s: %w", s, err)
+		}
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, args, os.Environ())
+}
+
 func main() {
-	ipc := flag.Bool("ipc", false, "Unshare the IPC namespace")
-	mount := flag.Bool("mount", false, "Unshare the mount namespace")
-	pid := flag.Bool("pid", false, "Unshare the pid namespace")
-	net := flag.Bool("net", false, "Unshare the net namespace")
-	uts := flag.Bool("uts", false, "Unshare the uts namespace")
-	user := flag.Bool("user", false, "Unshare the user namespace")
+	if how, ok := os.LookupEnv(setupEnv); ok {
+		os.Unsetenv(setupEnv)
+		log.Fatal(setup(how, os.Args[1:]))
+	}
+
+	var o options
+	flag.BoolVar(&o.ipc, "ipc", false, "Unshare the IPC namespace")
+	flag.BoolVar(&o.mount, "mount", false, "Unshare the mount namespace")
+	flag.BoolVar(&o.pid, "pid", false, "Unshare the pid namespace")
+	flag.BoolVar(&o.net, "net", false, "Unshare the net namespace")
+	flag.BoolVar(&o.uts, "uts", false, "Unshare the uts namespace")
+	flag.BoolVar(&o.user, "user", false, "Unshare the user namespace")
+	flag.BoolVar(&o.cgroup, "cgroup", false, "Unshare the cgroup namespace")
+	mapRoot := flag.Bool("map-root-user", false, "Map the current user and group to root")
+	flag.IntVar(&o.mapUser, "map-user", -1, "Map the current user to this UID")
+	flag.IntVar(&o.mapGroup, "map-group", -1, "Map the current group to this GID")
+	flag.BoolVar(&o.mountProc, "mount-proc", false, "Mount a new /proc; implies -mount")
+	flag.StringVar(&o.propagation, "propagation", "private", "Propagation of mounts: private, slave, shared or unchanged")
 	flag.Parse()
-	if err := command(*ipc, *mount, *pid, *net, *uts, *user, flag.Args()...).Run(); err != nil {
+	if *mapRoot {
+		o.mapUser, o.mapGroup = 0, 0
+	}
+
+	c, err := o.command(flag.Args()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.ExitCode())
+		}
 		log.Fatalf("%v", err)
 	}
 }
@@ -4,8 +4,11 @@
 package main
 
 import (
+	"errors"
 	"os"
+	"reflect"
 	"slices"
+	"strings"
 	"syscall"
 	"testing"
 )
@@ -51,3 +54,78 @@ func TestCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		o      options
+		flags  uintptr
+		uidMap []syscall.SysProcIDMap
+		gidMap []syscall.SysProcIDMap
+		setup  string
+		err    error
+	}{
+		{
+			name:  "mount",
+			o:     options{mount: true, mapUser: -1, mapGroup: -1, propagation: "private"},
+			flags: syscall.CLONE_NEWNS,
+			setup: "private",
+		},
+		{
+			name:  "mount unchanged",
+			o:     options{mount: true, mapUser: -1, mapGroup: -1, propagation: "unchanged"},
+			flags: syscall.CLONE_NEWNS,
+		},
+		{
+			name:  "mount proc",
+			o:     options{pid: true, mountProc: true, mapUser: -1, mapGroup: -1, propagation: "slave"},
+			flags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
+			setup: "slave,proc",
+		},
+		{
+			name:   "map root",
+			o:      options{net: true, cgroup: true, mapUser: 0, mapGroup: 0, propagation: "private"},
+			flags:  syscall.CLONE_NEWNET | syscall.CLONE_NEWUSER | syscall.CLONE_NEWCGROUP,
+			uidMap: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			gidMap: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		},
+		{
+			name: "bad propagation",
+			o:    options{mount: true, mapUser: -1, mapGroup: -1, propagation: "private,proc"},
+			err:  errPropagation,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.o.command("echo", "hello")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			attr := c.SysProcAttr
+			if attr.Cloneflags != tt.flags {
+				t.Errorf("got flags %#x, want %#x", attr.Cloneflags, tt.flags)
+			}
+			if !reflect.DeepEqual(attr.UidMappings, tt.uidMap) || !reflect.DeepEqual(attr.GidMappings, tt.gidMap) {
+				t.Errorf("got mappings %v and %v, want %v and %v", attr.UidMappings, attr.GidMappings, tt.uidMap, tt.gidMap)
+			}
+			var setup string
+			for _, e := range c.Env {
+				if v, ok := strings.CutPrefix(e, setupEnv+"="); ok {
+					setup = v
+				}
+			}
+			if setup != tt.setup {
+				t.Errorf("got setup %q, want %q", setup, tt.setup)
+			}
+			args := []string{"echo", "hello"}
+			if setup != "" {
+				args = append([]string{os.Args[0]}, args...)
+			}
+			if !slices.Equal(c.Args, args) {
+				t.Errorf("got args %q, want %q", c.Args, args)
+			}
+		})
+	}
+}
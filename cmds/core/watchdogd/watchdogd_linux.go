@@ -16,6 +16,8 @@
 //	    Send a signal to arm the running watchdog.
 //	watchdogd disarm
 //	    Send a signal to disarm the running watchdog.
+//	watchdogd heartbeat
+//	    Tell the running watchdogd this process is alive.
 //
 // Options:
 //
@@ -24,6 +26,8 @@
 //	--pre_timeout: Duration for pretimeout (default -1)
 //	--keep_alive: Duration between issuing keepalive (default 10)
 //	--monitors: comma separated list of monitors, ex: oops
+//	--heartbeat: stop petting if no heartbeat arrives for this long, e.g.
+//	    from init, which sends one every 5s (default 0, no heartbeats needed)
 package main
 
 import (
@@ -40,7 +44,7 @@ import (
 )
 
 func usage() {
-	fmt.Print(`watchdogd run [--dev DEV] [--timeout N] [--pre_timeout N] [--keep_alive N] [--monitors STRING]
+	fmt.Print(`watchdogd run [--dev DEV] [--timeout N] [--pre_timeout N] [--keep_alive N] [--monitors STRING] [--heartbeat N]
 	Run the watchdogd daemon in a child process (does not daemonize).
 watchdogd stop
 	Send a signal to arm the running watchdogd.
@@ -50,6 +54,8 @@ watchdogd arm
 	Send a signal to arm the running watchdogd.
 watchdogd disarm
 	Send a signal to disarm the running watchdogd.
+watchdogd heartbeat
+	Tell the running watchdogd this process is alive.
 `)
 	os.Exit(1)
 }
@@ -70,7 +76,8 @@ func runCommand() error {
 			preTimeout = fs.Duration("pre_timeout", -1, "duration for pretimeout")
 			keepAlive  = fs.Duration("keep_alive", 5*time.Second, "duration between issuing keepalive")
 			monitors   = fs.String("monitors", "", "comma seperated list of monitors, ex: oops")
-			uds        = fs.String("uds", "/tmp/watchdogd", "unix domain socket path for the daemon")
+			uds        = fs.String("uds", watchdogd.DefaultUDS, "unix domain socket path for the daemon")
+			heartbeat  = fs.Duration("heartbeat", 0, "stop petting if no heartbeat arrives for this long")
 		)
 		fs.Parse(args)
 		if fs.NArg() != 0 {
@@ -85,6 +92,9 @@ func runCommand() error {
 
 		monitorFuncs := []func() error{}
 		for _, m := range strings.Split(*monitors, ",") {
+			if m == "" {
+				continue
+			}
 			if m == "oops" {
 				monitorFuncs = append(monitorFuncs, watchdogd.MonitorOops)
 			} else {
@@ -99,6 +109,7 @@ func runCommand() error {
 			KeepAlive:  *keepAlive,
 			Monitors:   monitorFuncs,
 			UDS:        *uds,
+			Heartbeat:  *heartbeat,
 		})
 	default:
 		if len(args) != 0 {
@@ -109,10 +120,11 @@ func runCommand() error {
 			return fmt.Errorf("could not dial watchdog daemon: %v", err)
 		}
 		f, ok := map[string]func() error{
-			"stop":     d.Stop,
-			"continue": d.Continue,
-			"arm":      d.Arm,
-			"disarm":   d.Disarm,
+			"stop":      d.Stop,
+			"continue":  d.Continue,
+			"arm":       d.Arm,
+			"disarm":    d.Disarm,
+			"heartbeat": d.Heartbeat,
 		}[cmd]
 		if !ok {
 			return fmt.Errorf("unrecognized command %q", cmd)
@@ -12,6 +12,11 @@
 //
 //	Returns a non-zero code on failure.
 //
+//	Files are fetched through the HTTP or SOCKS5 proxies named by
+//	HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY, or by the
+//	uroot.http_proxy, uroot.https_proxy, uroot.all_proxy and
+//	uroot.no_proxy kernel command line flags.
+//
 // Notes:
 //
 //	There are a few differences with GNU wget:
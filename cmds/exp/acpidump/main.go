@@ -0,0 +1,181 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// acpidump shows, extracts and overrides ACPI tables.
+//
+// Synopsis:
+//
+//	acpidump [-s method | -f blob] [-x] [-hex] [-d dir] [SIGNATURE...]
+//	acpidump -o override.cpio [-fix] TABLE.aml...
+//
+// Description:
+//
+//	Without -o, acpidump reads the tables of the system, or of a blob as
+//	written by acpicat, and prints the header of each one with the
+//	signature SIGNATURE, or of all of them. Headers are printed as the
+//	iasl disassembler does.
+//
+//	With -x, the tables are written to files in the current directory, or
+//	in dir, named as by acpixtract, e.g. dsdt.dat, ssdt1.dat, ssdt2.dat.
+//	They can be disassembled with iasl -d.
+//
+//	With -o, acpidump writes an initrd that makes Linux use the tables
+//	compiled from ASL, e.g. by iasl -tc, in place of the tables of the
+//	firmware with the same signature and OEM table ID, if their OEM
+//	revision is higher, or in addition to them, e.g. for a new SSDT. The
+//	kernel needs CONFIG_ACPI_TABLE_UPGRADE and the initrd has to come
+//	first, e.g.
+//
+//	acpidump -o override.cpio dsdt.aml
+//	kexec -i "override.cpio initramfs.cpio" -c "$(cat /proc/cmdline)" -l bzImage
+//
+// Options:
+//
+//	-s: source of the tables, see acpicat
+//	-f: read the tables from a file instead
+//	-hex: also print the tables in hex
+//	-x: extract the tables to files
+//	-d: directory to extract the tables to
+//	-o: write an override initrd for the tables in the files given as arguments
+//	-fix: correct the checksums of the tables written with -o
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/acpi"
+)
+
+var errUsage = errors.New("usage: acpidump [-s method | -f blob] [-x] [-hex] [-d dir] [SIGNATURE...] or acpidump -o override.cpio [-fix] TABLE.aml...")
+
+type params struct {
+	source   string
+	file     string
+	hex      bool
+	extract  bool
+	dir      string
+	override string
+	fix      bool
+}
+
+func readTables(p params) ([]acpi.Table, error) {
+	if p.file != "" {
+		return acpi.RawFromName(p.file)
+	}
+	return acpi.ReadTables(p.source)
+}
+
+func filter(tabs []acpi.Table, sigs []string) []acpi.Table {
+	if len(sigs) == 0 {
+		return tabs
+	}
+	var out []acpi.Table
+	for _, t := range tabs {
+		for _, s := range sigs {
+			if t.Sig() == s {
+				out = append(out, t)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// override writes an override initrd for the tables in files.
+func override(stdout io.Writer, p params, files []string) error {
+	if len(files) == 0 {
+		return errUsage
+	}
+	var tabs []acpi.Table
+	for _, n := range files {
+		b, err := os.ReadFile(n)
+		if err != nil {
+			return err
+		}
+		if p.fix {
+			if err := acpi.FixChecksum(b); err != nil {
+				return fmt.Errorf("%s: %w", n, err)
+			}
+		}
+		t, err := acpi.NewRaw(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", n, err)
+		}
+		tabs = append(tabs, t...)
+	}
+	f, err := os.Create(p.override)
+	if err != nil {
+		return err
+	}
+	if err := acpi.WriteOverride(f, tabs...); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	for _, t := range tabs {
+		fmt.Fprintf(stdout, "%s: %s\n", p.override, acpi.String(t))
+	}
+	return nil
+}
+
+func run(stdout io.Writer, p params, args []string) error {
+	if p.override != "" {
+		return override(stdout, p, args)
+	}
+	tabs, err := readTables(p)
+	if err != nil {
+		return err
+	}
+	tabs = filter(tabs, args)
+	if len(tabs) == 0 {
+		return fmt.Errorf("no tables found")
+	}
+
+	if p.extract {
+		paths, err := acpi.ExtractTables(p.dir, tabs)
+		if err != nil {
+			return err
+		}
+		for i, path := range paths {
+			fmt.Fprintf(stdout, "%s: %d bytes written to %s\n", tabs[i].Sig(), tabs[i].Len(), path)
+		}
+		return nil
+	}
+
+	for i, t := range tabs {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		if err := acpi.DumpHeader(stdout, t); err != nil {
+			return err
+		}
+		if p.hex {
+			fmt.Fprintf(stdout, "\n%s", hex.Dump(t.Data()))
+		}
+	}
+	return nil
+}
+
+func main() {
+	var p params
+	flag.StringVar(&p.source, "s", acpi.DefaultMethod, "source of the tables")
+	flag.StringVar(&p.file, "f", "", "read the tables from a file instead")
+	flag.BoolVar(&p.hex, "hex", false, "also print the tables in hex")
+	flag.BoolVar(&p.extract, "x", false, "extract the tables to files")
+	flag.StringVar(&p.dir, "d", ".", "directory to extract the tables to")
+	flag.StringVar(&p.override, "o", "", "write an override initrd for the tables in the files given as arguments")
+	flag.BoolVar(&p.fix, "fix", false, "correct the checksums of the tables written with -o")
+	flag.Parse()
+	if err := run(os.Stdout, p, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/acpi"
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+// table returns a table with a header and a wrong checksum.
+func table(sig string) []byte {
+	b := make([]byte, 40)
+	copy(b, sig)
+	b[4] = byte(len(b))
+	copy(b[10:], "UROOT ")
+	return b
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blob")
+	var tabs []byte
+	for _, sig := range []string{"DSDT", "SSDT", "SSDT"} {
+		tab := table(sig)
+		if err := acpi.FixChecksum(tab); err != nil {
+			t.Fatal(err)
+		}
+		tabs = append(tabs, tab...)
+	}
+	if err := os.WriteFile(blob, tabs, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := run(&out, params{file: blob}, []string{"SSDT"}); err != nil {
+		t.Fatalf("run = %v", err)
+	}
+	if n := strings.Count(out.String(), "Signature : \"SSDT\""); n != 2 {
+		t.Errorf("dumped %d SSDT headers, want 2:\n%s", n, out.String())
+	}
+	if strings.Contains(out.String(), "DSDT") {
+		t.Errorf("dumped the DSDT, want only SSDTs:\n%s", out.String())
+	}
+
+	if err := run(&out, params{file: blob}, []string{"APIC"}); err == nil {
+		t.Errorf("run(APIC) = nil, want error")
+	}
+
+	extracted := filepath.Join(dir, "x")
+	if err := os.Mkdir(extracted, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(&out, params{file: blob, extract: true, dir: extracted}, nil); err != nil {
+		t.Fatalf("run -x = %v", err)
+	}
+	for _, n := range []string{"dsdt.dat", "ssdt1.dat", "ssdt2.dat"} {
+		if _, err := os.Stat(filepath.Join(extracted, n)); err != nil {
+			t.Errorf("run -x did not write %s: %v", n, err)
+		}
+	}
+}
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	aml := filepath.Join(dir, "ssdt.aml")
+	if err := os.WriteFile(aml, table("SSDT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	initrd := filepath.Join(dir, "override.cpio")
+
+	var out bytes.Buffer
+	if err := run(&out, params{override: initrd}, []string{aml}); err == nil {
+		t.Errorf("run -o with a bad checksum = nil, want error")
+	}
+	if err := run(&out, params{override: initrd, fix: true}, []string{aml}); err != nil {
+		t.Fatalf("run -o -fix = %v", err)
+	}
+	f, err := os.Open(initrd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := cpio.ArchiveFromReader(cpio.Newc.Reader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Get("kernel/firmware/acpi/ssdt.aml"); !ok {
+		t.Errorf("override initrd does not contain the SSDT:\n%s", a)
+	}
+
+	if err := run(&out, params{override: initrd}, nil); err != errUsage {
+		t.Errorf("run -o without tables = %v, want %v", err, errUsage)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// attest attests the machine to a remote service and gates booting on it.
+//
+// Synopsis:
+//
+//	attest [-url URL] [-pcrs PCR,...] [-ca FILE] [-owner PASSWORD] [-data FILE] [-t TIMEOUT] [COMMAND [ARGS...]]
+//
+// Description:
+//
+//	attest quotes PCRs with the TPM's attestation key and posts the
+//	quote and the firmware event log to the attestation service at URL.
+//	If the service allows the machine, attest writes the data the
+//	service released to FILE and runs COMMAND, e.g. the next boot
+//	step, in its place. Otherwise it exits with an error.
+//
+// Options:
+//
+//	-url:   attestation service (default uroot.attest= of the kernel command line)
+//	-pcrs:  PCRs to quote (default 0,2,4,7)
+//	-ca:    PEM file of CA certificates of the service (default system roots)
+//	-owner: TPM owner hierarchy password
+//	-data:  file to write released data to
+//	-t:     timeout (default 60s)
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/attest"
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+var errNoURL = errors.New("no attestation service: give -url or set uroot.attest= on the kernel command line")
+
+type cmd struct {
+	client *attest.Client
+	tpm    attest.TPM
+	data   string
+	args   []string
+	exec   func(argv0 string, argv []string, envv []string) error
+}
+
+func parsePCRs(s string) ([]int, error) {
+	var pcrs []int
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseUint(f, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("PCR %q: %w", f, err)
+		}
+		pcrs = append(pcrs, int(p))
+	}
+	return pcrs, nil
+}
+
+func (c *cmd) run(ctx context.Context) error {
+	v, err := c.client.Attest(ctx, c.tpm)
+	if err != nil {
+		return err
+	}
+	log.Printf("attest: allowed by %s", c.client.URL)
+	if c.data != "" {
+		if err := os.WriteFile(c.data, v.Data, 0o600); err != nil {
+			return err
+		}
+	}
+	if len(c.args) == 0 {
+		return nil
+	}
+	p, err := exec.LookPath(c.args[0])
+	if err != nil {
+		return err
+	}
+	return c.exec(p, c.args, os.Environ())
+}
+
+func main() {
+	def, _ := cmdline.Flag("uroot.attest")
+	url := flag.String("url", def, "Attestation service URL")
+	pcrs := flag.String("pcrs", "0,2,4,7", "PCRs to quote")
+	ca := flag.String("ca", "", "PEM file of CA certificates of the service")
+	owner := flag.String("owner", "", "TPM owner hierarchy password")
+	data := flag.String("data", "", "File to write released data to")
+	timeout := flag.Duration("t", time.Minute, "Timeout")
+	flag.Parse()
+
+	if *url == "" {
+		log.Fatal(errNoURL)
+	}
+	client, err := attest.NewClient(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if client.PCRs, err = parsePCRs(*pcrs); err != nil {
+		log.Fatal(err)
+	}
+	client.OwnerPassword = *owner
+	if *ca != "" {
+		pem, err := os.ReadFile(*ca)
+		if err != nil {
+			log.Fatal(err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			log.Fatalf("%s: no certificates", *ca)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}}
+	}
+	t, err := tss.NewTPM()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &cmd{client: client, tpm: t, data: *data, args: flag.Args(), exec: syscall.Exec}
+	if err := c.run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
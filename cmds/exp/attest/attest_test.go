@@ -0,0 +1,82 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/attest"
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+type fakeTPM struct{}
+
+func (fakeTPM) AKPublic(string) ([]byte, error) { return []byte("ak"), nil }
+
+func (fakeTPM) MeasurementLog() ([]byte, error) { return nil, os.ErrNotExist }
+
+func (fakeTPM) Quote(_ string, nonce []byte, pcrs []int) (*tss.Quote, error) {
+	return &tss.Quote{PCRs: pcrs, Attest: nonce}, nil
+}
+
+func TestRun(t *testing.T) {
+	allow := true
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/challenge" {
+			json.NewEncoder(w).Encode(attest.Challenge{Nonce: []byte("n")})
+			return
+		}
+		json.NewEncoder(w).Encode(attest.Verdict{Allow: allow, Reason: "PCR 7 mismatch", Data: []byte("key")})
+	}))
+	defer s.Close()
+	client, err := attest.NewClient(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		allow bool
+		args  []string
+		want  []string
+		err   error
+	}{
+		{name: "allowed", allow: true},
+		{name: "exec", allow: true, args: []string{"true", "-x"}, want: []string{"true", "-x"}},
+		{name: "denied", args: []string{"true"}, err: attest.ErrDenied},
+	} {
+		allow = tt.allow
+		data := filepath.Join(t.TempDir(), "key")
+		var got []string
+		c := &cmd{client: client, tpm: fakeTPM{}, data: data, args: tt.args, exec: func(_ string, argv, _ []string) error {
+			got = argv
+			return nil
+		}}
+		if err := c.run(context.Background()); !errors.Is(err, tt.err) {
+			t.Errorf("%s: run = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: exec %q, want %q", tt.name, got, tt.want)
+		}
+		if b, err := os.ReadFile(data); tt.allow && (err != nil || string(b) != "key") {
+			t.Errorf("%s: data = %q, %v, want key", tt.name, b, err)
+		}
+	}
+
+	if _, err := parsePCRs("0,x"); err == nil {
+		t.Errorf("parsePCRs(0,x) = nil, want error")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// awk scans and processes patterns in text.
+//
+// Synopsis:
+//
+//	awk [-F FS] [-v VAR=VALUE]... 'PROGRAM' [FILE | VAR=VALUE]...
+//	awk [-F FS] [-v VAR=VALUE]... -f PROGFILE... [FILE | VAR=VALUE]...
+//
+// Description:
+//
+//	awk runs PROGRAM on each record of the FILEs, or stdin if there are
+//	none. The program is a list of rules: patterns, which are
+//	expressions, regexes or ranges, with actions in braces. BEGIN and END
+//	rules run before the first record and after the last. Records are
+//	split into fields $1 to $NF by FS.
+//
+//	This is a small AWK for the pipelines of scripts: it has variables,
+//	arrays, user functions, print and printf with redirections to files
+//	and commands, getline, and the arithmetic, string and I/O functions
+//	of POSIX. Strings are handled as bytes, and for-in loops go through
+//	arrays in sorted order.
+//
+//	Operands like VAR=VALUE assign the variable when they are reached.
+//
+// Options:
+//
+//	-F: set the field separator FS; t is a tab
+//	-v: assign VALUE to VAR before the program starts
+//	-f: read the program from PROGFILE, which may be given more than once
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var errUsage = errors.New("usage: awk [-F FS] [-v VAR=VALUE]... 'PROGRAM' | -f PROGFILE... [FILE | VAR=VALUE]...")
+
+// list is a flag which may be given more than once.
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+type cmd struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	prog     *program
+	fs       string
+	assigns  []string
+	operands []string
+}
+
+func command(stdin io.Reader, stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdin: stdin, stdout: stdout, stderr: stderr}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.StringVar(&c.fs, "F", "", "Field separator")
+	var assigns, files list
+	f.Var(&assigns, "v", "Assign VAR=VALUE before the program starts")
+	f.Var(&files, "f", "Read the program from this file")
+	if err := f.Parse(splitFlags(args[1:])); err != nil {
+		return nil, errUsage
+	}
+	for _, a := range assigns {
+		if !assignment.MatchString(a) {
+			return nil, fmt.Errorf("%w: bad assignment %q", errUsage, a)
+		}
+	}
+	c.assigns = assigns
+	c.operands = f.Args()
+
+	var src string
+	if len(files) > 0 {
+		var b strings.Builder
+		for _, name := range files {
+			p, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(p)
+			b.WriteByte('\n')
+		}
+		src = b.String()
+	} else {
+		if len(c.operands) == 0 {
+			return nil, errUsage
+		}
+		src, c.operands = c.operands[0], c.operands[1:]
+	}
+	prog, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	c.prog = prog
+	return c, nil
+}
+
+// splitFlags splits values from the options they are attached to, as in
+// -F: and -vX=1, which the flag package does not take.
+func splitFlags(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--" || len(a) < 2 || a[0] != '-':
+			return append(out, args[i:]...)
+		case len(a) == 2 && i+1 < len(args):
+			// The value is the next argument.
+			out = append(out, a, args[i+1])
+			i++
+		case len(a) > 2 && strings.IndexByte("Fvf", a[1]) >= 0 && a[2] != '=':
+			out = append(out, a[:2], a[2:])
+		default:
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// run runs the program and returns its exit status.
+func (c *cmd) run() (int, error) {
+	in := newInterp(c.prog, c.operands, c.stdin, c.stdout, c.stderr)
+	if c.fs != "" {
+		fs := unescape(c.fs)
+		if fs == "t" {
+			fs = "\t"
+		}
+		in.fs.v = str(fs)
+	}
+	for _, a := range c.assigns {
+		name, v, _ := strings.Cut(a, "=")
+		if err := in.setVar(&varExpr{name: name, local: -1}, input(unescape(v))); err != nil {
+			return 2, err
+		}
+	}
+	return in.run()
+}
+
+func main() {
+	c, err := command(os.Stdin, os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	code, err := c.run()
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	os.Exit(code)
+}
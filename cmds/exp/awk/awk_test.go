@@ -0,0 +1,202 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAwk(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+		code  int
+	}{
+		{name: "fields", args: []string{"{ print $2, NF, NR }"}, input: "a b c\nd e\n", want: "b 3 1\ne 2 2\n"},
+		{name: "default action", args: []string{"/b/"}, input: "abc\nxyz\nb\n", want: "abc\nb\n"},
+		{name: "not regex", args: []string{"!/b/"}, input: "abc\nxyz\n", want: "xyz\n"},
+		{name: "range", args: []string{"NR == 2, NR == 3"}, input: "1\n2\n3\n4\n", want: "2\n3\n"},
+		{name: "separator", args: []string{"-F:", "$2 > 1 { s += $2 } END { print s }"}, input: "a:1\nb:2\nc:3\n", want: "5\n"},
+		{name: "tab separator", args: []string{"-F", "t", "{ print $2 }"}, input: "a b\tc d\n", want: "c d\n"},
+		{name: "regex separator", args: []string{"-F", "[0-9]+", "{ print $1, $3 }"}, input: "a12b3c\n", want: "a c\n"},
+		{name: "blanks", args: []string{`{ print NF, "[" $1 "]" }`}, input: "  x \t y  \n", want: "2 [x]\n"},
+		{name: "assign", args: []string{"-v", "OFS=-", "-vx=a\\tb", "{ $3 = x; print; NF = 2; print }"}, input: "1 2\n", want: "1-2-a\tb\n1-2\n"},
+		{name: "begin only", args: []string{`BEGIN { print "no input" }`}, input: "ignored\n", want: "no input\n"},
+		{name: "next", args: []string{"/skip/ { next } { print }"}, input: "a\nskip\nb\n", want: "a\nb\n"},
+		{name: "exit", args: []string{"{ print; exit 3 } END { print \"end\" }"}, input: "a\nb\n", want: "a\nend\n", code: 3},
+		{name: "paragraphs", args: []string{`BEGIN { RS = "" } { print NR ": " $1 "," $2 }`}, input: "\na b\nc\n\n\nd\n", want: "1: a,b\n2: d,\n"},
+		{name: "record separator", args: []string{`BEGIN { RS = ";" } { print }`}, input: "a;b;c", want: "a\nb\nc\n"},
+		{
+			name: "arithmetic",
+			args: []string{`BEGIN { x = 1; x += 2; x *= 3; x ^= 2; print x, x % 7, -2^2, 2^3^2, 1/3, 7 / 2 / 7, x++ + ++x }`},
+			want: "81 4 -4 512 0.333333 0.5 164\n",
+		},
+		{
+			name:  "comparisons",
+			args:  []string{`{ print ($1 < $2), ("10" < "9"), ($1 == 10), ($3 == ""), (x == 0) }`},
+			input: "10 9\n",
+			want:  "0 1 1 1 1\n",
+		},
+		{
+			name: "control flow",
+			args: []string{`BEGIN {
+	for (i = 0; i < 10; i++) {
+		if (i == 2)
+			continue
+		else if (i == 5)
+			break
+		s = s i
+	}
+	do {
+		j++
+	} while (j < 3)
+	while (1) { if (++k > 4) break }
+	print s, j, k, (s ? "yes" : "no")
+}`},
+			want: "0134 3 5 yes\n",
+		},
+		{
+			name:  "arrays",
+			args:  []string{`{ n[$1]++ } END { for (k in n) print k, n[k]; delete n["b"]; print ("b" in n), length(n); a[1, 2] = 3; for (k in a) { split(k, p, SUBSEP); print p[1], p[2] } print ((1, 2) in a) }`},
+			input: "b\na\nb\n",
+			want:  "a 1\nb 2\n0 1\n1 2\n1\n",
+		},
+		{
+			name: "strings",
+			args: []string{`BEGIN {
+	s = "hello world"
+	print length(s), substr(s, 7), substr(s, 0, 3), substr(s, -1), index(s, "o"), toupper(substr(s, 1, 1)) tolower("ABC")
+	n = gsub(/o/, "[&]", s); print n, s
+	sub("\\[", "\\&", s); print s
+	n = split("a:b:c", parts, ":"); print n, parts[3]
+	n = split("a1b22c", parts, /[0-9]+/); print n, parts[2]
+	print match("foobar", /ob+/), RSTART, RLENGTH, match("x", /y/), RLENGTH
+}`},
+			want: "11 world he hello world 5 Habc\n2 hell[o] w[o]rld\nhell&o] w[o]rld\n3 c\n3 b\n3 3 2 0 -1\n",
+		},
+		{
+			name: "printf",
+			args: []string{`BEGIN { printf "%5.2f|%-4s|%03d|%x|%c|%c|%d%%|%*d|%s\n", 3.14159, "ab", 7, 255, 65, "hello", 50, 4, 1, 0.1 }`},
+			want: " 3.14|ab  |007|ff|A|h|50%|   1|0.1\n",
+		},
+		{
+			name: "number formats",
+			args: []string{`BEGIN { print 1e3, 0.1 + 0.2, -0.5; CONVFMT = "%.2g"; x = 3.14159; print (x ""); OFMT = "%.3f"; print x, 17 }`},
+			want: "1000 0.3 -0.5\n3.1\n3.142 17\n",
+		},
+		{
+			name: "functions",
+			args: []string{`function fib(n) { return n < 2 ? n : fib(n-1) + fib(n-2) }
+function fill(arr, n,    i) { for (i = 1; i <= n; i++) arr[i] = i * i }
+BEGIN { print fib(15); fill(sq, 3); print sq[3], i }`},
+			want: "610\n9 \n",
+		},
+		{
+			name:  "getline",
+			args:  []string{`NR == 1 { getline; print "after", $0; getline line; print "line", line, NR }`},
+			input: "a\nb\nc\n",
+			want:  "after b\nline c 3\n",
+		},
+		{
+			name: "pipes",
+			args: []string{`BEGIN { "echo hi there" | getline; print $2; print "b\na" | "sort"; close("sort"); print system("exit 3") }`},
+			want: "there\na\nb\n3\n",
+		},
+		{name: "operand assignment", args: []string{"{ print x, $0 }", "x=1", "-", "x=2"}, input: "a\n", want: "1 a\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c, err := command(strings.NewReader(tt.input), &out, &out, append([]string{"awk"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := c.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("got exit status %d, want %d", code, tt.code)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b, prog := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "prog.awk")
+	if err := os.WriteFile(a, []byte("1\n2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	src := `{ print FILENAME ":" FNR ":" NR > "` + out + `" }
+END { close("` + out + `"); while ((getline l < "` + out + `") > 0) print l; printf "x" >> "` + out + `" }
+`
+	if err := os.WriteFile(prog, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	c, err := command(strings.NewReader(""), &stdout, &stdout, []string{"awk", "-f", prog, a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	want := a + ":1:1\n" + a + ":2:2\n" + b + ":1:3\n"
+	if stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want+"x" {
+		t.Errorf("got %q in %s, want %q", got, out, want+"x")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{
+		{name: "no program", args: nil, err: errUsage},
+		{name: "syntax", args: []string{"BEGIN { print substr(\"abc\" }"}, want: `line 1: unexpected "}"`},
+		{name: "unterminated", args: []string{`BEGIN { print "abc }`}, want: "line 1: unterminated string"},
+		{name: "undefined function", args: []string{"BEGIN { f() }"}, want: `line 1: function "f" is not defined`},
+		{name: "break", args: []string{"{ break }"}, want: "line 1: break outside of a loop"},
+		{name: "division", args: []string{"BEGIN { print 1 / 0 }"}, err: errDivZero},
+		{name: "missing file", args: []string{"{ print }", "/does/not/exist"}, err: os.ErrNotExist},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c, err := command(strings.NewReader(""), &out, &out, append([]string{"awk"}, tt.args...))
+			if err == nil {
+				_, err = c.run()
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if tt.want != "" && (err == nil || err.Error() != tt.want) {
+				t.Errorf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
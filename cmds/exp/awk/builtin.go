@@ -0,0 +1,224 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (in *interp) builtin(e *callExpr) (value, error) {
+	// These builtins take arrays, regexes or variables.
+	switch e.name {
+	case "length":
+		if len(e.args) == 0 {
+			return num(float64(len(in.fields[0]))), nil
+		}
+		if v, ok := e.args[0].(*varExpr); ok {
+			if c := in.cell(v); c.arr != nil {
+				return num(float64(len(c.arr))), nil
+			}
+		}
+	case "split":
+		return in.splitArray(e.args)
+	case "sub", "gsub":
+		return in.sub(e.args, e.name == "gsub")
+	case "match":
+		s, err := in.eval(e.args[0])
+		if err != nil {
+			return value{}, err
+		}
+		re, err := in.regexOf(e.args[1])
+		if err != nil {
+			return value{}, err
+		}
+		start, length := 0, -1
+		if loc := re.FindStringIndex(in.toStr(s)); loc != nil {
+			start, length = loc[0]+1, loc[1]-loc[0]
+		}
+		in.rstart.v, in.rlength.v = num(float64(start)), num(float64(length))
+		return num(float64(start)), nil
+	}
+
+	args := make([]value, len(e.args))
+	for i, x := range e.args {
+		v, err := in.eval(x)
+		if err != nil {
+			return value{}, err
+		}
+		args[i] = v
+	}
+	switch e.name {
+	case "length":
+		return num(float64(len(in.toStr(args[0])))), nil
+	case "substr":
+		return str(in.substr(args)), nil
+	case "index":
+		t := in.toStr(args[1])
+		if t == "" {
+			return num(0), nil
+		}
+		return num(float64(strings.Index(in.toStr(args[0]), t) + 1)), nil
+	case "sprintf":
+		return str(in.sprintf(in.toStr(args[0]), args[1:])), nil
+	case "tolower":
+		return str(strings.ToLower(in.toStr(args[0]))), nil
+	case "toupper":
+		return str(strings.ToUpper(in.toStr(args[0]))), nil
+	case "int":
+		return num(math.Trunc(args[0].num())), nil
+	case "sqrt":
+		return num(math.Sqrt(args[0].num())), nil
+	case "exp":
+		return num(math.Exp(args[0].num())), nil
+	case "log":
+		return num(math.Log(args[0].num())), nil
+	case "sin":
+		return num(math.Sin(args[0].num())), nil
+	case "cos":
+		return num(math.Cos(args[0].num())), nil
+	case "atan2":
+		return num(math.Atan2(args[0].num(), args[1].num())), nil
+	case "rand":
+		return num(in.rand.Float64()), nil
+	case "srand":
+		prev := in.seed
+		in.seed = float64(time.Now().Unix())
+		if len(args) > 0 {
+			in.seed = args[0].num()
+		}
+		in.rand = rand.New(rand.NewSource(int64(in.seed)))
+		return num(prev), nil
+	case "system":
+		return num(float64(in.system(in.toStr(args[0])))), nil
+	case "close":
+		return num(float64(in.closeStream(in.toStr(args[0])))), nil
+	case "fflush":
+		if len(args) == 0 {
+			if err := in.flush(); err != nil {
+				return num(-1), nil
+			}
+			return num(0), nil
+		}
+		o, ok := in.outputs[in.toStr(args[0])]
+		if !ok || o.w.Flush() != nil {
+			return num(-1), nil
+		}
+		return num(0), nil
+	}
+	return value{}, nil
+}
+
+// substr returns the characters of s from m on, or n of them, where
+// positions are rounded and those outside of s are left out.
+func (in *interp) substr(args []value) string {
+	s := in.toStr(args[0])
+	start := math.Round(args[1].num())
+	end := math.Inf(1)
+	if len(args) > 2 {
+		end = start + math.Round(args[2].num())
+	}
+	start = math.Max(start, 1)
+	end = math.Min(end, float64(len(s)+1))
+	if math.IsNaN(start) || math.IsNaN(end) || end <= start {
+		return ""
+	}
+	return s[int(start)-1 : int(end)-1]
+}
+
+// splitArray implements split(s, a[, fs]).
+func (in *interp) splitArray(args []expr) (value, error) {
+	s, err := in.eval(args[0])
+	if err != nil {
+		return value{}, err
+	}
+	var parts []string
+	switch {
+	case len(args) < 3:
+		parts, err = in.split(in.toStr(s), in.toStr(in.fs.v), false)
+	default:
+		if re, ok := args[2].(*regexExpr); ok {
+			var r *regexp.Regexp
+			if r, err = in.regex(re.re); err == nil && in.toStr(s) != "" {
+				parts = r.Split(in.toStr(s), -1)
+			}
+			break
+		}
+		var fs value
+		if fs, err = in.eval(args[2]); err == nil {
+			parts, err = in.split(in.toStr(s), in.toStr(fs), false)
+		}
+	}
+	if err != nil {
+		return value{}, err
+	}
+	arr := in.array(args[1].(*varExpr))
+	clear(arr)
+	for i, p := range parts {
+		arr[strconv.Itoa(i+1)] = input(p)
+	}
+	return num(float64(len(parts))), nil
+}
+
+// sub implements sub and gsub, which replace the first or all matches in
+// a variable, or $0.
+func (in *interp) sub(args []expr, global bool) (value, error) {
+	re, err := in.regexOf(args[0])
+	if err != nil {
+		return value{}, err
+	}
+	repl, err := in.eval(args[1])
+	if err != nil {
+		return value{}, err
+	}
+	var target expr = &fieldExpr{index: &numExpr{}}
+	if len(args) > 2 {
+		target = args[2]
+	}
+	r, err := in.ref(target)
+	if err != nil {
+		return value{}, err
+	}
+	s, n := substitute(re, in.toStr(in.get(r)), in.toStr(repl), global)
+	if n > 0 {
+		if err := in.set(r, str(s)); err != nil {
+			return value{}, err
+		}
+	}
+	return num(float64(n)), nil
+}
+
+// substitute replaces matches of re in s by repl, in which & is the match
+// and \& a literal &.
+func substitute(re *regexp.Regexp, s, repl string, global bool) (string, int) {
+	limit := 1
+	if global {
+		limit = -1
+	}
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range re.FindAllStringIndex(s, limit) {
+		b.WriteString(s[last:m[0]])
+		for i := 0; i < len(repl); i++ {
+			switch c := repl[i]; {
+			case c == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+				i++
+				b.WriteByte(repl[i])
+			case c == '&':
+				b.WriteString(s[m[0]:m[1]])
+			default:
+				b.WriteByte(c)
+			}
+		}
+		last = m[1]
+		n++
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
@@ -0,0 +1,945 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errNext and errExit unwind the interpreter for next and exit.
+	errNext    = errors.New("next")
+	errExit    = errors.New("exit")
+	errDivZero = errors.New("division by zero")
+)
+
+// ctrl tells how a statement ends.
+type ctrl int
+
+const (
+	ctrlNone ctrl = iota
+	ctrlBreak
+	ctrlContinue
+	ctrlReturn
+)
+
+// cell holds a variable, which is an array once arr is set.
+type cell struct {
+	v   value
+	arr map[string]value
+}
+
+type interp struct {
+	prog    *program
+	globals map[string]*cell
+	// frame holds the parameters of the running function.
+	frame []*cell
+	ret   value
+	// fields holds $0 and the fields of the record.
+	fields []string
+	ranges []bool
+
+	stdin   io.Reader
+	out     io.Writer
+	stdout  *bufio.Writer
+	stderr  io.Writer
+	outputs map[string]*output
+	inputs  map[string]*inputStream
+	// main is the current main input. argIndex is the next ARGV
+	// operand, and opened tells whether any file was.
+	main     *inputStream
+	argIndex int
+	opened   bool
+
+	regexps  map[string]*regexp.Regexp
+	rand     *rand.Rand
+	seed     float64
+	exitCode int
+
+	nr, fnr, fs, ofs, ors, rs, subsep, convfmt, ofmt, rstart, rlength, filename *cell
+}
+
+func newInterp(prog *program, operands []string, stdin io.Reader, stdout, stderr io.Writer) *interp {
+	lockWriters(&stdout, &stderr)
+	in := &interp{
+		prog:    prog,
+		globals: map[string]*cell{},
+		fields:  []string{""},
+		ranges:  make([]bool, len(prog.items)),
+		stdin:   stdin,
+		out:     stdout,
+		stdout:  bufio.NewWriter(stdout),
+		stderr:  stderr,
+		outputs: map[string]*output{},
+		inputs:  map[string]*inputStream{},
+		regexps: map[string]*regexp.Regexp{},
+		rand:    rand.New(rand.NewSource(0)),
+	}
+	for _, v := range []struct {
+		c    **cell
+		name string
+		v    value
+	}{
+		{&in.nr, "NR", num(0)},
+		{&in.fnr, "FNR", num(0)},
+		{&in.fs, "FS", str(" ")},
+		{&in.ofs, "OFS", str(" ")},
+		{&in.ors, "ORS", str("\n")},
+		{&in.rs, "RS", str("\n")},
+		{&in.subsep, "SUBSEP", str("\x1c")},
+		{&in.convfmt, "CONVFMT", str("%.6g")},
+		{&in.ofmt, "OFMT", str("%.6g")},
+		{&in.rstart, "RSTART", num(0)},
+		{&in.rlength, "RLENGTH", num(-1)},
+		{&in.filename, "FILENAME", str("")},
+	} {
+		*v.c = &cell{v: v.v}
+		in.globals[v.name] = *v.c
+	}
+	env := map[string]value{}
+	for _, e := range os.Environ() {
+		k, v, _ := strings.Cut(e, "=")
+		env[k] = input(v)
+	}
+	in.globals["ENVIRON"] = &cell{arr: env}
+	argv := map[string]value{"0": str("awk")}
+	for i, a := range operands {
+		argv[strconv.Itoa(i+1)] = input(a)
+	}
+	in.globals["ARGV"] = &cell{arr: argv}
+	in.globals["ARGC"] = &cell{v: num(float64(len(operands) + 1))}
+	in.argIndex = 1
+	return in
+}
+
+// run runs the program and returns its exit status.
+func (in *interp) run() (int, error) {
+	err := in.blocks(in.prog.begin)
+	if err == nil && (len(in.prog.items) > 0 || len(in.prog.end) > 0) {
+		err = in.records()
+	}
+	if err == nil || errors.Is(err, errExit) {
+		err = in.blocks(in.prog.end)
+	}
+	if errors.Is(err, errExit) {
+		err = nil
+	}
+	if errors.Is(err, errNext) {
+		err = fmt.Errorf("next used in BEGIN or END")
+	}
+	if cerr := in.closeAll(); err == nil {
+		err = cerr
+	}
+	return in.exitCode, err
+}
+
+func (in *interp) blocks(bs []blockStmt) error {
+	for _, b := range bs {
+		if _, err := in.exec(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records runs the rules on each record of the main input.
+func (in *interp) records() error {
+	for {
+		rec, ok, err := in.nextRecord()
+		if err != nil || !ok {
+			return err
+		}
+		in.incr(in.nr)
+		in.incr(in.fnr)
+		if err := in.setRecord(rec); err != nil {
+			return err
+		}
+		if err := in.rules(); err != nil && !errors.Is(err, errNext) {
+			return err
+		}
+	}
+}
+
+func (in *interp) rules() error {
+	for i, it := range in.prog.items {
+		ok, err := in.matches(i, &it)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if it.body == nil {
+			if _, err := in.stdout.WriteString(in.fields[0] + in.toStr(in.ors.v)); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := in.exec(it.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches tells whether the pattern of item i matches the record.
+func (in *interp) matches(i int, it *item) (bool, error) {
+	if it.pattern == nil {
+		return true, nil
+	}
+	if !it.isRange {
+		v, err := in.eval(it.pattern)
+		return v.bool(), err
+	}
+	if !in.ranges[i] {
+		v, err := in.eval(it.pattern)
+		if err != nil || !v.bool() {
+			return false, err
+		}
+		in.ranges[i] = true
+	}
+	v, err := in.eval(it.end)
+	if v.bool() {
+		in.ranges[i] = false
+	}
+	return true, err
+}
+
+func (in *interp) incr(c *cell) {
+	c.v = num(c.v.num() + 1)
+}
+
+func (in *interp) toStr(v value) string {
+	if v.k == kNum {
+		return formatNum(v.n, in.format(in.convfmt))
+	}
+	return v.s
+}
+
+// outStr converts v to a string for print.
+func (in *interp) outStr(v value) string {
+	if v.k == kNum {
+		return formatNum(v.n, in.format(in.ofmt))
+	}
+	return v.s
+}
+
+func (in *interp) format(c *cell) string {
+	if c.v.k == kNum {
+		return "%.6g"
+	}
+	return c.v.s
+}
+
+func (in *interp) regex(s string) (*regexp.Regexp, error) {
+	if re, ok := in.regexps[s]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad regex %q: %w", s, err)
+	}
+	// AWK matches the leftmost longest match, like POSIX.
+	re.Longest()
+	in.regexps[s] = re
+	return re, nil
+}
+
+// regexOf returns the regex of e, which is a literal or a string.
+func (in *interp) regexOf(e expr) (*regexp.Regexp, error) {
+	if re, ok := e.(*regexExpr); ok {
+		return in.regex(re.re)
+	}
+	v, err := in.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return in.regex(in.toStr(v))
+}
+
+// Records and fields.
+
+// split splits s into fields by fs. Newlines separate fields of records
+// too if RS is empty.
+func (in *interp) split(s, fs string, record bool) ([]string, error) {
+	paragraph := record && in.toStr(in.rs.v) == ""
+	switch {
+	case s == "":
+		return nil, nil
+	case fs == " ":
+		return strings.FieldsFunc(s, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\n'
+		}), nil
+	case len(fs) == 1 && fs != "\\" && !paragraph:
+		return strings.Split(s, fs), nil
+	}
+	pat := fs
+	if len(fs) == 1 {
+		pat = regexp.QuoteMeta(fs)
+	}
+	if paragraph {
+		pat = "(" + pat + ")|\n"
+	}
+	re, err := in.regex(pat)
+	if err != nil {
+		return nil, err
+	}
+	return re.Split(s, -1), nil
+}
+
+func (in *interp) setRecord(s string) error {
+	f, err := in.split(s, in.toStr(in.fs.v), true)
+	if err != nil {
+		return err
+	}
+	in.fields = append(append(in.fields[:0], s), f...)
+	return nil
+}
+
+func (in *interp) nf() int {
+	return len(in.fields) - 1
+}
+
+func (in *interp) field(i int) value {
+	if i > in.nf() {
+		return value{}
+	}
+	return input(in.fields[i])
+}
+
+func (in *interp) setField(i int, s string) error {
+	if i == 0 {
+		return in.setRecord(s)
+	}
+	for i > in.nf() {
+		in.fields = append(in.fields, "")
+	}
+	in.fields[i] = s
+	in.rebuild()
+	return nil
+}
+
+func (in *interp) setNF(n int) error {
+	if n < 0 {
+		return fmt.Errorf("NF set to negative value %d", n)
+	}
+	for n > in.nf() {
+		in.fields = append(in.fields, "")
+	}
+	in.fields = in.fields[:n+1]
+	in.rebuild()
+	return nil
+}
+
+// rebuild joins the fields into $0.
+func (in *interp) rebuild() {
+	in.fields[0] = strings.Join(in.fields[1:], in.toStr(in.ofs.v))
+}
+
+func (in *interp) fieldIndex(e expr) (int, error) {
+	v, err := in.eval(e)
+	if err != nil {
+		return 0, err
+	}
+	n := v.num()
+	if n < 0 {
+		return 0, fmt.Errorf("field $%s is negative", in.toStr(v))
+	}
+	return int(n), nil
+}
+
+// Variables.
+
+// cell returns the cell of a variable.
+func (in *interp) cell(v *varExpr) *cell {
+	if v.local >= 0 {
+		return in.frame[v.local]
+	}
+	c, ok := in.globals[v.name]
+	if !ok {
+		c = &cell{}
+		in.globals[v.name] = c
+	}
+	return c
+}
+
+func (in *interp) getVar(v *varExpr) value {
+	if v.local < 0 && v.name == "NF" {
+		return num(float64(in.nf()))
+	}
+	return in.cell(v).v
+}
+
+func (in *interp) setVar(v *varExpr, val value) error {
+	if v.local < 0 && v.name == "NF" {
+		return in.setNF(int(val.num()))
+	}
+	c := in.cell(v)
+	if c.arr != nil {
+		return fmt.Errorf("can't assign to %s; it's an array", v.name)
+	}
+	c.v = val
+	return nil
+}
+
+func (in *interp) array(v *varExpr) map[string]value {
+	c := in.cell(v)
+	if c.arr == nil {
+		c.arr = map[string]value{}
+	}
+	return c.arr
+}
+
+// key returns the subscript of index, joining several with SUBSEP.
+func (in *interp) key(index []expr) (string, error) {
+	if len(index) == 1 {
+		v, err := in.eval(index[0])
+		return in.toStr(v), err
+	}
+	parts := make([]string, len(index))
+	for i, x := range index {
+		v, err := in.eval(x)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = in.toStr(v)
+	}
+	return strings.Join(parts, in.toStr(in.subsep.v)), nil
+}
+
+// ref is an assignable variable, array element or field.
+type ref struct {
+	e     expr
+	arr   map[string]value
+	key   string
+	field int
+}
+
+func (in *interp) ref(e expr) (ref, error) {
+	r := ref{e: e}
+	var err error
+	switch e := e.(type) {
+	case *indexExpr:
+		r.arr = in.array(e.array)
+		r.key, err = in.key(e.index)
+	case *fieldExpr:
+		r.field, err = in.fieldIndex(e.index)
+	}
+	return r, err
+}
+
+func (in *interp) get(r ref) value {
+	switch e := r.e.(type) {
+	case *varExpr:
+		return in.getVar(e)
+	case *indexExpr:
+		v, ok := r.arr[r.key]
+		if !ok {
+			r.arr[r.key] = v
+		}
+		return v
+	}
+	return in.field(r.field)
+}
+
+func (in *interp) set(r ref, v value) error {
+	switch e := r.e.(type) {
+	case *varExpr:
+		return in.setVar(e, v)
+	case *indexExpr:
+		r.arr[r.key] = v
+		return nil
+	}
+	return in.setField(r.field, in.toStr(v))
+}
+
+// Expressions.
+
+func (in *interp) eval(e expr) (value, error) {
+	switch e := e.(type) {
+	case *numExpr:
+		return num(e.n), nil
+	case *strExpr:
+		return str(e.s), nil
+	case *regexExpr:
+		re, err := in.regex(e.re)
+		if err != nil {
+			return value{}, err
+		}
+		return boolean(re.MatchString(in.fields[0])), nil
+	case *varExpr:
+		return in.getVar(e), nil
+	case *indexExpr, *fieldExpr:
+		r, err := in.ref(e)
+		if err != nil {
+			return value{}, err
+		}
+		return in.get(r), nil
+	case *assignExpr:
+		r, err := in.ref(e.left)
+		if err != nil {
+			return value{}, err
+		}
+		v, err := in.eval(e.right)
+		if err != nil {
+			return value{}, err
+		}
+		if e.op != tAssign {
+			if v, err = in.binary(assignOps[e.op], in.get(r), v); err != nil {
+				return value{}, err
+			}
+		}
+		return v, in.set(r, v)
+	case *condExpr:
+		c, err := in.eval(e.cond)
+		if err != nil {
+			return value{}, err
+		}
+		if c.bool() {
+			return in.eval(e.yes)
+		}
+		return in.eval(e.no)
+	case *binaryExpr:
+		l, err := in.eval(e.l)
+		if err != nil {
+			return value{}, err
+		}
+		switch e.op {
+		case tAnd:
+			if !l.bool() {
+				return num(0), nil
+			}
+		case tOr:
+			if l.bool() {
+				return num(1), nil
+			}
+		}
+		r, err := in.eval(e.r)
+		if err != nil {
+			return value{}, err
+		}
+		return in.binary(e.op, l, r)
+	case *concatExpr:
+		l, err := in.eval(e.l)
+		if err != nil {
+			return value{}, err
+		}
+		r, err := in.eval(e.r)
+		if err != nil {
+			return value{}, err
+		}
+		return str(in.toStr(l) + in.toStr(r)), nil
+	case *unaryExpr:
+		x, err := in.eval(e.x)
+		if err != nil {
+			return value{}, err
+		}
+		switch e.op {
+		case tNot:
+			return boolean(!x.bool()), nil
+		case tSub:
+			return num(-x.num()), nil
+		}
+		return num(x.num()), nil
+	case *incrExpr:
+		r, err := in.ref(e.x)
+		if err != nil {
+			return value{}, err
+		}
+		old := in.get(r).num()
+		n := old + 1
+		if e.op == tDecr {
+			n = old - 1
+		}
+		if err := in.set(r, num(n)); err != nil {
+			return value{}, err
+		}
+		if e.pre {
+			return num(n), nil
+		}
+		return num(old), nil
+	case *matchExpr:
+		x, err := in.eval(e.x)
+		if err != nil {
+			return value{}, err
+		}
+		re, err := in.regexOf(e.re)
+		if err != nil {
+			return value{}, err
+		}
+		return boolean(re.MatchString(in.toStr(x)) != e.not), nil
+	case *inExpr:
+		k, err := in.key(e.index)
+		if err != nil {
+			return value{}, err
+		}
+		_, ok := in.array(e.array)[k]
+		return boolean(ok), nil
+	case *callExpr:
+		return in.builtin(e)
+	case *userCallExpr:
+		return in.call(e)
+	case *getlineExpr:
+		return in.getline(e)
+	}
+	return value{}, fmt.Errorf("unknown expression %T", e)
+}
+
+var assignOps = map[tokenKind]tokenKind{
+	tAddAssign: tAdd,
+	tSubAssign: tSub,
+	tMulAssign: tMul,
+	tDivAssign: tDiv,
+	tModAssign: tMod,
+	tPowAssign: tPow,
+}
+
+func (in *interp) binary(op tokenKind, l, r value) (value, error) {
+	switch op {
+	case tAdd:
+		return num(l.num() + r.num()), nil
+	case tSub:
+		return num(l.num() - r.num()), nil
+	case tMul:
+		return num(l.num() * r.num()), nil
+	case tDiv:
+		if r.num() == 0 {
+			return value{}, errDivZero
+		}
+		return num(l.num() / r.num()), nil
+	case tMod:
+		if r.num() == 0 {
+			return value{}, errDivZero
+		}
+		return num(math.Mod(l.num(), r.num())), nil
+	case tPow:
+		return num(math.Pow(l.num(), r.num())), nil
+	case tAnd, tOr:
+		return boolean(r.bool()), nil
+	}
+	c := in.compare(l, r)
+	switch op {
+	case tLess:
+		return boolean(c < 0), nil
+	case tLessEqual:
+		return boolean(c <= 0), nil
+	case tGreater:
+		return boolean(c > 0), nil
+	case tGreaterEqual:
+		return boolean(c >= 0), nil
+	case tEqual:
+		return boolean(c == 0), nil
+	case tNotEqual:
+		return boolean(c != 0), nil
+	}
+	return value{}, fmt.Errorf("unknown operator %d", op)
+}
+
+// compare compares numerically if both values are numbers, and as
+// strings otherwise.
+func (in *interp) compare(l, r value) int {
+	if l.isNum() && r.isNum() {
+		switch a, b := l.num(), r.num(); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(in.toStr(l), in.toStr(r))
+}
+
+func (in *interp) call(e *userCallExpr) (value, error) {
+	f := e.fn
+	frame := make([]*cell, len(f.params))
+	for i := range frame {
+		if i >= len(e.args) {
+			frame[i] = &cell{}
+			continue
+		}
+		// Arrays are passed by reference.
+		if v, ok := e.args[i].(*varExpr); ok && !(v.local < 0 && v.name == "NF") {
+			c := in.cell(v)
+			if c.arr != nil || (f.arrays[i] && c.v.k == kNull) {
+				if c.arr == nil {
+					c.arr = map[string]value{}
+				}
+				frame[i] = &cell{arr: c.arr}
+				continue
+			}
+		}
+		v, err := in.eval(e.args[i])
+		if err != nil {
+			return value{}, err
+		}
+		frame[i] = &cell{v: v}
+	}
+	saved := in.frame
+	in.frame = frame
+	_, err := in.exec(f.body)
+	in.frame = saved
+	ret := in.ret
+	in.ret = value{}
+	return ret, err
+}
+
+// Statements.
+
+func (in *interp) exec(s stmt) (ctrl, error) {
+	switch s := s.(type) {
+	case blockStmt:
+		for _, x := range s {
+			if c, err := in.exec(x); c != ctrlNone || err != nil {
+				return c, err
+			}
+		}
+	case *exprStmt:
+		_, err := in.eval(s.x)
+		return ctrlNone, err
+	case *printStmt:
+		return ctrlNone, in.print(s)
+	case *ifStmt:
+		c, err := in.eval(s.cond)
+		if err != nil {
+			return ctrlNone, err
+		}
+		if c.bool() {
+			return in.exec(s.then)
+		}
+		if s.els != nil {
+			return in.exec(s.els)
+		}
+	case *whileStmt:
+		for {
+			c, err := in.eval(s.cond)
+			if err != nil || !c.bool() {
+				return ctrlNone, err
+			}
+			if c, err := in.exec(s.body); err != nil || c == ctrlBreak || c == ctrlReturn {
+				return loopEnd(c), err
+			}
+		}
+	case *doStmt:
+		for {
+			if c, err := in.exec(s.body); err != nil || c == ctrlBreak || c == ctrlReturn {
+				return loopEnd(c), err
+			}
+			c, err := in.eval(s.cond)
+			if err != nil || !c.bool() {
+				return ctrlNone, err
+			}
+		}
+	case *forStmt:
+		if s.init != nil {
+			if _, err := in.exec(s.init); err != nil {
+				return ctrlNone, err
+			}
+		}
+		for {
+			if s.cond != nil {
+				c, err := in.eval(s.cond)
+				if err != nil || !c.bool() {
+					return ctrlNone, err
+				}
+			}
+			if c, err := in.exec(s.body); err != nil || c == ctrlBreak || c == ctrlReturn {
+				return loopEnd(c), err
+			}
+			if s.post != nil {
+				if _, err := in.exec(s.post); err != nil {
+					return ctrlNone, err
+				}
+			}
+		}
+	case *forInStmt:
+		arr := in.array(s.array)
+		for _, k := range sortedKeys(arr) {
+			if _, ok := arr[k]; !ok {
+				continue
+			}
+			if err := in.setVar(s.v, input(k)); err != nil {
+				return ctrlNone, err
+			}
+			if c, err := in.exec(s.body); err != nil || c == ctrlBreak || c == ctrlReturn {
+				return loopEnd(c), err
+			}
+		}
+	case *nextStmt:
+		return ctrlNone, errNext
+	case *exitStmt:
+		if s.x != nil {
+			v, err := in.eval(s.x)
+			if err != nil {
+				return ctrlNone, err
+			}
+			in.exitCode = int(v.num())
+		}
+		return ctrlNone, errExit
+	case *returnStmt:
+		if s.x != nil {
+			v, err := in.eval(s.x)
+			if err != nil {
+				return ctrlNone, err
+			}
+			in.ret = v
+		}
+		return ctrlReturn, nil
+	case *breakStmt:
+		return ctrlBreak, nil
+	case *continueStmt:
+		return ctrlContinue, nil
+	case *deleteStmt:
+		arr := in.array(s.array)
+		if s.index == nil {
+			clear(arr)
+			return ctrlNone, nil
+		}
+		k, err := in.key(s.index)
+		if err != nil {
+			return ctrlNone, err
+		}
+		delete(arr, k)
+	default:
+		return ctrlNone, fmt.Errorf("unknown statement %T", s)
+	}
+	return ctrlNone, nil
+}
+
+// loopEnd is how a statement ends when its loop ends with c.
+func loopEnd(c ctrl) ctrl {
+	if c == ctrlReturn {
+		return c
+	}
+	return ctrlNone
+}
+
+// sortedKeys returns the keys of arr, sorted as numbers if they all are
+// ones, so that for-in loops have a stable order.
+func sortedKeys(arr map[string]value) []string {
+	keys := make([]string, 0, len(arr))
+	numeric := true
+	for k := range arr {
+		keys = append(keys, k)
+		if _, ok := looksNumeric(k); !ok {
+			numeric = false
+		}
+	}
+	if numeric {
+		slices.SortFunc(keys, func(a, b string) int {
+			na, _ := looksNumeric(a)
+			nb, _ := looksNumeric(b)
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return strings.Compare(a, b)
+		})
+	} else {
+		slices.Sort(keys)
+	}
+	return keys
+}
+
+func (in *interp) print(s *printStmt) error {
+	var text string
+	switch {
+	case s.printf:
+		args := make([]value, len(s.args))
+		for i, x := range s.args {
+			v, err := in.eval(x)
+			if err != nil {
+				return err
+			}
+			args[i] = v
+		}
+		text = in.sprintf(in.toStr(args[0]), args[1:])
+	case len(s.args) == 0:
+		text = in.fields[0] + in.toStr(in.ors.v)
+	default:
+		parts := make([]string, len(s.args))
+		for i, x := range s.args {
+			v, err := in.eval(x)
+			if err != nil {
+				return err
+			}
+			parts[i] = in.outStr(v)
+		}
+		text = strings.Join(parts, in.toStr(in.ofs.v)) + in.toStr(in.ors.v)
+	}
+	if s.redirect == 0 {
+		_, err := in.stdout.WriteString(text)
+		return err
+	}
+	dest, err := in.eval(s.dest)
+	if err != nil {
+		return err
+	}
+	o, err := in.output(s.redirect, in.toStr(dest))
+	if err != nil {
+		return err
+	}
+	if _, err := o.w.WriteString(text); err != nil {
+		return err
+	}
+	if o.unbuffered {
+		return o.w.Flush()
+	}
+	return nil
+}
+
+func (in *interp) getline(e *getlineExpr) (value, error) {
+	var (
+		rec string
+		ok  bool
+		err error
+	)
+	switch e.from {
+	case tLess, tPipe:
+		src, err := in.eval(e.src)
+		if err != nil {
+			return value{}, err
+		}
+		s, err := in.input(in.toStr(src), e.from == tPipe)
+		if err != nil {
+			return num(-1), nil
+		}
+		rec, ok, err = s.read(in)
+		if ok && e.from == tPipe {
+			in.incr(in.nr)
+		}
+	default:
+		rec, ok, err = in.nextRecord()
+		if ok {
+			in.incr(in.nr)
+			in.incr(in.fnr)
+		}
+	}
+	switch {
+	case err != nil:
+		return num(-1), nil
+	case !ok:
+		return num(0), nil
+	case e.target == nil:
+		return num(1), in.setRecord(rec)
+	}
+	r, err := in.ref(e.target)
+	if err != nil {
+		return value{}, err
+	}
+	return num(1), in.set(r, input(rec))
+}
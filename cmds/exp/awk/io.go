@@ -0,0 +1,375 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// shell runs the commands of pipes and system.
+var shell = "/bin/sh"
+
+// lockedWriter serializes writes to w. exec copies the output of commands
+// to writers that are not files from its own goroutines, while awk may
+// still write to them.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// lockWriters wraps the writers that are not files in lockedWriters with
+// one lock, as they may be the same.
+func lockWriters(ws ...*io.Writer) {
+	mu := &sync.Mutex{}
+	for _, w := range ws {
+		if _, ok := (*w).(*os.File); !ok {
+			*w = &lockedWriter{mu: mu, w: *w}
+		}
+	}
+}
+
+// childStdin is the standard input of commands. Only a file is passed on:
+// exec would copy any other reader from its own goroutine, taking input
+// from awk and from other commands.
+func (in *interp) childStdin() io.Reader {
+	if f, ok := in.stdin.(*os.File); ok {
+		return f
+	}
+	return nil
+}
+
+// inputStream is the main input, a file of getline or the output of a
+// command.
+type inputStream struct {
+	r   *bufio.Reader
+	c   io.Closer
+	cmd *exec.Cmd
+	// rest holds the unread input when RS is a regex, which reads all
+	// of it at once.
+	rest    []byte
+	readAll bool
+}
+
+// read reads a record, separated by RS.
+func (s *inputStream) read(in *interp) (string, bool, error) {
+	rs := in.toStr(in.rs.v)
+	switch {
+	case rs == "":
+		// Records are separated by blank lines.
+		var lines []string
+		for {
+			l, err := s.r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return "", false, err
+			}
+			if l == "\n" {
+				if len(lines) > 0 {
+					return strings.Join(lines, "\n"), true, nil
+				}
+				continue
+			}
+			if l != "" {
+				lines = append(lines, strings.TrimSuffix(l, "\n"))
+			}
+			if err == io.EOF {
+				return strings.Join(lines, "\n"), len(lines) > 0, nil
+			}
+		}
+	case len(rs) == 1:
+		l, err := s.r.ReadString(rs[0])
+		switch {
+		case err == io.EOF:
+			return l, l != "", nil
+		case err != nil:
+			return "", false, err
+		}
+		return l[:len(l)-1], true, nil
+	}
+	if !s.readAll {
+		b, err := io.ReadAll(s.r)
+		if err != nil {
+			return "", false, err
+		}
+		s.rest, s.readAll = b, true
+	}
+	if len(s.rest) == 0 {
+		return "", false, nil
+	}
+	re, err := in.regex(rs)
+	if err != nil {
+		return "", false, err
+	}
+	loc := re.FindIndex(s.rest)
+	if loc == nil || loc[1] == 0 {
+		rec := string(s.rest)
+		s.rest = nil
+		return rec, true, nil
+	}
+	rec := string(s.rest[:loc[0]])
+	s.rest = s.rest[loc[1]:]
+	return rec, true, nil
+}
+
+// close closes the stream and returns the exit status of its command.
+func (s *inputStream) close() int {
+	if s.c != nil {
+		s.c.Close()
+	}
+	if s.cmd != nil {
+		return exitStatus(s.cmd.Wait())
+	}
+	return 0
+}
+
+func exitStatus(err error) int {
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &ee):
+		return ee.ExitCode()
+	}
+	return -1
+}
+
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// nextRecord reads a record of the main input, which are the files of
+// ARGV or stdin. Operands like VAR=VALUE assign variables instead.
+func (in *interp) nextRecord() (string, bool, error) {
+	for {
+		if in.main == nil {
+			ok, err := in.nextFile()
+			if err != nil || !ok {
+				return "", false, err
+			}
+		}
+		rec, ok, err := in.main.read(in)
+		if err != nil || ok {
+			return rec, ok, err
+		}
+		in.main.close()
+		in.main = nil
+	}
+}
+
+func (in *interp) nextFile() (bool, error) {
+	argc := int(in.globals["ARGC"].v.num())
+	argv := in.array(&varExpr{name: "ARGV", local: -1})
+	for ; in.argIndex < argc; in.argIndex++ {
+		arg := in.toStr(argv[strconv.Itoa(in.argIndex)])
+		if arg == "" {
+			continue
+		}
+		if assignment.MatchString(arg) {
+			name, v, _ := strings.Cut(arg, "=")
+			if err := in.setVar(&varExpr{name: name, local: -1}, input(unescape(v))); err != nil {
+				return false, err
+			}
+			continue
+		}
+		in.argIndex++
+		in.opened = true
+		if err := in.openMain(arg); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if in.opened {
+		return false, nil
+	}
+	in.opened = true
+	return true, in.openMain("-")
+}
+
+func (in *interp) openMain(name string) error {
+	s := &inputStream{}
+	if name == "-" || name == "/dev/stdin" {
+		s.r = bufio.NewReader(in.stdin)
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		s.r, s.c = bufio.NewReader(f), f
+	}
+	in.main = s
+	in.filename.v = str(name)
+	in.fnr.v = num(0)
+	return nil
+}
+
+// input returns the stream of getline from a file or command.
+func (in *interp) input(name string, command bool) (*inputStream, error) {
+	if s, ok := in.inputs[name]; ok {
+		return s, nil
+	}
+	s := &inputStream{}
+	switch {
+	case command:
+		if err := in.flush(); err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(shell, "-c", name)
+		cmd.Stdin = in.childStdin()
+		cmd.Stderr = in.stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		s.r, s.cmd = bufio.NewReader(out), cmd
+	case name == "-" || name == "/dev/stdin":
+		s.r = bufio.NewReader(in.stdin)
+	default:
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		s.r, s.c = bufio.NewReader(f), f
+	}
+	in.inputs[name] = s
+	return s, nil
+}
+
+// output is a file or command that print writes to.
+type output struct {
+	w   *bufio.Writer
+	c   io.Closer
+	cmd *exec.Cmd
+	// unbuffered outputs are flushed after each print.
+	unbuffered bool
+}
+
+func (o *output) close() (int, error) {
+	err := o.w.Flush()
+	if o.c != nil {
+		if cerr := o.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if o.cmd != nil {
+		return exitStatus(o.cmd.Wait()), err
+	}
+	return 0, err
+}
+
+// output returns the output of a redirection, which stays open until it
+// is closed.
+func (in *interp) output(redirect tokenKind, name string) (*output, error) {
+	if o, ok := in.outputs[name]; ok {
+		return o, nil
+	}
+	o := &output{}
+	switch {
+	case redirect == tPipe:
+		if err := in.flush(); err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(shell, "-c", name)
+		cmd.Stdout = in.out
+		cmd.Stderr = in.stderr
+		w, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		o.w, o.c, o.cmd = bufio.NewWriter(w), w, cmd
+	case name == "/dev/stdout" || name == "-":
+		o.w = in.stdout
+	case name == "/dev/stderr":
+		o.w, o.unbuffered = bufio.NewWriter(in.stderr), true
+	default:
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if redirect == tAppend {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(name, flags, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		o.w, o.c = bufio.NewWriter(f), f
+	}
+	in.outputs[name] = o
+	return o, nil
+}
+
+// flush flushes stdout and all outputs, before commands write to them.
+func (in *interp) flush() error {
+	for _, o := range in.outputs {
+		if err := o.w.Flush(); err != nil {
+			return err
+		}
+	}
+	return in.stdout.Flush()
+}
+
+// closeStream closes the output or input stream name and returns its exit
+// status, or -1 if it is not open.
+func (in *interp) closeStream(name string) int {
+	status := -1
+	if o, ok := in.outputs[name]; ok {
+		delete(in.outputs, name)
+		var err error
+		if status, err = o.close(); err != nil {
+			status = -1
+		}
+	}
+	if s, ok := in.inputs[name]; ok {
+		delete(in.inputs, name)
+		status = s.close()
+	}
+	return status
+}
+
+func (in *interp) closeAll() error {
+	var err error
+	for name, o := range in.outputs {
+		if _, cerr := o.close(); err == nil {
+			err = cerr
+		}
+		delete(in.outputs, name)
+	}
+	for name, s := range in.inputs {
+		s.close()
+		delete(in.inputs, name)
+	}
+	if in.main != nil {
+		in.main.close()
+		in.main = nil
+	}
+	if ferr := in.stdout.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// system runs a command and returns its exit status.
+func (in *interp) system(command string) int {
+	if err := in.flush(); err != nil {
+		return -1
+	}
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in.childStdin(), in.out, in.stderr
+	return exitStatus(cmd.Run())
+}
@@ -0,0 +1,366 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	tNewline
+	tNumber
+	tString
+	tRegex
+	tName
+	tFuncName // a name directly followed by (
+	tBuiltin
+
+	// Keywords.
+	tBegin
+	tEnd
+	tFunction
+	tIf
+	tElse
+	tWhile
+	tFor
+	tDo
+	tBreak
+	tContinue
+	tNext
+	tExit
+	tReturn
+	tDelete
+	tIn
+	tGetline
+	tPrint
+	tPrintf
+
+	// Punctuation.
+	tLbrace
+	tRbrace
+	tLparen
+	tRparen
+	tLbracket
+	tRbracket
+	tSemicolon
+	tComma
+	tAdd
+	tSub
+	tMul
+	tDiv
+	tMod
+	tPow
+	tNot
+	tGreater
+	tLess
+	tPipe
+	tQuestion
+	tColon
+	tMatch
+	tNoMatch
+	tDollar
+	tAssign
+	tAddAssign
+	tSubAssign
+	tMulAssign
+	tDivAssign
+	tModAssign
+	tPowAssign
+	tEqual
+	tLessEqual
+	tGreaterEqual
+	tNotEqual
+	tIncr
+	tDecr
+	tAnd
+	tOr
+	tAppend
+)
+
+var keywords = map[string]tokenKind{
+	"BEGIN":    tBegin,
+	"END":      tEnd,
+	"function": tFunction,
+	"func":     tFunction,
+	"if":       tIf,
+	"else":     tElse,
+	"while":    tWhile,
+	"for":      tFor,
+	"do":       tDo,
+	"break":    tBreak,
+	"continue": tContinue,
+	"next":     tNext,
+	"exit":     tExit,
+	"return":   tReturn,
+	"delete":   tDelete,
+	"in":       tIn,
+	"getline":  tGetline,
+	"print":    tPrint,
+	"printf":   tPrintf,
+}
+
+var builtins = map[string]bool{
+	"atan2": true, "close": true, "cos": true, "exp": true, "fflush": true,
+	"gsub": true, "index": true, "int": true, "length": true, "log": true,
+	"match": true, "rand": true, "sin": true, "split": true, "sprintf": true,
+	"sqrt": true, "srand": true, "sub": true, "substr": true, "system": true,
+	"tolower": true, "toupper": true,
+}
+
+// Operators, longest first.
+var operators = []struct {
+	s string
+	k tokenKind
+}{
+	{"**=", tPowAssign},
+	{"&&", tAnd}, {"||", tOr}, {">>", tAppend}, {"++", tIncr}, {"--", tDecr},
+	{"+=", tAddAssign}, {"-=", tSubAssign}, {"*=", tMulAssign}, {"/=", tDivAssign},
+	{"%=", tModAssign}, {"^=", tPowAssign}, {"==", tEqual}, {"<=", tLessEqual},
+	{">=", tGreaterEqual}, {"!=", tNotEqual}, {"!~", tNoMatch}, {"**", tPow},
+	{"{", tLbrace}, {"}", tRbrace}, {"(", tLparen}, {")", tRparen}, {"[", tLbracket},
+	{"]", tRbracket}, {";", tSemicolon}, {",", tComma}, {"+", tAdd}, {"-", tSub},
+	{"*", tMul}, {"/", tDiv}, {"%", tMod}, {"^", tPow}, {"!", tNot}, {">", tGreater},
+	{"<", tLess}, {"|", tPipe}, {"?", tQuestion}, {":", tColon}, {"~", tMatch},
+	{"$", tDollar}, {"=", tAssign},
+}
+
+type token struct {
+	kind tokenKind
+	s    string
+	line int
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	last tokenKind
+}
+
+// regexAllowed reports whether a / starts a regex rather than a division,
+// which it does unless it follows an operand.
+func (l *lexer) regexAllowed() bool {
+	switch l.last {
+	case tName, tNumber, tString, tRegex, tRparen, tRbracket, tIncr, tDecr, tBuiltin, tDollar:
+		return false
+	}
+	return true
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c)
+}
+
+func (l *lexer) next() (token, error) {
+	t, err := l.scan()
+	if err == nil {
+		l.last = t.kind
+	}
+	return t, err
+}
+
+func (l *lexer) scan() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+			continue
+		case c == '\\' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n':
+			l.pos += 2
+			l.line++
+			continue
+		case c == '\\' && strings.HasPrefix(l.src[l.pos+1:], "\r\n"):
+			l.pos += 3
+			l.line++
+			continue
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tEOF, line: l.line}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '\n':
+		l.pos++
+		l.line++
+		return token{kind: tNewline, line: l.line - 1}, nil
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+			p := l.pos + 1
+			if p < len(l.src) && (l.src[p] == '+' || l.src[p] == '-') {
+				p++
+			}
+			if p < len(l.src) && isDigit(l.src[p]) {
+				for p < len(l.src) && isDigit(l.src[p]) {
+					p++
+				}
+				l.pos = p
+			}
+		}
+		return token{kind: tNumber, s: l.src[start:l.pos], line: l.line}, nil
+	case isNameChar(c):
+		for l.pos < len(l.src) && isNameChar(l.src[l.pos]) {
+			l.pos++
+		}
+		name := l.src[start:l.pos]
+		if k, ok := keywords[name]; ok {
+			return token{kind: k, s: name, line: l.line}, nil
+		}
+		if builtins[name] {
+			return token{kind: tBuiltin, s: name, line: l.line}, nil
+		}
+		if l.pos < len(l.src) && l.src[l.pos] == '(' {
+			return token{kind: tFuncName, s: name, line: l.line}, nil
+		}
+		return token{kind: tName, s: name, line: l.line}, nil
+	case c == '"':
+		s, err := l.string()
+		return token{kind: tString, s: s, line: l.line}, err
+	case c == '/' && l.regexAllowed():
+		s, err := l.regex()
+		return token{kind: tRegex, s: s, line: l.line}, err
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op.s) {
+			l.pos += len(op.s)
+			return token{kind: op.k, s: op.s, line: l.line}, nil
+		}
+	}
+	return token{}, l.errorf("unexpected character %q", c)
+}
+
+// string scans a string literal and interprets its escapes.
+func (l *lexer) string() (string, error) {
+	l.pos++
+	start := l.pos
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return "", l.errorf("unterminated string")
+		}
+		switch l.src[l.pos] {
+		case '"':
+			l.pos++
+			return unescape(l.src[start : l.pos-1]), nil
+		case '\\':
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n' {
+				l.line++
+			}
+			l.pos++
+		}
+		l.pos++
+	}
+}
+
+// unescape interprets the escapes of string literals and -v assignments.
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		c = s[i]
+		switch c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\', '"', '/':
+			b.WriteByte(c)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\n':
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := int(c - '0')
+			for j := 0; j < 2 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; j++ {
+				i++
+				n = n*8 + int(s[i]-'0')
+			}
+			b.WriteByte(byte(n))
+		default:
+			// Keep unknown escapes, so strings used as regexes keep
+			// them.
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// regex scans a regex literal. Only \/ is unescaped.
+func (l *lexer) regex() (string, error) {
+	var b strings.Builder
+	l.pos++
+	inClass := false
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return "", l.errorf("unterminated regex")
+		}
+		c := l.src[l.pos]
+		l.pos++
+		switch {
+		case c == '/' && !inClass:
+			return b.String(), nil
+		case c == '[' && !inClass:
+			inClass = true
+			// A ] right after [ or [^ is part of the class.
+			if strings.HasPrefix(l.src[l.pos:], "^]") {
+				b.WriteString("[^]")
+				l.pos += 2
+				continue
+			} else if strings.HasPrefix(l.src[l.pos:], "]") {
+				b.WriteString("[]")
+				l.pos++
+				continue
+			}
+		case c == ']' && inClass:
+			inClass = false
+		case c == '\\' && l.pos < len(l.src):
+			if l.src[l.pos] == '/' {
+				b.WriteByte('/')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(l.src[l.pos])
+			}
+			l.pos++
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
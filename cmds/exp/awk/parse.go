@@ -0,0 +1,1062 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type expr interface{}
+
+type (
+	numExpr   struct{ n float64 }
+	strExpr   struct{ s string }
+	regexExpr struct{ re string }
+	// varExpr is a variable, which is a parameter of the enclosing
+	// function if local is not -1.
+	varExpr struct {
+		name  string
+		local int
+	}
+	indexExpr struct {
+		array *varExpr
+		index []expr
+	}
+	fieldExpr  struct{ index expr }
+	assignExpr struct {
+		left  expr
+		op    tokenKind
+		right expr
+	}
+	condExpr   struct{ cond, yes, no expr }
+	binaryExpr struct {
+		op   tokenKind
+		l, r expr
+	}
+	concatExpr struct{ l, r expr }
+	unaryExpr  struct {
+		op tokenKind
+		x  expr
+	}
+	incrExpr struct {
+		x   expr
+		op  tokenKind
+		pre bool
+	}
+	matchExpr struct {
+		x, re expr
+		not   bool
+	}
+	inExpr struct {
+		index []expr
+		array *varExpr
+	}
+	callExpr struct {
+		name string
+		args []expr
+	}
+	userCallExpr struct {
+		name string
+		fn   *function
+		args []expr
+		line int
+	}
+	// getlineExpr reads from the main input, a file (tLess) or a
+	// command (tPipe) into target, or $0 if it is nil.
+	getlineExpr struct {
+		from   tokenKind
+		src    expr
+		target expr
+	}
+)
+
+type stmt interface{}
+
+type (
+	printStmt struct {
+		args   []expr
+		printf bool
+		// redirect is tGreater, tAppend or tPipe to write to dest.
+		redirect tokenKind
+		dest     expr
+	}
+	exprStmt  struct{ x expr }
+	blockStmt []stmt
+	ifStmt    struct {
+		cond      expr
+		then, els stmt
+	}
+	whileStmt struct {
+		cond expr
+		body stmt
+	}
+	doStmt struct {
+		body stmt
+		cond expr
+	}
+	forStmt struct {
+		init stmt
+		cond expr
+		post stmt
+		body stmt
+	}
+	forInStmt struct {
+		v     *varExpr
+		array *varExpr
+		body  stmt
+	}
+	nextStmt     struct{}
+	breakStmt    struct{}
+	continueStmt struct{}
+	exitStmt     struct{ x expr }
+	returnStmt   struct{ x expr }
+	deleteStmt   struct {
+		array *varExpr
+		// index is nil to delete the whole array.
+		index []expr
+	}
+)
+
+// item is a pattern and its action. A nil pattern matches every record
+// and a nil body prints it.
+type item struct {
+	pattern expr
+	end     expr
+	isRange bool
+	body    blockStmt
+}
+
+type function struct {
+	name   string
+	params []string
+	// arrays tells which parameters are used as arrays, so that
+	// uninitialized variables passed for them become arrays of the
+	// caller.
+	arrays []bool
+	body   blockStmt
+}
+
+type program struct {
+	begin []blockStmt
+	items []item
+	end   []blockStmt
+	funcs map[string]*function
+}
+
+type parser struct {
+	toks   []token
+	pos    int
+	tok    token
+	prog   *program
+	fn     *function
+	locals map[string]int
+	calls  []*userCallExpr
+	// noGreater is set in print arguments, where > redirects.
+	noGreater bool
+	loops     int
+}
+
+// parse parses an AWK program.
+func parse(src string) (*program, error) {
+	l := &lexer{src: src, line: 1}
+	p := &parser{prog: &program{funcs: map[string]*function{}}}
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		p.toks = append(p.toks, t)
+		if t.kind == tEOF {
+			break
+		}
+	}
+	p.tok = p.toks[0]
+	if err := p.program(); err != nil {
+		return nil, err
+	}
+	for _, c := range p.calls {
+		f, ok := p.prog.funcs[c.name]
+		if !ok {
+			return nil, fmt.Errorf("line %d: function %q is not defined", c.line, c.name)
+		}
+		if len(c.args) > len(f.params) {
+			return nil, fmt.Errorf("line %d: function %q called with %d arguments, takes %d", c.line, c.name, len(c.args), len(f.params))
+		}
+		c.fn = f
+	}
+	return p.prog, nil
+}
+
+func (p *parser) advance() {
+	if p.pos < len(p.toks)-1 {
+		p.pos++
+	}
+	p.tok = p.toks[p.pos]
+}
+
+func (p *parser) peek() tokenKind {
+	if p.pos < len(p.toks)-1 {
+		return p.toks[p.pos+1].kind
+	}
+	return tEOF
+}
+
+func (p *parser) reset(pos int) {
+	p.pos = pos
+	p.tok = p.toks[pos]
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) unexpected() error {
+	switch p.tok.kind {
+	case tEOF:
+		return p.errorf("unexpected end of program")
+	case tNewline:
+		return p.errorf("unexpected newline")
+	}
+	return p.errorf("unexpected %q", p.tok.s)
+}
+
+func (p *parser) expect(k tokenKind) error {
+	if p.tok.kind != k {
+		return p.unexpected()
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) optNewlines() {
+	for p.tok.kind == tNewline {
+		p.advance()
+	}
+}
+
+func (p *parser) program() error {
+	for {
+		for p.tok.kind == tNewline || p.tok.kind == tSemicolon {
+			p.advance()
+		}
+		switch p.tok.kind {
+		case tEOF:
+			return nil
+		case tBegin, tEnd:
+			k := p.tok.kind
+			p.advance()
+			b, err := p.block()
+			if err != nil {
+				return err
+			}
+			if k == tBegin {
+				p.prog.begin = append(p.prog.begin, b)
+			} else {
+				p.prog.end = append(p.prog.end, b)
+			}
+		case tFunction:
+			if err := p.function(); err != nil {
+				return err
+			}
+		default:
+			var it item
+			if p.tok.kind != tLbrace {
+				x, err := p.expr()
+				if err != nil {
+					return err
+				}
+				it.pattern = x
+				if p.tok.kind == tComma {
+					p.advance()
+					p.optNewlines()
+					if it.end, err = p.expr(); err != nil {
+						return err
+					}
+					it.isRange = true
+				}
+			}
+			if p.tok.kind == tLbrace {
+				b, err := p.block()
+				if err != nil {
+					return err
+				}
+				it.body = b
+			}
+			p.prog.items = append(p.prog.items, it)
+		}
+	}
+}
+
+func (p *parser) function() error {
+	p.advance()
+	if p.tok.kind != tName && p.tok.kind != tFuncName {
+		return p.unexpected()
+	}
+	f := &function{name: p.tok.s}
+	if _, ok := p.prog.funcs[f.name]; ok {
+		return p.errorf("function %q is defined twice", f.name)
+	}
+	p.advance()
+	if err := p.expect(tLparen); err != nil {
+		return err
+	}
+	p.locals = map[string]int{}
+	for p.tok.kind != tRparen {
+		if p.tok.kind != tName {
+			return p.unexpected()
+		}
+		if _, ok := p.locals[p.tok.s]; ok {
+			return p.errorf("parameter %q is given twice", p.tok.s)
+		}
+		p.locals[p.tok.s] = len(f.params)
+		f.params = append(f.params, p.tok.s)
+		p.advance()
+		if p.tok.kind == tComma {
+			p.advance()
+			p.optNewlines()
+		} else if p.tok.kind != tRparen {
+			return p.unexpected()
+		}
+	}
+	p.advance()
+	f.arrays = make([]bool, len(f.params))
+	p.fn = f
+	p.prog.funcs[f.name] = f
+	p.optNewlines()
+	b, err := p.block()
+	if err != nil {
+		return err
+	}
+	f.body = b
+	p.fn, p.locals = nil, nil
+	return nil
+}
+
+func (p *parser) block() (blockStmt, error) {
+	if err := p.expect(tLbrace); err != nil {
+		return nil, err
+	}
+	b := blockStmt{}
+	for {
+		for p.tok.kind == tNewline || p.tok.kind == tSemicolon {
+			p.advance()
+		}
+		if p.tok.kind == tRbrace {
+			p.advance()
+			return b, nil
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, s)
+	}
+}
+
+// endSimple ends a simple statement, which ends with a ; or newline or
+// before a }.
+func (p *parser) endSimple() error {
+	switch p.tok.kind {
+	case tSemicolon, tNewline:
+		p.advance()
+	case tRbrace, tEOF:
+	default:
+		return p.unexpected()
+	}
+	return nil
+}
+
+// body parses the body of a compound statement.
+func (p *parser) body() (stmt, error) {
+	p.optNewlines()
+	if p.tok.kind == tSemicolon {
+		p.advance()
+		return blockStmt{}, nil
+	}
+	return p.stmt()
+}
+
+func (p *parser) loopBody() (stmt, error) {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.body()
+}
+
+func (p *parser) condition() (expr, error) {
+	if err := p.expect(tLparen); err != nil {
+		return nil, err
+	}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return x, p.expect(tRparen)
+}
+
+func (p *parser) stmt() (stmt, error) {
+	switch p.tok.kind {
+	case tLbrace:
+		return p.block()
+	case tIf:
+		p.advance()
+		cond, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		then, err := p.body()
+		if err != nil {
+			return nil, err
+		}
+		s := &ifStmt{cond: cond, then: then}
+		pos := p.pos
+		for p.tok.kind == tNewline || p.tok.kind == tSemicolon {
+			p.advance()
+		}
+		if p.tok.kind != tElse {
+			p.reset(pos)
+			return s, nil
+		}
+		p.advance()
+		s.els, err = p.body()
+		return s, err
+	case tWhile:
+		p.advance()
+		cond, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.loopBody()
+		return &whileStmt{cond: cond, body: body}, err
+	case tDo:
+		p.advance()
+		body, err := p.loopBody()
+		if err != nil {
+			return nil, err
+		}
+		for p.tok.kind == tNewline || p.tok.kind == tSemicolon {
+			p.advance()
+		}
+		if err := p.expect(tWhile); err != nil {
+			return nil, err
+		}
+		cond, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		return &doStmt{body: body, cond: cond}, p.endSimple()
+	case tFor:
+		return p.forStmt()
+	case tSemicolon:
+		p.advance()
+		return blockStmt{}, nil
+	}
+	s, err := p.simple()
+	if err != nil {
+		return nil, err
+	}
+	return s, p.endSimple()
+}
+
+func (p *parser) forStmt() (stmt, error) {
+	p.advance()
+	if err := p.expect(tLparen); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tName && p.peek() == tIn && p.pos+3 < len(p.toks) &&
+		p.toks[p.pos+2].kind == tName && p.toks[p.pos+3].kind == tRparen {
+		v := p.variable(p.tok.s)
+		p.advance()
+		p.advance()
+		array := p.array(p.tok.s)
+		p.advance()
+		p.advance()
+		body, err := p.loopBody()
+		return &forInStmt{v: v, array: array, body: body}, err
+	}
+	s := &forStmt{}
+	var err error
+	if p.tok.kind != tSemicolon {
+		if s.init, err = p.simple(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(tSemicolon); err != nil {
+		return nil, err
+	}
+	p.optNewlines()
+	if p.tok.kind != tSemicolon {
+		if s.cond, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(tSemicolon); err != nil {
+		return nil, err
+	}
+	p.optNewlines()
+	if p.tok.kind != tRparen {
+		if s.post, err = p.simple(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(tRparen); err != nil {
+		return nil, err
+	}
+	s.body, err = p.loopBody()
+	return s, err
+}
+
+// simple parses a simple statement.
+func (p *parser) simple() (stmt, error) {
+	switch p.tok.kind {
+	case tPrint, tPrintf:
+		return p.print()
+	case tNext:
+		p.advance()
+		return &nextStmt{}, nil
+	case tBreak, tContinue:
+		if p.loops == 0 {
+			return nil, p.errorf("%s outside of a loop", p.tok.s)
+		}
+		k := p.tok.kind
+		p.advance()
+		if k == tBreak {
+			return &breakStmt{}, nil
+		}
+		return &continueStmt{}, nil
+	case tExit, tReturn:
+		k := p.tok.kind
+		if k == tReturn && p.fn == nil {
+			return nil, p.errorf("return outside of a function")
+		}
+		p.advance()
+		var x expr
+		switch p.tok.kind {
+		case tSemicolon, tNewline, tRbrace, tEOF:
+		default:
+			var err error
+			if x, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if k == tExit {
+			return &exitStmt{x: x}, nil
+		}
+		return &returnStmt{x: x}, nil
+	case tDelete:
+		p.advance()
+		if p.tok.kind != tName {
+			return nil, p.unexpected()
+		}
+		s := &deleteStmt{array: p.array(p.tok.s)}
+		p.advance()
+		if p.tok.kind == tLbracket {
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			s.index = index
+		}
+		return s, nil
+	}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &exprStmt{x: x}, nil
+}
+
+func (p *parser) print() (stmt, error) {
+	s := &printStmt{printf: p.tok.kind == tPrintf}
+	p.advance()
+	p.noGreater = true
+	defer func() { p.noGreater = false }()
+	var err error
+	switch p.tok.kind {
+	case tSemicolon, tNewline, tRbrace, tEOF, tGreater, tAppend, tPipe:
+	case tLparen:
+		// print (a, b) > "file" has its arguments in parentheses, but
+		// print (a)(b) does not.
+		pos := p.pos
+		p.advance()
+		if s.args, err = p.exprList(tRparen); err == nil {
+			switch p.tok.kind {
+			case tSemicolon, tNewline, tRbrace, tEOF, tGreater, tAppend, tPipe:
+			default:
+				err = p.unexpected()
+			}
+		}
+		if err != nil {
+			p.reset(pos)
+			if s.args, err = p.exprList(tEOF); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if s.args, err = p.exprList(tEOF); err != nil {
+			return nil, err
+		}
+	}
+	if s.printf && len(s.args) == 0 {
+		return nil, p.errorf("printf without a format")
+	}
+	switch p.tok.kind {
+	case tGreater, tAppend, tPipe:
+		s.redirect = p.tok.kind
+		p.advance()
+		if s.dest, err = p.concat(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// exprList parses expressions separated by commas, up to and including
+// end, or up to the end of the list if end is tEOF.
+func (p *parser) exprList(end tokenKind) ([]expr, error) {
+	list := []expr{}
+	if end != tEOF && p.tok.kind == end {
+		p.advance()
+		return list, nil
+	}
+	for {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, x)
+		if p.tok.kind != tComma {
+			break
+		}
+		p.advance()
+		p.optNewlines()
+	}
+	if end != tEOF {
+		if err := p.expect(end); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// inner parses a list in parentheses or brackets, where > compares.
+func (p *parser) inner(end tokenKind) ([]expr, error) {
+	saved := p.noGreater
+	p.noGreater = false
+	defer func() { p.noGreater = saved }()
+	p.advance()
+	p.optNewlines()
+	return p.exprList(end)
+}
+
+func (p *parser) index() ([]expr, error) {
+	index, err := p.inner(tRbracket)
+	if err == nil && len(index) == 0 {
+		err = p.errorf("empty index")
+	}
+	return index, err
+}
+
+func (p *parser) variable(name string) *varExpr {
+	if i, ok := p.locals[name]; ok {
+		return &varExpr{name: name, local: i}
+	}
+	return &varExpr{name: name, local: -1}
+}
+
+// array returns the variable of an array, marking parameters used as
+// arrays.
+func (p *parser) array(name string) *varExpr {
+	v := p.variable(name)
+	if v.local >= 0 {
+		p.fn.arrays[v.local] = true
+	}
+	return v
+}
+
+func isLvalue(x expr) bool {
+	switch x.(type) {
+	case *varExpr, *indexExpr, *fieldExpr:
+		return true
+	}
+	return false
+}
+
+func (p *parser) expr() (expr, error) {
+	left, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	switch p.tok.kind {
+	case tAssign, tAddAssign, tSubAssign, tMulAssign, tDivAssign, tModAssign, tPowAssign:
+		if !isLvalue(left) {
+			return nil, p.errorf("assignment to a non-variable")
+		}
+		op := p.tok.kind
+		p.advance()
+		p.optNewlines()
+		right, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &assignExpr{left: left, op: op, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) ternary() (expr, error) {
+	cond, err := p.or()
+	if err != nil || p.tok.kind != tQuestion {
+		return cond, err
+	}
+	p.advance()
+	p.optNewlines()
+	yes, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.optNewlines()
+	if err := p.expect(tColon); err != nil {
+		return nil, err
+	}
+	p.optNewlines()
+	no, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &condExpr{cond: cond, yes: yes, no: no}, nil
+}
+
+func (p *parser) or() (expr, error) {
+	l, err := p.and()
+	for err == nil && p.tok.kind == tOr {
+		p.advance()
+		p.optNewlines()
+		var r expr
+		if r, err = p.and(); err == nil {
+			l = &binaryExpr{op: tOr, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) and() (expr, error) {
+	l, err := p.in()
+	for err == nil && p.tok.kind == tAnd {
+		p.advance()
+		p.optNewlines()
+		var r expr
+		if r, err = p.in(); err == nil {
+			l = &binaryExpr{op: tAnd, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) in() (expr, error) {
+	l, err := p.match()
+	for err == nil && p.tok.kind == tIn {
+		p.advance()
+		if p.tok.kind != tName {
+			return nil, p.unexpected()
+		}
+		l = &inExpr{index: []expr{l}, array: p.array(p.tok.s)}
+		p.advance()
+	}
+	return l, err
+}
+
+func (p *parser) match() (expr, error) {
+	l, err := p.compare()
+	for err == nil && (p.tok.kind == tMatch || p.tok.kind == tNoMatch) {
+		not := p.tok.kind == tNoMatch
+		p.advance()
+		var r expr
+		if r, err = p.compare(); err == nil {
+			l = &matchExpr{x: l, re: r, not: not}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) compare() (expr, error) {
+	l, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.tok.kind {
+		case tGreater:
+			if p.noGreater {
+				return l, nil
+			}
+			fallthrough
+		case tLess, tLessEqual, tGreaterEqual, tEqual, tNotEqual:
+			op := p.tok.kind
+			p.advance()
+			r, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryExpr{op: op, l: l, r: r}, nil
+		case tPipe:
+			if p.peek() != tGetline {
+				return l, nil
+			}
+			p.advance()
+			p.advance()
+			g := &getlineExpr{from: tPipe, src: l}
+			if g.target, err = p.optLvalue(); err != nil {
+				return nil, err
+			}
+			l = g
+		default:
+			return l, nil
+		}
+	}
+}
+
+// startsConcat reports whether the token starts the right operand of a
+// concatenation.
+func (p *parser) startsConcat() bool {
+	switch p.tok.kind {
+	case tNumber, tString, tRegex, tName, tFuncName, tBuiltin, tDollar, tLparen, tSub, tAdd, tIncr, tDecr:
+		return true
+	}
+	return false
+}
+
+func (p *parser) concat() (expr, error) {
+	l, err := p.additive()
+	for err == nil && p.startsConcat() {
+		var r expr
+		if r, err = p.additive(); err == nil {
+			l = &concatExpr{l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) additive() (expr, error) {
+	l, err := p.multiplicative()
+	for err == nil && (p.tok.kind == tAdd || p.tok.kind == tSub) {
+		op := p.tok.kind
+		p.advance()
+		var r expr
+		if r, err = p.multiplicative(); err == nil {
+			l = &binaryExpr{op: op, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	l, err := p.unary()
+	for err == nil && (p.tok.kind == tMul || p.tok.kind == tDiv || p.tok.kind == tMod) {
+		op := p.tok.kind
+		p.advance()
+		var r expr
+		if r, err = p.unary(); err == nil {
+			l = &binaryExpr{op: op, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) unary() (expr, error) {
+	switch p.tok.kind {
+	case tNot, tSub, tAdd:
+		op := p.tok.kind
+		p.advance()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.pow()
+}
+
+func (p *parser) pow() (expr, error) {
+	l, err := p.postfix()
+	if err != nil || p.tok.kind != tPow {
+		return l, err
+	}
+	p.advance()
+	// The exponent may have a sign, and ^ is right associative.
+	r, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: tPow, l: l, r: r}, nil
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if (p.tok.kind == tIncr || p.tok.kind == tDecr) && isLvalue(x) {
+		op := p.tok.kind
+		p.advance()
+		return &incrExpr{x: x, op: op}, nil
+	}
+	return x, nil
+}
+
+// optLvalue parses the optional variable of getline.
+func (p *parser) optLvalue() (expr, error) {
+	switch p.tok.kind {
+	case tName, tDollar:
+		return p.primary()
+	}
+	return nil, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.tok
+	switch t.kind {
+	case tNumber:
+		p.advance()
+		n, err := strconv.ParseFloat(t.s, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %q", t.s)
+		}
+		return &numExpr{n: n}, nil
+	case tString:
+		p.advance()
+		return &strExpr{s: t.s}, nil
+	case tRegex:
+		p.advance()
+		return &regexExpr{re: t.s}, nil
+	case tLparen:
+		list, err := p.inner(tRparen)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(list) > 1 && p.tok.kind == tIn:
+			p.advance()
+			if p.tok.kind != tName {
+				return nil, p.unexpected()
+			}
+			x := &inExpr{index: list, array: p.array(p.tok.s)}
+			p.advance()
+			return x, nil
+		case len(list) != 1:
+			return nil, p.errorf("expression list outside of print or in")
+		}
+		return list[0], nil
+	case tDollar:
+		p.advance()
+		var x expr
+		var err error
+		if p.tok.kind == tIncr || p.tok.kind == tDecr || p.tok.kind == tSub {
+			x, err = p.unary()
+		} else {
+			x, err = p.primary()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &fieldExpr{index: x}, nil
+	case tIncr, tDecr:
+		p.advance()
+		x, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		if !isLvalue(x) {
+			return nil, p.errorf("%s of a non-variable", t.s)
+		}
+		return &incrExpr{x: x, op: t.kind, pre: true}, nil
+	case tName:
+		p.advance()
+		if p.tok.kind == tLbracket {
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			return &indexExpr{array: p.array(t.s), index: index}, nil
+		}
+		return p.variable(t.s), nil
+	case tFuncName:
+		p.advance()
+		if _, ok := p.locals[t.s]; ok {
+			return nil, p.errorf("%q is a parameter, not a function", t.s)
+		}
+		args, err := p.inner(tRparen)
+		if err != nil {
+			return nil, err
+		}
+		c := &userCallExpr{name: t.s, args: args, line: t.line}
+		p.calls = append(p.calls, c)
+		return c, nil
+	case tBuiltin:
+		p.advance()
+		c := &callExpr{name: t.s}
+		if p.tok.kind == tLparen {
+			args, err := p.inner(tRparen)
+			if err != nil {
+				return nil, err
+			}
+			c.args = args
+		} else if t.s != "length" {
+			return nil, p.errorf("%s without arguments", t.s)
+		}
+		if err := p.checkCall(c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case tGetline:
+		p.advance()
+		g := &getlineExpr{}
+		var err error
+		if g.target, err = p.optLvalue(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tLess {
+			p.advance()
+			g.from = tLess
+			if g.src, err = p.primary(); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	}
+	return nil, p.unexpected()
+}
+
+// arity gives the least and most arguments of builtins.
+var arity = map[string][2]int{
+	"atan2": {2, 2}, "close": {1, 1}, "cos": {1, 1}, "exp": {1, 1},
+	"fflush": {0, 1}, "gsub": {2, 3}, "index": {2, 2}, "int": {1, 1},
+	"length": {0, 1}, "log": {1, 1}, "match": {2, 2}, "rand": {0, 0},
+	"sin": {1, 1}, "split": {2, 3}, "sprintf": {1, -1}, "sqrt": {1, 1},
+	"srand": {0, 1}, "sub": {2, 3}, "substr": {2, 3}, "system": {1, 1},
+	"tolower": {1, 1}, "toupper": {1, 1},
+}
+
+func (p *parser) checkCall(c *callExpr) error {
+	a := arity[c.name]
+	if len(c.args) < a[0] || (a[1] >= 0 && len(c.args) > a[1]) {
+		return p.errorf("%s called with %d arguments", c.name, len(c.args))
+	}
+	switch c.name {
+	case "split":
+		v, ok := c.args[1].(*varExpr)
+		if !ok {
+			return p.errorf("split into a non-array")
+		}
+		if v.local >= 0 {
+			p.fn.arrays[v.local] = true
+		}
+	case "sub", "gsub":
+		if len(c.args) == 3 && !isLvalue(c.args[2]) {
+			return p.errorf("%s of a non-variable", c.name)
+		}
+	}
+	return nil
+}
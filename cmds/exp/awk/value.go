@@ -0,0 +1,243 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type valueKind uint8
+
+const (
+	// kNull is the value of uninitialized variables, which is both ""
+	// and 0.
+	kNull valueKind = iota
+	kNum
+	kStr
+	// kStrNum is a string from input which looks like a number, so it
+	// compares as one.
+	kStrNum
+)
+
+type value struct {
+	k valueKind
+	s string
+	n float64
+}
+
+func num(n float64) value { return value{k: kNum, n: n} }
+
+func str(s string) value { return value{k: kStr, s: s} }
+
+func boolean(b bool) value {
+	if b {
+		return num(1)
+	}
+	return num(0)
+}
+
+// input returns the value of a string from input, which is a number too
+// if it looks like one.
+func input(s string) value {
+	if n, ok := looksNumeric(s); ok {
+		return value{k: kStrNum, s: s, n: n}
+	}
+	return str(s)
+}
+
+// numberPrefix returns the length of the number at the start of s, after
+// leading blanks, or 0 if there is none.
+func numberPrefix(s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+		i++
+	}
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+func looksNumeric(s string) (float64, bool) {
+	i := numberPrefix(s)
+	if i == 0 || strings.TrimRight(s[i:], " \t\n") != "" {
+		return 0, false
+	}
+	// Out of range numbers are infinite, which is fine.
+	n, _ := strconv.ParseFloat(strings.TrimLeft(s[:i], " \t\n"), 64)
+	return n, true
+}
+
+func (v value) num() float64 {
+	switch v.k {
+	case kNum, kStrNum:
+		return v.n
+	case kStr:
+		i := numberPrefix(v.s)
+		n, _ := strconv.ParseFloat(strings.TrimLeft(v.s[:i], " \t\n"), 64)
+		return n
+	}
+	return 0
+}
+
+func (v value) bool() bool {
+	switch v.k {
+	case kNum, kStrNum:
+		// Strings from input which look like numbers are true if
+		// they are non-zero.
+		return v.n != 0
+	case kStr:
+		return v.s != ""
+	}
+	return false
+}
+
+// isNum reports whether v compares as a number.
+func (v value) isNum() bool {
+	return v.k != kStr
+}
+
+// formatNum converts n to a string, with format for non-integers.
+func formatNum(n float64, format string) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e16 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	switch {
+	case math.IsNaN(n):
+		return "nan"
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	}
+	return fmt.Sprintf(format, n)
+}
+
+// sprintf formats args like printf of AWK, which follows printf of C.
+func (in *interp) sprintf(format string, args []value) string {
+	var b strings.Builder
+	arg := func() value {
+		if len(args) == 0 {
+			return value{}
+		}
+		v := args[0]
+		args = args[1:]
+		return v
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		start := i
+		spec := []byte{'%'}
+		i++
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			spec = append(spec, format[i])
+			i++
+		}
+		for i < len(format) && (isDigit(format[i]) || format[i] == '*' || format[i] == '.') {
+			if format[i] == '*' {
+				spec = strconv.AppendInt(spec, int64(arg().num()), 10)
+			} else {
+				spec = append(spec, format[i])
+			}
+			i++
+		}
+		// Length modifiers of C have no meaning here.
+		for i < len(format) && strings.IndexByte("hlLqjzt", format[i]) >= 0 {
+			i++
+		}
+		if i >= len(format) {
+			b.WriteString(format[start:])
+			break
+		}
+		verb := format[i]
+		switch verb {
+		case '%':
+			b.WriteByte('%')
+		case 'd', 'i':
+			spec = append(spec, 'd')
+			fmt.Fprintf(&b, string(spec), toInt(arg().num()))
+		case 'o', 'x', 'X', 'u':
+			if verb == 'u' {
+				verb = 'd'
+			}
+			spec = append(spec, verb)
+			n := arg().num()
+			if n < 0 {
+				fmt.Fprintf(&b, string(spec), uint64(toInt(n)))
+			} else {
+				fmt.Fprintf(&b, string(spec), toInt(n))
+			}
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			spec = append(spec, verb)
+			fmt.Fprintf(&b, string(spec), arg().num())
+		case 'c':
+			v := arg()
+			var s string
+			if v.k == kNum {
+				s = string(rune(toInt(v.n)))
+			} else if v.s != "" {
+				s = v.s[:1]
+			}
+			// Precision has no meaning for characters.
+			if j := strings.IndexByte(string(spec), '.'); j >= 0 {
+				spec = spec[:j]
+			}
+			spec = append(spec, 's')
+			fmt.Fprintf(&b, string(spec), s)
+		case 's':
+			spec = append(spec, 's')
+			fmt.Fprintf(&b, string(spec), in.toStr(arg()))
+		default:
+			b.WriteString(format[start : i+1])
+		}
+	}
+	return b.String()
+}
+
+func toInt(n float64) int64 {
+	switch {
+	case math.IsNaN(n):
+		return 0
+	case n >= math.MaxInt64:
+		return math.MaxInt64
+	case n <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(n)
+}
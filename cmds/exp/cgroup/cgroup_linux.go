@@ -0,0 +1,291 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cgroup manages cgroup v2 control groups.
+//
+// Synopsis:
+//
+//	cgroup [-root DIR] create NAME [LIMITS]
+//	cgroup [-root DIR] set NAME LIMITS
+//	cgroup [-root DIR] delete [-r] NAME
+//	cgroup [-root DIR] move NAME PID...
+//	cgroup [-root DIR] exec NAME COMMAND [ARG...]
+//	cgroup [-root DIR] show [-json] [NAME]
+//
+// Description:
+//
+//	create makes a cgroup, with its parents, and set changes its limits.
+//	delete removes an empty cgroup, and with -r the cgroups below it.
+//	move moves processes into a cgroup, and exec runs a command in one.
+//	show lists the processes and usage of a cgroup and those below it,
+//	or of all cgroups.
+//
+//	NAME is a path in the hierarchy, such as services/sshd.
+//
+// Options:
+//
+//	-root:        where the cgroup v2 hierarchy is mounted
+//	-cpu:         how many CPUs' worth of time may be used, as in 0.5, or max
+//	-cpu-weight:  the share of CPU time against siblings, 1 to 10000
+//	-memory:      the most memory, as a size such as 256M, or max
+//	-memory-high: where memory use is throttled
+//	-swap:        the most swap
+//	-pids:        the most processes and threads, or max
+//	-io:          an I/O limit, as "DEVICE rbps=SIZE wbps=SIZE riops=N
+//	              wiops=N"; may be repeated
+//
+// Example:
+//
+//	cgroup create services/agent -memory 256M -cpu 0.5 -pids 64
+//	cgroup exec services/agent /bin/agent
+//	cgroup show services
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+)
+
+var errUsage = errors.New(`usage: cgroup [-root DIR] create NAME [LIMITS]
+       cgroup [-root DIR] set NAME LIMITS
+       cgroup [-root DIR] delete [-r] NAME
+       cgroup [-root DIR] move NAME PID...
+       cgroup [-root DIR] exec NAME COMMAND [ARG...]
+       cgroup [-root DIR] show [-json] [NAME]`)
+
+// ioFlag collects -io flags.
+type ioFlag []string
+
+func (f *ioFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *ioFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+type cmd struct {
+	stdout io.Writer
+	root   string
+	verb   string
+	name   string
+	args   []string
+
+	limits    cgroup.Limits
+	recursive bool
+	json      bool
+
+	getpid func() int
+	exec   func(argv0 string, argv []string, envv []string) error
+}
+
+func command(stdout io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, getpid: os.Getpid, exec: syscall.Exec}
+	f := flag.NewFlagSet("cgroup", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.StringVar(&c.root, "root", cgroup.DefaultRoot, "where the cgroup v2 hierarchy is mounted")
+	if err := f.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if f.NArg() == 0 {
+		return nil, errUsage
+	}
+	c.verb, args = f.Arg(0), f.Args()[1:]
+
+	f = flag.NewFlagSet("cgroup "+c.verb, flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	var ioLimits ioFlag
+	switch c.verb {
+	case "create", "set":
+		f.StringVar(&c.limits.CPU, "cpu", "", "how many CPUs' worth of time may be used")
+		f.Uint64Var(&c.limits.CPUWeight, "cpu-weight", 0, "the share of CPU time against siblings")
+		f.StringVar(&c.limits.Memory, "memory", "", "the most memory")
+		f.StringVar(&c.limits.MemoryHigh, "memory-high", "", "where memory use is throttled")
+		f.StringVar(&c.limits.Swap, "swap", "", "the most swap")
+		f.StringVar(&c.limits.Pids, "pids", "", "the most processes and threads")
+		f.Var(&ioLimits, "io", "an I/O limit")
+	case "delete":
+		f.BoolVar(&c.recursive, "r", false, "delete the cgroups below as well")
+	case "show":
+		f.BoolVar(&c.json, "json", false, "print JSON")
+	case "move", "exec":
+	default:
+		return nil, errUsage
+	}
+	// Flags may come before or after the name, but for exec, those after
+	// it belong to the command.
+	if err := f.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	c.args = f.Args()
+	if len(c.args) > 0 {
+		c.name, c.args = c.args[0], c.args[1:]
+		if c.verb != "exec" {
+			if err := f.Parse(c.args); err != nil {
+				return nil, fmt.Errorf("%w: %v", errUsage, err)
+			}
+			c.args = f.Args()
+		}
+	}
+	c.limits.IO = ioLimits
+
+	switch {
+	case c.verb != "show" && c.name == "":
+		return nil, errUsage
+	case (c.verb == "move" || c.verb == "exec") && len(c.args) == 0:
+		return nil, errUsage
+	case c.verb != "move" && c.verb != "exec" && len(c.args) > 0:
+		return nil, errUsage
+	}
+	if err := c.limits.Check(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cmd) run() error {
+	switch c.verb {
+	case "create":
+		g, err := cgroup.Create(c.root, c.name)
+		if err != nil {
+			return err
+		}
+		return g.SetLimits(&c.limits)
+	case "set":
+		g, err := cgroup.Open(c.root, c.name)
+		if err != nil {
+			return err
+		}
+		return g.SetLimits(&c.limits)
+	case "delete":
+		g, err := cgroup.Open(c.root, c.name)
+		if err != nil {
+			return err
+		}
+		return g.Delete(c.recursive)
+	case "move":
+		g, err := cgroup.Open(c.root, c.name)
+		if err != nil {
+			return err
+		}
+		for _, a := range c.args {
+			pid, err := strconv.Atoi(a)
+			if err != nil || pid <= 0 {
+				return fmt.Errorf("%w: bad PID %q", errUsage, a)
+			}
+			if err := g.AddProc(pid); err != nil {
+				return fmt.Errorf("moving %d to %s: %w", pid, g, err)
+			}
+		}
+		return nil
+	case "exec":
+		g, err := cgroup.Open(c.root, c.name)
+		if err != nil {
+			return err
+		}
+		if err := g.AddProc(c.getpid()); err != nil {
+			return err
+		}
+		path, err := exec.LookPath(c.args[0])
+		if err != nil {
+			return err
+		}
+		return c.exec(path, c.args, os.Environ())
+	}
+	return c.show()
+}
+
+// entry is a cgroup as shown.
+type entry struct {
+	Name  string        `json:"name"`
+	Procs []int         `json:"procs"`
+	Stats *cgroup.Stats `json:"stats"`
+}
+
+func (c *cmd) show() error {
+	g, err := cgroup.Open(c.root, c.name)
+	if err != nil {
+		return err
+	}
+	var entries []entry
+	if err := g.Walk(func(g *cgroup.Cgroup) error {
+		procs, err := g.Procs()
+		if err != nil {
+			return err
+		}
+		st, err := g.Stats()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{Name: g.String(), Procs: procs, Stats: st})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "PROCS\tCPU\tMEMORY\tMEM.MAX\tPIDS\tPIDS.MAX\tIO.READ\tIO.WRITE\t CGROUP")
+	for _, e := range entries {
+		var read, written uint64
+		for _, s := range e.Stats.IO {
+			read += s.RBytes
+			written += s.WBytes
+		}
+		fmt.Fprintf(w, "%d\t%.2fs\t%s\t%s\t%d\t%s\t%s\t%s\t %s\n",
+			len(e.Procs), e.Stats.CPUUsage.Seconds(), size(e.Stats.Memory), limit(e.Stats.MemoryMax, true),
+			e.Stats.Pids, limit(e.Stats.PidsMax, false), size(read), size(written), e.Name)
+	}
+	return w.Flush()
+}
+
+// size formats a number of bytes the way the limits are given.
+func size(n uint64) string {
+	const units = "KMGT"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10)
+	}
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", v, units[i])
+}
+
+// limit formats the value of a limit, which is "-" without the controller.
+func limit(s string, bytes bool) string {
+	if s == "" {
+		return "-"
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil && bytes {
+		return size(n)
+	}
+	return s
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+)
+
+// hierarchy fakes the interface files of the cgroups of a hierarchy.
+func hierarchy(t *testing.T, cgroups map[string]map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, files := range cgroups {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for f, v := range files {
+			if err := os.WriteFile(filepath.Join(dir, f), []byte(v), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want cmd
+		err  error
+	}{
+		{args: []string{"show"}, want: cmd{verb: "show"}},
+		{args: []string{"show", "-json", "a"}, want: cmd{verb: "show", name: "a", json: true}},
+		{args: []string{"create", "a/b", "-memory", "1G", "-io", "8:0 rbps=1M"}, want: cmd{verb: "create", name: "a/b", limits: cgroup.Limits{Memory: "1G", IO: []string{"8:0 rbps=1M"}}}},
+		{args: []string{"set", "-pids", "8", "a"}, want: cmd{verb: "set", name: "a", limits: cgroup.Limits{Pids: "8"}}},
+		{args: []string{"delete", "a", "-r"}, want: cmd{verb: "delete", name: "a", recursive: true}},
+		{args: []string{"move", "a", "1", "2"}, want: cmd{verb: "move", name: "a", args: []string{"1", "2"}}},
+		{args: []string{"exec", "a", "ls", "-l"}, want: cmd{verb: "exec", name: "a", args: []string{"ls", "-l"}}},
+		{args: []string{}, err: errUsage},
+		{args: []string{"frob"}, err: errUsage},
+		{args: []string{"create"}, err: errUsage},
+		{args: []string{"delete", "a", "b"}, err: errUsage},
+		{args: []string{"move", "a"}, err: errUsage},
+		{args: []string{"set", "a", "-bogus"}, err: errUsage},
+		{args: []string{"set", "a", "-memory", "lots"}, err: cgroup.ErrLimit},
+	} {
+		c, err := command(nil, tt.args)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("command(%q) = %v, want %v", tt.args, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got := cmd{verb: c.verb, name: c.name, args: c.args, limits: c.limits, recursive: c.recursive, json: c.json}
+		if len(got.args) == 0 {
+			got.args = nil
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("command(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func run(t *testing.T, root string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	c, err := command(&out, append([]string{"-root", root}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.getpid = func() int { return 77 }
+	c.exec = func(argv0 string, argv []string, envv []string) error {
+		out.WriteString(strings.Join(argv, " "))
+		return nil
+	}
+	err = c.run()
+	return out.String(), err
+}
+
+func TestRun(t *testing.T) {
+	root := hierarchy(t, map[string]map[string]string{
+		"": {
+			"cgroup.procs":           "1\n",
+			"cgroup.subtree_control": "",
+			"cpu.stat":               "usage_usec 2500000\n",
+		},
+		"svc": {
+			"cgroup.procs":           "10\n11\n",
+			"cgroup.subtree_control": "",
+			"memory.current":         "1048576\n",
+			"memory.max":             "268435456\n",
+			"memory.high":            "",
+			"pids.current":           "2\n",
+			"pids.max":               "max\n",
+			"io.stat":                "8:0 rbytes=2048 wbytes=0 rios=2 wios=0\n",
+		},
+	})
+
+	if _, err := run(t, root, "set", "svc", "-memory", "512M", "-memory-high", "256M"); err != nil {
+		t.Fatalf("set = %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "svc/memory.max")); string(b) != "536870912" {
+		t.Errorf("memory.max = %q, want 536870912", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "cgroup.subtree_control")); string(b) != "+memory" {
+		t.Errorf("root subtree_control = %q, want +memory", b)
+	}
+	os.WriteFile(filepath.Join(root, "svc/memory.max"), []byte("268435456\n"), 0o644)
+
+	if _, err := run(t, root, "set", "nope", "-pids", "1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("set on a missing cgroup = %v, want %v", err, os.ErrNotExist)
+	}
+
+	if _, err := run(t, root, "move", "svc", "123"); err != nil {
+		t.Errorf("move = %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "svc/cgroup.procs")); string(b) != "123" {
+		t.Errorf("cgroup.procs = %q, want 123", b)
+	}
+	os.WriteFile(filepath.Join(root, "svc/cgroup.procs"), []byte("10\n11\n"), 0o644)
+	if _, err := run(t, root, "move", "svc", "x"); !errors.Is(err, errUsage) {
+		t.Errorf("move of a bad PID = %v, want %v", err, errUsage)
+	}
+
+	if out, err := run(t, root, "exec", "svc", "true", "-x"); err != nil || !strings.HasSuffix(out, "true -x") {
+		t.Errorf("exec = %q, %v", out, err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(root, "svc/cgroup.procs")); string(b) != "77" {
+		t.Errorf("exec moved %q, want 77", b)
+	}
+	os.WriteFile(filepath.Join(root, "svc/cgroup.procs"), []byte("10\n11\n"), 0o644)
+
+	out, err := run(t, root, "show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `  PROCS    CPU  MEMORY  MEM.MAX  PIDS  PIDS.MAX  IO.READ  IO.WRITE CGROUP
+      1  2.50s       0        -     0         -        0         0 /
+      2  0.00s    1.0M   256.0M     2       max     2.0K         0 /svc
+`
+	if out != want {
+		t.Errorf("show =\n%s\nwant\n%s", out, want)
+	}
+
+	out, err = run(t, root, "show", "-json", "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []entry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "/svc" || !reflect.DeepEqual(entries[0].Procs, []int{10, 11}) || entries[0].Stats.Memory != 1<<20 {
+		t.Errorf("show -json = %s", out)
+	}
+
+	if _, err := run(t, root, "create", "svc/sub/leaf"); err != nil {
+		t.Fatalf("create = %v", err)
+	}
+	if _, err := run(t, root, "delete", "svc/sub"); err == nil {
+		t.Errorf("delete of a cgroup with children succeeded")
+	}
+	if _, err := run(t, root, "delete", "-r", "svc/sub"); err != nil {
+		t.Errorf("delete -r = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "svc/sub")); !os.IsNotExist(err) {
+		t.Errorf("svc/sub was not deleted: %v", err)
+	}
+}
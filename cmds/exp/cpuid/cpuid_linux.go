@@ -0,0 +1,204 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cpuid enumerates and decodes the CPUID leaves of each processor package.
+//
+// Synopsis:
+//
+//	cpuid [-raw] [-json] [-a]
+//
+// Description:
+//
+//	cpuid reads all leaves and subleaves from the first CPU of each
+//	package, through /dev/cpu/N/cpuid, and prints the vendor, brand,
+//	signature, cache topology and features. On hybrid CPUs, the type of
+//	every core is read and the CPUs of each type are listed.
+//
+//	The cpuid driver has to be loaded, e.g. with modprobe cpuid.
+//
+// Options:
+//
+//	-raw: print the registers of all leaves instead of decoding them
+//	-json: print the leaves and their decoding as JSON
+//	-a: read the leaves of every CPU, not just the first of each package
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cpuid"
+)
+
+var (
+	flagRaw  = flag.Bool("raw", false, "print the registers of all leaves instead of decoding them")
+	flagJSON = flag.Bool("json", false, "print the leaves and their decoding as JSON")
+	flagAll  = flag.Bool("a", false, "read the leaves of every CPU, not just the first of each package")
+)
+
+type reader interface {
+	cpuid.Reader
+	Close() error
+}
+
+var (
+	open     = func(cpu int) (reader, error) { return cpuid.Open(cpu) }
+	packages = cpuid.Packages
+)
+
+type cpuInfo struct {
+	CPU      int          `json:"cpu"`
+	CoreType string       `json:"core_type,omitempty"`
+	Leaves   cpuid.Leaves `json:"leaves,omitempty"`
+}
+
+type packageInfo struct {
+	Package int        `json:"package"`
+	Info    cpuid.Info `json:"info"`
+	CPUs    []cpuInfo  `json:"cpus"`
+}
+
+// read reads the core type of a CPU and, if all is set, its leaves.
+func read(cpu int, all bool) (cpuInfo, error) {
+	r, err := open(cpu)
+	if err != nil {
+		return cpuInfo{}, err
+	}
+	defer r.Close()
+	c := cpuInfo{CPU: cpu}
+	if all {
+		if c.Leaves, err = cpuid.Enumerate(r); err != nil {
+			return cpuInfo{}, fmt.Errorf("cpu %d: %w", cpu, err)
+		}
+	}
+	t, err := cpuid.ReadCoreType(r)
+	if err != nil {
+		return cpuInfo{}, fmt.Errorf("cpu %d: %w", cpu, err)
+	}
+	if t != cpuid.CoreTypeNone {
+		c.CoreType = t.String()
+	}
+	return c, nil
+}
+
+func collect(all bool) ([]packageInfo, error) {
+	pkgs, err := packages()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for id := range pkgs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var infos []packageInfo
+	for _, id := range ids {
+		p := packageInfo{Package: id}
+		for i, cpu := range pkgs[id] {
+			c, err := read(cpu, all || i == 0)
+			if err != nil {
+				return nil, err
+			}
+			p.CPUs = append(p.CPUs, c)
+		}
+		p.Info = p.CPUs[0].Leaves.Decode()
+		infos = append(infos, p)
+	}
+	return infos, nil
+}
+
+// ranges formats a sorted list of CPUs as, e.g., 0-3,8.
+func ranges(cpus []int) string {
+	var s []string
+	for i := 0; i < len(cpus); i++ {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			s = append(s, fmt.Sprint(cpus[i]))
+		} else {
+			s = append(s, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j
+	}
+	return strings.Join(s, ",")
+}
+
+func printDecoded(w io.Writer, p packageInfo) {
+	var all []int
+	types := map[string][]int{}
+	for _, c := range p.CPUs {
+		all = append(all, c.CPU)
+		if c.CoreType != "" {
+			types[c.CoreType] = append(types[c.CoreType], c.CPU)
+		}
+	}
+	i := p.Info
+	fmt.Fprintf(w, "Package %d: CPUs %s\n", p.Package, ranges(all))
+	fmt.Fprintf(w, "\tVendor: %s\n", i.Vendor)
+	if i.Brand != "" {
+		fmt.Fprintf(w, "\tBrand: %s\n", i.Brand)
+	}
+	fmt.Fprintf(w, "\tSignature: %v\n", i.Signature)
+	for _, t := range []string{cpuid.CoreTypeCore.String(), cpuid.CoreTypeAtom.String()} {
+		if cpus, ok := types[t]; ok {
+			fmt.Fprintf(w, "\t%ss: %s\n", t, ranges(cpus))
+		}
+	}
+	fmt.Fprintf(w, "\tCaches:\n")
+	for _, c := range i.Caches {
+		fmt.Fprintf(w, "\t\t%v\n", c)
+	}
+	fmt.Fprintf(w, "\tFeatures: %s\n", strings.Join(i.Features, " "))
+}
+
+func printRaw(w io.Writer, p packageInfo) {
+	for _, c := range p.CPUs {
+		if c.Leaves == nil {
+			continue
+		}
+		fmt.Fprintf(w, "Package %d, CPU %d:\n", p.Package, c.CPU)
+		for _, l := range c.Leaves {
+			fmt.Fprintf(w, "\t%v\n", l)
+		}
+	}
+}
+
+func run(w io.Writer, raw, asJSON, all bool) error {
+	infos, err := collect(all)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(infos)
+	}
+	for i, p := range infos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if raw {
+			printRaw(w, p)
+		} else {
+			printDecoded(w, p)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdout, *flagRaw, *flagJSON, *flagAll); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cpuid"
+)
+
+// fakeCPU is a hybrid CPU with leaves 0, 1, 7 and 0x1a.
+type fakeCPU struct {
+	coreType uint32
+}
+
+func (f fakeCPU) CPUID(leaf, subleaf uint32) (cpuid.Regs, error) {
+	switch {
+	case subleaf != 0:
+	case leaf == 0:
+		// GenuineIntel
+		return cpuid.Regs{EAX: 0x1a, EBX: 0x756e6547, EDX: 0x49656e69, ECX: 0x6c65746e}, nil
+	case leaf == 1:
+		return cpuid.Regs{EAX: 0x000906a3, EDX: 1 << 0}, nil
+	case leaf == 7:
+		return cpuid.Regs{EDX: 1 << 15}, nil
+	case leaf == 0x1a:
+		return cpuid.Regs{EAX: f.coreType << 24}, nil
+	}
+	return cpuid.Regs{}, nil
+}
+
+func (fakeCPU) Close() error {
+	return nil
+}
+
+func setup(t *testing.T) {
+	oldOpen, oldPackages := open, packages
+	t.Cleanup(func() { open, packages = oldOpen, oldPackages })
+	open = func(cpu int) (reader, error) {
+		if cpu < 2 {
+			return fakeCPU{coreType: 0x40}, nil
+		}
+		return fakeCPU{coreType: 0x20}, nil
+	}
+	packages = func() (map[int][]int, error) {
+		return map[int][]int{0: {0, 1, 2, 3}}, nil
+	}
+}
+
+func TestDecoded(t *testing.T) {
+	setup(t)
+	var out strings.Builder
+	if err := run(&out, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `Package 0: CPUs 0-3
+	Vendor: GenuineIntel
+	Signature: family 0x6, model 0x9a, stepping 0x3
+	P-cores: 0-1
+	E-cores: 2-3
+	Caches:
+	Features: fpu hybrid_cpu
+`
+	if out.String() != want {
+		t.Errorf("run =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	setup(t)
+	var out strings.Builder
+	if err := run(&out, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	var got []packageInfo
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(got) != 1 || len(got[0].CPUs) != 4 {
+		t.Fatalf("got %+v, want 1 package of 4 CPUs", got)
+	}
+	// Only the first CPU of the package is enumerated without -a.
+	if n := len(got[0].CPUs[0].Leaves); n != 0x1b {
+		t.Errorf("CPU 0 has %d leaves, want %d", n, 0x1b)
+	}
+	if got[0].CPUs[3].Leaves != nil || got[0].CPUs[3].CoreType != "E-core" {
+		t.Errorf("CPU 3 = %+v, want an E-core without leaves", got[0].CPUs[3])
+	}
+}
+
+func TestRaw(t *testing.T) {
+	setup(t)
+	var out strings.Builder
+	if err := run(&out, true, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "0x00000007 0x00: eax=0x00000000 ebx=0x00000000 ecx=0x00000000 edx=0x00008000"); n != 4 {
+		t.Errorf("leaf 7 printed %d times, want once for each of 4 CPUs:\n%s", n, out.String())
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+	"golang.org/x/crypto/ssh"
+)
+
+var errKeyType = errors.New("unsupported key type")
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// newKey returns a new private key of type typ.
+func newKey(typ string, bits int, curve string) (crypto.Signer, error) {
+	switch typ {
+	case "rsa":
+		if bits < 2048 {
+			return nil, fmt.Errorf("%w: RSA keys of %d bits are too small", errKeyType, bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case "ecdsa":
+		c, ok := curves[curve]
+		if !ok {
+			return nil, fmt.Errorf("%w: curve %q, want P-256, P-384 or P-521", errKeyType, curve)
+		}
+		return ecdsa.GenerateKey(c, rand.Reader)
+	case "ed25519":
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		return k, err
+	}
+	return nil, fmt.Errorf("%w: %q", errKeyType, typ)
+}
+
+// writePEM writes a PEM block of typ holding der to the file name, or to
+// stdout if name is empty.
+func writePEM(stdout io.Writer, name string, mode os.FileMode, typ string, der []byte) error {
+	w, done, err := output(stdout, name, mode)
+	if err != nil {
+		return err
+	}
+	return done(pem.Encode(w, &pem.Block{Type: typ, Bytes: der}))
+}
+
+func genkey(stdout io.Writer, args []string) error {
+	f := flag.NewFlagSet("genkey", flag.ContinueOnError)
+	var (
+		typ       = f.String("type", "ecdsa", "key type: aes, rsa, ecdsa or ed25519")
+		bits      = f.Int("bits", 3072, "size of RSA keys")
+		curve     = f.String("curve", "P-256", "curve of ECDSA keys")
+		out       = f.String("out", "", "write the key to `file` rather than stdout")
+		pubOut    = f.String("pubout", "", "write the public key as PEM to `file`")
+		sshPubOut = f.String("sshpubout", "", "write the public key as an SSH authorized key to `file`")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 0 {
+		return errUsage
+	}
+
+	if *typ == "aes" {
+		if *pubOut != "" || *sshPubOut != "" {
+			return fmt.Errorf("%w: AES keys have no public key", errKeyType)
+		}
+		k := make([]byte, keySize)
+		if _, err := rand.Read(k); err != nil {
+			return err
+		}
+		w, done, err := output(stdout, *out, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, hex.EncodeToString(k))
+		return done(err)
+	}
+
+	k, err := newKey(*typ, *bits, *curve)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(stdout, *out, 0o600, "PRIVATE KEY", der); err != nil {
+		return err
+	}
+	if *pubOut != "" {
+		der, err := x509.MarshalPKIXPublicKey(k.Public())
+		if err != nil {
+			return err
+		}
+		if err := writePEM(stdout, *pubOut, 0o644, "PUBLIC KEY", der); err != nil {
+			return err
+		}
+	}
+	if *sshPubOut != "" {
+		pub, err := ssh.NewPublicKey(k.Public())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*sshPubOut, ssh.MarshalAuthorizedKey(pub), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPrivateKey reads a PEM private key, as PKCS #8, PKCS #1 or SEC 1.
+func readPrivateKey(name string) (crypto.Signer, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s is not PEM", errKey, name)
+	}
+	var k any
+	switch block.Type {
+	case "PRIVATE KEY":
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s holds a %s", errKey, name, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errKey, name, err)
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errKeyType, k)
+	}
+	return s, nil
+}
+
+// names are the subject and names of a request or certificate.
+type names struct {
+	key     string
+	subject pkix.Name
+	dns     unixflag.StringSlice
+	ips     []net.IP
+}
+
+func (n *names) flags(f *flag.FlagSet) {
+	f.StringVar(&n.key, "key", "", "private key `file`")
+	f.Func("subj", "`subject`, as /CN=NAME/O=ORG", func(s string) error {
+		var err error
+		n.subject, err = parseSubject(s)
+		return err
+	})
+	f.Var(&n.dns, "dns", "comma-separated DNS `names`")
+	f.Func("ip", "comma-separated IP `addresses`", func(s string) error {
+		var addrs unixflag.StringSlice
+		addrs.Set(s)
+		for _, a := range addrs {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", a)
+			}
+			n.ips = append(n.ips, ip)
+		}
+		return nil
+	})
+}
+
+func (n *names) signer() (crypto.Signer, error) {
+	if n.key == "" {
+		return nil, fmt.Errorf("%w: -key is required", errUsage)
+	}
+	if n.subject.CommonName == "" && len(n.dns) == 0 && len(n.ips) == 0 {
+		return nil, fmt.Errorf("%w: give a common name, DNS names or IP addresses", errSubject)
+	}
+	return readPrivateKey(n.key)
+}
+
+func req(stdout io.Writer, args []string) error {
+	f := flag.NewFlagSet("req", flag.ContinueOnError)
+	var n names
+	n.flags(f)
+	out := f.String("out", "", "write the request to `file` rather than stdout")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 0 {
+		return errUsage
+	}
+	k, err := n.signer()
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     n.subject,
+		DNSNames:    n.dns,
+		IPAddresses: n.ips,
+	}, k)
+	if err != nil {
+		return err
+	}
+	return writePEM(stdout, *out, 0o644, "CERTIFICATE REQUEST", der)
+}
+
+func selfsign(stdout io.Writer, args []string) error {
+	f := flag.NewFlagSet("selfsign", flag.ContinueOnError)
+	var n names
+	n.flags(f)
+	var (
+		days = f.Int("days", 365, "days the certificate is valid for")
+		ca   = f.Bool("ca", false, "make a CA certificate, which may sign others")
+		out  = f.String("out", "", "write the certificate to `file` rather than stdout")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 0 || *days <= 0 {
+		return errUsage
+	}
+	k, err := n.signer()
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	// Allow for clocks somewhat behind this one.
+	now := time.Now().Add(-time.Hour)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               n.subject,
+		DNSNames:              n.dns,
+		IPAddresses:           n.ips,
+		NotBefore:             now,
+		NotAfter:              now.AddDate(0, 0, *days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  *ca,
+	}
+	if _, ok := k.(*rsa.PrivateKey); ok {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if *ca {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, k.Public(), k)
+	if err != nil {
+		return err
+	}
+	return writePEM(stdout, *out, 0o644, "CERTIFICATE", der)
+}
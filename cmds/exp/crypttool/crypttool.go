@@ -0,0 +1,146 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// crypttool encrypts files and makes keys and certificates, as a small
+// subset of openssl does, so that TLS and SSH material can be made on the
+// machine which uses it.
+//
+// Synopsis:
+//
+//	crypttool enc [-d] (-key FILE | -pass PASS | -passfile FILE) [-in FILE] [-out FILE]
+//	crypttool dec (-key FILE | -pass PASS | -passfile FILE) [-in FILE] [-out FILE]
+//	crypttool genkey [-type aes|rsa|ecdsa|ed25519] [-bits N] [-curve P-256|P-384|P-521] [-out FILE] [-pubout FILE] [-sshpubout FILE]
+//	crypttool req -key FILE -subj /CN=NAME/O=ORG... [-dns NAMES] [-ip ADDRS] [-out FILE]
+//	crypttool selfsign -key FILE -subj /CN=NAME/O=ORG... [-days N] [-ca] [-dns NAMES] [-ip ADDRS] [-out FILE]
+//
+// Description:
+//
+//	enc encrypts, and dec or enc -d decrypts, files with AES-256-GCM, with
+//	a key from a file, as genkey -type aes makes them, or derived from a
+//	passphrase with Argon2id. Files are encrypted in chunks, so that large
+//	files need not fit in memory, and decryption fails for files which
+//	were changed, reordered or cut short.
+//
+//	genkey makes private keys, written as PKCS #8 PEM, with their public
+//	keys as PEM and as SSH authorized keys if asked.
+//
+//	req makes a certificate signing request, and selfsign a self-signed
+//	certificate, for a key, both as PEM. The subject is given as
+//	/CN=NAME/O=ORG/OU=UNIT/C=COUNTRY/ST=STATE/L=LOCALITY, and names and
+//	addresses as comma-separated lists.
+//
+//	Files are read from stdin and written to stdout if -in and -out are
+//	not given. Private keys are written with mode 0600.
+package main
+
+import (
+	"crypto/x509/pkix"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	errUsage   = errors.New("usage: crypttool enc|dec|genkey|req|selfsign [OPTIONS]")
+	errSubject = errors.New("invalid subject")
+)
+
+// output returns the file named name to write to, or stdout if name is
+// empty, with the function to call once it is written. If the write
+// failed, the file is removed.
+func output(stdout io.Writer, name string, mode os.FileMode) (io.Writer, func(error) error, error) {
+	if name == "" {
+		return stdout, func(err error) error { return err }, nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func(err error) error {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(name)
+		}
+		return err
+	}, nil
+}
+
+// input returns the file named name to read from, or stdin if name is
+// empty.
+func input(stdin io.Reader, name string) (io.ReadCloser, error) {
+	if name == "" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(name)
+}
+
+// parseSubject parses a subject such as /CN=example.com/O=Example.
+func parseSubject(s string) (pkix.Name, error) {
+	var n pkix.Name
+	rest, ok := strings.CutPrefix(s, "/")
+	if !ok {
+		return n, fmt.Errorf("%w: %q does not start with /", errSubject, s)
+	}
+	for _, rdn := range strings.Split(rest, "/") {
+		if rdn == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(rdn, "=")
+		if !ok || v == "" {
+			return n, fmt.Errorf("%w: %q in %q", errSubject, rdn, s)
+		}
+		switch strings.ToUpper(k) {
+		case "CN":
+			n.CommonName = v
+		case "O":
+			n.Organization = append(n.Organization, v)
+		case "OU":
+			n.OrganizationalUnit = append(n.OrganizationalUnit, v)
+		case "C":
+			n.Country = append(n.Country, v)
+		case "ST":
+			n.Province = append(n.Province, v)
+		case "L":
+			n.Locality = append(n.Locality, v)
+		default:
+			return n, fmt.Errorf("%w: unknown attribute %q in %q", errSubject, k, s)
+		}
+	}
+	return n, nil
+}
+
+func run(stdin io.Reader, stdout io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "enc":
+		return enc(stdin, stdout, false, args)
+	case "dec":
+		return enc(stdin, stdout, true, args)
+	case "genkey":
+		return genkey(stdout, args)
+	case "req":
+		return req(stdout, args)
+	case "selfsign":
+		return selfsign(stdout, args)
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+}
+
+func main() {
+	if err := run(os.Stdin, os.Stdout, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		size int
+		s    secret
+	}{
+		{name: "empty", size: 0, s: secret{key: key}},
+		{name: "short", size: 1, s: secret{key: key}},
+		{name: "partial chunk", size: chunkSize - 1, s: secret{key: key}},
+		{name: "one chunk", size: chunkSize, s: secret{key: key}},
+		{name: "several chunks", size: 2*chunkSize + 5, s: secret{key: key}},
+		{name: "passphrase", size: chunkSize + 1, s: secret{pass: []byte("hunter2")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			rand.Read(data)
+			var enc, dec bytes.Buffer
+			if err := encrypt(&enc, bytes.NewReader(data), tt.s); err != nil {
+				t.Fatalf("encrypt = %v", err)
+			}
+			if err := decrypt(&dec, bytes.NewReader(enc.Bytes()), tt.s); err != nil {
+				t.Fatalf("decrypt = %v", err)
+			}
+			if !bytes.Equal(dec.Bytes(), data) {
+				t.Errorf("decrypt(encrypt(%d bytes)) differs", tt.size)
+			}
+		})
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := make([]byte, keySize)
+	other := make([]byte, keySize)
+	other[0] = 1
+	data := make([]byte, 2*chunkSize+5)
+	var enc bytes.Buffer
+	if err := encrypt(&enc, bytes.NewReader(data), secret{key: key}); err != nil {
+		t.Fatal(err)
+	}
+	full := chunkSize + 16
+	flipped := bytes.Clone(enc.Bytes())
+	flipped[headerSize+full+10] ^= 1
+	swapped := bytes.Clone(enc.Bytes())
+	copy(swapped[headerSize:], enc.Bytes()[headerSize+full:headerSize+2*full])
+	copy(swapped[headerSize+full:], enc.Bytes()[headerSize:headerSize+full])
+
+	for _, tt := range []struct {
+		name string
+		in   []byte
+		s    secret
+		err  error
+	}{
+		{name: "wrong key", in: enc.Bytes(), s: secret{key: other}, err: errDecrypt},
+		{name: "passphrase for key", in: enc.Bytes(), s: secret{pass: []byte("x")}, err: errKey},
+		{name: "changed", in: flipped, s: secret{key: key}, err: errDecrypt},
+		{name: "reordered", in: swapped, s: secret{key: key}, err: errDecrypt},
+		{name: "cut at chunk", in: enc.Bytes()[:headerSize+2*full], s: secret{key: key}, err: errTruncated},
+		{name: "cut in chunk", in: enc.Bytes()[:headerSize+full+100], s: secret{key: key}, err: errDecrypt},
+		{name: "header only", in: enc.Bytes()[:headerSize], s: secret{key: key}, err: errTruncated},
+		{name: "not encrypted", in: []byte("hello"), s: secret{key: key}, err: errFormat},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := decrypt(&out, bytes.NewReader(tt.in), tt.s); !errors.Is(err, tt.err) {
+				t.Errorf("decrypt = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEncFiles(t *testing.T) {
+	d := t.TempDir()
+	key, in, enc, out := filepath.Join(d, "key"), filepath.Join(d, "in"), filepath.Join(d, "enc"), filepath.Join(d, "out")
+	if err := os.WriteFile(in, []byte("secret data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"genkey", "-type", "aes", "-out", key},
+		{"enc", "-key", key, "-in", in, "-out", enc},
+		{"dec", "-key", key, "-in", enc, "-out", out},
+	} {
+		if err := run(nil, nil, args); err != nil {
+			t.Fatalf("run(%q) = %v", args, err)
+		}
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "secret data" {
+		t.Errorf("decrypted %q, %v, want %q", b, err, "secret data")
+	}
+
+	// A failed decryption leaves no output behind.
+	os.Remove(out)
+	if err := run(bytes.NewReader(nil), nil, []string{"enc", "-d", "-key", key, "-in", in, "-out", out}); !errors.Is(err, errFormat) {
+		t.Errorf("decrypting plaintext = %v, want %v", err, errFormat)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("failed decryption left %s: %v", out, err)
+	}
+	if err := run(nil, nil, []string{"enc", "-key", key, "-pass", "x"}); !errors.Is(err, errKey) {
+		t.Errorf("enc -key -pass = %v, want %v", err, errKey)
+	}
+}
+
+func TestParseSubject(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		cn   string
+		orgs []string
+		err  error
+	}{
+		{in: "/CN=box", cn: "box"},
+		{in: "/CN=box/O=u-root/O=other/C=US/ST=CA/L=SF/OU=boot", cn: "box", orgs: []string{"u-root", "other"}},
+		{in: "/cn=lower", cn: "lower"},
+		{in: "CN=box", err: errSubject},
+		{in: "/CN=", err: errSubject},
+		{in: "/XX=y", err: errSubject},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			n, err := parseSubject(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("parseSubject(%q) = %v, want %v", tt.in, err, tt.err)
+			}
+			if n.CommonName != tt.cn || !reflect.DeepEqual(n.Organization, tt.orgs) {
+				t.Errorf("parseSubject(%q) = %+v, want CN %q, O %q", tt.in, n, tt.cn, tt.orgs)
+			}
+		})
+	}
+}
+
+func TestCertificates(t *testing.T) {
+	for _, args := range [][]string{
+		{"-type", "rsa", "-bits", "2048"},
+		{"-type", "ecdsa", "-curve", "P-384"},
+		{"-type", "ed25519"},
+	} {
+		t.Run(args[1], func(t *testing.T) {
+			d := t.TempDir()
+			key, pub, sshPub := filepath.Join(d, "key"), filepath.Join(d, "pub"), filepath.Join(d, "ssh.pub")
+			cert, csr := filepath.Join(d, "cert"), filepath.Join(d, "csr")
+			names := []string{"-key", key, "-subj", "/CN=box/O=u-root", "-dns", "box,box.local", "-ip", "10.0.0.1"}
+			for _, args := range [][]string{
+				append([]string{"genkey", "-out", key, "-pubout", pub, "-sshpubout", sshPub}, args...),
+				append([]string{"selfsign", "-days", "30", "-out", cert}, names...),
+				append([]string{"req", "-out", csr}, names...),
+			} {
+				if err := run(nil, nil, args); err != nil {
+					t.Fatalf("run(%q) = %v", args, err)
+				}
+			}
+
+			if fi, err := os.Stat(key); err != nil || fi.Mode().Perm() != 0o600 {
+				t.Errorf("key mode = %v, %v, want 0600", fi.Mode(), err)
+			}
+			pair, err := tls.LoadX509KeyPair(cert, key)
+			if err != nil {
+				t.Fatalf("tls.LoadX509KeyPair = %v", err)
+			}
+			c, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature); err != nil {
+				t.Errorf("certificate is not self-signed: %v", err)
+			}
+			if c.Subject.CommonName != "box" || !reflect.DeepEqual(c.DNSNames, []string{"box", "box.local"}) || len(c.IPAddresses) != 1 {
+				t.Errorf("certificate for %v, %q, %v, want box, [box box.local], [10.0.0.1]", c.Subject, c.DNSNames, c.IPAddresses)
+			}
+
+			b, err := os.ReadFile(csr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ := pem.Decode(b)
+			if block == nil || block.Type != "CERTIFICATE REQUEST" {
+				t.Fatalf("%s is not a PEM request", csr)
+			}
+			r, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.CheckSignature(); err != nil {
+				t.Errorf("request signature: %v", err)
+			}
+			for _, f := range []string{pub, sshPub} {
+				if fi, err := os.Stat(f); err != nil || fi.Size() == 0 {
+					t.Errorf("public key %s not written: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		err  error
+	}{
+		{args: nil, err: errUsage},
+		{args: []string{"sign"}, err: errUsage},
+		{args: []string{"genkey", "-type", "dsa"}, err: errKeyType},
+		{args: []string{"genkey", "-type", "rsa", "-bits", "1024"}, err: errKeyType},
+		{args: []string{"selfsign", "-subj", "/CN=box"}, err: errUsage},
+		{args: []string{"req", "-key", "k", "-subj", "/"}, err: errSubject},
+	} {
+		if err := run(nil, nil, tt.args); !errors.Is(err, tt.err) {
+			t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.err)
+		}
+	}
+}
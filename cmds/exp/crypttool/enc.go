@@ -0,0 +1,241 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted files start with a header, which is authenticated with each
+// chunk:
+//
+//	magic   [8]byte  "UCRYPT01"
+//	kdf     byte     kdfKey or kdfArgon2id
+//	salt    [16]byte for Argon2id, zero with a key
+//	nonce   [7]byte  random nonce prefix
+//
+// and the chunks follow, each of chunkSize bytes of plaintext but the last,
+// which is shorter and may be empty, sealed with the nonce prefix, the
+// chunk's index as a big-endian uint32 and a byte which is 1 for the last
+// chunk and 0 otherwise.
+const (
+	magic       = "UCRYPT01"
+	kdfKey      = 0
+	kdfArgon2id = 1
+	saltSize    = 16
+	prefixSize  = 7
+	headerSize  = len(magic) + 1 + saltSize + prefixSize
+	keySize     = 32
+	chunkSize   = 64 << 10
+
+	// Argon2id parameters, as RFC 9106 recommends for memory-constrained
+	// systems.
+	argonTime    = 3
+	argonMemory  = 64 << 10
+	argonThreads = 4
+)
+
+var (
+	errKey       = errors.New("invalid key")
+	errFormat    = errors.New("not a crypttool file")
+	errDecrypt   = errors.New("message authentication failed")
+	errTruncated = errors.New("file is truncated")
+)
+
+// secret is a key, or a passphrase to derive one from.
+type secret struct {
+	key  []byte
+	pass []byte
+}
+
+// readKey reads a key of keySize bytes, raw or in hex, from name.
+func readKey(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == keySize {
+		return b, nil
+	}
+	k, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil || len(k) != keySize {
+		return nil, fmt.Errorf("%w: %s has neither %d bytes nor %d hex digits", errKey, name, keySize, 2*keySize)
+	}
+	return k, nil
+}
+
+// aead returns the cipher for a file with header h.
+func (s secret) aead(h []byte) (cipher.AEAD, error) {
+	key := s.key
+	switch h[len(magic)] {
+	case kdfKey:
+		if key == nil {
+			return nil, fmt.Errorf("%w: file is encrypted with a key, not a passphrase", errKey)
+		}
+	case kdfArgon2id:
+		if s.pass == nil {
+			return nil, fmt.Errorf("%w: file is encrypted with a passphrase, not a key", errKey)
+		}
+		salt := h[len(magic)+1 : len(magic)+1+saltSize]
+		key = argon2.IDKey(s.pass, salt, argonTime, argonMemory, argonThreads, keySize)
+	default:
+		return nil, fmt.Errorf("%w: unknown key derivation %d", errFormat, h[len(magic)])
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// nonce returns the nonce of chunk i of a file with header h.
+func nonce(h []byte, i uint32, last bool) []byte {
+	n := make([]byte, 0, 12)
+	n = append(n, h[headerSize-prefixSize:]...)
+	n = binary.BigEndian.AppendUint32(n, i)
+	if last {
+		return append(n, 1)
+	}
+	return append(n, 0)
+}
+
+// encrypt encrypts r to w.
+func encrypt(w io.Writer, r io.Reader, s secret) error {
+	h := make([]byte, headerSize)
+	copy(h, magic)
+	if s.key == nil {
+		h[len(magic)] = kdfArgon2id
+		if _, err := rand.Read(h[len(magic)+1 : len(magic)+1+saltSize]); err != nil {
+			return err
+		}
+	}
+	if _, err := rand.Read(h[headerSize-prefixSize:]); err != nil {
+		return err
+	}
+	a, err := s.aead(h)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize, chunkSize+a.Overhead())
+	for i := uint32(0); ; i++ {
+		if i == ^uint32(0) {
+			return fmt.Errorf("file is too large")
+		}
+		n, err := io.ReadFull(r, buf)
+		last := n < chunkSize
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if _, err := w.Write(a.Seal(buf[:0], nonce(h, i, last), buf[:n], h)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// decrypt decrypts r to w.
+func decrypt(w io.Writer, r io.Reader, s secret) error {
+	h := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, h); err != nil || string(h[:len(magic)]) != magic {
+		return errFormat
+	}
+	a, err := s.aead(h)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize+a.Overhead())
+	for i := uint32(0); ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return errTruncated
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < len(buf)
+		p, err := a.Open(buf[:0], nonce(h, i, last), buf[:n], h)
+		if err != nil {
+			return errDecrypt
+		}
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func enc(stdin io.Reader, stdout io.Writer, decrypting bool, args []string) error {
+	f := flag.NewFlagSet("enc", flag.ContinueOnError)
+	var (
+		d        = f.Bool("d", false, "decrypt")
+		keyFile  = f.String("key", "", "read the key from `file`")
+		pass     = f.String("pass", "", "derive the key from `passphrase`")
+		passFile = f.String("passfile", "", "read the passphrase from the first line of `file`")
+		in       = f.String("in", "", "read from `file` rather than stdin")
+		out      = f.String("out", "", "write to `file` rather than stdout")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 0 {
+		return errUsage
+	}
+
+	var s secret
+	switch {
+	case *keyFile != "" && *pass == "" && *passFile == "":
+		k, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		s.key = k
+	case *keyFile == "" && *pass != "" && *passFile == "":
+		s.pass = []byte(*pass)
+	case *keyFile == "" && *pass == "" && *passFile != "":
+		b, err := os.ReadFile(*passFile)
+		if err != nil {
+			return err
+		}
+		line, _, _ := bytes.Cut(b, []byte("\n"))
+		s.pass = bytes.TrimSuffix(line, []byte("\r"))
+	default:
+		return fmt.Errorf("%w: give one of -key, -pass and -passfile", errKey)
+	}
+
+	r, err := input(stdin, *in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, done, err := output(stdout, *out, 0o600)
+	if err != nil {
+		return err
+	}
+	if decrypting || *d {
+		return done(decrypt(w, r, s))
+	}
+	return done(encrypt(w, r, s))
+}
@@ -0,0 +1,426 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// diff compares files line by line.
+//
+// Synopsis:
+//
+//	diff [-u] [-U N] [-q] [-a] [-r] [-x PATTERN]... FILE1 FILE2
+//
+// Description:
+//
+//	diff prints the lines which differ between FILE1 and FILE2, in the
+//	normal format or, with -u, as a unified diff with context. If one of
+//	them is a directory, the file of the same name in it is compared. If
+//	both are, diff compares the files they have in common and lists the
+//	others; with -r, it descends into subdirectories too. Files and
+//	directories whose name matches an -x pattern are skipped, e.g. to
+//	review the configs of two images without their caches.
+//
+//	Files with a NUL byte at the start are binary, and diff only reports
+//	that they differ unless -a is given.
+//
+//	The exit status is 0 if the files are the same, 1 if they differ and 2
+//	for errors.
+//
+// Options:
+//
+//	-u: print a unified diff with 3 lines of context
+//	-U: print a unified diff with N lines of context
+//	-q: only report whether files differ
+//	-a: treat all files as text
+//	-r: compare subdirectories recursively
+//	-x: skip files whose name matches the shell PATTERN
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// binarySniff is how much of a file is searched for NUL bytes.
+const binarySniff = 8000
+
+var (
+	errUsage  = errors.New("usage: diff [-u] [-U N] [-q] [-a] [-r] [-x PATTERN]... FILE1 FILE2")
+	errDiffer = errors.New("files differ")
+	errFailed = errors.New("some files could not be compared")
+)
+
+// patterns is a flag which may be given more than once.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(s string) error {
+	if _, err := filepath.Match(s, ""); err != nil {
+		return err
+	}
+	*p = append(*p, s)
+	return nil
+}
+
+type cmd struct {
+	w       io.Writer
+	context int
+	unified bool
+	brief   bool
+	text    bool
+	recurse bool
+	exclude patterns
+	// opts are the options as given, for the headers of files in
+	// directories.
+	opts string
+}
+
+// splitLines splits b into lines which keep their newline.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	l := strings.SplitAfter(string(b), "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// printLine prints a line with a prefix, noting a missing newline.
+func (c *cmd) printLine(prefix, l string) {
+	if strings.HasSuffix(l, "\n") {
+		fmt.Fprintf(c.w, "%s%s", prefix, l)
+	} else {
+		fmt.Fprintf(c.w, "%s%s\n\\ No newline at end of file\n", prefix, l)
+	}
+}
+
+// hunks groups the changes of edits into hunks with context lines of
+// unchanged lines around them, merging hunks which overlap.
+func hunks(edits []edit, context int) [][]edit {
+	var h [][]edit
+	start, end := -1, -1
+	for i, e := range edits {
+		if e.op == opEq {
+			continue
+		}
+		if start >= 0 && i-context <= end+1 {
+			end = min(i+context, len(edits)-1)
+			continue
+		}
+		if start >= 0 {
+			h = append(h, edits[start:end+1])
+		}
+		start, end = max(0, i-context), min(i+context, len(edits)-1)
+	}
+	if start >= 0 {
+		h = append(h, edits[start:end+1])
+	}
+	return h
+}
+
+// span returns the number of lines of a and b in hunk h.
+func span(h []edit) (na, nb int) {
+	for _, e := range h {
+		if e.op != opIns {
+			na++
+		}
+		if e.op != opDel {
+			nb++
+		}
+	}
+	return na, nb
+}
+
+// unifiedRange formats the range of a hunk header.
+func unifiedRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// normalRange formats the range of a change in the normal format.
+func normalRange(start, n int) string {
+	if n <= 1 {
+		return fmt.Sprintf("%d", start+n)
+	}
+	return fmt.Sprintf("%d,%d", start+1, start+n)
+}
+
+func (c *cmd) printUnified(a, b []string, edits []edit) {
+	for _, h := range hunks(edits, c.context) {
+		na, nb := span(h)
+		fmt.Fprintf(c.w, "@@ -%s +%s @@\n", unifiedRange(h[0].a, na), unifiedRange(h[0].b, nb))
+		for _, e := range h {
+			switch e.op {
+			case opEq:
+				c.printLine(" ", a[e.a])
+			case opDel:
+				c.printLine("-", a[e.a])
+			case opIns:
+				c.printLine("+", b[e.b])
+			}
+		}
+	}
+}
+
+func (c *cmd) printNormal(a, b []string, edits []edit) {
+	for _, h := range hunks(edits, 0) {
+		na, nb := span(h)
+		switch {
+		case nb == 0:
+			fmt.Fprintf(c.w, "%sd%d\n", normalRange(h[0].a, na), h[0].b)
+		case na == 0:
+			fmt.Fprintf(c.w, "%da%s\n", h[0].a, normalRange(h[0].b, nb))
+		default:
+			fmt.Fprintf(c.w, "%sc%s\n", normalRange(h[0].a, na), normalRange(h[0].b, nb))
+		}
+		for _, e := range h {
+			if e.op == opDel {
+				c.printLine("< ", a[e.a])
+			}
+		}
+		if na > 0 && nb > 0 {
+			fmt.Fprintln(c.w, "---")
+		}
+		for _, e := range h {
+			if e.op == opIns {
+				c.printLine("> ", b[e.b])
+			}
+		}
+	}
+}
+
+func isBinary(b []byte) bool {
+	return bytes.IndexByte(b[:min(len(b), binarySniff)], 0) >= 0
+}
+
+// header formats the name and modification time of a file for a unified
+// diff.
+func header(name string, fi os.FileInfo) string {
+	return fmt.Sprintf("%s\t%s", name, fi.ModTime().Format("2006-01-02 15:04:05.000000000 -0700"))
+}
+
+// diffFiles compares the files a and b.
+func (c *cmd) diffFiles(a, b string, fa, fb os.FileInfo) error {
+	da, err := os.ReadFile(a)
+	if err != nil {
+		return err
+	}
+	db, err := os.ReadFile(b)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(da, db) {
+		return nil
+	}
+	if c.brief {
+		fmt.Fprintf(c.w, "Files %s and %s differ\n", a, b)
+		return errDiffer
+	}
+	if !c.text && (isBinary(da) || isBinary(db)) {
+		fmt.Fprintf(c.w, "Binary files %s and %s differ\n", a, b)
+		return errDiffer
+	}
+	la, lb := splitLines(da), splitLines(db)
+	edits := diffLines(la, lb)
+	if c.unified {
+		fmt.Fprintf(c.w, "--- %s\n+++ %s\n", header(a, fa), header(b, fb))
+		c.printUnified(la, lb, edits)
+	} else {
+		c.printNormal(la, lb, edits)
+	}
+	return errDiffer
+}
+
+func (c *cmd) excluded(name string) bool {
+	for _, p := range c.exclude {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func names(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var n []string
+	for _, e := range entries {
+		n = append(n, e.Name())
+	}
+	return n, nil
+}
+
+// kind names the type of a file as diff reports it.
+func kind(fi os.FileInfo) string {
+	switch {
+	case fi.IsDir():
+		return "directory"
+	case fi.Mode().IsRegular():
+		return "regular file"
+	case fi.Mode()&os.ModeSymlink != 0:
+		return "symbolic link"
+	default:
+		return "special file"
+	}
+}
+
+// diffDirs compares the directories a and b. Errors with single files are
+// reported and the comparison goes on.
+func (c *cmd) diffDirs(a, b string) error {
+	na, err := names(a)
+	if err != nil {
+		return err
+	}
+	nb, err := names(b)
+	if err != nil {
+		return err
+	}
+	all := append(slices.Clone(na), nb...)
+	slices.Sort(all)
+	all = slices.Compact(all)
+
+	var result error
+	differ := func(err error) {
+		switch {
+		case err == nil:
+		case errors.Is(err, errDiffer):
+			if result == nil {
+				result = errDiffer
+			}
+		default:
+			log.Print(err)
+			result = errFailed
+		}
+	}
+	for _, n := range all {
+		if c.excluded(n) {
+			continue
+		}
+		pa, pb := filepath.Join(a, n), filepath.Join(b, n)
+		if !slices.Contains(na, n) {
+			fmt.Fprintf(c.w, "Only in %s: %s\n", b, n)
+			differ(errDiffer)
+			continue
+		}
+		if !slices.Contains(nb, n) {
+			fmt.Fprintf(c.w, "Only in %s: %s\n", a, n)
+			differ(errDiffer)
+			continue
+		}
+		fa, err := os.Stat(pa)
+		if err != nil {
+			differ(err)
+			continue
+		}
+		fb, err := os.Stat(pb)
+		if err != nil {
+			differ(err)
+			continue
+		}
+		switch {
+		case fa.IsDir() && fb.IsDir():
+			if c.recurse {
+				differ(c.diffDirs(pa, pb))
+			} else {
+				fmt.Fprintf(c.w, "Common subdirectories: %s and %s\n", pa, pb)
+			}
+		case fa.Mode().IsRegular() && fb.Mode().IsRegular():
+			var out bytes.Buffer
+			sub := *c
+			sub.w = &out
+			err := sub.diffFiles(pa, pb, fa, fb)
+			if errors.Is(err, errDiffer) && !c.brief && !strings.HasPrefix(out.String(), "Binary files") {
+				fmt.Fprintf(c.w, "diff %s%s %s\n", c.opts, pa, pb)
+			}
+			c.w.Write(out.Bytes())
+			differ(err)
+		default:
+			fmt.Fprintf(c.w, "File %s is a %s while file %s is a %s\n", pa, kind(fa), pb, kind(fb))
+			differ(errDiffer)
+		}
+	}
+	return result
+}
+
+func (c *cmd) run(a, b string) error {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return err
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fa.IsDir() && fb.IsDir():
+		return c.diffDirs(a, b)
+	case fa.IsDir():
+		a = filepath.Join(a, filepath.Base(b))
+		if fa, err = os.Stat(a); err != nil {
+			return err
+		}
+	case fb.IsDir():
+		b = filepath.Join(b, filepath.Base(a))
+		if fb, err = os.Stat(b); err != nil {
+			return err
+		}
+	}
+	return c.diffFiles(a, b, fa, fb)
+}
+
+func command(w io.Writer, args []string) (*cmd, []string, error) {
+	c := &cmd{w: w, context: -1}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	unified := f.Bool("u", false, "Print a unified diff with 3 lines of context")
+	f.IntVar(&c.context, "U", -1, "Print a unified diff with N lines of context")
+	f.BoolVar(&c.brief, "q", false, "Only report whether files differ")
+	f.BoolVar(&c.text, "a", false, "Treat all files as text")
+	f.BoolVar(&c.recurse, "r", false, "Compare subdirectories recursively")
+	f.Var(&c.exclude, "x", "Skip files whose name matches the shell PATTERN")
+	if err := f.Parse(args[1:]); err != nil || f.NArg() != 2 {
+		return nil, nil, errUsage
+	}
+	if *unified && c.context < 0 {
+		c.context = 3
+	}
+	c.unified = c.context >= 0
+	if opts := args[1 : len(args)-2]; len(opts) > 0 {
+		c.opts = strings.Join(opts, " ") + " "
+	}
+	return c, f.Args(), nil
+}
+
+func main() {
+	c, files, err := command(os.Stdout, os.Args)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	switch err := c.run(files[0], files[1]); {
+	case err == nil:
+	case errors.Is(err, errDiffer):
+		os.Exit(1)
+	default:
+		log.Print(err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"", "abc"},
+		{"abc", ""},
+		{"abcabba", "cbabac"},
+		{"xaxbxc", "abc"},
+		{"abcdefg", "abxdeyg"},
+	} {
+		a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+		edits := diffLines(a, b)
+		// Applying the script to a must give b, and it must be no
+		// longer than the one of an LCS.
+		var got []string
+		changes := 0
+		for _, e := range edits {
+			switch e.op {
+			case opEq:
+				if a[e.a] != b[e.b] {
+					t.Errorf("%q -> %q: %v is not equal", tt.a, tt.b, e)
+				}
+				got = append(got, a[e.a])
+			case opIns:
+				got = append(got, b[e.b])
+				changes++
+			case opDel:
+				changes++
+			}
+		}
+		if strings.Join(got, "") != tt.b {
+			t.Errorf("%q -> %q: script gives %q", tt.a, tt.b, strings.Join(got, ""))
+		}
+		if tt.a == "abcabba" && changes != 5 {
+			t.Errorf("%q -> %q: %d changes, want 5", tt.a, tt.b, changes)
+		}
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	write(t, a, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n")
+	write(t, b, "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n13")
+	bin := filepath.Join(dir, "bin")
+	write(t, bin, "1\n\x00\n")
+	fa, _ := os.Stat(a)
+	fb, _ := os.Stat(b)
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{
+		{
+			name: "normal",
+			args: []string{a, b},
+			want: "3c3\n< 3\n---\n> three\n11d10\n< 11\n12a12\n> 13\n\\ No newline at end of file\n",
+			err:  errDiffer,
+		},
+		{
+			name: "unified",
+			args: []string{"-u", a, b},
+			want: "--- " + header(a, fa) + "\n+++ " + header(b, fb) + "\n" +
+				"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+				"@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n 12\n+13\n\\ No newline at end of file\n",
+			err: errDiffer,
+		},
+		{
+			name: "merged hunks",
+			args: []string{"-U", "4", a, b},
+			want: "--- " + header(a, fa) + "\n+++ " + header(b, fb) + "\n" +
+				"@@ -1,12 +1,12 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n 8\n 9\n 10\n-11\n 12\n+13\n\\ No newline at end of file\n",
+			err: errDiffer,
+		},
+		{
+			name: "no context",
+			args: []string{"-U", "0", a, b},
+			want: "--- " + header(a, fa) + "\n+++ " + header(b, fb) + "\n" +
+				"@@ -3 +3 @@\n-3\n+three\n@@ -11 +10,0 @@\n-11\n@@ -12,0 +12 @@\n+13\n\\ No newline at end of file\n",
+			err: errDiffer,
+		},
+		{name: "brief", args: []string{"-q", a, b}, want: "Files " + a + " and " + b + " differ\n", err: errDiffer},
+		{name: "same", args: []string{a, a}},
+		{name: "binary", args: []string{a, bin}, want: "Binary files " + a + " and " + bin + " differ\n", err: errDiffer},
+		{name: "binary as text", args: []string{"-a", a, bin}, want: "2,12c2\n< 2\n< 3\n< 4\n< 5\n< 6\n< 7\n< 8\n< 9\n< 10\n< 11\n< 12\n---\n> \x00\n", err: errDiffer},
+		{name: "missing", args: []string{a, filepath.Join(dir, "c")}, err: os.ErrNotExist},
+		{name: "one file", args: []string{a}, err: errUsage},
+	} {
+		var out bytes.Buffer
+		c, files, err := command(&out, append([]string{"diff"}, tt.args...))
+		if err == nil {
+			err = c.run(files[0], files[1])
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, out.String(), tt.want)
+		}
+	}
+}
+
+func TestDirs(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	write(t, filepath.Join(a, "etc", "hosts"), "127.0.0.1 localhost\n")
+	write(t, filepath.Join(b, "etc", "hosts"), "127.0.0.1 localhost\n10.0.0.1 server\n")
+	write(t, filepath.Join(a, "etc", "same"), "x\n")
+	write(t, filepath.Join(b, "etc", "same"), "x\n")
+	write(t, filepath.Join(a, "only"), "")
+	write(t, filepath.Join(b, "cache", "x"), "")
+	write(t, filepath.Join(a, "kind"), "")
+	write(t, filepath.Join(b, "kind", "x"), "")
+	write(t, filepath.Join(a, "bin"), "\x00a")
+	write(t, filepath.Join(b, "bin"), "\x00b")
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "flat",
+			args: []string{a, b},
+			want: "Binary files " + a + "/bin and " + b + "/bin differ\n" +
+				"Only in " + b + ": cache\n" +
+				"Common subdirectories: " + a + "/etc and " + b + "/etc\n" +
+				"File " + a + "/kind is a regular file while file " + b + "/kind is a directory\n" +
+				"Only in " + a + ": only\n",
+		},
+		{
+			name: "recursive with excludes",
+			args: []string{"-r", "-x", "cache", "-x", "k*", a, b},
+			want: "Binary files " + a + "/bin and " + b + "/bin differ\n" +
+				"diff -r -x cache -x k* " + a + "/etc/hosts " + b + "/etc/hosts\n" +
+				"1a2\n> 10.0.0.1 server\n" +
+				"Only in " + a + ": only\n",
+		},
+		{
+			name: "file in directory",
+			args: []string{filepath.Join(a, "etc", "hosts"), b + "/etc"},
+			want: "1a2\n> 10.0.0.1 server\n",
+		},
+	} {
+		var out bytes.Buffer
+		c, files, err := command(&out, append([]string{"diff"}, tt.args...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.run(files[0], files[1]); !errors.Is(err, errDiffer) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, errDiffer)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+}
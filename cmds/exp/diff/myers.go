@@ -0,0 +1,107 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "slices"
+
+type op int
+
+const (
+	opEq op = iota
+	opDel
+	opIns
+)
+
+// edit is a step of an edit script from a to b. a and b are the numbers
+// of lines of a and b before it.
+type edit struct {
+	op   op
+	a, b int
+}
+
+// diffLines returns the shortest edit script from a to b, computed with
+// Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	// Lines at the start and end which are the same are common in
+	// configs and cost the algorithm nothing, but take memory in the
+	// trace.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var edits []edit
+	for i := 0; i < pre; i++ {
+		edits = append(edits, edit{opEq, i, i})
+	}
+	for _, e := range myers(a[pre:len(a)-suf], b[pre:len(b)-suf]) {
+		edits = append(edits, edit{e.op, e.a + pre, e.b + pre})
+	}
+	for i := 0; i < suf; i++ {
+		edits = append(edits, edit{opEq, len(a) - suf + i, len(b) - suf + i})
+	}
+	return edits
+}
+
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	x, y := 0, 0
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y = x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []edit
+	x, y = n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{opEq, x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if prevK == k+1 {
+			edits = append(edits, edit{opIns, prevX, prevY})
+		} else {
+			edits = append(edits, edit{opDel, prevX, prevY})
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(edits)
+	return edits
+}
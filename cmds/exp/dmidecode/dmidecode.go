@@ -5,6 +5,9 @@
 package main
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -20,6 +23,7 @@ var (
 	flagDumpBin  string
 	flagFromDump string
 	flagType     []string
+	flagJSON     bool
 	// NB: When adding flags, update resetFlags in dmidecode_test.
 )
 
@@ -35,6 +39,23 @@ var typeGroups = map[string][]uint8{
 	"slot":      {9},
 }
 
+// jsonOutput is what --json prints.
+type jsonOutput struct {
+	Version string      `json:"version"`
+	Tables  []jsonTable `json:"tables"`
+}
+
+// jsonTable is a table in --json output. Decoded tables have Fields, others
+// the raw Data and Strings.
+type jsonTable struct {
+	Handle  uint16       `json:"handle"`
+	Type    uint8        `json:"type"`
+	Name    string       `json:"name"`
+	Fields  fmt.Stringer `json:"fields,omitempty"`
+	Data    string       `json:"data,omitempty"`
+	Strings []string     `json:"strings,omitempty"`
+}
+
 type dmiDecodeError struct {
 	error
 	code int
@@ -100,8 +121,13 @@ func dmiDecode(textOut io.Writer) *dmiDecodeError {
 	if err != nil {
 		return &dmiDecodeError{code: 2, error: fmt.Errorf("invalid --type: %v", err)}
 	}
-	fmt.Fprintf(textOut, "# dmidecode-go\n") // TODO: version.
-	entryData, tableData, err := getData(textOut, flagFromDump, "/sys/firmware/dmi/tables")
+	// Only the JSON document goes to the output with --json.
+	infoOut := textOut
+	if flagJSON {
+		infoOut = io.Discard
+	}
+	fmt.Fprintf(infoOut, "# dmidecode-go\n") // TODO: version.
+	entryData, tableData, err := getData(infoOut, flagFromDump, "/sys/firmware/dmi/tables")
 	if err != nil {
 		return &dmiDecodeError{code: 1, error: fmt.Errorf("error parsing loading data: %v", err)}
 	}
@@ -112,6 +138,9 @@ func dmiDecode(textOut io.Writer) *dmiDecodeError {
 	if err != nil {
 		return &dmiDecodeError{code: 1, error: fmt.Errorf("error parsing data: %v", err)}
 	}
+	if flagJSON {
+		return dmiDecodeJSON(textOut, si, typeFilter)
+	}
 	if si.Entry64 != nil {
 		fmt.Fprintf(textOut, "SMBIOS %d.%d.%d present.\n", si.MajorVersion(), si.MinorVersion(), si.DocRev())
 	} else {
@@ -138,9 +167,39 @@ func dmiDecode(textOut io.Writer) *dmiDecodeError {
 	return nil
 }
 
+func dmiDecodeJSON(out io.Writer, si *smbios.Info, typeFilter map[smbios.TableType]bool) *dmiDecodeError {
+	o := jsonOutput{Version: fmt.Sprintf("%d.%d.%d", si.MajorVersion(), si.MinorVersion(), si.DocRev())}
+	for _, t := range si.Tables {
+		if len(typeFilter) != 0 && !typeFilter[t.Type] {
+			continue
+		}
+		jt := jsonTable{Handle: t.Handle, Type: uint8(t.Type), Name: t.Type.String()}
+		pt, err := smbios.ParseTypedTable(t)
+		switch {
+		case err == nil:
+			jt.Fields = pt
+		default:
+			if !errors.Is(err, smbios.ErrUnsupportedTableType) {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+			data, _ := t.GetBytesAt(0, t.Len())
+			jt.Data = hex.EncodeToString(data)
+			jt.Strings = t.Strings()
+		}
+		o.Tables = append(o.Tables, jt)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(o); err != nil {
+		return &dmiDecodeError{code: 1, error: fmt.Errorf("error encoding JSON: %w", err)}
+	}
+	return nil
+}
+
 func init() {
 	flag.StringVar(&flagDumpBin, "dump-bin", "", `Do not decode the entries, instead dump the DMI data to a file in binary form. The generated file is suitable to pass to --from-dump later.`)
 	flag.StringVar(&flagFromDump, "from-dump", "", `Read the DMI data from a binary file previously generated using --dump-bin.`)
+	flag.BoolVar(&flagJSON, "json", false, `Output the entries as JSON, with the fields of decoded entries and the raw data of others.`)
 
 	flag.Var((*unixflag.StringSlice)(&flagType), "type", `Only  display  the  entries of type TYPE. TYPE can be either a DMI type number, or a comma-separated list of type numbers, or a keyword from the following list: bios, system, baseboard, chassis, processor, memory, cache, connector, slot. If this option is used more than once, the set of displayed entries will be the union of all the given types. If TYPE is not provided or not valid, a list of all valid keywords is printed and dmidecode exits with an error.`)
 	flag.Var((*unixflag.StringSlice)(&flagType), "t", `Only  display  the  entries of type TYPE. TYPE can be either a DMI type number, or a comma-separated list of type numbers, or a keyword from the following list: bios, system, baseboard, chassis, processor, memory, cache, connector, slot. If this option is used more than once, the set of displayed entries will be the union of all the given types. If TYPE is not provided or not valid, a list of all valid keywords is printed and dmidecode exits with an error (shorthand).`)
@@ -7,6 +7,7 @@ package main
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -23,6 +24,7 @@ const (
 func resetFlags() {
 	flagFromDump = ""
 	flagType = nil
+	flagJSON = false
 }
 
 func testOutput(t *testing.T, dumpFile string, args []string, expectedOutFile string) {
@@ -67,6 +69,46 @@ func TestDMIDecodeTypeFilters(t *testing.T) {
 	testOutput(t, "testdata/Asus-UX307LA.bin", []string{"-t", "1,131"}, "testdata/Asus-UX307LA.1_131.txt")
 }
 
+func TestDMIDecodeJSON(t *testing.T) {
+	os.Args = []string{os.Args[0], "--from-dump", "testdata/Lenovo-ThinkPad-T480.bin", "--json", "-t", "22,136"}
+	flag.Parse()
+	defer resetFlags()
+	out := &bytes.Buffer{}
+	if err := dmiDecode(out); err != nil {
+		t.Fatalf("dmiDecode: %v", err)
+	}
+	var got struct {
+		Version string
+		Tables  []struct {
+			Handle  uint16
+			Type    uint8
+			Name    string
+			Fields  map[string]any
+			Data    string
+			Strings []string
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if got.Version != "3.0.0" {
+		t.Errorf("version = %q, want 3.0.0", got.Version)
+	}
+	if len(got.Tables) != 2 {
+		t.Fatalf("got %d tables, want 2:\n%s", len(got.Tables), out)
+	}
+	battery, oem := got.Tables[0], got.Tables[1]
+	if battery.Handle != 0x24 || battery.Type != 22 || battery.Name != "Portable Battery" {
+		t.Errorf("table 0 = %#x, type %d, %q, want 0x24, type 22, Portable Battery", battery.Handle, battery.Type, battery.Name)
+	}
+	if m := battery.Fields["Manufacturer"]; m != "LGC" {
+		t.Errorf("battery manufacturer = %v, want LGC", m)
+	}
+	if oem.Type != 136 || oem.Fields != nil || oem.Data == "" {
+		t.Errorf("OEM table = %+v, want raw data of type 136", oem)
+	}
+}
+
 func testDumpBin(t *testing.T, entryData, expectedOutData []byte) {
 	tmpfile, err := os.CreateTemp("", "dmidecode")
 	if err != nil {
@@ -0,0 +1,251 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dmsetup manages device-mapper devices.
+//
+// Synopsis:
+//
+//	dmsetup create NAME [-u UUID] [-r] [--table TABLE | FILE]
+//	dmsetup remove NAME...
+//	dmsetup table [NAME...]
+//	dmsetup status [NAME...]
+//	dmsetup ls
+//
+// Description:
+//
+//	create creates the device NAME with the table given by --table, read
+//	from FILE, or read from stdin. Tables have one target per line:
+//
+//	  START LENGTH TYPE PARAMS...
+//
+//	with START and LENGTH in 512 byte sectors. Any target type the
+//	kernel supports can be used, e.g.:
+//
+//	  0 2048 linear /dev/sda1 0
+//	  0 2048 crypt aes-xts-plain64 KEY 0 /dev/sda2 4096
+//	  0 2048 verity 1 /dev/sda3 /dev/sda4 4096 4096 256 1 sha256 ROOTHASH SALT
+//
+//	Tables containing crypt targets are wiped from kernel buffers once
+//	loaded. The new device appears as /dev/dm-N.
+//
+//	table and status print the tables or target status of the given
+//	devices, or of all devices, prefixed by the device name.
+//
+// Options:
+//
+//	-u, --uuid:      UUID of the new device
+//	-r, --readonly:  create a read-only device
+//	--table:         the table, with lines separated by ; or newlines
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var errUsage = errors.New("usage: dmsetup create NAME [-u UUID] [-r] [--table TABLE | FILE] | remove NAME... | table [NAME...] | status [NAME...] | ls")
+
+type cmd struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	op             string
+	uuid           string
+	readOnly       bool
+	table          string
+	args           []string
+
+	// Replaced in tests.
+	create func(name string, targets []dm.Target, opts dm.CreateOpts) (*dm.Device, error)
+	remove func(name string) error
+	query  map[string]func(name string) ([]dm.Target, error)
+	list   func() ([]dm.Device, error)
+}
+
+func (c *cmd) createDevice() error {
+	var r io.Reader
+	switch {
+	case c.table != "":
+		r = strings.NewReader(strings.ReplaceAll(c.table, ";", "\n"))
+	case len(c.args) == 2:
+		f, err := os.Open(c.args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	default:
+		r = c.stdin
+	}
+	targets, err := dm.ParseTable(r)
+	if err != nil {
+		return err
+	}
+	opts := dm.CreateOpts{UUID: c.uuid, ReadOnly: c.readOnly}
+	for _, t := range targets {
+		if t.Type == "crypt" {
+			opts.SecureData = true
+		}
+	}
+	d, err := c.create(c.args[0], targets, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "%s created as %s\n", d.Name, d.DevicePath())
+	return nil
+}
+
+// names returns the devices given on the command line, or all devices.
+func (c *cmd) names() ([]string, bool, error) {
+	if len(c.args) != 0 {
+		return c.args, len(c.args) > 1, nil
+	}
+	devs, err := c.list()
+	if err != nil {
+		return nil, false, err
+	}
+	var names []string
+	for _, d := range devs {
+		names = append(names, d.Name)
+	}
+	return names, true, nil
+}
+
+func (c *cmd) show() error {
+	names, prefix, err := c.names()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(c.stdout, "No devices found")
+		return nil
+	}
+	var errs error
+	for _, name := range names {
+		targets, err := c.query[c.op](name)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for _, t := range targets {
+			if prefix {
+				fmt.Fprintf(c.stdout, "%s: ", name)
+			}
+			fmt.Fprintln(c.stdout, strings.TrimSpace(t.String()))
+		}
+	}
+	return errs
+}
+
+func (c *cmd) ls() error {
+	devs, err := c.list()
+	if err != nil {
+		return err
+	}
+	if len(devs) == 0 {
+		fmt.Fprintln(c.stdout, "No devices found")
+	}
+	for _, d := range devs {
+		fmt.Fprintf(c.stdout, "%s\t(%d:%d)\n", d.Name, d.Major, d.Minor)
+	}
+	return nil
+}
+
+func (c *cmd) run() error {
+	switch c.op {
+	case "create":
+		return c.createDevice()
+	case "remove":
+		var errs error
+		for _, name := range c.args {
+			errs = errors.Join(errs, c.remove(name))
+		}
+		return errs
+	case "ls":
+		return c.ls()
+	}
+	return c.show()
+}
+
+// parse parses flags anywhere among args, since device names usually come
+// first.
+func parse(f *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := f.Parse(unixflag.ArgsToGoArgs(args)); err != nil {
+			return nil, err
+		}
+		args = f.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+func command(stdin io.Reader, stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		create: dm.Create,
+		remove: dm.Remove,
+		query:  map[string]func(string) ([]dm.Target, error){"table": dm.Table, "status": dm.Status},
+		list:   dm.List,
+	}
+	if len(args) < 2 {
+		return nil, errUsage
+	}
+	c.op = args[1]
+	f := flag.NewFlagSet(c.op, flag.ContinueOnError)
+	f.SetOutput(stderr)
+	if c.op == "create" {
+		f.StringVar(&c.uuid, "u", "", "UUID of the new device")
+		f.StringVar(&c.uuid, "uuid", "", "UUID of the new device")
+		f.BoolVar(&c.readOnly, "r", false, "create a read-only device")
+		f.BoolVar(&c.readOnly, "readonly", false, "create a read-only device")
+		f.StringVar(&c.table, "table", "", "table of the new device")
+	}
+	var err error
+	if c.args, err = parse(f, args[2:]); err != nil {
+		return nil, err
+	}
+
+	switch c.op {
+	case "create":
+		if len(c.args) == 0 || len(c.args) > 2 || len(c.args) == 2 && c.table != "" {
+			return nil, errUsage
+		}
+	case "remove":
+		if len(c.args) == 0 {
+			return nil, errUsage
+		}
+	case "ls":
+		if len(c.args) != 0 {
+			return nil, errUsage
+		}
+	case "table", "status":
+	default:
+		return nil, errUsage
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdin, os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+type fakeDM struct {
+	tables map[string][]dm.Target
+	opts   map[string]dm.CreateOpts
+}
+
+func newFakeDM() *fakeDM {
+	return &fakeDM{
+		tables: map[string][]dm.Target{
+			"root": {{Length: 2048, Type: "verity", Params: "1 8:3 8:4 4096 4096 256 1 sha256 ab -"}},
+			"swap": {{Length: 100, Type: "linear", Params: "8:1 0"}, {Start: 100, Length: 8, Type: "zero"}},
+		},
+		opts: map[string]dm.CreateOpts{},
+	}
+}
+
+func (f *fakeDM) install(c *cmd) {
+	c.create = func(name string, targets []dm.Target, opts dm.CreateOpts) (*dm.Device, error) {
+		if _, ok := f.tables[name]; ok {
+			return nil, fmt.Errorf("creating %s: exists", name)
+		}
+		f.tables[name], f.opts[name] = targets, opts
+		return &dm.Device{Name: name, Major: 253, Minor: 7}, nil
+	}
+	c.remove = func(name string) error {
+		if _, ok := f.tables[name]; !ok {
+			return fmt.Errorf("removing %s: %w", name, os.ErrNotExist)
+		}
+		delete(f.tables, name)
+		return nil
+	}
+	get := func(name string) ([]dm.Target, error) {
+		t, ok := f.tables[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return t, nil
+	}
+	c.query = map[string]func(string) ([]dm.Target, error){
+		"table": get,
+		"status": func(name string) ([]dm.Target, error) {
+			t, err := get(name)
+			if err != nil || t[0].Type != "verity" {
+				return t, err
+			}
+			return []dm.Target{{Length: t[0].Length, Type: "verity", Params: "V"}}, nil
+		},
+	}
+	c.list = func() ([]dm.Device, error) {
+		var devs []dm.Device
+		for _, n := range []string{"root", "swap", "new"} {
+			if _, ok := f.tables[n]; ok {
+				devs = append(devs, dm.Device{Name: n, Major: 253, Minor: uint32(len(devs))})
+			}
+		}
+		return devs, nil
+	}
+}
+
+func run(t *testing.T, f *fakeDM, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c, err := command(strings.NewReader(stdin), &stdout, &stderr, append([]string{"dmsetup"}, args...))
+	if err != nil {
+		return "", err
+	}
+	f.install(c)
+	err = c.run()
+	return stdout.String(), err
+}
+
+func TestShow(t *testing.T) {
+	f := newFakeDM()
+	for _, tt := range []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{args: []string{"table", "swap"}, want: "0 100 linear 8:1 0\n100 8 zero\n"},
+		{args: []string{"table"}, want: "root: 0 2048 verity 1 8:3 8:4 4096 4096 256 1 sha256 ab -\nswap: 0 100 linear 8:1 0\nswap: 100 8 zero\n"},
+		{args: []string{"status", "root"}, want: "0 2048 verity V\n"},
+		{args: []string{"status", "root", "gone"}, want: "root: 0 2048 verity V\n", err: os.ErrNotExist},
+		{args: []string{"ls"}, want: "root\t(253:0)\nswap\t(253:1)\n"},
+	} {
+		got, err := run(t, f, "", tt.args...)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("dmsetup %q = %v, want %v", tt.args, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("dmsetup %q printed %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCreateRemove(t *testing.T) {
+	tableFile := filepath.Join(t.TempDir(), "table")
+	if err := os.WriteFile(tableFile, []byte("0 8 crypt aes-xts-plain64 00ff 0 /dev/sda2 4096\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args  []string
+		stdin string
+		want  []dm.Target
+		opts  dm.CreateOpts
+	}{
+		{
+			args: []string{"create", "new", "--table", "0 8 linear /dev/sda1 0;8 8 zero", "-r", "-u", "TEST-1"},
+			want: []dm.Target{
+				{Length: 8, Type: "linear", Params: "/dev/sda1 0"},
+				{Start: 8, Length: 8, Type: "zero"},
+			},
+			opts: dm.CreateOpts{UUID: "TEST-1", ReadOnly: true},
+		},
+		{
+			args: []string{"create", "new", tableFile},
+			want: []dm.Target{{Length: 8, Type: "crypt", Params: "aes-xts-plain64 00ff 0 /dev/sda2 4096"}},
+			opts: dm.CreateOpts{SecureData: true},
+		},
+		{
+			args:  []string{"create", "--readonly", "new"},
+			stdin: "0 16 linear /dev/sdb 0\n",
+			want:  []dm.Target{{Length: 16, Type: "linear", Params: "/dev/sdb 0"}},
+			opts:  dm.CreateOpts{ReadOnly: true},
+		},
+	} {
+		f := newFakeDM()
+		if _, err := run(t, f, tt.stdin, tt.args...); err != nil {
+			t.Fatalf("dmsetup %q = %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(f.tables["new"], tt.want) || f.opts["new"] != tt.opts {
+			t.Errorf("dmsetup %q created %+v with %+v, want %+v with %+v", tt.args, f.tables["new"], f.opts["new"], tt.want, tt.opts)
+		}
+		if _, err := run(t, f, "", "remove", "new"); err != nil {
+			t.Errorf("dmsetup remove new = %v", err)
+		}
+		if _, ok := f.tables["new"]; ok {
+			t.Errorf("dmsetup remove new left the device")
+		}
+	}
+
+	f := newFakeDM()
+	if _, err := run(t, f, "", "create", "bad", "--table", "0 x zero"); err == nil {
+		t.Errorf("dmsetup create with bad table succeeded")
+	}
+	if _, err := run(t, f, "", "remove", "swap", "gone"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dmsetup remove swap gone = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, ok := f.tables["swap"]; ok {
+		t.Errorf("dmsetup remove swap gone did not remove swap")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"dmsetup"},
+		{"dmsetup", "suspend", "root"},
+		{"dmsetup", "create"},
+		{"dmsetup", "create", "a", "file", "--table", "0 1 zero"},
+		{"dmsetup", "remove"},
+		{"dmsetup", "ls", "root"},
+	} {
+		if _, err := command(nil, &bytes.Buffer{}, &bytes.Buffer{}, args); !errors.Is(err, errUsage) {
+			t.Errorf("command(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// efibootmgr manipulates the UEFI boot manager variables.
+//
+// Synopsis:
+//
+//	efibootmgr [-v]
+//	efibootmgr -c -d DISK [-p PART] -l LOADER [-L LABEL] [-b XXXX] [ARGS...]
+//	efibootmgr -b XXXX [-B | -a | -A]
+//	efibootmgr [-o XXXX,YYYY,...] [-n XXXX | -N]
+//
+// Description:
+//
+//	Without options, efibootmgr lists BootCurrent, BootNext, BootOrder and
+//	all Boot#### entries. Entries marked with '*' are active.
+//
+//	-c creates a new boot entry for LOADER on partition PART of the GPT
+//	formatted DISK and puts it first in BootOrder. Remaining arguments are
+//	passed to the loader as UCS-2 encoded optional data.
+//
+// Options:
+//
+//	-a, --active:          set boot entry XXXX active
+//	-A, --inactive:        set boot entry XXXX inactive
+//	-b, --bootnum:         boot entry number XXXX (hex)
+//	-B, --delete-bootnum:  delete boot entry XXXX
+//	-c, --create:          create a new boot entry
+//	-d, --disk:            disk containing the loader (default /dev/sda)
+//	-l, --loader:          loader path on the EFI system partition
+//	-L, --label:           boot entry description (default Linux)
+//	-n, --bootnext:        set BootNext to XXXX
+//	-N, --delete-bootnext: delete BootNext
+//	-o, --bootorder:       set BootOrder, a comma-separated list of XXXX
+//	-p, --part:            partition number of the loader (default 1)
+//	-v, --verbose:         print device paths and optional data
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/uefivars/boot"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var errUsage = errors.New("usage: efibootmgr [-acvAB] [-b XXXX] [-d disk] [-p part] [-l loader] [-L label] [-o order] [-n XXXX | -N] [args...]")
+
+type cmd struct {
+	e   efivarfs.EFIVar
+	out io.Writer
+
+	active         bool
+	inactive       bool
+	bootnum        string
+	deleteBootnum  bool
+	create         bool
+	disk           string
+	loader         string
+	label          string
+	bootnext       string
+	deleteBootnext bool
+	bootorder      string
+	part           uint
+	verbose        bool
+	args           []string
+}
+
+func parseFlags(args []string) (*cmd, error) {
+	c := &cmd{}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.BoolVar(&c.active, "active", false, "set boot entry active")
+	f.BoolVar(&c.active, "a", false, "set boot entry active (shorthand)")
+	f.BoolVar(&c.inactive, "inactive", false, "set boot entry inactive")
+	f.BoolVar(&c.inactive, "A", false, "set boot entry inactive (shorthand)")
+	f.StringVar(&c.bootnum, "bootnum", "", "boot entry number (hex)")
+	f.StringVar(&c.bootnum, "b", "", "boot entry number (hex) (shorthand)")
+	f.BoolVar(&c.deleteBootnum, "delete-bootnum", false, "delete boot entry")
+	f.BoolVar(&c.deleteBootnum, "B", false, "delete boot entry (shorthand)")
+	f.BoolVar(&c.create, "create", false, "create a new boot entry")
+	f.BoolVar(&c.create, "c", false, "create a new boot entry (shorthand)")
+	f.StringVar(&c.disk, "disk", "/dev/sda", "disk containing the loader")
+	f.StringVar(&c.disk, "d", "/dev/sda", "disk containing the loader (shorthand)")
+	f.StringVar(&c.loader, "loader", "", "loader path on the EFI system partition")
+	f.StringVar(&c.loader, "l", "", "loader path on the EFI system partition (shorthand)")
+	f.StringVar(&c.label, "label", "Linux", "boot entry description")
+	f.StringVar(&c.label, "L", "Linux", "boot entry description (shorthand)")
+	f.StringVar(&c.bootnext, "bootnext", "", "set BootNext")
+	f.StringVar(&c.bootnext, "n", "", "set BootNext (shorthand)")
+	f.BoolVar(&c.deleteBootnext, "delete-bootnext", false, "delete BootNext")
+	f.BoolVar(&c.deleteBootnext, "N", false, "delete BootNext (shorthand)")
+	f.StringVar(&c.bootorder, "bootorder", "", "set BootOrder (comma-separated)")
+	f.StringVar(&c.bootorder, "o", "", "set BootOrder (comma-separated) (shorthand)")
+	f.UintVar(&c.part, "part", 1, "partition number of the loader")
+	f.UintVar(&c.part, "p", 1, "partition number of the loader (shorthand)")
+	f.BoolVar(&c.verbose, "verbose", false, "print device paths and optional data")
+	f.BoolVar(&c.verbose, "v", false, "print device paths and optional data (shorthand)")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return nil, err
+	}
+	c.args = f.Args()
+	return c, nil
+}
+
+func parseNum(s string) (uint16, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "boot"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid boot entry number %q: %w", s, err)
+	}
+	return uint16(n), nil
+}
+
+func (c *cmd) run() error {
+	if (c.active || c.inactive || c.deleteBootnum) && c.bootnum == "" {
+		return fmt.Errorf("-a, -A and -B require -b: %w", errUsage)
+	}
+	if c.active && c.inactive {
+		return fmt.Errorf("-a and -A are mutually exclusive: %w", errUsage)
+	}
+	if c.bootnext != "" && c.deleteBootnext {
+		return fmt.Errorf("-n and -N are mutually exclusive: %w", errUsage)
+	}
+	if len(c.args) > 0 && !c.create {
+		return errUsage
+	}
+
+	var num uint16
+	if c.bootnum != "" {
+		n, err := parseNum(c.bootnum)
+		if err != nil {
+			return err
+		}
+		num = n
+	}
+
+	switch {
+	case c.create:
+		if err := c.createEntry(c.bootnum != "", num); err != nil {
+			return err
+		}
+	case c.deleteBootnum:
+		if err := efivarfs.RemoveBootEntry(c.e, num); err != nil {
+			return fmt.Errorf("deleting %s: %w", efivarfs.BootEntryName(num), err)
+		}
+	case c.active || c.inactive:
+		o, err := efivarfs.ReadBootEntry(c.e, num)
+		if err != nil {
+			return err
+		}
+		if c.active {
+			o.Attributes |= efivarfs.LoadOptionActive
+		} else {
+			o.Attributes &^= efivarfs.LoadOptionActive
+		}
+		if err := efivarfs.WriteBootEntry(c.e, num, o); err != nil {
+			return err
+		}
+	}
+
+	if c.bootorder != "" {
+		var order []uint16
+		for _, s := range strings.Split(c.bootorder, ",") {
+			n, err := parseNum(s)
+			if err != nil {
+				return err
+			}
+			order = append(order, n)
+		}
+		if err := efivarfs.WriteBootOrder(c.e, order); err != nil {
+			return fmt.Errorf("setting BootOrder: %w", err)
+		}
+	}
+
+	if c.bootnext != "" {
+		n, err := parseNum(c.bootnext)
+		if err != nil {
+			return err
+		}
+		if err := efivarfs.WriteBootNext(c.e, n); err != nil {
+			return fmt.Errorf("setting BootNext: %w", err)
+		}
+	}
+	if c.deleteBootnext {
+		if err := efivarfs.RemoveBootNext(c.e); err != nil && !errors.Is(err, efivarfs.ErrVarNotExist) {
+			return fmt.Errorf("deleting BootNext: %w", err)
+		}
+	}
+
+	return c.list()
+}
+
+// optionalData encodes the loader arguments as a UCS-2 string, which is
+// what Linux EFI stub and most loaders expect.
+func optionalData(args []string) []byte {
+	if len(args) == 0 {
+		return nil
+	}
+	u := utf16.Encode([]rune(strings.Join(args, " ")))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+func (c *cmd) createEntry(haveNum bool, num uint16) error {
+	if c.loader == "" {
+		return fmt.Errorf("-c requires -l: %w", errUsage)
+	}
+	if c.part == 0 {
+		return fmt.Errorf("partition numbers start at 1: %w", errUsage)
+	}
+
+	d, err := os.Open(c.disk)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	pt, err := gpt.New(d)
+	if pt.Primary == nil {
+		return fmt.Errorf("reading GPT of %s: %w", c.disk, err)
+	}
+	if c.part > uint(len(pt.Primary.Parts)) {
+		return fmt.Errorf("%s has no partition %d", c.disk, c.part)
+	}
+	p := pt.Primary.Parts[c.part-1]
+	if p.FirstLBA == 0 {
+		return fmt.Errorf("partition %d of %s is unused", c.part, c.disk)
+	}
+
+	var sig [16]byte
+	binary.LittleEndian.PutUint32(sig[0:], p.UniqueGUID.L)
+	binary.LittleEndian.PutUint16(sig[4:], p.UniqueGUID.W1)
+	binary.LittleEndian.PutUint16(sig[6:], p.UniqueGUID.W2)
+	copy(sig[8:], p.UniqueGUID.B[:])
+
+	var path []byte
+	path = append(path, efivarfs.HardDriveDevicePath(uint32(c.part), p.FirstLBA, p.LastLBA-p.FirstLBA+1, sig)...)
+	path = append(path, efivarfs.FilePathDevicePath(c.loader)...)
+	path = append(path, efivarfs.EndDevicePath()...)
+
+	if !haveNum {
+		if num, err = efivarfs.FreeBootEntry(c.e); err != nil {
+			return err
+		}
+	}
+	o := &efivarfs.LoadOption{
+		Attributes:   efivarfs.LoadOptionActive,
+		Description:  c.label,
+		FilePathList: path,
+		OptionalData: optionalData(c.args),
+	}
+	if err := efivarfs.WriteBootEntry(c.e, num, o); err != nil {
+		return fmt.Errorf("writing %s: %w", efivarfs.BootEntryName(num), err)
+	}
+
+	// Like efibootmgr, put the new entry first in BootOrder.
+	order, err := efivarfs.ReadBootOrder(c.e)
+	if err != nil && !errors.Is(err, efivarfs.ErrVarNotExist) {
+		return err
+	}
+	newOrder := []uint16{num}
+	for _, n := range order {
+		if n != num {
+			newOrder = append(newOrder, n)
+		}
+	}
+	return efivarfs.WriteBootOrder(c.e, newOrder)
+}
+
+func formatOrder(order []uint16) string {
+	s := make([]string, len(order))
+	for i, n := range order {
+		s[i] = fmt.Sprintf("%04X", n)
+	}
+	return strings.Join(s, ",")
+}
+
+func (c *cmd) list() error {
+	if n, err := efivarfs.ReadBootCurrent(c.e); err == nil {
+		fmt.Fprintf(c.out, "BootCurrent: %04X\n", n)
+	}
+	if n, err := efivarfs.ReadBootNext(c.e); err == nil {
+		fmt.Fprintf(c.out, "BootNext: %04X\n", n)
+	}
+	if order, err := efivarfs.ReadBootOrder(c.e); err == nil {
+		fmt.Fprintf(c.out, "BootOrder: %s\n", formatOrder(order))
+	}
+
+	entries, err := efivarfs.ListBootEntries(c.e)
+	if err != nil {
+		return err
+	}
+	for _, n := range entries {
+		o, err := efivarfs.ReadBootEntry(c.e, n)
+		if err != nil {
+			fmt.Fprintf(c.out, "%s: %v\n", efivarfs.BootEntryName(n), err)
+			continue
+		}
+		active := " "
+		if o.Attributes&efivarfs.LoadOptionActive != 0 {
+			active = "*"
+		}
+		if !c.verbose {
+			fmt.Fprintf(c.out, "%s%s %s\n", efivarfs.BootEntryName(n), active, o.Description)
+			continue
+		}
+		path, err := boot.ParseFilePathList(o.FilePathList)
+		if err != nil {
+			fmt.Fprintf(c.out, "%s%s %s\t<unparseable device path>\n", efivarfs.BootEntryName(n), active, o.Description)
+			continue
+		}
+		fmt.Fprintf(c.out, "%s%s %s\t%s", efivarfs.BootEntryName(n), active, o.Description, path)
+		if len(o.OptionalData) > 0 {
+			fmt.Fprintf(c.out, " %x", o.OptionalData)
+		}
+		fmt.Fprintln(c.out)
+	}
+	return nil
+}
+
+func main() {
+	c, err := parseFlags(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.out = os.Stdout
+	if c.e, err = efivarfs.New(); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+type memVars map[efivarfs.VariableDescriptor][]byte
+
+func (m memVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	d, ok := m[desc]
+	if !ok {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return 0, d, nil
+}
+
+func (m memVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	m[desc] = data
+	return nil
+}
+
+func (m memVars) Remove(desc efivarfs.VariableDescriptor) error {
+	if _, ok := m[desc]; !ok {
+		return efivarfs.ErrVarNotExist
+	}
+	delete(m, desc)
+	return nil
+}
+
+func (m memVars) List() ([]efivarfs.VariableDescriptor, error) {
+	var l []efivarfs.VariableDescriptor
+	for d := range m {
+		l = append(l, d)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l, nil
+}
+
+func runArgs(t *testing.T, e efivarfs.EFIVar, args ...string) (string, error) {
+	t.Helper()
+	c, err := parseFlags(append([]string{"efibootmgr"}, args...))
+	if err != nil {
+		t.Fatalf("parseFlags(%q) = %v", args, err)
+	}
+	var out bytes.Buffer
+	c.e = e
+	c.out = &out
+	err = c.run()
+	return out.String(), err
+}
+
+func TestEfibootmgr(t *testing.T) {
+	e := memVars{}
+	disk := "../../../pkg/mount/testdata/gptdisk"
+
+	out, err := runArgs(t, e, "-c", "-d", disk, "-p", "1", "-l", "/EFI/ubuntu/shimx64.efi", "-L", "ubuntu")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.Contains(out, "Boot0000* ubuntu") || !strings.Contains(out, "BootOrder: 0000\n") {
+		t.Errorf("create output = %q, want Boot0000* ubuntu and BootOrder 0000", out)
+	}
+
+	if _, err := runArgs(t, e, "--create", "--disk", disk, "--loader", "/vmlinuz", "--label", "linux", "root=/dev/sda2"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	o, err := efivarfs.ReadBootEntry(e, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := optionalData([]string{"root=/dev/sda2"}); !bytes.Equal(o.OptionalData, want) {
+		t.Errorf("optional data = %x, want %x", o.OptionalData, want)
+	}
+	if order, _ := efivarfs.ReadBootOrder(e); !reflect.DeepEqual(order, []uint16{1, 0}) {
+		t.Errorf("BootOrder = %v, want [1 0]", order)
+	}
+
+	out, err = runArgs(t, e, "-b", "0001", "-A", "-n", "0000", "-o", "0000,0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"BootNext: 0000\n", "BootOrder: 0000,0001\n", "Boot0001  linux\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+
+	out, err = runArgs(t, e, "-v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "shimx64.efi") {
+		t.Errorf("verbose output %q does not contain the loader path", out)
+	}
+
+	out, err = runArgs(t, e, "-B", "-b", "0", "-N")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "BootOrder: 0001\nBoot0001  linux\n"; out != want {
+		t.Errorf("output after delete = %q, want %q", out, want)
+	}
+}
+
+func TestEfibootmgrErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-B"},
+		{"-a", "-A", "-b", "1"},
+		{"-n", "1", "-N"},
+		{"-c", "-d", "/dev/null"},
+		{"extra"},
+	} {
+		if _, err := runArgs(t, memVars{}, args...); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+	if _, err := runArgs(t, memVars{}, "-b", "xyz", "-a"); err == nil {
+		t.Errorf("run(-b xyz) succeeded, want error")
+	}
+}
@@ -3,13 +3,26 @@
 // license that can be found in the LICENSE file.
 
 // getty Open a TTY and invoke a shell
-// There are no special options and no login support
-// Also getty exits after starting the shell so if one exits the shell, there
-// is no more shell!
+// Without -login or -autologin, getty exits after starting the shell so if
+// one exits the shell, there is no more shell!
 //
 // Synopsis:
 //
-//	getty <port> <baud> [term]
+//	getty [-login | -autologin USER] <port> <baud> [term]
+//
+// Description:
+//
+//	With -login, getty asks for a user name and password, checks them
+//	against /etc/passwd and /etc/shadow and starts the user's shell. After
+//	failed attempts, it waits longer and longer before asking again. With
+//	-autologin, it starts the shell of the user without asking. In both
+//	cases, getty starts a new session once the shell exits.
+//
+// Options:
+//
+//	-login:     ask for user name and password
+//	-autologin: log in the user without asking
+//	-v:         verbose log
 package main
 
 import (
@@ -25,10 +38,12 @@ import (
 )
 
 var (
-	verbose = flag.Bool("v", false, "verbose log")
-	debug   = func(string, ...interface{}) {}
-	cmdList []string
-	envs    []string
+	verbose      = flag.Bool("v", false, "verbose log")
+	requireLogin = flag.Bool("login", false, "ask for user name and password")
+	autologin    = flag.String("autologin", "", "log in the user without asking")
+	debug        = func(string, ...interface{}) {}
+	cmdList      []string
+	envs         []string
 )
 
 func init() {
@@ -79,6 +94,12 @@ func main() {
 			debug("Unable to set 'TERM=%s': %v", port, err)
 		}
 	}
+	if *requireLogin || *autologin != "" {
+		g := newGetty(ttyS, port, term)
+		g.autologin = *autologin
+		log.Fatal(g.loop())
+	}
+
 	envs = os.Environ()
 	debug("envs %v", envs)
 
@@ -0,0 +1,162 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/login"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+var errLogin = errors.New("login incorrect")
+
+type getty struct {
+	in  *bufio.Reader
+	out io.Writer
+	// echo turns the echo of the terminal on or off.
+	echo func(on bool) error
+	// session runs the shell of a logged in user and waits for it.
+	session func(u *login.User) error
+	sleep   func(time.Duration)
+
+	db        *login.DB
+	lockout   *login.Lockout
+	autologin string
+}
+
+func newGetty(tty *termios.TTYIO, port, term string) *getty {
+	g := &getty{
+		in:  bufio.NewReader(tty),
+		out: tty,
+		echo: func(on bool) error {
+			t, err := tty.Get()
+			if err != nil {
+				return err
+			}
+			if on {
+				t.Lflag |= unix.ECHO
+			} else {
+				t.Lflag &^= unix.ECHO
+			}
+			return tty.Set(t)
+		},
+		sleep:   time.Sleep,
+		db:      &login.DB{Root: "/"},
+		lockout: login.NewLockout(),
+	}
+	g.session = func(u *login.User) error {
+		env := []string{}
+		if term != "" {
+			env = append(env, "TERM="+term)
+		}
+		c, err := g.db.Shell(u, env...)
+		if err != nil {
+			return err
+		}
+		// As login(1), give the user the terminal for the session.
+		dev := filepath.Join("/dev", port)
+		if err := os.Chown(dev, u.UID, u.GID); err != nil {
+			debug("chown %s: %v", dev, err)
+		}
+		defer os.Chown(dev, 0, 0)
+		tty.Ctty(c)
+		debug("running %v as %s", c, u.Name)
+		return c.Run()
+	}
+	return g
+}
+
+// readLine reads a line from the terminal, without the line ending.
+func (g *getty) readLine() (string, error) {
+	line, err := g.in.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// prompt asks for a user name and password and authenticates the user.
+func (g *getty) prompt() (*login.User, error) {
+	host, _ := os.Hostname()
+	fmt.Fprintf(g.out, "%s login: ", host)
+	name, err := g.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errLogin
+	}
+	if wait, err := g.lockout.Check(name); err != nil {
+		fmt.Fprintf(g.out, "Too many failed logins, try again in %v\n", wait.Round(time.Second))
+		return nil, err
+	}
+
+	fmt.Fprint(g.out, "Password: ")
+	if err := g.echo(false); err != nil {
+		return nil, err
+	}
+	password, err := g.readLine()
+	g.echo(true)
+	fmt.Fprintln(g.out)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := g.db.Authenticate(name, password)
+	if err != nil {
+		log.Printf("login of %q failed: %v", name, err)
+		// As login(1), do not tell why, so as not to tell which users
+		// exist.
+		g.sleep(g.lockout.Fail(name))
+		fmt.Fprintln(g.out, "Login incorrect")
+		return nil, errLogin
+	}
+	g.lockout.Reset(name)
+	return u, nil
+}
+
+// loop starts a session for every login until the terminal is closed.
+func (g *getty) loop() error {
+	for {
+		var u *login.User
+		var err error
+		if g.autologin != "" {
+			u, err = g.db.Lookup(g.autologin)
+			if err == nil {
+				// A locked password does not prevent autologin, but an
+				// expired account does.
+				if err = g.db.Usable(u); errors.Is(err, login.ErrLocked) {
+					err = nil
+				}
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			u, err = g.prompt()
+			if errors.Is(err, errLogin) || errors.Is(err, login.ErrLockedOut) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := g.session(u); err != nil {
+			log.Printf("session of %s: %v", u.Name, err)
+			// Do not spin if the shell cannot start.
+			g.sleep(time.Second)
+		}
+	}
+}
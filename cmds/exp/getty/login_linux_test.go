@@ -0,0 +1,109 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/login"
+)
+
+func testGetty(t *testing.T, input string) (*getty, *strings.Builder, *[]string, *[]time.Duration) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"passwd": "root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/sh\n",
+		// The password of alice is hunter2.
+		"shadow": "root:!:19000::::::\nalice:$6$4n0th3rs4lt$Ea9udKnbZg.0rjs5Ya8n1Ybq19t/1C6xjwMVTFmC3DDanF2.RTjSEWWXVvtA6kWq3OIbFMlnUSBaO/jXBrQD/1:19000::::::\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(root, "etc", name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &strings.Builder{}
+	var sessions []string
+	var sleeps []time.Duration
+	g := &getty{
+		in:   bufio.NewReader(strings.NewReader(input)),
+		out:  out,
+		echo: func(bool) error { return nil },
+		session: func(u *login.User) error {
+			sessions = append(sessions, u.Name)
+			return nil
+		},
+		sleep:   func(d time.Duration) { sleeps = append(sleeps, d) },
+		db:      &login.DB{Root: root},
+		lockout: &login.Lockout{Free: 1, Delay: time.Second, Max: time.Minute},
+	}
+	return g, out, &sessions, &sleeps
+}
+
+func TestLogin(t *testing.T) {
+	g, out, sessions, sleeps := testGetty(t, "alice\nhunter3\nroot\n\nalice\r\nhunter2\r\nmallory\nx\n")
+	if err := g.loop(); !errors.Is(err, io.EOF) {
+		t.Errorf("loop = %v, want %v", err, io.EOF)
+	}
+	if want := []string{"alice"}; !reflect.DeepEqual(*sessions, want) {
+		t.Errorf("sessions %v, want %v", *sessions, want)
+	}
+	// The second failure for alice is not free, but the success resets
+	// it.
+	if want := []time.Duration{0, 0, 0}; !reflect.DeepEqual(*sleeps, want) {
+		t.Errorf("sleeps %v, want %v", *sleeps, want)
+	}
+	if n := strings.Count(out.String(), "Login incorrect\n"); n != 3 {
+		t.Errorf("%d incorrect logins, want 3:\n%s", n, out)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	g, out, sessions, sleeps := testGetty(t, "alice\na\nalice\nb\nalice\nalice\n")
+	if err := g.loop(); !errors.Is(err, io.EOF) {
+		t.Errorf("loop = %v, want %v", err, io.EOF)
+	}
+	if len(*sessions) != 0 {
+		t.Errorf("sessions %v", *sessions)
+	}
+	if want := []time.Duration{0, time.Second}; !reflect.DeepEqual(*sleeps, want) {
+		t.Errorf("sleeps %v, want %v", *sleeps, want)
+	}
+	// The sleeps are not real, so alice is still locked out.
+	if !strings.Contains(out.String(), "Too many failed logins, try again in 1s\n") {
+		t.Errorf("no lockout message:\n%s", out)
+	}
+}
+
+func TestAutologin(t *testing.T) {
+	g, _, sessions, _ := testGetty(t, "")
+	calls := 0
+	g.session = func(u *login.User) error {
+		*sessions = append(*sessions, u.Name)
+		if calls++; calls == 2 {
+			// Stop the loop with a user that does not exist.
+			g.autologin = "mallory"
+		}
+		return nil
+	}
+	g.autologin = "root"
+	if err := g.loop(); !errors.Is(err, login.ErrNoUser) {
+		t.Errorf("loop = %v, want %v", err, login.ErrNoUser)
+	}
+	if want := []string{"root", "root"}; !reflect.DeepEqual(*sessions, want) {
+		t.Errorf("sessions %v, want %v", *sessions, want)
+	}
+}
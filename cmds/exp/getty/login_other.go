@@ -0,0 +1,25 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/u-root/u-root/pkg/termios"
+)
+
+type getty struct {
+	autologin string
+}
+
+func newGetty(*termios.TTYIO, string, string) *getty {
+	return &getty{}
+}
+
+func (g *getty) loop() error {
+	return errors.New("-login and -autologin are only supported on Linux")
+}
@@ -0,0 +1,318 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// iostat reports block device IO statistics.
+//
+// Synopsis:
+//
+//	iostat [-x] [-m] [-p] [-z] [-t] [-json] [device...] [delay [count]]
+//
+// Description:
+//
+//	iostat reads /proc/diskstats. The first report has the averages since
+//	boot; with a delay in seconds, more reports follow with the averages
+//	over each delay, count times or until interrupted.
+//
+//	Only disks are shown, not their partitions, unless -p is given or
+//	they are named. tps is the requests per second, and the kB columns
+//	are kilobytes per second and in all since the previous report.
+//
+//	With -x, they are instead the reads and writes, in requests, merged
+//	requests and kilobytes, per second; the average milliseconds a read
+//	or write took, queueing included; the average number of requests in
+//	flight; and the percentage of the time the device was busy.
+//
+// Options:
+//
+//	-x: show extended statistics
+//	-m: show megabytes instead of kilobytes
+//	-p: show partitions as well
+//	-z: leave out devices which did no IO since the previous report
+//	-t: print the time of each report
+//	-json: print a JSON object for each report, one per line
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+)
+
+var (
+	extended   = flag.Bool("x", false, "show extended statistics")
+	megabytes  = flag.Bool("m", false, "show megabytes instead of kilobytes")
+	partitions = flag.Bool("p", false, "show partitions as well")
+	skipIdle   = flag.Bool("z", false, "leave out devices which did no IO")
+	timestamps = flag.Bool("t", false, "print the time of each report")
+	toJSON     = flag.Bool("json", false, "print a JSON object for each report")
+)
+
+var errUsage = errors.New("usage: iostat [-x] [-m] [-p] [-z] [-t] [-json] [device...] [delay [count]]")
+
+// sample is a snapshot of /proc/diskstats.
+type sample struct {
+	at    time.Time
+	disks map[string]proc.DiskStat
+	order []string
+}
+
+// disk is the statistics of a device over a report. The sizes are in the
+// unit chosen, KB or MB.
+type disk struct {
+	Device string  `json:"device"`
+	TPS    float64 `json:"tps"`
+	ReadPS float64 `json:"read_per_sec"`
+	WrtnPS float64 `json:"written_per_sec"`
+	Read   uint64  `json:"read"`
+	Wrtn   uint64  `json:"written"`
+
+	// Extended statistics.
+	RPS       float64 `json:"r_per_sec"`
+	WPS       float64 `json:"w_per_sec"`
+	RMergedPS float64 `json:"rrqm_per_sec"`
+	WMergedPS float64 `json:"wrqm_per_sec"`
+	RAwait    float64 `json:"r_await"`
+	WAwait    float64 `json:"w_await"`
+	QueueSize float64 `json:"aqu_sz"`
+	Util      float64 `json:"util"`
+}
+
+// report is the output for one interval.
+type report struct {
+	Time  string `json:"time,omitempty"`
+	Unit  string `json:"unit"`
+	Disks []disk `json:"disks"`
+}
+
+type iostat struct {
+	fs         proc.FS
+	sys        string
+	devices    map[string]bool
+	extended   bool
+	megabytes  bool
+	partitions bool
+	skipIdle   bool
+	timestamps bool
+	json       bool
+	now        func() time.Time
+	sleep      func(time.Duration)
+}
+
+func (s *iostat) sample() (*sample, error) {
+	stats, err := s.fs.DiskStats()
+	if err != nil {
+		return nil, err
+	}
+	sa := &sample{at: s.now(), disks: map[string]proc.DiskStat{}}
+	for _, d := range stats {
+		sa.disks[d.Name] = d
+		sa.order = append(sa.order, d.Name)
+	}
+	return sa, nil
+}
+
+// boot returns a sample of zeros at boot, to average since then.
+func (s *iostat) boot(cur *sample) (*sample, error) {
+	up, err := s.fs.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	return &sample{at: cur.at.Add(-time.Duration(up * float64(time.Second)))}, nil
+}
+
+// shown returns whether a device is shown. Named devices always are;
+// otherwise partitions, which have no /sys/block entry, only are with -p.
+func (s *iostat) shown(name string) bool {
+	if len(s.devices) > 0 {
+		return s.devices[name]
+	}
+	if s.partitions {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(s.sys, "block")); err != nil {
+		// Without sysfs, there is no telling.
+		return true
+	}
+	_, err := os.Stat(filepath.Join(s.sys, "block", name))
+	return err == nil
+}
+
+// delta returns how much a counter grew, or 0 if it was reset.
+func delta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+func (s *iostat) report(prev, cur *sample) report {
+	secs := cur.at.Sub(prev.at).Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	r := report{Unit: "kB", Disks: []disk{}}
+	// Sectors are 512 bytes.
+	var perUnit uint64 = 2
+	if s.megabytes {
+		r.Unit, perUnit = "MB", 2048
+	}
+	if s.timestamps {
+		r.Time = cur.at.Format("2006-01-02 15:04:05")
+	}
+	for _, name := range cur.order {
+		if !s.shown(name) {
+			continue
+		}
+		c, p := cur.disks[name], prev.disks[name]
+		reads, writes := delta(p.Reads, c.Reads), delta(p.Writes, c.Writes)
+		if s.skipIdle && reads == 0 && writes == 0 {
+			continue
+		}
+		read := delta(p.SectorsRead, c.SectorsRead)
+		written := delta(p.SectorsWritten, c.SectorsWritten)
+		d := disk{
+			Device:    name,
+			TPS:       float64(reads+writes) / secs,
+			ReadPS:    float64(read) / float64(perUnit) / secs,
+			WrtnPS:    float64(written) / float64(perUnit) / secs,
+			Read:      read / perUnit,
+			Wrtn:      written / perUnit,
+			RPS:       float64(reads) / secs,
+			WPS:       float64(writes) / secs,
+			RMergedPS: float64(delta(p.ReadsMerged, c.ReadsMerged)) / secs,
+			WMergedPS: float64(delta(p.WritesMerged, c.WritesMerged)) / secs,
+			QueueSize: float64(delta(p.WeightedIOTime, c.WeightedIOTime)) / (secs * 1000),
+			Util:      min(100, float64(delta(p.IOTime, c.IOTime))/(secs*10)),
+		}
+		if reads > 0 {
+			d.RAwait = float64(delta(p.ReadTime, c.ReadTime)) / float64(reads)
+		}
+		if writes > 0 {
+			d.WAwait = float64(delta(p.WriteTime, c.WriteTime)) / float64(writes)
+		}
+		r.Disks = append(r.Disks, d)
+	}
+	return r
+}
+
+func (s *iostat) print(w io.Writer, r report) error {
+	if s.json {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	if r.Time != "" {
+		fmt.Fprintln(w, r.Time)
+	}
+	u := r.Unit
+	if s.extended {
+		fmt.Fprintf(w, "%-13s %8s %8s %10s %10s %8s %8s %8s %8s %7s %6s\n", "Device", "r/s", "w/s",
+			"r"+u+"/s", "w"+u+"/s", "rrqm/s", "wrqm/s", "r_await", "w_await", "aqu-sz", "%util")
+		for _, d := range r.Disks {
+			fmt.Fprintf(w, "%-13s %8.2f %8.2f %10.2f %10.2f %8.2f %8.2f %8.2f %8.2f %7.2f %6.2f\n", d.Device, d.RPS, d.WPS,
+				d.ReadPS, d.WrtnPS, d.RMergedPS, d.WMergedPS, d.RAwait, d.WAwait, d.QueueSize, d.Util)
+		}
+	} else {
+		fmt.Fprintf(w, "%-13s %8s %12s %12s %12s %12s\n", "Device", "tps", u+"_read/s", u+"_wrtn/s", u+"_read", u+"_wrtn")
+		for _, d := range r.Disks {
+			fmt.Fprintf(w, "%-13s %8.2f %12.2f %12.2f %12d %12d\n", d.Device, d.TPS, d.ReadPS, d.WrtnPS, d.Read, d.Wrtn)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// run prints a report since boot, then count reports each delay apart, or
+// forever if count is 0. Without a delay, there is just the first.
+func (s *iostat) run(w io.Writer, delay time.Duration, count int) error {
+	cur, err := s.sample()
+	if err != nil {
+		return err
+	}
+	prev, err := s.boot(cur)
+	if err != nil {
+		return err
+	}
+	for i := 1; ; i++ {
+		if err := s.print(w, s.report(prev, cur)); err != nil {
+			return err
+		}
+		if delay == 0 || (count > 0 && i >= count) {
+			return nil
+		}
+		s.sleep(delay)
+		prev = cur
+		if cur, err = s.sample(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseArgs parses the devices, and the delay and count which follow them.
+func parseArgs(args []string) (map[string]bool, time.Duration, int, error) {
+	devices := map[string]bool{}
+	for len(args) > 0 {
+		if _, err := strconv.ParseFloat(args[0], 64); err == nil {
+			break
+		}
+		devices[filepath.Base(args[0])] = true
+		args = args[1:]
+	}
+	if len(args) > 2 {
+		return nil, 0, 0, errUsage
+	}
+	var delay time.Duration
+	count := 0
+	if len(args) > 0 {
+		d, _ := strconv.ParseFloat(args[0], 64)
+		if d <= 0 {
+			return nil, 0, 0, fmt.Errorf("%w: bad delay %q", errUsage, args[0])
+		}
+		delay = time.Duration(d * float64(time.Second))
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return nil, 0, 0, fmt.Errorf("%w: bad count %q", errUsage, args[1])
+		}
+		count = n
+	}
+	return devices, delay, count, nil
+}
+
+func main() {
+	flag.Parse()
+	devices, delay, count, err := parseArgs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &iostat{
+		fs:         proc.DefaultRoot,
+		sys:        "/sys",
+		devices:    devices,
+		extended:   *extended,
+		megabytes:  *megabytes,
+		partitions: *partitions,
+		skipIdle:   *skipIdle,
+		timestamps: *timestamps,
+		json:       *toJSON,
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+	if err := s.run(os.Stdout, delay, count); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+)
+
+const (
+	boot  = "   8       0 sda 100 10 2000 50 200 20 4000 80 0 1000 1300\n   8       1 sda1 100 10 2000 50 200 20 4000 80 0 1000 1300\n   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0\n"
+	later = "   8       0 sda 150 10 4048 150 200 30 4000 80 0 1500 1800\n   8       1 sda1 150 10 4048 150 200 30 4000 80 0 1500 1800\n   7       0 loop0 0 0 0 0 0 0 0 0 0 0 0\n"
+)
+
+func fakeIostat(t *testing.T) *iostat {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"proc/uptime":      "100.00 150.00\n",
+		"proc/diskstats":   boot,
+		"sys/block/sda":    "",
+		"sys/block/loop0":  "",
+		"sys/class/unused": "",
+	} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &iostat{
+		fs:  proc.FS(filepath.Join(root, "proc")),
+		sys: filepath.Join(root, "sys"),
+		now: func() time.Time { return now },
+		sleep: func(d time.Duration) {
+			now = now.Add(d)
+			if err := os.WriteFile(filepath.Join(root, "proc/diskstats"), []byte(later), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup func(*iostat)
+		count int
+		want  string
+	}{
+		{
+			name: "since boot",
+			want: "Device             tps    kB_read/s    kB_wrtn/s      kB_read      kB_wrtn\n" +
+				"sda               3.00        10.00        20.00         1000         2000\n" +
+				"loop0             0.00         0.00         0.00            0            0\n\n",
+		},
+		{
+			name:  "interval",
+			setup: func(s *iostat) { s.skipIdle = true },
+			count: 2,
+			want: "Device             tps    kB_read/s    kB_wrtn/s      kB_read      kB_wrtn\n" +
+				"sda               3.00        10.00        20.00         1000         2000\n\n" +
+				"Device             tps    kB_read/s    kB_wrtn/s      kB_read      kB_wrtn\n" +
+				"sda              25.00       512.00         0.00         1024            0\n\n",
+		},
+		{
+			name:  "extended",
+			setup: func(s *iostat) { s.extended, s.devices = true, map[string]bool{"sda1": true} },
+			count: 2,
+			want: "Device             r/s      w/s      rkB/s      wkB/s   rrqm/s   wrqm/s  r_await  w_await  aqu-sz  %util\n" +
+				"sda1              1.00     2.00      10.00      20.00     0.10     0.20     0.50     0.40    0.01   1.00\n\n" +
+				"Device             r/s      w/s      rkB/s      wkB/s   rrqm/s   wrqm/s  r_await  w_await  aqu-sz  %util\n" +
+				"sda1             25.00     0.00     512.00       0.00     0.00     5.00     2.00     0.00    0.25  25.00\n\n",
+		},
+		{
+			name:  "json",
+			setup: func(s *iostat) { s.json, s.megabytes, s.timestamps, s.skipIdle = true, true, true, true },
+			want:  `{"time":"2024-01-01 00:00:00","unit":"MB","disks":[{"device":"sda","tps":3,"read_per_sec":0.009765625,"written_per_sec":0.01953125,"read":0,"written":1,"r_per_sec":1,"w_per_sec":2,"rrqm_per_sec":0.1,"wrqm_per_sec":0.2,"r_await":0.5,"w_await":0.4,"aqu_sz":0.013,"util":1}]}` + "\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakeIostat(t)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+			var out bytes.Buffer
+			delay := time.Duration(0)
+			if tt.count > 0 {
+				delay = 2 * time.Second
+			}
+			if err := s.run(&out, delay, tt.count); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		devices map[string]bool
+		delay   time.Duration
+		count   int
+		err     error
+	}{
+		{devices: map[string]bool{}},
+		{args: []string{"/dev/sda", "nvme0n1", "1", "5"}, devices: map[string]bool{"sda": true, "nvme0n1": true}, delay: time.Second, count: 5},
+		{args: []string{"0.5"}, devices: map[string]bool{}, delay: time.Second / 2},
+		{args: []string{"0"}, err: errUsage},
+		{args: []string{"1", "2", "3"}, err: errUsage},
+		{args: []string{"1", "sda"}, err: errUsage},
+	} {
+		devices, delay, count, err := parseArgs(tt.args)
+		if !errors.Is(err, tt.err) || !reflect.DeepEqual(devices, tt.devices) || delay != tt.delay || count != tt.count {
+			t.Errorf("parseArgs(%q) = %v, %v, %d, %v, want %v, %v, %d, %v", tt.args, devices, delay, count, err, tt.devices, tt.delay, tt.count, tt.err)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// ipmitool talks to the BMC through the OpenIPMI driver.
+//
+// Synopsis:
+//
+//	ipmitool [-d DEVICE] COMMAND [ARGS...]
+//
+// Description:
+//
+//	ipmitool is a small subset of the ipmitool of the same name, to read
+//	the BMC's logs, sensors and inventory and control the chassis from
+//	the boot environment. Commands are:
+//
+//	mc info                            BMC device ID and firmware version
+//	sel info | list | clear            System Event Log
+//	sdr [list], sensor [list]          sensor readings and threshold status
+//	fru [print] [ID]                   FRU inventory, of the BMC's FRU by default
+//	chassis status                     power and intrusion state
+//	chassis power status | on | off | cycle | reset | soft
+//	chassis identify [SECONDS | force] turn on the identify light, 15s by default
+//	raw NETFN CMD [DATA...]            send a command of hex bytes
+//
+// Options:
+//
+//	-d: IPMI device (default /dev/ipmi0)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+var errUsage = errors.New("usage: ipmitool [-d DEVICE] mc info | sel info|list|clear | sdr | sensor | fru [ID] | chassis status|power ACTION|identify [SECONDS|force] | raw NETFN CMD [DATA...]")
+
+// bmc is the part of ipmi.IPMI used by ipmitool.
+type bmc interface {
+	GetDeviceID() (*ipmi.DevID, error)
+	GetSELInfo() (*ipmi.SELInfo, error)
+	ReadSEL() ([]ipmi.SELRecord, error)
+	ClearSEL() error
+	ReadSDR() ([]ipmi.SensorRecord, error)
+	ReadSensor(r *ipmi.SensorRecord) (*ipmi.SensorReading, error)
+	ReadFRU(id uint8) (*ipmi.FRU, error)
+	GetChassisStatus() (*ipmi.ChassisStatus, error)
+	ChassisControl(c ipmi.ChassisControl) error
+	ChassisIdentify(seconds int) error
+	RawCmd(param []byte) ([]byte, error)
+}
+
+type cmd struct {
+	w    io.Writer
+	bmc  bmc
+	args []string
+}
+
+var powerActions = map[string]struct {
+	c   ipmi.ChassisControl
+	msg string
+}{
+	"on":    {ipmi.ChassisPowerUp, "Up/On"},
+	"off":   {ipmi.ChassisPowerDown, "Down/Off"},
+	"cycle": {ipmi.ChassisPowerCycle, "Cycle"},
+	"reset": {ipmi.ChassisHardReset, "Reset"},
+	"soft":  {ipmi.ChassisSoftShutdown, "Soft"},
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func (c *cmd) mcInfo() error {
+	id, err := c.bmc.GetDeviceID()
+	if err != nil {
+		return err
+	}
+	mfg := uint32(id.ManufacturerID[0]) | uint32(id.ManufacturerID[1])<<8 | uint32(id.ManufacturerID[2]&0xf)<<16
+	fmt.Fprintf(c.w, "Device ID                 : %d\n", id.DeviceID)
+	fmt.Fprintf(c.w, "Device Revision           : %d\n", id.DeviceRevision&0xf)
+	fmt.Fprintf(c.w, "Firmware Revision         : %d.%02x\n", id.FwRev1&0x7f, id.FwRev2)
+	fmt.Fprintf(c.w, "IPMI Version              : %d.%d\n", id.IpmiVersion&0xf, id.IpmiVersion>>4)
+	fmt.Fprintf(c.w, "Manufacturer ID           : %d\n", mfg)
+	fmt.Fprintf(c.w, "Product ID                : %d\n", uint16(id.ProductID[0])|uint16(id.ProductID[1])<<8)
+	return nil
+}
+
+// thresholdEvents describe the offsets of threshold based events.
+var thresholdEvents = []string{
+	"Lower Non-critical going low", "Lower Non-critical going high",
+	"Lower Critical going low", "Lower Critical going high",
+	"Lower Non-recoverable going low", "Lower Non-recoverable going high",
+	"Upper Non-critical going low", "Upper Non-critical going high",
+	"Upper Critical going low", "Upper Critical going high",
+	"Upper Non-recoverable going low", "Upper Non-recoverable going high",
+}
+
+func selLine(r *ipmi.SELRecord) string {
+	when := "Pre-Init"
+	if t, ok := r.Time(); ok {
+		when = t.Format("2006-01-02 15:04:05")
+	} else if r.Type >= 0xe0 {
+		when = "-"
+	}
+	if r.Type != ipmi.SELRecordSystemEvent {
+		return fmt.Sprintf("%4x | %s | OEM record %02x | %x", r.ID, when, r.Type, r.Data[3:])
+	}
+	offset := r.EventData[0] & 0xf
+	event := fmt.Sprintf("Event offset %#x", offset)
+	if r.EventType() == ipmi.ReadingTypeThreshold && int(offset) < len(thresholdEvents) {
+		event = thresholdEvents[offset]
+	}
+	dir := "Asserted"
+	if r.Deassertion() {
+		dir = "Deasserted"
+	}
+	return fmt.Sprintf("%4x | %s | %s #0x%02x | %s | %s", r.ID, when, ipmi.SensorTypeName(r.SensorType), r.SensorNum, event, dir)
+}
+
+func (c *cmd) sel(args []string) error {
+	sub := "list"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "info":
+		info, err := c.bmc.GetSELInfo()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.w, "Version          : %d.%d\n", info.Version&0xf, info.Version>>4)
+		fmt.Fprintf(c.w, "Entries          : %d\n", info.Entries)
+		fmt.Fprintf(c.w, "Free Space       : %d bytes\n", info.FreeSpace)
+		if info.LastAddTime != 0xffffffff {
+			fmt.Fprintf(c.w, "Last Add Time    : %s\n", time.Unix(int64(info.LastAddTime), 0).UTC().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	case "list", "elist":
+		records, err := c.bmc.ReadSEL()
+		for i := range records {
+			fmt.Fprintln(c.w, selLine(&records[i]))
+		}
+		return err
+	case "clear":
+		if err := c.bmc.ClearSEL(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.w, "Clearing SEL")
+		return nil
+	}
+	return errUsage
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+func (c *cmd) sdr() error {
+	sensors, err := c.bmc.ReadSDR()
+	if err != nil && len(sensors) == 0 {
+		return err
+	}
+	tw := tabwriter.NewWriter(c.w, 0, 0, 1, ' ', 0)
+	for i := range sensors {
+		s := &sensors[i]
+		r, rerr := c.bmc.ReadSensor(s)
+		var value, status string
+		switch {
+		case rerr != nil:
+			value, status = "no reading", "na"
+		case r.Unavailable:
+			value, status = "no reading", r.Status(s)
+		case r.Analog:
+			value, status = formatValue(r.Value)+" "+ipmi.UnitName(s.Unit), r.Status(s)
+		default:
+			value, status = fmt.Sprintf("0x%02x", r.Raw), r.Status(s)
+		}
+		fmt.Fprintf(tw, "%s\t| %s\t| %s\n", s.Name, value, status)
+	}
+	if ferr := tw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (c *cmd) fru(args []string) error {
+	if len(args) > 0 && args[0] == "print" {
+		args = args[1:]
+	}
+	var id uint64
+	if len(args) > 0 {
+		var err error
+		if id, err = strconv.ParseUint(args[0], 0, 8); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+	}
+	fru, err := c.bmc.ReadFRU(uint8(id))
+	if err != nil {
+		return err
+	}
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(c.w, " %-22s: %s\n", name, value)
+		}
+	}
+	fmt.Fprintf(c.w, "FRU Device Description : ID %d\n", id)
+	if ch := fru.Chassis; ch != nil {
+		field("Chassis Type", smbios.ChassisType(ch.Type).String())
+		field("Chassis Part Number", ch.PartNumber)
+		field("Chassis Serial", ch.Serial)
+		for _, e := range ch.Custom {
+			field("Chassis Extra", e)
+		}
+	}
+	if b := fru.Board; b != nil {
+		if !b.MfgTime.IsZero() {
+			field("Board Mfg Date", b.MfgTime.Format("2006-01-02 15:04"))
+		}
+		field("Board Mfg", b.Manufacturer)
+		field("Board Product", b.Product)
+		field("Board Serial", b.Serial)
+		field("Board Part Number", b.PartNumber)
+		for _, e := range b.Custom {
+			field("Board Extra", e)
+		}
+	}
+	if p := fru.Product; p != nil {
+		field("Product Manufacturer", p.Manufacturer)
+		field("Product Name", p.Name)
+		field("Product Part Number", p.PartNumber)
+		field("Product Version", p.Version)
+		field("Product Serial", p.Serial)
+		field("Product Asset Tag", p.AssetTag)
+		for _, e := range p.Custom {
+			field("Product Extra", e)
+		}
+	}
+	return nil
+}
+
+func (c *cmd) chassis(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "status":
+		s, err := c.bmc.GetChassisStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.w, "System Power         : %s\n", onOff(s.PowerOn()))
+		fmt.Fprintf(c.w, "Power Overload       : %t\n", s.CurrentPowerState&0x2 != 0)
+		fmt.Fprintf(c.w, "Main Power Fault     : %t\n", s.CurrentPowerState&0x10 != 0)
+		fmt.Fprintf(c.w, "Chassis Intrusion    : %t\n", s.MiscChassisState&0x1 != 0)
+		fmt.Fprintf(c.w, "Cooling/Fan Fault    : %t\n", s.MiscChassisState&0x8 != 0)
+		return nil
+	case "power":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if args[1] == "status" {
+			s, err := c.bmc.GetChassisStatus()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(c.w, "Chassis Power is %s\n", onOff(s.PowerOn()))
+			return nil
+		}
+		a, ok := powerActions[args[1]]
+		if !ok {
+			return errUsage
+		}
+		if err := c.bmc.ChassisControl(a.c); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.w, "Chassis Power Control: %s\n", a.msg)
+		return nil
+	case "identify":
+		seconds := 15
+		if len(args) > 1 {
+			if args[1] == "force" {
+				seconds = -1
+			} else {
+				var err error
+				if seconds, err = strconv.Atoi(args[1]); err != nil || seconds < 0 {
+					return errUsage
+				}
+			}
+		}
+		if err := c.bmc.ChassisIdentify(seconds); err != nil {
+			return err
+		}
+		switch {
+		case seconds < 0:
+			fmt.Fprintln(c.w, "Chassis identify interval: indefinite")
+		case seconds == 0:
+			fmt.Fprintln(c.w, "Chassis identify interval: off")
+		default:
+			fmt.Fprintf(c.w, "Chassis identify interval: %d seconds\n", seconds)
+		}
+		return nil
+	}
+	return errUsage
+}
+
+func (c *cmd) raw(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	var param []byte
+	for _, a := range args {
+		b, err := strconv.ParseUint(strings.TrimPrefix(a, "0x"), 16, 8)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		param = append(param, byte(b))
+	}
+	r, err := c.bmc.RawCmd(param)
+	if err != nil {
+		return err
+	}
+	var out []string
+	// Skip the completion code.
+	for _, b := range r[min(1, len(r)):] {
+		out = append(out, fmt.Sprintf("%02x", b))
+	}
+	fmt.Fprintln(c.w, strings.Join(out, " "))
+	return nil
+}
+
+func (c *cmd) run() error {
+	if len(c.args) == 0 {
+		return errUsage
+	}
+	args := c.args[1:]
+	switch c.args[0] {
+	case "mc":
+		if len(args) != 1 || args[0] != "info" {
+			return errUsage
+		}
+		return c.mcInfo()
+	case "sel":
+		return c.sel(args)
+	case "sdr", "sensor":
+		if len(args) > 1 || (len(args) == 1 && args[0] != "list") {
+			return errUsage
+		}
+		return c.sdr()
+	case "fru":
+		return c.fru(args)
+	case "chassis":
+		return c.chassis(args)
+	case "raw":
+		return c.raw(args)
+	}
+	return errUsage
+}
+
+func main() {
+	dev := flag.String("d", "/dev/ipmi0", "IPMI device")
+	flag.Parse()
+	i, err := ipmi.OpenPath(*dev)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer i.Close()
+	c := &cmd{w: os.Stdout, bmc: i, args: flag.Args()}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+)
+
+type fakeBMC struct {
+	sel      []ipmi.SELRecord
+	sensors  []ipmi.SensorRecord
+	readings map[string]*ipmi.SensorReading
+	fru      *ipmi.FRU
+	power    byte
+
+	calls []string
+}
+
+func (f *fakeBMC) GetDeviceID() (*ipmi.DevID, error) {
+	return &ipmi.DevID{DeviceID: 32, DeviceRevision: 1, FwRev1: 2, FwRev2: 0x13, IpmiVersion: 0x02, ManufacturerID: [3]byte{0x57, 0x01, 0x00}, ProductID: [2]byte{0x3c, 0x0b}}, nil
+}
+
+func (f *fakeBMC) GetSELInfo() (*ipmi.SELInfo, error) {
+	return &ipmi.SELInfo{Version: 0x51, Entries: uint16(len(f.sel)), FreeSpace: 1024, LastAddTime: 0xffffffff}, nil
+}
+
+func (f *fakeBMC) ReadSEL() ([]ipmi.SELRecord, error) { return f.sel, nil }
+
+func (f *fakeBMC) ClearSEL() error {
+	f.calls = append(f.calls, "clear")
+	return nil
+}
+
+func (f *fakeBMC) ReadSDR() ([]ipmi.SensorRecord, error) { return f.sensors, nil }
+
+func (f *fakeBMC) ReadSensor(r *ipmi.SensorRecord) (*ipmi.SensorReading, error) {
+	if sr, ok := f.readings[r.Name]; ok {
+		return sr, nil
+	}
+	return nil, ipmi.CompletionError(ipmi.IPMI_CC_REQ_DATA_NOT_PRESENT)
+}
+
+func (f *fakeBMC) ReadFRU(id uint8) (*ipmi.FRU, error) {
+	if id != 0 {
+		return nil, ipmi.CompletionError(ipmi.IPMI_CC_REQ_DATA_NOT_PRESENT)
+	}
+	return f.fru, nil
+}
+
+func (f *fakeBMC) GetChassisStatus() (*ipmi.ChassisStatus, error) {
+	return &ipmi.ChassisStatus{CurrentPowerState: f.power}, nil
+}
+
+func (f *fakeBMC) ChassisControl(c ipmi.ChassisControl) error {
+	f.calls = append(f.calls, "control "+string('0'+byte(c)))
+	return nil
+}
+
+func (f *fakeBMC) ChassisIdentify(seconds int) error {
+	f.calls = append(f.calls, "identify")
+	return nil
+}
+
+func (f *fakeBMC) RawCmd(param []byte) ([]byte, error) {
+	if !bytes.Equal(param, []byte{0x06, 0x01}) {
+		return nil, ipmi.CompletionError(ipmi.IPMI_CC_INV_CMD)
+	}
+	return []byte{0x00, 0x20, 0x81}, nil
+}
+
+func TestRun(t *testing.T) {
+	newBMC := func() *fakeBMC {
+		return &fakeBMC{
+			sel: []ipmi.SELRecord{
+				{ID: 1, Type: ipmi.SELRecordSystemEvent, StandardEvent: ipmi.StandardEvent{Timestamp: 1704067200, SensorType: 0x01, SensorNum: 0x30, EventTypeDir: 0x01, EventData: [3]byte{0x59}}},
+				{ID: 2, Type: ipmi.SELRecordSystemEvent, StandardEvent: ipmi.StandardEvent{Timestamp: 30, SensorType: 0x12, SensorNum: 0x83, EventTypeDir: 0xef, EventData: [3]byte{0x01}}},
+				{ID: 3, Type: 0xe0, Data: [16]byte{3, 0, 0xe0, 0xde, 0xad}},
+			},
+			sensors: []ipmi.SensorRecord{
+				{Name: "CPU Temp", Unit: 1, ReadingType: ipmi.ReadingTypeThreshold},
+				{Name: "PSU1 Status", ReadingType: 0x6f},
+				{Name: "FAN1", Unit: 18, ReadingType: ipmi.ReadingTypeThreshold},
+				{Name: "P12V", Unit: 4, ReadingType: ipmi.ReadingTypeThreshold},
+			},
+			readings: map[string]*ipmi.SensorReading{
+				"CPU Temp":    {Raw: 0x5a, Analog: true, Value: 90.0000001, State: 0x18},
+				"PSU1 Status": {Raw: 0, State: 0x01},
+				"FAN1":        {Unavailable: true},
+			},
+			fru: &ipmi.FRU{
+				Chassis: &ipmi.FRUChassis{Type: 0x17, Serial: "C1"},
+				Board:   &ipmi.FRUBoard{MfgTime: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), Manufacturer: "ACME", Product: "X11"},
+				Product: &ipmi.FRUProduct{Name: "Server", Custom: []string{"rev b"}},
+			},
+			power: 0x01,
+		}
+	}
+	for _, tt := range []struct {
+		args  []string
+		want  string
+		calls []string
+		err   error
+	}{
+		{
+			args: []string{"mc", "info"},
+			want: "Device ID                 : 32\n" +
+				"Device Revision           : 1\n" +
+				"Firmware Revision         : 2.13\n" +
+				"IPMI Version              : 2.0\n" +
+				"Manufacturer ID           : 343\n" +
+				"Product ID                : 2876\n",
+		},
+		{
+			args: []string{"sel", "list"},
+			want: "   1 | 2024-01-01 00:00:00 | Temperature #0x30 | Upper Critical going high | Asserted\n" +
+				"   2 | Pre-Init | System Event #0x83 | Event offset 0x1 | Deasserted\n" +
+				"   3 | - | OEM record e0 | dead0000000000000000000000\n",
+		},
+		{
+			args: []string{"sel", "info"},
+			want: "Version          : 1.5\nEntries          : 3\nFree Space       : 1024 bytes\n",
+		},
+		{args: []string{"sel", "clear"}, want: "Clearing SEL\n", calls: []string{"clear"}},
+		{
+			args: []string{"sdr"},
+			want: "CPU Temp    | 90 degrees C | ucr\n" +
+				"PSU1 Status | 0x00         | 0x0001\n" +
+				"FAN1        | no reading   | na\n" +
+				"P12V        | no reading   | na\n",
+		},
+		{
+			args: []string{"fru", "print"},
+			want: "FRU Device Description : ID 0\n" +
+				" Chassis Type          : Rack Mount Chassis\n" +
+				" Chassis Serial        : C1\n" +
+				" Board Mfg Date        : 2023-05-01 10:00\n" +
+				" Board Mfg             : ACME\n" +
+				" Board Product         : X11\n" +
+				" Product Name          : Server\n" +
+				" Product Extra         : rev b\n",
+		},
+		{args: []string{"fru", "1"}, err: ipmi.CompletionError(ipmi.IPMI_CC_REQ_DATA_NOT_PRESENT)},
+		{args: []string{"chassis", "power", "status"}, want: "Chassis Power is on\n"},
+		{args: []string{"chassis", "power", "cycle"}, want: "Chassis Power Control: Cycle\n", calls: []string{"control 2"}},
+		{args: []string{"chassis", "power", "soft"}, want: "Chassis Power Control: Soft\n", calls: []string{"control 5"}},
+		{args: []string{"chassis", "identify", "force"}, want: "Chassis identify interval: indefinite\n", calls: []string{"identify"}},
+		{args: []string{"chassis", "identify"}, want: "Chassis identify interval: 15 seconds\n", calls: []string{"identify"}},
+		{args: []string{"raw", "0x06", "1"}, want: "20 81\n"},
+		{args: []string{"raw", "0x06", "zz"}, err: errUsage},
+		{args: []string{"chassis", "power", "sideways"}, err: errUsage},
+		{args: []string{"chassis", "identify", "-3"}, err: errUsage},
+		{args: []string{"sdr", "elist"}, err: errUsage},
+		{args: nil, err: errUsage},
+	} {
+		t.Run(strings.Join(tt.args, "_"), func(t *testing.T) {
+			var out bytes.Buffer
+			bmc := newBMC()
+			c := &cmd{w: &out, bmc: bmc, args: tt.args}
+			if err := c.run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run = %v, want %v", err, tt.err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(bmc.calls, tt.calls) {
+				t.Errorf("calls = %q, want %q", bmc.calls, tt.calls)
+			}
+		})
+	}
+}
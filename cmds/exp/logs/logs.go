@@ -0,0 +1,162 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+// logs prints the entries commands logged to the ulog ring.
+//
+// Synopsis:
+//
+//	logs [-f] [-n N] [-level LEVEL] [-tag TAG] [-json] [-file FILE]
+//
+// Description:
+//
+//	Commands which log with a ulog.Structured logger keep their entries
+//	in a ring file in /run, so that early boot messages can be read after
+//	they scrolled off the console. Where entries go is set with the ULOG
+//	environment variable, which can be given on the kernel command line,
+//	e.g. ULOG=stderr,ring,kmsg,udp://10.0.0.1.
+//
+// Options:
+//
+//	-f:     print new entries as they are logged
+//	-n:     print only the last N entries
+//	-level: print only entries at least this severe, e.g. warning
+//	-tag:   print only entries of this command
+//	-json:  print entries as lines of JSON
+//	-file:  the ring file (default /run/ulog/log)
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog"
+)
+
+var errUsage = errors.New("usage: logs [-f] [-n N] [-level LEVEL] [-tag TAG] [-json] [-file FILE]")
+
+type cmd struct {
+	w      io.Writer
+	file   string
+	follow bool
+	last   int
+	level  ulog.Level
+	tag    string
+	json   bool
+	// interval is how often the ring is read when following.
+	interval time.Duration
+	// done stops following.
+	done <-chan struct{}
+}
+
+func (c *cmd) print(e *ulog.Entry) error {
+	if e.Level > c.level || (c.tag != "" && e.Tag != c.tag) {
+		return nil
+	}
+	if c.json {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.w, "%s\n", b)
+		return err
+	}
+	_, err := fmt.Fprintln(c.w, e.String())
+	return err
+}
+
+func (c *cmd) run() error {
+	entries, err := ulog.ReadRing(c.file)
+	if err != nil && !(c.follow && errors.Is(err, os.ErrNotExist)) {
+		return err
+	}
+	var shown []ulog.Entry
+	for _, e := range entries {
+		if e.Level <= c.level && (c.tag == "" || e.Tag == c.tag) {
+			shown = append(shown, e)
+		}
+	}
+	if c.last > 0 && len(shown) > c.last {
+		shown = shown[len(shown)-c.last:]
+	}
+	var seq uint64
+	for i := range shown {
+		if err := c.print(&shown[i]); err != nil {
+			return err
+		}
+	}
+	if len(entries) > 0 {
+		seq = entries[len(entries)-1].Seq
+	}
+	if !c.follow {
+		return nil
+	}
+
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-t.C:
+		}
+		entries, err := ulog.ReadRing(c.file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 && entries[len(entries)-1].Seq < seq {
+			// The ring was removed and started over.
+			seq = 0
+		}
+		for i := range entries {
+			if entries[i].Seq <= seq {
+				continue
+			}
+			if err := c.print(&entries[i]); err != nil {
+				return err
+			}
+			seq = entries[i].Seq
+		}
+	}
+}
+
+func command(w io.Writer, args []string) (*cmd, error) {
+	c := &cmd{w: w, interval: 500 * time.Millisecond}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.BoolVar(&c.follow, "f", false, "Print new entries as they are logged")
+	f.IntVar(&c.last, "n", 0, "Print only the last N entries")
+	level := f.String("level", "debug", "Print only entries at least this severe")
+	f.StringVar(&c.tag, "tag", "", "Print only entries of this command")
+	f.BoolVar(&c.json, "json", false, "Print entries as lines of JSON")
+	f.StringVar(&c.file, "file", ulog.DefaultRingFile, "Ring file")
+	if err := f.Parse(args[1:]); err != nil || f.NArg() != 0 {
+		return nil, errUsage
+	}
+	var err error
+	if c.level, err = ulog.ParseLevel(*level); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog"
+)
+
+func TestLogs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+	ring := ulog.NewFileRing(file, ulog.DefaultRingSize)
+	boot := ulog.New("init", ring)
+	dhcp := ulog.New("dhclient", ring).With("iface", "eth0")
+	boot.Infof("welcome")
+	dhcp.Warningf("no lease")
+	boot.Errorf("no shell")
+	dhcp.Infof("lease 10.0.0.2")
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+		err  error
+	}{
+		{args: nil, want: []string{"init: welcome", "dhclient: no lease iface=eth0", "init: no shell", "dhclient: lease 10.0.0.2 iface=eth0"}},
+		{args: []string{"-n", "2"}, want: []string{"init: no shell", "dhclient: lease"}},
+		{args: []string{"-level", "warning"}, want: []string{"dhclient: no lease", "init: no shell"}},
+		{args: []string{"-tag", "init", "-n", "1"}, want: []string{"init: no shell"}},
+		{args: []string{"-json", "-tag", "dhclient", "-level", "4"}, want: []string{`{"seq":2,`}},
+		{args: []string{"-level", "loud"}, err: ulog.ErrBadLevel},
+		{args: []string{"extra"}, err: errUsage},
+	} {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var b bytes.Buffer
+			c, err := command(&b, append([]string{"logs", "-file", file}, tt.args...))
+			if err == nil {
+				err = c.run()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("logs = %v, want %v", err, tt.err)
+			}
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if tt.err != nil {
+				return
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("logs printed %q, want %d lines", b.String(), len(tt.want))
+			}
+			for i, l := range lines {
+				if !strings.Contains(l, tt.want[i]) {
+					t.Errorf("line %d = %q, want it to contain %q", i, l, tt.want[i])
+				}
+			}
+		})
+	}
+
+	c, err := command(&bytes.Buffer{}, []string{"logs", "-file", filepath.Join(t.TempDir(), "none")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("logs of a missing ring = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestFollow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+	l := ulog.New("init", ulog.NewFileRing(file, ulog.DefaultRingSize))
+
+	var out syncBuffer
+	c, err := command(&out, []string{"logs", "-f", "-file", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.interval = 10 * time.Millisecond
+	done := make(chan struct{})
+	c.done = done
+	errc := make(chan error, 1)
+	go func() { errc <- c.run() }()
+
+	for _, msg := range []string{"one", "two"} {
+		l.Infof("%s", msg)
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), "init: "+msg) {
+			if time.Now().After(deadline) {
+				t.Fatalf("logs -f did not print %q: %q", msg, out.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	close(done)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 2 {
+		t.Errorf("logs -f printed %d lines, want 2: %q", n, out.String())
+	}
+}
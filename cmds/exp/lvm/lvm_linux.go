@@ -0,0 +1,260 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// lvm lists and activates LVM2 logical volumes.
+//
+// Synopsis:
+//
+//	lvm pvscan
+//	lvm lvscan
+//	lvm vgchange -a y|n [-r] [VG...]
+//	lvm lvchange -a y|n [-r] VG/LV...
+//
+// Description:
+//
+//	lvm scans all block devices for LVM2 physical volumes. pvscan lists
+//	them, lvscan lists the logical volumes of their volume groups.
+//
+//	vgchange activates or deactivates all visible logical volumes of the
+//	given volume groups, or of all volume groups. lvchange does the same
+//	for single logical volumes. Active logical volumes appear as
+//	/dev/dm-N and are named VG-LV in the device-mapper, with dashes in
+//	either name doubled.
+//
+//	Only linear and striped logical volumes can be activated. lvm never
+//	modifies volume group metadata.
+//
+// Options:
+//
+//	-a y|n:  activate or deactivate
+//	-r:      activate read-only
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/lvm"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var errUsage = errors.New("usage: lvm pvscan | lvscan | vgchange -a y|n [-r] [VG...] | lvchange -a y|n [-r] VG/LV...")
+
+type cmd struct {
+	stdout, stderr io.Writer
+	op             string
+	activate       string
+	readOnly       bool
+	args           []string
+
+	// scan returns the volume groups on all block devices.
+	scan func() ([]*lvm.VG, error)
+	// create and remove (de)activate logical volumes.
+	create func(vg *lvm.VG, lv *lvm.LV, readOnly bool) (*dm.Device, error)
+	remove func(vg *lvm.VG, lv *lvm.LV) error
+}
+
+// scanDevices scans the block devices that may be physical volumes.
+func scanDevices() ([]*lvm.VG, error) {
+	attrs, err := block.ListAttrs()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, a := range attrs {
+		if a.Size == 0 || len(a.Holders) != 0 {
+			continue
+		}
+		// PVs may be disks, partitions, md arrays or LUKS mappings,
+		// but not logical volumes themselves.
+		if a.Type != "rom" && a.Type != "lvm" {
+			paths = append(paths, filepath.Join("/dev", a.Name))
+		}
+	}
+	return lvm.Scan(paths)
+}
+
+func (c *cmd) pvscan(vgs []*lvm.VG) {
+	for _, vg := range vgs {
+		for _, pv := range vg.PVs {
+			dev := pv.Device
+			if dev == "" {
+				dev = "[unknown]"
+			}
+			size := pv.PECount * vg.ExtentSize * 512
+			fmt.Fprintf(c.stdout, "  PV %-16s VG %-12s lvm2 [%s]\n", dev, vg.Name, humanSize(size))
+		}
+	}
+}
+
+func (c *cmd) lvscan(vgs []*lvm.VG) {
+	for _, vg := range vgs {
+		for _, lv := range vg.LVs {
+			if !lv.Visible() {
+				continue
+			}
+			var extents uint64
+			for _, s := range lv.Segments {
+				extents += s.ExtentCount
+			}
+			fmt.Fprintf(c.stdout, "  %-10s '/dev/%s/%s' [%s]\n", "LV", vg.Name, lv.Name, humanSize(extents*vg.ExtentSize*512))
+		}
+	}
+}
+
+// humanSize formats n bytes like LVM does, e.g. 1.00 GiB.
+func humanSize(n uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	f, u := float64(n), 0
+	for f >= 1024 && u < len(units)-1 {
+		f /= 1024
+		u++
+	}
+	return fmt.Sprintf("%.2f %s", f, units[u])
+}
+
+// change (de)activates lv and reports what it did.
+func (c *cmd) change(vg *lvm.VG, lv *lvm.LV) error {
+	if c.activate == "n" {
+		if err := c.remove(vg, lv); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stderr, "  %s/%s deactivated\n", vg.Name, lv.Name)
+		return nil
+	}
+	d, err := c.create(vg, lv, c.readOnly)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "  %s/%s activated as %s\n", vg.Name, lv.Name, d.DevicePath())
+	return nil
+}
+
+func (c *cmd) vgchange(vgs []*lvm.VG) error {
+	want := map[string]bool{}
+	for _, a := range c.args {
+		want[a] = true
+	}
+	var errs error
+	for _, vg := range vgs {
+		if len(want) != 0 && !want[vg.Name] {
+			continue
+		}
+		delete(want, vg.Name)
+		for _, lv := range vg.LVs {
+			if lv.Visible() {
+				errs = errors.Join(errs, c.change(vg, lv))
+			}
+		}
+	}
+	for name := range want {
+		errs = errors.Join(errs, fmt.Errorf("volume group %q not found", name))
+	}
+	return errs
+}
+
+func (c *cmd) lvchange(vgs []*lvm.VG) error {
+	var errs error
+	for _, a := range c.args {
+		vgName, lvName, _ := strings.Cut(a, "/")
+		var lv *lvm.LV
+		var vg *lvm.VG
+		for _, v := range vgs {
+			if v.Name == vgName {
+				vg, lv = v, v.LV(lvName)
+			}
+		}
+		if lv == nil {
+			errs = errors.Join(errs, fmt.Errorf("logical volume %q not found", a))
+			continue
+		}
+		errs = errors.Join(errs, c.change(vg, lv))
+	}
+	return errs
+}
+
+func (c *cmd) run() error {
+	vgs, err := c.scan()
+	if err != nil {
+		// Report unreadable devices but carry on with what was found.
+		fmt.Fprintf(c.stderr, "lvm: %v\n", err)
+	}
+	switch c.op {
+	case "pvscan":
+		c.pvscan(vgs)
+	case "lvscan":
+		c.lvscan(vgs)
+	case "vgchange":
+		return c.vgchange(vgs)
+	case "lvchange":
+		return c.lvchange(vgs)
+	}
+	return nil
+}
+
+func command(stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{
+		stdout: stdout,
+		stderr: stderr,
+		scan:   scanDevices,
+		create: (*lvm.VG).Activate,
+		remove: (*lvm.VG).Deactivate,
+	}
+	if len(args) < 2 {
+		return nil, errUsage
+	}
+	c.op = args[1]
+	f := flag.NewFlagSet(c.op, flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.StringVar(&c.activate, "a", "", "activate (y) or deactivate (n)")
+	f.BoolVar(&c.readOnly, "r", false, "activate read-only")
+	var fargs []string
+	for _, a := range args[2:] {
+		// -ay and -an are the usual spellings.
+		if a == "-ay" || a == "-an" {
+			fargs = append(fargs, "-a", a[2:])
+			continue
+		}
+		fargs = append(fargs, a)
+	}
+	if err := f.Parse(unixflag.ArgsToGoArgs(fargs)); err != nil {
+		return nil, err
+	}
+	c.args = f.Args()
+
+	switch c.op {
+	case "pvscan", "lvscan":
+		if c.activate != "" || len(c.args) != 0 {
+			return nil, errUsage
+		}
+	case "vgchange", "lvchange":
+		if c.activate != "y" && c.activate != "n" {
+			return nil, errUsage
+		}
+		if c.op == "lvchange" && len(c.args) == 0 {
+			return nil, errUsage
+		}
+	default:
+		return nil, errUsage
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
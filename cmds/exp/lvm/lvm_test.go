@@ -0,0 +1,149 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/lvm"
+)
+
+const metadata = `vg0 {
+	id = "Zf3tQp-8cJm-1xYw-Lr2v-0aNd-Hk5u-Qe7bWs"
+	seqno = 4
+	extent_size = 8192
+	physical_volumes {
+		pv0 {
+			id = "q8WvCx-3LkT-Rf9n-Ue2d-Ym7a-Hs4p-Gb1zKo"
+			pe_start = 2048
+			pe_count = 256
+		}
+	}
+	logical_volumes {
+		root {
+			id = "Ab1cDe-2fGh-3iJk-4lMn-5oPq-6rSt-7uVwXy"
+			status = ["READ", "WRITE", "VISIBLE"]
+			segment1 {
+				start_extent = 0
+				extent_count = 128
+				type = "striped"
+				stripes = ["pv0", 0]
+			}
+		}
+		swap {
+			id = "Zy9xWv-8uTs-7rQp-6oNm-5lKj-4iHg-3fEdCb"
+			status = ["READ", "WRITE", "VISIBLE"]
+			segment1 {
+				start_extent = 0
+				extent_count = 3
+				type = "striped"
+				stripes = ["pv0", 128]
+			}
+		}
+		hidden {
+			id = "Qw1eRt-2yUi-3oPa-4sDf-5gHj-6kLz-7xCvBn"
+			status = ["READ", "WRITE"]
+			segment1 {
+				start_extent = 0
+				extent_count = 1
+				type = "striped"
+				stripes = ["pv0", 131]
+			}
+		}
+	}
+}
+`
+
+func testCmd(t *testing.T, args ...string) (*cmd, *bytes.Buffer, *[]string) {
+	t.Helper()
+	var out bytes.Buffer
+	c, err := command(&out, &out, append([]string{"lvm"}, args...))
+	if err != nil {
+		t.Fatalf("command(%q) = %v", args, err)
+	}
+	var calls []string
+	c.scan = func() ([]*lvm.VG, error) {
+		vg, err := lvm.ParseVG([]byte(metadata))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vg.PVs[0].Device = "/dev/sda2"
+		return []*lvm.VG{vg}, nil
+	}
+	c.create = func(vg *lvm.VG, lv *lvm.LV, readOnly bool) (*dm.Device, error) {
+		mode := "rw"
+		if readOnly {
+			mode = "ro"
+		}
+		calls = append(calls, "create "+vg.DMName(lv)+" "+mode)
+		return &dm.Device{Name: vg.DMName(lv), Minor: uint32(len(calls) - 1)}, nil
+	}
+	c.remove = func(vg *lvm.VG, lv *lvm.LV) error {
+		calls = append(calls, "remove "+vg.DMName(lv))
+		return nil
+	}
+	return c, &out, &calls
+}
+
+func TestScan(t *testing.T) {
+	for _, tt := range []struct {
+		op   string
+		want string
+	}{
+		{op: "pvscan", want: "  PV /dev/sda2        VG vg0          lvm2 [1.00 GiB]\n"},
+		{op: "lvscan", want: "  LV         '/dev/vg0/root' [512.00 MiB]\n  LV         '/dev/vg0/swap' [12.00 MiB]\n"},
+	} {
+		c, out, _ := testCmd(t, tt.op)
+		if err := c.run(); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("lvm %s printed\n%q\nwant\n%q", tt.op, out.String(), tt.want)
+		}
+	}
+}
+
+func TestChange(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want []string
+		err  bool
+	}{
+		{args: []string{"vgchange", "-ay"}, want: []string{"create vg0-root rw", "create vg0-swap rw"}},
+		{args: []string{"vgchange", "-a", "y", "-r", "vg0"}, want: []string{"create vg0-root ro", "create vg0-swap ro"}},
+		{args: []string{"vgchange", "-an", "vg0"}, want: []string{"remove vg0-root", "remove vg0-swap"}},
+		{args: []string{"vgchange", "-ay", "vg1"}, err: true},
+		{args: []string{"lvchange", "-ay", "vg0/swap"}, want: []string{"create vg0-swap rw"}},
+		{args: []string{"lvchange", "-an", "vg0/hidden", "vg0/root"}, want: []string{"remove vg0-hidden", "remove vg0-root"}},
+		{args: []string{"lvchange", "-ay", "vg0/home"}, err: true},
+	} {
+		c, _, calls := testCmd(t, tt.args...)
+		if err := c.run(); (err != nil) != tt.err {
+			t.Errorf("lvm %q = %v, want error %v", tt.args, err, tt.err)
+		}
+		if !reflect.DeepEqual(*calls, tt.want) {
+			t.Errorf("lvm %q did %q, want %q", tt.args, *calls, tt.want)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"lvm"},
+		{"lvm", "lvcreate"},
+		{"lvm", "pvscan", "/dev/sda"},
+		{"lvm", "vgchange"},
+		{"lvm", "vgchange", "-a", "x"},
+		{"lvm", "lvchange", "-ay"},
+	} {
+		if _, err := command(&bytes.Buffer{}, &bytes.Buffer{}, args); !errors.Is(err, errUsage) {
+			t.Errorf("command(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mdadm examines and assembles Linux software RAID arrays.
+//
+// Synopsis:
+//
+//	mdadm -E|--examine DEVICE...
+//	mdadm -A|--assemble [-o|--readonly] [-R|--run] MD DEVICE...
+//	mdadm -A|--assemble --scan [-o|--readonly] [-R|--run]
+//	mdadm -S|--stop MD...
+//
+// Description:
+//
+//	mdadm only starts existing arrays; it does not create, grow or repair
+//	them. With --scan, all block devices are examined and every array
+//	found is started on /dev/mdN, where N comes from array names such as
+//	host:0, or counts down from 127.
+//
+//	Members that missed updates of the array are left out. Arrays with
+//	missing members are only started with --run.
+//
+// Options:
+//
+//	-o, --readonly:  start the array read-only; nothing is written to
+//	                 the members, not even their superblocks
+//	-R, --run:       start arrays even if they are degraded
+//	--scan:          assemble all arrays found on block devices
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mount/md"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var (
+	errUsage    = errors.New("usage: mdadm -E DEVICE... | -A [-oR] MD DEVICE... | -A --scan [-oR] | -S MD...")
+	errDegraded = errors.New("array is degraded, use --run to start it anyway")
+)
+
+type cmd struct {
+	stdout, stderr io.Writer
+	examine        bool
+	assemble       bool
+	stop           bool
+	scan           bool
+	readOnly       bool
+	force          bool
+	args           []string
+
+	// devices lists the block devices --scan examines.
+	devices func() ([]string, error)
+}
+
+// listDevices returns the block devices that may hold array members and
+// are not in use by an array yet.
+func listDevices() ([]string, error) {
+	attrs, err := block.ListAttrs()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, a := range attrs {
+		if a.Size == 0 || len(a.Holders) != 0 {
+			continue
+		}
+		switch a.Type {
+		case "disk", "part", "loop":
+			paths = append(paths, filepath.Join("/dev", a.Name))
+		}
+	}
+	return paths, nil
+}
+
+func role(r int) string {
+	switch r {
+	case md.RoleSpare:
+		return "spare"
+	case md.RoleFaulty:
+		return "faulty"
+	case md.RoleJournal:
+		return "journal"
+	}
+	return fmt.Sprintf("Active device %d", r)
+}
+
+func (c *cmd) printSuperblock(path string, s *md.Superblock) {
+	fmt.Fprintf(c.stdout, "%s:\n", path)
+	line := func(k, format string, v ...interface{}) {
+		fmt.Fprintf(c.stdout, "%15s : %s\n", k, fmt.Sprintf(format, v...))
+	}
+	line("Magic", "%x", uint32(md.Magic))
+	line("Version", "%s", s.Version)
+	line("Array UUID", "%s", s.UUIDString())
+	if s.Name != "" {
+		line("Name", "%s", s.Name)
+	}
+	line("Creation Time", "%s", s.Created.Format(time.ANSIC))
+	line("Raid Level", "%s", md.LevelName(s.Level))
+	line("Raid Devices", "%d", s.RaidDisks)
+	if s.ChunkSize != 0 {
+		line("Chunk Size", "%dK", s.ChunkSize/1024)
+	}
+	if s.DataSize != 0 {
+		line("Used Dev Size", "%d sectors", s.DataSize)
+	}
+	line("Data Offset", "%d sectors", s.DataOffset)
+	line("Update Time", "%s", s.Updated.Format(time.ANSIC))
+	line("Events", "%d", s.Events)
+	line("Device Role", "%s", role(s.Role))
+}
+
+func (c *cmd) examineDevices() error {
+	var errs error
+	for _, p := range c.args {
+		s, err := md.Examine(p)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		c.printSuperblock(p, s)
+	}
+	return errs
+}
+
+// start assembles a on the md device mdPath.
+func (c *cmd) start(a *md.Array, mdPath string) error {
+	cur := a.Current()
+	if len(cur) == 0 {
+		return fmt.Errorf("%s: %w", mdPath, md.ErrNoMembers)
+	}
+	if a.Degraded() && !c.force {
+		return fmt.Errorf("%s: %w", mdPath, errDegraded)
+	}
+	for _, m := range a.Members {
+		if m.Events != cur[0].Events && m.Role != md.RoleFaulty {
+			fmt.Fprintf(c.stderr, "mdadm: %s is out of date, leaving it out\n", m.Path)
+		}
+	}
+	if err := a.Assemble(mdPath, c.readOnly); err != nil {
+		return err
+	}
+	mode := ""
+	if c.readOnly {
+		mode = " read-only"
+	}
+	fmt.Fprintf(c.stderr, "mdadm: %s has been started%s with %d drives.\n", mdPath, mode, len(cur))
+	return nil
+}
+
+// mdPath picks the md device for an array found by --scan.
+func mdPath(a *md.Array, used map[int]bool) (string, error) {
+	// Names are host:name; a numeric name is the minor number.
+	_, name, _ := strings.Cut(a.Name(), ":")
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && !used[n] && !md.InUse(n) {
+		used[n] = true
+		return fmt.Sprintf("/dev/md%d", n), nil
+	}
+	for n := 127; n >= 0; n-- {
+		if !used[n] && !md.InUse(n) {
+			used[n] = true
+			return fmt.Sprintf("/dev/md%d", n), nil
+		}
+	}
+	return "", errors.New("no free md device")
+}
+
+func (c *cmd) assembleArrays() error {
+	if !c.scan {
+		arrays := md.Scan(c.args[1:])
+		switch len(arrays) {
+		case 0:
+			return fmt.Errorf("%w among %s", md.ErrNoSuperblock, strings.Join(c.args[1:], ", "))
+		case 1:
+		default:
+			return fmt.Errorf("devices belong to %d different arrays", len(arrays))
+		}
+		return c.start(arrays[0], c.args[0])
+	}
+
+	devices, err := c.devices()
+	if err != nil {
+		return err
+	}
+	var errs error
+	used := map[int]bool{}
+	for _, a := range md.Scan(devices) {
+		p, err := mdPath(a, used)
+		if err == nil {
+			err = c.start(a, p)
+		}
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
+
+func (c *cmd) run() error {
+	switch {
+	case c.examine:
+		return c.examineDevices()
+	case c.assemble:
+		return c.assembleArrays()
+	}
+	var errs error
+	for _, p := range c.args {
+		errs = errors.Join(errs, md.Stop(p))
+	}
+	return errs
+}
+
+func command(stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, stderr: stderr, devices: listDevices}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&c.examine, "E", false, "examine member superblocks")
+	f.BoolVar(&c.examine, "examine", false, "examine member superblocks")
+	f.BoolVar(&c.assemble, "A", false, "assemble an array")
+	f.BoolVar(&c.assemble, "assemble", false, "assemble an array")
+	f.BoolVar(&c.stop, "S", false, "stop arrays")
+	f.BoolVar(&c.stop, "stop", false, "stop arrays")
+	f.BoolVar(&c.scan, "scan", false, "assemble all arrays found")
+	f.BoolVar(&c.readOnly, "o", false, "start read-only")
+	f.BoolVar(&c.readOnly, "readonly", false, "start read-only")
+	f.BoolVar(&c.force, "R", false, "start degraded arrays")
+	f.BoolVar(&c.force, "run", false, "start degraded arrays")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return nil, err
+	}
+	c.args = f.Args()
+
+	modes := 0
+	for _, m := range []bool{c.examine, c.assemble, c.stop} {
+		if m {
+			modes++
+		}
+	}
+	switch {
+	case modes != 1:
+		return nil, errUsage
+	case c.assemble && c.scan && len(c.args) != 0:
+		return nil, errUsage
+	case c.assemble && !c.scan && len(c.args) < 2:
+		return nil, errUsage
+	case !c.assemble && len(c.args) == 0:
+		return nil, errUsage
+	}
+	if c.assemble && !c.scan {
+		if _, err := md.Minor(c.args[0]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
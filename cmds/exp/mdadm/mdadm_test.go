@@ -0,0 +1,119 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/md"
+)
+
+// member writes an image with a 0.90 superblock of a two disk RAID1.
+func member(t *testing.T, name string, raidDisk, events uint32) string {
+	t.Helper()
+	img := make([]byte, 1<<20)
+	sb := img[len(img)-64<<10:]
+	ctime := uint32(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Unix())
+	for i, v := range map[int]uint32{
+		0: md.Magic, 2: 90, 5: 0xa1b2c3d4, 6: ctime, 7: 1, 8: 448, 10: 2,
+		13: 1, 14: 2, 15: 3, 32: ctime, 39: events,
+		992 + 3: raidDisk, 992 + 4: 6,
+	} {
+		binary.LittleEndian.PutUint32(sb[4*i:], v)
+	}
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, img, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExamine(t *testing.T) {
+	p := member(t, "sda1", 1, 42)
+	var stdout, stderr bytes.Buffer
+	c, err := command(&stdout, &stderr, []string{"mdadm", "--examine", p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	want := p + `:
+          Magic : a92b4efc
+        Version : 0.90
+     Array UUID : a1b2c3d4:00000001:00000002:00000003
+  Creation Time : Fri Mar  1 12:00:00 2024
+     Raid Level : raid1
+   Raid Devices : 2
+  Used Dev Size : 896 sectors
+    Data Offset : 0 sectors
+    Update Time : Fri Mar  1 12:00:00 2024
+         Events : 42
+    Device Role : Active device 1
+`
+	if stdout.String() != want {
+		t.Errorf("mdadm -E printed\n%s\nwant\n%s", stdout.String(), want)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, make([]byte, 1<<20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = command(&stdout, &stderr, []string{"mdadm", "-E", empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); !errors.Is(err, md.ErrNoSuperblock) {
+		t.Errorf("mdadm -E empty = %v, want %v", err, md.ErrNoSuperblock)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	a := member(t, "a", 0, 10)
+	stale := member(t, "b", 1, 9)
+	for _, tt := range []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"-A", "/dev/md0", a, stale}, errDegraded},
+		{[]string{"-A", "/dev/md0", filepath.Join(t.TempDir(), "x")}, md.ErrNoSuperblock},
+	} {
+		var stdout, stderr bytes.Buffer
+		c, err := command(&stdout, &stderr, append([]string{"mdadm"}, tt.args...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.run(); !errors.Is(err, tt.err) {
+			t.Errorf("mdadm %s = %v, want %v", strings.Join(tt.args, " "), err, tt.err)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-E"},
+		{"-E", "-A", "/dev/sda"},
+		{"-A", "/dev/md0"},
+		{"-A", "--scan", "/dev/md0"},
+		{"-S"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if _, err := command(&stdout, &stderr, append([]string{"mdadm"}, args...)); !errors.Is(err, errUsage) {
+			t.Errorf("mdadm %s = %v, want %v", strings.Join(args, " "), err, errUsage)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	if _, err := command(&stdout, &stderr, []string{"mdadm", "-A", "/dev/sda", "/dev/sdb"}); err == nil {
+		t.Errorf("mdadm -A /dev/sda /dev/sdb = nil, want error")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mkfs creates a FAT or ext4 file system.
+//
+// Synopsis:
+//
+//	mkfs [-t vfat|ext4] [OPTIONS] DEVICE [SIZE]
+//	mkfs.vfat [-F 12|16|32] [-s SPC] [-n LABEL] [-i ID] DEVICE [SIZE]
+//	mkfs.ext4 [-b BLOCKSIZE] [-i BYTES] [-L LABEL] [-U UUID] [-z] DEVICE [SIZE]
+//
+// Description:
+//
+//	DEVICE is a block device or an image file. SIZE limits the file
+//	system to SIZE bytes, which may have a K, M or G suffix; image files
+//	are created or grown to SIZE. Without SIZE, the file system takes up
+//	all of DEVICE.
+//
+//	Invoked as mkfs.vfat, mkfs.fat, mkfs.msdos or mkfs.ext4 through a
+//	link, mkfs creates that file system type without -t.
+//
+// Options:
+//
+//	-t:        file system type: vfat or ext4
+//	-n, -L:    volume label
+//	-F:        FAT entry size: 12, 16 or 32 (default: by size)
+//	-s:        sectors per FAT cluster (default: by size)
+//	-i:        vfat: volume ID in hex; ext4: bytes per inode (default: 16384)
+//	-b:        ext4 block size (default: by size)
+//	-U:        ext4 file system UUID (default: random)
+//	-z:        zero all ext4 inode tables instead of leaving it to the kernel
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mkfs"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+var (
+	errUsage = errors.New("usage: mkfs [-t vfat|ext4] [OPTIONS] DEVICE [SIZE]")
+	errType  = errors.New("unsupported file system type")
+)
+
+// types maps the names mkfs can be invoked as to file system types.
+var types = map[string]string{
+	"mkfs.vfat":  "vfat",
+	"mkfs.fat":   "vfat",
+	"mkfs.msdos": "vfat",
+	"mkfs.ext4":  "ext4",
+}
+
+type cmd struct {
+	fsType    string
+	label     string
+	fatBits   int
+	spc       int
+	id        string
+	blockSize int
+	uuid      string
+	zero      bool
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	shift := 0
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift != 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 || n > 1<<(63-shift)-1 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
+
+func parseUUID(s string) ([16]byte, error) {
+	var u [16]byte
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != len(u) {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func (c *cmd) format(w io.WriterAt, size int64) error {
+	switch c.fsType {
+	case "vfat", "fat", "msdos":
+		o := mkfs.FATOptions{Bits: c.fatBits, SectorsPerCluster: c.spc, Label: c.label}
+		if c.id != "" {
+			id, err := strconv.ParseUint(strings.ReplaceAll(c.id, "-", ""), 16, 32)
+			if err != nil {
+				return fmt.Errorf("invalid volume ID %q", c.id)
+			}
+			o.VolumeID = uint32(id)
+		}
+		return mkfs.FAT(w, size, o)
+
+	case "ext4":
+		o := mkfs.Ext4Options{BlockSize: c.blockSize, Label: c.label, ZeroInodeTables: c.zero}
+		if c.id != "" {
+			bpi, err := strconv.Atoi(c.id)
+			if err != nil {
+				return fmt.Errorf("invalid bytes per inode %q", c.id)
+			}
+			o.BytesPerInode = bpi
+		}
+		if c.uuid != "" {
+			u, err := parseUUID(c.uuid)
+			if err != nil {
+				return err
+			}
+			o.UUID = u
+		}
+		return mkfs.Ext4(w, size, o)
+	}
+	return fmt.Errorf("%w: %q", errType, c.fsType)
+}
+
+func run(stderr io.Writer, args []string) error {
+	c := &cmd{fsType: types[filepath.Base(args[0])]}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.StringVar(&c.fsType, "t", c.fsType, "file system type: vfat or ext4")
+	f.StringVar(&c.label, "n", "", "volume label")
+	f.StringVar(&c.label, "L", "", "volume label")
+	f.IntVar(&c.fatBits, "F", 0, "FAT entry size: 12, 16 or 32")
+	f.IntVar(&c.spc, "s", 0, "sectors per FAT cluster")
+	f.StringVar(&c.id, "i", "", "vfat: volume ID in hex; ext4: bytes per inode")
+	f.IntVar(&c.blockSize, "b", 0, "ext4 block size")
+	f.StringVar(&c.uuid, "U", "", "ext4 file system UUID")
+	f.BoolVar(&c.zero, "z", false, "zero all ext4 inode tables")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return err
+	}
+	switch c.fsType {
+	case "":
+		c.fsType = "ext4"
+	case "vfat", "fat", "msdos", "ext4":
+	default:
+		return fmt.Errorf("%w: %q", errType, c.fsType)
+	}
+	if f.NArg() < 1 || f.NArg() > 2 {
+		return errUsage
+	}
+
+	var size int64
+	flags := os.O_RDWR
+	if f.NArg() == 2 {
+		var err error
+		if size, err = parseSize(f.Arg(1)); err != nil {
+			return err
+		}
+		flags |= os.O_CREATE
+	}
+	dev, err := os.OpenFile(f.Arg(0), flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	// Seeking to the end also tells the size of block devices.
+	end, err := dev.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	fi, err := dev.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case size == 0:
+		size = end
+	case fi.Mode().IsRegular() && size > end:
+		if err := dev.Truncate(size); err != nil {
+			return err
+		}
+	case size > end:
+		return fmt.Errorf("%s has only %d bytes", f.Arg(0), end)
+	}
+
+	if err := c.format(dev, size); err != nil {
+		return err
+	}
+	return dev.Sync()
+}
+
+func main() {
+	if err := run(os.Stderr, os.Args); err != nil {
+		log.Fatalf("mkfs: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "4096", want: 4096},
+		{in: "64K", want: 64 << 10},
+		{in: "32m", want: 32 << 20},
+		{in: "2G", want: 2 << 30},
+		{in: "", err: true},
+		{in: "G", err: true},
+		{in: "-1", err: true},
+		{in: "1T", err: true},
+	} {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMkfs(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		args  []string
+		off   int64
+		magic []byte
+		size  int64
+		err   error
+	}{
+		{args: []string{"mkfs.vfat", "-n", "efi", "-i", "1234-abcd", "esp.img", "8M"}, off: 0x27, magic: []byte{0xcd, 0xab, 0x34, 0x12}, size: 8 << 20},
+		{args: []string{"mkfs", "-t", "vfat", "-F", "32", "esp32.img", "40M"}, off: 0x52, magic: []byte("FAT32"), size: 40 << 20},
+		{args: []string{"mkfs.ext4", "-L", "root", "root.img", "16M"}, off: 0x438, magic: []byte{0x53, 0xef}, size: 16 << 20},
+		{args: []string{"mkfs", "-t", "ext4", "-b", "4096", "-U", "01020304-0506-0708-090a-0b0c0d0e0f10", "uuid.img", "16M"}, off: 0x468, magic: []byte{1, 2, 3, 4}, size: 16 << 20},
+		{args: []string{"mkfs", "-t", "btrfs", "x.img", "16M"}, err: errType},
+		{args: []string{"mkfs", "a", "b", "c"}, err: errUsage},
+	} {
+		args := append([]string(nil), tt.args...)
+		if tt.err == nil {
+			args[len(args)-2] = filepath.Join(dir, args[len(args)-2])
+		}
+		if err := run(io.Discard, args); !errors.Is(err, tt.err) {
+			t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		f, err := os.Open(args[len(args)-2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		b := make([]byte, len(tt.magic))
+		if _, err := f.ReadAt(b, tt.off); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(tt.magic) {
+			t.Errorf("run(%q) wrote % x at %#x, want % x", tt.args, b, tt.off, tt.magic)
+		}
+		if fi, err := f.Stat(); err != nil || fi.Size() != tt.size {
+			t.Errorf("run(%q) left a %d byte image, want %d", tt.args, fi.Size(), tt.size)
+		}
+	}
+}
@@ -8,6 +8,18 @@
 //
 //	modprobe [-n] modulename [parameters...]
 //	modprobe [-n] -a modulename...
+//	modprobe [-n] -r modulename...
+//
+// Description:
+//
+//	modprobe loads a module and, first, the modules it depends on, as
+//	listed in modules.dep. Compressed modules (.ko.gz, .ko.xz, .ko.zst)
+//	are decompressed. A module can also be named by an alias from
+//	modules.alias, e.g. the modalias of a device, which loads all the
+//	modules matching it.
+//
+//	With -r, modprobe unloads the modules and then the modules they
+//	depended on which are no longer used.
 //
 // Author:
 //
@@ -23,11 +35,12 @@ import (
 	"github.com/u-root/u-root/pkg/kmodule"
 )
 
-const cmd = "modprobe [-an] modulename[s] [parameters...]"
+const cmd = "modprobe [-anr] modulename[s] [parameters...]"
 
 var (
 	dryRun     = flag.Bool("n", false, "Dry run")
 	all        = flag.Bool("a", false, "Insert all module names on the command line.")
+	remove     = flag.Bool("r", false, "Remove the modules on the command line, and their unused dependencies.")
 	verboseAll = flag.Bool("va", false, "Insert all module names on the command line.")
 	rootDir    = flag.String("d", "/", "Root directory for modules")
 	kernelVer  = flag.String("S", "", "Set kernel version instead of using uname")
@@ -54,6 +67,26 @@ func main() {
 		RootDir: *rootDir,
 		KVer:    *kernelVer,
 	}
+	if *remove {
+		if *dryRun {
+			log.Println("Modules in unload order:")
+			opts.DryRunCB = func(name string) {
+				log.Println(name)
+			}
+		}
+		var failed bool
+		for _, modName := range flag.Args() {
+			if err := kmodule.RemoveOptions(modName, opts); err != nil {
+				log.Printf("modprobe: Could not remove module %q: %v", modName, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *dryRun {
 		log.Println("Unique dependencies in load order, already loaded ones get skipped:")
 		opts.DryRunCB = func(modPath string) {
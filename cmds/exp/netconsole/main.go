@@ -0,0 +1,107 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// netconsole sends the kernel log to a UDP collector.
+//
+// Synopsis:
+//
+//	netconsole [-kernel] [-name NAME] [-level N] [-n] [-x] TARGET
+//
+// Description:
+//
+//	TARGET is [src-port]@[src-ip]/[dev],[tgt-port]@<tgt-ip>/[tgt-macaddr],
+//	as for the netconsole kernel module, or just tgt-ip[:tgt-port].
+//
+//	By default, netconsole forwards /dev/kmsg from userspace, from the
+//	start of the log, and keeps following it. Messages init writes to the
+//	kernel log, e.g. with uroot.console=...,netconsole, are forwarded too.
+//
+//	With -kernel, netconsole instead adds a target to the netconsole
+//	module through configfs and exits. The kernel then sends messages
+//	itself, including those of panics.
+//
+// Options:
+//
+//	-kernel: configure the kernel netconsole module
+//	-name:   name of the kernel target
+//	-level:  most verbose log level to forward (default 7)
+//	-n:      exit at the end of the log rather than follow it
+//	-x:      send extended messages with the record metadata
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/netconsole"
+)
+
+var (
+	kernel   = flag.Bool("kernel", false, "configure the kernel netconsole module instead of forwarding from userspace")
+	name     = flag.String("name", "uroot", "name of the kernel netconsole target")
+	level    = flag.Int("level", 7, "most verbose log level to forward")
+	noFollow = flag.Bool("n", false, "exit at the end of the kernel log")
+	extended = flag.Bool("x", false, "send extended messages")
+)
+
+// parseTarget accepts the netconsole module syntax, or host[:port].
+func parseTarget(s string) (*netconsole.Target, error) {
+	if strings.Contains(s, ",") {
+		return netconsole.ParseTarget(s)
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = s, ""
+	}
+	t := &netconsole.Target{RemoteIP: net.ParseIP(strings.Trim(host, "[]"))}
+	if t.RemoteIP == nil {
+		return nil, fmt.Errorf("%w: %q", netconsole.ErrInvalidTarget, s)
+	}
+	if port != "" {
+		if t.RemotePort, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("%w: port %q", netconsole.ErrInvalidTarget, port)
+		}
+	}
+	return t, nil
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: netconsole [-kernel] [-name NAME] [-level N] [-n] [-x] TARGET")
+	}
+	t, err := parseTarget(args[0])
+	if err != nil {
+		return err
+	}
+	t.Extended = t.Extended || *extended
+
+	if *kernel {
+		return netconsole.Configure(*name, t)
+	}
+
+	c, err := netconsole.Dial(t)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	kmsg, err := netconsole.OpenKmsg(!*noFollow)
+	if err != nil {
+		return err
+	}
+	defer kmsg.Close()
+	return netconsole.Forward(c, kmsg, *level, t.Extended)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		ip   net.IP
+		port int
+		dev  string
+	}{
+		{in: "10.0.0.2", ip: net.ParseIP("10.0.0.2")},
+		{in: "10.0.0.2:514", ip: net.ParseIP("10.0.0.2"), port: 514},
+		{in: "[2001:db8::1]:6000", ip: net.ParseIP("2001:db8::1"), port: 6000},
+		{in: "@/eth1,@10.0.0.2/", ip: net.ParseIP("10.0.0.2"), dev: "eth1"},
+	} {
+		got, err := parseTarget(tt.in)
+		if err != nil {
+			t.Errorf("parseTarget(%q) = %v", tt.in, err)
+			continue
+		}
+		if !got.RemoteIP.Equal(tt.ip) || got.RemotePort != tt.port || got.Dev != tt.dev {
+			t.Errorf("parseTarget(%q) = %+v", tt.in, got)
+		}
+	}
+	if _, err := parseTarget("collector"); err == nil {
+		t.Errorf("parseTarget(collector) = nil, want error")
+	}
+}
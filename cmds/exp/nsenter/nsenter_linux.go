@@ -0,0 +1,266 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nsenter runs a program in the namespaces of another process.
+//
+// Synopsis:
+//
+//	nsenter -t PID [-a] [-m] [-u] [-i] [-n] [-p] [-C] [-r] [-w] [-S UID] [-G GID] [PROGRAM [ARGS]...]
+//
+// Description:
+//
+//	nsenter joins the namespaces of process PID and starts PROGRAM, by
+//	default $SHELL or /bin/sh, in them. A namespace option may also name
+//	the namespace file to join, as in -n=/run/netns/test.
+//
+//	The namespaces are joined by a thread of nsenter, which then starts
+//	PROGRAM. Joining a user namespace needs a single-threaded process,
+//	which a Go program never is, so -U is not supported; use unshare
+//	-user to make one.
+//
+// Options:
+//
+//	-t: the target process
+//	-a: join all the namespaces of the target, but its user namespace
+//	-m: join the mount namespace
+//	-u: join the UTS namespace
+//	-i: join the IPC namespace
+//	-n: join the network namespace
+//	-p: join the pid namespace; PROGRAM is started in it
+//	-C: join the cgroup namespace
+//	-r: use the root directory of the target
+//	-w: use the working directory of the target
+//	-S: the UID to run PROGRAM as
+//	-G: the GID to run PROGRAM as
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	errUsage = errors.New("usage: nsenter -t PID [-a] [-m] [-u] [-i] [-n] [-p] [-C] [-r] [-w] [-S UID] [-G GID] [PROGRAM [ARGS]...]")
+	errUser  = errors.New("joining a user namespace needs a single-threaded process, which a Go program is not")
+)
+
+// namespace is a kind of namespace, with the file to join, if any.
+type namespace struct {
+	option byte
+	name   string // in /proc/PID/ns
+	flag   int
+	file   string
+}
+
+// nsFlag is the flag of a namespace. It is a boolean flag, so that it can
+// be given without a file.
+type nsFlag struct {
+	ns *namespace
+}
+
+func (f *nsFlag) String() string   { return "" }
+func (f *nsFlag) IsBoolFlag() bool { return true }
+
+func (f *nsFlag) Set(s string) error {
+	switch s {
+	case "false":
+		f.ns.file = ""
+	case "true":
+		// The file is that of the target, once it is known.
+		f.ns.file = "-"
+	default:
+		f.ns.file = s
+	}
+	return nil
+}
+
+type cmd struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	target int
+	all    bool
+	root   bool
+	wd     bool
+	uid    int
+	gid    int
+	// namespaces are in the order they are joined: the mount namespace
+	// last, as the others may be named by files in the current one.
+	namespaces []*namespace
+	args       []string
+}
+
+func command(stdin io.Reader, stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		namespaces: []*namespace{
+			{option: 'U', name: "user", flag: unix.CLONE_NEWUSER},
+			{option: 'C', name: "cgroup", flag: unix.CLONE_NEWCGROUP},
+			{option: 'i', name: "ipc", flag: unix.CLONE_NEWIPC},
+			{option: 'u', name: "uts", flag: unix.CLONE_NEWUTS},
+			{option: 'n', name: "net", flag: unix.CLONE_NEWNET},
+			{option: 'p', name: "pid", flag: unix.CLONE_NEWPID},
+			{option: 'm', name: "mnt", flag: unix.CLONE_NEWNS},
+		},
+	}
+	f := flag.NewFlagSet("nsenter", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.IntVar(&c.target, "t", 0, "the target process")
+	f.BoolVar(&c.all, "a", false, "join all the namespaces of the target, but its user namespace")
+	f.BoolVar(&c.root, "r", false, "use the root directory of the target")
+	f.BoolVar(&c.wd, "w", false, "use the working directory of the target")
+	f.IntVar(&c.uid, "S", -1, "the UID to run as")
+	f.IntVar(&c.gid, "G", -1, "the GID to run as")
+	for _, ns := range c.namespaces {
+		f.Var(&nsFlag{ns}, string(ns.option), "join the "+ns.name+" namespace")
+	}
+	if err := f.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	c.args = f.Args()
+	if len(c.args) == 0 {
+		sh := os.Getenv("SHELL")
+		if sh == "" {
+			sh = "/bin/sh"
+		}
+		c.args = []string{sh}
+	}
+
+	for _, ns := range c.namespaces {
+		if c.all && ns.file == "" && ns.option != 'U' {
+			ns.file = "-"
+		}
+		if ns.file == "-" {
+			if c.target <= 0 {
+				return nil, fmt.Errorf("%w: -%c needs -t or a file", errUsage, ns.option)
+			}
+			ns.file = filepath.Join("/proc", strconv.Itoa(c.target), "ns", ns.name)
+		}
+		if ns.option == 'U' && ns.file != "" {
+			return nil, errUser
+		}
+	}
+	if (c.root || c.wd) && c.target <= 0 {
+		return nil, fmt.Errorf("%w: -r and -w need -t", errUsage)
+	}
+	return c, nil
+}
+
+// same returns whether the namespace file is that of this process, which
+// need not, and for a user namespace, cannot, be joined.
+func same(file, name string) bool {
+	a, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(filepath.Join("/proc/self/ns", name))
+	return err == nil && os.SameFile(a, b)
+}
+
+// run joins the namespaces on a thread which is never released, and starts
+// the program from it.
+func (c *cmd) run() error {
+	runtime.LockOSThread()
+
+	// Open everything before joining anything, as joining the mount
+	// namespace changes what the paths name.
+	type join struct {
+		ns *namespace
+		f  *os.File
+	}
+	var joins []join
+	for _, ns := range c.namespaces {
+		if ns.file == "" || (c.all && same(ns.file, ns.name)) {
+			continue
+		}
+		f, err := os.Open(ns.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		joins = append(joins, join{ns, f})
+	}
+	var root *os.File
+	var wd string
+	proc := filepath.Join("/proc", strconv.Itoa(c.target))
+	if c.root {
+		var err error
+		if root, err = os.Open(filepath.Join(proc, "root")); err != nil {
+			return err
+		}
+		defer root.Close()
+	}
+	if c.wd {
+		var err error
+		if wd, err = os.Readlink(filepath.Join(proc, "cwd")); err != nil {
+			return err
+		}
+	}
+
+	// This thread gets a root and working directory of its own, which
+	// joining a mount namespace needs, and changing them must not affect
+	// the rest of nsenter.
+	if err := unix.Unshare(unix.CLONE_FS); err != nil {
+		return fmt.Errorf("unshare: %w", err)
+	}
+	for _, j := range joins {
+		if err := unix.Setns(int(j.f.Fd()), j.ns.flag); err != nil {
+			return fmt.Errorf("joining %s namespace %s: %w", j.ns.name, j.ns.file, err)
+		}
+	}
+
+	cmd := exec.Command(c.args[0], c.args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.stdin, c.stdout, c.stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if root != nil {
+		// The program changes its root to the working directory of
+		// this thread.
+		if err := unix.Fchdir(int(root.Fd())); err != nil {
+			return err
+		}
+		cmd.SysProcAttr.Chroot = "."
+		cmd.Dir = "/"
+	}
+	if wd != "" {
+		cmd.Dir = wd
+	}
+	if c.uid >= 0 || c.gid >= 0 {
+		cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+		if c.uid >= 0 {
+			cred.Uid = uint32(c.uid)
+		}
+		if c.gid >= 0 {
+			cred.Gid = uint32(c.gid)
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
+	return cmd.Run()
+}
+
+func main() {
+	c, err := command(os.Stdin, os.Stdout, os.Stderr, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/testsh")
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		files map[string]string
+		run   []string
+		err   error
+	}{
+		{
+			name:  "target",
+			args:  []string{"-t", "42", "-n", "-u", "ip", "a"},
+			files: map[string]string{"net": "/proc/42/ns/net", "uts": "/proc/42/ns/uts"},
+			run:   []string{"ip", "a"},
+		},
+		{
+			name:  "file",
+			args:  []string{"-n=/run/netns/test"},
+			files: map[string]string{"net": "/run/netns/test"},
+			run:   []string{"/bin/testsh"},
+		},
+		{
+			name: "all",
+			args: []string{"-t", "7", "-a", "-n=/run/netns/test"},
+			files: map[string]string{
+				"cgroup": "/proc/7/ns/cgroup", "ipc": "/proc/7/ns/ipc", "uts": "/proc/7/ns/uts",
+				"net": "/run/netns/test", "pid": "/proc/7/ns/pid", "mnt": "/proc/7/ns/mnt",
+			},
+			run: []string{"/bin/testsh"},
+		},
+		{name: "no target", args: []string{"-m"}, err: errUsage},
+		{name: "root without target", args: []string{"-r"}, err: errUsage},
+		{name: "user", args: []string{"-t", "1", "-U"}, err: errUser},
+		{name: "bad flag", args: []string{"-x"}, err: errUsage},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := command(nil, nil, nil, tt.args)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			files := map[string]string{}
+			for _, ns := range c.namespaces {
+				if ns.file != "" {
+					files[ns.name] = ns.file
+				}
+			}
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("got namespaces %v, want %v", files, tt.files)
+			}
+			if !reflect.DeepEqual(c.args, tt.run) {
+				t.Errorf("got program %q, want %q", c.args, tt.run)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("joining namespaces needs root")
+	}
+	if _, err := exec.LookPath("pwd"); err != nil {
+		t.Skip(err)
+	}
+	// Join the namespaces this test is in already, which changes nothing
+	// but can always be done. This goroutine stays on the thread which
+	// joined them, whose working directory is then the root.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := fmt.Sprint(os.Getpid())
+	var stdout, stderr bytes.Buffer
+	c, err := command(nil, &stdout, &stderr, []string{"-t", pid, "-u", "-n", "-m", "-r", "-w", "pwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatalf("%v: %s", err, stderr.String())
+	}
+	if got := strings.TrimSpace(stdout.String()); got != wd {
+		t.Errorf("got working directory %q, want %q", got, wd)
+	}
+}
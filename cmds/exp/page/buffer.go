@@ -0,0 +1,132 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// pollInterval is how often a file is checked for new data at its end.
+var pollInterval = 250 * time.Millisecond
+
+// chunk is data read by the reader, or the end of the data read so far.
+type chunk struct {
+	data []byte
+	eof  bool
+	err  error
+}
+
+// buffer holds all lines read, so that the input can be scrolled back
+// even if it is a pipe.
+type buffer struct {
+	data <-chan chunk
+
+	lines []string
+	// partial is set if the last line has no newline yet.
+	partial bool
+	// eof is set when all the data available has been read, and done if
+	// no more will come.
+	eof  bool
+	done bool
+	err  error
+}
+
+// newBuffer starts reading r. At the end of a regular file the reader
+// keeps polling for data which is appended, as for following logs.
+func newBuffer(r io.Reader) *buffer {
+	ch := make(chan chunk)
+	poll := false
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			poll = true
+		}
+	}
+	go func() {
+		defer close(ch)
+		atEOF := false
+		for {
+			b := make([]byte, 32*1024)
+			n, err := r.Read(b)
+			if n > 0 {
+				atEOF = false
+				ch <- chunk{data: b[:n]}
+			}
+			switch {
+			case errors.Is(err, io.EOF) && poll:
+				if !atEOF {
+					ch <- chunk{eof: true}
+					atEOF = true
+				}
+				time.Sleep(pollInterval)
+			case errors.Is(err, io.EOF):
+				ch <- chunk{eof: true}
+				return
+			case err != nil:
+				ch <- chunk{eof: true, err: err}
+				return
+			}
+		}
+	}()
+	return &buffer{data: ch}
+}
+
+// add adds a chunk to the lines.
+func (b *buffer) add(c chunk, ok bool) {
+	if !ok {
+		b.eof, b.done, b.data = true, true, nil
+		return
+	}
+	if c.eof {
+		b.eof = true
+		if c.err != nil {
+			b.err = c.err
+		}
+		return
+	}
+	b.eof = false
+	data := c.data
+	for len(data) > 0 {
+		line, rest, found := bytes.Cut(data, []byte{'\n'})
+		if b.partial {
+			b.lines[len(b.lines)-1] += string(line)
+		} else {
+			b.lines = append(b.lines, string(line))
+		}
+		b.partial = !found
+		data = rest
+	}
+}
+
+// fill reads until there are n lines, or all the data available.
+func (b *buffer) fill(n int) {
+	for len(b.lines) < n && !b.eof {
+		c, ok := <-b.data
+		b.add(c, ok)
+	}
+}
+
+// fillAll reads all the data available.
+func (b *buffer) fillAll() {
+	for !b.eof {
+		c, ok := <-b.data
+		b.add(c, ok)
+	}
+}
+
+// drain adds the data which is available without waiting.
+func (b *buffer) drain() {
+	for !b.done {
+		select {
+		case c, ok := <-b.data:
+			b.add(c, ok)
+		default:
+			return
+		}
+	}
+}
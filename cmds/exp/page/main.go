@@ -4,104 +4,617 @@
 
 // Synopsis:
 //
-//	page [file]
+//	page [-N] [+COMMAND] [file]
 //
 // Description:
-// page prints a page at a time to stdout from either stdin or a named file.
-// It stops every x rows, where x is the number of rows determined from gtty.
-// Single character commands tell it what to do next. Currently the only ones
-// are return and q.
+// page shows stdin or a named file a screen at a time, with the number of
+// rows determined from gtty. All lines read are kept, so a pipe may be
+// scrolled back as well as a file. If stdout is not a terminal, page
+// copies its input to it.
+//
+// Single character commands tell it what to do next:
+//
+//	q                quit
+//	space f ^F ^V    forward a screen
+//	b ^B             back a screen
+//	return j e ^N    forward a line; the down arrow works too
+//	k y ^P           back a line; the up arrow works too
+//	d u              forward or back half a screen
+//	g <              go to the first line, or line N if preceded by N
+//	G >              go to the last line, or line N if preceded by N
+//	/PATTERN         search forward for the regular expression
+//	?PATTERN         search backward for the regular expression
+//	n N              repeat the search, or in the other direction
+//	F                follow: show data appended to the input as it comes,
+//	                 like tail -f, until a key is pressed
+//	-N               toggle line numbers
+//	r ^L             redraw
+//
+// Commands given with + are run at the start, so that page +F follows a
+// log, page +G starts at the end, page +100 at line 100, and page +/PATTERN
+// at the first match.
 //
 // Options:
+//
+//	-N: show line numbers
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
 )
 
-func page(t *termios.TTYIO, r io.Reader, w io.Writer) error {
-	rows := int64(24)
-	if w, err := t.GetWinSize(); err != nil {
-		log.Printf("Could not get win size: %v; continuing assuming %d rows", err, rows)
+var number = flag.Bool("N", false, "Show line numbers")
+
+const (
+	reverse   = "\033[7m"
+	noReverse = "\033[27m"
+)
+
+type pager struct {
+	b    *buffer
+	keys <-chan byte
+	name string
+
+	rows, cols int
+	// top is the index of the first line shown.
+	top    int
+	number bool
+	// re is the last search, and back is set if it was backward.
+	re   *regexp.Regexp
+	back bool
+	// msg is shown on the status line until the next command.
+	msg string
+	// resize gets the rows and columns when the screen size changes.
+	resize <-chan [2]int
+}
+
+// cell is a character on the screen, which may be highlighted.
+type cell struct {
+	s  string
+	hl bool
+}
+
+// cells turns a line into cells, expanding tabs, showing control
+// characters as ^X, and highlighting the matches of re.
+func cells(line string, re *regexp.Regexp) []cell {
+	var hl [][]int
+	if re != nil {
+		hl = re.FindAllStringIndex(line, -1)
+	}
+	var c []cell
+	for i, r := range line {
+		for len(hl) > 0 && hl[0][1] <= i {
+			hl = hl[1:]
+		}
+		h := len(hl) > 0 && hl[0][0] <= i
+		switch {
+		case r == '\t':
+			for n := 8 - len(c)%8; n > 0; n-- {
+				c = append(c, cell{" ", h})
+			}
+		case r == utf8.RuneError:
+			c = append(c, cell{"?", h})
+		case r < ' ' || r == 0x7f:
+			c = append(c, cell{"^", h}, cell{string(rune(r ^ 0x40)), h})
+		default:
+			c = append(c, cell{string(r), h})
+		}
+	}
+	return c
+}
+
+// width returns the number of columns left for text on each row.
+func (p *pager) width() int {
+	if p.number {
+		return max(p.cols-8, 1)
+	}
+	return max(p.cols, 1)
+}
+
+// wrap returns the screen rows for line i.
+func (p *pager) wrap(i int) []string {
+	c := cells(p.b.lines[i], p.re)
+	w := p.width()
+	var rows []string
+	for first := true; first || len(c) > 0; first = false {
+		n := min(w, len(c))
+		var b strings.Builder
+		if p.number {
+			if first {
+				fmt.Fprintf(&b, "%7d ", i+1)
+			} else {
+				b.WriteString("        ")
+			}
+		}
+		on := false
+		for _, x := range c[:n] {
+			if x.hl != on {
+				on = x.hl
+				if on {
+					b.WriteString(reverse)
+				} else {
+					b.WriteString(noReverse)
+				}
+			}
+			b.WriteString(x.s)
+		}
+		if on {
+			b.WriteString(noReverse)
+		}
+		rows = append(rows, b.String())
+		c = c[n:]
+	}
+	return rows
+}
+
+// height returns the number of screen rows for line i.
+func (p *pager) height(i int) int {
+	return max(1, (len(cells(p.b.lines[i], nil))+p.width()-1)/p.width())
+}
+
+// page returns the number of rows for lines.
+func (p *pager) page() int {
+	return max(p.rows-1, 1)
+}
+
+// shown returns the number of lines read which fit on the screen from
+// top.
+func (p *pager) shown() int {
+	n, h := 0, 0
+	for i := p.top; i < len(p.b.lines); i++ {
+		if h += p.height(i); h > p.page() {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// last returns the top at which the last line is at the bottom of the
+// screen. It reads all the input.
+func (p *pager) last() int {
+	p.b.fillAll()
+	return p.tail()
+}
+
+// tail returns the top at which the last line read is at the bottom of
+// the screen.
+func (p *pager) tail() int {
+	top, h := len(p.b.lines), 0
+	for top > 0 {
+		if h += p.height(top - 1); h > p.page() {
+			break
+		}
+		top--
+	}
+	return top
+}
+
+// atEnd returns whether the last line read is on the screen.
+func (p *pager) atEnd() bool {
+	return p.b.eof && p.top+p.shown() >= len(p.b.lines)
+}
+
+// screen returns the rows of the screen, with the status line last. It
+// only shows the lines read, so that following doesn't wait for more.
+func (p *pager) screen() []string {
+	var rows []string
+	for i := p.top; i < len(p.b.lines) && len(rows) < p.page(); i++ {
+		rows = append(rows, p.wrap(i)...)
+	}
+	if len(rows) > p.page() {
+		rows = rows[:p.page()]
+	}
+	for len(rows) < p.page() {
+		rows = append(rows, "~")
+	}
+	status := ":"
+	switch {
+	case p.msg != "":
+		status = reverse + p.msg + noReverse
+	case p.atEnd():
+		status = reverse + "(END)" + noReverse
+	case p.top == 0 && p.name != "":
+		status = reverse + p.name + noReverse
+	}
+	return append(rows, status)
+}
+
+func (p *pager) draw(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "\033[H\033[J%s", strings.Join(p.screen(), "\r\n"))
+	return err
+}
+
+// forward moves n lines forward, but not past the point where the last
+// line is at the bottom.
+func (p *pager) forward(n int) {
+	p.b.fill(p.top + n + p.page())
+	top := p.top + n
+	if p.b.eof {
+		top = min(top, max(p.last(), p.top))
+	}
+	p.top = top
+}
+
+func (p *pager) backward(n int) {
+	p.top = max(p.top-n, 0)
+}
+
+// backPage moves back a screen.
+func (p *pager) backPage() {
+	h := 0
+	for p.top > 0 {
+		if h += p.height(p.top - 1); h > p.page() {
+			break
+		}
+		p.top--
+	}
+}
+
+// search searches for the last regex forward, or backward if back,
+// starting next to the top line, and makes the line found the top line.
+func (p *pager) search(back bool) {
+	if p.re == nil {
+		p.msg = "No previous regular expression"
+		return
+	}
+	if back {
+		for i := p.top - 1; i >= 0; i-- {
+			if p.re.MatchString(p.b.lines[i]) {
+				p.top = i
+				return
+			}
+		}
 	} else {
-		rows = int64(w.Row)
+		for i := p.top + 1; ; i++ {
+			p.b.fill(i + 1)
+			if i >= len(p.b.lines) {
+				break
+			}
+			if p.re.MatchString(p.b.lines[i]) {
+				p.top = i
+				return
+			}
+		}
 	}
+	p.msg = "Pattern not found"
+}
 
-	l := int64(1)
-	scanner := bufio.NewScanner(r)
+// prompt reads a line typed after the prompt, which is shown on the
+// status line. It returns false if it is cancelled.
+func (p *pager) prompt(w io.Writer, prompt string) (string, bool) {
+	var s []byte
 	for {
-		cur := l
-		for {
-			if !scanner.Scan() {
-				return scanner.Err()
+		fmt.Fprintf(w, "\r\033[K%s%s", prompt, s)
+		c, ok := <-p.keys
+		switch {
+		case !ok, c == 3, c == 033:
+			return "", false
+		case c == '\r', c == '\n':
+			return string(s), true
+		case c == 0x7f, c == 8:
+			if len(s) == 0 {
+				return "", false
 			}
-			line := scanner.Text()
-			if _, err := fmt.Fprintf(w, "%s\r\n", string(line)); err != nil {
-				return err
-			}
-			cur++
-			if cur > l+rows {
+			_, n := utf8.DecodeLastRune(s)
+			s = s[:len(s)-n]
+		default:
+			s = append(s, c)
+		}
+	}
+}
+
+// follow shows the end of the input and data appended to it until a key
+// is pressed.
+func (p *pager) follow(w io.Writer) error {
+	defer func() { p.msg = "" }()
+	for {
+		p.b.drain()
+		p.top = p.tail()
+		p.msg = "Waiting for data... (press any key to stop)"
+		if err := p.draw(w); err != nil {
+			return err
+		}
+		select {
+		case c, ok := <-p.b.data:
+			p.b.add(c, ok)
+		case <-p.keys:
+			return nil
+		case s := <-p.resize:
+			p.rows, p.cols = s[0], s[1]
+		}
+	}
+}
+
+// escape reads the rest of an escape sequence and returns the key it
+// stands for.
+func (p *pager) escape() byte {
+	if c, ok := <-p.keys; !ok || c != '[' {
+		return 0
+	}
+	var seq []byte
+	for c := range p.keys {
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return 'k'
+	case "B":
+		return 'j'
+	case "5~":
+		return 'b'
+	case "6~":
+		return ' '
+	case "H":
+		return 'g'
+	case "F":
+		return 'G'
+	}
+	return 0
+}
+
+// command runs the command for key c, where count is the number typed
+// before it, or 0. It returns true to quit.
+func (p *pager) command(w io.Writer, c byte, count int) (bool, error) {
+	p.msg = ""
+	n := max(count, 1)
+	switch c {
+	case 033:
+		return p.command(w, p.escape(), count)
+	case 'q', 'Q':
+		return true, nil
+	case ' ', 'f', 'z', 6, 22:
+		if count == 0 {
+			p.b.fill(p.top + p.page())
+			n = max(p.shown(), 1)
+		}
+		p.forward(n)
+	case 'b', 'w', 2:
+		if count == 0 {
+			p.backPage()
+			break
+		}
+		p.backward(n)
+	case '\r', '\n', 'j', 'e', 14, 5:
+		p.forward(n)
+	case 'k', 'y', 16, 25:
+		p.backward(n)
+	case 'd', 4:
+		p.forward(max(count, p.page()/2))
+	case 'u', 21:
+		p.backward(max(count, p.page()/2))
+	case 'g', '<':
+		p.top = 0
+		if count > 0 {
+			p.b.fill(count)
+			p.top = min(count, len(p.b.lines)) - 1
+		}
+	case 'G', '>':
+		if count > 0 {
+			p.b.fill(count)
+			p.top = min(count, len(p.b.lines)) - 1
+			break
+		}
+		p.top = p.last()
+	case '/', '?':
+		s, ok := p.prompt(w, string(c))
+		if !ok {
+			break
+		}
+		if s != "" {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				p.msg = err.Error()
 				break
 			}
+			p.re = re
+		}
+		p.back = c == '?'
+		p.search(p.back)
+	case 'n':
+		p.search(p.back)
+	case 'N':
+		p.search(!p.back)
+	case 'F':
+		return false, p.follow(w)
+	case '-':
+		o, ok := <-p.keys
+		if !ok {
+			return true, nil
 		}
-		if cur == l {
+		if o != 'N' {
+			p.msg = fmt.Sprintf("There is no -%c option", o)
 			break
 		}
-		l = cur
-		if _, err := fmt.Fprintf(t, ":"); err != nil {
+		p.number = !p.number
+		p.msg = "Line numbers off"
+		if p.number {
+			p.msg = "Line numbers on"
+		}
+	case 'r', 12:
+	default:
+		p.msg = fmt.Sprintf("Unknown command %q", c)
+	}
+	return false, nil
+}
+
+// start runs a command given with +, which is keys as typed, or a search.
+func (p *pager) start(w io.Writer, cmd string) error {
+	count := 0
+	for len(cmd) > 0 && cmd[0] >= '0' && cmd[0] <= '9' {
+		count = count*10 + int(cmd[0]-'0')
+		cmd = cmd[1:]
+	}
+	if cmd == "" {
+		cmd = "g"
+	}
+	if cmd[0] == '/' || cmd[0] == '?' {
+		re, err := regexp.Compile(cmd[1:])
+		if err != nil {
 			return err
 		}
-		var cmd [1]byte
-		if _, err := t.Read(cmd[:]); err != nil {
+		p.re, p.back = re, cmd[0] == '?'
+		if p.back {
+			p.top = p.last()
+		} else {
+			// Like less, a match on the first line counts.
+			p.top--
+		}
+		p.search(p.back)
+		p.top = max(p.top, 0)
+		return nil
+	}
+	for i := 0; i < len(cmd); i++ {
+		if _, err := p.command(w, cmd[i], count); err != nil {
 			return err
 		}
-		switch cmd[0] {
-		default:
-			fmt.Printf("%q:unknown\n", cmd[0])
-		case '\n', ' ':
-		case 'q':
-			return nil
+	}
+	return nil
+}
+
+// run runs the commands given with + and then those typed, until q or the
+// keys end.
+func (p *pager) run(w io.Writer, initial []string) error {
+	for _, cmd := range initial {
+		if err := p.start(w, cmd); err != nil {
+			return err
+		}
+	}
+	count := 0
+	for {
+		p.b.fill(p.top + p.page())
+		if err := p.draw(w); err != nil {
+			return err
+		}
+		var c byte
+		select {
+		case s := <-p.resize:
+			p.rows, p.cols = s[0], s[1]
+			continue
+		case k, ok := <-p.keys:
+			if !ok {
+				return nil
+			}
+			c = k
+		}
+		if c >= '0' && c <= '9' {
+			count = count*10 + int(c-'0')
+			continue
+		}
+		quit, err := p.command(w, c, count)
+		if quit || err != nil {
+			return err
 		}
-		fmt.Fprintf(w, "\r")
+		count = 0
 	}
+}
 
-	return nil
+// readKeys puts the terminal in raw mode and sends the keys pressed on the
+// returned channel. The returned function restores the terminal.
+func readKeys(t *termios.TTYIO) (<-chan byte, func(), error) {
+	old, err := t.Raw()
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make(chan byte)
+	go func() {
+		var b [1]byte
+		for {
+			if n, err := t.Read(b[:]); err != nil {
+				close(keys)
+				return
+			} else if n == 1 {
+				keys <- b[0]
+			}
+		}
+	}()
+	return keys, func() {
+		if err := t.Set(old); err != nil {
+			log.Printf("Restoring modes failed; sorry (%v)", err)
+		}
+	}, nil
 }
 
 func main() {
-	t, err := termios.New()
-	if err != nil {
-		log.Fatal(err)
+	// Commands with + come before the file, and the flag package stops
+	// at them.
+	var initial, args []string
+	for _, a := range os.Args[1:] {
+		if len(a) > 1 && a[0] == '+' {
+			initial = append(initial, a[1:])
+			continue
+		}
+		args = append(args, a)
 	}
-	in := os.Stdin
+	flag.CommandLine.Parse(args)
 
-	switch len(os.Args) {
+	in, name := os.Stdin, ""
+	switch flag.NArg() {
+	case 0:
 	case 1:
-	case 2:
-		f, err := os.Open(os.Args[1])
+		f, err := os.Open(flag.Arg(0))
 		if err != nil {
 			log.Fatal(err)
 		}
-		in = f
+		in, name = f, flag.Arg(0)
 	default:
-		log.Fatal("Usage: page [file]")
+		log.Fatal("Usage: page [-N] [+COMMAND] [file]")
 	}
-	c, err := t.Raw()
+
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		if _, err := io.Copy(os.Stdout, in); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	t, err := termios.New()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err := t.Set(c); err != nil {
-			log.Printf("Restoring modes failed; sorry (%v)", err)
+	p := &pager{b: newBuffer(in), name: name, rows: 24, cols: 80, number: *number}
+	size := func() [2]int {
+		if w, err := t.GetWinSize(); err == nil && w.Row > 0 && w.Col > 0 {
+			return [2]int{int(w.Row), int(w.Col)}
+		}
+		return [2]int{p.rows, p.cols}
+	}
+	s := size()
+	p.rows, p.cols = s[0], s[1]
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+	resize := make(chan [2]int)
+	go func() {
+		for range winch {
+			resize <- size()
 		}
 	}()
-	if err := page(t, in, os.Stdout); err != nil {
+
+	keys, restore, err := readKeys(t)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.keys, p.resize = keys, resize
+	err = p.run(os.Stdout, initial)
+	fmt.Fprint(os.Stdout, "\r\033[K")
+	restore()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,169 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func lines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestBuffer(t *testing.T) {
+	b := &buffer{}
+	for _, s := range []string{"a\nb", "c\n", "\nd"} {
+		b.add(chunk{data: []byte(s)}, true)
+	}
+	b.add(chunk{}, false)
+	want := []string{"a", "bc", "", "d"}
+	if !reflect.DeepEqual(b.lines, want) {
+		t.Errorf("got %q, want %q", b.lines, want)
+	}
+	if !b.eof || !b.done || !b.partial {
+		t.Errorf("got eof %v, done %v, partial %v, want all true", b.eof, b.done, b.partial)
+	}
+}
+
+func TestCells(t *testing.T) {
+	var got strings.Builder
+	for _, c := range cells("a\tb\x01é", regexp.MustCompile("b.")) {
+		if c.hl {
+			got.WriteString(strings.ToUpper(c.s))
+		} else {
+			got.WriteString(c.s)
+		}
+	}
+	if want := "a       B^Aé"; got.String() != want {
+		t.Errorf("got %q, want %q", got.String(), want)
+	}
+}
+
+func TestPager(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		keys    string
+		initial []string
+		top     int
+		msg     string
+	}{
+		{name: "start", top: 0},
+		{name: "page", keys: " ", top: 9},
+		{name: "page back", keys: "  b", top: 9},
+		{name: "end", keys: "G", top: 91},
+		{name: "past end", keys: "G f", top: 91},
+		{name: "end line up", keys: "Gk", top: 90},
+		{name: "lines", keys: "5j\r", top: 6},
+		{name: "arrow", keys: "\033[B\033[B\033[A", top: 1},
+		{name: "half page", keys: "ddu", top: 4},
+		{name: "line number", keys: "20g", top: 19},
+		{name: "line number from end", keys: "G10G", top: 9},
+		{name: "search", keys: "/5\r", top: 4},
+		{name: "search again", keys: "/5\rn", top: 14},
+		{name: "search reverse", keys: "/5\rnN", top: 4},
+		{name: "search back", keys: "G?7\r", top: 86},
+		{name: "not found", keys: "/zz\r", top: 0, msg: "Pattern not found"},
+		{name: "cancelled", keys: "/5\x7f\x7f ", top: 9},
+		{name: "bad regex", keys: "/(\r", msg: "error parsing regexp: missing closing ): `(`"},
+		{name: "quit", keys: "q ", top: 0},
+		{name: "initial end", initial: []string{"G"}, top: 91},
+		{name: "initial line", initial: []string{"30"}, top: 29},
+		{name: "initial search", initial: []string{"/^1"}, top: 0},
+		{name: "initial search later", initial: []string{"/^5"}, keys: "n", top: 49},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := make(chan byte, len(tt.keys))
+			for i := 0; i < len(tt.keys); i++ {
+				keys <- tt.keys[i]
+			}
+			close(keys)
+			p := &pager{b: newBuffer(strings.NewReader(lines(100))), keys: keys, rows: 10, cols: 80}
+			// Check the last message, which the next draw would show.
+			var msg string
+			w := writerFunc(func(b []byte) (int, error) {
+				msg = p.msg
+				return len(b), nil
+			})
+			if err := p.run(w, tt.initial); err != nil {
+				t.Fatal(err)
+			}
+			if p.top != tt.top {
+				t.Errorf("got top %d, want %d", p.top, tt.top)
+			}
+			if msg != tt.msg {
+				t.Errorf("got message %q, want %q", msg, tt.msg)
+			}
+		})
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+func TestScreen(t *testing.T) {
+	p := &pager{b: newBuffer(strings.NewReader("abcdefghij\nshort\nx\ny\n")), rows: 5, cols: 4, name: "f"}
+	p.b.fill(p.page())
+	want := []string{"abcd", "efgh", "ij", "shor", reverse + "f" + noReverse}
+	if got := p.screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n := p.shown(); n != 1 {
+		t.Errorf("got %d lines shown, want 1", n)
+	}
+	p.forward(1)
+	want = []string{"shor", "t", "x", "y", reverse + "(END)" + noReverse}
+	if got := p.screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	p.number, p.cols, p.re = true, 12, regexp.MustCompile("h")
+	want = []string{"      2 s" + reverse + "h" + noReverse + "or", "        t", "      3 x", "      4 y", reverse + "(END)" + noReverse}
+	if got := p.screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	r, w := io.Pipe()
+	keys := make(chan byte)
+	p := &pager{b: newBuffer(r), keys: keys, rows: 3, cols: 80}
+	frames := make(chan string)
+	out := writerFunc(func(b []byte) (int, error) {
+		frames <- string(b)
+		return len(b), nil
+	})
+	errs := make(chan error)
+	go func() {
+		errs <- p.follow(out)
+	}()
+	for _, l := range []string{"one\n", "two\n", "three\n"} {
+		go w.Write([]byte(l))
+		for f := range frames {
+			if strings.Contains(f, strings.TrimSpace(l)) {
+				break
+			}
+		}
+	}
+	go func() {
+		for range frames {
+		}
+	}()
+	keys <- 'x'
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if p.top != 1 || p.msg != "" {
+		t.Errorf("got top %d and message %q, want 1 and none", p.top, p.msg)
+	}
+}
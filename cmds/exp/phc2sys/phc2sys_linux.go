@@ -0,0 +1,211 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// phc2sys reads and sets PTP hardware clocks (PHCs), the clocks of network
+// cards, and keeps the system clock in sync with one.
+//
+// Synopsis:
+//
+//	phc2sys [-s DEVICE | -i IFACE] [-O SECONDS] [-interval D] [-N N] [-step D] [-once] [-q]
+//	    Step the system clock to the PHC, then slew it to follow the PHC.
+//	phc2sys [-s DEVICE | -i IFACE] [-O SECONDS] -get
+//	    Print the time of the PHC and its offset from the system clock.
+//	phc2sys [-s DEVICE | -i IFACE] [-O SECONDS] -set
+//	    Set the PHC to the system time.
+//	phc2sys [-s DEVICE | -i IFACE] -caps
+//	    Print the capabilities of the PHC.
+//
+// Description:
+//
+//	PHCs usually count TAI, which is ahead of UTC by the leap seconds,
+//	37 as of 2017. -O is what is added to the PHC time to get the system
+//	time, so -O -37 makes the system clock UTC from a TAI PHC.
+//
+//	The system clock is stepped on the first update, and when it is off by
+//	more than -step; otherwise its frequency is adjusted by a PI servo.
+//
+// Options:
+//
+//	-s: PHC device (default /dev/ptp0)
+//	-i: use the PHC of network interface IFACE
+//	-O: seconds from PHC time to system time (default 0)
+//	-interval: time between updates (default 1s)
+//	-N: readings of the PHC per update, the best of which is used (default 5)
+//	-step: offset above which the system clock is stepped after the first
+//	    update (default 0, never)
+//	-once: step the system clock to the PHC and exit
+//	-q: do not print updates
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ptp"
+)
+
+var errExclusive = errors.New("only one of -get, -set and -caps may be given")
+
+type flags struct {
+	device   string
+	iface    string
+	offset   int
+	interval time.Duration
+	samples  int
+	step     time.Duration
+	once     bool
+	quiet    bool
+	get      bool
+	set      bool
+	caps     bool
+}
+
+// phc is what phc2sys needs of the PHC, replaced in tests.
+type phc interface {
+	Time() (time.Time, error)
+	Set(time.Time) error
+	Offset(n int) (offset, delay time.Duration, err error)
+	Caps() (ptp.Caps, error)
+}
+
+// system is what phc2sys needs of the system clock, replaced in tests.
+type system interface {
+	Time() (time.Time, error)
+	Step(time.Duration) error
+	AdjustFrequency(ppb float64) error
+}
+
+type cmd struct {
+	stdout io.Writer
+	flags
+	phc    phc
+	system system
+	sleep  func(time.Duration)
+}
+
+// utcOffset is the time added to PHC time to get system time.
+func (c *cmd) utcOffset() time.Duration {
+	return time.Duration(c.offset) * time.Second
+}
+
+func (c *cmd) printTime() error {
+	t, err := c.phc.Time()
+	if err != nil {
+		return err
+	}
+	offset, delay, err := c.phc.Offset(c.samples)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "%s\n", t.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(c.stdout, "system clock offset %v, delay %v\n", offset-c.utcOffset(), delay)
+	return nil
+}
+
+func (c *cmd) setPHC() error {
+	now, err := c.system.Time()
+	if err != nil {
+		return err
+	}
+	return c.phc.Set(now.Add(-c.utcOffset()))
+}
+
+// sync makes the system clock follow the PHC for n updates, or forever if
+// n is 0.
+func (c *cmd) sync(n int) error {
+	servo := ptp.NewServo()
+	servo.Step = c.step
+	for i := 0; n == 0 || i < n; i++ {
+		if i > 0 {
+			c.sleep(c.interval)
+		}
+		offset, delay, err := c.phc.Offset(c.samples)
+		if err != nil {
+			return err
+		}
+		// How far the system clock is ahead of where it should be.
+		offset -= c.utcOffset()
+		freq, step := servo.Sample(offset, c.interval)
+		if step {
+			if err := c.system.Step(-offset); err != nil {
+				return err
+			}
+		}
+		if err := c.system.AdjustFrequency(freq); err != nil {
+			return err
+		}
+		if !c.quiet {
+			state := "slew"
+			if step {
+				state = "step"
+			}
+			fmt.Fprintf(c.stdout, "offset %9d ns %s freq %+7.0f ppb delay %6d ns\n", offset.Nanoseconds(), state, freq, delay.Nanoseconds())
+		}
+	}
+	return nil
+}
+
+func (c *cmd) run() error {
+	switch {
+	case c.get && (c.set || c.caps), c.set && c.caps:
+		return errExclusive
+	case c.get:
+		return c.printTime()
+	case c.set:
+		return c.setPHC()
+	case c.caps:
+		caps, err := c.phc.Caps()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, caps)
+		return nil
+	case c.once:
+		return c.sync(1)
+	}
+	return c.sync(0)
+}
+
+func main() {
+	var f flags
+	flag.StringVar(&f.device, "s", "/dev/ptp0", "PHC `device`")
+	flag.StringVar(&f.iface, "i", "", "use the PHC of network `interface`")
+	flag.IntVar(&f.offset, "O", 0, "`seconds` from PHC time to system time")
+	flag.DurationVar(&f.interval, "interval", time.Second, "time between updates")
+	flag.IntVar(&f.samples, "N", 5, "readings of the PHC per update")
+	flag.DurationVar(&f.step, "step", 0, "offset above which the system clock is stepped after the first update (0 for never)")
+	flag.BoolVar(&f.once, "once", false, "step the system clock to the PHC and exit")
+	flag.BoolVar(&f.quiet, "q", false, "do not print updates")
+	flag.BoolVar(&f.get, "get", false, "print the time of the PHC")
+	flag.BoolVar(&f.set, "set", false, "set the PHC to the system time")
+	flag.BoolVar(&f.caps, "caps", false, "print the capabilities of the PHC")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if f.iface != "" {
+		dev, err := ptp.InterfaceClock(f.iface)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.device = dev
+	}
+	clock, err := ptp.Open(f.device, f.set)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := &cmd{stdout: os.Stdout, flags: f, phc: clock, system: ptp.Realtime, sleep: time.Sleep}
+	err = c.run()
+	clock.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
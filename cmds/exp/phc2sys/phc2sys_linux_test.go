@@ -0,0 +1,151 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ptp"
+)
+
+// fakeClocks is a PHC and a system clock in one: the system clock is
+// ahead of the PHC by offset, and drifts by drift per second.
+type fakeClocks struct {
+	now    time.Time
+	offset time.Duration
+	drift  time.Duration
+	freq   float64
+	steps  []time.Duration
+	set    time.Time
+}
+
+func (f *fakeClocks) Time() (time.Time, error) { return f.now, nil }
+func (f *fakeClocks) Set(t time.Time) error    { f.set = t; return nil }
+
+func (f *fakeClocks) Offset(int) (time.Duration, time.Duration, error) {
+	return f.offset, time.Microsecond, nil
+}
+
+func (f *fakeClocks) Caps() (ptp.Caps, error) {
+	return ptp.Caps{MaxAdj: 1000000, PPS: true}, nil
+}
+
+func (f *fakeClocks) Step(d time.Duration) error {
+	f.steps = append(f.steps, d)
+	f.offset += d
+	return nil
+}
+
+func (f *fakeClocks) AdjustFrequency(ppb float64) error {
+	f.freq = ppb
+	return nil
+}
+
+func (f *fakeClocks) sleep(d time.Duration) {
+	f.now = f.now.Add(d)
+	// ppb is nanoseconds per second.
+	f.offset += time.Duration((float64(f.drift) + f.freq) * d.Seconds())
+}
+
+func newCmd(f *fakeClocks, fl flags, out *strings.Builder) *cmd {
+	fl.interval = time.Second
+	return &cmd{stdout: out, flags: fl, phc: f, system: f, sleep: f.sleep}
+}
+
+func TestSync(t *testing.T) {
+	f := &fakeClocks{offset: 37*time.Second + 5*time.Millisecond, drift: 20 * time.Microsecond}
+	var out strings.Builder
+	c := newCmd(f, flags{offset: 37, quiet: true}, &out)
+	if err := c.sync(30); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.steps) != 1 || f.steps[0] != -5*time.Millisecond {
+		t.Errorf("steps = %v, want [-5ms]", f.steps)
+	}
+	// The system clock must have caught up with the drift.
+	if off := f.offset - 37*time.Second; off > time.Microsecond || off < -time.Microsecond {
+		t.Errorf("offset after 30 updates = %v, want within 1µs", off)
+	}
+	if f.freq > -19000 || f.freq < -21000 {
+		t.Errorf("frequency = %v ppb, want about -20000", f.freq)
+	}
+	if out.Len() != 0 {
+		t.Errorf("quiet sync wrote %q", out.String())
+	}
+}
+
+func TestSyncStep(t *testing.T) {
+	f := &fakeClocks{}
+	var out strings.Builder
+	c := newCmd(f, flags{step: time.Millisecond}, &out)
+	if err := c.sync(1); err != nil {
+		t.Fatal(err)
+	}
+	f.offset = 2 * time.Millisecond
+	if err := c.sync(2); err != nil {
+		t.Fatal(err)
+	}
+	// The new sync steps first, and the offset is then over -step.
+	if len(f.steps) != 2 {
+		t.Errorf("steps = %v, want 2", f.steps)
+	}
+	if got := strings.Count(out.String(), " step "); got != 2 {
+		t.Errorf("output %q has %d steps, want 2", out.String(), got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		name    string
+		flags   flags
+		want    string
+		wantSet time.Time
+		err     error
+	}{
+		{
+			name:  "get",
+			flags: flags{get: true, offset: -37},
+			want:  "2024-01-02T03:04:05Z\nsystem clock offset 37.002s, delay 1µs\n",
+		},
+		{
+			name:    "set",
+			flags:   flags{set: true, offset: -37},
+			wantSet: now.Add(37 * time.Second),
+		},
+		{
+			name:  "caps",
+			flags: flags{caps: true},
+			want:  "max adjustment 1000000 ppb, 0 alarms, 0 external timestamp channels, 0 periodic outputs, pps true, 0 pins, cross timestamping false\n",
+		},
+		{
+			name:  "once",
+			flags: flags{once: true},
+			want:  "offset   2000000 ns step freq      +0 ppb delay   1000 ns\n",
+		},
+		{
+			name:  "exclusive",
+			flags: flags{get: true, caps: true},
+			err:   errExclusive,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeClocks{now: now, offset: 2 * time.Millisecond}
+			var out strings.Builder
+			if err := newCmd(f, tt.flags, &out).run(); !errors.Is(err, tt.err) {
+				t.Fatalf("run() = %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+			if !f.set.Equal(tt.wantSet) {
+				t.Errorf("PHC set to %v, want %v", f.set, tt.wantSet)
+			}
+		})
+	}
+}
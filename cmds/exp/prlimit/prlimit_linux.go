@@ -0,0 +1,175 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// prlimit gets and sets the resource limits of a process.
+//
+// Synopsis:
+//
+//	prlimit [-p PID] [-noheadings] [-RESOURCE[=LIMITS]]... [COMMAND [ARG...]]
+//
+// Description:
+//
+//	prlimit shows the limits of process PID, or changes those given with
+//	LIMITS. Without -p, the limits changed are those of COMMAND, which is
+//	run with them, or without a COMMAND, of prlimit itself.
+//
+//	LIMITS is SOFT:HARD, SOFT: or :HARD to change just one, or a single
+//	value for both; a value may be "unlimited". They are in the units
+//	shown: bytes, not the kilobytes of ulimit.
+//
+//	Limits given without a value are shown; if none are given at all, and
+//	none are changed, all are shown.
+//
+// Options:
+//
+//	-p:          the process
+//	-noheadings: leave out the headings
+//	-as, -core, -cpu, -data, -fsize, -locks, -memlock, -msgqueue, -nice,
+//	-nofile, -nproc, -rss, -rtprio, -rttime, -sigpending, -stack:
+//	             the limits, which take LIMITS after an =, as in -nofile=1024
+//
+// Example:
+//
+//	prlimit -p 1234 -nofile=4096:8192
+//	prlimit -core=unlimited sh
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+)
+
+var errUsage = errors.New("usage: prlimit [-p PID] [-noheadings] [-RESOURCE[=LIMITS]]... [COMMAND [ARG...]]")
+
+// selection is a resource given on the command line, with the limits to
+// set, if any.
+type selection struct {
+	r      rlimit.Resource
+	limits string
+}
+
+type cmd struct {
+	stdout     io.Writer
+	pid        int
+	noheadings bool
+	selected   []selection
+	args       []string
+	exec       func(argv0 string, argv []string, envv []string) error
+}
+
+// limitFlag is the flag of a resource. It is a boolean flag, so that it
+// can be given without a value to show the limit.
+type limitFlag struct {
+	c *cmd
+	r rlimit.Resource
+}
+
+func (f *limitFlag) String() string   { return "" }
+func (f *limitFlag) IsBoolFlag() bool { return true }
+
+func (f *limitFlag) Set(s string) error {
+	if s == "true" {
+		s = ""
+	}
+	f.c.selected = append(f.c.selected, selection{r: f.r, limits: s})
+	return nil
+}
+
+func command(stdout io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdout: stdout, exec: syscall.Exec}
+	f := flag.NewFlagSet("prlimit", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.IntVar(&c.pid, "p", 0, "process ID")
+	f.IntVar(&c.pid, "pid", 0, "process ID")
+	f.BoolVar(&c.noheadings, "noheadings", false, "leave out the headings")
+	for _, r := range rlimit.Resources {
+		f.Var(&limitFlag{c: c, r: r}, r.Name, r.Description)
+	}
+	if err := f.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	c.args = f.Args()
+	if c.pid < 0 || (c.pid != 0 && len(c.args) > 0) {
+		return nil, errUsage
+	}
+	return c, nil
+}
+
+func (c *cmd) show(shown []rlimit.Resource) error {
+	if !c.noheadings {
+		fmt.Fprintf(c.stdout, "%-10s %-35s %9s %9s %s\n", "RESOURCE", "DESCRIPTION", "SOFT", "HARD", "UNITS")
+	}
+	for _, r := range shown {
+		l, err := rlimit.Get(c.pid, r.Resource)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.Name, err)
+		}
+		line := fmt.Sprintf("%-10s %-35s %9s %9s %s", strings.ToUpper(r.Name), r.Description,
+			rlimit.FormatValue(l.Cur, 1), rlimit.FormatValue(l.Max, 1), r.Units)
+		fmt.Fprintln(c.stdout, strings.TrimRight(line, " "))
+	}
+	return nil
+}
+
+func (c *cmd) run() error {
+	var shown []rlimit.Resource
+	changed := false
+	for _, s := range c.selected {
+		if s.limits == "" {
+			shown = append(shown, s.r)
+			continue
+		}
+		old, err := rlimit.Get(c.pid, s.r.Resource)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.r.Name, err)
+		}
+		l, err := rlimit.ParseLimits(s.limits, old)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.r.Name, err)
+		}
+		if err := rlimit.Set(c.pid, s.r.Resource, l); err != nil {
+			return fmt.Errorf("%s: %w", s.r.Name, err)
+		}
+		changed = true
+	}
+
+	if len(c.args) > 0 {
+		if len(shown) > 0 {
+			if err := c.show(shown); err != nil {
+				return err
+			}
+		}
+		path, err := exec.LookPath(c.args[0])
+		if err != nil {
+			return err
+		}
+		return c.exec(path, c.args, os.Environ())
+	}
+	if len(shown) == 0 && !changed {
+		shown = rlimit.Resources
+	}
+	if len(shown) == 0 {
+		return nil
+	}
+	return c.show(shown)
+}
+
+func main() {
+	c, err := command(os.Stdout, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+func TestPrlimit(t *testing.T) {
+	old, err := rlimit.Get(0, unix.RLIMIT_CORE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rlimit.Set(0, unix.RLIMIT_CORE, old)
+	hard := rlimit.FormatValue(old.Max, 1)
+	pid := fmt.Sprint(os.Getpid())
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		exec []string
+		err  error
+	}{
+		{
+			name: "set",
+			args: []string{"-p", pid, "-core=0:"},
+		},
+		{
+			name: "show",
+			args: []string{"-p", pid, "-core"},
+			want: "RESOURCE   DESCRIPTION                              SOFT      HARD UNITS\n" +
+				fmt.Sprintf("CORE       max core file size                          0 %9s bytes\n", hard),
+		},
+		{
+			name: "set and show",
+			args: []string{"-noheadings", "-core=1024:", "-core"},
+			want: fmt.Sprintf("CORE       max core file size                       1024 %9s bytes\n", hard),
+		},
+		{
+			name: "command",
+			args: []string{"-core=0:", "true", "x"},
+			exec: []string{"true", "x"},
+		},
+		{name: "pid and command", args: []string{"-p", "1", "true"}, err: errUsage},
+		{name: "bad limits", args: []string{"-core=lots"}, err: rlimit.ErrBadLimit},
+		{name: "soft above hard", args: []string{"-nofile=10:5"}, err: rlimit.ErrBadLimit},
+		{name: "bad flag", args: []string{"-files"}, err: errUsage},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c, err := command(&out, tt.args)
+			var ran []string
+			if err == nil {
+				c.exec = func(path string, argv, env []string) error {
+					ran = argv
+					return nil
+				}
+				err = c.run()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+			if !reflect.DeepEqual(ran, tt.exec) {
+				t.Errorf("ran %q, want %q", ran, tt.exec)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	c, err := command(&out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != len(rlimit.Resources)+1 {
+		t.Errorf("got %d lines, want %d", n, len(rlimit.Resources)+1)
+	}
+}
@@ -6,6 +6,10 @@
 //
 // pxeserver can either respond to *all* DHCP requests, or a DHCP request from
 // a specific MAC. In either case, it will supply the same IP in all answers.
+//
+// TFTP transfers can be limited in number and bandwidth, globally and per
+// client, so that one client cannot starve the others, and their stats can
+// be logged or served as Prometheus metrics.
 package main
 
 import (
@@ -24,6 +28,8 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
 	"github.com/insomniacslk/dhcp/dhcpv6/server6"
+	"github.com/u-root/u-root/pkg/metrics"
+	utftp "github.com/u-root/u-root/pkg/tftp"
 	"pack.ag/tftp"
 )
 
@@ -48,6 +54,14 @@ var (
 	tftpPort = flag.Int("tftp-port", 69, "Port to serve TFTP on")
 	httpDir  = flag.String("http-dir", "", "Directory to serve over HTTP")
 	httpPort = flag.Int("http-port", 80, "Port to serve HTTP on")
+
+	// TFTP limits
+	tftpMaxSessions       = flag.Int("tftp-max-sessions", 0, "Number of TFTP transfers to serve at once, 0 for no limit")
+	tftpMaxClientSessions = flag.Int("tftp-max-client-sessions", 0, "Number of TFTP transfers to serve at once to one client, 0 for no limit")
+	tftpRate              = flag.Int64("tftp-rate", 0, "Bytes per second to send over TFTP to all clients, 0 for no limit")
+	tftpClientRate        = flag.Int64("tftp-client-rate", 0, "Bytes per second to send over TFTP to one client, 0 for no limit")
+	tftpStats             = flag.Duration("tftp-stats", 0, "Interval to log TFTP stats at, 0 to not log them")
+	metricsAddr           = flag.String("metrics-addr", "", "Address to serve Prometheus metrics on at /metrics, e.g. :9100")
 )
 
 type dserver4 struct {
@@ -210,11 +224,31 @@ func main() {
 				log.Fatalf("Could not start TFTP server: %v", err)
 			}
 
+			limiter := utftp.NewLimiter(tftp.FileServer(*tftpDir), utftp.Limits{
+				MaxSessions:       *tftpMaxSessions,
+				MaxClientSessions: *tftpMaxClientSessions,
+				Rate:              *tftpRate,
+				ClientRate:        *tftpClientRate,
+			})
+			if *tftpStats > 0 {
+				go func() {
+					for range time.Tick(*tftpStats) {
+						log.Printf("TFTP: %v", limiter.Stats())
+					}
+				}()
+			}
+			limiter.Register(metrics.Default)
+
 			log.Println("starting file server")
-			server.ReadHandler(tftp.FileServer(*tftpDir))
+			server.ReadHandler(limiter)
 			log.Fatal(server.ListenAndServe())
 		}()
 	}
+	if len(*metricsAddr) != 0 {
+		go func() {
+			log.Fatal(metrics.ListenAndServe(*metricsAddr))
+		}()
+	}
 	if len(*httpDir) != 0 {
 		wg.Add(1)
 		go func() {
@@ -0,0 +1,238 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// redfish controls a host through its BMC's Redfish API.
+//
+// Synopsis:
+//
+//	redfish [-e URL] [-u USER] [-k] [-system ID] COMMAND [ARGS...]
+//
+// Description:
+//
+//	redfish logs in to the BMC at URL, runs a command on a system, and
+//	logs out. The password is read from $REDFISH_PASSWORD, so that it does
+//	not show up in the process list. Commands are:
+//
+//	power [status]                 print the power state
+//	power on | off | force-off | restart | reset | cycle | nmi
+//	boot [status]                  print the boot override
+//	boot TARGET [once | continuous] [uefi | legacy]
+//	                               boot from TARGET, e.g. pxe, hdd, cd, usb,
+//	                               bios or none, next time (default) or always
+//	inventory                      print the systems, chassis and managers
+//	get PATH                       print a resource as JSON
+//
+// Options:
+//
+//	-e:      BMC URL (default $REDFISH_URL)
+//	-u:      user name (default $REDFISH_USER)
+//	-k:      do not verify the BMC's TLS certificate
+//	-system: ID of the system, needed if the BMC manages more than one
+//	-t:      timeout of the whole command (default 60s)
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/redfish"
+)
+
+var (
+	errUsage      = errors.New("usage: redfish [-e URL] [-u USER] [-k] [-system ID] power|boot|inventory|get [ARGS...]")
+	errNoEndpoint = errors.New("no BMC URL: give -e or set REDFISH_URL")
+)
+
+var powerActions = map[string]string{
+	"on":        redfish.ResetOn,
+	"off":       redfish.ResetGracefulShutdown,
+	"force-off": redfish.ResetForceOff,
+	"restart":   redfish.ResetGracefulRestart,
+	"reset":     redfish.ResetForceRestart,
+	"cycle":     redfish.ResetPowerCycle,
+	"nmi":       redfish.ResetNmi,
+}
+
+var bootTargets = map[string]string{
+	"none": redfish.BootTargetNone,
+	"pxe":  redfish.BootTargetPxe,
+	"hdd":  redfish.BootTargetHdd,
+	"cd":   redfish.BootTargetCd,
+	"usb":  redfish.BootTargetUsb,
+	"bios": redfish.BootTargetBiosSetup,
+	"http": redfish.BootTargetUefiHTTP,
+}
+
+type cmd struct {
+	w      io.Writer
+	c      *redfish.Client
+	system string
+	args   []string
+}
+
+func (c *cmd) power(ctx context.Context, args []string) error {
+	s, err := c.c.System(ctx, c.system)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "status" {
+		fmt.Fprintf(c.w, "%s: power %s\n", s.ID, s.PowerState)
+		return nil
+	}
+	reset, ok := powerActions[args[0]]
+	if !ok || len(args) != 1 {
+		return errUsage
+	}
+	if err := c.c.Reset(ctx, s, reset); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.w, "%s: %s\n", s.ID, reset)
+	return nil
+}
+
+func (c *cmd) boot(ctx context.Context, args []string) error {
+	s, err := c.c.System(ctx, c.system)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "status" {
+		b := s.Boot
+		fmt.Fprintf(c.w, "%s: boot %s %s %s\n", s.ID, b.BootSourceOverrideTarget, b.BootSourceOverrideEnabled, b.BootSourceOverrideMode)
+		return nil
+	}
+	target, ok := bootTargets[strings.ToLower(args[0])]
+	if !ok {
+		return errUsage
+	}
+	enabled, mode := redfish.BootOnce, ""
+	for _, a := range args[1:] {
+		switch a {
+		case "once":
+			enabled = redfish.BootOnce
+		case "continuous":
+			enabled = redfish.BootContinuous
+		case "uefi":
+			mode = "UEFI"
+		case "legacy":
+			mode = "Legacy"
+		default:
+			return errUsage
+		}
+	}
+	if target == redfish.BootTargetNone {
+		enabled = redfish.BootDisabled
+	}
+	if err := c.c.SetBoot(ctx, s, target, enabled, mode); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.w, "%s: boot %s %s\n", s.ID, target, enabled)
+	return nil
+}
+
+func (c *cmd) inventory(ctx context.Context) error {
+	systems, err := c.c.Systems(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range systems {
+		fmt.Fprintf(c.w, "System %s: %s %s, serial %s, UUID %s\n", s.ID, s.Manufacturer, s.Model, s.SerialNumber, s.UUID)
+		fmt.Fprintf(c.w, "  power %s, health %s, BIOS %s\n", s.PowerState, s.Status.Health, s.BiosVersion)
+		fmt.Fprintf(c.w, "  %d x %s, %g GiB memory\n", s.ProcessorSummary.Count, s.ProcessorSummary.Model, s.MemorySummary.TotalSystemMemoryGiB)
+	}
+	chassis, err := c.c.Chassis(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ch := range chassis {
+		fmt.Fprintf(c.w, "Chassis %s: %s %s %s, serial %s, part %s, asset tag %s\n", ch.ID, ch.ChassisType, ch.Manufacturer, ch.Model, ch.SerialNumber, ch.PartNumber, ch.AssetTag)
+	}
+	managers, err := c.c.Managers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range managers {
+		fmt.Fprintf(c.w, "Manager %s: %s %s, firmware %s\n", m.ID, m.ManagerType, m.Model, m.FirmwareVersion)
+	}
+	return nil
+}
+
+func (c *cmd) get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	var v json.RawMessage
+	if err := c.c.Get(ctx, args[0], &v); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(c.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cmd) run(ctx context.Context) (err error) {
+	if len(c.args) == 0 {
+		return errUsage
+	}
+	if err := c.c.Login(ctx); err != nil {
+		var e *redfish.Error
+		// Without a session service, use basic authentication.
+		if !errors.As(err, &e) || (e.StatusCode != http.StatusNotFound && e.StatusCode != http.StatusMethodNotAllowed) {
+			return err
+		}
+	}
+	defer func() {
+		if lerr := c.c.Logout(ctx); err == nil {
+			err = lerr
+		}
+	}()
+	args := c.args[1:]
+	switch c.args[0] {
+	case "power":
+		return c.power(ctx, args)
+	case "boot":
+		return c.boot(ctx, args)
+	case "inventory":
+		return c.inventory(ctx)
+	case "get":
+		return c.get(ctx, args)
+	}
+	return errUsage
+}
+
+func main() {
+	endpoint := flag.String("e", os.Getenv("REDFISH_URL"), "BMC URL")
+	user := flag.String("u", os.Getenv("REDFISH_USER"), "User name")
+	insecure := flag.Bool("k", false, "Do not verify the BMC's TLS certificate")
+	system := flag.String("system", "", "ID of the system")
+	timeout := flag.Duration("t", time.Minute, "Timeout")
+	flag.Parse()
+	if *endpoint == "" {
+		log.Fatal(errNoEndpoint)
+	}
+	client, err := redfish.NewClient(*endpoint, *user, os.Getenv("REDFISH_PASSWORD"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *insecure {
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &cmd{w: os.Stdout, c: client, system: *system, args: flag.Args()}
+	if err := c.run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
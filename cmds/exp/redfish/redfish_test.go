@@ -0,0 +1,155 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/redfish"
+)
+
+// resources is a BMC without a session service, which takes basic
+// authentication only.
+var resources = map[string]string{
+	"/redfish/v1/Systems":      `{"Members": [{"@odata.id": "/redfish/v1/Systems/sys0"}]}`,
+	"/redfish/v1/Chassis":      `{"Members": [{"@odata.id": "/redfish/v1/Chassis/ch0"}]}`,
+	"/redfish/v1/Managers":     `{"Members": [{"@odata.id": "/redfish/v1/Managers/bmc"}]}`,
+	"/redfish/v1/Chassis/ch0":  `{"Id": "ch0", "ChassisType": "RackMount", "Manufacturer": "ACME", "Model": "2U", "SerialNumber": "C1", "PartNumber": "P1", "AssetTag": "A1"}`,
+	"/redfish/v1/Managers/bmc": `{"Id": "bmc", "ManagerType": "BMC", "Model": "AST2600", "FirmwareVersion": "1.2"}`,
+	"/redfish/v1/Systems/sys0": `{
+		"@odata.id": "/redfish/v1/Systems/sys0",
+		"Id": "sys0",
+		"Manufacturer": "ACME",
+		"Model": "S1",
+		"SerialNumber": "S123",
+		"UUID": "u-u-i-d",
+		"BiosVersion": "2.0",
+		"PowerState": "On",
+		"Status": {"Health": "OK"},
+		"ProcessorSummary": {"Count": 2, "Model": "Xeon"},
+		"MemorySummary": {"TotalSystemMemoryGiB": 64},
+		"Boot": {"BootSourceOverrideEnabled": "Disabled", "BootSourceOverrideTarget": "None", "BootSourceOverrideMode": "UEFI"},
+		"Actions": {"#ComputerSystem.Reset": {"target": "/redfish/v1/Systems/sys0/Actions/ComputerSystem.Reset"}}
+	}`,
+}
+
+type request struct {
+	method, path, body string
+}
+
+func TestRun(t *testing.T) {
+	var got []request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "root" || p != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/redfish/v1/SessionService/Sessions" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method != http.MethodGet {
+			b, _ := io.ReadAll(r.Body)
+			got = append(got, request{r.Method, r.URL.Path, string(b)})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		res, ok := resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, res)
+	}))
+	defer s.Close()
+	client, err := redfish.NewClient(s.URL, "root", "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+		reqs []request
+		err  error
+	}{
+		{args: []string{"power"}, want: "sys0: power On\n"},
+		{
+			args: []string{"power", "cycle"},
+			want: "sys0: PowerCycle\n",
+			reqs: []request{{"POST", "/redfish/v1/Systems/sys0/Actions/ComputerSystem.Reset", `{"ResetType":"PowerCycle"}`}},
+		},
+		{args: []string{"boot"}, want: "sys0: boot None Disabled UEFI\n"},
+		{
+			args: []string{"boot", "PXE"},
+			want: "sys0: boot Pxe Once\n",
+			reqs: []request{{"PATCH", "/redfish/v1/Systems/sys0", `{"Boot":{"BootSourceOverrideEnabled":"Once","BootSourceOverrideTarget":"Pxe"}}`}},
+		},
+		{
+			args: []string{"boot", "hdd", "continuous", "legacy"},
+			want: "sys0: boot Hdd Continuous\n",
+			reqs: []request{{"PATCH", "/redfish/v1/Systems/sys0", `{"Boot":{"BootSourceOverrideEnabled":"Continuous","BootSourceOverrideTarget":"Hdd","BootSourceOverrideMode":"Legacy"}}`}},
+		},
+		{
+			args: []string{"inventory"},
+			want: "System sys0: ACME S1, serial S123, UUID u-u-i-d\n" +
+				"  power On, health OK, BIOS 2.0\n" +
+				"  2 x Xeon, 64 GiB memory\n" +
+				"Chassis ch0: RackMount ACME 2U, serial C1, part P1, asset tag A1\n" +
+				"Manager bmc: BMC AST2600, firmware 1.2\n",
+		},
+		{args: []string{"get", "/redfish/v1/Managers/bmc"}, want: "{\n  \"Id\": \"bmc\",\n  \"ManagerType\": \"BMC\",\n  \"Model\": \"AST2600\",\n  \"FirmwareVersion\": \"1.2\"\n}\n"},
+		{args: []string{"power", "sideways"}, err: errUsage},
+		{args: []string{"boot", "floppy"}, err: errUsage},
+		{args: []string{"reboot"}, err: errUsage},
+		{args: nil, err: errUsage},
+	} {
+		got = nil
+		var out bytes.Buffer
+		c := &cmd{w: &out, c: client, args: tt.args}
+		if err := c.run(context.Background()); !errors.Is(err, tt.err) {
+			t.Errorf("%q: run = %v, want %v", tt.args, err, tt.err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: output\n%s\nwant\n%s", tt.args, out.String(), tt.want)
+		}
+		if len(got) != len(tt.reqs) {
+			t.Errorf("%q: requests %q, want %q", tt.args, got, tt.reqs)
+			continue
+		}
+		for i := range got {
+			if got[i].method != tt.reqs[i].method || got[i].path != tt.reqs[i].path || !jsonEqual(got[i].body, tt.reqs[i].body) {
+				t.Errorf("%q: request %q, want %q", tt.args, got[i], tt.reqs[i])
+			}
+		}
+	}
+
+	var e *redfish.Error
+	c := &cmd{w: io.Discard, c: client, system: "nope", args: []string{"power"}}
+	if err := c.run(context.Background()); !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Errorf("power of unknown system = %v, want 404", err)
+	}
+}
+
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
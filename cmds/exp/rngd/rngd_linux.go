@@ -0,0 +1,188 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// rngd feeds the kernel's entropy pool from hardware random number
+// generators.
+//
+// Synopsis:
+//
+//	rngd run [OPTIONS]
+//	    Feed the pool whenever the kernel wants entropy (does not daemonize).
+//	rngd seed [OPTIONS] [--timeout D]
+//	    Feed the pool until getrandom no longer blocks, and exit.
+//	rngd genkey [OPTIONS] [--bytes N] [--out FILE] [--timeout D]
+//	    Seed the pool if needed, and write N random bytes from getrandom as
+//	    hex, for keys made early in boot.
+//
+// Options:
+//
+//	--sources: comma-separated sources among rdrand, hwrng and tpm
+//	    (default all the machine has)
+//	--hwrng: hardware RNG device (default /dev/hwrng)
+//	--tpm: TPM 2.0 device (default /dev/tpmrm0, then /dev/tpm0)
+//	--block: bytes added to the pool at once (default 64)
+//	--threshold: bits of entropy to fill the pool up to (default the
+//	    kernel's write_wakeup_threshold)
+//	--interval: how often to feed the pool regardless (default 1m)
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/rngd"
+)
+
+var errUsage = errors.New("usage: rngd run|seed|genkey [OPTIONS]")
+
+type options struct {
+	sources string
+	hwrng   string
+	tpm     string
+	rngd.Options
+}
+
+func (o *options) flags(f *flag.FlagSet) {
+	f.StringVar(&o.sources, "sources", "", "comma-separated sources among rdrand, hwrng and tpm (default all the machine has)")
+	f.StringVar(&o.hwrng, "hwrng", rngd.DefaultHWRNG, "hardware RNG `device`")
+	f.StringVar(&o.tpm, "tpm", "", "TPM 2.0 `device` (default "+strings.Join(rngd.DefaultTPMs, ", then ")+")")
+	f.IntVar(&o.BlockSize, "block", rngd.DefaultBlockSize, "bytes added to the pool at once")
+	f.IntVar(&o.Threshold, "threshold", 0, "bits of entropy to fill the pool up to (default the kernel's write_wakeup_threshold)")
+	f.DurationVar(&o.Interval, "interval", time.Minute, "how often to feed the pool regardless")
+}
+
+// open opens the sources asked for, or all the machine has.
+func (o *options) open() ([]rngd.Source, error) {
+	tpms := rngd.DefaultTPMs
+	if o.tpm != "" {
+		tpms = []string{o.tpm}
+	}
+	if o.sources == "" {
+		var sources []rngd.Source
+		for _, open := range []func() (rngd.Source, error){
+			rngd.RDRAND,
+			func() (rngd.Source, error) { return rngd.HWRNG(o.hwrng) },
+			func() (rngd.Source, error) { return rngd.TPM(tpms...) },
+		} {
+			if s, err := open(); err == nil {
+				sources = append(sources, s)
+			}
+		}
+		if len(sources) == 0 {
+			return nil, rngd.ErrNoSource
+		}
+		return sources, nil
+	}
+
+	var sources []rngd.Source
+	for _, name := range strings.Split(o.sources, ",") {
+		var (
+			s   rngd.Source
+			err error
+		)
+		switch name {
+		case "rdrand":
+			s, err = rngd.RDRAND()
+		case "hwrng":
+			s, err = rngd.HWRNG(o.hwrng)
+		case "tpm":
+			s, err = rngd.TPM(tpms...)
+		default:
+			err = fmt.Errorf("%w: unknown source %q", errUsage, name)
+		}
+		if err != nil {
+			for _, s := range sources {
+				s.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		sources = append(sources, s)
+	}
+	return sources, nil
+}
+
+func run(stdout io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+
+	var o options
+	f := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	o.flags(f)
+	var (
+		timeout time.Duration
+		n       int
+		out     string
+	)
+	switch cmd {
+	case "run":
+	case "seed":
+		f.DurationVar(&timeout, "timeout", 0, "give up after this long (default never)")
+	case "genkey":
+		f.DurationVar(&timeout, "timeout", 0, "give up after this long (default never)")
+		f.IntVar(&n, "bytes", 32, "bytes of key")
+		f.StringVar(&out, "out", "", "write the key to `file` rather than stdout")
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 0 || (cmd == "genkey" && n <= 0) {
+		return errUsage
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	sources, err := o.open()
+	// Keys may still be made without sources once the kernel is seeded.
+	if err != nil && (cmd != "genkey" || !errors.Is(err, rngd.ErrNoSource)) {
+		return err
+	}
+	o.Sources = sources
+	for _, s := range sources {
+		log.Printf("rngd: using %s", s.Name())
+	}
+
+	switch cmd {
+	case "run":
+		return rngd.Run(ctx, o.Options)
+	case "seed":
+		return rngd.Seed(ctx, o.Options)
+	}
+	k, err := rngd.Key(ctx, n, o.Options)
+	if err != nil {
+		return err
+	}
+	w := stdout
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = fmt.Fprintln(w, hex.EncodeToString(k))
+	return err
+}
+
+func main() {
+	if err := run(os.Stdout, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
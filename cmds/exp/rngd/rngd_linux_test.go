@@ -0,0 +1,46 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunErrors(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		err  error
+	}{
+		{args: nil, err: errUsage},
+		{args: []string{"stop"}, err: errUsage},
+		{args: []string{"seed", "extra"}, err: errUsage},
+		{args: []string{"genkey", "-bytes", "0"}, err: errUsage},
+		{args: []string{"seed", "-sources", "dice"}, err: errUsage},
+		{args: []string{"seed", "-sources", "hwrng", "-hwrng", filepath.Join(t.TempDir(), "missing")}, err: os.ErrNotExist},
+	} {
+		if err := run(nil, tt.args); !errors.Is(err, tt.err) {
+			t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestGenkey(t *testing.T) {
+	var out bytes.Buffer
+	// The machine's sources may be missing; keys are made regardless once
+	// the kernel is seeded.
+	if err := run(&out, []string{"genkey", "-bytes", "24", "-sources", ""}); err != nil {
+		t.Fatal(err)
+	}
+	k, err := hex.DecodeString(strings.TrimSpace(out.String()))
+	if err != nil || len(k) != 24 {
+		t.Errorf("genkey wrote %q, want 24 bytes in hex", out.String())
+	}
+}
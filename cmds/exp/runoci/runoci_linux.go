@@ -0,0 +1,314 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// runoci runs an OCI container image.
+//
+// Synopsis:
+//
+//	runoci [OPTIONS] IMAGE [ARG...]
+//
+// Description:
+//
+//	IMAGE is either a directory holding an OCI image layout, from which
+//	the image named with -ref is run, or a registry reference such as
+//	ghcr.io/org/agent:v2, which is pulled over HTTPS into the layout in
+//	-store first. Pulls go through the HTTP or SOCKS5 proxies named by
+//	HTTP_PROXY, HTTPS_PROXY, ALL_PROXY and NO_PROXY, or by the
+//	uroot.http_proxy, uroot.https_proxy, uroot.all_proxy and
+//	uroot.no_proxy kernel command line flags.
+//
+//	The image is unpacked into a root file system, which is run in new
+//	mount, PID, UTS and IPC namespaces with its own /proc, /sys and /dev.
+//	ARGs replace the image's Cmd. The network is the host's unless -net
+//	is given.
+//
+//	runoci exits with the exit code of the container.
+//
+// Options:
+//
+//	-ref:        the name of the image in a layout, if it holds several
+//	-store:      the layout pulled images are kept in
+//	-ca:         PEM file of the CAs trusted for pulls, instead of the system's
+//	-plain-http: pull without TLS
+//	-rootfs:     unpack into this directory, and keep it
+//	-entrypoint: replace the image's entrypoint
+//	-e:          set an environment variable, as KEY=VALUE; may be repeated
+//	-w:          the working directory
+//	-u:          the user, as USER[:GROUP]
+//	-hostname:   the hostname of the container
+//	-net:        give the container a network namespace of its own
+//	-cgroup:     run in this cgroup v2, as a path such as containers/agent
+//	-cpus:       how many CPUs' worth of time the container may use
+//	-memory:     the most memory the container may use, as in 256M
+//	-pids:       the most processes the container may have
+//
+// Example:
+//
+//	runoci -e SERVER=10.0.0.1 -memory 256M ghcr.io/example/provision:latest
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/oci"
+)
+
+var errUsage = errors.New("usage: runoci [OPTIONS] IMAGE [ARG...]")
+
+// envFlag collects -e flags.
+type envFlag []string
+
+func (e *envFlag) String() string { return strings.Join(*e, ",") }
+
+func (e *envFlag) Set(s string) error {
+	if k, _, ok := strings.Cut(s, "="); !ok || k == "" {
+		return fmt.Errorf("%q is not KEY=VALUE", s)
+	}
+	*e = append(*e, s)
+	return nil
+}
+
+type cmd struct {
+	image      string
+	args       []string
+	ref        string
+	store      string
+	ca         string
+	plainHTTP  bool
+	rootfs     string
+	entrypoint string
+	env        envFlag
+	dir        string
+	user       string
+	hostname   string
+	net        bool
+	cgroup     string
+	limits     cgroup.Limits
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	start          func(*oci.Container) error
+}
+
+func command(stdin io.Reader, stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		start:  (*oci.Container).Run,
+	}
+	f := flag.NewFlagSet("runoci", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.StringVar(&c.ref, "ref", "", "the name of the image in a layout")
+	f.StringVar(&c.store, "store", "/var/lib/runoci", "the layout pulled images are kept in")
+	f.StringVar(&c.ca, "ca", "", "PEM file of the CAs trusted for pulls")
+	f.BoolVar(&c.plainHTTP, "plain-http", false, "pull without TLS")
+	f.StringVar(&c.rootfs, "rootfs", "", "unpack into this directory, and keep it")
+	f.StringVar(&c.entrypoint, "entrypoint", "", "replace the image's entrypoint")
+	f.Var(&c.env, "e", "set an environment variable, as KEY=VALUE")
+	f.StringVar(&c.dir, "w", "", "the working directory")
+	f.StringVar(&c.user, "u", "", "the user, as USER[:GROUP]")
+	f.StringVar(&c.hostname, "hostname", "", "the hostname of the container")
+	f.BoolVar(&c.net, "net", false, "give the container a network namespace of its own")
+	f.StringVar(&c.cgroup, "cgroup", "", "run in this cgroup")
+	f.StringVar(&c.limits.CPU, "cpus", "", "how many CPUs' worth of time the container may use")
+	f.StringVar(&c.limits.Memory, "memory", "", "the most memory the container may use")
+	f.StringVar(&c.limits.Pids, "pids", "", "the most processes the container may have")
+	if err := f.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if f.NArg() == 0 {
+		return nil, errUsage
+	}
+	c.image, c.args = f.Arg(0), f.Args()[1:]
+	if err := c.limits.Check(); err != nil {
+		return nil, err
+	}
+	if c.cgroup == "" && c.limited() {
+		c.cgroup = fmt.Sprintf("runoci-%d", os.Getpid())
+	}
+	return c, nil
+}
+
+// limited reports whether limits were given.
+func (c *cmd) limited() bool {
+	return c.limits.CPU != "" || c.limits.Memory != "" || c.limits.Pids != ""
+}
+
+// client returns the HTTP client pulls are made with, or nil for the
+// registry's default.
+func (c *cmd) client() (*http.Client, error) {
+	if c.ca == "" {
+		return nil, nil
+	}
+	pool, err := curl.LoadCertPool(c.ca)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           curl.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}, nil
+}
+
+// layout returns the layout holding the image and its name there, pulling
+// it first if it is a registry reference.
+func (c *cmd) layout() (*oci.Layout, string, error) {
+	if fi, err := os.Stat(c.image); err == nil && fi.IsDir() {
+		l, err := oci.OpenLayout(c.image)
+		return l, c.ref, err
+	}
+	ref, err := oci.ParseReference(c.image)
+	if err != nil {
+		return nil, "", err
+	}
+	l, err := oci.CreateLayout(c.store)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := c.client()
+	if err != nil {
+		return nil, "", err
+	}
+	r := oci.NewRegistry(client)
+	r.PlainHTTP = c.plainHTTP
+	if _, err := r.Pull(context.Background(), ref, l); err != nil {
+		return nil, "", fmt.Errorf("pulling %s: %w", ref, err)
+	}
+	return l, ref.String(), nil
+}
+
+// mergeEnv returns env with the variables of set replacing those of the
+// same name.
+func mergeEnv(env, set []string) []string {
+	var merged []string
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		replaced := false
+		for _, s := range set {
+			if strings.HasPrefix(s, k+"=") {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, e)
+		}
+	}
+	return append(merged, set...)
+}
+
+// container returns the container running img in rootfs.
+func (c *cmd) container(img *oci.Image, rootfs string) (*oci.Container, error) {
+	cfg := img.Config
+	entrypoint, args := cfg.Entrypoint, cfg.Cmd
+	if c.entrypoint != "" {
+		// As with docker, a new entrypoint drops the image's Cmd.
+		entrypoint, args = []string{c.entrypoint}, nil
+	}
+	if len(c.args) > 0 {
+		args = c.args
+	}
+	args = append(append([]string{}, entrypoint...), args...)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s has no command to run", c.image)
+	}
+	user := cfg.User
+	if c.user != "" {
+		user = c.user
+	}
+	uid, gid, err := oci.LookupUser(rootfs, user)
+	if err != nil {
+		return nil, err
+	}
+	dir := cfg.WorkingDir
+	if c.dir != "" {
+		dir = c.dir
+	}
+	env := cfg.Env
+	if len(env) == 0 {
+		env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+	}
+	ctr := &oci.Container{
+		Rootfs:   rootfs,
+		Args:     args,
+		Env:      mergeEnv(env, c.env),
+		Dir:      dir,
+		UID:      uid,
+		GID:      gid,
+		Hostname: c.hostname,
+		NewNet:   c.net,
+		Cgroup:   c.cgroup,
+		Stdin:    c.stdin,
+		Stdout:   c.stdout,
+		Stderr:   c.stderr,
+	}
+	if c.limited() {
+		ctr.Limits = &c.limits
+	}
+	return ctr, nil
+}
+
+func (c *cmd) run() error {
+	l, ref, err := c.layout()
+	if err != nil {
+		return err
+	}
+	m, err := l.Manifest(ref)
+	if err != nil {
+		return err
+	}
+	img, err := l.Image(m)
+	if err != nil {
+		return err
+	}
+	rootfs := c.rootfs
+	if rootfs == "" {
+		if rootfs, err = os.MkdirTemp("", "runoci-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(rootfs)
+		// Whoever the container runs as must be able to get in.
+		if err := os.Chmod(rootfs, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := l.Unpack(m, rootfs); err != nil {
+		return err
+	}
+	ctr, err := c.container(img, rootfs)
+	if err != nil {
+		return err
+	}
+	return c.start(ctr)
+}
+
+func main() {
+	if oci.IsInit() {
+		log.Fatal(oci.Init())
+	}
+	c, err := command(os.Stdin, os.Stdout, os.Stderr, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
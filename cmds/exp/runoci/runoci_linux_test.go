@@ -0,0 +1,161 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+	"github.com/u-root/u-root/pkg/oci"
+)
+
+func add(t *testing.T, l *oci.Layout, mediaType string, b []byte) oci.Descriptor {
+	t.Helper()
+	sum := sha256.Sum256(b)
+	d := oci.Descriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(b))}
+	if err := l.Write(d, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// layout returns a layout holding an image named "agent" with cfg.
+func layout(t *testing.T, cfg oci.Config) string {
+	t.Helper()
+	l, err := oci.CreateLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	files := map[string]string{
+		"etc/passwd": "root:x:0:0::/root:/bin/sh\nagent:x:100:100::/:/bin/false\n",
+		"etc/group":  "root:x:0:\nagent:x:100:\n",
+		"bin/agent":  "#!/bin/sh\n",
+	}
+	for name, body := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Typeflag: tar.TypeReg, Size: int64(len(body))})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	config, err := json.Marshal(oci.Image{Architecture: oci.DefaultPlatform.Architecture, OS: oci.DefaultPlatform.OS, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := json.Marshal(oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeManifest,
+		Config:        add(t, l, oci.MediaTypeConfig, config),
+		Layers:        []oci.Descriptor{add(t, l, oci.MediaTypeLayer, b.Bytes())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Tag(add(t, l, oci.MediaTypeManifest, manifest), "agent"); err != nil {
+		t.Fatal(err)
+	}
+	return l.Dir
+}
+
+func TestCommand(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-e", "NOEQUALS", "img"},
+		{"-bogus", "img"},
+	} {
+		if _, err := command(nil, nil, nil, args); !errors.Is(err, errUsage) {
+			t.Errorf("command(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+	if _, err := command(nil, nil, nil, []string{"-memory", "lots", "img"}); !errors.Is(err, cgroup.ErrLimit) {
+		t.Errorf("command(-memory lots) = %v, want %v", err, cgroup.ErrLimit)
+	}
+	c, err := command(nil, nil, nil, []string{"-memory", "64M", "img", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.image != "img" || !reflect.DeepEqual(c.args, []string{"a", "b"}) || c.cgroup == "" {
+		t.Errorf("command() = %+v, want image img, args [a b] and a cgroup", c)
+	}
+}
+
+func TestRun(t *testing.T) {
+	cfg := oci.Config{
+		User:       "agent",
+		Env:        []string{"PATH=/bin", "SERVER=a"},
+		Entrypoint: []string{"/bin/agent"},
+		Cmd:        []string{"-v"},
+		WorkingDir: "/var",
+	}
+	dir := layout(t, cfg)
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want oci.Container
+		err  bool
+	}{
+		{
+			name: "image",
+			args: []string{"-ref", "agent", dir},
+			want: oci.Container{Args: []string{"/bin/agent", "-v"}, Env: []string{"PATH=/bin", "SERVER=a"}, Dir: "/var", UID: 100, GID: 100},
+		},
+		{
+			name: "overrides",
+			args: []string{"-ref", "agent", "-e", "SERVER=b", "-e", "DEBUG=1", "-u", "0:0", "-w", "/", "-hostname", "box", "-net", "-pids", "10", dir, "-x"},
+			want: oci.Container{
+				Args:     []string{"/bin/agent", "-x"},
+				Env:      []string{"PATH=/bin", "SERVER=b", "DEBUG=1"},
+				Dir:      "/",
+				Hostname: "box",
+				NewNet:   true,
+				Limits:   &cgroup.Limits{Pids: "10"},
+			},
+		},
+		{
+			name: "entrypoint",
+			args: []string{"-ref", "agent", "-entrypoint", "/bin/sh", dir},
+			want: oci.Container{Args: []string{"/bin/sh"}, Env: []string{"PATH=/bin", "SERVER=a"}, Dir: "/var", UID: 100, GID: 100},
+		},
+		{name: "unknown user", args: []string{"-ref", "agent", "-u", "nobody", dir}, err: true},
+		{name: "unknown image", args: []string{"-ref", "other", dir}, err: true},
+		{name: "bad reference", args: []string{"-store", t.TempDir(), "Not/A/Ref"}, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := command(nil, nil, nil, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got *oci.Container
+			c.start = func(ctr *oci.Container) error {
+				if _, err := os.Stat(filepath.Join(ctr.Rootfs, "bin/agent")); err != nil {
+					t.Errorf("image was not unpacked: %v", err)
+				}
+				got = ctr
+				return nil
+			}
+			err = c.run()
+			if (err != nil) != tt.err {
+				t.Fatalf("run() = %v, want error %t", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			tt.want.Rootfs, tt.want.Cgroup = got.Rootfs, got.Cgroup
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("run() starts %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,571 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// input reads lines from files in turn, looking one line ahead to know
+// which is the last.
+type input struct {
+	stdin  io.Reader
+	stderr io.Writer
+	names  []string
+	r      *bufio.Reader
+	f      *os.File
+	// failed is set if a file could not be read.
+	failed bool
+
+	peeked  bool
+	next    string
+	nextNL  bool
+	nextOK  bool
+	nextErr error
+}
+
+// read returns the next line, and whether it ended in a newline.
+func (in *input) read() (string, bool, bool, error) {
+	if in.peeked {
+		in.peeked = false
+		return in.next, in.nextNL, in.nextOK, in.nextErr
+	}
+	for {
+		if in.r == nil {
+			if len(in.names) == 0 {
+				return "", false, false, nil
+			}
+			name := in.names[0]
+			in.names = in.names[1:]
+			if name == "-" {
+				in.r = bufio.NewReader(in.stdin)
+			} else {
+				f, err := os.Open(name)
+				if err != nil {
+					fmt.Fprintf(in.stderr, "sed: %v\n", err)
+					in.failed = true
+					continue
+				}
+				in.r, in.f = bufio.NewReader(f), f
+			}
+		}
+		l, err := in.r.ReadString('\n')
+		if l != "" {
+			if nl := strings.HasSuffix(l, "\n"); nl {
+				return l[:len(l)-1], true, true, nil
+			}
+			return l, false, true, nil
+		}
+		if err != io.EOF {
+			return "", false, false, err
+		}
+		in.close()
+	}
+}
+
+// last reports whether the last line was read.
+func (in *input) last() bool {
+	if !in.peeked {
+		in.next, in.nextNL, in.nextOK, in.nextErr = in.read()
+		in.peeked = true
+	}
+	return !in.nextOK && in.nextErr == nil
+}
+
+func (in *input) close() {
+	if in.f != nil {
+		in.f.Close()
+	}
+	in.r, in.f = nil, nil
+}
+
+// output writes the pattern space and text, adding the newline missing at
+// the end of the last line if more follows.
+type output struct {
+	w       *bufio.Writer
+	missing bool
+}
+
+func (o *output) write(s string, newline bool) error {
+	if o.missing {
+		if err := o.w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if _, err := o.w.WriteString(s); err != nil {
+		return err
+	}
+	o.missing = !newline
+	if newline {
+		return o.w.WriteByte('\n')
+	}
+	return nil
+}
+
+// appended is text of a or a file of r, written at the end of the cycle.
+type appended struct {
+	text string
+	file string
+}
+
+// sed runs a script on an input.
+type sed struct {
+	cmds  []*instr
+	quiet bool
+	in    *input
+	out   *output
+
+	line    int
+	ps, hs  string
+	newline bool
+	// replaced is the flag of t, set by substitutions.
+	replaced bool
+	appends  []appended
+	lastRE   *regexp.Regexp
+	quit     bool
+	code     int
+}
+
+// reset starts the ranges over for the next file of -s and -i.
+func (s *sed) reset() {
+	s.line = 0
+	for _, c := range s.cmds {
+		c.active = false
+	}
+}
+
+// next reads the next line into the pattern space, which clears the flag
+// of t.
+func (s *sed) next() (bool, error) {
+	l, nl, ok, err := s.in.read()
+	if err != nil || !ok {
+		return false, err
+	}
+	s.line++
+	s.ps, s.newline = l, nl
+	s.replaced = false
+	return true, nil
+}
+
+// run runs the script on each line until the input ends or it quits.
+func (s *sed) run() error {
+	for !s.quit {
+		ok, err := s.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := s.cycle(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cycle runs the script on the pattern space. D restarts it without
+// reading a new line.
+func (s *sed) cycle() error {
+	for {
+		restart, err := s.execute()
+		if err != nil {
+			return err
+		}
+		if !restart {
+			return nil
+		}
+	}
+}
+
+func (s *sed) endCycle(print bool) error {
+	if print && !s.quiet {
+		if err := s.out.write(s.ps, s.newline); err != nil {
+			return err
+		}
+	}
+	return s.flushAppends()
+}
+
+func (s *sed) flushAppends() error {
+	for _, a := range s.appends {
+		if a.file == "" {
+			if err := s.out.write(a.text, true); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := os.ReadFile(a.file)
+		if err != nil {
+			// Files which can't be read are ignored.
+			continue
+		}
+		if len(b) > 0 {
+			text := string(b)
+			nl := strings.HasSuffix(text, "\n")
+			if err := s.out.write(strings.TrimSuffix(text, "\n"), nl); err != nil {
+				return err
+			}
+		}
+	}
+	s.appends = s.appends[:0]
+	return s.out.w.Flush()
+}
+
+// regex returns re, or the last regex used for the empty one.
+func (s *sed) regex(re *regexp.Regexp) (*regexp.Regexp, error) {
+	if re == nil {
+		if s.lastRE == nil {
+			return nil, fmt.Errorf("%w: no previous regular expression", errScript)
+		}
+		re = s.lastRE
+	}
+	s.lastRE = re
+	return re, nil
+}
+
+func (s *sed) match(re *regexp.Regexp, t string) (bool, error) {
+	re, err := s.regex(re)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(t), nil
+}
+
+func (s *sed) matchAddr(a *address) (bool, error) {
+	switch a.kind {
+	case addrLine:
+		return s.line == a.line, nil
+	case addrLast:
+		return s.in.last(), nil
+	case addrStep:
+		if a.step <= 0 {
+			return s.line == a.line, nil
+		}
+		return s.line >= a.line && (s.line-a.line)%a.step == 0, nil
+	case addrZero:
+		return s.line == 1, nil
+	}
+	return s.match(a.re, s.ps)
+}
+
+// selects reports whether the addresses of c select the pattern space.
+func (s *sed) selects(c *instr) (bool, error) {
+	ok, err := s.inRange(c)
+	return ok != c.negate, err
+}
+
+func (s *sed) inRange(c *instr) (bool, error) {
+	if c.addr1 == nil {
+		return true, nil
+	}
+	if c.addr2 == nil {
+		return s.matchAddr(c.addr1)
+	}
+	a2 := c.addr2
+	if c.active {
+		switch a2.kind {
+		case addrLine:
+			// A range whose end is before the line ends right away.
+			if s.line > a2.line {
+				c.active = false
+				return false, nil
+			}
+			c.active = s.line < a2.line
+		case addrRelative:
+			c.active = s.line < c.endLine
+		case addrMultiple:
+			c.active = s.line%a2.line != 0
+		default:
+			ok, err := s.matchAddr(a2)
+			if err != nil {
+				return false, err
+			}
+			c.active = !ok
+		}
+		return true, nil
+	}
+	ok, err := s.matchAddr(c.addr1)
+	if err != nil || !ok {
+		return false, err
+	}
+	c.active = true
+	switch a2.kind {
+	case addrLine:
+		c.active = s.line < a2.line
+	case addrRelative:
+		c.endLine = s.line + a2.line
+		c.active = a2.line > 0
+	case addrMultiple:
+		c.active = a2.line > 0 && s.line%a2.line != 0
+	case addrLast:
+		c.active = !s.in.last()
+	case addrRegex:
+		// The end is looked for from the next line, except after 0.
+		if c.addr1.kind == addrZero {
+			ok, err := s.match(a2.re, s.ps)
+			if err != nil {
+				return false, err
+			}
+			c.active = !ok
+		}
+	}
+	return true, nil
+}
+
+// execute runs the commands once and reports whether D restarts them.
+func (s *sed) execute() (bool, error) {
+	for pc := 0; pc < len(s.cmds); pc++ {
+		c := s.cmds[pc]
+		ok, err := s.selects(c)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			if c.name == '{' {
+				pc = c.target
+			}
+			continue
+		}
+		switch c.name {
+		case '{', '}', ':':
+		case '=':
+			if err := s.out.write(fmt.Sprint(s.line), true); err != nil {
+				return false, err
+			}
+		case 'a':
+			s.appends = append(s.appends, appended{text: c.text})
+		case 'i':
+			if err := s.out.write(c.text, true); err != nil {
+				return false, err
+			}
+		case 'c':
+			// A range is changed into the text once, at its end.
+			if c.addr2 == nil || !c.active || c.negate {
+				if err := s.out.write(c.text, true); err != nil {
+					return false, err
+				}
+			}
+			return false, s.endCycle(false)
+		case 'b':
+			pc = c.target - 1
+		case 't', 'T':
+			if s.replaced == (c.name == 't') {
+				pc = c.target - 1
+			}
+			s.replaced = false
+		case 'd':
+			return false, s.endCycle(false)
+		case 'D':
+			i := strings.IndexByte(s.ps, '\n')
+			if i < 0 {
+				return false, s.endCycle(false)
+			}
+			s.ps = s.ps[i+1:]
+			if err := s.flushAppends(); err != nil {
+				return false, err
+			}
+			return true, nil
+		case 'g':
+			s.ps = s.hs
+		case 'G':
+			s.ps += "\n" + s.hs
+		case 'h':
+			s.hs = s.ps
+		case 'H':
+			s.hs += "\n" + s.ps
+		case 'x':
+			s.ps, s.hs = s.hs, s.ps
+		case 'z':
+			s.ps = ""
+		case 'l':
+			if err := s.list(); err != nil {
+				return false, err
+			}
+		case 'n', 'N':
+			if s.in.last() {
+				// Without more input, sed ends as with q.
+				s.quit = true
+				return false, s.endCycle(true)
+			}
+			if c.name == 'n' {
+				if err := s.endCycle(true); err != nil {
+					return false, err
+				}
+				if _, err := s.next(); err != nil {
+					return false, err
+				}
+				continue
+			}
+			if err := s.flushAppends(); err != nil {
+				return false, err
+			}
+			ps := s.ps
+			if _, err := s.next(); err != nil {
+				return false, err
+			}
+			s.ps = ps + "\n" + s.ps
+		case 'p':
+			if err := s.out.write(s.ps, true); err != nil {
+				return false, err
+			}
+		case 'P':
+			first, _, _ := strings.Cut(s.ps, "\n")
+			if err := s.out.write(first, true); err != nil {
+				return false, err
+			}
+		case 'q':
+			s.quit, s.code = true, c.code
+			return false, s.endCycle(true)
+		case 'Q':
+			s.quit, s.code = true, c.code
+			return false, nil
+		case 'r':
+			s.appends = append(s.appends, appended{file: c.text})
+		case 'w':
+			if err := s.writeFile(c.w, s.ps); err != nil {
+				return false, err
+			}
+		case 's':
+			if err := s.substitute(c); err != nil {
+				return false, err
+			}
+		case 'y':
+			s.ps = strings.Map(func(r rune) rune {
+				for i, f := range c.from {
+					if f == r {
+						return c.to[i]
+					}
+				}
+				return r
+			}, s.ps)
+		}
+	}
+	return false, s.endCycle(true)
+}
+
+func (s *sed) substitute(c *instr) error {
+	re, err := s.regex(c.re)
+	if err != nil {
+		return err
+	}
+	matches := re.FindAllStringSubmatchIndex(s.ps, -1)
+	var b strings.Builder
+	last, n := 0, 0
+	for i, m := range matches {
+		if i+1 < c.nth || (i+1 > c.nth && !c.global) {
+			continue
+		}
+		b.WriteString(s.ps[last:m[0]])
+		for _, p := range c.repl {
+			switch {
+			case p.group < 0:
+				b.WriteString(p.text)
+			case 2*p.group+1 < len(m) && m[2*p.group] >= 0:
+				b.WriteString(s.ps[m[2*p.group]:m[2*p.group+1]])
+			}
+		}
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	b.WriteString(s.ps[last:])
+	s.ps = b.String()
+	s.replaced = true
+	if c.print {
+		if err := s.out.write(s.ps, true); err != nil {
+			return err
+		}
+	}
+	if c.w != nil {
+		return s.writeFile(c.w, s.ps)
+	}
+	return nil
+}
+
+// writeFile writes a line to the file of w.
+func (s *sed) writeFile(f *outFile, line string) error {
+	if f.name == "/dev/stdout" {
+		return s.out.write(line, true)
+	}
+	return f.out.write(line, true)
+}
+
+// list writes the pattern space unambiguously, for l.
+func (s *sed) list() error {
+	const width = 70
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(s.ps); i++ {
+		var e string
+		switch c := s.ps[i]; {
+		case c == '\\':
+			e = `\\`
+		case c == '\a':
+			e = `\a`
+		case c == '\b':
+			e = `\b`
+		case c == '\f':
+			e = `\f`
+		case c == '\n':
+			e = `\n`
+		case c == '\r':
+			e = `\r`
+		case c == '\t':
+			e = `\t`
+		case c == '\v':
+			e = `\v`
+		case c < ' ' || c >= 0x7f:
+			e = fmt.Sprintf(`\%03o`, c)
+		default:
+			e = string(c)
+		}
+		if col+len(e) > width-1 {
+			b.WriteString("\\\n")
+			col = 0
+		}
+		b.WriteString(e)
+		col += len(e)
+	}
+	b.WriteByte('$')
+	return s.out.write(b.String(), true)
+}
+
+// openFiles creates the files of w, even those never written.
+func (s *sed) openFiles() error {
+	for _, c := range s.cmds {
+		if c.w == nil || c.w.f != nil || c.w.name == "/dev/stdout" {
+			continue
+		}
+		f, err := os.Create(c.w.name)
+		if err != nil {
+			return err
+		}
+		c.w.f, c.w.out = f, &output{w: bufio.NewWriter(f)}
+	}
+	return nil
+}
+
+// closeFiles flushes and closes the files of w.
+func (s *sed) closeFiles() error {
+	var err error
+	for _, c := range s.cmds {
+		if c.w == nil || c.w.f == nil {
+			continue
+		}
+		if ferr := c.w.out.w.Flush(); err == nil {
+			err = ferr
+		}
+		if cerr := c.w.f.Close(); err == nil {
+			err = cerr
+		}
+		c.w.f = nil
+	}
+	return err
+}
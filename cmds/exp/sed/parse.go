@@ -0,0 +1,702 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var errScript = errors.New("bad script")
+
+type addrKind int
+
+const (
+	addrLine addrKind = iota
+	addrLast
+	addrRegex
+	// addrStep is first~step.
+	addrStep
+	// addrZero is the 0 of 0,/re/, whose range may end on line 1.
+	addrZero
+	// addrRelative and addrMultiple are the ends +N and ~N of ranges.
+	addrRelative
+	addrMultiple
+)
+
+type address struct {
+	kind addrKind
+	line int
+	step int
+	// re is nil for the empty regex, which is the last one used.
+	re *regexp.Regexp
+}
+
+// instr is a command of a script. Ranges keep their state in it.
+type instr struct {
+	addr1, addr2 *address
+	negate       bool
+	name         byte
+
+	// text is the text of a, i and c, the label of : and branches, or the
+	// file of r.
+	text string
+	// target is where branches jump, or the } of a {.
+	target int
+	label  string
+	code   int
+	w      *outFile
+
+	// s
+	re     *regexp.Regexp
+	repl   []replPart
+	global bool
+	nth    int
+	print  bool
+
+	// y
+	from, to []rune
+
+	active  bool
+	endLine int
+}
+
+// replPart is literal text or, if group is not -1, a group of the match.
+type replPart struct {
+	text  string
+	group int
+}
+
+// outFile is a file of w commands, which all commands naming it share.
+type outFile struct {
+	name string
+	f    *os.File
+	out  *output
+}
+
+type parser struct {
+	src      string
+	pos      int
+	extended bool
+	cmds     []*instr
+	files    map[string]*outFile
+	// quiet is set by #n on the first line.
+	quiet bool
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: char %d: %s", errScript, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// parseScript parses a sed script into commands, with branches and blocks
+// resolved.
+func parseScript(src string, extended bool) ([]*instr, bool, error) {
+	p := &parser{src: src, extended: extended, files: map[string]*outFile{}}
+	if strings.HasPrefix(src, "#n\n") || src == "#n" {
+		p.quiet = true
+	}
+	var blocks []int
+	for {
+		for !p.eof() && strings.IndexByte(" \t\n;", p.peek()) >= 0 {
+			p.pos++
+		}
+		if p.eof() {
+			break
+		}
+		if p.peek() == '#' {
+			p.line()
+			continue
+		}
+		c, err := p.command()
+		if err != nil {
+			return nil, false, err
+		}
+		switch c.name {
+		case '{':
+			blocks = append(blocks, len(p.cmds))
+		case '}':
+			if len(blocks) == 0 {
+				return nil, false, p.errorf("unexpected }")
+			}
+			p.cmds[blocks[len(blocks)-1]].target = len(p.cmds)
+			blocks = blocks[:len(blocks)-1]
+		}
+		p.cmds = append(p.cmds, c)
+	}
+	if len(blocks) > 0 {
+		return nil, false, p.errorf("unmatched {")
+	}
+	labels := map[string]int{}
+	for i, c := range p.cmds {
+		if c.name == ':' {
+			if _, ok := labels[c.label]; ok {
+				return nil, false, fmt.Errorf("%w: duplicate label %q", errScript, c.label)
+			}
+			labels[c.label] = i
+		}
+	}
+	for _, c := range p.cmds {
+		switch c.name {
+		case 'b', 't', 'T':
+			if c.label == "" {
+				c.target = len(p.cmds)
+				continue
+			}
+			i, ok := labels[c.label]
+			if !ok {
+				return nil, false, fmt.Errorf("%w: can't find label %q", errScript, c.label)
+			}
+			c.target = i
+		}
+	}
+	return p.cmds, p.quiet, nil
+}
+
+// line returns the rest of the line.
+func (p *parser) line() string {
+	start := p.pos
+	for !p.eof() && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// label returns a label, which ends at a newline or ;.
+func (p *parser) label() string {
+	p.skipSpace()
+	start := p.pos
+	for !p.eof() && p.src[p.pos] != '\n' && p.src[p.pos] != ';' {
+		p.pos++
+	}
+	return strings.TrimRight(p.src[start:p.pos], " \t")
+}
+
+func (p *parser) number() (int, bool) {
+	start := p.pos
+	for !p.eof() && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	return n, err == nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func (p *parser) address(second bool) (*address, error) {
+	c := p.peek()
+	switch {
+	case isDigit(c):
+		n, _ := p.number()
+		if p.peek() == '~' && !second {
+			p.pos++
+			step, _ := p.number()
+			return &address{kind: addrStep, line: n, step: step}, nil
+		}
+		if n == 0 && !second {
+			return &address{kind: addrZero}, nil
+		}
+		return &address{kind: addrLine, line: n}, nil
+	case c == '$':
+		p.pos++
+		return &address{kind: addrLast}, nil
+	case second && (c == '+' || c == '~'):
+		p.pos++
+		n, ok := p.number()
+		if !ok {
+			return nil, p.errorf("expected a number after %c", c)
+		}
+		if c == '+' {
+			return &address{kind: addrRelative, line: n}, nil
+		}
+		return &address{kind: addrMultiple, line: n}, nil
+	case c == '/' || c == '\\':
+		p.pos++
+		if c == '\\' {
+			if p.eof() {
+				return nil, p.errorf("unterminated address regex")
+			}
+			c = p.src[p.pos]
+			p.pos++
+		}
+		s, err := p.delimited(c, true)
+		if err != nil {
+			return nil, err
+		}
+		flags := ""
+		for p.peek() == 'I' || p.peek() == 'M' {
+			flags += string(p.src[p.pos])
+			p.pos++
+		}
+		re, err := compile(s, flags, p.extended)
+		if err != nil {
+			return nil, err
+		}
+		return &address{kind: addrRegex, re: re}, nil
+	}
+	return nil, nil
+}
+
+// delimited returns the text up to the unescaped delimiter d, where \d is
+// d and \n a newline for regexes.
+func (p *parser) delimited(d byte, regex bool) (string, error) {
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated `%c'", d)
+		}
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == d:
+			return b.String(), nil
+		case c == '\n' && regex:
+			return "", p.errorf("unterminated `%c'", d)
+		case c == '\\' && !p.eof():
+			e := p.src[p.pos]
+			p.pos++
+			switch {
+			case e == d:
+				b.WriteByte(d)
+			case e == 'n' && regex:
+				b.WriteByte('\n')
+			case e == '\n':
+				// An escaped newline is a newline.
+				b.WriteByte('\n')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// end ends a command, which may be followed by spaces, a comment, or ; or
+// a newline or }.
+func (p *parser) end() error {
+	p.skipSpace()
+	switch p.peek() {
+	case 0, '\n', ';':
+		if !p.eof() {
+			p.pos++
+		}
+	case '}', '#':
+	default:
+		return p.errorf("extra characters after command")
+	}
+	return nil
+}
+
+func (p *parser) command() (*instr, error) {
+	c := &instr{}
+	var err error
+	if c.addr1, err = p.address(false); err != nil {
+		return nil, err
+	}
+	if c.addr1 != nil {
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpace()
+			if c.addr2, err = p.address(true); err != nil {
+				return nil, err
+			}
+			if c.addr2 == nil {
+				return nil, p.errorf("unexpected `,'")
+			}
+		}
+	}
+	if c.addr1 != nil && c.addr1.kind == addrZero && (c.addr2 == nil || c.addr2.kind != addrRegex) {
+		return nil, p.errorf("invalid usage of line address 0")
+	}
+	p.skipSpace()
+	for p.peek() == '!' {
+		c.negate = true
+		p.pos++
+		p.skipSpace()
+	}
+	if p.eof() {
+		return nil, p.errorf("missing command")
+	}
+	c.name = p.src[p.pos]
+	p.pos++
+	switch c.name {
+	case '{':
+		return c, nil
+	case '}', ':':
+		if c.addr1 != nil {
+			return nil, p.errorf("%c doesn't want any addresses", c.name)
+		}
+		if c.name == ':' {
+			if c.label = p.label(); c.label == "" {
+				return nil, p.errorf("\":\" lacks a label")
+			}
+			if !p.eof() {
+				p.pos++
+			}
+			return c, nil
+		}
+		return c, p.end()
+	case '=', 'd', 'D', 'g', 'G', 'h', 'H', 'l', 'n', 'N', 'p', 'P', 'x', 'z':
+		return c, p.end()
+	case 'q', 'Q':
+		p.skipSpace()
+		c.code, _ = p.number()
+		return c, p.end()
+	case 'a', 'i', 'c':
+		c.text = p.text()
+		return c, nil
+	case 'b', 't', 'T':
+		c.label = p.label()
+		return c, p.end()
+	case 'r', 'w':
+		p.skipSpace()
+		name := p.line()
+		if name == "" {
+			return nil, p.errorf("missing filename in r/R/w/W commands")
+		}
+		if c.name == 'r' {
+			c.text = name
+			return c, nil
+		}
+		c.w, err = p.file(name)
+		return c, err
+	case 's':
+		return c, p.substitute(c)
+	case 'y':
+		return c, p.transliterate(c)
+	}
+	p.pos--
+	return nil, p.errorf("unknown command: `%c'", c.name)
+}
+
+// text parses the text of a, i and c, which is either on the following
+// lines, as in a\, or the rest of the line. Lines ending in a backslash
+// continue the text.
+func (p *parser) text() string {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], "\\\n") {
+		p.pos += 2
+	} else if p.peek() == '\\' {
+		p.pos++
+	}
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		if c == '\n' {
+			break
+		}
+		if c == '\\' && !p.eof() {
+			c = p.src[p.pos]
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (p *parser) file(name string) (*outFile, error) {
+	if f, ok := p.files[name]; ok {
+		return f, nil
+	}
+	f := &outFile{name: name}
+	p.files[name] = f
+	return f, nil
+}
+
+func (p *parser) substitute(c *instr) error {
+	if p.eof() || p.peek() == '\n' || p.peek() == '\\' {
+		return p.errorf("unterminated `s' command")
+	}
+	d := p.src[p.pos]
+	p.pos++
+	re, err := p.delimited(d, true)
+	if err != nil {
+		return err
+	}
+	repl, err := p.delimited(d, false)
+	if err != nil {
+		return err
+	}
+	c.repl = parseReplacement(repl)
+	flags := ""
+	c.nth = 1
+	for !p.eof() {
+		switch f := p.peek(); {
+		case f == 'g':
+			c.global = true
+		case f == 'p':
+			c.print = true
+		case f == 'i' || f == 'I':
+			flags += "I"
+		case f == 'm' || f == 'M':
+			flags += "M"
+		case isDigit(f):
+			n, _ := p.number()
+			if n == 0 {
+				return p.errorf("number option to `s' command may not be zero")
+			}
+			c.nth = n
+			continue
+		case f == 'w':
+			p.pos++
+			p.skipSpace()
+			name := p.line()
+			if name == "" {
+				return p.errorf("missing filename in r/R/w/W commands")
+			}
+			if c.w, err = p.file(name); err != nil {
+				return err
+			}
+			c.re, err = compile(re, flags, p.extended)
+			return err
+		default:
+			if c.re, err = compile(re, flags, p.extended); err != nil {
+				return err
+			}
+			return p.end()
+		}
+		p.pos++
+	}
+	c.re, err = compile(re, flags, p.extended)
+	return err
+}
+
+// parseReplacement parses the replacement of s, where & is the match, \N
+// group N and \n a newline.
+func parseReplacement(s string) []replPart {
+	var parts []replPart
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			parts = append(parts, replPart{text: b.String(), group: -1})
+			b.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '&':
+			flush()
+			parts = append(parts, replPart{group: 0})
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; {
+			case isDigit(e):
+				flush()
+				parts = append(parts, replPart{group: int(e - '0')})
+			case e == 'n':
+				b.WriteByte('\n')
+			case e == 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return parts
+}
+
+func (p *parser) transliterate(c *instr) error {
+	if p.eof() || p.peek() == '\n' || p.peek() == '\\' {
+		return p.errorf("unterminated `y' command")
+	}
+	d := p.src[p.pos]
+	p.pos++
+	var sides [2][]rune
+	for i := range sides {
+		s, err := p.delimited(d, false)
+		if err != nil {
+			return err
+		}
+		for j := 0; j < len(s); {
+			r, n := utf8.DecodeRuneInString(s[j:])
+			j += n
+			if r == '\\' && j < len(s) {
+				switch s[j] {
+				case 'n':
+					r = '\n'
+				case '\\':
+					r = '\\'
+				default:
+					return p.errorf("unknown escape in `y' command")
+				}
+				j++
+			}
+			sides[i] = append(sides[i], r)
+		}
+	}
+	if len(sides[0]) != len(sides[1]) {
+		return p.errorf("strings for `y' command are different lengths")
+	}
+	c.from, c.to = sides[0], sides[1]
+	return p.end()
+}
+
+// compile compiles a regex of sed, which is a BRE unless extended is
+// set, with the flags I and M. The empty regex is nil, for the last one
+// used.
+func compile(s, flags string, extended bool) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := translate(s, extended)
+	if err != nil {
+		return nil, err
+	}
+	// . matches newlines in the pattern space.
+	prefix := "(?s"
+	if strings.Contains(flags, "I") {
+		prefix += "i"
+	}
+	if strings.Contains(flags, "M") {
+		prefix += "m"
+	}
+	re, err := regexp.Compile(prefix + ")" + t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", errScript, s, err)
+	}
+	re.Longest()
+	return re, nil
+}
+
+// translate translates a BRE or ERE of POSIX, with the extensions of GNU,
+// into the syntax of Go.
+func translate(s string, extended bool) (string, error) {
+	var b strings.Builder
+	// start is set where * is literal in a BRE and ^ an anchor.
+	start := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		wasStart := start
+		start = false
+		switch {
+		case c == '[':
+			j := i + 1
+			if j < len(s) && s[j] == '^' {
+				j++
+			}
+			if j < len(s) && s[j] == ']' {
+				j++
+			}
+			for j < len(s) && s[j] != ']' {
+				if s[j] == '[' && j+1 < len(s) && strings.IndexByte(":.=", s[j+1]) >= 0 {
+					if k := strings.Index(s[j+2:], string(s[j+1])+"]"); k >= 0 {
+						j += k + 4
+						continue
+					}
+				}
+				j++
+			}
+			if j >= len(s) {
+				return "", fmt.Errorf("%w: unterminated [ in %q", errScript, s)
+			}
+			b.WriteByte('[')
+			for k := i + 1; k < j; k++ {
+				switch {
+				case s[k] == '\\' && k+1 < j && s[k+1] == 'n':
+					b.WriteString(`\n`)
+					k++
+				case s[k] == '\\' && k+1 < j && s[k+1] == 't':
+					b.WriteString(`\t`)
+					k++
+				case s[k] == '\\':
+					b.WriteString(`\\`)
+				case s[k] == '[' && (k+1 >= j || strings.IndexByte(":.=", s[k+1]) < 0):
+					b.WriteString(`\[`)
+				default:
+					b.WriteByte(s[k])
+				}
+			}
+			b.WriteByte(']')
+			i = j
+		case c == '\\' && i+1 < len(s):
+			i++
+			e := s[i]
+			switch e {
+			case '(', '|':
+				start = !extended
+				fallthrough
+			case ')', '{', '}', '+', '?':
+				if extended {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(e)
+			case 'n':
+				b.WriteString(`\n`)
+			case 't':
+				b.WriteString(`\t`)
+			case '<', '>':
+				b.WriteString(`\b`)
+			case '`':
+				b.WriteString(`\A`)
+			case '\'':
+				b.WriteString(`\z`)
+			case 'b', 'B', 'w', 'W', 's', 'S':
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				return "", fmt.Errorf("%w: back-references are not supported: %q", errScript, s)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(e)))
+			}
+		case extended:
+			b.WriteByte(c)
+			start = c == '(' || c == '|'
+		case strings.IndexByte("(){}|+?", c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '*' && wasStart:
+			b.WriteString(`\*`)
+		case c == '^':
+			if wasStart {
+				b.WriteByte('^')
+				start = true
+			} else {
+				b.WriteString(`\^`)
+			}
+		case c == '$':
+			// $ is an anchor at the end, or before \) or \|.
+			if rest := s[i+1:]; rest == "" || strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`) {
+				b.WriteByte('$')
+			} else {
+				b.WriteString(`\$`)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sed edits streams of text.
+//
+// Synopsis:
+//
+//	sed [-n] [-E] [-s] [-i[SUFFIX]] SCRIPT [FILE]...
+//	sed [-n] [-E] [-s] [-i[SUFFIX]] [-e SCRIPT]... [-f SCRIPTFILE]... [FILE]...
+//
+// Description:
+//
+//	sed runs the SCRIPT on each line of the FILEs, or stdin if there are
+//	none, and prints the result. Commands may be selected by a line
+//	number, $ for the last line, first~step, a /regex/ or \cregexc, or a
+//	range of two such addresses, where the second may also be +N or ~N and
+//	the first 0 for a range ending on line 1; ! selects the other lines.
+//
+//	The commands are those of POSIX and most of GNU sed: { } = a b c d D
+//	g G h H i l n N p P q Q r s t T w x y z and : for labels. The flags of
+//	s are g, p, a number, I, M and w FILE. Regexes are basic ones unless
+//	-E is given, and back-references are not supported.
+//
+//	With -i, files are edited in place. If a SUFFIX is given, the original
+//	file is kept with the suffix appended, or with * in the suffix
+//	replaced by the name of the file.
+//
+// Options:
+//
+//	-n: only print what the script prints
+//	-e: add SCRIPT to the commands
+//	-f: add the commands of SCRIPTFILE
+//	-E, -r: use extended regexes
+//	-i: edit files in place, keeping a backup if SUFFIX is given
+//	-s: treat files as separate rather than as one stream
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errUsage  = errors.New("usage: sed [-n] [-E] [-s] [-i[SUFFIX]] {SCRIPT | -e SCRIPT... | -f SCRIPTFILE...} [FILE]...")
+	errFailed = errors.New("some files could not be read")
+)
+
+// list is a flag which may be given more than once.
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// inPlace is the -i flag, with an optional suffix for backups.
+type inPlace struct {
+	set    bool
+	suffix string
+}
+
+func (i *inPlace) String() string { return i.suffix }
+
+func (i *inPlace) Set(s string) error {
+	i.set = true
+	if s != "true" {
+		i.suffix = s
+	}
+	return nil
+}
+
+func (i *inPlace) IsBoolFlag() bool { return true }
+
+type cmd struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	cmds     []*instr
+	quiet    bool
+	inPlace  inPlace
+	separate bool
+	files    []string
+}
+
+// splitFlags splits combined options like -ne and attaches the suffix of
+// -i, which is optional, so that the flag package takes them.
+func splitFlags(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || len(a) < 2 || a[0] != '-' || a[1] == '-' {
+			if a == "--" || len(a) < 2 || a[0] != '-' {
+				return append(out, args[i:]...)
+			}
+			out = append(out, a)
+			continue
+		}
+	opts:
+		for j := 1; j < len(a); j++ {
+			switch a[j] {
+			case 'i':
+				if j+1 < len(a) {
+					out = append(out, "-i="+a[j+1:])
+				} else {
+					out = append(out, "-i")
+				}
+				break opts
+			case 'e', 'f':
+				out = append(out, "-"+a[j:j+1])
+				if j+1 < len(a) {
+					out = append(out, a[j+1:])
+				} else if i+1 < len(args) {
+					out = append(out, args[i+1])
+					i++
+				}
+				break opts
+			default:
+				out = append(out, "-"+a[j:j+1])
+			}
+		}
+	}
+	return out
+}
+
+func command(stdin io.Reader, stdout, stderr io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdin: stdin, stdout: stdout, stderr: stderr}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.BoolVar(&c.quiet, "n", false, "Only print what the script prints")
+	var scripts, files list
+	f.Var(&scripts, "e", "Add the script to the commands")
+	f.Var(&files, "f", "Add the commands of the file")
+	extended := f.Bool("E", false, "Use extended regexes")
+	f.BoolVar(extended, "r", false, "Use extended regexes")
+	f.Var(&c.inPlace, "i", "Edit files in place, keeping backups with the suffix")
+	f.BoolVar(&c.separate, "s", false, "Treat files as separate")
+	if err := f.Parse(splitFlags(args[1:])); err != nil {
+		return nil, errUsage
+	}
+	c.files = f.Args()
+	for _, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, strings.TrimSuffix(string(b), "\n"))
+	}
+	if len(scripts) == 0 {
+		if len(c.files) == 0 {
+			return nil, errUsage
+		}
+		scripts, c.files = c.files[:1], c.files[1:]
+	}
+	cmds, quiet, err := parseScript(strings.Join(scripts, "\n"), *extended)
+	if err != nil {
+		return nil, err
+	}
+	c.cmds, c.quiet = cmds, c.quiet || quiet
+	if c.inPlace.set && len(c.files) == 0 {
+		return nil, fmt.Errorf("%w: -i needs files", errUsage)
+	}
+	return c, nil
+}
+
+// backup returns the name of the backup of the file path.
+func (c *cmd) backup(path string) string {
+	if !strings.Contains(c.inPlace.suffix, "*") {
+		return path + c.inPlace.suffix
+	}
+	dir, base := filepath.Split(path)
+	name := strings.ReplaceAll(c.inPlace.suffix, "*", base)
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// edit edits a file in place, writing to a temporary file which replaces
+// it.
+func (c *cmd) edit(s *sed, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("couldn't edit %s: not a regular file", path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sed")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	s.in = &input{stdin: c.stdin, stderr: c.stderr, names: []string{path}}
+	s.out = &output{w: bufio.NewWriter(tmp)}
+	s.reset()
+	if err := s.run(); err != nil {
+		return err
+	}
+	if err := s.out.w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if c.inPlace.suffix != "" {
+		if err := os.Rename(path, c.backup(path)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// run runs the script and returns the exit status of q or Q.
+func (c *cmd) run() (int, error) {
+	s := &sed{cmds: c.cmds, quiet: c.quiet}
+	if err := s.openFiles(); err != nil {
+		return 0, err
+	}
+	defer s.closeFiles()
+	failed := false
+	names := c.files
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	switch {
+	case c.inPlace.set:
+		for _, name := range names {
+			if err := c.edit(s, name); err != nil {
+				fmt.Fprintf(c.stderr, "sed: %v\n", err)
+				failed = true
+			}
+			if s.quit {
+				break
+			}
+		}
+	default:
+		out := &output{w: bufio.NewWriter(c.stdout)}
+		groups := [][]string{names}
+		if c.separate {
+			groups = nil
+			for _, name := range names {
+				groups = append(groups, []string{name})
+			}
+		}
+		for _, g := range groups {
+			s.in = &input{stdin: c.stdin, stderr: c.stderr, names: g}
+			s.out = out
+			s.reset()
+			err := s.run()
+			s.in.close()
+			failed = failed || s.in.failed
+			if err != nil {
+				out.w.Flush()
+				return 0, err
+			}
+			if s.quit {
+				break
+			}
+		}
+		if err := out.w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.closeFiles(); err != nil {
+		return 0, err
+	}
+	if failed {
+		return 2, errFailed
+	}
+	return s.code, nil
+}
+
+func main() {
+	c, err := command(os.Stdin, os.Stdout, os.Stderr, os.Args)
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+	code, err := c.run()
+	if errors.Is(err, errFailed) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(4)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSed(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		input string
+		want  string
+		code  int
+	}{
+		{name: "print range", args: []string{"-n", "2,4p"}, input: "1\n2\n3\n4\n5\n", want: "2\n3\n4\n"},
+		{name: "regex range", args: []string{"/2/,/4/d"}, input: "1\n2\n3\n4\n5\n", want: "1\n5\n"},
+		{name: "zero range", args: []string{"0,/a/d"}, input: "a\nb\na\n", want: "b\na\n"},
+		{name: "relative range", args: []string{"-n", "/2/,+2p"}, input: "1\n2\n3\n4\n5\n6\n", want: "2\n3\n4\n"},
+		{name: "multiple range", args: []string{"-n", "2,~4p"}, input: "1\n2\n3\n4\n5\n", want: "2\n3\n4\n"},
+		{name: "step", args: []string{"-n", "0~3p"}, input: "1\n2\n3\n4\n5\n6\n7\n", want: "3\n6\n"},
+		{name: "last", args: []string{"$!d"}, input: "1\n2\n3\n", want: "3\n"},
+		{name: "line count", args: []string{"-n", "$="}, input: "a\nb\nc\n", want: "3\n"},
+		{name: "case insensitive", args: []string{"/a/Id"}, input: "A\nb\n", want: "b\n"},
+		{name: "custom delimiter", args: []string{`\,/,d`}, input: "/x\ny\n", want: "y\n"},
+		{name: "substitute nth", args: []string{"s/foo/X/2"}, input: "foo bar foo\n", want: "foo bar X\n"},
+		{name: "substitute global", args: []string{"s/a/[&]/g"}, input: "aba\n", want: "[a]b[a]\n"},
+		{name: "groups", args: []string{`s/\(k\)=\(v*\)/\2=\1/`}, input: "k=vv\n", want: "vv=k\n"},
+		{name: "extended", args: []string{"-E", `s/(fo+) (bar)?/\2 \1/`}, input: "foo bar\n", want: "bar foo\n"},
+		{name: "basic literals", args: []string{`s/x+a{/y/;s/b\+/B/`}, input: "x+a{bb\n", want: "yB\n"},
+		{name: "newline in replacement", args: []string{`s/,/\n/`}, input: "a,b\n", want: "a\nb\n"},
+		{name: "substitute print", args: []string{"-n", "s/a/b/p"}, input: "a\nc\n", want: "b\n"},
+		{name: "tac", args: []string{"-n", "1!G;h;$p"}, input: "a\nb\nc\n", want: "c\nb\na\n"},
+		{name: "exchange", args: []string{"1{h;d};2{x;G}"}, input: "a\nb\nc\n", want: "a\nb\nc\n"},
+		{name: "join", args: []string{":a;N;$!ba;s/\\n/,/g"}, input: "one\ntwo\nthree\n", want: "one,two,three\n"},
+		{name: "test", args: []string{"s/a/A/;ta;s/$/ no/;b;:a;s/$/ yes/"}, input: "a\nb\n", want: "A yes\nb no\n"},
+		{name: "test not", args: []string{"s/x/y/;T;s/a/Z/"}, input: "ab\n", want: "ab\n"},
+		{name: "delete first line", args: []string{"$!N;P;D"}, input: "a\nb\nc\n", want: "a\nb\nc\n"},
+		{name: "next", args: []string{"n;d"}, input: "1\n2\n3\n", want: "1\n3\n"},
+		{name: "insert append", args: []string{"-e", "1i\\", "-e", "top", "-e", "$a end"}, input: "a\nb\n", want: "top\na\nb\nend\n"},
+		{name: "change", args: []string{"2,3c\\\nnew"}, input: "a\nb\nc\nd\n", want: "a\nnew\nd\n"},
+		{name: "transliterate", args: []string{"y/abc/xyz/"}, input: "aabbcc\n", want: "xxyyzz\n"},
+		{name: "list", args: []string{"-n", "l"}, input: "a\tb\\\n", want: "a\\tb\\\\$\n"},
+		{name: "zap", args: []string{"2z"}, input: "a\nb\n", want: "a\n\n"},
+		{name: "quit", args: []string{"2q5"}, input: "1\n2\n3\n", want: "1\n2\n", code: 5},
+		{name: "quit silently", args: []string{"2Q"}, input: "1\n2\n3\n", want: "1\n"},
+		{name: "missing newline", args: []string{"p"}, input: "a\nb", want: "a\na\nb\nb"},
+		{name: "quiet comment", args: []string{"#n\n2p"}, input: "1\n2\n", want: "2\n"},
+		{name: "combined flags", args: []string{"-ne", "1p"}, input: "1\n2\n", want: "1\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c, err := command(strings.NewReader(tt.input), &out, &out, append([]string{"sed"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := c.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("got exit status %d, want %d", code, tt.code)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.WriteFile(a, []byte("one\ntwo\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("three\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := filepath.Join(dir, "w")
+	var out bytes.Buffer
+	c, err := command(strings.NewReader(""), &out, &out, []string{"sed", "-i.orig", "-e", "1s/^/1:/", "-e", "/t/w " + w, a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("got %q on stdout, want nothing", out.String())
+	}
+	for name, want := range map[string]string{
+		a:           "1:one\ntwo\n",
+		b:           "1:three\n",
+		a + ".orig": "one\ntwo\n",
+		b + ".orig": "three\n",
+		w:           "two\n1:three\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %q in %s, want %q", got, name, want)
+		}
+	}
+	fi, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o640 {
+		t.Errorf("got mode %v for %s, want %v", fi.Mode().Perm(), a, os.FileMode(0o640))
+	}
+
+	c, err = command(strings.NewReader(""), &out, &out, []string{"sed", "-ibak/*", "$d", a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.backup(a); got != "bak/a" {
+		t.Errorf("got backup %s, want bak/a", got)
+	}
+	if got := (&cmd{inPlace: inPlace{suffix: "old_*"}}).backup(a); got != filepath.Join(dir, "old_a") {
+		t.Errorf("got backup %s, want %s", got, filepath.Join(dir, "old_a"))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{name: "no script", args: nil, err: errUsage},
+		{name: "in place without files", args: []string{"-i", "p"}, err: errUsage},
+		{name: "unknown command", args: []string{"1k"}, err: errScript},
+		{name: "unterminated", args: []string{"s/a/b"}, err: errScript},
+		{name: "unmatched brace", args: []string{"1{p"}, err: errScript},
+		{name: "undefined label", args: []string{"b nowhere"}, err: errScript},
+		{name: "y lengths", args: []string{"y/ab/c/"}, err: errScript},
+		{name: "missing file", args: []string{"p", "/does/not/exist"}, err: errFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c, err := command(strings.NewReader(""), &out, &out, append([]string{"sed"}, tt.args...))
+			if err == nil {
+				_, err = c.run()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	facilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// message is a syslog message.
+type message struct {
+	Facility int
+	Severity int
+	Time     time.Time
+	Hostname string
+	Tag      string
+	PID      string
+	Msg      string
+}
+
+// priority returns facility.severity, e.g. daemon.info.
+func (m *message) priority() string {
+	f := strconv.Itoa(m.Facility)
+	if m.Facility < len(facilities) {
+		f = facilities[m.Facility]
+	}
+	return f + "." + severities[m.Severity]
+}
+
+// String returns the message as a line of a log file, without the newline.
+func (m *message) String() string {
+	tag := m.Tag
+	if m.PID != "" {
+		tag += "[" + m.PID + "]"
+	}
+	if tag != "" {
+		tag += ": "
+	}
+	return fmt.Sprintf("%s %s %s %s%s", m.Time.Format(time.RFC3339), m.Hostname, m.priority(), tag, m.Msg)
+}
+
+// parse parses an RFC 5424 or RFC 3164 (BSD) message. Missing parts are
+// filled in as syslog daemons do: the priority defaults to user.notice,
+// the time to now and the hostname to host.
+func parse(b []byte, now time.Time, host string) *message {
+	m := &message{Facility: 1, Severity: 5, Time: now, Hostname: host}
+	s := string(bytes.TrimRight(b, "\x00\r\n"))
+	if strings.HasPrefix(s, "<") {
+		if end := strings.IndexByte(s, '>'); end > 1 && end <= 4 {
+			if pri, err := strconv.Atoi(s[1:end]); err == nil && pri < 192 {
+				m.Facility, m.Severity = pri/8, pri%8
+				s = s[end+1:]
+			}
+		}
+	}
+	if strings.HasPrefix(s, "1 ") {
+		parse5424(m, s[2:])
+	} else {
+		parse3164(m, s, now)
+	}
+	m.Msg = strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\t' {
+			return ' '
+		}
+		return r
+	}, m.Msg)
+	return m
+}
+
+// next returns the first field of s and the rest.
+func next(s string) (string, string) {
+	f, rest, _ := strings.Cut(s, " ")
+	return f, rest
+}
+
+func parse5424(m *message, s string) {
+	var ts, host, app, pid string
+	ts, s = next(s)
+	host, s = next(s)
+	app, s = next(s)
+	pid, s = next(s)
+	_, s = next(s) // MSGID
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		m.Time = t
+	}
+	if host != "-" && host != "" {
+		m.Hostname = host
+	}
+	if app != "-" {
+		m.Tag = app
+	}
+	if pid != "-" {
+		m.PID = pid
+	}
+	// Keep structured data in the message, it is all the BSD format has.
+	if strings.HasPrefix(s, "- ") {
+		s = s[2:]
+	} else if s == "-" {
+		s = ""
+	}
+	m.Msg = strings.TrimPrefix(s, "\ufeff")
+}
+
+func parse3164(m *message, s string, now time.Time) {
+	// Jan _2 15:04:05 is 15 characters.
+	if len(s) >= 16 && s[15] == ' ' {
+		if t, err := time.ParseInLocation(time.Stamp, s[:15], now.Location()); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// A message from December received in January is from
+			// last year.
+			if t.After(now.AddDate(0, 1, 0)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Time = t
+			s = s[16:]
+			// A hostname follows the time, unless the tag does.
+			if f, rest := next(s); rest != "" && !strings.HasSuffix(f, ":") && !strings.Contains(f, "[") {
+				m.Hostname, s = f, rest
+			}
+		}
+	}
+	// The tag is alphanumeric, up to 32 characters, optionally with a
+	// [pid], and ends in a colon.
+	if i := strings.Index(s, ": "); i > 0 && i <= 48 && !strings.ContainsAny(s[:i], " ") {
+		tag := s[:i]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			m.PID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		m.Tag, s = tag, s[i+2:]
+	}
+	m.Msg = s
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// rotator is a log file which is rotated when it would grow past max
+// bytes: path is renamed to path.1, path.1 to path.2, and so on, and the
+// oldest of keep rotated files is removed.
+type rotator struct {
+	path string
+	max  int64
+	keep int
+
+	f    *os.File
+	size int64
+}
+
+func (r *rotator) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotator) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	if r.keep == 0 {
+		return os.Remove(r.path)
+	}
+	for i := r.keep - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(r.path, r.path+".1")
+}
+
+// WriteLine writes a line, after rotating the file if it would grow past
+// max.
+func (r *rotator) WriteLine(line string) error {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	n := int64(len(line) + 1)
+	if r.max > 0 && r.size > 0 && r.size+n > r.max {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	_, err := r.f.WriteString(line + "\n")
+	r.size += n
+	return err
+}
+
+// Close closes the file.
+func (r *rotator) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
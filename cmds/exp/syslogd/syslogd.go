@@ -0,0 +1,228 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+// syslogd receives syslog messages, writes them to a rotated log file, and
+// forwards them to other syslog servers.
+//
+// Synopsis:
+//
+//	syslogd [-unix PATH] [-udp ADDR] [-o FILE] [-size BYTES] [-rotate N] [-forward HOST[:PORT],...]
+//
+// Description:
+//
+//	syslogd reads RFC 5424 and BSD (RFC 3164) messages from the local
+//	socket /dev/log and, with -udp, from the network, e.g. from a BMC.
+//	Messages are written to FILE as lines of time, host, facility.severity,
+//	tag and message. When FILE would grow past -size bytes, it is renamed
+//	to FILE.1, FILE.1 to FILE.2 and so on, keeping -rotate old files.
+//
+//	Messages are forwarded over UDP in the BSD format, with the host and
+//	time filled in.
+//
+// Options:
+//
+//	-unix:    local socket, or "" for none (default /dev/log)
+//	-udp:     UDP address to listen on, e.g. :514 (default: none)
+//	-o:       log file, or - for stdout (default /var/log/messages)
+//	-size:    size at which the log file is rotated (default 1 MiB)
+//	-rotate:  number of rotated files to keep (default 3)
+//	-forward: comma separated servers to forward messages to, port 514 by default
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoInput = errors.New("no socket to listen on: give -unix or -udp")
+
+type server struct {
+	mu      sync.Mutex
+	write   func(line string) error
+	forward []net.Conn
+	now     func() time.Time
+	host    string
+}
+
+// forwardFormat returns m as a BSD message.
+func forwardFormat(m *message) string {
+	tag := m.Tag
+	if m.PID != "" {
+		tag += "[" + m.PID + "]"
+	}
+	if tag != "" {
+		tag += ": "
+	}
+	return fmt.Sprintf("<%d>%s %s %s%s", m.Facility*8+m.Severity, m.Time.Format(time.Stamp), m.Hostname, tag, m.Msg)
+}
+
+// handle logs and forwards a message from host.
+func (s *server) handle(b []byte, host string) {
+	m := parse(b, s.now(), host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(m.String()); err != nil {
+		log.Printf("Writing log: %v", err)
+	}
+	fwd := []byte(forwardFormat(m))
+	for _, c := range s.forward {
+		// A server which is down must not stop logging.
+		_, _ = c.Write(fwd)
+	}
+}
+
+// serve receives messages on c until it is closed.
+func (s *server) serve(c net.PacketConn) error {
+	b := make([]byte, 64*1024)
+	for {
+		n, addr, err := c.ReadFrom(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		host := s.host
+		if u, ok := addr.(*net.UDPAddr); ok {
+			host = u.IP.String()
+		}
+		s.handle(b[:n], host)
+	}
+}
+
+// listenUnix listens on a unix socket any user can log to.
+func listenUnix(path string) (net.PacketConn, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	c, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialForward connects to the servers to forward to.
+func dialForward(list string) ([]net.Conn, error) {
+	var conns []net.Conn
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), "514")
+		}
+		c, err := net.Dial("udp", addr)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	return conns, nil
+}
+
+// listen opens the sockets to receive messages on.
+func listen(unixPath, udpAddr string) ([]net.PacketConn, error) {
+	var conns []net.PacketConn
+	if unixPath != "" {
+		c, err := listenUnix(unixPath)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	if udpAddr != "" {
+		c, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	if len(conns) == 0 {
+		return nil, errNoInput
+	}
+	return conns, nil
+}
+
+// run serves conns until they are all closed.
+func (s *server) run(conns []net.PacketConn) error {
+	errc := make(chan error, len(conns))
+	for _, c := range conns {
+		go func(c net.PacketConn) { errc <- s.serve(c) }(c)
+	}
+	var err error
+	for range conns {
+		if e := <-errc; e != nil && err == nil {
+			err = e
+			for _, c := range conns {
+				c.Close()
+			}
+		}
+	}
+	return err
+}
+
+func main() {
+	unixPath := flag.String("unix", "/dev/log", "Local socket, or empty for none")
+	udpAddr := flag.String("udp", "", "UDP address to listen on, e.g. :514")
+	out := flag.String("o", "/var/log/messages", "Log file, or - for stdout")
+	size := flag.Int64("size", 1<<20, "Size at which the log file is rotated")
+	keep := flag.Int("rotate", 3, "Number of rotated log files to keep")
+	forward := flag.String("forward", "", "Comma separated servers to forward messages to")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s := &server{now: time.Now}
+	s.host, _ = os.Hostname()
+	if *out == "-" {
+		s.write = func(line string) error {
+			_, err := fmt.Println(line)
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+			log.Fatal(err)
+		}
+		r := &rotator{path: *out, max: *size, keep: *keep}
+		defer r.Close()
+		s.write = r.WriteLine
+	}
+	var err error
+	if s.forward, err = dialForward(*forward); err != nil {
+		log.Fatal(err)
+	}
+	conns, err := listen(*unixPath, *udpAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.run(conns); err != nil {
+		log.Fatal(err)
+	}
+}
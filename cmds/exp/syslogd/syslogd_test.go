@@ -0,0 +1,185 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "<30>1 2024-01-10T11:59:58.5Z node1 dhclient 42 - - lease 10.0.0.2\n",
+			want: "2024-01-10T11:59:58Z node1 daemon.info dhclient[42]: lease 10.0.0.2",
+		},
+		{
+			in:   `<13>1 2024-01-10T11:59:58Z - init 1 - [ulog@32473 tries="3"] no shell`,
+			want: `2024-01-10T11:59:58Z local user.notice init[1]: [ulog@32473 tries="3"] no shell`,
+		},
+		{
+			in:   "<34>Jan  9 22:14:15 bmc sshd[77]: accepted key",
+			want: "2024-01-09T22:14:15Z bmc auth.crit sshd[77]: accepted key",
+		},
+		{
+			in:   "<165>Dec 31 23:59:59 ipmi: sensor\tfan1 low",
+			want: "2023-12-31T23:59:59Z local local4.notice ipmi: sensor\tfan1 low",
+		},
+		{
+			in:   "<11>su: bad password",
+			want: "2024-01-10T12:00:00Z local user.err su: bad password",
+		},
+		{
+			in:   "just text\r\nmore",
+			want: "2024-01-10T12:00:00Z local user.notice just text  more",
+		},
+		{
+			in:   "<999>overflow",
+			want: "2024-01-10T12:00:00Z local user.notice <999>overflow",
+		},
+	} {
+		if got := parse([]byte(tt.in), now, "local").String(); got != tt.want {
+			t.Errorf("parse(%q) =\n%q, want\n%q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRotator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages")
+	r := &rotator{path: path, max: 10, keep: 2}
+	for _, l := range []string{"one", "two", "three", "four", "five", "six"} {
+		if err := r.WriteLine(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		path:        "six\n",
+		path + ".1": "four\nfive\n",
+		path + ".2": "three\n",
+	} {
+		b, err := os.ReadFile(file)
+		if err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", file, b, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want 2 rotated files", path)
+	}
+}
+
+// lines collects written lines.
+type lines struct {
+	mu sync.Mutex
+	l  []string
+}
+
+func (l *lines) write(line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.l = append(l.l, line)
+	return nil
+}
+
+func (l *lines) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		l.mu.Lock()
+		if len(l.l) >= n {
+			defer l.mu.Unlock()
+			return append([]string(nil), l.l...)
+		}
+		l.mu.Unlock()
+	}
+	t.Fatalf("did not get %d lines", n)
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	fwd, err := dialForward(upstream.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sock := filepath.Join(t.TempDir(), "dev", "log")
+	conns, err := listen(sock, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out lines
+	s := &server{write: out.write, forward: fwd, now: time.Now, host: "node1"}
+	done := make(chan error)
+	go func() { done <- s.run(conns) }()
+
+	// A ulog logger on the local socket, which sends its hostname.
+	hostname, _ := os.Hostname()
+	l := ulog.New("init", ulog.NewSyslog("unixgram", sock))
+	l.Warningf("no shell")
+	got := out.wait(t, 1)
+	if !strings.HasSuffix(got[0], " user.warning init["+strconv.Itoa(os.Getpid())+"]: no shell") || !strings.Contains(got[0], " "+hostname+" ") {
+		t.Errorf("local line = %q", got[0])
+	}
+
+	// Messages over UDP without a hostname are from the sender.
+	c, err := net.Dial("udp", conns[1].LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("<38>sshd: hello"))
+	got = out.wait(t, 2)
+	if !strings.HasSuffix(got[1], " 127.0.0.1 auth.info sshd: hello") {
+		t.Errorf("UDP line = %q", got[1])
+	}
+
+	// A BSD message over UDP.
+	c.Write([]byte("<38>Jan  9 22:14:15 bmc sshd: hello"))
+	got = out.wait(t, 3)
+	if !strings.HasSuffix(got[2], " bmc auth.info sshd: hello") {
+		t.Errorf("UDP line = %q", got[2])
+	}
+
+	b := make([]byte, 1024)
+	upstream.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := upstream.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := string(b[:n]); !strings.HasPrefix(m, "<12>") || !strings.HasSuffix(m, " "+hostname+" init["+strconv.Itoa(os.Getpid())+"]: no shell") {
+		t.Errorf("forwarded %q", m)
+	}
+
+	for _, c := range conns {
+		c.Close()
+	}
+	if err := <-done; err != nil {
+		t.Errorf("run = %v", err)
+	}
+
+	if _, err := listen("", ""); err != errNoInput {
+		t.Errorf("listen without sockets = %v, want %v", err, errNoInput)
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -316,6 +317,18 @@ func main() {
 		signal.Ignore()
 	}
 
+	// A boot policy on the kernel command line replaces the boot entries
+	// and the default boot sequence.
+	if policy, ok, err := systembooter.PolicyFromCmdline(); ok {
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", systembooter.PolicyFlag, err)
+		}
+		if _, err := policy.Run(context.Background(), ulog.Log, debugEnabled); err != nil {
+			log.Fatalf("Boot policy: %v", err)
+		}
+		return
+	}
+
 	// Get and show boot entries
 	var bootEntries []systembooter.BootEntry
 	if bmcBootOverride && ocp.BmcUpdatedBootorder {
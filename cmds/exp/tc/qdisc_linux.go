@@ -0,0 +1,498 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	errArg     = errors.New("bad argument")
+	errMissing = errors.New("missing argument")
+)
+
+// unit splits s into its number and its unit, in lower case.
+func unit(s string) (float64, string, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || v < 0 {
+		return 0, "", fmt.Errorf("%w: %q is not a number", errArg, s)
+	}
+	return v, strings.ToLower(s[i:]), nil
+}
+
+// scale parses s, a number with a unit among units, and returns it in the
+// unit of the empty string, up to limit.
+func scale(s string, units map[string]float64, limit float64, what string) (float64, error) {
+	v, u, err := unit(s)
+	if err != nil {
+		return 0, err
+	}
+	m, ok := units[u]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q: unknown %s unit %q", errArg, s, what, u)
+	}
+	if v *= m; v > limit {
+		return 0, fmt.Errorf("%w: %s %q is too large", errArg, what, s)
+	}
+	return v, nil
+}
+
+var timeUnits = map[string]float64{
+	"": 1, "us": 1, "usec": 1, "usecs": 1,
+	"ms": 1e3, "msec": 1e3, "msecs": 1e3,
+	"s": 1e6, "sec": 1e6, "secs": 1e6,
+}
+
+// parseTime returns s in microseconds.
+func parseTime(s string) (uint32, error) {
+	v, err := scale(s, timeUnits, math.MaxUint32, "time")
+	return uint32(math.Round(v)), err
+}
+
+var rateUnits = map[string]float64{
+	"": 1, "bit": 1, "kbit": 1e3, "mbit": 1e6, "gbit": 1e9, "tbit": 1e12,
+	"kibit": 1 << 10, "mibit": 1 << 20, "gibit": 1 << 30, "tibit": 1 << 40,
+	"bps": 8, "kbps": 8e3, "mbps": 8e6, "gbps": 8e9, "tbps": 8e12,
+}
+
+// parseRate returns s in bytes per second.
+func parseRate(s string) (uint64, error) {
+	v, err := scale(s, rateUnits, 8*(1<<53), "rate")
+	return uint64(v / 8), err
+}
+
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "g": 1 << 30, "gb": 1 << 30,
+	"kbit": 1 << 10 / 8, "mbit": 1 << 20 / 8, "gbit": 1 << 30 / 8,
+}
+
+// parseSize returns s in bytes.
+func parseSize(s string) (uint32, error) {
+	v, err := scale(s, sizeUnits, math.MaxUint32, "size")
+	return uint32(v), err
+}
+
+func parseCount(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a count", errArg, s)
+	}
+	return uint32(v), nil
+}
+
+func parsePercent(s string) (float32, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 32)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("%w: %q is not a percentage", errArg, s)
+	}
+	return float32(v), nil
+}
+
+// parseID parses a qdisc or class ID, MAJOR: or MAJOR:MINOR in hex.
+func parseID(s string) (uint32, error) {
+	maj, mnr, _ := strings.Cut(s, ":")
+	major, err := strconv.ParseUint(maj, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an ID", errArg, s)
+	}
+	var minor uint64
+	if mnr != "" {
+		if minor, err = strconv.ParseUint(mnr, 16, 16); err != nil {
+			return 0, fmt.Errorf("%w: %q is not an ID", errArg, s)
+		}
+	}
+	return netlink.MakeHandle(uint16(major), uint16(minor)), nil
+}
+
+// params walks the parameters of a qdisc.
+type params struct {
+	args []string
+	i    int
+}
+
+func (p *params) more() bool {
+	return p.i < len(p.args)
+}
+
+// next returns the next argument, or "" at the end.
+func (p *params) next() string {
+	if !p.more() {
+		return ""
+	}
+	p.i++
+	return p.args[p.i-1]
+}
+
+// value returns the value of parameter name.
+func (p *params) value(name string) (string, error) {
+	if !p.more() {
+		return "", fmt.Errorf("%w: %s needs a value", errMissing, name)
+	}
+	return p.next(), nil
+}
+
+// optional returns the next argument if it is not one of keywords.
+func (p *params) optional(keywords map[string]bool) (string, bool) {
+	if !p.more() || keywords[p.args[p.i]] {
+		return "", false
+	}
+	return p.next(), true
+}
+
+// parseQdisc parses the arguments of tc qdisc add, replace, change and del,
+// and returns the qdisc, whose link index is not set, and the device name.
+// For del, the qdisc kind may be left out, and the returned qdisc then has
+// no type.
+func parseQdisc(args []string, del bool) (netlink.Qdisc, string, error) {
+	attrs := netlink.QdiscAttrs{Parent: netlink.HANDLE_ROOT}
+	var dev string
+	p := &params{args: args}
+	var kind string
+	for kind == "" && p.more() {
+		var (
+			v   string
+			err error
+		)
+		switch arg := p.next(); arg {
+		case "dev":
+			dev, err = p.value(arg)
+		case "root":
+			attrs.Parent = netlink.HANDLE_ROOT
+		case "parent", "handle":
+			if v, err = p.value(arg); err != nil {
+				break
+			}
+			if arg == "parent" {
+				attrs.Parent, err = parseID(v)
+			} else {
+				attrs.Handle, err = parseID(v)
+			}
+		default:
+			kind = arg
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if dev == "" {
+		return nil, "", fmt.Errorf("%w: dev", errMissing)
+	}
+	if del {
+		if p.more() {
+			return nil, "", fmt.Errorf("%w: %q: del takes no qdisc parameters", errArg, p.next())
+		}
+		return &netlink.GenericQdisc{QdiscAttrs: attrs, QdiscType: kind}, dev, nil
+	}
+
+	var (
+		q   netlink.Qdisc
+		err error
+	)
+	switch kind {
+	case "":
+		return nil, "", fmt.Errorf("%w: qdisc", errMissing)
+	case "fq_codel":
+		q, err = parseFqCodel(attrs, p)
+	case "tbf":
+		q, err = parseTbf(attrs, p)
+	case "netem":
+		q, err = parseNetem(attrs, p)
+	default:
+		return nil, "", fmt.Errorf("%w: unsupported qdisc %q", errArg, kind)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", kind, err)
+	}
+	return q, dev, nil
+}
+
+func parseFqCodel(attrs netlink.QdiscAttrs, p *params) (netlink.Qdisc, error) {
+	q := netlink.NewFqCodel(attrs)
+	for p.more() {
+		arg := p.next()
+		var field *uint32
+		parse := parseCount
+		switch arg {
+		case "ecn":
+			q.ECN = 1
+			continue
+		case "noecn":
+			q.ECN = 0
+			continue
+		case "limit":
+			field = &q.Limit
+		case "flows":
+			field = &q.Flows
+		case "drop_batch":
+			field = &q.DropBatchSize
+		case "target":
+			field, parse = &q.Target, parseTime
+		case "interval":
+			field, parse = &q.Interval, parseTime
+		case "ce_threshold":
+			field, parse = &q.CEThreshold, parseTime
+		case "quantum":
+			field, parse = &q.Quantum, parseSize
+		case "memory_limit":
+			field, parse = &q.MemoryLimit, parseSize
+		default:
+			return nil, fmt.Errorf("%w: unknown parameter %q", errArg, arg)
+		}
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		if *field, err = parse(v); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func parseTbf(attrs netlink.QdiscAttrs, p *params) (netlink.Qdisc, error) {
+	var (
+		rate                   uint64
+		burst, latency, limit  uint32
+		haveLatency, haveLimit bool
+	)
+	for p.more() {
+		arg := p.next()
+		v, err := p.value(arg)
+		if err != nil {
+			return nil, err
+		}
+		switch arg {
+		case "rate":
+			rate, err = parseRate(v)
+		case "burst", "buffer", "maxburst":
+			burst, err = parseSize(v)
+		case "latency":
+			latency, err = parseTime(v)
+			haveLatency = true
+		case "limit":
+			limit, err = parseSize(v)
+			haveLimit = true
+		default:
+			err = fmt.Errorf("%w: unknown parameter %q", errArg, arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case rate == 0:
+		return nil, fmt.Errorf("%w: rate", errMissing)
+	case burst == 0:
+		return nil, fmt.Errorf("%w: burst", errMissing)
+	case haveLatency == haveLimit:
+		return nil, fmt.Errorf("%w: exactly one of latency and limit", errMissing)
+	case haveLatency:
+		// What can be sent in the latency, and a burst.
+		limit = uint32(min(float64(rate)*float64(latency)/1e6+float64(burst), math.MaxUint32))
+	}
+	return &netlink.Tbf{
+		QdiscAttrs: attrs,
+		Rate:       rate,
+		Limit:      limit,
+		Buffer:     netlink.Xmittime(rate, burst),
+	}, nil
+}
+
+var netemKeywords = map[string]bool{
+	"limit": true, "delay": true, "latency": true, "loss": true, "duplicate": true,
+	"corrupt": true, "reorder": true, "gap": true,
+}
+
+func parseNetem(attrs netlink.QdiscAttrs, p *params) (netlink.Qdisc, error) {
+	var n netlink.NetemQdiscAttrs
+	// percent parses a percentage and an optional correlation.
+	percent := func(name string, v, corr *float32) error {
+		s, err := p.value(name)
+		if err != nil {
+			return err
+		}
+		if *v, err = parsePercent(s); err != nil {
+			return err
+		}
+		if s, ok := p.optional(netemKeywords); ok {
+			*corr, err = parsePercent(s)
+		}
+		return err
+	}
+	for p.more() {
+		var err error
+		switch arg := p.next(); arg {
+		case "limit":
+			var v string
+			if v, err = p.value(arg); err == nil {
+				n.Limit, err = parseCount(v)
+			}
+		case "gap":
+			var v string
+			if v, err = p.value(arg); err == nil {
+				n.Gap, err = parseCount(v)
+			}
+		case "delay", "latency":
+			var v string
+			if v, err = p.value(arg); err != nil {
+				break
+			}
+			if n.Latency, err = parseTime(v); err != nil {
+				break
+			}
+			if v, ok := p.optional(netemKeywords); ok {
+				if n.Jitter, err = parseTime(v); err != nil {
+					break
+				}
+				if v, ok := p.optional(netemKeywords); ok {
+					n.DelayCorr, err = parsePercent(v)
+				}
+			}
+		case "loss":
+			if p.more() && p.args[p.i] == "random" {
+				p.next()
+			}
+			err = percent(arg, &n.Loss, &n.LossCorr)
+		case "duplicate":
+			err = percent(arg, &n.Duplicate, &n.DuplicateCorr)
+		case "corrupt":
+			err = percent(arg, &n.CorruptProb, &n.CorruptCorr)
+		case "reorder":
+			err = percent(arg, &n.ReorderProb, &n.ReorderCorr)
+		default:
+			err = fmt.Errorf("%w: unknown parameter %q", errArg, arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n.ReorderProb > 0 && n.Latency == 0 {
+		return nil, fmt.Errorf("%w: reorder needs a delay", errMissing)
+	}
+	return netlink.NewNetem(attrs, n), nil
+}
+
+// formatID formats a qdisc or class ID as tc does.
+func formatID(id uint32) string {
+	major, minor := netlink.MajorMinor(id)
+	if minor == 0 {
+		return fmt.Sprintf("%x:", major)
+	}
+	return fmt.Sprintf("%x:%x", major, minor)
+}
+
+// formatTime formats us microseconds.
+func formatTime(us float64) string {
+	us = math.Round(us)
+	switch {
+	case us >= 1e6:
+		return strconv.FormatFloat(us/1e6, 'f', -1, 64) + "s"
+	case us >= 1e3:
+		return strconv.FormatFloat(us/1e3, 'f', -1, 64) + "ms"
+	}
+	return strconv.FormatFloat(us, 'f', -1, 64) + "us"
+}
+
+// formatRate formats a rate of bytes per second in bits per second.
+func formatRate(rate uint64) string {
+	bits := float64(rate) * 8
+	for _, u := range []struct {
+		div  float64
+		name string
+	}{{1e12, "Tbit"}, {1e9, "Gbit"}, {1e6, "Mbit"}, {1e3, "Kbit"}} {
+		if bits >= u.div {
+			return strconv.FormatFloat(math.Round(bits/u.div*10)/10, 'f', -1, 64) + u.name
+		}
+	}
+	return strconv.FormatFloat(bits, 'f', -1, 64) + "bit"
+}
+
+// formatSize formats a size in bytes.
+func formatSize(size uint32) string {
+	switch {
+	case size >= 1<<20:
+		return strconv.FormatFloat(math.Round(float64(size)/(1<<20)*10)/10, 'f', -1, 64) + "Mb"
+	case size >= 1<<10:
+		return strconv.FormatFloat(math.Round(float64(size)/(1<<10)*10)/10, 'f', -1, 64) + "Kb"
+	}
+	return strconv.FormatUint(uint64(size), 10) + "b"
+}
+
+// formatPercent formats a probability scaled to a uint32.
+func formatPercent(v uint32) string {
+	return strconv.FormatFloat(math.Round(float64(v)/math.MaxUint32*1e4)/100, 'f', -1, 64) + "%"
+}
+
+// formatQdisc formats q like tc qdisc show, without the device.
+func formatQdisc(q netlink.Qdisc) string {
+	a := q.Attrs()
+	var b strings.Builder
+	fmt.Fprintf(&b, "qdisc %s %s", q.Type(), formatID(a.Handle))
+	if a.Parent == netlink.HANDLE_ROOT {
+		b.WriteString(" root")
+	} else {
+		fmt.Fprintf(&b, " parent %s", formatID(a.Parent))
+	}
+	if a.Refcnt > 0 {
+		fmt.Fprintf(&b, " refcnt %d", a.Refcnt)
+	}
+
+	switch q := q.(type) {
+	case *netlink.FqCodel:
+		fmt.Fprintf(&b, " limit %dp flows %d quantum %d target %s interval %s",
+			q.Limit, q.Flows, q.Quantum, formatTime(float64(q.Target)), formatTime(float64(q.Interval)))
+		if q.ECN != 0 {
+			b.WriteString(" ecn")
+		}
+	case *netlink.Tbf:
+		fmt.Fprintf(&b, " rate %s burst %s limit %s", formatRate(q.Rate), formatSize(netlink.Xmitsize(q.Rate, q.Buffer)), formatSize(q.Limit))
+	case *netlink.Netem:
+		fmt.Fprintf(&b, " limit %d", q.Limit)
+		if q.Latency > 0 {
+			// Delays are in ticks of the packet scheduler.
+			fmt.Fprintf(&b, " delay %s", formatTime(float64(q.Latency)/netlink.TickInUsec()))
+			if q.Jitter > 0 {
+				fmt.Fprintf(&b, " %s", formatTime(float64(q.Jitter)/netlink.TickInUsec()))
+				if q.DelayCorr > 0 {
+					fmt.Fprintf(&b, " %s", formatPercent(q.DelayCorr))
+				}
+			}
+		}
+		for _, pc := range []struct {
+			name    string
+			v, corr uint32
+		}{
+			{"loss", q.Loss, q.LossCorr},
+			{"duplicate", q.Duplicate, q.DuplicateCorr},
+			{"reorder", q.ReorderProb, q.ReorderCorr},
+			{"corrupt", q.CorruptProb, q.CorruptCorr},
+		} {
+			if pc.v == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, " %s %s", pc.name, formatPercent(pc.v))
+			if pc.corr > 0 {
+				fmt.Fprintf(&b, " %s", formatPercent(pc.corr))
+			}
+		}
+		if q.Gap > 0 {
+			fmt.Fprintf(&b, " gap %d", q.Gap)
+		}
+	}
+	return b.String()
+}
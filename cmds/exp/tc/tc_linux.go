@@ -0,0 +1,174 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tc shows and changes the queueing disciplines (qdiscs) of network
+// interfaces, to shape and delay traffic, e.g. to test how netboot copes
+// with a slow or lossy network.
+//
+// Synopsis:
+//
+//	tc qdisc show [dev DEV]
+//	tc qdisc {add | replace | change} dev DEV [root | parent ID] [handle ID] QDISC [PARAMS]
+//	tc qdisc del dev DEV [root | parent ID] [handle ID] [QDISC]
+//
+// Description:
+//
+//	The qdiscs and parameters are those of iproute2's tc:
+//
+//	fq_codel [limit PACKETS] [flows N] [target TIME] [interval TIME]
+//	    [quantum SIZE] [ce_threshold TIME] [memory_limit SIZE]
+//	    [drop_batch N] [ecn | noecn]
+//	tbf rate RATE burst SIZE {latency TIME | limit SIZE}
+//	netem [limit PACKETS] [delay TIME [JITTER [CORRELATION]]]
+//	    [loss [random] PERCENT [CORRELATION]]
+//	    [duplicate PERCENT [CORRELATION]] [corrupt PERCENT [CORRELATION]]
+//	    [reorder PERCENT [CORRELATION]] [gap N]
+//
+//	IDs are MAJOR: or MAJOR:MINOR in hex. TIMEs are in us by default, or
+//	have a unit among s, ms and us. RATEs are in bit/s by default, or have
+//	a unit among bit, kbit, mbit, gbit, bps (bytes/s), kbps, mbps and gbps.
+//	SIZEs are in bytes by default, or have a unit among b, k, kb, m, mb,
+//	kbit and mbit. PERCENTs may end in %.
+//
+//	For example, to delay packets on eth0 by 100ms, give or take 10ms, and
+//	lose 1% of them, at 10Mbit/s:
+//
+//	tc qdisc add dev eth0 root handle 1: tbf rate 10mbit burst 32k latency 50ms
+//	tc qdisc add dev eth0 parent 1:1 netem delay 100ms 10ms loss 1%
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/vishvananda/netlink"
+)
+
+var errUsage = errors.New("usage: tc qdisc {show | add | replace | change | del} [dev DEV] ...")
+
+// handle is what tc needs of netlink, replaced in tests.
+type handle interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkList() ([]netlink.Link, error)
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+	QdiscAdd(q netlink.Qdisc) error
+	QdiscReplace(q netlink.Qdisc) error
+	QdiscChange(q netlink.Qdisc) error
+	QdiscDel(q netlink.Qdisc) error
+}
+
+type cmd struct {
+	stdout io.Writer
+	h      handle
+}
+
+func (c *cmd) show(args []string) error {
+	var links []netlink.Link
+	switch {
+	case len(args) == 0:
+		var err error
+		if links, err = c.h.LinkList(); err != nil {
+			return err
+		}
+	case len(args) == 2 && args[0] == "dev":
+		l, err := c.h.LinkByName(args[1])
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[1], err)
+		}
+		links = []netlink.Link{l}
+	default:
+		return errUsage
+	}
+	for _, l := range links {
+		qs, err := c.h.QdiscList(l)
+		if err != nil {
+			return err
+		}
+		for _, q := range qs {
+			fmt.Fprintf(c.stdout, "%s dev %s\n", formatQdisc(q), l.Attrs().Name)
+		}
+	}
+	return nil
+}
+
+func (c *cmd) qdisc(args []string) error {
+	if len(args) == 0 {
+		return c.show(nil)
+	}
+	op, args := args[0], args[1:]
+	switch op {
+	case "show", "list", "ls":
+		return c.show(args)
+	case "add", "replace", "change", "del", "delete":
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, op)
+	}
+
+	q, dev, err := parseQdisc(args, op == "del" || op == "delete")
+	if err != nil {
+		return err
+	}
+	l, err := c.h.LinkByName(dev)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dev, err)
+	}
+	q.Attrs().LinkIndex = l.Attrs().Index
+	if q.Type() == "" {
+		// The kernel wants the kind of the qdisc it deletes.
+		if q, err = c.find(l, q.Attrs()); err != nil {
+			return err
+		}
+	}
+
+	switch op {
+	case "add":
+		err = c.h.QdiscAdd(q)
+	case "replace":
+		err = c.h.QdiscReplace(q)
+	case "change":
+		err = c.h.QdiscChange(q)
+	default:
+		err = c.h.QdiscDel(q)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s on %s: %w", op, q.Type(), dev, err)
+	}
+	return nil
+}
+
+// find returns the qdisc of l at the parent of a, with the handle of a if
+// it has one.
+func (c *cmd) find(l netlink.Link, a *netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	qs, err := c.h.QdiscList(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range qs {
+		if q.Attrs().Parent == a.Parent && (a.Handle == 0 || q.Attrs().Handle == a.Handle) {
+			return q, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no qdisc at %s: %w", l.Attrs().Name, formatID(a.Parent), os.ErrNotExist)
+}
+
+func (c *cmd) run(args []string) error {
+	if len(args) == 0 || args[0] != "qdisc" {
+		return errUsage
+	}
+	return c.qdisc(args[1:])
+}
+
+func main() {
+	h, err := netlink.NewHandle()
+	if err != nil {
+		log.Fatalf("tc: %v", err)
+	}
+	c := &cmd{stdout: os.Stdout, h: h}
+	if err := c.run(os.Args[1:]); err != nil {
+		log.Fatalf("tc: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestUnits(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		f    func(string) (uint64, error)
+		want uint64
+		err  error
+	}{
+		{"100ms", wrap(parseTime), 100000, nil},
+		{"1.5s", wrap(parseTime), 1500000, nil},
+		{"250", wrap(parseTime), 250, nil},
+		{"10mbit", parseRate, 1250000, nil},
+		{"8000", parseRate, 1000, nil},
+		{"1MBps", parseRate, 1000000, nil},
+		{"32k", wrap(parseSize), 32768, nil},
+		{"1mb", wrap(parseSize), 1 << 20, nil},
+		{"1500", wrap(parseSize), 1500, nil},
+		{"10 ms", wrap(parseTime), 0, errArg},
+		{"1h", wrap(parseTime), 0, errArg},
+		{"-1", wrap(parseSize), 0, errArg},
+		{"5000s", wrap(parseTime), 0, errArg},
+	} {
+		got, err := tt.f(tt.s)
+		if !errors.Is(err, tt.err) || (err == nil && got != tt.want) {
+			t.Errorf("parse(%q) = %d, %v, want %d, %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func wrap(f func(string) (uint32, error)) func(string) (uint64, error) {
+	return func(s string) (uint64, error) {
+		v, err := f(s)
+		return uint64(v), err
+	}
+}
+
+func TestParseQdisc(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		del  bool
+		want string
+		err  error
+	}{
+		{
+			args: strings.Fields("dev eth0 root fq_codel limit 1000 target 5ms noecn"),
+			want: "qdisc fq_codel 0: root limit 1000p flows 0 quantum 0 target 5ms interval 0us",
+		},
+		{
+			args: strings.Fields("dev eth0 handle 1: tbf rate 10mbit burst 32k latency 50ms"),
+			want: "qdisc tbf 1: root rate 10Mbit burst 32Kb limit 93Kb",
+		},
+		{
+			args: strings.Fields("dev eth0 parent 1:1 netem delay 100ms 10ms loss random 1% reorder 25% 50"),
+			want: "qdisc netem 0: parent 1:1 limit 1000 delay 100ms 10ms loss 1% reorder 25% 50% gap 1",
+		},
+		{
+			args: strings.Fields("dev eth0 parent 1:1 handle 10:"),
+			del:  true,
+			want: "qdisc  10: parent 1:1",
+		},
+		{args: strings.Fields("dev eth0 root tbf rate 1mbit burst 10k"), err: errMissing},
+		{args: strings.Fields("dev eth0 root netem reorder 25%"), err: errMissing},
+		{args: strings.Fields("root netem delay 1ms"), err: errMissing},
+		{args: strings.Fields("dev eth0 root"), err: errMissing},
+		{args: strings.Fields("dev eth0 root sfq"), err: errArg},
+		{args: strings.Fields("dev eth0 parent 1:x netem"), err: errArg},
+		{args: strings.Fields("dev eth0 root netem loss 101%"), err: errArg},
+		{args: strings.Fields("dev eth0 root fq_codel flows"), err: errMissing},
+		{args: strings.Fields("dev eth0 root netem delay 1ms"), del: true, err: errArg},
+	} {
+		q, dev, err := parseQdisc(tt.args, tt.del)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseQdisc(%q) = %v, want %v", tt.args, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := formatQdisc(q); got != tt.want || dev != "eth0" {
+			t.Errorf("parseQdisc(%q) = %q on %q, want %q on eth0", tt.args, got, dev, tt.want)
+		}
+	}
+}
+
+// fakeHandle has one link, eth0, with a root tbf and a netem under it.
+type fakeHandle struct {
+	qdiscs []netlink.Qdisc
+	calls  []string
+}
+
+func newFakeHandle() *fakeHandle {
+	return &fakeHandle{qdiscs: []netlink.Qdisc{
+		&netlink.Tbf{
+			QdiscAttrs: netlink.QdiscAttrs{LinkIndex: 2, Handle: netlink.MakeHandle(1, 0), Parent: netlink.HANDLE_ROOT, Refcnt: 2},
+			Rate:       125000,
+			Limit:      10000,
+			Buffer:     netlink.Xmittime(125000, 5000),
+		},
+		netlink.NewNetem(netlink.QdiscAttrs{LinkIndex: 2, Handle: netlink.MakeHandle(0x10, 0), Parent: netlink.MakeHandle(1, 1)},
+			netlink.NetemQdiscAttrs{Limit: 1000, Latency: 20000}),
+	}}
+}
+
+var eth0 = &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "eth0"}}
+
+func (f *fakeHandle) LinkByName(name string) (netlink.Link, error) {
+	if name != "eth0" {
+		return nil, os.ErrNotExist
+	}
+	return eth0, nil
+}
+
+func (f *fakeHandle) LinkList() ([]netlink.Link, error) {
+	return []netlink.Link{eth0}, nil
+}
+
+func (f *fakeHandle) QdiscList(netlink.Link) ([]netlink.Qdisc, error) {
+	return f.qdiscs, nil
+}
+
+func (f *fakeHandle) call(op string, q netlink.Qdisc) error {
+	f.calls = append(f.calls, op+" "+formatQdisc(q))
+	return nil
+}
+
+func (f *fakeHandle) QdiscAdd(q netlink.Qdisc) error     { return f.call("add", q) }
+func (f *fakeHandle) QdiscReplace(q netlink.Qdisc) error { return f.call("replace", q) }
+func (f *fakeHandle) QdiscChange(q netlink.Qdisc) error  { return f.call("change", q) }
+func (f *fakeHandle) QdiscDel(q netlink.Qdisc) error     { return f.call("del", q) }
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		args  string
+		want  string
+		calls []string
+		err   error
+	}{
+		{
+			args: "qdisc",
+			want: "qdisc tbf 1: root refcnt 2 rate 1Mbit burst 4.9Kb limit 9.8Kb dev eth0\n" +
+				"qdisc netem 10: parent 1:1 limit 1000 delay 20ms dev eth0\n",
+		},
+		{
+			args: "qdisc show dev eth0",
+			want: "qdisc tbf 1: root refcnt 2 rate 1Mbit burst 4.9Kb limit 9.8Kb dev eth0\n" +
+				"qdisc netem 10: parent 1:1 limit 1000 delay 20ms dev eth0\n",
+		},
+		{
+			args:  "qdisc replace dev eth0 parent 1:1 handle 10: netem delay 50ms",
+			calls: []string{"replace qdisc netem 10: parent 1:1 limit 1000 delay 50ms"},
+		},
+		{
+			args:  "qdisc del dev eth0 parent 1:1",
+			calls: []string{"del qdisc netem 10: parent 1:1 limit 1000 delay 20ms"},
+		},
+		{
+			args:  "qdisc del dev eth0 root tbf",
+			calls: []string{"del qdisc tbf 0: root"},
+		},
+		{args: "qdisc del dev eth0 parent 2:", err: os.ErrNotExist},
+		{args: "qdisc show dev eth1", err: os.ErrNotExist},
+		{args: "qdisc add dev eth1 root fq_codel", err: os.ErrNotExist},
+		{args: "qdisc flush", err: errUsage},
+		{args: "class show", err: errUsage},
+	} {
+		f := newFakeHandle()
+		var out strings.Builder
+		c := &cmd{stdout: &out, h: f}
+		if err := c.run(strings.Fields(tt.args)); !errors.Is(err, tt.err) {
+			t.Errorf("tc %s = %v, want %v", tt.args, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("tc %s printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+		if strings.Join(f.calls, "\n") != strings.Join(tt.calls, "\n") {
+			t.Errorf("tc %s made calls %q, want %q", tt.args, f.calls, tt.calls)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// top displays the processes using the most CPU, memory or IO.
+//
+// Synopsis:
+//
+//	top [-b] [-n count] [-d seconds] [-s key] [-H] [-c] [-m rows]
+//
+// Description:
+//
+//	top samples /proc every few seconds and shows the load, the memory
+//	use and the busiest processes, with their CPU and memory use, the
+//	rates at which they read and write storage and, optionally, their
+//	cgroups.
+//
+//	While top runs on a terminal, these keys change the display:
+//
+//	P, M, I, N, T: sort by CPU, memory, IO, PID or CPU time
+//	R: reverse the sort order
+//	H: toggle threads
+//	c: toggle cgroups
+//	q: quit
+//
+// Options:
+//
+//	-b: batch mode: no screen control or keys, and every frame starts
+//	    with a timestamp, for logging
+//	-n: exit after count frames; 0 runs forever
+//	-d: seconds between frames
+//	-s: sort key: cpu, mem, io, pid or time
+//	-H: show threads instead of processes
+//	-c: show the cgroup of each process
+//	-m: number of processes to show; 0 fits the terminal, or shows all
+//	    in batch mode
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	batch   = flag.Bool("b", false, "batch mode, for logging")
+	count   = flag.Int("n", 0, "exit after count frames; 0 runs forever")
+	delay   = flag.Float64("d", 2, "seconds between frames")
+	sortKey = flag.String("s", "cpu", "sort key: cpu, mem, io, pid or time")
+	threads = flag.Bool("H", false, "show threads instead of processes")
+	cgroups = flag.Bool("c", false, "show the cgroup of each process")
+	maxRows = flag.Int("m", 0, "number of processes to show; 0 fits the terminal, or shows all in batch mode")
+)
+
+// sortKeys maps the -s keys to the keys that select them.
+var sortKeys = map[string]byte{"cpu": 'P', "mem": 'M', "io": 'I', "pid": 'N', "time": 'T'}
+
+type key struct{ pid, tid int }
+
+// sample is a snapshot of /proc.
+type sample struct {
+	at      time.Time
+	threads bool
+	procs   map[key]*proc.Proc
+	order   []key
+}
+
+// row is a process, with its rates since the previous sample.
+type row struct {
+	*proc.Proc
+	cpu float64 // percent of one CPU
+	mem float64 // percent of MemTotal
+	// rd and wr are bytes per second read from and written to storage.
+	rd, wr float64
+}
+
+type top struct {
+	fs      proc.FS
+	sortKey string
+	reverse bool
+	threads bool
+	cgroups bool
+	batch   bool
+	// rows is the number of processes shown, or 0 for all.
+	rows int
+}
+
+func (t *top) sample() (*sample, error) {
+	var procs []*proc.Proc
+	var err error
+	if t.threads {
+		procs, err = t.fs.AllThreads()
+	} else {
+		procs, err = t.fs.Procs()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &sample{at: time.Now(), threads: t.threads, procs: map[key]*proc.Proc{}}
+	for _, p := range procs {
+		k := key{p.PID, p.TID}
+		s.procs[k] = p
+		s.order = append(s.order, k)
+	}
+	return s, nil
+}
+
+// rows computes the rates of the processes in cur. Processes which were
+// not in prev are measured since they started.
+func rows(prev, cur *sample, memTotal uint64) []row {
+	secs := cur.at.Sub(prev.at).Seconds()
+	var r []row
+	for _, k := range cur.order {
+		p := cur.procs[k]
+		var old proc.Proc
+		if o, ok := prev.procs[k]; ok && o.StartTime == p.StartTime {
+			old = *o
+		}
+		rw := row{Proc: p}
+		if secs > 0 {
+			ticks := float64(p.UTime + p.STime - old.UTime - old.STime)
+			rw.cpu = 100 * ticks / proc.UserHZ / secs
+			rw.rd = float64(p.IO.ReadBytes-old.IO.ReadBytes) / secs
+			rw.wr = float64(p.IO.WriteBytes-old.IO.WriteBytes) / secs
+		}
+		if memTotal > 0 {
+			rw.mem = 100 * float64(p.RSS) / float64(memTotal)
+		}
+		r = append(r, rw)
+	}
+	return r
+}
+
+// sortRows sorts rows by key, busiest first, or by ascending PID.
+func sortRows(r []row, key string, reverse bool) {
+	less := map[string]func(a, b row) bool{
+		"cpu":  func(a, b row) bool { return a.cpu > b.cpu },
+		"mem":  func(a, b row) bool { return a.RSS > b.RSS },
+		"io":   func(a, b row) bool { return a.rd+a.wr > b.rd+b.wr },
+		"pid":  func(a, b row) bool { return a.PID < b.PID || (a.PID == b.PID && a.TID < b.TID) },
+		"time": func(a, b row) bool { return a.UTime+a.STime > b.UTime+b.STime },
+	}[key]
+	sort.SliceStable(r, func(i, j int) bool {
+		if reverse {
+			return less(r[j], r[i])
+		}
+		return less(r[i], r[j])
+	})
+}
+
+// size formats bytes in KiB, MiB or GiB, as top does.
+func size(b float64) string {
+	switch {
+	case b >= 10<<30:
+		return fmt.Sprintf("%.1fg", b/(1<<30))
+	case b >= 10<<20:
+		return fmt.Sprintf("%.1fm", b/(1<<20))
+	}
+	return fmt.Sprintf("%.0f", b/(1<<10))
+}
+
+// cpuTime formats clock ticks as minutes:seconds.hundredths.
+func cpuTime(ticks uint64) string {
+	return fmt.Sprintf("%d:%02d.%02d", ticks/proc.UserHZ/60, ticks/proc.UserHZ%60, ticks%proc.UserHZ)
+}
+
+// frame writes the summary and the processes of one frame.
+func (t *top) frame(w io.Writer, prev, cur *sample) error {
+	mem, err := t.fs.MemInfo()
+	if err != nil {
+		return err
+	}
+	load, err := t.fs.LoadAvg()
+	if err != nil {
+		return err
+	}
+	up, err := t.fs.Uptime()
+	if err != nil {
+		return err
+	}
+	r := rows(prev, cur, mem["MemTotal"])
+	sortRows(r, t.sortKey, t.reverse)
+
+	states := map[string]int{}
+	for _, rw := range r {
+		states[rw.State]++
+	}
+	if t.batch {
+		fmt.Fprintf(w, "%s\n", cur.at.Format(time.RFC3339))
+	}
+	upt := time.Duration(up) * time.Second
+	fmt.Fprintf(w, "up %s, load average: %.2f, %.2f, %.2f\n", upt, load[0], load[1], load[2])
+	what := "Tasks"
+	if t.threads {
+		what = "Threads"
+	}
+	fmt.Fprintf(w, "%s: %d total, %d running, %d sleeping, %d stopped, %d zombie\n", what, len(r), states["R"], states["S"]+states["D"]+states["I"], states["T"]+states["t"], states["Z"])
+	fmt.Fprintf(w, "MiB Mem: %.1f total, %.1f free, %.1f available; Swap: %.1f total, %.1f free\n",
+		float64(mem["MemTotal"])/(1<<20), float64(mem["MemFree"])/(1<<20), float64(mem["MemAvailable"])/(1<<20),
+		float64(mem["SwapTotal"])/(1<<20), float64(mem["SwapFree"])/(1<<20))
+	fmt.Fprintln(w)
+
+	id := "PID"
+	if t.threads {
+		id = "TID"
+	}
+	fmt.Fprintf(w, "%7s %-5s S  %%CPU %%MEM %7s %7s %7s %7s %10s ", id, "UID", "RES", "SWAP", "READ/s", "WRITE/s", "TIME+")
+	if t.cgroups {
+		fmt.Fprintf(w, "%-30s ", "CGROUP")
+	}
+	fmt.Fprintf(w, "COMMAND\n")
+	if t.rows > 0 && len(r) > t.rows {
+		r = r[:t.rows]
+	}
+	for _, rw := range r {
+		n := rw.PID
+		if t.threads {
+			n = rw.TID
+		}
+		name := rw.Name()
+		if t.threads {
+			name = rw.Comm
+		}
+		fmt.Fprintf(w, "%7d %-5d %s %5.1f %4.1f %7s %7s %7s %7s %10s ", n, rw.UID, rw.State, rw.cpu, rw.mem,
+			size(float64(rw.RSS)), size(float64(rw.Swap)), size(rw.rd), size(rw.wr), cpuTime(rw.UTime+rw.STime))
+		if t.cgroups {
+			fmt.Fprintf(w, "%-30s ", rw.Cgroup)
+		}
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
+
+// key applies a key pressed on the terminal, and returns whether to quit.
+func (t *top) key(c byte) bool {
+	for k, b := range sortKeys {
+		if c == b {
+			t.sortKey = k
+		}
+	}
+	switch c {
+	case 'R':
+		t.reverse = !t.reverse
+	case 'H':
+		t.threads = !t.threads
+	case 'c':
+		t.cgroups = !t.cgroups
+	case 'q':
+		return true
+	}
+	return false
+}
+
+// run shows count frames, or runs until q is read from keys if count is 0.
+// Without keys, top runs in batch mode.
+func (t *top) run(w io.Writer, keys <-chan byte, count int, delay time.Duration) error {
+	prev, err := t.sample()
+	if err != nil {
+		return err
+	}
+	for i := 0; count <= 0 || i < count; i++ {
+		select {
+		case c, ok := <-keys:
+			if ok && t.key(c) {
+				return nil
+			}
+			// Redraw right away. After H, there is nothing to
+			// compare to, so wait a little to measure.
+			if t.threads != prev.threads {
+				if prev, err = t.sample(); err != nil {
+					return err
+				}
+				time.Sleep(delay / 10)
+			}
+		case <-time.After(delay):
+		}
+		cur, err := t.sample()
+		if err != nil {
+			return err
+		}
+		if !t.batch {
+			// Home the cursor and clear the screen.
+			fmt.Fprint(w, "\033[H\033[J")
+		} else if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := t.frame(w, prev, cur); err != nil {
+			return err
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// readKeys puts the terminal in non-canonical mode, without echo, and
+// sends the keys pressed on the returned channel. The returned function
+// restores the terminal.
+func readKeys(f *os.File) (<-chan byte, func(), error) {
+	old, err := termios.GetTermios(f.Fd())
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	if err := termios.SetTermios(f.Fd(), &raw); err != nil {
+		return nil, nil, err
+	}
+	keys := make(chan byte)
+	go func() {
+		var b [1]byte
+		for {
+			if n, err := f.Read(b[:]); err != nil {
+				close(keys)
+				return
+			} else if n == 1 {
+				keys <- b[0]
+			}
+		}
+	}()
+	return keys, func() { termios.SetTermios(f.Fd(), old) }, nil
+}
+
+func main() {
+	flag.Parse()
+	if _, ok := sortKeys[*sortKey]; !ok {
+		log.Fatalf("unknown sort key %q", *sortKey)
+	}
+	t := &top{
+		fs:      proc.DefaultRoot,
+		sortKey: *sortKey,
+		threads: *threads,
+		cgroups: *cgroups,
+		batch:   *batch,
+		rows:    *maxRows,
+	}
+	var keys <-chan byte
+	restore := func() {}
+	if !t.batch {
+		var err error
+		if keys, restore, err = readKeys(os.Stdin); err != nil {
+			log.Fatalf("%v; use -b without a terminal", err)
+		}
+		if ws, err := termios.GetWinSize(os.Stdout.Fd()); err == nil && t.rows == 0 {
+			// Leave room for the summary and the header.
+			t.rows = int(ws.Row) - 7
+		}
+	}
+	err := t.run(os.Stdout, keys, *count, time.Duration(*delay*float64(time.Second)))
+	restore()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+)
+
+func p(pid int, ticks, rss, read uint64) *proc.Proc {
+	return &proc.Proc{
+		Stat:   proc.Stat{PID: pid, Comm: fmt.Sprint("p", pid), State: "S", UTime: ticks},
+		Status: proc.Status{RSS: rss},
+		IO:     proc.IO{ReadBytes: read},
+	}
+}
+
+func snapshot(at time.Time, procs ...*proc.Proc) *sample {
+	s := &sample{at: at, procs: map[key]*proc.Proc{}}
+	for _, p := range procs {
+		s.procs[key{p.PID, p.TID}] = p
+		s.order = append(s.order, key{p.PID, p.TID})
+	}
+	return s
+}
+
+func TestRows(t *testing.T) {
+	t0 := time.Now()
+	prev := snapshot(t0, p(1, 100, 1<<20, 0), p(2, 0, 4<<20, 0))
+	cur := snapshot(t0.Add(2*time.Second), p(1, 150, 1<<20, 0), p(2, 100, 4<<20, 8<<20), p(3, 10, 2<<20, 0))
+	r := rows(prev, cur, 8<<20)
+
+	// PID 1 used 50 ticks in 2s, PID 2 100 ticks and read 8 MiB, and
+	// PID 3 is new.
+	want := map[int]row{
+		1: {cpu: 25, mem: 12.5},
+		2: {cpu: 50, mem: 50, rd: 4 << 20},
+		3: {cpu: 5, mem: 25},
+	}
+	for _, rw := range r {
+		w := want[rw.PID]
+		if rw.cpu != w.cpu || rw.mem != w.mem || rw.rd != w.rd {
+			t.Errorf("PID %d: cpu %v mem %v rd %v, want cpu %v mem %v rd %v", rw.PID, rw.cpu, rw.mem, rw.rd, w.cpu, w.mem, w.rd)
+		}
+	}
+
+	for _, tt := range []struct {
+		key     string
+		reverse bool
+		want    []int
+	}{
+		{key: "cpu", want: []int{2, 1, 3}},
+		{key: "mem", want: []int{2, 3, 1}},
+		// Ties keep the order of the previous sort.
+		{key: "io", want: []int{2, 3, 1}},
+		{key: "pid", want: []int{1, 2, 3}},
+		{key: "pid", reverse: true, want: []int{3, 2, 1}},
+		{key: "time", want: []int{1, 2, 3}},
+	} {
+		sortRows(r, tt.key, tt.reverse)
+		var got []int
+		for _, rw := range r {
+			got = append(got, rw.PID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("sortRows(%s, reverse %v) = %v, want %v", tt.key, tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tp := &top{sortKey: "cpu"}
+	for _, c := range []byte("MRHc") {
+		if tp.key(c) {
+			t.Fatalf("key(%q) quits", c)
+		}
+	}
+	if tp.sortKey != "mem" || !tp.reverse || !tp.threads || !tp.cgroups {
+		t.Errorf("after MRHc: %+v", tp)
+	}
+	if !tp.key('q') {
+		t.Errorf("key('q') does not quit")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	root := t.TempDir()
+	stat := "42 (sleepy) S 1 42 42 0 -1 0 0 0 0 0 150 50 0 0 20 0 1 0 100 1048576 64 0 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n"
+	files := map[string]string{
+		"42/stat":   stat,
+		"42/status": "Uid:\t1000\t1000\t1000\t1000\nVmRSS:\t 256 kB\n",
+		"42/cgroup": "0::/test.slice\n",
+		"meminfo":   "MemTotal: 1024 kB\nMemFree: 512 kB\nMemAvailable: 768 kB\n",
+		"loadavg":   "1.00 0.50 0.25 1/10 42\n",
+		"uptime":    "3600.00 0.00\n",
+	}
+	for name, data := range files {
+		f := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tp := &top{fs: proc.FS(root), sortKey: "cpu", cgroups: true, batch: true}
+	var out strings.Builder
+	if err := tp.run(&out, nil, 2, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		"up 1h0m0s, load average: 1.00, 0.50, 0.25\n",
+		"Tasks: 1 total, 0 running, 1 sleeping, 0 stopped, 0 zombie\n",
+		"MiB Mem: 1.0 total, 0.5 free, 0.8 available",
+		"/test.slice",
+		"[sleepy]\n",
+		"    0:02.00 ",
+	} {
+		if strings.Count(s, want) != 2 {
+			t.Errorf("output does not contain %q in both frames:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "\033") {
+		t.Errorf("batch output contains escape sequences:\n%q", s)
+	}
+}
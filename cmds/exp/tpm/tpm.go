@@ -0,0 +1,276 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+// tpm reads PCRs, accesses NVRAM, seals data and quotes PCRs with the TPM.
+//
+// Synopsis:
+//
+//	tpm [-owner PASSWORD] [-auth PASSWORD] COMMAND [ARGS...]
+//
+// Description:
+//
+//	tpm exposes the TPM operations of boot flows which attest the
+//	platform or release disk keys. All but info and pcr need a TPM 2.0.
+//	Commands are:
+//
+//	info                       print the TPM version and vendor
+//	pcr [PCR...]               print SHA-256 PCR values, all by default
+//	nv define INDEX SIZE       define an NVRAM index readable and writable
+//	                           with the owner or -auth password
+//	nv read INDEX [SIZE]       write the contents of an index to stdout
+//	nv write INDEX             write stdin to an index
+//	nv undefine INDEX          delete an index
+//	seal PCR[,PCR...]          seal stdin to the current PCR values, and
+//	                           write the sealed blob as JSON to stdout
+//	unseal                     unseal the blob on stdin to stdout
+//	ak                         write the attestation key's TPMT_PUBLIC to stdout
+//	quote NONCE PCR[,PCR...]   quote PCRs with the attestation key and the
+//	                           hex NONCE, and write the quote as JSON to stdout
+//
+// Options:
+//
+//	-owner: owner hierarchy password
+//	-auth:  NVRAM index password
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+var errUsage = errors.New("usage: tpm [-owner PASSWORD] [-auth PASSWORD] info | pcr [PCR...] | nv define|read|write|undefine INDEX [SIZE] | seal PCRS | unseal | ak | quote NONCE PCRS")
+
+// tpm is the part of tss.TPM used by the command.
+type tpm interface {
+	Info() (*tss.TPMInfo, error)
+	ReadPCR(pcrIndex uint32) ([]byte, error)
+	NVDefine(index uint32, size uint16, ownerPassword, authPassword string) error
+	NVReadValue(index uint32, ownerPassword string, size, offhandle uint32) ([]byte, error)
+	NVWriteValue(index uint32, password string, data []byte) error
+	NVUndefine(index uint32, ownerPassword string) error
+	Seal(ownerPassword string, data []byte, pcrs []int) (*tss.SealedBlob, error)
+	Unseal(ownerPassword string, b *tss.SealedBlob) ([]byte, error)
+	AKPublic(ownerPassword string) ([]byte, error)
+	Quote(ownerPassword string, nonce []byte, pcrs []int) (*tss.Quote, error)
+}
+
+type cmd struct {
+	r     io.Reader
+	w     io.Writer
+	tpm   tpm
+	owner string
+	auth  string
+	args  []string
+}
+
+var versions = map[tss.TPMVersion]string{
+	tss.TPMVersion12: "1.2",
+	tss.TPMVersion20: "2.0",
+}
+
+func parsePCRs(s string) ([]int, error) {
+	var pcrs []int
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseUint(f, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("PCR %q: %w", f, errUsage)
+		}
+		pcrs = append(pcrs, int(p))
+	}
+	return pcrs, nil
+}
+
+func parseIndex(s string) (uint32, error) {
+	i, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("NV index %q: %w", s, errUsage)
+	}
+	return uint32(i), nil
+}
+
+func (c *cmd) pcr(args []string) error {
+	if len(args) == 0 {
+		for i := 0; i < 24; i++ {
+			args = append(args, strconv.Itoa(i))
+		}
+	}
+	for _, a := range args {
+		i, err := strconv.ParseUint(a, 10, 8)
+		if err != nil {
+			return errUsage
+		}
+		v, err := c.tpm.ReadPCR(uint32(i))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.w, "%2d: %x\n", i, v)
+	}
+	return nil
+}
+
+func (c *cmd) nv(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	index, err := parseIndex(args[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "define" && len(args) == 3:
+		size, err := strconv.ParseUint(args[2], 0, 16)
+		if err != nil {
+			return errUsage
+		}
+		return c.tpm.NVDefine(index, uint16(size), c.owner, c.auth)
+	case args[0] == "read" && len(args) <= 3:
+		// A size of 0 reads in blocks of the TPM's maximum.
+		var size uint64
+		if len(args) == 3 {
+			if size, err = strconv.ParseUint(args[2], 0, 32); err != nil {
+				return errUsage
+			}
+		}
+		b, err := c.tpm.NVReadValue(index, c.auth, uint32(size), index)
+		if err != nil {
+			return err
+		}
+		_, err = c.w.Write(b)
+		return err
+	case args[0] == "write" && len(args) == 2:
+		b, err := io.ReadAll(c.r)
+		if err != nil {
+			return err
+		}
+		return c.tpm.NVWriteValue(index, c.auth, b)
+	case args[0] == "undefine" && len(args) == 2:
+		return c.tpm.NVUndefine(index, c.owner)
+	}
+	return errUsage
+}
+
+func (c *cmd) seal(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	pcrs, err := parsePCRs(args[0])
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(c.r)
+	if err != nil {
+		return err
+	}
+	b, err := c.tpm.Seal(c.owner, data, pcrs)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.w).Encode(b)
+}
+
+func (c *cmd) unseal() error {
+	var b tss.SealedBlob
+	if err := json.NewDecoder(c.r).Decode(&b); err != nil {
+		return fmt.Errorf("reading sealed blob: %w", err)
+	}
+	data, err := c.tpm.Unseal(c.owner, &b)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+func (c *cmd) quote(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	nonce, err := hex.DecodeString(args[0])
+	if err != nil {
+		return fmt.Errorf("nonce %q: %w", args[0], errUsage)
+	}
+	pcrs, err := parsePCRs(args[1])
+	if err != nil {
+		return err
+	}
+	q, err := c.tpm.Quote(c.owner, nonce, pcrs)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(c.w).Encode(q)
+}
+
+func (c *cmd) run() error {
+	if len(c.args) == 0 {
+		return errUsage
+	}
+	args := c.args[1:]
+	switch c.args[0] {
+	case "info":
+		i, err := c.tpm.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.w, "TPM %s, %s", versions[i.Version], i.Manufacturer)
+		if i.VendorInfo != "" {
+			fmt.Fprintf(c.w, " %s", i.VendorInfo)
+		}
+		if i.Version == tss.TPMVersion20 {
+			fmt.Fprintf(c.w, ", firmware %d.%d", i.FirmwareVersionMajor, i.FirmwareVersionMinor)
+		}
+		fmt.Fprintln(c.w)
+		return nil
+	case "pcr":
+		return c.pcr(args)
+	case "nv":
+		return c.nv(args)
+	case "seal":
+		return c.seal(args)
+	case "unseal":
+		if len(args) != 0 {
+			return errUsage
+		}
+		return c.unseal()
+	case "ak":
+		if len(args) != 0 {
+			return errUsage
+		}
+		pub, err := c.tpm.AKPublic(c.owner)
+		if err != nil {
+			return err
+		}
+		_, err = c.w.Write(pub)
+		return err
+	case "quote":
+		return c.quote(args)
+	}
+	return errUsage
+}
+
+func main() {
+	owner := flag.String("owner", "", "Owner hierarchy password")
+	auth := flag.String("auth", "", "NVRAM index password")
+	flag.Parse()
+	t, err := tss.NewTPM()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.Close()
+	c := &cmd{r: os.Stdin, w: os.Stdout, tpm: t, owner: *owner, auth: *auth, args: flag.Args()}
+	if err := c.run(); err != nil {
+		log.Fatal(err)
+	}
+}
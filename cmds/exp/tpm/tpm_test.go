@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// fakeTPM keeps NV indices in memory, and seals by keeping the data in the
+// blob's private part.
+type fakeTPM struct {
+	nv    map[uint32][]byte
+	calls []string
+}
+
+var errPCRRange = errors.New("PCR out of range")
+
+func (f *fakeTPM) Info() (*tss.TPMInfo, error) {
+	return &tss.TPMInfo{Version: tss.TPMVersion20, Manufacturer: 1229346816, VendorInfo: "SLB9670", FirmwareVersionMajor: 7, FirmwareVersionMinor: 85}, nil
+}
+
+func (f *fakeTPM) ReadPCR(i uint32) ([]byte, error) {
+	if i >= 24 {
+		return nil, errPCRRange
+	}
+	return bytes.Repeat([]byte{byte(i)}, 4), nil
+}
+
+func (f *fakeTPM) NVDefine(index uint32, size uint16, owner, auth string) error {
+	f.calls = append(f.calls, fmt.Sprintf("define %#x %d %q %q", index, size, owner, auth))
+	f.nv[index] = make([]byte, size)
+	return nil
+}
+
+func (f *fakeTPM) NVReadValue(index uint32, pw string, size, handle uint32) ([]byte, error) {
+	f.calls = append(f.calls, fmt.Sprintf("read %#x %q %d %#x", index, pw, size, handle))
+	return f.nv[index], nil
+}
+
+func (f *fakeTPM) NVWriteValue(index uint32, pw string, data []byte) error {
+	f.calls = append(f.calls, fmt.Sprintf("write %#x %q %q", index, pw, data))
+	copy(f.nv[index], data)
+	return nil
+}
+
+func (f *fakeTPM) NVUndefine(index uint32, owner string) error {
+	f.calls = append(f.calls, fmt.Sprintf("undefine %#x %q", index, owner))
+	delete(f.nv, index)
+	return nil
+}
+
+func (f *fakeTPM) Seal(owner string, data []byte, pcrs []int) (*tss.SealedBlob, error) {
+	return &tss.SealedBlob{PCRs: pcrs, Public: []byte("pub"), Private: data}, nil
+}
+
+func (f *fakeTPM) Unseal(owner string, b *tss.SealedBlob) ([]byte, error) {
+	if !slices.Equal(b.PCRs, []int{0, 7}) {
+		return nil, errors.New("policy check failed")
+	}
+	return b.Private, nil
+}
+
+func (f *fakeTPM) AKPublic(owner string) ([]byte, error) {
+	return []byte("AK"), nil
+}
+
+func (f *fakeTPM) Quote(owner string, nonce []byte, pcrs []int) (*tss.Quote, error) {
+	return &tss.Quote{PCRs: pcrs, Values: [][]byte{{1}}, Attest: nonce, Signature: []byte{2}}, nil
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		in    string
+		want  string
+		calls []string
+		err   error
+	}{
+		{args: []string{"info"}, want: "TPM 2.0, Infineon SLB9670, firmware 7.85\n"},
+		{args: []string{"pcr", "0", "7"}, want: " 0: 00000000\n 7: 07070707\n"},
+		{args: []string{"pcr", "24"}, err: errPCRRange},
+		{args: []string{"nv", "define", "0x1500016", "16"}, calls: []string{`define 0x1500016 16 "owner" "auth"`}},
+		{args: []string{"nv", "write", "0x1500016"}, in: "data", calls: []string{`write 0x1500016 "auth" "data"`}},
+		{args: []string{"nv", "read", "0x1500016"}, want: "data", calls: []string{`read 0x1500016 "auth" 0 0x1500016`}},
+		{args: []string{"nv", "read", "0x1500016", "4"}, want: "data", calls: []string{`read 0x1500016 "auth" 4 0x1500016`}},
+		{args: []string{"nv", "undefine", "0x1500016"}, calls: []string{`undefine 0x1500016 "owner"`}},
+		{args: []string{"seal", "0,7"}, in: "key", want: `{"pcrs":[0,7],"public":"cHVi","private":"a2V5"}` + "\n"},
+		{args: []string{"unseal"}, in: `{"pcrs":[0,7],"public":"cHVi","private":"a2V5"}`, want: "key"},
+		{args: []string{"ak"}, want: "AK"},
+		{args: []string{"quote", "abcd", "7"}, want: `{"pcrs":[7],"values":["AQ=="],"attest":"q80=","signature":"Ag=="}` + "\n"},
+		{args: []string{"quote", "xyz", "7"}, err: errUsage},
+		{args: []string{"seal", "0,x"}, err: errUsage},
+		{args: []string{"nv", "define", "1"}, err: errUsage},
+		{args: []string{"nv", "erase", "1"}, err: errUsage},
+		{args: []string{"nv", "read", "index"}, err: errUsage},
+		{args: []string{"unseal", "x"}, err: errUsage},
+		{args: []string{"reset"}, err: errUsage},
+		{args: nil, err: errUsage},
+	} {
+		f := &fakeTPM{nv: map[uint32][]byte{0x1500016: []byte("data")}}
+		var out bytes.Buffer
+		c := &cmd{r: strings.NewReader(tt.in), w: &out, tpm: f, owner: "owner", auth: "auth", args: tt.args}
+		if err := c.run(); !errors.Is(err, tt.err) {
+			t.Errorf("%q: run = %v, want %v", tt.args, err, tt.err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q: output %q, want %q", tt.args, out.String(), tt.want)
+		}
+		if !slices.Equal(f.calls, tt.calls) {
+			t.Errorf("%q: calls %q, want %q", tt.args, f.calls, tt.calls)
+		}
+	}
+}
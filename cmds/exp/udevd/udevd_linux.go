@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// udevd creates device nodes and symlinks for the kernel's uevents, and
+// loads the modules of new devices.
+//
+// Synopsis:
+//
+//	udevd [-rules FILE] [-dev DIR] [-sys DIR] [-once] [-settle DURATION] [-v]
+//
+// Description:
+//
+//	udevd first handles the devices already in sysfs, then every uevent
+//	the kernel sends. For devices with a modalias, the modules are loaded
+//	from modules.alias. Device nodes are created with the mode and owner
+//	the rules give them, and symlinks such as /dev/disk/by-uuid are made.
+//
+//	Without -rules, the links in /dev/disk are made: by-uuid, by-partuuid,
+//	by-partlabel and by-path. The rules are a subset of udev's, e.g.
+//
+//	    SUBSYSTEM=="block", KERNEL=="sd*", MODE="0660", GROUP="6"
+//	    KERNEL=="ttyS0", SYMLINK+="console/serial"
+//
+// Options:
+//
+//	-rules:  file with rules instead of the defaults
+//	-dev:    where device nodes are created
+//	-sys:    where sysfs is mounted
+//	-once:   exit once no event arrived for the -settle duration
+//	-settle: how long to wait for events with -once
+//	-v:      log every node, link and module
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/kmodule"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/udev"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	rules   = flag.String("rules", "", "File with rules instead of the defaults")
+	dev     = flag.String("dev", "/dev", "Where device nodes are created")
+	sys     = flag.String("sys", "/sys", "Where sysfs is mounted")
+	once    = flag.Bool("once", false, "Exit once no event arrived for the settle duration")
+	settle  = flag.Duration("settle", time.Second, "How long to wait for events with -once")
+	verbose = flag.Bool("v", false, "Log every node, link and module")
+)
+
+// events are the uevents from the kernel, a *udev.Conn.
+type events interface {
+	Read() (*udev.Event, error)
+	SetTimeout(ms int) error
+	Close() error
+}
+
+var listen = func() (events, error) {
+	return udev.Listen()
+}
+
+func run(m *udev.Manager, once bool, settle time.Duration) error {
+	// Listen before the coldplug, so no device added meanwhile is missed.
+	c, err := listen()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := m.Coldplug(m.Handle); err != nil {
+		return err
+	}
+	if once {
+		if err := c.SetTimeout(int(settle.Milliseconds())); err != nil {
+			return err
+		}
+	}
+	for {
+		e, err := c.Read()
+		if once && errors.Is(err, unix.EAGAIN) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.Handle(e); err != nil {
+			log.Printf("%v: %v", e, err)
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	m := &udev.Manager{
+		Dev: *dev,
+		Sys: *sys,
+		Probe: func(modalias string) error {
+			return kmodule.Probe(modalias, "")
+		},
+		FSUUID: block.FSUUID,
+	}
+	if *verbose {
+		m.Log = log.Printf
+	}
+	if *rules != "" {
+		f, err := os.Open(*rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.Rules, err = udev.ParseRules(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *rules, err)
+		}
+	}
+	if err := run(m, *once, *settle); err != nil {
+		log.Fatal(err)
+	}
+}
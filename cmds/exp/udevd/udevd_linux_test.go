@@ -0,0 +1,77 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/udev"
+	"golang.org/x/sys/unix"
+)
+
+type fakeEvents struct {
+	events  []*udev.Event
+	timeout int
+}
+
+func (f *fakeEvents) Read() (*udev.Event, error) {
+	if len(f.events) == 0 {
+		return nil, os.NewSyscallError("recvfrom", unix.EAGAIN)
+	}
+	e := f.events[0]
+	f.events = f.events[1:]
+	return e, nil
+}
+
+func (f *fakeEvents) SetTimeout(ms int) error {
+	f.timeout = ms
+	return nil
+}
+
+func (f *fakeEvents) Close() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	dev, sys := t.TempDir(), t.TempDir()
+	loop := filepath.Join(sys, "devices/virtual/block/loop0")
+	if err := os.MkdirAll(loop, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(loop, "uevent"), []byte("MAJOR=7\nMINOR=0\nDEVNAME=loop0\nDEVTYPE=disk\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../../class/block", filepath.Join(loop, "subsystem")); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeEvents{events: []*udev.Event{{
+		Action:  "add",
+		DevPath: "/devices/virtual/misc/fuse",
+		Env:     map[string]string{"SUBSYSTEM": "misc", "DEVNAME": "fuse", "DEVMODE": "0666", "MAJOR": "10", "MINOR": "229"},
+	}}}
+	listen = func() (events, error) { return f, nil }
+
+	if err := run(&udev.Manager{Dev: dev, Sys: sys}, true, 250*time.Millisecond); err != nil {
+		if os.IsPermission(err) {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	if f.timeout != 250 {
+		t.Errorf("timeout %dms, want 250ms", f.timeout)
+	}
+	for node, mode := range map[string]uint32{"loop0": unix.S_IFBLK | 0o600, "fuse": unix.S_IFCHR | 0o666} {
+		var st unix.Stat_t
+		if err := unix.Stat(filepath.Join(dev, node), &st); err != nil {
+			t.Error(err)
+		} else if st.Mode != mode {
+			t.Errorf("%s: mode %o, want %o", node, st.Mode, mode)
+		}
+	}
+}
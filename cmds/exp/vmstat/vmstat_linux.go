@@ -0,0 +1,275 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// vmstat reports processes, memory, paging, block IO and CPU activity.
+//
+// Synopsis:
+//
+//	vmstat [-t] [-json] [delay [count]]
+//
+// Description:
+//
+//	vmstat reads /proc/stat, /proc/meminfo and /proc/vmstat. The first
+//	report has the averages since boot; with a delay in seconds, more
+//	reports follow with the averages over each delay, count times or
+//	until interrupted.
+//
+//	r and b are the runnable processes and those blocked on IO. The
+//	memory is in KiB: swpd is swap used, and cache includes reclaimable
+//	slab. si and so are KiB swapped in and out per second, bi and bo KiB
+//	read from and written to block devices per second, and in and cs
+//	interrupts and context switches per second. us, sy, id, wa and st are
+//	the percentages of CPU time spent in user code, the kernel, idle,
+//	waiting for IO and stolen by the hypervisor.
+//
+// Options:
+//
+//	-t: add the time of each report
+//	-json: print a JSON object for each report, one per line
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+)
+
+var (
+	timestamps = flag.Bool("t", false, "add the time of each report")
+	toJSON     = flag.Bool("json", false, "print a JSON object for each report")
+)
+
+var errUsage = errors.New("usage: vmstat [-t] [-json] [delay [count]]")
+
+const (
+	header1 = "procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----"
+	header2 = " r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st"
+)
+
+// sample is a snapshot of the counters.
+type sample struct {
+	at   time.Time
+	stat proc.SysStat
+	mem  map[string]uint64
+	vm   map[string]uint64
+}
+
+// report is a line of output. The names are those of the columns.
+type report struct {
+	Time  string `json:"time,omitempty"`
+	R     uint64 `json:"r"`
+	B     uint64 `json:"b"`
+	Swpd  uint64 `json:"swpd"`
+	Free  uint64 `json:"free"`
+	Buff  uint64 `json:"buff"`
+	Cache uint64 `json:"cache"`
+	Si    uint64 `json:"si"`
+	So    uint64 `json:"so"`
+	Bi    uint64 `json:"bi"`
+	Bo    uint64 `json:"bo"`
+	In    uint64 `json:"in"`
+	Cs    uint64 `json:"cs"`
+	Us    uint64 `json:"us"`
+	Sy    uint64 `json:"sy"`
+	Id    uint64 `json:"id"`
+	Wa    uint64 `json:"wa"`
+	St    uint64 `json:"st"`
+}
+
+type vmstat struct {
+	fs         proc.FS
+	pageSize   uint64
+	timestamps bool
+	json       bool
+	now        func() time.Time
+	sleep      func(time.Duration)
+}
+
+func (v *vmstat) sample() (*sample, error) {
+	s := &sample{at: v.now()}
+	var err error
+	if s.stat, err = v.fs.Stat(); err != nil {
+		return nil, err
+	}
+	if s.mem, err = v.fs.MemInfo(); err != nil {
+		return nil, err
+	}
+	if s.vm, err = v.fs.VMStat(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// boot returns a sample of zeros at boot, to average since then.
+func (v *vmstat) boot(cur *sample) (*sample, error) {
+	up, err := v.fs.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	return &sample{at: cur.at.Add(-time.Duration(up * float64(time.Second)))}, nil
+}
+
+// percent returns part of total as a rounded percentage.
+func percent(part, total uint64) uint64 {
+	if total == 0 {
+		return 0
+	}
+	return uint64(math.Round(100 * float64(part) / float64(total)))
+}
+
+// rate returns the change in a counter per second, ignoring counters that
+// went backwards, as they do when they wrap.
+func rate(prev, cur uint64, secs float64) uint64 {
+	if cur < prev || secs <= 0 {
+		return 0
+	}
+	return uint64(math.Round(float64(cur-prev) / secs))
+}
+
+func (v *vmstat) report(prev, cur *sample) report {
+	secs := cur.at.Sub(prev.at).Seconds()
+	// Counts of pages and KiB since the previous sample, per second.
+	pages := func(name string) uint64 {
+		return rate(prev.vm[name], cur.vm[name], secs) * v.pageSize >> 10
+	}
+	kib := func(name string) uint64 {
+		return rate(prev.vm[name], cur.vm[name], secs)
+	}
+
+	p, c := prev.stat.CPU, cur.stat.CPU
+	d := func(a, b uint64) uint64 {
+		if b < a {
+			return 0
+		}
+		return b - a
+	}
+	total := d(p.Total(), c.Total())
+	r := report{
+		R:     cur.stat.ProcsRunning,
+		B:     cur.stat.ProcsBlocked,
+		Swpd:  (cur.mem["SwapTotal"] - cur.mem["SwapFree"]) >> 10,
+		Free:  cur.mem["MemFree"] >> 10,
+		Buff:  cur.mem["Buffers"] >> 10,
+		Cache: (cur.mem["Cached"] + cur.mem["SReclaimable"]) >> 10,
+		Si:    pages("pswpin"),
+		So:    pages("pswpout"),
+		Bi:    kib("pgpgin"),
+		Bo:    kib("pgpgout"),
+		In:    rate(prev.stat.Interrupts, cur.stat.Interrupts, secs),
+		Cs:    rate(prev.stat.ContextSwitches, cur.stat.ContextSwitches, secs),
+		Us:    percent(d(p.User+p.Nice, c.User+c.Nice), total),
+		Sy:    percent(d(p.System+p.IRQ+p.SoftIRQ, c.System+c.IRQ+c.SoftIRQ), total),
+		Id:    percent(d(p.Idle, c.Idle), total),
+		Wa:    percent(d(p.IOWait, c.IOWait), total),
+		St:    percent(d(p.Steal, c.Steal), total),
+	}
+	if v.timestamps {
+		r.Time = cur.at.Format("2006-01-02 15:04:05")
+	}
+	return r
+}
+
+func (v *vmstat) print(w io.Writer, r report, first bool) error {
+	if v.json {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	if first {
+		if v.timestamps {
+			zone, _ := v.now().Zone()
+			fmt.Fprintf(w, "%s -----timestamp-----\n%s %19s\n", header1, header2, zone)
+		} else {
+			fmt.Fprintf(w, "%s\n%s\n", header1, header2)
+		}
+	}
+	fmt.Fprintf(w, "%2d %2d %6d %6d %6d %6d %4d %4d %5d %5d %4d %4d %2d %2d %2d %2d %2d",
+		r.R, r.B, r.Swpd, r.Free, r.Buff, r.Cache, r.Si, r.So, r.Bi, r.Bo, r.In, r.Cs, r.Us, r.Sy, r.Id, r.Wa, r.St)
+	if v.timestamps {
+		fmt.Fprintf(w, " %s", r.Time)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// run prints a report since boot, then count reports each delay apart, or
+// forever if count is 0. Without a delay, there is just the first.
+func (v *vmstat) run(w io.Writer, delay time.Duration, count int) error {
+	cur, err := v.sample()
+	if err != nil {
+		return err
+	}
+	prev, err := v.boot(cur)
+	if err != nil {
+		return err
+	}
+	for i := 1; ; i++ {
+		if err := v.print(w, v.report(prev, cur), i == 1); err != nil {
+			return err
+		}
+		if delay == 0 || (count > 0 && i >= count) {
+			return nil
+		}
+		v.sleep(delay)
+		prev = cur
+		if cur, err = v.sample(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRepeat parses the delay and count arguments.
+func parseRepeat(args []string) (time.Duration, int, error) {
+	if len(args) > 2 {
+		return 0, 0, errUsage
+	}
+	var delay time.Duration
+	count := 0
+	if len(args) > 0 {
+		d, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("%w: bad delay %q", errUsage, args[0])
+		}
+		delay = time.Duration(d * float64(time.Second))
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("%w: bad count %q", errUsage, args[1])
+		}
+		count = n
+	}
+	return delay, count, nil
+}
+
+func main() {
+	flag.Parse()
+	delay, count, err := parseRepeat(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	v := &vmstat{
+		fs:         proc.DefaultRoot,
+		pageSize:   uint64(os.Getpagesize()),
+		timestamps: *timestamps,
+		json:       *toJSON,
+		now:        time.Now,
+		sleep:      time.Sleep,
+	}
+	if err := v.run(os.Stdout, delay, count); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/proc"
+)
+
+func writeProc(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	const meminfo = "MemFree: 4000 kB\nBuffers: 100 kB\nCached: 1000 kB\nSReclaimable: 24 kB\nSwapTotal: 2048 kB\nSwapFree: 1024 kB\n"
+	writeProc(t, root, map[string]string{
+		"uptime":  "100.00 150.00\n",
+		"meminfo": meminfo,
+		"stat":    "cpu 600 0 200 1000 100 50 50 0\nintr 10000\nctxt 50000\nprocs_running 2\nprocs_blocked 1\n",
+		"vmstat":  "pswpin 100\npswpout 200\npgpgin 3000\npgpgout 5000\n",
+	})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	v := &vmstat{
+		fs:       proc.FS(root),
+		pageSize: 4096,
+		now:      func() time.Time { return now },
+		sleep: func(d time.Duration) {
+			now = now.Add(d)
+			writeProc(t, root, map[string]string{
+				"stat":   "cpu 650 50 250 1100 50 50 50 0\nintr 10200\nctxt 50400\nprocs_running 1\nprocs_blocked 0\n",
+				"vmstat": "pswpin 100\npswpout 210\npgpgin 3100\npgpgout 5000\n",
+			})
+		},
+	}
+
+	var out bytes.Buffer
+	if err := v.run(&out, 2*time.Second, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := header1 + "\n" + header2 + "\n" +
+		" 2  1   1024   4000    100   1024    4    8    30    50  100  500 30 15 50  5  0\n" +
+		" 1  0   1024   4000    100   1024    0   20    50     0  100  200 50 25 50  0  0\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	now = start
+	v.json, v.timestamps = true, true
+	out.Reset()
+	if err := v.run(&out, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, `{"time":"2024-01-01 00:00:00","r":1,"b":0,"swpd":1024,`) || strings.Count(got, "\n") != 1 {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseRepeat(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		delay time.Duration
+		count int
+		err   error
+	}{
+		{},
+		{args: []string{"2"}, delay: 2 * time.Second},
+		{args: []string{"0.5", "3"}, delay: time.Second / 2, count: 3},
+		{args: []string{"0"}, err: errUsage},
+		{args: []string{"1", "x"}, err: errUsage},
+		{args: []string{"1", "2", "3"}, err: errUsage},
+	} {
+		delay, count, err := parseRepeat(tt.args)
+		if !errors.Is(err, tt.err) || delay != tt.delay || count != tt.count {
+			t.Errorf("parseRepeat(%q) = %v, %d, %v, want %v, %d, %v", tt.args, delay, count, err, tt.delay, tt.count, tt.err)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// xxd makes a hexdump of a file, or a file of a hexdump.
+//
+// Synopsis:
+//
+//	xxd [-C|-p] [-c COLS] [-g BYTES] [-s SEEK] [-l LEN] [-d FILE] [-R WHEN] [INFILE [OUTFILE]]
+//	xxd -r [-p] [-s OFFSET] [INFILE [OUTFILE]]
+//
+// Description:
+//
+//	xxd dumps INFILE, or stdin, to OUTFILE, or stdout, in the format of
+//	xxd, in the canonical format of hexdump -C with -C, or as plain hex
+//	with -p.
+//
+//	With -d, it compares INFILE with FILE and dumps only the lines which
+//	differ, those of INFILE prefixed with - and those of FILE with +.
+//	Differing bytes are colored. xxd then exits with status 1, like cmp.
+//
+//	With -r, it reverses a dump: it reads lines of the xxd or canonical
+//	format, or plain hex with -p, and writes their bytes. Lines are
+//	written at their offsets plus OFFSET, and an OUTFILE is patched, not
+//	truncated, so that a dump of a firmware blob can be edited and
+//	written back to it.
+//
+// Options:
+//
+//	-C: dump in the canonical format of hexdump -C
+//	-p: dump or reverse plain hex
+//	-c: bytes per line (default 16, 30 with -p)
+//	-g: bytes per group (default 2)
+//	-s: start at this offset; negative offsets count from the end
+//	-l: stop after this many bytes
+//	-d: dump the lines which differ from those of FILE
+//	-R: color the differences of -d: always, auto or never (default auto)
+//	-r: reverse a dump into binary
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorDiff  = "\x1b[1;31m"
+	colorReset = "\x1b[0m"
+)
+
+var (
+	errUsage  = errors.New("usage: xxd [-C|-p|-r] [-c COLS] [-g BYTES] [-s SEEK] [-l LEN] [-d FILE] [-R WHEN] [INFILE [OUTFILE]]")
+	errDiffer = errors.New("files differ")
+	errSeek   = errors.New("cannot write backwards to a stream")
+	errLine   = errors.New("malformed dump line")
+)
+
+type cmd struct {
+	stdin  io.Reader
+	stdout io.Writer
+	args   []string
+
+	canonical bool
+	plain     bool
+	reverse   bool
+	cols      int
+	group     int
+	seek      int64
+	length    int64
+	diff      string
+	color     bool
+}
+
+// line formats the bytes of b, which are at off, as a line of a dump.
+// Bytes for which mark is set are colored.
+func (c *cmd) line(off int64, b []byte, mark []bool) string {
+	var s strings.Builder
+	hexByte := func(i int) {
+		if mark != nil && mark[i] && c.color {
+			fmt.Fprintf(&s, "%s%02x%s", colorDiff, b[i], colorReset)
+		} else {
+			fmt.Fprintf(&s, "%02x", b[i])
+		}
+	}
+	if c.plain {
+		for i := range b {
+			hexByte(i)
+		}
+		return s.String()
+	}
+
+	if c.canonical {
+		fmt.Fprintf(&s, "%08x  ", off)
+		for i := 0; i < c.cols; i++ {
+			if i < len(b) {
+				hexByte(i)
+				s.WriteString(" ")
+			} else {
+				s.WriteString("   ")
+			}
+			if i == 7 {
+				s.WriteString(" ")
+			}
+		}
+		s.WriteString(" |")
+	} else {
+		fmt.Fprintf(&s, "%08x: ", off)
+		for i := 0; i < c.cols; i++ {
+			if i < len(b) {
+				hexByte(i)
+			} else {
+				s.WriteString("  ")
+			}
+			if (i+1)%c.group == 0 || i == c.cols-1 {
+				s.WriteString(" ")
+			}
+		}
+		s.WriteString(" ")
+	}
+	for i, ch := range b {
+		if ch < 0x20 || ch > 0x7e {
+			ch = '.'
+		}
+		if mark != nil && mark[i] && c.color {
+			fmt.Fprintf(&s, "%s%c%s", colorDiff, ch, colorReset)
+		} else {
+			s.WriteByte(ch)
+		}
+	}
+	if c.canonical {
+		s.WriteString("|")
+	}
+	return s.String()
+}
+
+// open opens the input file name, or stdin for "" or "-", and skips to
+// the offset of -s.
+func (c *cmd) open(name string) (io.Reader, int64, func() error, error) {
+	r, closer := c.stdin, func() error { return nil }
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		r, closer = f, f.Close
+	}
+	off := c.seek
+	if s, ok := r.(io.Seeker); ok {
+		whence := io.SeekStart
+		if off < 0 {
+			whence = io.SeekEnd
+		}
+		pos, err := s.Seek(off, whence)
+		if err == nil {
+			return r, pos, closer, nil
+		}
+	}
+	if off < 0 {
+		closer()
+		return nil, 0, nil, fmt.Errorf("%s: %w", name, errSeek)
+	}
+	if _, err := io.CopyN(io.Discard, r, off); err != nil && err != io.EOF {
+		closer()
+		return nil, 0, nil, err
+	}
+	return r, off, closer, nil
+}
+
+// dump writes the dump of r, which starts at off, to w.
+func (c *cmd) dump(w io.Writer, r io.Reader, off int64) error {
+	if c.length >= 0 {
+		r = io.LimitReader(r, c.length)
+	}
+	br := bufio.NewReader(r)
+	b := make([]byte, c.cols)
+	for {
+		n, err := io.ReadFull(br, b)
+		if n > 0 {
+			if _, err := fmt.Fprintln(w, c.line(off, b[:n], nil)); err != nil {
+				return err
+			}
+			off += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// compare writes the lines of r1 and r2, which start at off, which differ
+// to w.
+func (c *cmd) compare(w io.Writer, r1, r2 io.Reader, off int64) error {
+	if c.length >= 0 {
+		r1, r2 = io.LimitReader(r1, c.length), io.LimitReader(r2, c.length)
+	}
+	br1, br2 := bufio.NewReader(r1), bufio.NewReader(r2)
+	b1, b2 := make([]byte, c.cols), make([]byte, c.cols)
+	differ := false
+	for {
+		n1, err1 := io.ReadFull(br1, b1)
+		n2, err2 := io.ReadFull(br2, b2)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return err1
+		}
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return err2
+		}
+		if n1 == 0 && n2 == 0 {
+			break
+		}
+		if !bytes.Equal(b1[:n1], b2[:n2]) {
+			differ = true
+			mark := make([]bool, c.cols)
+			for i := range mark {
+				mark[i] = i >= n1 || i >= n2 || b1[i] != b2[i]
+			}
+			for _, l := range []struct {
+				prefix string
+				b      []byte
+			}{{"-", b1[:n1]}, {"+", b2[:n2]}} {
+				if len(l.b) == 0 {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "%s%s\n", l.prefix, c.line(off, l.b, mark)); err != nil {
+					return err
+				}
+			}
+		}
+		off += int64(c.cols)
+	}
+	if differ {
+		return errDiffer
+	}
+	return nil
+}
+
+// streamWriter writes to a stream at increasing offsets, filling gaps with
+// zeros.
+type streamWriter struct {
+	w   io.Writer
+	off int64
+}
+
+// WriteAt implements io.WriterAt.
+func (s *streamWriter) WriteAt(b []byte, off int64) (int, error) {
+	if off < s.off {
+		return 0, fmt.Errorf("offset %#x: %w", off, errSeek)
+	}
+	if _, err := io.CopyN(s.w, zeros{}, off-s.off); err != nil {
+		return 0, err
+	}
+	n, err := s.w.Write(b)
+	s.off = off + int64(n)
+	return n, err
+}
+
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// parseLine returns the offset and bytes of a line of the xxd or canonical
+// format.
+func parseLine(l string) (int64, []byte, error) {
+	i := strings.IndexAny(l, ": ")
+	if i < 0 {
+		return 0, nil, fmt.Errorf("%w: %q", errLine, l)
+	}
+	off, err := strconv.ParseInt(l[:i], 16, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %q", errLine, l)
+	}
+	h := l[i+1:]
+	if l[i] == ':' {
+		// The ASCII column follows two spaces.
+		if j := strings.Index(h, "  "); j >= 0 {
+			h = h[:j]
+		}
+	} else if j := strings.Index(h, "|"); j >= 0 {
+		h = h[:j]
+	}
+	b, err := hex.DecodeString(strings.Join(strings.Fields(h), ""))
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %q: %v", errLine, l, err)
+	}
+	return off, b, nil
+}
+
+// unhex writes the bytes of the dump in r to w.
+func (c *cmd) unhex(w io.WriterAt, r io.Reader) error {
+	s := bufio.NewScanner(r)
+	off := c.seek
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" {
+			continue
+		}
+		if c.plain {
+			b, err := hex.DecodeString(strings.Join(strings.Fields(l), ""))
+			if err != nil {
+				return fmt.Errorf("%w: %q: %v", errLine, l, err)
+			}
+			if _, err := w.WriteAt(b, off); err != nil {
+				return err
+			}
+			off += int64(len(b))
+			continue
+		}
+		o, b, err := parseLine(l)
+		if err != nil {
+			return err
+		}
+		if _, err := w.WriteAt(b, o+c.seek); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func (c *cmd) run() error {
+	var in, out string
+	switch len(c.args) {
+	case 2:
+		out = c.args[1]
+		fallthrough
+	case 1:
+		in = c.args[0]
+	case 0:
+	default:
+		return errUsage
+	}
+
+	if c.reverse {
+		r := c.stdin
+		if in != "" && in != "-" {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if out == "" {
+			return c.unhex(&streamWriter{w: c.stdout}, r)
+		}
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := c.unhex(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	r, off, closer, err := c.open(in)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if out == "" {
+		return c.write(c.stdout, r, off)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = c.write(bw, r, off)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// write writes the dump of r, which starts at off, or its differences to
+// the file of -d, to w.
+func (c *cmd) write(w io.Writer, r io.Reader, off int64) error {
+	if c.diff == "" {
+		return c.dump(w, r, off)
+	}
+	r2, _, closer, err := c.open(c.diff)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	return c.compare(w, r, r2, off)
+}
+
+func command(stdin io.Reader, stdout io.Writer, args []string) (*cmd, error) {
+	c := &cmd{stdin: stdin, stdout: stdout}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.BoolVar(&c.canonical, "C", false, "Dump in the canonical format of hexdump -C")
+	f.BoolVar(&c.plain, "p", false, "Dump or reverse plain hex")
+	f.BoolVar(&c.reverse, "r", false, "Reverse a dump into binary")
+	f.IntVar(&c.cols, "c", 0, "Bytes per line (default 16, 30 with -p)")
+	f.IntVar(&c.group, "g", 2, "Bytes per group")
+	f.Int64Var(&c.seek, "s", 0, "Start at this offset; negative offsets count from the end")
+	f.Int64Var(&c.length, "l", -1, "Stop after this many bytes")
+	f.StringVar(&c.diff, "d", "", "Dump the lines which differ from those of FILE")
+	color := f.String("R", "auto", "Color the differences of -d: always, auto or never")
+	if err := f.Parse(args[1:]); err != nil {
+		return nil, errUsage
+	}
+	c.args = f.Args()
+	if c.canonical && c.plain {
+		return nil, errUsage
+	}
+	switch {
+	case c.canonical:
+		c.cols = 16
+	case c.cols == 0 && c.plain:
+		c.cols = 30
+	case c.cols == 0:
+		c.cols = 16
+	}
+	if c.cols < 0 || c.group < 0 {
+		return nil, errUsage
+	}
+	if c.group == 0 {
+		c.group = c.cols
+	}
+	switch *color {
+	case "always":
+		c.color = true
+	case "auto":
+		f, ok := stdout.(*os.File)
+		c.color = ok && term.IsTerminal(int(f.Fd()))
+	case "never":
+	default:
+		return nil, errUsage
+	}
+	return c, nil
+}
+
+func main() {
+	c, err := command(os.Stdin, os.Stdout, os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.run(); errors.Is(err, errDiffer) {
+		os.Exit(1)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testData = "abcdefghijklmnopqrstuvwxyz"
+
+func TestDump(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte(testData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := os.WriteFile(other, []byte(strings.Replace(testData, "r", "R", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		err  error
+	}{
+		{
+			name: "xxd",
+			args: []string{file},
+			want: "00000000: 6162 6364 6566 6768 696a 6b6c 6d6e 6f70  abcdefghijklmnop\n" +
+				"00000010: 7172 7374 7576 7778 797a                 qrstuvwxyz\n",
+		},
+		{
+			name: "stdin",
+			want: "00000000: 6162 6364 6566 6768 696a 6b6c 6d6e 6f70  abcdefghijklmnop\n" +
+				"00000010: 7172 7374 7576 7778 797a                 qrstuvwxyz\n",
+		},
+		{
+			name: "canonical",
+			args: []string{"-C", file},
+			want: "00000000  61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70  |abcdefghijklmnop|\n" +
+				"00000010  71 72 73 74 75 76 77 78  79 7a                    |qrstuvwxyz|\n",
+		},
+		{
+			name: "plain",
+			args: []string{"-p", "-c", "10", file},
+			want: "6162636465666768696a\n6b6c6d6e6f7071727374\n75767778797a\n",
+		},
+		{
+			name: "seek length cols group",
+			args: []string{"-s", "0x4", "-l", "10", "-c", "8", "-g", "4", file},
+			want: "00000004: 65666768 696a6b6c  efghijkl\n" +
+				"0000000c: 6d6e               mn\n",
+		},
+		{
+			name: "seek from end",
+			args: []string{"-s", "-3", file},
+			want: "00000017: 7879 7a                                  xyz\n",
+		},
+		{
+			name: "diff",
+			args: []string{"-d", other, "-R", "always", file},
+			want: "-00000010: 71\x1b[1;31m72\x1b[0m 7374 7576 7778 797a                 q\x1b[1;31mr\x1b[0mstuvwxyz\n" +
+				"+00000010: 71\x1b[1;31m52\x1b[0m 7374 7576 7778 797a                 q\x1b[1;31mR\x1b[0mstuvwxyz\n",
+			err: errDiffer,
+		},
+		{name: "same", args: []string{"-d", file, file}},
+		{name: "bad color", args: []string{"-R", "sometimes"}, err: errUsage},
+		{name: "plain and canonical", args: []string{"-p", "-C"}, err: errUsage},
+		{name: "too many files", args: []string{"a", "b", "c"}, err: errUsage},
+	} {
+		var out bytes.Buffer
+		c, err := command(strings.NewReader(testData), &out, append([]string{"xxd"}, tt.args...))
+		if err == nil {
+			err = c.run()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, out.String(), tt.want)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blob")
+	if err := os.WriteFile(blob, []byte(testData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		in   string
+		want string
+		err  error
+	}{
+		{
+			name: "xxd",
+			in: "00000000: 6162 6364 6566 6768 696a 6b6c 6d6e 6f70  abcdefghijklmnop\n" +
+				"00000010: 7172 7374 7576 7778 797a                 qrstuvwxyz\n",
+			want: testData,
+		},
+		{
+			name: "canonical",
+			in:   "00000000  61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70  |abcd|efghijklmnop|\n",
+			want: testData[:16],
+		},
+		{
+			name: "gap",
+			in:   "00000002: 4142  AB\n",
+			want: "\x00\x00AB",
+		},
+		{
+			name: "plain",
+			args: []string{"-p"},
+			in:   "6162 63\n\n6465\n",
+			want: "abcde",
+		},
+		{name: "backwards", in: "00000004: 41  A\n00000000: 42  B\n", want: "\x00\x00\x00\x00A", err: errSeek},
+		{name: "garbage", in: "hello\n", err: errLine},
+		{name: "bad hex", in: "00000000: 6x  a\n", err: errLine},
+	} {
+		var out bytes.Buffer
+		c, err := command(strings.NewReader(tt.in), &out, append([]string{"xxd", "-r"}, tt.args...))
+		if err == nil {
+			err = c.run()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, out.String(), tt.want)
+		}
+	}
+
+	// Patch a file in place: the dump of a line is edited and written back.
+	c, err := command(strings.NewReader("00000010: 5152 53  QRS\n"), nil, []string{"xxd", "-r", "-", blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcdefghijklmnopQRStuvwxyz"; string(b) != want {
+		t.Errorf("patched file is %q, want %q", b, want)
+	}
+}
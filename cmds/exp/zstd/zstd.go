@@ -0,0 +1,175 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// zstd compresses and decompresses files in the Zstandard format.
+//
+// Synopsis:
+//
+//	zstd [-d] [-c] [-k] [-f] [-p N] [-level N] [FILE]...
+//
+// Description:
+//
+//	Each FILE is compressed to FILE.zst, or decompressed from FILE.zst to
+//	FILE, and removed afterwards. Without FILE, or if FILE is -, zstd
+//	reads standard input and writes standard output.
+//
+//	Like pigz, zstd splits its input into blocks that several workers
+//	compress at the same time; all files share the same workers.
+//
+// Options:
+//
+//	-d:       decompress
+//	-c:       write to standard output and keep input files
+//	-k:       keep input files
+//	-f:       overwrite existing output files
+//	-p N:     compress with N workers (default: the number of CPUs)
+//	-level N: compression level from 1 to 22 (default: 3)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/compress"
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+const suffix = ".zst"
+
+var errFormat = errors.New("not in zstd format")
+
+type cmd struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	decompress bool
+	stdoutOnly bool
+	keep       bool
+	force      bool
+	level      int
+	pool       *compress.Pool
+}
+
+func run(stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	c := &cmd{stdin: stdin, stdout: stdout, stderr: stderr}
+	f := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	f.SetOutput(stderr)
+	f.BoolVar(&c.decompress, "d", false, "decompress")
+	f.BoolVar(&c.stdoutOnly, "c", false, "write to standard output and keep input files")
+	f.BoolVar(&c.keep, "k", false, "keep input files")
+	f.BoolVar(&c.force, "f", false, "overwrite existing output files")
+	jobs := f.Int("p", 0, "compress with N workers (default: the number of CPUs)")
+	f.IntVar(&c.level, "level", compress.DefaultLevel, "compression level from 1 to 22")
+	if err := f.Parse(unixflag.ArgsToGoArgs(args[1:])); err != nil {
+		return err
+	}
+	c.pool = compress.NewPool(*jobs)
+
+	// Catch bad levels before touching any file.
+	if _, err := compress.NewWriter(io.Discard, compress.Zstd, c.level); err != nil {
+		return err
+	}
+
+	files := f.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var failed bool
+	for _, name := range files {
+		if err := c.file(name); err != nil {
+			fmt.Fprintf(stderr, "zstd: %s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("some files failed")
+	}
+	return nil
+}
+
+// file compresses or decompresses one file, or standard input for -.
+func (c *cmd) file(name string) error {
+	if name == "-" {
+		return c.copy(c.stdout, c.stdin)
+	}
+
+	out := name + suffix
+	if c.decompress {
+		var ok bool
+		if out, ok = strings.CutSuffix(name, suffix); !ok {
+			return fmt.Errorf("unknown suffix, want %s", suffix)
+		}
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if c.stdoutOnly {
+		return c.copy(c.stdout, in)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if c.force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	o, err := os.OpenFile(out, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := c.copy(o, in); err != nil {
+		o.Close()
+		os.Remove(out)
+		return err
+	}
+	if err := o.Close(); err != nil {
+		return err
+	}
+	if c.keep {
+		return nil
+	}
+	return os.Remove(name)
+}
+
+// copy compresses or decompresses r to w.
+func (c *cmd) copy(w io.Writer, r io.Reader) error {
+	if c.decompress {
+		zr, format, err := compress.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		if format != compress.Zstd {
+			return errFormat
+		}
+		_, err = io.Copy(w, zr)
+		return err
+	}
+
+	zw, err := compress.NewParallelWriter(w, compress.Zstd, c.level, compress.DefaultBlockSize, c.pool)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func main() {
+	if err := run(os.Stdin, os.Stdout, os.Stderr, os.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatalf("zstd: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStdio(t *testing.T) {
+	data := strings.Repeat("u-root zstd ", 1<<16)
+	var compressed, stderr bytes.Buffer
+	if err := run(strings.NewReader(data), &compressed, &stderr, []string{"zstd", "-p", "4", "-level", "19"}); err != nil {
+		t.Fatalf("compressing: %v, %s", err, stderr.String())
+	}
+	if compressed.Len() >= len(data) {
+		t.Errorf("compressed to %d bytes, want less than %d", compressed.Len(), len(data))
+	}
+
+	var out bytes.Buffer
+	if err := run(&compressed, &out, &stderr, []string{"zstd", "-d"}); err != nil {
+		t.Fatalf("decompressing: %v, %s", err, stderr.String())
+	}
+	if out.String() != data {
+		t.Errorf("round trip returned %d bytes, want %d", out.Len(), len(data))
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "image")
+	if err := os.WriteFile(name, []byte("disk image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", name}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("input file kept without -k")
+	}
+
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", "-dk", name + ".zst"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(name); err != nil || string(b) != "disk image" {
+		t.Errorf("decompressed file = %q, %v, want %q", b, err, "disk image")
+	}
+	if _, err := os.Stat(name + ".zst"); err != nil {
+		t.Errorf("input file removed with -k: %v", err)
+	}
+
+	// The output exists now, so compressing again needs -f.
+	var stderr bytes.Buffer
+	if err := run(nil, io.Discard, &stderr, []string{"zstd", "-k", name}); err == nil {
+		t.Errorf("overwrote %s.zst without -f", name)
+	}
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", "-kf", name}); err != nil {
+		t.Errorf("compressing with -f: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var stderr bytes.Buffer
+	if err := run(strings.NewReader("plain"), io.Discard, &stderr, []string{"zstd", "-d"}); err == nil || !strings.Contains(stderr.String(), errFormat.Error()) {
+		t.Errorf("decompressing plain text: got %v, %q, want %v", err, stderr.String(), errFormat)
+	}
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", "-level", "40"}); err == nil {
+		t.Errorf("level 40: got nil, want error")
+	}
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", "-d", "file.gz"}); err == nil {
+		t.Errorf("decompressing file.gz: got nil, want error")
+	}
+	if err := run(nil, io.Discard, io.Discard, []string{"zstd", "-x"}); err == nil {
+		t.Errorf("unknown flag: got nil, want error")
+	}
+}
@@ -6,7 +6,7 @@
 //
 // Synopsis:
 //
-//	flash -p PROGRAMMER[:parameter[,parameter[...]]] [-r FILE|-w FILE]
+//	flash -p PROGRAMMER[:parameter[,parameter[...]]] [-l FILE|--ifd] [-i REGION]... [-e] [-r FILE|-w FILE|-v FILE]
 //
 // Options:
 //
@@ -14,10 +14,19 @@
 //	-s size: Number of bytes to read or write.
 //	-p PROGRAMMER: Specify the programmer with zero or more parameters (see
 //	               below).
+//	-e: Erase the flash chip, or the included regions.
 //	-r FILE: Read flash data into the file.
 //	-w FILE: Write the file to the flash chip. First, the flash chip is read
 //	         and then diffed against the file. The differing blocks are
 //	         erased and written. Finally, the contents are verified.
+//	-v FILE: Verify the flash chip against the file.
+//	-n: Do not verify after writing.
+//	-l FILE: Read the layout of the flash chip from a flashrom layout file,
+//	         with lines such as "00001000:00ffffff bios".
+//	--ifd: Read the layout from the Intel flash descriptor on the chip.
+//	-i REGION: Only read, write, erase or verify a region of the layout.
+//	           May be repeated. With regions, files are images of the whole
+//	           chip, of which only the regions are used.
 //
 // Programmers:
 //
@@ -27,6 +36,13 @@
 //	  dummy:image=image.rom
 //	    File to memmap for the memory buffer.
 //
+//	internal
+//	  Use the BIOS flash through the MTD device of Linux's intel-spi
+//	  driver, which has to be loaded with writeable=1 to write.
+//
+//	linux_mtd:dev=/dev/mtd0
+//	  Use a Linux MTD device. The dev parameter is required.
+//
 //	linux_spi:dev=/dev/spidev0.0
 //	  Use Linux's spidev driver. This is only supported on Linux. The dev
 //	  parameter is required.
@@ -37,10 +53,12 @@
 // Description:
 //
 //	flash is u-root's implementation of flashrom. It has a very limited
-//	feature set and depends on the the flash chip implementing the SFDP.
+//	feature set. Through spidev, it depends on the flash chip implementing
+//	the SFDP.
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -51,12 +69,15 @@ import (
 	"strings"
 
 	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/flash"
+	"github.com/u-root/u-root/pkg/flash/layout"
 )
 
 type programmer interface {
 	io.ReaderAt
 	io.WriterAt
 	EraseAt(int64, int64) (int64, error)
+	EraseSize() int64
 	Size() int64
 	Close() error
 }
@@ -87,6 +108,62 @@ func parseProgrammerParams(arg string) (string, map[string]string) {
 	return arg[:colon], params
 }
 
+// readLayout reads the layout from a file or from the flash descriptor on
+// the chip, and selects the regions.
+func readLayout(p programmer, file string, ifd bool, regions []string) (layout.Layout, error) {
+	var l layout.Layout
+	var err error
+	switch {
+	case file != "":
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if l, err = layout.Parse(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	case ifd:
+		if l, err = layout.FromIFD(p, p.Size()); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range l {
+		if r.End >= p.Size() {
+			return nil, fmt.Errorf("region %v is past the end of the %#x byte chip", r, p.Size())
+		}
+	}
+	return l.Select(regions...)
+}
+
+// part is the contents of a region of an image.
+type part struct {
+	layout.Region
+	data []byte
+}
+
+// readImage reads a file that is written to or verified against the chip.
+// With regions, it has to be an image of the whole chip, of which the
+// regions are used. Without, it is placed at the start of rng.
+func readImage(file string, p programmer, regions layout.Layout, rng layout.Region) ([]part, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if regions == nil {
+		buf = buf[:min(int64(len(buf)), rng.Size())]
+		return []part{{Region: layout.Region{Name: file, Start: rng.Start, End: rng.Start + int64(len(buf)) - 1}, data: buf}}, nil
+	}
+	if int64(len(buf)) != p.Size() {
+		return nil, fmt.Errorf("%s is %#x bytes, but regions need an image of the whole %#x byte chip", file, len(buf), p.Size())
+	}
+	var parts []part
+	for _, r := range regions {
+		parts = append(parts, part{Region: r, data: buf[r.Start : r.End+1]})
+	}
+	return parts, nil
+}
+
 func run(args []string, supportedProgrammers map[string]programmerInit) (reterr error) {
 	// Make a human readable list of supported programmers.
 	programmerList := []string{}
@@ -98,12 +175,17 @@ func run(args []string, supportedProgrammers map[string]programmerInit) (reterr
 	// Parse args.
 	fs := flag.NewFlagSet("flash", flag.ContinueOnError)
 	var (
-		e    = fs.BoolP("erase", "e", false, "erase the flash part")
-		p    = fs.StringP("programmer", "p", "", fmt.Sprintf("programmer (%s)", strings.Join(programmerList, ",")))
-		r    = fs.StringP("read", "r", "", "read flash data into the file")
-		w    = fs.StringP("write", "w", "", "write the file to flash")
-		off  = fs.Int64P("offset", "o", 0, "off at which to write")
-		size = fs.Int64P("size", "s", math.MaxInt64, "number of bytes")
+		e        = fs.BoolP("erase", "e", false, "erase the flash part")
+		p        = fs.StringP("programmer", "p", "", fmt.Sprintf("programmer (%s)", strings.Join(programmerList, ",")))
+		r        = fs.StringP("read", "r", "", "read flash data into the file")
+		w        = fs.StringP("write", "w", "", "write the file to flash")
+		v        = fs.StringP("verify", "v", "", "verify flash against the file")
+		noverify = fs.BoolP("noverify", "n", false, "do not verify after writing")
+		lf       = fs.StringP("layout", "l", "", "read the flash layout from the file")
+		ifd      = fs.Bool("ifd", false, "read the flash layout from the Intel flash descriptor")
+		include  = fs.StringArrayP("image", "i", nil, "only access the region of the layout")
+		off      = fs.Int64P("offset", "o", 0, "off at which to write")
+		size     = fs.Int64P("size", "s", math.MaxInt64, "number of bytes")
 	)
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -117,11 +199,26 @@ func run(args []string, supportedProgrammers map[string]programmerInit) (reterr
 		return errors.New("-p needs to be set")
 	}
 
-	if *r == "" && *w == "" && !*e {
-		return errors.New("at least one of -e, -r or -w need to be set")
+	if *r == "" && *w == "" && *v == "" && !*e {
+		return errors.New("at least one of -e, -r, -w or -v need to be set")
+	}
+	ops := 0
+	for _, f := range []string{*r, *w, *v} {
+		if f != "" {
+			ops++
+		}
+	}
+	if ops > 1 {
+		return errors.New("only one of -r, -w and -v can be set")
 	}
-	if *r != "" && *w != "" {
-		return errors.New("both -r and -w cannot be set")
+	if *lf != "" && *ifd {
+		return errors.New("both -l and --ifd cannot be set")
+	}
+	if len(*include) != 0 && *lf == "" && !*ifd {
+		return errors.New("-i needs a layout from -l or --ifd")
+	}
+	if len(*include) != 0 && (fs.Changed("offset") || fs.Changed("size")) {
+		return errors.New("-i cannot be combined with -o or -s")
 	}
 
 	programmerName, params := parseProgrammerParams(*p)
@@ -141,49 +238,78 @@ func run(args []string, supportedProgrammers map[string]programmerInit) (reterr
 		}
 	}()
 
+	// Without regions, the range from -o and -s is accessed.
+	var regions layout.Layout
+	if len(*include) != 0 {
+		if regions, err = readLayout(programmer, *lf, *ifd, *include); err != nil {
+			return err
+		}
+	}
+	if *off < 0 || *off > programmer.Size() {
+		return fmt.Errorf("offset %#x is outside of the %#x byte chip", *off, programmer.Size())
+	}
+	rng := layout.Region{Name: "range", Start: *off, End: *off + min(*size, programmer.Size()-*off) - 1}
+
 	if *e {
-		n, err := programmer.EraseAt(min(*size, programmer.Size()), *off)
-		if err != nil {
-			log.Fatal(err)
+		targets := regions
+		if targets == nil {
+			targets = layout.Layout{rng}
+		}
+		for _, reg := range targets {
+			n, err := programmer.EraseAt(reg.Size(), reg.Start)
+			if err != nil {
+				return fmt.Errorf("erasing %v: %w", reg, err)
+			}
+			log.Printf("Erased %#x bytes @ %#x", n, reg.Start)
 		}
-		log.Printf("Erased %#x bytes @ %#x", n, *off)
 	}
 
-	// Create a buffer to hold the contents of the image.
+	switch {
+	case *r != "":
+		var buf []byte
+		if regions == nil {
+			buf = make([]byte, rng.Size())
+			if _, err := programmer.ReadAt(buf, rng.Start); err != nil {
+				return err
+			}
+		} else {
+			// Leave everything but the regions erased.
+			buf = bytes.Repeat([]byte{0xff}, int(programmer.Size()))
+			for _, reg := range regions {
+				if _, err := programmer.ReadAt(buf[reg.Start:reg.End+1], reg.Start); err != nil {
+					return fmt.Errorf("reading %v: %w", reg, err)
+				}
+			}
+		}
+		return os.WriteFile(*r, buf, 0o644)
 
-	if *r != "" {
-		buf := make([]byte, min(*size, programmer.Size()))
-		f, err := os.Create(*r)
+	case *w != "":
+		parts, err := readImage(*w, programmer, regions, rng)
 		if err != nil {
 			return err
 		}
-		defer func() {
-			err := f.Close()
-			if reterr == nil {
-				reterr = err
+		for _, pt := range parts {
+			if _, err := flash.Program(programmer, pt.data, pt.Start); err != nil {
+				return fmt.Errorf("writing %v: %w", pt.Region, err)
+			}
+			if *noverify {
+				continue
+			}
+			if err := flash.Verify(programmer, pt.data, pt.Start); err != nil {
+				return fmt.Errorf("verifying %v: %w", pt.Region, err)
 			}
-		}()
-		if _, err := programmer.ReadAt(buf, *off); err != nil {
-			return err
-		}
-		if _, err := f.Write(buf); err != nil {
-			return err
 		}
-	} else if *w != "" {
-		buf, err := os.ReadFile(*w)
+
+	case *v != "":
+		parts, err := readImage(*v, programmer, regions, rng)
 		if err != nil {
 			return err
 		}
-		buf = buf[:min(int64(len(buf)), *size)]
-		amt, err := programmer.WriteAt(buf, *off)
-		if err != nil {
-			return fmt.Errorf("writing %d bytes to dev %v:%w", len(buf), programmer, err)
-		}
-		if amt != len(buf) {
-			return fmt.Errorf("only flashed %d of %d bytes", amt, len(buf))
+		for _, pt := range parts {
+			if err := flash.Verify(programmer, pt.data, pt.Start); err != nil {
+				return fmt.Errorf("verifying %v: %w", pt.Region, err)
+			}
 		}
-
-		return nil
 	}
 
 	return nil
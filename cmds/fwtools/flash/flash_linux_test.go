@@ -0,0 +1,105 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memProgrammer is a 64 KiB chip in memory.
+type memProgrammer struct {
+	data []byte
+}
+
+func (m *memProgrammer) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, m.data[off:]), nil
+}
+
+func (m *memProgrammer) WriteAt(p []byte, off int64) (int, error) {
+	return copy(m.data[off:], p), nil
+}
+
+func (m *memProgrammer) EraseAt(n, off int64) (int64, error) {
+	copy(m.data[off:off+n], bytes.Repeat([]byte{0xff}, int(n)))
+	return n, nil
+}
+
+func (m *memProgrammer) EraseSize() int64 { return 4096 }
+func (m *memProgrammer) Size() int64      { return int64(len(m.data)) }
+func (m *memProgrammer) Close() error     { return nil }
+
+func TestRegions(t *testing.T) {
+	dir := t.TempDir()
+	chip := &memProgrammer{data: bytes.Repeat([]byte{0x11}, 0x10000)}
+	programmers := map[string]programmerInit{
+		"mem": func(programmerParams) (programmer, error) { return chip, nil },
+	}
+	layoutFile := filepath.Join(dir, "layout")
+	if err := os.WriteFile(layoutFile, []byte("00000000:00000fff fd\n00001000:00007fff me\n00008000:0000ffff bios\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write only the BIOS region of a new image.
+	img := filepath.Join(dir, "new.rom")
+	if err := os.WriteFile(img, bytes.Repeat([]byte{0x22}, 0x10000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-p", "mem", "-l", layoutFile, "-i", "bios", "-w", img}, programmers); err != nil {
+		t.Fatalf("writing bios: %v", err)
+	}
+	if chip.data[0x7fff] != 0x11 || chip.data[0x8000] != 0x22 {
+		t.Errorf("after writing bios, me ends with %#x and bios starts with %#x, want 0x11 and 0x22", chip.data[0x7fff], chip.data[0x8000])
+	}
+	if err := run([]string{"-p", "mem", "-l", layoutFile, "-i", "bios", "-v", img}, programmers); err != nil {
+		t.Errorf("verifying bios: %v", err)
+	}
+	if err := run([]string{"-p", "mem", "-l", layoutFile, "-i", "me", "-v", img}, programmers); err == nil {
+		t.Errorf("verifying me against the new image succeeded, want an error")
+	}
+
+	// Read the descriptor and ME regions; the rest reads as erased.
+	out := filepath.Join(dir, "out.rom")
+	if err := run([]string{"-p", "mem", "-l", layoutFile, "-i", "fd", "-i", "me", "-r", out}, programmers); err != nil {
+		t.Fatalf("reading fd and me: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(bytes.Repeat([]byte{0x11}, 0x8000), bytes.Repeat([]byte{0xff}, 0x8000)...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("reading fd and me did not return them and an erased bios region")
+	}
+
+	// Regions need an image of the whole chip.
+	small := filepath.Join(dir, "small.rom")
+	if err := os.WriteFile(small, make([]byte, 0x1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-p", "mem", "-l", layoutFile, "-i", "fd", "-w", small}, programmers); err == nil {
+		t.Errorf("writing a partial image to a region succeeded, want an error")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	programmers := map[string]programmerInit{
+		"mem": func(programmerParams) (programmer, error) { return &memProgrammer{data: make([]byte, 0x1000)}, nil },
+	}
+	for _, args := range [][]string{
+		{"-p", "mem"},
+		{"-p", "mem", "-r", "a", "-v", "b"},
+		{"-p", "mem", "-i", "bios", "-r", "a"},
+		{"-p", "mem", "-l", "l", "--ifd", "-r", "a"},
+		{"-p", "mem", "-l", "l", "-i", "bios", "-o", "0x10", "-r", "a"},
+		{"-p", "nope", "-r", "a"},
+	} {
+		if err := run(args, programmers); err == nil {
+			t.Errorf("run(%q) succeeded, want an error", args)
+		}
+	}
+}
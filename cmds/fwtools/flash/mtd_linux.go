@@ -0,0 +1,37 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/flash/mtd"
+)
+
+func openMTD(dev string, params programmerParams) (programmer, error) {
+	if len(params) != 0 {
+		return nil, fmt.Errorf("unrecognized parameters: %v", params)
+	}
+	return mtd.Open(dev)
+}
+
+func init() {
+	supportedProgrammers["linux_mtd"] = func(params programmerParams) (programmer, error) {
+		dev, ok := params["dev"]
+		if !ok {
+			return nil, fmt.Errorf("dev is a required parameter for linux_mtd")
+		}
+		delete(params, "dev")
+		return openMTD(dev, params)
+	}
+	// internal uses the BIOS flash as exposed by the intel-spi driver.
+	supportedProgrammers["internal"] = func(params programmerParams) (programmer, error) {
+		dev, err := mtd.Find(mtd.IntelSPI)
+		if err != nil {
+			return nil, fmt.Errorf("%w (is intel-spi loaded, with writeable=1?)", err)
+		}
+		return openMTD(dev, params)
+	}
+}
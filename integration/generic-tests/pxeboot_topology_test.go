@@ -0,0 +1,93 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !race
+
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/vmtest/qemu"
+	"github.com/hugelgupf/vmtest/scriptvm"
+	"github.com/u-root/mkuimage/uimage"
+	"github.com/u-root/u-root/pkg/testutil"
+	"github.com/u-root/u-root/pkg/vmnet"
+)
+
+// TestPxebootTopology runs a server on two networks, serving DHCPv4 on
+// each and TFTP on both, with a client on each network pxebooting from it.
+func TestPxebootTopology(t *testing.T) {
+	topo := vmnet.New(t)
+
+	serverScript := `
+		ip addr add 192.168.0.1/24 dev eth0
+		ip addr add 192.168.1.1/24 dev eth1
+		ip link set eth0 up
+		ip link set eth1 up
+		pxeserver -interface eth1 -ip 192.168.1.1 -your-ip 192.168.1.2/24 &
+		pxeserver -interface eth0 -tftp-dir=/pxeroot
+	`
+	serverVM := scriptvm.Start(t, "pxe_server", serverScript,
+		scriptvm.WithUimage(
+			uimage.WithBusyboxCommands(
+				"github.com/u-root/u-root/cmds/core/ip",
+				"github.com/u-root/u-root/cmds/exp/pxeserver",
+			),
+			uimage.WithFiles("./testdata/pxe:pxeroot"),
+		),
+		scriptvm.WithQEMUFn(
+			qemu.WithVMTimeout(90*time.Second),
+			topo.NIC("server", "lan-a"),
+			topo.NIC("server", "lan-b"),
+			qemu.VirtioRandom(),
+		),
+	)
+
+	var clientVMs []*qemu.VM
+	for _, c := range []struct {
+		network string
+		server  string
+	}{
+		{network: "lan-a", server: "192.168.0.1"},
+		{network: "lan-b", server: "192.168.1.1"},
+	} {
+		network, server := c.network, c.server
+		name := "pxe_client_" + network
+		vm := scriptvm.Start(t, name, "pxeboot --no-exec -v",
+			scriptvm.WithUimage(
+				uimage.WithCoveredCommands(
+					"github.com/u-root/u-root/cmds/boot/pxeboot",
+				),
+			),
+			scriptvm.WithQEMUFn(
+				qemu.WithVMTimeout(90*time.Second),
+				topo.NIC(name, network),
+				qemu.VirtioRandom(),
+			),
+		)
+		if _, err := vm.Console.ExpectString("Got DHCPv4 lease on eth0:"); err != nil {
+			t.Errorf("%s %s lease: %v", testutil.NowLog(), network, err)
+		}
+		if _, err := vm.Console.ExpectString("Boot URI: tftp://" + server + "/pxelinux.0"); err != nil {
+			t.Errorf("%s %s boot: %v", testutil.NowLog(), network, err)
+		}
+		if _, err := vm.Console.ExpectString("Kernel: tftp://" + server + "/kernel"); err != nil {
+			t.Errorf("%s %s parsed kernel: %v", testutil.NowLog(), network, err)
+		}
+		clientVMs = append(clientVMs, vm)
+	}
+
+	if err := serverVM.Kill(); err != nil {
+		t.Error(err)
+	}
+	serverVM.Wait()
+
+	for _, vm := range clientVMs {
+		if err := vm.Wait(); err != nil {
+			t.Errorf("Client VM Wait: %v", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// descriptions are the names iasl prints for common signatures.
+var descriptions = map[string]string{
+	"APIC": "Multiple APIC Description Table (MADT)",
+	"BERT": "Boot Error Record Table",
+	"BGRT": "Boot Graphics Resource Table",
+	"DBG2": "Debug Port Table type 2",
+	"DMAR": "DMA Remapping Table",
+	"DSDT": "Differentiated System Description Table",
+	"ECDT": "Embedded Controller Boot Resources Table",
+	"EINJ": "Error Injection Table",
+	"ERST": "Error Record Serialization Table",
+	"FACP": "Fixed ACPI Description Table (FADT)",
+	"FACS": "Firmware ACPI Control Structure",
+	"FPDT": "Firmware Performance Data Table",
+	"HEST": "Hardware Error Source Table",
+	"HPET": "High Precision Event Timer Table",
+	"IVRS": "I/O Virtualization Reporting Structure",
+	"MCFG": "Memory Mapped Configuration Table",
+	"MSDM": "Microsoft Data Management Table",
+	"RSDT": "Root System Description Table",
+	"SLIT": "System Locality Distance Information Table",
+	"SPCR": "Serial Port Console Redirection Table",
+	"SRAT": "System Resource Affinity Table",
+	"SSDT": "Secondary System Description Table",
+	"TPM2": "Trusted Platform Module hardware interface Table",
+	"UEFI": "UEFI Boot Optimization Table",
+	"WSMT": "Windows SMM Security Mitigations Table",
+	"XSDT": "Extended System Description Table",
+}
+
+// hasHeader reports whether a table has the common 36-byte header. The FACS
+// only has a signature and a length.
+func hasHeader(t Table) bool {
+	return t.Sig() != "FACS" && len(t.Data()) >= headerLength
+}
+
+// ChecksumValid reports whether the bytes of a table sum up to zero. Tables
+// without a checksum are always valid.
+func ChecksumValid(t Table) bool {
+	if !hasHeader(t) {
+		return true
+	}
+	return gencsum(t.Data()) == 0
+}
+
+// FixChecksum sets the checksum of the table in b, e.g. after it was edited.
+func FixChecksum(b []byte) error {
+	if len(b) < minTableLength {
+		return fmt.Errorf("table is only %d bytes and must be at least %d bytes", len(b), minTableLength)
+	}
+	b[cSUMOffset] = 0
+	b[cSUMOffset] = gencsum(b)
+	return nil
+}
+
+// DumpHeader writes the header of a table in the format of the iasl
+// disassembler: offset, length, name and value of each field.
+func DumpHeader(w io.Writer, t Table) error {
+	b := t.Data()
+	field := func(off, n int, name, format string, v ...any) string {
+		return fmt.Sprintf("[%03Xh %04d %3d] %28s : "+format+"\n", append([]any{off, off, n, name}, v...)...)
+	}
+	var s strings.Builder
+	sig := field(0, 4, "Signature", "%q", t.Sig())
+	if d, ok := descriptions[t.Sig()]; ok {
+		sig = strings.TrimSuffix(sig, "\n") + fmt.Sprintf("    [%s]\n", d)
+	}
+	s.WriteString(sig)
+	s.WriteString(field(lengthOffset, 4, "Table Length", "%08X", binary.LittleEndian.Uint32(b[lengthOffset:])))
+	if hasHeader(t) {
+		s.WriteString(field(8, 1, "Revision", "%02X", t.Revision()))
+		s.WriteString(field(cSUMOffset, 1, "Checksum", "%02X", t.CheckSum()))
+		s.WriteString(field(10, 6, "Oem ID", "%q", b[10:16]))
+		s.WriteString(field(16, 8, "Oem Table ID", "%q", b[16:24]))
+		s.WriteString(field(24, 4, "Oem Revision", "%08X", t.OEMRevision()))
+		s.WriteString(field(28, 4, "Asl Compiler ID", "%q", b[28:32]))
+		s.WriteString(field(32, 4, "Asl Compiler Revision", "%08X", t.CreatorRevision()))
+		if !ChecksumValid(t) {
+			fixed := append([]byte{}, b...)
+			FixChecksum(fixed)
+			fmt.Fprintf(&s, "/* Incorrect checksum, should be %02X */\n", fixed[cSUMOffset])
+		}
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// FileNames returns the names acpixtract uses for tables: the lowercase
+// signature, numbered from 1 if there are several tables with it, and a .dat
+// extension.
+func FileNames(tabs []Table) []string {
+	count := map[string]int{}
+	for _, t := range tabs {
+		count[t.Sig()]++
+	}
+	seen := map[string]int{}
+	var names []string
+	for _, t := range tabs {
+		name := strings.ToLower(t.Sig())
+		if count[t.Sig()] > 1 {
+			seen[t.Sig()]++
+			name = fmt.Sprintf("%s%d", name, seen[t.Sig()])
+		}
+		names = append(names, name+".dat")
+	}
+	return names
+}
+
+// ExtractTables writes each table to its own file in dir, named as by
+// FileNames, and returns the paths of the files.
+func ExtractTables(dir string, tabs []Table) ([]string, error) {
+	var paths []string
+	for i, name := range FileNames(tabs) {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, tabs[i].Data(), 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+// ssdt returns an SSDT with a valid checksum and 4 bytes of AML.
+func ssdt(t *testing.T, oemTableID string) []byte {
+	t.Helper()
+	b := make([]byte, headerLength+4)
+	copy(b, "SSDT")
+	b[lengthOffset] = byte(len(b))
+	b[8] = 2
+	copy(b[10:], "UROOT ")
+	copy(b[16:], oemTableID)
+	b[24] = 1
+	copy(b[28:], "INTL")
+	b[32] = 0x20
+	copy(b[headerLength:], []byte{0x10, 0x02, 0x5c, 0x00})
+	if err := FixChecksum(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDumpHeader(t *testing.T) {
+	b := ssdt(t, "Override")
+	tabs, err := NewRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ChecksumValid(tabs[0]) {
+		t.Errorf("ChecksumValid = false, want true")
+	}
+	var out strings.Builder
+	if err := DumpHeader(&out, tabs[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := `[000h 0000   4]                    Signature : "SSDT"    [Secondary System Description Table]
+[004h 0004   4]                 Table Length : 00000028
+[008h 0008   1]                     Revision : 02
+[009h 0009   1]                     Checksum : D9
+[00Ah 0010   6]                       Oem ID : "UROOT "
+[010h 0016   8]                 Oem Table ID : "Override"
+[018h 0024   4]                 Oem Revision : 00000001
+[01Ch 0028   4]              Asl Compiler ID : "INTL"
+[020h 0032   4]        Asl Compiler Revision : 00000020
+`
+	if out.String() != want {
+		t.Errorf("DumpHeader =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	b[headerLength]++
+	out.Reset()
+	if err := DumpHeader(&out, tabs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "/* Incorrect checksum, should be D8 */\n") {
+		t.Errorf("DumpHeader of a modified table = \n%s\nwant a checksum warning", out.String())
+	}
+}
+
+func TestExtractTables(t *testing.T) {
+	var blob []byte
+	for _, id := range []string{"One", "Two"} {
+		blob = append(blob, ssdt(t, id)...)
+	}
+	dsdt := ssdt(t, "DSDT")
+	copy(dsdt, "DSDT")
+	blob = append(blob, dsdt...)
+	tabs, err := NewRaw(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	paths, err := ExtractTables(dir, tabs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "ssdt1.dat"), filepath.Join(dir, "ssdt2.dat"), filepath.Join(dir, "dsdt.dat")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("ExtractTables = %q, want %q", paths, want)
+	}
+	for i, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, tabs[i].Data()) {
+			t.Errorf("%s does not contain table %d", p, i)
+		}
+	}
+}
+
+func TestWriteOverride(t *testing.T) {
+	good, err := NewRaw(ssdt(t, "Override"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteOverride(&buf, good...); err != nil {
+		t.Fatalf("WriteOverride = %v", err)
+	}
+	recs, err := cpio.ReadAllRecords(cpio.Newc.Reader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range recs {
+		names = append(names, r.Name)
+	}
+	wantNames := []string{"kernel", "kernel/firmware", "kernel/firmware/acpi", "kernel/firmware/acpi/ssdt.aml"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("archive contains %q, want %q", names, wantNames)
+	}
+
+	bad := ssdt(t, "Override")
+	bad[headerLength]++
+	tabs, err := NewRaw(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteOverride(&buf, tabs...); !errors.Is(err, ErrBadChecksum) {
+		t.Errorf("WriteOverride(bad checksum) = %v, want %v", err, ErrBadChecksum)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+// OverrideDir is the directory in an initrd that Linux loads ACPI tables
+// from, with CONFIG_ACPI_TABLE_UPGRADE.
+const OverrideDir = "kernel/firmware/acpi"
+
+// ErrBadChecksum is returned for tables whose checksum is wrong.
+var ErrBadChecksum = errors.New("bad checksum")
+
+// WriteOverride writes a cpio archive that overrides or adds tables when it
+// is passed to Linux as the first, uncompressed, initrd, e.g. to kexec.
+//
+// A table replaces a firmware table with the same signature and OEM table ID
+// if its OEM revision is higher. Other tables, e.g. an SSDT with a new OEM
+// table ID, are added.
+func WriteOverride(w io.Writer, tabs ...Table) error {
+	var recs []cpio.Record
+	for i, name := range FileNames(tabs) {
+		t := tabs[i]
+		if !hasHeader(t) || t.Len() != uint32(len(t.Data())) {
+			return fmt.Errorf("%s: not a complete table", t.Sig())
+		}
+		if !ChecksumValid(t) {
+			return fmt.Errorf("%s: %w", t.Sig(), ErrBadChecksum)
+		}
+		name = strings.TrimSuffix(name, ".dat") + ".aml"
+		recs = append(recs, cpio.StaticRecord(t.Data(), cpio.Info{
+			Name: path.Join(OverrideDir, name),
+			Mode: cpio.S_IFREG | 0o444,
+		}))
+	}
+	rw := cpio.NewDedupWriter(cpio.Newc.Writer(w))
+	if err := cpio.WriteRecordsAndDirs(rw, recs); err != nil {
+		return err
+	}
+	return cpio.WriteTrailer(rw)
+}
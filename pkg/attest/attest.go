@@ -0,0 +1,167 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package attest implements the client side of remote attestation at boot.
+//
+// The client asks the attestation service for a nonce, quotes PCRs with
+// the TPM's attestation key and that nonce, and posts the quote, the key
+// and the firmware event log to the service. The service replays the log,
+// checks the quote with tss.VerifyQuote and decides whether the machine
+// may continue booting:
+//
+//	POST URL/challenge  -> {"nonce": "..."}
+//	POST URL/evidence   {"nonce": "...", "ak_public": ..., "quote": ..., "event_log": ...}
+//	                    -> {"allow": true|false, "reason": "...", "data": "..."}
+//
+// Binary values are base64 encoded, as encoding/json does for []byte.
+package attest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// DefaultPCRs are the PCRs quoted by default: firmware code, option ROMs,
+// the boot loader and the Secure Boot policy.
+var DefaultPCRs = []int{0, 2, 4, 7}
+
+// ErrDenied is returned when the attestation service does not allow the
+// machine to boot.
+var ErrDenied = errors.New("attestation denied")
+
+// TPM is the part of tss.TPM used for attestation.
+type TPM interface {
+	AKPublic(ownerPassword string) ([]byte, error)
+	Quote(ownerPassword string, nonce []byte, pcrs []int) (*tss.Quote, error)
+	MeasurementLog() ([]byte, error)
+}
+
+// Challenge is the response of the service to a challenge request.
+type Challenge struct {
+	Nonce []byte `json:"nonce"`
+}
+
+// Evidence is what the client posts to the service.
+type Evidence struct {
+	Nonce    []byte     `json:"nonce"`
+	AKPublic []byte     `json:"ak_public"`
+	Quote    *tss.Quote `json:"quote"`
+	EventLog []byte     `json:"event_log"`
+}
+
+// Verdict is the decision of the service on the evidence.
+type Verdict struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+	// Data is released by the service to allowed machines, e.g. a disk
+	// key.
+	Data []byte `json:"data,omitempty"`
+}
+
+// Client is an attestation client.
+type Client struct {
+	// URL is the base URL of the attestation service.
+	URL *url.URL
+
+	// PCRs are quoted; DefaultPCRs if nil.
+	PCRs []int
+
+	// OwnerPassword is the TPM's owner hierarchy password, which the
+	// attestation key is created under.
+	OwnerPassword string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the attestation service at serviceURL,
+// which must use HTTPS unless it is on localhost.
+func NewClient(serviceURL string) (*Client, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")) {
+		return nil, fmt.Errorf("attestation service %q: scheme must be https", serviceURL)
+	}
+	return &Client{URL: u}, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body, v any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u := c.URL.JoinPath(path).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", u, resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("POST %s: %w", u, err)
+	}
+	return nil
+}
+
+// Attest runs one attestation with the TPM. It returns the verdict of the
+// service, or an error wrapping ErrDenied if the machine was not allowed.
+func (c *Client) Attest(ctx context.Context, t TPM) (*Verdict, error) {
+	pcrs := c.PCRs
+	if pcrs == nil {
+		pcrs = DefaultPCRs
+	}
+	ak, err := t.AKPublic(c.OwnerPassword)
+	if err != nil {
+		return nil, err
+	}
+	// A missing event log is up to the service to judge.
+	log, _ := t.MeasurementLog()
+
+	var ch Challenge
+	if err := c.post(ctx, "challenge", struct{}{}, &ch); err != nil {
+		return nil, err
+	}
+	if len(ch.Nonce) == 0 {
+		return nil, errors.New("attestation service sent no nonce")
+	}
+	q, err := t.Quote(c.OwnerPassword, ch.Nonce, pcrs)
+	if err != nil {
+		return nil, err
+	}
+	var v Verdict
+	if err := c.post(ctx, "evidence", &Evidence{
+		Nonce:    ch.Nonce,
+		AKPublic: ak,
+		Quote:    q,
+		EventLog: log,
+	}, &v); err != nil {
+		return nil, err
+	}
+	if !v.Allow {
+		return &v, fmt.Errorf("%w: %s", ErrDenied, v.Reason)
+	}
+	return &v, nil
+}
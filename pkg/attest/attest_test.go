@@ -0,0 +1,109 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package attest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// fakeTPM "signs" quotes by putting the nonce into the attestation data.
+type fakeTPM struct {
+	pcrs []int
+}
+
+func (f *fakeTPM) AKPublic(string) ([]byte, error) { return []byte("ak"), nil }
+
+func (f *fakeTPM) MeasurementLog() ([]byte, error) { return []byte("log"), nil }
+
+func (f *fakeTPM) Quote(_ string, nonce []byte, pcrs []int) (*tss.Quote, error) {
+	f.pcrs = pcrs
+	return &tss.Quote{PCRs: pcrs, Attest: nonce}, nil
+}
+
+// verifier allows machines whose event log is "log".
+func verifier(t *testing.T) *httptest.Server {
+	nonce := []byte("nonce-1")
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/attest/challenge":
+			json.NewEncoder(w).Encode(Challenge{Nonce: nonce})
+		case "/attest/evidence":
+			var e Evidence
+			if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !bytes.Equal(e.Nonce, nonce) || !bytes.Equal(e.Quote.Attest, nonce) || string(e.AKPublic) != "ak" {
+				http.Error(w, "bad evidence", http.StatusBadRequest)
+				return
+			}
+			if string(e.EventLog) != "log" {
+				json.NewEncoder(w).Encode(Verdict{Reason: "unknown firmware"})
+				return
+			}
+			json.NewEncoder(w).Encode(Verdict{Allow: true, Data: []byte("key")})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestAttest(t *testing.T) {
+	ctx := context.Background()
+	s := verifier(t)
+	c, err := NewClient(s.URL + "/attest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeTPM{}
+	v, err := c.Attest(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Allow || string(v.Data) != "key" {
+		t.Errorf("Attest = %+v, want allowed with data", v)
+	}
+	if !slices.Equal(f.pcrs, DefaultPCRs) {
+		t.Errorf("quoted PCRs %v, want %v", f.pcrs, DefaultPCRs)
+	}
+
+	c.PCRs = []int{7}
+	if _, err := c.Attest(ctx, denyTPM{f}); !errors.Is(err, ErrDenied) {
+		t.Errorf("Attest with other firmware = %v, want %v", err, ErrDenied)
+	}
+	if !slices.Equal(f.pcrs, []int{7}) {
+		t.Errorf("quoted PCRs %v, want [7]", f.pcrs)
+	}
+
+	c.URL = c.URL.JoinPath("nope")
+	if _, err := c.Attest(ctx, f); err == nil || errors.Is(err, ErrDenied) {
+		t.Errorf("Attest at wrong URL = %v, want HTTP error", err)
+	}
+
+	for _, u := range []string{"http://attest.example.com", "ftp://localhost", "://"} {
+		if _, err := NewClient(u); err == nil {
+			t.Errorf("NewClient(%q) = nil, want error", u)
+		}
+	}
+}
+
+// denyTPM has an event log the verifier does not know.
+type denyTPM struct {
+	*fakeTPM
+}
+
+func (denyTPM) MeasurementLog() ([]byte, error) { return []byte("other"), nil }
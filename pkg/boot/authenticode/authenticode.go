@@ -0,0 +1,394 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package authenticode verifies Authenticode signatures of PE/COFF images,
+// such as kernels with an EFI stub signed with sbsign for Secure Boot.
+package authenticode
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	// Register the hashes Authenticode signatures may use.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Errors returned by Verify.
+var (
+	ErrNotPE        = errors.New("not a PE/COFF image")
+	ErrUnsigned     = errors.New("image has no Authenticode signature")
+	ErrDigest       = errors.New("image digest does not match its signature")
+	ErrBadSignature = errors.New("bad Authenticode signature")
+	ErrUntrusted    = errors.New("image is not signed by a trusted certificate")
+)
+
+var (
+	oidSignedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirect    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidContentType    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA256         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	winCertRevision   = uint16(0x0200)
+	winCertPKCSSigned = uint16(0x0002)
+)
+
+// hashes are the digest algorithms accepted in signatures. SHA-1 is not.
+var hashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{oidSHA256, crypto.SHA256},
+	{oidSHA384, crypto.SHA384},
+	{oidSHA512, crypto.SHA512},
+}
+
+func hashFor(alg pkix.AlgorithmIdentifier) (crypto.Hash, error) {
+	for _, h := range hashes {
+		if h.oid.Equal(alg.Algorithm) {
+			return h.hash, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unsupported digest algorithm %v", ErrBadSignature, alg.Algorithm)
+}
+
+// PKCS #7 and Authenticode structures, RFC 2315 and the Windows
+// Authenticode PE Signature Format.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerial           issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+// image locates the parts of a PE/COFF image the Authenticode digest skips.
+type image struct {
+	size        int64
+	checksumOff int64
+	certDirOff  int64
+	certOff     int64
+	certSize    int64
+}
+
+func parseImage(r io.ReaderAt, size int64) (*image, error) {
+	le := binary.LittleEndian
+	read := func(off int64, n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := r.ReadAt(b, off); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotPE, err)
+		}
+		return b, nil
+	}
+
+	dos, err := read(0, 64)
+	if err != nil {
+		return nil, err
+	}
+	if string(dos[:2]) != "MZ" {
+		return nil, ErrNotPE
+	}
+	pe := int64(le.Uint32(dos[0x3c:]))
+	hdr, err := read(pe, 4+20+2)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr[:4]) != "PE\x00\x00" {
+		return nil, ErrNotPE
+	}
+	opt := pe + 24
+	var dirs int64
+	switch le.Uint16(hdr[24:]) {
+	case 0x10b:
+		dirs = opt + 96
+	case 0x20b:
+		dirs = opt + 112
+	default:
+		return nil, fmt.Errorf("%w: unknown optional header magic", ErrNotPE)
+	}
+	n, err := read(dirs-4, 4)
+	if err != nil {
+		return nil, err
+	}
+	if le.Uint32(n) < 5 {
+		return nil, ErrUnsigned
+	}
+
+	img := &image{
+		size:        size,
+		checksumOff: opt + 64,
+		// The certificate table is data directory 4.
+		certDirOff: dirs + 4*8,
+	}
+	dir, err := read(img.certDirOff, 8)
+	if err != nil {
+		return nil, err
+	}
+	// Unlike other data directories, the certificate table's address is a
+	// file offset.
+	img.certOff, img.certSize = int64(le.Uint32(dir)), int64(le.Uint32(dir[4:]))
+	if img.certSize == 0 {
+		return nil, ErrUnsigned
+	}
+	if img.certOff < img.certDirOff+8 || img.certOff+img.certSize > size {
+		return nil, fmt.Errorf("%w: certificate table out of bounds", ErrNotPE)
+	}
+	return img, nil
+}
+
+// digest hashes the image except its checksum, the certificate table
+// directory entry and the certificate table.
+func (img *image) digest(r io.ReaderAt, h crypto.Hash) ([]byte, error) {
+	d := h.New()
+	for _, s := range [][2]int64{
+		{0, img.checksumOff},
+		{img.checksumOff + 4, img.certDirOff},
+		{img.certDirOff + 8, img.certOff},
+		{img.certOff + img.certSize, img.size},
+	} {
+		if _, err := io.Copy(d, io.NewSectionReader(r, s[0], s[1]-s[0])); err != nil {
+			return nil, err
+		}
+	}
+	return d.Sum(nil), nil
+}
+
+// Signatures returns the PKCS #7 signatures in the certificate table of the
+// PE/COFF image r of size bytes.
+func Signatures(r io.ReaderAt, size int64) ([][]byte, error) {
+	img, err := parseImage(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return img.signatures(r)
+}
+
+func (img *image) signatures(r io.ReaderAt) ([][]byte, error) {
+	table := make([]byte, img.certSize)
+	if _, err := r.ReadAt(table, img.certOff); err != nil {
+		return nil, err
+	}
+	var sigs [][]byte
+	for len(table) >= 8 {
+		// WIN_CERTIFICATE entries are 8 byte aligned.
+		l := binary.LittleEndian.Uint32(table)
+		if l < 8 || int64(l) > int64(len(table)) {
+			return nil, fmt.Errorf("%w: bad certificate table entry", ErrNotPE)
+		}
+		if binary.LittleEndian.Uint16(table[4:]) == winCertRevision && binary.LittleEndian.Uint16(table[6:]) == winCertPKCSSigned {
+			sigs = append(sigs, table[8:l])
+		}
+		table = table[min(len(table), int((l+7)&^7)):]
+	}
+	if len(sigs) == 0 {
+		return nil, ErrUnsigned
+	}
+	return sigs, nil
+}
+
+// Verify checks that the PE/COFF image r of size bytes carries an
+// Authenticode signature of a certificate in roots or one chaining to them.
+//
+// As in UEFI Secure Boot, certificate validity periods are not enforced.
+func Verify(r io.ReaderAt, size int64, roots []*x509.Certificate) error {
+	img, err := parseImage(r, size)
+	if err != nil {
+		return err
+	}
+	sigs, err := img.signatures(r)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	for _, c := range roots {
+		pool.AddCert(c)
+	}
+	var errs []error
+	for _, sig := range sigs {
+		err := verifySignature(r, img, sig, pool)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func verifySignature(r io.ReaderAt, img *image, sig []byte, roots *x509.CertPool) error {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return fmt.Errorf("%w: not PKCS #7 signed data", ErrBadSignature)
+	}
+	var sd signedData
+	// RawValues keep their explicit tags, so unwrap them by hand.
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidSpcIndirect) || len(sd.SignerInfos) != 1 {
+		return fmt.Errorf("%w: not an Authenticode signature", ErrBadSignature)
+	}
+	// The signed content is the SpcIndirectDataContent without its tag
+	// and length.
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	var spc spcIndirectDataContent
+	if _, err := asn1.Unmarshal(content.FullBytes, &spc); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+
+	h, err := hashFor(spc.MessageDigest.Algorithm)
+	if err != nil {
+		return err
+	}
+	digest, err := img.digest(r, h)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, spc.MessageDigest.Digest) {
+		return ErrDigest
+	}
+
+	si := sd.SignerInfos[0]
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	var signer *x509.Certificate
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, si.IssuerAndSerial.Issuer.FullBytes) && c.SerialNumber.Cmp(si.IssuerAndSerial.SerialNumber) == 0 {
+			signer = c
+			break
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("%w: signer certificate missing", ErrBadSignature)
+	}
+	if err := checkSignerInfo(&si, content.Bytes, signer); err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+	if _, err := signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signer.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrusted, err)
+	}
+	return nil
+}
+
+// checkSignerInfo checks that signer signed content.
+func checkSignerInfo(si *signerInfo, content []byte, signer *x509.Certificate) error {
+	h, err := hashFor(si.DigestAlgorithm)
+	if err != nil {
+		return err
+	}
+	d := h.New()
+	d.Write(content)
+	contentDigest := d.Sum(nil)
+
+	signed := content
+	if len(si.AuthenticatedAttributes.FullBytes) > 0 {
+		var found bool
+		for rest := si.AuthenticatedAttributes.Bytes; len(rest) > 0; {
+			var a attribute
+			if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+				return fmt.Errorf("%w: %v", ErrBadSignature, err)
+			}
+			if !a.Type.Equal(oidMessageDigest) || len(a.Values) != 1 {
+				continue
+			}
+			var md []byte
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil {
+				return fmt.Errorf("%w: %v", ErrBadSignature, err)
+			}
+			if !bytes.Equal(md, contentDigest) {
+				return fmt.Errorf("%w: message digest mismatch", ErrBadSignature)
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("%w: no message digest attribute", ErrBadSignature)
+		}
+		// The attributes are signed as a SET, not with their implicit
+		// [0] tag.
+		signed = append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+
+	d = h.New()
+	d.Write(signed)
+	sum := d.Sum(nil)
+	switch pub := signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, h, sum, si.EncryptedDigest)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, sum, si.EncryptedDigest) {
+			err = errors.New("ECDSA verification failed")
+		}
+	default:
+		err = fmt.Errorf("unsupported public key %T", pub)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package authenticode
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const (
+	testOpt     = 0x40 + 24
+	testCertDir = testOpt + 112 + 4*8
+)
+
+// peImage returns a minimal PE32+ image with payload as its body.
+func peImage(payload []byte) []byte {
+	le := binary.LittleEndian
+	b := make([]byte, testOpt+112+16*8)
+	copy(b, "MZ")
+	le.PutUint32(b[0x3c:], 0x40)
+	copy(b[0x40:], "PE\x00\x00")
+	le.PutUint16(b[0x40+4+16:], 112+16*8)
+	le.PutUint16(b[testOpt:], 0x20b)
+	le.PutUint32(b[testOpt+108:], 16)
+	return append(b, payload...)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCert(t *testing.T, name string, parent *testCA) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		// Expired, which Secure Boot does not care about.
+		NotAfter:              time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func mustMarshal(t *testing.T, v any, params string) []byte {
+	t.Helper()
+	b, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// explicit returns b with an explicit [0] tag. asn1.Marshal writes
+// FullBytes as they are, whatever the field's tag.
+func explicit(t *testing.T, b []byte) asn1.RawValue {
+	t.Helper()
+	return asn1.RawValue{FullBytes: mustMarshal(t, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b}, "")}
+}
+
+// sign appends an Authenticode signature of signer to img.
+func sign(t *testing.T, img []byte, signer *testCA, chain ...*x509.Certificate) []byte {
+	t.Helper()
+	for len(img)%8 != 0 {
+		img = append(img, 0)
+	}
+	h := sha256.New()
+	h.Write(img[:testOpt+64])
+	h.Write(img[testOpt+68 : testCertDir])
+	h.Write(img[testCertDir+8:])
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	peImageData := mustMarshal(t, struct{ Type asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}}, "")
+	spc := mustMarshal(t, spcIndirectDataContent{
+		Data:          asn1.RawValue{FullBytes: peImageData},
+		MessageDigest: digestInfo{Algorithm: sha256Alg, Digest: h.Sum(nil)},
+	}, "")
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(spc, &content); err != nil {
+		t.Fatal(err)
+	}
+	contentDigest := sha256.Sum256(content.Bytes)
+
+	attrs := []attribute{
+		{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: mustMarshal(t, oidSpcIndirect, "")}}},
+		{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: mustMarshal(t, contentDigest[:], "")}}},
+	}
+	attrSet := mustMarshal(t, attrs, "set")
+	attrDigest := sha256.Sum256(attrSet)
+	sig, err := signer.key.Sign(rand.Reader, attrDigest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var certs []byte
+	for _, c := range append([]*x509.Certificate{signer.cert}, chain...) {
+		certs = append(certs, c.Raw...)
+	}
+	sd := mustMarshal(t, signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo:      contentInfo{ContentType: oidSpcIndirect, Content: explicit(t, spc)},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:                   1,
+			IssuerAndSerial:           issuerAndSerial{Issuer: asn1.RawValue{FullBytes: signer.cert.RawIssuer}, SerialNumber: signer.cert.SerialNumber},
+			DigestAlgorithm:           sha256Alg,
+			AuthenticatedAttributes:   asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrSet[2:]},
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			EncryptedDigest:           sig,
+		}},
+	}, "")
+	pkcs7 := mustMarshal(t, contentInfo{ContentType: oidSignedData, Content: explicit(t, sd)}, "")
+
+	le := binary.LittleEndian
+	entry := make([]byte, 8, 8+len(pkcs7)+7)
+	le.PutUint32(entry, uint32(8+len(pkcs7)))
+	le.PutUint16(entry[4:], winCertRevision)
+	le.PutUint16(entry[6:], winCertPKCSSigned)
+	entry = append(entry, pkcs7...)
+	for len(entry)%8 != 0 {
+		entry = append(entry, 0)
+	}
+	le.PutUint32(img[testCertDir:], uint32(len(img)))
+	le.PutUint32(img[testCertDir+4:], uint32(len(entry)))
+	return append(img, entry...)
+}
+
+func TestVerify(t *testing.T) {
+	root := newCert(t, "db", nil)
+	leaf := newCert(t, "kernel signer", root)
+	other := newCert(t, "other", nil)
+
+	payload := bytes.Repeat([]byte("kernel"), 1000)
+	verify := func(img []byte, roots ...*x509.Certificate) error {
+		return Verify(bytes.NewReader(img), int64(len(img)), roots)
+	}
+
+	direct := sign(t, peImage(payload), root)
+	if err := verify(direct, root.cert); err != nil {
+		t.Errorf("Verify(signed by root) = %v", err)
+	}
+	chained := sign(t, peImage(payload), leaf)
+	if err := verify(chained, root.cert); err != nil {
+		t.Errorf("Verify(signed by leaf) = %v", err)
+	}
+	if err := verify(chained, other.cert); !errors.Is(err, ErrUntrusted) {
+		t.Errorf("Verify(other root) = %v, want %v", err, ErrUntrusted)
+	}
+	if sigs, err := Signatures(bytes.NewReader(chained), int64(len(chained))); err != nil || len(sigs) != 1 {
+		t.Errorf("Signatures() = %d signatures, %v; want 1", len(sigs), err)
+	}
+
+	tampered := bytes.Clone(chained)
+	tampered[len(peImage(nil))+5] ^= 1
+	if err := verify(tampered, root.cert); !errors.Is(err, ErrDigest) {
+		t.Errorf("Verify(tampered) = %v, want %v", err, ErrDigest)
+	}
+	// The checksum is not covered by the signature.
+	checksum := bytes.Clone(chained)
+	checksum[testOpt+64] = 0x42
+	if err := verify(checksum, root.cert); err != nil {
+		t.Errorf("Verify(new checksum) = %v", err)
+	}
+
+	if err := verify(peImage(payload), root.cert); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Verify(unsigned) = %v, want %v", err, ErrUnsigned)
+	}
+	if err := verify(payload, root.cert); !errors.Is(err, ErrNotPE) {
+		t.Errorf("Verify(not PE) = %v, want %v", err, ErrNotPE)
+	}
+}
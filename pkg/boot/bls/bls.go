@@ -4,9 +4,10 @@
 
 // Package bls parses systemd Boot Loader Spec config files.
 //
-// See spec at https://systemd.io/BOOT_LOADER_SPECIFICATION. Only Type #1 BLS
-// entries are supported at the moment, while Type #2 EFI entries are left
-// unimplemented awaiting EFI boot support in u-root/LinuxBoot.
+// See spec at https://systemd.io/BOOT_LOADER_SPECIFICATION. Type #1 BLS
+// entries in loader/entries are supported, as are Type #2 entries: unified
+// kernel images (UKIs) in EFI/Linux, whose kernel, initrd and command line are
+// extracted from their PE sections and kexec'd.
 //
 // This package also supports the systemd-boot loader.conf as described in
 // https://www.freedesktop.org/software/systemd/man/loader.conf.html. Only the
@@ -16,6 +17,7 @@ package bls
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -47,9 +49,10 @@ func ScanBLSEntries(l ulog.Logger, fsRoot string, variables map[string]string, g
 		// Try blsEntriesDir2
 		entriesDir = filepath.Join(fsRoot, blsEntriesDir2)
 		files, err = filepath.Glob(filepath.Join(entriesDir, "*.conf"))
-		if err != nil || len(files) == 0 {
-			return nil, fmt.Errorf("no BootLoaderSpec entries found: %w", err)
-		}
+	}
+	ukis, _ := filepath.Glob(filepath.Join(fsRoot, ukiDir, "*.efi"))
+	if len(files) == 0 && len(ukis) == 0 {
+		return nil, fmt.Errorf("no BootLoaderSpec entries found: %w", err)
 	}
 
 	// loader.conf is not in the real spec; it's an implementation detail
@@ -82,6 +85,18 @@ func ScanBLSEntries(l ulog.Logger, fsRoot string, variables map[string]string, g
 		imgs[identifier] = img
 	}
 
+	// Type #2 entries are identified by their file name, including the
+	// .efi suffix.
+	for _, f := range ukis {
+		identifier := filepath.Base(f)
+		img, err := parseUKIEntry(f, identifier == grubDefaultSavedEntry)
+		if err != nil {
+			l.Printf("BootLoaderSpec skipping unified kernel image %s: %v", f, err)
+			continue
+		}
+		imgs[identifier] = img
+	}
+
 	return sortImages(loaderConf, imgs), nil
 }
 
@@ -101,7 +116,7 @@ func sortImages(loaderConf map[string]string, imgs map[string]boot.OSImage) []bo
 	// Find default and non-default identifiers.
 	for ident := range imgs {
 		ok, err := filepath.Match(pattern, ident)
-		if err != nil && ok {
+		if err == nil && ok {
 			defaultIdents = append(defaultIdents, ident)
 		} else {
 			otherIdents = append(otherIdents, ident)
@@ -139,11 +154,16 @@ func parseConf(entryPath string) (map[string]string, error) {
 		}
 		line = strings.TrimSpace(line)
 
-		sline := strings.SplitN(line, " ", 2)
-		if len(sline) != 2 {
+		key, val, ok := strings.Cut(line, " ")
+		if !ok {
 			continue
 		}
-		vals[sline[0]] = strings.TrimSpace(sline[1])
+		val = strings.TrimSpace(val)
+		// initrd and options may appear more than once.
+		if prev, ok := vals[key]; ok && (key == "initrd" || key == "options") {
+			val = prev + " " + val
+		}
+		vals[key] = val
 	}
 	return vals, nil
 }
@@ -184,21 +204,33 @@ func parseLinuxImage(vals map[string]string, fsRoot string, variables map[string
 			}
 			linux.Kernel = f
 
-		// TODO: initrd may be specified more than once.
-		// TODO: For now only process the first token, the rest are ignored, e.g. '$tuned_initrd'.
+		// Variables, e.g. '$tuned_initrd', are ignored.
 		case "initrd":
-			tokens = strings.Split(val, " ")
-			f, err := os.Open(filePath(fsRoot, tokens[0]))
-			if err != nil {
-				return nil, err
+			var initrds []io.ReaderAt
+			for _, name := range strings.Fields(val) {
+				if strings.HasPrefix(name, "$") {
+					continue
+				}
+				f, err := os.Open(filePath(fsRoot, name))
+				if err != nil {
+					return nil, err
+				}
+				initrds = append(initrds, f)
+			}
+			switch len(initrds) {
+			case 0:
+			case 1:
+				linux.Initrd = initrds[0]
+			default:
+				linux.Initrd = boot.CatInitrds(initrds...)
 			}
-			linux.Initrd = f
 
 		case "devicetree":
-			// Explicitly return an error rather than ignore this,
-			// because the intended kernel likely won't boot
-			// correctly if we silently ignore this attribute.
-			return nil, fmt.Errorf("devicetree attribute unsupported for Linux entries")
+			f, err := os.Open(filePath(fsRoot, val))
+			if err != nil {
+				return nil, err
+			}
+			linux.DTB = f
 
 		// options may appear more than once.
 		case "options":
@@ -248,21 +280,22 @@ func parseLinuxImage(vals map[string]string, fsRoot string, variables map[string
 	// If both title and version were empty, so will this.
 	linux.Name = strings.Join(name, " ")
 	linux.Cmdline = strings.Join(cmdlines, " ")
-	// If this is the default option, increase the BootRank by 1
-	// when os.LookupEnv("BLS_BOOT_RANK") doesn't exist so it's not affected.
+	linux.BootRank = bootRank(grubDefaultFlag)
+	return linux, nil
+}
+
+// bootRank returns the rank of BLS entries. If this is the default option,
+// increase the BootRank by 1 when os.LookupEnv("BLS_BOOT_RANK") doesn't
+// exist so it's not affected.
+func bootRank(grubDefaultFlag bool) int {
 	if val, exist := os.LookupEnv("BLS_BOOT_RANK"); exist {
-		if rank, err := strconv.Atoi(val); err == nil {
-			linux.BootRank = rank
-		}
-	} else {
-		if grubDefaultFlag {
-			linux.BootRank = blsDefaultRank + 1
-		} else {
-			linux.BootRank = blsDefaultRank
-		}
+		rank, _ := strconv.Atoi(val)
+		return rank
 	}
-
-	return linux, nil
+	if grubDefaultFlag {
+		return blsDefaultRank + 1
+	}
+	return blsDefaultRank
 }
 
 // parseBLSEntry takes a Type #1 BLS entry and the directory of entries, and
@@ -281,7 +314,7 @@ func parseBLSEntry(entryPath, fsRoot string, variables map[string]string, grubDe
 	} else if _, ok := vals["multiboot"]; ok {
 		err = fmt.Errorf("multiboot not yet supported")
 	} else if _, ok := vals["efi"]; ok {
-		err = fmt.Errorf("EFI not yet supported")
+		img, err = parseEFIEntry(vals, fsRoot, grubDefaultFlag)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("error parsing config in %s: %w", entryPath, err)
@@ -0,0 +1,194 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"bufio"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/boot"
+)
+
+// ukiDir is where Type #2 entries live, relative to the file system root.
+const ukiDir = "EFI/Linux"
+
+// ErrNotUKI is returned for PE images without a kernel section.
+var ErrNotUKI = errors.New("not a unified kernel image: no .linux section")
+
+// uki is the content of a unified kernel image. See
+// https://uapi-group.org/specifications/specs/unified_kernel_image/.
+type uki struct {
+	kernel  io.ReaderAt
+	initrd  io.ReaderAt
+	dtb     io.ReaderAt
+	cmdline string
+	// osRelease is the embedded os-release file.
+	osRelease map[string]string
+	uname     string
+}
+
+// section returns the content of s in f, without the padding to the file
+// alignment.
+func section(f io.ReaderAt, s *pe.Section) *io.SectionReader {
+	size := s.Size
+	if s.VirtualSize != 0 && s.VirtualSize < size {
+		size = s.VirtualSize
+	}
+	return io.NewSectionReader(f, int64(s.Offset), int64(size))
+}
+
+// readSection returns the content of s in f as a string, without trailing
+// NULs and white space.
+func readSection(f io.ReaderAt, s *pe.Section) (string, error) {
+	b, err := io.ReadAll(section(f, s))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\x00 \t\n"), nil
+}
+
+// parseOSRelease parses an os-release file.
+func parseOSRelease(s string) map[string]string {
+	vals := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		vals[key] = strings.Trim(val, `"'`)
+	}
+	return vals
+}
+
+// parseUKI reads the sections of the unified kernel image r. The kernel,
+// initrd and device tree refer to r, which must stay open.
+func parseUKI(r io.ReaderAt) (*uki, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	u := &uki{}
+	for _, s := range f.Sections {
+		var err error
+		switch s.Name {
+		case ".linux":
+			u.kernel = section(r, s)
+		case ".initrd":
+			u.initrd = section(r, s)
+		case ".dtb":
+			u.dtb = section(r, s)
+		case ".cmdline":
+			u.cmdline, err = readSection(r, s)
+		case ".uname":
+			u.uname, err = readSection(r, s)
+		case ".osrel":
+			var osrel string
+			osrel, err = readSection(r, s)
+			u.osRelease = parseOSRelease(osrel)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading section %s: %w", s.Name, err)
+		}
+	}
+	if u.kernel == nil {
+		return nil, ErrNotUKI
+	}
+	return u, nil
+}
+
+// name returns the name systemd-boot shows for u.
+func (u *uki) name() string {
+	var name []string
+	for _, key := range []string{"PRETTY_NAME", "NAME", "ID"} {
+		if v := u.osRelease[key]; v != "" {
+			name = append(name, v)
+			break
+		}
+	}
+	if u.uname != "" {
+		name = append(name, u.uname)
+	} else if v := u.osRelease["VERSION_ID"]; v != "" {
+		name = append(name, v)
+	}
+	return strings.Join(name, " ")
+}
+
+func (u *uki) linuxImage(grubDefaultFlag bool) *boot.LinuxImage {
+	return &boot.LinuxImage{
+		Name:     u.name(),
+		Kernel:   u.kernel,
+		Initrd:   u.initrd,
+		DTB:      u.dtb,
+		Cmdline:  u.cmdline,
+		BootRank: bootRank(grubDefaultFlag),
+	}
+}
+
+// ParseUKI returns the Linux image in the unified kernel image r, e.g. one
+// fetched by HTTP boot. The kernel and initrd refer to r.
+func ParseUKI(r io.ReaderAt) (*boot.LinuxImage, error) {
+	u, err := parseUKI(r)
+	if err != nil {
+		return nil, err
+	}
+	return u.linuxImage(false), nil
+}
+
+func openUKI(path string) (*uki, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	u, err := parseUKI(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+// parseUKIEntry takes a Type #2 entry and returns a LinuxImage.
+func parseUKIEntry(path string, grubDefaultFlag bool) (boot.OSImage, error) {
+	u, err := openUKI(path)
+	if err != nil {
+		return nil, err
+	}
+	return u.linuxImage(grubDefaultFlag), nil
+}
+
+// parseEFIEntry takes a Type #1 entry with an efi key and returns a
+// LinuxImage, if the EFI program is a unified kernel image. The entry's
+// title, version and options take precedence over those in the image.
+func parseEFIEntry(vals map[string]string, fsRoot string, grubDefaultFlag bool) (boot.OSImage, error) {
+	u, err := openUKI(filePath(fsRoot, vals["efi"]))
+	if err != nil {
+		return nil, fmt.Errorf("EFI programs other than unified kernel images are not supported: %w", err)
+	}
+	linux := u.linuxImage(grubDefaultFlag)
+	var name []string
+	if title := vals["title"]; title != "" {
+		name = append(name, title)
+	}
+	if version := vals["version"]; version != "" {
+		name = append(name, version)
+	}
+	if len(name) > 0 {
+		linux.Name = strings.Join(name, " ")
+	}
+	if options := vals["options"]; options != "" {
+		linux.Cmdline = options
+	}
+	return linux, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/ulog/ulogtest"
+)
+
+type peSection struct {
+	name string
+	data string
+}
+
+// peImage returns a PE image with the given sections, each padded to 512
+// bytes like the file alignment of real images.
+func peImage(t *testing.T, sections ...peSection) []byte {
+	t.Helper()
+	const align = 512
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	fh := pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64, NumberOfSections: uint16(len(sections))}
+	if err := binary.Write(&b, binary.LittleEndian, fh); err != nil {
+		t.Fatal(err)
+	}
+	offset := uint32(align)
+	for _, s := range sections {
+		sh := pe.SectionHeader32{
+			VirtualSize:      uint32(len(s.data)),
+			SizeOfRawData:    align,
+			PointerToRawData: offset,
+		}
+		copy(sh.Name[:], s.name)
+		if err := binary.Write(&b, binary.LittleEndian, sh); err != nil {
+			t.Fatal(err)
+		}
+		offset += align
+	}
+	for _, s := range sections {
+		b.Write(make([]byte, align-b.Len()%align))
+		b.WriteString(s.data)
+	}
+	b.Write(make([]byte, align-b.Len()%align))
+	return b.Bytes()
+}
+
+func readAll(t *testing.T, r io.ReaderAt) string {
+	t.Helper()
+	if r == nil {
+		return ""
+	}
+	b, err := io.ReadAll(io.NewSectionReader(r, 0, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestParseUKI(t *testing.T) {
+	img := peImage(t,
+		peSection{".osrel", "NAME=Fedora Linux\nPRETTY_NAME=\"Fedora Linux 39 (Server Edition)\"\nVERSION_ID=39\n"},
+		peSection{".cmdline", "root=/dev/sda2 ro\x00"},
+		peSection{".uname", "6.5.6-300.fc39.x86_64\n"},
+		peSection{".linux", "kernel"},
+		peSection{".initrd", "initrd"},
+	)
+	u, err := parseUKI(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	li := u.linuxImage(false)
+	if got, want := li.Name, "Fedora Linux 39 (Server Edition) 6.5.6-300.fc39.x86_64"; got != want {
+		t.Errorf("Name = %q, want %q", got, want)
+	}
+	if got, want := li.Cmdline, "root=/dev/sda2 ro"; got != want {
+		t.Errorf("Cmdline = %q, want %q", got, want)
+	}
+	if got := readAll(t, li.Kernel); got != "kernel" {
+		t.Errorf("Kernel = %q, want %q", got, "kernel")
+	}
+	if got := readAll(t, li.Initrd); got != "initrd" {
+		t.Errorf("Initrd = %q, want %q", got, "initrd")
+	}
+	if li.DTB != nil {
+		t.Errorf("DTB = %v, want nil", li.DTB)
+	}
+
+	if _, err := parseUKI(bytes.NewReader(peImage(t, peSection{".text", "stub"}))); !errors.Is(err, ErrNotUKI) {
+		t.Errorf("parseUKI(systemd-boot) = %v, want %v", err, ErrNotUKI)
+	}
+}
+
+func TestScanUKIEntries(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string][]byte{
+		"EFI/Linux/fedora-6.5.6.efi": peImage(t,
+			peSection{".osrel", "NAME=Fedora\n"},
+			peSection{".cmdline", "console=ttyS0"},
+			peSection{".linux", "kernel 6.5.6"},
+		),
+		"EFI/Linux/fedora-6.6.1.efi": peImage(t,
+			peSection{".osrel", "NAME=Fedora\nVERSION_ID=40\n"},
+			peSection{".linux", "kernel 6.6.1"},
+		),
+		"EFI/Linux/bogus.efi":          []byte("not a PE image"),
+		"EFI/systemd/systemd-boot.efi": peImage(t, peSection{".text", "stub"}),
+		"loader/loader.conf":           []byte("default fedora-6.5.6.efi\n"),
+		"loader/entries/debug.conf":    []byte("title Debug\nefi /EFI/Linux/fedora-6.6.1.efi\noptions debug\noptions console=ttyS1\n"),
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imgs, err := ScanBLSEntries(ulogtest.Logger{TB: t}, root, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type want struct {
+		name, cmdline, kernel string
+	}
+	// The default entry comes first.
+	wants := []want{
+		{name: "Fedora", cmdline: "console=ttyS0", kernel: "kernel 6.5.6"},
+		{name: "Fedora 40", kernel: "kernel 6.6.1"},
+		{name: "Debug", cmdline: "debug console=ttyS1", kernel: "kernel 6.6.1"},
+	}
+	if len(imgs) != len(wants) {
+		t.Fatalf("ScanBLSEntries() = %v, want %d images", imgs, len(wants))
+	}
+	for i, w := range wants {
+		li := imgs[i].(*boot.LinuxImage)
+		if li.Name != w.name || li.Cmdline != w.cmdline || readAll(t, li.Kernel) != w.kernel {
+			t.Errorf("image %d = %q, %q, %q; want %q, %q, %q", i, li.Name, li.Cmdline, readAll(t, li.Kernel), w.name, w.cmdline, w.kernel)
+		}
+	}
+}
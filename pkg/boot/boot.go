@@ -17,9 +17,11 @@ import (
 type LoadOption func(*loadOptions)
 
 type loadOptions struct {
-	logger        ulog.Logger
-	verbose       bool
-	callKexecLoad bool
+	logger         ulog.Logger
+	verbose        bool
+	callKexecLoad  bool
+	measurer       Measurer
+	kernelVerifier KernelVerifier
 }
 
 func defaultLoadOptions() *loadOptions {
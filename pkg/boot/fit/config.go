@@ -0,0 +1,147 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/dt"
+)
+
+// ErrNoCompatibleConfig is returned if no configuration matches the
+// compatible strings of a board.
+var ErrNoCompatibleConfig = errors.New("no compatible configuration")
+
+// Config is a configuration of a FIT image, naming the images to boot.
+type Config struct {
+	Name        string
+	Description string
+	Kernel      string
+	Ramdisk     string
+	FDT         string
+	// Overlays are the device tree overlays U-Boot applies to FDT, from
+	// the rest of the fdt property.
+	Overlays []string
+	// Compatible are the boards the configuration is for, from its
+	// compatible property or else the root node of its FDT.
+	Compatible []string
+}
+
+// ReadConfig reads a configuration.
+func (i *Image) ReadConfig(name string) (*Config, error) {
+	n, err := i.node("configurations", name)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{Name: name}
+	str := func(prop string) string {
+		if p, ok := n.LookProperty(prop); ok {
+			// Lists of images, as for loadables, start with the
+			// main one.
+			if l, err := p.AsStringList(); err == nil && len(l) > 0 {
+				return l[0]
+			}
+		}
+		return ""
+	}
+	c.Description, c.Kernel, c.Ramdisk, c.FDT = str("description"), str("kernel"), str("ramdisk"), str("fdt")
+	if p, ok := n.LookProperty("fdt"); ok {
+		if l, err := p.AsStringList(); err == nil && len(l) > 1 {
+			c.Overlays = l[1:]
+		}
+	}
+	if c.Kernel == "" {
+		return nil, fmt.Errorf("configuration %q has no kernel", name)
+	}
+
+	if p, ok := n.LookProperty("compatible"); ok {
+		c.Compatible, _ = p.AsStringList()
+	} else if c.FDT != "" {
+		c.Compatible = i.fdtCompatible(c.FDT)
+	}
+	return c, nil
+}
+
+// fdtCompatible returns the compatible strings of the root node of an FDT
+// image, or nil if it cannot be read.
+func (i *Image) fdtCompatible(image string) []string {
+	b, err := i.ReadImage(image)
+	if err != nil {
+		return nil
+	}
+	fdt, err := dt.ReadFDT(b)
+	if err != nil {
+		return nil
+	}
+	p, ok := fdt.RootNode.LookProperty("compatible")
+	if !ok {
+		return nil
+	}
+	c, _ := p.AsStringList()
+	return c
+}
+
+// Configs reads all configurations.
+func (i *Image) Configs() ([]Config, error) {
+	names, err := i.Root.Root().Walk("configurations").ListChildNodes()
+	if err != nil {
+		return nil, err
+	}
+	var cs []Config
+	for _, n := range names {
+		c, err := i.ReadConfig(n)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, *c)
+	}
+	return cs, nil
+}
+
+// ConfigByCompatible returns the name of the configuration that best
+// matches the compatible strings of a board, which go from the most to the
+// least specific as in the root node of a device tree.
+//
+// As in U-Boot, the configuration matching the earliest of the strings
+// wins, and the first of equally good configurations is used.
+func (i *Image) ConfigByCompatible(compatible ...string) (string, error) {
+	cs, err := i.Configs()
+	if err != nil {
+		return "", err
+	}
+	best, bestScore := "", len(compatible)
+	for _, c := range cs {
+		for score, b := range compatible[:bestScore] {
+			if contains(c.Compatible, b) {
+				best, bestScore = c.Name, score
+				break
+			}
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%q: %w", compatible, ErrNoCompatibleConfig)
+	}
+	return best, nil
+}
+
+// BoardCompatible returns the compatible strings of a device tree, as
+// passed to ConfigByCompatible.
+func BoardCompatible(fdt *dt.FDT) ([]string, error) {
+	p, ok := fdt.RootNode.LookProperty("compatible")
+	if !ok {
+		return nil, fmt.Errorf("device tree has no compatible property")
+	}
+	return p.AsStringList()
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/dt"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+func strList(name string, s ...string) dt.Property {
+	var b []byte
+	for _, e := range s {
+		b = append(append(b, e...), 0)
+	}
+	return dt.Property{Name: name, Value: b}
+}
+
+// dtb returns a device tree blob with a compatible root node.
+func dtb(t *testing.T, compatible ...string) []byte {
+	fdt := &dt.FDT{
+		Header:   dt.Header{Magic: dt.Magic, Version: 17, LastCompVersion: 16},
+		RootNode: dt.NewNode("", dt.WithProperty(strList("compatible", compatible...))),
+	}
+	var b bytes.Buffer
+	if _, err := fdt.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func gz(t *testing.T, b []byte) []byte {
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func lz(t *testing.T, b []byte) []byte {
+	var out bytes.Buffer
+	w, err := lzma.NewWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// imageNode returns an image node with hashes of its data.
+func imageNode(name string, data []byte, compression string) *dt.Node {
+	sum := sha256.Sum256(data)
+	crc := crc32.NewIEEE()
+	crc.Write(data)
+	return dt.NewNode(name,
+		dt.WithProperty(
+			dt.Property{Name: "data", Value: data},
+			dt.PropertyString("compression", compression),
+		),
+		dt.WithChildren(
+			dt.NewNode("hash-1", dt.WithProperty(dt.PropertyString("algo", "sha256"), dt.Property{Name: "value", Value: sum[:]})),
+			dt.NewNode("hash-2", dt.WithProperty(dt.PropertyString("algo", "crc32"), dt.Property{Name: "value", Value: crc.Sum(nil)})),
+		),
+	)
+}
+
+const kernelData = "compressed kernel, compressed kernel, compressed kernel"
+
+// boardImage is a FIT image for two boards: a configuration that names its
+// boards and one that takes them from its device tree.
+func boardImage(t *testing.T) *Image {
+	root := dt.NewNode("",
+		dt.WithChildren(
+			dt.NewNode("images", dt.WithChildren(
+				imageNode("kernel-1", gz(t, []byte(kernelData)), "gzip"),
+				imageNode("kernel-2", lz(t, []byte(kernelData)), "lzma"),
+				imageNode("fdt-1", dtb(t, "acme,rocket-v2", "acme,rocket"), "none"),
+				imageNode("fdt-2", gz(t, dtb(t, "acme,anvil")), "gzip"),
+			)),
+			dt.NewNode("configurations",
+				dt.WithProperty(dt.PropertyString("default", "conf-generic")),
+				dt.WithChildren(
+					dt.NewNode("conf-generic", dt.WithProperty(
+						dt.PropertyString("kernel", "kernel-1"),
+						strList("compatible", "acme,rocket"),
+					)),
+					dt.NewNode("conf-rocket-v2", dt.WithProperty(
+						dt.PropertyString("description", "Rocket v2"),
+						dt.PropertyString("kernel", "kernel-2"),
+						dt.PropertyString("fdt", "fdt-1"),
+					)),
+					dt.NewNode("conf-anvil", dt.WithProperty(
+						dt.PropertyString("kernel", "kernel-1"),
+						dt.PropertyString("fdt", "fdt-2"),
+					)),
+				),
+			),
+		),
+	)
+	return &Image{name: "boards", Root: &dt.FDT{RootNode: root}}
+}
+
+func TestReadConfig(t *testing.T) {
+	i := boardImage(t)
+	c, err := i.ReadConfig("conf-rocket-v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Name:        "conf-rocket-v2",
+		Description: "Rocket v2",
+		Kernel:      "kernel-2",
+		FDT:         "fdt-1",
+		Compatible:  []string{"acme,rocket-v2", "acme,rocket"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("ReadConfig = %+v, want %+v", c, want)
+	}
+	// The device tree of the anvil is compressed.
+	if c, err := i.ReadConfig("conf-anvil"); err != nil || !reflect.DeepEqual(c.Compatible, []string{"acme,anvil"}) {
+		t.Errorf("ReadConfig(conf-anvil) = %+v, %v, want compatible acme,anvil", c, err)
+	}
+	if _, err := i.ReadConfig("conf-nope"); err == nil {
+		t.Errorf("ReadConfig(conf-nope) succeeded, want an error")
+	}
+}
+
+func TestConfigByCompatible(t *testing.T) {
+	i := boardImage(t)
+	for _, tt := range []struct {
+		board []string
+		want  string
+		err   error
+	}{
+		{board: []string{"acme,rocket-v2", "acme,rocket"}, want: "conf-rocket-v2"},
+		{board: []string{"acme,rocket-v3", "acme,rocket"}, want: "conf-generic"},
+		{board: []string{"acme,anvil"}, want: "conf-anvil"},
+		{board: []string{"acme,coyote"}, err: ErrNoCompatibleConfig},
+	} {
+		got, err := i.ConfigByCompatible(tt.board...)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ConfigByCompatible(%q) = %q, %v, want %q, %v", tt.board, got, err, tt.want, tt.err)
+		}
+	}
+
+	// GetConfigName prefers Compatible over the default.
+	i.Compatible = []string{"acme,anvil"}
+	if kn, _, err := i.LoadConfig(); err != nil || kn != "kernel-1" {
+		t.Errorf("LoadConfig with Compatible = %q, %v, want kernel-1", kn, err)
+	}
+}
+
+func TestReadCompressedImage(t *testing.T) {
+	i := boardImage(t)
+	for _, name := range []string{"kernel-1", "kernel-2"} {
+		r, err := i.ReadImage(name)
+		if err != nil {
+			t.Fatalf("ReadImage(%s) = %v", name, err)
+		}
+		if b, _ := io.ReadAll(r); string(b) != kernelData {
+			t.Errorf("ReadImage(%s) = %q, want %q", name, b, kernelData)
+		}
+	}
+
+	// Corrupt the kernel.
+	n, _ := i.node("images", "kernel-1")
+	n.Properties[0].Value[20] ^= 0xff
+	if _, err := i.ReadImage("kernel-1"); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("ReadImage of a corrupt kernel = %v, want %v", err, ErrHashMismatch)
+	}
+
+	// Data that is not what it claims to be.
+	n.Children = nil
+	n.Properties = []dt.Property{{Name: "data", Value: []byte(kernelData)}, dt.PropertyString("compression", "gzip")}
+	if _, err := i.ReadImage("kernel-1"); !errors.Is(err, ErrCompression) {
+		t.Errorf("ReadImage of an uncompressed kernel = %v, want %v", err, ErrCompression)
+	}
+	n.Properties[1] = dt.PropertyString("compression", "lzo")
+	if _, err := i.ReadImage("kernel-1"); !errors.Is(err, ErrCompression) {
+		t.Errorf("ReadImage of an lzo kernel = %v, want %v", err, ErrCompression)
+	}
+}
+
+func TestLoadFDT(t *testing.T) {
+	i := boardImage(t)
+	i.Compatible = []string{"acme,rocket-v2"}
+	c, err := i.GetConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := i.ReadConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.Kernel, i.FDT = cfg.Kernel, cfg.FDT
+
+	defer func(old func(i *boot.LinuxImage, opts ...boot.LoadOption) error) { loadImage = old }(loadImage)
+	loadImage = func(li *boot.LinuxImage, opts ...boot.LoadOption) error {
+		if li.DTB == nil {
+			t.Errorf("Load passed no DTB")
+			return nil
+		}
+		fdt, err := dt.ReadFDT(io.NewSectionReader(li.DTB, 0, 1<<20))
+		if err != nil {
+			return err
+		}
+		if got, _ := BoardCompatible(fdt); !reflect.DeepEqual(got, []string{"acme,rocket-v2", "acme,rocket"}) {
+			t.Errorf("Load passed the DTB of %q, want the rocket v2", got)
+		}
+		return nil
+	}
+	if err := i.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadOverlays(t *testing.T) {
+	overlay := &dt.FDT{
+		Header: dt.Header{Magic: dt.Magic, Version: 17, LastCompVersion: 16},
+		RootNode: dt.NewNode("", dt.WithChildren(dt.NewNode("fragment@0",
+			dt.WithProperty(dt.PropertyString("target-path", "/")),
+			dt.WithChildren(dt.NewNode("__overlay__", dt.WithProperty(dt.PropertyString("model", "Rocket v2 with camera")))),
+		))),
+	}
+	ob, err := overlay.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := boardImage(t)
+	images, _ := i.node("images")
+	images.Children = append(images.Children, imageNode("camera", ob, "none"))
+	conf, _ := i.node("configurations", "conf-rocket-v2")
+	conf.Properties[2] = strList("fdt", "fdt-1", "camera")
+
+	imgs, err := i.Configs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := imgs[1].Overlays; !reflect.DeepEqual(got, []string{"camera"}) {
+		t.Errorf("Overlays = %q, want camera", got)
+	}
+	i.Kernel, i.FDT, i.Overlays = imgs[1].Kernel, imgs[1].FDT, imgs[1].Overlays
+
+	defer func(old func(i *boot.LinuxImage, opts ...boot.LoadOption) error) { loadImage = old }(loadImage)
+	loadImage = func(li *boot.LinuxImage, opts ...boot.LoadOption) error {
+		fdt, err := dt.ReadFDT(io.NewSectionReader(li.DTB, 0, 1<<20))
+		if err != nil {
+			return err
+		}
+		p, ok := fdt.RootNode.LookProperty("model")
+		if got, _ := p.AsString(); !ok || got != "Rocket v2 with camera" {
+			t.Errorf("model = %q, want the one from the overlay", got)
+		}
+		return nil
+	}
+	if err := i.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
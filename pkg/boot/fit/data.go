@@ -0,0 +1,108 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fit
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/compress"
+	"github.com/u-root/u-root/pkg/dt"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+var (
+	// ErrHashMismatch is returned if the data of an image does not match
+	// one of its hash nodes.
+	ErrHashMismatch = errors.New("hash mismatch")
+	// ErrCompression is returned for unsupported or mismatching
+	// compression of an image.
+	ErrCompression = errors.New("unsupported compression")
+)
+
+// newHash returns the hash for the algo property of a hash node.
+func newHash(algo string) (hash.Hash, error) {
+	if algo == "crc32" {
+		return crc32.NewIEEE(), nil
+	}
+	h, ok := algs[strings.ToUpper(algo)]
+	if !ok || !h.Available() {
+		return nil, fmt.Errorf("unsupported hash algo %q", algo)
+	}
+	return h.New(), nil
+}
+
+// verifyHashes checks the data of an image against its hash nodes, as
+// U-Boot does. Hash nodes without a value, as in an .its source, are
+// skipped.
+func verifyHashes(image *dt.Node, data []byte) error {
+	for _, n := range image.Children {
+		if !strings.HasPrefix(n.Name, "hash") {
+			continue
+		}
+		v, ok := n.LookProperty("value")
+		if !ok {
+			continue
+		}
+		a, ok := n.LookProperty("algo")
+		if !ok {
+			return fmt.Errorf("%s/%s: missing algo", image.Name, n.Name)
+		}
+		algo := strings.TrimRight(string(a.Value), "\x00")
+		h, err := newHash(algo)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", image.Name, n.Name, err)
+		}
+		h.Write(data)
+		if sum := h.Sum(nil); subtle.ConstantTimeCompare(sum, v.Value) != 1 {
+			return fmt.Errorf("%s/%s: %s is %x, want %x: %w", image.Name, n.Name, algo, sum, v.Value, ErrHashMismatch)
+		}
+	}
+	return nil
+}
+
+// decompress decompresses the data of an image according to its
+// compression property.
+func decompress(image *dt.Node, data []byte) ([]byte, error) {
+	c := "none"
+	if p, ok := image.LookProperty("compression"); ok {
+		c, _ = p.AsString()
+	}
+	var r io.Reader
+	switch c {
+	case "none", "":
+		return data, nil
+	case "lzma":
+		// LZMA has no magic to detect it by.
+		lr, err := lzma.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", image.Name, err)
+		}
+		r = lr
+	case compress.Gzip, compress.Bzip2, compress.LZ4, compress.Zstd, compress.XZ:
+		cr, format, err := compress.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", image.Name, err)
+		}
+		defer cr.Close()
+		if format != c {
+			return nil, fmt.Errorf("%s: compression is %s, but data is %s: %w", image.Name, c, format, ErrCompression)
+		}
+		r = cr
+	default:
+		return nil, fmt.Errorf("%s: %q: %w", image.Name, c, ErrCompression)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: decompressing %s: %w", image.Name, c, err)
+	}
+	return b, nil
+}
@@ -30,6 +30,14 @@ type Image struct {
 	InitRAMFS string
 	// ConfigOverride is the optional FIT config to use instead of default
 	ConfigOverride string
+	// FDT is the optional name of the device tree node.
+	FDT string
+	// Overlays are the optional names of device tree overlay nodes to
+	// apply to FDT.
+	Overlays []string
+	// Compatible are the optional compatible strings of the board, most
+	// specific first, to select a config by if ConfigOverride is not set.
+	Compatible []string
 	// SkipInitRAMFS skips the search for an ramdisk entry in the config
 	SkipInitRAMFS bool
 	// BootRank ranks the priority of the images in boot menu
@@ -69,10 +77,9 @@ func ParseConfig(r io.ReadSeeker) ([]Image, error) {
 	for _, n := range cn {
 		i := Image{name: n, Root: fdt, ConfigOverride: n}
 
-		kn, in, err := i.LoadConfig()
-
+		c, err := i.ReadConfig(n)
 		if err == nil {
-			i.Kernel, i.InitRAMFS = kn, in
+			i.Kernel, i.InitRAMFS, i.FDT, i.Overlays = c.Kernel, c.Ramdisk, c.FDT, c.Overlays
 			images = append(images, i)
 		}
 	}
@@ -121,55 +128,122 @@ func (i *Image) Load(opts ...boot.LoadOption) error {
 		Cmdline: i.Cmdline,
 	}
 
-	if i.KeyRing != nil {
-		kr, err := i.ReadSignedImage(i.Kernel, i.KeyRing)
+	kr, err := i.read(i.Kernel)
+	if err != nil {
+		return err
+	}
+	image.Kernel = kr
+
+	if len(i.InitRAMFS) != 0 {
+		ir, err := i.read(i.InitRAMFS)
 		if err != nil {
 			return err
 		}
-		image.Kernel = kr
-	} else {
-		kr, err := i.ReadImage(i.Kernel)
+		image.Initrd = ir
+	}
+
+	if len(i.FDT) != 0 {
+		dr, err := i.readFDT()
 		if err != nil {
 			return err
 		}
-		image.Kernel = kr
+		image.DTB = dr
 	}
 
-	if len(i.InitRAMFS) != 0 {
-		if i.KeyRing != nil {
-			ir, err := i.ReadSignedImage(i.InitRAMFS, i.KeyRing)
-			if err != nil {
-				return err
-			}
-			image.Initrd = ir
-		} else {
-			ir, err := i.ReadImage(i.InitRAMFS)
-			if err != nil {
-				return err
-			}
-			image.Initrd = ir
+	return loadImage(image, opts...)
+}
+
+// read reads an image, verifying its signature if there is a KeyRing.
+func (i *Image) read(image string) (*bytes.Reader, error) {
+	if i.KeyRing != nil {
+		return i.ReadSignedImage(image, i.KeyRing)
+	}
+	return i.ReadImage(image)
+}
+
+// readFDT reads the device tree and applies the overlays to it.
+func (i *Image) readFDT() (*bytes.Reader, error) {
+	dr, err := i.read(i.FDT)
+	if err != nil || len(i.Overlays) == 0 {
+		return dr, err
+	}
+	fdt, err := dt.ReadFDT(dr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i.FDT, err)
+	}
+	for _, o := range i.Overlays {
+		or, err := i.read(o)
+		if err != nil {
+			return nil, err
+		}
+		overlay, err := dt.ReadFDT(or)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o, err)
+		}
+		if err := fdt.ApplyOverlay(overlay); err != nil {
+			return nil, fmt.Errorf("applying %s to %s: %w", o, i.FDT, err)
+		}
+	}
+	b, err := fdt.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// node returns the node at a path from the root.
+func (i *Image) node(path ...string) (*dt.Node, error) {
+	n := i.Root.RootNode
+	for _, name := range path {
+		c, ok := n.LookupChildByName(name)
+		if !ok {
+			return nil, fmt.Errorf("cannot find node name %q", name)
 		}
+		n = c
 	}
+	return n, nil
+}
 
-	return loadImage(image, opts...)
+// imageData returns the node and the data of an image.
+func (i *Image) imageData(image string) (*dt.Node, []byte, error) {
+	n, err := i.node("images", image)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, ok := n.LookProperty("data")
+	if !ok {
+		return nil, nil, fmt.Errorf("image %q has no data", image)
+	}
+	return n, p.Value, nil
 }
 
-// ReadImage reads an image node from an FDT and returns the `data` contents.
+// ReadImage reads an image node from an FDT and returns the `data`
+// contents. The data is checked against the hash nodes of the image, and
+// decompressed according to its compression property.
 func (i *Image) ReadImage(image string) (*bytes.Reader, error) {
-	root := i.Root.Root().Walk("images").Walk(image)
-	b, err := root.Property("data").AsBytes()
+	n, b, err := i.imageData(image)
 	if err != nil {
 		return nil, err
 	}
+	if err := verifyHashes(n, b); err != nil {
+		return nil, err
+	}
+	if b, err = decompress(n, b); err != nil {
+		return nil, err
+	}
 	return bytes.NewReader(b), nil
 }
 
-// GetConfigName finds the name of the default configuration or returns the
-// override config if available
+// GetConfigName finds the name of the configuration to use: the override
+// config if available, else the best match for Compatible if set, else the
+// default.
 func (i *Image) GetConfigName() (string, error) {
 	if len(i.ConfigOverride) != 0 {
 		return i.ConfigOverride, nil
 	}
+	if len(i.Compatible) != 0 {
+		return i.ConfigByCompatible(i.Compatible...)
+	}
 
 	configs := i.Root.Root().Walk("configurations")
 	dc, err := configs.Property("default").AsString()
@@ -188,23 +262,9 @@ func (i *Image) LoadConfig() (string, string, error) {
 		return "", "", err
 	}
 
-	configs := i.Root.Root().Walk("configurations")
-	config := configs.Walk(tc)
-	_, err = config.AsString()
-
-	if err != nil {
-		return "", "", err
-	}
-
-	var kn, rn string
-
-	kn, err = config.Property("kernel").AsString()
+	c, err := i.ReadConfig(tc)
 	if err != nil {
 		return "", "", err
 	}
-
-	// Allow missing initram nodes
-	rn, _ = config.Property("ramdisk").AsString()
-
-	return kn, rn, nil
+	return c.Kernel, c.Ramdisk, nil
 }
@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 //
 // Performs signature checks on FDT images.
-// Currently supports PGP and raw PKCS1v15 RSA signatures.
+// Currently supports PGP and raw PKCS1v15 RSA, ECDSA and Ed25519 signatures.
 //
 // Expected FDT Format:
 //  Node: images
@@ -11,7 +11,7 @@
 //   P: data
 //   Node: signature*
 //    P: value
-//    P: algo          (ex. 'sha256,rsa4096', 'pgp')
+//    P: algo          (ex. 'sha256,rsa4096', 'sha256,ecdsa256', 'sha256,ed25519', 'pgp')
 //    P: signer-name   (Optional)
 //    P: key-name-hint (Optional)
 
@@ -20,7 +20,6 @@ package fit
 import (
 	"bytes"
 	"crypto"
-	"crypto/rsa"
 	"fmt"
 	"strings"
 	"unicode"
@@ -64,8 +63,9 @@ type PGPSignature struct {
 	hint   string
 }
 
-// RSASignature implements a PKCS1v15 signature check.
-type RSASignature struct {
+// RawSignature implements a check of a raw RSA PKCS1v15, ECDSA or Ed25519
+// signature over the hash of an image.
+type RawSignature struct {
 	name  string // Name of signature Node
 	hash  crypto.Hash
 	value []byte
@@ -74,11 +74,16 @@ type RSASignature struct {
 	hint   string
 }
 
+// RSASignature is the former name of RawSignature.
+//
+// Deprecated: use RawSignature.
+type RSASignature = RawSignature
+
 func (s PGPSignature) String() string {
 	return fmt.Sprintf("PGP Signature - name: %s, signer: '%s', hint: '%s'", s.name, s.signer, s.hint)
 }
 
-// Verify runs a PKCS1v15 check using the RSA keys extracted from the provided
+// Verify runs a OpenPGP check using the PGP keys extracted from the provided
 // key ring.
 // Warning: If the signature does not exist or does not match the keyring,
 // both the file and a signature error will be returned.
@@ -92,13 +97,13 @@ func (s PGPSignature) Verify(b []byte, ring openpgp.KeyRing) (*bytes.Reader, err
 	return r, nil
 }
 
-// Verify runs a OpenPGP check using the PGP keys extracted from the provided
-// key ring.
+// Verify checks the signature against all RSA, ECDSA and Ed25519 keys
+// extracted from the provided key ring.
 // Warning: If the signature does not exist or does not match the keyring,
 // both the file and a signature error will be returned.
-func (s RSASignature) Verify(b []byte, ring openpgp.KeyRing) (*bytes.Reader, error) {
+func (s RawSignature) Verify(b []byte, ring openpgp.KeyRing) (*bytes.Reader, error) {
 	r := bytes.NewReader(b)
-	keys, err := vfile.GetRSAKeysFromRing(ring)
+	keys, err := vfile.GetKeysFromRing(ring)
 	if err != nil {
 		return r, err
 	}
@@ -108,16 +113,14 @@ func (s RSASignature) Verify(b []byte, ring openpgp.KeyRing) (*bytes.Reader, err
 		return r, err
 	}
 
-	for _, key := range keys {
-		if err = rsa.VerifyPKCS1v15(key, s.hash, hashed, s.value); err == nil {
-			return r, nil
-		}
+	if err := vfile.VerifyHashSignature(keys, s.hash, hashed, s.value); err != nil {
+		return r, vfile.ErrUnsigned{Path: s.name, Err: vfile.ErrWrongSigner{KeyRing: ring}}
 	}
-	return r, vfile.ErrUnsigned{Err: vfile.ErrWrongSigner{ring}}
+	return r, nil
 }
 
-func (s RSASignature) String() string {
-	return fmt.Sprintf("RSA Signature - name: %s, signer: '%s', hint: '%s', hash: '%s'", s.name, s.signer, s.hint, s.hash)
+func (s RawSignature) String() string {
+	return fmt.Sprintf("Raw Signature - name: %s, signer: '%s', hint: '%s', hash: '%s'", s.name, s.signer, s.hint, s.hash)
 }
 
 // parseHash cleans and maps the first detected hash string into a crypto.Hash.
@@ -138,7 +141,7 @@ func parseHash(algo string) (crypto.Hash, error) {
 	return 0, fmt.Errorf("unrecognized hash algo: '%s'", cleaned)
 }
 
-// parseSignatures parses dt.Node to RSASignatures
+// parseSignatures parses dt.Node to PGPSignatures and RawSignatures
 // Nodes with missing required properties or invalid hash functions are skipped.
 // An error is returned if no valid signatures are found
 func parseSignatures(n ...*dt.Node) ([]Signature, error) {
@@ -164,18 +167,19 @@ func parseSignatures(n ...*dt.Node) ([]Signature, error) {
 			hint = string(hintProp.Value)
 		}
 
-		// Perform a broad stroke check for algos. RSA is assumed a raw RSA signature
-		switch {
-		case strings.Contains(string(a.Value), "pgp"):
-			sigs = append(sigs, PGPSignature{value: v.Value, signer: signer, hint: hint})
-		case strings.Contains(string(a.Value), "rsa"):
+		// Perform a broad stroke check for algos. RSA, ECDSA and Ed25519
+		// are assumed raw signatures.
+		switch algo := string(a.Value); {
+		case strings.Contains(algo, "pgp"):
+			sigs = append(sigs, PGPSignature{name: node.Name, value: v.Value, signer: signer, hint: hint})
+		case strings.Contains(algo, "rsa"), strings.Contains(algo, "ecdsa"), strings.Contains(algo, "ed25519"):
 			// Parse the hash function used for the signature. ex. 'sha256,rsa4096'
-			hf, err := parseHash(string(a.Value))
+			hf, err := parseHash(algo)
 			if err != nil {
 				fmt.Printf("Skipping signature %s: %v", node.Name, err)
 				continue
 			}
-			sigs = append(sigs, RSASignature{value: v.Value, hash: hf, signer: signer, hint: hint})
+			sigs = append(sigs, RawSignature{name: node.Name, value: v.Value, hash: hf, signer: signer, hint: hint})
 		}
 	}
 	if len(sigs) == 0 {
@@ -193,17 +197,24 @@ func parseSignatures(n ...*dt.Node) ([]Signature, error) {
 // If the signature does not exist or does not match the keyring, both the file
 // and a signature error will be returned.
 func (i *Image) ReadSignedImage(image string, ring openpgp.KeyRing) (*bytes.Reader, error) {
-	iroot := i.Root.Root().Walk("images").Walk(image)
-	b, err := iroot.Property("data").AsBytes()
+	iroot, b, err := i.imageData(image)
 	if err != nil {
 		return nil, err
 	}
+	if err := verifyHashes(iroot, b); err != nil {
+		return nil, err
+	}
+	// Signatures are over the data as stored, which may be compressed.
+	d, err := decompress(iroot, b)
+	if err != nil {
+		return nil, err
+	}
+	br := bytes.NewReader(d)
 
-	br := bytes.NewReader(b)
-	sigNodes, err := iroot.FindAll(func(n *dt.Node) bool {
+	sigNodes, ok := iroot.FindAll(func(n *dt.Node) bool {
 		return strings.HasPrefix(strings.ToLower(n.Name), "signature")
 	})
-	if err != nil {
+	if !ok {
 		return br, vfile.ErrUnsigned{Path: image, Err: fmt.Errorf("no signature nodes found")}
 	}
 	sigs, err := parseSignatures(sigNodes...)
@@ -212,9 +223,9 @@ func (i *Image) ReadSignedImage(image string, ring openpgp.KeyRing) (*bytes.Read
 	}
 
 	for _, sig := range sigs {
-		v, err := sig.Verify(b, ring)
+		_, err := sig.Verify(b, ring)
 		if err == nil {
-			return v, nil
+			return br, nil
 		}
 		fmt.Printf("Ignoring failed signature - %s: Failed with %v\n", sig, err)
 	}
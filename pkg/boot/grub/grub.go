@@ -23,7 +23,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/pflag"
@@ -243,6 +242,20 @@ type parser struct {
 	// curLabel is the last parsed label from a "menuentry".
 	curLabel string
 
+	// curKeys are the names the current entry can be referred to by.
+	curKeys []string
+
+	// blocks are the enclosing if and { } blocks.
+	blocks []*scope
+
+	// prefixes are the key prefixes of the current submenu.
+	prefixes []string
+
+	// functions are the bodies of functions by name.
+	functions map[string]string
+	function  *function
+	callDepth int
+
 	devices   block.BlockDevices
 	mountPool *mount.Pool
 	schemes   curl.Schemes
@@ -272,6 +285,7 @@ func newParser(root *url.URL, devices block.BlockDevices, mountPool *mount.Pool,
 		variables: map[string]string{
 			"root": root.String(),
 		},
+		functions:   make(map[string]string),
 		devices:     devices,
 		mountPool:   mountPool,
 		schemes:     s,
@@ -364,232 +378,330 @@ func cmdlineQuote(args []string) string {
 
 // append parses `config` and adds the respective configuration to `c`.
 //
-// NOTE: This parser has outlived its usefulness already, given that it doesn't
-// even understand the {} scoping in GRUB. But let's get the tests to pass, and
-// then we can do a rewrite.
+// Commands are run as GRUB would, as far as the scripting constructs in
+// script.go go.
 func (c *parser) append(ctx context.Context, config string) error {
-	// Here's a shitty parser.
 	for _, line := range strings.Split(config, "\n") {
+		if c.function != nil {
+			if !c.function.capture(line) {
+				c.functions[c.function.name] = strings.Join(c.function.body, "\n")
+				c.function = nil
+			}
+			continue
+		}
 		// Add extra backslash for OpenSUSE/Fedora/RHEL use case. shlex
 		// will convert it back to a single backslash.
 		line = hexEscape.ReplaceAllString(line, `\\$0`)
-		kv := shlex.Argv(line)
-		if len(kv) < 1 {
-			continue
+		for _, cmd := range splitCommands(line) {
+			if err := c.command(ctx, shlex.Argv(cmd)); err != nil {
+				return err
+			}
 		}
-		directive := strings.ToLower(kv[0])
-		// blscfg len(kv) is 1 so need to be checked here
-		if directive == "blscfg" {
-			c.blscfgFound = true
+	}
+	return nil
+}
+
+// command runs the command kv.
+func (c *parser) command(ctx context.Context, kv []string) error {
+	if len(kv) < 1 {
+		return nil
+	}
+	directive := strings.ToLower(kv[0])
+	switch directive {
+	case "if":
+		c.startIf(kv[1:])
+		return nil
+	case "elif":
+		c.elif(kv[1:])
+		return nil
+	case "else":
+		c.els()
+		return c.command(ctx, kv[1:])
+	case "then", "do":
+		return c.command(ctx, kv[1:])
+	case "fi":
+		c.endIf()
+		return nil
+	case "}":
+		c.endBlock()
+		return nil
+	}
+
+	if !c.active() {
+		// Keep track of the blocks we skip.
+		if len(kv) > 1 && kv[len(kv)-1] == "{" {
+			c.blocks = append(c.blocks, &scope{kind: blockOther})
 		}
+		return nil
+	}
 
-		// Used by tests (allow no parameters here)
-		if c.W != nil && directive == "echo" {
-			fmt.Fprintf(c.W, "echo:%#v\n", kv[1:])
+	// blscfg len(kv) is 1 so need to be checked here
+	if directive == "blscfg" {
+		c.blscfgFound = true
+	}
+
+	// Used by tests (allow no parameters here)
+	if c.W != nil && directive == "echo" {
+		fmt.Fprintf(c.W, "echo:%#v\n", kv[1:])
+	}
+
+	if body, ok := c.functions[kv[0]]; ok {
+		if c.callDepth >= maxFunctionDepth {
+			return fmt.Errorf("functions nested more than %d deep", maxFunctionDepth)
 		}
+		c.callDepth++
+		defer func() { c.callDepth-- }()
+		return c.append(ctx, body)
+	}
 
-		if len(kv) <= 1 {
-			continue
+	// name=value is the same as set name=value.
+	if assignment.MatchString(kv[0]) {
+		kv = []string{"set", kv[0]}
+		directive = "set"
+	}
+
+	if len(kv) <= 1 {
+		return nil
+	}
+	arg := kv[1]
+
+	switch directive {
+	case "function":
+		c.function = &function{name: arg}
+		if kv[len(kv)-1] == "{" {
+			c.function.depth = 1
 		}
-		arg := kv[1]
-
-		switch directive {
-		case "search.file", "search.fs_label", "search.fs_uuid":
-			// Alias to regular search directive.
-			kv = append(
-				[]string{"search", map[string]string{
-					"search.file":     "--file",
-					"search.fs_label": "--fs-label",
-					"search.fs_uuid":  "--fs-uuid",
-				}[directive]},
-				kv[1:]...,
-			)
-			fallthrough
-		case "search":
-			// Parses a line with this format:
-			//   search [--file|--label|--fs-uuid] [--set [var]] [--no-floppy] name
-			fs := pflag.NewFlagSet("grub.search", pflag.ContinueOnError)
-			searchUUID := fs.BoolP("fs-uuid", "u", false, "")
-			searchLabel := fs.BoolP("fs-label", "l", false, "")
-			searchFile := fs.BoolP("file", "f", false, "")
-			setVar := fs.String("set", "root", "")
-			// Ignored flags
-			fs.String("no-floppy", "", "ignored")
-			fs.String("hint", "", "ignored")
-			fs.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
-				// Everything that begins with "hint" is ignored.
-				if strings.HasPrefix(name, "hint") {
-					name = "hint"
-				}
-				return pflag.NormalizedName(name)
-			})
 
-			if err := fs.Parse(kv[1:]); err != nil || fs.NArg() != 1 {
-				log.Printf("Warning: Grub parser could not parse %q", kv)
-				continue
-			}
-			searchName := fs.Arg(0)
-			if *searchUUID && *searchLabel || *searchUUID && *searchFile || *searchLabel && *searchFile {
-				log.Printf("Warning: Grub parser found more than one search option in %q, skipping line", line)
-				continue
-			}
-			if !*searchUUID && !*searchLabel && !*searchFile {
-				// defaults to searchUUID
-				*searchUUID = true
-			}
+	case "search", "search.file", "search.fs_label", "search.fs_uuid":
+		c.search(kv)
 
-			switch {
-			case *searchUUID:
-				d := c.devices.FilterFSUUID(searchName)
-				if len(d) != 1 {
-					log.Printf("Error: Expected 1 device with UUID %q, found %d", searchName, len(d))
-					continue
-				}
-				mp, err := c.mountPool.Mount(d[0], mountFlags)
-				if err != nil {
-					log.Printf("Error: Could not mount %v: %v", d[0], err)
-					continue
-				}
-				setVal, err := absFileScheme(mp.Path)
-				if err != nil {
-					continue
-				}
-				c.variables[*setVar] = setVal.String()
-			case *searchLabel:
-				d := c.devices.FilterPartLabel(searchName)
-				if len(d) != 1 {
-					log.Printf("Error: Expected 1 device with label %q, found %d", searchName, len(d))
-					continue
-				}
-				mp, err := c.mountPool.Mount(d[0], mountFlags)
-				if err != nil {
-					log.Printf("Error: Could not mount %v: %v", d[0], err)
-					continue
-				}
-				setVal, err := absFileScheme(mp.Path)
-				if err != nil {
-					continue
-				}
-				c.variables[*setVar] = setVal.String()
-			case *searchFile:
-				// Make sure searchName stays in mountpoint. Remove "../" components.
-				cleanPath, err := filepath.Rel("/", filepath.Clean(filepath.Join("/", searchName)))
-				if err != nil {
-					log.Printf("Error: Could not clean path %q: %v", searchName, err)
-					continue
-				}
-				// Search through all the devices for the file.
-				for _, d := range c.devices {
-					mp, err := c.mountPool.Mount(d, mountFlags)
-					if err != nil {
-						log.Printf("Warning: Could not mount %v: %v", mp, err)
-						continue
-					}
-					file := filepath.Join(mp.Path, cleanPath)
-					if _, err := os.Stat(file); err == nil {
-						setVal, err := absFileScheme(mp.Path)
-						if err != nil {
-							continue
-						}
-						c.variables[*setVar] = setVal.String()
-						break
-					}
-				}
+	case "set":
+		vals := strings.SplitN(arg, "=", 2)
+		if len(vals) == 2 {
+			// TODO: We cannot parse grub device syntax.
+			if vals[0] == "root" {
+				return nil
 			}
-
-		case "set":
-			vals := strings.SplitN(arg, "=", 2)
-			if len(vals) == 2 {
-				// TODO: We cannot parse grub device syntax.
-				if vals[0] == "root" {
-					continue
-				}
-				// here we only add the support for the case: set default="${saved_entry}".
-				if vals[0] == "default" {
-					if vals[1] == "${saved_entry}" {
-						c.variables["default_saved_entry"] = vals[1]
-					} else {
-						c.variables[vals[0]] = vals[1]
-					}
+			// here we only add the support for the case: set default="${saved_entry}".
+			if vals[0] == "default" {
+				if vals[1] == "${saved_entry}" {
+					c.variables["default_saved_entry"] = vals[1]
 				} else {
 					c.variables[vals[0]] = vals[1]
 				}
+			} else {
+				c.variables[vals[0]] = vals[1]
 			}
+		}
 
-		case "configfile":
-			// TODO test that
-			if err := c.appendFile(ctx, arg); err != nil {
-				return err
-			}
+	case "configfile":
+		// TODO test that
+		if err := c.appendFile(ctx, arg); err != nil {
+			return err
+		}
+
+	case "menuentry":
+		keys := c.entryKeys(c.numEntry, arg, c.menuentryID(kv[2:]))
+		if len(c.prefixes) > 0 {
+			// Entries in submenus can be referred to by
+			// their title alone, too.
+			keys = append(keys, arg)
+		}
+		c.numEntry++
+		c.curEntry = keys[0]
+		c.curLabel = arg
+		c.curKeys = keys
+		c.labelOrder = append(c.labelOrder, keys...)
+		c.blocks = append(c.blocks, &scope{kind: blockMenuentry})
+
+	case "submenu":
+		keys := c.entryKeys(c.numEntry, arg, c.menuentryID(kv[2:]))
+		c.blocks = append(c.blocks, &scope{kind: blockSubmenu, numEntry: c.numEntry + 1, prefixes: c.prefixes})
+		c.numEntry = 0
+		c.prefixes = nil
+		for _, k := range keys {
+			c.prefixes = append(c.prefixes, k+">")
+		}
 
-		case "menuentry":
-			c.curEntry = strconv.Itoa(c.numEntry)
-			c.curLabel = arg
-			c.numEntry++
-			c.labelOrder = append(c.labelOrder, c.curEntry, c.curLabel)
+	case "linux", "linux16", "linuxefi":
+		k, err := c.getFile(arg)
+		if err != nil {
+			return err
+		}
+		// from grub manual: "Any initrd must be reloaded after using this command" so we can replace the entry
+		entry := &boot.LinuxImage{
+			Name:    c.curLabel,
+			Kernel:  k,
+			Cmdline: cmdlineQuote(kv[2:]),
+		}
+		c.addLinuxEntry(entry)
 
-		case "linux", "linux16", "linuxefi":
-			k, err := c.getFile(arg)
+	case "initrd", "initrd16", "initrdefi":
+		if e, ok := c.linuxEntries[c.curEntry]; ok {
+			i, err := c.getFile(arg)
 			if err != nil {
 				return err
 			}
-			// from grub manual: "Any initrd must be reloaded after using this command" so we can replace the entry
-			entry := &boot.LinuxImage{
-				Name:    c.curLabel,
-				Kernel:  k,
-				Cmdline: cmdlineQuote(kv[2:]),
-			}
-			c.linuxEntries[c.curEntry] = entry
-			c.linuxEntries[c.curLabel] = entry
-
-		case "initrd", "initrd16", "initrdefi":
-			if e, ok := c.linuxEntries[c.curEntry]; ok {
-				i, err := c.getFile(arg)
-				if err != nil {
-					return err
+			e.Initrd = i
+		}
+
+	case "multiboot", "multiboot2":
+		// TODO handle --quirk-* arguments ? (change parsing)
+		k, err := c.getFile(arg)
+		if err != nil {
+			return err
+		}
+		// from grub manual: "Any initrd must be reloaded after using this command" so we can replace the entry
+		entry := &boot.MultibootImage{
+			Name:    c.curLabel,
+			Kernel:  k,
+			Cmdline: cmdlineQuote(kv[2:]),
+		}
+		c.addMultibootEntry(entry)
+
+	case "module", "module2":
+		// TODO handle --nounzip arguments ? (change parsing)
+		if e, ok := c.mbEntries[c.curEntry]; ok {
+			// The only allowed arg
+			cmdline := kv[1:]
+			if arg == "--nounzip" {
+				if len(kv) < 3 {
+					return fmt.Errorf("no file argument given: %v", kv)
 				}
-				e.Initrd = i
+				arg = kv[2]
+				cmdline = kv[2:]
 			}
-
-		case "multiboot", "multiboot2":
-			// TODO handle --quirk-* arguments ? (change parsing)
-			k, err := c.getFile(arg)
+			m, err := c.getFile(arg)
 			if err != nil {
 				return err
 			}
-			// from grub manual: "Any initrd must be reloaded after using this command" so we can replace the entry
-			entry := &boot.MultibootImage{
-				Name:    c.curLabel,
-				Kernel:  k,
-				Cmdline: cmdlineQuote(kv[2:]),
-			}
-			c.mbEntries[c.curEntry] = entry
-			c.mbEntries[c.curLabel] = entry
-
-		case "module", "module2":
-			// TODO handle --nounzip arguments ? (change parsing)
-			if e, ok := c.mbEntries[c.curEntry]; ok {
-				// The only allowed arg
-				cmdline := kv[1:]
-				if arg == "--nounzip" {
-					if len(kv) < 3 {
-						return fmt.Errorf("no file argument given: %v", kv)
-					}
-					arg = kv[2]
-					cmdline = kv[2:]
-				}
-				m, err := c.getFile(arg)
-				if err != nil {
-					return err
-				}
-				// TODO: Lasy tryGzipFilter(m)
-				mod := multiboot.Module{
-					Module:  m,
-					Cmdline: cmdlineQuote(cmdline),
-				}
-				e.Modules = append(e.Modules, mod)
+			// TODO: Lasy tryGzipFilter(m)
+			mod := multiboot.Module{
+				Module:  m,
+				Cmdline: cmdlineQuote(cmdline),
 			}
+			e.Modules = append(e.Modules, mod)
 		}
 	}
 	return nil
 }
+
+func (c *parser) addLinuxEntry(e *boot.LinuxImage) {
+	c.linuxEntries[c.curEntry] = e
+	for _, k := range c.curKeys {
+		c.linuxEntries[k] = e
+	}
+}
+
+func (c *parser) addMultibootEntry(e *boot.MultibootImage) {
+	c.mbEntries[c.curEntry] = e
+	for _, k := range c.curKeys {
+		c.mbEntries[k] = e
+	}
+}
+
+// search runs the search command in kv and returns whether it found the
+// device.
+func (c *parser) search(kv []string) bool {
+	alias := kv[0] != "search"
+	switch kv[0] {
+	case "search.file", "search.fs_label", "search.fs_uuid":
+		// Alias to regular search directive.
+		kv = append(
+			[]string{"search", map[string]string{
+				"search.file":     "--file",
+				"search.fs_label": "--fs-label",
+				"search.fs_uuid":  "--fs-uuid",
+			}[kv[0]]},
+			kv[1:]...,
+		)
+	}
+
+	// Parses a line with this format:
+	//   search [--file|--label|--fs-uuid] [--set [var]] [--no-floppy] name
+	fs := pflag.NewFlagSet("grub.search", pflag.ContinueOnError)
+	searchUUID := fs.BoolP("fs-uuid", "u", false, "")
+	searchLabel := fs.BoolP("fs-label", "l", false, "")
+	searchFile := fs.BoolP("file", "f", false, "")
+	setVar := fs.StringP("set", "s", "root", "")
+	// "--set" alone sets root.
+	fs.Lookup("set").NoOptDefVal = "root"
+	// Ignored flags
+	fs.BoolP("no-floppy", "n", false, "ignored")
+	fs.String("hint", "", "ignored")
+	fs.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		// Everything that begins with "hint" is ignored.
+		if strings.HasPrefix(name, "hint") {
+			name = "hint"
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	if err := fs.Parse(kv[1:]); err != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		log.Printf("Warning: Grub parser could not parse %q", kv)
+		return false
+	}
+	searchName := fs.Arg(0)
+	switch {
+	case fs.NArg() == 2 && alias:
+		// search.fs_uuid name var
+		searchName, *setVar = fs.Arg(0), fs.Arg(1)
+	case fs.NArg() == 2:
+		// search --set var name
+		*setVar, searchName = fs.Arg(0), fs.Arg(1)
+	}
+	if *searchUUID && *searchLabel || *searchUUID && *searchFile || *searchLabel && *searchFile {
+		log.Printf("Warning: Grub parser found more than one search option in %q, skipping line", kv)
+		return false
+	}
+	if !*searchUUID && !*searchLabel && !*searchFile {
+		// defaults to searchUUID
+		*searchUUID = true
+	}
+
+	var devices block.BlockDevices
+	switch {
+	case *searchUUID:
+		devices = c.devices.FilterFSUUID(searchName)
+		if len(devices) != 1 {
+			log.Printf("Error: Expected 1 device with UUID %q, found %d", searchName, len(devices))
+			return false
+		}
+	case *searchLabel:
+		devices = c.devices.FilterPartLabel(searchName)
+		if len(devices) != 1 {
+			log.Printf("Error: Expected 1 device with label %q, found %d", searchName, len(devices))
+			return false
+		}
+	case *searchFile:
+		devices = c.devices
+		// Make sure searchName stays in mountpoint. Remove "../" components.
+		cleanPath, err := filepath.Rel("/", filepath.Clean(filepath.Join("/", searchName)))
+		if err != nil {
+			log.Printf("Error: Could not clean path %q: %v", searchName, err)
+			return false
+		}
+		searchName = cleanPath
+	}
+
+	// Search through all the devices for the file.
+	for _, d := range devices {
+		mp, err := c.mountPool.Mount(d, mountFlags)
+		if err != nil {
+			log.Printf("Warning: Could not mount %v: %v", d, err)
+			continue
+		}
+		if *searchFile {
+			if _, err := os.Stat(filepath.Join(mp.Path, searchName)); err != nil {
+				continue
+			}
+		}
+		setVal, err := absFileScheme(mp.Path)
+		if err != nil {
+			continue
+		}
+		c.variables[*setVar] = setVal.String()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,423 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grub
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GRUB's scripting language is interpreted only as far as it matters for
+// finding boot entries: if/elif/else on variables, menuentry and submenu
+// blocks, and functions. Conditions that depend on things we cannot know,
+// like files in GRUB's prefix or variables from grubenv, run every branch.
+
+// features are the variables GRUB sets to tell configs what it supports.
+var features = map[string]string{
+	"feature_200_final":            "y",
+	"feature_all_video_module":     "y",
+	"feature_chainloader_bpb":      "y",
+	"feature_default_font_path":    "y",
+	"feature_menuentry_id":         "y",
+	"feature_menuentry_options":    "y",
+	"feature_nativedisk_cmd":       "y",
+	"feature_ntldr":                "y",
+	"feature_platform_search_hint": "y",
+	"feature_timeout_style":        "y",
+}
+
+// maxFunctionDepth limits recursive function calls.
+const maxFunctionDepth = 16
+
+type cond uint8
+
+const (
+	condFalse cond = iota
+	condTrue
+	// condUnknown is a condition that cannot be evaluated.
+	condUnknown
+)
+
+type blockKind uint8
+
+const (
+	blockIf blockKind = iota
+	blockMenuentry
+	blockSubmenu
+	blockOther
+)
+
+// scope is an if/fi or a { } block.
+type scope struct {
+	kind blockKind
+
+	// For if blocks: run is set if the current branch runs, done if a
+	// branch ran already. unknown is set if a condition could not be
+	// evaluated, in which case all branches run.
+	run, done, unknown bool
+
+	// For submenus: the entry number and key prefixes of the enclosing
+	// menu.
+	numEntry int
+	prefixes []string
+}
+
+// function is a GRUB function being defined.
+type function struct {
+	name  string
+	body  []string
+	depth int
+}
+
+var varRef = regexp.MustCompile(`\$(\{[^}]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9#?@*])`)
+
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// lookup returns the value of variable name.
+func (c *parser) lookup(name string) (string, bool) {
+	if v, ok := c.variables[name]; ok {
+		return v, true
+	}
+	v, ok := features[name]
+	return v, ok
+}
+
+// expand expands variable references in s. ok is false if a variable is
+// not set, e.g. because it would come from grubenv.
+func (c *parser) expand(s string) (string, bool) {
+	ok := true
+	s = varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := strings.TrimSuffix(strings.TrimPrefix(ref[1:], "{"), "}")
+		v, found := c.lookup(name)
+		if !found {
+			ok = false
+		}
+		return v
+	})
+	return s, ok
+}
+
+// splitCommands splits line into the commands separated by ';', leaving
+// quoted or escaped semicolons and comments alone.
+func splitCommands(line string) []string {
+	var cmds []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			}
+		case ch == '\\':
+			i++
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t' || line[i-1] == ';'):
+			return append(cmds, line[start:])
+		case ch == ';':
+			cmds = append(cmds, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(cmds, line[start:])
+}
+
+// active returns whether commands are run, i.e. whether all enclosing
+// if branches run.
+func (c *parser) active() bool {
+	for _, b := range c.blocks {
+		if b.kind == blockIf && !b.run {
+			return false
+		}
+	}
+	return true
+}
+
+// topIf returns the innermost if block.
+func (c *parser) topIf() *scope {
+	if len(c.blocks) == 0 || c.blocks[len(c.blocks)-1].kind != blockIf {
+		return nil
+	}
+	return c.blocks[len(c.blocks)-1]
+}
+
+// startIf enters an if block.
+func (c *parser) startIf(args []string) {
+	b := &scope{kind: blockIf}
+	if !c.active() {
+		// No branch runs.
+		b.done = true
+	} else {
+		c.branch(b, c.condition(args))
+	}
+	c.blocks = append(c.blocks, b)
+}
+
+func (c *parser) branch(b *scope, r cond) {
+	switch r {
+	case condTrue:
+		b.run, b.done = true, true
+	case condFalse:
+		b.run = false
+	case condUnknown:
+		b.run, b.unknown = true, true
+	}
+}
+
+// elif enters an elif branch of the innermost if block.
+func (c *parser) elif(args []string) {
+	b := c.topIf()
+	switch {
+	case b == nil:
+	case b.unknown:
+		b.run = true
+	case b.done:
+		b.run = false
+	default:
+		c.branch(b, c.condition(args))
+	}
+}
+
+// els enters the else branch of the innermost if block.
+func (c *parser) els() {
+	if b := c.topIf(); b != nil {
+		b.run = b.unknown || !b.done
+		b.done = true
+	}
+}
+
+// endIf leaves the innermost if block.
+func (c *parser) endIf() {
+	if c.topIf() != nil {
+		c.blocks = c.blocks[:len(c.blocks)-1]
+	}
+}
+
+// endBlock leaves the innermost { } block.
+func (c *parser) endBlock() {
+	for len(c.blocks) > 0 {
+		b := c.blocks[len(c.blocks)-1]
+		c.blocks = c.blocks[:len(c.blocks)-1]
+		switch b.kind {
+		case blockIf:
+			// Unterminated if inside the block.
+			continue
+		case blockSubmenu:
+			c.numEntry = b.numEntry
+			c.prefixes = b.prefixes
+		}
+		return
+	}
+}
+
+// condition evaluates the command after if or elif.
+func (c *parser) condition(args []string) cond {
+	if len(args) == 0 {
+		return condUnknown
+	}
+	switch args[0] {
+	case "[":
+		if args[len(args)-1] != "]" {
+			return condUnknown
+		}
+		return c.test(args[1 : len(args)-1])
+	case "test":
+		return c.test(args[1:])
+	case "true":
+		return condTrue
+	case "false":
+		return condFalse
+	case "search", "search.file", "search.fs_label", "search.fs_uuid":
+		if c.search(args) {
+			return condTrue
+		}
+		return condFalse
+	case "loadfont", "background_image", "background_color":
+		// There is no graphical terminal, so fonts and
+		// backgrounds are skipped.
+		return condFalse
+	}
+	return condUnknown
+}
+
+// test evaluates the arguments of the test command.
+func (c *parser) test(args []string) cond {
+	expanded := make([]string, len(args))
+	for i, a := range args {
+		var ok bool
+		if expanded[i], ok = c.expand(a); !ok {
+			return condUnknown
+		}
+	}
+
+	// -a binds tighter than -o.
+	result := condFalse
+	for _, or := range splitArgs(expanded, "-o") {
+		and := condTrue
+		for _, expr := range splitArgs(or, "-a") {
+			and = condAnd(and, testExpr(expr))
+		}
+		result = condOr(result, and)
+	}
+	return result
+}
+
+func splitArgs(args []string, sep string) [][]string {
+	var ret [][]string
+	start := 0
+	for i, a := range args {
+		if a == sep {
+			ret = append(ret, args[start:i])
+			start = i + 1
+		}
+	}
+	return append(ret, args[start:])
+}
+
+func condAnd(a, b cond) cond {
+	switch {
+	case a == condFalse || b == condFalse:
+		return condFalse
+	case a == condUnknown || b == condUnknown:
+		return condUnknown
+	}
+	return condTrue
+}
+
+func condOr(a, b cond) cond {
+	switch {
+	case a == condTrue || b == condTrue:
+		return condTrue
+	case a == condUnknown || b == condUnknown:
+		return condUnknown
+	}
+	return condFalse
+}
+
+func toCond(b bool) cond {
+	if b {
+		return condTrue
+	}
+	return condFalse
+}
+
+// testExpr evaluates a single test expression.
+func testExpr(args []string) cond {
+	if len(args) > 0 && args[0] == "!" {
+		switch r := testExpr(args[1:]); r {
+		case condUnknown:
+			return r
+		default:
+			return toCond(r == condFalse)
+		}
+	}
+	switch len(args) {
+	case 0:
+		return condFalse
+	case 1:
+		return toCond(args[0] != "")
+	case 2:
+		switch args[0] {
+		case "-n":
+			return toCond(args[1] != "")
+		case "-z":
+			return toCond(args[1] == "")
+		}
+		// File tests depend on GRUB's view of the disks.
+		return condUnknown
+	case 3:
+		a, op, b := args[0], args[1], args[2]
+		switch op {
+		case "=", "==":
+			return toCond(a == b)
+		case "!=":
+			return toCond(a != b)
+		case "<":
+			return toCond(a < b)
+		case ">":
+			return toCond(a > b)
+		}
+		x, errx := strconv.Atoi(a)
+		y, erry := strconv.Atoi(b)
+		if errx != nil || erry != nil {
+			return condUnknown
+		}
+		switch op {
+		case "-eq":
+			return toCond(x == y)
+		case "-ne":
+			return toCond(x != y)
+		case "-lt":
+			return toCond(x < y)
+		case "-le":
+			return toCond(x <= y)
+		case "-gt":
+			return toCond(x > y)
+		case "-ge":
+			return toCond(x >= y)
+		}
+	}
+	return condUnknown
+}
+
+// menuentryID returns the --id of a menuentry or submenu.
+func (c *parser) menuentryID(args []string) string {
+	for i := 0; i < len(args); i++ {
+		a, _ := c.expand(args[i])
+		if id, ok := strings.CutPrefix(a, "--id="); ok {
+			return id
+		}
+		if a == "--id" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// entryKeys returns the names GRUB's default variable can refer to an entry
+// with number num, title and id by: its number, title and id, prefixed with
+// those of the submenus it is in and separated by '>'.
+func (c *parser) entryKeys(num int, title, id string) []string {
+	names := []string{strconv.Itoa(num), title}
+	if id != "" {
+		names = append(names, id)
+	}
+	prefixes := c.prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	var keys []string
+	for _, p := range prefixes {
+		for _, n := range names {
+			keys = append(keys, p+n)
+		}
+	}
+	return keys
+}
+
+// capture adds line to the function being defined. It returns
+// false once the function's closing brace is found.
+func (f *function) capture(line string) bool {
+	for _, cmd := range splitCommands(line) {
+		kv := strings.Fields(cmd)
+		switch {
+		case len(kv) == 1 && kv[0] == "}":
+			f.depth--
+		case len(kv) > 0 && kv[len(kv)-1] == "{":
+			f.depth++
+		}
+	}
+	if f.depth <= 0 {
+		return false
+	}
+	f.body = append(f.body, line)
+	return true
+}
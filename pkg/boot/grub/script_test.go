@@ -0,0 +1,196 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grub
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+func TestSplitCommands(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []string
+	}{
+		{in: "insmod part_gpt", want: []string{"insmod part_gpt"}},
+		{in: "if [ x ] ; then", want: []string{"if [ x ] ", " then"}},
+		{in: `echo 'a;b' "c;d" e\;f; fi`, want: []string{`echo 'a;b' "c;d" e\;f`, " fi"}},
+		{in: "fi # done; really", want: []string{"fi # done; really"}},
+		{in: "echo a#b; fi", want: []string{"echo a#b", " fi"}},
+	} {
+		if got := splitCommands(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommands(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func testParser(t *testing.T, config string) (*parser, string) {
+	t.Helper()
+	var b bytes.Buffer
+	c := newParser(&url.URL{Scheme: "file", Path: "/boot"}, block.BlockDevices{}, &mount.Pool{}, curl.DefaultSchemes)
+	c.W = &b
+	if err := c.append(context.Background(), config); err != nil {
+		t.Fatalf("append() = %v", err)
+	}
+	return c, b.String()
+}
+
+func TestConditionals(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "if else",
+			config: `set a=1
+if [ "$a" = 1 ]; then echo yes; else echo no; fi`,
+			want: []string{"yes"},
+		},
+		{
+			name: "elif",
+			config: `set a=2
+if [ "${a}" = 1 ]; then
+  echo one
+elif [ "${a}" -eq 2 -a -n "${a}" ]; then
+  echo two
+else
+  echo other
+fi`,
+			want: []string{"two"},
+		},
+		{
+			name: "features",
+			config: `if [ x$feature_platform_search_hint = xy ]; then
+  echo hint
+else
+  echo nohint
+fi`,
+			want: []string{"hint"},
+		},
+		{
+			name: "nested",
+			config: `if test -z "$root"; then
+  if true; then echo inner; fi
+  echo outer
+else
+  if true; then echo elsebranch; fi
+fi
+echo after`,
+			want: []string{"elsebranch", "after"},
+		},
+		{
+			name: "unknown variables run all branches",
+			config: `if [ "${next_entry}" ] ; then
+   echo next
+else
+   echo default
+fi`,
+			want: []string{"next", "default"},
+		},
+		{
+			name: "loadfont is skipped",
+			config: `if loadfont unicode ; then
+  echo gfxterm
+else
+  echo console
+fi`,
+			want: []string{"console"},
+		},
+		{
+			name: "functions",
+			config: `function say {
+  echo said
+  if true; then echo nested; fi
+}
+echo defined
+say`,
+			want: []string{"defined", "said", "nested"},
+		},
+		{
+			name:   "assignment",
+			config: "x=y\nif [ \"$x\" != y ]; then echo wrong; fi",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, out := testParser(t, tt.config)
+			var got []string
+			for _, l := range strings.Split(strings.TrimSpace(out), "\n") {
+				if l != "" {
+					got = append(got, strings.TrimSuffix(strings.TrimPrefix(l, `echo:[]string{"`), `"}`))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("echoed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmenu(t *testing.T) {
+	c, _ := testParser(t, `
+if [ x"${feature_menuentry_id}" = xy ]; then
+  menuentry_id_option="--id"
+else
+  menuentry_id_option=""
+fi
+menuentry 'Debian GNU/Linux' --class debian $menuentry_id_option 'gnulinux-simple' {
+	linux /vmlinuz root=/dev/sda1
+}
+submenu 'Advanced options for Debian GNU/Linux' $menuentry_id_option 'gnulinux-advanced' {
+	menuentry 'Debian GNU/Linux, with Linux 6.1.0' --class debian $menuentry_id_option 'gnulinux-6.1.0-advanced' {
+		linux /vmlinuz-6.1.0 root=/dev/sda1
+	}
+	menuentry 'Debian GNU/Linux, with Linux 6.1.0 (recovery mode)' $menuentry_id_option 'gnulinux-6.1.0-recovery' {
+		linux /vmlinuz-6.1.0 root=/dev/sda1 single
+	}
+}
+menuentry 'Memory test' {
+	linux16 /memtest86+.bin
+}
+`)
+	for key, want := range map[string]string{
+		"0":               "Debian GNU/Linux",
+		"gnulinux-simple": "Debian GNU/Linux",
+		"1>0":             "Debian GNU/Linux, with Linux 6.1.0",
+		"1>1":             "Debian GNU/Linux, with Linux 6.1.0 (recovery mode)",
+		"gnulinux-advanced>gnulinux-6.1.0-recovery":                                "Debian GNU/Linux, with Linux 6.1.0 (recovery mode)",
+		"Advanced options for Debian GNU/Linux>Debian GNU/Linux, with Linux 6.1.0": "Debian GNU/Linux, with Linux 6.1.0",
+		"Debian GNU/Linux, with Linux 6.1.0 (recovery mode)":                       "Debian GNU/Linux, with Linux 6.1.0 (recovery mode)",
+		"2": "Memory test",
+	} {
+		if e, ok := c.linuxEntries[key]; !ok || e.Name != want {
+			t.Errorf("entry %q = %v, want %q", key, e, want)
+		}
+	}
+}
+
+func TestSearchSet(t *testing.T) {
+	dev := &block.BlockDev{Name: "sda1", FsUUID: "1234-ABCD"}
+	pool := &mount.Pool{}
+	pool.Add(&mount.MountPoint{Path: "/mnt/sda1", Device: "/dev/sda1"})
+	for _, line := range []string{
+		"search --no-floppy --fs-uuid --set=root 1234-ABCD",
+		"search --no-floppy --fs-uuid --set root 1234-ABCD",
+		"search -n -u -s 1234-ABCD",
+		"search.fs_uuid 1234-ABCD root",
+	} {
+		c := newParser(&url.URL{Scheme: "file", Path: "/boot"}, block.BlockDevices{dev}, pool, curl.DefaultSchemes)
+		if err := c.append(context.Background(), line); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := c.variables["root"], "file:///mnt/sda1"; got != want {
+			t.Errorf("%q: root = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kexec
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// lockdownPath is the securityfs file describing the kernel lockdown state.
+var lockdownPath = "/sys/kernel/security/lockdown"
+
+// LockdownMode returns the active kernel lockdown mode: "none", "integrity"
+// or "confidentiality".
+//
+// If securityfs is not mounted or the kernel was built without lockdown
+// support, LockdownMode returns "none".
+func LockdownMode() (string, error) {
+	b, err := os.ReadFile(lockdownPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "none", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// The file looks like "none [integrity] confidentiality", where the
+	// active mode is in brackets.
+	for _, f := range strings.Fields(string(b)) {
+		if strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+			return strings.Trim(f, "[]"), nil
+		}
+	}
+	return "none", nil
+}
+
+// FileLoadUnsupported reports whether err, as returned by FileLoad, indicates
+// that kexec_file_load is not available on this platform or kernel.
+//
+// Errors such as EKEYREJECTED or EPERM mean the kernel did run
+// kexec_file_load but refused the image (e.g. signature or IMA appraisal
+// failure); callers must not fall back to kexec_load in that case.
+func FileLoadUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOSYS)
+}
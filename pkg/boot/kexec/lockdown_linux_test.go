@@ -0,0 +1,62 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kexec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLockdownMode(t *testing.T) {
+	dir := t.TempDir()
+	old := lockdownPath
+	defer func() { lockdownPath = old }()
+
+	for _, tt := range []struct {
+		content string
+		want    string
+	}{
+		{content: "[none] integrity confidentiality\n", want: "none"},
+		{content: "none [integrity] confidentiality\n", want: "integrity"},
+		{content: "none integrity [confidentiality]\n", want: "confidentiality"},
+		{content: "", want: "none"},
+	} {
+		lockdownPath = filepath.Join(dir, "lockdown")
+		if err := os.WriteFile(lockdownPath, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LockdownMode()
+		if err != nil || got != tt.want {
+			t.Errorf("LockdownMode(%q) = %q, %v, want %q, nil", tt.content, got, err, tt.want)
+		}
+	}
+
+	lockdownPath = filepath.Join(dir, "does-not-exist")
+	if got, err := LockdownMode(); err != nil || got != "none" {
+		t.Errorf("LockdownMode(missing) = %q, %v, want none, nil", got, err)
+	}
+}
+
+func TestFileLoadUnsupported(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: syscall.ENOSYS, want: true},
+		{err: fmt.Errorf("SYS_kexec_file_load: %w", syscall.ENOSYS), want: true},
+		{err: fmt.Errorf("SYS_kexec_file_load: %w", unix.EKEYREJECTED), want: false},
+		{err: syscall.EPERM, want: false},
+	} {
+		if got := FileLoadUnsupported(tt.err); got != tt.want {
+			t.Errorf("FileLoadUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -23,10 +23,13 @@ import (
 type LinuxImage struct {
 	Name string
 
-	Kernel      io.ReaderAt
-	Initrd      io.ReaderAt
-	Cmdline     string
-	BootRank    int
+	Kernel   io.ReaderAt
+	Initrd   io.ReaderAt
+	Cmdline  string
+	BootRank int
+	// LoadSyscall forces the use of kexec_load. By default, Load uses
+	// kexec_file_load and only falls back to kexec_load if the former is
+	// not supported by the running kernel.
 	LoadSyscall bool
 	DTB         io.ReaderAt
 
@@ -37,12 +40,23 @@ type LinuxImage struct {
 	// free to use this memory unless some other mechanism (such as
 	// memmap=) reserves it.
 	ReservedRanges kexec.Ranges
+
+	// KernelSignature is an optional detached signature of Kernel,
+	// checked if Load is given a KernelVerifier.
+	KernelSignature io.ReaderAt
 }
 
 var _ OSImage = &LinuxImage{}
 
 var errNilKernel = errors.New("kernel image is empty, nothing to execute")
 
+// Syscall wrappers, replaceable in tests.
+var (
+	kexecFileLoad = kexec.FileLoad
+	kexecLoad     = linux.KexecLoad
+	lockdownMode  = kexec.LockdownMode
+)
+
 // named is satisifed by *os.File.
 type named interface {
 	Name() string
@@ -224,6 +238,20 @@ func (li *LinuxImage) loadImage(loadOpts *loadOptions) (*os.File, *os.File, erro
 	return k, i, nil
 }
 
+// measure measures the kernel, initramfs and command line about to be
+// loaded with m.
+func (li *LinuxImage) measure(m Measurer, k, i *os.File) error {
+	if err := m.Measure(KernelPCR, io.NewSectionReader(k, 0, 1<<62), "kernel"); err != nil {
+		return err
+	}
+	if i != nil {
+		if err := m.Measure(InitrdPCR, io.NewSectionReader(i, 0, 1<<62), "initrd"); err != nil {
+			return err
+		}
+	}
+	return m.Measure(CmdlinePCR, strings.NewReader(li.Cmdline), "kernel_cmdline: "+li.Cmdline)
+}
+
 // Load implements OSImage.Load and kexec_load's the kernel with its initramfs.
 func (li *LinuxImage) Load(opts ...LoadOption) error {
 	loadOpts := defaultLoadOptions()
@@ -247,11 +275,40 @@ func (li *LinuxImage) Load(opts ...LoadOption) error {
 	loadOpts.logger.Printf("Command line: %s", li.Cmdline)
 	loadOpts.logger.Printf("DTB: %#v", li.DTB)
 
+	if v := loadOpts.kernelVerifier; v != nil {
+		fi, err := k.Stat()
+		if err != nil {
+			return err
+		}
+		if err := v.VerifyKernel(k, fi.Size(), li.KernelSignature); err != nil {
+			return err
+		}
+	}
 	if !loadOpts.callKexecLoad {
 		return nil
 	}
+	if m := loadOpts.measurer; m != nil {
+		if err := li.measure(m, k, i); err != nil {
+			return err
+		}
+	}
 	if li.LoadSyscall {
-		return linux.KexecLoad(k, i, li.Cmdline, li.DTB, li.ReservedRanges)
+		return kexecLoad(k, i, li.Cmdline, li.DTB, li.ReservedRanges)
+	}
+
+	// Prefer kexec_file_load: the kernel itself parses and verifies the
+	// image, which is what signed-kernel policies, IMA appraisal and
+	// lockdown mode require.
+	err = kexecFileLoad(k, i, li.Cmdline)
+	if !kexec.FileLoadUnsupported(err) {
+		return err
+	}
+
+	// kexec_load is refused by the kernel in any lockdown mode, so don't
+	// bother trying and report the original error.
+	if mode, lerr := lockdownMode(); lerr == nil && mode != "none" {
+		return fmt.Errorf("kexec_file_load unavailable and kexec_load is blocked by lockdown mode %q: %w", mode, err)
 	}
-	return kexec.FileLoad(k, i, li.Cmdline)
+	loadOpts.logger.Printf("kexec_file_load unavailable (%v), falling back to kexec_load", err)
+	return kexecLoad(k, i, li.Cmdline, li.DTB, li.ReservedRanges)
 }
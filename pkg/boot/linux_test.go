@@ -16,6 +16,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/u-root/u-root/pkg/boot/kexec"
 	"github.com/u-root/u-root/pkg/curl"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/uio/uio"
@@ -294,3 +295,79 @@ func TestLoadLinuxImage(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadFileLoadFallback(t *testing.T) {
+	oldFileLoad, oldLoad, oldLockdown := kexecFileLoad, kexecLoad, lockdownMode
+	defer func() {
+		kexecFileLoad, kexecLoad, lockdownMode = oldFileLoad, oldLoad, oldLockdown
+	}()
+
+	errRejected := fmt.Errorf("SYS_kexec_file_load: %w", unix.EKEYREJECTED)
+	errNoSys := fmt.Errorf("SYS_kexec_file_load: %w", unix.ENOSYS)
+
+	for _, tt := range []struct {
+		name         string
+		loadSyscall  bool
+		fileLoadErr  error
+		lockdown     string
+		wantFileLoad bool
+		wantLoad     bool
+		wantErr      error
+	}{
+		{
+			name:         "file load succeeds",
+			wantFileLoad: true,
+		},
+		{
+			name:        "forced kexec_load",
+			loadSyscall: true,
+			wantLoad:    true,
+		},
+		{
+			name:         "signature rejected, no fallback",
+			fileLoadErr:  errRejected,
+			wantFileLoad: true,
+			wantErr:      unix.EKEYREJECTED,
+		},
+		{
+			name:         "unsupported, fallback",
+			fileLoadErr:  errNoSys,
+			lockdown:     "none",
+			wantFileLoad: true,
+			wantLoad:     true,
+		},
+		{
+			name:         "unsupported, locked down",
+			fileLoadErr:  errNoSys,
+			lockdown:     "integrity",
+			wantFileLoad: true,
+			wantErr:      unix.ENOSYS,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFileLoad, gotLoad bool
+			kexecFileLoad = func(kernel, ramfs *os.File, cmdline string) error {
+				gotFileLoad = true
+				return tt.fileLoadErr
+			}
+			kexecLoad = func(kernel, ramfs *os.File, cmdline string, dtb io.ReaderAt, reservedRanges kexec.Ranges) error {
+				gotLoad = true
+				return nil
+			}
+			lockdownMode = func() (string, error) {
+				return tt.lockdown, nil
+			}
+
+			li := &LinuxImage{
+				Kernel:      strings.NewReader("testkernel"),
+				LoadSyscall: tt.loadSyscall,
+			}
+			if err := li.Load(WithLogger(ulogtest.Logger{TB: t})); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Load() = %v, want %v", err, tt.wantErr)
+			}
+			if gotFileLoad != tt.wantFileLoad || gotLoad != tt.wantLoad {
+				t.Errorf("kexec_file_load called = %v, kexec_load called = %v, want %v, %v", gotFileLoad, gotLoad, tt.wantFileLoad, tt.wantLoad)
+			}
+		})
+	}
+}
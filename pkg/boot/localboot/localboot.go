@@ -7,6 +7,9 @@ package localboot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 	"sort"
 
 	"github.com/u-root/u-root/pkg/boot"
@@ -14,6 +17,9 @@ import (
 	"github.com/u-root/u-root/pkg/boot/esxi"
 	"github.com/u-root/u-root/pkg/boot/grub"
 	"github.com/u-root/u-root/pkg/boot/syslinux"
+	"github.com/u-root/u-root/pkg/boot/verity"
+	"github.com/u-root/u-root/pkg/luks"
+	"github.com/u-root/u-root/pkg/lvm"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/ulog"
@@ -81,8 +87,151 @@ func parseUnmounted(l ulog.Logger, device *block.BlockDev, mountPool *mount.Pool
 	return images
 }
 
+// Option configures Localboot.
+type Option func(*options)
+
+type options struct {
+	luksKeys []luks.KeySource
+	lvm      bool
+	verity   *verity.Config
+}
+
+// WithLUKSKeys unlocks LUKS-encrypted block devices with the given key
+// sources, tried in order, and scans the decrypted devices for boot
+// configurations. Without this option, encrypted devices are skipped.
+func WithLUKSKeys(sources ...luks.KeySource) Option {
+	return func(o *options) {
+		o.luksKeys = append(o.luksKeys, sources...)
+	}
+}
+
+// WithLVM activates the logical volumes of LVM2 volume groups found on the
+// block devices, after unlocking LUKS devices, and scans them for boot
+// configurations. Logical volumes are activated read-only.
+func WithLVM() Option {
+	return func(o *options) {
+		o.lvm = true
+	}
+}
+
+// WithVerity sets up the dm-verity device described by cfg, after
+// unlocking LUKS devices and activating LVM volumes, and only scans it for
+// boot configurations. Localboot fails if the device cannot be set up.
+func WithVerity(cfg *verity.Config) Option {
+	return func(o *options) {
+		o.verity = cfg
+	}
+}
+
+// Replaced in tests.
+var (
+	isLUKS      = isLUKSDevice
+	luksOpen    = openLUKS
+	lvmActivate = activateLVM
+	veritySetup = setupVerity
+)
+
+// setupVerity creates the verity device of cfg as /dev/mapper/verity-root.
+func setupVerity(cfg *verity.Config, devices block.BlockDevices) (*block.BlockDev, error) {
+	d, err := verity.Setup(cfg, "verity-root", devices)
+	if err != nil {
+		return nil, err
+	}
+	return block.Device(d.DevicePath())
+}
+
+func isLUKSDevice(device *block.BlockDev) bool {
+	f, err := os.Open(device.DevicePath())
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return luks.IsLUKS(f)
+}
+
+// openLUKS maps device to /dev/mapper/luks-<name> and returns the mapped
+// device.
+func openLUKS(device *block.BlockDev, sources []luks.KeySource) (*block.BlockDev, error) {
+	d, err := luks.Open(device.DevicePath(), "luks-"+device.Name, sources...)
+	if err != nil {
+		return nil, err
+	}
+	return block.Device(d.DevicePath())
+}
+
+// unlock replaces LUKS devices in blockDevs by their decrypted mappings.
+func unlock(l ulog.Logger, blockDevs block.BlockDevices, sources []luks.KeySource) block.BlockDevices {
+	var devs block.BlockDevices
+	for _, device := range blockDevs {
+		if !isLUKS(device) {
+			devs = append(devs, device)
+			continue
+		}
+		if len(sources) == 0 {
+			l.Printf("Skipping encrypted device %s: no LUKS key sources", device)
+			continue
+		}
+		d, err := luksOpen(device, sources)
+		if err != nil {
+			l.Printf("Could not unlock %s: %v", device, err)
+			continue
+		}
+		l.Printf("Unlocked %s as %s", device, d)
+		devs = append(devs, d)
+	}
+	return devs
+}
+
+// activateLVM activates all logical volumes on devices and returns their
+// block devices.
+func activateLVM(devices block.BlockDevices) (block.BlockDevices, error) {
+	var paths []string
+	for _, d := range devices {
+		paths = append(paths, d.DevicePath())
+	}
+	vgs, errs := lvm.Scan(paths)
+	var lvs block.BlockDevices
+	for _, vg := range vgs {
+		dms, err := vg.ActivateAll(true)
+		errs = errors.Join(errs, err)
+		for _, d := range dms {
+			b, err := block.Device(d.DevicePath())
+			if err != nil {
+				errs = errors.Join(errs, err)
+				continue
+			}
+			lvs = append(lvs, b)
+		}
+	}
+	return lvs, errs
+}
+
 // Localboot tries to boot from any local filesystem by parsing grub configuration
-func Localboot(l ulog.Logger, blockDevs block.BlockDevices, mp *mount.Pool) ([]boot.OSImage, error) {
+func Localboot(l ulog.Logger, blockDevs block.BlockDevices, mp *mount.Pool, opts ...Option) ([]boot.OSImage, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	blockDevs = unlock(l, blockDevs, o.luksKeys)
+	if o.lvm {
+		lvs, err := lvmActivate(blockDevs)
+		if err != nil {
+			l.Printf("Activating LVM volumes: %v", err)
+		}
+		for _, d := range lvs {
+			l.Printf("Activated logical volume %s", d)
+		}
+		blockDevs = append(blockDevs, lvs...)
+	}
+	if o.verity != nil {
+		d, err := veritySetup(o.verity, blockDevs)
+		if err != nil {
+			return nil, fmt.Errorf("setting up verity device: %w", err)
+		}
+		l.Printf("Verified %s as %s", o.verity.DataDevice, d)
+		blockDevs = block.BlockDevices{d}
+	}
+
 	var images []boot.OSImage
 	for _, device := range blockDevs {
 		imgs := parseUnmounted(l, device, mp)
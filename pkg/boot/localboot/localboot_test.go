@@ -0,0 +1,93 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localboot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot/verity"
+	"github.com/u-root/u-root/pkg/luks"
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/ulog/ulogtest"
+)
+
+func TestUnlock(t *testing.T) {
+	defer func(i func(*block.BlockDev) bool, o func(*block.BlockDev, []luks.KeySource) (*block.BlockDev, error)) {
+		isLUKS, luksOpen = i, o
+	}(isLUKS, luksOpen)
+
+	isLUKS = func(d *block.BlockDev) bool { return d.Name == "sda2" || d.Name == "sdb1" }
+	luksOpen = func(d *block.BlockDev, _ []luks.KeySource) (*block.BlockDev, error) {
+		if d.Name == "sdb1" {
+			return nil, luks.ErrWrongKey
+		}
+		return &block.BlockDev{Name: "dm-0"}, nil
+	}
+
+	devs := block.BlockDevices{{Name: "sda1"}, {Name: "sda2"}, {Name: "sdb1"}}
+	l := &ulogtest.Logger{TB: t}
+	key := func(string, *luks.Volume) ([]byte, error) { return nil, errors.New("unused") }
+
+	got := unlock(l, devs, []luks.KeySource{key})
+	if want := (block.BlockDevices{{Name: "sda1"}, {Name: "dm-0"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("unlock() = %v, want %v", got, want)
+	}
+
+	// Without key sources, encrypted devices are skipped.
+	got = unlock(l, devs, nil)
+	if want := (block.BlockDevices{{Name: "sda1"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("unlock() = %v, want %v", got, want)
+	}
+}
+
+func TestLocalbootLVM(t *testing.T) {
+	defer func(a func(block.BlockDevices) (block.BlockDevices, error)) { lvmActivate = a }(lvmActivate)
+
+	var scanned block.BlockDevices
+	lvmActivate = func(devs block.BlockDevices) (block.BlockDevices, error) {
+		scanned = devs
+		return block.BlockDevices{{Name: "dm-1"}}, errors.New("vg0/pool: unsupported")
+	}
+	l := &ulogtest.Logger{TB: t}
+	devs := block.BlockDevices{{Name: "nonexistent0"}}
+
+	if _, err := Localboot(l, devs, &mount.Pool{}); err != nil || scanned != nil {
+		t.Errorf("Localboot() without WithLVM = %v, scanned %v; want nil, nothing", err, scanned)
+	}
+	if _, err := Localboot(l, devs, &mount.Pool{}, WithLVM()); err != nil {
+		t.Errorf("Localboot(WithLVM()) = %v", err)
+	}
+	if !reflect.DeepEqual(scanned, devs) {
+		t.Errorf("WithLVM scanned %v, want %v", scanned, devs)
+	}
+}
+
+func TestLocalbootVerity(t *testing.T) {
+	defer func(s func(*verity.Config, block.BlockDevices) (*block.BlockDev, error)) { veritySetup = s }(veritySetup)
+
+	l := &ulogtest.Logger{TB: t}
+	devs := block.BlockDevices{{Name: "nonexistent0"}}
+	cfg := &verity.Config{DataDevice: "PARTLABEL=root", RootHash: []byte{1}}
+
+	veritySetup = func(c *verity.Config, d block.BlockDevices) (*block.BlockDev, error) {
+		if c != cfg || !reflect.DeepEqual(d, devs) {
+			t.Errorf("veritySetup(%+v, %v), want (%+v, %v)", c, d, cfg, devs)
+		}
+		return nil, verity.ErrRootHash
+	}
+	if _, err := Localboot(l, devs, &mount.Pool{}, WithVerity(cfg)); !errors.Is(err, verity.ErrRootHash) {
+		t.Errorf("Localboot(WithVerity()) = %v, want %v", err, verity.ErrRootHash)
+	}
+
+	veritySetup = func(*verity.Config, block.BlockDevices) (*block.BlockDev, error) {
+		return &block.BlockDev{Name: "nonexistent-dm"}, nil
+	}
+	if _, err := Localboot(l, devs, &mount.Pool{}, WithVerity(cfg)); err != nil {
+		t.Errorf("Localboot(WithVerity()) = %v", err)
+	}
+}
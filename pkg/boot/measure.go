@@ -0,0 +1,104 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boot
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// PCRs Load measures into. They follow GRUB: the command line goes to the
+// boot configuration PCR, kernel and initramfs to PCR 9.
+const (
+	CmdlinePCR uint32 = 8
+	KernelPCR  uint32 = 9
+	InitrdPCR  uint32 = 9
+)
+
+// Measurer records a measurement of a boot component before it is loaded.
+type Measurer interface {
+	// Measure hashes r and extends the digest into pcr. description is
+	// logged along with it.
+	Measure(pcr uint32, r io.Reader, description string) error
+}
+
+// WithMeasurer is a LoadOption that measures the kernel, initramfs and
+// command line with m before loading them. Load fails if a measurement
+// fails.
+func WithMeasurer(m Measurer) LoadOption {
+	return func(o *loadOptions) {
+		o.measurer = m
+	}
+}
+
+type pcrExtender interface {
+	Extend(hash []byte, pcr uint32) error
+	Bank() crypto.Hash
+	Close() error
+}
+
+// TPMMeasurer extends measurements into the TPM and records them in a TCG
+// event log.
+type TPMMeasurer struct {
+	tpm     pcrExtender
+	log     *tss.EventLog
+	logPath string
+}
+
+// NewTPMMeasurer opens the system TPM. If logPath is not empty, the event
+// log is written there after each measurement.
+func NewTPMMeasurer(logPath string) (*TPMMeasurer, error) {
+	t, err := tss.NewTPM()
+	if err != nil {
+		return nil, err
+	}
+	m, err := newTPMMeasurer(t, logPath)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newTPMMeasurer(t pcrExtender, logPath string) (*TPMMeasurer, error) {
+	l, err := tss.NewEventLog(t.Bank())
+	if err != nil {
+		return nil, err
+	}
+	return &TPMMeasurer{tpm: t, log: l, logPath: logPath}, nil
+}
+
+// Measure implements Measurer.
+func (m *TPMMeasurer) Measure(pcr uint32, r io.Reader, description string) error {
+	h := m.tpm.Bank().New()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("measuring %s: %w", description, err)
+	}
+	digest := h.Sum(nil)
+	if err := m.tpm.Extend(digest, pcr); err != nil {
+		return fmt.Errorf("extending PCR %d with %s: %w", pcr, description, err)
+	}
+	if err := m.log.Add(pcr, tss.EventIPL, [][]byte{digest}, []byte(description)); err != nil {
+		return err
+	}
+	if m.logPath != "" {
+		return os.WriteFile(m.logPath, m.log.Bytes(), 0o644)
+	}
+	return nil
+}
+
+// EventLog returns the log of all measurements so far.
+func (m *TPMMeasurer) EventLog() *tss.EventLog {
+	return m.log
+}
+
+// Close closes the TPM.
+func (m *TPMMeasurer) Close() error {
+	return m.tpm.Close()
+}
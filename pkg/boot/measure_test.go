@@ -0,0 +1,124 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+type measurement struct {
+	pcr         uint32
+	data        string
+	description string
+}
+
+type fakeMeasurer struct {
+	got []measurement
+	err error
+}
+
+func (f *fakeMeasurer) Measure(pcr uint32, r io.Reader, description string) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.got = append(f.got, measurement{pcr, string(b), description})
+	return f.err
+}
+
+func TestLoadMeasure(t *testing.T) {
+	oldFileLoad := kexecFileLoad
+	defer func() { kexecFileLoad = oldFileLoad }()
+	var loaded bool
+	kexecFileLoad = func(kernel, ramfs *os.File, cmdline string) error {
+		loaded = true
+		return nil
+	}
+
+	li := &LinuxImage{
+		Kernel:  strings.NewReader("testkernel"),
+		Initrd:  strings.NewReader("testinitrd"),
+		Cmdline: "console=ttyS0",
+	}
+	m := &fakeMeasurer{}
+	if err := li.Load(WithMeasurer(m)); err != nil || !loaded {
+		t.Fatalf("Load() = %v, loaded %v; want nil, true", err, loaded)
+	}
+	want := []measurement{
+		{KernelPCR, "testkernel", "kernel"},
+		{InitrdPCR, "testinitrd", "initrd"},
+		{CmdlinePCR, "console=ttyS0", "kernel_cmdline: console=ttyS0"},
+	}
+	if !reflect.DeepEqual(m.got, want) {
+		t.Errorf("Load() measured %v, want %v", m.got, want)
+	}
+
+	// Dry runs extend nothing.
+	m = &fakeMeasurer{}
+	if err := li.Load(WithMeasurer(m), WithDryRun(true)); err != nil || m.got != nil {
+		t.Errorf("Load(dry run) = %v, measured %v; want nil, nothing", err, m.got)
+	}
+
+	errTPM := errors.New("no TPM")
+	loaded = false
+	if err := li.Load(WithMeasurer(&fakeMeasurer{err: errTPM})); !errors.Is(err, errTPM) || loaded {
+		t.Errorf("Load(failing measurer) = %v, loaded %v; want %v, false", err, loaded, errTPM)
+	}
+}
+
+type fakeTPM struct {
+	pcrs map[uint32][]byte
+}
+
+func (f *fakeTPM) Extend(hash []byte, pcr uint32) error {
+	f.pcrs[pcr] = append(f.pcrs[pcr], hash...)
+	return nil
+}
+
+func (f *fakeTPM) Bank() crypto.Hash { return crypto.SHA256 }
+
+func (f *fakeTPM) Close() error { return nil }
+
+func TestTPMMeasurer(t *testing.T) {
+	tpm := &fakeTPM{pcrs: map[uint32][]byte{}}
+	logPath := filepath.Join(t.TempDir(), "eventlog")
+	m, err := newTPMMeasurer(tpm, logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Measure(9, strings.NewReader("kernel"), "kernel"); err != nil {
+		t.Fatalf("Measure() = %v", err)
+	}
+	digest := sha256.Sum256([]byte("kernel"))
+	if !bytes.Equal(tpm.pcrs[9], digest[:]) {
+		t.Errorf("PCR 9 extended with %x, want %x", tpm.pcrs[9], digest)
+	}
+
+	want, err := tss.NewEventLog(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Add(9, tss.EventIPL, [][]byte{digest[:]}, []byte("kernel")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) || !bytes.Equal(m.EventLog().Bytes(), want.Bytes()) {
+		t.Errorf("event log = %x, want %x", got, want.Bytes())
+	}
+}
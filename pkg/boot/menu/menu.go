@@ -195,18 +195,25 @@ func ShowMenuAndLoad(allowEdit bool, entries ...Entry) Entry {
 //
 // The user is left to call Entry.Exec when this function returns.
 func showMenuAndLoadFromFile(file *os.File, allowEdit bool, entries ...Entry) Entry {
-	// Clear the screen (ANSI terminal escape code for screen clear).
-	fmt.Printf("\033[1;1H\033[2J\n\n")
-	fmt.Printf("Welcome to LinuxBoot's Menu\n\n")
-	fmt.Printf("Enter a number to boot a kernel:\n")
+	if !fullScreen {
+		// Clear the screen (ANSI terminal escape code for screen clear).
+		fmt.Printf("\033[1;1H\033[2J\n\n")
+		fmt.Printf("Welcome to LinuxBoot's Menu\n\n")
+		fmt.Printf("Enter a number to boot a kernel:\n")
+	}
 
 	for {
-		t := NewTerminal(file)
-		// Allow the user to choose.
-		entry := Choose(t, allowEdit, entries...)
-		if err := t.Close(); err != nil {
-			log.Printf("Failed to close terminal made from file %s "+
-				"(desc %d): %v", file.Name(), file.Fd(), err)
+		var entry Entry
+		if fullScreen {
+			entry = chooseScreenFromFile(file, allowEdit, entries...)
+		} else {
+			t := NewTerminal(file)
+			// Allow the user to choose.
+			entry = Choose(t, allowEdit, entries...)
+			if err := t.Close(); err != nil {
+				log.Printf("Failed to close terminal made from file %s "+
+					"(desc %d): %v", file.Name(), file.Fd(), err)
+			}
 		}
 
 		if entry == nil {
@@ -264,16 +271,29 @@ func OSImages(verbose bool, imgs ...boot.OSImage) []Entry {
 	return menu
 }
 
+// ApplyLoadOptions adds opts to the LoadOptions of every OSImageAction in
+// entries.
+func ApplyLoadOptions(entries []Entry, opts ...boot.LoadOption) {
+	for _, e := range entries {
+		if oia, ok := e.(*OSImageAction); ok {
+			oia.LoadOptions = append(oia.LoadOptions, opts...)
+		}
+	}
+}
+
 // OSImageAction is a menu.Entry that boots an OSImage.
 type OSImageAction struct {
 	boot.OSImage
 	Verbose     bool
 	NoKexecLoad bool
+	// LoadOptions are passed to OSImage.Load after the options above.
+	LoadOptions []boot.LoadOption
 }
 
 // Load implements Entry.Load by loading the OS image into memory.
 func (oia OSImageAction) Load() error {
-	if err := oia.OSImage.Load(boot.WithVerbose(oia.Verbose), boot.WithDryRun(oia.NoKexecLoad)); err != nil {
+	opts := append([]boot.LoadOption{boot.WithVerbose(oia.Verbose), boot.WithDryRun(oia.NoKexecLoad)}, oia.LoadOptions...)
+	if err := oia.OSImage.Load(opts...); err != nil {
 		return fmt.Errorf("could not load image %s: %v", oia.OSImage, err)
 	}
 	return nil
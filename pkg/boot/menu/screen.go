@@ -0,0 +1,351 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package menu
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// fullScreen selects the full-screen menu over the line based one.
+var fullScreen bool
+
+// SetFullScreen selects the full-screen menu, in which entries are chosen
+// with the arrow keys and the countdown to booting the default entries is
+// shown, like in GRUB.
+func SetFullScreen(b bool) {
+	fullScreen = b
+}
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyEsc
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyBackspace
+	keyDelete
+	keyKillEnd
+	keyKillStart
+	keyBoot
+)
+
+// key is a key press. r is set for keyRune.
+type key struct {
+	code keyCode
+	r    rune
+}
+
+var controlKeys = map[byte]keyCode{
+	'\r':     keyEnter,
+	'\n':     keyEnter,
+	0x7f:     keyBackspace,
+	'\b':     keyBackspace,
+	'a' - 96: keyHome,
+	'e' - 96: keyEnd,
+	'b' - 96: keyLeft,
+	'f' - 96: keyRight,
+	'p' - 96: keyUp,
+	'n' - 96: keyDown,
+	'k' - 96: keyKillEnd,
+	'u' - 96: keyKillStart,
+	'x' - 96: keyBoot,
+	'c' - 96: keyEsc,
+	'g' - 96: keyEsc,
+}
+
+var csiKeys = map[string]keyCode{
+	"A":  keyUp,
+	"B":  keyDown,
+	"C":  keyRight,
+	"D":  keyLeft,
+	"H":  keyHome,
+	"F":  keyEnd,
+	"1~": keyHome,
+	"7~": keyHome,
+	"4~": keyEnd,
+	"8~": keyEnd,
+	"3~": keyDelete,
+}
+
+// decodeKeys decodes the keys in b, which was read from a terminal in raw
+// mode. Terminals send escape sequences in one go, so an escape at the end
+// of b is the escape key.
+func decodeKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b && len(b) > 1 && (b[1] == '[' || b[1] == 'O'):
+			// Parameters are digits and ';', followed by the final byte.
+			i := 2
+			for i < len(b) && (b[i] >= '0' && b[i] <= '9' || b[i] == ';') {
+				i++
+			}
+			if i == len(b) {
+				return keys
+			}
+			if code, ok := csiKeys[string(b[2:i+1])]; ok {
+				keys = append(keys, key{code: code})
+			}
+			b = b[i+1:]
+
+		case c == 0x1b:
+			keys = append(keys, key{code: keyEsc})
+			b = b[1:]
+
+		case controlKeys[c] != keyRune:
+			keys = append(keys, key{code: controlKeys[c]})
+			b = b[1:]
+
+		case c < 0x20:
+			b = b[1:]
+
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[n:]
+		}
+	}
+	return keys
+}
+
+// readKeys sends the keys read from r to keys until r fails or done is
+// closed.
+func readKeys(r io.Reader, keys chan<- key, done <-chan struct{}) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range decodeKeys(buf[:n]) {
+			select {
+			case keys <- k:
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type screen struct {
+	out       io.Writer
+	keys      <-chan key
+	allowEdit bool
+	entries   []Entry
+
+	selected int
+	// number is the entry number typed so far.
+	number int
+}
+
+// ChooseScreen presents a full-screen menu on out and lets the user choose
+// an entry with the keys read from in, which must be a terminal in raw mode.
+//
+// The entries are listed with the chosen one highlighted, along with a
+// countdown. If it expires or in fails, nil is returned, leaving the caller
+// to boot the default entries. A key press resets the countdown to the
+// subsequent timeout.
+//
+// If allowEdit is set, the kernel command line of the highlighted entry can
+// be edited with 'e'.
+func ChooseScreen(in io.Reader, out io.Writer, allowEdit bool, entries ...Entry) Entry {
+	keys := make(chan key)
+	done := make(chan struct{})
+	defer close(done)
+	go readKeys(in, keys, done)
+
+	s := &screen{out: out, keys: keys, allowEdit: allowEdit, entries: entries}
+	return s.choose()
+}
+
+func (s *screen) choose() Entry {
+	deadline := time.Now().Add(initialTimeout)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil
+		}
+		s.draw(left)
+
+		timeout := time.NewTimer(left)
+		select {
+		case k, ok := <-s.keys:
+			if !ok {
+				timeout.Stop()
+				return nil
+			}
+			deadline = time.Now().Add(subsequentTimeout)
+			if e := s.handle(k); e != nil {
+				timeout.Stop()
+				return e
+			}
+		case <-tick.C:
+		case <-timeout.C:
+		}
+		timeout.Stop()
+	}
+}
+
+// handle acts on k and returns the entry to boot, if any.
+func (s *screen) handle(k key) Entry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	number := s.number
+	s.number = 0
+	switch k.code {
+	case keyUp:
+		s.selected = (s.selected + len(s.entries) - 1) % len(s.entries)
+	case keyDown:
+		s.selected = (s.selected + 1) % len(s.entries)
+	case keyHome:
+		s.selected = 0
+	case keyEnd:
+		s.selected = len(s.entries) - 1
+	case keyEnter, keyBoot:
+		return s.entries[s.selected]
+	case keyRune:
+		switch {
+		case k.r >= '0' && k.r <= '9':
+			// Typing 1 and 2 selects the 12th entry, if there is one.
+			d := int(k.r - '0')
+			if n := number*10 + d; n >= 1 && n <= len(s.entries) {
+				s.number = n
+			} else if d >= 1 && d <= len(s.entries) {
+				s.number = d
+			}
+			if s.number > 0 {
+				s.selected = s.number - 1
+			}
+		case k.r == 'e' && s.allowEdit:
+			if s.edit(s.entries[s.selected]) {
+				return s.entries[s.selected]
+			}
+		}
+	}
+	return nil
+}
+
+func (s *screen) draw(left time.Duration) {
+	var b strings.Builder
+	// Clear the screen and move the cursor home.
+	b.WriteString("\033[H\033[2J\r\n  Welcome to LinuxBoot's Menu\r\n\r\n")
+	for i, e := range s.entries {
+		if i == s.selected {
+			fmt.Fprintf(&b, "\033[7m* %02d. %s\033[0m\r\n", i+1, e.Label())
+		} else {
+			fmt.Fprintf(&b, "  %02d. %s\r\n", i+1, e.Label())
+		}
+	}
+	b.WriteString("\r\n  Use the up and down keys or type a number to select an entry.\r\n")
+	b.WriteString("  Press enter to boot the selected entry")
+	if s.allowEdit {
+		b.WriteString(", 'e' to edit its kernel command line")
+	}
+	fmt.Fprintf(&b, ".\r\n  The default entries are booted in %ds.\r\n", int((left+time.Second-1)/time.Second))
+	io.WriteString(s.out, b.String())
+}
+
+// edit lets the user edit the command line of e. It returns true if the
+// user asked to boot e right away.
+func (s *screen) edit(e Entry) (boot bool) {
+	label := e.Label()
+	e.Edit(func(cmdline string) string {
+		line := []rune(cmdline)
+		pos := len(line)
+		for {
+			s.drawEditor(label, line, pos)
+			k, ok := <-s.keys
+			if !ok {
+				return cmdline
+			}
+			switch k.code {
+			case keyRune:
+				line = append(line[:pos], append([]rune{k.r}, line[pos:]...)...)
+				pos++
+			case keyLeft:
+				pos = max(pos-1, 0)
+			case keyRight:
+				pos = min(pos+1, len(line))
+			case keyHome:
+				pos = 0
+			case keyEnd:
+				pos = len(line)
+			case keyBackspace:
+				if pos > 0 {
+					line = append(line[:pos-1], line[pos:]...)
+					pos--
+				}
+			case keyDelete:
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			case keyKillEnd:
+				line = line[:pos]
+			case keyKillStart:
+				line = line[pos:]
+				pos = 0
+			case keyEnter:
+				return string(line)
+			case keyBoot:
+				boot = true
+				return string(line)
+			case keyEsc:
+				return cmdline
+			}
+		}
+	})
+	return boot
+}
+
+func (s *screen) drawEditor(label string, line []rune, pos int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\033[H\033[2J\r\n  Editing the kernel command line of %s\r\n\r\n", label)
+	b.WriteString("  Press enter to accept the changes, ctrl-x to accept them and boot,\r\n")
+	b.WriteString("  or escape to discard them.\r\n\r\n")
+	// Save the cursor position at pos, and restore it at the end.
+	fmt.Fprintf(&b, "> %s\0337%s\0338", string(line[:pos]), string(line[pos:]))
+	io.WriteString(s.out, b.String())
+}
+
+// chooseScreenFromFile shows the full-screen menu on the terminal f.
+func chooseScreenFromFile(f *os.File, allowEdit bool, entries ...Entry) Entry {
+	oldState, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		fmt.Printf("BUG: Please report: We cannot actually let you choose from menu (MakeRaw failed): %v\n", err)
+	} else {
+		defer term.Restore(int(f.Fd()), oldState)
+	}
+	// Deadlines let us stop reading keys when we are done.
+	if err := syscall.SetNonblock(int(f.Fd()), true); err != nil {
+		fmt.Printf("BUG: Error setting Fd %d to nonblocking: %v\n", f.Fd(), err)
+	}
+	_ = f.SetReadDeadline(time.Time{})
+	defer f.SetReadDeadline(time.Now())
+
+	e := ChooseScreen(f, f, allowEdit, entries...)
+	// Leave the cursor below the menu.
+	fmt.Fprint(f, "\r\n")
+	return e
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package menu
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []key
+	}{
+		{in: "ab", want: []key{{keyRune, 'a'}, {keyRune, 'b'}}},
+		{in: "ü\r", want: []key{{keyRune, 'ü'}, {code: keyEnter}}},
+		{in: "\x1b[A\x1b[B\x1bOC\x1b[D", want: []key{{code: keyUp}, {code: keyDown}, {code: keyRight}, {code: keyLeft}}},
+		{in: "\x1b[1~\x1b[4~\x1b[3~\x1b[1;5D", want: []key{{code: keyHome}, {code: keyEnd}, {code: keyDelete}}},
+		{in: "\x1b", want: []key{{code: keyEsc}}},
+		{in: "\x1bx", want: []key{{code: keyEsc}, {keyRune, 'x'}}},
+		{in: "\x7f\x01\x05\x0b\x15\x18\x03", want: []key{
+			{code: keyBackspace}, {code: keyHome}, {code: keyEnd}, {code: keyKillEnd}, {code: keyKillStart}, {code: keyBoot}, {code: keyEsc},
+		}},
+		// Incomplete escape sequences are dropped.
+		{in: "a\x1b[12", want: []key{{keyRune, 'a'}}},
+	} {
+		if got := decodeKeys([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeKeys(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestChooseScreen(t *testing.T) {
+	entries := func() []Entry {
+		var e []Entry
+		for _, l := range []string{"one", "two", "three"} {
+			e = append(e, &testEntry{label: l, cmdline: "console=ttyS0"})
+		}
+		return e
+	}
+	for _, tt := range []struct {
+		name      string
+		input     string
+		allowEdit bool
+		want      int
+		cmdline   []string
+	}{
+		{name: "enter", input: "\r", want: 1},
+		{name: "down", input: "\x1b[B\x1b[B\r", want: 3},
+		{name: "wrap around", input: "\x1b[A\r", want: 3},
+		{name: "number", input: "2\r", want: 2},
+		{name: "bad number", input: "7\r", want: 1},
+		{name: "no input", input: "", want: -1},
+		{name: "no choice", input: "\x1b[B", want: -1},
+		{
+			name:      "edit and boot",
+			input:     "\x1b[Be debug\x18",
+			allowEdit: true,
+			want:      2,
+			cmdline:   []string{"console=ttyS0", "console=ttyS0 debug", "console=ttyS0"},
+		},
+		{
+			name:      "edit in the middle",
+			input:     "e\x01\x1b[C\x1b[C\x1b[3~X\r\x1b[B\r",
+			allowEdit: true,
+			want:      2,
+			cmdline:   []string{"coXsole=ttyS0", "console=ttyS0", "console=ttyS0"},
+		},
+		{
+			name:      "discard edit",
+			input:     "e\x15root=/dev/sda\x1b\r",
+			allowEdit: true,
+			want:      1,
+			cmdline:   []string{"console=ttyS0", "console=ttyS0", "console=ttyS0"},
+		},
+		{
+			name:    "edit not allowed",
+			input:   "e\x15\r",
+			want:    1,
+			cmdline: []string{"console=ttyS0", "console=ttyS0", "console=ttyS0"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := entries()
+			var out bytes.Buffer
+			got := ChooseScreen(strings.NewReader(tt.input), &out, tt.allowEdit, e...)
+			var want Entry
+			if tt.want > 0 {
+				want = e[tt.want-1]
+			}
+			if got != want {
+				t.Errorf("ChooseScreen(%q) = %v, want %v", tt.input, got, want)
+			}
+			for i, c := range tt.cmdline {
+				if got := e[i].(*testEntry).cmdline; got != c {
+					t.Errorf("cmdline of entry %d = %q, want %q", i+1, got, c)
+				}
+			}
+			if !strings.Contains(out.String(), "Welcome to LinuxBoot's Menu") {
+				t.Errorf("ChooseScreen did not draw the menu: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestChooseScreenTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	start := time.Now()
+	var out bytes.Buffer
+	if got := ChooseScreen(r, &out, true, &testEntry{label: "one"}); got != nil {
+		t.Errorf("ChooseScreen() = %v, want nil", got)
+	}
+	if d := time.Since(start); d < initialTimeout {
+		t.Errorf("ChooseScreen() returned after %v, before the timeout of %v", d, initialTimeout)
+	}
+	if !strings.Contains(out.String(), "booted in 1s") {
+		t.Errorf("ChooseScreen() did not show the countdown: %q", out.String())
+	}
+}
@@ -3,7 +3,10 @@
 // license that can be found in the LICENSE file.
 
 // Package multiboot implements bootloading multiboot kernels as defined by
-// https://www.gnu.org/software/grub/manual/multiboot/multiboot.html.
+// https://www.gnu.org/software/grub/manual/multiboot/multiboot.html and
+// multiboot2 kernels as defined by
+// https://www.gnu.org/software/grub/manual/multiboot2/multiboot.html, such
+// as the Xen and Jailhouse hypervisors.
 //
 // Package multiboot crafts kexec segments that can be used with the kexec_load
 // system call.
@@ -129,12 +132,15 @@ func (m memoryMaps) String() string {
 	return strings.Join(s, "\n")
 }
 
-// Probe checks if `kernel` is multiboot v1 or esxBootInfo kernel.
+// Probe checks if `kernel` is multiboot v1, multiboot2 or esxBootInfo kernel.
 // If the `kernel` is gzip'ed, it will decompress it.
 // Only Gzip decmpression is supported at present.
 func Probe(kernel io.ReaderAt) error {
 	r := util.TryGzipFilter(kernel)
 	_, err := parseHeader(uio.Reader(r))
+	if err == ErrHeaderNotFound {
+		_, err = parseMB2Header(uio.Reader(r))
+	}
 	if err == ErrHeaderNotFound {
 		_, err = parseMutiHeader(uio.Reader(r))
 	}
@@ -265,6 +271,12 @@ func (m *multiboot) load(debug bool, ibft *ibft.IBFT) error {
 	if err == nil {
 		header = multibootHeader
 	} else if err == ErrHeaderNotFound {
+		var mb2 *mb2Header
+		if mb2, err = parseMB2Header(uio.Reader(m.kernel)); err == nil {
+			header = mb2
+		}
+	}
+	if err == ErrHeaderNotFound {
 		var esxBootInfoHeader *esxBootInfoHeader
 		// We don't even need the header at the moment. Just need to
 		// know it's there. Everything that matters is in the ELF.
@@ -276,18 +288,17 @@ func (m *multiboot) load(debug bool, ibft *ibft.IBFT) error {
 	}
 	log.Printf("Found %s image", header.name())
 
-	log.Printf("Getting kernel entry point")
-	kernelEntry, err := getEntryPoint(m.kernel)
+	var kernelEntry uintptr
+	if l, ok := header.(kernelLoader); ok {
+		kernelEntry, err = l.loadKernel(m)
+	} else {
+		kernelEntry, err = m.loadELF()
+	}
 	if err != nil {
-		return fmt.Errorf("error getting kernel entry point: %v", err)
+		return err
 	}
 	log.Printf("Kernel entry point at %#x", kernelEntry)
 
-	log.Printf("Parsing ELF segments")
-	if _, err := m.mem.LoadElfSegments(m.kernel); err != nil {
-		return fmt.Errorf("error loading ELF segments: %v", err)
-	}
-
 	log.Printf("Parsing memory map")
 	memmap, err := kexec.MemoryMapFromSysfsMemmap()
 	if err != nil {
@@ -337,6 +348,27 @@ func (m *multiboot) load(debug bool, ibft *ibft.IBFT) error {
 	return nil
 }
 
+// kernelLoader is implemented by image types that are not necessarily ELF
+// files.
+type kernelLoader interface {
+	loadKernel(m *multiboot) (entry uintptr, err error)
+}
+
+// loadELF loads the ELF segments of the kernel and returns its entry point.
+func (m *multiboot) loadELF() (uintptr, error) {
+	log.Printf("Getting kernel entry point")
+	kernelEntry, err := getEntryPoint(m.kernel)
+	if err != nil {
+		return 0, fmt.Errorf("error getting kernel entry point: %v", err)
+	}
+
+	log.Printf("Parsing ELF segments")
+	if _, err := m.mem.LoadElfSegments(m.kernel); err != nil {
+		return 0, fmt.Errorf("error loading ELF segments: %v", err)
+	}
+	return kernelEntry, nil
+}
+
 func getEntryPoint(r io.ReaderAt) (uintptr, error) {
 	f, err := elf.NewFile(r)
 	if err != nil {
@@ -0,0 +1,381 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package multiboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+// Multiboot2 as defined by
+// https://www.gnu.org/software/grub/manual/multiboot2/multiboot.html.
+const (
+	// mb2HeaderMagic is the magic value found in a multiboot2 kernel header.
+	mb2HeaderMagic = 0xE85250D6
+
+	// mb2BootMagic is the magic expected by the loaded OS in EAX at boot
+	// handover.
+	mb2BootMagic = 0x36D76289
+
+	// The multiboot2 header must be contained completely within the
+	// first 32768 bytes of the OS image, 64-bit aligned.
+	mb2SearchSize = 32768
+
+	mb2ArchI386 = 0
+)
+
+// Header tag types.
+const (
+	mb2HeaderTagEnd = iota
+	mb2HeaderTagInfoRequest
+	mb2HeaderTagAddress
+	mb2HeaderTagEntryAddress
+	mb2HeaderTagConsoleFlags
+	mb2HeaderTagFramebuffer
+	mb2HeaderTagModuleAlign
+	mb2HeaderTagEFIBootServices
+	mb2HeaderTagEntryAddressEFI32
+	mb2HeaderTagEntryAddressEFI64
+	mb2HeaderTagRelocatable
+)
+
+// mb2HeaderTagOptional marks header tags the image can do without.
+const mb2HeaderTagOptional = 1
+
+// Boot information tag types.
+const (
+	mb2InfoEnd             = 0
+	mb2InfoCmdline         = 1
+	mb2InfoBootLoaderName  = 2
+	mb2InfoModule          = 3
+	mb2InfoBasicMeminfo    = 4
+	mb2InfoMmap            = 6
+	mb2InfoFramebuffer     = 8
+	mb2InfoEFI32           = 11
+	mb2InfoEFI64           = 12
+	mb2InfoACPIOld         = 14
+	mb2InfoACPINew         = 15
+	mb2InfoFramebufferRGB  = 1
+	mb2MmapEntrySize       = 24
+	mb2MmapEntryVersion    = 0
+	mb2InfoHeaderSize      = 8
+	mb2TagAlignment        = 8
+	mb2FramebufferTagExtra = 6
+)
+
+// mb2Address is the address header tag of images that are not ELF files.
+type mb2Address struct {
+	HeaderAddr  uint32
+	LoadAddr    uint32
+	LoadEndAddr uint32
+	BSSEndAddr  uint32
+}
+
+// mb2Header represents a multiboot2 header loaded from the file.
+type mb2Header struct {
+	// offset is the file offset of the header.
+	offset int64
+
+	// requests are the boot information tags the image requires.
+	requests []uint32
+
+	address *mb2Address
+	entry   uint32
+
+	// framebuffer is set if the image asks for a graphics mode.
+	framebuffer bool
+}
+
+func (h *mb2Header) name() string {
+	return "multiboot2"
+}
+
+func (h *mb2Header) bootMagic() uintptr {
+	return mb2BootMagic
+}
+
+func align8(n int) int {
+	return (n + mb2TagAlignment - 1) &^ (mb2TagAlignment - 1)
+}
+
+// parseMB2Header parses a multiboot2 header as defined in
+// https://www.gnu.org/software/grub/manual/multiboot2/multiboot.html#OS-image-format
+func parseMB2Header(r io.Reader) (*mb2Header, error) {
+	buf := make([]byte, mb2SearchSize)
+	n, err := io.ReadAtLeast(r, buf, 16)
+	if err != nil {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	ne := binary.NativeEndian
+	for off := 0; off+16 <= len(buf); off += 8 {
+		magic, arch := ne.Uint32(buf[off:]), ne.Uint32(buf[off+4:])
+		length, checksum := ne.Uint32(buf[off+8:]), ne.Uint32(buf[off+12:])
+		if magic != mb2HeaderMagic || magic+arch+length+checksum != 0 {
+			continue
+		}
+		if arch != mb2ArchI386 {
+			return nil, fmt.Errorf("%w: multiboot2 architecture %d", ErrFlagsNotSupported, arch)
+		}
+		if length < 16 || uint64(off)+uint64(length) > uint64(len(buf)) {
+			return nil, fmt.Errorf("multiboot2 header of %d bytes at %#x is truncated", length, off)
+		}
+		h := &mb2Header{offset: int64(off)}
+		if err := h.parseTags(buf[off+16 : off+int(length)]); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+	return nil, ErrHeaderNotFound
+}
+
+func (h *mb2Header) parseTags(b []byte) error {
+	ne := binary.NativeEndian
+	for len(b) >= 8 {
+		typ, flags, size := ne.Uint16(b), ne.Uint16(b[2:]), int(ne.Uint32(b[4:]))
+		if size < 8 || size > len(b) {
+			return fmt.Errorf("multiboot2 header tag %d has bad size %d", typ, size)
+		}
+		body := b[8:size]
+		optional := flags&mb2HeaderTagOptional != 0
+
+		switch typ {
+		case mb2HeaderTagEnd:
+			return nil
+
+		case mb2HeaderTagInfoRequest:
+			if optional {
+				break
+			}
+			for ; len(body) >= 4; body = body[4:] {
+				h.requests = append(h.requests, ne.Uint32(body))
+			}
+
+		case mb2HeaderTagAddress:
+			var a mb2Address
+			if err := binary.Read(bytes.NewReader(body), ne, &a); err != nil {
+				return fmt.Errorf("multiboot2 address tag: %w", err)
+			}
+			h.address = &a
+
+		case mb2HeaderTagEntryAddress:
+			if len(body) < 4 {
+				return errors.New("multiboot2 entry address tag is too short")
+			}
+			h.entry = ne.Uint32(body)
+
+		case mb2HeaderTagFramebuffer:
+			h.framebuffer = true
+
+		case mb2HeaderTagConsoleFlags, mb2HeaderTagModuleAlign, mb2HeaderTagRelocatable,
+			mb2HeaderTagEntryAddressEFI32, mb2HeaderTagEntryAddressEFI64:
+			// Modules are always page aligned, and the image is
+			// loaded at its preferred address. EFI entry points
+			// need boot services, which Linux has exited, so the
+			// i386 entry point is used instead.
+
+		case mb2HeaderTagEFIBootServices:
+			if !optional {
+				return fmt.Errorf("%w: image needs EFI boot services", ErrFlagsNotSupported)
+			}
+
+		default:
+			if !optional {
+				return fmt.Errorf("%w: multiboot2 header tag %d", ErrFlagsNotSupported, typ)
+			}
+		}
+		if size = align8(size); size > len(b) {
+			size = len(b)
+		}
+		b = b[size:]
+	}
+	return errors.New("multiboot2 header has no end tag")
+}
+
+// loadKernel loads the kernel image, either as an ELF file or as a flat
+// image at the place given by the address tag. It returns the entry point.
+func (h *mb2Header) loadKernel(m *multiboot) (uintptr, error) {
+	var entry uintptr
+	if a := h.address; a == nil {
+		var err error
+		if entry, err = m.loadELF(); err != nil {
+			return 0, err
+		}
+	} else {
+		if a.LoadAddr > a.HeaderAddr || int64(a.HeaderAddr-a.LoadAddr) > h.offset {
+			return 0, fmt.Errorf("multiboot2 load address %#x is not before header %#x", a.LoadAddr, a.HeaderAddr)
+		}
+		size := int64(1<<62 - 1)
+		if a.LoadEndAddr != 0 {
+			if a.LoadEndAddr < a.LoadAddr {
+				return 0, fmt.Errorf("multiboot2 load end address %#x is before load address %#x", a.LoadEndAddr, a.LoadAddr)
+			}
+			size = int64(a.LoadEndAddr - a.LoadAddr)
+		}
+		data, err := io.ReadAll(io.NewSectionReader(m.kernel, h.offset-int64(a.HeaderAddr-a.LoadAddr), size))
+		if err != nil {
+			return 0, err
+		}
+		memSize := uint(len(data))
+		if end := uint(a.BSSEndAddr); end > uint(a.LoadAddr)+memSize {
+			memSize = end - uint(a.LoadAddr)
+		}
+		m.mem.Segments.Insert(kexec.NewSegment(data, kexec.Range{Start: uintptr(a.LoadAddr), Size: memSize}))
+	}
+	if h.entry != 0 {
+		entry = uintptr(h.entry)
+	}
+	if entry == 0 {
+		return 0, errors.New("multiboot2 image without ELF has no entry address tag")
+	}
+	return entry, nil
+}
+
+// mb2Info is the multiboot2 boot information, a list of tags.
+type mb2Info struct {
+	buf  bytes.Buffer
+	tags map[uint32]bool
+}
+
+func (i *mb2Info) add(typ uint32, data ...any) {
+	var body bytes.Buffer
+	for _, d := range data {
+		if s, ok := d.(string); ok {
+			body.WriteString(s)
+			body.WriteByte(0)
+			continue
+		}
+		binary.Write(&body, binary.NativeEndian, d)
+	}
+	binary.Write(&i.buf, binary.NativeEndian, [2]uint32{typ, uint32(8 + body.Len())})
+	i.buf.Write(body.Bytes())
+	i.buf.Write(make([]byte, align8(i.buf.Len())-i.buf.Len()))
+	if i.tags == nil {
+		i.tags = map[uint32]bool{}
+	}
+	i.tags[typ] = true
+}
+
+// marshal returns the boot information with its fixed part and end tag.
+func (i *mb2Info) marshal() []byte {
+	end := [2]uint32{mb2InfoEnd, 8}
+	var b bytes.Buffer
+	binary.Write(&b, binary.NativeEndian, [2]uint32{uint32(mb2InfoHeaderSize + i.buf.Len() + 8), 0})
+	b.Write(i.buf.Bytes())
+	binary.Write(&b, binary.NativeEndian, end)
+	return b.Bytes()
+}
+
+// Framebuffer describes a linear RGB framebuffer handed to the loaded OS.
+type Framebuffer struct {
+	Addr          uint64
+	Pitch         uint32
+	Width         uint32
+	Height        uint32
+	BPP           uint8
+	RedPos        uint8
+	RedMaskSize   uint8
+	GreenPos      uint8
+	GreenMaskSize uint8
+	BluePos       uint8
+	BlueMaskSize  uint8
+}
+
+// EFI describes the firmware the running kernel was booted by.
+type EFI struct {
+	// SystemTable is the physical address of the EFI system table.
+	SystemTable uint64
+	// Is64 is set for 64-bit firmware.
+	Is64 bool
+}
+
+// Platform information for multiboot2 boot information, replaceable in
+// tests.
+var (
+	mb2Framebuffer = currentFramebuffer
+	mb2EFI         = currentEFI
+	mb2RSDP        = currentRSDP
+)
+
+// newMB2Info collects the boot information for the image.
+func (h *mb2Header) newMB2Info(m *multiboot) (*mb2Info, error) {
+	var inf mb2Info
+	inf.add(mb2InfoCmdline, m.cmdLine)
+	inf.add(mb2InfoBootLoaderName, m.bootloader)
+
+	if len(m.modules) > 0 {
+		mods, err := m.loadModules()
+		if err != nil {
+			return nil, err
+		}
+		for i, mod := range mods {
+			inf.add(mb2InfoModule, mod.Start, mod.End, m.modules[i].Cmdline)
+		}
+	}
+
+	lower, upper := m.memoryBoundaries()
+	inf.add(mb2InfoBasicMeminfo, lower>>10, upper>>10)
+
+	var mmap []any
+	mmap = append(mmap, uint32(mb2MmapEntrySize), uint32(mb2MmapEntryVersion))
+	for _, mm := range m.memoryMap() {
+		mmap = append(mmap, mm.BaseAddr, mm.Length, mm.Type, uint32(0))
+	}
+	inf.add(mb2InfoMmap, mmap...)
+
+	if fb, err := mb2Framebuffer(); err == nil {
+		inf.add(mb2InfoFramebuffer, fb.Addr, fb.Pitch, fb.Width, fb.Height, fb.BPP, uint8(mb2InfoFramebufferRGB), uint16(0),
+			[mb2FramebufferTagExtra]uint8{fb.RedPos, fb.RedMaskSize, fb.GreenPos, fb.GreenMaskSize, fb.BluePos, fb.BlueMaskSize})
+	} else if h.framebuffer {
+		log.Printf("Image asks for a framebuffer, but none is available: %v", err)
+	}
+
+	if efi, err := mb2EFI(); err == nil {
+		if efi.Is64 {
+			inf.add(mb2InfoEFI64, efi.SystemTable)
+		} else {
+			inf.add(mb2InfoEFI32, uint32(efi.SystemTable))
+		}
+	}
+
+	if rsdp, err := mb2RSDP(); err == nil && len(rsdp) >= 20 {
+		// The revision of ACPI 1.0 RSDPs is 0, later ones are 2.
+		if rsdp[15] == 0 {
+			inf.add(mb2InfoACPIOld, rsdp[:20])
+		} else {
+			inf.add(mb2InfoACPINew, rsdp)
+		}
+	}
+
+	for _, t := range h.requests {
+		if !inf.tags[t] {
+			return nil, fmt.Errorf("image requires multiboot2 information tag %d, which is not available", t)
+		}
+	}
+	return &inf, nil
+}
+
+// addInfo adds the multiboot2 boot information into the segments.
+func (h *mb2Header) addInfo(m *multiboot) (addr uintptr, err error) {
+	inf, err := h.newMB2Info(m)
+	if err != nil {
+		return 0, err
+	}
+	b := inf.marshal()
+	r, err := m.mem.FindSpace(uint(len(b)), uint(os.Getpagesize()))
+	if err != nil {
+		return 0, err
+	}
+	m.mem.Segments.Insert(kexec.NewSegment(b, r))
+	return r.Start, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package multiboot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/u-root/u-root/pkg/acpi"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	fbDevice           = "/dev/fb0"
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+
+	bootParams = "/sys/kernel/boot_params/data"
+
+	// Offsets of struct efi_info in struct boot_params.
+	efiLoaderSignature = 0x1c0
+	efiSystabLo        = 0x1c4
+	efiSystabHi        = 0x1d8
+)
+
+// fbFixScreenInfo is struct fb_fix_screeninfo.
+type fbFixScreenInfo struct {
+	ID           [16]byte
+	SmemStart    uint64
+	SmemLen      uint32
+	Type         uint32
+	TypeAux      uint32
+	Visual       uint32
+	XPanStep     uint16
+	YPanStep     uint16
+	YWrapStep    uint16
+	LineLength   uint32
+	MMIOStart    uint64
+	MMIOLen      uint32
+	Accel        uint32
+	Capabilities uint16
+	Reserved     [2]uint16
+}
+
+type fbBitfield struct {
+	Offset   uint32
+	Length   uint32
+	MSBRight uint32
+}
+
+// fbVarScreenInfo is struct fb_var_screeninfo.
+type fbVarScreenInfo struct {
+	XRes, YRes               uint32
+	XResVirtual, YResVirtual uint32
+	XOffset, YOffset         uint32
+	BitsPerPixel             uint32
+	Grayscale                uint32
+	Red, Green, Blue, Transp fbBitfield
+	NonStd                   uint32
+	Activate                 uint32
+	Height, Width            uint32
+	AccelFlags               uint32
+	Timings                  [13]uint32
+	Colorspace               uint32
+	Reserved                 [4]uint32
+}
+
+// currentFramebuffer returns the linear framebuffer Linux uses, e.g. the
+// one set up by firmware and driven by efifb or simplefb.
+func currentFramebuffer() (*Framebuffer, error) {
+	f, err := os.Open(fbDevice)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fix fbFixScreenInfo
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fbioGetFScreenInfo, uintptr(unsafe.Pointer(&fix))); errno != 0 {
+		return nil, fmt.Errorf("FBIOGET_FSCREENINFO: %w", errno)
+	}
+	var v fbVarScreenInfo
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fbioGetVScreenInfo, uintptr(unsafe.Pointer(&v))); errno != 0 {
+		return nil, fmt.Errorf("FBIOGET_VSCREENINFO: %w", errno)
+	}
+	if fix.SmemStart == 0 {
+		return nil, errors.New("framebuffer has no physical address")
+	}
+	return &Framebuffer{
+		Addr:          fix.SmemStart,
+		Pitch:         fix.LineLength,
+		Width:         v.XRes,
+		Height:        v.YRes,
+		BPP:           uint8(v.BitsPerPixel),
+		RedPos:        uint8(v.Red.Offset),
+		RedMaskSize:   uint8(v.Red.Length),
+		GreenPos:      uint8(v.Green.Offset),
+		GreenMaskSize: uint8(v.Green.Length),
+		BluePos:       uint8(v.Blue.Offset),
+		BlueMaskSize:  uint8(v.Blue.Length),
+	}, nil
+}
+
+// currentEFI returns the EFI system table the running kernel was booted
+// with, as recorded in its boot parameters.
+func currentEFI() (*EFI, error) {
+	bp, err := os.ReadFile(bootParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(bp) < efiSystabHi+4 {
+		return nil, errors.New("boot parameters are too short")
+	}
+	le := binary.LittleEndian
+	systab := uint64(le.Uint32(bp[efiSystabLo:])) | uint64(le.Uint32(bp[efiSystabHi:]))<<32
+	switch sig := string(bp[efiLoaderSignature : efiLoaderSignature+4]); {
+	case systab == 0:
+		return nil, errors.New("not booted by EFI")
+	case sig == "EL64":
+		return &EFI{SystemTable: systab, Is64: true}, nil
+	case sig == "EL32":
+		return &EFI{SystemTable: systab}, nil
+	default:
+		return nil, fmt.Errorf("unknown EFI loader signature %q", sig)
+	}
+}
+
+func currentRSDP() ([]byte, error) {
+	r, err := acpi.GetRSDP()
+	if err != nil {
+		return nil, err
+	}
+	return r.AllData(), nil
+}
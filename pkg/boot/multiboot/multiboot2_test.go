@@ -0,0 +1,200 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package multiboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+type mb2Tag struct {
+	typ, flags uint16
+	body       []uint32
+}
+
+// createMB2File returns an image with a multiboot2 header at offset.
+func createMB2File(offset int, arch uint32, tags ...mb2Tag) []byte {
+	ne := binary.NativeEndian
+	var t []byte
+	for _, tag := range append(tags, mb2Tag{typ: mb2HeaderTagEnd}) {
+		t = ne.AppendUint16(t, tag.typ)
+		t = ne.AppendUint16(t, tag.flags)
+		t = ne.AppendUint32(t, uint32(8+4*len(tag.body)))
+		for _, v := range tag.body {
+			t = ne.AppendUint32(t, v)
+		}
+		t = append(t, make([]byte, align8(len(t))-len(t))...)
+	}
+	length := uint32(16 + len(t))
+	h := ne.AppendUint32(nil, mb2HeaderMagic)
+	h = ne.AppendUint32(h, arch)
+	h = ne.AppendUint32(h, length)
+	h = ne.AppendUint32(h, -(mb2HeaderMagic + arch + length))
+
+	buf := bytes.Repeat([]byte{0xDE, 0xAD, 0xBE, 0xEF}, 4096)
+	copy(buf[offset:], append(h, t...))
+	return buf
+}
+
+func TestParseMB2Header(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		offset int
+		arch   uint32
+		tags   []mb2Tag
+		want   *mb2Header
+		err    error
+	}{
+		{
+			name:   "xen",
+			offset: 8,
+			tags: []mb2Tag{
+				{typ: mb2HeaderTagInfoRequest, body: []uint32{mb2InfoBasicMeminfo, mb2InfoMmap}},
+				{typ: mb2HeaderTagInfoRequest, flags: mb2HeaderTagOptional, body: []uint32{mb2InfoFramebuffer}},
+				{typ: mb2HeaderTagConsoleFlags, body: []uint32{3}},
+				{typ: mb2HeaderTagModuleAlign},
+				{typ: mb2HeaderTagFramebuffer, flags: mb2HeaderTagOptional, body: []uint32{0, 0, 0}},
+				{typ: mb2HeaderTagEntryAddressEFI64, flags: mb2HeaderTagOptional, body: []uint32{0x200000}},
+				{typ: mb2HeaderTagEFIBootServices, flags: mb2HeaderTagOptional},
+			},
+			want: &mb2Header{offset: 8, requests: []uint32{mb2InfoBasicMeminfo, mb2InfoMmap}, framebuffer: true},
+		},
+		{
+			name:   "flat image",
+			offset: 64,
+			tags: []mb2Tag{
+				{typ: mb2HeaderTagAddress, body: []uint32{0x100040, 0x100000, 0, 0x110000}},
+				{typ: mb2HeaderTagEntryAddress, body: []uint32{0x100100}},
+			},
+			want: &mb2Header{
+				offset:  64,
+				address: &mb2Address{HeaderAddr: 0x100040, LoadAddr: 0x100000, BSSEndAddr: 0x110000},
+				entry:   0x100100,
+			},
+		},
+		{
+			name:   "unaligned",
+			offset: 4,
+			err:    ErrHeaderNotFound,
+		},
+		{
+			name: "arm",
+			arch: 4,
+			err:  ErrFlagsNotSupported,
+		},
+		{
+			name: "boot services",
+			tags: []mb2Tag{{typ: mb2HeaderTagEFIBootServices}},
+			err:  ErrFlagsNotSupported,
+		},
+		{
+			name: "unknown required tag",
+			tags: []mb2Tag{{typ: 42}},
+			err:  ErrFlagsNotSupported,
+		},
+		{
+			name: "unknown optional tag",
+			tags: []mb2Tag{{typ: 42, flags: mb2HeaderTagOptional}},
+			want: &mb2Header{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMB2Header(bytes.NewReader(createMB2File(tt.offset, tt.arch, tt.tags...)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("parseMB2Header() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMB2Header() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mb2Tags splits boot information into its tags.
+func mb2Tags(t *testing.T, b []byte) map[uint32][]byte {
+	t.Helper()
+	ne := binary.NativeEndian
+	if total := int(ne.Uint32(b)); total != len(b) {
+		t.Fatalf("total_size = %d, want %d", total, len(b))
+	}
+	tags := map[uint32][]byte{}
+	for b = b[mb2InfoHeaderSize:]; len(b) > 0; {
+		typ, size := ne.Uint32(b), int(ne.Uint32(b[4:]))
+		tags[typ] = b[8:size]
+		if typ == mb2InfoEnd {
+			return tags
+		}
+		b = b[align8(size):]
+	}
+	t.Fatal("boot information has no end tag")
+	return nil
+}
+
+func TestMB2Info(t *testing.T) {
+	fb := &Framebuffer{Addr: 0x80000000, Pitch: 4096, Width: 1024, Height: 768, BPP: 32, RedPos: 16, RedMaskSize: 8, GreenPos: 8, GreenMaskSize: 8, BlueMaskSize: 8}
+	rsdp := append([]byte("RSD PTR "), make([]byte, 28)...)
+	rsdp[15] = 2
+	oldFramebuffer, oldEFI, oldRSDP := mb2Framebuffer, mb2EFI, mb2RSDP
+	defer func() {
+		mb2Framebuffer, mb2EFI, mb2RSDP = oldFramebuffer, oldEFI, oldRSDP
+	}()
+	mb2Framebuffer = func() (*Framebuffer, error) { return fb, nil }
+	mb2EFI = func() (*EFI, error) { return &EFI{SystemTable: 0x7f000000, Is64: true}, nil }
+	mb2RSDP = func() ([]byte, error) { return rsdp, nil }
+
+	m := &multiboot{
+		cmdLine:    "xen console=com1",
+		bootloader: bootloader,
+		mem: kexec.Memory{Phys: kexec.MemoryMap{
+			{Range: kexec.Range{Start: 0, Size: 0x9f000}, Type: kexec.RangeRAM},
+			{Range: kexec.Range{Start: 0x100000, Size: 0x7ff00000}, Type: kexec.RangeRAM},
+		}},
+	}
+	h := &mb2Header{requests: []uint32{mb2InfoMmap, mb2InfoEFI64}}
+	inf, err := h.newMB2Info(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := mb2Tags(t, inf.marshal())
+
+	ne := binary.NativeEndian
+	for typ, want := range map[uint32][]byte{
+		mb2InfoCmdline:        []byte("xen console=com1\x00"),
+		mb2InfoBootLoaderName: []byte(bootloader + "\x00"),
+		mb2InfoBasicMeminfo:   ne.AppendUint32(ne.AppendUint32(nil, 0x9f000>>10), 0x7ff00000>>10),
+		mb2InfoEFI64:          ne.AppendUint64(nil, 0x7f000000),
+		mb2InfoACPINew:        rsdp,
+		mb2InfoFramebuffer: {
+			0, 0, 0, 0x80, 0, 0, 0, 0, // addr
+			0, 0x10, 0, 0, // pitch
+			0, 4, 0, 0, // width
+			0, 3, 0, 0, // height
+			32, mb2InfoFramebufferRGB, 0, 0,
+			16, 8, 8, 8, 0, 8,
+		},
+	} {
+		if got := tags[typ]; !bytes.Equal(got, want) {
+			t.Errorf("tag %d = %#v, want %#v", typ, got, want)
+		}
+	}
+	mmap := tags[mb2InfoMmap]
+	if len(mmap) != 8+2*mb2MmapEntrySize || ne.Uint32(mmap) != mb2MmapEntrySize {
+		t.Fatalf("mmap tag = %#v, want 2 entries", mmap)
+	}
+	if base, length, typ := ne.Uint64(mmap[32:]), ne.Uint64(mmap[40:]), ne.Uint32(mmap[48:]); base != 0x100000 || length != 0x7ff00000 || typ != 1 {
+		t.Errorf("mmap[1] = %#x, %#x, %d; want 0x100000, 0x7ff00000, 1", base, length, typ)
+	}
+
+	// Images fail to boot without the information they require.
+	mb2EFI = func() (*EFI, error) { return nil, errors.New("not booted by EFI") }
+	if _, err := h.newMB2Info(m); err == nil {
+		t.Errorf("newMB2Info() without required EFI tag succeeded, want error")
+	}
+}
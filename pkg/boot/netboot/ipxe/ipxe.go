@@ -25,52 +25,98 @@ import (
 // ipxe script.
 var ErrNotIpxeScript = errors.New("config file is not ipxe as it does not start with #!ipxe")
 
+// ErrChainTooDeep is returned when scripts chain to each other more than
+// maxChainDepth times, which usually means there is a loop.
+var ErrChainTooDeep = errors.New("too many chained ipxe scripts")
+
+// maxChainDepth is the maximum number of nested chain commands.
+const maxChainDepth = 8
+
 // parser encapsulates a parsed ipxe configuration file.
 //
-// We currently only support kernel and initrd commands.
+// We support the image commands (kernel, initrd, chain and their imgfetch
+// style aliases), imgargs, set/clear and ${variable} expansion.
 type parser struct {
 	bootImage *boot.LinuxImage
+	initrds   []io.Reader
 
 	// wd is the current working directory.
 	//
 	// Relative file paths are interpreted relative to this URL.
 	wd *url.URL
 
+	// vars are the iPXE settings available for ${name} expansion.
+	vars map[string]string
+
+	// depth is the current chain depth.
+	depth int
+
 	log ulog.Logger
 
 	schemes curl.Schemes
 }
 
+// Option configures the iPXE parser.
+type Option func(*parser)
+
+// WithVariables predefines iPXE settings such as "mac", "ip" or
+// "net0/mac" that scripts can refer to as ${name}.
+func WithVariables(vars map[string]string) Option {
+	return func(c *parser) {
+		for k, v := range vars {
+			c.setVar(k, v)
+		}
+	}
+}
+
 // ParseConfig returns a new configuration with the file at URL and default
 // schemes.
 //
-// `s` is used to get files referred to by URLs in the configuration.
-func ParseConfig(ctx context.Context, l ulog.Logger, configURL *url.URL, s curl.Schemes) (*boot.LinuxImage, error) {
+// `s` is used to get files referred to by URLs in the configuration. To boot
+// over HTTPS, register an "https" scheme in `s` whose client trusts the
+// desired root store (see curl.NewHTTPSClient).
+func ParseConfig(ctx context.Context, l ulog.Logger, configURL *url.URL, s curl.Schemes, opts ...Option) (*boot.LinuxImage, error) {
 	c := &parser{
 		schemes: s,
 		log:     l,
 	}
-	if err := c.getAndParseFile(ctx, configURL); err != nil {
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.bootImage = &boot.LinuxImage{}
+	if _, err := c.getAndParseFile(ctx, configURL); err != nil {
 		return nil, err
 	}
+	c.createInitrd()
 	return c.bootImage, nil
 }
 
-// getAndParse parses the config file downloaded from `url` and fills in `c`.
-func (c *parser) getAndParseFile(ctx context.Context, u *url.URL) error {
+// fetchScript fetches the file at `u`. It returns the script if the file
+// is an ipxe script, and ErrNotIpxeScript otherwise.
+func (c *parser) fetchScript(ctx context.Context, u *url.URL) (string, error) {
 	r, err := c.schemes.Fetch(ctx, u)
 	if err != nil {
-		return err
+		return "", err
 	}
 	data, err := uio.ReadAll(r)
 	if err != nil {
-		return err
+		return "", err
 	}
 	config := string(data)
 	if !strings.HasPrefix(config, "#!ipxe") {
-		return ErrNotIpxeScript
+		return "", ErrNotIpxeScript
 	}
 	c.log.Printf("Got ipxe config file %s:\n%s\n", r, config)
+	return config, nil
+}
+
+// getAndParseFile parses the config file downloaded from `u` and fills in
+// `c`. It returns true if the script asked to boot.
+func (c *parser) getAndParseFile(ctx context.Context, u *url.URL) (bool, error) {
+	config, err := c.fetchScript(ctx, u)
+	if err != nil {
+		return false, err
+	}
 
 	// Parent dir of the config file.
 	c.wd = &url.URL{
@@ -78,7 +124,7 @@ func (c *parser) getAndParseFile(ctx context.Context, u *url.URL) error {
 		Host:   u.Host,
 		Path:   path.Dir(u.Path),
 	}
-	return c.parseIpxe(config)
+	return c.runScript(ctx, config)
 }
 
 // getFile parses `surl` and returns an io.Reader for the requested url.
@@ -116,6 +162,22 @@ func (c *parser) getFile(surl string) (io.ReaderAt, error) {
 	}), nil
 }
 
+// setKernel sets the kernel of the boot image to surl. surl.sig is its
+// detached signature, fetched only if a signature policy asks for it.
+func (c *parser) setKernel(surl string) error {
+	k, err := c.getFile(surl)
+	if err != nil {
+		return err
+	}
+	sig, err := c.getFile(surl + ".sig")
+	if err != nil {
+		return err
+	}
+	c.bootImage.Kernel = k
+	c.bootImage.KernelSignature = sig
+	return nil
+}
+
 func (c *parser) getFileWithoutCache(surl string) (io.Reader, error) {
 	u, err := parseURL(surl, c.wd)
 	if err != nil {
@@ -149,19 +211,78 @@ func parseURL(name string, wd *url.URL) (*url.URL, error) {
 	return u, nil
 }
 
-func (c *parser) createInitrd(initrds []io.Reader) {
-	if len(initrds) > 0 {
-		c.bootImage.Initrd = boot.CatInitrdsWithFileCache(initrds...)
+func (c *parser) createInitrd() {
+	if len(c.initrds) > 0 {
+		c.bootImage.Initrd = boot.CatInitrdsWithFileCache(c.initrds...)
+	}
+}
+
+func (c *parser) setVar(name, value string) {
+	if c.vars == nil {
+		c.vars = make(map[string]string)
+	}
+	c.vars[name] = value
+}
+
+// expandVars replaces ${name} references in s with their values.
+//
+// As in iPXE, undefined settings expand to the empty string and an optional
+// type suffix (${name:type}) is ignored.
+func (c *parser) expandVars(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(s[:i])
+		name := s[i+2 : i+j]
+		if k := strings.IndexByte(name, ':'); k >= 0 {
+			name = name[:k]
+		}
+		b.WriteString(c.vars[name])
+		s = s[i+j+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// imageArgs strips the options accepted by iPXE image commands (e.g.
+// --name foo, --timeout=5000, --autofree) and returns the image URL
+// followed by its arguments.
+func imageArgs(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		opt := args[0]
+		args = args[1:]
+		switch opt {
+		case "-n", "--name", "-t", "--timeout":
+			// These take a separate value.
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		}
 	}
+	return args
 }
 
 // parseIpxe parses `config` and constructs a BootImage for `c`.
 func (c *parser) parseIpxe(config string) error {
-	// A trivial ipxe script parser.
-	// Currently only supports kernel and initrd commands.
 	c.bootImage = &boot.LinuxImage{}
+	c.initrds = nil
+	if _, err := c.runScript(context.Background(), config); err != nil {
+		return err
+	}
+	c.createInitrd()
+	return nil
+}
 
-	var initrds []io.Reader
+// runScript runs the commands of the ipxe script `config`. It returns true
+// if the script asked to boot.
+func (c *parser) runScript(ctx context.Context, config string) (bool, error) {
 	for _, line := range strings.Split(config, "\n") {
 		// Skip blank lines and comment lines.
 		line = strings.TrimSpace(line)
@@ -169,43 +290,67 @@ func (c *parser) parseIpxe(config string) error {
 			continue
 		}
 
-		args := strings.Fields(line)
+		args := strings.Fields(c.expandVars(line))
 		if len(args) == 0 {
 			continue
 		}
 		cmd := strings.ToLower(args[0])
 
 		switch cmd {
-		case "kernel":
-			if len(args) > 1 {
-				k, err := c.getFile(args[1])
-				if err != nil {
-					return err
+		case "kernel", "imgexec", "imgload", "imgselect":
+			args := imageArgs(args[1:])
+			if len(args) > 0 {
+				if err := c.setKernel(args[0]); err != nil {
+					return false, err
 				}
-				c.bootImage.Kernel = k
 			}
 
 			// Add cmdline if there are any.
-			if len(args) > 2 {
-				c.bootImage.Cmdline = strings.Join(args[2:], " ")
+			if len(args) > 1 {
+				c.bootImage.Cmdline = strings.Join(args[1:], " ")
 			}
 
-		case "initrd":
-			if len(args) > 1 {
-				for _, f := range strings.Split(args[1], ",") {
+		case "initrd", "imgfetch", "module":
+			args := imageArgs(args[1:])
+			if len(args) > 0 {
+				for _, f := range strings.Split(args[0], ",") {
 					i, err := c.getFileWithoutCache(f)
 					if err != nil {
-						return err
+						return false, err
 					}
-					initrds = append(initrds, i)
+					c.initrds = append(c.initrds, i)
 				}
 			}
 
+		case "imgargs":
+			// imgargs <image> [args...] replaces the kernel cmdline.
+			if len(args) > 1 {
+				c.bootImage.Cmdline = strings.Join(args[2:], " ")
+			}
+
+		case "chain":
+			args := imageArgs(args[1:])
+			if len(args) == 0 {
+				continue
+			}
+			if booted, err := c.chain(ctx, args[0], args[1:]); err != nil || booted {
+				return booted, err
+			}
+
+		case "set":
+			if len(args) > 1 {
+				c.setVar(args[1], strings.Join(args[2:], " "))
+			}
+
+		case "clear":
+			if len(args) > 1 {
+				delete(c.vars, args[1])
+			}
+
 		case "boot":
 			// Stop parsing at this point, we should go ahead and
 			// boot.
-			c.createInitrd(initrds)
-			return nil
+			return true, nil
 
 		default:
 			c.log.Printf("Ignoring unsupported ipxe cmd: %s", line)
@@ -213,6 +358,38 @@ func (c *parser) parseIpxe(config string) error {
 	}
 
 	// EOF - we should go ahead and boot.
-	c.createInitrd(initrds)
-	return nil
+	return false, nil
+}
+
+// chain follows a chain command. If `surl` is another ipxe script it is
+// executed in place, otherwise it is treated as a kernel to boot with
+// `args` as its command line.
+func (c *parser) chain(ctx context.Context, surl string, args []string) (bool, error) {
+	if c.depth >= maxChainDepth {
+		return false, ErrChainTooDeep
+	}
+	u, err := parseURL(surl, c.wd)
+	if err != nil {
+		return false, err
+	}
+
+	// Relative paths in the chained script are relative to it, so
+	// restore our own working directory once it is done.
+	wd := c.wd
+	c.depth++
+	booted, err := c.getAndParseFile(ctx, u)
+	c.depth--
+	c.wd = wd
+	if !errors.Is(err, ErrNotIpxeScript) {
+		return booted, err
+	}
+
+	c.log.Printf("Chained file %s is not an ipxe script, booting it as a kernel", u)
+	if err := c.setKernel(surl); err != nil {
+		return false, err
+	}
+	if len(args) > 0 {
+		c.bootImage.Cmdline = strings.Join(args, " ")
+	}
+	return true, nil
 }
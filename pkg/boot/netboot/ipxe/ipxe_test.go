@@ -123,6 +123,7 @@ func TestIpxeConfig(t *testing.T) {
 	for i, tt := range []struct {
 		desc       string
 		schemeFunc func() curl.Schemes
+		opts       []Option
 		curl       *url.URL
 		want       *boot.LinuxImage
 		err        error
@@ -413,6 +414,112 @@ func TestIpxeConfig(t *testing.T) {
 				Initrd: strings.NewReader(content2),
 			},
 		},
+		{
+			desc: "image command variants with options",
+			schemeFunc: func() curl.Schemes {
+				s := make(curl.Schemes)
+				fs := curl.NewMockScheme("http")
+				conf := `#!ipxe
+				imgload --name vmlinuz --timeout 5000 http://someplace.com/foobar/pxefiles/kernel
+				imgfetch -n initrd.img http://someplace.com/someinitrd.gz
+				imgargs vmlinuz console=ttyS0
+				boot vmlinuz`
+				fs.Add("someplace.com", "/foobar/pxefiles/ipxeconfig", conf)
+				fs.Add("someplace.com", "/foobar/pxefiles/kernel", content1)
+				fs.Add("someplace.com", "/someinitrd.gz", content2)
+				s.Register(fs.Scheme, fs)
+				return s
+			},
+			curl: &url.URL{
+				Scheme: "http",
+				Host:   "someplace.com",
+				Path:   "/foobar/pxefiles/ipxeconfig",
+			},
+			want: &boot.LinuxImage{
+				Kernel:  strings.NewReader(content1),
+				Initrd:  strings.NewReader(content2),
+				Cmdline: "console=ttyS0",
+			},
+		},
+		{
+			desc: "variables and chained script",
+			schemeFunc: func() curl.Schemes {
+				s := make(curl.Schemes)
+				fs := curl.NewMockScheme("http")
+				conf := `#!ipxe
+				set base http://someplace.com/${arch}
+				chain ${base}/boot.ipxe`
+				chained := `#!ipxe
+				kernel kernel root=${root:string} mac=${net0/mac}
+				module --autofree initrd
+				boot`
+				fs.Add("someplace.com", "/foobar/pxefiles/ipxeconfig", conf)
+				fs.Add("someplace.com", "/x86_64/boot.ipxe", chained)
+				fs.Add("someplace.com", "/x86_64/kernel", content1)
+				fs.Add("someplace.com", "/x86_64/initrd", content2)
+				s.Register(fs.Scheme, fs)
+				return s
+			},
+			opts: []Option{WithVariables(map[string]string{
+				"arch":     "x86_64",
+				"root":     "/dev/sda1",
+				"net0/mac": "00:11:22:33:44:55",
+			})},
+			curl: &url.URL{
+				Scheme: "http",
+				Host:   "someplace.com",
+				Path:   "/foobar/pxefiles/ipxeconfig",
+			},
+			want: &boot.LinuxImage{
+				Kernel:  strings.NewReader(content1),
+				Initrd:  strings.NewReader(content2),
+				Cmdline: "root=/dev/sda1 mac=00:11:22:33:44:55",
+			},
+		},
+		{
+			desc: "chain to kernel image",
+			schemeFunc: func() curl.Schemes {
+				s := make(curl.Schemes)
+				fs := curl.NewMockScheme("http")
+				conf := `#!ipxe
+				initrd http://someplace.com/someinitrd.gz
+				chain --autofree kernel console=tty0
+				kernel ignored`
+				fs.Add("someplace.com", "/foobar/pxefiles/ipxeconfig", conf)
+				fs.Add("someplace.com", "/foobar/pxefiles/kernel", content1)
+				fs.Add("someplace.com", "/someinitrd.gz", content2)
+				s.Register(fs.Scheme, fs)
+				return s
+			},
+			curl: &url.URL{
+				Scheme: "http",
+				Host:   "someplace.com",
+				Path:   "/foobar/pxefiles/ipxeconfig",
+			},
+			want: &boot.LinuxImage{
+				Kernel:  strings.NewReader(content1),
+				Initrd:  strings.NewReader(content2),
+				Cmdline: "console=tty0",
+			},
+		},
+		{
+			desc: "chain loop",
+			schemeFunc: func() curl.Schemes {
+				s := make(curl.Schemes)
+				fs := curl.NewMockScheme("http")
+				conf := `#!ipxe
+				chain ipxeconfig`
+				fs.Add("someplace.com", "/foobar/pxefiles/ipxeconfig", conf)
+				s.Register(fs.Scheme, fs)
+				return s
+			},
+			curl: &url.URL{
+				Scheme: "http",
+				Host:   "someplace.com",
+				Path:   "/foobar/pxefiles/ipxeconfig",
+			},
+			err: ErrChainTooDeep,
+		},
 		{
 			desc: "valid config with unsupported cmds",
 			schemeFunc: func() curl.Schemes {
@@ -441,7 +548,7 @@ func TestIpxeConfig(t *testing.T) {
 		},
 	} {
 		t.Run(fmt.Sprintf("Test [%02d] %s", i, tt.desc), func(t *testing.T) {
-			got, err := ParseConfig(context.Background(), ulogtest.Logger{t}, tt.curl, tt.schemeFunc())
+			got, err := ParseConfig(context.Background(), ulogtest.Logger{t}, tt.curl, tt.schemeFunc(), tt.opts...)
 			if !reflect.DeepEqual(err, tt.err) {
 				t.Errorf("ParseConfig() got %v, want %v", err, tt.err)
 				return
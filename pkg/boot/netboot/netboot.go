@@ -15,6 +15,7 @@ import (
 	"net"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/boot"
 	"github.com/u-root/u-root/pkg/boot/netboot/ipxe"
@@ -33,6 +34,9 @@ import (
 //
 //   - to detect a pxelinux.0, in which case we will ignore the pxelinux.0 and
 //     try to parse pxelinux.cfg/<files>.
+//
+// For DHCPv6 leases, the boot file URL is option 59 and the parameters in
+// option 60 are appended to the kernel command lines.
 func BootImages(ctx context.Context, l ulog.Logger, s curl.Schemes, lease dhclient.Lease) ([]boot.OSImage, error) {
 	uri, err := lease.Boot()
 	if err != nil {
@@ -46,7 +50,35 @@ func BootImages(ctx context.Context, l ulog.Logger, s curl.Schemes, lease dhclie
 	if p4, ok := lease.(*dhclient.Packet4); ok {
 		ip = p4.Lease().IP
 	}
-	return getBootImages(ctx, l, s, uri, lease.Link().Attrs().HardwareAddr, ip), nil
+	images := getBootImages(ctx, l, s, uri, lease.Link().Attrs().HardwareAddr, ip)
+
+	// DHCPv6 servers may pass parameters for the boot file, which are
+	// appended to the kernel command line.
+	if p6, ok := lease.(*dhclient.Packet6); ok {
+		if params := strings.Join(p6.BootParams(), " "); params != "" {
+			l.Printf("Boot file parameters: %s", params)
+			for _, img := range images {
+				img.Edit(func(cmdline string) string {
+					return strings.TrimSpace(cmdline + " " + params)
+				})
+			}
+		}
+	}
+	return images, nil
+}
+
+// ipxeVars returns the iPXE settings describing the booting interface.
+func ipxeVars(mac net.HardwareAddr, ip net.IP) map[string]string {
+	vars := make(map[string]string)
+	if mac != nil {
+		vars["mac"] = mac.String()
+		vars["net0/mac"] = mac.String()
+	}
+	if ip != nil {
+		vars["ip"] = ip.String()
+		vars["net0/ip"] = ip.String()
+	}
+	return vars
 }
 
 // getBootImages attempts to parse the file at uri as an ipxe config and returns
@@ -58,7 +90,7 @@ func getBootImages(ctx context.Context, l ulog.Logger, schemes curl.Schemes, uri
 	// 1: Attempt to download the given url as is.
 	//
 	// 1.1: Try ipxe config file.
-	ipc, err := ipxe.ParseConfig(ctx, l, uri, schemes)
+	ipc, err := ipxe.ParseConfig(ctx, l, uri, schemes, ipxe.WithVariables(ipxeVars(mac, ip)))
 	if err != nil {
 		l.Printf("Parsing boot files as iPXE failed, trying other formats...: %v", err)
 	}
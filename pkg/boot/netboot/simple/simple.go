@@ -11,15 +11,18 @@ import (
 	l "log"
 	"math"
 	"net/url"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/boot/bls"
+	"github.com/u-root/u-root/pkg/boot/bzimage"
 	"github.com/u-root/u-root/pkg/boot/fit"
 	"github.com/u-root/u-root/pkg/curl"
 )
 
 // FetchAndProbe fetches the file at the specified URL and checks if it is an
 // Image file type rather than a config such as ipxe.
-// TODO: detect nonFIT multiboot and bzImage Linux kernel files
+// TODO: detect nonFIT multiboot files
 func FetchAndProbe(ctx context.Context, u *url.URL, s curl.Schemes) ([]boot.OSImage, error) {
 	file, err := s.Fetch(ctx, u)
 	if err != nil {
@@ -36,8 +39,52 @@ func FetchAndProbe(ctx context.Context, u *url.URL, s curl.Schemes) ([]boot.OSIm
 		l.Printf("Parsing boot file as FIT image failed: %v", err)
 	}
 
+	// A unified kernel image carries its own initrd and command line, as
+	// booted by UEFI HTTP boot.
+	if len(images) == 0 {
+		img, err := bls.ParseUKI(file)
+		if err == nil {
+			images = append(images, img)
+		} else {
+			l.Printf("Parsing boot file as unified kernel image failed: %v", err)
+		}
+	}
+
+	// A bare kernel is booted without an initrd.
+	if len(images) == 0 {
+		if name, ok := probeKernel(file); ok {
+			images = append(images, &boot.LinuxImage{Name: name, Kernel: file})
+		} else {
+			l.Printf("Boot file is not a Linux kernel")
+		}
+	}
+
 	if len(images) == 0 {
 		return nil, fmt.Errorf("exhausted all supported simple file types")
 	}
 	return images, nil
 }
+
+// arm64ImageMagic is the magic number of arm64 kernel Images, at offset
+// arm64MagicOffset.
+const (
+	arm64ImageMagic  = "ARM\x64"
+	arm64MagicOffset = 56
+)
+
+// probeKernel returns whether r is an x86 bzImage or an arm64 Image, and the
+// name to show for it.
+func probeKernel(r io.ReaderAt) (string, bool) {
+	if v, err := bzimage.KVer(io.NewSectionReader(r, 0, math.MaxInt64)); err == nil {
+		// The version is followed by the builder and build date.
+		if f := strings.Fields(v); len(f) > 0 {
+			return "Linux " + f[0], true
+		}
+		return "Linux", true
+	}
+	magic := make([]byte, len(arm64ImageMagic))
+	if _, err := r.ReadAt(magic, arm64MagicOffset); err == nil && string(magic) == arm64ImageMagic {
+		return "Linux", true
+	}
+	return "", false
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simple
+
+import (
+	"context"
+	"encoding/binary"
+	"net/url"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/curl"
+)
+
+// bzImage returns the start of an x86 kernel with version v.
+func bzImage(v string) string {
+	b := make([]byte, 64<<10)
+	b[510], b[511] = 0x55, 0xaa
+	copy(b[514:], "HdrS")
+	binary.LittleEndian.PutUint16(b[526:], 0x100)
+	copy(b[0x300:], v+"\x00")
+	return string(b)
+}
+
+func arm64Image() string {
+	b := make([]byte, 128)
+	copy(b[arm64MagicOffset:], arm64ImageMagic)
+	return string(b)
+}
+
+func TestFetchAndProbe(t *testing.T) {
+	s := curl.NewMockScheme("http")
+	s.Add("boot", "/bzImage", bzImage("6.1.0-13-amd64 (debian-kernel@lists.debian.org) #1 SMP"))
+	s.Add("boot", "/Image", arm64Image())
+	s.Add("boot", "/boot.ipxe", "#!ipxe\nchain http://boot/bzImage\n")
+	schemes := curl.Schemes{"http": s}
+
+	for _, tt := range []struct {
+		path string
+		name string
+		err  bool
+	}{
+		{path: "/bzImage", name: "Linux 6.1.0-13-amd64"},
+		{path: "/Image", name: "Linux"},
+		{path: "/boot.ipxe", err: true},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			imgs, err := FetchAndProbe(context.Background(), &url.URL{Scheme: "http", Host: "boot", Path: tt.path}, schemes)
+			if tt.err {
+				if err == nil {
+					t.Errorf("FetchAndProbe() = %v, want error", imgs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchAndProbe() = %v", err)
+			}
+			if len(imgs) != 1 {
+				t.Fatalf("FetchAndProbe() = %v, want 1 image", imgs)
+			}
+			li, ok := imgs[0].(*boot.LinuxImage)
+			if !ok || li.Name != tt.name || li.Kernel == nil || li.Initrd != nil {
+				t.Errorf("FetchAndProbe() = %v, want kernel named %q", imgs[0], tt.name)
+			}
+		})
+	}
+}
@@ -171,10 +171,12 @@ type parser struct {
 	// parser internals.
 	globalAppend string
 	scope        scope
-	curEntry     string
-	wd           string
-	rootdir      *url.URL
-	schemes      curl.Schemes
+	// helpText is set between "text help" and "endtext".
+	helpText bool
+	curEntry string
+	wd       string
+	rootdir  *url.URL
+	schemes  curl.Schemes
 }
 
 type scope uint8
@@ -286,6 +288,13 @@ func (c *parser) append(ctx context.Context, config string) error {
 	for _, line := range strings.Split(config, "\n") {
 		// This is stupid. There should be a FieldsN(...).
 		kv := strings.Fields(line)
+		if c.helpText {
+			// Help text may contain anything, even directives.
+			if len(kv) == 1 && strings.EqualFold(kv[0], "endtext") {
+				c.helpText = false
+			}
+			continue
+		}
 		if len(kv) <= 1 {
 			continue
 		}
@@ -298,6 +307,19 @@ func (c *parser) append(ctx context.Context, config string) error {
 		}
 
 		switch directive {
+		case "text":
+			if strings.EqualFold(arg, "help") {
+				c.helpText = true
+			}
+
+		case "com32", "comboot", "localboot", "config", "pxe", "fdimage", "bss":
+			// Entries that run syslinux modules, chain load other
+			// boot loaders or boot the local disk cannot be
+			// booted by us.
+			if c.scope == scopeEntry {
+				delete(c.linuxEntries, c.curEntry)
+			}
+
 		case "default":
 			c.defaultEntry = arg
 
@@ -359,6 +381,12 @@ func (c *parser) append(ctx context.Context, config string) error {
 				c.mbEntries[c.curEntry] = &boot.MultibootImage{
 					Name: c.curEntry,
 				}
+			} else if isModule(arg) {
+				// KERNEL picks the kernel type by extension,
+				// e.g. vesamenu.c32 or pxechn.c32 are
+				// modules, not Linux kernels.
+				delete(c.linuxEntries, c.curEntry)
+				continue
 			}
 			fallthrough
 
@@ -369,6 +397,11 @@ func (c *parser) append(ctx context.Context, config string) error {
 					return err
 				}
 				e.Kernel = k
+				// The detached signature is only fetched if a
+				// signature policy asks for it.
+				if sig, err := c.getFile(arg + ".sig"); err == nil {
+					e.KernelSignature = sig
+				}
 			}
 
 		case "initrd":
@@ -378,16 +411,11 @@ func (c *parser) append(ctx context.Context, config string) error {
 				// For how this interacts with global appends,
 				// read
 				// https://wiki.syslinux.org/wiki/index.php?title=Directives/append
-				// Multiple initrds are comma-separated
-				var initrds []io.Reader
-				for _, f := range strings.Split(arg, ",") {
-					i, err := c.getFileWithoutCache(f)
-					if err != nil {
-						return err
-					}
-					initrds = append(initrds, i)
+				i, err := c.getInitrds(arg)
+				if err != nil {
+					return err
 				}
-				e.Initrd = boot.CatInitrdsWithFileCache(initrds...)
+				e.Initrd = i
 			}
 
 		case "fdt":
@@ -483,12 +511,12 @@ func (c *parser) append(ctx context.Context, config string) error {
 		}
 
 		for _, opt := range strings.Fields(label.Cmdline) {
-			optkv := strings.Split(opt, "=")
-			if len(optkv) != 2 || optkv[0] != "initrd" {
+			name, value, ok := strings.Cut(opt, "=")
+			if !ok || name != "initrd" || value == "" {
 				continue
 			}
 
-			i, err := c.getFile(optkv[1])
+			i, err := c.getInitrds(value)
 			if err != nil {
 				return err
 			}
@@ -497,3 +525,31 @@ func (c *parser) append(ctx context.Context, config string) error {
 	}
 	return nil
 }
+
+// getInitrds returns the concatenation of the comma-separated initrds in
+// arg, as given to the INITRD directive or the initrd= parameter.
+func (c *parser) getInitrds(arg string) (io.ReaderAt, error) {
+	files := strings.Split(arg, ",")
+	if len(files) == 1 {
+		return c.getFile(files[0])
+	}
+	var initrds []io.Reader
+	for _, f := range files {
+		i, err := c.getFileWithoutCache(f)
+		if err != nil {
+			return nil, err
+		}
+		initrds = append(initrds, i)
+	}
+	return boot.CatInitrdsWithFileCache(initrds...), nil
+}
+
+// isModule returns whether KERNEL file is a syslinux module or boot sector
+// rather than a Linux kernel.
+func isModule(file string) bool {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".c32", ".com", ".cbt", ".bss", ".bs", ".bin", ".0":
+		return true
+	}
+	return false
+}
@@ -563,6 +563,58 @@ func TestParseGeneral(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "modules, local boot and help text are skipped",
+			configFiles: map[string]string{
+				"/foobar/pxelinux.cfg/default": `
+					ui vesamenu.c32
+					default foo
+
+					label menu
+					kernel vesamenu.c32
+					append pxelinux.cfg/other
+
+					label local
+					menu label Boot from local disk
+					localboot 0
+
+					label hdt
+					com32 hdt.c32
+
+					label foo
+					kernel ./pxefiles/kernel1
+					text help
+					kernel ./pxefiles/kernel2
+					append this is help text
+					endtext
+					append foo=bar`,
+			},
+			want: []boot.OSImage{
+				&boot.LinuxImage{
+					Name:    "foo",
+					Kernel:  strings.NewReader(kernel1),
+					Cmdline: "foo=bar",
+				},
+			},
+		},
+		{
+			desc: "comma-separated initrds in append",
+			configFiles: map[string]string{
+				"/foobar/pxelinux.cfg/default": `
+					default foo
+					label foo
+					kernel ./pxefiles/kernel1
+					append initrd=./pxefiles/initrd1,./pxefiles/initrd2 root=LABEL=root`,
+			},
+			want: []boot.OSImage{
+				&boot.LinuxImage{
+					Name:    "foo",
+					Kernel:  strings.NewReader(kernel1),
+					Initrd:  boot.CatInitrds(strings.NewReader(initrd1), strings.NewReader(initrd2)),
+					Cmdline: "initrd=./pxefiles/initrd1,./pxefiles/initrd2 root=LABEL=root",
+				},
+			},
+		},
 	} {
 		t.Run(fmt.Sprintf("Test [%02d] %s", i, tt.desc), func(t *testing.T) {
 			fs := newMockScheme()
@@ -0,0 +1,228 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package systembooter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/ulog"
+)
+
+// PolicyFlag is the kernel command line flag holding a boot policy, e.g.
+//
+//	uroot.bootpolicy=netboot:eth0:30s,netboot:eth1:30s,localboot:60s,shell
+const PolicyFlag = "uroot.bootpolicy"
+
+// PolicyRetriesFlag is the kernel command line flag holding how many times
+// a boot policy is retried after all of its methods failed.
+const PolicyRetriesFlag = "uroot.bootpolicy.retries"
+
+// ErrPolicyFailed is returned when every method of a boot policy failed.
+var ErrPolicyFailed = errors.New("all boot methods failed")
+
+var (
+	errUnknownMethod = errors.New("unknown boot method")
+	errNotBooted     = errors.New("returned without booting")
+)
+
+// Method is one step of a boot policy.
+type Method struct {
+	// Name is one of "netboot", "localboot" or "shell".
+	Name string
+	// Interface restricts netboot to a single interface. Empty means all
+	// Ethernet interfaces.
+	Interface string
+	// Timeout is how long the method may run. Zero means no limit.
+	Timeout time.Duration
+}
+
+// String returns the method in the syntax of ParsePolicy, without timeout.
+func (m Method) String() string {
+	if m.Interface != "" {
+		return m.Name + ":" + m.Interface
+	}
+	return m.Name
+}
+
+// command returns the command line that runs m.
+func (m Method) command(debugEnabled bool) ([]string, error) {
+	var c []string
+	switch m.Name {
+	case "netboot":
+		c = []string{"pxeboot"}
+		if debugEnabled {
+			c = append(c, "-v")
+		}
+		if m.Interface != "" {
+			c = append(c, "^"+regexp.QuoteMeta(m.Interface)+"$")
+		}
+	case "localboot":
+		c = []string{"boot"}
+		if debugEnabled {
+			c = append(c, "-v")
+		}
+	case "shell":
+		c = []string{"gosh"}
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownMethod, m.Name)
+	}
+	return c, nil
+}
+
+// Policy is an ordered list of boot methods. The first method that boots
+// wins; the others are tried in order when one fails or times out.
+type Policy struct {
+	Methods []Method
+	// Retries is how many more times the whole list is tried.
+	Retries int
+}
+
+// ParsePolicy parses a comma separated list of methods, each of the form
+// name[:interface][:timeout], e.g. "netboot:eth0:30s,localboot:1m,shell".
+// Only netboot takes an interface.
+func ParsePolicy(s string) (*Policy, error) {
+	p := &Policy{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, ":")
+		m := Method{Name: parts[0]}
+		if _, err := m.command(false); err != nil {
+			return nil, err
+		}
+		parts = parts[1:]
+		if len(parts) > 0 {
+			if d, err := time.ParseDuration(parts[len(parts)-1]); err == nil {
+				m.Timeout = d
+				parts = parts[:len(parts)-1]
+			}
+		}
+		switch {
+		case len(parts) == 1 && m.Name == "netboot":
+			m.Interface = parts[0]
+		case len(parts) > 0:
+			return nil, fmt.Errorf("invalid boot method %q", field)
+		}
+		p.Methods = append(p.Methods, m)
+	}
+	if len(p.Methods) == 0 {
+		return nil, fmt.Errorf("empty boot policy %q", s)
+	}
+	return p, nil
+}
+
+// PolicyFromCmdline returns the boot policy set on the kernel command line,
+// and false if there is none.
+func PolicyFromCmdline() (*Policy, bool, error) {
+	s, ok := cmdline.Flag(PolicyFlag)
+	if !ok {
+		return nil, false, nil
+	}
+	p, err := ParsePolicy(s)
+	if err != nil {
+		return nil, true, err
+	}
+	if r, ok := cmdline.Flag(PolicyRetriesFlag); ok {
+		if p.Retries, err = strconv.Atoi(r); err != nil {
+			return nil, true, fmt.Errorf("invalid %s: %w", PolicyRetriesFlag, err)
+		}
+	}
+	return p, true, nil
+}
+
+// Attempt records the outcome of running one method.
+type Attempt struct {
+	Method   Method
+	Round    int
+	Duration time.Duration
+	// Err is why the method failed. It wraps context.DeadlineExceeded if
+	// the method timed out.
+	Err error
+}
+
+// String formats a as key=value pairs.
+func (a Attempt) String() string {
+	result := "ok"
+	switch {
+	case errors.Is(a.Err, context.DeadlineExceeded):
+		result = "timeout"
+	case a.Err != nil:
+		result = "failed"
+	}
+	s := fmt.Sprintf("method=%s round=%d result=%s duration=%s", a.Method, a.Round, result, a.Duration.Round(time.Millisecond))
+	if a.Err != nil {
+		s += fmt.Sprintf(" error=%q", a.Err)
+	}
+	return s
+}
+
+// commandContext is overridden in tests.
+var commandContext = exec.CommandContext
+
+func (m Method) run(ctx context.Context, debugEnabled bool) error {
+	c, err := m.command(debugEnabled)
+	if err != nil {
+		return err
+	}
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+	cmd := commandContext(ctx, c[0], c[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%v: %w", c, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %w", c, err)
+	}
+	// Boot commands that return without error did not kexec either.
+	return fmt.Errorf("%v: %w", c, errNotBooted)
+}
+
+// Run tries the methods of p in order until one boots, which does not
+// return, and logs every failed attempt to l. Shell methods that exit
+// cleanly count as success. Run returns the attempts, and an error wrapping
+// ErrPolicyFailed and the errors of the last round if nothing booted.
+func (p *Policy) Run(ctx context.Context, l ulog.Logger, debugEnabled bool) ([]Attempt, error) {
+	var attempts []Attempt
+	var errs []error
+	for round := 0; round <= p.Retries; round++ {
+		errs = nil
+		for _, m := range p.Methods {
+			if err := ctx.Err(); err != nil {
+				return attempts, err
+			}
+			l.Printf("bootpolicy: method=%s round=%d timeout=%s starting", m, round, m.Timeout)
+			start := time.Now()
+			err := m.run(ctx, debugEnabled)
+			if m.Name == "shell" && errors.Is(err, errNotBooted) {
+				// The user left the recovery shell.
+				err = nil
+			}
+			a := Attempt{Method: m, Round: round, Duration: time.Since(start), Err: err}
+			attempts = append(attempts, a)
+			l.Printf("bootpolicy: %s", a)
+			if err == nil {
+				return attempts, nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", m, err))
+		}
+	}
+	return attempts, fmt.Errorf("%w: %w", ErrPolicyFailed, errors.Join(errs...))
+}
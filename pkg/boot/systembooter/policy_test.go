@@ -0,0 +1,124 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package systembooter
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog/ulogtest"
+)
+
+func TestParsePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []Method
+		err  bool
+	}{
+		{
+			in: "netboot:eth0:30s,netboot:eth1:30s,localboot:1m,shell",
+			want: []Method{
+				{Name: "netboot", Interface: "eth0", Timeout: 30 * time.Second},
+				{Name: "netboot", Interface: "eth1", Timeout: 30 * time.Second},
+				{Name: "localboot", Timeout: time.Minute},
+				{Name: "shell"},
+			},
+		},
+		{in: "netboot:eth0", want: []Method{{Name: "netboot", Interface: "eth0"}}},
+		{in: " localboot , ", want: []Method{{Name: "localboot"}}},
+		{in: "localboot:sda", err: true},
+		{in: "netboot:eth0:eth1", err: true},
+		{in: "floppy", err: true},
+		{in: "", err: true},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePolicy(tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParsePolicy(%q) = %v, want error %v", tt.in, err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(got.Methods, tt.want) {
+				t.Errorf("ParsePolicy(%q) = %+v, want %+v", tt.in, got.Methods, tt.want)
+			}
+		})
+	}
+}
+
+func TestMethodCommand(t *testing.T) {
+	c, err := Method{Name: "netboot", Interface: "eth0.1"}.command(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pxeboot", "-v", `^eth0\.1$`}; !reflect.DeepEqual(c, want) {
+		t.Errorf("command() = %q, want %q", c, want)
+	}
+}
+
+// fakeCommands makes each boot command run the shell script in scripts.
+func fakeCommands(t *testing.T, scripts map[string]string) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	old := commandContext
+	t.Cleanup(func() { commandContext = old })
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", scripts[name])
+	}
+}
+
+func TestPolicyRun(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"pxeboot": "exec sleep 10",
+		"boot":    "exit 1",
+		"gosh":    "exit 0",
+	})
+	p := &Policy{Methods: []Method{
+		{Name: "netboot", Interface: "eth0", Timeout: 100 * time.Millisecond},
+		{Name: "localboot"},
+		{Name: "shell"},
+	}}
+	attempts, err := p.Run(context.Background(), ulogtest.Logger{TB: t}, false)
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("Run() made %d attempts, want 3", len(attempts))
+	}
+	if !errors.Is(attempts[0].Err, context.DeadlineExceeded) {
+		t.Errorf("netboot error = %v, want timeout", attempts[0].Err)
+	}
+	if s := attempts[0].String(); !strings.Contains(s, "method=netboot:eth0 round=0 result=timeout") {
+		t.Errorf("netboot attempt = %q", s)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(attempts[1].Err, &exitErr) {
+		t.Errorf("localboot error = %v, want exit error", attempts[1].Err)
+	}
+	if attempts[2].Err != nil {
+		t.Errorf("shell error = %v, want nil", attempts[2].Err)
+	}
+}
+
+func TestPolicyRunFailed(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"pxeboot": "exit 0",
+		"boot":    "exit 2",
+	})
+	p := &Policy{
+		Methods: []Method{{Name: "netboot"}, {Name: "localboot"}},
+		Retries: 1,
+	}
+	attempts, err := p.Run(context.Background(), ulogtest.Logger{TB: t}, false)
+	if !errors.Is(err, ErrPolicyFailed) || !errors.Is(err, errNotBooted) {
+		t.Errorf("Run() = %v, want %v and %v", err, ErrPolicyFailed, errNotBooted)
+	}
+	if len(attempts) != 4 || attempts[3].Round != 1 {
+		t.Errorf("Run() = %v, want 2 rounds of 2 attempts", attempts)
+	}
+}
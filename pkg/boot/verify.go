@@ -0,0 +1,163 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boot
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/u-root/u-root/pkg/boot/authenticode"
+)
+
+// ErrKernelSignature is returned by Load for kernels rejected by the
+// KernelVerifier.
+var ErrKernelSignature = errors.New("kernel signature verification failed")
+
+// KernelVerifier checks a kernel before it is loaded.
+type KernelVerifier interface {
+	// VerifyKernel checks the size bytes of kernel. sig is the detached
+	// signature of the image, or nil.
+	VerifyKernel(kernel io.ReaderAt, size int64, sig io.ReaderAt) error
+}
+
+// WithKernelVerifier is a LoadOption that refuses to load kernels v does not
+// accept. Signatures cover the kernel as it is loaded, i.e. after
+// decompression.
+func WithKernelVerifier(v KernelVerifier) LoadOption {
+	return func(o *loadOptions) {
+		o.kernelVerifier = v
+	}
+}
+
+// SignaturePolicy accepts kernels with a detached OpenPGP signature by a
+// key of Keyring, or PE/COFF kernels with an Authenticode signature
+// chaining to one of Certificates.
+//
+// It is meant for systems where the kernel cannot enforce signatures on
+// kexec_file_load itself, e.g. because it lacks IMA or the kexec signature
+// check.
+type SignaturePolicy struct {
+	Keyring      openpgp.KeyRing
+	Certificates []*x509.Certificate
+}
+
+// ReadSignaturePolicy reads a SignaturePolicy from the keys in dir, usually
+// one embedded in the initramfs. PEM certificates end in .pem or .crt,
+// binary OpenPGP keyrings in .gpg and armored ones in .asc.
+func ReadSignaturePolicy(dir string) (*SignaturePolicy, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var p SignaturePolicy
+	var ring openpgp.EntityList
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		var read func([]byte) error
+		switch filepath.Ext(name) {
+		case ".pem", ".crt":
+			read = func(b []byte) error {
+				certs, err := parseCertificates(b)
+				p.Certificates = append(p.Certificates, certs...)
+				return err
+			}
+		case ".gpg":
+			read = func(b []byte) error {
+				el, err := openpgp.ReadKeyRing(bytes.NewReader(b))
+				ring = append(ring, el...)
+				return err
+			}
+		case ".asc":
+			read = func(b []byte) error {
+				el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+				ring = append(ring, el...)
+				return err
+			}
+		default:
+			continue
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := read(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if len(ring) > 0 {
+		p.Keyring = ring
+	}
+	if p.Keyring == nil && len(p.Certificates) == 0 {
+		return nil, fmt.Errorf("no keys in %s", dir)
+	}
+	return &p, nil
+}
+
+func parseCertificates(b []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificates")
+	}
+	return certs, nil
+}
+
+// VerifyKernel implements KernelVerifier.
+func (p *SignaturePolicy) VerifyKernel(kernel io.ReaderAt, size int64, sig io.ReaderAt) error {
+	var errs []error
+	if p.Keyring != nil && sig != nil {
+		err := checkDetachedSignature(p.Keyring, io.NewSectionReader(kernel, 0, size), sig)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("detached signature: %w", err))
+	}
+	if len(p.Certificates) > 0 {
+		err := authenticode.Verify(kernel, size, p.Certificates)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("authenticode: %w", err))
+	}
+	if len(errs) == 0 {
+		errs = append(errs, errors.New("no signature to check"))
+	}
+	return fmt.Errorf("%w: %w", ErrKernelSignature, errors.Join(errs...))
+}
+
+func checkDetachedSignature(ring openpgp.KeyRing, signed io.Reader, sig io.ReaderAt) error {
+	b, err := io.ReadAll(io.NewSectionReader(sig, 0, 1<<62))
+	if err != nil {
+		return err
+	}
+	check := openpgp.CheckDetachedSignature
+	if strings.HasPrefix(string(b), "-----BEGIN PGP SIGNATURE") {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+	_, err = check(ring, signed, bytes.NewReader(b), nil)
+	return err
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boot
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestSignaturePolicy(t *testing.T) {
+	signer, err := openpgp.NewEntity("kernel signer", "", "kernel@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernel := []byte("testkernel")
+	var sig, armored bytes.Buffer
+	if err := openpgp.DetachSign(&sig, signer, bytes.NewReader(kernel), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := openpgp.ArmoredDetachSign(&armored, signer, bytes.NewReader(kernel), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &SignaturePolicy{Keyring: openpgp.EntityList{signer}}
+	verify := func(p *SignaturePolicy, k []byte, sig []byte) error {
+		var s io.ReaderAt
+		if sig != nil {
+			s = bytes.NewReader(sig)
+		}
+		return p.VerifyKernel(bytes.NewReader(k), int64(len(k)), s)
+	}
+	if err := verify(p, kernel, sig.Bytes()); err != nil {
+		t.Errorf("VerifyKernel() = %v", err)
+	}
+	if err := verify(p, kernel, armored.Bytes()); err != nil {
+		t.Errorf("VerifyKernel(armored) = %v", err)
+	}
+	if err := verify(p, []byte("evilkernel"), sig.Bytes()); !errors.Is(err, ErrKernelSignature) {
+		t.Errorf("VerifyKernel(modified) = %v, want %v", err, ErrKernelSignature)
+	}
+	if err := verify(p, kernel, nil); !errors.Is(err, ErrKernelSignature) {
+		t.Errorf("VerifyKernel(unsigned) = %v, want %v", err, ErrKernelSignature)
+	}
+	if err := verify(&SignaturePolicy{Keyring: openpgp.EntityList{other}}, kernel, sig.Bytes()); !errors.Is(err, ErrKernelSignature) {
+		t.Errorf("VerifyKernel(other key) = %v, want %v", err, ErrKernelSignature)
+	}
+
+	// Load checks the kernel before anything else.
+	li := &LinuxImage{Kernel: bytes.NewReader(kernel), KernelSignature: bytes.NewReader(sig.Bytes())}
+	if err := li.Load(WithKernelVerifier(p), WithDryRun(true)); err != nil {
+		t.Errorf("Load(signed) = %v", err)
+	}
+	li = &LinuxImage{Kernel: strings.NewReader("evilkernel"), KernelSignature: bytes.NewReader(sig.Bytes())}
+	if err := li.Load(WithKernelVerifier(p), WithDryRun(true)); !errors.Is(err, ErrKernelSignature) {
+		t.Errorf("Load(modified) = %v, want %v", err, ErrKernelSignature)
+	}
+}
+
+func TestReadSignaturePolicy(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadSignaturePolicy(dir); err == nil {
+		t.Errorf("ReadSignaturePolicy(empty) succeeded, want error")
+	}
+
+	e, err := openpgp.NewEntity("kernel signer", "", "kernel@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asc bytes.Buffer
+	w, err := armor.Encode(&asc, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "db"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"signer.asc": asc.Bytes(),
+		"db.pem":     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"README":     []byte("not a key"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := ReadSignaturePolicy(dir)
+	if err != nil {
+		t.Fatalf("ReadSignaturePolicy() = %v", err)
+	}
+	if el, ok := p.Keyring.(openpgp.EntityList); !ok || len(el) != 1 || len(p.Certificates) != 1 {
+		t.Errorf("ReadSignaturePolicy() = %+v, want 1 OpenPGP key and 1 certificate", p)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.crt"), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSignaturePolicy(dir); err == nil {
+		t.Errorf("ReadSignaturePolicy(bad certificate) succeeded, want error")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verity
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrBadSignature is returned for boot policies whose signature does not
+// verify.
+var ErrBadSignature = errors.New("boot policy signature does not verify")
+
+// policy is the JSON form of a boot policy, e.g.
+//
+//	{
+//		"root_hash": "4392712b...",
+//		"data_device": "PARTLABEL=root-a",
+//		"hash_device": "PARTLABEL=root-a-verity",
+//		"options": ["panic_on_corruption"]
+//	}
+type policy struct {
+	RootHash   string   `json:"root_hash"`
+	DataDevice string   `json:"data_device"`
+	HashDevice string   `json:"hash_device,omitempty"`
+	HashOffset uint64   `json:"hash_offset,omitempty"`
+	Options    []string `json:"options,omitempty"`
+}
+
+// ParsePolicy verifies the Ed25519 signature sig of the JSON boot policy
+// data and returns the verity configuration it describes.
+func ParsePolicy(data, sig []byte, key ed25519.PublicKey) (*Config, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("boot policy key has %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return nil, ErrBadSignature
+	}
+	var p policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("boot policy: %w", err)
+	}
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil || len(root) == 0 {
+		return nil, fmt.Errorf("boot policy: bad root_hash %q", p.RootHash)
+	}
+	if p.DataDevice == "" {
+		return nil, errors.New("boot policy: no data_device")
+	}
+	cfg := &Config{
+		DataDevice: p.DataDevice,
+		HashDevice: p.HashDevice,
+		HashOffset: p.HashOffset,
+		RootHash:   root,
+		Options:    p.Options,
+	}
+	if cfg.HashDevice == "" {
+		cfg.HashDevice = cfg.DataDevice
+	}
+	return cfg, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verity
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+// Replaced in tests.
+var (
+	dmCreate = dm.Create
+	resolve  = resolveDevice
+)
+
+// resolveDevice returns the path of the device spec among devices.
+func resolveDevice(spec string, devices block.BlockDevices) (string, error) {
+	k, v, ok := strings.Cut(spec, "=")
+	if !ok {
+		return spec, nil
+	}
+	var found block.BlockDevices
+	switch k {
+	case "PARTUUID":
+		found = devices.FilterPartID(v)
+	case "PARTLABEL":
+		found = devices.FilterPartLabel(v)
+	case "UUID":
+		found = devices.FilterFSUUID(v)
+	default:
+		return "", fmt.Errorf("unsupported device spec %q", spec)
+	}
+	if len(found) != 1 {
+		return "", fmt.Errorf("%d devices match %s, want 1", len(found), spec)
+	}
+	return found[0].DevicePath(), nil
+}
+
+// Setup checks the hash tree of cfg against its root hash and creates the
+// read-only verity device name. Device specs in cfg are looked up among
+// devices.
+func Setup(cfg *Config, name string, devices block.BlockDevices) (*dm.Device, error) {
+	dataPath, err := resolve(cfg.DataDevice, devices)
+	if err != nil {
+		return nil, err
+	}
+	hashPath := dataPath
+	if cfg.HashDevice != "" && cfg.HashDevice != cfg.DataDevice {
+		if hashPath, err = resolve(cfg.HashDevice, devices); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	hash, err := os.Open(hashPath)
+	if err != nil {
+		return nil, err
+	}
+	defer hash.Close()
+
+	sb, err := ReadSuperblock(hash, int64(cfg.HashOffset))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", hashPath, err)
+	}
+	if err := sb.CheckRoot(data, hash, cfg.HashOffset, cfg.RootHash); err != nil {
+		return nil, fmt.Errorf("%s: %w", hashPath, err)
+	}
+	size, err := data.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(size) < sb.DataBlocks*uint64(sb.DataBlockSize) {
+		return nil, fmt.Errorf("%s is smaller than its %d verity blocks", dataPath, sb.DataBlocks)
+	}
+
+	v := &dm.Verity{
+		Version:       int(sb.HashType),
+		DataDevice:    dataPath,
+		HashDevice:    hashPath,
+		DataBlockSize: sb.DataBlockSize,
+		HashBlockSize: sb.HashBlockSize,
+		DataBlocks:    sb.DataBlocks,
+		HashStart:     sb.hashStart(cfg.HashOffset),
+		Algorithm:     sb.Algorithm,
+		RootHash:      cfg.RootHash,
+		Salt:          sb.Salt,
+		Options:       cfg.Options,
+	}
+	return dmCreate(name, []dm.Target{v.Target()}, dm.CreateOpts{
+		UUID:     fmt.Sprintf("CRYPT-VERITY-%s-%s", hex.EncodeToString(sb.UUID[:]), name),
+		ReadOnly: true,
+	})
+}
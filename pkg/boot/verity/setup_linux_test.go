@@ -0,0 +1,60 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+func TestSetup(t *testing.T) {
+	img, off, root := image(3)
+	path := filepath.Join(t.TempDir(), "sda2")
+	if err := os.WriteFile(path, img, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []dm.Target
+	var opts dm.CreateOpts
+	dmCreate = func(name string, targets []dm.Target, o dm.CreateOpts) (*dm.Device, error) {
+		got, opts = targets, o
+		return &dm.Device{Name: name}, nil
+	}
+	resolve = func(spec string, _ block.BlockDevices) (string, error) {
+		if spec == "PARTLABEL=root" {
+			return path, nil
+		}
+		return "", fmt.Errorf("no device %s", spec)
+	}
+	defer func() { dmCreate, resolve = dm.Create, resolveDevice }()
+
+	cfg := &Config{DataDevice: "PARTLABEL=root", HashOffset: off, RootHash: root, Options: []string{"panic_on_corruption"}}
+	if _, err := Setup(cfg, "root", nil); err != nil {
+		t.Fatalf("Setup() = %v", err)
+	}
+	want := fmt.Sprintf("0 24 verity 1 %s %s 4096 4096 3 4 sha256 %x 5a17 1 panic_on_corruption", path, path, root)
+	if len(got) != 1 || got[0].String() != want {
+		t.Errorf("Setup() created %v, want %q", got, want)
+	}
+	if !opts.ReadOnly || opts.UUID != "CRYPT-VERITY-30313233343536373839616263646566-root" {
+		t.Errorf("Setup() created with %+v, want read-only with verity UUID", opts)
+	}
+
+	got = nil
+	root[1]++
+	if _, err := Setup(cfg, "root", nil); !errors.Is(err, ErrRootHash) || got != nil {
+		t.Errorf("Setup(wrong root hash) = %v, created %v; want %v", err, got, ErrRootHash)
+	}
+	cfg.HashDevice = "PARTLABEL=missing"
+	if _, err := Setup(cfg, "root", nil); err == nil || got != nil {
+		t.Errorf("Setup(missing hash device) = %v, created %v; want error", err, got)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package verity sets up dm-verity devices for verified boot.
+//
+// The root hash of a verity device comes from the kernel command line, in
+// the form systemd uses, or from a boot policy signed with an Ed25519 key.
+// Hash devices must have been formatted by veritysetup with a superblock.
+// Before the device is created, the top of the hash tree is checked
+// against the root hash, so that a wrong image is rejected up front
+// rather than on first read.
+package verity
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	// Register hash algorithms verity uses.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+var (
+	// ErrNotConfigured is returned if the kernel command line does not
+	// ask for a verity device.
+	ErrNotConfigured = errors.New("no verity root hash configured")
+
+	// ErrNoSuperblock is returned if a hash device has no veritysetup
+	// superblock.
+	ErrNoSuperblock = errors.New("no verity superblock found")
+
+	// ErrRootHash is returned if the hash tree does not match the root
+	// hash.
+	ErrRootHash = errors.New("verity root hash mismatch")
+)
+
+const (
+	superblockSize  = 512
+	superblockMagic = "verity\x00\x00"
+)
+
+// Superblock is the veritysetup superblock at the start of a hash area.
+type Superblock struct {
+	// HashType is 1 for normal hash trees and 0 for Chrome OS ones,
+	// which append the salt instead of prepending it.
+	HashType      uint32
+	UUID          [16]byte
+	Algorithm     string
+	DataBlockSize uint32
+	HashBlockSize uint32
+	DataBlocks    uint64
+	Salt          []byte
+}
+
+// ReadSuperblock reads the superblock at offset off of r.
+func ReadSuperblock(r io.ReaderAt, off int64) (*Superblock, error) {
+	b := make([]byte, superblockSize)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoSuperblock
+		}
+		return nil, err
+	}
+	le := binary.LittleEndian
+	if string(b[:8]) != superblockMagic {
+		return nil, ErrNoSuperblock
+	}
+	if v := le.Uint32(b[8:]); v != 1 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrNoSuperblock, v)
+	}
+	sb := &Superblock{
+		HashType:      le.Uint32(b[12:]),
+		Algorithm:     string(bytes.TrimRight(b[32:64], "\x00")),
+		DataBlockSize: le.Uint32(b[64:]),
+		HashBlockSize: le.Uint32(b[68:]),
+		DataBlocks:    le.Uint64(b[72:]),
+	}
+	copy(sb.UUID[:], b[16:32])
+	saltSize := int(le.Uint16(b[80:]))
+	if saltSize > 256 {
+		return nil, fmt.Errorf("%w: salt size %d", ErrNoSuperblock, saltSize)
+	}
+	sb.Salt = append([]byte{}, b[88:88+saltSize]...)
+	for _, s := range []uint32{sb.DataBlockSize, sb.HashBlockSize} {
+		if s < 512 || s&(s-1) != 0 {
+			return nil, fmt.Errorf("%w: bad block size %d", ErrNoSuperblock, s)
+		}
+	}
+	if sb.HashType > 1 {
+		return nil, fmt.Errorf("%w: unsupported hash type %d", ErrNoSuperblock, sb.HashType)
+	}
+	return sb, nil
+}
+
+func (sb *Superblock) hash() (crypto.Hash, error) {
+	switch sb.Algorithm {
+	case "sha1":
+		return crypto.SHA1, nil
+	case "sha256":
+		return crypto.SHA256, nil
+	case "sha512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported verity hash algorithm %q", sb.Algorithm)
+}
+
+// hashStart returns the block of the hash device where the tree starts,
+// right after the superblock at hashOffset.
+func (sb *Superblock) hashStart(hashOffset uint64) uint64 {
+	bs := uint64(sb.HashBlockSize)
+	return (hashOffset + superblockSize + bs - 1) / bs
+}
+
+// levels returns the number of levels of the hash tree.
+func (sb *Superblock) levels(digestSize int) int {
+	// Each digest takes a power of two bytes in a hash block.
+	perBlockBits := bits.Len32(sb.HashBlockSize/uint32(digestSize)) - 1
+	n := 0
+	for perBlockBits*n < 64 && (sb.DataBlocks-1)>>(perBlockBits*n) != 0 {
+		n++
+	}
+	return n
+}
+
+// CheckRoot checks that the top block of the hash tree, or the only data
+// block of tiny images, hashes to rootHash.
+func (sb *Superblock) CheckRoot(data, hash io.ReaderAt, hashOffset uint64, rootHash []byte) error {
+	alg, err := sb.hash()
+	if err != nil {
+		return err
+	}
+	if len(rootHash) != alg.Size() {
+		return fmt.Errorf("%w: root hash has %d bytes, %s needs %d", ErrRootHash, len(rootHash), sb.Algorithm, alg.Size())
+	}
+	if sb.DataBlocks == 0 {
+		return fmt.Errorf("%w: no data blocks", ErrNoSuperblock)
+	}
+
+	var block []byte
+	if sb.levels(alg.Size()) == 0 {
+		block = make([]byte, sb.DataBlockSize)
+		_, err = data.ReadAt(block, 0)
+	} else {
+		block = make([]byte, sb.HashBlockSize)
+		_, err = hash.ReadAt(block, int64(sb.hashStart(hashOffset)*uint64(sb.HashBlockSize)))
+	}
+	if err != nil {
+		return err
+	}
+
+	h := alg.New()
+	if sb.HashType == 1 {
+		h.Write(sb.Salt)
+		h.Write(block)
+	} else {
+		h.Write(block)
+		h.Write(sb.Salt)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, rootHash) {
+		return fmt.Errorf("%w: tree has %x, want %x", ErrRootHash, got, rootHash)
+	}
+	return nil
+}
+
+// Config describes a verity device to set up.
+type Config struct {
+	// DataDevice and HashDevice are paths or PARTUUID=, PARTLABEL= or
+	// UUID= specs. HashDevice defaults to DataDevice.
+	DataDevice string
+	HashDevice string
+
+	// HashOffset is the byte offset of the superblock on HashDevice.
+	HashOffset uint64
+
+	RootHash []byte
+
+	// Options are dm-verity optional parameters, e.g.
+	// panic_on_corruption.
+	Options []string
+}
+
+// cmdlineOptions maps the options of systemd.verity_root_options to
+// dm-verity parameters.
+var cmdlineOptions = map[string]string{
+	"ignore-corruption":     "ignore_corruption",
+	"restart-on-corruption": "restart_on_corruption",
+	"panic-on-corruption":   "panic_on_corruption",
+	"ignore-zero-blocks":    "ignore_zero_blocks",
+	"check-at-most-once":    "check_at_most_once",
+}
+
+// FromCmdline returns the verity configuration of the kernel command
+// line, which uses the systemd-veritysetup-generator parameters:
+//
+//	roothash=HEX
+//	systemd.verity_root_data=DEVICE
+//	systemd.verity_root_hash=DEVICE
+//	systemd.verity_root_options=hash-offset=N,panic-on-corruption,...
+//
+// The hash tree is on the data device unless systemd.verity_root_hash is
+// given.
+func FromCmdline(c *cmdline.CmdLine) (*Config, error) {
+	h, ok := c.Flag("roothash")
+	if !ok {
+		return nil, ErrNotConfigured
+	}
+	root, err := hex.DecodeString(h)
+	if err != nil || len(root) == 0 {
+		return nil, fmt.Errorf("bad roothash %q", h)
+	}
+	d, ok := c.Flag("systemd.verity_root_data")
+	if !ok || d == "" {
+		return nil, errors.New("roothash= needs systemd.verity_root_data=")
+	}
+	cfg := &Config{RootHash: root, DataDevice: d, HashDevice: d}
+	if d, ok := c.Flag("systemd.verity_root_hash"); ok {
+		cfg.HashDevice = d
+	}
+	opts, _ := c.Flag("systemd.verity_root_options")
+	for _, o := range strings.Split(opts, ",") {
+		k, v, _ := strings.Cut(o, "=")
+		switch {
+		case o == "":
+		case k == "hash-offset":
+			if cfg.HashOffset, err = strconv.ParseUint(v, 0, 64); err != nil {
+				return nil, fmt.Errorf("bad verity hash-offset %q", v)
+			}
+		case cmdlineOptions[o] != "":
+			cfg.Options = append(cfg.Options, cmdlineOptions[o])
+		default:
+			return nil, fmt.Errorf("unsupported verity option %q", o)
+		}
+	}
+	return cfg, nil
+}
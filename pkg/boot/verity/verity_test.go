@@ -0,0 +1,201 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+var testSalt = []byte{0x5a, 0x17}
+
+// image returns blocks 4 KiB data blocks followed by a veritysetup hash
+// area at hashOffset, and the root hash.
+func image(blocks int) ([]byte, uint64, []byte) {
+	const bs = 4096
+	img := make([]byte, blocks*bs)
+	for i := range img {
+		img[i] = byte(i / bs * 7)
+	}
+	hashOffset := uint64(len(img))
+
+	sb := make([]byte, bs)
+	copy(sb, superblockMagic)
+	le := binary.LittleEndian
+	le.PutUint32(sb[8:], 1)
+	le.PutUint32(sb[12:], 1)
+	copy(sb[16:], "0123456789abcdef")
+	copy(sb[32:], "sha256")
+	le.PutUint32(sb[64:], bs)
+	le.PutUint32(sb[68:], bs)
+	le.PutUint64(sb[72:], uint64(blocks))
+	le.PutUint16(sb[80:], uint16(len(testSalt)))
+	copy(sb[88:], testSalt)
+
+	salted := func(b []byte) []byte {
+		h := sha256.New()
+		h.Write(testSalt)
+		h.Write(b)
+		return h.Sum(nil)
+	}
+	// One level of hashes is enough for up to 128 blocks.
+	tree := make([]byte, bs)
+	for i := 0; i < blocks; i++ {
+		copy(tree[32*i:], salted(img[i*bs:(i+1)*bs]))
+	}
+	root := salted(tree)
+	if blocks == 1 {
+		root = salted(img)
+	}
+	img = append(img, sb...)
+	img = append(img, tree...)
+	return img, hashOffset, root
+}
+
+func TestCheckRoot(t *testing.T) {
+	for _, blocks := range []int{1, 3} {
+		img, off, root := image(blocks)
+		sb, err := ReadSuperblock(bytes.NewReader(img), int64(off))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &Superblock{
+			HashType:      1,
+			UUID:          [16]byte([]byte("0123456789abcdef")),
+			Algorithm:     "sha256",
+			DataBlockSize: 4096,
+			HashBlockSize: 4096,
+			DataBlocks:    uint64(blocks),
+			Salt:          testSalt,
+		}
+		if !reflect.DeepEqual(sb, want) {
+			t.Errorf("ReadSuperblock() = %+v, want %+v", sb, want)
+		}
+		r := bytes.NewReader(img)
+		if err := sb.CheckRoot(r, r, off, root); err != nil {
+			t.Errorf("%d blocks: CheckRoot() = %v", blocks, err)
+		}
+		root[0]++
+		if err := sb.CheckRoot(r, r, off, root); !errors.Is(err, ErrRootHash) {
+			t.Errorf("%d blocks: CheckRoot(wrong hash) = %v, want %v", blocks, err, ErrRootHash)
+		}
+		if err := sb.CheckRoot(r, r, off, root[:20]); !errors.Is(err, ErrRootHash) {
+			t.Errorf("%d blocks: CheckRoot(short hash) = %v, want %v", blocks, err, ErrRootHash)
+		}
+	}
+
+	img, off, _ := image(3)
+	if _, err := ReadSuperblock(bytes.NewReader(img), 0); !errors.Is(err, ErrNoSuperblock) {
+		t.Errorf("ReadSuperblock(data) = %v, want %v", err, ErrNoSuperblock)
+	}
+	img[off+64] = 3
+	if _, err := ReadSuperblock(bytes.NewReader(img), int64(off)); !errors.Is(err, ErrNoSuperblock) {
+		t.Errorf("ReadSuperblock(bad block size) = %v, want %v", err, ErrNoSuperblock)
+	}
+	if _, err := ReadSuperblock(bytes.NewReader(img[:100]), 0); !errors.Is(err, ErrNoSuperblock) {
+		t.Errorf("ReadSuperblock(short) = %v, want %v", err, ErrNoSuperblock)
+	}
+}
+
+func TestFromCmdline(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		flags map[string]string
+		want  *Config
+		err   bool
+	}{
+		{name: "none", flags: map[string]string{"root": "/dev/sda1"}, err: true},
+		{
+			name: "data only",
+			flags: map[string]string{
+				"roothash":                 "abcd",
+				"systemd.verity_root_data": "PARTLABEL=root",
+			},
+			want: &Config{DataDevice: "PARTLABEL=root", HashDevice: "PARTLABEL=root", RootHash: []byte{0xab, 0xcd}},
+		},
+		{
+			name: "all",
+			flags: map[string]string{
+				"roothash":                    "abcd",
+				"systemd.verity_root_data":    "/dev/sda2",
+				"systemd.verity_root_hash":    "/dev/sda3",
+				"systemd.verity_root_options": "hash-offset=4096,panic-on-corruption,ignore-zero-blocks",
+			},
+			want: &Config{
+				DataDevice: "/dev/sda2",
+				HashDevice: "/dev/sda3",
+				HashOffset: 4096,
+				RootHash:   []byte{0xab, 0xcd},
+				Options:    []string{"panic_on_corruption", "ignore_zero_blocks"},
+			},
+		},
+		{name: "bad hash", flags: map[string]string{"roothash": "xyz", "systemd.verity_root_data": "/dev/sda2"}, err: true},
+		{name: "no data", flags: map[string]string{"roothash": "abcd"}, err: true},
+		{
+			name: "bad option",
+			flags: map[string]string{
+				"roothash":                    "abcd",
+				"systemd.verity_root_data":    "/dev/sda2",
+				"systemd.verity_root_options": "fec-device=/dev/sda4",
+			},
+			err: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromCmdline(&cmdline.CmdLine{AsMap: tt.flags})
+			if (err != nil) != tt.err {
+				t.Fatalf("FromCmdline() = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromCmdline() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := FromCmdline(&cmdline.CmdLine{AsMap: map[string]string{}}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("FromCmdline(empty) = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(p string) []byte { return ed25519.Sign(priv, []byte(p)) }
+
+	p := `{"root_hash": "0102", "data_device": "PARTUUID=1234", "options": ["restart_on_corruption"]}`
+	got, err := ParsePolicy([]byte(p), sign(p), pub)
+	if err != nil {
+		t.Fatalf("ParsePolicy() = %v", err)
+	}
+	want := &Config{DataDevice: "PARTUUID=1234", HashDevice: "PARTUUID=1234", RootHash: []byte{1, 2}, Options: []string{"restart_on_corruption"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePolicy() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ParsePolicy([]byte(p+" "), sign(p), pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParsePolicy(modified) = %v, want %v", err, ErrBadSignature)
+	}
+	for _, bad := range []string{
+		`{"root_hash": "0102"}`,
+		`{"root_hash": "", "data_device": "/dev/sda"}`,
+		`not json`,
+	} {
+		if _, err := ParsePolicy([]byte(bad), sign(bad), pub); err == nil {
+			t.Errorf("ParsePolicy(%q) succeeded, want error", bad)
+		}
+	}
+	if _, err := ParsePolicy([]byte(p), sign(p), pub[:16]); err == nil {
+		t.Errorf("ParsePolicy(short key) succeeded, want error")
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// DefaultRoot is where the cgroup v2 hierarchy is usually mounted.
+const DefaultRoot = "/sys/fs/cgroup"
+
+var (
+	// ErrNotV2 is returned for directories which are not in a cgroup v2
+	// hierarchy.
+	ErrNotV2 = errors.New("not a cgroup v2 hierarchy")
+
+	// ErrName is returned for cgroup names leading out of the hierarchy.
+	ErrName = errors.New("bad cgroup name")
+)
+
+// Cgroup is a control group.
+type Cgroup struct {
+	// Root is the mount point of the hierarchy.
+	Root string
+	// Name is the path of the cgroup in the hierarchy, such as
+	// "services/sshd". The root cgroup is "".
+	Name string
+}
+
+// Check returns ErrNotV2 unless a cgroup v2 hierarchy is mounted at root.
+func Check(root string) error {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(root, &fs); err != nil {
+		return err
+	}
+	if fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return fmt.Errorf("%s: %w", root, ErrNotV2)
+	}
+	return nil
+}
+
+// cleanName returns name relative to the root of the hierarchy.
+func cleanName(name string) (string, error) {
+	clean := strings.TrimPrefix(filepath.Clean("/"+name), "/")
+	for _, e := range strings.Split(name, "/") {
+		if e == ".." {
+			return "", fmt.Errorf("%w %q", ErrName, name)
+		}
+	}
+	return clean, nil
+}
+
+// Open returns the existing cgroup name of the hierarchy at root.
+func Open(root, name string) (*Cgroup, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cgroup{Root: root, Name: name}
+	fi, err := os.Stat(c.Path())
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%w %q", ErrName, name)
+	}
+	return c, nil
+}
+
+// Create creates the cgroup name of the hierarchy at root, along with its
+// missing parents, and returns it. The controllers it needs must be enabled
+// in its parent with EnableControllers.
+func Create(root, name string) (*Cgroup, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cgroup{Root: root, Name: name}
+	if err := os.MkdirAll(c.Path(), 0o755); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Path returns the directory of c.
+func (c *Cgroup) Path() string {
+	return filepath.Join(c.Root, c.Name)
+}
+
+// String implements fmt.Stringer.
+func (c *Cgroup) String() string {
+	return "/" + c.Name
+}
+
+// Parent returns the parent of c, or nil for the root cgroup.
+func (c *Cgroup) Parent() *Cgroup {
+	if c.Name == "" {
+		return nil
+	}
+	name := filepath.Dir(c.Name)
+	if name == "." {
+		name = ""
+	}
+	return &Cgroup{Root: c.Root, Name: name}
+}
+
+// Children returns the cgroups directly below c, sorted by name.
+func (c *Cgroup) Children() ([]*Cgroup, error) {
+	ents, err := os.ReadDir(c.Path())
+	if err != nil {
+		return nil, err
+	}
+	var kids []*Cgroup
+	for _, e := range ents {
+		if e.IsDir() {
+			kids = append(kids, &Cgroup{Root: c.Root, Name: filepath.Join(c.Name, e.Name())})
+		}
+	}
+	return kids, nil
+}
+
+// Walk calls fn for c and all cgroups below it, parents first.
+func (c *Cgroup) Walk(fn func(*Cgroup) error) error {
+	if err := fn(c); err != nil {
+		return err
+	}
+	kids, err := c.Children()
+	if err != nil {
+		return err
+	}
+	for _, k := range kids {
+		if err := k.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes c, which must have no processes. With recursive, the
+// cgroups below it go first; otherwise it must have none.
+func (c *Cgroup) Delete(recursive bool) error {
+	if c.Name == "" {
+		return fmt.Errorf("%w: the root cgroup cannot be deleted", ErrName)
+	}
+	if recursive {
+		kids, err := c.Children()
+		if err != nil {
+			return err
+		}
+		for _, k := range kids {
+			if err := k.Delete(true); err != nil {
+				return err
+			}
+		}
+	}
+	// The interface files go with the directory.
+	return unix.Rmdir(c.Path())
+}
+
+// Get returns the contents of the interface file of c, without the
+// trailing newline.
+func (c *Cgroup) Get(file string) (string, error) {
+	if strings.ContainsRune(file, '/') {
+		return "", fmt.Errorf("%w: interface file %q", ErrName, file)
+	}
+	b, err := os.ReadFile(filepath.Join(c.Path(), file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// Set writes value to the interface file of c. The file must exist: the
+// kernel provides those of the controllers enabled for c.
+func (c *Cgroup) Set(file, value string) error {
+	if strings.ContainsRune(file, '/') {
+		return fmt.Errorf("%w: interface file %q", ErrName, file)
+	}
+	f, err := os.OpenFile(filepath.Join(c.Path(), file), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return fmt.Errorf("setting %s of %s to %q: %w", file, c, value, err)
+	}
+	return f.Close()
+}
+
+// Controllers returns the controllers available in c.
+func (c *Cgroup) Controllers() ([]string, error) {
+	s, err := c.Get("cgroup.controllers")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(s), nil
+}
+
+// EnableControllers makes controllers available to the children of c, and
+// to c itself by doing the same in each of its parents.
+func (c *Cgroup) EnableControllers(controllers ...string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+	if p := c.Parent(); p != nil {
+		if err := p.EnableControllers(controllers...); err != nil {
+			return err
+		}
+	}
+	enabled, err := c.Get("cgroup.subtree_control")
+	if err != nil {
+		return err
+	}
+	have := strings.Fields(enabled)
+	var add []string
+	for _, ctl := range controllers {
+		if !contains(have, ctl) {
+			add = append(add, "+"+ctl)
+		}
+	}
+	if len(add) == 0 {
+		return nil
+	}
+	return c.Set("cgroup.subtree_control", strings.Join(add, " "))
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// AddProc moves the process pid, with all its threads, into c.
+func (c *Cgroup) AddProc(pid int) error {
+	return c.Set("cgroup.procs", strconv.Itoa(pid))
+}
+
+// Procs returns the processes in c, not counting those below it.
+func (c *Cgroup) Procs() ([]int, error) {
+	f, err := os.Open(filepath.Join(c.Path(), "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var pids []int
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		pid, err := strconv.Atoi(strings.TrimSpace(s.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: cgroup.procs: %w", c, err)
+		}
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids, s.Err()
+}
+
+// Kill kills all processes in c and below it.
+func (c *Cgroup) Kill() error {
+	return c.Set("cgroup.kill", "1")
+}
+
+// OfProcess returns the cgroup of the process pid in the hierarchy at root,
+// from the PID's /proc/PID/cgroup.
+func OfProcess(root string, pid int) (*Cgroup, error) {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if name, ok := strings.CutPrefix(line, "0::"); ok {
+			return &Cgroup{Root: root, Name: strings.TrimPrefix(filepath.Clean(name), "/")}, nil
+		}
+	}
+	return nil, fmt.Errorf("process %d: %w", pid, ErrNotV2)
+}
+
+// SetLimits enables the controllers l needs for c and sets its limits.
+func (c *Cgroup) SetLimits(l *Limits) error {
+	s, err := l.settings()
+	if err != nil {
+		return err
+	}
+	var controllers []string
+	for _, set := range s {
+		if !contains(controllers, set.controller) {
+			controllers = append(controllers, set.controller)
+		}
+	}
+	if p := c.Parent(); p != nil {
+		if err := p.EnableControllers(controllers...); err != nil {
+			return fmt.Errorf("enabling %s for %s: %w", strings.Join(controllers, ", "), c, err)
+		}
+	}
+	for _, set := range s {
+		if err := c.Set(set.file, set.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockDevice returns the MAJOR:MINOR of the block device file name.
+func blockDevice(name string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(name, &st); err != nil {
+		return "", err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", fmt.Errorf("not a block device")
+	}
+	return fmt.Sprintf("%d:%d", unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev))), nil
+}
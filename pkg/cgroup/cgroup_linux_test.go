@@ -0,0 +1,215 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cgroup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fake creates the interface files of a cgroup in dir, which the kernel
+// would provide.
+func fake(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(v), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func read(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCgroup(t *testing.T) {
+	root := t.TempDir()
+	c, err := Create(root, "/services/sshd/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "services/sshd" || c.String() != "/services/sshd" || c.Parent().Name != "services" || c.Parent().Parent().Name != "" || c.Parent().Parent().Parent() != nil {
+		t.Errorf("Create() = %+v with bad names", c)
+	}
+	if _, err := Create(root, "../escape"); !errors.Is(err, ErrName) {
+		t.Errorf("Create(../escape) = %v, want %v", err, ErrName)
+	}
+	if _, err := Open(root, "services/nope"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open(missing) = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := Create(root, "services/ntpd"); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	r, err := Open(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Walk(func(c *Cgroup) error {
+		walked = append(walked, c.String())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/", "/services", "/services/ntpd", "/services/sshd"}; !reflect.DeepEqual(walked, want) {
+		t.Errorf("Walk() = %q, want %q", walked, want)
+	}
+
+	fake(t, c.Path(), map[string]string{"cgroup.procs": "12\n3\n"})
+	pids, err := c.Procs()
+	if err != nil || !reflect.DeepEqual(pids, []int{3, 12}) {
+		t.Errorf("Procs() = %v, %v, want [3 12]", pids, err)
+	}
+	if err := c.AddProc(42); err != nil || read(t, filepath.Join(c.Path(), "cgroup.procs")) != "42" {
+		t.Errorf("AddProc(42) = %v", err)
+	}
+	if err := c.Set("cpu.max", "1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Set(missing file) = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := c.Get("../cgroup.procs"); !errors.Is(err, ErrName) {
+		t.Errorf("Get(../cgroup.procs) = %v, want %v", err, ErrName)
+	}
+
+	n, err := Open(root, "services/ntpd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Delete(false); err != nil {
+		t.Errorf("Delete() = %v", err)
+	}
+	if err := r.Delete(true); !errors.Is(err, ErrName) {
+		t.Errorf("Delete(root) = %v, want %v", err, ErrName)
+	}
+}
+
+func TestEnableControllers(t *testing.T) {
+	root := t.TempDir()
+	fake(t, root, map[string]string{"cgroup.subtree_control": "cpu memory"})
+	fake(t, filepath.Join(root, "services"), map[string]string{"cgroup.subtree_control": "memory"})
+	c := &Cgroup{Root: root, Name: "services"}
+	if err := c.EnableControllers("memory", "pids"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, filepath.Join(root, "cgroup.subtree_control")); got != "+pids" {
+		t.Errorf("root subtree_control = %q, want %q", got, "+pids")
+	}
+	if got := read(t, filepath.Join(root, "services/cgroup.subtree_control")); got != "+pids" {
+		t.Errorf("services subtree_control = %q, want %q", got, "+pids")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		l     Limits
+		files map[string]string
+		err   error
+	}{
+		{
+			name: "all",
+			l:    Limits{CPU: "0.5", CPUWeight: 200, Memory: "256M", MemoryHigh: "1g", Swap: "0", Pids: "64"},
+			files: map[string]string{
+				"cpu.max":         "50000 100000",
+				"cpu.weight":      "200",
+				"memory.max":      "268435456",
+				"memory.high":     "1073741824",
+				"memory.swap.max": "0",
+				"pids.max":        "64",
+			},
+		},
+		{
+			name:  "max",
+			l:     Limits{CPU: "max", Memory: "max", Pids: "max"},
+			files: map[string]string{"cpu.max": "max 100000", "memory.max": "max", "pids.max": "max"},
+		},
+		{
+			name:  "tiny cpu",
+			l:     Limits{CPU: "0.001"},
+			files: map[string]string{"cpu.max": "1000 100000"},
+		},
+		{
+			name:  "io",
+			l:     Limits{IO: []string{"8:0 rbps=1M wiops=100 wbps=max"}},
+			files: map[string]string{"io.max": "8:0 rbps=1048576 wiops=100 wbps=max"},
+		},
+		{name: "bad cpu", l: Limits{CPU: "-1"}, err: ErrLimit},
+		{name: "bad weight", l: Limits{CPUWeight: 10001}, err: ErrLimit},
+		{name: "bad size", l: Limits{Memory: "12X"}, err: ErrLimit},
+		{name: "bad pids", l: Limits{Pids: "lots"}, err: ErrLimit},
+		{name: "bad io key", l: Limits{IO: []string{"8:0 speed=1"}}, err: ErrLimit},
+		{name: "bad io device", l: Limits{IO: []string{"/nonexistent rbps=1"}}, err: ErrLimit},
+		{name: "io not a block device", l: Limits{IO: []string{"/dev/null rbps=1"}}, err: ErrLimit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			fake(t, root, map[string]string{"cgroup.subtree_control": ""})
+			files := map[string]string{}
+			for _, f := range []string{"cpu.max", "cpu.weight", "memory.max", "memory.high", "memory.swap.max", "pids.max", "io.max"} {
+				files[f] = ""
+			}
+			fake(t, filepath.Join(root, "svc"), files)
+			c := &Cgroup{Root: root, Name: "svc"}
+			err := c.SetLimits(&tt.l)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SetLimits() = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			for f, want := range tt.files {
+				if got := read(t, filepath.Join(c.Path(), f)); got != want {
+					t.Errorf("%s = %q, want %q", f, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	root := t.TempDir()
+	fake(t, root, map[string]string{
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\nnr_periods 0\n",
+		"memory.current": "4096\n",
+		"memory.max":     "max\n",
+		"pids.current":   "3\n",
+		"io.stat":        "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n",
+	})
+	c := &Cgroup{Root: root}
+	st, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Stats{
+		CPUUsage:  1500 * time.Millisecond,
+		CPUUser:   time.Second,
+		CPUSystem: 500 * time.Millisecond,
+		Memory:    4096,
+		MemoryMax: "max",
+		Pids:      3,
+		IO:        []IOStat{{Device: "8:0", RBytes: 1024, WBytes: 2048, RIOs: 1, WIOs: 2}},
+	}
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+
+	// Without controllers there is nothing to report.
+	empty := &Cgroup{Root: t.TempDir()}
+	if st, err := empty.Stats(); err != nil || !reflect.DeepEqual(st, &Stats{}) {
+		t.Errorf("Stats() = %+v, %v, want zero", st, err)
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package cgroup
+
+import "errors"
+
+// blockDevice is not supported: only Linux has cgroups.
+func blockDevice(name string) (string, error) {
+	return "", errors.ErrUnsupported
+}
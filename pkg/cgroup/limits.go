@@ -0,0 +1,189 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cgroup manages control groups of the unified cgroup v2 hierarchy:
+// creating and deleting them, setting their CPU, memory, PID and I/O
+// limits, moving processes into them and reading their usage.
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Max is the value of an interface file for no limit.
+const Max = "max"
+
+// cpuPeriod is the period of cpu.max, in microseconds.
+const cpuPeriod = 100000
+
+// ErrLimit is returned for malformed limits.
+var ErrLimit = errors.New("bad limit")
+
+// Limits are the limits of a cgroup. Empty fields are left as they are.
+type Limits struct {
+	// CPU is how many CPUs' worth of time the cgroup may use, as in
+	// "0.5" or "2", or "max".
+	CPU string `json:"cpu,omitempty"`
+	// CPUWeight is the cgroup's share of CPU time against its siblings,
+	// from 1 to 10000; the default is 100.
+	CPUWeight uint64 `json:"cpu_weight,omitempty"`
+
+	// Memory is the most memory the cgroup may use, as a size such as
+	// "256M", or "max". MemoryHigh is where it is throttled and
+	// reclaimed from, and Swap is the most swap it may use.
+	Memory     string `json:"memory,omitempty"`
+	MemoryHigh string `json:"memory_high,omitempty"`
+	Swap       string `json:"swap,omitempty"`
+
+	// Pids is the most processes and threads the cgroup may have, or
+	// "max".
+	Pids string `json:"pids,omitempty"`
+
+	// IO limits block devices, as "DEVICE KEY=VALUE..." where DEVICE is
+	// a device file or MAJOR:MINOR and the keys are rbps and wbps, with
+	// sizes, and riops and wiops.
+	IO []string `json:"io,omitempty"`
+}
+
+// ParseSize parses a size in bytes, which may have a K, M, G or T suffix
+// for powers of 1024, or "max".
+func ParseSize(s string) (string, error) {
+	if s == Max {
+		return s, nil
+	}
+	num, shift := s, 0
+	if n := len(s); n > 0 {
+		switch strings.ToUpper(s[n-1:]) {
+		case "K":
+			shift = 10
+		case "M":
+			shift = 20
+		case "G":
+			shift = 30
+		case "T":
+			shift = 40
+		}
+		if shift != 0 {
+			num = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || v > (1<<63-1)>>shift {
+		return "", fmt.Errorf("%w: size %q", ErrLimit, s)
+	}
+	return strconv.FormatUint(v<<shift, 10), nil
+}
+
+// parseCPU returns cpu.max for a number of CPUs.
+func parseCPU(s string) (string, error) {
+	if s == Max {
+		return Max + " " + strconv.Itoa(cpuPeriod), nil
+	}
+	cpus, err := strconv.ParseFloat(s, 64)
+	if err != nil || cpus <= 0 || cpus > 1<<20 {
+		return "", fmt.Errorf("%w: CPUs %q", ErrLimit, s)
+	}
+	// The kernel wants a quota of at least 1ms.
+	quota := max(int64(cpus*cpuPeriod), 1000)
+	return fmt.Sprintf("%d %d", quota, cpuPeriod), nil
+}
+
+// parseIO returns the line of io.max for a device limit.
+func parseIO(s string) (string, error) {
+	f := strings.Fields(s)
+	if len(f) < 2 {
+		return "", fmt.Errorf("%w: I/O %q", ErrLimit, s)
+	}
+	dev := f[0]
+	if !strings.Contains(dev, ":") {
+		var err error
+		if dev, err = blockDevice(dev); err != nil {
+			return "", fmt.Errorf("%w: I/O device %q: %w", ErrLimit, f[0], err)
+		}
+	}
+	out := []string{dev}
+	for _, kv := range f[1:] {
+		k, v, ok := strings.Cut(kv, "=")
+		var err error
+		switch {
+		case !ok:
+			err = ErrLimit
+		case k == "rbps" || k == "wbps":
+			v, err = ParseSize(v)
+		case k == "riops" || k == "wiops":
+			if v != Max {
+				_, err = strconv.ParseUint(v, 10, 64)
+			}
+		default:
+			err = ErrLimit
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: I/O %q", ErrLimit, kv)
+		}
+		out = append(out, k+"="+v)
+	}
+	return strings.Join(out, " "), nil
+}
+
+// setting is a value for an interface file.
+type setting struct {
+	controller, file, value string
+}
+
+// settings returns what l writes to which interface files.
+func (l *Limits) settings() ([]setting, error) {
+	var s []setting
+	add := func(controller, file, v string, parse func(string) (string, error)) error {
+		if v == "" {
+			return nil
+		}
+		v, err := parse(v)
+		if err != nil {
+			return err
+		}
+		s = append(s, setting{controller, file, v})
+		return nil
+	}
+	if err := add("cpu", "cpu.max", l.CPU, parseCPU); err != nil {
+		return nil, err
+	}
+	if l.CPUWeight != 0 {
+		if l.CPUWeight > 10000 {
+			return nil, fmt.Errorf("%w: CPU weight %d", ErrLimit, l.CPUWeight)
+		}
+		s = append(s, setting{"cpu", "cpu.weight", strconv.FormatUint(l.CPUWeight, 10)})
+	}
+	for _, m := range []struct{ file, v string }{
+		{"memory.max", l.Memory},
+		{"memory.high", l.MemoryHigh},
+		{"memory.swap.max", l.Swap},
+	} {
+		if err := add("memory", m.file, m.v, ParseSize); err != nil {
+			return nil, err
+		}
+	}
+	if err := add("pids", "pids.max", l.Pids, func(v string) (string, error) {
+		if _, err := strconv.ParseUint(v, 10, 64); err != nil && v != Max {
+			return "", fmt.Errorf("%w: pids %q", ErrLimit, v)
+		}
+		return v, nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, io := range l.IO {
+		if err := add("io", "io.max", io, parseIO); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Check returns an error if l is malformed.
+func (l *Limits) Check() error {
+	_, err := l.settings()
+	return err
+}
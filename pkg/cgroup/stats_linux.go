@@ -0,0 +1,155 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IOStat is the I/O of a cgroup on one device.
+type IOStat struct {
+	// Device is MAJOR:MINOR.
+	Device string `json:"device"`
+	RBytes uint64 `json:"rbytes"`
+	WBytes uint64 `json:"wbytes"`
+	RIOs   uint64 `json:"rios"`
+	WIOs   uint64 `json:"wios"`
+}
+
+// Stats is the usage of a cgroup, including the cgroups below it. Usage of
+// controllers not enabled for the cgroup is zero.
+type Stats struct {
+	CPUUsage  time.Duration `json:"cpu_usage"`
+	CPUUser   time.Duration `json:"cpu_user"`
+	CPUSystem time.Duration `json:"cpu_system"`
+
+	// Memory is the memory in use, in bytes, and MemoryMax the limit,
+	// or "max".
+	Memory    uint64 `json:"memory"`
+	MemoryMax string `json:"memory_max,omitempty"`
+
+	// Pids is the number of processes and threads, and PidsMax the
+	// limit, or "max".
+	Pids    uint64 `json:"pids"`
+	PidsMax string `json:"pids_max,omitempty"`
+
+	IO []IOStat `json:"io,omitempty"`
+}
+
+// parseFlatKeyed parses an interface file of "KEY VALUE" lines.
+func parseFlatKeyed(s string) (map[string]uint64, error) {
+	m := map[string]uint64{}
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("bad line %q", line)
+		}
+		v, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad line %q", line)
+		}
+		m[f[0]] = v
+	}
+	return m, nil
+}
+
+// ParseIOStat parses io.stat, whose lines are "MAJOR:MINOR KEY=VALUE...".
+func ParseIOStat(s string) ([]IOStat, error) {
+	var stats []IOStat
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		st := IOStat{Device: f[0]}
+		for _, kv := range f[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("io.stat: bad line %q", line)
+			}
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("io.stat: bad line %q", line)
+			}
+			switch k {
+			case "rbytes":
+				st.RBytes = n
+			case "wbytes":
+				st.WBytes = n
+			case "rios":
+				st.RIOs = n
+			case "wios":
+				st.WIOs = n
+			}
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// Stats returns the usage of c.
+func (c *Cgroup) Stats() (*Stats, error) {
+	var st Stats
+	// get returns "" for the files of controllers that are not enabled.
+	get := func(file string) (string, error) {
+		s, err := c.Get(file)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return s, err
+	}
+	s, err := get("cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	if s != "" {
+		m, err := parseFlatKeyed(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cpu.stat: %w", c, err)
+		}
+		st.CPUUsage = time.Duration(m["usage_usec"]) * time.Microsecond
+		st.CPUUser = time.Duration(m["user_usec"]) * time.Microsecond
+		st.CPUSystem = time.Duration(m["system_usec"]) * time.Microsecond
+	}
+	for _, u := range []struct {
+		file string
+		v    *uint64
+	}{
+		{"memory.current", &st.Memory},
+		{"pids.current", &st.Pids},
+	} {
+		s, err := get(u.file)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			continue
+		}
+		if *u.v, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", c, u.file, err)
+		}
+	}
+	if st.MemoryMax, err = get("memory.max"); err != nil {
+		return nil, err
+	}
+	if st.PidsMax, err = get("pids.max"); err != nil {
+		return nil, err
+	}
+	if s, err = get("io.stat"); err != nil {
+		return nil, err
+	}
+	if st.IO, err = ParseIOStat(s); err != nil {
+		return nil, fmt.Errorf("%s: %w", c, err)
+	}
+	return &st, nil
+}
@@ -0,0 +1,390 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package checksum implements shasum and the sha256sum family of commands:
+// it prints digests of files and verifies files against lists of them.
+//
+// Digests are printed as GNU coreutils prints them,
+//
+//	<hex digest>  <file>
+//
+// or, with Tag, as BSD systems do,
+//
+//	SHA256 (<file>) = <hex digest>
+//
+// and lists in either form are checked. Files are hashed in parallel, and
+// results are printed in the order the files were given.
+package checksum
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// ErrMismatch is returned when files do not match their digests.
+	ErrMismatch = errors.New("computed checksums did NOT match")
+	// ErrUnreadable is returned when files listed cannot be read.
+	ErrUnreadable = errors.New("listed files could not be read")
+	// ErrFormat is returned for lines of a list which are not digests.
+	ErrFormat = errors.New("improperly formatted checksum lines")
+)
+
+// Algorithm is a digest algorithm.
+type Algorithm struct {
+	// Name is the name of the algorithm in BSD-style lines.
+	Name string
+	// Cmd is the name of the command printing its digests.
+	Cmd string
+	// New returns a hash computing digests.
+	New func() hash.Hash
+}
+
+// The algorithms of the commands.
+var (
+	MD5     = Algorithm{Name: "MD5", Cmd: "md5sum", New: md5.New}
+	SHA1    = Algorithm{Name: "SHA1", Cmd: "sha1sum", New: sha1.New}
+	SHA224  = Algorithm{Name: "SHA224", Cmd: "sha224sum", New: sha256.New224}
+	SHA256  = Algorithm{Name: "SHA256", Cmd: "sha256sum", New: sha256.New}
+	SHA384  = Algorithm{Name: "SHA384", Cmd: "sha384sum", New: sha512.New384}
+	SHA512  = Algorithm{Name: "SHA512", Cmd: "sha512sum", New: sha512.New}
+	BLAKE2b = Algorithm{Name: "BLAKE2b", Cmd: "b2sum", New: newBLAKE2b}
+)
+
+func newBLAKE2b() hash.Hash {
+	// blake2b.New512 only fails for keys that are too long.
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+// Options configures how digests are printed and checked.
+type Options struct {
+	Algorithm
+
+	// Tag prints BSD-style lines.
+	Tag bool
+	// Binary marks files as read in binary mode, with a '*' before their
+	// names. It changes nothing else.
+	Binary bool
+	// Jobs is how many files are hashed at once, runtime.GOMAXPROCS if 0.
+	Jobs int
+
+	// Quiet does not print files which match when checking.
+	Quiet bool
+	// Status prints nothing when checking; only the error tells.
+	Status bool
+	// Strict fails checks for improperly formatted lines.
+	Strict bool
+	// Warn prints improperly formatted lines when checking.
+	Warn bool
+	// IgnoreMissing skips files which do not exist when checking.
+	IgnoreMissing bool
+}
+
+type result struct {
+	sum []byte
+	err error
+}
+
+// hashAll hashes the files, "-" being stdin, in parallel, and returns their
+// results in order.
+func (o Options) hashAll(stdin io.Reader, names []string) []chan result {
+	jobs := o.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	results := make([]chan result, len(names))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	var stdinMu sync.Mutex
+	sem := make(chan struct{}, jobs)
+	go func() {
+		// Files are started in order, so that results come in about
+		// the order they are printed.
+		for i, name := range names {
+			sem <- struct{}{}
+			go func(c chan result, name string) {
+				defer func() { <-sem }()
+				if name == "-" {
+					stdinMu.Lock()
+					defer stdinMu.Unlock()
+				}
+				sum, err := o.hash(stdin, name)
+				c <- result{sum, err}
+			}(results[i], name)
+		}
+	}()
+	return results
+}
+
+func (o Options) hash(stdin io.Reader, name string) ([]byte, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	h := o.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// escape returns name as GNU lines have it: names with newlines or
+// backslashes have them escaped, and the line starts with a backslash.
+func escape(name string) (string, string) {
+	if !strings.ContainsAny(name, "\\\n\r") {
+		return "", name
+	}
+	r := strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+	return "\\", r.Replace(name)
+}
+
+func unescape(name string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '\\' {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i++; i == len(name) {
+			return "", false
+		}
+		switch name[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// Line returns the line listing the digest sum of the file name.
+func (o Options) Line(name string, sum []byte) string {
+	prefix, name := escape(name)
+	if o.Tag {
+		return fmt.Sprintf("%s%s (%s) = %x", prefix, o.Name, name, sum)
+	}
+	mode := " "
+	if o.Binary {
+		mode = "*"
+	}
+	return fmt.Sprintf("%s%x %s%s", prefix, sum, mode, name)
+}
+
+// ParseLine returns the file and digest a line of a list, in either form,
+// lists.
+func (o Options) ParseLine(line string) (string, []byte, error) {
+	line, escaped := strings.CutPrefix(line, "\\")
+	size := o.New().Size()
+
+	var name, digest string
+	if rest, ok := strings.CutPrefix(line, o.Name+" ("); ok {
+		i := strings.LastIndex(rest, ") = ")
+		if i < 0 {
+			return "", nil, fmt.Errorf("%w: %q", ErrFormat, line)
+		}
+		name, digest = rest[:i], rest[i+len(") = "):]
+	} else {
+		var ok bool
+		if digest, name, ok = strings.Cut(line, " "); !ok {
+			return "", nil, fmt.Errorf("%w: %q", ErrFormat, line)
+		}
+		// The mode is optional, as in lines md5sum and shasum print.
+		if strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*") {
+			name = name[1:]
+		}
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != size || name == "" {
+		return "", nil, fmt.Errorf("%w: %q", ErrFormat, line)
+	}
+	if escaped {
+		var ok bool
+		if name, ok = unescape(name); !ok {
+			return "", nil, fmt.Errorf("%w: %q", ErrFormat, line)
+		}
+	}
+	return name, sum, nil
+}
+
+// Sum prints a line with the digest of each file, "-" being stdin, to w.
+// Files which cannot be read are reported to stderr, and the first error is
+// returned once all files are hashed.
+func (o Options) Sum(w, stderr io.Writer, stdin io.Reader, names []string) error {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	var firstErr error
+	for i, c := range o.hashAll(stdin, names) {
+		r := <-c
+		if r.err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", o.Cmd, r.err)
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		fmt.Fprintln(w, o.Line(names[i], r.sum))
+	}
+	return firstErr
+}
+
+// entry is a file listed with its digest.
+type entry struct {
+	name string
+	sum  []byte
+}
+
+// Check verifies the files listed in each list, "-" being stdin, printing
+// whether each matches to w. It returns an error wrapping ErrMismatch,
+// ErrUnreadable or ErrFormat when checks failed.
+func (o Options) Check(w, stderr io.Writer, stdin io.Reader, lists []string) error {
+	if len(lists) == 0 {
+		lists = []string{"-"}
+	}
+	var (
+		errs []error
+		bad  int
+	)
+	for _, list := range lists {
+		entries, n, err := o.readList(stderr, stdin, list)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", o.Cmd, err)
+			errs = append(errs, err)
+			continue
+		}
+		bad += n
+		if len(entries) == 0 {
+			err := fmt.Errorf("%s: no properly formatted checksum lines found: %w", list, ErrFormat)
+			if !o.Status {
+				fmt.Fprintf(stderr, "%s: %v\n", o.Cmd, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, o.checkEntries(w, stderr, stdin, entries)...)
+	}
+	if bad > 0 {
+		err := fmt.Errorf("%d %w", bad, ErrFormat)
+		if !o.Status {
+			fmt.Fprintf(stderr, "%s: WARNING: %v\n", o.Cmd, err)
+		}
+		if o.Strict {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readList returns the entries of list and how many of its lines are
+// improperly formatted.
+func (o Options) readList(stderr io.Writer, stdin io.Reader, list string) ([]entry, int, error) {
+	r := stdin
+	if list != "-" {
+		f, err := os.Open(list)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var (
+		entries []entry
+		bad     int
+	)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, sum, err := o.ParseLine(line)
+		if err != nil {
+			bad++
+			if o.Warn && !o.Status {
+				fmt.Fprintf(stderr, "%s: %s: %d: improperly formatted %s checksum line\n", o.Cmd, list, n, o.Name)
+			}
+			continue
+		}
+		entries = append(entries, entry{name: name, sum: sum})
+	}
+	if err := s.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", list, err)
+	}
+	return entries, bad, nil
+}
+
+func (o Options) checkEntries(w, stderr io.Writer, stdin io.Reader, entries []entry) []error {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.name
+	}
+	var mismatched, unreadable int
+	for i, c := range o.hashAll(stdin, names) {
+		e, r := entries[i], <-c
+		prefix, name := escape(e.name)
+		name = prefix + name
+		switch {
+		case r.err != nil && o.IgnoreMissing && errors.Is(r.err, os.ErrNotExist):
+		case r.err != nil:
+			unreadable++
+			if !o.Status {
+				fmt.Fprintf(stderr, "%s: %v\n", o.Cmd, r.err)
+				fmt.Fprintf(w, "%s: FAILED open or read\n", name)
+			}
+		case !bytes.Equal(r.sum, e.sum):
+			mismatched++
+			if !o.Status {
+				fmt.Fprintf(w, "%s: FAILED\n", name)
+			}
+		case !o.Quiet && !o.Status:
+			fmt.Fprintf(w, "%s: OK\n", name)
+		}
+	}
+
+	var errs []error
+	for _, f := range []struct {
+		n   int
+		err error
+	}{
+		{unreadable, ErrUnreadable},
+		{mismatched, ErrMismatch},
+	} {
+		if f.n == 0 {
+			continue
+		}
+		err := fmt.Errorf("%d %w", f.n, f.err)
+		if !o.Status {
+			fmt.Fprintf(stderr, "%s: WARNING: %v\n", o.Cmd, err)
+		}
+		errs = append(errs, err)
+	}
+	return errs
+}
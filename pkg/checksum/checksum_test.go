@@ -0,0 +1,247 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Digests of "abc\n".
+const (
+	abcSHA256 = "edeaaff3f1774ad2888673770c6d64097e391bc362d7d6fb34982ddf0efd18cb"
+	abcSHA1   = "03cfd743661f07975fa2f1220c5194cbaff48451"
+	abcMD5    = "0bee89b07a248e27c83fc3d5951213c1"
+)
+
+func TestAlgorithms(t *testing.T) {
+	for _, tt := range []struct {
+		a    Algorithm
+		want string
+	}{
+		{a: MD5, want: abcMD5},
+		{a: SHA1, want: abcSHA1},
+		{a: SHA224, want: "f5c93b6f06f7c56d7ea720c121e3b1fb6730e5cf5f18d776bf0f2d88"},
+		{a: SHA256, want: abcSHA256},
+		{a: SHA384, want: "e8d1420b4ff41c3f12186d894a99e1c4aa681da79c47007e9dadecd9ecb0482ee1e224510e7484078c0289f34396b9c3"},
+		{a: SHA512, want: "4f285d0c0cc77286d8731798b7aae2639e28270d4166f40d769cbbdca5230714d848483d364e2f39fe6cb9083c15229b39a33615ebc6d57605f7c43f6906739d"},
+		{a: BLAKE2b, want: "426526ad3aa99ac6bbb98555c6c30b5a55189d0d7537d9214053b886f365a84bd7e68c91b544a8f485fa842c0ea9fbc4fb92277290264e685eed9e67b7621fc9"},
+	} {
+		t.Run(tt.a.Cmd, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := (Options{Algorithm: tt.a}).Sum(&out, io.Discard, strings.NewReader("abc\n"), nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Fields(out.String())[0]; got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.a.Cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+	sum, _ := hex.DecodeString(abcSHA256)
+	for _, tt := range []struct {
+		name string
+		o    Options
+		file string
+		want string
+	}{
+		{name: "gnu", o: Options{Algorithm: SHA256}, file: "a", want: abcSHA256 + "  a"},
+		{name: "binary", o: Options{Algorithm: SHA256, Binary: true}, file: "a", want: abcSHA256 + " *a"},
+		{name: "bsd", o: Options{Algorithm: SHA256, Tag: true}, file: "a b", want: "SHA256 (a b) = " + abcSHA256},
+		{name: "escaped", o: Options{Algorithm: SHA256}, file: "a\nb\\c", want: "\\" + abcSHA256 + "  a\\nb\\\\c"},
+		{name: "bsd escaped", o: Options{Algorithm: SHA256, Tag: true}, file: "a\nb", want: "\\SHA256 (a\\nb) = " + abcSHA256},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			line := tt.o.Line(tt.file, sum)
+			if line != tt.want {
+				t.Errorf("Line(%q) = %q, want %q", tt.file, line, tt.want)
+			}
+			file, got, err := tt.o.ParseLine(line)
+			if err != nil || file != tt.file || !bytes.Equal(got, sum) {
+				t.Errorf("ParseLine(%q) = %q, %x, %v, want %q, %x, nil", line, file, got, err, tt.file, sum)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	o := Options{Algorithm: SHA256}
+	for _, tt := range []struct {
+		line string
+		file string
+		err  error
+	}{
+		{line: abcSHA256 + " a", file: "a"},
+		{line: abcSHA256 + "  a (b) = c", file: "a (b) = c"},
+		{line: "SHA256 (a) = b) = " + abcSHA256, file: "a) = b"},
+		{line: "SHA1 (a) = " + abcSHA1, err: ErrFormat},
+		{line: abcSHA1 + "  a", err: ErrFormat},
+		{line: abcSHA256, err: ErrFormat},
+		{line: abcSHA256 + "  ", err: ErrFormat},
+		{line: "\\" + abcSHA256 + "  a\\x", err: ErrFormat},
+		{line: "garbage", err: ErrFormat},
+	} {
+		t.Run(tt.line, func(t *testing.T) {
+			file, _, err := o.ParseLine(tt.line)
+			if !errors.Is(err, tt.err) || file != tt.file {
+				t.Errorf("ParseLine(%q) = %q, %v, want %q, %v", tt.line, file, err, tt.file, tt.err)
+			}
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	d := t.TempDir()
+	var names []string
+	var want strings.Builder
+	for i := 0; i < 20; i++ {
+		name := filepath.Join(d, strings.Repeat("f", i+1))
+		if err := os.WriteFile(name, []byte("abc\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+		want.WriteString(abcSHA256 + "  " + name + "\n")
+	}
+
+	var out, stderr bytes.Buffer
+	o := Options{Algorithm: SHA256, Jobs: 3}
+	if err := o.Sum(&out, &stderr, nil, names); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("Sum = %q, want %q", out.String(), want.String())
+	}
+
+	out.Reset()
+	missing := filepath.Join(d, "missing")
+	err := o.Sum(&out, &stderr, strings.NewReader("abc\n"), []string{missing, "-"})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Sum(missing) = %v, want %v", err, os.ErrNotExist)
+	}
+	if want := abcSHA256 + "  -\n"; out.String() != want {
+		t.Errorf("Sum(missing, -) = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d := t.TempDir()
+	good, bad := filepath.Join(d, "good"), filepath.Join(d, "bad")
+	for _, name := range []string{good, bad} {
+		if err := os.WriteFile(name, []byte("abc\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := strings.Repeat("0", len(abcSHA256))
+	missing := filepath.Join(d, "missing")
+
+	for _, tt := range []struct {
+		name string
+		o    Options
+		list string
+		want string
+		err  error
+	}{
+		{
+			name: "ok",
+			list: abcSHA256 + "  " + good + "\nSHA256 (" + good + ") = " + abcSHA256 + "\n",
+			want: good + ": OK\n" + good + ": OK\n",
+		},
+		{
+			name: "mismatch",
+			list: abcSHA256 + "  " + good + "\n" + other + "  " + bad + "\n",
+			want: good + ": OK\n" + bad + ": FAILED\n",
+			err:  ErrMismatch,
+		},
+		{
+			name: "quiet",
+			o:    Options{Quiet: true},
+			list: abcSHA256 + "  " + good + "\n" + other + "  " + bad + "\n",
+			want: bad + ": FAILED\n",
+			err:  ErrMismatch,
+		},
+		{
+			name: "status",
+			o:    Options{Status: true},
+			list: other + "  " + bad + "\n",
+			err:  ErrMismatch,
+		},
+		{
+			name: "missing",
+			list: abcSHA256 + "  " + missing + "\n",
+			want: missing + ": FAILED open or read\n",
+			err:  ErrUnreadable,
+		},
+		{
+			name: "ignore missing",
+			o:    Options{IgnoreMissing: true},
+			list: abcSHA256 + "  " + missing + "\n" + abcSHA256 + "  " + good + "\n",
+			want: good + ": OK\n",
+		},
+		{
+			name: "improperly formatted",
+			list: "garbage\n" + abcSHA256 + "  " + good + "\n",
+			want: good + ": OK\n",
+		},
+		{
+			name: "strict",
+			o:    Options{Strict: true},
+			list: "garbage\n" + abcSHA256 + "  " + good + "\n",
+			want: good + ": OK\n",
+			err:  ErrFormat,
+		},
+		{
+			name: "no lines",
+			list: "garbage\n",
+			err:  ErrFormat,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.o
+			o.Algorithm = SHA256
+			var out, stderr bytes.Buffer
+			err := o.Check(&out, &stderr, strings.NewReader(tt.list), nil)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("Check = %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Check printed %q, want %q", out.String(), tt.want)
+			}
+			if tt.o.Status && stderr.Len() != 0 {
+				t.Errorf("Check with Status printed %q to stderr", stderr.String())
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	d := t.TempDir()
+	f := filepath.Join(d, "f")
+	if err := os.WriteFile(f, []byte("abc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Run(SHA256, &out, io.Discard, nil, []string{"--tag", f}); err != nil {
+		t.Fatal(err)
+	}
+	list := out.String()
+	out.Reset()
+	if err := Run(SHA256, &out, io.Discard, strings.NewReader(list), []string{"-c"}); err != nil {
+		t.Fatalf("Run(-c) = %v", err)
+	}
+	if want := f + ": OK\n"; out.String() != want {
+		t.Errorf("Run(-c) printed %q, want %q", out.String(), want)
+	}
+	if err := Run(SHA256, io.Discard, io.Discard, nil, []string{"--bogus"}); err == nil {
+		t.Errorf("Run(--bogus) = nil, want an error")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/u-root/u-root/pkg/uroot/unixflag"
+)
+
+// ErrAlgorithm is returned by Shasum for algorithms other than SHA1 and
+// SHA2.
+var ErrAlgorithm = errors.New("invalid algorithm")
+
+// shasumAlgorithms are the algorithms of shasum -a.
+var shasumAlgorithms = map[int]Algorithm{
+	1:   SHA1,
+	224: SHA224,
+	256: SHA256,
+	384: SHA384,
+	512: SHA512,
+}
+
+// Usage returns the usage of the command printing digests of a.
+func Usage(a Algorithm) string {
+	return fmt.Sprintf("%s [-bt] [--tag] [-j JOBS] [FILE...]\n%s -c [--quiet] [--status] [--strict] [-w] [--ignore-missing] [FILE...]", a.Cmd, a.Cmd)
+}
+
+// command holds the flags the commands share.
+type command struct {
+	Options
+	check, text bool
+	f           *flag.FlagSet
+}
+
+func newCommand(name, usage string, stderr io.Writer) *command {
+	c := &command{f: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f := c.f
+	f.SetOutput(stderr)
+	f.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s\n", usage)
+		f.PrintDefaults()
+	}
+	f.BoolVar(&c.check, "c", false, "check files against the digests listed in FILEs")
+	f.BoolVar(&c.check, "check", false, "check files against the digests listed in FILEs")
+	f.BoolVar(&c.Binary, "b", false, "mark files as read in binary mode")
+	f.BoolVar(&c.Binary, "binary", false, "mark files as read in binary mode")
+	f.BoolVar(&c.text, "t", false, "mark files as read in text mode (default)")
+	f.BoolVar(&c.text, "text", false, "mark files as read in text mode (default)")
+	f.BoolVar(&c.Tag, "tag", false, "print BSD-style lines")
+	f.IntVar(&c.Jobs, "j", 0, "hash `jobs` files at once, one per CPU by default")
+	f.IntVar(&c.Jobs, "jobs", 0, "hash `jobs` files at once, one per CPU by default")
+	f.BoolVar(&c.Quiet, "quiet", false, "do not print files which match")
+	f.BoolVar(&c.Status, "status", false, "print nothing; the exit status tells")
+	f.BoolVar(&c.Strict, "strict", false, "fail for improperly formatted lines")
+	f.BoolVar(&c.Warn, "w", false, "warn about improperly formatted lines")
+	f.BoolVar(&c.Warn, "warn", false, "warn about improperly formatted lines")
+	f.BoolVar(&c.IgnoreMissing, "ignore-missing", false, "skip files which do not exist")
+	return c
+}
+
+func (c *command) parse(args []string) error {
+	if err := c.f.Parse(unixflag.ArgsToGoArgs(args)); err != nil {
+		return err
+	}
+	if c.text {
+		c.Binary = false
+	}
+	return nil
+}
+
+func (c *command) run(stdout, stderr io.Writer, stdin io.Reader) error {
+	if c.check {
+		return c.Check(stdout, stderr, stdin, c.f.Args())
+	}
+	return c.Sum(stdout, stderr, stdin, c.f.Args())
+}
+
+// Run runs the command printing digests of a, with Unix-style args.
+func Run(a Algorithm, stdout, stderr io.Writer, stdin io.Reader, args []string) error {
+	c := newCommand(a.Cmd, Usage(a), stderr)
+	c.Algorithm = a
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	return c.run(stdout, stderr, stdin)
+}
+
+// Shasum runs shasum, which prints digests of the SHA algorithm chosen with
+// -a, SHA1 by default, with Unix-style args. Like Run, it reports errors to
+// stderr as well.
+func Shasum(stdout, stderr io.Writer, stdin io.Reader, args []string) error {
+	c := newCommand("shasum", "shasum [-a ALGORITHM] [-bt] [--tag] [-j JOBS] [FILE...]\nshasum [-a ALGORITHM] -c [--quiet] [--status] [--strict] [-w] [--ignore-missing] [FILE...]", stderr)
+	var bits int
+	c.f.IntVar(&bits, "a", 1, "SHA `algorithm`: 1, 224, 256, 384 or 512")
+	c.f.IntVar(&bits, "algorithm", 1, "SHA `algorithm`: 1, 224, 256, 384 or 512")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	a, ok := shasumAlgorithms[bits]
+	if !ok {
+		err := fmt.Errorf("%w %d, only 1, 224, 256, 384 and 512 are valid", ErrAlgorithm, bits)
+		fmt.Fprintf(stderr, "shasum: %v\n", err)
+		return err
+	}
+	a.Cmd = "shasum"
+	c.Algorithm = a
+	return c.run(stdout, stderr, stdin)
+}
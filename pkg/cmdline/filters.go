@@ -4,36 +4,11 @@
 
 package cmdline
 
-import (
-	"fmt"
-	"strings"
-)
-
 // RemoveFilter filters out variable for a given space-separated kernel commandline
 func removeFilter(input string, variables []string) string {
-	var newCl []string
-
-	// kernel variables must allow '-' and '_' to be equivalent in variable
-	// names. We will replace dashes with underscores for processing as
-	// `doParse` is doing.
-	for i, v := range variables {
-		variables[i] = strings.Replace(v, "-", "_", -1)
-	}
-
-	doParse(input, func(flag, key, canonicalKey, value, trimmedValue string) {
-		skip := false
-		for _, v := range variables {
-			if canonicalKey == v {
-				skip = true
-				break
-			}
-		}
-		if skip {
-			return
-		}
-		newCl = append(newCl, flag)
-	})
-	return strings.Join(newCl, " ")
+	ps := ParseParams(input)
+	ps.Remove(variables...)
+	return ps.String()
 }
 
 // Filter represents and kernel commandline filter
@@ -61,16 +36,13 @@ func NewUpdateFilter(appendCmd string, removeVar, reuseVar []string) Filter {
 }
 
 func (u *updater) Update(c *CmdLine, cmdline string) string {
-	acl := ""
-	if len(u.appendCmd) > 0 {
-		acl = " " + u.appendCmd
-	}
+	ps := ParseParams(cmdline)
+	ps.Remove(u.removeVar...)
+	ps.Add(ParseParams(u.appendCmd)...)
 	for _, f := range u.reuseVar {
-		value, present := c.Flag(f)
-		if present {
-			acl = fmt.Sprintf("%s %s=%s", acl, f, value)
+		if value, present := c.Flag(f); present {
+			ps.Add(Param{Key: f, Value: value, HasValue: true})
 		}
 	}
-
-	return removeFilter(cmdline, u.removeVar) + acl
+	return ps.String()
 }
@@ -0,0 +1,184 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"strings"
+)
+
+// initSeparator separates kernel parameters from the arguments of init.
+const initSeparator = "--"
+
+// Param is a single kernel command line parameter.
+type Param struct {
+	// Key is the name of the parameter as written, including the module
+	// prefix of module parameters.
+	Key string
+	// Value is the unquoted value. It is empty for parameters without
+	// value.
+	Value string
+	// HasValue distinguishes "key=" from "key".
+	HasValue bool
+}
+
+// canonical returns name with dashes replaced by underscores, as the kernel
+// treats them the same in parameter names.
+func canonical(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// Is returns whether p is the parameter name, treating '-' and '_' as equal.
+func (p Param) Is(name string) bool {
+	return canonical(p.Key) == canonical(name)
+}
+
+// Module returns the module of a module parameter, e.g. "usbcore" for
+// "usbcore.autosuspend=-1", and "" for other parameters.
+func (p Param) Module() string {
+	if i := strings.Index(p.Key, "."); i > 0 {
+		return p.Key[:i]
+	}
+	return ""
+}
+
+// String returns p as it is written on the kernel command line. Values
+// containing white space are double quoted, unless they already contain
+// quoted parts.
+func (p Param) String() string {
+	if !p.HasValue {
+		return p.Key
+	}
+	if strings.ContainsAny(p.Value, " \t\n") && !strings.Contains(p.Value, `"`) {
+		return p.Key + `="` + p.Value + `"`
+	}
+	return p.Key + "=" + p.Value
+}
+
+// ParseParam parses a single parameter, e.g. `key`, `key=value`,
+// `key="a value"` or `"key=a value"`.
+func ParseParam(s string) Param {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	key, value, ok := strings.Cut(s, "=")
+	if ok && len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return Param{Key: key, Value: value, HasValue: ok}
+}
+
+// Params is a kernel command line as an ordered list of parameters.
+// Arguments of init follow a Param with Key "--".
+type Params []Param
+
+// ParseParams splits a kernel command line into parameters.
+func ParseParams(s string) Params {
+	var ps Params
+	doParse(s, func(flag, _, _, _, _ string) {
+		ps = append(ps, ParseParam(flag))
+	})
+	return ps
+}
+
+// Params returns the parameters of c.
+func (c *CmdLine) Params() Params {
+	return ParseParams(c.Raw)
+}
+
+// kernel returns the parameters before "--".
+func (ps Params) kernel() Params {
+	for i, p := range ps {
+		if p.Key == initSeparator && !p.HasValue {
+			return ps[:i]
+		}
+	}
+	return ps
+}
+
+// Get returns the value of the last parameter name, as the kernel and most
+// programs use the last one, and whether it is set.
+func (ps Params) Get(name string) (string, bool) {
+	all := ps.GetAll(name)
+	if len(all) == 0 {
+		return "", false
+	}
+	return all[len(all)-1], true
+}
+
+// GetAll returns the values of all parameters name in order, e.g. of all
+// console parameters.
+func (ps Params) GetAll(name string) []string {
+	var vals []string
+	for _, p := range ps.kernel() {
+		if p.Is(name) {
+			vals = append(vals, p.Value)
+		}
+	}
+	return vals
+}
+
+// Contains returns whether parameter name is set.
+func (ps Params) Contains(name string) bool {
+	_, ok := ps.Get(name)
+	return ok
+}
+
+// ForModule returns the parameters of module, with dashes and underscores
+// in the module name treated the same.
+func (ps Params) ForModule(module string) Params {
+	var ret Params
+	for _, p := range ps.kernel() {
+		if m := p.Module(); m != "" && canonical(m) == canonical(module) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// Add appends parameters to the kernel parameters, before the arguments of
+// init if there are any. Existing parameters of the same name are kept, as
+// for repeated console parameters.
+func (ps *Params) Add(p ...Param) {
+	n := len(ps.kernel())
+	tail := append(Params{}, (*ps)[n:]...)
+	*ps = append(append((*ps)[:n], p...), tail...)
+}
+
+// Set replaces all parameters name with a single one with value.
+func (ps *Params) Set(name, value string) {
+	ps.Remove(name)
+	ps.Add(Param{Key: name, Value: value, HasValue: true})
+}
+
+// Remove removes all kernel parameters with one of names.
+func (ps *Params) Remove(names ...string) {
+	n := len(ps.kernel())
+	var ret Params
+	for i, p := range *ps {
+		if i < n && p.isAny(names) {
+			continue
+		}
+		ret = append(ret, p)
+	}
+	*ps = ret
+}
+
+func (p Param) isAny(names []string) bool {
+	for _, name := range names {
+		if p.Is(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns ps as a kernel command line.
+func (ps Params) String() string {
+	s := make([]string, 0, len(ps))
+	for _, p := range ps {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, " ")
+}
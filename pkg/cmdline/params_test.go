@@ -0,0 +1,96 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	ps := ParseParams(`ro root=LABEL=/ console=tty0 console=ttyS0,115200 dyndbg="file foo.c +p" "quoted=a b" usb-core.auto_suspend=-1 empty= -- init-arg`)
+	want := Params{
+		{Key: "ro"},
+		{Key: "root", Value: "LABEL=/", HasValue: true},
+		{Key: "console", Value: "tty0", HasValue: true},
+		{Key: "console", Value: "ttyS0,115200", HasValue: true},
+		{Key: "dyndbg", Value: "file foo.c +p", HasValue: true},
+		{Key: "quoted", Value: "a b", HasValue: true},
+		{Key: "usb-core.auto_suspend", Value: "-1", HasValue: true},
+		{Key: "empty", HasValue: true},
+		{Key: "--"},
+		{Key: "init-arg"},
+	}
+	if !reflect.DeepEqual(ps, want) {
+		t.Fatalf("ParseParams() = %+v, want %+v", ps, want)
+	}
+
+	if got, want := ps.String(), `ro root=LABEL=/ console=tty0 console=ttyS0,115200 dyndbg="file foo.c +p" quoted="a b" usb-core.auto_suspend=-1 empty= -- init-arg`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, ok := ps.Get("console"); !ok || got != "ttyS0,115200" {
+		t.Errorf("Get(console) = %q, %v, want ttyS0,115200, true", got, ok)
+	}
+	if got, want := ps.GetAll("console"), []string{"tty0", "ttyS0,115200"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll(console) = %q, want %q", got, want)
+	}
+	if !ps.Contains("usb_core.auto-suspend") {
+		t.Errorf("Contains(usb_core.auto-suspend) = false, want true")
+	}
+	if ps.Contains("init-arg") {
+		t.Errorf("Contains(init-arg) = true, want false")
+	}
+	if got, want := ps.ForModule("usb_core"), ps[6:7]; !reflect.DeepEqual(got, want) {
+		t.Errorf("ForModule(usb_core) = %v, want %v", got, want)
+	}
+}
+
+func TestModifyParams(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		in     string
+		modify func(*Params)
+		want   string
+	}{
+		{
+			name:   "add before init args",
+			in:     "ro -- single",
+			modify: func(ps *Params) { ps.Add(ParseParam("console=ttyS0")) },
+			want:   "ro console=ttyS0 -- single",
+		},
+		{
+			name:   "add repeated",
+			in:     "console=tty0",
+			modify: func(ps *Params) { ps.Add(Param{Key: "console", Value: "ttyS0", HasValue: true}) },
+			want:   "console=tty0 console=ttyS0",
+		},
+		{
+			name:   "set",
+			in:     "root=/dev/sda1 ro root=/dev/sdb1",
+			modify: func(ps *Params) { ps.Set("root", "PARTUUID=1234") },
+			want:   "ro root=PARTUUID=1234",
+		},
+		{
+			name:   "set quoted",
+			in:     "",
+			modify: func(ps *Params) { ps.Set("uroot.initflags", "a=1 b") },
+			want:   `uroot.initflags="a=1 b"`,
+		},
+		{
+			name:   "remove keeps init args",
+			in:     "quiet console=tty0 -- console",
+			modify: func(ps *Params) { ps.Remove("console", "quiet") },
+			want:   "-- console",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := ParseParams(tt.in)
+			tt.modify(&ps)
+			if got := ps.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package complete implements argument completion for interactive shells.
+//
+// Completion functions are registered under the name of the command whose
+// arguments they complete. The shell looks them up when the user asks for
+// completion after a command name, and falls back to completing file names
+// if there is none.
+package complete
+
+import (
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Func returns the candidates for word, the argument being completed.
+// args are the arguments before word, without the command name.
+//
+// Each candidate replaces word entirely. A nil result asks the shell to
+// complete file names instead; an empty non-nil result offers nothing.
+type Func func(args []string, word string) []string
+
+var (
+	mu    sync.RWMutex
+	funcs = map[string]Func{}
+)
+
+// Register registers f to complete the arguments of the command name.
+func Register(name string, f Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	funcs[name] = f
+}
+
+// Lookup returns the completion function of the command cmd, which may be a
+// path, or nil.
+func Lookup(cmd string) Func {
+	mu.RLock()
+	defer mu.RUnlock()
+	return funcs[filepath.Base(cmd)]
+}
+
+// Filter returns the sorted, unique candidates that start with prefix.
+func Filter(prefix string, candidates []string) []string {
+	out := []string{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	for i := 1; i < len(out); i++ {
+		if out[i] == out[i-1] {
+			out = append(out[:i], out[i+1:]...)
+			i--
+		}
+	}
+	return out
+}
+
+// Words returns a Func that completes any argument from words.
+func Words(words ...string) Func {
+	return func(_ []string, word string) []string {
+		return Filter(word, words)
+	}
+}
+
+// None completes nothing, for arguments that cannot be guessed.
+func None([]string, string) []string {
+	return []string{}
+}
+
+// Interfaces completes network interface names.
+func Interfaces(_ []string, word string) []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return []string{}
+	}
+	names := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	return Filter(word, names)
+}
+
+// Command completes a command made of subcommands, like "ip link set".
+type Command struct {
+	// Sub are the subcommands. Like many u-root commands, a subcommand
+	// may be abbreviated as long as the abbreviation is unique.
+	Sub map[string]*Command
+	// Args completes the arguments after the subcommands.
+	Args Func
+}
+
+func (c *Command) sub(name string) *Command {
+	if s, ok := c.Sub[name]; ok {
+		return s
+	}
+	var match *Command
+	for n, s := range c.Sub {
+		if strings.HasPrefix(n, name) {
+			if match != nil {
+				return nil
+			}
+			match = s
+		}
+	}
+	return match
+}
+
+// Complete implements Func.
+func (c *Command) Complete(args []string, word string) []string {
+	for len(args) > 0 {
+		s := c.sub(args[0])
+		if s == nil {
+			break
+		}
+		c, args = s, args[1:]
+	}
+	if len(args) == 0 && len(c.Sub) > 0 {
+		names := make([]string, 0, len(c.Sub))
+		for n := range c.Sub {
+			names = append(names, n)
+		}
+		return Filter(word, names)
+	}
+	if c.Args != nil {
+		return c.Args(args, word)
+	}
+	return nil
+}
+
+// Options completes keyword arguments, such as "dev eth0 mtu 1500". After a
+// keyword, its value is completed by the keyword's Func; keywords mapped to
+// nil take no value.
+type Options map[string]Func
+
+// Complete implements Func.
+func (o Options) Complete(args []string, word string) []string {
+	if n := len(args); n > 0 {
+		if f := o[args[n-1]]; f != nil {
+			return f(args, word)
+		}
+	}
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	return Filter(word, keys)
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package complete
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	got := Filter("b", []string{"bar", "foo", "baz", "bar", "b"})
+	if want := []string{"b", "bar", "baz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+	if got := Filter("x", []string{"a"}); got == nil || len(got) != 0 {
+		t.Errorf("Filter() = %#v, want empty non-nil slice", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("frob", Words("on", "off"))
+	if Lookup("nonexistent") != nil {
+		t.Errorf("Lookup(nonexistent) != nil")
+	}
+	f := Lookup("/bbin/frob")
+	if f == nil {
+		t.Fatalf("Lookup(/bbin/frob) = nil")
+	}
+	if got, want := f(nil, "o"), []string{"off", "on"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("frob completions = %q, want %q", got, want)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := &Command{Sub: map[string]*Command{
+		"link": {Sub: map[string]*Command{
+			"set": {Args: Options{
+				"mtu":    None,
+				"up":     nil,
+				"master": Words("br0", "br1"),
+			}.Complete},
+			"show": {},
+		}},
+		"list": {Args: Words("a", "b")},
+	}}
+	for _, tt := range []struct {
+		args []string
+		word string
+		want []string
+	}{
+		{args: nil, word: "", want: []string{"link", "list"}},
+		{args: nil, word: "lin", want: []string{"link"}},
+		{args: []string{"link"}, word: "", want: []string{"set", "show"}},
+		{args: []string{"lin"}, word: "s", want: []string{"set", "show"}},
+		// "li" is ambiguous.
+		{args: []string{"li"}, word: "", want: nil},
+		{args: []string{"lis"}, word: "", want: []string{"a", "b"}},
+		{args: []string{"link", "set"}, word: "m", want: []string{"master", "mtu"}},
+		{args: []string{"link", "set", "master"}, word: "", want: []string{"br0", "br1"}},
+		{args: []string{"link", "set", "mtu"}, word: "", want: []string{}},
+		{args: []string{"link", "set", "up"}, word: "", want: []string{"master", "mtu", "up"}},
+		{args: []string{"link", "show"}, word: "", want: nil},
+	} {
+		if got := c.Complete(tt.args, tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q, %q) = %#v, want %#v", tt.args, tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package compress implements streaming compressors for the formats the
+// Linux kernel can unpack an initramfs from.
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Supported formats.
+const (
+	None = "none"
+	Gzip = "gzip"
+	XZ   = "xz"
+	Zstd = "zstd"
+	LZ4  = "lz4"
+)
+
+// DefaultLevel selects the default compression level of a format.
+const DefaultLevel = -1
+
+// ErrUnknownFormat is returned for unsupported compression formats.
+var ErrUnknownFormat = errors.New("unknown compression format")
+
+// ErrLevel is returned for compression levels a format does not support.
+var ErrLevel = errors.New("invalid compression level")
+
+// Formats are the supported formats, in order of preference.
+var Formats = []string{Zstd, XZ, Gzip, LZ4}
+
+var extensions = map[string]string{
+	".gz":   Gzip,
+	".xz":   XZ,
+	".zst":  Zstd,
+	".zstd": Zstd,
+	".lz4":  LZ4,
+}
+
+// FormatFromName returns the format implied by the extension of name, or
+// None.
+func FormatFromName(name string) string {
+	if f, ok := extensions[filepath.Ext(name)]; ok {
+		return f
+	}
+	return None
+}
+
+// nopCloser is an io.WriteCloser whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// xzDictCaps are the dictionary sizes of the xz presets 0 to 9.
+var xzDictCaps = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// NewWriter returns a writer that compresses everything written to it in
+// format and writes it to w.
+//
+// Levels range from 1 to 9 for gzip, xz and lz4 and from 1 to 22 for zstd.
+// Closing the returned writer flushes it but does not close w.
+func NewWriter(w io.Writer, format string, level int) (io.WriteCloser, error) {
+	switch format {
+	case None, "":
+		return nopCloser{w}, nil
+
+	case Gzip:
+		if level == DefaultLevel {
+			level = pgzip.DefaultCompression
+		}
+		zw, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrLevel, err)
+		}
+		return zw, nil
+
+	case XZ:
+		c := xz.WriterConfig{
+			// The kernel's xz decoder only supports CRC32, and
+			// smaller dictionaries are easier on early boot memory.
+			CheckSum: xz.CRC32,
+			DictCap:  1 << 20,
+		}
+		if level != DefaultLevel {
+			if level < 0 || level >= len(xzDictCaps) {
+				return nil, fmt.Errorf("%w: xz level %d", ErrLevel, level)
+			}
+			c.DictCap = xzDictCaps[level]
+		}
+		return c.NewWriter(w)
+
+	case Zstd:
+		opts, err := zstdOptions(level)
+		if err != nil {
+			return nil, err
+		}
+		return zstd.NewWriter(w, opts...)
+
+	case LZ4:
+		lw := lz4.NewWriter(w)
+		// The kernel only reads the legacy lz4 format.
+		opts := []lz4.Option{lz4.LegacyOption(true)}
+		if level != DefaultLevel {
+			if level < 0 || level > 9 {
+				return nil, fmt.Errorf("%w: lz4 level %d", ErrLevel, level)
+			}
+			l := lz4.Fast
+			if level > 0 {
+				l = lz4.Level1 << (level - 1)
+			}
+			opts = append(opts, lz4.CompressionLevelOption(l))
+		}
+		if err := lw.Apply(opts...); err != nil {
+			return nil, err
+		}
+		return lw, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func zstdOptions(level int) ([]zstd.EOption, error) {
+	opts := []zstd.EOption{zstd.WithEncoderCRC(true)}
+	if level != DefaultLevel {
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("%w: zstd level %d", ErrLevel, level)
+		}
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	return opts, nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+func decompress(t *testing.T, format string, b []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch format {
+	case None:
+		return b
+	case Gzip:
+		r, err = pgzip.NewReader(bytes.NewReader(b))
+	case XZ:
+		r, err = xz.NewReader(bytes.NewReader(b))
+	case Zstd:
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(b))
+		if err == nil {
+			defer d.Close()
+		}
+		r = d
+	case LZ4:
+		r = lz4.NewReader(bytes.NewReader(b))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompressing %s: %v", format, err)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("u-root initramfs "), 64<<10)
+	for _, tt := range []struct {
+		format string
+		level  int
+		magic  []byte
+	}{
+		{None, DefaultLevel, []byte("u-root")},
+		{Gzip, DefaultLevel, []byte{0x1f, 0x8b}},
+		{Gzip, 9, []byte{0x1f, 0x8b}},
+		{XZ, DefaultLevel, []byte{0xfd, '7', 'z', 'X', 'Z', 0}},
+		{XZ, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0}},
+		{Zstd, DefaultLevel, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		{Zstd, 19, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+		// Legacy lz4 frames, as the kernel expects them.
+		{LZ4, DefaultLevel, []byte{0x02, 0x21, 0x4c, 0x18}},
+		{LZ4, 9, []byte{0x02, 0x21, 0x4c, 0x18}},
+	} {
+		var b bytes.Buffer
+		w, err := NewWriter(&b, tt.format, tt.level)
+		if err != nil {
+			t.Fatalf("NewWriter(%s, %d) = %v", tt.format, tt.level, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b.Bytes(), tt.magic) {
+			t.Errorf("%s output starts with %x, want %x", tt.format, b.Bytes()[:8], tt.magic)
+		}
+		if got := decompress(t, tt.format, b.Bytes()); !bytes.Equal(got, data) {
+			t.Errorf("%s level %d: round trip mismatch", tt.format, tt.level)
+		}
+	}
+}
+
+func TestNewWriterErrors(t *testing.T) {
+	for _, tt := range []struct {
+		format string
+		level  int
+		err    error
+	}{
+		{"bzip2", DefaultLevel, ErrUnknownFormat},
+		{XZ, 10, ErrLevel},
+		{Zstd, 0, ErrLevel},
+		{LZ4, 12, ErrLevel},
+		{Gzip, 12, ErrLevel},
+	} {
+		if _, err := NewWriter(io.Discard, tt.format, tt.level); !errors.Is(err, tt.err) {
+			t.Errorf("NewWriter(%s, %d) = %v, want %v", tt.format, tt.level, err, tt.err)
+		}
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	for name, want := range map[string]string{
+		"/tmp/initramfs.cpio":     None,
+		"/tmp/initramfs.cpio.zst": Zstd,
+		"initramfs.cpio.xz":       XZ,
+		"initramfs.cpio.gz":       Gzip,
+		"initramfs.lz4":           LZ4,
+	} {
+		if got := FormatFromName(name); got != want {
+			t.Errorf("FormatFromName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	data := bytes.Repeat([]byte("u-root tarball "), 4<<10)
+	for _, format := range append([]string{None}, Formats...) {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			w, err := NewWriter(&b, format, DefaultLevel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, got, err := NewReader(&b)
+			if err != nil {
+				t.Fatalf("NewReader() = %v", err)
+			}
+			defer r.Close()
+			if got != format {
+				t.Errorf("NewReader() detected %q, want %q", got, format)
+			}
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading %s: %v", format, err)
+			}
+			if !bytes.Equal(out, data) {
+				t.Errorf("read %d bytes, want the %d bytes written", len(out), len(data))
+			}
+		})
+	}
+
+	// bzip2 is only detected and read. This is "hello\n" compressed.
+	bz := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xc1, 0xc0,
+		0x80, 0xe2, 0x00, 0x00, 0x01, 0x41, 0x00, 0x00, 0x10, 0x02, 0x44, 0xa0,
+		0x00, 0x30, 0xcd, 0x00, 0xc3, 0x46, 0x29, 0x97, 0x17, 0x72, 0x45, 0x38,
+		0x50, 0x90, 0xc1, 0xc0, 0x80, 0xe2,
+	}
+	r, format, err := NewReader(bytes.NewReader(bz))
+	if err != nil || format != Bzip2 {
+		t.Fatalf("NewReader(bzip2) = %q, %v, want %q, nil", format, err, Bzip2)
+	}
+	if out, err := io.ReadAll(r); err != nil || string(out) != "hello\n" {
+		t.Errorf("reading bzip2 = %q, %v, want %q, nil", out, err, "hello\n")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, tt := range []struct {
+		header []byte
+		want   string
+	}{
+		{[]byte{0x1f, 0x8b, 8}, Gzip},
+		{[]byte("BZh91AY"), Bzip2},
+		{[]byte{0xfd, '7', 'z', 'X', 'Z', 0}, XZ},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd}, Zstd},
+		{[]byte{0x02, 0x21, 0x4c, 0x18}, LZ4},
+		{[]byte("070701"), None},
+		{nil, None},
+	} {
+		if got := Detect(tt.header); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParallelWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("u-root parallel "), 20<<10)
+	// A pool shared by all writers, as the u-root builder uses it.
+	pool := NewPool(3)
+	for _, format := range Formats {
+		for _, size := range []int{0, 1, 100 << 10} {
+			t.Run(fmt.Sprintf("%s/%d", format, size), func(t *testing.T) {
+				var b bytes.Buffer
+				w, err := NewParallelWriter(&b, format, DefaultLevel, 64<<10, pool)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Odd-sized writes straddle block boundaries.
+				for in := data[:size]; len(in) > 0; {
+					n := min(len(in), 7000)
+					if _, err := w.Write(in[:n]); err != nil {
+						t.Fatal(err)
+					}
+					in = in[n:]
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write([]byte("x")); err == nil {
+					t.Errorf("Write after Close succeeded")
+				}
+
+				r, got, err := NewReader(&b)
+				if err != nil || got != format {
+					t.Fatalf("NewReader() = %q, %v, want %q, nil", got, err, format)
+				}
+				defer r.Close()
+				out, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("decompressing: %v", err)
+				}
+				if !bytes.Equal(out, data[:size]) {
+					t.Errorf("got %d bytes back, want the %d bytes written", len(out), size)
+				}
+			})
+		}
+	}
+}
+
+func TestParallelWriterErrors(t *testing.T) {
+	if _, err := NewParallelWriter(io.Discard, "rar", DefaultLevel, 0, nil); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format: got %v, want %v", err, ErrUnknownFormat)
+	}
+	if _, err := NewParallelWriter(io.Discard, Zstd, 99, 0, nil); !errors.Is(err, ErrLevel) {
+		t.Errorf("bad level: got %v, want %v", err, ErrLevel)
+	}
+
+	w, err := NewParallelWriter(errWriter{}, Gzip, DefaultLevel, 16, NewPool(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(bytes.Repeat([]byte("x"), 100))
+	if err := w.Close(); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Close() with a failing writer = %v, want %v", err, io.ErrShortWrite)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
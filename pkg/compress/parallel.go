@@ -0,0 +1,232 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var errClosed = errors.New("compress: writer is closed")
+
+// DefaultBlockSize is the amount of input NewParallelWriter compresses as
+// one block.
+const DefaultBlockSize = 4 << 20
+
+// A Pool bounds the number of blocks that are compressed at the same time.
+// Writers may share a Pool, so that compressing several streams at once
+// does not use more CPUs than intended.
+type Pool struct {
+	sem chan struct{}
+}
+
+// NewPool returns a Pool of n workers, or of one worker per CPU if n is not
+// positive.
+func NewPool(n int) *Pool {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	return &Pool{sem: make(chan struct{}, n)}
+}
+
+// Workers returns the number of blocks p compresses at the same time.
+func (p *Pool) Workers() int {
+	return cap(p.sem)
+}
+
+// goFunc runs f on a worker of p, waiting for one to become free.
+func (p *Pool) goFunc(f func()) {
+	p.sem <- struct{}{}
+	go func() {
+		defer func() { <-p.sem }()
+		f()
+	}()
+}
+
+// A block is a compressed piece of the input.
+type block struct {
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+type parallelWriter struct {
+	w         io.Writer
+	pool      *Pool
+	blockSize int
+	compress  func(dst *bytes.Buffer, src []byte) error
+	close     func()
+
+	buf     []byte
+	written bool
+	closed  bool
+	// blocks are written to w in the order they are queued.
+	blocks chan *block
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewParallelWriter returns a writer that compresses everything written to
+// it in format, like NewWriter, but splits the input into blocks of
+// blockSize bytes that the workers of pool compress concurrently.
+//
+// Each block becomes a complete stream of its own. gzip, xz, zstd and lz4
+// decoders, as well as the kernel's initramfs unpacker, read concatenated
+// streams as one, at the cost of a slightly worse compression ratio.
+// Closing the returned writer flushes it but does not close w.
+func NewParallelWriter(w io.Writer, format string, level int, blockSize int, pool *Pool) (io.WriteCloser, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if pool == nil {
+		pool = NewPool(0)
+	}
+	pw := &parallelWriter{
+		w:         w,
+		pool:      pool,
+		blockSize: blockSize,
+		close:     func() {},
+		// Buffering twice as many blocks as there are workers keeps
+		// them busy while the output is written.
+		blocks: make(chan *block, 2*pool.Workers()),
+	}
+
+	switch format {
+	case None, "":
+		return nopCloser{w}, nil
+
+	case Zstd:
+		// One encoder compresses all blocks, with as many goroutines as
+		// the pool has workers.
+		opts, err := zstdOptions(level)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, zstd.WithEncoderConcurrency(pool.Workers()), zstd.WithZeroFrames(true))
+		enc, err := zstd.NewWriter(nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		pw.compress = func(dst *bytes.Buffer, src []byte) error {
+			dst.Write(enc.EncodeAll(src, nil))
+			return nil
+		}
+		pw.close = func() { enc.Close() }
+
+	default:
+		// Reject unknown formats and levels before any data is written.
+		if _, err := NewWriter(io.Discard, format, level); err != nil {
+			return nil, err
+		}
+		pw.compress = func(dst *bytes.Buffer, src []byte) error {
+			zw, err := NewWriter(dst, format, level)
+			if err != nil {
+				return err
+			}
+			if _, err := zw.Write(src); err != nil {
+				zw.Close()
+				return err
+			}
+			return zw.Close()
+		}
+	}
+
+	pw.wg.Add(1)
+	go pw.writeBlocks()
+	return pw, nil
+}
+
+func (pw *parallelWriter) setErr(err error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err == nil {
+		pw.err = err
+	}
+}
+
+func (pw *parallelWriter) getErr() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+// writeBlocks writes the compressed blocks to w in order.
+func (pw *parallelWriter) writeBlocks() {
+	defer pw.wg.Done()
+	for b := range pw.blocks {
+		<-b.done
+		if pw.getErr() != nil {
+			continue
+		}
+		if b.err != nil {
+			pw.setErr(b.err)
+			continue
+		}
+		if _, err := b.out.WriteTo(pw.w); err != nil {
+			pw.setErr(err)
+		}
+	}
+}
+
+// flush queues the buffered input for compression.
+func (pw *parallelWriter) flush() {
+	src := pw.buf
+	pw.buf = nil
+	pw.written = true
+
+	b := &block{done: make(chan struct{})}
+	pw.blocks <- b
+	pw.pool.goFunc(func() {
+		defer close(b.done)
+		b.err = pw.compress(&b.out, src)
+	})
+}
+
+// Write implements io.Writer.
+func (pw *parallelWriter) Write(p []byte) (int, error) {
+	if pw.closed {
+		return 0, errClosed
+	}
+	if err := pw.getErr(); err != nil {
+		return 0, err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if pw.buf == nil {
+			pw.buf = make([]byte, 0, pw.blockSize)
+		}
+		c := min(len(p), pw.blockSize-len(pw.buf))
+		pw.buf = append(pw.buf, p[:c]...)
+		p = p[c:]
+		if len(pw.buf) == pw.blockSize {
+			pw.flush()
+		}
+	}
+	return n, pw.getErr()
+}
+
+// Close implements io.Closer. It compresses the last partial block and
+// waits for all blocks to be written.
+func (pw *parallelWriter) Close() error {
+	if pw.closed {
+		return errClosed
+	}
+	pw.closed = true
+	// Empty input still makes a valid, empty stream.
+	if len(pw.buf) > 0 || !pw.written {
+		pw.flush()
+	}
+	close(pw.blocks)
+	pw.wg.Wait()
+	pw.close()
+	return pw.getErr()
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// Bzip2 can be detected and read, but not written.
+const Bzip2 = "bzip2"
+
+var magics = []struct {
+	format string
+	magic  []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b}},
+	{XZ, []byte{0xfd, '7', 'z', 'X', 'Z', 0}},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{LZ4, []byte{0x04, 0x22, 0x4d, 0x18}},
+	// The legacy format the kernel reads.
+	{LZ4, []byte{0x02, 0x21, 0x4c, 0x18}},
+	{Bzip2, []byte("BZh")},
+}
+
+// Detect returns the format of compressed data starting with header, or
+// None.
+func Detect(header []byte) string {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	return None
+}
+
+type readCloser struct {
+	io.Reader
+	close func()
+}
+
+func (r readCloser) Close() error {
+	r.close()
+	return nil
+}
+
+// NewReader detects the format of the data in r and returns a reader that
+// decompresses it, along with the format. Uncompressed data is returned as
+// is. Closing the returned reader releases its resources but does not close
+// r.
+func NewReader(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	// A short or empty stream is simply not compressed.
+	header, _ := br.Peek(6)
+	format := Detect(header)
+
+	switch format {
+	case Gzip:
+		zr, err := pgzip.NewReader(br)
+		if err != nil {
+			return nil, format, err
+		}
+		return zr, format, nil
+
+	case XZ:
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, format, err
+		}
+		return io.NopCloser(xr), format, nil
+
+	case Zstd:
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, format, err
+		}
+		return readCloser{d, d.Close}, format, nil
+
+	case LZ4:
+		return io.NopCloser(lz4.NewReader(br)), format, nil
+
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(br)), format, nil
+	}
+	return io.NopCloser(br), None, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package console mirrors the I/O of init and its shells across several
+// consoles at once, e.g. a serial port, the VGA console and netconsole, so
+// that both headless servers and crash carts see the boot.
+//
+// Consoles are selected on the kernel command line:
+//
+//	uroot.console=ttyS0,tty0,netconsole
+//
+// Without uroot.console, the devices of all console= parameters are used.
+// A single console can be turned off with uroot.console.<name>=0.
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+// Flag is the kernel command line flag listing the consoles to mirror to.
+const Flag = "uroot.console"
+
+// Netconsole is the name of the output-only console that sends to the
+// kernel log, and on from there to the netconsole module.
+const Netconsole = "netconsole"
+
+// ErrNoConsoles is returned when writing to a Mux without working consoles.
+var ErrNoConsoles = errors.New("no working consoles")
+
+// Names returns the consoles ps selects, in order and without duplicates.
+func Names(ps cmdline.Params) []string {
+	var names []string
+	if v, ok := ps.Get(Flag); ok {
+		names = strings.Split(v, ",")
+	} else {
+		for _, c := range ps.GetAll("console") {
+			// Strip options, e.g. ttyS0,115200n8.
+			name, _, _ := strings.Cut(c, ",")
+			names = append(names, name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var ret []string
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "/dev/")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if v, ok := ps.Get(Flag + "." + name); ok {
+			if on, err := strconv.ParseBool(v); err == nil && !on {
+				continue
+			}
+		}
+		ret = append(ret, name)
+	}
+	return ret
+}
+
+// console is one device of a Mux.
+type console struct {
+	name string
+	w    io.Writer
+	r    io.Reader
+}
+
+// Mux writes to all of its consoles and reads from all of the consoles that
+// have input.
+type Mux struct {
+	mu       sync.Mutex
+	consoles []*console
+	input    *io.PipeReader
+	inputW   *io.PipeWriter
+}
+
+// NewMux returns a Mux without consoles.
+func NewMux() *Mux {
+	r, w := io.Pipe()
+	return &Mux{input: r, inputW: w}
+}
+
+// Add adds a console. r may be nil for output-only consoles. Input read from
+// r is available from m.Read.
+func (m *Mux) Add(name string, w io.Writer, r io.Reader) {
+	c := &console{name: name, w: w, r: r}
+	m.mu.Lock()
+	m.consoles = append(m.consoles, c)
+	m.mu.Unlock()
+	if r == nil {
+		return
+	}
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if _, err := m.inputW.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				log.Printf("console: reading %s: %v", name, err)
+				return
+			}
+		}
+	}()
+}
+
+// Names returns the names of the consoles of m.
+func (m *Mux) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, c := range m.consoles {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Write writes p to every console. A console that fails is removed, so that
+// one unplugged console does not hold up the others. Write only fails if no
+// console is left.
+func (m *Mux) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	var ok []*console
+	var failed []error
+	for _, c := range m.consoles {
+		if _, err := c.w.Write(p); err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		ok = append(ok, c)
+	}
+	m.consoles = ok
+	m.mu.Unlock()
+
+	if len(ok) == 0 {
+		return 0, ErrNoConsoles
+	}
+	// Log without holding the lock, as the log may go to m.
+	for _, err := range failed {
+		log.Printf("console: disabled %v", err)
+	}
+	return len(p), nil
+}
+
+// Read reads input typed on any of the consoles.
+func (m *Mux) Read(p []byte) (int, error) {
+	return m.input.Read(p)
+}
+
+// Close stops reading input.
+func (m *Mux) Close() error {
+	return m.inputW.Close()
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+// devDir and kmsgPath are where Open finds consoles.
+const (
+	devDir   = "/dev"
+	kmsgPath = "/dev/kmsg"
+)
+
+// kmsgWriter writes whole lines to the kernel log, which netconsole sends
+// to its collector.
+type kmsgWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	line []byte
+}
+
+// kmsgLevel is the log level of lines written to the kernel log. It is
+// "notice", the most verbose level init leaves on the console.
+const kmsgLevel = "<5>"
+
+func (k *kmsgWriter) Write(p []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.line = append(k.line, p...)
+	for {
+		i := bytes.IndexByte(k.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(k.line[:i], "\r")
+		if len(line) > 0 {
+			if _, err := k.w.Write(append([]byte(kmsgLevel), line...)); err != nil {
+				return 0, err
+			}
+		}
+		k.line = k.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// Open returns a Mux of the consoles names, e.g. "ttyS0" or Netconsole.
+// TTYs are switched to raw mode, as the pty returned by Attach does line
+// editing and echo for all of them. Consoles that cannot be opened are
+// logged and skipped.
+func Open(names []string) (*Mux, error) {
+	m := NewMux()
+	for _, name := range names {
+		if name == Netconsole {
+			f, err := os.OpenFile(kmsgPath, os.O_WRONLY, 0)
+			if err != nil {
+				log.Printf("console: %s: %v", name, err)
+				continue
+			}
+			m.Add(name, &kmsgWriter{w: f}, nil)
+			continue
+		}
+		tty, err := termios.NewWithDev(filepath.Join(devDir, name))
+		if err != nil {
+			log.Printf("console: %s: %v", name, err)
+			continue
+		}
+		if _, err := tty.Raw(); err != nil {
+			log.Printf("console: %s: %v", name, err)
+		}
+		m.Add(name, tty, tty)
+	}
+	if len(m.Names()) == 0 {
+		return nil, fmt.Errorf("console: opening %q: %w", names, ErrNoConsoles)
+	}
+	return m, nil
+}
+
+// Attach returns the terminal end of a new pty connected to m. Commands
+// using it as their standard I/O and controlling terminal read from and
+// write to all consoles of m.
+func (m *Mux) Attach() (*os.File, error) {
+	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.IoctlSetPointerInt(int(ptm.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		ptm.Close()
+		return nil, fmt.Errorf("unlocking pty: %w", err)
+	}
+	n, err := unix.IoctlGetInt(int(ptm.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		ptm.Close()
+		return nil, fmt.Errorf("getting pty number: %w", err)
+	}
+	pts, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	go io.Copy(m, ptm)
+	go io.Copy(ptm, m)
+	return pts, nil
+}
+
+// FromCmdline opens the consoles selected on the kernel command line. It
+// returns nil if there are none, or only one, as nothing needs mirroring.
+func FromCmdline() (*Mux, error) {
+	names := Names(cmdline.NewCmdLine().Params())
+	if len(names) < 2 {
+		return nil, nil
+	}
+	return Open(names)
+}
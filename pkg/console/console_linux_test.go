@@ -0,0 +1,38 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package console
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// records keeps every write separately, like /dev/kmsg.
+type records [][]byte
+
+func (r *records) Write(p []byte) (int, error) {
+	*r = append(*r, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestKmsgWriter(t *testing.T) {
+	var r records
+	k := &kmsgWriter{w: &r}
+	for _, s := range []string{"# l", "s\r\n", "\r\nbin  etc\r\nusr"} {
+		if _, err := io.WriteString(k, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := records{[]byte("<5># ls"), []byte("<5>bin  etc")}
+	if len(r) != len(want) {
+		t.Fatalf("kmsg got %q, want %q", r, want)
+	}
+	for i := range r {
+		if !bytes.Equal(r[i], want[i]) {
+			t.Errorf("kmsg record %d = %q, want %q", i, r[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package console
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+func TestNames(t *testing.T) {
+	for _, tt := range []struct {
+		cmdline string
+		want    []string
+	}{
+		{cmdline: "console=tty0 console=ttyS0,115200n8", want: []string{"tty0", "ttyS0"}},
+		{cmdline: "console=ttyS0 uroot.console=ttyS0,tty0,netconsole", want: []string{"ttyS0", "tty0", "netconsole"}},
+		{cmdline: "uroot.console=/dev/ttyS0,tty0,ttyS0 uroot.console.tty0=0", want: []string{"ttyS0"}},
+		{cmdline: "console=ttyS1 uroot.console.ttyS1=1", want: []string{"ttyS1"}},
+		{cmdline: "quiet"},
+	} {
+		if got := Names(cmdline.ParseParams(tt.cmdline)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Names(%q) = %q, want %q", tt.cmdline, got, tt.want)
+		}
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestMux(t *testing.T) {
+	m := NewMux()
+	defer m.Close()
+
+	var a, b bytes.Buffer
+	in, typed := io.Pipe()
+	m.Add("a", &a, in)
+	m.Add("b", &b, nil)
+	m.Add("broken", failWriter{}, nil)
+
+	if _, err := io.WriteString(m, "hello\n"); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if a.String() != "hello\n" || b.String() != "hello\n" {
+		t.Errorf("consoles got %q and %q, want hello", a.String(), b.String())
+	}
+	if got, want := m.Names(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+
+	go io.WriteString(typed, "ls\n")
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(m, buf); err != nil || string(buf) != "ls\n" {
+		t.Errorf("Read() = %q, %v, want ls", buf, err)
+	}
+
+	empty := NewMux()
+	if _, err := empty.Write([]byte("x")); !errors.Is(err, ErrNoConsoles) {
+		t.Errorf("Write() without consoles = %v, want %v", err, ErrNoConsoles)
+	}
+}
@@ -7,6 +7,10 @@
 // CopyTree in particular copies entire trees of files.
 //
 // Only directories, symlinks, and regular files are currently supported.
+//
+// Regular files share their data with their copies where the file system
+// supports it, as with cp --reflink=auto, and holes in them stay holes in
+// their copies.
 package cp
 
 import (
@@ -15,10 +19,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/u-root/u-root/pkg/uio"
 )
 
-// ErrSkip can be returned by PreCallback to skip a file.
-var ErrSkip = errors.New("skip")
+var (
+	// ErrSkip can be returned by PreCallback to skip a file.
+	ErrSkip = errors.New("skip")
+
+	// ErrReflink is returned when files cannot share data with their
+	// copies and ReflinkAlways asks them to.
+	ErrReflink = errors.New("cannot reflink")
+)
 
 // Options are configuration options for how copying files should behave.
 type Options struct {
@@ -38,6 +50,53 @@ type Options struct {
 
 	// PostCallback is called on each file after it is copied if specified.
 	PostCallback func(src, dst string)
+
+	// Preserve copies the owner, mode, timestamps and extended attributes
+	// of files along with them, as far as the user may set them.
+	Preserve bool
+
+	// Reflink sets whether copies of regular files share their data.
+	Reflink Reflink
+}
+
+// Reflink sets whether copies of regular files share their data with the
+// originals, so that only the blocks written to later take space.
+type Reflink int
+
+const (
+	// ReflinkAuto shares data where the file system supports it, and
+	// copies it otherwise.
+	ReflinkAuto Reflink = iota
+	// ReflinkNever always copies data.
+	ReflinkNever
+	// ReflinkAlways shares data or fails.
+	ReflinkAlways
+)
+
+var reflinkNames = map[string]Reflink{
+	"auto":   ReflinkAuto,
+	"never":  ReflinkNever,
+	"always": ReflinkAlways,
+}
+
+// ParseReflink returns the Reflink called s, one of auto, never or always,
+// as cp --reflink takes them.
+func ParseReflink(s string) (Reflink, error) {
+	r, ok := reflinkNames[s]
+	if !ok {
+		return 0, fmt.Errorf("%w: reflink %q, want auto, never or always", ErrReflink, s)
+	}
+	return r, nil
+}
+
+// String implements fmt.Stringer.
+func (r Reflink) String() string {
+	for name, v := range reflinkNames {
+		if v == r {
+			return name
+		}
+	}
+	return fmt.Sprintf("Reflink(%d)", int(r))
 }
 
 // Default are the default options. Default follows symlinks.
@@ -57,6 +116,19 @@ func (o Options) stat(path string) (os.FileInfo, error) {
 
 // Copy copies a file at src to dst.
 func (o Options) Copy(src, dst string) error {
+	return o.copy(src, dst, nil)
+}
+
+// copied is a directory copied, whose attributes are preserved once its
+// contents are copied.
+type copied struct {
+	src, dst string
+	fi       os.FileInfo
+}
+
+// copy copies a file at src to dst. If dirs is set, directories are added
+// to it instead of having their attributes preserved.
+func (o Options) copy(src, dst string, dirs *[]copied) error {
 	srcInfo, err := o.stat(src)
 	if err != nil {
 		return err
@@ -69,9 +141,16 @@ func (o Options) Copy(src, dst string) error {
 			return err
 		}
 	}
-	if err := copyFile(src, dst, srcInfo); err != nil {
+	if err := o.copyFile(src, dst, srcInfo); err != nil {
 		return err
 	}
+	if o.Preserve {
+		if srcInfo.IsDir() && dirs != nil {
+			*dirs = append(*dirs, copied{src, dst, srcInfo})
+		} else if err := preserve(dst, srcInfo, src); err != nil {
+			return err
+		}
+	}
 	if o.PostCallback != nil {
 		o.PostCallback(src, dst)
 	}
@@ -80,7 +159,8 @@ func (o Options) Copy(src, dst string) error {
 
 // CopyTree recursively copies all files in the src tree to dst.
 func (o Options) CopyTree(src, dst string) error {
-	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+	var dirs []copied
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -89,8 +169,19 @@ func (o Options) CopyTree(src, dst string) error {
 		if err != nil {
 			return err
 		}
-		return o.Copy(path, filepath.Join(dst, rel))
+		return o.copy(path, filepath.Join(dst, rel), &dirs)
 	})
+	if err != nil {
+		return err
+	}
+	// Copying into directories changes their times, so they are set
+	// last, innermost first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := preserve(dirs[i].dst, dirs[i].fi, dirs[i].src); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Copy src file to dst file using Default's config.
@@ -104,14 +195,14 @@ func CopyTree(src, dst string) error {
 	return Default.CopyTree(src, dst)
 }
 
-func copyFile(src, dst string, srcInfo os.FileInfo) error {
+func (o Options) copyFile(src, dst string, srcInfo os.FileInfo) error {
 	m := srcInfo.Mode()
 	switch {
 	case m.IsDir():
 		return os.MkdirAll(dst, srcInfo.Mode().Perm())
 
 	case m.IsRegular():
-		return copyRegularFile(src, dst, srcInfo)
+		return o.copyRegularFile(src, dst, srcInfo)
 
 	case m&os.ModeSymlink == os.ModeSymlink:
 		// Yeah, this may not make any sense logically. But this is how
@@ -131,7 +222,7 @@ func copyFile(src, dst string, srcInfo os.FileInfo) error {
 	}
 }
 
-func copyRegularFile(src, dst string, srcfi os.FileInfo) error {
+func (o Options) copyRegularFile(src, dst string, srcfi os.FileInfo) error {
 	srcf, err := os.Open(src)
 	if err != nil {
 		return err
@@ -144,6 +235,61 @@ func copyRegularFile(src, dst string, srcfi os.FileInfo) error {
 	}
 	defer dstf.Close()
 
-	_, err = io.Copy(dstf, srcf)
-	return err
+	dstfi, err := dstf.Stat()
+	if err != nil {
+		return err
+	}
+	if dstfi.Mode().IsRegular() && o.Reflink != ReflinkNever {
+		err := reflink(dstf, srcf)
+		if err == nil {
+			return nil
+		}
+		if o.Reflink == ReflinkAlways {
+			return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: fmt.Errorf("%w: %w", ErrReflink, err)}
+		}
+	}
+
+	switch {
+	case dstfi.Mode()&(os.ModeDevice|os.ModeCharDevice) == os.ModeDevice:
+		// Images written to block devices keep many large requests
+		// in flight, to keep fast drives busy.
+		_, err = uio.Copy(dstf, srcf)
+		return err
+	case dstfi.Mode().IsRegular():
+		return copySparse(dstf, srcf, srcfi.Size(), o.Reflink != ReflinkNever)
+	default:
+		_, err = io.Copy(dstf, srcf)
+		return err
+	}
+}
+
+// copySparse copies the data of src, of size bytes, to the empty file dst,
+// skipping holes, which read as zeros in dst too. If share is set, the
+// kernel may share the data of the files.
+func copySparse(dst, src *os.File, size int64, share bool) error {
+	var r io.Reader = src
+	if !share {
+		// io.Copy would use copy_file_range, which may share data.
+		r = struct{ io.Reader }{src}
+	}
+	for off := int64(0); off < size; {
+		start, end, err := nextData(src, off, size)
+		if err != nil {
+			return err
+		}
+		if start >= size {
+			break
+		}
+		if _, err := src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, r, end-start); err != nil {
+			return err
+		}
+		off = end
+	}
+	return dst.Truncate(size)
 }
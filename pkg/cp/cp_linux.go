@@ -0,0 +1,119 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cp
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink makes dst share the data of src.
+func reflink(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
+
+// nextData returns the first range of data of f, of size bytes, at or
+// after off. Without support for finding holes, all of f is data.
+func nextData(f *os.File, off, size int64) (int64, int64, error) {
+	start, err := f.Seek(off, unix.SEEK_DATA)
+	if errors.Is(err, unix.ENXIO) {
+		// Only a hole is left.
+		return size, size, nil
+	}
+	if err != nil {
+		return off, size, nil
+	}
+	end, err := f.Seek(start, unix.SEEK_HOLE)
+	if err != nil {
+		return start, size, nil
+	}
+	return start, min(end, size), nil
+}
+
+// preserve gives dst the owner, mode, extended attributes and timestamps
+// of src, whose information is fi. Owners and attributes the user may not
+// set are skipped.
+func preserve(dst string, fi os.FileInfo, src string) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	// Symlinks have no mode of their own. Changing the owner clears
+	// setuid and setgid, so the mode comes after.
+	if fi.Mode()&os.ModeSymlink == 0 {
+		if err := os.Chmod(dst, fi.Mode()); err != nil {
+			return err
+		}
+	}
+	if err := copyXattrs(dst, src); err != nil {
+		return err
+	}
+	ts := []unix.Timespec{
+		unix.NsecToTimespec(syscall.TimespecToNsec(st.Atim)),
+		unix.NsecToTimespec(syscall.TimespecToNsec(st.Mtim)),
+	}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, dst, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{Op: "utimensat", Path: dst, Err: err}
+	}
+	return nil
+}
+
+// skipXattr reports whether an error setting an extended attribute means it
+// cannot be set here, as on file systems without them or for namespaces
+// only root may set.
+func skipXattr(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EPERM)
+}
+
+// copyXattrs copies the extended attributes of src to dst.
+func copyXattrs(dst, src string) error {
+	names, err := xattr(func(b []byte) (int, error) { return unix.Llistxattr(src, b) })
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "listxattr", Path: src, Err: err}
+	}
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		v, err := xattr(func(b []byte) (int, error) { return unix.Lgetxattr(src, string(name), b) })
+		if err != nil {
+			return &os.PathError{Op: "getxattr", Path: src, Err: err}
+		}
+		if err := unix.Lsetxattr(dst, string(name), v, 0); err != nil && !skipXattr(err) {
+			return &os.PathError{Op: "setxattr", Path: dst, Err: err}
+		}
+	}
+	return nil
+}
+
+// xattr returns the value get reads, sized with a first call.
+func xattr(get func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := get(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		b := make([]byte, n)
+		n, err = get(b)
+		if errors.Is(err, unix.ERANGE) {
+			// It grew in between.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cp
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopySparse(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A hole, data, and a trailing hole.
+	const size = 16 << 20
+	data := bytes.Repeat([]byte("data"), 4096)
+	if _, err := f.WriteAt(data, 8<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, tt := range []struct {
+		name string
+		opt  Options
+	}{
+		{name: "reflink auto", opt: Options{}},
+		{name: "reflink never", opt: Options{Reflink: ReflinkNever}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "dst")
+			if err := tt.opt.Copy(src, dst); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]byte, size)
+			copy(want[8<<20:], data)
+			if !bytes.Equal(got, want) {
+				t.Errorf("dst differs from src")
+			}
+			fi, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Blocks are 512 bytes. Allow for file systems which
+			// allocate more around the data.
+			if blocks := fi.Sys().(*syscall.Stat_t).Blocks * 512; blocks >= size/2 {
+				t.Errorf("dst takes %d bytes, want it sparse", blocks)
+			}
+		})
+	}
+}
+
+func TestReflinkAlways(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("shared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Options{Reflink: ReflinkAlways}.Copy(src, filepath.Join(dir, "dst"))
+	if err != nil && !errors.Is(err, ErrReflink) {
+		t.Errorf("Copy = %v, want nil or %v", err, ErrReflink)
+	}
+	if err != nil {
+		t.Skipf("file system cannot reflink: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "dst")); err != nil || string(got) != "shared" {
+		t.Errorf("dst = %q, %v, want %q", got, err, "shared")
+	}
+}
+
+func TestPreserveXattrs(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(src, "user.u-root", []byte("cp"), 0); err != nil {
+		t.Skipf("file system has no user xattrs: %v", err)
+	}
+	dst := filepath.Join(dir, "dst")
+	if err := (Options{Preserve: true}).Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, err := unix.Getxattr(dst, "user.u-root", b)
+	if err != nil || string(b[:n]) != "cp" {
+		t.Errorf("user.u-root of dst = %q, %v, want %q", b[:n], err, "cp")
+	}
+}
+
+func TestParseReflink(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Reflink
+		err  error
+	}{
+		{in: "auto", want: ReflinkAuto},
+		{in: "never", want: ReflinkNever},
+		{in: "always", want: ReflinkAlways},
+		{in: "yes", err: ErrReflink},
+	} {
+		got, err := ParseReflink(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseReflink(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("%v.String() = %q, want %q", got, got.String(), tt.in)
+		}
+	}
+}
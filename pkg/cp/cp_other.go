@@ -0,0 +1,33 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package cp
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
+
+// nextData returns the rest of f as data, as holes cannot be found here.
+func nextData(f *os.File, off, size int64) (int64, int64, error) {
+	return off, size, nil
+}
+
+// preserve gives dst the mode and modification time of src, whose
+// information is fi.
+func preserve(dst string, fi os.FileInfo, src string) error {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if err := os.Chmod(dst, fi.Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpio
+
+import (
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/upath"
+)
+
+// An Extractor creates local files from a stream of records and restores
+// the hard links between them.
+//
+// In newc archives, all names of a hard-linked file share an inode number,
+// and only one of the records carries the contents. Extractor creates the
+// file for the first record and links every later content-less record with
+// the same inode number to it.
+type Extractor struct {
+	// Root is the directory files are created in.
+	Root string
+
+	// ForcePriv is passed on to CreateFileInRoot.
+	ForcePriv bool
+
+	// links maps inode numbers to the path they were first created at.
+	links map[uint64]string
+}
+
+// NewExtractor returns an Extractor that creates files relative to root.
+func NewExtractor(root string, forcePriv bool) *Extractor {
+	return &Extractor{
+		Root:      root,
+		ForcePriv: forcePriv,
+		links:     make(map[uint64]string),
+	}
+}
+
+// Extract creates a local file for f.
+//
+// Records with an inode number of 0 are never treated as hard links; that is
+// what reproducible archives of some tools use for all files.
+func (e *Extractor) Extract(f Record) error {
+	if f.Ino == 0 || f.Mode&S_IFMT == S_IFDIR {
+		return CreateFileInRoot(f, e.Root, e.ForcePriv)
+	}
+	name, err := upath.SafeFilepathJoin(e.Root, f.Name)
+	if err != nil {
+		// Let CreateFileInRoot warn about and skip the file.
+		return CreateFileInRoot(f, e.Root, e.ForcePriv)
+	}
+	if target, ok := e.links[f.Ino]; ok && (f.FileSize == 0 || f.ReaderAt == nil) {
+		Debug("Hard linking %s to %s", name, target)
+		return os.Link(target, name)
+	}
+	e.links[f.Ino] = name
+	return CreateFileInRoot(f, e.Root, e.ForcePriv)
+}
+
+// ExtractAll creates local files under root for all records in rr.
+func ExtractAll(rr RecordReader, root string, forcePriv bool) error {
+	e := NewExtractor(root, forcePriv)
+	return ForEachRecord(rr, e.Extract)
+}
+
+// sparseBlockSize is the granularity at which copySparse looks for holes.
+const sparseBlockSize = 4096
+
+// copySparse copies r to f. Blocks of zeros are not written, leaving holes
+// in the file if the file system supports them. f must be empty.
+func copySparse(f *os.File, r io.Reader) (int64, error) {
+	buf := make([]byte, 32*sparseBlockSize)
+	var off int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		for b := 0; b < n; b += sparseBlockSize {
+			blk := buf[b:min(b+sparseBlockSize, n)]
+			if isZero(blk) {
+				continue
+			}
+			if _, err := f.WriteAt(blk, off+int64(b)); err != nil {
+				return off, err
+			}
+		}
+		off += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return off, err
+		}
+	}
+	// Trailing holes are only recorded by the file size.
+	return off, f.Truncate(off)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package cpio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestExtractHardLinks(t *testing.T) {
+	tmp := t.TempDir()
+	hello := StaticFile("a", "hello", 0o644)
+	hello.Ino = 7
+	link := Record{Info: Info{Name: "dir/b", Ino: 7, Mode: S_IFREG | 0o644, FileSize: 5}}
+	empty := StaticFile("c", "", 0o644)
+	empty.Ino = 8
+
+	a := ArchiveFromRecords([]Record{hello, Directory("dir", 0o755), link, empty})
+	if err := ExtractAll(a.Reader(), tmp, false); err != nil {
+		t.Fatalf("ExtractAll() = %v", err)
+	}
+
+	sa, err := os.Stat(filepath.Join(tmp, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, err := os.Stat(filepath.Join(tmp, "dir/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(sa, sb) {
+		t.Errorf("a and dir/b are not hard links of the same file")
+	}
+	if c, err := os.ReadFile(filepath.Join(tmp, "dir/b")); err != nil || string(c) != "hello" {
+		t.Errorf("dir/b = %q, %v, want hello", c, err)
+	}
+}
+
+func TestExtractSparse(t *testing.T) {
+	tmp := t.TempDir()
+	content := make([]byte, 64*sparseBlockSize)
+	copy(content[10*sparseBlockSize:], "data")
+	if err := CreateFileInRoot(StaticRecord(content, Info{Name: "sparse", Mode: S_IFREG | 0o644, FileSize: uint64(len(content))}), tmp, false); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(tmp, "sparse")
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("sparse file contents differ")
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// File systems without holes use all blocks; only check that we never
+	// allocate more than the file size.
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Blocks*512 > int64(len(content))+sparseBlockSize {
+		t.Errorf("sparse file uses %d bytes, want at most %d", st.Blocks*512, len(content))
+	}
+}
@@ -145,7 +145,7 @@ func CreateFileInRoot(f Record, rootDir string, forcePriv bool) error {
 			return err
 		}
 		defer nf.Close()
-		if _, err := io.Copy(nf, uio.Reader(f)); err != nil {
+		if _, err := copySparse(nf, uio.Reader(f)); err != nil {
 			return err
 		}
 
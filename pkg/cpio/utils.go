@@ -11,6 +11,7 @@ import (
 	"math"
 	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/u-root/uio/uio"
@@ -307,6 +308,51 @@ func MakeAllReproducible(files []Record) {
 	}
 }
 
+// pathLess orders cpio paths component by component, so that a directory
+// comes right before its contents.
+func pathLess(a, b string) bool {
+	as, bs := strings.Split(Normalize(a), "/"), strings.Split(Normalize(b), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// SortNames sorts paths in the order Sort would sort their records.
+//
+// Sorting the names of files before creating records for them with a
+// Recorder makes inode numbers independent of the order in which the files
+// were found.
+func SortNames(names []string) {
+	sort.SliceStable(names, func(i, j int) bool { return pathLess(names[i], names[j]) })
+}
+
+// sortRecords sorts records by path, with directories before their contents, so
+// that the same set of files always results in the same archive.
+//
+// For hard-linked files, the contents are moved to the first record of each
+// inode, which is where readers such as the Linux kernel expect them.
+func sortRecords(files []Record) {
+	sort.SliceStable(files, func(i, j int) bool { return pathLess(files[i].Name, files[j].Name) })
+
+	first := make(map[uint64]int)
+	for i, f := range files {
+		if f.Ino == 0 || f.Mode&S_IFMT != S_IFREG {
+			continue
+		}
+		j, ok := first[f.Ino]
+		if !ok {
+			first[f.Ino] = i
+			continue
+		}
+		if files[j].ReaderAt == nil && f.ReaderAt != nil {
+			files[j].ReaderAt, files[i].ReaderAt = f.ReaderAt, nil
+		}
+	}
+}
+
 // AllEqual compares all metadata and contents of r and s.
 func AllEqual(r []Record, s []Record) bool {
 	if len(r) != len(s) {
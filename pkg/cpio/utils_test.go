@@ -9,6 +9,7 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -173,3 +174,36 @@ func TestCharDev(t *testing.T) {
 		}
 	}
 }
+
+func TestSortRecords(t *testing.T) {
+	data := StaticFile("z/x", "data", 0o644)
+	data.Ino = 5
+	link := Record{Info: Info{Name: "b", Ino: 5, Mode: S_IFREG | 0o644, FileSize: 4}}
+	files := []Record{
+		data,
+		Directory("z", 0o755),
+		StaticFile("z.txt", "", 0o644),
+		link,
+		Directory("a", 0o755),
+	}
+	sortRecords(files)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	// "z" sorts before "z.txt" and is directly followed by its contents.
+	want := []string{"a", "b", "z", "z/x", "z.txt"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sortRecords() = %v, want %v", names, want)
+	}
+	if files[1].ReaderAt == nil || files[3].ReaderAt != nil {
+		t.Errorf("sortRecords() did not move the hard link contents to the first record")
+	}
+
+	names = []string{"z.txt", "z/x", "b", "z", "a"}
+	SortNames(names)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("SortNames() = %v, want %v", names, want)
+	}
+}
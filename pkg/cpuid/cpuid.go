@@ -0,0 +1,149 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cpuid enumerates and decodes the CPUID leaves of x86 CPUs.
+//
+// Leaves are read through a Reader, on Linux the cpuid device of a CPU, so
+// that any CPU can be queried, not just the one the program runs on.
+package cpuid
+
+import (
+	"fmt"
+)
+
+// Regs are the registers returned by CPUID.
+type Regs struct {
+	EAX uint32 `json:"eax"`
+	EBX uint32 `json:"ebx"`
+	ECX uint32 `json:"ecx"`
+	EDX uint32 `json:"edx"`
+}
+
+// Leaf is the result of CPUID for a leaf and subleaf.
+type Leaf struct {
+	Leaf    uint32 `json:"leaf"`
+	Subleaf uint32 `json:"subleaf"`
+	Regs
+}
+
+// String formats the leaf as a line of a raw dump.
+func (l Leaf) String() string {
+	return fmt.Sprintf("0x%08x 0x%02x: eax=0x%08x ebx=0x%08x ecx=0x%08x edx=0x%08x", l.Leaf, l.Subleaf, l.EAX, l.EBX, l.ECX, l.EDX)
+}
+
+// Leaves are the leaves of a CPU, in the order they were enumerated.
+type Leaves []Leaf
+
+// Get returns the registers of a leaf and subleaf. Missing leaves read as
+// zero, as CPUs return for unsupported leaves.
+func (l Leaves) Get(leaf, subleaf uint32) Regs {
+	for _, lf := range l {
+		if lf.Leaf == leaf && lf.Subleaf == subleaf {
+			return lf.Regs
+		}
+	}
+	return Regs{}
+}
+
+// Has reports whether a leaf was enumerated.
+func (l Leaves) Has(leaf uint32) bool {
+	for _, lf := range l {
+		if lf.Leaf == leaf {
+			return true
+		}
+	}
+	return false
+}
+
+// Reader executes CPUID.
+type Reader interface {
+	CPUID(leaf, subleaf uint32) (Regs, error)
+}
+
+// maxSubleaves bounds the subleaves enumerated for a leaf, in case a CPU
+// never reports the end of a list.
+const maxSubleaves = 64
+
+const (
+	extendedBase   = 0x80000000
+	hypervisorBase = 0x40000000
+)
+
+// bits returns bits lo to hi of v.
+func bits(v uint32, lo, hi uint) uint32 {
+	return (v >> lo) & (1<<(hi-lo+1) - 1)
+}
+
+// subleaves returns whether a leaf has subleaves and, given the registers
+// of subleaf 0 and n, whether subleaf n is the last one.
+func subleaves(leaf uint32) (func(sub0 Regs, n uint32, r Regs) bool, bool) {
+	switch leaf {
+	case 0x4, 0x8000001d:
+		// Deterministic cache parameters, until the null cache type.
+		return func(_ Regs, _ uint32, r Regs) bool { return bits(r.EAX, 0, 4) == 0 }, true
+	case 0x7, 0x14, 0x17, 0x18, 0x1d, 0x20, 0x24:
+		// Subleaf 0 EAX is the last subleaf.
+		return func(sub0 Regs, n uint32, _ Regs) bool { return n >= sub0.EAX }, true
+	case 0xb, 0x1f, 0x80000026:
+		// Extended topology, until the invalid level type.
+		return func(_ Regs, _ uint32, r Regs) bool { return bits(r.ECX, 8, 15) == 0 }, true
+	case 0xd:
+		// XSAVE state components, up to the highest one supported.
+		return func(_ Regs, n uint32, _ Regs) bool { return n >= 63 }, true
+	case 0xf, 0x10:
+		// RDT monitoring and allocation resources.
+		return func(_ Regs, n uint32, _ Regs) bool { return n >= 3 }, true
+	case 0x12:
+		// SGX, then the EPC sections until an invalid one.
+		return func(_ Regs, n uint32, r Regs) bool { return n >= 2 && bits(r.EAX, 0, 3) == 0 }, true
+	}
+	return nil, false
+}
+
+// Enumerate reads all standard, hypervisor and extended leaves and their
+// subleaves. The terminating subleaves of lists are included.
+func Enumerate(r Reader) (Leaves, error) {
+	var l Leaves
+	for _, base := range []uint32{0, hypervisorBase, extendedBase} {
+		top, err := r.CPUID(base, 0)
+		if err != nil {
+			return nil, fmt.Errorf("leaf %#x: %w", base, err)
+		}
+		// Without a hypervisor, the leaves read as those of the
+		// highest standard leaf.
+		if base == hypervisorBase && (top.EAX < hypervisorBase || top.EAX > hypervisorBase+0xff) {
+			continue
+		}
+		if base == extendedBase && top.EAX < extendedBase {
+			continue
+		}
+		for leaf := base; leaf <= top.EAX && leaf-base < 0x100; leaf++ {
+			sub0, err := r.CPUID(leaf, 0)
+			if err != nil {
+				return nil, fmt.Errorf("leaf %#x: %w", leaf, err)
+			}
+			l = append(l, Leaf{Leaf: leaf, Regs: sub0})
+			// A leaf that reads as zero is not supported, and
+			// neither are its subleaves.
+			last, ok := subleaves(leaf)
+			if !ok || sub0 == (Regs{}) || last(sub0, 0, sub0) {
+				continue
+			}
+			for n := uint32(1); n < maxSubleaves; n++ {
+				regs, err := r.CPUID(leaf, n)
+				if err != nil {
+					return nil, fmt.Errorf("leaf %#x subleaf %#x: %w", leaf, n, err)
+				}
+				// Unused XSAVE components read as zero.
+				if leaf != 0xd || regs != (Regs{}) {
+					l = append(l, Leaf{Leaf: leaf, Subleaf: n, Regs: regs})
+				}
+				if last(sub0, n, regs) {
+					break
+				}
+			}
+		}
+	}
+	return l, nil
+}
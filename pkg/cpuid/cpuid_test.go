@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpuid
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+type key struct{ leaf, subleaf uint32 }
+
+// fake is a CPU that returns zeros for leaves it does not have.
+type fake map[key]Regs
+
+func (f fake) CPUID(leaf, subleaf uint32) (Regs, error) {
+	return f[key{leaf, subleaf}], nil
+}
+
+func str(s string) []uint32 {
+	b := make([]byte, (len(s)+15)/16*16)
+	copy(b, s)
+	var r []uint32
+	for i := 0; i < len(b); i += 4 {
+		r = append(r, binary.LittleEndian.Uint32(b[i:]))
+	}
+	return r
+}
+
+// alderLake is a P-core of a hybrid Intel CPU, trimmed to a few leaves.
+func alderLake() fake {
+	vendor := str("GenuineIntel")
+	brand := str("12th Gen Intel(R) Core(TM) i7-1260P")
+	f := fake{
+		{0, 0}: {EAX: 0x1a, EBX: vendor[0], EDX: vendor[1], ECX: vendor[2]},
+		// Family 6, model 0x9a, stepping 3; sse2, ht; sse3, vmx, avx.
+		{1, 0}: {EAX: 0x000906a3, ECX: 1<<0 | 1<<5 | 1<<28, EDX: 1<<26 | 1<<28},
+		// L1d, L1i, L2 and L3, then the end of the list.
+		{4, 0}: {EAX: 0x1<<14 | 1<<5 | 1, EBX: 11<<22 | 63, ECX: 63},
+		{4, 1}: {EAX: 0x1<<14 | 1<<5 | 2, EBX: 7<<22 | 63, ECX: 63},
+		{4, 2}: {EAX: 0x1<<14 | 2<<5 | 3, EBX: 9<<22 | 63, ECX: 2047},
+		{4, 3}: {EAX: 0x1f<<14 | 3<<5 | 3, EBX: 11<<22 | 63, ECX: 24575},
+		// One subleaf; avx2; hybrid.
+		{7, 0}: {EBX: 1 << 5, EDX: 1 << 15},
+		// Two topology levels.
+		{0xb, 0}:          {EAX: 1, EBX: 2, ECX: 1 << 8},
+		{0xb, 1}:          {EAX: 7, EBX: 16, ECX: 2 << 8},
+		{0x1a, 0}:         {EAX: 0x40 << 24},
+		{extendedBase, 0}: {EAX: 0x80000004},
+		{0x80000001, 0}:   {EDX: 1<<29 | 1<<20},
+		{0x80000002, 0}:   {EAX: brand[0], EBX: brand[1], ECX: brand[2], EDX: brand[3]},
+		{0x80000003, 0}:   {EAX: brand[4], EBX: brand[5], ECX: brand[6], EDX: brand[7]},
+		{0x80000004, 0}:   {EAX: brand[8], EBX: brand[9], ECX: brand[10], EDX: brand[11]},
+	}
+	return f
+}
+
+func TestEnumerate(t *testing.T) {
+	l, err := Enumerate(alderLake())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []key
+	for _, lf := range l {
+		if lf.Leaf == 4 || lf.Leaf == 0xb || lf.Leaf == 7 {
+			got = append(got, key{lf.Leaf, lf.Subleaf})
+		}
+	}
+	want := []key{{4, 0}, {4, 1}, {4, 2}, {4, 3}, {4, 4}, {7, 0}, {0xb, 0}, {0xb, 1}, {0xb, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("enumerated subleaves %v, want %v", got, want)
+	}
+	// 0x1b standard and 5 extended leaves; no hypervisor leaves.
+	if n := len(l) - len(got) + 3; n != 0x1b+5 {
+		t.Errorf("enumerated %d leaves, want %d", n, 0x1b+5)
+	}
+	if l.Has(hypervisorBase) {
+		t.Errorf("enumerated hypervisor leaves without a hypervisor")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	l, err := Enumerate(alderLake())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{
+		Vendor:    "GenuineIntel",
+		Brand:     "12th Gen Intel(R) Core(TM) i7-1260P",
+		Signature: Signature{Family: 6, Model: 0x9a, Stepping: 3},
+		CoreType:  "P-core",
+		Features:  []string{"sse2", "ht", "pni", "vmx", "avx", "avx2", "hybrid_cpu", "nx", "lm"},
+		Caches: []Cache{
+			{Level: 1, Type: "Data", Size: 48 << 10, Ways: 12, LineSize: 64, Sets: 64, SharedBy: 2},
+			{Level: 1, Type: "Instruction", Size: 32 << 10, Ways: 8, LineSize: 64, Sets: 64, SharedBy: 2},
+			{Level: 2, Type: "Unified", Size: 1280 << 10, Ways: 10, LineSize: 64, Sets: 2048, SharedBy: 2},
+			{Level: 3, Type: "Unified", Size: 18 << 20, Ways: 12, LineSize: 64, Sets: 24576, SharedBy: 32},
+		},
+	}
+	if got := l.Decode(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode =\n%+v\nwant\n%+v", got, want)
+	}
+	if got, want := l.Decode().Caches[0].String(), "L1d: 48 KiB, 12-way, 64 sets, 64-byte lines, shared by 2 threads"; got != want {
+		t.Errorf("Cache.String = %q, want %q", got, want)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	for _, tt := range []struct {
+		eax  uint32
+		want Signature
+	}{
+		// AMD Zen 3.
+		{eax: 0x00a20f10, want: Signature{Family: 0x19, Model: 0x21, Stepping: 0}},
+		// Intel Pentium 4.
+		{eax: 0x00000f29, want: Signature{Family: 0xf, Model: 2, Stepping: 9}},
+		// Intel Skylake.
+		{eax: 0x000506e3, want: Signature{Family: 6, Model: 0x5e, Stepping: 3}},
+	} {
+		l := Leaves{{Leaf: 1, Regs: Regs{EAX: tt.eax}}}
+		if got := l.Signature(); got != tt.want {
+			t.Errorf("Signature(%#x) = %v, want %v", tt.eax, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpuid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// flags names the feature bits of a register of a leaf, as Linux does in
+// /proc/cpuinfo.
+type flags struct {
+	leaf, subleaf uint32
+	reg           func(Regs) uint32
+	names         map[uint]string
+}
+
+var features = []flags{
+	{leaf: 1, reg: func(r Regs) uint32 { return r.EDX }, names: map[uint]string{
+		0: "fpu", 1: "vme", 2: "de", 3: "pse", 4: "tsc", 5: "msr", 6: "pae", 7: "mce",
+		8: "cx8", 9: "apic", 11: "sep", 12: "mtrr", 13: "pge", 14: "mca", 15: "cmov",
+		16: "pat", 17: "pse36", 18: "pn", 19: "clflush", 21: "dts", 22: "acpi", 23: "mmx",
+		24: "fxsr", 25: "sse", 26: "sse2", 27: "ss", 28: "ht", 29: "tm", 30: "ia64", 31: "pbe",
+	}},
+	{leaf: 1, reg: func(r Regs) uint32 { return r.ECX }, names: map[uint]string{
+		0: "pni", 1: "pclmulqdq", 2: "dtes64", 3: "monitor", 4: "ds_cpl", 5: "vmx", 6: "smx",
+		7: "est", 8: "tm2", 9: "ssse3", 10: "cid", 11: "sdbg", 12: "fma", 13: "cx16",
+		14: "xtpr", 15: "pdcm", 17: "pcid", 18: "dca", 19: "sse4_1", 20: "sse4_2",
+		21: "x2apic", 22: "movbe", 23: "popcnt", 24: "tsc_deadline_timer", 25: "aes",
+		26: "xsave", 27: "osxsave", 28: "avx", 29: "f16c", 30: "rdrand", 31: "hypervisor",
+	}},
+	{leaf: 7, reg: func(r Regs) uint32 { return r.EBX }, names: map[uint]string{
+		0: "fsgsbase", 1: "tsc_adjust", 2: "sgx", 3: "bmi1", 4: "hle", 5: "avx2", 7: "smep",
+		8: "bmi2", 9: "erms", 10: "invpcid", 11: "rtm", 12: "cqm", 14: "mpx", 15: "rdt_a",
+		16: "avx512f", 17: "avx512dq", 18: "rdseed", 19: "adx", 20: "smap", 21: "avx512ifma",
+		23: "clflushopt", 24: "clwb", 25: "intel_pt", 26: "avx512pf", 27: "avx512er",
+		28: "avx512cd", 29: "sha_ni", 30: "avx512bw", 31: "avx512vl",
+	}},
+	{leaf: 7, reg: func(r Regs) uint32 { return r.ECX }, names: map[uint]string{
+		1: "avx512vbmi", 2: "umip", 3: "pku", 4: "ospke", 5: "waitpkg", 6: "avx512_vbmi2",
+		8: "gfni", 9: "vaes", 10: "vpclmulqdq", 11: "avx512_vnni", 12: "avx512_bitalg",
+		13: "tme", 14: "avx512_vpopcntdq", 16: "la57", 22: "rdpid", 23: "bus_lock_detect",
+		25: "cldemote", 27: "movdiri", 28: "movdir64b", 29: "enqcmd", 30: "sgx_lc",
+	}},
+	{leaf: 7, reg: func(r Regs) uint32 { return r.EDX }, names: map[uint]string{
+		2: "avx512_4vnniw", 3: "avx512_4fmaps", 4: "fsrm", 8: "avx512_vp2intersect",
+		10: "md_clear", 14: "serialize", 15: "hybrid_cpu", 16: "tsxldtrk", 18: "pconfig",
+		19: "arch_lbr", 20: "ibt", 22: "amx_bf16", 23: "avx512_fp16", 24: "amx_tile",
+		25: "amx_int8", 26: "spec_ctrl", 27: "intel_stibp", 28: "flush_l1d",
+		29: "arch_capabilities", 30: "core_capabilities", 31: "spec_ctrl_ssbd",
+	}},
+	{leaf: 0x80000001, reg: func(r Regs) uint32 { return r.EDX }, names: map[uint]string{
+		11: "syscall", 20: "nx", 22: "mmxext", 25: "fxsr_opt", 26: "pdpe1gb", 27: "rdtscp",
+		29: "lm", 30: "3dnowext", 31: "3dnow",
+	}},
+	{leaf: 0x80000001, reg: func(r Regs) uint32 { return r.ECX }, names: map[uint]string{
+		0: "lahf_lm", 1: "cmp_legacy", 2: "svm", 3: "extapic", 4: "cr8_legacy", 5: "abm",
+		6: "sse4a", 7: "misalignsse", 8: "3dnowprefetch", 9: "osvw", 10: "ibs", 11: "xop",
+		12: "skinit", 13: "wdt", 15: "lwp", 16: "fma4", 17: "tce", 19: "nodeid_msr",
+		21: "tbm", 22: "topoext", 23: "perfctr_core", 24: "perfctr_nb", 26: "bpext",
+		27: "ptsc", 28: "perfctr_llc", 29: "mwaitx",
+	}},
+}
+
+// Features returns the names of the features the leaves report.
+func (l Leaves) Features() []string {
+	var f []string
+	for _, fl := range features {
+		if !l.Has(fl.leaf) {
+			continue
+		}
+		v := fl.reg(l.Get(fl.leaf, fl.subleaf))
+		for bit := uint(0); bit < 32; bit++ {
+			if name, ok := fl.names[bit]; ok && v&(1<<bit) != 0 {
+				f = append(f, name)
+			}
+		}
+	}
+	return f
+}
+
+// HasFeature reports whether the leaves report a feature, by its name in
+// /proc/cpuinfo.
+func (l Leaves) HasFeature(name string) bool {
+	for _, f := range l.Features() {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Vendor returns the vendor ID, e.g. GenuineIntel.
+func (l Leaves) Vendor() string {
+	r := l.Get(0, 0)
+	return regString(r.EBX, r.EDX, r.ECX)
+}
+
+// Brand returns the processor brand string.
+func (l Leaves) Brand() string {
+	var regs []uint32
+	for leaf := uint32(0x80000002); leaf <= 0x80000004; leaf++ {
+		r := l.Get(leaf, 0)
+		regs = append(regs, r.EAX, r.EBX, r.ECX, r.EDX)
+	}
+	return strings.TrimSpace(regString(regs...))
+}
+
+func regString(regs ...uint32) string {
+	b := make([]byte, 4*len(regs))
+	for i, r := range regs {
+		binary.LittleEndian.PutUint32(b[4*i:], r)
+	}
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// Signature is the family, model and stepping of a CPU.
+type Signature struct {
+	Family   uint32 `json:"family"`
+	Model    uint32 `json:"model"`
+	Stepping uint32 `json:"stepping"`
+}
+
+// String formats the signature like the SDM.
+func (s Signature) String() string {
+	return fmt.Sprintf("family %#x, model %#x, stepping %#x", s.Family, s.Model, s.Stepping)
+}
+
+// Signature returns the signature from leaf 1, with the extended family
+// and model folded in as the SDM describes.
+func (l Leaves) Signature() Signature {
+	eax := l.Get(1, 0).EAX
+	s := Signature{
+		Family:   bits(eax, 8, 11),
+		Model:    bits(eax, 4, 7),
+		Stepping: bits(eax, 0, 3),
+	}
+	if s.Family == 0xf {
+		s.Family += bits(eax, 20, 27)
+	}
+	if s.Family == 0x6 || s.Family >= 0xf {
+		s.Model += bits(eax, 16, 19) << 4
+	}
+	return s
+}
+
+// CoreType is the type of a core of a hybrid CPU.
+type CoreType uint8
+
+// Core types in leaf 0x1a.
+const (
+	CoreTypeNone CoreType = 0
+	CoreTypeAtom CoreType = 0x20
+	CoreTypeCore CoreType = 0x40
+)
+
+// String returns the common name of the core type.
+func (c CoreType) String() string {
+	switch c {
+	case CoreTypeNone:
+		return "none"
+	case CoreTypeAtom:
+		return "E-core"
+	case CoreTypeCore:
+		return "P-core"
+	}
+	return fmt.Sprintf("core type %#x", uint8(c))
+}
+
+// CoreType returns the type of the core the leaves were read on, or
+// CoreTypeNone if the CPU is not hybrid.
+func (l Leaves) CoreType() CoreType {
+	if l.Get(7, 0).EDX&(1<<15) == 0 {
+		return CoreTypeNone
+	}
+	return CoreType(bits(l.Get(0x1a, 0).EAX, 24, 31))
+}
+
+// ReadCoreType reads the type of a core directly, without enumerating all
+// leaves.
+func ReadCoreType(r Reader) (CoreType, error) {
+	var l Leaves
+	for _, leaf := range []uint32{0, 7, 0x1a} {
+		regs, err := r.CPUID(leaf, 0)
+		if err != nil {
+			return CoreTypeNone, err
+		}
+		if leaf > l.Get(0, 0).EAX {
+			break
+		}
+		l = append(l, Leaf{Leaf: leaf, Regs: regs})
+	}
+	return l.CoreType(), nil
+}
+
+// Cache is a cache as described by leaf 4 or 0x8000001d.
+type Cache struct {
+	Level    uint32 `json:"level"`
+	Type     string `json:"type"`
+	Size     uint32 `json:"size"`
+	Ways     uint32 `json:"ways"`
+	LineSize uint32 `json:"line_size"`
+	Sets     uint32 `json:"sets"`
+	// SharedBy is the maximum number of logical processors that share
+	// the cache.
+	SharedBy uint32 `json:"shared_by"`
+}
+
+var cacheTypes = map[uint32]string{1: "Data", 2: "Instruction", 3: "Unified"}
+
+// String describes the cache on one line, e.g. L1d: 48 KiB, 12-way, ...
+func (c Cache) String() string {
+	t := map[string]string{"Data": "d", "Instruction": "i"}[c.Type]
+	return fmt.Sprintf("L%d%s: %d KiB, %d-way, %d sets, %d-byte lines, shared by %d threads", c.Level, t, c.Size/1024, c.Ways, c.Sets, c.LineSize, c.SharedBy)
+}
+
+// Caches returns the cache topology, from leaf 4 on Intel CPUs or leaf
+// 0x8000001d on AMD CPUs.
+func (l Leaves) Caches() []Cache {
+	leaf := uint32(4)
+	if !l.Has(4) || bits(l.Get(4, 0).EAX, 0, 4) == 0 {
+		leaf = 0x8000001d
+	}
+	var caches []Cache
+	for _, lf := range l {
+		if lf.Leaf != leaf {
+			continue
+		}
+		t, ok := cacheTypes[bits(lf.EAX, 0, 4)]
+		if !ok {
+			continue
+		}
+		c := Cache{
+			Level:    bits(lf.EAX, 5, 7),
+			Type:     t,
+			Ways:     bits(lf.EBX, 22, 31) + 1,
+			LineSize: bits(lf.EBX, 0, 11) + 1,
+			Sets:     lf.ECX + 1,
+			SharedBy: bits(lf.EAX, 14, 25) + 1,
+		}
+		c.Size = c.Ways * (bits(lf.EBX, 12, 21) + 1) * c.LineSize * c.Sets
+		caches = append(caches, c)
+	}
+	return caches
+}
+
+// Info is what Decode makes of the leaves of a CPU.
+type Info struct {
+	Vendor    string    `json:"vendor"`
+	Brand     string    `json:"brand"`
+	Signature Signature `json:"signature"`
+	CoreType  string    `json:"core_type,omitempty"`
+	Features  []string  `json:"features"`
+	Caches    []Cache   `json:"caches"`
+}
+
+// Decode decodes the leaves of a CPU.
+func (l Leaves) Decode() Info {
+	i := Info{
+		Vendor:    l.Vendor(),
+		Brand:     l.Brand(),
+		Signature: l.Signature(),
+		Features:  l.Features(),
+		Caches:    l.Caches(),
+	}
+	if c := l.CoreType(); c != CoreTypeNone {
+		i.CoreType = c.String()
+	}
+	return i
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpuid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dev is the cpuid device of a CPU, provided by the Linux cpuid driver.
+type Dev struct {
+	f *os.File
+}
+
+var _ Reader = &Dev{}
+
+// Open opens the cpuid device of a CPU.
+func Open(cpu int) (*Dev, error) {
+	f, err := os.Open(filepath.Join("/dev/cpu", strconv.Itoa(cpu), "cpuid"))
+	if err != nil {
+		return nil, fmt.Errorf("%w (is the cpuid module loaded?)", err)
+	}
+	return &Dev{f: f}, nil
+}
+
+// CPUID implements Reader. The driver takes the leaf and subleaf from the
+// file offset.
+func (d *Dev) CPUID(leaf, subleaf uint32) (Regs, error) {
+	var b [16]byte
+	if _, err := d.f.ReadAt(b[:], int64(subleaf)<<32|int64(leaf)); err != nil {
+		return Regs{}, err
+	}
+	return Regs{
+		EAX: binary.LittleEndian.Uint32(b[0:]),
+		EBX: binary.LittleEndian.Uint32(b[4:]),
+		ECX: binary.LittleEndian.Uint32(b[8:]),
+		EDX: binary.LittleEndian.Uint32(b[12:]),
+	}, nil
+}
+
+// Close closes the device.
+func (d *Dev) Close() error {
+	return d.f.Close()
+}
+
+// sysfsCPU is where the topology of CPUs is read from.
+var sysfsCPU = "/sys/devices/system/cpu"
+
+// Packages returns the online CPUs, grouped by physical package.
+func Packages() (map[int][]int, error) {
+	dirs, err := filepath.Glob(filepath.Join(sysfsCPU, "cpu[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	pkgs := map[int][]int{}
+	for _, d := range dirs {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(d), "cpu"))
+		if err != nil {
+			continue
+		}
+		// Offline CPUs have no topology.
+		b, err := os.ReadFile(filepath.Join(d, "topology", "physical_package_id"))
+		if err != nil {
+			continue
+		}
+		pkg, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("cpu %d: package: %w", cpu, err)
+		}
+		pkgs[pkg] = append(pkgs[pkg], cpu)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no CPUs in %s", sysfsCPU)
+	}
+	for _, cpus := range pkgs {
+		sort.Ints(cpus)
+	}
+	return pkgs, nil
+}
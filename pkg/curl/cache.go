@@ -0,0 +1,285 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDigest is returned when the digest of a fetched file is not the one
+// its URL names.
+var ErrDigest = errors.New("digest mismatch")
+
+// Cache keeps fetched files in a directory, such as on a scratch partition
+// or a tmpfs, so that booting again does not fetch them again.
+//
+// Files are stored by their SHA-256 digest. A URL may name the digest its
+// file must have with a fragment, as in
+// http://boot/vmlinuz#sha256=HEX; the file is then taken from the cache
+// whatever URL it was fetched from, and a fetched file with another digest
+// is refused. Files of URLs without a digest are found by URL. Files are
+// verified against their digest whenever they are taken from the cache.
+//
+// When the files take more than MaxSize bytes, those used least recently
+// are evicted.
+type Cache struct {
+	// Dir is where files are kept.
+	Dir string
+	// MaxSize is how many bytes the files may take, or 0 for no limit.
+	MaxSize int64
+
+	mu sync.Mutex
+}
+
+// NewCache returns a Cache in dir, which is created if needed.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	for _, d := range []string{"blobs", "urls", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{Dir: dir, MaxSize: maxSize}, nil
+}
+
+// Scheme returns a FileScheme which fetches files with s unless they are
+// in c.
+func (c *Cache) Scheme(s FileScheme) FileScheme {
+	return &CachingScheme{Scheme: s, Cache: c}
+}
+
+// Schemes returns schemes with each scheme but "file" fetching through c.
+func (c *Cache) Schemes(schemes Schemes) Schemes {
+	cached := Schemes{}
+	for name, s := range schemes {
+		if name != "file" {
+			s = c.Scheme(s)
+		}
+		cached.Register(name, s)
+	}
+	return cached
+}
+
+// URLDigest returns the SHA-256 digest u names with a "sha256=HEX"
+// fragment, in hex, and u without it.
+func URLDigest(u *url.URL) (string, *url.URL, error) {
+	hx, ok := strings.CutPrefix(u.Fragment, "sha256=")
+	if !ok {
+		return "", u, nil
+	}
+	if b, err := hex.DecodeString(hx); err != nil || len(b) != sha256.Size {
+		return "", nil, fmt.Errorf("%w: bad sha256 %q in %s", ErrDigest, hx, u.Redacted())
+	}
+	stripped := *u
+	stripped.Fragment, stripped.RawFragment = "", ""
+	return strings.ToLower(hx), &stripped, nil
+}
+
+func (c *Cache) blob(digest string) string {
+	return filepath.Join(c.Dir, "blobs", digest)
+}
+
+// urlEntry is the file holding the digest of the file of u.
+func (c *Cache) urlEntry(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	return filepath.Join(c.Dir, "urls", hex.EncodeToString(sum[:]))
+}
+
+// Lookup returns the file of u, opened, or an error satisfying
+// errors.Is(err, fs.ErrNotExist) if it is not in c. A file which fails
+// verification is removed.
+func (c *Cache) Lookup(u *url.URL) (*os.File, error) {
+	digest, u, err := URLDigest(u)
+	if err != nil {
+		return nil, err
+	}
+	if digest == "" {
+		b, err := os.ReadFile(c.urlEntry(u))
+		if err != nil {
+			return nil, err
+		}
+		digest = strings.TrimSpace(string(b))
+	}
+	name := c.blob(digest)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != digest {
+		f.Close()
+		os.Remove(name)
+		return nil, fmt.Errorf("%w: cached %s: %w", ErrDigest, digest, fs.ErrNotExist)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// The modification time orders files for eviction.
+	now := time.Now()
+	os.Chtimes(name, now, now)
+	return f, nil
+}
+
+// Store reads the file of u from r into c and returns it, opened.
+func (c *Cache) Store(u *url.URL, r io.Reader) (*os.File, error) {
+	want, u, err := URLDigest(u)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(c.Dir, "tmp"), "fetch")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return nil, err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if want != "" && digest != want {
+		return nil, fmt.Errorf("%w: %s has sha256 %s, want %s", ErrDigest, u.Redacted(), digest, want)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), c.blob(digest)); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(c.urlEntry(u), []byte(digest+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+	if err := c.evict(digest); err != nil {
+		return nil, err
+	}
+	return os.Open(c.blob(digest))
+}
+
+// evict removes the files used least recently, but not keep, until those
+// left take at most MaxSize bytes, along with URL entries of files no
+// longer there.
+func (c *Cache) evict(keep string) error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ents, err := os.ReadDir(filepath.Join(c.Dir, "blobs"))
+	if err != nil {
+		return err
+	}
+	var infos []fs.FileInfo
+	var total int64
+	for _, e := range ents {
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, fi)
+		total += fi.Size()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	removed := false
+	for _, fi := range infos {
+		if total <= c.MaxSize {
+			break
+		}
+		if fi.Name() == keep {
+			continue
+		}
+		if err := os.Remove(c.blob(fi.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= fi.Size()
+		removed = true
+	}
+	if !removed {
+		return nil
+	}
+
+	urls, err := os.ReadDir(filepath.Join(c.Dir, "urls"))
+	if err != nil {
+		return err
+	}
+	for _, e := range urls {
+		name := filepath.Join(c.Dir, "urls", e.Name())
+		b, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(c.blob(strings.TrimSpace(string(b)))); os.IsNotExist(err) {
+			os.Remove(name)
+		}
+	}
+	return nil
+}
+
+// CachingScheme wraps a FileScheme to fetch files through a Cache.
+type CachingScheme struct {
+	Scheme FileScheme
+	Cache  *Cache
+}
+
+// fetch returns the file of u from the cache, storing it there first if
+// needed.
+func (s *CachingScheme) fetch(ctx context.Context, u *url.URL) (*os.File, error) {
+	f, err := s.Cache.Lookup(u)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	_, stripped, err := URLDigest(u)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.Scheme.FetchWithoutCache(ctx, stripped)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	return s.Cache.Store(u, r)
+}
+
+// Fetch implements FileScheme.Fetch.
+func (s *CachingScheme) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	f, err := s.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache. The file is
+// still kept in the cache; it is not held in memory.
+func (s *CachingScheme) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	f, err := s.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCache(t *testing.T) {
+	m := NewMockScheme("tftp")
+	m.Add("boot", "/vmlinuz", "kernel")
+	m.Add("boot", "/initrd", "initramfs")
+	m.Add("mirror", "/vmlinuz", "kernel")
+	c, err := NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.Schemes(Schemes{"tftp": m, "file": &LocalFileClient{}})
+	if _, ok := s["file"].(*LocalFileClient); !ok {
+		t.Errorf("file scheme is cached")
+	}
+
+	fetch := func(rawURL string) (string, error) {
+		t.Helper()
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := s.FetchWithoutCache(context.Background(), u)
+		if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(r)
+		return string(b), err
+	}
+	kernel := &url.URL{Scheme: "tftp", Host: "boot", Path: "/vmlinuz"}
+	mirror := &url.URL{Scheme: "tftp", Host: "mirror", Path: "/vmlinuz"}
+
+	for i := 0; i < 2; i++ {
+		if got, err := fetch("tftp://boot/vmlinuz"); err != nil || got != "kernel" {
+			t.Fatalf("Fetch() = %q, %v, want kernel", got, err)
+		}
+	}
+	if n := m.NumCalled(kernel); n != 1 {
+		t.Errorf("fetched %s %d times, want once", kernel, n)
+	}
+
+	// A file named by digest is found whatever its URL.
+	if got, err := fetch("tftp://mirror/vmlinuz#sha256=" + digest("kernel")); err != nil || got != "kernel" {
+		t.Errorf("Fetch() by digest = %q, %v, want kernel", got, err)
+	}
+	if n := m.NumCalled(mirror); n != 0 {
+		t.Errorf("fetched %s %d times, want never", mirror, n)
+	}
+	if _, err := fetch("tftp://boot/initrd#sha256=" + digest("other")); !errors.Is(err, ErrDigest) {
+		t.Errorf("Fetch() with the wrong digest = %v, want %v", err, ErrDigest)
+	}
+	if _, err := fetch("tftp://boot/initrd#sha256=abc"); !errors.Is(err, ErrDigest) {
+		t.Errorf("Fetch() with a bad digest = %v, want %v", err, ErrDigest)
+	}
+
+	// A corrupted file is fetched again.
+	if err := os.WriteFile(filepath.Join(c.Dir, "blobs", digest("kernel")), []byte("kernal"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fetch("tftp://boot/vmlinuz"); err != nil || got != "kernel" {
+		t.Errorf("Fetch() of a corrupted file = %q, %v, want kernel", got, err)
+	}
+	if n := m.NumCalled(kernel); n != 2 {
+		t.Errorf("fetched %s %d times, want twice", kernel, n)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	m := NewMockScheme("http")
+	for _, f := range []string{"a", "b", "c"} {
+		m.Add("boot", "/"+f, f+f+f+f)
+	}
+	c, err := NewCache(t.TempDir(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.Scheme(m)
+	past := time.Now().Add(-time.Hour)
+	for i, f := range []string{"a", "b", "c"} {
+		if _, err := s.Fetch(context.Background(), &url.URL{Scheme: "http", Host: "boot", Path: "/" + f}); err != nil {
+			t.Fatal(err)
+		}
+		// Order the files by use, as they would be over time.
+		stamp := past.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(filepath.Join(c.Dir, "blobs", digest(f+f+f+f)), stamp, stamp)
+	}
+	for f, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		_, err := c.Lookup(&url.URL{Scheme: "http", Host: "boot", Path: "/" + f})
+		if (err == nil) != want {
+			t.Errorf("Lookup(%s) = %v, want cached %t", f, err, want)
+		}
+	}
+	ents, err := os.ReadDir(filepath.Join(c.Dir, "urls"))
+	if err != nil || len(ents) != 2 {
+		t.Errorf("%d URL entries, %v, want 2", len(ents), err)
+	}
+}
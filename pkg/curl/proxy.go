@@ -0,0 +1,253 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+// ErrProxy is returned for proxies that cannot be used.
+var ErrProxy = errors.New("bad proxy")
+
+// ProxyConfig names the proxies HTTP requests go through.
+//
+// Proxies are given as URLs with an http, https, socks5 or socks5h scheme,
+// or as HOST:PORT of an HTTP proxy.
+type ProxyConfig struct {
+	// HTTPProxy is the proxy for http URLs.
+	HTTPProxy string
+	// HTTPSProxy is the proxy for https URLs.
+	HTTPSProxy string
+	// NoProxy is a comma-separated list of hosts reached directly: host
+	// names, which match their subdomains too, domains with a leading
+	// ".", which only match subdomains, IP addresses, CIDR blocks, any
+	// of those with a ":PORT", or "*" for all hosts.
+	NoProxy string
+}
+
+// proxyVars are the environment variables and kernel command line flags of
+// each field of ProxyConfig. ALL_PROXY is the proxy for both schemes.
+var proxyVars = []struct {
+	env, flag string
+	field     func(*ProxyConfig) *string
+}{
+	{"HTTP_PROXY", "uroot.http_proxy", func(c *ProxyConfig) *string { return &c.HTTPProxy }},
+	{"HTTPS_PROXY", "uroot.https_proxy", func(c *ProxyConfig) *string { return &c.HTTPSProxy }},
+	{"NO_PROXY", "uroot.no_proxy", func(c *ProxyConfig) *string { return &c.NoProxy }},
+}
+
+// proxyConfig returns the ProxyConfig of the variables lookup finds under
+// name(env, flag).
+func proxyConfig(lookup func(string) (string, bool), name func(env, flag string) []string) *ProxyConfig {
+	get := func(env, flag string) (string, bool) {
+		for _, n := range name(env, flag) {
+			if v, ok := lookup(n); ok && v != "" {
+				return v, true
+			}
+		}
+		return "", false
+	}
+	c := &ProxyConfig{}
+	all, _ := get("ALL_PROXY", "uroot.all_proxy")
+	for _, v := range proxyVars {
+		s, ok := get(v.env, v.flag)
+		if !ok && v.env != "NO_PROXY" {
+			s = all
+		}
+		*v.field(c) = s
+	}
+	return c
+}
+
+func envNames(env, _ string) []string {
+	return []string{env, strings.ToLower(env)}
+}
+
+func flagNames(_, flag string) []string {
+	return []string{flag}
+}
+
+// ProxyFromEnvironmentVars returns the proxies named by the HTTP_PROXY,
+// HTTPS_PROXY, ALL_PROXY and NO_PROXY environment variables, or their
+// lowercase versions, which lookup returns.
+func ProxyFromEnvironmentVars(lookup func(string) (string, bool)) *ProxyConfig {
+	return proxyConfig(lookup, envNames)
+}
+
+// ProxyFromCmdline returns the proxies named by the uroot.http_proxy,
+// uroot.https_proxy, uroot.all_proxy and uroot.no_proxy flags of c.
+func ProxyFromCmdline(c *cmdline.CmdLine) *ProxyConfig {
+	return proxyConfig(c.Flag, flagNames)
+}
+
+// Merge returns c with the fields it does not set taken from o.
+func (c *ProxyConfig) Merge(o *ProxyConfig) *ProxyConfig {
+	m := *c
+	for _, v := range proxyVars {
+		if *v.field(&m) == "" {
+			*v.field(&m) = *v.field(o)
+		}
+	}
+	return &m
+}
+
+// parseProxy parses the proxy s, which defaults to the http scheme.
+func parseProxy(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%w %q: unsupported scheme %q", ErrProxy, s, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w %q: no host", ErrProxy, s)
+	}
+	return u, nil
+}
+
+// Check returns an error if a proxy of c cannot be used.
+func (c *ProxyConfig) Check() error {
+	_, err := c.ProxyFunc()
+	return err
+}
+
+// ProxyFunc returns a function for http.Transport.Proxy which picks the
+// proxy of a request's URL. Like http.ProxyFromEnvironment, it does not
+// proxy requests to localhost and loopback addresses.
+func (c *ProxyConfig) ProxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, err := parseProxy(c.HTTPProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parseProxy(c.HTTPSProxy)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := strings.Split(c.NoProxy, ",")
+	return func(req *http.Request) (*url.URL, error) {
+		var proxy *url.URL
+		switch req.URL.Scheme {
+		case "http":
+			proxy = httpProxy
+		case "https":
+			proxy = httpsProxy
+		}
+		if proxy == nil || !useProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// useProxy reports whether requests to u go through a proxy, given the
+// NoProxy entries.
+func useProxy(u *url.URL, noProxy []string) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+	for _, e := range noProxy {
+		e = strings.ToLower(strings.TrimSpace(e))
+		switch {
+		case e == "":
+			continue
+		case e == "*":
+			return false
+		}
+		if _, n, err := net.ParseCIDR(e); err == nil {
+			if ip != nil && n.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(e); err == nil {
+			if p != port {
+				continue
+			}
+			e = h
+		}
+		e = strings.Trim(e, "[]")
+		if eip := net.ParseIP(e); eip != nil {
+			if ip != nil && eip.Equal(ip) {
+				return false
+			}
+			continue
+		}
+		if strings.HasPrefix(e, ".") {
+			if strings.HasSuffix(host, e) {
+				return false
+			}
+			continue
+		}
+		if host == e || strings.HasSuffix(host, "."+e) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	defaultProxyOnce sync.Once
+	defaultProxy     func(*http.Request) (*url.URL, error)
+	defaultProxyErr  error
+)
+
+// DefaultProxy returns the proxies named by the environment variables of
+// ProxyFromEnvironmentVars and, for those not set there, by the kernel
+// command line flags of ProxyFromCmdline.
+func DefaultProxy() *ProxyConfig {
+	return ProxyFromEnvironmentVars(os.LookupEnv).Merge(ProxyFromCmdline(cmdline.NewCmdLine()))
+}
+
+// ProxyFromEnvironment returns the proxy of req from DefaultProxy, which is
+// read on first use. Like http.ProxyFromEnvironment it is meant for
+// http.Transport.Proxy, but it also honors ALL_PROXY and the kernel command
+// line.
+func ProxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	defaultProxyOnce.Do(func() {
+		defaultProxy, defaultProxyErr = DefaultProxy().ProxyFunc()
+	})
+	if defaultProxyErr != nil {
+		return nil, defaultProxyErr
+	}
+	return defaultProxy(req)
+}
+
+// WithProxyConfig makes requests go through the proxies of c.
+func WithProxyConfig(c *ProxyConfig) HTTPOption {
+	return func(t *http.Transport) {
+		f, err := c.ProxyFunc()
+		if err != nil {
+			t.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+			return
+		}
+		t.Proxy = f
+	}
+}
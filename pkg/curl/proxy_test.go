@@ -0,0 +1,112 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+func TestProxyConfig(t *testing.T) {
+	env := map[string]string{
+		"http_proxy": "proxy:3128",
+		"ALL_PROXY":  "socks5://socks:1080",
+		"no_proxy":   "example.com",
+	}
+	got := ProxyFromEnvironmentVars(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	})
+	want := &ProxyConfig{HTTPProxy: "proxy:3128", HTTPSProxy: "socks5://socks:1080", NoProxy: "example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProxyFromEnvironmentVars() = %+v, want %+v", got, want)
+	}
+
+	c := ProxyFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{
+		"uroot.https_proxy": "https://secure:443",
+		"uroot.no_proxy":    "10.0.0.0/8",
+	}})
+	if want := (&ProxyConfig{HTTPSProxy: "https://secure:443", NoProxy: "10.0.0.0/8"}); !reflect.DeepEqual(c, want) {
+		t.Errorf("ProxyFromCmdline() = %+v, want %+v", c, want)
+	}
+	m := (&ProxyConfig{HTTPProxy: "env:1"}).Merge(c)
+	if want := (&ProxyConfig{HTTPProxy: "env:1", HTTPSProxy: "https://secure:443", NoProxy: "10.0.0.0/8"}); !reflect.DeepEqual(m, want) {
+		t.Errorf("Merge() = %+v, want %+v", m, want)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	c := &ProxyConfig{
+		HTTPProxy:  "proxy:3128",
+		HTTPSProxy: "socks5h://socks:1080",
+		NoProxy:    "example.com, .internal,10.0.0.0/8, 192.168.1.1,ports.org:8080,[::1]:80",
+	}
+	f, err := c.ProxyFunc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		url  string
+		want string
+	}{
+		{"http://boot.lab/vmlinuz", "http://proxy:3128"},
+		{"https://boot.lab/vmlinuz", "socks5h://socks:1080"},
+		{"tftp://boot.lab/vmlinuz", ""},
+		{"http://example.com/", ""},
+		{"http://www.example.com/", ""},
+		{"http://notexample.com/", "http://proxy:3128"},
+		{"http://internal/", "http://proxy:3128"},
+		{"http://a.internal/", ""},
+		{"http://10.1.2.3/", ""},
+		{"http://192.168.1.1/", ""},
+		{"http://192.168.1.2/", "http://proxy:3128"},
+		{"http://ports.org:8080/", ""},
+		{"http://ports.org/", "http://proxy:3128"},
+		{"http://localhost:8080/", ""},
+		{"http://127.0.0.1/", ""},
+	} {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := f(&http.Request{URL: u})
+		if err != nil {
+			t.Fatalf("proxy of %s = %v", tt.url, err)
+		}
+		var got string
+		if p != nil {
+			got = p.String()
+		}
+		if got != tt.want {
+			t.Errorf("proxy of %s = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	if _, err := (&ProxyConfig{NoProxy: "*"}).ProxyFunc(); err != nil {
+		t.Errorf("ProxyFunc() = %v", err)
+	}
+	for _, p := range []string{"ftp://proxy:21", "http://", "http://%zz"} {
+		if err := (&ProxyConfig{HTTPProxy: p}).Check(); !errors.Is(err, ErrProxy) {
+			t.Errorf("Check(%q) = %v, want %v", p, err, ErrProxy)
+		}
+	}
+}
+
+func TestWithProxyConfig(t *testing.T) {
+	u, err := url.Parse("http://boot.example.com/vmlinuz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewHTTPClientWithOptions(WithProxyConfig(&ProxyConfig{HTTPProxy: "gopher://proxy"})).FetchWithoutCache(context.Background(), u)
+	if !errors.Is(err, ErrProxy) {
+		t.Errorf("Fetch() through a bad proxy = %v, want %v", err, ErrProxy)
+	}
+}
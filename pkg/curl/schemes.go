@@ -4,11 +4,15 @@
 
 // Package curl implements routines to fetch files given a URL.
 //
-// curl currently supports HTTP, TFTP, and local files.
+// curl currently supports HTTP(S), TFTP, and local files. HTTP(S) requests
+// go through the HTTP or SOCKS5 proxies named by the environment or the
+// kernel command line; see ProxyFromEnvironment.
 package curl
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -68,11 +72,12 @@ type FileScheme interface {
 }
 
 var (
-	// DefaultHTTPClient is the default HTTP FileScheme.
+	// DefaultHTTPClient is the default HTTP FileScheme. Its requests go
+	// through the proxies of ProxyFromEnvironment.
 	//
 	// It is not recommended to use this for HTTPS. We recommend creating an
 	// http.Client that accepts only a private pool of certificates.
-	DefaultHTTPClient = NewHTTPClient(http.DefaultClient)
+	DefaultHTTPClient = NewHTTPClientWithOptions()
 
 	// DefaultTFTPClient is the default TFTP FileScheme.
 	DefaultTFTPClient = NewTFTPClient(tftp.ClientMode(tftp.ModeOctet), tftp.ClientBlocksize(1450), tftp.ClientWindowsize(64))
@@ -402,6 +407,11 @@ func (h *HTTPClientCodeError) Unwrap() error {
 // HTTPClient implements FileScheme for HTTP files.
 type HTTPClient struct {
 	c *http.Client
+
+	// segmentSize and parallel configure segmented downloads, if
+	// segmentSize is not 0.
+	segmentSize int64
+	parallel    int
 }
 
 // NewHTTPClient returns a new HTTP FileScheme based on the given http.Client.
@@ -411,6 +421,80 @@ func NewHTTPClient(c *http.Client) *HTTPClient {
 	}
 }
 
+// ErrInsecureRedirect is returned when an HTTPS server redirects to a plain
+// HTTP URL.
+var ErrInsecureRedirect = errors.New("redirect from https to http")
+
+// maxRedirects is how many redirects are followed, like Go's default.
+const maxRedirects = 10
+
+// checkRedirect follows up to maxRedirects redirects, but none from HTTPS to
+// plain HTTP: a boot file fetched over HTTPS must not be substituted by one
+// anybody on the network can tamper with.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: %s", ErrInsecureRedirect, req.URL)
+	}
+	return nil
+}
+
+// HTTPOption configures the transport of HTTP FileSchemes.
+type HTTPOption func(*http.Transport)
+
+// WithProxy makes requests go through the HTTP or SOCKS5 proxy at proxy,
+// instead of those of ProxyFromEnvironment.
+func WithProxy(proxy *url.URL) HTTPOption {
+	return func(t *http.Transport) {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+}
+
+// NewHTTPClientWithOptions returns a new HTTP FileScheme using a copy of
+// http.DefaultTransport modified by opts, which refuses redirects from
+// HTTPS to plain HTTP. Unless opts say otherwise, requests go through the
+// proxies of ProxyFromEnvironment.
+func NewHTTPClientWithOptions(opts ...HTTPOption) *HTTPClient {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = ProxyFromEnvironment
+	for _, opt := range opts {
+		opt(t)
+	}
+	return NewHTTPClient(&http.Client{Transport: t, CheckRedirect: checkRedirect})
+}
+
+// NewHTTPSClient returns a new HTTP FileScheme that only trusts server
+// certificates signed by one of the given roots.
+//
+// Use it to register an "https" scheme for netbooting against a private
+// PKI rather than the system root store.
+func NewHTTPSClient(roots *x509.CertPool, opts ...HTTPOption) *HTTPClient {
+	return NewHTTPClientWithOptions(append([]HTTPOption{func(t *http.Transport) {
+		t.TLSClientConfig = &tls.Config{
+			RootCAs:    roots,
+			MinVersion: tls.VersionTLS12,
+		}
+	}}, opts...)...)
+}
+
+// LoadCertPool returns a certificate pool containing all PEM-encoded
+// certificates found in the given files.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %q", f)
+		}
+	}
+	return pool, nil
+}
+
 func httpFetch(ctx context.Context, c *http.Client, u *url.URL) (io.Reader, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
@@ -429,7 +513,7 @@ func httpFetch(ctx context.Context, c *http.Client, u *url.URL) (io.Reader, erro
 
 // Fetch implements FileScheme.Fetch for HTTP.
 func (h HTTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
-	r, err := httpFetch(ctx, h.c, u)
+	r, err := h.FetchWithoutCache(ctx, u)
 	if err != nil {
 		return nil, err
 	}
@@ -438,6 +522,9 @@ func (h HTTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error)
 
 // FetchWithoutCache implements FileScheme.FetchWithoutCache for HTTP.
 func (h HTTPClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	if h.segmentSize > 0 {
+		return h.segmentedFetch(ctx, u)
+	}
 	return httpFetch(ctx, h.c, u)
 }
 
@@ -6,12 +6,17 @@ package curl
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cenkalti/backoff/v4"
@@ -354,3 +359,118 @@ func TestHttpFetches(t *testing.T) {
 		t.Errorf("got %s, want %s", got, c)
 	}
 }
+
+func TestHTTPSFetch(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello over tls")
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test server's certificate is not trusted by an empty pool.
+	if _, err := NewHTTPSClient(x509.NewCertPool()).FetchWithoutCache(context.Background(), u); err == nil {
+		t.Errorf("Fetch with empty root store succeeded, want error")
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	f := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(f, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := LoadCertPool(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewHTTPSClient(roots).Fetch(context.Background(), u)
+	if err != nil {
+		t.Fatalf("Fetch() = %v", err)
+	}
+	b, err := uio.ReadAll(r)
+	if err != nil || string(b) != "hello over tls" {
+		t.Errorf("Fetch() content = %q, %v, want %q", b, err, "hello over tls")
+	}
+
+	if _, err := LoadCertPool(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Errorf("LoadCertPool(missing) succeeded, want error")
+	}
+}
+
+func TestHTTPRedirects(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "plain")
+	}))
+	defer plain.Close()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/kernel", http.StatusFound)
+		case "/downgrade":
+			http.Redirect(w, r, plain.URL+"/kernel", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			fmt.Fprint(w, "kernel")
+		}
+	}))
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	c := NewHTTPSClient(roots)
+	for _, tt := range []struct {
+		path string
+		want string
+		err  error
+	}{
+		{path: "/redirect", want: "kernel"},
+		{path: "/downgrade", err: ErrInsecureRedirect},
+		{path: "/loop", err: errors.New("stopped after 10 redirects")},
+	} {
+		u, err := url.Parse(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.FetchWithoutCache(context.Background(), u)
+		if tt.err != nil {
+			if err == nil || !errors.Is(err, tt.err) && !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Errorf("Fetch(%s) = %v, want %v", tt.path, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Fetch(%s) = %v", tt.path, err)
+		}
+		if b, _ := io.ReadAll(r); string(b) != tt.want {
+			t.Errorf("Fetch(%s) = %q, want %q", tt.path, b, tt.want)
+		}
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	pu, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("http://boot.example.com/vmlinuz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewHTTPClientWithOptions(WithProxy(pu)).FetchWithoutCache(context.Background(), u)
+	if err != nil {
+		t.Fatalf("Fetch() = %v", err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != "via proxy" || proxied != u.String() {
+		t.Errorf("Fetch() = %q for %q, want %q for %q", b, proxied, "via proxy", u)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrSegment is returned when a server answers a range request of a
+// segmented download with something other than the range asked for, as
+// when the file changed while it was fetched.
+var ErrSegment = errors.New("bad segment")
+
+// DefaultSegmentSize is a segment size fit for fetching kernels and OS
+// images.
+const DefaultSegmentSize = 8 << 20
+
+// Segmented returns a copy of h which fetches files in segments of size
+// bytes, with up to parallel range requests at once, and reassembles them
+// in order. At most parallel segments are held in memory.
+//
+// Files are fetched with a single request as before if the server does not
+// support ranges, or if they fit in one segment.
+func (h HTTPClient) Segmented(size int64, parallel int) *HTTPClient {
+	if size <= 0 {
+		size = DefaultSegmentSize
+	}
+	h.segmentSize, h.parallel = size, max(parallel, 1)
+	return &h
+}
+
+// contentRange parses a Content-Range header, "bytes FIRST-LAST/SIZE".
+func contentRange(s string) (first, last, size int64, err error) {
+	r, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("%w: Content-Range %q", ErrSegment, s)
+	}
+	span, total, ok := strings.Cut(r, "/")
+	f, l, ok2 := strings.Cut(span, "-")
+	if !ok || !ok2 {
+		return 0, 0, 0, fmt.Errorf("%w: Content-Range %q", ErrSegment, s)
+	}
+	if first, err = strconv.ParseInt(f, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("%w: Content-Range %q", ErrSegment, s)
+	}
+	if last, err = strconv.ParseInt(l, 10, 64); err != nil || last < first {
+		return 0, 0, 0, fmt.Errorf("%w: Content-Range %q", ErrSegment, s)
+	}
+	// The size may be unknown, "*".
+	size = -1
+	if total != "*" {
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil || size <= last {
+			return 0, 0, 0, fmt.Errorf("%w: Content-Range %q", ErrSegment, s)
+		}
+	}
+	return first, last, size, nil
+}
+
+// rangeRequest asks for bytes first to last of u, if the file still matches
+// the validator, an ETag or Last-Modified date.
+func rangeRequest(ctx context.Context, c *http.Client, u *url.URL, first, last int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+	return c.Do(req)
+}
+
+// validator returns what later range requests check the file of resp has
+// not changed with.
+func validator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// segmentedFetch fetches u in segments, or with one request if the server
+// does not support ranges.
+func (h HTTPClient) segmentedFetch(ctx context.Context, u *url.URL) (io.Reader, error) {
+	resp, err := rangeRequest(ctx, h.c, u, 0, h.segmentSize-1, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range.
+		return resp.Body, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file is empty.
+		resp.Body.Close()
+		return httpFetch(ctx, h.c, u)
+	case http.StatusPartialContent:
+	default:
+		resp.Body.Close()
+		return nil, &HTTPClientCodeError{ErrStatusNotOk, resp.StatusCode}
+	}
+	first, last, size, err := contentRange(resp.Header.Get("Content-Range"))
+	if err == nil && first != 0 {
+		err = fmt.Errorf("%w: got bytes %d-%d, want the first", ErrSegment, first, last)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if size >= 0 && last+1 >= size {
+		// The file fits in one segment.
+		return resp.Body, nil
+	}
+	v := validator(resp)
+	if size < 0 || v == "" {
+		// Only ranges of a file which is known not to change can be
+		// fetched separately. Otherwise take it in one go.
+		resp.Body.Close()
+		return httpFetch(ctx, h.c, u)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &segmentReader{
+		cur:    io.LimitReader(resp.Body, last+1),
+		left:   last + 1,
+		body:   resp.Body,
+		cancel: cancel,
+		sem:    make(chan struct{}, h.parallel),
+	}
+	for off := last + 1; off < size; off += h.segmentSize {
+		s.segments = append(s.segments, make(chan segment, 1))
+	}
+	go s.fetch(ctx, h.c, u, last+1, size, h.segmentSize, v)
+	return s, nil
+}
+
+// segment is a fetched segment.
+type segment struct {
+	b   []byte
+	err error
+}
+
+// segmentReader reads the segments of a file in order while they are
+// fetched.
+type segmentReader struct {
+	// cur is the segment being read, first the body of the first
+	// response.
+	cur  io.Reader
+	left int64
+	body io.Closer
+	// segments are the segments after the first, each sent once it is
+	// fetched.
+	segments []chan segment
+	next     int
+	// sem holds a token for each segment fetched but not yet read.
+	sem    chan struct{}
+	cancel context.CancelFunc
+	err    error
+}
+
+// fetch fetches the segments of u from off to size.
+func (s *segmentReader) fetch(ctx context.Context, c *http.Client, u *url.URL, off, size, segmentSize int64, v string) {
+	for i := range s.segments {
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			s.segments[i] <- segment{err: ctx.Err()}
+			continue
+		}
+		first := off + int64(i)*segmentSize
+		last := min(first+segmentSize, size) - 1
+		go func(ch chan segment) {
+			b, err := fetchSegment(ctx, c, u, first, last, size, v)
+			ch <- segment{b: b, err: err}
+		}(s.segments[i])
+	}
+}
+
+// fetchSegment returns bytes first to last of u, which has size bytes.
+func fetchSegment(ctx context.Context, c *http.Client, u *url.URL, first, last, size int64, v string) ([]byte, error) {
+	resp, err := rangeRequest(ctx, c, u, first, last, v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// If-Range gets the whole file when it changed.
+		return nil, fmt.Errorf("%w: %s changed while it was fetched", ErrSegment, u)
+	default:
+		return nil, &HTTPClientCodeError{ErrStatusNotOk, resp.StatusCode}
+	}
+	f, l, sz, err := contentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	if f != first || l != last || sz != size {
+		return nil, fmt.Errorf("%w: got bytes %d-%d/%d, want %d-%d/%d", ErrSegment, f, l, sz, first, last, size)
+	}
+	b := make([]byte, last-first+1)
+	if _, err := io.ReadFull(resp.Body, b); err != nil {
+		return nil, fmt.Errorf("%w: bytes %d-%d: %v", ErrSegment, first, last, err)
+	}
+	return b, nil
+}
+
+// Read implements io.Reader.
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for s.err == nil {
+		n, err := s.cur.Read(p)
+		if s.next == 0 {
+			s.left -= int64(n)
+		}
+		if err != io.EOF {
+			if err != nil {
+				s.fail(err)
+			}
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+		if s.next == 0 {
+			s.body.Close()
+			if s.left != 0 {
+				s.fail(fmt.Errorf("%w: first segment: %v", ErrSegment, io.ErrUnexpectedEOF))
+				break
+			}
+		} else {
+			// The segment was read, so another can be fetched.
+			<-s.sem
+		}
+		if s.next == len(s.segments) {
+			s.fail(io.EOF)
+			break
+		}
+		seg := <-s.segments[s.next]
+		s.next++
+		if seg.err != nil {
+			s.fail(seg.err)
+			break
+		}
+		s.cur = bytes.NewReader(seg.b)
+	}
+	return 0, s.err
+}
+
+func (s *segmentReader) fail(err error) {
+	s.err = err
+	s.cancel()
+	s.body.Close()
+}
+
+// Close stops fetching.
+func (s *segmentReader) Close() error {
+	if s.err == nil {
+		s.fail(errors.New("reader closed"))
+	}
+	return nil
+}
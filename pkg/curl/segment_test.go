@@ -0,0 +1,180 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContentRange(t *testing.T) {
+	for _, tt := range []struct {
+		s                 string
+		first, last, size int64
+		err               bool
+	}{
+		{s: "bytes 0-99/1000", first: 0, last: 99, size: 1000},
+		{s: "bytes 100-199/*", first: 100, last: 199, size: -1},
+		{s: "bytes 0-99/99", err: true},
+		{s: "bytes 9-0/100", err: true},
+		{s: "bytes */100", err: true},
+		{s: "items 0-1/2", err: true},
+	} {
+		first, last, size, err := contentRange(tt.s)
+		if (err != nil) != tt.err || (err == nil && (first != tt.first || last != tt.last || size != tt.size)) {
+			t.Errorf("contentRange(%q) = %d, %d, %d, %v", tt.s, first, last, size, err)
+		}
+	}
+}
+
+func TestSegmentedFetch(t *testing.T) {
+	data := make([]byte, 100_000)
+	rand.New(rand.NewSource(1)).Read(data)
+	modified := time.Unix(1700000000, 0)
+
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		handler  func(requests int32, w http.ResponseWriter, r *http.Request)
+		want     []byte
+		requests int32
+		err      error
+	}{
+		{
+			name:     "segments",
+			data:     data,
+			want:     data,
+			requests: 10,
+		},
+		{
+			name:     "one segment",
+			data:     data[:5000],
+			want:     data[:5000],
+			requests: 1,
+		},
+		{
+			name:     "empty",
+			data:     []byte{},
+			want:     []byte{},
+			requests: 1,
+		},
+		{
+			name: "no ranges",
+			handler: func(_ int32, w http.ResponseWriter, r *http.Request) {
+				w.Write(data)
+			},
+			want:     data,
+			requests: 1,
+		},
+		{
+			name: "no validator",
+			handler: func(_ int32, w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			},
+			want:     data,
+			requests: 2,
+		},
+		{
+			name: "changed",
+			handler: func(n int32, w http.ResponseWriter, r *http.Request) {
+				d := data
+				if n > 3 {
+					w.Header().Set("ETag", `"v2"`)
+					d = append([]byte{1}, data...)
+				} else {
+					w.Header().Set("ETag", `"v1"`)
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(d))
+			},
+			err: ErrSegment,
+		},
+		{
+			name: "wrong range",
+			handler: func(n int32, w http.ResponseWriter, r *http.Request) {
+				if n > 1 {
+					r.Header.Set("Range", "bytes=0-9999")
+				}
+				http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+			},
+			err: ErrSegment,
+		},
+		{
+			name: "missing",
+			handler: func(_ int32, w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			err:      ErrStatusNotOk,
+			requests: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				if tt.handler != nil {
+					tt.handler(n, w, r)
+					return
+				}
+				http.ServeContent(w, r, "", modified, bytes.NewReader(tt.data))
+			}))
+			defer s.Close()
+			u, err := url.Parse(s.URL + "/image")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewHTTPClient(http.DefaultClient).Segmented(10_000, 3)
+			r, err := c.FetchWithoutCache(context.Background(), u)
+			var got []byte
+			if err == nil {
+				got, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Fetch() = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if tt.requests != 0 && requests != tt.requests {
+					t.Errorf("Fetch() made %d requests, want %d", requests, tt.requests)
+				}
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Fetch() = %d bytes, want %d", len(got), len(tt.want))
+			}
+			if requests != tt.requests {
+				t.Errorf("Fetch() made %d requests, want %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestSegmentedFetchCached(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Unix(1, 0), strings.NewReader("0123456789abcdef"))
+	}))
+	defer s.Close()
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewHTTPClient(http.DefaultClient).Segmented(3, 2).Fetch(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := r.ReadAt(b, 10); err != nil || string(b) != "abcd" {
+		t.Errorf("ReadAt(10) = %q, %v, want %q", b, err, "abcd")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,8 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
 	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/u-root/u-root/pkg/metrics"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 )
@@ -195,6 +198,25 @@ type Config struct {
 
 	// If true, add Client Identifier (61) option to the IPv4 request.
 	V4ClientIdentifier bool
+
+	// V6DUID identifies the client to DHCPv6 servers.
+	//
+	// If not set, a DUID-LL made of the interface's hardware address is
+	// used, which unlike a DUID-LLT stays the same across requests and
+	// reboots.
+	V6DUID dhcpv6.DUID
+
+	// V6SLAAC makes the client solicit a router advertisement before
+	// DHCPv6, as on IPv6-only networks. The kernel configures addresses
+	// from its prefixes (SLAAC), and its flags choose whether addresses
+	// are requested by DHCPv6, only other configuration like the boot
+	// file URL is, or DHCPv6 is not used at all.
+	V6SLAAC bool
+
+	// HTTPBoot makes requests like UEFI HTTP Boot clients do, with the
+	// HTTPClient vendor class and an HTTP boot client architecture.
+	// Servers set up for them reply with boot file URLs.
+	HTTPBoot bool
 }
 
 func lease4(ctx context.Context, iface netlink.Link, c Config) (Lease, error) {
@@ -218,13 +240,15 @@ func lease4(ctx context.Context, iface netlink.Link, c Config) (Lease, error) {
 	defer client.Close()
 
 	// Prepend modifiers with default options, so they can be overriden.
-	reqmods := append(
-		[]dhcpv4.Modifier{
-			dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXE UROOT")),
-			dhcpv4.WithRequestedOptions(dhcpv4.OptionSubnetMask),
-			dhcpv4.WithNetboot,
-		},
-		c.Modifiers4...)
+	reqmods := []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXE UROOT")),
+		dhcpv4.WithRequestedOptions(dhcpv4.OptionSubnetMask),
+		dhcpv4.WithNetboot,
+	}
+	if c.HTTPBoot {
+		reqmods = append(reqmods, httpBootModifiers4()...)
+	}
+	reqmods = append(reqmods, c.Modifiers4...)
 
 	if c.V4ClientIdentifier {
 		// Client Id is hardware type + mac per RFC 2132 9.14.
@@ -275,6 +299,18 @@ func lease6(ctx context.Context, iface netlink.Link, c Config, linkUpTimeout tim
 		}
 	}
 
+	var ra *RouterAdvertisement
+	if c.V6SLAAC {
+		var err error
+		if ra, err = slaac(ctx, iface, c, linkUpTimeout); err != nil {
+			return nil, err
+		}
+		if !ra.Managed && !ra.Other {
+			log.Printf("Router %s on %s does not offer DHCPv6, using SLAAC only", ra.Router, iface.Attrs().Name)
+			return &Packet6{p: &dhcpv6.Message{MessageType: dhcpv6.MessageTypeReply}, iface: iface, ra: ra}, nil
+		}
+	}
+
 	// If user specified a non-multicast address, make sure it's routable before we start.
 	if c.V6ServerAddr != nil {
 		for {
@@ -321,24 +357,82 @@ func lease6(ctx context.Context, iface netlink.Link, c Config, linkUpTimeout tim
 	}
 	defer client.Close()
 
+	duid := c.V6DUID
+	if duid == nil {
+		duid = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: i.HardwareAddr}
+	}
+
 	// Prepend modifiers with default options, so they can be overriden.
-	reqmods := append(
-		[]dhcpv6.Modifier{
-			dhcpv6.WithNetboot,
-		},
-		c.Modifiers6...)
-
-	log.Printf("Attempting to get DHCPv6 lease on %s", iface.Attrs().Name)
-	p, err := client.RapidSolicit(ctx, reqmods...)
+	reqmods := []dhcpv6.Modifier{
+		dhcpv6.WithNetboot,
+		dhcpv6.WithClientID(duid),
+	}
+	if c.HTTPBoot {
+		reqmods = append(reqmods, httpBootModifiers6()...)
+	}
+	reqmods = append(reqmods, c.Modifiers6...)
+
+	var p *dhcpv6.Message
+	if ra != nil && !ra.Managed {
+		log.Printf("Attempting to get DHCPv6 information on %s", iface.Attrs().Name)
+		p, err = informationRequest(ctx, client, reqmods...)
+	} else {
+		log.Printf("Attempting to get DHCPv6 lease on %s", iface.Attrs().Name)
+		p, err = client.RapidSolicit(ctx, reqmods...)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	packet := NewPacket6(iface, p)
-	log.Printf("Got DHCPv6 lease on %s: %v", iface.Attrs().Name, p.Summary())
+	packet := &Packet6{p: p, iface: iface, ra: ra}
+	log.Printf("Got DHCPv6 reply on %s: %v", iface.Attrs().Name, p.Summary())
 	return packet, nil
 }
 
+// slaac solicits a router advertisement on iface and waits for the address
+// the kernel configures from it, if any.
+func slaac(ctx context.Context, iface netlink.Link, c Config, linkUpTimeout time.Duration) (*RouterAdvertisement, error) {
+	name := iface.Attrs().Name
+	for _, sysctl := range []string{"accept_ra", "autoconf"} {
+		if err := os.WriteFile(filepath.Join("/proc/sys/net/ipv6/conf", name, sysctl), []byte("1"), 0o644); err != nil {
+			log.Printf("Could not enable %s on %s: %v", sysctl, name, err)
+		}
+	}
+
+	log.Printf("Soliciting IPv6 router advertisement on %s", name)
+	ra, err := SolicitRouter(ctx, iface, c)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ra.slaacPrefix()
+	if prefix == nil {
+		if !ra.Managed {
+			return nil, fmt.Errorf("router %s on %s offers neither SLAAC nor DHCPv6 addresses", ra.Router, name)
+		}
+		return ra, nil
+	}
+	addr, err := waitSLAAC(ctx, iface, prefix, linkUpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Configured %s on %s by SLAAC", addr, name)
+	return ra, nil
+}
+
+// informationRequest requests configuration other than addresses, as in
+// stateless DHCPv6 (RFC 8415, Section 6.1).
+func informationRequest(ctx context.Context, client *nclient6.Client, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	msg, err := dhcpv6.NewMessage(append([]dhcpv6.Modifier{
+		dhcpv6.WithRequestedOptions(dhcpv6.OptionDNSRecursiveNameServer, dhcpv6.OptionDomainSearchList),
+		dhcpv6.WithOption(dhcpv6.OptElapsedTime(0)),
+	}, modifiers...)...)
+	if err != nil {
+		return nil, err
+	}
+	msg.MessageType = dhcpv6.MessageTypeInformationRequest
+	return client.SendAndRead(ctx, client.RemoteAddr(), msg, nclient6.IsMessageType(dhcpv6.MessageTypeReply))
+}
+
 // NetworkProtocol is either IPv4 or IPv6.
 type NetworkProtocol int
 
@@ -358,7 +452,7 @@ func (n NetworkProtocol) String() string {
 	case NetBoth:
 		return "IPv4+IPv6"
 	}
-	return fmt.Sprintf("unknown network protocol (%#x)", n)
+	return fmt.Sprintf("unknown network protocol (%#x)", int(n))
 }
 
 // Result is the result of a particular DHCP attempt.
@@ -378,12 +472,29 @@ type Result struct {
 	Err error
 }
 
+var (
+	leases   = metrics.NewCounterVec("dhclient_leases_total", "Number of DHCP leases obtained, by interface and protocol.", "interface", "protocol")
+	failures = metrics.NewCounterVec("dhclient_failures_total", "Number of DHCP attempts that failed, by interface and protocol.", "interface", "protocol")
+)
+
+// count counts r in the metrics, and returns it.
+func count(r *Result) *Result {
+	if r.Err != nil {
+		failures.With(r.Interface.Attrs().Name, r.Protocol.String()).Inc()
+	} else {
+		leases.With(r.Interface.Attrs().Name, r.Protocol.String()).Inc()
+	}
+	return r
+}
+
 // SendRequests coordinates soliciting DHCP configuration on all ifs.
 //
 // ipv4 and ipv6 determine whether to send DHCPv4 and DHCPv6 requests,
 // respectively.
 //
 // The *Result channel will be closed when all requests have completed.
+//
+// Leases obtained and failed attempts are counted in metrics.Default.
 func SendRequests(ctx context.Context, ifs []netlink.Link, ipv4, ipv6 bool, c Config, linkUpTimeout time.Duration) chan *Result {
 	// Yeah, this is a hack, until we can cancel all leases in progress.
 	r := make(chan *Result, 3*len(ifs))
@@ -405,7 +516,7 @@ func SendRequests(ctx context.Context, ifs []netlink.Link, ipv4, ipv6 bool, c Co
 				go func(iface netlink.Link) {
 					defer wg.Done()
 					lease, err := lease4(ctx, iface, c)
-					r <- &Result{NetIPv4, iface, lease, err}
+					r <- count(&Result{NetIPv4, iface, lease, err})
 				}(iface)
 			}
 
@@ -414,7 +525,7 @@ func SendRequests(ctx context.Context, ifs []netlink.Link, ipv4, ipv6 bool, c Co
 				go func(iface netlink.Link) {
 					defer wg.Done()
 					lease, err := lease6(ctx, iface, c, linkUpTimeout)
-					r <- &Result{NetIPv6, iface, lease, err}
+					r <- count(&Result{NetIPv6, iface, lease, err})
 				}(iface)
 			}
 		}(iface)
@@ -20,6 +20,10 @@ import (
 type Packet6 struct {
 	p     *dhcpv6.Message
 	iface netlink.Link
+
+	// ra is the router advertisement received before DHCPv6, if the
+	// client solicited one.
+	ra *RouterAdvertisement
 }
 
 // NewPacket6 wraps a DHCPv6 packet with some convenience methods.
@@ -46,13 +50,35 @@ func Configure6(iface netlink.Link, packet *dhcpv6.Message) error {
 	return p.Configure()
 }
 
+// RouterAdvertisement returns the router advertisement received before the
+// DHCPv6 exchange, or nil if none was solicited.
+func (p *Packet6) RouterAdvertisement() *RouterAdvertisement {
+	return p.ra
+}
+
 // Configure configures interface using this packet.
+//
+// If the address was configured by SLAAC, only DNS servers are configured.
 func (p *Packet6) Configure() error {
 	l := p.Lease()
-	if l == nil {
+	if l == nil && p.ra == nil {
 		return fmt.Errorf("no lease returned")
 	}
+	if l != nil {
+		if err := p.configureAddr(l); err != nil {
+			return err
+		}
+	}
 
+	if ips := p.DNS(); ips != nil {
+		if err := WriteDNSSettings(ips, nil, "", ResolvConfPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Packet6) configureAddr(l *dhcpv6.OptIAAddress) error {
 	// Add the address to the iface.
 	dst := &netlink.Addr{
 		IPNet: &net.IPNet{
@@ -82,12 +108,6 @@ func (p *Packet6) Configure() error {
 			return fmt.Errorf("add/replace %s to %v: %w", dst, p.iface, err)
 		}
 	}
-
-	if ips := p.DNS(); ips != nil {
-		if err := WriteDNSSettings(ips, nil, "", ResolvConfPath); err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
@@ -95,6 +115,9 @@ func (p *Packet6) String() string {
 	if p.Lease() != nil {
 		return fmt.Sprintf("IPv6 DHCP Lease IP %s", p.Lease().IPv6Addr)
 	}
+	if p.ra != nil {
+		return fmt.Sprintf("IPv6 SLAAC configuration from router %s", p.ra.Router)
+	}
 	return "IPv6 DHCP Lease came with no IP"
 }
 
@@ -107,9 +130,16 @@ func (p *Packet6) Lease() *dhcpv6.OptIAAddress {
 	return iana.Options.OneAddress()
 }
 
-// DNS returns DNS servers assigned.
+// DNS returns DNS servers assigned, by DHCPv6 or else by the router
+// advertisement.
 func (p *Packet6) DNS() []net.IP {
-	return p.p.Options.DNS()
+	if ips := p.p.Options.DNS(); ips != nil {
+		return ips
+	}
+	if p.ra != nil && len(p.ra.DNS) > 0 {
+		return p.ra.DNS
+	}
+	return nil
 }
 
 // Boot returns the boot file URL and parameters assigned.
@@ -121,6 +151,12 @@ func (p *Packet6) Boot() (*url.URL, error) {
 	return url.Parse(uri)
 }
 
+// BootParams returns the parameters for the boot file (option 60 of RFC
+// 5970), e.g. kernel command line arguments.
+func (p *Packet6) BootParams() []string {
+	return p.p.Options.BootFileParam()
+}
+
 // ISCSIBoot returns the target address and volume name to boot from if
 // they were part of the DHCP message.
 //
@@ -0,0 +1,84 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// httpClientClass is the vendor class of UEFI HTTP Boot clients, and of the
+// replies of servers that support them.
+const httpClientClass = "HTTPClient"
+
+// enterpriseIANA is the enterprise number of DHCPv6 vendor classes in UEFI
+// HTTP Boot.
+const enterpriseIANA = 343
+
+// httpBootArchs are the client architecture types of UEFI HTTP Boot clients.
+var httpBootArchs = map[string]iana.Arch{
+	"386":     iana.EFI_X86_HTTP,
+	"amd64":   iana.EFI_X86_64_HTTP,
+	"arm":     iana.EFI_ARM32_HTTP,
+	"arm64":   iana.EFI_ARM64_HTTP,
+	"riscv64": iana.EFI_RISCV64_HTTP,
+}
+
+// httpBootClass returns the vendor class UEFI HTTP Boot clients of arch
+// send, as specified in section 24.7 of the UEFI specification.
+func httpBootClass(arch iana.Arch) string {
+	return fmt.Sprintf("%s:Arch:%05d:UNDI:003001", httpClientClass, arch)
+}
+
+func httpBootArch() iana.Arch {
+	if arch, ok := httpBootArchs[runtime.GOARCH]; ok {
+		return arch
+	}
+	return iana.EFI_X86_64_HTTP
+}
+
+// httpBootModifiers4 makes DHCPv4 requests look like those of UEFI HTTP
+// Boot clients.
+func httpBootModifiers4() []dhcpv4.Modifier {
+	arch := httpBootArch()
+	return []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier(httpBootClass(arch))),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(arch)),
+	}
+}
+
+// httpBootModifiers6 makes DHCPv6 requests look like those of UEFI HTTP
+// Boot clients.
+func httpBootModifiers6() []dhcpv6.Modifier {
+	arch := httpBootArch()
+	return []dhcpv6.Modifier{
+		dhcpv6.WithOption(&dhcpv6.OptVendorClass{
+			EnterpriseNumber: enterpriseIANA,
+			Data:             [][]byte{[]byte(httpBootClass(arch))},
+		}),
+		dhcpv6.WithArchType(arch),
+	}
+}
+
+// HTTPBoot returns whether the server replied to a UEFI HTTP Boot request,
+// in which case the boot file is a URL.
+func (p *Packet4) HTTPBoot() bool {
+	return strings.HasPrefix(p.P.ClassIdentifier(), httpClientClass)
+}
+
+// HTTPBoot returns whether the server replied to a UEFI HTTP Boot request.
+func (p *Packet6) HTTPBoot() bool {
+	for _, class := range p.p.Options.VendorClass(enterpriseIANA) {
+		if strings.HasPrefix(string(class), httpClientClass) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+func TestHTTPBootClass(t *testing.T) {
+	if got, want := httpBootClass(iana.EFI_X86_64_HTTP), "HTTPClient:Arch:00016:UNDI:003001"; got != want {
+		t.Errorf("httpBootClass() = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPBoot4(t *testing.T) {
+	req := mustNew(t, httpBootModifiers4()...)
+	if got := req.ClassIdentifier(); got != httpBootClass(httpBootArch()) {
+		t.Errorf("request class identifier = %q, want %q", got, httpBootClass(httpBootArch()))
+	}
+	if archs := req.ClientArch(); len(archs) != 1 || archs[0] != httpBootArch() {
+		t.Errorf("request client arch = %v, want %v", archs, httpBootArch())
+	}
+
+	for _, tt := range []struct {
+		class string
+		want  bool
+	}{
+		{class: "HTTPClient", want: true},
+		{class: "PXEClient"},
+		{},
+	} {
+		var mods []dhcpv4.Modifier
+		if tt.class != "" {
+			mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClassIdentifier(tt.class)))
+		}
+		p := NewPacket4(nil, mustNew(t, mods...))
+		if got := p.HTTPBoot(); got != tt.want {
+			t.Errorf("HTTPBoot() with class %q = %v, want %v", tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPBoot6(t *testing.T) {
+	req, err := dhcpv6.NewMessage(httpBootModifiers6()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !NewPacket6(nil, req).HTTPBoot() {
+		t.Errorf("HTTPBoot() of request = false, want true")
+	}
+	if archs := req.Options.ArchTypes(); len(archs) != 1 || archs[0] != httpBootArch() {
+		t.Errorf("request arch types = %v, want %v", archs, httpBootArch())
+	}
+
+	reply, err := dhcpv6.NewMessage(dhcpv6.WithOption(&dhcpv6.OptVendorClass{
+		EnterpriseNumber: 9999,
+		Data:             [][]byte{[]byte(httpClientClass)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NewPacket6(nil, reply).HTTPBoot() {
+		t.Errorf("HTTPBoot() with other enterprise number = true, want false")
+	}
+}
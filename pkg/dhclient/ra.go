@@ -0,0 +1,255 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// ErrShortRA is returned for truncated router advertisements.
+var ErrShortRA = errors.New("router advertisement is too short")
+
+// Router advertisement flags and option types from RFC 4861 and RFC 8106.
+const (
+	raFlagManaged = 0x80
+	raFlagOther   = 0x40
+
+	raOptPrefixInfo = 3
+	raOptMTU        = 5
+	raOptRDNSS      = 25
+
+	prefixFlagOnLink     = 0x80
+	prefixFlagAutonomous = 0x40
+
+	// raHeaderSize is the size of the router advertisement after the
+	// ICMPv6 type, code and checksum.
+	raHeaderSize = 12
+)
+
+// allRouters is the link-local all-routers multicast address.
+var allRouters = net.ParseIP("ff02::2")
+
+// RAPrefix is a prefix advertised by a router.
+type RAPrefix struct {
+	Prefix net.IPNet
+
+	// OnLink is set if addresses in the prefix are reachable without a
+	// router.
+	OnLink bool
+
+	// Autonomous is set if hosts configure addresses in the prefix
+	// themselves (SLAAC).
+	Autonomous bool
+
+	ValidLifetime     time.Duration
+	PreferredLifetime time.Duration
+}
+
+// RouterAdvertisement is an IPv6 router advertisement.
+type RouterAdvertisement struct {
+	// Router is the link-local address of the router.
+	Router net.IP
+
+	// Managed is set if addresses are available from DHCPv6.
+	Managed bool
+
+	// Other is set if other configuration, like DNS servers and boot
+	// file URLs, is available from DHCPv6.
+	Other bool
+
+	// RouterLifetime is how long the router is a default router. It is 0
+	// if it is not one.
+	RouterLifetime time.Duration
+
+	Prefixes []RAPrefix
+	MTU      uint32
+
+	// DNS are the recursive DNS servers (RDNSS) advertised by the router.
+	DNS []net.IP
+}
+
+func seconds(b []byte) time.Duration {
+	return time.Duration(binary.BigEndian.Uint32(b)) * time.Second
+}
+
+// ParseRouterAdvertisement parses the body of an ICMPv6 router advertisement,
+// i.e. the message without its type, code and checksum.
+func ParseRouterAdvertisement(b []byte) (*RouterAdvertisement, error) {
+	if len(b) < raHeaderSize {
+		return nil, ErrShortRA
+	}
+	ra := &RouterAdvertisement{
+		Managed:        b[1]&raFlagManaged != 0,
+		Other:          b[1]&raFlagOther != 0,
+		RouterLifetime: time.Duration(binary.BigEndian.Uint16(b[2:])) * time.Second,
+	}
+	for opts := b[raHeaderSize:]; len(opts) > 0; {
+		if len(opts) < 2 || opts[1] == 0 || len(opts) < 8*int(opts[1]) {
+			return nil, ErrShortRA
+		}
+		typ, opt := opts[0], opts[2:8*int(opts[1])]
+		opts = opts[8*int(opts[1]):]
+
+		switch typ {
+		case raOptPrefixInfo:
+			if len(opt) < 30 {
+				return nil, fmt.Errorf("%w: prefix information option", ErrShortRA)
+			}
+			ra.Prefixes = append(ra.Prefixes, RAPrefix{
+				Prefix: net.IPNet{
+					IP:   net.IP(append([]byte(nil), opt[14:30]...)).Mask(net.CIDRMask(int(opt[0]), 128)),
+					Mask: net.CIDRMask(int(opt[0]), 128),
+				},
+				OnLink:            opt[1]&prefixFlagOnLink != 0,
+				Autonomous:        opt[1]&prefixFlagAutonomous != 0,
+				ValidLifetime:     seconds(opt[2:]),
+				PreferredLifetime: seconds(opt[6:]),
+			})
+
+		case raOptMTU:
+			if len(opt) < 6 {
+				return nil, fmt.Errorf("%w: MTU option", ErrShortRA)
+			}
+			ra.MTU = binary.BigEndian.Uint32(opt[2:])
+
+		case raOptRDNSS:
+			if len(opt) < 6 {
+				return nil, fmt.Errorf("%w: RDNSS option", ErrShortRA)
+			}
+			for addrs := opt[6:]; len(addrs) >= net.IPv6len; addrs = addrs[net.IPv6len:] {
+				ra.DNS = append(ra.DNS, net.IP(append([]byte(nil), addrs[:net.IPv6len]...)))
+			}
+		}
+	}
+	return ra, nil
+}
+
+// slaacPrefix returns the first prefix hosts configure addresses in.
+func (ra *RouterAdvertisement) slaacPrefix() *net.IPNet {
+	for _, p := range ra.Prefixes {
+		ones, _ := p.Prefix.Mask.Size()
+		if p.Autonomous && ones == 64 && p.ValidLifetime > 0 {
+			return &p.Prefix
+		}
+	}
+	return nil
+}
+
+// SolicitRouter sends router solicitations on iface and returns the first
+// router advertisement received. Solicitations are retried c.Retries times,
+// every c.Timeout.
+func SolicitRouter(ctx context.Context, iface netlink.Link, c Config) (*RouterAdvertisement, error) {
+	ifi, err := net.InterfaceByName(iface.Attrs().Name)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	pc := conn.IPv6PacketConn()
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeRouterAdvertisement)
+	if err := pc.SetICMPFilter(&filter); err != nil {
+		return nil, err
+	}
+	if err := pc.SetControlMessage(ipv6.FlagInterface|ipv6.FlagHopLimit, true); err != nil {
+		return nil, err
+	}
+	// Routers discard solicitations that might have been forwarded.
+	if err := pc.SetMulticastHopLimit(255); err != nil {
+		return nil, err
+	}
+	if err := pc.SetMulticastInterface(ifi); err != nil {
+		return nil, err
+	}
+
+	rs, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeRouterSolicitation,
+		Body: &icmp.RawBody{Data: make([]byte, 4)},
+	}).Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst := &net.IPAddr{IP: allRouters, Zone: ifi.Name}
+
+	buf := make([]byte, 1500)
+	for try := 0; try <= c.Retries; try++ {
+		if _, err := pc.WriteTo(rs, &ipv6.ControlMessage{IfIndex: ifi.Index, HopLimit: 255}, dst); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(c.Timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := pc.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			n, cm, src, err := pc.ReadFrom(buf)
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			// Advertisements from other links or forwarded ones are
+			// invalid.
+			if cm == nil || cm.IfIndex != ifi.Index || cm.HopLimit != 255 || n < 4 || ipv6.ICMPType(buf[0]) != ipv6.ICMPTypeRouterAdvertisement {
+				continue
+			}
+			ra, err := ParseRouterAdvertisement(buf[4:n])
+			if err != nil {
+				continue
+			}
+			if a, ok := src.(*net.IPAddr); ok {
+				ra.Router = a.IP
+			}
+			return ra, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("no router advertisement received on %s", ifi.Name)
+}
+
+// waitSLAAC waits until the kernel has configured an address in prefix on
+// iface, and returns it.
+func waitSLAAC(ctx context.Context, iface netlink.Link, prefix *net.IPNet, timeout time.Duration) (*net.IPNet, error) {
+	deadline := time.After(timeout)
+	for {
+		addrs, err := netlink.AddrList(iface, netlink.FAMILY_V6)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if prefix.Contains(addr.IP) && addr.Flags&unix.IFA_F_TENTATIVE == 0 {
+				return addr.IPNet, nil
+			}
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			return nil, fmt.Errorf("timeout waiting for an address in %s", prefix)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timeout waiting for an address in %s", prefix)
+		}
+	}
+}
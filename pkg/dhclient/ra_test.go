@@ -0,0 +1,120 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRouterAdvertisement(t *testing.T) {
+	header := []byte{
+		64,         // cur hop limit
+		0x40,       // O flag
+		0x07, 0x08, // router lifetime 1800s
+		0, 0, 0, 0, // reachable time
+		0, 0, 0, 0, // retrans timer
+	}
+	prefix := []byte{
+		3, 4, // prefix information, 32 bytes
+		64, 0xc0, // /64, on-link and autonomous
+		0, 0x27, 0x8d, 0, // valid lifetime 2592000s
+		0, 0x09, 0x3a, 0x80, // preferred lifetime 604800s
+		0, 0, 0, 0,
+		0x20, 0x01, 0x0d, 0xb8, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	mtu := []byte{5, 1, 0, 0, 0, 0, 0x05, 0xdc}
+	rdnss := []byte{
+		25, 3, 0, 0,
+		0, 0, 0x0e, 0x10, // lifetime
+		0x20, 0x01, 0x0d, 0xb8, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x53,
+	}
+	unknown := []byte{1, 1, 0x52, 0x54, 0, 0x12, 0x34, 0x56}
+
+	cat := func(b ...[]byte) []byte {
+		var ret []byte
+		for _, p := range b {
+			ret = append(ret, p...)
+		}
+		return ret
+	}
+
+	for _, tt := range []struct {
+		name string
+		in   []byte
+		want *RouterAdvertisement
+		err  error
+	}{
+		{
+			name: "full",
+			in:   cat(header, unknown, prefix, mtu, rdnss),
+			want: &RouterAdvertisement{
+				Other:          true,
+				RouterLifetime: 1800 * time.Second,
+				Prefixes: []RAPrefix{{
+					Prefix:            net.IPNet{IP: net.ParseIP("2001:db8:1::"), Mask: net.CIDRMask(64, 128)},
+					OnLink:            true,
+					Autonomous:        true,
+					ValidLifetime:     30 * 24 * time.Hour,
+					PreferredLifetime: 7 * 24 * time.Hour,
+				}},
+				MTU: 1500,
+				DNS: []net.IP{net.ParseIP("2001:db8:1::53")},
+			},
+		},
+		{
+			name: "managed",
+			in:   []byte{64, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			want: &RouterAdvertisement{Managed: true},
+		},
+		{
+			name: "short header",
+			in:   header[:8],
+			err:  ErrShortRA,
+		},
+		{
+			name: "truncated option",
+			in:   cat(header, prefix[:16]),
+			err:  ErrShortRA,
+		},
+		{
+			name: "zero length option",
+			in:   cat(header, []byte{1, 0, 0, 0, 0, 0, 0, 0}),
+			err:  ErrShortRA,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRouterAdvertisement(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseRouterAdvertisement() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRouterAdvertisement() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSLAACPrefix(t *testing.T) {
+	p := func(s string, autonomous bool, valid time.Duration) RAPrefix {
+		_, n, _ := net.ParseCIDR(s)
+		return RAPrefix{Prefix: *n, Autonomous: autonomous, ValidLifetime: valid}
+	}
+	ra := &RouterAdvertisement{Prefixes: []RAPrefix{
+		p("2001:db8:1::/64", false, time.Hour),
+		p("2001:db8:2::/48", true, time.Hour),
+		p("2001:db8:3::/64", true, 0),
+		p("2001:db8:4::/64", true, time.Hour),
+	}}
+	if got, want := ra.slaacPrefix().String(), "2001:db8:4::/64"; got != want {
+		t.Errorf("slaacPrefix() = %s, want %s", got, want)
+	}
+	if got := (&RouterAdvertisement{}).slaacPrefix(); got != nil {
+		t.Errorf("slaacPrefix() = %s, want nil", got)
+	}
+}
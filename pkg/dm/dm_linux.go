@@ -0,0 +1,447 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dm talks to the Linux device-mapper through its ioctl interface
+// on /dev/mapper/control.
+package dm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ControlPath is the device-mapper control device.
+var ControlPath = "/dev/mapper/control"
+
+// Interface version this package speaks. The kernel accepts any request
+// with the same major version.
+const (
+	versionMajor = 4
+	versionMinor = 0
+	versionPatch = 0
+)
+
+const (
+	nameLen     = 128
+	uuidLen     = 129
+	typeNameLen = 16
+
+	// ioctlHeaderSize is sizeof(struct dm_ioctl).
+	ioctlHeaderSize = 312
+	// targetSpecSize is sizeof(struct dm_target_spec).
+	targetSpecSize = 40
+)
+
+// ioctl command numbers, from include/uapi/linux/dm-ioctl.h.
+const (
+	cmdVersion = iota
+	cmdRemoveAll
+	cmdListDevices
+	cmdDevCreate
+	cmdDevRemove
+	cmdDevRename
+	cmdDevSuspend
+	cmdDevStatus
+	cmdDevWait
+	cmdTableLoad
+	cmdTableClear
+	cmdTableDeps
+	cmdTableStatus
+	cmdListVersions
+	cmdTargetMsg
+	cmdDevSetGeometry
+)
+
+// Flags of struct dm_ioctl.
+const (
+	flagReadOnly   = 1 << 0
+	flagBufferFull = 1 << 8
+	flagSecureData = 1 << 15
+)
+
+// defaultResultBufSize is the initial size of the buffer for ioctl results.
+const defaultResultBufSize = 16 * 1024
+
+// ErrBufferFull is returned when a result did not fit in the largest buffer
+// the package is willing to allocate.
+var ErrBufferFull = errors.New("device-mapper result buffer full")
+
+// ioctlHeader is struct dm_ioctl without its trailing data.
+type ioctlHeader struct {
+	Version     [3]uint32
+	DataSize    uint32
+	DataStart   uint32
+	TargetCount uint32
+	OpenCount   int32
+	Flags       uint32
+	EventNr     uint32
+	Padding     uint32
+	Dev         uint64
+	Name        [nameLen]byte
+	UUID        [uuidLen]byte
+	Data        [7]byte
+}
+
+// targetSpec is struct dm_target_spec.
+type targetSpec struct {
+	SectorStart uint64
+	Length      uint64
+	Status      int32
+	Next        uint32
+	TargetType  [typeNameLen]byte
+}
+
+// Target is one line of a device-mapper table.
+type Target struct {
+	// Start and Length are in 512-byte sectors.
+	Start  uint64
+	Length uint64
+	// Type is the target type, e.g. "linear", "crypt" or "verity".
+	Type string
+	// Params is the target-specific parameter string.
+	Params string
+}
+
+// String formats t like a dmsetup table line.
+func (t Target) String() string {
+	return fmt.Sprintf("%d %d %s %s", t.Start, t.Length, t.Type, t.Params)
+}
+
+func ioctlNum(cmd uintptr) uintptr {
+	// _IOWR(DM_IOCTL, cmd, struct dm_ioctl)
+	const dmIoctl = 0xfd
+	return (3 << 30) | (ioctlHeaderSize << 16) | (dmIoctl << 8) | cmd
+}
+
+func setString(dst []byte, s, what string) error {
+	if len(s) >= len(dst) {
+		return fmt.Errorf("%s %q is longer than %d bytes", what, s, len(dst)-1)
+	}
+	copy(dst, s)
+	return nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// marshalTargets encodes targets as the data section of a DM_TABLE_LOAD
+// request.
+func marshalTargets(targets []Target) ([]byte, error) {
+	var b bytes.Buffer
+	for _, t := range targets {
+		// Parameters are null-terminated and each spec is 8-byte
+		// aligned.
+		paramLen := len(t.Params) + 1
+		size := (targetSpecSize + paramLen + 7) &^ 7
+		spec := targetSpec{
+			SectorStart: t.Start,
+			Length:      t.Length,
+			Next:        uint32(size),
+		}
+		if err := setString(spec.TargetType[:], t.Type, "target type"); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&b, binary.NativeEndian, &spec); err != nil {
+			return nil, err
+		}
+		b.WriteString(t.Params)
+		b.Write(make([]byte, size-targetSpecSize-len(t.Params)))
+	}
+	return b.Bytes(), nil
+}
+
+// request is a dm_ioctl request: a header followed by payload data.
+type request struct {
+	hdr  ioctlHeader
+	data []byte
+}
+
+func newRequest(name string) (*request, error) {
+	r := &request{}
+	r.hdr.Version = [3]uint32{versionMajor, versionMinor, versionPatch}
+	if err := setString(r.hdr.Name[:], name, "device name"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// do issues cmd on the control device. If the kernel reports that the
+// result did not fit, the request is retried with a bigger buffer.
+func (r *request) do(cmd uintptr) ([]byte, error) {
+	f, err := os.OpenFile(ControlPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bufSize := len(r.data) + defaultResultBufSize
+	for tries := 0; tries < 8; tries++ {
+		buf := make([]byte, ioctlHeaderSize+bufSize)
+		r.hdr.DataStart = ioctlHeaderSize
+		r.hdr.DataSize = uint32(len(buf))
+		var hb bytes.Buffer
+		if err := binary.Write(&hb, binary.NativeEndian, &r.hdr); err != nil {
+			return nil, err
+		}
+		copy(buf, hb.Bytes())
+		copy(buf[ioctlHeaderSize:], r.data)
+
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), ioctlNum(cmd), uintptr(unsafe.Pointer(&buf[0])))
+		if r.hdr.Flags&flagSecureData != 0 {
+			// Don't leave key material lying around in our copy.
+			clear(buf[ioctlHeaderSize : ioctlHeaderSize+len(r.data)])
+		}
+		if errno != 0 {
+			return nil, os.NewSyscallError("ioctl(DM)", errno)
+		}
+		if err := binary.Read(bytes.NewReader(buf[:ioctlHeaderSize]), binary.NativeEndian, &r.hdr); err != nil {
+			return nil, err
+		}
+		if r.hdr.Flags&flagBufferFull == 0 {
+			return buf[r.hdr.DataStart:r.hdr.DataSize], nil
+		}
+		bufSize *= 2
+	}
+	return nil, ErrBufferFull
+}
+
+// Device describes an existing device-mapper device.
+type Device struct {
+	Name  string
+	UUID  string
+	Major uint32
+	Minor uint32
+}
+
+// DevicePath returns the /dev node of the device, e.g. /dev/dm-0.
+func (d *Device) DevicePath() string {
+	return filepath.Join("/dev", fmt.Sprintf("dm-%d", d.Minor))
+}
+
+func (r *request) device() *Device {
+	return &Device{
+		Name:  cString(r.hdr.Name[:]),
+		UUID:  cString(r.hdr.UUID[:]),
+		Major: unix.Major(r.hdr.Dev),
+		Minor: unix.Minor(r.hdr.Dev),
+	}
+}
+
+// CreateOpts are options for Create.
+type CreateOpts struct {
+	// UUID is an optional unique identifier, e.g. "CRYPT-LUKS2-<uuid>-name".
+	UUID string
+	// ReadOnly creates a read-only device.
+	ReadOnly bool
+	// SecureData asks the kernel to wipe the table from its buffers,
+	// which should be set when the table contains key material.
+	SecureData bool
+}
+
+// Create creates the device-mapper device name with the given table and
+// activates it.
+//
+// If loading or activating the table fails, the device is removed again.
+func Create(name string, targets []Target, opts CreateOpts) (*Device, error) {
+	r, err := newRequest(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := setString(r.hdr.UUID[:], opts.UUID, "device UUID"); err != nil {
+		return nil, err
+	}
+	if _, err := r.do(cmdDevCreate); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", name, err)
+	}
+
+	if err := load(name, targets, opts); err != nil {
+		_ = Remove(name)
+		return nil, err
+	}
+
+	// Resuming a device with an inactive table activates that table.
+	r, _ = newRequest(name)
+	if _, err := r.do(cmdDevSuspend); err != nil {
+		_ = Remove(name)
+		return nil, fmt.Errorf("activating %s: %w", name, err)
+	}
+	return r.device(), nil
+}
+
+func load(name string, targets []Target, opts CreateOpts) error {
+	r, err := newRequest(name)
+	if err != nil {
+		return err
+	}
+	if r.data, err = marshalTargets(targets); err != nil {
+		return err
+	}
+	r.hdr.TargetCount = uint32(len(targets))
+	if opts.ReadOnly {
+		r.hdr.Flags |= flagReadOnly
+	}
+	if opts.SecureData {
+		r.hdr.Flags |= flagSecureData
+	}
+	if _, err := r.do(cmdTableLoad); err != nil {
+		return fmt.Errorf("loading table of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Remove removes the device-mapper device name.
+func Remove(name string) error {
+	r, err := newRequest(name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.do(cmdDevRemove); err != nil {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+	return nil
+}
+
+// More flags of struct dm_ioctl.
+const (
+	flagSuspend         = 1 << 1
+	flagStatusTable     = 1 << 4
+	flagActivePresent   = 1 << 5
+	flagInactivePresent = 1 << 6
+)
+
+// unmarshalTargets decodes the count target specs of a DM_TABLE_STATUS
+// result. Unlike in DM_TABLE_LOAD requests, next is the offset of the next
+// spec from the start of data.
+func unmarshalTargets(data []byte, count uint32) ([]Target, error) {
+	var targets []Target
+	off := 0
+	for i := uint32(0); i < count; i++ {
+		if off+targetSpecSize > len(data) {
+			return nil, fmt.Errorf("target %d out of bounds", i)
+		}
+		var spec targetSpec
+		if err := binary.Read(bytes.NewReader(data[off:]), binary.NativeEndian, &spec); err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{
+			Start:  spec.SectorStart,
+			Length: spec.Length,
+			Type:   cString(spec.TargetType[:]),
+			Params: cString(data[off+targetSpecSize:]),
+		})
+		off = int(spec.Next)
+	}
+	return targets, nil
+}
+
+func tableStatus(name string, flags uint32) ([]Target, error) {
+	r, err := newRequest(name)
+	if err != nil {
+		return nil, err
+	}
+	r.hdr.Flags = flags
+	data, err := r.do(cmdTableStatus)
+	if err != nil {
+		return nil, fmt.Errorf("reading table of %s: %w", name, err)
+	}
+	return unmarshalTargets(data, r.hdr.TargetCount)
+}
+
+// Table returns the active table of the device name. Keys of crypt
+// targets are only shown if the kernel is configured to.
+func Table(name string) ([]Target, error) {
+	return tableStatus(name, flagStatusTable)
+}
+
+// Status returns the status of the targets of the device name. Params
+// holds the target-specific status, e.g. "V" for a verity target that has
+// not found corrupted blocks.
+func Status(name string) ([]Target, error) {
+	return tableStatus(name, 0)
+}
+
+// Info is the state of a device-mapper device.
+type Info struct {
+	Device
+	OpenCount     int32
+	TargetCount   uint32
+	EventNr       uint32
+	ReadOnly      bool
+	Suspended     bool
+	LiveTable     bool
+	InactiveTable bool
+}
+
+// GetInfo returns the state of the device name.
+func GetInfo(name string) (*Info, error) {
+	r, err := newRequest(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.do(cmdDevStatus); err != nil {
+		return nil, fmt.Errorf("reading status of %s: %w", name, err)
+	}
+	return &Info{
+		Device:        *r.device(),
+		OpenCount:     r.hdr.OpenCount,
+		TargetCount:   r.hdr.TargetCount,
+		EventNr:       r.hdr.EventNr,
+		ReadOnly:      r.hdr.Flags&flagReadOnly != 0,
+		Suspended:     r.hdr.Flags&flagSuspend != 0,
+		LiveTable:     r.hdr.Flags&flagActivePresent != 0,
+		InactiveTable: r.hdr.Flags&flagInactivePresent != 0,
+	}, nil
+}
+
+// nameListSize is the size of the fixed part of struct dm_name_list.
+const nameListSize = 12
+
+// unmarshalNames decodes the struct dm_name_list entries of a
+// DM_LIST_DEVICES result. Each next is relative to its own entry.
+func unmarshalNames(data []byte) []Device {
+	var devs []Device
+	for off := 0; off+nameListSize <= len(data); {
+		e := data[off:]
+		dev := binary.NativeEndian.Uint64(e)
+		if dev == 0 {
+			// No devices.
+			break
+		}
+		devs = append(devs, Device{
+			Name:  cString(e[nameListSize:]),
+			Major: unix.Major(dev),
+			Minor: unix.Minor(dev),
+		})
+		next := binary.NativeEndian.Uint32(e[8:])
+		if next == 0 {
+			break
+		}
+		off += int(next)
+	}
+	return devs
+}
+
+// List returns all device-mapper devices. UUIDs are not filled in.
+func List() ([]Device, error) {
+	r, err := newRequest("")
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do(cmdListDevices)
+	if err != nil {
+		return nil, fmt.Errorf("listing devices: %w", err)
+	}
+	return unmarshalNames(data), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestABISizes(t *testing.T) {
+	if got := binary.Size(ioctlHeader{}); got != ioctlHeaderSize {
+		t.Errorf("sizeof(dm_ioctl) = %d, want %d", got, ioctlHeaderSize)
+	}
+	if got := binary.Size(targetSpec{}); got != targetSpecSize {
+		t.Errorf("sizeof(dm_target_spec) = %d, want %d", got, targetSpecSize)
+	}
+	if got := unsafe.Sizeof(targetSpec{}); got != targetSpecSize {
+		t.Errorf("unsafe.Sizeof(dm_target_spec) = %d, want %d", got, targetSpecSize)
+	}
+	// DM_DEV_CREATE from dm-ioctl.h.
+	if got, want := ioctlNum(cmdDevCreate), uintptr(0xc138fd03); got != want {
+		t.Errorf("DM_DEV_CREATE = %#x, want %#x", got, want)
+	}
+}
+
+func TestMarshalTargets(t *testing.T) {
+	targets := []Target{
+		{Start: 0, Length: 100, Type: "linear", Params: "/dev/sda 0"},
+		{Start: 100, Length: 50, Type: "zero"},
+	}
+	b, err := marshalTargets(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 40 + len("/dev/sda 0\x00") = 51, aligned to 56; 40 + 1 aligned to 48.
+	if len(b) != 56+48 {
+		t.Fatalf("len(marshalTargets) = %d, want %d", len(b), 56+48)
+	}
+	var spec targetSpec
+	if err := binary.Read(bytes.NewReader(b), binary.NativeEndian, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Length != 100 || spec.Next != 56 || cString(spec.TargetType[:]) != "linear" {
+		t.Errorf("first spec = %+v", spec)
+	}
+	if got := cString(b[targetSpecSize:]); got != "/dev/sda 0" {
+		t.Errorf("params = %q, want %q", got, "/dev/sda 0")
+	}
+	if err := binary.Read(bytes.NewReader(b[56:]), binary.NativeEndian, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.SectorStart != 100 || spec.Next != 48 || cString(spec.TargetType[:]) != "zero" {
+		t.Errorf("second spec = %+v", spec)
+	}
+
+	if _, err := marshalTargets([]Target{{Type: "a-very-long-target-type"}}); err == nil {
+		t.Errorf("marshalTargets with long type succeeded, want error")
+	}
+}
+
+func TestTargetString(t *testing.T) {
+	tg := Target{Start: 0, Length: 8, Type: "linear", Params: "/dev/sda 2048"}
+	if got, want := tg.String(), "0 8 linear /dev/sda 2048"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestUnmarshalTargets(t *testing.T) {
+	// Build a DM_TABLE_STATUS result, where next is counted from the
+	// start of data.
+	var b bytes.Buffer
+	for _, tg := range []Target{
+		{Start: 0, Length: 100, Type: "linear", Params: "8:1 0"},
+		{Start: 100, Length: 50, Type: "verity", Params: "V"},
+	} {
+		spec := targetSpec{SectorStart: tg.Start, Length: tg.Length}
+		copy(spec.TargetType[:], tg.Type)
+		spec.Next = uint32(b.Len() + 64)
+		if err := binary.Write(&b, binary.NativeEndian, &spec); err != nil {
+			t.Fatal(err)
+		}
+		b.WriteString(tg.Params)
+		b.Write(make([]byte, 64-targetSpecSize-len(tg.Params)))
+	}
+
+	got, err := unmarshalTargets(b.Bytes(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Start: 0, Length: 100, Type: "linear", Params: "8:1 0"},
+		{Start: 100, Length: 50, Type: "verity", Params: "V"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshalTargets() = %+v, want %+v", got, want)
+	}
+	if _, err := unmarshalTargets(b.Bytes(), 3); err == nil {
+		t.Errorf("unmarshalTargets() past the end succeeded, want error")
+	}
+}
+
+func TestUnmarshalNames(t *testing.T) {
+	entry := func(major, minor uint32, next uint32, name string) []byte {
+		e := make([]byte, 24)
+		binary.NativeEndian.PutUint64(e, unix.Mkdev(major, minor))
+		binary.NativeEndian.PutUint32(e[8:], next)
+		copy(e[nameListSize:], name)
+		return e
+	}
+	data := append(entry(253, 0, 24, "root"), entry(253, 1, 0, "swap")...)
+	want := []Device{{Name: "root", Major: 253}, {Name: "swap", Major: 253, Minor: 1}}
+	if got := unmarshalNames(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshalNames() = %+v, want %+v", got, want)
+	}
+	if got := unmarshalNames(make([]byte, 16)); got != nil {
+		t.Errorf("unmarshalNames(empty) = %+v, want nil", got)
+	}
+}
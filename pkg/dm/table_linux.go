@@ -0,0 +1,148 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseTable parses a table in dmsetup format: one target per line of the
+// form "start length type params". Blank lines and lines starting with #
+// are ignored.
+func ParseTable(r io.Reader) ([]Target, error) {
+	var targets []Target
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 3 {
+			return nil, fmt.Errorf("table line %d: want start, length and type", n)
+		}
+		start, err := strconv.ParseUint(f[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("table line %d: start: %w", n, err)
+		}
+		length, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("table line %d: length: %w", n, err)
+		}
+		targets = append(targets, Target{
+			Start:  start,
+			Length: length,
+			Type:   f[2],
+			Params: strings.Join(f[3:], " "),
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("empty table")
+	}
+	return targets, nil
+}
+
+// withOptions appends the optional parameters of a target, preceded by
+// their count.
+func withOptions(params string, opts []string) string {
+	if len(opts) == 0 {
+		return params
+	}
+	return fmt.Sprintf("%s %d %s", params, len(opts), strings.Join(opts, " "))
+}
+
+// Linear returns a target mapping length sectors at start to device,
+// beginning at sector offset.
+func Linear(start, length uint64, device string, offset uint64) Target {
+	return Target{
+		Start:  start,
+		Length: length,
+		Type:   "linear",
+		Params: fmt.Sprintf("%s %d", device, offset),
+	}
+}
+
+// Crypt describes a dm-crypt target.
+type Crypt struct {
+	// Cipher is the kernel crypto API cipher spec, e.g. aes-xts-plain64.
+	Cipher string
+	Key    []byte
+	// IVOffset is added to the sector number to compute the IV.
+	IVOffset uint64
+
+	Device string
+	// Offset is where the encrypted data starts on Device, in sectors.
+	Offset uint64
+	// Length of the mapping in sectors.
+	Length uint64
+
+	// Options are optional parameters, e.g. "allow_discards" or
+	// "sector_size:4096".
+	Options []string
+}
+
+// Target returns the table line of c at sector 0. The parameters contain
+// the key, so devices created from it should set CreateOpts.SecureData.
+func (c *Crypt) Target() Target {
+	return Target{
+		Length: c.Length,
+		Type:   "crypt",
+		Params: withOptions(fmt.Sprintf("%s %s %d %s %d", c.Cipher, hex.EncodeToString(c.Key), c.IVOffset, c.Device, c.Offset), c.Options),
+	}
+}
+
+// Verity describes a dm-verity target.
+type Verity struct {
+	// Version is the hash format version, 1 unless the hash tree was
+	// made for Chrome OS.
+	Version int
+
+	DataDevice string
+	HashDevice string
+
+	// Block sizes are in bytes.
+	DataBlockSize uint32
+	HashBlockSize uint32
+
+	// DataBlocks is the number of data blocks covered by the hash tree.
+	DataBlocks uint64
+	// HashStart is the block of HashDevice where the hash tree starts,
+	// in units of HashBlockSize.
+	HashStart uint64
+
+	// Algorithm is the hash algorithm, e.g. sha256.
+	Algorithm string
+	RootHash  []byte
+	// Salt may be empty.
+	Salt []byte
+
+	// Options are optional parameters, e.g. "panic_on_corruption" or
+	// "ignore_zero_blocks".
+	Options []string
+}
+
+// Target returns the table line of v at sector 0.
+func (v *Verity) Target() Target {
+	salt := "-"
+	if len(v.Salt) != 0 {
+		salt = hex.EncodeToString(v.Salt)
+	}
+	params := fmt.Sprintf("%d %s %s %d %d %d %d %s %s %s",
+		v.Version, v.DataDevice, v.HashDevice, v.DataBlockSize, v.HashBlockSize,
+		v.DataBlocks, v.HashStart, v.Algorithm, hex.EncodeToString(v.RootHash), salt)
+	return Target{
+		Length: v.DataBlocks * uint64(v.DataBlockSize) / 512,
+		Type:   "verity",
+		Params: withOptions(params, v.Options),
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		table string
+		want  []Target
+		err   bool
+	}{
+		{
+			name:  "linear",
+			table: "0 2048 linear /dev/sda 0\n",
+			want:  []Target{{Length: 2048, Type: "linear", Params: "/dev/sda 0"}},
+		},
+		{
+			name:  "multiple",
+			table: "# root\n0 100 linear 8:1 0\n\n100  50 zero\n",
+			want: []Target{
+				{Length: 100, Type: "linear", Params: "8:1 0"},
+				{Start: 100, Length: 50, Type: "zero"},
+			},
+		},
+		{name: "empty", table: "\n# nothing\n", err: true},
+		{name: "short", table: "0 100\n", err: true},
+		{name: "bad start", table: "x 100 zero\n", err: true},
+		{name: "bad length", table: "0 -1 zero\n", err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTable(strings.NewReader(tt.table))
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseTable() = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTable() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		got  Target
+		want string
+	}{
+		{
+			name: "linear",
+			got:  Linear(8, 100, "/dev/sdb1", 2048),
+			want: "8 100 linear /dev/sdb1 2048",
+		},
+		{
+			name: "crypt",
+			got: (&Crypt{
+				Cipher:  "aes-xts-plain64",
+				Key:     []byte{0xde, 0xad, 0xbe, 0xef},
+				Device:  "/dev/sda2",
+				Offset:  32768,
+				Length:  1000,
+				Options: []string{"allow_discards", "sector_size:4096"},
+			}).Target(),
+			want: "0 1000 crypt aes-xts-plain64 deadbeef 0 /dev/sda2 32768 2 allow_discards sector_size:4096",
+		},
+		{
+			name: "verity",
+			got: (&Verity{
+				Version:       1,
+				DataDevice:    "/dev/sda3",
+				HashDevice:    "/dev/sda4",
+				DataBlockSize: 4096,
+				HashBlockSize: 4096,
+				DataBlocks:    256,
+				HashStart:     1,
+				Algorithm:     "sha256",
+				RootHash:      []byte{0x01, 0x02},
+			}).Target(),
+			want: "0 2048 verity 1 /dev/sda3 /dev/sda4 4096 4096 256 1 sha256 0102 -",
+		},
+		{
+			name: "verity salt",
+			got: (&Verity{
+				Version:       1,
+				DataDevice:    "/dev/sda3",
+				HashDevice:    "/dev/sda3",
+				DataBlockSize: 512,
+				HashBlockSize: 4096,
+				DataBlocks:    8,
+				HashStart:     1,
+				Algorithm:     "sha1",
+				RootHash:      []byte{0xff},
+				Salt:          []byte{0xab, 0xcd},
+				Options:       []string{"panic_on_corruption"},
+			}).Target(),
+			want: "0 8 verity 1 /dev/sda3 /dev/sda3 512 4096 8 1 sha1 ff abcd 1 panic_on_corruption",
+		},
+	} {
+		if s := tt.got.String(); s != tt.want {
+			t.Errorf("%s: table = %q, want %q", tt.name, s, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrBadOverlay is returned for overlays that are not as dtc -@
+	// compiles them.
+	ErrBadOverlay = errors.New("invalid overlay")
+	// ErrNoSymbol is returned if an overlay refers to a label that is
+	// not in the __symbols__ of the base tree, e.g. because the base was
+	// compiled without dtc -@.
+	ErrNoSymbol = errors.New("label not in __symbols__")
+)
+
+// Special nodes of overlays and of trees overlays are applied to.
+const (
+	overlayNode     = "__overlay__"
+	symbolsNode     = "__symbols__"
+	fixupsNode      = "__fixups__"
+	localFixupsNode = "__local_fixups__"
+)
+
+// NodeByPath returns the node at an absolute path, such as /soc/i2c@1000.
+// A component without a unit address matches a node with one if that is
+// the only node of that name.
+func (fdt *FDT) NodeByPath(path string) (*Node, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	n := fdt.RootNode
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		c, ok := n.LookupChildByName(name)
+		if !ok && !strings.Contains(name, "@") {
+			for _, cc := range n.Children {
+				if base, _, _ := strings.Cut(cc.Name, "@"); base == name {
+					if ok {
+						return nil, false
+					}
+					c, ok = cc, true
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		n = c
+	}
+	return n, true
+}
+
+// pathOf returns the absolute path of a node of the tree.
+func (fdt *FDT) pathOf(target *Node) (string, bool) {
+	var walk func(n *Node, path string) (string, bool)
+	walk = func(n *Node, path string) (string, bool) {
+		if n == target {
+			return path, true
+		}
+		for _, c := range n.Children {
+			if p, ok := walk(c, strings.TrimSuffix(path, "/")+"/"+c.Name); ok {
+				return p, true
+			}
+		}
+		return "", false
+	}
+	return walk(fdt.RootNode, "/")
+}
+
+func isPHandle(name string) bool {
+	return name == "phandle" || name == "linux,phandle"
+}
+
+// maxPHandle returns the highest phandle of a tree.
+func (fdt *FDT) maxPHandle() uint32 {
+	var max uint32
+	fdt.RootNode.Walk(func(n *Node) error {
+		for _, p := range n.Properties {
+			if isPHandle(p.Name) && len(p.Value) == 4 {
+				if v := binary.BigEndian.Uint32(p.Value); v != 0xffffffff && v > max {
+					max = v
+				}
+			}
+		}
+		return nil
+	})
+	return max
+}
+
+// NodeByPHandle returns the node with a phandle.
+func (fdt *FDT) NodeByPHandle(ph PHandle) (*Node, bool) {
+	return fdt.RootNode.Find(func(n *Node) bool {
+		for _, p := range n.Properties {
+			if isPHandle(p.Name) && len(p.Value) == 4 && PHandle(binary.BigEndian.Uint32(p.Value)) == ph {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// addU32 adds delta to the cell at off of a property.
+func addU32(p *Property, off uint32, delta uint32) error {
+	if uint64(off)+4 > uint64(len(p.Value)) {
+		return fmt.Errorf("offset %d past the end of property %q: %w", off, p.Name, ErrBadOverlay)
+	}
+	binary.BigEndian.PutUint32(p.Value[off:], binary.BigEndian.Uint32(p.Value[off:])+delta)
+	return nil
+}
+
+// localFixups adds delta to the phandles of an overlay, and to the
+// references to them that __local_fixups__ lists for n.
+func localFixups(n, fixups *Node, delta uint32) error {
+	for _, f := range fixups.Properties {
+		p, ok := n.LookProperty(f.Name)
+		if !ok {
+			return fmt.Errorf("local fixup of missing property %s/%s: %w", n.Name, f.Name, ErrBadOverlay)
+		}
+		if len(f.Value)%4 != 0 {
+			return fmt.Errorf("local fixup %s/%s: %w", n.Name, f.Name, ErrBadOverlay)
+		}
+		for i := 0; i < len(f.Value); i += 4 {
+			if err := addU32(p, binary.BigEndian.Uint32(f.Value[i:]), delta); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fc := range fixups.Children {
+		c, ok := n.LookupChildByName(fc.Name)
+		if !ok {
+			return fmt.Errorf("local fixup of missing node %s/%s: %w", n.Name, fc.Name, ErrBadOverlay)
+		}
+		if err := localFixups(c, fc, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixups resolves the references of an overlay to labels of the base.
+func fixups(base, overlay *FDT, fixups *Node) error {
+	symbols, _ := base.RootNode.LookupChildByName(symbolsNode)
+	for _, f := range fixups.Properties {
+		if symbols == nil {
+			return fmt.Errorf("%q: %w", f.Name, ErrNoSymbol)
+		}
+		sym, ok := symbols.LookProperty(f.Name)
+		if !ok {
+			return fmt.Errorf("%q: %w", f.Name, ErrNoSymbol)
+		}
+		path, err := sym.AsString()
+		if err != nil {
+			return fmt.Errorf("symbol %q: %w", f.Name, err)
+		}
+		target, ok := base.NodeByPath(path)
+		if !ok {
+			return fmt.Errorf("symbol %q: no node at %s", f.Name, path)
+		}
+		ph, ok := target.LookProperty("phandle")
+		if !ok {
+			ph, ok = target.LookProperty("linux,phandle")
+		}
+		if !ok || len(ph.Value) != 4 {
+			return fmt.Errorf("symbol %q: %s has no phandle", f.Name, path)
+		}
+
+		locs, err := f.AsStringList()
+		if err != nil {
+			return fmt.Errorf("fixup %q: %w", f.Name, ErrBadOverlay)
+		}
+		for _, loc := range locs {
+			// Each location is path:property:offset.
+			parts := strings.Split(loc, ":")
+			if len(parts) != 3 {
+				return fmt.Errorf("fixup %q: %q: %w", f.Name, loc, ErrBadOverlay)
+			}
+			off, err := strconv.ParseUint(parts[2], 10, 32)
+			if err != nil {
+				return fmt.Errorf("fixup %q: %q: %w", f.Name, loc, ErrBadOverlay)
+			}
+			n, ok := overlay.NodeByPath(parts[0])
+			if !ok {
+				return fmt.Errorf("fixup %q: no node at %s: %w", f.Name, parts[0], ErrBadOverlay)
+			}
+			p, ok := n.LookProperty(parts[1])
+			if !ok || off+4 > uint64(len(p.Value)) {
+				return fmt.Errorf("fixup %q: no cell at %q: %w", f.Name, loc, ErrBadOverlay)
+			}
+			copy(p.Value[off:], ph.Value)
+		}
+	}
+	return nil
+}
+
+// merge merges the properties and children of src into dst.
+func merge(dst, src *Node) {
+	for _, p := range src.Properties {
+		dst.Update(Property{Name: p.Name, Value: append([]byte(nil), p.Value...)})
+	}
+	for _, c := range src.Children {
+		if d, ok := dst.LookupChildByName(c.Name); ok {
+			merge(d, c)
+			continue
+		}
+		d := NewNode(c.Name)
+		merge(d, c)
+		dst.Children = append(dst.Children, d)
+	}
+}
+
+// fragmentTarget returns the node of the base a fragment applies to.
+func fragmentTarget(base *FDT, frag *Node) (*Node, error) {
+	if p, ok := frag.LookProperty("target"); ok {
+		ph, err := p.AsPHandle()
+		if err != nil {
+			return nil, fmt.Errorf("%s: target: %w", frag.Name, err)
+		}
+		n, ok := base.NodeByPHandle(ph)
+		if !ok {
+			return nil, fmt.Errorf("%s: no node with phandle %#x", frag.Name, uint32(ph))
+		}
+		return n, nil
+	}
+	if p, ok := frag.LookProperty("target-path"); ok {
+		path, err := p.AsString()
+		if err != nil {
+			return nil, fmt.Errorf("%s: target-path: %w", frag.Name, err)
+		}
+		n, ok := base.NodeByPath(path)
+		if !ok {
+			return nil, fmt.Errorf("%s: no node at %s", frag.Name, path)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s has no target or target-path: %w", frag.Name, ErrBadOverlay)
+}
+
+// ApplyOverlay applies a device tree overlay, as compiled by dtc -@ from a
+// .dtso, to the tree.
+//
+// The phandles of the overlay are moved past those of the tree, its
+// references to labels of the tree are resolved through the tree's
+// __symbols__, and each fragment is merged into its target. The labels of
+// the overlay are added to __symbols__, so that later overlays can refer
+// to them. The overlay is modified in the process.
+func (fdt *FDT) ApplyOverlay(overlay *FDT) error {
+	root := overlay.RootNode
+	if delta := fdt.maxPHandle(); delta != 0 {
+		root.Walk(func(n *Node) error {
+			for i := range n.Properties {
+				if isPHandle(n.Properties[i].Name) && len(n.Properties[i].Value) == 4 {
+					addU32(&n.Properties[i], 0, delta)
+				}
+			}
+			return nil
+		})
+		if lf, ok := root.LookupChildByName(localFixupsNode); ok {
+			if err := localFixups(root, lf, delta); err != nil {
+				return err
+			}
+		}
+	}
+	if f, ok := root.LookupChildByName(fixupsNode); ok {
+		if err := fixups(fdt, overlay, f); err != nil {
+			return err
+		}
+	}
+
+	// Where the fragments go, to rewrite the paths of the symbols.
+	targets := map[string]string{}
+	for _, frag := range root.Children {
+		ov, ok := frag.LookupChildByName(overlayNode)
+		if !ok {
+			continue
+		}
+		t, err := fragmentTarget(fdt, frag)
+		if err != nil {
+			return err
+		}
+		merge(t, ov)
+		if path, ok := fdt.pathOf(t); ok {
+			targets["/"+frag.Name+"/"+overlayNode] = path
+		}
+	}
+
+	osyms, ok := root.LookupChildByName(symbolsNode)
+	if !ok {
+		return nil
+	}
+	syms, ok := fdt.RootNode.LookupChildByName(symbolsNode)
+	if !ok {
+		syms = NewNode(symbolsNode)
+		fdt.RootNode.Children = append(fdt.RootNode.Children, syms)
+	}
+	for _, s := range osyms.Properties {
+		path, err := s.AsString()
+		if err != nil {
+			return fmt.Errorf("symbol %q: %w", s.Name, err)
+		}
+		for frag, target := range targets {
+			if rest, ok := strings.CutPrefix(path, frag); ok && (rest == "" || rest[0] == '/') {
+				path = strings.TrimSuffix(target, "/") + rest
+				if path == "" {
+					path = "/"
+				}
+				break
+			}
+		}
+		syms.Update(PropertyString(s.Name, path))
+	}
+	return nil
+}
+
+// Bytes returns the tree as a device tree blob.
+func (fdt *FDT) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	if _, err := fdt.Write(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func u32(name string, v ...uint32) Property {
+	b := make([]byte, 4*len(v))
+	for i, c := range v {
+		binary.BigEndian.PutUint32(b[4*i:], c)
+	}
+	return Property{Name: name, Value: b}
+}
+
+func baseTree() *FDT {
+	return &FDT{
+		Header: Header{Magic: Magic, Version: 17, LastCompVersion: 16},
+		RootNode: NewNode("", WithChildren(
+			NewNode("soc", WithChildren(
+				NewNode("i2c@1000", WithProperty(u32("phandle", 1))),
+				NewNode("gpio@2000", WithProperty(u32("phandle", 2), u32("#gpio-cells", 2))),
+			)),
+			NewNode(symbolsNode, WithProperty(
+				PropertyString("i2c0", "/soc/i2c@1000"),
+				PropertyString("gpio", "/soc/gpio@2000"),
+			)),
+		)),
+	}
+}
+
+// overlayTree is what dtc -@ makes of
+//
+//	&i2c0 {
+//		sensor@48 { reg = <0x48>; interrupt-parent = <&gpio>; };
+//		mux: mux@70 { reg = <0x70>; };
+//	};
+//	&{/} { widget { mux = <&mux>; }; };
+func overlayTree() *FDT {
+	return &FDT{RootNode: NewNode("", WithChildren(
+		NewNode("fragment@0",
+			WithProperty(u32("target", 0xffffffff)),
+			WithChildren(NewNode(overlayNode, WithChildren(
+				NewNode("sensor@48", WithProperty(u32("reg", 0x48), u32("interrupt-parent", 0xffffffff))),
+				NewNode("mux@70", WithProperty(u32("reg", 0x70), u32("phandle", 1))),
+			))),
+		),
+		NewNode("fragment@1",
+			WithProperty(PropertyString("target-path", "/")),
+			WithChildren(NewNode(overlayNode, WithChildren(
+				NewNode("widget", WithProperty(u32("mux", 1))),
+			))),
+		),
+		NewNode(symbolsNode, WithProperty(PropertyString("mux", "/fragment@0/__overlay__/mux@70"))),
+		NewNode(fixupsNode, WithProperty(
+			PropertyString("i2c0", "/fragment@0:target:0"),
+			PropertyString("gpio", "/fragment@0/__overlay__/sensor@48:interrupt-parent:0"),
+		)),
+		NewNode(localFixupsNode, WithChildren(
+			NewNode("fragment@1", WithChildren(NewNode(overlayNode, WithChildren(
+				NewNode("widget", WithProperty(u32("mux", 0))),
+			)))),
+		)),
+	))}
+}
+
+func propU32(t *testing.T, fdt *FDT, path, name string) uint32 {
+	t.Helper()
+	n, ok := fdt.NodeByPath(path)
+	if !ok {
+		t.Fatalf("no node at %s", path)
+	}
+	p, ok := n.LookProperty(name)
+	if !ok {
+		t.Fatalf("%s has no property %s", path, name)
+	}
+	v, err := p.AsU32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestApplyOverlay(t *testing.T) {
+	base := baseTree()
+	if err := base.ApplyOverlay(overlayTree()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Round trip through a blob, as passed to kexec.
+	b, err := base.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdt, err := ReadFDT(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path, prop string
+		want       uint32
+	}{
+		{"/soc/i2c@1000/sensor@48", "reg", 0x48},
+		// Resolved to the base's gpio.
+		{"/soc/i2c@1000/sensor@48", "interrupt-parent", 2},
+		// Moved past the phandles of the base.
+		{"/soc/i2c@1000/mux@70", "phandle", 3},
+		{"/widget", "mux", 3},
+		{"/soc/gpio@2000", "#gpio-cells", 2},
+	} {
+		if got := propU32(t, fdt, tt.path, tt.prop); got != tt.want {
+			t.Errorf("%s/%s = %d, want %d", tt.path, tt.prop, got, tt.want)
+		}
+	}
+
+	syms, _ := fdt.RootNode.LookupChildByName(symbolsNode)
+	p, _ := syms.LookProperty("mux")
+	if got, _ := p.AsString(); got != "/soc/i2c@1000/mux@70" {
+		t.Errorf("symbol mux = %q, want /soc/i2c@1000/mux@70", got)
+	}
+}
+
+func TestApplyOverlayErrors(t *testing.T) {
+	// A base compiled without -@.
+	base := baseTree()
+	base.RootNode.Children = base.RootNode.Children[:1]
+	if err := base.ApplyOverlay(overlayTree()); !errors.Is(err, ErrNoSymbol) {
+		t.Errorf("ApplyOverlay without __symbols__ = %v, want %v", err, ErrNoSymbol)
+	}
+
+	ov := overlayTree()
+	frag, _ := ov.NodeByPath("/fragment@1")
+	frag.Properties = nil
+	if err := baseTree().ApplyOverlay(ov); !errors.Is(err, ErrBadOverlay) {
+		t.Errorf("ApplyOverlay of a fragment without target = %v, want %v", err, ErrBadOverlay)
+	}
+
+	ov = overlayTree()
+	lf, _ := ov.NodeByPath("/__local_fixups__/fragment@1/__overlay__/widget")
+	lf.Properties[0] = u32("mux", 4)
+	if err := baseTree().ApplyOverlay(ov); !errors.Is(err, ErrBadOverlay) {
+		t.Errorf("ApplyOverlay with a fixup past the end = %v, want %v", err, ErrBadOverlay)
+	}
+}
+
+func TestNodeByPath(t *testing.T) {
+	fdt := baseTree()
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{"/", ""},
+		{"/soc/i2c@1000", "i2c@1000"},
+		{"/soc/gpio", "gpio@2000"},
+		{"/soc/spi", "-"},
+		{"soc", "-"},
+	} {
+		n, ok := fdt.NodeByPath(tt.path)
+		got := "-"
+		if ok {
+			got = n.Name
+		}
+		if got != tt.want {
+			t.Errorf("NodeByPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
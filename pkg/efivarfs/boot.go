@@ -0,0 +1,344 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	guid "github.com/google/uuid"
+)
+
+// GlobalVariableGUID is the EFI_GLOBAL_VARIABLE vendor GUID, which owns the
+// boot manager variables (Boot####, BootOrder, BootNext, BootCurrent).
+var GlobalVariableGUID = guid.MustParse("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+
+// Load option attributes, as defined in UEFI spec 2.10, section 3.1.3.
+const (
+	LoadOptionActive         uint32 = 0x00000001
+	LoadOptionForceReconnect uint32 = 0x00000002
+	LoadOptionHidden         uint32 = 0x00000008
+	LoadOptionCategoryApp    uint32 = 0x00000100
+)
+
+// bootVarAttrs are the attributes the boot manager expects its variables to
+// have.
+const bootVarAttrs = AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+
+var (
+	// ErrBadLoadOption is returned when a Boot#### variable can not be
+	// decoded.
+	ErrBadLoadOption = errors.New("malformed EFI_LOAD_OPTION")
+
+	// ErrNoFreeBootEntry is returned when all 65536 Boot#### variables
+	// are in use.
+	ErrNoFreeBootEntry = errors.New("no free boot entry number")
+)
+
+// LoadOption is an EFI_LOAD_OPTION, the content of a Boot#### variable.
+//
+//	typedef struct _EFI_LOAD_OPTION {
+//	    UINT32 Attributes;
+//	    UINT16 FilePathListLength;
+//	    // CHAR16 Description[];
+//	    // EFI_DEVICE_PATH_PROTOCOL FilePathList[];
+//	    // UINT8 OptionalData[];
+//	} EFI_LOAD_OPTION;
+type LoadOption struct {
+	Attributes   uint32
+	Description  string
+	FilePathList []byte
+	OptionalData []byte
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (o *LoadOption) MarshalBinary() ([]byte, error) {
+	if len(o.FilePathList) > 0xffff {
+		return nil, fmt.Errorf("%w: file path list is %d bytes", ErrBadLoadOption, len(o.FilePathList))
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, o.Attributes)
+	binary.Write(&b, binary.LittleEndian, uint16(len(o.FilePathList)))
+	b.Write(encodeUCS2(o.Description))
+	b.Write(o.FilePathList)
+	b.Write(o.OptionalData)
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (o *LoadOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 6 {
+		return fmt.Errorf("%w: %d bytes is too short", ErrBadLoadOption, len(data))
+	}
+	o.Attributes = binary.LittleEndian.Uint32(data[0:4])
+	pathLen := int(binary.LittleEndian.Uint16(data[4:6]))
+
+	desc, n, err := decodeUCS2(data[6:])
+	if err != nil {
+		return err
+	}
+	o.Description = desc
+
+	rest := data[6+n:]
+	if len(rest) < pathLen {
+		return fmt.Errorf("%w: file path list is %d bytes, want %d", ErrBadLoadOption, len(rest), pathLen)
+	}
+	o.FilePathList = rest[:pathLen]
+	o.OptionalData = rest[pathLen:]
+	return nil
+}
+
+// encodeUCS2 returns s as a null-terminated UCS-2 string.
+func encodeUCS2(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u)+2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// decodeUCS2 decodes a null-terminated UCS-2 string and returns it along
+// with the number of bytes consumed, including the terminator.
+func decodeUCS2(b []byte) (string, int, error) {
+	var u []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			return string(utf16.Decode(u)), i + 2, nil
+		}
+		u = append(u, c)
+	}
+	return "", 0, fmt.Errorf("%w: description is not null-terminated", ErrBadLoadOption)
+}
+
+// BootEntryName returns the variable name of boot entry n.
+func BootEntryName(n uint16) string {
+	return fmt.Sprintf("Boot%04X", n)
+}
+
+func globalVar(name string) VariableDescriptor {
+	return VariableDescriptor{Name: name, GUID: GlobalVariableGUID}
+}
+
+// ReadBootEntry reads and decodes boot entry n.
+func ReadBootEntry(e EFIVar, n uint16) (*LoadOption, error) {
+	_, data, err := ReadVariable(e, globalVar(BootEntryName(n)))
+	if err != nil {
+		return nil, err
+	}
+	o := &LoadOption{}
+	if err := o.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", BootEntryName(n), err)
+	}
+	return o, nil
+}
+
+// WriteBootEntry creates or replaces boot entry n.
+func WriteBootEntry(e EFIVar, n uint16, o *LoadOption) error {
+	data, err := o.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return WriteVariable(e, globalVar(BootEntryName(n)), bootVarAttrs, data)
+}
+
+// RemoveBootEntry deletes boot entry n and drops it from BootOrder and
+// BootNext.
+func RemoveBootEntry(e EFIVar, n uint16) error {
+	if err := RemoveVariable(e, globalVar(BootEntryName(n))); err != nil {
+		return err
+	}
+
+	order, err := ReadBootOrder(e)
+	if err != nil && !errors.Is(err, ErrVarNotExist) {
+		return err
+	}
+	var newOrder []uint16
+	for _, o := range order {
+		if o != n {
+			newOrder = append(newOrder, o)
+		}
+	}
+	if len(newOrder) != len(order) {
+		if err := WriteBootOrder(e, newOrder); err != nil {
+			return err
+		}
+	}
+
+	if next, err := ReadBootNext(e); err == nil && next == n {
+		return RemoveBootNext(e)
+	}
+	return nil
+}
+
+// ListBootEntries returns the numbers of all Boot#### variables in
+// ascending order.
+func ListBootEntries(e EFIVar) ([]uint16, error) {
+	vars, err := ListVariables(e)
+	if err != nil {
+		return nil, err
+	}
+	var entries []uint16
+	for _, v := range vars {
+		if v.GUID != GlobalVariableGUID || len(v.Name) != 8 || !strings.HasPrefix(v.Name, "Boot") {
+			continue
+		}
+		n, err := strconv.ParseUint(v.Name[4:], 16, 16)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, uint16(n))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+	return entries, nil
+}
+
+// FreeBootEntry returns the lowest boot entry number not in use.
+func FreeBootEntry(e EFIVar) (uint16, error) {
+	entries, err := ListBootEntries(e)
+	if err != nil {
+		return 0, err
+	}
+	var n uint16
+	for _, used := range entries {
+		if used != n {
+			break
+		}
+		if n == 0xffff {
+			return 0, ErrNoFreeBootEntry
+		}
+		n++
+	}
+	return n, nil
+}
+
+func readUint16s(e EFIVar, name string) ([]uint16, error) {
+	_, data, err := ReadVariable(e, globalVar(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("%s has odd length %d", name, len(data))
+	}
+	v := make([]uint16, len(data)/2)
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return v, nil
+}
+
+func writeUint16s(e EFIVar, name string, v []uint16) error {
+	data := make([]byte, 2*len(v))
+	for i, n := range v {
+		binary.LittleEndian.PutUint16(data[2*i:], n)
+	}
+	return WriteVariable(e, globalVar(name), bootVarAttrs, data)
+}
+
+func readUint16(e EFIVar, name string) (uint16, error) {
+	v, err := readUint16s(e, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 1 {
+		return 0, fmt.Errorf("%s has length %d, want 2", name, 2*len(v))
+	}
+	return v[0], nil
+}
+
+// ReadBootOrder returns the BootOrder variable.
+func ReadBootOrder(e EFIVar) ([]uint16, error) {
+	return readUint16s(e, "BootOrder")
+}
+
+// WriteBootOrder sets the BootOrder variable.
+func WriteBootOrder(e EFIVar, order []uint16) error {
+	return writeUint16s(e, "BootOrder", order)
+}
+
+// ReadBootNext returns the BootNext variable.
+func ReadBootNext(e EFIVar) (uint16, error) {
+	return readUint16(e, "BootNext")
+}
+
+// WriteBootNext sets the BootNext variable, which selects the boot entry
+// used for the next boot only.
+func WriteBootNext(e EFIVar, n uint16) error {
+	return writeUint16s(e, "BootNext", []uint16{n})
+}
+
+// RemoveBootNext deletes the BootNext variable.
+func RemoveBootNext(e EFIVar) error {
+	return RemoveVariable(e, globalVar("BootNext"))
+}
+
+// ReadBootCurrent returns the BootCurrent variable, the boot entry used for
+// the current boot.
+func ReadBootCurrent(e EFIVar) (uint16, error) {
+	return readUint16(e, "BootCurrent")
+}
+
+// Device path node types, UEFI spec 2.10, section 10.3.
+const (
+	devPathTypeMedia        = 0x04
+	devPathTypeEnd          = 0x7f
+	devPathSubTypeHardDisk  = 0x01
+	devPathSubTypeFilePath  = 0x04
+	devPathSubTypeEndEntire = 0xff
+)
+
+// Partition formats and signature types of a hard drive media device path.
+const (
+	PartitionFormatMBR = 0x01
+	PartitionFormatGPT = 0x02
+
+	SignatureTypeNone = 0x00
+	SignatureTypeMBR  = 0x01
+	SignatureTypeGUID = 0x02
+)
+
+func devPathNode(typ, subType uint8, data []byte) []byte {
+	b := make([]byte, 4, 4+len(data))
+	b[0] = typ
+	b[1] = subType
+	binary.LittleEndian.PutUint16(b[2:], uint16(4+len(data)))
+	return append(b, data...)
+}
+
+// HardDriveDevicePath returns a hard drive media device path node for a GPT
+// partition.
+//
+// signature is the partition's unique GUID in its on-disk (mixed-endian)
+// encoding, start and size are in logical blocks.
+func HardDriveDevicePath(partNum uint32, start, size uint64, signature [16]byte) []byte {
+	data := make([]byte, 38)
+	binary.LittleEndian.PutUint32(data[0:], partNum)
+	binary.LittleEndian.PutUint64(data[4:], start)
+	binary.LittleEndian.PutUint64(data[12:], size)
+	copy(data[20:36], signature[:])
+	data[36] = PartitionFormatGPT
+	data[37] = SignatureTypeGUID
+	return devPathNode(devPathTypeMedia, devPathSubTypeHardDisk, data)
+}
+
+// FilePathDevicePath returns a file path media device path node.
+//
+// Forward slashes in path are converted to the backslashes firmware expects.
+func FilePathDevicePath(path string) []byte {
+	path = strings.ReplaceAll(path, "/", `\`)
+	return devPathNode(devPathTypeMedia, devPathSubTypeFilePath, encodeUCS2(path))
+}
+
+// EndDevicePath returns the node terminating a device path.
+func EndDevicePath() []byte {
+	return devPathNode(devPathTypeEnd, devPathSubTypeEndEntire, nil)
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// memVars is an in-memory EFIVar.
+type memVars map[VariableDescriptor][]byte
+
+func (m memVars) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	d, ok := m[desc]
+	if !ok {
+		return 0, nil, ErrVarNotExist
+	}
+	return bootVarAttrs, d, nil
+}
+
+func (m memVars) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	m[desc] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m memVars) Remove(desc VariableDescriptor) error {
+	if _, ok := m[desc]; !ok {
+		return ErrVarNotExist
+	}
+	delete(m, desc)
+	return nil
+}
+
+func (m memVars) List() ([]VariableDescriptor, error) {
+	var l []VariableDescriptor
+	for d := range m {
+		l = append(l, d)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l, nil
+}
+
+var _ EFIVar = memVars{}
+
+func TestLoadOptionRoundTrip(t *testing.T) {
+	var sig [16]byte
+	copy(sig[:], "0123456789abcdef")
+	var path []byte
+	path = append(path, HardDriveDevicePath(1, 2048, 1048576, sig)...)
+	path = append(path, FilePathDevicePath("/EFI/BOOT/BOOTX64.EFI")...)
+	path = append(path, EndDevicePath()...)
+
+	want := &LoadOption{
+		Attributes:   LoadOptionActive,
+		Description:  "Linux Boot Manager ✓",
+		FilePathList: path,
+		OptionalData: []byte("console=ttyS0"),
+	}
+	b, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &LoadOption{}
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalBinary(MarshalBinary(%+v)) = %+v", want, got)
+	}
+
+	// HD node is 42 bytes, file path node is 4 + 2*(21+1), end node is 4.
+	if len(path) != 42+48+4 {
+		t.Errorf("device path length = %d, want %d", len(path), 42+48+4)
+	}
+	if !bytes.Contains(path, []byte{'\\', 0, 'E', 0, 'F', 0, 'I', 0}) {
+		t.Errorf("file path node %x does not use backslashes", path)
+	}
+}
+
+func TestLoadOptionUnmarshalErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{1, 0, 0, 0, 0},
+		// No description terminator.
+		{1, 0, 0, 0, 0, 0, 'a', 0},
+		// File path list longer than remaining data.
+		{1, 0, 0, 0, 8, 0, 'a', 0, 0, 0, 1, 2},
+	} {
+		if err := (&LoadOption{}).UnmarshalBinary(data); !errors.Is(err, ErrBadLoadOption) {
+			t.Errorf("UnmarshalBinary(%x) = %v, want %v", data, err, ErrBadLoadOption)
+		}
+	}
+}
+
+func TestBootEntries(t *testing.T) {
+	e := memVars{}
+	opt := &LoadOption{Attributes: LoadOptionActive, Description: "test", FilePathList: EndDevicePath()}
+
+	for i := 0; i < 3; i++ {
+		n, err := FreeBootEntry(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != uint16(i) {
+			t.Errorf("FreeBootEntry() = %d, want %d", n, i)
+		}
+		if err := WriteBootEntry(e, n, opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Unrelated variables must be ignored.
+	e[VariableDescriptor{Name: "BootOrder", GUID: GlobalVariableGUID}] = []byte{2, 0, 0, 0, 1, 0}
+	e[VariableDescriptor{Name: "Boot0007", GUID: fakeGUID}] = []byte{}
+
+	entries, err := ListBootEntries(e)
+	if err != nil || !reflect.DeepEqual(entries, []uint16{0, 1, 2}) {
+		t.Errorf("ListBootEntries() = %v, %v, want [0 1 2], nil", entries, err)
+	}
+
+	got, err := ReadBootEntry(e, 1)
+	if err != nil || got.Description != "test" {
+		t.Errorf("ReadBootEntry(1) = %+v, %v", got, err)
+	}
+
+	if err := WriteBootNext(e, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ReadBootNext(e); err != nil || n != 1 {
+		t.Errorf("ReadBootNext() = %d, %v, want 1, nil", n, err)
+	}
+
+	if err := RemoveBootEntry(e, 1); err != nil {
+		t.Fatalf("RemoveBootEntry(1) = %v", err)
+	}
+	if _, err := ReadBootEntry(e, 1); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("ReadBootEntry(1) after removal = %v, want %v", err, ErrVarNotExist)
+	}
+	if order, err := ReadBootOrder(e); err != nil || !reflect.DeepEqual(order, []uint16{2, 0}) {
+		t.Errorf("ReadBootOrder() = %v, %v, want [2 0], nil", order, err)
+	}
+	if _, err := ReadBootNext(e); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("ReadBootNext() after removal = %v, want %v", err, ErrVarNotExist)
+	}
+	if n, err := FreeBootEntry(e); err != nil || n != 1 {
+		t.Errorf("FreeBootEntry() = %d, %v, want 1, nil", n, err)
+	}
+}
@@ -4,7 +4,8 @@
 
 // Package find searches for files in a directory hierarchy recursively.
 //
-// find can filter out files by file names, paths, and modes.
+// find can filter out files by file names, paths, modes, and arbitrary
+// predicates, and limit how deep it descends.
 package find
 
 import (
@@ -13,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/ls"
 )
@@ -52,6 +54,14 @@ type finder struct {
 	debug      func(string, ...interface{})
 	files      chan *File
 	sendErrors bool
+
+	// maxDepth is the deepest level below root that is visited, or -1.
+	maxDepth int
+	// prune skips matching files and directories, with all they
+	// contain, and returns the other files instead.
+	prune bool
+	// filters must all accept a file for it to match.
+	filters []func(*File) bool
 }
 
 type Set func(*finder)
@@ -111,6 +121,41 @@ func WithModeMatch(mode, modeMask os.FileMode) Set {
 	}
 }
 
+// WithMaxDepth descends at most depth levels below the root. A depth of 0
+// only considers the root itself.
+func WithMaxDepth(depth int) Set {
+	return func(f *finder) {
+		f.maxDepth = depth
+	}
+}
+
+// WithPrune skips matching files and does not descend into matching
+// directories; all other files are returned instead, like
+// find -name X -prune -o -print.
+func WithPrune() Set {
+	return func(f *finder) {
+		f.prune = true
+	}
+}
+
+// WithFilter adds a filter that must accept a file for it to be returned.
+// Filters are only called for files without errors, after the name and mode
+// matched.
+func WithFilter(filter func(*File) bool) Set {
+	return func(f *finder) {
+		f.filters = append(f.filters, filter)
+	}
+}
+
+// depth returns the number of path elements of n below root.
+func depth(root, n string) int {
+	rel, err := filepath.Rel(root, n)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
 // WithDebugLog logs messages to l.
 func WithDebugLog(l func(string, ...interface{})) Set {
 	return func(f *finder) {
@@ -134,6 +179,7 @@ func Find(ctx context.Context, opt ...Set) <-chan *File {
 		files:      make(chan *File, 128),
 		match:      filepath.Match,
 		sendErrors: true,
+		maxDepth:   -1,
 	}
 
 	for _, o := range opt {
@@ -154,26 +200,29 @@ func Find(ctx context.Context, opt ...Set) <-chan *File {
 				FileInfo: fi,
 				Err:      err,
 			}
+			// skip is returned once the file has been considered, to
+			// stop the walk from descending further.
+			var skip error
+			if err == nil && fi.IsDir() && f.maxDepth >= 0 && depth(f.root, n) >= f.maxDepth {
+				skip = filepath.SkipDir
+			}
 			if err == nil {
 				// If it matches, then push its name into the result channel,
 				// and keep looking.
-				f.debug("check pattern %q against name %q", f.pattern, n)
-				if f.pattern != "" {
-					m, err := f.match(f.pattern, n)
-					if err != nil {
-						f.debug("%s: err on matching: %v", n, err)
-						return nil
-					}
-					if !m {
-						f.debug("%s: name does not match %q", n, f.pattern)
-						return nil
-					}
+				m, err := f.matches(file)
+				if err != nil {
+					f.debug("%s: err on matching: %v", n, err)
+					return nil
 				}
-				m := fi.Mode()
-				f.debug("%s: file mode %v / want mode %s with mask %s", n, m, f.mode, f.modeMask)
-				if masked := m & f.modeMask; masked != f.mode {
-					f.debug("%s: mode %s (masked %s) does not match expected mode %s", n, m, masked, f.mode)
+				switch {
+				case f.prune && m:
+					f.debug("%s: pruned", n)
+					if fi.IsDir() {
+						return filepath.SkipDir
+					}
 					return nil
+				case !f.prune && !m:
+					return skip
 				}
 				f.debug("Found: %s", n)
 			}
@@ -182,7 +231,7 @@ func Find(ctx context.Context, opt ...Set) <-chan *File {
 				return fmt.Errorf("should never be returned to user: stop walking")
 
 			case f.files <- file:
-				return nil
+				return skip
 			}
 		})
 		close(f.files)
@@ -190,3 +239,32 @@ func Find(ctx context.Context, opt ...Set) <-chan *File {
 
 	return f.files
 }
+
+// matches reports whether file matches the pattern, mode and filters of f.
+func (f *finder) matches(file *File) (bool, error) {
+	n := file.Name
+	f.debug("check pattern %q against name %q", f.pattern, n)
+	if f.pattern != "" {
+		m, err := f.match(f.pattern, n)
+		if err != nil {
+			return false, err
+		}
+		if !m {
+			f.debug("%s: name does not match %q", n, f.pattern)
+			return false, nil
+		}
+	}
+	m := file.Mode()
+	f.debug("%s: file mode %v / want mode %s with mask %s", n, m, f.mode, f.modeMask)
+	if masked := m & f.modeMask; masked != f.mode {
+		f.debug("%s: mode %s (masked %s) does not match expected mode %s", n, m, masked, f.mode)
+		return false, nil
+	}
+	for _, filter := range f.filters {
+		if !filter(file) {
+			f.debug("%s: rejected by filter", n)
+			return false, nil
+		}
+	}
+	return true, nil
+}
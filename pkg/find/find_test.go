@@ -76,6 +76,38 @@ func TestSimple(t *testing.T) {
 			opts:  []Set{WithRegexPathMatch("file")},
 			names: []string{"/root/xyz/file"},
 		},
+		{
+			name:  "max depth",
+			opts:  []Set{WithMaxDepth(2)},
+			names: []string{"", "/root", "/root/xyz"},
+		},
+		{
+			name:  "max depth of root",
+			opts:  []Set{WithMaxDepth(0)},
+			names: []string{""},
+		},
+		{
+			name:  "prune directories",
+			opts:  []Set{WithModeMatch(os.ModeDir, os.ModeDir), WithPrune()},
+			names: nil,
+		},
+		{
+			name:  "prune by name",
+			opts:  []Set{WithFilenameMatch("xyz"), WithPrune()},
+			names: []string{"", "/root"},
+		},
+		{
+			name:  "prune files",
+			opts:  []Set{WithFilenameMatch("0777"), WithPrune()},
+			names: []string{"", "/root", "/root/xyz", "/root/xyz/file"},
+		},
+		{
+			name: "filter",
+			opts: []Set{WithFilter(func(f *File) bool {
+				return f.Mode().Perm() == 0o444
+			})},
+			names: []string{"/root/xyz/0777"},
+		},
 	}
 	d := t.TempDir()
 
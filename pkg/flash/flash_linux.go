@@ -250,9 +250,19 @@ func (f *Flash) WriteAt(p []byte, off int64) (int, error) {
 	return len(p), nil
 }
 
+// ProgramAt erases and writes the sectors that differ from p, and verifies
+// them.
 func (f *Flash) ProgramAt(p []byte, off int64) (int, error) {
-	// TODO
-	return 0, nil
+	n, err := Program(f, p, off)
+	if err != nil {
+		return n, err
+	}
+	return n, Verify(f, p, off)
+}
+
+// EraseSize returns the smallest size EraseAt can erase.
+func (f *Flash) EraseSize() int64 {
+	return f.SectorSize
 }
 
 // EraseAt erases n bytes from offset off. Both parameters must be aligned to
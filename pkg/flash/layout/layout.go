@@ -0,0 +1,170 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package layout describes the regions of a flash image, either from a
+// flashrom layout file or from the Intel flash descriptor in the image.
+package layout
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoDescriptor is returned if an image has no Intel flash descriptor.
+	ErrNoDescriptor = errors.New("no Intel flash descriptor")
+	// ErrNoRegion is returned if a region is not in a layout.
+	ErrNoRegion = errors.New("no such region")
+)
+
+// Region is a named range of a flash image. End is inclusive, as in
+// flashrom layout files.
+type Region struct {
+	Name  string
+	Start int64
+	End   int64
+}
+
+// Size returns the size of the region in bytes.
+func (r Region) Size() int64 {
+	return r.End - r.Start + 1
+}
+
+// String formats the region as a line of a layout file.
+func (r Region) String() string {
+	return fmt.Sprintf("%08x:%08x %s", r.Start, r.End, r.Name)
+}
+
+// Layout is the list of regions of a flash image.
+type Layout []Region
+
+// Parse parses a flashrom layout file, with lines of the form
+//
+//	00000000:00000fff fd
+//
+// Empty lines and lines starting with # are ignored.
+func Parse(r io.Reader) (Layout, error) {
+	var l Layout
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("line %d: %q is not of the form start:end name", n, line)
+		}
+		start, end, ok := strings.Cut(f[0], ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q is not a range start:end", n, f[0])
+		}
+		var reg Region
+		var err error
+		if reg.Start, err = strconv.ParseInt(strings.TrimPrefix(start, "0x"), 16, 64); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if reg.End, err = strconv.ParseInt(strings.TrimPrefix(end, "0x"), 16, 64); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if reg.End < reg.Start {
+			return nil, fmt.Errorf("line %d: region %q ends before it starts", n, f[1])
+		}
+		reg.Name = f[1]
+		l = append(l, reg)
+	}
+	return l, s.Err()
+}
+
+// descriptorSignature marks an Intel flash descriptor, at offset 0x10 of
+// the image, or at 0 on old chipsets.
+const descriptorSignature = 0x0ff0a55a
+
+// ifdRegions are the names flashrom gives the regions of the descriptor.
+var ifdRegions = []string{
+	"fd", "bios", "me", "gbe", "pd", "reg5", "bios2", "reg7",
+	"ec", "reg9", "ie", "10gbe0", "10gbe1", "reg13", "reg14", "ptt",
+}
+
+// FromIFD reads the layout from the Intel flash descriptor of an image.
+//
+// The number of regions depends on the chipset. Region registers beyond
+// those of older chipsets read as unused on real images, and regions that
+// are unused or lie outside the image are left out.
+func FromIFD(image io.ReaderAt, size int64) (Layout, error) {
+	var b [4]byte
+	var sig int64 = -1
+	for _, off := range []int64{0x10, 0} {
+		if _, err := image.ReadAt(b[:], off); err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint32(b[:]) == descriptorSignature {
+			sig = off
+			break
+		}
+	}
+	if sig < 0 {
+		return nil, ErrNoDescriptor
+	}
+	if _, err := image.ReadAt(b[:], sig+4); err != nil {
+		return nil, err
+	}
+	// FLMAP0 holds the flash region base address in bits 16-23.
+	frba := int64(b[2]) << 4
+
+	var l Layout
+	for i, name := range ifdRegions {
+		if _, err := image.ReadAt(b[:], frba+4*int64(i)); err != nil {
+			return nil, fmt.Errorf("region %s: %w", name, err)
+		}
+		flreg := binary.LittleEndian.Uint32(b[:])
+		reg := Region{
+			Name:  name,
+			Start: int64(flreg&0x7fff) << 12,
+			End:   int64(flreg>>16&0x7fff)<<12 | 0xfff,
+		}
+		if reg.Start > reg.End || reg.End >= size {
+			continue
+		}
+		l = append(l, reg)
+	}
+	return l, nil
+}
+
+// Select returns the named regions, in the order given.
+func (l Layout) Select(names ...string) (Layout, error) {
+	var sel Layout
+	for _, name := range names {
+		r, ok := l.Find(name)
+		if !ok {
+			return nil, fmt.Errorf("%q: %w", name, ErrNoRegion)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// Find returns the region with a name.
+func (l Layout) Find(name string) (Region, bool) {
+	for _, r := range l {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// String formats the layout as a layout file.
+func (l Layout) String() string {
+	var s strings.Builder
+	for _, r := range l {
+		fmt.Fprintln(&s, r)
+	}
+	return s.String()
+}
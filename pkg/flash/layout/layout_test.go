@@ -0,0 +1,119 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package layout
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		want Layout
+		err  bool
+	}{
+		{
+			name: "flashrom",
+			in:   "# layout\n00000000:00000fff fd\n\n00001000:001fffff me\n0x200000:0x3fffff bios\n",
+			want: Layout{
+				{Name: "fd", Start: 0, End: 0xfff},
+				{Name: "me", Start: 0x1000, End: 0x1fffff},
+				{Name: "bios", Start: 0x200000, End: 0x3fffff},
+			},
+		},
+		{name: "no name", in: "00000000:00000fff\n", err: true},
+		{name: "no range", in: "00000000 fd\n", err: true},
+		{name: "bad hex", in: "0000000g:00000fff fd\n", err: true},
+		{name: "backwards", in: "00001000:00000fff fd\n", err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.in))
+			if (err != nil) != tt.err {
+				t.Fatalf("Parse = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	l := Layout{{Name: "fd", Start: 0, End: 0xfff}, {Name: "bios", Start: 0x1000, End: 0xffff}}
+	want := "00000000:00000fff fd\n00001000:0000ffff bios\n"
+	if got := l.String(); got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	back, err := Parse(strings.NewReader(want))
+	if err != nil || !reflect.DeepEqual(back, l) {
+		t.Errorf("Parse(String) = %v, %v, want %v", back, err, l)
+	}
+}
+
+// ifdImage returns a 1 MiB image with a descriptor, an ME and a BIOS
+// region.
+func ifdImage() []byte {
+	img := bytes.Repeat([]byte{0xff}, 1<<20)
+	le := binary.LittleEndian
+	le.PutUint32(img[0x10:], descriptorSignature)
+	// FLMAP0 with FRBA at 0x40.
+	le.PutUint32(img[0x14:], 0x04<<16)
+	flreg := func(base, limit uint32) uint32 { return limit>>12<<16 | base>>12 }
+	le.PutUint32(img[0x40:], flreg(0, 0xfff))
+	le.PutUint32(img[0x44:], flreg(0x80000, 0xfffff))
+	le.PutUint32(img[0x48:], flreg(0x1000, 0x7ffff))
+	// Unused regions.
+	for i := 3; i < len(ifdRegions); i++ {
+		le.PutUint32(img[0x40+4*i:], flreg(0x7fff000, 0))
+	}
+	return img
+}
+
+func TestFromIFD(t *testing.T) {
+	img := ifdImage()
+	l, err := FromIFD(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Layout{
+		{Name: "fd", Start: 0, End: 0xfff},
+		{Name: "bios", Start: 0x80000, End: 0xfffff},
+		{Name: "me", Start: 0x1000, End: 0x7ffff},
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("FromIFD = %v, want %v", l, want)
+	}
+
+	// A smaller chip cannot hold the BIOS region.
+	l, err = FromIFD(bytes.NewReader(img), 0x80000)
+	if err != nil || len(l) != 2 {
+		t.Errorf("FromIFD of a 512 KiB chip = %v, %v, want fd and me", l, err)
+	}
+
+	blank := make([]byte, 0x1000)
+	if _, err := FromIFD(bytes.NewReader(blank), int64(len(blank))); !errors.Is(err, ErrNoDescriptor) {
+		t.Errorf("FromIFD of a blank image = %v, want %v", err, ErrNoDescriptor)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	l := Layout{{Name: "fd", End: 0xfff}, {Name: "bios", Start: 0x1000, End: 0xffff}}
+	got, err := l.Select("bios", "fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Layout{l[1], l[0]}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Select = %v, want %v", got, want)
+	}
+	if _, err := l.Select("me"); !errors.Is(err, ErrNoRegion) {
+		t.Errorf("Select(me) = %v, want %v", err, ErrNoRegion)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mtd reads, writes and erases flash through Linux MTD character
+// devices, such as the one the intel-spi driver creates for the BIOS flash.
+package mtd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNotFound is returned by Find if no MTD device has the name.
+var ErrNotFound = errors.New("no MTD device found")
+
+// IntelSPI is the name intel-spi gives the MTD device of the BIOS flash.
+const IntelSPI = "BIOS"
+
+var sysfsMTD = "/sys/class/mtd"
+
+// info is struct mtd_info_user.
+type info struct {
+	Type      uint8
+	_         [3]uint8
+	Flags     uint32
+	Size      uint32
+	EraseSize uint32
+	WriteSize uint32
+	OOBSize   uint32
+	_         uint64
+}
+
+// eraseInfo is struct erase_info_user.
+type eraseInfo struct {
+	Start  uint32
+	Length uint32
+}
+
+// Dev is an MTD character device.
+type Dev struct {
+	f    *os.File
+	info info
+}
+
+// Open opens an MTD character device, e.g. /dev/mtd0.
+func Open(path string) (*Dev, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dev{f: f}
+	if err := d.ioctl(unix.MEMGETINFO, unsafe.Pointer(&d.info)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, os.NewSyscallError("ioctl(MEMGETINFO)", err))
+	}
+	return d, nil
+}
+
+// Find returns the path of the MTD character device with a name, as in
+// /sys/class/mtd/mtdN/name.
+func Find(name string) (string, error) {
+	names, err := filepath.Glob(filepath.Join(sysfsMTD, "mtd*", "name"))
+	if err != nil {
+		return "", err
+	}
+	for _, n := range names {
+		b, err := os.ReadFile(n)
+		if err != nil || strings.TrimSpace(string(b)) != name {
+			continue
+		}
+		// mtdNro is the read-only twin of mtdN.
+		dev := filepath.Base(filepath.Dir(n))
+		if strings.HasSuffix(dev, "ro") {
+			continue
+		}
+		return filepath.Join("/dev", dev), nil
+	}
+	return "", fmt.Errorf("%q: %w", name, ErrNotFound)
+}
+
+func (d *Dev) ioctl(req uint, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, d.f.Fd(), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Size returns the size of the device in bytes.
+func (d *Dev) Size() int64 {
+	return int64(d.info.Size)
+}
+
+// EraseSize returns the size of an erase block.
+func (d *Dev) EraseSize() int64 {
+	return int64(d.info.EraseSize)
+}
+
+// ReadAt reads from the device.
+func (d *Dev) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= d.Size() {
+		return 0, io.EOF
+	}
+	return d.f.ReadAt(p[:min(int64(len(p)), d.Size()-off)], off)
+}
+
+// WriteAt writes to the device. The range has to be erased first.
+func (d *Dev) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > d.Size() {
+		return 0, fmt.Errorf("writing %#x bytes at %#x past the end at %#x: %w", len(p), off, d.Size(), os.ErrInvalid)
+	}
+	return d.f.WriteAt(p, off)
+}
+
+// EraseAt erases n bytes at off. Both have to be aligned to EraseSize.
+func (d *Dev) EraseAt(n int64, off int64) (int64, error) {
+	bs := d.EraseSize()
+	if off < 0 || off+n > d.Size() || off%bs != 0 || n%bs != 0 {
+		return 0, fmt.Errorf("erasing %#x bytes at %#x, not aligned to %#x or past %#x: %w", n, off, bs, d.Size(), os.ErrInvalid)
+	}
+	e := eraseInfo{Start: uint32(off), Length: uint32(n)}
+	if err := d.ioctl(unix.MEMERASE, unsafe.Pointer(&e)); err != nil {
+		return 0, os.NewSyscallError("ioctl(MEMERASE)", err)
+	}
+	return n, nil
+}
+
+// Close closes the device.
+func (d *Dev) Close() error {
+	return d.f.Close()
+}
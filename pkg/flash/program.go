@@ -0,0 +1,85 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flash
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrVerify is returned if the contents of a flash device differ from what
+// was written.
+var ErrVerify = errors.New("verification failed")
+
+// Device is a flash device that can be erased in blocks of EraseSize.
+type Device interface {
+	io.ReaderAt
+	io.WriterAt
+	EraseAt(n int64, off int64) (int64, error)
+	EraseSize() int64
+}
+
+// erased is the value of erased flash bytes.
+const erased = 0xff
+
+// Program writes p to the device at off. Only the erase blocks that differ
+// from p are erased and written; the parts of the first and last block
+// outside of p are preserved. Call Verify to check the result.
+func Program(d Device, p []byte, off int64) (int, error) {
+	bs := d.EraseSize()
+	if bs <= 0 {
+		return 0, fmt.Errorf("invalid erase size %d", bs)
+	}
+	start := off / bs * bs
+	end := (off + int64(len(p)) + bs - 1) / bs * bs
+
+	old := make([]byte, bs)
+	for blk := start; blk < end; blk += bs {
+		if _, err := d.ReadAt(old, blk); err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("reading block %#x: %w", blk, err)
+		}
+		// Overlay the new contents on the current ones.
+		cur := append([]byte(nil), old...)
+		lo, hi := max(off, blk), min(off+int64(len(p)), blk+bs)
+		copy(cur[lo-blk:hi-blk], p[lo-off:hi-off])
+		if bytes.Equal(cur, old) {
+			continue
+		}
+		if _, err := d.EraseAt(bs, blk); err != nil {
+			return int(max(blk-off, 0)), fmt.Errorf("erasing block %#x: %w", blk, err)
+		}
+		if !isErased(cur) {
+			if _, err := d.WriteAt(cur, blk); err != nil {
+				return int(max(blk-off, 0)), fmt.Errorf("writing block %#x: %w", blk, err)
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Verify compares the contents of the device at off with p.
+func Verify(d io.ReaderAt, p []byte, off int64) error {
+	got := make([]byte, len(p))
+	if _, err := d.ReadAt(got, off); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading back %#x bytes at %#x: %w", len(p), off, err)
+	}
+	for i := range p {
+		if got[i] != p[i] {
+			return fmt.Errorf("at %#x: got %#02x, want %#02x: %w", off+int64(i), got[i], p[i], ErrVerify)
+		}
+	}
+	return nil
+}
+
+func isErased(p []byte) bool {
+	for _, b := range p {
+		if b != erased {
+			return false
+		}
+	}
+	return true
+}
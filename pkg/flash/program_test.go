@@ -0,0 +1,92 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// mem is flash in memory, which like NOR flash can only clear bits when
+// written.
+type mem struct {
+	data   []byte
+	erases []int64
+	// stuck is a byte that can not be written.
+	stuck int64
+}
+
+func newMem(size int) *mem {
+	return &mem{data: bytes.Repeat([]byte{0xff}, size), stuck: -1}
+}
+
+func (m *mem) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	return copy(p, m.data[off:]), nil
+}
+
+func (m *mem) WriteAt(p []byte, off int64) (int, error) {
+	for i, b := range p {
+		if off+int64(i) != m.stuck {
+			m.data[off+int64(i)] &= b
+		}
+	}
+	return len(p), nil
+}
+
+func (m *mem) EraseAt(n, off int64) (int64, error) {
+	m.erases = append(m.erases, off)
+	copy(m.data[off:off+n], bytes.Repeat([]byte{0xff}, int(n)))
+	return n, nil
+}
+
+func (m *mem) EraseSize() int64 {
+	return 16
+}
+
+func TestProgram(t *testing.T) {
+	m := newMem(64)
+	copy(m.data, bytes.Repeat([]byte{0x55}, 64))
+
+	// Change bytes 20-35, keeping 16-19, so blocks 16 and 32 differ.
+	p := bytes.Repeat([]byte{0x55}, 20)
+	copy(p[4:], bytes.Repeat([]byte{0xaa}, 14))
+	if n, err := Program(m, p, 16); err != nil || n != len(p) {
+		t.Fatalf("Program = %d, %v, want %d, nil", n, err, len(p))
+	}
+	if want := []int64{16, 32}; len(m.erases) != 2 || m.erases[0] != want[0] || m.erases[1] != want[1] {
+		t.Errorf("erased blocks %v, want %v", m.erases, want)
+	}
+	if err := Verify(m, p, 16); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	// The rest of the blocks is preserved.
+	if m.data[15] != 0x55 || m.data[36] != 0x55 {
+		t.Errorf("bytes around the write are %#x and %#x, want 0x55", m.data[15], m.data[36])
+	}
+
+	// Programming the same contents again does nothing.
+	m.erases = nil
+	if _, err := Program(m, p, 16); err != nil || m.erases != nil {
+		t.Errorf("Program again erased %v, %v, want no erases", m.erases, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := newMem(32)
+	m.stuck = 5
+	copy(m.data, bytes.Repeat([]byte{0}, 32))
+	p := bytes.Repeat([]byte{0x12}, 32)
+	if _, err := Program(m, p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(m, p, 0); !errors.Is(err, ErrVerify) {
+		t.Errorf("Verify = %v, want %v", err, ErrVerify)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fts walks file trees to change or remove them, safe from files
+// being swapped for symlinks while they are walked, as in trees others
+// may write to.
+//
+// On Linux, directories are opened relative to their parents, without
+// following symlinks, and checked to be the directories that were found,
+// and files are changed and removed relative to their directory with the
+// *at system calls. No path is resolved again once it was walked, so that
+// symlinks swapped in cannot redirect changes outside the tree, and deep
+// trees do not cost lookups of long paths.
+//
+// Elsewhere, files are changed and removed by path.
+package fts
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// SkipDir may be returned by a WalkFunc visiting a directory to skip its
+// contents.
+var SkipDir = fs.SkipDir
+
+// ErrChanged is returned when a directory is replaced while it is walked.
+var ErrChanged = errors.New("directory changed while walked")
+
+// Entry is a file visited by Walk.
+type Entry struct {
+	// Path is the path of the file, from the root of the walk.
+	Path string
+	// Name is the name of the file in its directory.
+	Name string
+	// Depth is 0 for the root of the walk, 1 for the files in it, and so
+	// on.
+	Depth int
+	// Post is set when a directory is visited after its contents.
+	Post bool
+
+	// Mode, UID and GID are those of the file, not following symlinks.
+	Mode fs.FileMode
+	UID  int
+	GID  int
+
+	entry
+}
+
+// IsDir reports whether e is a directory.
+func (e *Entry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// WalkFunc is called for each file Walk visits. Directories are visited
+// before their contents, and again after them with Post set. If a
+// directory cannot be read, it is visited after with the error, and its
+// contents are not.
+//
+// If the WalkFunc returns SkipDir for a directory visited before its
+// contents, they are skipped, along with the visit after them. Any other
+// error stops the walk.
+type WalkFunc func(e *Entry, err error) error
+
+// Walk walks the tree at root, calling fn for each file, and returns the
+// error fn returned, if any. Symlinks are visited, but not followed, even
+// at the root. Files in a directory are visited in lexical order.
+func Walk(root string, fn WalkFunc) error {
+	err := walk(root, fn)
+	if errors.Is(err, SkipDir) {
+		return nil
+	}
+	return err
+}
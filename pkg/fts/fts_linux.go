@@ -0,0 +1,214 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/sys/unix"
+)
+
+// entry is where a file is: its name in the directory open as dirfd.
+type entry struct {
+	dirfd int
+	dev   uint64
+	ino   uint64
+}
+
+func stat(dirfd int, name, path string, depth int) (*Entry, error) {
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	return &Entry{
+		Path:  path,
+		Name:  name,
+		Depth: depth,
+		Mode:  fileMode(st.Mode),
+		UID:   int(st.Uid),
+		GID:   int(st.Gid),
+		entry: entry{dirfd: dirfd, dev: uint64(st.Dev), ino: st.Ino},
+	}, nil
+}
+
+// fileMode converts a st_mode to an fs.FileMode, as os.Lstat does.
+func fileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & unix.S_IFMT {
+	case unix.S_IFBLK:
+		mode |= fs.ModeDevice
+	case unix.S_IFCHR:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case unix.S_IFDIR:
+		mode |= fs.ModeDir
+	case unix.S_IFIFO:
+		mode |= fs.ModeNamedPipe
+	case unix.S_IFLNK:
+		mode |= fs.ModeSymlink
+	case unix.S_IFSOCK:
+		mode |= fs.ModeSocket
+	}
+	if m&unix.S_ISGID != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&unix.S_ISUID != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&unix.S_ISVTX != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// unixMode converts an fs.FileMode to the mode bits chmod takes.
+func unixMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= unix.S_ISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= unix.S_ISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= unix.S_ISVTX
+	}
+	return m
+}
+
+func walk(root string, fn WalkFunc) error {
+	// The path to the root is trusted, as fts(3) trusts it.
+	e, err := stat(unix.AT_FDCWD, filepath.Clean(root), root, 0)
+	if err != nil {
+		return err
+	}
+	return visit(e, fn)
+}
+
+// visit visits e and, if it is a directory, its contents.
+func visit(e *Entry, fn WalkFunc) error {
+	if err := fn(e, nil); err != nil || !e.IsDir() {
+		if errors.Is(err, SkipDir) && e.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	e.Post = true
+	dir, names, err := e.open()
+	if err != nil {
+		return fn(e, err)
+	}
+	defer dir.Close()
+	fd := int(dir.Fd())
+	for _, name := range names {
+		child, err := stat(fd, name, filepath.Join(e.Path, name), e.Depth+1)
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed since it was listed.
+			continue
+		}
+		if err != nil {
+			if err := fn(&Entry{Path: filepath.Join(e.Path, name), Name: name, Depth: e.Depth + 1, entry: entry{dirfd: fd}}, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(child, fn); err != nil {
+			return err
+		}
+	}
+	return fn(e, nil)
+}
+
+// open opens the directory e without following symlinks, checks it is
+// still the one visited, and lists it.
+func (e *Entry) open() (*os.File, []string, error) {
+	fd, err := unix.Openat(e.dirfd, e.Name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: e.Path, Err: err}
+	}
+	dir := os.NewFile(uintptr(fd), e.Path)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		dir.Close()
+		return nil, nil, &os.PathError{Op: "fstat", Path: e.Path, Err: err}
+	}
+	if uint64(st.Dev) != e.dev || st.Ino != e.ino {
+		dir.Close()
+		return nil, nil, &os.PathError{Op: "open", Path: e.Path, Err: ErrChanged}
+	}
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		dir.Close()
+		return nil, nil, err
+	}
+	sort.Strings(names)
+	return dir, names, nil
+}
+
+// Remove removes e, which must be empty if it is a directory.
+func (e *Entry) Remove() error {
+	flags := 0
+	if e.IsDir() {
+		flags = unix.AT_REMOVEDIR
+	}
+	if err := unix.Unlinkat(e.dirfd, e.Name, flags); err != nil {
+		return &os.PathError{Op: "remove", Path: e.Path, Err: err}
+	}
+	return nil
+}
+
+// Chown changes the owner and group of e, not following symlinks. An id of
+// -1 is not changed.
+func (e *Entry) Chown(uid, gid int) error {
+	if err := unix.Fchownat(e.dirfd, e.Name, uid, gid, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &os.PathError{Op: "chown", Path: e.Path, Err: err}
+	}
+	return nil
+}
+
+// Chmod changes the mode of e, which must not be a symlink.
+func (e *Entry) Chmod(mode fs.FileMode) error {
+	m := unixMode(mode)
+	err := unix.Fchmodat(e.dirfd, e.Name, m, unix.AT_SYMLINK_NOFOLLOW)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EPERM) {
+		// Without fchmodat2, or with it refused, change the file
+		// opened without following symlinks.
+		err = e.chmodPath(m)
+	}
+	if err != nil {
+		return &os.PathError{Op: "chmod", Path: e.Path, Err: err}
+	}
+	return nil
+}
+
+// chmodPath changes the mode of e through an O_PATH descriptor.
+func (e *Entry) chmodPath(m uint32) error {
+	fd, err := unix.Openat(e.dirfd, e.Name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return unix.ELOOP
+	}
+	if uint64(st.Dev) != e.dev || st.Ino != e.ino {
+		return ErrChanged
+	}
+	err = unix.Chmod(fmt.Sprintf("/proc/self/fd/%d", fd), m)
+	if errors.Is(err, unix.ENOENT) {
+		// Without /proc, there is nothing but the name.
+		return unix.Fchmodat(e.dirfd, e.Name, m, 0)
+	}
+	return err
+}
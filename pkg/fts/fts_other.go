@@ -0,0 +1,88 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package fts
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// entry is where a file is, by path.
+type entry struct{}
+
+func stat(path string, depth int) (*Entry, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	uid, gid := owner(fi)
+	return &Entry{Path: path, Name: filepath.Base(path), Depth: depth, Mode: fi.Mode(), UID: uid, GID: gid}, nil
+}
+
+func walk(root string, fn WalkFunc) error {
+	e, err := stat(root, 0)
+	if err != nil {
+		return err
+	}
+	return visit(e, fn)
+}
+
+func visit(e *Entry, fn WalkFunc) error {
+	if err := fn(e, nil); err != nil || !e.IsDir() {
+		if errors.Is(err, SkipDir) && e.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	e.Post = true
+	dir, err := os.Open(e.Path)
+	if err != nil {
+		return fn(e, err)
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return fn(e, err)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child, err := stat(filepath.Join(e.Path, name), e.Depth+1)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			if err := fn(&Entry{Path: filepath.Join(e.Path, name), Name: name, Depth: e.Depth + 1}, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(child, fn); err != nil {
+			return err
+		}
+	}
+	return fn(e, nil)
+}
+
+// Remove removes e, which must be empty if it is a directory.
+func (e *Entry) Remove() error {
+	return os.Remove(e.Path)
+}
+
+// Chown changes the owner and group of e, not following symlinks. An id of
+// -1 is not changed.
+func (e *Entry) Chown(uid, gid int) error {
+	return os.Lchown(e.Path, uid, gid)
+}
+
+// Chmod changes the mode of e, which must not be a symlink.
+func (e *Entry) Chmod(mode fs.FileMode) error {
+	return os.Chmod(e.Path, mode)
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tree makes a tree with a directory, files and a symlink out of it.
+func tree(t *testing.T) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, d := range []string{"a", "a/b"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"a/b/f", "a/g", "h", filepath.Join(outside, "secret")} {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(root, f)
+		}
+		if err := os.WriteFile(f, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "a/link")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestWalk(t *testing.T) {
+	root, _ := tree(t)
+	for _, tt := range []struct {
+		name string
+		skip string
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{".", "a", "a/b", "a/b/f", "post a/b", "a/g", "a/link", "post a", "h", "post ."},
+		},
+		{
+			name: "skip dir",
+			skip: "a/b",
+			want: []string{".", "a", "a/b", "a/g", "a/link", "post a", "h", "post ."},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Walk(root, func(e *Entry, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(root, e.Path)
+				if err != nil {
+					return err
+				}
+				if e.Post {
+					rel = "post " + rel
+				}
+				got = append(got, rel)
+				if rel == tt.skip {
+					return SkipDir
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Walk visited %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	root, outside := tree(t)
+	err := Walk(root, func(e *Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() && !e.Post {
+			return nil
+		}
+		return e.Remove()
+	})
+	if err != nil {
+		t.Fatalf("Walk = %v", err)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed: %v", root, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+		t.Errorf("file outside the tree was removed: %v", err)
+	}
+}
+
+func TestChmod(t *testing.T) {
+	root, outside := tree(t)
+	fi, err := os.Stat(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsideMode := fi.Mode().Perm()
+	err = Walk(root, func(e *Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.Mode&os.ModeSymlink != 0 || e.Post {
+			return nil
+		}
+		return e.Chmod(e.Mode.Perm() | 0o070)
+	})
+	if err != nil {
+		t.Fatalf("Walk = %v", err)
+	}
+	for _, tt := range []struct {
+		path string
+		want os.FileMode
+	}{
+		{path: filepath.Join(root, "a"), want: 0o775},
+		{path: filepath.Join(root, "a/b/f"), want: 0o674},
+		{path: filepath.Join(root, "h"), want: 0o674},
+		{path: outside, want: outsideMode},
+		{path: filepath.Join(outside, "secret"), want: 0o644},
+	} {
+		fi, err := os.Stat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != tt.want {
+			t.Errorf("mode of %s = %o, want %o", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestChown(t *testing.T) {
+	root, _ := tree(t)
+	uid, gid := os.Getuid(), os.Getgid()
+	var n int
+	err := Walk(root, func(e *Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.UID != uid || e.GID != gid {
+			return fmt.Errorf("%s owned by %d:%d, want %d:%d", e.Path, e.UID, e.GID, uid, gid)
+		}
+		n++
+		return e.Chown(uid, gid)
+	})
+	if err != nil {
+		t.Fatalf("Walk = %v", err)
+	}
+	if n == 0 {
+		t.Errorf("Walk visited nothing")
+	}
+}
+
+func TestWalkNotExist(t *testing.T) {
+	err := Walk(filepath.Join(t.TempDir(), "missing"), func(*Entry, error) error { return nil })
+	if !os.IsNotExist(err) {
+		t.Errorf("Walk = %v, want not exist", err)
+	}
+}
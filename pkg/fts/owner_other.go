@@ -0,0 +1,13 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+
+package fts
+
+import "os"
+
+func owner(os.FileInfo) (int, int) {
+	return -1, -1
+}
@@ -0,0 +1,401 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeBMC answers commands from its SEL, SDR repository, sensor readings and
+// FRU data.
+type fakeBMC struct {
+	sel      [][]byte
+	sdr      [][]byte
+	readings map[uint8][]byte
+	fru      []byte
+	// maxRead is the most bytes returned by Get SDR and Read FRU Data.
+	maxRead int
+
+	chassis [][]byte
+	cleared bool
+}
+
+const fakeReservation = 0x1234
+
+// next returns the record with ID id, or the first record for ID 0, and the
+// ID of the following record.
+func next(records [][]byte, id uint16) ([]byte, uint16, error) {
+	for i, r := range records {
+		if id != 0 && binary.LittleEndian.Uint16(r) != id {
+			continue
+		}
+		if i+1 == len(records) {
+			return r, 0xffff, nil
+		}
+		return r, binary.LittleEndian.Uint16(records[i+1]), nil
+	}
+	return nil, 0, CompletionError(IPMI_CC_REQ_DATA_NOT_PRESENT)
+}
+
+func (b *fakeBMC) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
+	ok := []byte{byte(IPMI_CC_OK)}
+	switch {
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SEL_INFO:
+		return append(ok, 0x51, byte(len(b.sel)), 0, 0xff, 0xff), nil
+	case netfn == _IPMI_NETFN_STORAGE && (cmd == BMC_RESERVE_SEL || cmd == BMC_RESERVE_SDR_REPOSITORY):
+		return append(ok, 0x34, 0x12), nil
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SEL_ENTRY:
+		r, n, err := next(b.sel, binary.LittleEndian.Uint16(data[2:]))
+		if err != nil {
+			return nil, err
+		}
+		return append(append(ok, byte(n), byte(n>>8)), r...), nil
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_CLEAR_SEL:
+		if binary.LittleEndian.Uint16(data) != fakeReservation || string(data[2:5]) != "CLR" {
+			return nil, CompletionError(IPMI_CC_INV_DATA_FIELD_IN_REQ)
+		}
+		b.sel, b.cleared = nil, true
+		return append(ok, 1), nil
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SDR:
+		if binary.LittleEndian.Uint16(data) != fakeReservation {
+			return nil, CompletionError(IPMI_CC_RES_CANCELED)
+		}
+		off, n := int(data[4]), int(data[5])
+		if b.maxRead != 0 && n > b.maxRead {
+			return nil, CompletionError(IPMI_CC_CANT_RET_NUM_REQ_BYTES)
+		}
+		r, nextID, err := next(b.sdr, binary.LittleEndian.Uint16(data[2:]))
+		if err != nil {
+			return nil, err
+		}
+		return append(append(ok, byte(nextID), byte(nextID>>8)), r[off:off+n]...), nil
+	case netfn == _IPMI_NETFN_SENSOR && cmd == BMC_GET_SENSOR_READING:
+		r, found := b.readings[data[0]]
+		if !found {
+			return nil, CompletionError(IPMI_CC_REQ_DATA_NOT_PRESENT)
+		}
+		return append(ok, r...), nil
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_FRU_INVENTORY_AREA_INFO:
+		return append(ok, byte(len(b.fru)), byte(len(b.fru)>>8), 0), nil
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_READ_FRU_DATA:
+		off, n := int(binary.LittleEndian.Uint16(data[1:])), int(data[3])
+		if b.maxRead != 0 && n > b.maxRead {
+			return nil, CompletionError(IPMI_CC_CANT_RET_NUM_REQ_BYTES)
+		}
+		n = min(n, len(b.fru)-off)
+		return append(append(ok, byte(n)), b.fru[off:off+n]...), nil
+	case netfn == _IPMI_NETFN_CHASSIS && (cmd == BMC_CHASSIS_CONTROL || cmd == BMC_CHASSIS_IDENTIFY):
+		b.chassis = append(b.chassis, append([]byte{byte(cmd)}, data...))
+		return ok, nil
+	}
+	return nil, CompletionError(IPMI_CC_INV_CMD)
+}
+
+func selRecord(id uint16, typ byte, rest ...byte) []byte {
+	r := make([]byte, 16)
+	binary.LittleEndian.PutUint16(r, id)
+	r[2] = typ
+	copy(r[3:], rest)
+	return r
+}
+
+func TestReadSEL(t *testing.T) {
+	bmc := &fakeBMC{}
+	if records, err := readSEL(bmc); err != nil || records != nil {
+		t.Errorf("readSEL of empty SEL = %v, %v, want nil, nil", records, err)
+	}
+
+	bmc.sel = [][]byte{
+		selRecord(1, SELRecordSystemEvent, 0x00, 0x00, 0x00, 0x60, 0x20, 0x00, 0x04, 0x01, 0x30, 0x81, 0x59, 0x00, 0x00),
+		selRecord(7, 0xc0, 0x10, 0x00, 0x00, 0x00, 0x57, 0x01, 0x00, 0xaa),
+		selRecord(9, 0xe0, 0xbb),
+	}
+	records, err := readSEL(bmc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("readSEL returned %d records, want 3", len(records))
+	}
+
+	r := records[0]
+	want := StandardEvent{Timestamp: 0x60000000, GenID: 0x20, EvMRev: 4, SensorType: 1, SensorNum: 0x30, EventTypeDir: 0x81, EventData: [3]uint8{0x59}}
+	if r.ID != 1 || r.StandardEvent != want {
+		t.Errorf("record 1 = %#04x %+v, want %+v", r.ID, r.StandardEvent, want)
+	}
+	if !r.Deassertion() || r.EventType() != ReadingTypeThreshold {
+		t.Errorf("record 1: Deassertion = %t, EventType = %d, want true, 1", r.Deassertion(), r.EventType())
+	}
+	if tm, ok := r.Time(); !ok || !tm.Equal(time.Unix(0x60000000, 0)) {
+		t.Errorf("record 1: Time = %v, %t", tm, ok)
+	}
+
+	// Timestamped OEM records have a time since the BMC initialized.
+	if r := records[1]; r.ID != 7 || r.Timestamp != 0x10 || r.SensorType != 0 {
+		t.Errorf("record 7 = %+v", r)
+	}
+	if _, ok := records[1].Time(); ok {
+		t.Errorf("record 7 has a time before the clock was set, Time() ok = true")
+	}
+	if r := records[2]; r.ID != 9 || r.Timestamp != 0 || r.Data[3] != 0xbb {
+		t.Errorf("record 9 = %+v", r)
+	}
+
+	if err := clearSEL(bmc); err != nil || !bmc.cleared {
+		t.Errorf("clearSEL = %v, cleared %t", err, bmc.cleared)
+	}
+}
+
+// fullSensor returns a threshold based full sensor record.
+func fullSensor(id uint16, num, unit byte, m, b int16, rExp, bExp int8, name string) []byte {
+	r := make([]byte, 48+len(name))
+	binary.LittleEndian.PutUint16(r, id)
+	r[2], r[3], r[4] = 0x51, SDRFullSensor, byte(len(r)-5)
+	r[5], r[7], r[12], r[13] = 0x20, num, 0x01, ReadingTypeThreshold
+	r[20], r[21] = 0x80, unit // two's complement readings
+	r[24], r[25] = byte(m), byte(m>>8)<<6
+	r[26], r[27] = byte(b), byte(b>>8)<<6
+	r[29] = byte(rExp)<<4 | byte(bExp)&0xf
+	r[47] = 0xc0 | byte(len(name))
+	copy(r[48:], name)
+	return r
+}
+
+func compactSensor(id uint16, num, typ byte, name string) []byte {
+	r := make([]byte, 32+len(name))
+	binary.LittleEndian.PutUint16(r, id)
+	r[2], r[3], r[4] = 0x51, SDRCompactSensor, byte(len(r)-5)
+	r[5], r[7], r[12], r[13] = 0x20, num, typ, 0x6f
+	r[20] = 0xc0
+	r[31] = 0xc0 | byte(len(name))
+	copy(r[32:], name)
+	return r
+}
+
+func TestReadSDR(t *testing.T) {
+	bmc := &fakeBMC{
+		sdr: [][]byte{
+			fullSensor(1, 0x30, 1, 1, 0, 0, 0, "CPU Temp"),
+			fullSensor(2, 0x31, 4, 2, -5, -1, 1, "P12V"),
+			// A FRU device locator is skipped.
+			{3, 0, 0x51, 0x11, 3, 0, 0, 0},
+			compactSensor(4, 0x40, 0x08, "PSU1 Status"),
+			fullSensor(5, 0x32, 18, -3, 0, 2, 0, "FAN1"),
+		},
+		readings: map[uint8][]byte{
+			0x30: {0x2a, 0xc0, 0x00},
+			0x31: {0x64, 0xc0, 0x18},
+			0x40: {0x00, 0xc0, 0x01, 0x00},
+			0x32: {0xf6, 0xe0},
+		},
+		maxRead: 8,
+	}
+	sensors, err := readSDR(bmc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range sensors {
+		names = append(names, s.Name)
+	}
+	if want := []string{"CPU Temp", "P12V", "PSU1 Status", "FAN1"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("sensors = %q, want %q", names, want)
+	}
+
+	for i, tt := range []struct {
+		analog bool
+		value  float64
+		status string
+	}{
+		{analog: true, value: 42, status: "ok"},
+		// (2*100 + -5*10) / 10 crosses the upper critical threshold.
+		{analog: true, value: 15, status: "ucr"},
+		{status: "0x0001"},
+		// -3 * -10 * 100, but not scanned.
+		{analog: true, value: 3000, status: "na"},
+	} {
+		s := &sensors[i]
+		r, err := readSensor(bmc, s)
+		if err != nil {
+			t.Errorf("readSensor(%s) = %v", s.Name, err)
+			continue
+		}
+		if s.Analog() != tt.analog || r.Analog != tt.analog || math.Abs(r.Value-tt.value) > 1e-9 || r.Status(s) != tt.status {
+			t.Errorf("%s: Analog = %t, Value = %v, Status = %q, want %t, %v, %q", s.Name, s.Analog(), r.Value, r.Status(s), tt.analog, tt.value, tt.status)
+		}
+	}
+	if s := sensors[1]; s.Unit != 4 || UnitName(s.Unit) != "Volts" || SensorTypeName(s.SensorType) != "Temperature" {
+		t.Errorf("P12V: unit %q, type %q", UnitName(s.Unit), SensorTypeName(s.SensorType))
+	}
+}
+
+func TestSensorStatus(t *testing.T) {
+	threshold := &SensorRecord{ReadingType: ReadingTypeThreshold}
+	for _, tt := range []struct {
+		state uint16
+		want  string
+	}{
+		{0, "ok"},
+		{0x01, "lnc"},
+		{0x03, "lcr"},
+		{0x07, "lnr"},
+		{0x08, "unc"},
+		{0x09, "lnc"},
+		{0x0a, "lcr"},
+		{0x20, "unr"},
+	} {
+		r := &SensorReading{State: tt.state}
+		if got := r.Status(threshold); got != tt.want {
+			t.Errorf("Status(%#x) = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
+
+// pack6 encodes s as 6-bit ASCII.
+func pack6(s string) []byte {
+	var b []byte
+	var acc, bits uint
+	for _, c := range []byte(s) {
+		acc |= uint(c-0x20) << bits
+		for bits += 6; bits >= 8; bits -= 8 {
+			b = append(b, byte(acc))
+			acc >>= 8
+		}
+	}
+	if bits > 0 {
+		b = append(b, byte(acc))
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func ascii(s string) []byte {
+	return append([]byte{0xc0 | byte(len(s))}, s...)
+}
+
+// fruArea returns an area of fields, padded and with a checksum.
+func fruArea(head []byte, fields ...[]byte) []byte {
+	a := append([]byte{1, 0}, head...)
+	for _, f := range fields {
+		a = append(a, f...)
+	}
+	a = append(a, 0xc1)
+	for (len(a)+1)%8 != 0 {
+		a = append(a, 0)
+	}
+	a = append(a, 0)
+	a[1] = byte(len(a) / 8)
+	var sum byte
+	for _, c := range a {
+		sum += c
+	}
+	a[len(a)-1] = -sum
+	return a
+}
+
+func fruImage(chassis, board, product []byte) []byte {
+	h := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	b := append([]byte(nil), h...)
+	for i, a := range [][]byte{chassis, board, product} {
+		if a != nil {
+			h[2+i] = byte(len(b) / 8)
+			b = append(b, a...)
+		}
+	}
+	var sum byte
+	for _, c := range h[:7] {
+		sum += c
+	}
+	h[7] = -sum
+	copy(b, h)
+	return b
+}
+
+func TestParseFRU(t *testing.T) {
+	img := fruImage(
+		fruArea([]byte{0x17}, ascii("CH-100"), []byte{0x42, 0x12, 0x34}, ascii("rack 3")),
+		fruArea([]byte{0, 0x3c, 0, 0}, pack6("ACME"), ascii("X11 Board"), ascii("B0001"), ascii("PN-7"), []byte{0xc0}),
+		fruArea([]byte{0}, ascii("ACME Inc.  "), ascii("Server"), ascii("S-1"), ascii("v2"), ascii("SN42"), ascii("asset\x00\x00")),
+	)
+	fru, err := ParseFRU(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FRU{
+		Chassis: &FRUChassis{Type: 0x17, PartNumber: "CH-100", Serial: "1234", Custom: []string{"rack 3"}},
+		Board: &FRUBoard{
+			MfgTime:      time.Date(1996, 1, 1, 1, 0, 0, 0, time.UTC),
+			Manufacturer: "ACME",
+			Product:      "X11 Board",
+			Serial:       "B0001",
+			PartNumber:   "PN-7",
+		},
+		Product: &FRUProduct{Manufacturer: "ACME Inc.", Name: "Server", PartNumber: "S-1", Version: "v2", Serial: "SN42", AssetTag: "asset"},
+	}
+	if !reflect.DeepEqual(fru, want) {
+		t.Errorf("ParseFRU =\n%+v %+v %+v, want\n%+v %+v %+v", fru.Chassis, fru.Board, fru.Product, want.Chassis, want.Board, want.Product)
+	}
+
+	bmc := &fakeBMC{fru: img, maxRead: 4}
+	b, err := readFRUData(bmc, 0)
+	if err != nil || !reflect.DeepEqual(b, img) {
+		t.Errorf("readFRUData = %x, %v, want %x", b, err, img)
+	}
+
+	onlyBoard := fruImage(nil, fruArea([]byte{0, 0, 0, 0}, ascii("MF")), nil)
+	if fru, err := ParseFRU(onlyBoard); err != nil || fru.Chassis != nil || fru.Product != nil || fru.Board.Manufacturer != "MF" || !fru.Board.MfgTime.IsZero() {
+		t.Errorf("ParseFRU(board only) = %+v, %v", fru, err)
+	}
+
+	badHeader := append([]byte(nil), img...)
+	badHeader[7]++
+	badArea := append([]byte(nil), img...)
+	badArea[len(badArea)-1]++
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		want error
+	}{
+		{"short", img[:4], ErrShortResponse},
+		{"header checksum", badHeader, ErrFRUChecksum},
+		{"area checksum", badArea, ErrFRUChecksum},
+		{"truncated", img[:len(img)-8], ErrShortResponse},
+	} {
+		if _, err := ParseFRU(tt.b); !errors.Is(err, tt.want) {
+			t.Errorf("ParseFRU(%s) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestChassis(t *testing.T) {
+	bmc := &fakeBMC{}
+	if err := chassisControl(bmc, ChassisPowerCycle); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []int{15, -1, 0} {
+		if err := chassisIdentify(bmc, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := chassisIdentify(bmc, 256); err == nil {
+		t.Errorf("chassisIdentify(256) = nil, want error")
+	}
+	want := [][]byte{
+		{byte(BMC_CHASSIS_CONTROL), 2},
+		{byte(BMC_CHASSIS_IDENTIFY), 15},
+		{byte(BMC_CHASSIS_IDENTIFY), 0, 1},
+		{byte(BMC_CHASSIS_IDENTIFY), 0},
+	}
+	if !reflect.DeepEqual(bmc.chassis, want) {
+		t.Errorf("chassis commands = %x, want %x", bmc.chassis, want)
+	}
+	if s := (&ChassisStatus{CurrentPowerState: 0x21}); !s.PowerOn() {
+		t.Errorf("PowerOn(0x21) = false")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import "fmt"
+
+// ChassisControl is an action of the Chassis Control command.
+type ChassisControl byte
+
+// Chassis Control actions.
+const (
+	ChassisPowerDown     ChassisControl = 0x0
+	ChassisPowerUp       ChassisControl = 0x1
+	ChassisPowerCycle    ChassisControl = 0x2
+	ChassisHardReset     ChassisControl = 0x3
+	ChassisDiagInterrupt ChassisControl = 0x4
+	ChassisSoftShutdown  ChassisControl = 0x5
+)
+
+// PowerOn returns whether the system power is on.
+func (s *ChassisStatus) PowerOn() bool {
+	return s.CurrentPowerState&0x1 != 0
+}
+
+func chassisControl(s sendRecver, c ChassisControl) error {
+	_, err := recv(s, _IPMI_NETFN_CHASSIS, BMC_CHASSIS_CONTROL, []byte{byte(c)}, 0)
+	return err
+}
+
+// ChassisControl powers the system up, down or cycles it, resets it, or
+// asks the OS to shut down through ACPI.
+func (i *IPMI) ChassisControl(c ChassisControl) error {
+	return chassisControl(i, c)
+}
+
+func chassisIdentify(s sendRecver, seconds int) error {
+	var data []byte
+	switch {
+	case seconds < 0:
+		data = []byte{0, 1} // on until turned off
+	case seconds > 255:
+		return fmt.Errorf("identify interval %d is longer than 255 seconds", seconds)
+	default:
+		data = []byte{byte(seconds)}
+	}
+	_, err := recv(s, _IPMI_NETFN_CHASSIS, BMC_CHASSIS_IDENTIFY, data, 0)
+	return err
+}
+
+// ChassisIdentify turns on the chassis identify light for seconds, which
+// are at most 255; 0 turns it off and a negative value turns it on
+// indefinitely.
+func (i *IPMI) ChassisIdentify(seconds int) error {
+	return chassisIdentify(i, seconds)
+}
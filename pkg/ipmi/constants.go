@@ -13,6 +13,7 @@ const (
 
 	// Net functions
 	_IPMI_NETFN_CHASSIS   NetFn = 0x0
+	_IPMI_NETFN_SENSOR    NetFn = 0x4
 	_IPMI_NETFN_APP       NetFn = 0x6
 	_IPMI_NETFN_STORAGE   NetFn = 0xA
 	_IPMI_NETFN_TRANSPORT NetFn = 0xC
@@ -30,9 +31,26 @@ const (
 
 	// Chassis Device Commands
 	BMC_GET_CHASSIS_STATUS Command = 0x01
+	BMC_CHASSIS_CONTROL    Command = 0x02
+	BMC_CHASSIS_IDENTIFY   Command = 0x04
+
+	// Sensor Device Commands
+	BMC_GET_SENSOR_READING Command = 0x2D
+
+	// FRU Device Commands
+	BMC_GET_FRU_INVENTORY_AREA_INFO Command = 0x10
+	BMC_READ_FRU_DATA               Command = 0x11
+
+	// SDR Device Commands
+	BMC_GET_SDR_REPOSITORY_INFO Command = 0x20
+	BMC_RESERVE_SDR_REPOSITORY  Command = 0x22
+	BMC_GET_SDR                 Command = 0x23
 
 	// SEL device Commands
-	BMC_GET_SEL_INFO Command = 0x40
+	BMC_GET_SEL_INFO  Command = 0x40
+	BMC_RESERVE_SEL   Command = 0x42
+	BMC_GET_SEL_ENTRY Command = 0x43
+	BMC_CLEAR_SEL     Command = 0x47
 
 	// LAN Device Commands
 	BMC_GET_LAN_CONFIG Command = 0x02
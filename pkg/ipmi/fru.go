@@ -0,0 +1,264 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrFRUChecksum is returned for FRU inventory data with a bad checksum.
+var ErrFRUChecksum = errors.New("bad FRU checksum")
+
+// fruEpoch is the start of FRU manufacturing dates.
+var fruEpoch = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FRUChassis is the chassis info area of FRU inventory data. Type is an
+// SMBIOS chassis type.
+type FRUChassis struct {
+	Type       uint8
+	PartNumber string
+	Serial     string
+	Custom     []string
+}
+
+// FRUBoard is the board info area of FRU inventory data.
+type FRUBoard struct {
+	MfgTime      time.Time
+	Manufacturer string
+	Product      string
+	Serial       string
+	PartNumber   string
+	FileID       string
+	Custom       []string
+}
+
+// FRUProduct is the product info area of FRU inventory data.
+type FRUProduct struct {
+	Manufacturer string
+	Name         string
+	PartNumber   string
+	Version      string
+	Serial       string
+	AssetTag     string
+	FileID       string
+	Custom       []string
+}
+
+// FRU is FRU inventory data. Areas which are not present are nil.
+type FRU struct {
+	Chassis *FRUChassis
+	Board   *FRUBoard
+	Product *FRUProduct
+}
+
+func checksum(b []byte) error {
+	var sum byte
+	for _, c := range b {
+		sum += c
+	}
+	if sum != 0 {
+		return ErrFRUChecksum
+	}
+	return nil
+}
+
+const bcdPlus = "0123456789 -.???"
+
+// decodeField decodes a type/length encoded field.
+func decodeField(typ byte, b []byte) string {
+	switch typ {
+	case 0: // binary
+		return fmt.Sprintf("%x", b)
+	case 1: // BCD plus
+		var s strings.Builder
+		for _, c := range b {
+			s.WriteByte(bcdPlus[c>>4])
+			s.WriteByte(bcdPlus[c&0xf])
+		}
+		return s.String()
+	case 2: // 6-bit ASCII, packed little endian
+		var s strings.Builder
+		var acc, bits uint
+		for _, c := range b {
+			acc |= uint(c) << bits
+			for bits += 8; bits >= 6; bits -= 6 {
+				s.WriteByte(byte(acc&0x3f) + 0x20)
+				acc >>= 6
+			}
+		}
+		return strings.TrimRight(s.String(), " ")
+	default: // 8-bit ASCII or Latin-1
+		return strings.TrimRight(string(b), " \x00")
+	}
+}
+
+// fields decodes the type/length encoded fields of an area, up to the end
+// marker 0xc1.
+func fields(area []byte) ([]string, error) {
+	var f []string
+	for i := 0; ; {
+		if i >= len(area) {
+			return nil, fmt.Errorf("field %d: no end marker: %w", len(f), ErrShortResponse)
+		}
+		tl := area[i]
+		if tl == 0xc1 {
+			return f, nil
+		}
+		n := int(tl & 0x3f)
+		if i+1+n > len(area) {
+			return nil, fmt.Errorf("field %d: %w", len(f), ErrShortResponse)
+		}
+		f = append(f, decodeField(tl>>6, area[i+1:i+1+n]))
+		i += 1 + n
+	}
+}
+
+// area returns the area at offset off of b, in multiples of 8, and its
+// fields starting at start.
+func area(b []byte, name string, off byte, start int) ([]byte, []string, error) {
+	i := int(off) * 8
+	if i+2 > len(b) {
+		return nil, nil, fmt.Errorf("%s area: %w", name, ErrShortResponse)
+	}
+	n := int(b[i+1]) * 8
+	if n < start || i+n > len(b) {
+		return nil, nil, fmt.Errorf("%s area: %w", name, ErrShortResponse)
+	}
+	a := b[i : i+n]
+	if err := checksum(a); err != nil {
+		return nil, nil, fmt.Errorf("%s area: %w", name, err)
+	}
+	f, err := fields(a[start:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s area: %w", name, err)
+	}
+	return a, f, nil
+}
+
+// field returns field i of f, the rest of which are custom fields.
+func field(f []string, i int) string {
+	if i < len(f) {
+		return f[i]
+	}
+	return ""
+}
+
+func custom(f []string, n int) []string {
+	if len(f) > n {
+		return f[n:]
+	}
+	return nil
+}
+
+// ParseFRU parses FRU inventory data, as read from the BMC or an EEPROM.
+func ParseFRU(b []byte) (*FRU, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("common header: %w", ErrShortResponse)
+	}
+	if err := checksum(b[:8]); err != nil {
+		return nil, fmt.Errorf("common header: %w", err)
+	}
+	if v := b[0] & 0xf; v != 1 {
+		return nil, fmt.Errorf("unsupported FRU format version %d", v)
+	}
+	fru := &FRU{}
+	if off := b[2]; off != 0 {
+		a, f, err := area(b, "chassis", off, 3)
+		if err != nil {
+			return nil, err
+		}
+		fru.Chassis = &FRUChassis{
+			Type:       a[2],
+			PartNumber: field(f, 0),
+			Serial:     field(f, 1),
+			Custom:     custom(f, 2),
+		}
+	}
+	if off := b[3]; off != 0 {
+		a, f, err := area(b, "board", off, 6)
+		if err != nil {
+			return nil, err
+		}
+		fru.Board = &FRUBoard{
+			Manufacturer: field(f, 0),
+			Product:      field(f, 1),
+			Serial:       field(f, 2),
+			PartNumber:   field(f, 3),
+			FileID:       field(f, 4),
+			Custom:       custom(f, 5),
+		}
+		if m := uint32(a[3]) | uint32(a[4])<<8 | uint32(a[5])<<16; m != 0 {
+			fru.Board.MfgTime = fruEpoch.Add(time.Duration(m) * time.Minute)
+		}
+	}
+	if off := b[4]; off != 0 {
+		_, f, err := area(b, "product", off, 3)
+		if err != nil {
+			return nil, err
+		}
+		fru.Product = &FRUProduct{
+			Manufacturer: field(f, 0),
+			Name:         field(f, 1),
+			PartNumber:   field(f, 2),
+			Version:      field(f, 3),
+			Serial:       field(f, 4),
+			AssetTag:     field(f, 5),
+			FileID:       field(f, 6),
+			Custom:       custom(f, 7),
+		}
+	}
+	return fru, nil
+}
+
+func readFRUData(s sendRecver, id uint8) ([]byte, error) {
+	info, err := recv(s, _IPMI_NETFN_STORAGE, BMC_GET_FRU_INVENTORY_AREA_INFO, []byte{id}, 3)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint16(info[0:2]))
+	// Devices accessed by words take offsets and counts in words.
+	shift := uint(info[2] & 1)
+	var data []byte
+	for chunk := 16; len(data) < size; {
+		off := len(data) >> shift
+		c := min(chunk, size-len(data)) >> shift
+		if c == 0 {
+			break
+		}
+		r, err := recv(s, _IPMI_NETFN_STORAGE, BMC_READ_FRU_DATA, []byte{id, byte(off), byte(off >> 8), byte(c)}, 1)
+		if isCompletion(err, IPMI_CC_CANT_RET_NUM_REQ_BYTES) && chunk > 2 {
+			chunk /= 2
+			continue
+		}
+		if err != nil {
+			return data, fmt.Errorf("FRU %d offset %d: %w", id, len(data), err)
+		}
+		n := int(r[0]) << shift
+		if n == 0 || len(r) < 1+n {
+			return data, fmt.Errorf("FRU %d offset %d: %w", id, len(data), ErrShortResponse)
+		}
+		data = append(data, r[1:1+n]...)
+	}
+	return data, nil
+}
+
+// ReadFRUData returns the raw inventory data of FRU device id, where 0 is
+// the BMC's own FRU.
+func (i *IPMI) ReadFRUData(id uint8) ([]byte, error) {
+	return readFRUData(i, id)
+}
+
+// ReadFRU returns the parsed inventory data of FRU device id.
+func (i *IPMI) ReadFRU(id uint8) (*FRU, error) {
+	b, err := readFRUData(i, id)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFRU(b)
+}
@@ -0,0 +1,350 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SDR record types of sensors.
+const (
+	SDRFullSensor    = 0x01
+	SDRCompactSensor = 0x02
+)
+
+// ReadingTypeThreshold is the event/reading type code of threshold based
+// sensors, which have readings with a unit.
+const ReadingTypeThreshold = 0x01
+
+var sensorTypes = map[uint8]string{
+	0x01: "Temperature",
+	0x02: "Voltage",
+	0x03: "Current",
+	0x04: "Fan",
+	0x05: "Physical Security",
+	0x06: "Platform Security",
+	0x07: "Processor",
+	0x08: "Power Supply",
+	0x09: "Power Unit",
+	0x0b: "Other Units",
+	0x0c: "Memory",
+	0x0d: "Drive Slot",
+	0x0f: "System Firmware Progress",
+	0x10: "Event Logging Disabled",
+	0x12: "System Event",
+	0x13: "Critical Interrupt",
+	0x14: "Button",
+	0x15: "Module / Board",
+	0x19: "Chip Set",
+	0x1d: "System Boot Initiated",
+	0x1f: "OS Boot",
+	0x20: "OS Stop / Shutdown",
+	0x21: "Slot / Connector",
+	0x22: "System ACPI Power State",
+	0x23: "Watchdog",
+	0x25: "Entity Presence",
+	0x28: "Management Subsystem Health",
+	0x29: "Battery",
+	0x2b: "Version Change",
+}
+
+// SensorTypeName returns the name of a sensor type, as used in SDR and
+// SEL records.
+func SensorTypeName(t uint8) string {
+	if s, ok := sensorTypes[t]; ok {
+		return s
+	}
+	if t >= 0xc0 {
+		return fmt.Sprintf("OEM 0x%02x", t)
+	}
+	return fmt.Sprintf("Unknown 0x%02x", t)
+}
+
+var units = []string{
+	"unspecified", "degrees C", "degrees F", "degrees K", "Volts", "Amps",
+	"Watts", "Joules", "Coulombs", "VA", "Nits", "lumen", "lux", "Candela",
+	"kPa", "PSI", "Newton", "CFM", "RPM", "Hz", "microsecond", "millisecond",
+	"second", "minute", "hour", "day", "week",
+}
+
+// UnitName returns the name of a sensor's base unit.
+func UnitName(u uint8) string {
+	if int(u) < len(units) {
+		return units[u]
+	}
+	return fmt.Sprintf("unit %d", u)
+}
+
+// SensorRecord is a full or compact sensor record of the Sensor Data
+// Repository.
+type SensorRecord struct {
+	ID     uint16
+	Type   uint8
+	Name   string
+	Owner  uint8
+	LUN    uint8
+	Number uint8
+	Entity uint8
+
+	// Instance is the entity instance.
+	Instance    uint8
+	SensorType  uint8
+	ReadingType uint8
+	Unit        uint8
+
+	// The conversion of analog readings of full sensor records:
+	// y = L((M*x + B*10^BExp) * 10^RExp), where x is the reading in
+	// analogFormat and L the linearization function.
+	analogFormat  uint8
+	linearization uint8
+	m, b          int16
+	bExp, rExp    int8
+}
+
+// Analog returns whether readings of the sensor convert to a value.
+func (r *SensorRecord) Analog() bool {
+	return r.Type == SDRFullSensor && r.analogFormat != 3 && r.linearization <= 11
+}
+
+// signExtend returns the two's complement value of the low bits of v.
+func signExtend(v uint16, bits uint) int16 {
+	v &= 1<<bits - 1
+	if v&(1<<(bits-1)) != 0 {
+		return int16(v) - 1<<bits
+	}
+	return int16(v)
+}
+
+// Convert converts a raw analog reading.
+func (r *SensorRecord) Convert(raw uint8) float64 {
+	var x float64
+	switch r.analogFormat {
+	case 1:
+		x = float64(int8(raw))
+		if raw&0x80 != 0 {
+			x++
+		}
+	case 2:
+		x = float64(int8(raw))
+	default:
+		x = float64(raw)
+	}
+	y := (float64(r.m)*x + float64(r.b)*math.Pow10(int(r.bExp))) * math.Pow10(int(r.rExp))
+	switch r.linearization {
+	case 1:
+		y = math.Log(y)
+	case 2:
+		y = math.Log10(y)
+	case 3:
+		y = math.Log2(y)
+	case 4:
+		y = math.Exp(y)
+	case 5:
+		y = math.Pow(10, y)
+	case 6:
+		y = math.Exp2(y)
+	case 7:
+		y = 1 / y
+	case 8:
+		y = y * y
+	case 9:
+		y = y * y * y
+	case 10:
+		y = math.Sqrt(y)
+	case 11:
+		y = math.Cbrt(y)
+	}
+	return y
+}
+
+// parseSensorRecord parses a full or compact sensor record, including the
+// 5 byte record header.
+func parseSensorRecord(b []byte) (*SensorRecord, error) {
+	if len(b) < 5 {
+		return nil, ErrShortResponse
+	}
+	r := &SensorRecord{ID: binary.LittleEndian.Uint16(b[0:2]), Type: b[3]}
+	nameAt := 0
+	switch r.Type {
+	case SDRFullSensor:
+		nameAt = 47
+	case SDRCompactSensor:
+		nameAt = 31
+	default:
+		return nil, fmt.Errorf("SDR record %#04x has type %#02x, not a sensor", r.ID, r.Type)
+	}
+	if len(b) <= nameAt {
+		return nil, fmt.Errorf("SDR record %#04x: %w", r.ID, ErrShortResponse)
+	}
+	r.Owner = b[5]
+	r.LUN = b[6] & 0x3
+	r.Number = b[7]
+	r.Entity = b[8]
+	r.Instance = b[9]
+	r.SensorType = b[12]
+	r.ReadingType = b[13]
+	r.analogFormat = b[20] >> 6
+	r.Unit = b[21]
+	if r.Type == SDRFullSensor {
+		r.linearization = b[23] & 0x7f
+		r.m = signExtend(uint16(b[24])|uint16(b[25]&0xc0)<<2, 10)
+		r.b = signExtend(uint16(b[26])|uint16(b[27]&0xc0)<<2, 10)
+		r.rExp = int8(signExtend(uint16(b[29]>>4), 4))
+		r.bExp = int8(signExtend(uint16(b[29]&0xf), 4))
+	} else {
+		r.analogFormat = 3
+	}
+	n := int(b[nameAt] & 0x1f)
+	name := b[nameAt+1:]
+	if n < len(name) {
+		name = name[:n]
+	}
+	r.Name = string(name)
+	return r, nil
+}
+
+// getSDR reads n bytes at off of an SDR record.
+func getSDR(s sendRecver, res, id uint16, off, n uint8) (uint16, []byte, error) {
+	req := make([]byte, 6)
+	binary.LittleEndian.PutUint16(req[0:], res)
+	binary.LittleEndian.PutUint16(req[2:], id)
+	req[4], req[5] = off, n
+	r, err := recv(s, _IPMI_NETFN_STORAGE, BMC_GET_SDR, req, 2+int(n))
+	if err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint16(r[0:2]), r[2 : 2+int(n) : 2+int(n)], nil
+}
+
+func isCompletion(err error, cc CompletionCode) bool {
+	var ce CompletionError
+	return errors.As(err, &ce) && CompletionCode(ce) == cc
+}
+
+func readSDR(s sendRecver) ([]SensorRecord, error) {
+	res, err := reserve(s, BMC_RESERVE_SDR_REPOSITORY)
+	if err != nil {
+		return nil, err
+	}
+	var sensors []SensorRecord
+	// Records are read 16 bytes at a time, or fewer if the BMC cannot
+	// return that many.
+	chunk := 16
+	for id, n := uint16(0), 0; id != 0xffff; n++ {
+		next, rec, err := getSDR(s, res, id, 0, 5)
+		if err != nil {
+			return sensors, fmt.Errorf("SDR record %#04x: %w", id, err)
+		}
+		length := 5 + int(rec[4])
+		for retries := 0; len(rec) < length; {
+			c := min(chunk, length-len(rec))
+			_, b, err := getSDR(s, res, id, uint8(len(rec)), uint8(c))
+			switch {
+			case isCompletion(err, IPMI_CC_CANT_RET_NUM_REQ_BYTES) && chunk > 1:
+				chunk /= 2
+				continue
+			case isCompletion(err, IPMI_CC_RES_CANCELED) && retries < 3:
+				retries++
+				if res, err = reserve(s, BMC_RESERVE_SDR_REPOSITORY); err == nil {
+					continue
+				}
+			}
+			if err != nil {
+				return sensors, fmt.Errorf("SDR record %#04x: %w", id, err)
+			}
+			rec = append(rec, b...)
+		}
+		if t := rec[3]; t == SDRFullSensor || t == SDRCompactSensor {
+			r, err := parseSensorRecord(rec)
+			if err != nil {
+				return sensors, err
+			}
+			sensors = append(sensors, *r)
+		}
+		if next == id || n > 0xffff {
+			return sensors, fmt.Errorf("SDR record %#04x: next record %#04x makes a loop", id, next)
+		}
+		id = next
+	}
+	return sensors, nil
+}
+
+// ReadSDR returns the sensor records of the Sensor Data Repository. Other
+// records, e.g. of FRU devices, are skipped.
+func (i *IPMI) ReadSDR() ([]SensorRecord, error) {
+	return readSDR(i)
+}
+
+// SensorReading is the reading of a sensor.
+type SensorReading struct {
+	Raw uint8
+
+	// Analog is set if Value is the converted reading of an analog sensor.
+	Analog bool
+	Value  float64
+
+	// Unavailable is set if the sensor has no reading, e.g. because it is
+	// not scanned or the entity is not present.
+	Unavailable bool
+
+	// State holds the threshold comparison or discrete state bits.
+	State uint16
+}
+
+var thresholds = []string{"lnc", "lcr", "lnr", "unc", "ucr", "unr"}
+
+// Status returns "ok" or the most severe threshold crossed by a threshold
+// based sensor, as lnc, lcr, lnr, unc, ucr or unr for lower and upper
+// non-critical, critical and non-recoverable thresholds. Discrete sensors
+// return their state bits.
+func (sr *SensorReading) Status(r *SensorRecord) string {
+	if sr.Unavailable {
+		return "na"
+	}
+	if r.ReadingType != ReadingTypeThreshold {
+		return fmt.Sprintf("%#04x", sr.State)
+	}
+	status, severity := "ok", -1
+	for i, t := range thresholds {
+		// Non-recoverable beats critical beats non-critical.
+		if sr.State&(1<<i) != 0 && i%3 > severity {
+			status, severity = t, i%3
+		}
+	}
+	return status
+}
+
+func readSensor(s sendRecver, r *SensorRecord) (*SensorReading, error) {
+	b, err := recv(s, _IPMI_NETFN_SENSOR, BMC_GET_SENSOR_READING, []byte{r.Number}, 2)
+	if err != nil {
+		return nil, fmt.Errorf("sensor %q: %w", r.Name, err)
+	}
+	sr := &SensorReading{
+		Raw: b[0],
+		// Bit 6 clear means scanning is disabled.
+		Unavailable: b[1]&0x20 != 0 || b[1]&0x40 == 0,
+	}
+	if len(b) > 2 {
+		sr.State = uint16(b[2])
+	}
+	if len(b) > 3 {
+		sr.State |= uint16(b[3]) << 8
+	}
+	if r.ReadingType == ReadingTypeThreshold {
+		sr.State &= 0x3f
+	}
+	if r.Analog() {
+		sr.Analog, sr.Value = true, r.Convert(sr.Raw)
+	}
+	return sr, nil
+}
+
+// ReadSensor returns the current reading of a sensor.
+func (i *IPMI) ReadSensor(r *SensorRecord) (*SensorReading, error) {
+	return readSensor(i, r)
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrShortResponse is returned when the BMC's response is shorter than the
+// command's response format.
+var ErrShortResponse = errors.New("response too short")
+
+// sendRecver sends a command and returns the response, starting with the
+// completion code. It is implemented by IPMI, and by fake BMCs in tests.
+type sendRecver interface {
+	SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error)
+}
+
+// recv sends a command and returns the response data after the completion
+// code, which must be at least n bytes.
+func recv(s sendRecver, netfn NetFn, cmd Command, data []byte, n int) ([]byte, error) {
+	r, err := s.SendRecv(netfn, cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) < n+1 {
+		return nil, fmt.Errorf("netfn %#x command %#x: %w: got %d bytes, want %d", netfn, cmd, ErrShortResponse, len(r)-1, n)
+	}
+	return r[1:], nil
+}
+
+// reserve reserves the SEL or SDR repository with cmd. BMCs which do not
+// support reservations get the reservation ID 0, which may be used to read
+// whole records.
+func reserve(s sendRecver, cmd Command) (uint16, error) {
+	r, err := recv(s, _IPMI_NETFN_STORAGE, cmd, nil, 2)
+	if isCompletion(err, IPMI_CC_INV_CMD) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(r), nil
+}
+
+// SEL record types.
+const (
+	SELRecordSystemEvent = 0x02
+	selRecordOEMNonTs    = 0xE0
+)
+
+// SELRecord is an entry of the System Event Log.
+type SELRecord struct {
+	ID   uint16
+	Type uint8
+
+	// StandardEvent is set for system event records. For timestamped OEM
+	// records, only Timestamp is set.
+	StandardEvent
+
+	// Data is the whole record, including ID and type.
+	Data [16]byte
+}
+
+// Time returns the time of the record. ok is false if the record has no
+// timestamp, or it was taken before the BMC's clock was set, in which case
+// it counts seconds since the BMC initialized.
+func (r *SELRecord) Time() (t time.Time, ok bool) {
+	if r.Type >= selRecordOEMNonTs {
+		return time.Time{}, false
+	}
+	t = time.Unix(int64(r.Timestamp), 0).UTC()
+	return t, r.Timestamp > 0x20000000
+}
+
+// Deassertion returns whether a system event is a deassertion event.
+func (r *SELRecord) Deassertion() bool {
+	return r.EventTypeDir&0x80 != 0
+}
+
+// EventType returns the event/reading type code of a system event.
+func (r *SELRecord) EventType() uint8 {
+	return r.EventTypeDir & 0x7f
+}
+
+func parseSELRecord(b []byte) SELRecord {
+	var r SELRecord
+	copy(r.Data[:], b)
+	r.ID = binary.LittleEndian.Uint16(b[0:2])
+	r.Type = b[2]
+	if r.Type < selRecordOEMNonTs {
+		r.Timestamp = binary.LittleEndian.Uint32(b[3:7])
+	}
+	if r.Type == SELRecordSystemEvent {
+		r.GenID = binary.LittleEndian.Uint16(b[7:9])
+		r.EvMRev = b[9]
+		r.SensorType = b[10]
+		r.SensorNum = b[11]
+		r.EventTypeDir = b[12]
+		copy(r.EventData[:], b[13:16])
+	}
+	return r
+}
+
+func readSEL(s sendRecver) ([]SELRecord, error) {
+	info, err := recv(s, _IPMI_NETFN_STORAGE, BMC_GET_SEL_INFO, nil, 3)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(info[1:3]) == 0 {
+		return nil, nil
+	}
+	res, err := reserve(s, BMC_RESERVE_SEL)
+	if err != nil {
+		return nil, err
+	}
+	var records []SELRecord
+	for id := uint16(0); id != 0xffff; {
+		req := make([]byte, 6)
+		binary.LittleEndian.PutUint16(req[0:], res)
+		binary.LittleEndian.PutUint16(req[2:], id)
+		req[5] = 0xff // the whole record
+		r, err := recv(s, _IPMI_NETFN_STORAGE, BMC_GET_SEL_ENTRY, req, 18)
+		if err != nil {
+			return records, fmt.Errorf("SEL record %#04x: %w", id, err)
+		}
+		records = append(records, parseSELRecord(r[2:18]))
+		next := binary.LittleEndian.Uint16(r[0:2])
+		if next == id || len(records) > 0xffff {
+			return records, fmt.Errorf("SEL record %#04x: next record %#04x makes a loop", id, next)
+		}
+		id = next
+	}
+	return records, nil
+}
+
+// ReadSEL returns all records of the System Event Log.
+func (i *IPMI) ReadSEL() ([]SELRecord, error) {
+	return readSEL(i)
+}
+
+func clearSEL(s sendRecver) error {
+	res, err := reserve(s, BMC_RESERVE_SEL)
+	if err != nil {
+		return err
+	}
+	req := []byte{byte(res), byte(res >> 8), 'C', 'L', 'R', 0xaa}
+	for deadline := time.Now().Add(timeout); ; {
+		r, err := recv(s, _IPMI_NETFN_STORAGE, BMC_CLEAR_SEL, req, 1)
+		if err != nil {
+			return err
+		}
+		// The erasure progress is 1 when it is done.
+		if r[0]&0xf == 1 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("SEL erasure did not complete in %v", timeout)
+		}
+		// Poll the progress.
+		req[5] = 0
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// ClearSEL erases all records of the System Event Log.
+func (i *IPMI) ClearSEL() error {
+	return clearSEL(i)
+}
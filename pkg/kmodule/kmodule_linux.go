@@ -38,7 +38,7 @@ func Init(image []byte, opts string) error {
 }
 
 // FileInit loads the kernel module contained by `f` with the given opts and
-// flags. Uncompresses modules with a .xz and .gz suffix before loading.
+// flags. Uncompresses modules with a .xz, .gz and .zst suffix before loading.
 //
 // FileInit falls back to init_module(2) via Init when the finit_module(2)
 // syscall is not available and when loading compressed modules.
@@ -101,6 +101,19 @@ type dependency struct {
 
 type depMap map[string]*dependency
 
+// alias is a line of modules.alias: the module to load for the devices
+// whose modalias matches pattern.
+type alias struct {
+	pattern string
+	module  string
+}
+
+// moduleDB is what genDeps reads from the module directory.
+type moduleDB struct {
+	deps    depMap
+	aliases []alias
+}
+
 // ProbeOpts contains optional parameters to Probe.
 //
 // An empty ProbeOpts{} should lead to the default behavior.
@@ -119,30 +132,199 @@ func Probe(name string, modParams string) error {
 
 // ProbeOptions loads the given kernel module and its dependencies.
 // This functions takes ProbeOpts.
+//
+// name is a module name, or an alias from modules.alias, such as a device's
+// modalias; all modules matching an alias are loaded.
 func ProbeOptions(name, modParams string, opts ProbeOpts) error {
-	deps, err := genDeps(opts)
+	db, err := genDeps(opts)
 	if err != nil {
 		return fmt.Errorf("could not generate dependency map %v", err)
 	}
 
-	modPath, err := findModPath(name, deps)
+	modPaths, err := db.resolve(name)
 	if err != nil {
 		return fmt.Errorf("could not find module path %q: %v", name, err)
 	}
 
-	dep := deps[modPath]
+	for _, modPath := range modPaths {
+		dep := db.deps[modPath]
+		if dep.state == builtin || dep.state == loaded {
+			continue
+		}
 
-	if dep.state == builtin || dep.state == loaded {
-		return nil
+		dep.state = loading
+		for _, d := range dep.deps {
+			if err := loadDeps(d, db.deps, opts); err != nil {
+				return err
+			}
+		}
+		if err := loadModule(modPath, modParams, opts); err != nil {
+			return err
+		}
+		dep.state = loaded
+	}
+	return nil
+}
+
+// resolve returns the paths of the module name, or of the modules an alias
+// matches.
+func (db *moduleDB) resolve(name string) ([]string, error) {
+	if p, err := findModPath(name, db.deps); err == nil {
+		return []string{p}, nil
+	}
+	var paths []string
+	for _, a := range db.aliases {
+		if ok, _ := path.Match(a.pattern, name); !ok {
+			continue
+		}
+		p, err := findModPath(a.module, db.deps)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %w", a.pattern, err)
+		}
+		if !contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("could not find path for module %q", name)
+	}
+	return paths, nil
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// modName returns the name the kernel knows a module by, given its path.
+func modName(modPath string) string {
+	n := path.Base(modPath)
+	n = n[:strings.Index(n+".ko", ".ko")]
+	return strings.ReplaceAll(n, "-", "_")
+}
+
+// RemoveOptions unloads the given kernel module and then those of its
+// dependencies which are no longer used, as modprobe -r does.
+//
+// When opts.DryRunCB is set, it is called with the name of every module
+// which would be unloaded, instead of unloading it.
+func RemoveOptions(name string, opts ProbeOpts) error {
+	db, err := genDeps(opts)
+	if err != nil {
+		return fmt.Errorf("could not generate dependency map %v", err)
+	}
+	modPaths, err := db.resolve(name)
+	if err != nil {
+		return fmt.Errorf("could not find module path %q: %v", name, err)
+	}
+
+	users := map[string]*modUsers{}
+	if !opts.IgnoreProcMods {
+		fm, err := os.Open("/proc/modules")
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+		if users, err = readModUsers(fm); err != nil {
+			return err
+		}
+	}
+	r := remover{deps: db.deps, users: users, opts: opts}
+
+	for _, modPath := range modPaths {
+		if db.deps[modPath].state == builtin {
+			return fmt.Errorf("module %q is builtin", modName(modPath))
+		}
+		if _, ok := users[modName(modPath)]; !ok && !opts.IgnoreProcMods {
+			return fmt.Errorf("module %q is not loaded", modName(modPath))
+		}
+		if err := r.remove(modPath); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	dep.state = loading
-	for _, d := range dep.deps {
-		if err := loadDeps(d, deps, opts); err != nil {
+// modUsers is the use count of a loaded module, and the modules using it,
+// from /proc/modules.
+type modUsers struct {
+	refcnt int
+	users  []string
+}
+
+func readModUsers(r io.Reader) (map[string]*modUsers, error) {
+	m := map[string]*modUsers{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		f := strings.Fields(scanner.Text())
+		if len(f) < 4 {
+			continue
+		}
+		u := &modUsers{}
+		if _, err := fmt.Sscan(f[2], &u.refcnt); err != nil {
+			return nil, fmt.Errorf("/proc/modules: %s: %v", f[0], err)
+		}
+		for _, user := range strings.Split(f[3], ",") {
+			if user != "" && user != "-" {
+				u.users = append(u.users, user)
+			}
+		}
+		m[f[0]] = u
+	}
+	return m, scanner.Err()
+}
+
+type remover struct {
+	deps    depMap
+	users   map[string]*modUsers
+	opts    ProbeOpts
+	removed map[string]bool
+}
+
+// remove unloads a module, then its dependencies no one else uses.
+func (r *remover) remove(modPath string) error {
+	name := modName(modPath)
+	if r.removed == nil {
+		r.removed = map[string]bool{}
+	}
+	r.removed[name] = true
+	if r.opts.DryRunCB != nil {
+		r.opts.DryRunCB(name)
+	} else if err := Delete(name, unix.O_NONBLOCK); err != nil {
+		return fmt.Errorf("could not remove %q: %w", name, err)
+	}
+	delete(r.users, name)
+	for _, u := range r.users {
+		for i, user := range u.users {
+			if user == name {
+				u.users = append(u.users[:i], u.users[i+1:]...)
+				u.refcnt--
+				break
+			}
+		}
+	}
+
+	for _, d := range r.deps[modPath].deps {
+		dep, ok := r.deps[d]
+		if !ok || dep.state == builtin || r.removed[modName(d)] {
+			continue
+		}
+		// Without /proc/modules, assume the dependencies are unused.
+		if u, ok := r.users[modName(d)]; ok && u.refcnt > 0 {
+			continue
+		} else if !ok && !r.opts.IgnoreProcMods {
+			// Not loaded.
+			continue
+		}
+		if err := r.remove(d); err != nil {
 			return err
 		}
 	}
-	return loadModule(modPath, modParams, opts)
+	return nil
 }
 
 func checkBuiltin(moduleDir string, deps depMap) error {
@@ -167,14 +349,15 @@ func checkBuiltin(moduleDir string, deps depMap) error {
 	return scanner.Err()
 }
 
-func genDeps(opts ProbeOpts) (depMap, error) {
-	deps := make(depMap)
+// moduleDir returns the directory of the modules of the kernel release
+// opts.KVer, or of the running kernel.
+func moduleDir(opts ProbeOpts) (string, error) {
 	rel := opts.KVer
 
 	if rel == "" {
 		var u unix.Utsname
 		if err := unix.Uname(&u); err != nil {
-			return nil, fmt.Errorf("could not get release (uname -r): %v", err)
+			return "", fmt.Errorf("could not get release (uname -r): %v", err)
 		}
 		rel = unix.ByteSliceToString(u.Release[:])
 	}
@@ -186,6 +369,15 @@ func genDeps(opts ProbeOpts) (depMap, error) {
 			break
 		}
 	}
+	return moduleDir, nil
+}
+
+func genDeps(opts ProbeOpts) (*moduleDB, error) {
+	deps := make(depMap)
+	moduleDir, err := moduleDir(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	f, err := os.Open(filepath.Join(moduleDir, "modules.dep"))
 	if err != nil {
@@ -196,15 +388,15 @@ func genDeps(opts ProbeOpts) (depMap, error) {
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		txt := scanner.Text()
-		nameDeps := strings.Split(txt, ":")
-		modPath, modDeps := nameDeps[0], nameDeps[1]
+		modPath, modDeps, ok := strings.Cut(txt, ":")
+		if !ok {
+			continue
+		}
 		modPath = filepath.Join(moduleDir, strings.TrimSpace(modPath))
 
 		var dependency dependency
-		if len(modDeps) > 0 {
-			for _, dep := range strings.Split(strings.TrimSpace(modDeps), " ") {
-				dependency.deps = append(dependency.deps, filepath.Join(moduleDir, dep))
-			}
+		for _, dep := range strings.Fields(modDeps) {
+			dependency.deps = append(dependency.deps, filepath.Join(moduleDir, dep))
 		}
 		deps[modPath] = &dependency
 	}
@@ -217,6 +409,11 @@ func genDeps(opts ProbeOpts) (depMap, error) {
 		return nil, err
 	}
 
+	aliases, err := genAliases(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+
 	if !opts.IgnoreProcMods {
 		fm, err := os.Open("/proc/modules")
 		if err == nil {
@@ -225,7 +422,30 @@ func genDeps(opts ProbeOpts) (depMap, error) {
 		}
 	}
 
-	return deps, nil
+	return &moduleDB{deps: deps, aliases: aliases}, nil
+}
+
+// genAliases reads modules.alias, which is optional.
+func genAliases(moduleDir string) ([]alias, error) {
+	f, err := os.Open(filepath.Join(moduleDir, "modules.alias"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not open alias file: %v", err)
+	}
+	defer f.Close()
+
+	var aliases []alias
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// alias <pattern> <module>
+		f := strings.Fields(scanner.Text())
+		if len(f) != 3 || f[0] != "alias" {
+			continue
+		}
+		aliases = append(aliases, alias{pattern: f[1], module: f[2]})
+	}
+	return aliases, scanner.Err()
 }
 
 func findModPath(name string, m depMap) (string, error) {
@@ -6,7 +6,10 @@ package kmodule
 
 import (
 	"bytes"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -32,3 +35,122 @@ func TestGenLoadedMods(t *testing.T) {
 		}
 	}
 }
+
+// fakeModules writes a module directory for kernel 6.6.6 to a temporary root.
+func fakeModules(t *testing.T) string {
+	root := t.TempDir()
+	dir := filepath.Join(root, "lib/modules/6.6.6")
+	files := map[string]string{
+		"modules.dep": `kernel/drivers/net/ethernet/intel/e1000e/e1000e.ko.xz: kernel/drivers/ptp/ptp.ko.zst kernel/drivers/pps/pps_core.ko
+kernel/drivers/ptp/ptp.ko.zst: kernel/drivers/pps/pps_core.ko
+kernel/drivers/pps/pps_core.ko:
+kernel/drivers/net/ethernet/intel/igb/igb.ko: kernel/drivers/ptp/ptp.ko.zst kernel/drivers/pps/pps_core.ko kernel/drivers/i2c/i2c-algo-bit.ko
+kernel/drivers/i2c/i2c-algo-bit.ko:
+`,
+		"modules.alias": `# Aliases extracted from modules themselves.
+alias pci:v00008086d000015B8sv*sd*bc*sc*i* e1000e
+alias pci:v00008086d00001533sv*sd*bc*sc*i* igb
+alias pci:v00008086d*sv*sd*bc02sc*i* e1000e
+alias net-pf-10 ipv6
+`,
+		"modules.builtin": "kernel/net/ipv6/ipv6.ko\n",
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestProbe(t *testing.T) {
+	root := fakeModules(t)
+	for _, tt := range []struct {
+		name string
+		want []string
+	}{
+		{name: "e1000e", want: []string{"pps_core.ko", "ptp.ko.zst", "e1000e.ko.xz"}},
+		{name: "i2c_algo_bit", want: []string{"i2c-algo-bit.ko"}},
+		{name: "pci:v00008086d00001533sv00008086sd00000001bc02sc00i00", want: []string{"pps_core.ko", "ptp.ko.zst", "i2c-algo-bit.ko", "igb.ko", "e1000e.ko.xz"}},
+		{name: "net-pf-10"},
+	} {
+		var got []string
+		opts := ProbeOpts{
+			RootDir:        root,
+			KVer:           "6.6.6",
+			IgnoreProcMods: true,
+			DryRunCB:       func(p string) { got = append(got, path.Base(p)) },
+		}
+		if err := ProbeOptions(tt.name, "", opts); err != nil {
+			t.Errorf("ProbeOptions(%q) = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProbeOptions(%q) loads %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if err := ProbeOptions("nosuchmodule", "", ProbeOpts{RootDir: root, KVer: "6.6.6", IgnoreProcMods: true, DryRunCB: func(string) {}}); err == nil {
+		t.Errorf("ProbeOptions(nosuchmodule) succeeded, want error")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	root := fakeModules(t)
+	var got []string
+	opts := ProbeOpts{
+		RootDir:        root,
+		KVer:           "6.6.6",
+		IgnoreProcMods: true,
+		DryRunCB:       func(n string) { got = append(got, n) },
+	}
+	if err := RemoveOptions("e1000e", opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"e1000e", "ptp", "pps_core"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoveOptions(e1000e) removes %q, want %q", got, want)
+	}
+	if err := RemoveOptions("net-pf-10", opts); err == nil {
+		t.Errorf("RemoveOptions of a builtin module succeeded, want error")
+	}
+}
+
+func TestRemoveUsers(t *testing.T) {
+	users, err := readModUsers(bytes.NewBufferString(`e1000e 331776 0 - Live 0x0000000000000000
+igb 290816 0 - Live 0x0000000000000000
+ptp 45056 2 e1000e,igb, Live 0x0000000000000000
+pps_core 32768 1 ptp, Live 0x0000000000000000
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := genDeps(ProbeOpts{RootDir: fakeModules(t), KVer: "6.6.6", IgnoreProcMods: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	r := remover{deps: db.deps, users: users, opts: ProbeOpts{DryRunCB: func(n string) { got = append(got, n) }}}
+	p, err := findModPath("e1000e", db.deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ptp is still used by igb, so it and pps_core stay.
+	if err := r.remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"e1000e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remove(e1000e) removes %q, want %q", got, want)
+	}
+	p, err = findModPath("igb", db.deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"e1000e", "igb", "ptp", "pps_core"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remove(igb) removes %q, want %q", got, want)
+	}
+}
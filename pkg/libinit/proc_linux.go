@@ -25,6 +25,7 @@ func WaitOrphans() uint {
 			break
 		}
 		log.Printf("%v: exited with %v, status %v, rusage %v", p, err, s, r)
+		notifyExit(p, s)
 		numReaped++
 	}
 	return numReaped
@@ -91,6 +92,7 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 				break
 			} else if p != -1 {
 				debug("Reaped PID %d, exit status %d", p, s.ExitStatus())
+				notifyExit(p, s)
 			} else {
 				debug("Error from Wait4 for orphaned child: %v", err)
 				break
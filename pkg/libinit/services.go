@@ -0,0 +1,208 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+)
+
+// ServicesFile is where init looks for service configuration by default.
+const ServicesFile = "/etc/init/services.json"
+
+var (
+	// ErrUnknownService is returned for dependencies on undefined services.
+	ErrUnknownService = errors.New("unknown service")
+	// ErrServiceCycle is returned when services depend on each other.
+	ErrServiceCycle = errors.New("service dependency cycle")
+)
+
+// RestartPolicy says when a service is restarted after it exits.
+type RestartPolicy string
+
+// Restart policies.
+const (
+	RestartNever     RestartPolicy = "no"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// Duration is a time.Duration written as a string, e.g. "1s", in JSON.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	var err error
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Service is a program init starts and, depending on its restart policy,
+// keeps running.
+type Service struct {
+	Name    string   `json:"name"`
+	Command []string `json:"command"`
+	// Env is added to the environment of init, as KEY=value.
+	Env []string `json:"env,omitempty"`
+
+	// After lists services started before this one.
+	After []string `json:"after,omitempty"`
+	// Requires lists services started before this one, which must not
+	// have failed to start, or for oneshot services, failed to run.
+	Requires []string `json:"requires,omitempty"`
+
+	// Oneshot services run to completion before services after them start.
+	Oneshot bool `json:"oneshot,omitempty"`
+
+	Restart RestartPolicy `json:"restart,omitempty"`
+	// RestartDelay is how long to wait before restarting, 1s by default.
+	RestartDelay *Duration `json:"restart_delay,omitempty"`
+
+	// Cgroup is the cgroup v2 the service runs in, as a path in the
+	// hierarchy. Services with Limits run in "services/NAME" unless it
+	// is set.
+	Cgroup string `json:"cgroup,omitempty"`
+	// Limits are the limits of the service's cgroup.
+	Limits *cgroup.Limits `json:"limits,omitempty"`
+}
+
+// ServiceConfig is the declarative configuration of init's services.
+type ServiceConfig struct {
+	Services []*Service `json:"services"`
+}
+
+// ParseServices parses a JSON service configuration and checks it.
+func ParseServices(b []byte) (*ServiceConfig, error) {
+	var c ServiceConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if _, err := c.Order(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LoadServices reads a JSON service configuration from path.
+func LoadServices(path string) (*ServiceConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseServices(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (s *Service) check() error {
+	if s.Name == "" {
+		return fmt.Errorf("service without name")
+	}
+	if len(s.Command) == 0 {
+		return fmt.Errorf("service %s: no command", s.Name)
+	}
+	switch s.Restart {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("service %s: unknown restart policy %q", s.Name, s.Restart)
+	}
+	if s.Oneshot && s.Restart == RestartAlways {
+		return fmt.Errorf("service %s: oneshot services cannot always restart", s.Name)
+	}
+	if s.Limits != nil {
+		if err := s.Limits.Check(); err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// cgroupName returns the cgroup s runs in, or "" to stay in init's.
+func (s *Service) cgroupName() string {
+	if s.Cgroup == "" && s.Limits != nil {
+		return "services/" + s.Name
+	}
+	return s.Cgroup
+}
+
+// restartDelay returns the delay before restarting s.
+func (s *Service) restartDelay() time.Duration {
+	if s.RestartDelay == nil {
+		return time.Second
+	}
+	return s.RestartDelay.Duration
+}
+
+// Order returns the services in the order they are started: every service
+// after those it is ordered after or requires, and otherwise in the order
+// of the configuration.
+func (c *ServiceConfig) Order() ([]*Service, error) {
+	byName := make(map[string]*Service)
+	for _, s := range c.Services {
+		if err := s.check(); err != nil {
+			return nil, err
+		}
+		if byName[s.Name] != nil {
+			return nil, fmt.Errorf("service %s defined twice", s.Name)
+		}
+		byName[s.Name] = s
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var order []*Service
+	var visit func(s *Service) error
+	visit = func(s *Service) error {
+		switch state[s.Name] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrServiceCycle, s.Name)
+		case done:
+			return nil
+		}
+		state[s.Name] = visiting
+		for _, deps := range [][]string{s.After, s.Requires} {
+			for _, d := range deps {
+				dep, ok := byName[d]
+				if !ok {
+					return fmt.Errorf("%w %q needed by %s", ErrUnknownService, d, s.Name)
+				}
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[s.Name] = done
+		order = append(order, s)
+		return nil
+	}
+	for _, s := range c.Services {
+		if err := visit(s); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+	"github.com/u-root/u-root/pkg/metrics"
+	"golang.org/x/sys/unix"
+)
+
+// CgroupRoot is where services find the cgroup v2 hierarchy.
+var CgroupRoot = cgroup.DefaultRoot
+
+// exitHandlers maps PIDs of services to what to do when they exit. init
+// reaps all children with wait4(-1), so services cannot wait for their own
+// processes; RunCommands and WaitOrphans pass them on instead.
+var exitHandlers = struct {
+	// mu is held while starting a service, so that it cannot be reaped
+	// before its handler is registered.
+	mu sync.Mutex
+	m  map[int]func(unix.WaitStatus)
+}{m: make(map[int]func(unix.WaitStatus))}
+
+var (
+	serviceStarts   = metrics.NewCounterVec("init_service_starts_total", "Number of times a service was started.", "service")
+	serviceFailures = metrics.NewCounterVec("init_service_failures_total", "Number of times a service failed to start or exited with an error.", "service")
+	serviceRunning  = metrics.NewGaugeVec("init_service_running", "Whether a service is running.", "service")
+)
+
+// notifyExit calls the exit handler of pid, if any.
+func notifyExit(pid int, s unix.WaitStatus) {
+	exitHandlers.mu.Lock()
+	h, ok := exitHandlers.m[pid]
+	delete(exitHandlers.m, pid)
+	exitHandlers.mu.Unlock()
+	if ok {
+		go h(s)
+	}
+}
+
+// Supervisor starts services and restarts them as their policies say.
+type Supervisor struct {
+	debug func(string, ...interface{})
+
+	mu      sync.Mutex
+	failed  map[string]bool
+	stopped bool
+}
+
+// StartServices starts the services of c in order and returns the
+// Supervisor that restarts them. Services whose requirements failed are
+// not started. It returns once all oneshot services ran.
+func StartServices(c *ServiceConfig, debug func(string, ...interface{})) (*Supervisor, error) {
+	order, err := c.Order()
+	if err != nil {
+		return nil, err
+	}
+	sv := &Supervisor{debug: debug, failed: make(map[string]bool)}
+	addSupervisor(sv)
+	for _, s := range order {
+		if dep := sv.failedRequirement(s); dep != "" {
+			log.Printf("Not starting service %s: required service %s failed", s.Name, dep)
+			sv.setFailed(s.Name)
+			continue
+		}
+		if err := sv.start(s); err != nil {
+			log.Printf("Service %s: %v", s.Name, err)
+			sv.setFailed(s.Name)
+		}
+	}
+	return sv, nil
+}
+
+// Stop stops restarting services. Running services are left alone.
+func (sv *Supervisor) Stop() {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.stopped = true
+}
+
+func (sv *Supervisor) isStopped() bool {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return sv.stopped
+}
+
+// Failed returns whether service name failed to start or run.
+func (sv *Supervisor) Failed(name string) bool {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return sv.failed[name]
+}
+
+func (sv *Supervisor) setFailed(name string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.failed[name] = true
+}
+
+func (sv *Supervisor) failedRequirement(s *Service) string {
+	for _, r := range s.Requires {
+		if sv.Failed(r) {
+			return r
+		}
+	}
+	return ""
+}
+
+func (s *Service) command() *exec.Cmd {
+	c := exec.Command(s.Command[0], s.Command[1:]...)
+	c.Env = append(os.Environ(), s.Env...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	// Services run in their own session, without the console as
+	// controlling terminal.
+	c.SysProcAttr = &unix.SysProcAttr{Setsid: true}
+	return c
+}
+
+// joinCgroup makes c start in the cgroup of s, if it has one, which it
+// creates and sets the limits of. The returned directory of the cgroup
+// must stay open until c has started.
+func (s *Service) joinCgroup(c *exec.Cmd) (*os.File, error) {
+	name := s.cgroupName()
+	if name == "" {
+		return nil, nil
+	}
+	if err := cgroup.Check(CgroupRoot); err != nil {
+		return nil, err
+	}
+	cg, err := cgroup.Create(CgroupRoot, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.Limits != nil {
+		if err := cg.SetLimits(s.Limits); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(cg.Path())
+	if err != nil {
+		return nil, err
+	}
+	// Starting in the cgroup, rather than moving there, leaves no window
+	// in which the service runs without its limits.
+	c.SysProcAttr.UseCgroupFD = true
+	c.SysProcAttr.CgroupFD = int(f.Fd())
+	return f, nil
+}
+
+// start starts s, and for oneshot services, waits for it to exit.
+func (sv *Supervisor) start(s *Service) error {
+	c := s.command()
+	cg, err := s.joinCgroup(c)
+	if err != nil {
+		return fmt.Errorf("cgroup %s: %w", s.cgroupName(), err)
+	}
+	if cg != nil {
+		defer cg.Close()
+	}
+	if s.Oneshot {
+		// Oneshot services run before the commands of init, so nothing
+		// else reaps them.
+		sv.debug("Running oneshot service %s: %v", s.Name, s.Command)
+		serviceStarts.With(s.Name).Inc()
+		if err := c.Run(); err != nil {
+			serviceFailures.With(s.Name).Inc()
+			if s.Restart == RestartOnFailure {
+				time.Sleep(s.restartDelay())
+				return sv.start(s)
+			}
+			return err
+		}
+		return nil
+	}
+
+	sv.debug("Starting service %s: %v", s.Name, s.Command)
+	exitHandlers.mu.Lock()
+	defer exitHandlers.mu.Unlock()
+	serviceStarts.With(s.Name).Inc()
+	if err := c.Start(); err != nil {
+		serviceFailures.With(s.Name).Inc()
+		return err
+	}
+	serviceRunning.With(s.Name).Set(1)
+	exitHandlers.m[c.Process.Pid] = func(ws unix.WaitStatus) {
+		sv.exited(s, ws)
+	}
+	return nil
+}
+
+// exited restarts s after it exited with ws, if its policy says so.
+func (sv *Supervisor) exited(s *Service, ws unix.WaitStatus) {
+	serviceRunning.With(s.Name).Set(0)
+	failed := !ws.Exited() || ws.ExitStatus() != 0
+	if failed {
+		serviceFailures.With(s.Name).Inc()
+	}
+	if sv.isStopped() {
+		return
+	}
+	log.Printf("Service %s exited: %s", s.Name, waitStatusString(ws))
+	switch {
+	case s.Restart == RestartAlways, s.Restart == RestartOnFailure && failed:
+		time.Sleep(s.restartDelay())
+		if sv.isStopped() {
+			return
+		}
+		if err := sv.start(s); err != nil {
+			log.Printf("Restarting service %s: %v", s.Name, err)
+			sv.setFailed(s.Name)
+		}
+	case failed:
+		sv.setFailed(s.Name)
+	}
+}
+
+func waitStatusString(ws unix.WaitStatus) string {
+	if ws.Signaled() {
+		return fmt.Sprintf("signal %v", ws.Signal())
+	}
+	return fmt.Sprintf("exit status %d", ws.ExitStatus())
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestStartServices(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	sh := func(script string) []string {
+		return []string{"sh", "-c", "{ " + script + "; } >> " + out}
+	}
+	delay := &Duration{10 * time.Millisecond}
+	c := &ServiceConfig{Services: []*Service{
+		{Name: "broken", Command: []string{"sh", "-c", "exit 1"}, Oneshot: true},
+		{Name: "skipped", Command: sh("echo skipped"), Requires: []string{"broken"}},
+		{Name: "env", Command: sh("echo $GREETING"), Env: []string{"GREETING=hello"}, Oneshot: true},
+		{Name: "flaky", Command: sh("echo flaky; exit 1"), After: []string{"env"}, Restart: RestartOnFailure, RestartDelay: delay},
+	}}
+	sv, err := StartServices(c, func(string, ...interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sv.Stop()
+	if !sv.Failed("broken") || !sv.Failed("skipped") || sv.Failed("env") {
+		t.Errorf("Failed() = %v, %v, %v, want true, true, false", sv.Failed("broken"), sv.Failed("skipped"), sv.Failed("env"))
+	}
+	if n := serviceStarts.With("skipped").Value(); n != 0 {
+		t.Errorf("skipped started %d times, want 0", n)
+	}
+	if n := serviceFailures.With("broken").Value(); n != 1 {
+		t.Errorf("broken failed %d times, want 1", n)
+	}
+
+	// Reap like init does, until flaky was restarted.
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		b, _ := os.ReadFile(out)
+		if strings.Count(string(b), "flaky") >= 3 {
+			break
+		}
+		var ws unix.WaitStatus
+		if pid, err := unix.Wait4(-1, &ws, unix.WNOHANG, nil); err == nil && pid > 0 {
+			notifyExit(pid, ws)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if !strings.HasPrefix(got, "hello\nflaky\nflaky\nflaky\n") || strings.Contains(got, "skipped") {
+		t.Errorf("services wrote %q, want hello and flaky at least 3 times", got)
+	}
+	if n := serviceStarts.With("flaky").Value(); n < 3 {
+		t.Errorf("flaky started %d times, want at least 3", n)
+	}
+}
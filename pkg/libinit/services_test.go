@@ -0,0 +1,77 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+)
+
+func TestParseServices(t *testing.T) {
+	c, err := ParseServices([]byte(`{"services": [
+		{"name": "sshd", "command": ["/bbin/sshd"], "requires": ["net"], "restart": "always", "restart_delay": "5s"},
+		{"name": "syslogd", "command": ["/bbin/syslogd"], "env": ["TZ=UTC"]},
+		{"name": "net", "command": ["/bbin/dhclient", "-ipv6=false"], "oneshot": true, "after": ["syslogd"]},
+		{"name": "agent", "command": ["/bin/agent"], "limits": {"memory": "64M", "pids": "16"}},
+		{"name": "batch", "command": ["/bin/batch"], "cgroup": "work/batch"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Services[0].restartDelay(); got != 5*time.Second {
+		t.Errorf("restart delay = %v, want 5s", got)
+	}
+	if got := c.Services[1].restartDelay(); got != time.Second {
+		t.Errorf("default restart delay = %v, want 1s", got)
+	}
+
+	for i, want := range []string{"", "", "", "services/agent", "work/batch"} {
+		if got := c.Services[i].cgroupName(); got != want {
+			t.Errorf("service %s runs in cgroup %q, want %q", c.Services[i].Name, got, want)
+		}
+	}
+	if l := c.Services[3].Limits; l == nil || l.Memory != "64M" || l.Pids != "16" {
+		t.Errorf("agent limits = %+v, want memory 64M and pids 16", l)
+	}
+
+	order, err := c.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range order {
+		names = append(names, s.Name)
+	}
+	if want := []string{"syslogd", "net", "sshd", "agent", "batch"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Order() = %v, want %v", names, want)
+	}
+}
+
+func TestParseServicesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{name: "cycle", in: `{"services": [{"name": "a", "command": ["a"], "after": ["b"]}, {"name": "b", "command": ["b"], "requires": ["a"]}]}`, err: ErrServiceCycle},
+		{name: "unknown", in: `{"services": [{"name": "a", "command": ["a"], "requires": ["b"]}]}`, err: ErrUnknownService},
+		{name: "no command", in: `{"services": [{"name": "a"}]}`},
+		{name: "duplicate", in: `{"services": [{"name": "a", "command": ["a"]}, {"name": "a", "command": ["b"]}]}`},
+		{name: "bad restart", in: `{"services": [{"name": "a", "command": ["a"], "restart": "sometimes"}]}`},
+		{name: "bad delay", in: `{"services": [{"name": "a", "command": ["a"], "restart_delay": "soon"}]}`},
+		{name: "bad limits", in: `{"services": [{"name": "a", "command": ["a"], "limits": {"memory": "lots"}}]}`, err: cgroup.ErrLimit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseServices([]byte(tt.in))
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("ParseServices() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ShutdownSocket is the abstract unix socket init takes shutdown requests
+// on. Being abstract, it needs no writable file system.
+const ShutdownSocket = "@u-root/init"
+
+// ShutdownGrace is how long processes get to exit after SIGTERM before
+// they are killed.
+const ShutdownGrace = 5 * time.Second
+
+// ShutdownAction is what happens after the system is shut down.
+type ShutdownAction string
+
+// Shutdown actions.
+const (
+	Halt     ShutdownAction = "halt"
+	PowerOff ShutdownAction = "poweroff"
+	Reboot   ShutdownAction = "reboot"
+	// Kexec boots the kernel loaded with kexec -l.
+	Kexec ShutdownAction = "kexec"
+)
+
+var rebootCmds = map[ShutdownAction]uint32{
+	Halt:     unix.LINUX_REBOOT_CMD_HALT,
+	PowerOff: unix.LINUX_REBOOT_CMD_POWER_OFF,
+	Reboot:   unix.LINUX_REBOOT_CMD_RESTART,
+	Kexec:    unix.LINUX_REBOOT_CMD_KEXEC,
+}
+
+var (
+	// ErrUnknownAction is returned for shutdown actions other than the
+	// above.
+	ErrUnknownAction = errors.New("unknown shutdown action")
+	// ErrNoInit is returned by RequestShutdown if init does not listen on
+	// ShutdownSocket, e.g. because it is not u-root's init.
+	ErrNoInit = errors.New("init does not take shutdown requests")
+)
+
+// These are overridden in tests, which must not kill every process of the
+// user or reboot the machine.
+var (
+	killAll    = func(sig unix.Signal) error { return unix.Kill(-1, sig) }
+	reboot     = unix.Reboot
+	unmount    = unix.Unmount
+	remount    = func(path string) error { return unix.Mount("", path, "", unix.MS_REMOUNT|unix.MS_RDONLY, "") }
+	mountsFile = "/proc/self/mounts"
+)
+
+// supervisors are stopped on shutdown, so that they do not restart the
+// services being terminated.
+var supervisors struct {
+	mu sync.Mutex
+	s  []*Supervisor
+}
+
+func addSupervisor(sv *Supervisor) {
+	supervisors.mu.Lock()
+	defer supervisors.mu.Unlock()
+	supervisors.s = append(supervisors.s, sv)
+}
+
+func stopSupervisors() {
+	supervisors.mu.Lock()
+	defer supervisors.mu.Unlock()
+	for _, sv := range supervisors.s {
+		sv.Stop()
+	}
+}
+
+// Shutdown stops services, terminates all processes, syncs and unmounts
+// file systems, and then halts, powers off, reboots or kexecs. Processes
+// get grace to exit after SIGTERM before they are killed. Shutdown only
+// returns on error.
+func Shutdown(action ShutdownAction, grace time.Duration) error {
+	cmd, ok := rebootCmds[action]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownAction, action)
+	}
+	log.Printf("Shutting down to %s", action)
+	stopSupervisors()
+	terminateAll(grace)
+
+	unix.Sync()
+	if err := unmountAll(); err != nil {
+		// Everything left was remounted read-only, or at least synced.
+		log.Printf("Unmounting file systems: %v", err)
+	}
+	unix.Sync()
+	return reboot(int(cmd))
+}
+
+// terminateAll sends SIGTERM to all processes but init, waits up to grace
+// for them to exit, and kills the rest.
+func terminateAll(grace time.Duration) {
+	if err := killAll(unix.SIGTERM); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return
+		}
+		log.Printf("Terminating processes: %v", err)
+	}
+	if reapAll(grace) {
+		return
+	}
+	log.Printf("Killing processes that did not exit within %v", grace)
+	if err := killAll(unix.SIGKILL); err != nil && !errors.Is(err, unix.ESRCH) {
+		log.Printf("Killing processes: %v", err)
+	}
+	reapAll(grace)
+}
+
+// reapAll reaps children until there are none left, and returns false if
+// some are still running after timeout.
+func reapAll(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		var s unix.WaitStatus
+		p, err := unix.Wait4(-1, &s, unix.WNOHANG, nil)
+		switch {
+		case errors.Is(err, unix.ECHILD):
+			return true
+		case err != nil && !errors.Is(err, unix.EINTR):
+			log.Printf("Reaping processes: %v", err)
+			return true
+		case p > 0:
+			continue
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// unmountAll unmounts all file systems but the root, most recently mounted
+// first. File systems that cannot be unmounted are remounted read-only.
+func unmountAll() error {
+	b, err := os.ReadFile(mountsFile)
+	if err != nil {
+		return err
+	}
+	var targets []string
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[1] == "/" || f[2] == "rootfs" {
+			continue
+		}
+		// Octal escapes, e.g. \040 for space.
+		targets = append(targets, unescapeMount(f[1]))
+	}
+
+	var errs []error
+	for i := len(targets) - 1; i >= 0; i-- {
+		t := targets[i]
+		if err := unmount(t, 0); err == nil || errors.Is(err, unix.EINVAL) {
+			// EINVAL: already unmounted with a parent.
+			continue
+		}
+		if err := remount(t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			if _, err := fmt.Sscanf(s[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+type shutdownRequest struct {
+	Action ShutdownAction `json:"action"`
+}
+
+type shutdownReply struct {
+	Error string `json:"error,omitempty"`
+}
+
+// ListenShutdown listens on ShutdownSocket.
+func ListenShutdown() (net.Listener, error) {
+	return net.Listen("unix", ShutdownSocket)
+}
+
+// ServeShutdown takes shutdown requests from root, or the user init runs as,
+// on l, replies whether the action is valid, and calls shutdown with it. It returns when l is closed.
+func ServeShutdown(l net.Listener, shutdown func(ShutdownAction) error) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		action, err := readShutdownRequest(c)
+		reply := shutdownReply{}
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		json.NewEncoder(c).Encode(reply)
+		c.Close()
+		if err != nil {
+			log.Printf("Shutdown request: %v", err)
+			continue
+		}
+		if err := shutdown(action); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+	}
+}
+
+func readShutdownRequest(c net.Conn) (ShutdownAction, error) {
+	if uc, ok := c.(*net.UnixConn); ok {
+		raw, err := uc.SyscallConn()
+		if err != nil {
+			return "", err
+		}
+		var cred *unix.Ucred
+		var credErr error
+		if err := raw.Control(func(fd uintptr) {
+			cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+		}); err != nil {
+			return "", err
+		}
+		if credErr != nil {
+			return "", credErr
+		}
+		if cred.Uid != 0 && int(cred.Uid) != os.Getuid() {
+			return "", fmt.Errorf("uid %d may not shut down: %w", cred.Uid, os.ErrPermission)
+		}
+	}
+	var req shutdownRequest
+	if err := json.NewDecoder(bufio.NewReader(c)).Decode(&req); err != nil {
+		return "", err
+	}
+	if _, ok := rebootCmds[req.Action]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownAction, req.Action)
+	}
+	return req.Action, nil
+}
+
+// RequestShutdown asks init to shut down to action. It returns once init
+// accepted the request.
+func RequestShutdown(action ShutdownAction) error {
+	return requestShutdown(ShutdownSocket, action)
+}
+
+func requestShutdown(addr string, action ShutdownAction) error {
+	c, err := net.Dial("unix", addr)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNoInit, err)
+	}
+	defer c.Close()
+	if err := json.NewEncoder(c).Encode(shutdownRequest{Action: action}); err != nil {
+		return err
+	}
+	var reply shutdownReply
+	if err := json.NewDecoder(c).Decode(&reply); err != nil {
+		return fmt.Errorf("reading reply of init: %w", err)
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
+// HandleShutdownSignals shuts down on the signals busybox init uses:
+// SIGUSR1 halts, SIGUSR2 powers off and SIGTERM reboots.
+func HandleShutdownSignals(grace time.Duration) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGUSR1, unix.SIGUSR2, unix.SIGTERM)
+	go func() {
+		for sig := range sigs {
+			action := Reboot
+			switch sig {
+			case unix.SIGUSR1:
+				action = Halt
+			case unix.SIGUSR2:
+				action = PowerOff
+			}
+			if err := Shutdown(action, grace); err != nil {
+				log.Printf("Shutdown on %v: %v", sig, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestShutdown(t *testing.T) {
+	mounts := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(mounts, []byte(`rootfs / rootfs rw 0 0
+proc /proc proc rw 0 0
+/dev/sda1 /mnt/my\040disk ext4 rw 0 0
+tmpfs /mnt/my\040disk/tmp tmpfs rw 0 0
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sigs []unix.Signal
+	var unmounted, remounted []string
+	var rebooted int
+	oldKill, oldReboot, oldUnmount, oldRemount, oldMounts := killAll, reboot, unmount, remount, mountsFile
+	defer func() {
+		killAll, reboot, unmount, remount, mountsFile = oldKill, oldReboot, oldUnmount, oldRemount, oldMounts
+	}()
+	killAll = func(sig unix.Signal) error {
+		sigs = append(sigs, sig)
+		return unix.ESRCH
+	}
+	reboot = func(cmd int) error {
+		rebooted = cmd
+		return nil
+	}
+	unmount = func(path string, _ int) error {
+		if path == "/mnt/my disk" {
+			return unix.EBUSY
+		}
+		unmounted = append(unmounted, path)
+		return nil
+	}
+	remount = func(path string) error {
+		remounted = append(remounted, path)
+		return nil
+	}
+	mountsFile = mounts
+
+	if err := Shutdown(Kexec, time.Second); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if rebooted != unix.LINUX_REBOOT_CMD_KEXEC {
+		t.Errorf("reboot(%#x), want LINUX_REBOOT_CMD_KEXEC", rebooted)
+	}
+	if want := []unix.Signal{unix.SIGTERM}; !reflect.DeepEqual(sigs, want) {
+		t.Errorf("signals = %v, want %v", sigs, want)
+	}
+	if want := []string{"/mnt/my disk/tmp", "/proc"}; !reflect.DeepEqual(unmounted, want) {
+		t.Errorf("unmounted %q, want %q", unmounted, want)
+	}
+	if want := []string{"/mnt/my disk"}; !reflect.DeepEqual(remounted, want) {
+		t.Errorf("remounted %q, want %q", remounted, want)
+	}
+
+	if err := Shutdown("nap", time.Second); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Shutdown(nap) = %v, want %v", err, ErrUnknownAction)
+	}
+}
+
+func TestShutdownSocket(t *testing.T) {
+	addr := fmt.Sprintf("@u-root/init-test-%d", os.Getpid())
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan ShutdownAction, 1)
+	go ServeShutdown(l, func(a ShutdownAction) error {
+		got <- a
+		return nil
+	})
+	defer l.Close()
+
+	if err := requestShutdown(addr, PowerOff); err != nil {
+		t.Fatalf("requestShutdown() = %v", err)
+	}
+	if a := <-got; a != PowerOff {
+		t.Errorf("init got %q, want %q", a, PowerOff)
+	}
+	if err := requestShutdown(addr, "nap"); err == nil || errors.Is(err, ErrNoInit) {
+		t.Errorf("requestShutdown(nap) = %v, want rejection", err)
+	}
+	if err := requestShutdown(addr+"-none", Reboot); !errors.Is(err, ErrNoInit) {
+		t.Errorf("requestShutdown() without init = %v, want %v", err, ErrNoInit)
+	}
+}
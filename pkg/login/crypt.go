@@ -0,0 +1,188 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package login
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedHash is returned for password hashes of unknown methods,
+// such as DES, MD5 or yescrypt.
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// SHA-crypt limits, from https://www.akkadia.org/drepper/SHA-crypt.txt.
+const (
+	defaultRounds = 5000
+	minRounds     = 1000
+	maxRounds     = 999999999
+	maxSalt       = 16
+)
+
+// The order in which the bytes of the final digest are encoded, in groups
+// of three.
+var (
+	sha256Order = []int{0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14, 15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29}
+	sha512Order = []int{
+		0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4, 47, 5, 26, 6, 27, 48,
+		28, 49, 7, 50, 8, 29, 9, 30, 51, 31, 52, 10, 53, 11, 32, 12, 33, 54, 34, 55, 13,
+		56, 14, 35, 15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19, 62, 20, 41,
+	}
+)
+
+// encode24 encodes three bytes as n characters, least significant first.
+func encode24(b *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		b.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// repeat returns the bytes of sum repeated to n bytes.
+func repeat(sum []byte, n int) []byte {
+	r := make([]byte, 0, n)
+	for len(r) < n {
+		r = append(r, sum[:min(len(sum), n-len(r))]...)
+	}
+	return r
+}
+
+// shaCrypt computes a $5$ (SHA-256) or $6$ (SHA-512) crypt hash. setting
+// is everything before the hash, e.g. $6$rounds=10000$salt.
+func shaCrypt(setting, password string) (string, error) {
+	var newHash func() hash.Hash
+	var order []int
+	id, rest, _ := strings.Cut(strings.TrimPrefix(setting, "$"), "$")
+	switch id {
+	case "5":
+		newHash, order = sha256.New, sha256Order
+	case "6":
+		newHash, order = sha512.New, sha512Order
+	default:
+		return "", fmt.Errorf("%w: $%s$", ErrUnsupportedHash, id)
+	}
+
+	rounds, custom := defaultRounds, false
+	if r, ok := strings.CutPrefix(rest, "rounds="); ok {
+		n, s, _ := strings.Cut(r, "$")
+		v, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%w: rounds %q", ErrUnsupportedHash, n)
+		}
+		rounds, custom, rest = int(min(max(v, minRounds), maxRounds)), true, s
+	}
+	salt, _, _ := strings.Cut(rest, "$")
+	if len(salt) > maxSalt {
+		salt = salt[:maxSalt]
+	}
+	p, s := []byte(password), []byte(salt)
+
+	h := newHash()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+
+	h.Reset()
+	h.Write(p)
+	h.Write(s)
+	h.Write(repeat(b, len(p)))
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	h.Reset()
+	for range p {
+		h.Write(p)
+	}
+	pp := repeat(h.Sum(nil), len(p))
+
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	ss := repeat(h.Sum(nil), len(s))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(pp)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(ss)
+		}
+		if i%7 != 0 {
+			h.Write(pp)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pp)
+		}
+		c = h.Sum(c[:0])
+	}
+
+	var out strings.Builder
+	out.WriteString("$" + id + "$")
+	if custom {
+		fmt.Fprintf(&out, "rounds=%d$", rounds)
+	}
+	out.WriteString(salt + "$")
+	for i := 0; i+2 < len(order); i += 3 {
+		encode24(&out, c[order[i]], c[order[i+1]], c[order[i+2]], 4)
+	}
+	if id == "5" {
+		encode24(&out, 0, c[31], c[30], 3)
+	} else {
+		encode24(&out, 0, 0, c[63], 2)
+	}
+	return out.String(), nil
+}
+
+// Verify checks a password against a crypt(3) hash. SHA-256 ($5$), SHA-512
+// ($6$) and bcrypt ($2a$, $2b$, $2y$) hashes are supported.
+func Verify(hashed, password string) error {
+	switch {
+	case strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"), strings.HasPrefix(hashed, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrBadPassword
+		}
+		return err
+	case strings.HasPrefix(hashed, "$5$"), strings.HasPrefix(hashed, "$6$"):
+		h, err := shaCrypt(hashed[:strings.LastIndexByte(hashed, '$')], password)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hashed)) != 1 {
+			return ErrBadPassword
+		}
+		return nil
+	}
+	method, _, _ := strings.Cut(strings.TrimPrefix(hashed, "$"), "$")
+	if !strings.HasPrefix(hashed, "$") {
+		method = "DES"
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedHash, method)
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package login
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestShaCrypt(t *testing.T) {
+	// From https://www.akkadia.org/drepper/SHA-crypt.txt.
+	for _, tt := range []struct {
+		setting, password, want string
+	}{
+		{"$5$saltstring", "Hello world!", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"$5$rounds=10000$saltstringsaltstring", "Hello world!", "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		{"$5$rounds=10$roundstoolow", "the minimum number is still observed", "$5$rounds=1000$roundstoolow$yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC"},
+		{"$6$saltstring", "Hello world!", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"$6$rounds=10000$saltstringsaltstring", "Hello world!", "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"$6$rounds=1400$anotherlongsaltstring", "a very much longer text to encrypt.  This one even stretches over morethan one line.", "$6$rounds=1400$anotherlongsalts$POfYwTEok97VWcjxIiSOjiykti.o/pQs.wPvMxQ6Fm7I6IoYN3CmLs66x9t0oSwbtEW7o7UmJEiDwGqd8p4ur1"},
+	} {
+		got, err := shaCrypt(tt.setting, tt.password)
+		if err != nil || got != tt.want {
+			t.Errorf("shaCrypt(%s, %q) = %s, %v, want %s", tt.setting, tt.password, got, err, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	bc, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		hash, password string
+		err            error
+	}{
+		{hash: "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", password: "Hello world!"},
+		{hash: "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", password: "hello world!", err: ErrBadPassword},
+		{hash: "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA", password: "Hello world!"},
+		{hash: string(bc), password: "secret"},
+		{hash: string(bc), password: "Secret", err: ErrBadPassword},
+		{hash: "$1$salt$hash", password: "x", err: ErrUnsupportedHash},
+		{hash: "$y$j9T$salt$hash", password: "x", err: ErrUnsupportedHash},
+		{hash: "abJnggxhB/yWI", password: "x", err: ErrUnsupportedHash},
+	} {
+		if err := Verify(tt.hash, tt.password); !errors.Is(err, tt.err) {
+			t.Errorf("Verify(%s, %q) = %v, want %v", tt.hash, tt.password, err, tt.err)
+		}
+	}
+}
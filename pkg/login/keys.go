@@ -0,0 +1,60 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package login
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrBadKey is returned for keys which are not authorized for a user.
+var ErrBadKey = errors.New("key not authorized")
+
+// AuthorizedKeys returns the keys in a user's ~/.ssh/authorized_keys. Lines
+// which cannot be parsed are skipped, as by OpenSSH.
+func (db *DB) AuthorizedKeys(u *User) ([]ssh.PublicKey, error) {
+	b, err := os.ReadFile(filepath.Join(db.Root, u.Home, ".ssh", "authorized_keys"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []ssh.PublicKey
+	for len(b) > 0 {
+		k, _, _, rest, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			break
+		}
+		keys = append(keys, k)
+		b = rest
+	}
+	return keys, nil
+}
+
+// AuthenticateKey checks that a key is one of a user's authorized keys.
+// Users whose password is locked can still log in with keys, but expired
+// accounts cannot.
+func (db *DB) AuthenticateKey(name string, key ssh.PublicKey) (*User, error) {
+	u, err := db.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Usable(u); err != nil && !errors.Is(err, ErrLocked) {
+		return nil, err
+	}
+	keys, err := db.AuthorizedKeys(u)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrBadKey)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package login
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLockedOut is returned by Lockout.Check while a user must wait after
+// failed logins.
+var ErrLockedOut = errors.New("too many failed logins")
+
+// Lockout delays logins after failures: after Free failures in a row, each
+// further failure doubles the wait before the next attempt, from Delay up
+// to Max. A successful login resets it.
+type Lockout struct {
+	Free  int
+	Delay time.Duration
+	Max   time.Duration
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	users map[string]*failures
+}
+
+type failures struct {
+	n     int
+	until time.Time
+}
+
+// NewLockout returns a Lockout allowing 3 failures, then waiting from 2s up
+// to 5 minutes.
+func NewLockout() *Lockout {
+	return &Lockout{Free: 3, Delay: 2 * time.Second, Max: 5 * time.Minute}
+}
+
+func (l *Lockout) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// Check returns how long a user must wait before the next attempt, and an
+// error wrapping ErrLockedOut if it is not zero.
+func (l *Lockout) Check(name string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.users[name]
+	if !ok {
+		return 0, nil
+	}
+	if wait := f.until.Sub(l.now()); wait > 0 {
+		return wait, fmt.Errorf("%s: %w, wait %v", name, ErrLockedOut, wait.Round(time.Second))
+	}
+	return 0, nil
+}
+
+// Fail records a failed login, and returns how long the user must wait.
+func (l *Lockout) Fail(name string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.users == nil {
+		l.users = map[string]*failures{}
+	}
+	f, ok := l.users[name]
+	if !ok {
+		f = &failures{}
+		l.users[name] = f
+	}
+	f.n++
+	if f.n <= l.Free {
+		return 0
+	}
+	wait := l.Delay
+	for i := l.Free + 1; i < f.n && wait < l.Max; i++ {
+		wait *= 2
+	}
+	wait = min(wait, l.Max)
+	f.until = l.now().Add(wait)
+	return wait
+}
+
+// Reset forgets the failures of a user, after a successful login.
+func (l *Lockout) Reset(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, name)
+}
@@ -0,0 +1,253 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package login authenticates users against /etc/passwd and /etc/shadow,
+// or their SSH authorized keys, and starts their sessions.
+package login
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoUser is returned for users not in /etc/passwd.
+	ErrNoUser = errors.New("no such user")
+	// ErrBadPassword is returned for wrong passwords.
+	ErrBadPassword = errors.New("wrong password")
+	// ErrLocked is returned for accounts whose password is locked, i.e.
+	// starts with ! or *.
+	ErrLocked = errors.New("account locked")
+	// ErrExpired is returned for accounts past their expiry date.
+	ErrExpired = errors.New("account expired")
+	// ErrBadEntry is returned for lines of passwd, shadow or group files
+	// which cannot be parsed.
+	ErrBadEntry = errors.New("bad entry")
+)
+
+// User is a user of /etc/passwd, with the password hash from /etc/shadow.
+type User struct {
+	Name  string
+	UID   int
+	GID   int
+	Gecos string
+	Home  string
+	Shell string
+	// Hash is the crypt(3) hash of the password. It is empty if the user
+	// has none, and starts with ! or * if the account is locked.
+	Hash string
+	// Expire is when the account expires, or zero.
+	Expire time.Time
+}
+
+// fields splits a line of a passwd-like file, checking it has n fields.
+func fields(line string, n int) ([]string, error) {
+	f := strings.Split(line, ":")
+	if len(f) != n {
+		return nil, fmt.Errorf("%w: %q has %d fields, want %d", ErrBadEntry, line, len(f), n)
+	}
+	return f, nil
+}
+
+// entries calls parse for the non-empty, non-comment lines of a file.
+func entries(r io.Reader, parse func(string) error) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// ParsePasswd parses a passwd file, e.g.
+//
+//	root:x:0:0:root:/root:/bin/sh
+func ParsePasswd(r io.Reader) ([]*User, error) {
+	var users []*User
+	err := entries(r, func(line string) error {
+		f, err := fields(line, 7)
+		if err != nil {
+			return err
+		}
+		uid, err1 := strconv.Atoi(f[2])
+		gid, err2 := strconv.Atoi(f[3])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%w: %q has a bad UID or GID", ErrBadEntry, line)
+		}
+		users = append(users, &User{Name: f[0], Hash: f[1], UID: uid, GID: gid, Gecos: f[4], Home: f[5], Shell: f[6]})
+		return nil
+	})
+	return users, err
+}
+
+// Shadow is an entry of a shadow file.
+type Shadow struct {
+	Name string
+	Hash string
+	// Expire is when the account expires, or zero.
+	Expire time.Time
+}
+
+// ParseShadow parses a shadow file, e.g.
+//
+//	root:$6$salt$hash:19000:0:99999:7:::
+func ParseShadow(r io.Reader) ([]*Shadow, error) {
+	var shadow []*Shadow
+	err := entries(r, func(line string) error {
+		f, err := fields(line, 9)
+		if err != nil {
+			return err
+		}
+		s := &Shadow{Name: f[0], Hash: f[1]}
+		if f[7] != "" {
+			days, err := strconv.Atoi(f[7])
+			if err != nil {
+				return fmt.Errorf("%w: %q has a bad expiry date", ErrBadEntry, line)
+			}
+			s.Expire = time.Unix(int64(days)*24*60*60, 0).UTC()
+		}
+		shadow = append(shadow, s)
+		return nil
+	})
+	return shadow, err
+}
+
+// DB is the user database of a root directory.
+type DB struct {
+	// Root is the directory containing etc/passwd, usually /.
+	Root string
+	// Now returns the current time, for account expiry. If nil, time.Now
+	// is used.
+	Now func() time.Time
+}
+
+func (db *DB) open(name string) (*os.File, error) {
+	return os.Open(filepath.Join(db.Root, "etc", name))
+}
+
+func (db *DB) now() time.Time {
+	if db.Now != nil {
+		return db.Now()
+	}
+	return time.Now()
+}
+
+// Lookup returns a user, with the password hash from etc/shadow if the
+// passwd entry has x as password.
+func (db *DB) Lookup(name string) (*User, error) {
+	f, err := db.open("passwd")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	users, err := ParsePasswd(f)
+	if err != nil {
+		return nil, err
+	}
+	var u *User
+	for _, v := range users {
+		if v.Name == name {
+			u = v
+			break
+		}
+	}
+	if u == nil {
+		return nil, fmt.Errorf("%q: %w", name, ErrNoUser)
+	}
+	if u.Hash != "x" {
+		return u, nil
+	}
+
+	// Without a shadow entry, the account is locked.
+	u.Hash = "!"
+	sf, err := db.open("shadow")
+	if err != nil {
+		return nil, err
+	}
+	defer sf.Close()
+	shadow, err := ParseShadow(sf)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range shadow {
+		if s.Name == name {
+			u.Hash, u.Expire = s.Hash, s.Expire
+			break
+		}
+	}
+	return u, nil
+}
+
+// Usable returns an error if the user cannot log in at all: if the account
+// is locked or has expired.
+func (db *DB) Usable(u *User) error {
+	if strings.HasPrefix(u.Hash, "!") || strings.HasPrefix(u.Hash, "*") {
+		return fmt.Errorf("%s: %w", u.Name, ErrLocked)
+	}
+	if !u.Expire.IsZero() && !db.now().Before(u.Expire) {
+		return fmt.Errorf("%s: %w", u.Name, ErrExpired)
+	}
+	return nil
+}
+
+// Authenticate checks a user's password. Users without a password log in
+// with any, as with login(1).
+func (db *DB) Authenticate(name, password string) (*User, error) {
+	u, err := db.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Usable(u); err != nil {
+		return nil, err
+	}
+	if u.Hash == "" {
+		return u, nil
+	}
+	if err := Verify(u.Hash, password); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return u, nil
+}
+
+// Groups returns the supplementary groups of a user from etc/group.
+func (db *DB) Groups(u *User) ([]int, error) {
+	f, err := db.open("group")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var groups []int
+	err = entries(f, func(line string) error {
+		f, err := fields(line, 4)
+		if err != nil {
+			return err
+		}
+		gid, err := strconv.Atoi(f[2])
+		if err != nil {
+			return fmt.Errorf("%w: %q has a bad GID", ErrBadEntry, line)
+		}
+		for _, m := range strings.Split(f[3], ",") {
+			if m == u.Name && gid != u.GID {
+				groups = append(groups, gid)
+			}
+		}
+		return nil
+	})
+	return groups, err
+}
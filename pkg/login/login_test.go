@@ -0,0 +1,172 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package login
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// hunter2 is the hash of the password hunter2.
+const hunter2 = "$6$4n0th3rs4lt$Ea9udKnbZg.0rjs5Ya8n1Ybq19t/1C6xjwMVTFmC3DDanF2.RTjSEWWXVvtA6kWq3OIbFMlnUSBaO/jXBrQD/1"
+
+func testDB(t *testing.T, files map[string]string) *DB {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		f := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &DB{Root: root, Now: func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }}
+}
+
+var users = map[string]string{
+	"etc/passwd": `# Users.
+root:x:0:0:root:/root:/bin/sh
+alice:x:1000:1000:Alice:/home/alice:/bin/bash
+bob:x:1001:1001::/home/bob:
+old:x:1002:1002::/home/old:/bin/sh
+guest::1003:1003::/tmp:/bin/sh
+noshadow:x:1004:1004::/:/bin/sh
+`,
+	"etc/shadow": `root:!:19000:0:99999:7:::
+alice:` + hunter2 + `:19000:0:99999:7:::
+bob:*:19000:0:99999:7:::
+old:` + hunter2 + `:19000:0:99999:7::19500:
+`,
+	"etc/group": `root:x:0:
+wheel:x:10:alice,bob
+alice:x:1000:alice
+disk:x:6:root,alice
+`,
+}
+
+func TestAuthenticate(t *testing.T) {
+	db := testDB(t, users)
+	for _, tt := range []struct {
+		name, password string
+		err            error
+	}{
+		{name: "alice", password: "hunter2"},
+		{name: "alice", password: "hunter3", err: ErrBadPassword},
+		{name: "root", password: "", err: ErrLocked},
+		{name: "bob", password: "", err: ErrLocked},
+		// Expired on 2023-05-22.
+		{name: "old", password: "hunter2", err: ErrExpired},
+		{name: "guest", password: "anything"},
+		{name: "noshadow", password: "", err: ErrLocked},
+		{name: "mallory", password: "hunter2", err: ErrNoUser},
+	} {
+		u, err := db.Authenticate(tt.name, tt.password)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Authenticate(%s, %q) = %v, want %v", tt.name, tt.password, err, tt.err)
+		}
+		if err == nil && u.Name != tt.name {
+			t.Errorf("Authenticate(%s) = %s", tt.name, u.Name)
+		}
+	}
+}
+
+func TestBadEntries(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"passwd fields": {"etc/passwd": "alice:x:1000:1000\n"},
+		"passwd uid":    {"etc/passwd": "alice:x:a:1000::/:/bin/sh\n"},
+		"shadow expire": {"etc/passwd": "alice:x:1000:1000::/:/bin/sh\n", "etc/shadow": "alice:!:0:0:0:0::never:\n"},
+	} {
+		if _, err := testDB(t, files).Lookup("alice"); !errors.Is(err, ErrBadEntry) {
+			t.Errorf("%s: Lookup = %v, want %v", name, err, ErrBadEntry)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	db := testDB(t, users)
+	u, err := db.Lookup("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := db.Groups(u)
+	if err != nil || !reflect.DeepEqual(g, []int{10, 6}) {
+		t.Errorf("Groups(alice) = %v, %v, want [10 6]", g, err)
+	}
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	var keys [2]ssh.PublicKey
+	for i := range keys {
+		pub, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys[i], err = ssh.NewPublicKey(pub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"home/alice/.ssh/authorized_keys": "# Laptop.\n" + string(ssh.MarshalAuthorizedKey(keys[0])),
+		"home/bob/.ssh/authorized_keys":   "garbage\n" + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(keys[1]))) + " bob@host\n",
+		"home/old/.ssh/authorized_keys":   string(ssh.MarshalAuthorizedKey(keys[0])),
+	}
+	for k, v := range users {
+		files[k] = v
+	}
+	db := testDB(t, files)
+	for _, tt := range []struct {
+		name string
+		key  ssh.PublicKey
+		err  error
+	}{
+		{name: "alice", key: keys[0]},
+		{name: "alice", key: keys[1], err: ErrBadKey},
+		// Locked passwords do not lock out keys.
+		{name: "bob", key: keys[1]},
+		{name: "old", key: keys[0], err: ErrExpired},
+		{name: "root", key: keys[0], err: os.ErrNotExist},
+	} {
+		if _, err := db.AuthenticateKey(tt.name, tt.key); !errors.Is(err, tt.err) {
+			t.Errorf("AuthenticateKey(%s) = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &Lockout{Free: 2, Delay: time.Second, Max: 5 * time.Second, Now: func() time.Time { return now }}
+	var waits []time.Duration
+	for i := 0; i < 7; i++ {
+		waits = append(waits, l.Fail("alice"))
+	}
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	if !reflect.DeepEqual(waits, want) {
+		t.Errorf("waits %v, want %v", waits, want)
+	}
+	if wait, err := l.Check("alice"); wait != 5*time.Second || !errors.Is(err, ErrLockedOut) {
+		t.Errorf("Check = %v, %v, want 5s, %v", wait, err, ErrLockedOut)
+	}
+	if _, err := l.Check("bob"); err != nil {
+		t.Errorf("Check(bob) = %v", err)
+	}
+	now = now.Add(5 * time.Second)
+	if _, err := l.Check("alice"); err != nil {
+		t.Errorf("Check after waiting = %v", err)
+	}
+	l.Reset("alice")
+	if wait := l.Fail("alice"); wait != 0 {
+		t.Errorf("Fail after Reset = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package login
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// DefaultShell is the shell of users without one.
+var DefaultShell = "/bin/sh"
+
+// Env returns the environment of a login of the user.
+func Env(u *User) []string {
+	return []string{
+		"HOME=" + u.Home,
+		"USER=" + u.Name,
+		"LOGNAME=" + u.Name,
+		"SHELL=" + u.shell(),
+		"PATH=/usr/local/bin:/usr/bin:/bin:/usr/local/sbin:/usr/sbin:/sbin",
+	}
+}
+
+func (u *User) shell() string {
+	if u.Shell == "" {
+		return DefaultShell
+	}
+	return u.Shell
+}
+
+// Credential returns the credential to run processes as the user, with their
+// supplementary groups.
+func (db *DB) Credential(u *User) (*syscall.Credential, error) {
+	groups, err := db.Groups(u)
+	if err != nil {
+		return nil, err
+	}
+	gids := make([]uint32, len(groups))
+	for i, g := range groups {
+		gids[i] = uint32(g)
+	}
+	return &syscall.Credential{Uid: uint32(u.UID), Gid: uint32(u.GID), Groups: gids}, nil
+}
+
+// Shell returns the command for a user's login shell, running as the user in
+// their home directory, with the environment of a login.
+//
+// As with login(1), argv[0] of the shell starts with -, so it reads its
+// profile.
+func (db *DB) Shell(u *User, env ...string) (*exec.Cmd, error) {
+	cred, err := db.Credential(u)
+	if err != nil {
+		return nil, err
+	}
+	c := exec.Command(u.shell())
+	c.Args[0] = "-" + filepath.Base(u.shell())
+	c.Dir = u.Home
+	if _, err := os.Stat(c.Dir); err != nil {
+		c.Dir = "/"
+	}
+	c.Env = append(Env(u), env...)
+	c.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	return c, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package login
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	db := testDB(t, users)
+	u, err := db.Lookup("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := db.Shell(u, "TERM=vt100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Path != DefaultShell || c.Args[0] != "-sh" || c.Dir != "/" {
+		t.Errorf("Shell(bob) runs %s as %s in %s", c.Path, c.Args[0], c.Dir)
+	}
+	cred := c.SysProcAttr.Credential
+	if cred.Uid != 1001 || cred.Gid != 1001 || !reflect.DeepEqual(cred.Groups, []uint32{10}) {
+		t.Errorf("Shell(bob) credential %+v", cred)
+	}
+	if want := "USER=bob"; c.Env[1] != want || c.Env[len(c.Env)-1] != "TERM=vt100" {
+		t.Errorf("Shell(bob) env %v", c.Env)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/xts"
+)
+
+var hashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// derive derives a key of length n from secret.
+func (k kdf) derive(secret []byte, n int) ([]byte, error) {
+	switch k.Type {
+	case "pbkdf2":
+		h, err := hashFunc(k.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.Key(secret, k.Salt, k.Iterations, n, h), nil
+	case "argon2i":
+		return argon2.Key(secret, k.Salt, uint32(k.Time), uint32(k.Memory), uint8(k.CPUs), uint32(n)), nil
+	case "argon2id":
+		return argon2.IDKey(secret, k.Salt, uint32(k.Time), uint32(k.Memory), uint8(k.CPUs), uint32(n)), nil
+	default:
+		return nil, fmt.Errorf("%w: KDF %q", ErrUnsupported, k.Type)
+	}
+}
+
+// afMerge recovers a key of keySize bytes from its anti-forensic split
+// into stripes stripes, as described in the LUKS1 on-disk format
+// specification.
+func afMerge(material []byte, keySize, stripes int, h func() hash.Hash) []byte {
+	d := make([]byte, keySize)
+	for i := 0; i < stripes-1; i++ {
+		xorBytes(d, material[i*keySize:(i+1)*keySize])
+		diffuse(d, h)
+	}
+	xorBytes(d, material[(stripes-1)*keySize:stripes*keySize])
+	return d
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// diffuse hashes d in place, one digest-sized block at a time, with each
+// block prefixed by its big-endian index.
+func diffuse(d []byte, newHash func() hash.Hash) {
+	h := newHash()
+	size := h.Size()
+	var iv [4]byte
+	for i := 0; i*size < len(d); i++ {
+		end := min((i+1)*size, len(d))
+		h.Reset()
+		binary.BigEndian.PutUint32(iv[:], uint32(i))
+		h.Write(iv[:])
+		h.Write(d[i*size : end])
+		copy(d[i*size:end], h.Sum(nil))
+	}
+}
+
+// sectorCipher decrypts disk sectors with a dm-crypt cipher specification
+// such as "aes-xts-plain64" or "aes-cbc-essiv:sha256".
+type sectorCipher struct {
+	mode string
+	ivh  func() hash.Hash
+}
+
+func newSectorCipher(spec string) (*sectorCipher, error) {
+	parts := strings.SplitN(spec, "-", 3)
+	if len(parts) != 3 || parts[0] != "aes" {
+		return nil, fmt.Errorf("%w: cipher %q", ErrUnsupported, spec)
+	}
+	switch mode := parts[1] + "-" + parts[2]; {
+	case mode == "xts-plain64" || mode == "xts-plain":
+		return &sectorCipher{mode: "xts"}, nil
+	case strings.HasPrefix(mode, "cbc-essiv:"):
+		h, err := hashFunc(strings.TrimPrefix(mode, "cbc-essiv:"))
+		if err != nil {
+			return nil, err
+		}
+		return &sectorCipher{mode: "cbc-essiv", ivh: h}, nil
+	default:
+		return nil, fmt.Errorf("%w: cipher %q", ErrUnsupported, spec)
+	}
+}
+
+// decrypt decrypts data in place. data starts at sector 0 and is a
+// multiple of secSize long.
+func (c *sectorCipher) decrypt(key, data []byte, secSize int) error {
+	switch c.mode {
+	case "xts":
+		x, err := xts.NewCipher(aes.NewCipher, key)
+		if err != nil {
+			return err
+		}
+		// plain64 IVs are the little-endian sector number, which is
+		// what xts.Cipher uses as tweak.
+		for s := 0; s*secSize < len(data); s++ {
+			sec := data[s*secSize : (s+1)*secSize]
+			x.Decrypt(sec, sec, uint64(s))
+		}
+		return nil
+
+	case "cbc-essiv":
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		h := c.ivh()
+		h.Write(key)
+		salt := h.Sum(nil)
+		ivc, err := aes.NewCipher(salt)
+		if err != nil {
+			return err
+		}
+		iv := make([]byte, aes.BlockSize)
+		for s := 0; s*secSize < len(data); s++ {
+			clear(iv)
+			binary.LittleEndian.PutUint64(iv, uint64(s))
+			ivc.Encrypt(iv, iv)
+			sec := data[s*secSize : (s+1)*secSize]
+			cipher.NewCBCDecrypter(b, iv).CryptBlocks(sec, sec)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: cipher mode %q", ErrUnsupported, c.mode)
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/tss"
+	"golang.org/x/term"
+)
+
+// ErrNoKey is returned by a KeySource that has no key for a volume.
+var ErrNoKey = errors.New("no key available")
+
+// A KeySource returns the passphrase for the volume on device dev.
+type KeySource func(dev string, v *Volume) ([]byte, error)
+
+// KeyFile returns a KeySource that reads the passphrase from a file. As
+// with cryptsetup --key-file, the whole file is the passphrase.
+func KeyFile(path string) KeySource {
+	return func(string, *Volume) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// Prompt returns a KeySource that asks for the passphrase on a terminal.
+// If in is not a terminal, the source returns ErrNoKey.
+func Prompt(in *os.File, out io.Writer) KeySource {
+	return func(dev string, v *Volume) ([]byte, error) {
+		if !term.IsTerminal(int(in.Fd())) {
+			return nil, ErrNoKey
+		}
+		fmt.Fprintf(out, "Enter passphrase for %s (UUID %s): ", dev, v.UUID)
+		p, err := term.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(out)
+		return p, err
+	}
+}
+
+// TPMNV returns a KeySource that reads a size byte passphrase from the
+// TPM NV index index. Trailing zero bytes are removed.
+func TPMNV(index, size uint32, password string) KeySource {
+	return func(string, *Volume) ([]byte, error) {
+		t, err := tss.NewTPM()
+		if err != nil {
+			return nil, err
+		}
+		defer t.RWC.Close()
+		// For TPM 2.0, the index authorizes itself.
+		p, err := t.NVReadValue(index, password, size, index)
+		if err != nil {
+			return nil, fmt.Errorf("reading TPM NV index %#x: %w", index, err)
+		}
+		return bytes.TrimRight(p, "\x00"), nil
+	}
+}
+
+// Sources tries each KeySource in turn and returns the first key that
+// unlocks v.
+func Sources(r io.ReaderAt, dev string, v *Volume, sources ...KeySource) ([]byte, error) {
+	var errs []error
+	for _, s := range sources {
+		p, err := s(dev, v)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key, err := v.Unlock(r, p)
+		clear(p)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, ErrNoKey
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,532 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package luks reads LUKS1 and LUKS2 headers and recovers the volume key of
+// an encrypted volume from a passphrase.
+//
+// Only the subset of LUKS needed to boot is implemented: AES in XTS or
+// CBC-ESSIV mode, PBKDF2 and Argon2 key derivation, and read-only access to
+// the header. Volumes are opened with the kernel's dm-crypt target.
+package luks
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotLUKS is returned by Probe if there is no LUKS header.
+	ErrNotLUKS = errors.New("no LUKS header found")
+
+	// ErrWrongKey is returned by Unlock if no key slot could be opened
+	// with the given passphrase.
+	ErrWrongKey = errors.New("no key slot matches the passphrase")
+
+	// ErrUnsupported is returned for valid LUKS volumes that use features
+	// this package does not implement.
+	ErrUnsupported = errors.New("unsupported LUKS feature")
+)
+
+var luksMagic = []byte{'L', 'U', 'K', 'S', 0xba, 0xbe}
+
+// sectorSize is the sector size used for key slot material.
+const sectorSize = 512
+
+// kdf describes a key derivation function.
+type kdf struct {
+	Type       string
+	Hash       string
+	Iterations int
+	Time       int
+	Memory     int
+	CPUs       int
+	Salt       []byte
+}
+
+type keyslot struct {
+	id  int
+	kdf kdf
+	// keySize is the size of the volume key, and areaKeySize the size of
+	// the key that encrypts the key material.
+	keySize     int
+	areaKeySize int
+	// offset of the key material in bytes.
+	offset   int64
+	stripes  int
+	afHash   string
+	cipher   string
+	priority int
+}
+
+// digest verifies a candidate volume key.
+type digest struct {
+	kdf    kdf
+	digest []byte
+	// keyslots is the set of key slots the digest applies to.
+	keyslots map[int]bool
+}
+
+// Volume describes a LUKS volume.
+type Volume struct {
+	// Version is 1 or 2.
+	Version int
+	// UUID is the LUKS UUID, as used in rd.luks.uuid= and /dev/disk/by-uuid.
+	UUID string
+	// Label is the LUKS2 label, if any.
+	Label string
+
+	// Cipher is the dm-crypt cipher specification of the data segment,
+	// e.g. "aes-xts-plain64".
+	Cipher string
+	// KeySize is the size of the volume key in bytes.
+	KeySize int
+	// PayloadOffset is the offset of the encrypted data in bytes.
+	PayloadOffset int64
+	// PayloadSize is the size of the encrypted data in bytes. Zero means
+	// the data extends to the end of the device.
+	PayloadSize int64
+	// SectorSize is the encryption sector size of the data segment.
+	SectorSize int
+	// IVTweak is the initial IV offset, in sectors.
+	IVTweak uint64
+
+	keyslots []keyslot
+	digests  []digest
+}
+
+// IsLUKS reports whether r starts with a LUKS header.
+func IsLUKS(r io.ReaderAt) bool {
+	magic := make([]byte, len(luksMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, luksMagic)
+}
+
+// Probe reads the LUKS header of r.
+func Probe(r io.ReaderAt) (*Volume, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotLUKS, err)
+	}
+	if !bytes.Equal(hdr[:6], luksMagic) {
+		return nil, ErrNotLUKS
+	}
+	switch v := binary.BigEndian.Uint16(hdr[6:]); v {
+	case 1:
+		return probeLUKS1(r)
+	case 2:
+		return probeLUKS2(r)
+	default:
+		return nil, fmt.Errorf("%w: version %d", ErrUnsupported, v)
+	}
+}
+
+// luks1Header is the on-disk LUKS1 header. All fields are big-endian.
+type luks1Header struct {
+	Magic              [6]byte
+	Version            uint16
+	CipherName         [32]byte
+	CipherMode         [32]byte
+	HashSpec           [32]byte
+	PayloadOffset      uint32
+	KeyBytes           uint32
+	MKDigest           [20]byte
+	MKDigestSalt       [32]byte
+	MKDigestIterations uint32
+	UUID               [40]byte
+	KeySlots           [8]luks1KeySlot
+}
+
+type luks1KeySlot struct {
+	Active            uint32
+	Iterations        uint32
+	Salt              [32]byte
+	KeyMaterialOffset uint32
+	Stripes           uint32
+}
+
+const luks1KeyEnabled = 0x00ac71f3
+
+func cstr(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func probeLUKS1(r io.ReaderAt) (*Volume, error) {
+	var h luks1Header
+	if err := binary.Read(io.NewSectionReader(r, 0, int64(binary.Size(h))), binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	cipher := cstr(h.CipherName[:]) + "-" + cstr(h.CipherMode[:])
+	hash := cstr(h.HashSpec[:])
+	v := &Volume{
+		Version:       1,
+		UUID:          cstr(h.UUID[:]),
+		Cipher:        cipher,
+		KeySize:       int(h.KeyBytes),
+		PayloadOffset: int64(h.PayloadOffset) * sectorSize,
+		SectorSize:    sectorSize,
+	}
+	v.digests = []digest{{
+		kdf: kdf{
+			Type:       "pbkdf2",
+			Hash:       hash,
+			Iterations: int(h.MKDigestIterations),
+			Salt:       h.MKDigestSalt[:],
+		},
+		digest: h.MKDigest[:],
+	}}
+	for i, ks := range h.KeySlots {
+		if ks.Active != luks1KeyEnabled {
+			continue
+		}
+		v.keyslots = append(v.keyslots, keyslot{
+			id: i,
+			kdf: kdf{
+				Type:       "pbkdf2",
+				Hash:       hash,
+				Iterations: int(ks.Iterations),
+				Salt:       append([]byte(nil), ks.Salt[:]...),
+			},
+			keySize:     int(h.KeyBytes),
+			areaKeySize: int(h.KeyBytes),
+			offset:      int64(ks.KeyMaterialOffset) * sectorSize,
+			stripes:     int(ks.Stripes),
+			afHash:      hash,
+			cipher:      cipher,
+		})
+	}
+	return v, nil
+}
+
+// luks2Header is the binary part of a LUKS2 header, followed by the JSON
+// metadata area.
+type luks2Header struct {
+	Magic       [6]byte
+	Version     uint16
+	HdrSize     uint64
+	SeqID       uint64
+	Label       [48]byte
+	ChecksumAlg [32]byte
+	Salt        [64]byte
+	UUID        [40]byte
+	Subsystem   [48]byte
+	HdrOffset   uint64
+	_           [184]byte
+	Checksum    [64]byte
+}
+
+const luks2BinaryHeaderSize = 4096
+
+// jsonInt is an integer that LUKS2 metadata stores as a JSON string.
+type jsonInt int64
+
+func (j *jsonInt) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		// Some fields are plain numbers.
+		var i int64
+		if err := json.Unmarshal(b, &i); err != nil {
+			return err
+		}
+		*j = jsonInt(i)
+		return nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	*j = jsonInt(i)
+	return err
+}
+
+type luks2KDF struct {
+	Type       string `json:"type"`
+	Hash       string `json:"hash"`
+	Iterations int    `json:"iterations"`
+	Time       int    `json:"time"`
+	Memory     int    `json:"memory"`
+	CPUs       int    `json:"cpus"`
+	Salt       string `json:"salt"`
+}
+
+func (k luks2KDF) kdf() (kdf, error) {
+	salt, err := base64.StdEncoding.DecodeString(k.Salt)
+	if err != nil {
+		return kdf{}, fmt.Errorf("bad KDF salt: %w", err)
+	}
+	return kdf{
+		Type:       k.Type,
+		Hash:       k.Hash,
+		Iterations: k.Iterations,
+		Time:       k.Time,
+		Memory:     k.Memory,
+		CPUs:       k.CPUs,
+		Salt:       salt,
+	}, nil
+}
+
+type luks2Metadata struct {
+	Keyslots map[string]struct {
+		Type     string   `json:"type"`
+		KeySize  int      `json:"key_size"`
+		Priority *int     `json:"priority"`
+		KDF      luks2KDF `json:"kdf"`
+		AF       struct {
+			Type    string `json:"type"`
+			Stripes int    `json:"stripes"`
+			Hash    string `json:"hash"`
+		} `json:"af"`
+		Area struct {
+			Type       string  `json:"type"`
+			Offset     jsonInt `json:"offset"`
+			Size       jsonInt `json:"size"`
+			Encryption string  `json:"encryption"`
+			KeySize    int     `json:"key_size"`
+		} `json:"area"`
+	} `json:"keyslots"`
+	Segments map[string]struct {
+		Type       string  `json:"type"`
+		Offset     jsonInt `json:"offset"`
+		Size       string  `json:"size"`
+		IVTweak    jsonInt `json:"iv_tweak"`
+		Encryption string  `json:"encryption"`
+		SectorSize int     `json:"sector_size"`
+	} `json:"segments"`
+	Digests map[string]struct {
+		Type       string   `json:"type"`
+		Keyslots   []string `json:"keyslots"`
+		Segments   []string `json:"segments"`
+		Hash       string   `json:"hash"`
+		Iterations int      `json:"iterations"`
+		Salt       string   `json:"salt"`
+		Digest     string   `json:"digest"`
+	} `json:"digests"`
+}
+
+func probeLUKS2(r io.ReaderAt) (*Volume, error) {
+	var h luks2Header
+	if err := binary.Read(io.NewSectionReader(r, 0, int64(binary.Size(h))), binary.BigEndian, &h); err != nil {
+		return nil, err
+	}
+	if h.HdrSize <= luks2BinaryHeaderSize || h.HdrSize > 4<<20 {
+		return nil, fmt.Errorf("%w: bad LUKS2 header size %d", ErrNotLUKS, h.HdrSize)
+	}
+	area := make([]byte, h.HdrSize-luks2BinaryHeaderSize)
+	if _, err := r.ReadAt(area, luks2BinaryHeaderSize); err != nil {
+		return nil, err
+	}
+	var md luks2Metadata
+	if err := json.NewDecoder(bytes.NewReader(bytes.TrimRight(area, "\x00"))).Decode(&md); err != nil {
+		return nil, fmt.Errorf("LUKS2 metadata: %w", err)
+	}
+
+	v := &Volume{
+		Version: 2,
+		UUID:    cstr(h.UUID[:]),
+		Label:   cstr(h.Label[:]),
+	}
+
+	// Only single-segment volumes (i.e. not in the middle of a
+	// reencryption) are supported.
+	if len(md.Segments) != 1 {
+		return nil, fmt.Errorf("%w: %d segments", ErrUnsupported, len(md.Segments))
+	}
+	var segID string
+	for id, s := range md.Segments {
+		if s.Type != "crypt" {
+			return nil, fmt.Errorf("%w: segment type %q", ErrUnsupported, s.Type)
+		}
+		segID = id
+		v.Cipher = s.Encryption
+		v.PayloadOffset = int64(s.Offset)
+		v.SectorSize = s.SectorSize
+		v.IVTweak = uint64(s.IVTweak)
+		if s.Size != "dynamic" {
+			size, err := strconv.ParseInt(s.Size, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad segment size %q: %w", s.Size, err)
+			}
+			v.PayloadSize = size
+		}
+	}
+
+	for id, d := range md.Digests {
+		if d.Type != "pbkdf2" {
+			continue
+		}
+		applies := false
+		for _, s := range d.Segments {
+			applies = applies || s == segID
+		}
+		if !applies {
+			continue
+		}
+		salt, err := base64.StdEncoding.DecodeString(d.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("digest %s: bad salt: %w", id, err)
+		}
+		dg, err := base64.StdEncoding.DecodeString(d.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("digest %s: bad digest: %w", id, err)
+		}
+		nd := digest{
+			kdf: kdf{
+				Type:       "pbkdf2",
+				Hash:       d.Hash,
+				Iterations: d.Iterations,
+				Salt:       salt,
+			},
+			digest:   dg,
+			keyslots: make(map[int]bool),
+		}
+		for _, ks := range d.Keyslots {
+			if n, err := strconv.Atoi(ks); err == nil {
+				nd.keyslots[n] = true
+			}
+		}
+		v.digests = append(v.digests, nd)
+	}
+	if len(v.digests) == 0 {
+		return nil, fmt.Errorf("%w: no pbkdf2 digest for the data segment", ErrUnsupported)
+	}
+
+	for id, ks := range md.Keyslots {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		if ks.Type != "luks2" || ks.AF.Type != "luks1" || ks.Area.Type != "raw" {
+			continue
+		}
+		k, err := ks.KDF.kdf()
+		if err != nil {
+			return nil, fmt.Errorf("keyslot %s: %w", id, err)
+		}
+		prio := 1
+		if ks.Priority != nil {
+			prio = *ks.Priority
+		}
+		v.keyslots = append(v.keyslots, keyslot{
+			id:          n,
+			kdf:         k,
+			keySize:     ks.KeySize,
+			areaKeySize: ks.Area.KeySize,
+			offset:      int64(ks.Area.Offset),
+			stripes:     ks.AF.Stripes,
+			afHash:      ks.AF.Hash,
+			cipher:      ks.Area.Encryption,
+			priority:    prio,
+		})
+		v.KeySize = ks.KeySize
+	}
+	// Try high priority slots first, and never try slots with priority
+	// 0 ("ignore") unless explicitly asked to.
+	sortKeyslots(v.keyslots)
+	return v, nil
+}
+
+func sortKeyslots(ks []keyslot) {
+	for i := 1; i < len(ks); i++ {
+		for j := i; j > 0 && (ks[j].priority > ks[j-1].priority ||
+			(ks[j].priority == ks[j-1].priority && ks[j].id < ks[j-1].id)); j-- {
+			ks[j], ks[j-1] = ks[j-1], ks[j]
+		}
+	}
+}
+
+// Unlock returns the volume key of v, which is stored on r, using
+// passphrase.
+func (v *Volume) Unlock(r io.ReaderAt, passphrase []byte) ([]byte, error) {
+	var errs []error
+	for _, ks := range v.keyslots {
+		if ks.priority == 0 && v.Version == 2 {
+			continue
+		}
+		key, err := v.unlockSlot(r, ks, passphrase)
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, ErrWrongKey) {
+			errs = append(errs, fmt.Errorf("keyslot %d: %w", ks.id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(append([]error{ErrWrongKey}, errs...)...)
+	}
+	return nil, ErrWrongKey
+}
+
+func (v *Volume) unlockSlot(r io.ReaderAt, ks keyslot, passphrase []byte) ([]byte, error) {
+	if ks.stripes < 1 {
+		return nil, fmt.Errorf("%w: %d stripes", ErrUnsupported, ks.stripes)
+	}
+	h, err := hashFunc(ks.afHash)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newSectorCipher(ks.cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	areaKey, err := ks.kdf.derive(passphrase, ks.areaKeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(areaKey)
+
+	// The key material is padded to whole sectors.
+	size := ks.keySize * ks.stripes
+	material := make([]byte, (size+sectorSize-1)/sectorSize*sectorSize)
+	defer clear(material)
+	if _, err := r.ReadAt(material, ks.offset); err != nil {
+		return nil, fmt.Errorf("reading key material: %w", err)
+	}
+	if err := c.decrypt(areaKey, material, sectorSize); err != nil {
+		return nil, err
+	}
+
+	key := afMerge(material[:size], ks.keySize, ks.stripes, h)
+	if err := v.verify(ks, key); err != nil {
+		clear(key)
+		return nil, err
+	}
+	return key, nil
+}
+
+// verify checks key against the digest that covers key slot ks.
+func (v *Volume) verify(ks keyslot, key []byte) error {
+	for _, d := range v.digests {
+		if d.keyslots != nil && !d.keyslots[ks.id] {
+			continue
+		}
+		got, err := d.kdf.derive(key, len(d.digest))
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(got, d.digest) == 1 {
+			return nil
+		}
+	}
+	return ErrWrongKey
+}
+
+// hashFunc returns a hash constructor for a LUKS hash specification.
+func hashFunc(name string) (func() hash.Hash, error) {
+	h, ok := hashes[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: hash %q", ErrUnsupported, name)
+	}
+	return h, nil
+}
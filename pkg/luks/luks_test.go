@@ -0,0 +1,300 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/xts"
+)
+
+const (
+	testPassphrase = "correct horse battery staple"
+	testStripes    = 40
+	testIterations = 1000
+)
+
+var testKey = bytes.Repeat([]byte{0x42, 0x17}, 16)
+
+// afSplit is the inverse of afMerge. Deterministic "random" stripes are
+// good enough for tests.
+func afSplit(key []byte, stripes int, h func() hash.Hash) []byte {
+	out := make([]byte, len(key)*stripes)
+	d := make([]byte, len(key))
+	for i := 0; i < stripes-1; i++ {
+		s := out[i*len(key) : (i+1)*len(key)]
+		for j := range s {
+			s[j] = byte(i*7 + j)
+		}
+		xorBytes(d, s)
+		diffuse(d, h)
+	}
+	last := out[(stripes-1)*len(key):]
+	copy(last, d)
+	xorBytes(last, key)
+	return out
+}
+
+func encryptSectors(t *testing.T, mode string, key, data []byte) {
+	t.Helper()
+	switch mode {
+	case "xts":
+		x, err := xts.NewCipher(aes.NewCipher, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for s := 0; s*sectorSize < len(data); s++ {
+			sec := data[s*sectorSize : (s+1)*sectorSize]
+			x.Encrypt(sec, sec, uint64(s))
+		}
+	case "cbc-essiv":
+		b, _ := aes.NewCipher(key)
+		salt := sha256.Sum256(key)
+		ivc, _ := aes.NewCipher(salt[:])
+		for s := 0; s*sectorSize < len(data); s++ {
+			iv := make([]byte, aes.BlockSize)
+			binary.LittleEndian.PutUint64(iv, uint64(s))
+			ivc.Encrypt(iv, iv)
+			sec := data[s*sectorSize : (s+1)*sectorSize]
+			cipher.NewCBCEncrypter(b, iv).CryptBlocks(sec, sec)
+		}
+	}
+}
+
+// keyMaterial returns the encrypted, padded key material of a key slot.
+func keyMaterial(t *testing.T, mode string, areaKey []byte) []byte {
+	split := afSplit(testKey, testStripes, sha256.New)
+	m := make([]byte, (len(split)+sectorSize-1)/sectorSize*sectorSize)
+	copy(m, split)
+	encryptSectors(t, mode, areaKey, m)
+	return m
+}
+
+func luks1Image(t *testing.T, cipherMode, cipherModeShort string) []byte {
+	t.Helper()
+	h := luks1Header{
+		Version:            1,
+		PayloadOffset:      4096,
+		KeyBytes:           uint32(len(testKey)),
+		MKDigestIterations: testIterations,
+	}
+	copy(h.Magic[:], luksMagic)
+	copy(h.CipherName[:], "aes")
+	copy(h.CipherMode[:], cipherMode)
+	copy(h.HashSpec[:], "sha256")
+	copy(h.MKDigestSalt[:], "digest salt")
+	copy(h.UUID[:], "6f0d9f05-7a25-4ce0-8b45-1e9b4e2e4d1c")
+	copy(h.MKDigest[:], pbkdf2.Key(testKey, h.MKDigestSalt[:], testIterations, 20, sha256.New))
+
+	// Slot 0 is disabled, slot 3 is active.
+	h.KeySlots[0] = luks1KeySlot{Active: 0x0000dead}
+	ks := &h.KeySlots[3]
+	ks.Active = luks1KeyEnabled
+	ks.Iterations = testIterations
+	copy(ks.Salt[:], "slot salt")
+	ks.KeyMaterialOffset = 8
+	ks.Stripes = testStripes
+
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.BigEndian, &h); err != nil {
+		t.Fatal(err)
+	}
+	img := make([]byte, 8192*sectorSize)
+	copy(img, b.Bytes())
+	areaKey := pbkdf2.Key([]byte(testPassphrase), ks.Salt[:], testIterations, len(testKey), sha256.New)
+	copy(img[8*sectorSize:], keyMaterial(t, cipherModeShort, areaKey))
+	return img
+}
+
+// luks2Image returns a LUKS2 image whose key material is encrypted with a
+// key of areaKeySize bytes.
+func luks2Image(t *testing.T, areaKeySize int) []byte {
+	t.Helper()
+	const hdrSize = 16384
+	slotSalt := []byte("argon2 salt")
+	digestSalt := []byte("digest salt")
+	md := map[string]any{
+		"keyslots": map[string]any{
+			"1": map[string]any{
+				"type":     "luks2",
+				"key_size": len(testKey),
+				"af":       map[string]any{"type": "luks1", "stripes": testStripes, "hash": "sha256"},
+				"area":     map[string]any{"type": "raw", "offset": "32768", "size": "258048", "encryption": "aes-xts-plain64", "key_size": areaKeySize},
+				"kdf":      map[string]any{"type": "argon2id", "time": 1, "memory": 64, "cpus": 1, "salt": base64.StdEncoding.EncodeToString(slotSalt)},
+			},
+			// A slot we cannot open must not stop us.
+			"0": map[string]any{
+				"type":     "luks2",
+				"key_size": len(testKey),
+				"af":       map[string]any{"type": "luks1", "stripes": testStripes, "hash": "sha256"},
+				"area":     map[string]any{"type": "raw", "offset": "65536", "size": "4096", "encryption": "twofish-xts-plain64", "key_size": len(testKey)},
+				"kdf":      map[string]any{"type": "pbkdf2", "hash": "sha256", "iterations": 1, "salt": ""},
+			},
+		},
+		"segments": map[string]any{
+			"0": map[string]any{"type": "crypt", "offset": "16777216", "size": "dynamic", "iv_tweak": "0", "encryption": "aes-xts-plain64", "sector_size": 4096},
+		},
+		"digests": map[string]any{
+			"0": map[string]any{
+				"type":       "pbkdf2",
+				"keyslots":   []string{"0", "1"},
+				"segments":   []string{"0"},
+				"hash":       "sha256",
+				"iterations": testIterations,
+				"salt":       base64.StdEncoding.EncodeToString(digestSalt),
+				"digest":     base64.StdEncoding.EncodeToString(pbkdf2.Key(testKey, digestSalt, testIterations, 32, sha256.New)),
+			},
+		},
+	}
+	j, err := json.Marshal(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := luks2Header{Version: 2, HdrSize: hdrSize}
+	copy(h.Magic[:], luksMagic)
+	copy(h.Label[:], "root")
+	copy(h.UUID[:], "0e8a8cf4-4a7f-4cdb-a9f1-b7f4d8f2f3a1")
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.BigEndian, &h); err != nil {
+		t.Fatal(err)
+	}
+	img := make([]byte, 1<<20)
+	copy(img, b.Bytes())
+	copy(img[luks2BinaryHeaderSize:], j)
+	areaKey := argon2.IDKey([]byte(testPassphrase), slotSalt, 1, 64, 1, uint32(areaKeySize))
+	copy(img[32768:], keyMaterial(t, "xts", areaKey))
+	return img
+}
+
+func TestABISize(t *testing.T) {
+	if got := binary.Size(luks1Header{}); got != 592 {
+		t.Errorf("LUKS1 header size = %d, want 592", got)
+	}
+	if got := binary.Size(luks2Header{}); got != 512 {
+		t.Errorf("LUKS2 header size = %d, want 512", got)
+	}
+}
+
+func TestAFRoundTrip(t *testing.T) {
+	for _, n := range []int{1, 2, 4000} {
+		// 33 bytes is not a multiple of the digest size.
+		key := bytes.Repeat([]byte{0xa5}, 33)
+		if got := afMerge(afSplit(key, n, sha256.New), len(key), n, sha256.New); !bytes.Equal(got, key) {
+			t.Errorf("afMerge(afSplit(%x, %d)) = %x", key, n, got)
+		}
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		img     []byte
+		version int
+		cipher  string
+		offset  int64
+		secSize int
+	}{
+		{"luks1-xts", luks1Image(t, "xts-plain64", "xts"), 1, "aes-xts-plain64", 4096 * sectorSize, sectorSize},
+		{"luks1-cbc-essiv", luks1Image(t, "cbc-essiv:sha256", "cbc-essiv"), 1, "aes-cbc-essiv:sha256", 4096 * sectorSize, sectorSize},
+		{"luks2-argon2id", luks2Image(t, len(testKey)), 2, "aes-xts-plain64", 16 << 20, 4096},
+		// AES-256 key material for an AES-128 volume key.
+		{"luks2-area-key-size", luks2Image(t, 64), 2, "aes-xts-plain64", 16 << 20, 4096},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.img)
+			if !IsLUKS(r) {
+				t.Fatalf("IsLUKS() = false, want true")
+			}
+			v, err := Probe(r)
+			if err != nil {
+				t.Fatalf("Probe() = %v", err)
+			}
+			if v.Version != tt.version || v.Cipher != tt.cipher || v.PayloadOffset != tt.offset || v.SectorSize != tt.secSize {
+				t.Errorf("Probe() = %+v, want version %d, cipher %s, offset %d, sector size %d", v, tt.version, tt.cipher, tt.offset, tt.secSize)
+			}
+
+			key, err := v.Unlock(r, []byte(testPassphrase))
+			if err != nil {
+				t.Fatalf("Unlock() = %v", err)
+			}
+			if !bytes.Equal(key, testKey) {
+				t.Errorf("Unlock() = %x, want %x", key, testKey)
+			}
+			if _, err := v.Unlock(r, []byte("wrong")); !errors.Is(err, ErrWrongKey) {
+				t.Errorf("Unlock(wrong) = %v, want %v", err, ErrWrongKey)
+			}
+		})
+	}
+}
+
+func TestProbeErrors(t *testing.T) {
+	if _, err := Probe(bytes.NewReader(make([]byte, 4096))); !errors.Is(err, ErrNotLUKS) {
+		t.Errorf("Probe(zeros) = %v, want %v", err, ErrNotLUKS)
+	}
+	if _, err := Probe(bytes.NewReader(nil)); !errors.Is(err, ErrNotLUKS) {
+		t.Errorf("Probe(empty) = %v, want %v", err, ErrNotLUKS)
+	}
+	img := luks1Image(t, "xts-plain64", "xts")
+	img[7] = 3
+	if _, err := Probe(bytes.NewReader(img)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Probe(version 3) = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	v, err := Probe(bytes.NewReader(luks2Image(t, len(testKey))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt, err := v.Target("/dev/sda2", 32<<20, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("0 32768 crypt aes-xts-plain64 %x 0 /dev/sda2 32768 1 sector_size:4096", testKey)
+	if got := tgt.String(); got != want {
+		t.Errorf("Target() = %q, want %q", got, want)
+	}
+	if _, err := v.Target("/dev/sda2", 1<<20, testKey); err == nil || !strings.Contains(err.Error(), "too small") {
+		t.Errorf("Target(small device) = %v, want error", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	img := luks1Image(t, "xts-plain64", "xts")
+	r := bytes.NewReader(img)
+	v, err := Probe(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := func(p string) KeySource {
+		return func(string, *Volume) ([]byte, error) { return []byte(p), nil }
+	}
+	none := func(string, *Volume) ([]byte, error) { return nil, ErrNoKey }
+
+	key, err := Sources(r, "/dev/sda2", v, none, fixed("wrong"), fixed(testPassphrase))
+	if err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("Sources() = %x, %v, want %x, nil", key, err, testKey)
+	}
+	if _, err := Sources(r, "/dev/sda2", v); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Sources() = %v, want %v", err, ErrNoKey)
+	}
+	if _, err := Sources(r, "/dev/sda2", v, none, fixed("wrong")); !errors.Is(err, ErrWrongKey) || !errors.Is(err, ErrNoKey) {
+		t.Errorf("Sources() = %v, want %v and %v", err, ErrNoKey, ErrWrongKey)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+// dmCreate is replaced in tests.
+var dmCreate = dm.Create
+
+// Target returns the dm-crypt table for v, stored on device devPath of
+// devSize bytes, using the volume key key.
+func (v *Volume) Target(devPath string, devSize int64, key []byte) (dm.Target, error) {
+	size := v.PayloadSize
+	if size == 0 {
+		size = devSize - v.PayloadOffset
+	}
+	if size <= 0 {
+		return dm.Target{}, fmt.Errorf("%s is too small for its LUKS payload", devPath)
+	}
+	c := &dm.Crypt{
+		Cipher:   v.Cipher,
+		Key:      key,
+		IVOffset: v.IVTweak,
+		Device:   devPath,
+		Offset:   uint64(v.PayloadOffset / sectorSize),
+		Length:   uint64(size / sectorSize),
+	}
+	if v.SectorSize != 0 && v.SectorSize != sectorSize {
+		c.Options = []string{fmt.Sprintf("sector_size:%d", v.SectorSize)}
+	}
+	return c.Target(), nil
+}
+
+// Open unlocks the LUKS volume on devPath with the first passphrase from
+// sources that matches and maps the decrypted data to the device-mapper
+// device name.
+func Open(devPath, name string, sources ...KeySource) (*dm.Device, error) {
+	f, err := os.Open(devPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	v, err := Probe(f)
+	if err != nil {
+		return nil, err
+	}
+	key, err := Sources(f, devPath, v, sources...)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	devSize, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	t, err := v.Target(devPath, devSize, key)
+	if err != nil {
+		return nil, err
+	}
+	return dmCreate(name, []dm.Target{t}, dm.CreateOpts{
+		UUID:       fmt.Sprintf("CRYPT-LUKS%d-%s-%s", v.Version, uuidNoDashes(v.UUID), name),
+		SecureData: true,
+	})
+}
+
+func uuidNoDashes(u string) string {
+	b := make([]byte, 0, len(u))
+	for i := 0; i < len(u); i++ {
+		if u[i] != '-' {
+			b = append(b, u[i])
+		}
+	}
+	return string(b)
+}
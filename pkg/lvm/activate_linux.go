@@ -0,0 +1,94 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+// dmCreate and dmRemove are replaced in tests.
+var (
+	dmCreate = dm.Create
+	dmRemove = dm.Remove
+)
+
+// Table returns the device-mapper table of lv. All physical volumes lv
+// uses must have been found.
+func (vg *VG) Table(lv *LV) ([]dm.Target, error) {
+	var t []dm.Target
+	for _, sg := range lv.Segments {
+		if sg.Type != "striped" && sg.Type != "linear" {
+			return nil, fmt.Errorf("%w: %s/%s has a %s segment", ErrUnsupported, vg.Name, lv.Name, sg.Type)
+		}
+		if len(sg.Stripes) == 0 || sg.ExtentCount%uint64(len(sg.Stripes)) != 0 {
+			return nil, fmt.Errorf("%w: %s/%s: bad stripes", ErrSyntax, vg.Name, lv.Name)
+		}
+		var devs []string
+		for _, s := range sg.Stripes {
+			pv := vg.pv(s.PV)
+			if pv == nil || pv.Device == "" {
+				return nil, fmt.Errorf("%s/%s: physical volume %s is missing", vg.Name, lv.Name, s.PV)
+			}
+			devs = append(devs, fmt.Sprintf("%s %d", pv.Device, pv.PEStart+s.Extent*vg.ExtentSize))
+		}
+
+		target := dm.Target{
+			Start:  sg.StartExtent * vg.ExtentSize,
+			Length: sg.ExtentCount * vg.ExtentSize,
+			Type:   "linear",
+			Params: devs[0],
+		}
+		if len(devs) > 1 {
+			target.Type = "striped"
+			target.Params = fmt.Sprintf("%d %d %s", len(devs), sg.StripeSize, strings.Join(devs, " "))
+		}
+		t = append(t, target)
+	}
+	if len(t) == 0 {
+		return nil, fmt.Errorf("%w: %s/%s has no segments", ErrUnsupported, vg.Name, lv.Name)
+	}
+	return t, nil
+}
+
+// Activate creates the device-mapper device of lv. Logical volumes
+// without the WRITE flag are always activated read-only.
+func (vg *VG) Activate(lv *LV, readOnly bool) (*dm.Device, error) {
+	t, err := vg.Table(lv)
+	if err != nil {
+		return nil, err
+	}
+	return dmCreate(vg.DMName(lv), t, dm.CreateOpts{
+		UUID:     vg.DMUUID(lv),
+		ReadOnly: readOnly || !lv.Writable(),
+	})
+}
+
+// ActivateAll activates all visible logical volumes of vg. It returns the
+// devices that were created along with the errors of those that were not.
+func (vg *VG) ActivateAll(readOnly bool) ([]*dm.Device, error) {
+	var devs []*dm.Device
+	var errs error
+	for _, lv := range vg.LVs {
+		if !lv.Visible() {
+			continue
+		}
+		d, err := vg.Activate(lv, readOnly)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		devs = append(devs, d)
+	}
+	return devs, errs
+}
+
+// Deactivate removes the device-mapper device of lv.
+func (vg *VG) Deactivate(lv *LV) error {
+	return dmRemove(vg.DMName(lv))
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+func testVG(t *testing.T) *VG {
+	vg, err := ParseVG([]byte(testText("3")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vg.PVs[0].Device = "/dev/sda2"
+	vg.PVs[1].Device = "/dev/sdb"
+	return vg
+}
+
+func TestTable(t *testing.T) {
+	vg := testVG(t)
+	for _, tt := range []struct {
+		lv   string
+		want []dm.Target
+		err  error
+	}{
+		{
+			lv: "root",
+			want: []dm.Target{
+				{Start: 0, Length: 81920, Type: "linear", Params: "/dev/sda2 2048"},
+				{Start: 81920, Length: 32768, Type: "striped", Params: "2 128 /dev/sda2 83968 /dev/sdb 2048"},
+			},
+		},
+		{lv: "my-data", want: []dm.Target{{Length: 8192, Type: "linear", Params: "/dev/sdb 18432"}}},
+		{lv: "pool", err: ErrUnsupported},
+	} {
+		t.Run(tt.lv, func(t *testing.T) {
+			got, err := vg.Table(vg.LV(tt.lv))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Table() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Table() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	vg.PVs[1].Device = ""
+	if _, err := vg.Table(vg.LV("my-data")); err == nil {
+		t.Errorf("Table() with missing PV = nil, want error")
+	}
+}
+
+func TestActivate(t *testing.T) {
+	type call struct {
+		name string
+		opts dm.CreateOpts
+	}
+	var calls []call
+	dmCreate = func(name string, targets []dm.Target, opts dm.CreateOpts) (*dm.Device, error) {
+		calls = append(calls, call{name, opts})
+		return &dm.Device{Name: name, UUID: opts.UUID, Minor: uint32(len(calls))}, nil
+	}
+	var removed []string
+	dmRemove = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	defer func() { dmCreate, dmRemove = dm.Create, dm.Remove }()
+
+	vg := testVG(t)
+	devs, err := vg.ActivateAll(false)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ActivateAll() = %v, want %v for the thin pool", err, ErrUnsupported)
+	}
+	if len(devs) != 2 {
+		t.Errorf("ActivateAll() activated %d devices, want 2", len(devs))
+	}
+	want := []call{
+		{"vg--a-root", dm.CreateOpts{UUID: vg.DMUUID(vg.LV("root"))}},
+		{"vg--a-my--data", dm.CreateOpts{UUID: vg.DMUUID(vg.LV("my-data")), ReadOnly: true}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("dm.Create calls = %+v, want %+v", calls, want)
+	}
+
+	calls = nil
+	if _, err := vg.Activate(vg.LV("root"), true); err != nil || !calls[0].opts.ReadOnly {
+		t.Errorf("Activate(root, true) = %v, read-only %v; want nil, true", err, calls[0].opts.ReadOnly)
+	}
+
+	if err := vg.Deactivate(vg.LV("my-data")); err != nil || !reflect.DeepEqual(removed, []string{"vg--a-my--data"}) {
+		t.Errorf("Deactivate() = %v, removed %q", err, removed)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrSyntax is returned for metadata that does not parse.
+var ErrSyntax = errors.New("LVM2 metadata syntax error")
+
+// section is a name { ... } block of LVM2 text metadata. Values are
+// strings, int64s or []interface{} of those.
+type section struct {
+	name     string
+	values   map[string]interface{}
+	sections []*section
+}
+
+func (s *section) str(key string) string {
+	v, _ := s.values[key].(string)
+	return v
+}
+
+func (s *section) int(key string) (int64, error) {
+	v, ok := s.values[key].(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s: missing integer %q", ErrSyntax, s.name, key)
+	}
+	return v, nil
+}
+
+// strings returns an array of strings, such as status flags.
+func (s *section) strings(key string) []string {
+	a, _ := s.values[key].([]interface{})
+	var l []string
+	for _, v := range a {
+		if s, ok := v.(string); ok {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+func (s *section) child(name string) *section {
+	for _, c := range s.sections {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parser is a recursive descent parser of the LVM2 text format.
+type parser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrSyntax, p.line+1, fmt.Sprintf(format, v...))
+}
+
+// skip skips blanks and comments.
+func (p *parser) skip() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isIdent(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.+-", c) >= 0
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) && isIdent(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) value() (interface{}, error) {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of metadata")
+	}
+	switch p.s[p.pos] {
+	case '"':
+		var b strings.Builder
+		for p.pos++; p.pos < len(p.s); p.pos++ {
+			switch c := p.s[p.pos]; c {
+			case '"':
+				p.pos++
+				return b.String(), nil
+			case '\\':
+				p.pos++
+				if p.pos < len(p.s) {
+					b.WriteByte(p.s[p.pos])
+				}
+			default:
+				if c == '\n' {
+					p.line++
+				}
+				b.WriteByte(c)
+			}
+		}
+		return nil, p.errorf("unterminated string")
+	case '[':
+		p.pos++
+		a := []interface{}{}
+		for {
+			p.skip()
+			if p.pos < len(p.s) && p.s[p.pos] == ']' {
+				p.pos++
+				return a, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+			p.skip()
+			if p.pos < len(p.s) && p.s[p.pos] == ',' {
+				p.pos++
+			}
+		}
+	}
+	tok := p.ident()
+	if tok == "" {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	if n, err := strconv.ParseInt(tok, 10, 64); err == nil {
+		return n, nil
+	}
+	if _, err := strconv.ParseFloat(tok, 64); err == nil {
+		return tok, nil
+	}
+	return nil, p.errorf("unexpected %q", tok)
+}
+
+// body parses the contents of a section up to a closing brace, or the end
+// of the metadata at the top level.
+func (p *parser) body(s *section, top bool) error {
+	for {
+		p.skip()
+		if p.pos >= len(p.s) {
+			if top {
+				return nil
+			}
+			return p.errorf("missing } of %s", s.name)
+		}
+		if p.s[p.pos] == '}' {
+			if top {
+				return p.errorf("unexpected }")
+			}
+			p.pos++
+			return nil
+		}
+		name := p.ident()
+		if name == "" {
+			return p.errorf("unexpected %q", p.s[p.pos])
+		}
+		p.skip()
+		switch {
+		case p.pos < len(p.s) && p.s[p.pos] == '{':
+			p.pos++
+			c := &section{name: name, values: map[string]interface{}{}}
+			if err := p.body(c, false); err != nil {
+				return err
+			}
+			s.sections = append(s.sections, c)
+		case p.pos < len(p.s) && p.s[p.pos] == '=':
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			s.values[name] = v
+		default:
+			return p.errorf("expected = or { after %s", name)
+		}
+	}
+}
+
+// parseConfig parses LVM2 text metadata.
+func parseConfig(text string) (*section, error) {
+	p := &parser{s: text}
+	s := &section{values: map[string]interface{}{}}
+	if err := p.body(s, true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lvm reads LVM2 physical volume labels and volume group metadata
+// and activates logical volumes through the device-mapper.
+//
+// Only what is needed to boot from LVM is supported: linear and striped
+// logical volumes can be activated. Volume groups are never modified.
+package lvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrNoLabel is returned for devices that are not physical volumes.
+	ErrNoLabel = errors.New("no LVM2 label found")
+
+	// ErrChecksum is returned if a label or metadata checksum is wrong.
+	ErrChecksum = errors.New("LVM2 checksum mismatch")
+
+	// ErrNoMetadata is returned for physical volumes without metadata.
+	ErrNoMetadata = errors.New("no LVM2 metadata found")
+)
+
+const (
+	sectorSize    = 512
+	labelScan     = 4
+	mdaHeaderSize = 512
+	mdaMagic      = " LVM2 x[5A%r0N*>"
+	initialCRC    = 0xf597a6cf
+
+	// rawLocnIgnored marks metadata copies LVM was told not to use.
+	rawLocnIgnored = 1
+)
+
+// crc is the CRC LVM2 uses: CRC-32 without the final inversion.
+func crc(p []byte) uint32 {
+	return ^crc32.Update(^uint32(initialCRC), crc32.IEEETable, p)
+}
+
+// Area is a data or metadata area on a physical volume, in bytes.
+type Area struct {
+	Offset uint64
+	Size   uint64
+}
+
+// PV is the label of an LVM2 physical volume.
+type PV struct {
+	// UUID is the physical volume ID, formatted like in the metadata.
+	UUID string
+
+	// Size of the device as LVM2 saw it, in bytes.
+	Size uint64
+
+	DataAreas     []Area
+	MetadataAreas []Area
+}
+
+// formatID inserts dashes into a 32 character LVM2 ID.
+func formatID(id string) string {
+	if len(id) != 32 {
+		return id
+	}
+	return strings.Join([]string{id[0:6], id[6:10], id[10:14], id[14:18], id[18:22], id[22:26], id[26:32]}, "-")
+}
+
+// ReadPV reads the LVM2 label of a physical volume.
+func ReadPV(r io.ReaderAt) (*PV, error) {
+	le := binary.LittleEndian
+	for s := uint64(0); s < labelScan; s++ {
+		l := make([]byte, sectorSize)
+		if _, err := r.ReadAt(l, int64(s*sectorSize)); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		if string(l[:8]) != "LABELONE" || le.Uint64(l[8:]) != s {
+			continue
+		}
+		if string(l[24:32]) != "LVM2 001" {
+			return nil, fmt.Errorf("%w: unsupported label type %q", ErrNoLabel, l[24:32])
+		}
+		if crc(l[20:]) != le.Uint32(l[16:]) {
+			return nil, fmt.Errorf("%w in label", ErrChecksum)
+		}
+
+		h := l[le.Uint32(l[20:]):]
+		if len(h) < 40 {
+			return nil, fmt.Errorf("%w: label header out of bounds", ErrNoLabel)
+		}
+		pv := &PV{UUID: formatID(string(h[:32])), Size: le.Uint64(h[32:])}
+		// Both lists end with a zero entry.
+		areas := &pv.DataAreas
+		for i := 40; i+16 <= len(h); i += 16 {
+			a := Area{Offset: le.Uint64(h[i:]), Size: le.Uint64(h[i+8:])}
+			if a.Offset == 0 {
+				if areas == &pv.MetadataAreas {
+					break
+				}
+				areas = &pv.MetadataAreas
+				continue
+			}
+			*areas = append(*areas, a)
+		}
+		return pv, nil
+	}
+	return nil, ErrNoLabel
+}
+
+// Metadata returns the text of the current volume group metadata from the
+// first metadata area of the physical volume that has one.
+func (pv *PV) Metadata(r io.ReaderAt) ([]byte, error) {
+	le := binary.LittleEndian
+	for _, a := range pv.MetadataAreas {
+		h := make([]byte, mdaHeaderSize)
+		if _, err := r.ReadAt(h, int64(a.Offset)); err != nil {
+			return nil, err
+		}
+		if string(h[4:20]) != mdaMagic || le.Uint64(h[24:]) != a.Offset {
+			return nil, fmt.Errorf("%w: bad metadata area header at %d", ErrNoMetadata, a.Offset)
+		}
+		if crc(h[4:]) != le.Uint32(h) {
+			return nil, fmt.Errorf("%w in metadata area header at %d", ErrChecksum, a.Offset)
+		}
+		size := le.Uint64(h[32:])
+
+		// The first raw location is the committed metadata.
+		off, n := le.Uint64(h[40:]), le.Uint64(h[48:])
+		sum, flags := le.Uint32(h[56:]), le.Uint32(h[60:])
+		if off == 0 || n == 0 || flags&rawLocnIgnored != 0 {
+			continue
+		}
+		if off >= size || n > size-mdaHeaderSize {
+			return nil, fmt.Errorf("%w: metadata location out of bounds", ErrNoMetadata)
+		}
+
+		// The metadata area is a ring buffer after the header.
+		text := make([]byte, n)
+		first := n
+		if off+n > size {
+			first = size - off
+		}
+		if _, err := r.ReadAt(text[:first], int64(a.Offset+off)); err != nil {
+			return nil, err
+		}
+		if first < n {
+			if _, err := r.ReadAt(text[first:], int64(a.Offset+mdaHeaderSize)); err != nil {
+				return nil, err
+			}
+		}
+		if crc(text) != sum {
+			return nil, fmt.Errorf("%w in metadata", ErrChecksum)
+		}
+		return bytes.TrimRight(text, "\x00"), nil
+	}
+	return nil, ErrNoMetadata
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrUnsupported is returned for logical volumes whose segment types
+// cannot be activated.
+var ErrUnsupported = errors.New("unsupported logical volume")
+
+// VG is an LVM2 volume group.
+type VG struct {
+	Name  string
+	ID    string
+	Seqno int64
+
+	// ExtentSize is in 512 byte sectors.
+	ExtentSize uint64
+
+	PVs []*PVInfo
+	LVs []*LV
+}
+
+// PVInfo is a physical volume of a volume group.
+type PVInfo struct {
+	// Name is the name the metadata refers to the PV by, e.g. pv0.
+	Name string
+	ID   string
+
+	// Device is where the PV was found, or "" if it is missing.
+	Device string
+
+	// PEStart is the first sector of the first extent.
+	PEStart uint64
+	PECount uint64
+}
+
+// LV is a logical volume.
+type LV struct {
+	Name     string
+	ID       string
+	Status   []string
+	Segments []Segment
+}
+
+// Segment maps a range of logical extents to physical volumes.
+type Segment struct {
+	StartExtent uint64
+	ExtentCount uint64
+	Type        string
+
+	// StripeSize is in sectors; it is 0 for a single stripe.
+	StripeSize uint64
+	Stripes    []Stripe
+}
+
+// Stripe is the start of a segment stripe on a physical volume.
+type Stripe struct {
+	PV     string
+	Extent uint64
+}
+
+func hasFlag(flags []string, f string) bool {
+	for _, s := range flags {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible tells if lv is a volume users see, as opposed to internal
+// volumes of thin pools, mirrors or snapshots.
+func (lv *LV) Visible() bool {
+	return hasFlag(lv.Status, "VISIBLE")
+}
+
+// Writable tells if lv may be activated read-write.
+func (lv *LV) Writable() bool {
+	return hasFlag(lv.Status, "WRITE")
+}
+
+// ParseVG parses LVM2 text metadata.
+func ParseVG(text []byte) (*VG, error) {
+	top, err := parseConfig(string(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(top.sections) != 1 {
+		return nil, fmt.Errorf("%w: want one volume group, got %d", ErrSyntax, len(top.sections))
+	}
+	s := top.sections[0]
+	vg := &VG{Name: s.name, ID: s.str("id")}
+	if vg.Seqno, err = s.int("seqno"); err != nil {
+		return nil, err
+	}
+	es, err := s.int("extent_size")
+	if err != nil {
+		return nil, err
+	}
+	vg.ExtentSize = uint64(es)
+
+	if pvs := s.child("physical_volumes"); pvs != nil {
+		for _, p := range pvs.sections {
+			pv := &PVInfo{Name: p.name, ID: p.str("id")}
+			start, err := p.int("pe_start")
+			if err != nil {
+				return nil, err
+			}
+			count, err := p.int("pe_count")
+			if err != nil {
+				return nil, err
+			}
+			pv.PEStart, pv.PECount = uint64(start), uint64(count)
+			vg.PVs = append(vg.PVs, pv)
+		}
+	}
+
+	if lvs := s.child("logical_volumes"); lvs != nil {
+		for _, l := range lvs.sections {
+			lv := &LV{Name: l.name, ID: l.str("id"), Status: l.strings("status")}
+			for _, seg := range l.sections {
+				sg, err := parseSegment(seg)
+				if err != nil {
+					return nil, fmt.Errorf("%s/%s: %w", vg.Name, lv.Name, err)
+				}
+				lv.Segments = append(lv.Segments, sg)
+			}
+			vg.LVs = append(vg.LVs, lv)
+		}
+	}
+	return vg, nil
+}
+
+func parseSegment(s *section) (Segment, error) {
+	var sg Segment
+	start, err := s.int("start_extent")
+	if err != nil {
+		return sg, err
+	}
+	count, err := s.int("extent_count")
+	if err != nil {
+		return sg, err
+	}
+	sg.StartExtent, sg.ExtentCount, sg.Type = uint64(start), uint64(count), s.str("type")
+	if ss, ok := s.values["stripe_size"].(int64); ok {
+		sg.StripeSize = uint64(ss)
+	}
+	// stripes = [ "pv0", 0, "pv1", 0 ]
+	stripes, _ := s.values["stripes"].([]interface{})
+	for i := 0; i+1 < len(stripes); i += 2 {
+		pv, ok1 := stripes[i].(string)
+		ext, ok2 := stripes[i+1].(int64)
+		if !ok1 || !ok2 {
+			return sg, fmt.Errorf("%w: bad stripes in %s", ErrSyntax, s.name)
+		}
+		sg.Stripes = append(sg.Stripes, Stripe{PV: pv, Extent: uint64(ext)})
+	}
+	return sg, nil
+}
+
+// LV returns the logical volume with the given name.
+func (vg *VG) LV(name string) *LV {
+	for _, lv := range vg.LVs {
+		if lv.Name == name {
+			return lv
+		}
+	}
+	return nil
+}
+
+func (vg *VG) pv(name string) *PVInfo {
+	for _, pv := range vg.PVs {
+		if pv.Name == name {
+			return pv
+		}
+	}
+	return nil
+}
+
+// DMName returns the device-mapper name of lv, vg-lv with dashes in
+// either name doubled.
+func (vg *VG) DMName(lv *LV) string {
+	return strings.ReplaceAll(vg.Name, "-", "--") + "-" + strings.ReplaceAll(lv.Name, "-", "--")
+}
+
+// DMUUID returns the device-mapper UUID LVM2 gives lv.
+func (vg *VG) DMUUID(lv *LV) string {
+	return "LVM-" + strings.ReplaceAll(vg.ID, "-", "") + strings.ReplaceAll(lv.ID, "-", "")
+}
+
+// Scan reads the physical volumes among devices and returns the volume
+// groups they belong to, with the devices of their physical volumes
+// filled in. Devices that are not physical volumes are skipped.
+//
+// Errors reading physical volumes are joined and returned along with the
+// volume groups that could be read.
+func Scan(devices []string) ([]*VG, error) {
+	var vgs []*VG
+	var errs error
+	byID := map[string]*VG{}
+	pvDevice := map[string]string{}
+	for _, d := range devices {
+		vg, pvID, err := scanDevice(d)
+		if errors.Is(err, ErrNoLabel) {
+			continue
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", d, err))
+			continue
+		}
+		pvDevice[pvID] = d
+		if vg == nil {
+			continue
+		}
+		// All PVs carry the metadata; newer copies win.
+		old, ok := byID[vg.ID]
+		switch {
+		case !ok:
+			vgs = append(vgs, vg)
+		case old.Seqno < vg.Seqno:
+			for i := range vgs {
+				if vgs[i] == old {
+					vgs[i] = vg
+				}
+			}
+		default:
+			continue
+		}
+		byID[vg.ID] = vg
+	}
+	for _, vg := range vgs {
+		for _, pv := range vg.PVs {
+			pv.Device = pvDevice[pv.ID]
+		}
+	}
+	return vgs, errs
+}
+
+// scanDevice returns the PV ID of device and the volume group in its
+// metadata, which is nil for PVs without metadata areas.
+func scanDevice(device string) (*VG, string, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	pv, err := ReadPV(f)
+	if err != nil {
+		return nil, "", err
+	}
+	text, err := pv.Metadata(f)
+	if errors.Is(err, ErrNoMetadata) && len(pv.MetadataAreas) == 0 {
+		return nil, pv.UUID, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	vg, err := ParseVG(text)
+	return vg, pv.UUID, err
+}
@@ -0,0 +1,349 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testVGID = "Zf3tQp-8cJm-1xYw-Lr2v-0aNd-Hk5u-Qe7bWs"
+	pv0ID    = "q8WvCx-3LkT-Rf9n-Ue2d-Ym7a-Hs4p-Gb1zKo"
+	pv1ID    = "Jt6nAe-2WqR-Lc8x-Dv5m-Ps3k-Yf9u-Nh0bTz"
+
+	mdaOffset = 4096
+	mdaSize   = 64 << 10
+)
+
+const testMetadata = `# Generated by LVM2
+vg-a {
+	id = "` + testVGID + `"
+	seqno = %SEQNO%
+	format = "lvm2" # informational
+	status = ["RESIZEABLE", "READ", "WRITE"]
+	extent_size = 8192
+	max_lv = 0
+
+	physical_volumes {
+		pv0 {
+			id = "` + pv0ID + `"
+			device = "/dev/sda2"
+			dev_size = 2097152
+			pe_start = 2048
+			pe_count = 255
+		}
+		pv1 {
+			id = "` + pv1ID + `"
+			pe_start = 2048
+			pe_count = 255
+		}
+	}
+
+	logical_volumes {
+		root {
+			id = "Ab1cDe-2fGh-3iJk-4lMn-5oPq-6rSt-7uVwXy"
+			status = ["READ", "WRITE", "VISIBLE"]
+			segment_count = 2
+
+			segment1 {
+				start_extent = 0
+				extent_count = 10
+				type = "striped"
+				stripe_count = 1
+				stripes = [
+					"pv0", 0
+				]
+			}
+			segment2 {
+				start_extent = 10
+				extent_count = 4
+				type = "striped"
+				stripe_count = 2
+				stripe_size = 128
+				stripes = [
+					"pv0", 10,
+					"pv1", 0
+				]
+			}
+		}
+		my-data {
+			id = "Zy9xWv-8uTs-7rQp-6oNm-5lKj-4iHg-3fEdCb"
+			status = ["READ", "VISIBLE"]
+			segment_count = 1
+
+			segment1 {
+				start_extent = 0
+				extent_count = 1
+				type = "striped"
+				stripe_count = 1
+				stripes = ["pv1", 2]
+			}
+		}
+		pool_tmeta {
+			id = "Qw1eRt-2yUi-3oPa-4sDf-5gHj-6kLz-7xCvBn"
+			status = ["READ", "WRITE"]
+			segment_count = 1
+
+			segment1 {
+				start_extent = 0
+				extent_count = 1
+				type = "striped"
+				stripe_count = 1
+				stripes = ["pv1", 3]
+			}
+		}
+		pool {
+			id = "Mn1bVc-2xZl-3kJh-4gFd-5sAp-6oIu-7yTrEw"
+			status = ["READ", "WRITE", "VISIBLE"]
+			segment_count = 1
+
+			segment1 {
+				start_extent = 0
+				extent_count = 1
+				type = "thin-pool"
+				metadata = "pool_tmeta"
+			}
+		}
+	}
+}
+contents = "Text Format Volume Group"
+version = 1
+description = "Created *after* executing 'lvcreate -n \"my-data\"'"
+creation_time = 1709294400	# Fri Mar  1 12:00:00 2024
+`
+
+func testText(seqno string) string {
+	return strings.Replace(testMetadata, "%SEQNO%", seqno, 1)
+}
+
+// pvImage returns a physical volume image with the label in sector 1. If
+// text is not empty, it is written as metadata starting at textOffset
+// into the metadata area, wrapping around its end.
+func pvImage(id, text string, textOffset uint64) []byte {
+	le := binary.LittleEndian
+	img := make([]byte, 1<<20)
+
+	l := img[512:1024]
+	copy(l, "LABELONE")
+	le.PutUint64(l[8:], 1)
+	le.PutUint32(l[20:], 32)
+	copy(l[24:], "LVM2 001")
+	h := l[32:]
+	copy(h, strings.ReplaceAll(id, "-", ""))
+	le.PutUint64(h[32:], uint64(len(img)))
+	// One data area, then one metadata area if there is metadata.
+	le.PutUint64(h[40:], 1<<20)
+	if text != "" {
+		le.PutUint64(h[72:], mdaOffset)
+		le.PutUint64(h[80:], mdaSize)
+	}
+	le.PutUint32(l[16:], crc(l[20:]))
+
+	if text == "" {
+		return img
+	}
+	mda := img[mdaOffset : mdaOffset+mdaSize]
+	copy(mda[4:], mdaMagic)
+	le.PutUint32(mda[20:], 1)
+	le.PutUint64(mda[24:], mdaOffset)
+	le.PutUint64(mda[32:], mdaSize)
+	le.PutUint64(mda[40:], textOffset)
+	le.PutUint64(mda[48:], uint64(len(text)))
+	le.PutUint32(mda[56:], crc([]byte(text)))
+	le.PutUint32(mda, crc(mda[4:mdaHeaderSize]))
+
+	n := copy(mda[textOffset:], text)
+	copy(mda[mdaHeaderSize:], text[n:])
+	return img
+}
+
+func TestReadPV(t *testing.T) {
+	text := testText("3")
+	wrapped := pvImage(pv0ID, text, mdaSize-100)
+	badLabel := pvImage(pv0ID, text, 512)
+	badLabel[512+40]++
+	badText := pvImage(pv0ID, text, 512)
+	badText[mdaOffset+600]++
+
+	for _, tt := range []struct {
+		name    string
+		img     []byte
+		pv      *PV
+		err     error
+		mdaErr  error
+		wantMDA bool
+	}{
+		{
+			name: "plain",
+			img:  pvImage(pv0ID, text, 512),
+			pv: &PV{
+				UUID:          pv0ID,
+				Size:          1 << 20,
+				DataAreas:     []Area{{Offset: 1 << 20}},
+				MetadataAreas: []Area{{Offset: mdaOffset, Size: mdaSize}},
+			},
+			wantMDA: true,
+		},
+		{
+			name: "wrapped",
+			img:  wrapped,
+			pv: &PV{
+				UUID:          pv0ID,
+				Size:          1 << 20,
+				DataAreas:     []Area{{Offset: 1 << 20}},
+				MetadataAreas: []Area{{Offset: mdaOffset, Size: mdaSize}},
+			},
+			wantMDA: true,
+		},
+		{
+			name:   "no metadata",
+			img:    pvImage(pv1ID, "", 0),
+			pv:     &PV{UUID: pv1ID, Size: 1 << 20, DataAreas: []Area{{Offset: 1 << 20}}},
+			mdaErr: ErrNoMetadata,
+		},
+		{name: "label checksum", img: badLabel, err: ErrChecksum},
+		{
+			name: "text checksum",
+			img:  badText,
+			pv: &PV{
+				UUID:          pv0ID,
+				Size:          1 << 20,
+				DataAreas:     []Area{{Offset: 1 << 20}},
+				MetadataAreas: []Area{{Offset: mdaOffset, Size: mdaSize}},
+			},
+			mdaErr: ErrChecksum,
+		},
+		{name: "no label", img: make([]byte, 4096), err: ErrNoLabel},
+		{name: "short", img: make([]byte, 100), err: ErrNoLabel},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.img)
+			pv, err := ReadPV(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReadPV() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(pv, tt.pv) {
+				t.Errorf("ReadPV() = %+v, want %+v", pv, tt.pv)
+			}
+			if err != nil {
+				return
+			}
+			got, err := pv.Metadata(r)
+			if !errors.Is(err, tt.mdaErr) {
+				t.Fatalf("Metadata() = %v, want %v", err, tt.mdaErr)
+			}
+			if tt.wantMDA && string(got) != text {
+				t.Errorf("Metadata() = %q, want %q", got, text)
+			}
+		})
+	}
+}
+
+func TestParseVG(t *testing.T) {
+	vg, err := ParseVG([]byte(testText("3")))
+	if err != nil {
+		t.Fatalf("ParseVG() = %v", err)
+	}
+	if vg.Name != "vg-a" || vg.ID != testVGID || vg.Seqno != 3 || vg.ExtentSize != 8192 {
+		t.Errorf("ParseVG() = %+v, want vg-a %s seqno 3 extent size 8192", vg, testVGID)
+	}
+	wantPVs := []*PVInfo{
+		{Name: "pv0", ID: pv0ID, PEStart: 2048, PECount: 255},
+		{Name: "pv1", ID: pv1ID, PEStart: 2048, PECount: 255},
+	}
+	if !reflect.DeepEqual(vg.PVs, wantPVs) {
+		t.Errorf("PVs = %+v, want %+v", vg.PVs, wantPVs)
+	}
+	if len(vg.LVs) != 4 {
+		t.Fatalf("got %d LVs, want 4", len(vg.LVs))
+	}
+	root := vg.LV("root")
+	wantSegs := []Segment{
+		{StartExtent: 0, ExtentCount: 10, Type: "striped", Stripes: []Stripe{{"pv0", 0}}},
+		{StartExtent: 10, ExtentCount: 4, Type: "striped", StripeSize: 128, Stripes: []Stripe{{"pv0", 10}, {"pv1", 0}}},
+	}
+	if root == nil || !reflect.DeepEqual(root.Segments, wantSegs) {
+		t.Errorf("root = %+v, want segments %+v", root, wantSegs)
+	}
+	if !root.Visible() || !root.Writable() {
+		t.Errorf("root is not a visible writable LV")
+	}
+	if data := vg.LV("my-data"); data.Writable() {
+		t.Errorf("my-data is writable")
+	}
+	if vg.LV("pool_tmeta").Visible() {
+		t.Errorf("pool_tmeta is visible")
+	}
+	if got, want := vg.DMName(vg.LV("my-data")), "vg--a-my--data"; got != want {
+		t.Errorf("DMName() = %q, want %q", got, want)
+	}
+	if got, want := vg.DMUUID(root), "LVM-Zf3tQp8cJm1xYwLr2v0aNdHk5uQe7bWsAb1cDe2fGh3iJk4lMn5oPq6rSt7uVwXy"; got != want {
+		t.Errorf("DMUUID() = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{
+		`vg { seqno = 1 extent_size = 8 `,
+		`vg { seqno = 1 extent_size = 8 } }`,
+		`vg { id = "x }`,
+		`vg { seqno = , }`,
+		`vg { extent_size = 8 }`,
+		`a { seqno = 1 extent_size = 8 } b { seqno = 1 extent_size = 8 }`,
+		`vg { seqno = 1 extent_size = 8 logical_volumes { lv { s { start_extent = 0 extent_count = 1 stripes = [1, "pv0"] } } } }`,
+	} {
+		if _, err := ParseVG([]byte(bad)); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseVG(%q) = %v, want %v", bad, err, ErrSyntax)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, img []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, img, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	// stale claims to be pv1 as well, but has older metadata and is
+	// scanned before the real pv1.
+	stale := write("stale", pvImage(pv1ID, testText("2"), 512))
+	pv0 := write("pv0", pvImage(pv0ID, testText("3"), 512))
+	pv1 := write("pv1", pvImage(pv1ID, "", 0))
+	other := write("other", make([]byte, 8192))
+	corrupt := pvImage(pv0ID, testText("3"), 512)
+	corrupt[mdaOffset+1000]++
+	bad := write("bad", corrupt)
+
+	vgs, err := Scan([]string{stale, other, pv0, pv1})
+	if err != nil {
+		t.Fatalf("Scan() = %v", err)
+	}
+	if len(vgs) != 1 || vgs[0].Seqno != 3 {
+		t.Fatalf("Scan() = %+v, want one VG with seqno 3", vgs)
+	}
+	var got []string
+	for _, pv := range vgs[0].PVs {
+		got = append(got, pv.Device)
+	}
+	if want := []string{pv0, pv1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PV devices = %q, want %q", got, want)
+	}
+
+	vgs, err = Scan([]string{bad, pv1, filepath.Join(dir, "missing")})
+	if !errors.Is(err, ErrChecksum) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Scan() = %v, want %v and %v", err, ErrChecksum, os.ErrNotExist)
+	}
+	if len(vgs) != 0 {
+		t.Errorf("Scan() = %+v, want no VGs", vgs)
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics lets long-running programs count what they do, and serves
+// the counts over HTTP in the Prometheus text format.
+//
+// Programs create counters and gauges in a Registry, usually Default, and
+// serve it with ListenAndServe:
+//
+//	var served = metrics.NewCounter("myd_requests_total", "Requests served.")
+//
+//	func main() {
+//		go func() { log.Fatal(metrics.ListenAndServe(":9100")) }()
+//		...
+//		served.Inc()
+//	}
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Path is where metrics are served by ListenAndServe.
+const Path = "/metrics"
+
+var validName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// metric is something a Registry writes.
+type metric interface {
+	// series returns the label values and values of the metric.
+	series() []series
+}
+
+// series is one value of a metric, with its label values.
+type series struct {
+	labels []string
+	value  float64
+}
+
+type entry struct {
+	name, help, typ string
+	labels          []string
+	m               metric
+}
+
+// Registry holds metrics and writes them. The zero value is not usable;
+// use NewRegistry.
+type Registry struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// Default is the Registry the package functions use.
+var Default = NewRegistry()
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: map[string]*entry{}}
+}
+
+// register adds m to r. It panics if name or a label is not valid, or if
+// name is already registered, as these are programming errors.
+func (r *Registry) register(name, help, typ string, labels []string, m metric) {
+	if !validName.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid name %q", name))
+	}
+	for _, l := range labels {
+		if !validName.MatchString(l) || strings.Contains(l, ":") {
+			panic(fmt.Sprintf("metrics: %s: invalid label %q", name, l))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.entries[name] = &entry{name: name, help: help, typ: typ, labels: labels, m: m}
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc adds 1 to c.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add adds n to c.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns the value of c.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+func (c *Counter) series() []series {
+	return []series{{value: float64(c.Value())}}
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets g to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add adds v, which may be negative, to g.
+func (g *Gauge) Add(v float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Inc adds 1 to g.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts 1 from g.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value returns the value of g.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) series() []series {
+	return []series{{value: g.Value()}}
+}
+
+// valueFunc is a metric whose value is computed when it is written.
+type valueFunc func() float64
+
+func (f valueFunc) series() []series {
+	return []series{{value: f()}}
+}
+
+// vec is a metric with labels, with one series per set of label values.
+type vec[M interface{ series() []series }] struct {
+	labels int
+	mu     sync.Mutex
+	m      map[string]M
+	values map[string][]string
+	newM   func() M
+}
+
+func newVec[M interface{ series() []series }](labels int, newM func() M) *vec[M] {
+	return &vec[M]{labels: labels, m: map[string]M{}, values: map[string][]string{}, newM: newM}
+}
+
+// with returns the series for values, creating it if needed. It panics if
+// the number of values is not the number of labels.
+func (v *vec[M]) with(values []string) M {
+	if len(values) != v.labels {
+		panic(fmt.Sprintf("metrics: %d label values for %d labels", len(values), v.labels))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	m, ok := v.m[key]
+	if !ok {
+		m = v.newM()
+		v.m[key] = m
+		v.values[key] = append([]string(nil), values...)
+	}
+	return m
+}
+
+func (v *vec[M]) series() []series {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var s []series
+	for key, m := range v.m {
+		s = append(s, series{labels: v.values[key], value: m.series()[0].value})
+	}
+	return s
+}
+
+// CounterVec is a Counter per set of label values.
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+// With returns the Counter for the label values, in the order of the
+// labels.
+func (c CounterVec) With(values ...string) *Counter {
+	return c.with(values)
+}
+
+// GaugeVec is a Gauge per set of label values.
+type GaugeVec struct {
+	*vec[*Gauge]
+}
+
+// With returns the Gauge for the label values, in the order of the labels.
+func (g GaugeVec) With(values ...string) *Gauge {
+	return g.with(values)
+}
+
+// NewCounter registers a Counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", nil, c)
+	return c
+}
+
+// NewGauge registers a Gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", nil, g)
+	return g
+}
+
+// NewCounterFunc registers a counter whose value f returns. f is called
+// each time the metrics are written, and must be safe to call from any
+// goroutine.
+func (r *Registry) NewCounterFunc(name, help string, f func() float64) {
+	r.register(name, help, "counter", nil, valueFunc(f))
+}
+
+// NewGaugeFunc registers a gauge whose value f returns. f is called each
+// time the metrics are written, and must be safe to call from any
+// goroutine.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, help, "gauge", nil, valueFunc(f))
+}
+
+// NewCounterVec registers a CounterVec with labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) CounterVec {
+	c := CounterVec{newVec(len(labels), func() *Counter { return &Counter{} })}
+	r.register(name, help, "counter", labels, c)
+	return c
+}
+
+// NewGaugeVec registers a GaugeVec with labels.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) GaugeVec {
+	g := GaugeVec{newVec(len(labels), func() *Gauge { return &Gauge{} })}
+	r.register(name, help, "gauge", labels, g)
+	return g
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	case v == math.Trunc(v) && math.Abs(v) < 1<<53:
+		// Whole numbers, as counters mostly are, without exponents.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Write writes the metrics of r to w in the Prometheus text format, sorted
+// by name and label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	r.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", e.name, helpEscaper.Replace(e.help), e.name, e.typ)
+		s := e.m.series()
+		sort.Slice(s, func(i, j int) bool {
+			return strings.Join(s[i].labels, "\xff") < strings.Join(s[j].labels, "\xff")
+		})
+		for _, s := range s {
+			b.WriteString(e.name)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i, v := range s.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", e.labels[i], labelEscaper.Replace(v))
+				}
+				b.WriteByte('}')
+			}
+			fmt.Fprintf(&b, " %s\n", formatValue(s.value))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP implements http.Handler, serving the metrics of r.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// ListenAndServe serves the metrics of r at Path on addr, e.g. ":9100".
+// It only returns on error, like http.ListenAndServe.
+func (r *Registry) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, r)
+	return http.ListenAndServe(addr, mux)
+}
+
+// NewCounter registers a Counter in Default.
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+// NewGauge registers a Gauge in Default.
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+// NewCounterFunc registers a counter whose value f returns in Default.
+func NewCounterFunc(name, help string, f func() float64) {
+	Default.NewCounterFunc(name, help, f)
+}
+
+// NewGaugeFunc registers a gauge whose value f returns in Default.
+func NewGaugeFunc(name, help string, f func() float64) {
+	Default.NewGaugeFunc(name, help, f)
+}
+
+// NewCounterVec registers a CounterVec in Default.
+func NewCounterVec(name, help string, labels ...string) CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewGaugeVec registers a GaugeVec in Default.
+func NewGaugeVec(name, help string, labels ...string) GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+// ListenAndServe serves the metrics of Default at Path on addr.
+func ListenAndServe(addr string) error {
+	return Default.ListenAndServe(addr)
+}
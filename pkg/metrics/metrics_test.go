@@ -0,0 +1,137 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Requests served.")
+	g := r.NewGauge("test_sessions", "Sessions open.")
+	cv := r.NewCounterVec("test_errors_total", "Errors, by kind.", "kind", "iface")
+	gv := r.NewGaugeVec("test_up", "Whether a thing is up.\nOne line.", "name")
+	r.NewGaugeFunc("test_inf", "Infinity.", func() float64 { return math.Inf(1) })
+	r.NewCounterFunc("a_first_total", "Written first.", func() float64 { return 7 })
+
+	c.Add(2999999)
+	c.Inc()
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	g.Add(0.5)
+	cv.With("timeout", "eth0").Inc()
+	cv.With("refused", "eth0").Add(4)
+	cv.With("timeout", "eth0").Inc()
+	gv.With(`a "quoted" \ name`).Set(1)
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP a_first_total Written first.
+# TYPE a_first_total counter
+a_first_total 7
+# HELP test_errors_total Errors, by kind.
+# TYPE test_errors_total counter
+test_errors_total{kind="refused",iface="eth0"} 4
+test_errors_total{kind="timeout",iface="eth0"} 2
+# HELP test_inf Infinity.
+# TYPE test_inf gauge
+test_inf +Inf
+# HELP test_requests_total Requests served.
+# TYPE test_requests_total counter
+test_requests_total 3000000
+# HELP test_sessions Sessions open.
+# TYPE test_sessions gauge
+test_sessions 1.5
+# HELP test_up Whether a thing is up.\nOne line.
+# TYPE test_up gauge
+test_up{name="a \"quoted\" \\ name"} 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		f    func(r *Registry)
+	}{
+		{
+			name: "twice",
+			f: func(r *Registry) {
+				r.NewCounter("x_total", "")
+				r.NewGauge("x_total", "")
+			},
+		},
+		{
+			name: "bad name",
+			f:    func(r *Registry) { r.NewCounter("x-total", "") },
+		},
+		{
+			name: "bad label",
+			f:    func(r *Registry) { r.NewCounterVec("x_total", "", "a:b") },
+		},
+		{
+			name: "label count",
+			f:    func(r *Registry) { r.NewCounterVec("x_total", "", "a").With("1", "2") },
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("did not panic")
+				}
+			}()
+			tt.f(NewRegistry())
+		})
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("c_total", "", "l")
+	g := r.NewGauge("g", "")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.With("x").Inc()
+				g.Add(1)
+				r.Write(io.Discard)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.With("x").Value(); got != 8000 {
+		t.Errorf("counter = %d, want 8000", got)
+	}
+	if got := g.Value(); got != 8000 {
+		t.Errorf("gauge = %v, want 8000", got)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("x_total", "X.").Inc()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", Path, nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "x_total 1\n") {
+		t.Errorf("body = %q, want x_total 1", w.Body.String())
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Ext4Options configures an ext4 file system.
+type Ext4Options struct {
+	// BlockSize is 1024, 2048 or 4096. With 0, devices under 512 MiB get
+	// 1024 byte blocks and larger ones 4096 byte blocks.
+	BlockSize int
+
+	// BytesPerInode is the space planned per inode, which determines how
+	// many files the file system can hold. With 0, it is 16384.
+	BytesPerInode int
+
+	// Label is the volume label, up to 16 bytes.
+	Label string
+
+	// UUID is the file system UUID. With the zero UUID, a random one is
+	// used.
+	UUID [16]byte
+
+	// ZeroInodeTables zeroes the inode tables of all block groups. By
+	// default, only the first one is, and the kernel zeroes the others in
+	// the background after the file system is first mounted.
+	ZeroInodeTables bool
+}
+
+const (
+	ext4Magic       = 0xef53
+	ext4InodeSize   = 256
+	ext4ExtraIsize  = 32
+	ext4DescSize    = 32
+	ext4FirstIno    = 11
+	ext4RootIno     = 2
+	ext4LostFound   = 11
+	ext4ExtentMagic = 0xf30a
+
+	// The file system is kept minimal: no journal, no flex_bg, no 64bit
+	// block numbers and no metadata checksums other than those of the
+	// group descriptors, which allow leaving inode tables uninitialized.
+	ext4CompatExtAttr      = 0x8
+	ext4IncompatFiletype   = 0x2
+	ext4IncompatExtents    = 0x40
+	ext4ROCompatSparse     = 0x1
+	ext4ROCompatLargeFile  = 0x2
+	ext4ROCompatGDTCsum    = 0x10
+	ext4ROCompatDirNlink   = 0x20
+	ext4ROCompatExtraIsize = 0x40
+
+	ext4BGInodeUninit  = 0x1
+	ext4BGItableZeroed = 0x4
+
+	ext4ExtentsFl = 0x80000
+	ext4FtDir     = 2
+)
+
+// ext4Layout is the geometry of an ext4 file system.
+type ext4Layout struct {
+	bs        int64
+	firstData int64
+	blocks    int64
+	bpg       int64
+	groups    int64
+	ipg       int64
+	gdtBlocks int64
+	itBlocks  int64
+	// lfBlocks is the size of lost+found, which e2fsck fills when it
+	// finds orphaned files and should not have to grow.
+	lfBlocks int64
+}
+
+func (l *ext4Layout) groupStart(g int64) int64 {
+	return l.firstData + g*l.bpg
+}
+
+func (l *ext4Layout) groupBlocks(g int64) int64 {
+	return min(l.bpg, l.blocks-l.groupStart(g))
+}
+
+// hasSuper reports whether group g has a copy of the superblock and the
+// group descriptors. With sparse_super, those are groups 0, 1 and powers
+// of 3, 5 and 7.
+func hasSuper(g int64) bool {
+	if g <= 1 {
+		return true
+	}
+	for _, b := range []int64{3, 5, 7} {
+		n := b
+		for n < g {
+			n *= b
+		}
+		if n == g {
+			return true
+		}
+	}
+	return false
+}
+
+// blockBitmap returns the block bitmap of group g. The inode bitmap and
+// the inode table follow it.
+func (l *ext4Layout) blockBitmap(g int64) int64 {
+	b := l.groupStart(g)
+	if hasSuper(g) {
+		b += 1 + l.gdtBlocks
+	}
+	return b
+}
+
+// overhead is the number of metadata blocks at the start of group g.
+func (l *ext4Layout) overhead(g int64) int64 {
+	return l.blockBitmap(g) + 2 + l.itBlocks - l.groupStart(g)
+}
+
+func newExt4Layout(size int64, o Ext4Options) (*ext4Layout, error) {
+	bs := int64(o.BlockSize)
+	switch bs {
+	case 0:
+		bs = 4096
+		if size < 512<<20 {
+			bs = 1024
+		}
+	case 1024, 2048, 4096:
+	default:
+		return nil, fmt.Errorf("%w: block size %d", ErrOption, bs)
+	}
+	bpi := int64(o.BytesPerInode)
+	if bpi == 0 {
+		bpi = 16384
+	}
+	if bpi < 1024 || bpi > 64<<20 {
+		return nil, fmt.Errorf("%w: %d bytes per inode", ErrOption, bpi)
+	}
+
+	l := &ext4Layout{bs: bs, bpg: 8 * bs, blocks: size / bs, lfBlocks: max(1, 16384/bs)}
+	if bs == 1024 {
+		l.firstData = 1
+	}
+	if l.blocks > 1<<32-1 {
+		return nil, fmt.Errorf("%w for ext4 without 64bit block numbers", ErrTooLarge)
+	}
+	for {
+		l.groups = (l.blocks - l.firstData + l.bpg - 1) / l.bpg
+		if l.groups == 0 {
+			return nil, fmt.Errorf("%w for ext4", ErrTooSmall)
+		}
+		// Inode tables fill whole blocks and inode bitmaps whole bytes.
+		align := max(8, bs/ext4InodeSize)
+		inodes := l.blocks * bs / bpi
+		l.ipg = (inodes + l.groups - 1) / l.groups
+		l.ipg = max(16, (l.ipg+align-1)/align*align)
+		l.ipg = min(l.ipg, 8*bs)
+		l.gdtBlocks = (l.groups*ext4DescSize + bs - 1) / bs
+		l.itBlocks = l.ipg * ext4InodeSize / bs
+
+		// Like mke2fs, drop a last group too small to be useful.
+		last := l.groups - 1
+		if last > 0 && l.groupBlocks(last) < l.overhead(last)+50 {
+			l.blocks = l.groupStart(last)
+			continue
+		}
+		break
+	}
+	if l.groupBlocks(0) < l.overhead(0)+1+l.lfBlocks {
+		return nil, fmt.Errorf("%w for ext4", ErrTooSmall)
+	}
+	if l.ipg*l.groups > 1<<32-1 {
+		return nil, fmt.Errorf("%w: too many inodes", ErrOption)
+	}
+	return l, nil
+}
+
+// crc16 is the CRC-16 (polynomial 0x8005, reflected) of the ext4 group
+// descriptor checksums.
+func crc16(crc uint16, b []byte) uint16 {
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// setBits sets bits [from, to) in bitmap.
+func setBits(bitmap []byte, from, to int64) {
+	for i := from; i < to; i++ {
+		bitmap[i/8] |= 1 << (i % 8)
+	}
+}
+
+// Ext4 creates an ext4 file system with an empty root directory and
+// lost+found on the first size bytes of w.
+func Ext4(w io.WriterAt, size int64, o Ext4Options) error {
+	l, err := newExt4Layout(size, o)
+	if err != nil {
+		return err
+	}
+	if len(o.Label) > 16 {
+		return fmt.Errorf("%w: label %q is longer than 16 bytes", ErrOption, o.Label)
+	}
+	uuid := o.UUID
+	if uuid == [16]byte{} {
+		if _, err := rand.Read(uuid[:]); err != nil {
+			return err
+		}
+		uuid[6] = uuid[6]&0x0f | 0x40
+		uuid[8] = uuid[8]&0x3f | 0x80
+	}
+	var hashSeed [16]byte
+	if _, err := rand.Read(hashSeed[:]); err != nil {
+		return err
+	}
+	now := uint32(time.Now().Unix())
+	le := binary.LittleEndian
+	bs := l.bs
+
+	// Wipe the boot sector, so that nothing mistakes the device for
+	// what it held before.
+	if err := zero(w, 0, 1024); err != nil {
+		return err
+	}
+
+	// The root directory and lost+found follow the metadata of group 0.
+	rootBlock := l.groupStart(0) + l.overhead(0)
+	lfBlock := rootBlock + 1
+	dataBlocks := 1 + l.lfBlocks
+
+	gdt := make([]byte, l.gdtBlocks*bs)
+	var freeBlocks int64
+	for g := int64(0); g < l.groups; g++ {
+		used := l.overhead(g)
+		if g == 0 {
+			used += dataBlocks
+		}
+		free := l.groupBlocks(g) - used
+		freeBlocks += free
+
+		bitmap := make([]byte, bs)
+		setBits(bitmap, 0, used)
+		setBits(bitmap, l.groupBlocks(g), l.bpg)
+		bb := l.blockBitmap(g)
+		if _, err := w.WriteAt(bitmap, bb*bs); err != nil {
+			return err
+		}
+
+		usedInodes := int64(0)
+		flags := uint16(ext4BGInodeUninit)
+		if g == 0 {
+			usedInodes, flags = ext4LostFound, ext4BGItableZeroed
+		}
+		bitmap = make([]byte, bs)
+		setBits(bitmap, 0, usedInodes)
+		setBits(bitmap, l.ipg, 8*bs)
+		if _, err := w.WriteAt(bitmap, (bb+1)*bs); err != nil {
+			return err
+		}
+		if g == 0 || o.ZeroInodeTables {
+			if err := zero(w, (bb+2)*bs, l.itBlocks*bs); err != nil {
+				return err
+			}
+			flags |= ext4BGItableZeroed
+		}
+
+		d := gdt[g*ext4DescSize : (g+1)*ext4DescSize]
+		le.PutUint32(d[0x0:], uint32(bb))
+		le.PutUint32(d[0x4:], uint32(bb+1))
+		le.PutUint32(d[0x8:], uint32(bb+2))
+		le.PutUint16(d[0xc:], uint16(free))
+		le.PutUint16(d[0xe:], uint16(l.ipg-usedInodes))
+		if g == 0 {
+			le.PutUint16(d[0x10:], 2)
+		}
+		le.PutUint16(d[0x12:], flags)
+		le.PutUint16(d[0x1c:], uint16(l.ipg-usedInodes))
+		var gn [4]byte
+		le.PutUint32(gn[:], uint32(g))
+		crc := crc16(crc16(crc16(0xffff, uuid[:]), gn[:]), d[:0x1e])
+		le.PutUint16(d[0x1e:], crc)
+	}
+
+	sb := make([]byte, 1024)
+	inodes := l.ipg * l.groups
+	le.PutUint32(sb[0x0:], uint32(inodes))
+	le.PutUint32(sb[0x4:], uint32(l.blocks))
+	le.PutUint32(sb[0x8:], uint32(l.blocks/20))
+	le.PutUint32(sb[0xc:], uint32(freeBlocks))
+	le.PutUint32(sb[0x10:], uint32(inodes-ext4LostFound))
+	le.PutUint32(sb[0x14:], uint32(l.firstData))
+	var logBS uint32
+	for b := bs; b > 1024; b >>= 1 {
+		logBS++
+	}
+	le.PutUint32(sb[0x18:], logBS)
+	le.PutUint32(sb[0x1c:], logBS)
+	le.PutUint32(sb[0x20:], uint32(l.bpg))
+	le.PutUint32(sb[0x24:], uint32(l.bpg))
+	le.PutUint32(sb[0x28:], uint32(l.ipg))
+	le.PutUint32(sb[0x30:], now)
+	le.PutUint16(sb[0x36:], 0xffff)
+	le.PutUint16(sb[0x38:], ext4Magic)
+	le.PutUint16(sb[0x3a:], 1) // cleanly unmounted
+	le.PutUint16(sb[0x3c:], 1) // continue on errors
+	le.PutUint32(sb[0x40:], now)
+	le.PutUint32(sb[0x4c:], 1) // dynamic inode sizes
+	le.PutUint32(sb[0x54:], ext4FirstIno)
+	le.PutUint16(sb[0x58:], ext4InodeSize)
+	le.PutUint32(sb[0x5c:], ext4CompatExtAttr)
+	le.PutUint32(sb[0x60:], ext4IncompatFiletype|ext4IncompatExtents)
+	le.PutUint32(sb[0x64:], ext4ROCompatSparse|ext4ROCompatLargeFile|ext4ROCompatGDTCsum|ext4ROCompatDirNlink|ext4ROCompatExtraIsize)
+	copy(sb[0x68:], uuid[:])
+	copy(sb[0x78:], o.Label)
+	copy(sb[0xec:], hashSeed[:])
+	sb[0xfc] = 1 // half MD4
+	le.PutUint32(sb[0x108:], now)
+	le.PutUint16(sb[0x15c:], ext4ExtraIsize)
+	le.PutUint16(sb[0x15e:], ext4ExtraIsize)
+	le.PutUint32(sb[0x160:], 1) // signed directory hash
+
+	for g := int64(0); g < l.groups; g++ {
+		if !hasSuper(g) {
+			continue
+		}
+		le.PutUint16(sb[0x5a:], uint16(g))
+		off := l.groupStart(g) * bs
+		if g == 0 {
+			off = 1024
+		}
+		if _, err := w.WriteAt(sb, off); err != nil {
+			return err
+		}
+		if _, err := w.WriteAt(gdt, (l.groupStart(g)+1)*bs); err != nil {
+			return err
+		}
+	}
+
+	root := make([]byte, bs)
+	putDirent(root, ext4RootIno, ".", 12)
+	putDirent(root[12:], ext4RootIno, "..", 12)
+	putDirent(root[24:], ext4LostFound, "lost+found", bs-24)
+	if _, err := w.WriteAt(root, rootBlock*bs); err != nil {
+		return err
+	}
+	lf := make([]byte, bs)
+	putDirent(lf, ext4LostFound, ".", 12)
+	putDirent(lf[12:], ext4RootIno, "..", bs-12)
+	if _, err := w.WriteAt(lf, lfBlock*bs); err != nil {
+		return err
+	}
+	empty := make([]byte, bs)
+	le.PutUint16(empty[4:], uint16(bs))
+	for b := int64(1); b < l.lfBlocks; b++ {
+		if _, err := w.WriteAt(empty, (lfBlock+b)*bs); err != nil {
+			return err
+		}
+	}
+
+	itable := (l.blockBitmap(0) + 2) * bs
+	for _, ino := range []struct {
+		n     int64
+		mode  uint16
+		links uint16
+		block int64
+		len   int64
+	}{
+		{ext4RootIno, 0o40755, 3, rootBlock, 1},
+		{ext4LostFound, 0o40700, 2, lfBlock, l.lfBlocks},
+	} {
+		b := make([]byte, ext4InodeSize)
+		le.PutUint16(b[0x0:], ino.mode)
+		le.PutUint32(b[0x4:], uint32(ino.len*bs))
+		le.PutUint32(b[0x8:], now)
+		le.PutUint32(b[0xc:], now)
+		le.PutUint32(b[0x10:], now)
+		le.PutUint16(b[0x1a:], ino.links)
+		le.PutUint32(b[0x1c:], uint32(ino.len*bs/512))
+		le.PutUint32(b[0x20:], ext4ExtentsFl)
+		// An extent tree with a single extent in the inode.
+		le.PutUint16(b[0x28:], ext4ExtentMagic)
+		le.PutUint16(b[0x2a:], 1)
+		le.PutUint16(b[0x2c:], 4)
+		le.PutUint32(b[0x34:], 0)
+		le.PutUint16(b[0x38:], uint16(ino.len))
+		le.PutUint32(b[0x3c:], uint32(ino.block))
+		le.PutUint16(b[0x80:], ext4ExtraIsize)
+		le.PutUint32(b[0x90:], now)
+		if _, err := w.WriteAt(b, itable+(ino.n-1)*ext4InodeSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putDirent writes a directory entry for a directory to b.
+func putDirent(b []byte, ino uint32, name string, recLen int64) {
+	binary.LittleEndian.PutUint32(b[0:], ino)
+	binary.LittleEndian.PutUint16(b[4:], uint16(recLen))
+	b[6] = byte(len(name))
+	b[7] = ext4FtDir
+	copy(b[8:], name)
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasSuper(t *testing.T) {
+	var got []int64
+	for g := int64(0); g < 130; g++ {
+		if hasSuper(g) {
+			got = append(got, g)
+		}
+	}
+	want := []int64{0, 1, 3, 5, 7, 9, 25, 27, 49, 81, 125}
+	if len(got) != len(want) {
+		t.Fatalf("groups with superblocks = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("groups with superblocks = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestExt4(t *testing.T) {
+	fsck, _ := exec.LookPath("e2fsck")
+	for _, tt := range []struct {
+		size int64
+		o    Ext4Options
+		err  error
+	}{
+		{size: 1 << 20},
+		{size: 64 << 20, o: Ext4Options{BlockSize: 2048, ZeroInodeTables: true}},
+		{size: 96<<20 + 12345, o: Ext4Options{BlockSize: 4096, BytesPerInode: 4096}},
+		{size: 600 << 20},
+		{size: 64 << 10},
+		{size: 16 << 10, err: ErrTooSmall},
+		{size: 64 << 20, o: Ext4Options{BlockSize: 8192}, err: ErrOption},
+		{size: 64 << 20, o: Ext4Options{Label: "a label much too long"}, err: ErrOption},
+	} {
+		img := filepath.Join(t.TempDir(), "ext4.img")
+		f, err := os.Create(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := f.Truncate(tt.size); err != nil {
+			t.Fatal(err)
+		}
+		if tt.o.Label == "" {
+			tt.o.Label = "root"
+		}
+		tt.o.UUID = [16]byte{1, 2, 3, 4}
+		if err := Ext4(f, tt.size, tt.o); !errors.Is(err, tt.err) {
+			t.Errorf("Ext4(%d, %+v) = %v, want %v", tt.size, tt.o, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+
+		sb := make([]byte, 1024)
+		if _, err := f.ReadAt(sb, 1024); err != nil {
+			t.Fatal(err)
+		}
+		le := binary.LittleEndian
+		if m := le.Uint16(sb[0x38:]); m != ext4Magic {
+			t.Errorf("Ext4(%d): magic %#x, want %#x", tt.size, m, ext4Magic)
+		}
+		if l := string(sb[0x78:0x7c]); l != "root" {
+			t.Errorf("Ext4(%d): label %q, want root", tt.size, l)
+		}
+		if u := sb[0x68:0x6c]; u[0] != 1 || u[3] != 4 {
+			t.Errorf("Ext4(%d): UUID starts % x, want 01 02 03 04", tt.size, u)
+		}
+
+		if fsck == "" {
+			continue
+		}
+		if out, err := exec.Command(fsck, "-fn", img).CombinedOutput(); err != nil {
+			t.Errorf("Ext4(%d, %+v): e2fsck: %v\n%s", tt.size, tt.o, err, out)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FATOptions configures a FAT file system.
+type FATOptions struct {
+	// Bits is the size of FAT entries: 12, 16 or 32. With 0, devices of
+	// 512 MiB and more get FAT32 and smaller ones FAT16 or, if they are
+	// too small for it, FAT12.
+	Bits int
+
+	// SectorsPerCluster is the cluster size in 512 byte sectors, a power
+	// of two up to 128. With 0, it is picked based on the device size.
+	SectorsPerCluster int
+
+	// Label is the volume label, up to 11 characters.
+	Label string
+
+	// VolumeID is the volume serial number. With 0, it is derived from
+	// the current time.
+	VolumeID uint32
+}
+
+const (
+	sectorSize = 512
+	dirEntSize = 32
+
+	// Cluster count limits of the FAT types, as in the FAT specification.
+	fat12MaxClusters = 4084
+	fat16MaxClusters = 65524
+	fat32MaxClusters = 0x0ffffff5
+)
+
+// fatLayout is the geometry of a FAT file system.
+type fatLayout struct {
+	bits        int
+	spc         int
+	reserved    int64
+	rootEntries int64
+	fatSectors  int64
+	sectors     int64
+	clusters    int64
+}
+
+func (l *fatLayout) rootSectors() int64 {
+	return l.rootEntries * dirEntSize / sectorSize
+}
+
+// dataStart is the first sector of cluster 2.
+func (l *fatLayout) dataStart() int64 {
+	return l.reserved + 2*l.fatSectors + l.rootSectors()
+}
+
+// newFATLayout lays out a FAT file system with bits wide entries and spc
+// sectors per cluster on sectors sectors.
+func newFATLayout(sectors int64, bits, spc int) (*fatLayout, error) {
+	l := &fatLayout{bits: bits, spc: spc, sectors: sectors, reserved: 1, rootEntries: 512}
+	if bits == 32 {
+		l.reserved, l.rootEntries = 32, 0
+	}
+	// Growing the FATs shrinks the data area, so this converges.
+	for l.fatSectors = 1; ; {
+		data := sectors - l.dataStart()
+		if data < int64(spc) {
+			return nil, fmt.Errorf("%w for FAT%d", ErrTooSmall, bits)
+		}
+		l.clusters = data / int64(spc)
+		need := (((l.clusters+2)*int64(bits)+7)/8 + sectorSize - 1) / sectorSize
+		if need <= l.fatSectors {
+			break
+		}
+		l.fatSectors = need
+	}
+
+	var lo, hi int64
+	switch bits {
+	case 12:
+		lo, hi = 1, fat12MaxClusters
+	case 16:
+		lo, hi = fat12MaxClusters+1, fat16MaxClusters
+	case 32:
+		lo, hi = fat16MaxClusters+1, fat32MaxClusters
+	}
+	switch {
+	case l.clusters < lo:
+		return nil, fmt.Errorf("%w for FAT%d with %d byte clusters", ErrTooSmall, bits, spc*sectorSize)
+	case l.clusters > hi:
+		return nil, fmt.Errorf("%w for FAT%d with %d byte clusters", ErrTooLarge, bits, spc*sectorSize)
+	}
+	return l, nil
+}
+
+// defaultSPC returns the usual cluster size for FAT16 and FAT32 file
+// systems of size bytes, or 0 for FAT12, which uses the smallest that fits.
+func defaultSPC(bits int, size int64) int {
+	const mib = 1 << 20
+	var table []struct {
+		max int64
+		spc int
+	}
+	switch bits {
+	case 16:
+		table = []struct {
+			max int64
+			spc int
+		}{{16 * mib, 2}, {128 * mib, 4}, {256 * mib, 8}, {512 * mib, 16}, {1024 * mib, 32}, {2048 * mib, 64}}
+	case 32:
+		table = []struct {
+			max int64
+			spc int
+		}{{260 * mib, 1}, {8192 * mib, 8}, {16384 * mib, 16}, {32768 * mib, 32}}
+	default:
+		return 0
+	}
+	for _, t := range table {
+		if size <= t.max {
+			return t.spc
+		}
+	}
+	if bits == 16 {
+		return 128
+	}
+	return 64
+}
+
+func fatLayoutFor(size int64, o FATOptions) (*fatLayout, error) {
+	sectors := size / sectorSize
+	if sectors > 0xffffffff {
+		return nil, fmt.Errorf("%w for FAT", ErrTooLarge)
+	}
+	if spc := o.SectorsPerCluster; spc != 0 {
+		if spc < 0 || spc > 128 || spc&(spc-1) != 0 {
+			return nil, fmt.Errorf("%w: %d sectors per cluster", ErrOption, spc)
+		}
+	}
+
+	bits := []int{o.Bits}
+	switch o.Bits {
+	case 12, 16, 32:
+	case 0:
+		bits = []int{16, 12}
+		if size >= 512<<20 {
+			bits = []int{32}
+		}
+	default:
+		return nil, fmt.Errorf("%w: FAT%d", ErrOption, o.Bits)
+	}
+
+	var err error
+	for _, b := range bits {
+		spc := o.SectorsPerCluster
+		if spc == 0 {
+			spc = defaultSPC(b, size)
+		}
+		if spc != 0 {
+			var l *fatLayout
+			if l, err = newFATLayout(sectors, b, spc); err == nil {
+				return l, nil
+			}
+			continue
+		}
+		for spc = 1; spc <= 128; spc <<= 1 {
+			var l *fatLayout
+			if l, err = newFATLayout(sectors, b, spc); err == nil {
+				return l, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// validLabel checks a FAT volume label and returns it in upper case.
+func validLabel(s string) (string, error) {
+	if len(s) > 11 || strings.ContainsAny(s, "\"*+,./:;<=>?[\\]|") {
+		return "", fmt.Errorf("%w: label %q", ErrOption, s)
+	}
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e {
+			return "", fmt.Errorf("%w: label %q", ErrOption, s)
+		}
+	}
+	return strings.ToUpper(s), nil
+}
+
+// dosTime returns t in the FAT directory entry format.
+func dosTime(t time.Time) (date, tm uint16) {
+	date = uint16(t.Year()-1980)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	tm = uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	return date, tm
+}
+
+// FAT creates a FAT file system, as used for EFI system partitions, on the
+// first size bytes of w.
+func FAT(w io.WriterAt, size int64, o FATOptions) error {
+	l, err := fatLayoutFor(size, o)
+	if err != nil {
+		return err
+	}
+	lbl, err := validLabel(o.Label)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	id := o.VolumeID
+	if id == 0 {
+		id = uint32(now.Unix()) ^ uint32(now.Nanosecond())
+	}
+
+	// The reserved sectors, the FATs and the root directory start out
+	// empty.
+	rootSectors := l.rootSectors()
+	if l.bits == 32 {
+		rootSectors = int64(l.spc)
+	}
+	if err := zero(w, 0, (l.dataStart()-l.rootSectors()+rootSectors)*sectorSize); err != nil {
+		return err
+	}
+
+	boot := fatBootSector(l, lbl, id)
+	if _, err := w.WriteAt(boot, 0); err != nil {
+		return err
+	}
+	if l.bits == 32 {
+		info := make([]byte, sectorSize)
+		binary.LittleEndian.PutUint32(info[0:], 0x41615252)
+		binary.LittleEndian.PutUint32(info[484:], 0x61417272)
+		// The root directory takes up the first cluster.
+		binary.LittleEndian.PutUint32(info[488:], uint32(l.clusters-1))
+		binary.LittleEndian.PutUint32(info[492:], 3)
+		binary.LittleEndian.PutUint32(info[508:], 0xaa550000)
+		for _, s := range []int64{1, 7} {
+			if _, err := w.WriteAt(info, s*sectorSize); err != nil {
+				return err
+			}
+		}
+		if _, err := w.WriteAt(boot, 6*sectorSize); err != nil {
+			return err
+		}
+	}
+
+	// The first two entries hold the media descriptor and the end of
+	// chain marker. On FAT32, the third one is the root directory.
+	var fat []byte
+	switch l.bits {
+	case 12:
+		fat = []byte{0xf8, 0xff, 0xff}
+	case 16:
+		fat = []byte{0xf8, 0xff, 0xff, 0xff}
+	case 32:
+		fat = []byte{0xf8, 0xff, 0xff, 0x0f, 0xff, 0xff, 0xff, 0x0f, 0xff, 0xff, 0xff, 0x0f}
+	}
+	for i := int64(0); i < 2; i++ {
+		if _, err := w.WriteAt(fat, (l.reserved+i*l.fatSectors)*sectorSize); err != nil {
+			return err
+		}
+	}
+
+	if lbl != "" {
+		ent := make([]byte, dirEntSize)
+		copy(ent, label(lbl, 11, ' '))
+		ent[11] = 0x08
+		date, tm := dosTime(now)
+		binary.LittleEndian.PutUint16(ent[22:], tm)
+		binary.LittleEndian.PutUint16(ent[24:], date)
+		if _, err := w.WriteAt(ent, (l.dataStart()-l.rootSectors())*sectorSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fatBootSector(l *fatLayout, lbl string, id uint32) []byte {
+	b := make([]byte, sectorSize)
+	le := binary.LittleEndian
+
+	// Jump over the BPB to code that lets the BIOS try the next boot
+	// device (int 0x18).
+	ext := 36
+	if l.bits == 32 {
+		ext = 64
+	}
+	code := ext + 26
+	b[0], b[1], b[2] = 0xeb, byte(code-2), 0x90
+	b[code], b[code+1] = 0xcd, 0x18
+
+	copy(b[3:], "MSWIN4.1")
+	le.PutUint16(b[11:], sectorSize)
+	b[13] = byte(l.spc)
+	le.PutUint16(b[14:], uint16(l.reserved))
+	b[16] = 2
+	le.PutUint16(b[17:], uint16(l.rootEntries))
+	if l.sectors < 0x10000 && l.bits != 32 {
+		le.PutUint16(b[19:], uint16(l.sectors))
+	} else {
+		le.PutUint32(b[32:], uint32(l.sectors))
+	}
+	b[21] = 0xf8
+	le.PutUint16(b[24:], 32)
+	le.PutUint16(b[26:], 64)
+
+	fsType := fmt.Sprintf("FAT%d", l.bits)
+	if l.bits == 32 {
+		le.PutUint32(b[36:], uint32(l.fatSectors))
+		le.PutUint32(b[44:], 2)
+		le.PutUint16(b[48:], 1)
+		le.PutUint16(b[50:], 6)
+	} else {
+		le.PutUint16(b[22:], uint16(l.fatSectors))
+	}
+	if lbl == "" {
+		lbl = "NO NAME"
+	}
+	b[ext] = 0x80
+	b[ext+2] = 0x29
+	le.PutUint32(b[ext+3:], id)
+	copy(b[ext+7:], label(lbl, 11, ' '))
+	copy(b[ext+18:], label(fsType, 8, ' '))
+	b[510], b[511] = 0x55, 0xaa
+	return b
+}
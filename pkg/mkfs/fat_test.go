@@ -0,0 +1,122 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFAT(t *testing.T) {
+	for _, tt := range []struct {
+		size     int64
+		o        FATOptions
+		bits     int
+		spc      int
+		jmp      byte
+		reserved int
+		err      error
+	}{
+		{size: 1 << 20, bits: 12, spc: 1, jmp: 0x3c, reserved: 1},
+		{size: 8 << 20, bits: 16, spc: 2, jmp: 0x3c, reserved: 1},
+		{size: 100 << 20, o: FATOptions{Bits: 32}, bits: 32, spc: 1, jmp: 0x58, reserved: 32},
+		{size: 600 << 20, bits: 32, spc: 8, jmp: 0x58, reserved: 32},
+		{size: 600 << 20, o: FATOptions{Bits: 16}, bits: 16, spc: 32, jmp: 0x3c, reserved: 1},
+		{size: 256 << 20, o: FATOptions{Bits: 16, SectorsPerCluster: 64}, bits: 16, spc: 64, jmp: 0x3c, reserved: 1},
+		{size: 1 << 20, o: FATOptions{Bits: 32}, err: ErrTooSmall},
+		{size: 1 << 20, o: FATOptions{Bits: 16}, err: ErrTooSmall},
+		{size: 300 << 20, o: FATOptions{Bits: 12}, err: ErrTooLarge},
+		{size: 8 << 20, o: FATOptions{Bits: 15}, err: ErrOption},
+		{size: 8 << 20, o: FATOptions{SectorsPerCluster: 3}, err: ErrOption},
+		{size: 8 << 20, o: FATOptions{Label: "a/b"}, err: ErrOption},
+		{size: 8 << 20, o: FATOptions{Label: "much too long"}, err: ErrOption},
+	} {
+		f, err := os.Create(filepath.Join(t.TempDir(), "fat.img"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := f.Truncate(tt.size); err != nil {
+			t.Fatal(err)
+		}
+		if tt.o.Label == "" {
+			tt.o.Label = "efi"
+		}
+		if err := FAT(f, tt.size, tt.o); !errors.Is(err, tt.err) {
+			t.Errorf("FAT(%d, %+v) = %v, want %v", tt.size, tt.o, err, tt.err)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+
+		boot := make([]byte, sectorSize)
+		if _, err := f.ReadAt(boot, 0); err != nil {
+			t.Fatal(err)
+		}
+		le := binary.LittleEndian
+		if boot[0] != 0xeb || boot[1] != tt.jmp || boot[510] != 0x55 || boot[511] != 0xaa {
+			t.Errorf("FAT%d: boot sector starts % x and ends % x", tt.bits, boot[:3], boot[510:])
+		}
+		if spc := int(boot[13]); spc != tt.spc {
+			t.Errorf("FAT%d: %d sectors per cluster, want %d", tt.bits, spc, tt.spc)
+		}
+		if r := int(le.Uint16(boot[14:])); r != tt.reserved {
+			t.Errorf("FAT%d: %d reserved sectors, want %d", tt.bits, r, tt.reserved)
+		}
+
+		// Determine the FAT type from the cluster count, as the
+		// specification says.
+		total := int64(le.Uint16(boot[19:]))
+		if total == 0 {
+			total = int64(le.Uint32(boot[32:]))
+		}
+		fatSize := int64(le.Uint16(boot[22:]))
+		labelOff := 43
+		if fatSize == 0 {
+			fatSize = int64(le.Uint32(boot[36:]))
+			labelOff = 71
+		}
+		rootSectors := int64(le.Uint16(boot[17:])) * dirEntSize / sectorSize
+		dataStart := int64(tt.reserved) + int64(boot[16])*fatSize + rootSectors
+		clusters := (total - dataStart) / int64(tt.spc)
+		bits := 32
+		if clusters <= fat12MaxClusters {
+			bits = 12
+		} else if clusters <= fat16MaxClusters {
+			bits = 16
+		}
+		if bits != tt.bits {
+			t.Errorf("%d clusters make FAT%d, want FAT%d", clusters, bits, tt.bits)
+		}
+		if (clusters+2)*int64(bits)/8 > fatSize*sectorSize {
+			t.Errorf("FAT%d: %d clusters do not fit in %d FAT sectors", bits, clusters, fatSize)
+		}
+		if got := string(boot[labelOff : labelOff+11]); got != "EFI        " {
+			t.Errorf("FAT%d: label %q, want %q", bits, got, "EFI        ")
+		}
+
+		media := make([]byte, 2)
+		for i := int64(0); i < 2; i++ {
+			if _, err := f.ReadAt(media, (int64(tt.reserved)+i*fatSize)*sectorSize); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(media, []byte{0xf8, 0xff}) {
+				t.Errorf("FAT%d: FAT %d starts % x", bits, i, media)
+			}
+		}
+		ent := make([]byte, dirEntSize)
+		if _, err := f.ReadAt(ent, (dataStart-rootSectors)*sectorSize); err != nil {
+			t.Fatal(err)
+		}
+		if string(ent[:11]) != "EFI        " || ent[11] != 0x08 {
+			t.Errorf("FAT%d: root directory starts with %q", bits, ent)
+		}
+	}
+}
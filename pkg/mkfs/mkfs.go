@@ -0,0 +1,55 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mkfs creates empty file systems on block devices and image files.
+//
+// Only the metadata of the new file system is written. Blocks that end up
+// as free space keep their old contents.
+package mkfs
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrTooSmall is returned if a file system does not fit.
+	ErrTooSmall = errors.New("device too small")
+
+	// ErrTooLarge is returned if a device exceeds what a file system
+	// can address.
+	ErrTooLarge = errors.New("device too large")
+
+	// ErrOption is returned for invalid options.
+	ErrOption = errors.New("invalid option")
+)
+
+// zeroBuf is the largest chunk zero writes at once.
+var zeroBuf = make([]byte, 64<<10)
+
+// zero writes n zero bytes to w at off.
+func zero(w io.WriterAt, off, n int64) error {
+	for n > 0 {
+		b := zeroBuf
+		if n < int64(len(b)) {
+			b = b[:n]
+		}
+		if _, err := w.WriteAt(b, off); err != nil {
+			return err
+		}
+		off += int64(len(b))
+		n -= int64(len(b))
+	}
+	return nil
+}
+
+// label returns s as a fixed size, padded label.
+func label(s string, n int, pad byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = pad
+	}
+	copy(b, s)
+	return b
+}
@@ -58,7 +58,7 @@ type BlockDev struct {
 // We will just use the last component.
 func Device(maybeDevpath string) (*BlockDev, error) {
 	devname := filepath.Base(maybeDevpath)
-	if _, err := os.Stat(filepath.Join("/sys/class/block", devname)); err != nil {
+	if _, err := os.Stat(filepath.Join(sysClassBlock, devname)); err != nil {
 		return nil, err
 	}
 
@@ -192,7 +192,7 @@ func (b *BlockDev) ReadPartitionTable() error {
 // PCIInfo searches sysfs for the PCI vendor and device id.
 // We fill in the PCI struct with just those two elements.
 func (b *BlockDev) PCIInfo() (*pci.PCI, error) {
-	p, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", b.Name))
+	p, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock, b.Name))
 	if err != nil {
 		return nil, err
 	}
@@ -225,6 +225,12 @@ func (b *BlockDev) PCIInfo() (*pci.PCI, error) {
 	return pci.OnePCI(p)
 }
 
+// FSUUID returns the UUID of the vfat, ext4 or xfs filesystem on the block
+// device at devpath, as in /dev/disk/by-uuid.
+func FSUUID(devpath string) (string, error) {
+	return getFSUUID(devpath)
+}
+
 func getFSUUID(devpath string) (string, error) {
 	file, err := os.Open(devpath)
 	if err != nil {
@@ -394,7 +400,7 @@ func GetBlockDevices() (BlockDevices, error) {
 	var blockdevs []*BlockDev
 	var devnames []string
 
-	root := "/sys/class/block"
+	root := sysClassBlock
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -484,7 +490,7 @@ func (b BlockDevices) FilterHavingPartitions(parts []int) BlockDevices {
 	for _, device := range b {
 		hasParts := true
 		for _, part := range parts {
-			if _, err := os.Stat(filepath.Join("/sys/class/block",
+			if _, err := os.Stat(filepath.Join(sysClassBlock,
 				ComposePartName(device.Name, part))); err != nil {
 				hasParts = false
 				break
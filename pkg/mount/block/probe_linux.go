@@ -0,0 +1,352 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNoFS is returned by Probe if no known file system or container
+// signature is found.
+var ErrNoFS = errors.New("no known file system signature")
+
+// FSInfo is what Probe found on a device, named after the blkid tags.
+type FSInfo struct {
+	// Type is the file system or container type, e.g. ext4, vfat, swap,
+	// crypto_LUKS, linux_raid_member or LVM2_member.
+	Type string
+
+	// UUID identifies the file system, or the array or volume group
+	// member for containers. Its format depends on Type.
+	UUID string
+
+	// Label is the volume label, if the format has one.
+	Label string
+}
+
+// prober checks r for one signature and returns nil, nil if it is not there.
+type prober func(r io.ReaderAt) (*FSInfo, error)
+
+// probers run in order. Containers come first, because a file system
+// superblock may be left over inside them.
+var probers = []prober{
+	probeMD,
+	probeLVM2,
+	probeLUKS,
+	probeExt,
+	probeBtrfs,
+	probeXFS,
+	probeF2FS,
+	probeEROFS,
+	probeSquashfs,
+	probeISO9660,
+	probeExFAT,
+	probeFAT,
+	probeSwap,
+}
+
+// Probe identifies the file system, swap area or container on r by its
+// on-disk signature.
+func Probe(r io.ReaderAt) (*FSInfo, error) {
+	for _, p := range probers {
+		fs, err := p(r)
+		if err != nil {
+			return nil, err
+		}
+		if fs != nil {
+			return fs, nil
+		}
+	}
+	return nil, ErrNoFS
+}
+
+// Probe identifies the file system or container on the block device.
+func (b *BlockDev) Probe() (*FSInfo, error) {
+	f, err := os.Open(b.DevicePath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Probe(f)
+}
+
+// readAt reads n bytes at off. Devices that are too small to hold the
+// structure yield nil, not an error.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// cstring returns b up to the first NUL byte, with trailing blanks removed.
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// uuidString formats 16 bytes as a RFC 4122 UUID.
+func uuidString(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// The ext2 feature flags that ext3 does not know about.
+const (
+	extCompatHasJournal   = 0x4
+	extIncompatJournalDev = 0x8
+	extIncompatExt3       = 0x2 | 0x4 | 0x10
+	extROCompatExt3       = 0x1 | 0x2 | 0x4
+)
+
+func probeExt(r io.ReaderAt) (*FSInfo, error) {
+	sb, err := readAt(r, ext2SprblkOff, 1024)
+	if sb == nil || err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(sb[ext2SprblkMagicOff:]) != ext2SprblkMagic {
+		return nil, nil
+	}
+	compat := binary.LittleEndian.Uint32(sb[92:])
+	incompat := binary.LittleEndian.Uint32(sb[96:])
+	roCompat := binary.LittleEndian.Uint32(sb[100:])
+
+	fs := &FSInfo{
+		UUID:  uuidString(sb[ext2SprblkUUIDOff:]),
+		Label: cstring(sb[120:136]),
+	}
+	switch {
+	case incompat&extIncompatJournalDev != 0:
+		fs.Type = "jbd"
+	case incompat&^extIncompatExt3 != 0 || roCompat&^extROCompatExt3 != 0:
+		fs.Type = "ext4"
+	case compat&extCompatHasJournal != 0:
+		fs.Type = "ext3"
+	default:
+		fs.Type = "ext2"
+	}
+	return fs, nil
+}
+
+func probeBtrfs(r io.ReaderAt) (*FSInfo, error) {
+	const off = 0x10000
+	sb, err := readAt(r, off, 0x300)
+	if sb == nil || err != nil {
+		return nil, err
+	}
+	if string(sb[0x40:0x48]) != "_BHRfS_M" {
+		return nil, nil
+	}
+	return &FSInfo{Type: "btrfs", UUID: uuidString(sb[0x20:]), Label: cstring(sb[0x12b:0x22b])}, nil
+}
+
+func probeXFS(r io.ReaderAt) (*FSInfo, error) {
+	sb, err := readAt(r, 0, 120)
+	if sb == nil || err != nil {
+		return nil, err
+	}
+	if string(sb[:4]) != xfsMagic {
+		return nil, nil
+	}
+	return &FSInfo{Type: "xfs", UUID: uuidString(sb[xfsUUIDOff:]), Label: cstring(sb[108:120])}, nil
+}
+
+func probeF2FS(r io.ReaderAt) (*FSInfo, error) {
+	sb, err := readAt(r, 0x400, 0x27c)
+	if sb == nil || err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(sb) != 0xf2f52010 {
+		return nil, nil
+	}
+	// The volume name is UTF-16.
+	name := make([]uint16, 0, 256)
+	for i := 0x7c; i < len(sb); i += 2 {
+		c := binary.LittleEndian.Uint16(sb[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	return &FSInfo{Type: "f2fs", UUID: uuidString(sb[0x6c:]), Label: string(utf16.Decode(name))}, nil
+}
+
+func probeEROFS(r io.ReaderAt) (*FSInfo, error) {
+	sb, err := readAt(r, 0x400, 0x60)
+	if sb == nil || err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(sb) != 0xe0f5e1e2 {
+		return nil, nil
+	}
+	return &FSInfo{Type: "erofs", UUID: uuidString(sb[0x30:]), Label: cstring(sb[0x40:0x50])}, nil
+}
+
+func probeSquashfs(r io.ReaderAt) (*FSInfo, error) {
+	b, err := readAt(r, 0, 4)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	if string(b) != "hsqs" {
+		return nil, nil
+	}
+	return &FSInfo{Type: "squashfs"}, nil
+}
+
+func probeISO9660(r io.ReaderAt) (*FSInfo, error) {
+	// The primary volume descriptor is in the 17th 2048 byte sector.
+	pvd, err := readAt(r, 0x8000, 2048)
+	if pvd == nil || err != nil {
+		return nil, err
+	}
+	if pvd[0] != 1 || string(pvd[1:6]) != "CD001" {
+		return nil, nil
+	}
+	fs := &FSInfo{Type: "iso9660", Label: cstring(pvd[40:72])}
+	// Like blkid, use the creation or else modification time as UUID.
+	for _, t := range [][]byte{pvd[813:830], pvd[830:847]} {
+		if t[0] != 0 && t[0] != '0' {
+			fs.UUID = fmt.Sprintf("%s-%s-%s-%s-%s-%s-%s", t[0:4], t[4:6], t[6:8], t[8:10], t[10:12], t[12:14], t[14:16])
+			break
+		}
+	}
+	return fs, nil
+}
+
+func probeExFAT(r io.ReaderAt) (*FSInfo, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	if string(b[3:11]) != "EXFAT   " {
+		return nil, nil
+	}
+	id := binary.LittleEndian.Uint32(b[100:])
+	return &FSInfo{Type: "exfat", UUID: fmt.Sprintf("%04X-%04X", id>>16, id&0xffff)}, nil
+}
+
+func probeFAT(r io.ReaderAt) (*FSInfo, error) {
+	b, err := readAt(r, 0, 512)
+	if b == nil || err != nil {
+		return nil, err
+	}
+	if b[510] != 0x55 || b[511] != 0xaa {
+		return nil, nil
+	}
+	// FAT32 has a larger BPB, which moves the extended boot record.
+	var ext int
+	switch {
+	case string(b[fat32MagicOff:fat32MagicOff+fat32MagicSize]) == fat32Magic:
+		ext = 64
+	case strings.HasPrefix(string(b[fat16MagicOff:fat16MagicOff+fat16MagicSize]), "FAT1"):
+		ext = 36
+	default:
+		return nil, nil
+	}
+	id := binary.LittleEndian.Uint32(b[ext+3:])
+	fs := &FSInfo{Type: "vfat", UUID: fmt.Sprintf("%04X-%04X", id>>16, id&0xffff)}
+	if l := cstring(b[ext+7 : ext+18]); l != "NO NAME" {
+		fs.Label = l
+	}
+	return fs, nil
+}
+
+func probeSwap(r io.ReaderAt) (*FSInfo, error) {
+	// The signature ends the first page, whose size depends on the
+	// architecture that created the swap area.
+	for _, page := range []int64{4096, 8192, 16384, 65536} {
+		b, err := readAt(r, page-10, 10)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			break
+		}
+		switch string(b) {
+		case "SWAPSPACE2":
+			h, err := readAt(r, 1024, 44)
+			if h == nil || err != nil {
+				return nil, err
+			}
+			return &FSInfo{Type: "swap", UUID: uuidString(h[12:]), Label: cstring(h[28:44])}, nil
+		case "SWAP-SPACE":
+			return &FSInfo{Type: "swap"}, nil
+		}
+	}
+	return nil, nil
+}
+
+func probeLUKS(r io.ReaderAt) (*FSInfo, error) {
+	h, err := readAt(r, 0, 208)
+	if h == nil || err != nil {
+		return nil, err
+	}
+	if string(h[:6]) != "LUKS\xba\xbe" {
+		return nil, nil
+	}
+	fs := &FSInfo{Type: "crypto_LUKS", UUID: cstring(h[168:208])}
+	// Only LUKS2 headers have a label.
+	if binary.BigEndian.Uint16(h[6:]) == 2 {
+		fs.Label = cstring(h[24:72])
+	}
+	return fs, nil
+}
+
+// mdMagic is the magic number of version 1 md superblocks.
+const mdMagic = 0xa92b4efc
+
+func probeMD(r io.ReaderAt) (*FSInfo, error) {
+	// Version 1.1 superblocks are at the start of the device, 1.2 ones
+	// 4 KiB in.
+	for _, off := range []int64{0, 4096} {
+		sb, err := readAt(r, off, 64)
+		if err != nil {
+			return nil, err
+		}
+		if sb == nil || binary.LittleEndian.Uint32(sb) != mdMagic || binary.LittleEndian.Uint32(sb[4:]) != 1 {
+			continue
+		}
+		return &FSInfo{
+			Type:  "linux_raid_member",
+			UUID:  uuidString(sb[16:32]),
+			Label: cstring(sb[32:64]),
+		}, nil
+	}
+	return nil, nil
+}
+
+func probeLVM2(r io.ReaderAt) (*FSInfo, error) {
+	// The label is in one of the first four sectors.
+	for s := int64(0); s < 4; s++ {
+		l, err := readAt(r, s*512, 64)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil {
+			break
+		}
+		if string(l[:8]) != "LABELONE" || string(l[24:32]) != "LVM2 001" {
+			continue
+		}
+		u := string(l[32:64])
+		return &FSInfo{
+			Type: "LVM2_member",
+			UUID: strings.Join([]string{u[0:6], u[6:10], u[10:14], u[14:18], u[18:22], u[22:26], u[26:32]}, "-"),
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mkfs"
+)
+
+// image is a sparse in-memory device.
+type image []byte
+
+func (m image) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader(m).ReadAt(b, off)
+}
+
+func (m image) WriteAt(b []byte, off int64) (int, error) {
+	return copy(m[off:], b), nil
+}
+
+func (m image) put(off int, b ...[]byte) image {
+	for _, s := range b {
+		off += copy(m[off:], s)
+	}
+	return m
+}
+
+var testUUID = []byte{0x11, 0x11, 0x11, 0x11, 0x22, 0x22, 0x33, 0x33, 0x44, 0x44, 0x55, 0x55, 0x55, 0x55, 0x55, 0x55}
+
+const testUUIDString = "11111111-2222-3333-4444-555555555555"
+
+func TestProbe(t *testing.T) {
+	fat16 := make(image, 16<<20)
+	if err := mkfs.FAT(fat16, int64(len(fat16)), mkfs.FATOptions{Label: "boot", VolumeID: 0x1234abcd}); err != nil {
+		t.Fatal(err)
+	}
+	fat32 := make(image, 600<<20)
+	if err := mkfs.FAT(fat32, int64(len(fat32)), mkfs.FATOptions{VolumeID: 0xdeadbeef}); err != nil {
+		t.Fatal(err)
+	}
+	ext4 := make(image, 4<<20)
+	var u [16]byte
+	copy(u[:], testUUID)
+	if err := mkfs.Ext4(ext4, int64(len(ext4)), mkfs.Ext4Options{Label: "root", UUID: u}); err != nil {
+		t.Fatal(err)
+	}
+
+	ext2 := make(image, 4096).put(1024+56, []byte{0x53, 0xef}).put(1024+104, testUUID)
+	ext3 := make(image, 4096).put(1024+56, []byte{0x53, 0xef}).put(1024+92, []byte{4}).put(1024+120, []byte("data"))
+
+	swap := make(image, 4096).put(1024+12, testUUID, []byte("swp")).put(4096-10, []byte("SWAPSPACE2"))
+	luks := make(image, 4096).put(0, []byte("LUKS\xba\xbe\x00\x02")).put(24, []byte("secret")).put(168, []byte(testUUIDString))
+	xfs := make(image, 4096).put(0, []byte("XFSB")).put(32, testUUID).put(108, []byte("xfsvol"))
+	btrfs := make(image, 0x11000).put(0x10020, testUUID).put(0x10040, []byte("_BHRfS_M")).put(0x1012b, []byte("pool"))
+	md := make(image, 8192).put(4096, []byte{0xfc, 0x4e, 0x2b, 0xa9, 1, 0, 0, 0}).put(4096+16, testUUID, []byte("host:0"))
+	lvm := make(image, 4096).put(512, []byte("LABELONE")).put(512+24, []byte("LVM2 001abcdefghijklmnopqrstuvwxyz0123456"))
+	iso := make(image, 0x9000).put(0x8000, []byte("\x01CD001")).put(0x8000+40, []byte("CDROM                           ")).put(0x8000+813, []byte("2024010203040500"))
+	squash := make(image, 4096).put(0, []byte("hsqs"))
+
+	for _, tt := range []struct {
+		name string
+		img  image
+		want *FSInfo
+	}{
+		{"fat16", fat16, &FSInfo{Type: "vfat", UUID: "1234-ABCD", Label: "BOOT"}},
+		{"fat32", fat32, &FSInfo{Type: "vfat", UUID: "DEAD-BEEF"}},
+		{"ext4", ext4, &FSInfo{Type: "ext4", UUID: testUUIDString, Label: "root"}},
+		{"ext3", ext3, &FSInfo{Type: "ext3", UUID: "00000000-0000-0000-0000-000000000000", Label: "data"}},
+		{"ext2", ext2, &FSInfo{Type: "ext2", UUID: testUUIDString}},
+		{"swap", swap, &FSInfo{Type: "swap", UUID: testUUIDString, Label: "swp"}},
+		{"luks2", luks, &FSInfo{Type: "crypto_LUKS", UUID: testUUIDString, Label: "secret"}},
+		{"xfs", xfs, &FSInfo{Type: "xfs", UUID: testUUIDString, Label: "xfsvol"}},
+		{"btrfs", btrfs, &FSInfo{Type: "btrfs", UUID: testUUIDString, Label: "pool"}},
+		{"md", md, &FSInfo{Type: "linux_raid_member", UUID: testUUIDString, Label: "host:0"}},
+		{"lvm", lvm, &FSInfo{Type: "LVM2_member", UUID: "abcdef-ghij-klmn-opqr-stuv-wxyz-012345"}},
+		{"iso9660", iso, &FSInfo{Type: "iso9660", UUID: "2024-01-02-03-04-05-00", Label: "CDROM"}},
+		{"squashfs", squash, &FSInfo{Type: "squashfs"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe(tt.img)
+			if err != nil {
+				t.Fatalf("Probe() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Probe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeNone(t *testing.T) {
+	for _, size := range []int{0, 100, 1 << 20} {
+		if _, err := Probe(make(image, size)); !errors.Is(err, ErrNoFS) {
+			t.Errorf("Probe(%d zero bytes) = %v, want %v", size, err, ErrNoFS)
+		}
+	}
+}
+
+func TestReadAttrs(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("sda/dev", "8:0")
+	write("sda/size", "2048")
+	write("sda/removable", "1")
+	write("sda/device/model", "Flash Disk  ")
+	write("sda/sda1/partition", "1")
+	write("sda/sda2/partition", "2")
+	write("sda1/dev", "8:1")
+	write("sda1/size", "1024")
+	write("sda1/partition", "1")
+	write("sda1/uevent", "MAJOR=8\nMINOR=1\nPARTN=1\nPARTNAME=EFI\nPARTUUID="+testUUIDString)
+	write("sda1/holders/md0/x", "")
+	write("md0/dev", "9:0")
+	write("md0/md/level", "raid1")
+	write("md0/slaves/sda1/x", "")
+	write("dm-0/dev", "253:0")
+	write("dm-0/dm/name", "vg-root")
+	write("dm-0/dm/uuid", "LVM-abc")
+	write("sr0/dev", "11:0")
+	write("sr0/ro", "1")
+	write("sr0/device/type", "5")
+
+	old := sysClassBlock
+	sysClassBlock = root
+	defer func() { sysClassBlock = old }()
+
+	got, err := ListAttrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Attrs{
+		{Name: "sda", Major: 8, Size: 1 << 20, Removable: true, Type: "disk", Model: "Flash Disk", Partitions: []string{"sda1", "sda2"}},
+		{Name: "sda1", Major: 8, Minor: 1, Size: 512 << 10, Type: "part", PartLabel: "EFI", PartUUID: testUUIDString, Holders: []string{"md0"}},
+		{Name: "md0", Major: 9, Type: "raid1", Slaves: []string{"sda1"}},
+		{Name: "sr0", Major: 11, ReadOnly: true, Type: "rom"},
+		{Name: "dm-0", Major: 253, Type: "lvm"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for _, a := range got {
+			t.Logf("%+v", a)
+		}
+		t.Errorf("ListAttrs() did not return %d expected devices", len(want))
+	}
+
+	if _, err := ReadAttrs("nvme0n1"); err == nil {
+		t.Errorf("ReadAttrs(nonexistent) = nil, want error")
+	}
+}
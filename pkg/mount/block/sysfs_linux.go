@@ -0,0 +1,159 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysClassBlock is where the kernel lists block devices.
+var sysClassBlock = "/sys/class/block"
+
+// Attrs are the sysfs attributes of a block device.
+type Attrs struct {
+	Name         string
+	Major, Minor int
+
+	// Size is in bytes.
+	Size      uint64
+	ReadOnly  bool
+	Removable bool
+
+	// Type is disk, part, loop, rom, lvm, crypt, dm or the RAID level of
+	// md devices, e.g. raid1, as lsblk calls them.
+	Type   string
+	Model  string
+	Serial string
+
+	// PartLabel and PartUUID are the GPT partition name and GUID of
+	// partitions, as far as the kernel reports them.
+	PartLabel string
+	PartUUID  string
+
+	// Partitions, Holders and Slaves name the partitions of a disk, the
+	// devices built on top of this one and those it is built from.
+	Partitions []string
+	Holders    []string
+	Slaves     []string
+}
+
+// readAttr returns the trimmed contents of a sysfs file, or "".
+func readAttr(path ...string) string {
+	b, err := os.ReadFile(filepath.Join(path...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readDirNames returns the sorted entries of a directory, or nil.
+func readDirNames(path ...string) []string {
+	d, err := os.ReadDir(filepath.Join(path...))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range d {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// ReadAttrs reads the sysfs attributes of the named block device.
+func ReadAttrs(name string) (*Attrs, error) {
+	dir := filepath.Join(sysClassBlock, filepath.Base(name))
+	dev, err := os.ReadFile(filepath.Join(dir, "dev"))
+	if err != nil {
+		return nil, err
+	}
+	a := &Attrs{Name: filepath.Base(name)}
+	if maj, min, ok := strings.Cut(strings.TrimSpace(string(dev)), ":"); ok {
+		a.Major, _ = strconv.Atoi(maj)
+		a.Minor, _ = strconv.Atoi(min)
+	}
+	// The size is always in 512 byte sectors.
+	if s, err := strconv.ParseUint(readAttr(dir, "size"), 10, 64); err == nil {
+		a.Size = s * 512
+	}
+	a.ReadOnly = readAttr(dir, "ro") == "1"
+	a.Removable = readAttr(dir, "removable") == "1"
+	a.Model = readAttr(dir, "device", "model")
+	if a.Serial = readAttr(dir, "serial"); a.Serial == "" {
+		a.Serial = readAttr(dir, "device", "serial")
+	}
+	a.Holders = readDirNames(dir, "holders")
+	a.Slaves = readDirNames(dir, "slaves")
+
+	if f, err := os.Open(filepath.Join(dir, "uevent")); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			k, v, _ := strings.Cut(s.Text(), "=")
+			switch k {
+			case "PARTNAME":
+				a.PartLabel = v
+			case "PARTUUID":
+				a.PartUUID = v
+			}
+		}
+		f.Close()
+	}
+
+	for _, n := range readDirNames(dir) {
+		if _, err := os.Stat(filepath.Join(dir, n, "partition")); err == nil {
+			a.Partitions = append(a.Partitions, n)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "partition"))
+	switch dmUUID := readAttr(dir, "dm", "uuid"); {
+	case err == nil:
+		a.Type = "part"
+	case strings.HasPrefix(dmUUID, "LVM-"):
+		a.Type = "lvm"
+	case strings.HasPrefix(dmUUID, "CRYPT-"):
+		a.Type = "crypt"
+	case readAttr(dir, "dm", "name") != "":
+		a.Type = "dm"
+	case readAttr(dir, "md", "level") != "":
+		a.Type = readAttr(dir, "md", "level")
+	case strings.HasPrefix(a.Name, "loop"):
+		a.Type = "loop"
+	case readAttr(dir, "device", "type") == "5":
+		// SCSI type 5 is a CD/DVD drive.
+		a.Type = "rom"
+	default:
+		a.Type = "disk"
+	}
+	return a, nil
+}
+
+// ListAttrs reads the sysfs attributes of all block devices, sorted by
+// device number.
+func ListAttrs() ([]*Attrs, error) {
+	names, err := os.ReadDir(sysClassBlock)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []*Attrs
+	for _, n := range names {
+		a, err := ReadAttrs(n.Name())
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, a)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Major != attrs[j].Major {
+			return attrs[i].Major < attrs[j].Major
+		}
+		return attrs[i].Minor < attrs[j].Minor
+	})
+	return attrs, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrBackup is returned for malformed partition table backups.
+	ErrBackup = errors.New("invalid partition table backup")
+
+	// ErrMBR is returned for hybrid MBRs which cannot be built.
+	ErrMBR = errors.New("invalid hybrid MBR")
+)
+
+// SaveBackup writes p to w in the binary format of sgdisk --backup: the
+// MBR, the primary and the backup header, each a block, and then the
+// partition entries.
+func SaveBackup(w io.Writer, p *PartitionTable) error {
+	if p.MasterBootRecord == nil || p.Primary == nil {
+		return fmt.Errorf("%w: no primary GPT", ErrBackup)
+	}
+	backup := p.Backup
+	if backup == nil {
+		backup = &GPT{Header: p.Primary.Header, Parts: p.Primary.Parts}
+		backup.CurrentLBA, backup.BackupLBA = p.Primary.BackupLBA, p.Primary.CurrentLBA
+		backup.PartStart = p.Primary.BackupLBA - (uint64(p.Primary.NPart)*uint64(p.Primary.PartSize)+BlockSize-1)/BlockSize
+	}
+	primary, parts, err := p.Primary.marshal()
+	if err != nil {
+		return err
+	}
+	secondary, _, err := backup.marshal()
+	if err != nil {
+		return err
+	}
+	for _, b := range [][]byte{p.MasterBootRecord[:], primary[:], secondary[:], parts} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeader reads a header block of a backup and checks its CRC.
+func readHeader(r io.Reader, which string) (Header, error) {
+	var block [BlockSize]byte
+	var h Header
+	if _, err := io.ReadFull(r, block[:]); err != nil {
+		return h, fmt.Errorf("%w: reading %s header: %v", ErrBackup, which, err)
+	}
+	if err := binary.Read(bytes.NewReader(block[:]), binary.LittleEndian, &h); err != nil {
+		return h, err
+	}
+	if h.Signature != Signature || h.HeaderSize != HeaderSize || h.NPart > MaxNPart || h.PartSize < 0x80 || h.PartSize > BlockSize {
+		return h, fmt.Errorf("%w: %s header is not a GPT header", ErrBackup, which)
+	}
+	binary.LittleEndian.PutUint32(block[16:], 0)
+	if crc := crc32.ChecksumIEEE(block[:h.HeaderSize]); crc != h.CRC {
+		return h, fmt.Errorf("%w: %s header CRC is %08x, computed %08x", ErrBackup, which, h.CRC, crc)
+	}
+	return h, nil
+}
+
+// LoadBackup reads a backup written by SaveBackup or sgdisk --backup.
+func LoadBackup(r io.Reader) (*PartitionTable, error) {
+	mbr := &MBR{}
+	if _, err := io.ReadFull(r, mbr[:]); err != nil {
+		return nil, fmt.Errorf("%w: reading MBR: %v", ErrBackup, err)
+	}
+	primary, err := readHeader(r, "primary")
+	if err != nil {
+		return nil, err
+	}
+	backup, err := readHeader(r, "backup")
+	if err != nil {
+		return nil, err
+	}
+	if err := EqualHeader(primary, backup); err != nil {
+		return nil, fmt.Errorf("%w: primary and backup header differ: %v", ErrBackup, err)
+	}
+	entries := make([]byte, int(primary.NPart)*int(primary.PartSize))
+	if _, err := io.ReadFull(r, entries); err != nil {
+		return nil, fmt.Errorf("%w: reading partitions: %v", ErrBackup, err)
+	}
+	if crc := crc32.ChecksumIEEE(entries); crc != primary.PartCRC {
+		return nil, fmt.Errorf("%w: partition CRC is %08x, computed %08x", ErrBackup, primary.PartCRC, crc)
+	}
+	parts := make([]Part, primary.NPart)
+	for i := range parts {
+		e := entries[i*int(primary.PartSize):]
+		if err := binary.Read(bytes.NewReader(e), binary.LittleEndian, &parts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &PartitionTable{
+		MasterBootRecord: mbr,
+		Primary:          &GPT{Header: primary, Parts: parts},
+		Backup:           &GPT{Header: backup, Parts: append([]Part(nil), parts...)},
+	}, nil
+}
+
+// Relocate returns a copy of p for a disk of sectors 512 byte sectors, as
+// when restoring a backup to another disk. The backup GPT moves to the end
+// of the disk and the usable space grows or shrinks with it; all
+// partitions must still fit. A protective MBR is resized.
+func (p *PartitionTable) Relocate(sectors uint64) (*PartitionTable, error) {
+	h := p.Primary.Header
+	blocks := (uint64(h.NPart)*uint64(h.PartSize) + BlockSize - 1) / BlockSize
+	if sectors < 3+2*blocks {
+		return nil, fmt.Errorf("%w: disk of %d sectors is too small", ErrLayout, sectors)
+	}
+	last := sectors - 2 - blocks
+	if h.FirstLBA < 2+blocks || h.FirstLBA > last {
+		return nil, fmt.Errorf("%w: first LBA %d does not fit on a disk of %d sectors", ErrLayout, h.FirstLBA, sectors)
+	}
+	for i, part := range p.Primary.Parts {
+		if part.PartGUID != (GUID{}) && part.LastLBA > last {
+			return nil, fmt.Errorf("%w: partition %d ends at %d, after the last usable sector %d", ErrLayout, i+1, part.LastLBA, last)
+		}
+	}
+	h.CurrentLBA, h.BackupLBA, h.LastLBA, h.PartStart = 1, sectors-1, last, 2
+	backup := h
+	backup.CurrentLBA, backup.BackupLBA = h.BackupLBA, h.CurrentLBA
+	backup.PartStart = sectors - 1 - blocks
+
+	mbr := *p.MasterBootRecord
+	if mbr[450] == 0xee {
+		pm := ProtectiveMBR(sectors)
+		copy(mbr[446:], pm[446:])
+	}
+	return &PartitionTable{
+		MasterBootRecord: &mbr,
+		Primary:          &GPT{Header: h, Parts: append([]Part(nil), p.Primary.Parts...)},
+		Backup:           &GPT{Header: backup, Parts: append([]Part(nil), p.Primary.Parts...)},
+	}, nil
+}
+
+// NewGUIDs gives the disk, if disk is set, and the partitions, if parts
+// is set, new random GUIDs, so that a copy of a disk can be used next to
+// the original.
+func (p *PartitionTable) NewGUIDs(disk, parts bool) error {
+	gpts := []*GPT{p.Primary}
+	if p.Backup != nil {
+		gpts = append(gpts, p.Backup)
+	}
+	if disk {
+		g, err := RandomGUID()
+		if err != nil {
+			return err
+		}
+		for _, t := range gpts {
+			t.DiskGUID = g
+		}
+	}
+	if !parts {
+		return nil
+	}
+	for i, part := range p.Primary.Parts {
+		if part.PartGUID == (GUID{}) {
+			continue
+		}
+		g, err := RandomGUID()
+		if err != nil {
+			return err
+		}
+		for _, t := range gpts {
+			t.Parts[i].UniqueGUID = g
+		}
+	}
+	return nil
+}
+
+// mbrTypes maps partition type GUIDs to MBR partition types. Other types
+// become Linux (0x83).
+var mbrTypes = map[string]byte{
+	Types["esp"]:                           0xef,
+	Types["swap"]:                          0x82,
+	Types["raid"]:                          0xfd,
+	Types["lvm"]:                           0x8e,
+	"EBD0A0A2-B9E5-4433-87C0-68B6B72699C7": 0x07, // Microsoft basic data
+}
+
+// mbrEntry fills in an MBR partition entry. CHS addresses are all set to
+// the maximum, which means to use the LBAs.
+func mbrEntry(e []byte, typ byte, start, size uint64, active bool) {
+	if active {
+		e[0] = 0x80
+	}
+	copy(e[1:4], []byte{0xfe, 0xff, 0xff})
+	e[4] = typ
+	copy(e[5:8], []byte{0xfe, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(e[8:], uint32(start))
+	binary.LittleEndian.PutUint32(e[12:], uint32(size))
+}
+
+// HybridMBR returns an MBR which mirrors up to three partitions of g, by
+// their numbers, for firmware and boot loaders which do not read GPTs. A
+// partition of type 0xEE protects the GPT up to the first mirrored
+// partition. The active partition, if not 0, is marked bootable.
+func HybridMBR(g *GPT, parts []int, active int) (*MBR, error) {
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil, fmt.Errorf("%w: %d partitions, want 1 to 3", ErrMBR, len(parts))
+	}
+	m := &MBR{}
+	first := uint64(1<<32 - 1)
+	for i, n := range parts {
+		if n < 1 || n > len(g.Parts) || g.Parts[n-1].PartGUID == (GUID{}) {
+			return nil, fmt.Errorf("%w: no partition %d", ErrMBR, n)
+		}
+		part := g.Parts[n-1]
+		if part.LastLBA >= 1<<32 {
+			return nil, fmt.Errorf("%w: partition %d ends beyond 2 TiB", ErrMBR, n)
+		}
+		typ, ok := mbrTypes[strings.ToUpper(part.PartGUID.String())]
+		if !ok {
+			typ = 0x83
+		}
+		mbrEntry(m[446+16*(i+1):], typ, part.FirstLBA, part.LastLBA-part.FirstLBA+1, n == active)
+		first = min(first, part.FirstLBA)
+	}
+	if active != 0 && !containsInt(parts, active) {
+		return nil, fmt.Errorf("%w: active partition %d is not mirrored", ErrMBR, active)
+	}
+	mbrEntry(m[446:], 0xee, 1, first-1, false)
+	m[510], m[511] = 0x55, 0xaa
+	return m, nil
+}
+
+func containsInt(l []int, n int) bool {
+	for _, i := range l {
+		if i == n {
+			return true
+		}
+	}
+	return false
+}
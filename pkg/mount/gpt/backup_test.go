@@ -0,0 +1,153 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testTable(t *testing.T, sectors uint64) *PartitionTable {
+	t.Helper()
+	p, err := NewPartitionTable(sectors, &Layout{Partitions: []LayoutPart{
+		{Size: 8192, Type: "esp", Name: "EFI"},
+		{Size: 4096, Type: "swap"},
+		{Type: "linux", Name: "root"},
+	}}, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBackupRestore(t *testing.T) {
+	const sectors = 1 << 16
+	p := testTable(t, sectors)
+	var b bytes.Buffer
+	if err := SaveBackup(&b, p); err != nil {
+		t.Fatal(err)
+	}
+	if want := 3*BlockSize + partEntries*partEntrySize; b.Len() != want {
+		t.Errorf("backup is %d bytes, want %d", b.Len(), want)
+	}
+	q, err := LoadBackup(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(p, q); diff != "" {
+		t.Errorf("restored table (-want +got):\n%s", diff)
+	}
+
+	// Restore to a larger disk: the backup GPT moves to its end.
+	r, err := q.Relocate(2 * sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := filepath.Join(t.TempDir(), "disk")
+	f, err := os.Create(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(2 * sectors * BlockSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(f, r); err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(f)
+	if err != nil {
+		t.Fatalf("reading back the relocated table: %v", err)
+	}
+	if n.Backup.CurrentLBA != 2*sectors-1 || n.Primary.LastLBA != 2*sectors-34 {
+		t.Errorf("backup at %d, last LBA %d, want %d, %d", n.Backup.CurrentLBA, n.Primary.LastLBA, 2*sectors-1, 2*sectors-34)
+	}
+	if size := binary.LittleEndian.Uint32(n.MasterBootRecord[458:]); size != 2*sectors-1 {
+		t.Errorf("protective MBR covers %d sectors, want %d", size, 2*sectors-1)
+	}
+	if diff := cmp.Diff(p.Primary.Parts, n.Primary.Parts); diff != "" {
+		t.Errorf("relocated partitions (-want +got):\n%s", diff)
+	}
+
+	if _, err := q.Relocate(sectors / 2); !errors.Is(err, ErrLayout) {
+		t.Errorf("Relocate to a smaller disk = %v, want %v", err, ErrLayout)
+	}
+
+	for _, off := range []int{BlockSize, 2*BlockSize + 24, 3*BlockSize + 1} {
+		bad := bytes.Clone(b.Bytes())
+		bad[off] ^= 0xff
+		if _, err := LoadBackup(bytes.NewReader(bad)); !errors.Is(err, ErrBackup) {
+			t.Errorf("LoadBackup with byte %d corrupted = %v, want %v", off, err, ErrBackup)
+		}
+	}
+	if _, err := LoadBackup(bytes.NewReader(b.Bytes()[:1000])); !errors.Is(err, ErrBackup) {
+		t.Errorf("LoadBackup of truncated backup = %v, want %v", err, ErrBackup)
+	}
+}
+
+func TestNewGUIDs(t *testing.T) {
+	p := testTable(t, 1<<16)
+	disk, part := p.Primary.DiskGUID, p.Primary.Parts[0].UniqueGUID
+	if err := p.NewGUIDs(true, false); err != nil {
+		t.Fatal(err)
+	}
+	if p.Primary.DiskGUID == disk || p.Backup.DiskGUID != p.Primary.DiskGUID {
+		t.Errorf("disk GUID not changed in both GPTs")
+	}
+	if p.Primary.Parts[0].UniqueGUID != part {
+		t.Errorf("partition GUID changed")
+	}
+	if err := p.NewGUIDs(false, true); err != nil {
+		t.Fatal(err)
+	}
+	if p.Primary.Parts[0].UniqueGUID == part || p.Backup.Parts[0].UniqueGUID != p.Primary.Parts[0].UniqueGUID {
+		t.Errorf("partition GUID not changed in both GPTs")
+	}
+	if p.Primary.Parts[3].UniqueGUID != (GUID{}) {
+		t.Errorf("empty entry got a GUID")
+	}
+}
+
+func TestHybridMBR(t *testing.T) {
+	p := testTable(t, 1<<16)
+	m, err := HybridMBR(p.Primary, []int{1, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &MBR{}
+	for i, e := range [][]byte{
+		{0x00, 0xfe, 0xff, 0xff, 0xee, 0xfe, 0xff, 0xff, 1, 0, 0, 0, 0xff, 0x07, 0, 0},
+		{0x80, 0xfe, 0xff, 0xff, 0xef, 0xfe, 0xff, 0xff, 0x00, 0x08, 0, 0, 0x00, 0x20, 0, 0},
+		{0x00, 0xfe, 0xff, 0xff, 0x83, 0xfe, 0xff, 0xff, 0x00, 0x38, 0, 0, 0x00, 0xc0, 0, 0},
+	} {
+		copy(want[446+16*i:], e)
+	}
+	want[510], want[511] = 0x55, 0xaa
+	if diff := cmp.Diff(want[446:], m[446:]); diff != "" {
+		t.Errorf("hybrid MBR (-want +got):\n%s", diff)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		parts  []int
+		active int
+	}{
+		{name: "none"},
+		{name: "too many", parts: []int{1, 2, 3, 1}},
+		{name: "empty entry", parts: []int{4}},
+		{name: "out of range", parts: []int{0}},
+		{name: "active not mirrored", parts: []int{1}, active: 2},
+	} {
+		if _, err := HybridMBR(p.Primary, tt.parts, tt.active); !errors.Is(err, ErrMBR) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrMBR)
+		}
+	}
+}
@@ -281,42 +281,47 @@ func Write(w io.WriterAt, p *PartitionTable) error {
 	return nil
 }
 
-// Write writes the GPT to w. It generates the partition and header CRC before writing.
-func writeGPT(w io.WriterAt, g *GPT) error {
+// marshal returns the header block and partition entries of g. It
+// generates the partition and header CRC.
+func (g *GPT) marshal() ([BlockSize]byte, []byte, error) {
+	var block [BlockSize]byte
 	// The maximum extent is NPart * PartSize
 	h := make([]byte, uint64(g.NPart*g.PartSize))
 	s := int64(g.PartSize)
 	for i := int64(0); i < int64(g.NPart); i++ {
 		var b bytes.Buffer
 		if err := binary.Write(&b, binary.LittleEndian, &g.Parts[i]); err != nil {
-			return err
+			return block, nil, err
 		}
 		copy(h[i*s:], b.Bytes())
 	}
 
-	ps := int64(g.PartStart * BlockSize)
-	if _, err := w.WriteAt(h, ps); err != nil {
-		return fmt.Errorf("writing %d bytes of partition table at %v: %v", len(h), ps, err)
-	}
-
 	g.PartCRC = crc32.ChecksumIEEE(h[:])
 	g.CRC = 0
 	var b bytes.Buffer
 	if err := binary.Write(&b, binary.LittleEndian, &g.Header); err != nil {
-		return err
+		return block, nil, err
 	}
 
-	var block [BlockSize]byte
 	copy(block[:], b.Bytes())
 	g.CRC = crc32.ChecksumIEEE(block[0:g.HeaderSize])
+	binary.LittleEndian.PutUint32(block[16:], g.CRC)
+	return block, h, nil
+}
 
-	b.Reset()
-	if err := binary.Write(&b, binary.LittleEndian, g.CRC); err != nil {
+// Write writes the GPT to w. It generates the partition and header CRC before writing.
+func writeGPT(w io.WriterAt, g *GPT) error {
+	block, h, err := g.marshal()
+	if err != nil {
 		return err
 	}
-	copy(block[16:], b.Bytes())
 
-	_, err := w.WriteAt(block[:], int64(g.CurrentLBA*BlockSize))
+	ps := int64(g.PartStart * BlockSize)
+	if _, err := w.WriteAt(h, ps); err != nil {
+		return fmt.Errorf("writing %d bytes of partition table at %v: %v", len(h), ps, err)
+	}
+
+	_, err = w.WriteAt(block[:], int64(g.CurrentLBA*BlockSize))
 	return err
 }
 
@@ -0,0 +1,383 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	// ErrGUID is returned for malformed GUIDs.
+	ErrGUID = errors.New("invalid GUID")
+
+	// ErrLayout is returned for partition layouts that do not fit on a
+	// disk.
+	ErrLayout = errors.New("invalid partition layout")
+)
+
+// Types maps short names of common partition types to their type GUIDs.
+// The one letter names are those of sfdisk.
+var Types = map[string]string{
+	"esp":         "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+	"U":           "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+	"linux":       "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+	"L":           "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+	"swap":        "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F",
+	"S":           "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F",
+	"home":        "933AC7E1-2EB4-4F13-B844-0E14E2AEF915",
+	"H":           "933AC7E1-2EB4-4F13-B844-0E14E2AEF915",
+	"raid":        "A19D880F-05FC-4D3B-A006-743F0F84911E",
+	"R":           "A19D880F-05FC-4D3B-A006-743F0F84911E",
+	"lvm":         "E6D6D379-F507-44C2-A23C-238F2A3DF928",
+	"V":           "E6D6D379-F507-44C2-A23C-238F2A3DF928",
+	"bios":        "21686148-6449-6E6F-744E-656564454649",
+	"root-x86-64": "4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709",
+	"root-arm64":  "B921B045-1DF0-41C3-AF44-4C6F280D3FAE",
+}
+
+// ParseGUID parses a GUID in its usual string form, e.g.
+// C12A7328-F81F-11D2-BA4B-00A0C93EC93B.
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 || len(s) != 36 {
+		return g, fmt.Errorf("%w: %q", ErrGUID, s)
+	}
+	g.L = binary.BigEndian.Uint32(b[0:])
+	g.W1 = binary.BigEndian.Uint16(b[4:])
+	g.W2 = binary.BigEndian.Uint16(b[6:])
+	copy(g.B[:], b[8:])
+	return g, nil
+}
+
+// RandomGUID returns a random (version 4) GUID.
+func RandomGUID() (GUID, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return GUID{}, err
+	}
+	g := GUID{
+		L:  binary.BigEndian.Uint32(b[0:]),
+		W1: binary.BigEndian.Uint16(b[4:]),
+		W2: binary.BigEndian.Uint16(b[6:])&0x0fff | 0x4000,
+	}
+	copy(g.B[:], b[8:])
+	g.B[0] = g.B[0]&0x3f | 0x80
+	return g, nil
+}
+
+// String returns the name, which ends at the first NUL.
+func (n *PartName) String() string {
+	u := make([]uint16, 0, len(n)/2)
+	for i := 0; i < len(n); i += 2 {
+		c := binary.LittleEndian.Uint16(n[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// NewPartName encodes s as a partition name of at most 36 UTF-16 code
+// units.
+func NewPartName(s string) (PartName, error) {
+	var n PartName
+	u := utf16.Encode([]rune(s))
+	if len(u) > len(n)/2 {
+		return n, fmt.Errorf("%w: name %q is longer than %d characters", ErrLayout, s, len(n)/2)
+	}
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(n[2*i:], c)
+	}
+	return n, nil
+}
+
+// attrNames are the names sfdisk uses for the defined attribute bits.
+var attrNames = []string{"RequiredPartition", "NoBlockIOProtocol", "LegacyBIOSBootable"}
+
+// String lists the attributes as sfdisk does, e.g.
+// "RequiredPartition GUID:63".
+func (a PartAttr) String() string {
+	var s []string
+	for i := 0; i < 64; i++ {
+		if a&(1<<i) == 0 {
+			continue
+		}
+		if i < len(attrNames) {
+			s = append(s, attrNames[i])
+		} else {
+			s = append(s, fmt.Sprintf("GUID:%d", i))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// ParsePartAttr parses attributes in the form PartAttr.String returns.
+// Bits may also be given by number, and separated by commas.
+func ParsePartAttr(s string) (PartAttr, error) {
+	var a PartAttr
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		bit := -1
+		for i, n := range attrNames {
+			if f == n {
+				bit = i
+			}
+		}
+		if bit < 0 {
+			n, err := strconv.Atoi(strings.TrimPrefix(f, "GUID:"))
+			if err != nil || n < 0 || n > 63 {
+				return 0, fmt.Errorf("%w: attribute %q", ErrLayout, f)
+			}
+			bit = n
+		}
+		a |= 1 << bit
+	}
+	return a, nil
+}
+
+// Layout describes a partition table in the form sfdisk uses for JSON
+// dumps, without the fields that follow from the disk.
+type Layout struct {
+	Label      string       `json:"label"`
+	ID         string       `json:"id,omitempty"`
+	Device     string       `json:"device,omitempty"`
+	Unit       string       `json:"unit"`
+	FirstLBA   uint64       `json:"firstlba,omitempty"`
+	LastLBA    uint64       `json:"lastlba,omitempty"`
+	SectorSize int          `json:"sectorsize,omitempty"`
+	Partitions []LayoutPart `json:"partitions"`
+}
+
+// LayoutPart describes a partition. Start and Size are in sectors.
+type LayoutPart struct {
+	// Node is the device of the partition. When a layout is applied,
+	// its trailing number selects the partition table entry.
+	Node  string `json:"node,omitempty"`
+	Start uint64 `json:"start,omitempty"`
+	Size  uint64 `json:"size,omitempty"`
+	// Type is a type GUID or one of the names in Types.
+	Type  string `json:"type,omitempty"`
+	UUID  string `json:"uuid,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Attrs string `json:"attrs,omitempty"`
+}
+
+// Layout returns the layout of the primary GPT of p. The partitions are
+// named after device, with a "p" in between if it ends in a digit, as
+// Linux names them.
+func (p *PartitionTable) Layout(device string) *Layout {
+	g := p.Primary
+	l := &Layout{
+		Label:      "gpt",
+		ID:         strings.ToUpper(g.DiskGUID.String()),
+		Device:     device,
+		Unit:       "sectors",
+		FirstLBA:   g.FirstLBA,
+		LastLBA:    g.LastLBA,
+		SectorSize: BlockSize,
+		Partitions: []LayoutPart{},
+	}
+	sep := ""
+	if device != "" && strings.ContainsAny(device[len(device)-1:], "0123456789") {
+		sep = "p"
+	}
+	for i, part := range g.Parts {
+		if part.PartGUID == (GUID{}) {
+			continue
+		}
+		lp := LayoutPart{
+			Start: part.FirstLBA,
+			Size:  part.LastLBA - part.FirstLBA + 1,
+			Type:  strings.ToUpper(part.PartGUID.String()),
+			UUID:  strings.ToUpper(part.UniqueGUID.String()),
+			Name:  part.Name.String(),
+			Attrs: part.Attribute.String(),
+		}
+		if device != "" {
+			lp.Node = fmt.Sprintf("%s%s%d", device, sep, i+1)
+		}
+		l.Partitions = append(l.Partitions, lp)
+	}
+	return l
+}
+
+// partEntries is the number of partition entries in new tables, and
+// partEntryBlocks the number of blocks they take up.
+const (
+	partEntries     = MaxNPart
+	partEntrySize   = 0x80
+	partEntryBlocks = partEntries * partEntrySize / BlockSize
+)
+
+// nodeNumber returns the trailing number of a partition device, or 0.
+func nodeNumber(node string) int {
+	i := len(node)
+	for i > 0 && node[i-1] >= '0' && node[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(node[i:])
+	return n
+}
+
+// NewPartitionTable returns a new partition table with a protective MBR
+// for a disk of sectors 512 byte sectors, with the partitions of l.
+//
+// Partitions without a node take the table entry after the previous
+// partition. Partitions without a start begin at the first multiple of align
+// sectors after the previous partition, and the one without a size takes
+// up the remaining space, rounded down to a multiple of align. Partitions
+// without a UUID, and the disk if l has no ID, get random ones. The type
+// defaults to Linux file system data.
+func NewPartitionTable(sectors uint64, l *Layout, align uint64) (*PartitionTable, error) {
+	if align == 0 {
+		align = 1
+	}
+	if sectors < 3+2*partEntryBlocks {
+		return nil, fmt.Errorf("%w: disk of %d sectors is too small", ErrLayout, sectors)
+	}
+	first, last := uint64(2+partEntryBlocks), sectors-2-partEntryBlocks
+	if l.FirstLBA != 0 {
+		if l.FirstLBA < first || l.FirstLBA > last {
+			return nil, fmt.Errorf("%w: first LBA %d outside of [%d, %d]", ErrLayout, l.FirstLBA, first, last)
+		}
+		first = l.FirstLBA
+	}
+	if l.LastLBA != 0 {
+		if l.LastLBA < first || l.LastLBA > last {
+			return nil, fmt.Errorf("%w: last LBA %d outside of [%d, %d]", ErrLayout, l.LastLBA, first, last)
+		}
+		last = l.LastLBA
+	}
+	if len(l.Partitions) > partEntries {
+		return nil, fmt.Errorf("%w: more than %d partitions", ErrLayout, partEntries)
+	}
+
+	disk, err := RandomGUID()
+	if err != nil {
+		return nil, err
+	}
+	if l.ID != "" {
+		if disk, err = ParseGUID(l.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	parts := make([]Part, partEntries)
+	used := make([]bool, partEntries)
+	next := (first + align - 1) / align * align
+	n := -1
+	for _, lp := range l.Partitions {
+		n++
+		if num := nodeNumber(lp.Node); num > 0 {
+			n = num - 1
+		}
+		if n >= partEntries || used[n] {
+			return nil, fmt.Errorf("%w: partition %d is used twice or out of range", ErrLayout, n+1)
+		}
+		used[n] = true
+
+		start := lp.Start
+		if start == 0 {
+			start = next
+		}
+		end := last
+		if lp.Size != 0 {
+			end = start + lp.Size - 1
+		} else if (last+1)/align*align > start {
+			end = (last+1)/align*align - 1
+		}
+		if start < first || end > last || end < start {
+			return nil, fmt.Errorf("%w: partition %d [%d, %d] outside of [%d, %d]", ErrLayout, n+1, start, end, first, last)
+		}
+		next = (end + align) / align * align
+
+		typ := lp.Type
+		if typ == "" {
+			typ = "linux"
+		}
+		if t, ok := Types[typ]; ok {
+			typ = t
+		}
+		p := Part{FirstLBA: start, LastLBA: end}
+		if p.PartGUID, err = ParseGUID(typ); err != nil {
+			return nil, fmt.Errorf("partition %d type: %w", n+1, err)
+		}
+		if lp.UUID != "" {
+			p.UniqueGUID, err = ParseGUID(lp.UUID)
+		} else {
+			p.UniqueGUID, err = RandomGUID()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("partition %d: %w", n+1, err)
+		}
+		if p.Name, err = NewPartName(lp.Name); err != nil {
+			return nil, err
+		}
+		if p.Attribute, err = ParsePartAttr(lp.Attrs); err != nil {
+			return nil, err
+		}
+		parts[n] = p
+	}
+
+	// Partitions must not overlap.
+	var sorted []Part
+	for _, p := range parts {
+		if p.PartGUID != (GUID{}) {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FirstLBA < sorted[j].FirstLBA })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].FirstLBA <= sorted[i-1].LastLBA {
+			return nil, fmt.Errorf("%w: partitions at %d and %d overlap", ErrLayout, sorted[i-1].FirstLBA, sorted[i].FirstLBA)
+		}
+	}
+
+	header := Header{
+		Signature:  Signature,
+		Revision:   Revision,
+		HeaderSize: HeaderSize,
+		CurrentLBA: 1,
+		BackupLBA:  sectors - 1,
+		FirstLBA:   first,
+		LastLBA:    last,
+		DiskGUID:   disk,
+		PartStart:  2,
+		NPart:      partEntries,
+		PartSize:   partEntrySize,
+	}
+	backup := header
+	backup.CurrentLBA, backup.BackupLBA = header.BackupLBA, header.CurrentLBA
+	backup.PartStart = sectors - 1 - partEntryBlocks
+
+	return &PartitionTable{
+		MasterBootRecord: ProtectiveMBR(sectors),
+		Primary:          &GPT{Header: header, Parts: parts},
+		Backup:           &GPT{Header: backup, Parts: append([]Part(nil), parts...)},
+	}, nil
+}
+
+// ProtectiveMBR returns an MBR with a single partition of type 0xEE that
+// covers the disk, so that tools unaware of GPT leave it alone.
+func ProtectiveMBR(sectors uint64) *MBR {
+	m := &MBR{}
+	e := m[446:462]
+	copy(e[1:4], []byte{0x00, 0x02, 0x00})
+	e[4] = 0xee
+	copy(e[5:8], []byte{0xff, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(e[8:], 1)
+	binary.LittleEndian.PutUint32(e[12:], uint32(min(sectors-1, 0xffffffff)))
+	m[510], m[511] = 0x55, 0xaa
+	return m
+}
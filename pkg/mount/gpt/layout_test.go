@@ -0,0 +1,152 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGUID(t *testing.T) {
+	const esp = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	g, err := ParseGUID(esp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.L != 0xc12a7328 || g.W1 != 0xf81f || g.W2 != 0x11d2 || g.B[0] != 0xba || g.B[7] != 0x3b {
+		t.Errorf("ParseGUID(%q) = %#v", esp, g)
+	}
+	if s := strings.ToUpper(g.String()); s != esp {
+		t.Errorf("String() = %q, want %q", s, esp)
+	}
+	for _, bad := range []string{"", "C12A7328F81F11D2BA4B00A0C93EC93B", "C12A7328-F81F-11D2-BA4B-00A0C93EC93", "X12A7328-F81F-11D2-BA4B-00A0C93EC93B"} {
+		if _, err := ParseGUID(bad); !errors.Is(err, ErrGUID) {
+			t.Errorf("ParseGUID(%q) = %v, want %v", bad, err, ErrGUID)
+		}
+	}
+
+	r, err := RandomGUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.W2>>12 != 4 || r.B[0]>>6 != 2 {
+		t.Errorf("RandomGUID() = %v, not a version 4 GUID", r.String())
+	}
+}
+
+func TestPartNameAttr(t *testing.T) {
+	n, err := NewPartName("EFI System ü")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != "EFI System ü" {
+		t.Errorf("name = %q, want %q", s, "EFI System ü")
+	}
+	if _, err := NewPartName(strings.Repeat("x", 37)); !errors.Is(err, ErrLayout) {
+		t.Errorf("37 character name: got %v, want %v", err, ErrLayout)
+	}
+
+	a, err := ParsePartAttr("RequiredPartition LegacyBIOSBootable,GUID:63 48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := PartAttr(1 | 4 | 1<<48 | 1<<63); a != want {
+		t.Errorf("ParsePartAttr = %#x, want %#x", a, want)
+	}
+	if s, want := a.String(), "RequiredPartition LegacyBIOSBootable GUID:48 GUID:63"; s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+	if _, err := ParsePartAttr("GUID:64"); !errors.Is(err, ErrLayout) {
+		t.Errorf("GUID:64: got %v, want %v", err, ErrLayout)
+	}
+}
+
+func TestNewPartitionTable(t *testing.T) {
+	const sectors = 1 << 16 // 32 MiB
+	l := &Layout{
+		ID: "01234567-89AB-CDEF-0123-456789ABCDEF",
+		Partitions: []LayoutPart{
+			{Size: 8192, Type: "esp", Name: "EFI", UUID: "11111111-2222-3333-4444-555555555555", Attrs: "RequiredPartition"},
+			{Node: "disk3", Size: 1000, Type: "swap"},
+			{Type: "linux", Name: "root"},
+		},
+	}
+	p, err := NewPartitionTable(sectors, l, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := filepath.Join(t.TempDir(), "disk")
+	f, err := os.Create(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(sectors * BlockSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(f, p); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := New(f)
+	if err != nil {
+		t.Fatalf("reading back the new table: %v", err)
+	}
+	if q.MasterBootRecord[450] != 0xee || q.MasterBootRecord[510] != 0x55 {
+		t.Errorf("no protective MBR")
+	}
+	got := q.Layout("/dev/nvme0n1")
+	want := &Layout{
+		Label:      "gpt",
+		ID:         "01234567-89AB-CDEF-0123-456789ABCDEF",
+		Device:     "/dev/nvme0n1",
+		Unit:       "sectors",
+		FirstLBA:   34,
+		LastLBA:    sectors - 34,
+		SectorSize: 512,
+		Partitions: []LayoutPart{
+			{Node: "/dev/nvme0n1p1", Start: 2048, Size: 8192, Type: Types["esp"], UUID: "11111111-2222-3333-4444-555555555555", Name: "EFI", Attrs: "RequiredPartition"},
+			{Node: "/dev/nvme0n1p3", Start: 10240, Size: 1000, Type: Types["swap"], UUID: got.Partitions[1].UUID},
+			{Node: "/dev/nvme0n1p4", Start: 12288, Size: sectors - 2048 - 12288, Type: Types["linux"], UUID: got.Partitions[2].UUID, Name: "root"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("layout (-want +got):\n%s", diff)
+	}
+
+	// Applying the dumped layout reproduces the table.
+	r, err := NewPartitionTable(sectors, got, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(p.Primary.Parts, r.Primary.Parts); diff != "" {
+		t.Errorf("restored partitions (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewPartitionTableErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		l    Layout
+		err  error
+	}{
+		{name: "too large", l: Layout{Partitions: []LayoutPart{{Size: 1 << 20}}}, err: ErrLayout},
+		{name: "overlap", l: Layout{Partitions: []LayoutPart{{Start: 2048, Size: 4096}, {Start: 4096, Size: 10}}}, err: ErrLayout},
+		{name: "before first", l: Layout{Partitions: []LayoutPart{{Start: 10, Size: 10}}}, err: ErrLayout},
+		{name: "duplicate", l: Layout{Partitions: []LayoutPart{{Node: "sda1", Size: 10}, {Node: "sda1", Size: 10}}}, err: ErrLayout},
+		{name: "bad type", l: Layout{Partitions: []LayoutPart{{Type: "nope"}}}, err: ErrGUID},
+		{name: "bad id", l: Layout{ID: "nope"}, err: ErrGUID},
+		{name: "first lba", l: Layout{FirstLBA: 2}, err: ErrLayout},
+	} {
+		if _, err := NewPartitionTable(1<<16, &tt.l, 2048); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -5,8 +5,13 @@
 package loop
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"golang.org/x/sys/unix"
 )
@@ -82,3 +87,180 @@ func ClearFile(devicename string) error {
 
 	return ClearFD(int(device.Fd()))
 }
+
+// attachRetries is how often Attach looks for another free device when a
+// concurrent caller claimed the one it found first.
+const attachRetries = 16
+
+// Config describes how a regular file is exposed by a loop device.
+type Config struct {
+	// Flags are LO_FLAGS_* flags for the device.
+	//
+	// With unix.LO_FLAGS_READ_ONLY, the file is opened read-only. With
+	// unix.LO_FLAGS_AUTOCLEAR, the kernel frees the device once the last
+	// file system on it is unmounted and the device is no longer open.
+	// With unix.LO_FLAGS_PARTSCAN, the kernel creates /dev/loopNpM
+	// devices for the partitions on the file.
+	Flags uint32
+
+	// Offset is where the device starts in the file.
+	Offset uint64
+
+	// SizeLimit is the size of the device, or 0 to use the rest of the
+	// file.
+	SizeLimit uint64
+}
+
+// Attach associates filename with a free loop device and returns the open
+// device. Its Name is the device's /dev/loopN path.
+//
+// Without unix.LO_FLAGS_READ_ONLY in c.Flags, Attach falls back to a
+// read-only device if the file cannot be opened for writing. Devices with
+// unix.LO_FLAGS_AUTOCLEAR are freed once the returned file is closed unless
+// they have been mounted, so the file must be kept open until then.
+func Attach(filename string, c Config) (*os.File, error) {
+	return attachDevice("", filename, c)
+}
+
+// AttachDevice associates filename with the loop device devicename and
+// returns the open device, like Attach.
+func AttachDevice(devicename, filename string, c Config) (*os.File, error) {
+	return attachDevice(devicename, filename, c)
+}
+
+func attachDevice(devicename, filename string, c Config) (*os.File, error) {
+	mode := os.O_RDWR
+	if c.Flags&unix.LO_FLAGS_READ_ONLY != 0 {
+		mode = os.O_RDONLY
+	}
+	file, err := os.OpenFile(filename, mode, 0)
+	if err != nil && mode == os.O_RDWR {
+		mode = os.O_RDONLY
+		c.Flags |= unix.LO_FLAGS_READ_ONLY
+		file, err = os.OpenFile(filename, mode, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	find := devicename == ""
+	for i := 0; ; i++ {
+		if find {
+			if devicename, err = FindDevice(); err != nil {
+				return nil, err
+			}
+		}
+		device, err := os.OpenFile(devicename, mode, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = configure(int(device.Fd()), file, c)
+		if err == nil {
+			return device, nil
+		}
+		device.Close()
+		if !find || err != unix.EBUSY || i == attachRetries {
+			return nil, fmt.Errorf("attaching %s to %s: %w", filename, devicename, err)
+		}
+	}
+}
+
+func configure(lfd int, file *os.File, c Config) error {
+	config := unix.LoopConfig{Fd: uint32(file.Fd())}
+	config.Info.Flags = c.Flags
+	config.Info.Offset = c.Offset
+	config.Info.Sizelimit = c.SizeLimit
+	name, err := filepath.Abs(file.Name())
+	if err != nil {
+		name = file.Name()
+	}
+	copy(config.Info.File_name[:len(config.Info.File_name)-1], name)
+	err = unix.IoctlLoopConfigure(lfd, &config)
+	if err != unix.EINVAL && err != unix.ENOTTY {
+		return err
+	}
+
+	// Kernels before 5.8 lack LOOP_CONFIGURE.
+	if err := SetFD(lfd, int(file.Fd())); err != nil {
+		return err
+	}
+	if err := unix.IoctlLoopSetStatus64(lfd, &config.Info); err != nil {
+		_ = ClearFD(lfd)
+		return err
+	}
+	return nil
+}
+
+// Device is an attached loop device.
+type Device struct {
+	// Name is the device's /dev/loopN path.
+	Name string
+
+	// BackingFile is the path of the file the device exposes.
+	BackingFile string
+
+	Config
+}
+
+// Status returns the configuration of the loop device devicename. It
+// returns an error wrapping unix.ENXIO if no file is attached.
+func Status(devicename string) (*Device, error) {
+	f, err := os.Open(devicename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := unix.IoctlLoopGetStatus64(int(f.Fd()))
+	if err != nil {
+		return nil, &os.PathError{Op: "LOOP_GET_STATUS64", Path: devicename, Err: err}
+	}
+	d := &Device{
+		Name: devicename,
+		Config: Config{
+			Flags:     info.Flags,
+			Offset:    info.Offset,
+			SizeLimit: info.Sizelimit,
+		},
+	}
+	// The name in the status is truncated, sysfs has all of it.
+	b, err := os.ReadFile(filepath.Join(sysBlock, filepath.Base(devicename), "loop", "backing_file"))
+	if err == nil {
+		d.BackingFile = strings.TrimSuffix(string(b), "\n")
+	} else {
+		d.BackingFile = unix.ByteSliceToString(info.File_name[:])
+	}
+	return d, nil
+}
+
+// sysBlock is where List looks for loop devices.
+var sysBlock = "/sys/block"
+
+// List returns the attached loop devices.
+func List() ([]*Device, error) {
+	files, err := filepath.Glob(filepath.Join(sysBlock, "loop*", "loop"))
+	if err != nil {
+		return nil, err
+	}
+	var devices []*Device
+	for _, f := range files {
+		name := filepath.Base(filepath.Dir(f))
+		d, err := Status(filepath.Join("/dev", name))
+		if errors.Is(err, unix.ENXIO) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devNumber(devices[i].Name) < devNumber(devices[j].Name)
+	})
+	return devices, nil
+}
+
+func devNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(name), "loop"))
+	return n
+}
@@ -5,6 +5,7 @@
 package loop
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/hugelgupf/vmtest/guest"
 	"github.com/u-root/u-root/pkg/cp"
+	"github.com/u-root/u-root/pkg/mount"
 	"golang.org/x/sys/unix"
 )
 
@@ -66,3 +68,41 @@ func TestSetFile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestAttachAutoclear(t *testing.T) {
+	guest.SkipIfNotInVM(t)
+
+	testdisk := filepath.Join(t.TempDir(), "testdisk")
+	if err := cp.Copy("./testdata/pristine-vfat-disk", testdisk); err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := Attach(testdisk, Config{Flags: unix.LO_FLAGS_AUTOCLEAR | unix.LO_FLAGS_READ_ONLY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	mp, err := mount.Mount(device.Name(), dir, "vfat", "", unix.MS_RDONLY)
+	device.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Status(device.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint32(unix.LO_FLAGS_AUTOCLEAR | unix.LO_FLAGS_READ_ONLY); d.Flags&want != want {
+		t.Errorf("loop flags = %#x, want %#x set", d.Flags, want)
+	}
+	if d.BackingFile != testdisk {
+		t.Errorf("backing file = %q, want %q", d.BackingFile, testdisk)
+	}
+
+	if err := mp.Unmount(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Status(device.Name()); !errors.Is(err, unix.ENXIO) {
+		t.Errorf("status of released device: got %v, want %v", err, unix.ENXIO)
+	}
+}
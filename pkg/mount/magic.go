@@ -28,6 +28,10 @@ var (
 	ISOFS    = []byte{1, 'C', 'D', '0', '0', '1'}
 	SQUASHFS = []byte{'h', 's', 'q', 's'}
 	XFS      = []byte{'X', 'F', 'S', 'B'}
+	// On-disk superblock magics of f2fs and erofs. They are stored
+	// little-endian, unlike the statfs magics below.
+	F2FSSuper  = []byte{0x10, 0x20, 0xf5, 0xf2}
+	EROFSSuper = []byte{0xe2, 0xe1, 0xf5, 0xe0}
 	// There's no fixed magic number for the different FAT varieties
 	// Usually they start with 0xEB but it's not mandatory.
 	// Therefore we just list a few examples that we have seen in the wild.
@@ -130,6 +134,8 @@ var magics = []magic{
 	{magic: VVFAT, name: "vfat", off: 0},
 	{magic: XFS, name: "xfs", off: 0},
 	{magic: BTRFS, name: "btrfs", off: 0x10040},
+	{magic: F2FSSuper, name: "f2fs", off: 0x400},
+	{magic: EROFSSuper, name: "erofs", flags: MS_RDONLY, off: 0x400},
 }
 
 var unknownMagics = []magic{
@@ -0,0 +1,42 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package mount
+
+import "testing"
+
+func TestFindMagics(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		off   int
+		magic []byte
+		want  string
+		flags uintptr
+	}{
+		{name: "btrfs", off: 0x10040, magic: BTRFS, want: "btrfs"},
+		{name: "f2fs", off: 0x400, magic: F2FSSuper, want: "f2fs"},
+		{name: "erofs", off: 0x400, magic: EROFSSuper, want: "erofs", flags: MS_RDONLY},
+		{name: "squashfs", off: 0, magic: SQUASHFS, want: "squashfs", flags: MS_RDONLY},
+		{name: "ext4", off: 0x438, magic: EXT4, want: "ext4"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			blk := make([]byte, blocksize*2)
+			copy(blk[tt.off:], tt.magic)
+			m := FindMagics(blk)
+			if len(m) == 0 {
+				t.Fatalf("FindMagics() found nothing, want %s", tt.want)
+			}
+			if m[0].name != tt.want || m[0].flags != tt.flags {
+				t.Errorf("FindMagics()[0] = %s (flags %#x), want %s (flags %#x)", m[0].name, m[0].flags, tt.want, tt.flags)
+			}
+		})
+	}
+
+	if m := FindMagics(make([]byte, blocksize*2)); len(m) != 0 {
+		t.Errorf("FindMagics(zeros) = %v, want none", m)
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package md reads Linux software RAID (md) superblocks and assembles
+// existing arrays from their members.
+//
+// Both the old 0.90 and the version 1 superblock formats are supported.
+// Creating, growing or repairing arrays is left to mdadm.
+package md
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNoSuperblock is returned for devices that are not array members.
+	ErrNoSuperblock = errors.New("no md superblock found")
+
+	// ErrChecksum is returned for superblocks with a bad checksum.
+	ErrChecksum = errors.New("md superblock checksum mismatch")
+)
+
+// Magic is the magic number of all md superblock versions.
+const Magic = 0xa92b4efc
+
+// Roles of members that are not active devices of the array.
+const (
+	RoleSpare   = -1
+	RoleFaulty  = -2
+	RoleJournal = -3
+)
+
+// Superblock is the md metadata of an array member.
+type Superblock struct {
+	// Version is the superblock format: 0.90, 1.0, 1.1 or 1.2.
+	Version string
+
+	// UUID identifies the array. All members share it.
+	UUID [16]byte
+
+	// Name is the array name of version 1 superblocks, usually
+	// host:name.
+	Name string
+
+	Level     int
+	Layout    uint32
+	RaidDisks int
+
+	// ChunkSize is in bytes.
+	ChunkSize uint32
+
+	// Events counts array updates. Members with fewer events than the
+	// others missed some writes.
+	Events uint64
+
+	// Role is the slot of this member in the array, or one of RoleSpare,
+	// RoleFaulty and RoleJournal.
+	Role int
+
+	// DataOffset and DataSize give the array data on this member in 512
+	// byte sectors. DataSize is 0 if unknown.
+	DataOffset uint64
+	DataSize   uint64
+
+	Created time.Time
+	Updated time.Time
+}
+
+// MinorVersion is the minor version number of the superblock format, as
+// the kernel wants it.
+func (s *Superblock) MinorVersion() int {
+	switch s.Version {
+	case "0.90":
+		return 90
+	case "1.1":
+		return 1
+	case "1.2":
+		return 2
+	}
+	return 0
+}
+
+// UUIDString formats the array UUID like mdadm.
+func (s *Superblock) UUIDString() string {
+	u := s.UUID
+	return fmt.Sprintf("%x:%x:%x:%x", u[0:4], u[4:8], u[8:12], u[12:16])
+}
+
+// LevelName returns the RAID level as mdadm and /proc/mdstat name it.
+func LevelName(level int) string {
+	switch level {
+	case -4:
+		return "multipath"
+	case -1:
+		return "linear"
+	case -5:
+		return "faulty"
+	}
+	return fmt.Sprintf("raid%d", level)
+}
+
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNoSuperblock
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// v1Checksum returns the checksum of a version 1 superblock, whose
+// sb_csum field must be zero.
+func v1Checksum(sb []byte) uint32 {
+	var sum uint64
+	for i := 0; i+4 <= len(sb); i += 4 {
+		sum += uint64(binary.LittleEndian.Uint32(sb[i:]))
+	}
+	return uint32(sum&0xffffffff + sum>>32)
+}
+
+// v1 superblock offsets, in bytes, as in struct mdp_superblock_1.
+const (
+	v1SetUUID     = 16
+	v1SetName     = 32
+	v1Ctime       = 64
+	v1Level       = 72
+	v1Layout      = 76
+	v1ChunkSize   = 88
+	v1RaidDisks   = 92
+	v1DataOffset  = 128
+	v1DataSize    = 136
+	v1SuperOffset = 144
+	v1DevNumber   = 160
+	v1Utime       = 192
+	v1Events      = 200
+	v1Csum        = 216
+	v1MaxDev      = 220
+	v1DevRoles    = 256
+)
+
+// v1Time converts the 40 bit seconds and 24 bit microseconds of version
+// 1 timestamps.
+func v1Time(t uint64) time.Time {
+	return time.Unix(int64(t&0xffffffffff), int64(t>>40)*1000).UTC()
+}
+
+func readV1(r io.ReaderAt, version string, off int64) (*Superblock, error) {
+	h, err := readAt(r, off, v1DevRoles)
+	if err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	if le.Uint32(h) != Magic || le.Uint32(h[4:]) != 1 {
+		return nil, ErrNoSuperblock
+	}
+	maxDev := le.Uint32(h[v1MaxDev:])
+	if maxDev > 384 {
+		return nil, fmt.Errorf("%w: %d devices", ErrNoSuperblock, maxDev)
+	}
+	sb, err := readAt(r, off, v1DevRoles+2*int(maxDev))
+	if err != nil {
+		return nil, err
+	}
+	csum := le.Uint32(sb[v1Csum:])
+	le.PutUint32(sb[v1Csum:], 0)
+	if v1Checksum(sb) != csum {
+		return nil, ErrChecksum
+	}
+	if uint64(off/512) != le.Uint64(sb[v1SuperOffset:]) {
+		return nil, fmt.Errorf("%w: superblock at sector %d claims to be at %d", ErrNoSuperblock, off/512, le.Uint64(sb[v1SuperOffset:]))
+	}
+
+	s := &Superblock{
+		Version:    version,
+		Name:       cstring(sb[v1SetName : v1SetName+32]),
+		Level:      int(int32(le.Uint32(sb[v1Level:]))),
+		Layout:     le.Uint32(sb[v1Layout:]),
+		RaidDisks:  int(le.Uint32(sb[v1RaidDisks:])),
+		ChunkSize:  le.Uint32(sb[v1ChunkSize:]) * 512,
+		Events:     le.Uint64(sb[v1Events:]),
+		DataOffset: le.Uint64(sb[v1DataOffset:]),
+		DataSize:   le.Uint64(sb[v1DataSize:]),
+		Created:    v1Time(le.Uint64(sb[v1Ctime:])),
+		Updated:    v1Time(le.Uint64(sb[v1Utime:])),
+		Role:       RoleSpare,
+	}
+	copy(s.UUID[:], sb[v1SetUUID:])
+	if n := le.Uint32(sb[v1DevNumber:]); n < maxDev {
+		switch role := le.Uint16(sb[v1DevRoles+2*n:]); role {
+		case 0xffff:
+			s.Role = RoleSpare
+		case 0xfffe:
+			s.Role = RoleFaulty
+		case 0xfffd:
+			s.Role = RoleJournal
+		default:
+			s.Role = int(role)
+		}
+	}
+	return s, nil
+}
+
+// 0.90 superblock word offsets, as in struct mdp_superblock_s.
+const (
+	v0Major     = 1
+	v0Minor     = 2
+	v0UUID0     = 5
+	v0Ctime     = 6
+	v0Level     = 7
+	v0Size      = 8
+	v0RaidDisks = 10
+	v0UUID1     = 13
+	v0Utime     = 32
+	v0EventsLo  = 39
+	v0EventsHi  = 40
+	v0Layout    = 64
+	v0ChunkSize = 65
+	v0ThisDisk  = 992
+	v0Bytes     = 4096
+)
+
+// Disk state bits of 0.90 superblocks.
+const (
+	v0DiskFaulty = 1 << 0
+	v0DiskActive = 1 << 1
+	v0DiskSync   = 1 << 2
+)
+
+func readV0(r io.ReaderAt, size int64) (*Superblock, error) {
+	// The superblock is in the last 64 KiB aligned 64 KiB block.
+	off := size&^(64<<10-1) - 64<<10
+	if off < 0 {
+		return nil, ErrNoSuperblock
+	}
+	b, err := readAt(r, off, v0Bytes)
+	if err != nil {
+		return nil, err
+	}
+	// Like the kernel, only read superblocks in the host byte order,
+	// which is little endian on all platforms u-root runs md on.
+	w := func(i int) uint32 { return binary.LittleEndian.Uint32(b[4*i:]) }
+	if w(0) != Magic || w(v0Major) != 0 || w(v0Minor) != 90 {
+		return nil, ErrNoSuperblock
+	}
+
+	s := &Superblock{
+		Version:   "0.90",
+		Level:     int(int32(w(v0Level))),
+		Layout:    w(v0Layout),
+		RaidDisks: int(w(v0RaidDisks)),
+		ChunkSize: w(v0ChunkSize),
+		Events:    uint64(w(v0EventsHi))<<32 | uint64(w(v0EventsLo)),
+		DataSize:  uint64(w(v0Size)) * 2,
+		Created:   time.Unix(int64(w(v0Ctime)), 0).UTC(),
+		Updated:   time.Unix(int64(w(v0Utime)), 0).UTC(),
+	}
+	// mdadm prints the four UUID words in host order; store them so that
+	// the bytes read the same.
+	binary.BigEndian.PutUint32(s.UUID[0:], w(v0UUID0))
+	for i := 0; i < 3; i++ {
+		binary.BigEndian.PutUint32(s.UUID[4+4*i:], w(v0UUID1+i))
+	}
+
+	raidDisk, state := w(v0ThisDisk+3), w(v0ThisDisk+4)
+	switch {
+	case state&v0DiskFaulty != 0:
+		s.Role = RoleFaulty
+	case state&(v0DiskActive|v0DiskSync) == v0DiskActive|v0DiskSync:
+		s.Role = int(raidDisk)
+	default:
+		s.Role = RoleSpare
+	}
+	return s, nil
+}
+
+// ReadSuperblock reads the md superblock of an array member of size bytes.
+func ReadSuperblock(r io.ReaderAt, size int64) (*Superblock, error) {
+	// Version 1.0 superblocks are at least 8 KiB from the end, 4 KiB
+	// aligned.
+	locations := []struct {
+		version string
+		off     int64
+	}{
+		{"1.2", 4096},
+		{"1.1", 0},
+		{"1.0", (size/512 - 16) &^ 7 * 512},
+	}
+	for _, l := range locations {
+		if l.off < 0 {
+			continue
+		}
+		s, err := readV1(r, l.version, l.off)
+		if !errors.Is(err, ErrNoSuperblock) {
+			return s, err
+		}
+	}
+	return readV0(r, size)
+}
+
+// Examine reads the md superblock of a device or image file.
+func Examine(path string) (*Superblock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Seeking to the end also tells the size of block devices.
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ReadSuperblock(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Member is a device with an md superblock.
+type Member struct {
+	Path string
+	*Superblock
+}
+
+// Array is a set of members that share an array UUID.
+type Array struct {
+	UUID    [16]byte
+	Members []*Member
+}
+
+// Name returns the array name, or "" for 0.90 arrays.
+func (a *Array) Name() string {
+	return a.Members[0].Name
+}
+
+// Current returns the members that are up to date, sorted by role. Those
+// that missed updates, for example because they were removed from the
+// array, are left out, as are faulty members.
+func (a *Array) Current() []*Member {
+	var events uint64
+	for _, m := range a.Members {
+		if m.Role != RoleFaulty && m.Events > events {
+			events = m.Events
+		}
+	}
+	var cur []*Member
+	for _, m := range a.Members {
+		if m.Role != RoleFaulty && m.Events == events {
+			cur = append(cur, m)
+		}
+	}
+	sort.SliceStable(cur, func(i, j int) bool {
+		ri, rj := cur[i].Role, cur[j].Role
+		// Spares and journals go last.
+		if ri < 0 || rj < 0 {
+			return ri > rj
+		}
+		return ri < rj
+	})
+	return cur
+}
+
+// Degraded tells if active devices are missing among the current members.
+func (a *Array) Degraded() bool {
+	roles := map[int]bool{}
+	for _, m := range a.Current() {
+		if m.Role >= 0 {
+			roles[m.Role] = true
+		}
+	}
+	return len(roles) < a.Members[0].RaidDisks
+}
+
+// Scan examines devices and groups array members by UUID, in the order
+// the arrays were first seen. Devices without a superblock are skipped.
+func Scan(paths []string) []*Array {
+	var arrays []*Array
+	byUUID := map[[16]byte]*Array{}
+	for _, p := range paths {
+		s, err := Examine(p)
+		if err != nil {
+			continue
+		}
+		a, ok := byUUID[s.UUID]
+		if !ok {
+			a = &Array{UUID: s.UUID}
+			byUUID[s.UUID] = a
+			arrays = append(arrays, a)
+		}
+		a.Members = append(a.Members, &Member{Path: p, Superblock: s})
+	}
+	return arrays
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package md
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNoMembers is returned when assembling an array without members.
+var ErrNoMembers = errors.New("no usable array members")
+
+// mdMajor is the block device major number of md devices.
+const mdMajor = 9
+
+// md ioctls from linux/raid/md_u.h.
+const (
+	ioctlAddNewDisk   = 0x40140921
+	ioctlSetArrayInfo = 0x40480923
+	ioctlRunArray     = 0x400c0930
+	ioctlStopArray    = 0x932
+)
+
+// arrayInfo is mdu_array_info_t.
+type arrayInfo struct {
+	MajorVersion  int32
+	MinorVersion  int32
+	PatchVersion  int32
+	Ctime         int32
+	Level         int32
+	Size          int32
+	NrDisks       int32
+	RaidDisks     int32
+	MdMinor       int32
+	NotPersistent int32
+	Utime         int32
+	State         int32
+	ActiveDisks   int32
+	WorkingDisks  int32
+	FailedDisks   int32
+	SpareDisks    int32
+	Layout        int32
+	ChunkSize     int32
+}
+
+// diskInfo is mdu_disk_info_t.
+type diskInfo struct {
+	Number   int32
+	Major    int32
+	Minor    int32
+	RaidDisk int32
+	State    int32
+}
+
+// sysBlock is where md devices have their sysfs attributes.
+var sysBlock = "/sys/block"
+
+// Minor returns the minor number of an md device path such as /dev/md0.
+func Minor(path string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "md"))
+	if err != nil || !strings.HasPrefix(filepath.Base(path), "md") || n < 0 {
+		return 0, fmt.Errorf("%q is not an md device name like /dev/md0", path)
+	}
+	return n, nil
+}
+
+// open opens an md device, creating its node if it does not exist yet.
+func open(path string) (*os.File, int, error) {
+	minor, err := Minor(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := unix.Mknod(path, unix.S_IFBLK|0o600, int(unix.Mkdev(mdMajor, uint32(minor)))); err != nil {
+			return nil, 0, &os.PathError{Op: "mknod", Path: path, Err: err}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	return f, minor, err
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Assemble starts the array on the md device at path, e.g. /dev/md0, from
+// its current members. Read-only arrays are not written to at all, not
+// even to update superblocks, which is what a boot loader wants.
+func (a *Array) Assemble(path string, readOnly bool) error {
+	members := a.Current()
+	if len(members) == 0 {
+		return ErrNoMembers
+	}
+	f, minor, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// With no disks, SET_ARRAY_INFO only tells the kernel which
+	// superblock format to load from the members.
+	info := arrayInfo{
+		MajorVersion: int32(members[0].Version[0] - '0'),
+		MinorVersion: int32(members[0].MinorVersion()),
+	}
+	if err := ioctl(f, ioctlSetArrayInfo, unsafe.Pointer(&info)); err != nil {
+		return fmt.Errorf("%s: SET_ARRAY_INFO: %w", path, err)
+	}
+
+	for _, m := range members {
+		var st unix.Stat_t
+		if err := unix.Stat(m.Path, &st); err != nil {
+			stop(f)
+			return &os.PathError{Op: "stat", Path: m.Path, Err: err}
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+			stop(f)
+			return fmt.Errorf("%s: not a block device", m.Path)
+		}
+		d := diskInfo{
+			Major: int32(unix.Major(uint64(st.Rdev))),
+			Minor: int32(unix.Minor(uint64(st.Rdev))),
+		}
+		if err := ioctl(f, ioctlAddNewDisk, unsafe.Pointer(&d)); err != nil {
+			stop(f)
+			return fmt.Errorf("%s: adding %s: %w", path, m.Path, err)
+		}
+	}
+
+	if readOnly {
+		// Writing readonly to array_state of an inactive array starts it
+		// read-only.
+		state := filepath.Join(sysBlock, fmt.Sprintf("md%d", minor), "md", "array_state")
+		err = os.WriteFile(state, []byte("readonly"), 0)
+	} else {
+		err = ioctl(f, ioctlRunArray, nil)
+	}
+	if err != nil {
+		stop(f)
+		return fmt.Errorf("%s: starting array: %w", path, err)
+	}
+	return nil
+}
+
+// stop releases the members of a partially assembled array.
+func stop(f *os.File) {
+	_ = ioctl(f, ioctlStopArray, nil)
+}
+
+// Stop stops the md device at path and releases its members.
+func Stop(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := ioctl(f, ioctlStopArray, nil); err != nil {
+		return fmt.Errorf("%s: STOP_ARRAY: %w", path, err)
+	}
+	return nil
+}
+
+// InUse tells if the md device with the given minor number holds an
+// array.
+func InUse(minor int) bool {
+	// Opening an md device creates it, so unused ones may exist in the
+	// clear state.
+	state, err := os.ReadFile(filepath.Join(sysBlock, fmt.Sprintf("md%d", minor), "md", "array_state"))
+	return err == nil && strings.TrimSpace(string(state)) != "clear"
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package md
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type image []byte
+
+func (m image) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader(m).ReadAt(b, off)
+}
+
+var (
+	testUUID = [16]byte{0x3c, 0x8f, 0xd1, 0xb4, 0x2b, 0x1a, 0x6a, 0x6e, 0x8f, 0x1c, 0x4d, 0x33, 0x0d, 0x9f, 0x1c, 0x11}
+	created  = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// v1Member writes a version 1 superblock at sector for the member with
+// the given dev_number of an array whose roles are roles.
+func v1Member(img []byte, sector uint64, devNumber uint32, roles []uint16, events uint64) {
+	le := binary.LittleEndian
+	sb := img[sector*512:]
+	le.PutUint32(sb, Magic)
+	le.PutUint32(sb[4:], 1)
+	copy(sb[v1SetUUID:], testUUID[:])
+	copy(sb[v1SetName:], "host:root")
+	le.PutUint64(sb[v1Ctime:], uint64(created.Unix()))
+	le.PutUint32(sb[v1Level:], 1)
+	le.PutUint32(sb[v1RaidDisks:], 2)
+	le.PutUint64(sb[v1DataOffset:], 2048)
+	le.PutUint64(sb[v1DataSize:], 4096)
+	le.PutUint64(sb[v1SuperOffset:], sector)
+	le.PutUint32(sb[v1DevNumber:], devNumber)
+	le.PutUint64(sb[v1Utime:], uint64(created.Unix())|123<<40)
+	le.PutUint64(sb[v1Events:], events)
+	le.PutUint32(sb[v1MaxDev:], uint32(len(roles)))
+	for i, r := range roles {
+		le.PutUint16(sb[v1DevRoles+2*i:], r)
+	}
+	n := v1DevRoles + 2*len(roles)
+	le.PutUint32(sb[v1Csum:], v1Checksum(sb[:n]))
+}
+
+func v0Member(img []byte, raidDisk, state uint32) {
+	sb := img[len(img)&^(64<<10-1)-64<<10:]
+	w := func(i int, v uint32) { binary.LittleEndian.PutUint32(sb[4*i:], v) }
+	w(0, Magic)
+	w(v0Minor, 90)
+	w(v0UUID0, 0x3c8fd1b4)
+	w(v0UUID1, 0x2b1a6a6e)
+	w(v0UUID1+1, 0x8f1c4d33)
+	w(v0UUID1+2, 0x0d9f1c11)
+	w(v0Ctime, uint32(created.Unix()))
+	w(v0Utime, uint32(created.Unix()))
+	w(v0Level, 1)
+	w(v0Size, 1024)
+	w(v0RaidDisks, 2)
+	w(v0EventsLo, 7)
+	w(v0EventsHi, 1)
+	w(v0ThisDisk+3, raidDisk)
+	w(v0ThisDisk+4, state)
+}
+
+func TestReadSuperblock(t *testing.T) {
+	const size = 1 << 20
+	v12 := make(image, size)
+	v1Member(v12, 8, 1, []uint16{0, 1}, 19)
+
+	v10 := make(image, size+3*512)
+	v10sector := uint64((len(v10)/512 - 16) &^ 7)
+	v1Member(v10, v10sector, 2, []uint16{1, 0xfffe, 0xffff}, 5)
+
+	v11 := make(image, size)
+	v1Member(v11, 0, 0, []uint16{0xfffe}, 5)
+
+	v090 := make(image, size+4096)
+	v0Member(v090, 1, v0DiskActive|v0DiskSync)
+
+	badCsum := make(image, size)
+	v1Member(badCsum, 8, 0, []uint16{0}, 1)
+	badCsum[8*512+v1Events]++
+
+	v1Want := func(version string, role int, events uint64) *Superblock {
+		return &Superblock{
+			Version:    version,
+			UUID:       testUUID,
+			Name:       "host:root",
+			Level:      1,
+			RaidDisks:  2,
+			Events:     events,
+			Role:       role,
+			DataOffset: 2048,
+			DataSize:   4096,
+			Created:    created,
+			Updated:    created.Add(123 * time.Microsecond),
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		img  image
+		want *Superblock
+		err  error
+	}{
+		{name: "1.2", img: v12, want: v1Want("1.2", 1, 19)},
+		{name: "1.0 spare", img: v10, want: v1Want("1.0", RoleSpare, 5)},
+		{name: "1.1 faulty", img: v11, want: v1Want("1.1", RoleFaulty, 5)},
+		{
+			name: "0.90",
+			img:  v090,
+			want: &Superblock{
+				Version:   "0.90",
+				UUID:      testUUID,
+				Level:     1,
+				RaidDisks: 2,
+				Events:    1<<32 | 7,
+				Role:      1,
+				DataSize:  2048,
+				Created:   created,
+				Updated:   created,
+			},
+		},
+		{name: "checksum", img: badCsum, err: ErrChecksum},
+		{name: "none", img: make(image, size), err: ErrNoSuperblock},
+		{name: "tiny", img: make(image, 1000), err: ErrNoSuperblock},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadSuperblock(tt.img, int64(len(tt.img)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReadSuperblock() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadSuperblock() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	s, _ := ReadSuperblock(v12, size)
+	if got, want := s.UUIDString(), "3c8fd1b4:2b1a6a6e:8f1c4d33:0d9f1c11"; got != want {
+		t.Errorf("UUIDString() = %q, want %q", got, want)
+	}
+	if s.MinorVersion() != 2 {
+		t.Errorf("MinorVersion() = %d, want 2", s.MinorVersion())
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	member := func(name string, devNumber uint32, roles []uint16, events uint64) string {
+		img := make([]byte, 1<<20)
+		if roles != nil {
+			v1Member(img, 8, devNumber, roles, events)
+		}
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, img, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	roles := []uint16{1, 0, 0xffff, 2}
+	a := member("a", 0, roles, 10)
+	b := member("b", 1, roles, 10)
+	spare := member("spare", 2, roles, 10)
+	stale := member("stale", 3, roles, 8)
+	none := member("none", 0, nil, 0)
+
+	arrays := Scan([]string{spare, a, none, stale, b, filepath.Join(dir, "missing")})
+	if len(arrays) != 1 {
+		t.Fatalf("Scan() found %d arrays, want 1", len(arrays))
+	}
+	arr := arrays[0]
+	if arr.UUID != testUUID || arr.Name() != "host:root" || len(arr.Members) != 4 {
+		t.Errorf("Scan() = %+v, want array host:root with 4 members", arr)
+	}
+	var got []string
+	for _, m := range arr.Current() {
+		got = append(got, filepath.Base(m.Path))
+	}
+	if want := []string{"b", "a", "spare"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Current() = %q, want %q", got, want)
+	}
+	if arr.Degraded() {
+		t.Errorf("Degraded() = true, want false")
+	}
+
+	arr = Scan([]string{a, spare})[0]
+	if !arr.Degraded() {
+		t.Errorf("Degraded() without member b = false, want true")
+	}
+}
+
+func TestLevelName(t *testing.T) {
+	for level, want := range map[int]string{-1: "linear", 0: "raid0", 1: "raid1", 10: "raid10", -4: "multipath"} {
+		if got := LevelName(level); got != want {
+			t.Errorf("LevelName(%d) = %q, want %q", level, got, want)
+		}
+	}
+}
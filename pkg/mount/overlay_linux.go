@@ -0,0 +1,114 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOverlayConfig is returned for invalid overlay layer configurations.
+var ErrOverlayConfig = errors.New("invalid overlay configuration")
+
+// Overlay describes the layers of an overlayfs mount.
+type Overlay struct {
+	// Lower are the read-only layers, top-most first.
+	Lower []string
+	// Upper is the writable layer. If empty, the overlay is read-only.
+	Upper string
+	// Work is a scratch directory on the same file system as Upper. It
+	// is required if Upper is set.
+	Work string
+}
+
+// escapeOverlayPath escapes the characters that separate overlayfs
+// options and lower layers.
+func escapeOverlayPath(p string) string {
+	return strings.NewReplacer(`\`, `\\`, `:`, `\:`, `,`, `\,`).Replace(p)
+}
+
+// Data returns the overlayfs mount options for o.
+func (o Overlay) Data() (string, error) {
+	if len(o.Lower) == 0 {
+		return "", fmt.Errorf("%w: no lower layer", ErrOverlayConfig)
+	}
+	if (o.Upper == "") != (o.Work == "") {
+		return "", fmt.Errorf("%w: upper and work directories must be set together", ErrOverlayConfig)
+	}
+	lower := make([]string, len(o.Lower))
+	for i, l := range o.Lower {
+		lower[i] = escapeOverlayPath(l)
+	}
+	data := "lowerdir=" + strings.Join(lower, ":")
+	if o.Upper != "" {
+		data += ",upperdir=" + escapeOverlayPath(o.Upper) + ",workdir=" + escapeOverlayPath(o.Work)
+	}
+	return data, nil
+}
+
+// MountOverlay mounts the overlay o at path.
+func MountOverlay(o Overlay, path string, flags uintptr, opts ...func() error) (*MountPoint, error) {
+	data, err := o.Data()
+	if err != nil {
+		return nil, err
+	}
+	if o.Upper == "" {
+		flags |= MS_RDONLY
+	}
+	return Mount("overlay", path, "overlay", data, flags, opts...)
+}
+
+// LiveOverlay mounts the read-only file system image on device (e.g. a
+// squashfs or erofs root) under scratch, puts a tmpfs-backed writable layer
+// on top of it and mounts the combination at path.
+//
+// It returns the overlay and the mounts it depends on, in the order they
+// have to be unmounted. On error, nothing is left mounted.
+func LiveOverlay(device, path, scratch string) (*MountPoint, []*MountPoint, error) {
+	lowerDir := filepath.Join(scratch, "lower")
+	rwDir := filepath.Join(scratch, "rw")
+	var mps []*MountPoint
+	undo := func() {
+		for i := len(mps) - 1; i >= 0; i-- {
+			_ = mps[i].Unmount(MNT_DETACH)
+		}
+	}
+
+	lower, err := TryMount(device, lowerDir, "", ReadOnly, func() error { return os.MkdirAll(lowerDir, 0o755) })
+	if err != nil {
+		return nil, nil, err
+	}
+	mps = append(mps, lower)
+
+	rw, err := Mount("tmpfs", rwDir, "tmpfs", "mode=0755", 0, func() error { return os.MkdirAll(rwDir, 0o755) })
+	if err != nil {
+		undo()
+		return nil, nil, err
+	}
+	mps = append(mps, rw)
+
+	o := Overlay{
+		Lower: []string{lowerDir},
+		Upper: filepath.Join(rwDir, "upper"),
+		Work:  filepath.Join(rwDir, "work"),
+	}
+	ov, err := MountOverlay(o, path, 0, func() error {
+		for _, d := range []string{o.Upper, o.Work, path} {
+			if err := os.MkdirAll(d, 0o755); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		undo()
+		return nil, nil, err
+	}
+	// Unmount order: overlay first, then its layers.
+	return ov, []*MountPoint{rw, lower}, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOverlayData(t *testing.T) {
+	for _, tt := range []struct {
+		o    Overlay
+		want string
+		err  error
+	}{
+		{
+			o:    Overlay{Lower: []string{"/a", "/b"}},
+			want: "lowerdir=/a:/b",
+		},
+		{
+			o:    Overlay{Lower: []string{"/ro"}, Upper: "/rw/upper", Work: "/rw/work"},
+			want: "lowerdir=/ro,upperdir=/rw/upper,workdir=/rw/work",
+		},
+		{
+			o:    Overlay{Lower: []string{`/a:b,c\d`}},
+			want: `lowerdir=/a\:b\,c\\d`,
+		},
+		{o: Overlay{}, err: ErrOverlayConfig},
+		{o: Overlay{Lower: []string{"/a"}, Upper: "/u"}, err: ErrOverlayConfig},
+		{o: Overlay{Lower: []string{"/a"}, Work: "/w"}, err: ErrOverlayConfig},
+	} {
+		got, err := tt.o.Data()
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%+v.Data() = %q, %v, want %q, %v", tt.o, got, err, tt.want, tt.err)
+		}
+	}
+}
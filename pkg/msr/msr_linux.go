@@ -13,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/intel-go/cpuid"
 )
@@ -147,31 +148,58 @@ func (c CPUs) paths() []string {
 	return p
 }
 
+// each calls f for the MSR on every CPU in c, in parallel, with the MSR
+// device of the CPU opened with flag o and positioned at the MSR. It returns
+// nil if all calls succeeded, or an error for each CPU otherwise.
+func (m MSR) each(c CPUs, o int, f func(i int, port *os.File) error) []error {
+	files, errs := openAll(c.paths(), o)
+	if errs != nil {
+		return errs
+	}
+	errs = make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer files[i].Close()
+			errs[i] = doIO(files[i], m, func(port *os.File) error {
+				return f(i, port)
+			})
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
 // Read reads an MSR from a set of CPUs.
 func (m MSR) Read(c CPUs) ([]uint64, []error) {
-	var hadErr bool
 	regs := make([]uint64, len(c))
-
-	paths := c.paths()
-	f, errs := openAll(paths, os.O_RDONLY)
-	if errs != nil {
+	if errs := m.each(c, os.O_RDONLY, func(i int, port *os.File) error {
+		return binary.Read(port, binary.LittleEndian, &regs[i])
+	}); errs != nil {
 		return nil, errs
 	}
-	errs = make([]error, len(f))
-	for i := range f {
-		defer f[i].Close()
-		errs[i] = doIO(f[i], m, func(port *os.File) error {
-			return binary.Read(port, binary.LittleEndian, &regs[i])
-		})
-		if errs[i] != nil {
-			hadErr = true
+	return regs, nil
+}
+
+// expand returns data with a value for each CPU in c.
+func expand(c CPUs, data []uint64) ([]uint64, error) {
+	if len(data) == 1 {
+		// Expand value to all cpus
+		for i := 1; i < len(c); i++ {
+			data = append(data, data[0])
 		}
 	}
-	if hadErr {
-		return nil, errs
+	if len(data) != len(c) {
+		return nil, fmt.Errorf("mismatched lengths: cpus %v, data %v", c, data)
 	}
-
-	return regs, nil
+	return data, nil
 }
 
 // Write writes values to an MSR on a set of CPUs.
@@ -182,81 +210,63 @@ func (m MSR) Read(c CPUs) ([]uint64, []error) {
 // If multiple data values are given, each will be written to its corresponding
 // CPU.
 func (m MSR) Write(c CPUs, data ...uint64) []error {
-	var hadErr bool
-
-	if len(data) == 1 {
-		// Expand value to all cpus
-		for i := 1; i < len(c); i++ {
-			data = append(data, data[0])
-		}
-	}
-	if len(data) != len(c) {
-		return []error{fmt.Errorf("mismatched lengths: cpus %v, data %v", c, data)}
+	data, err := expand(c, data)
+	if err != nil {
+		return []error{err}
 	}
+	return m.each(c, os.O_RDWR, func(i int, port *os.File) error {
+		return binary.Write(port, binary.LittleEndian, data[i])
+	})
+}
 
-	paths := c.paths()
-	f, errs := openAll(paths, os.O_RDWR)
-
-	if errs != nil {
-		return errs
+// WriteVerify writes values like Write, then reads them back. It returns an
+// error for each CPU on which the MSR does not hold the value written, e.g.
+// because it is locked or the value has reserved bits set.
+func (m MSR) WriteVerify(c CPUs, data ...uint64) []error {
+	data, err := expand(c, data)
+	if err != nil {
+		return []error{err}
 	}
-	errs = make([]error, len(f))
-	for i := range f {
-		defer f[i].Close()
-		errs[i] = doIO(f[i], m, func(port *os.File) error {
-			return binary.Write(port, binary.LittleEndian, data[i])
-		})
-		if errs[i] != nil {
-			hadErr = true
+	return m.each(c, os.O_RDWR, func(i int, port *os.File) error {
+		if err := binary.Write(port, binary.LittleEndian, data[i]); err != nil {
+			return err
 		}
-	}
-	if hadErr {
-		return errs
-	}
-	return nil
+		// Like the read in testAndSetMaybe, the write does not move
+		// the offset.
+		var v uint64
+		if err := binary.Read(port, binary.LittleEndian, &v); err != nil {
+			return err
+		}
+		if v != data[i] {
+			return fmt.Errorf("cpu %d: msr %v is %#x after writing %#x", c[i], m, v, data[i])
+		}
+		return nil
+	})
 }
 
 // testAndSetMaybe takes a mask of bits to clear and to set, and applies them to the specified MSR in
 // each of the CPUs. It will set the MSR only if the value is different and a set is requested.
 // If the MSR is different for any reason that is an error.
 func (m MSR) testAndSetMaybe(c CPUs, clearMask uint64, setMask uint64, set bool) []error {
-	var hadErr bool
-	paths := c.paths()
-	f, errs := openAll(paths, os.O_RDWR)
-
-	if errs != nil {
-		return errs
-	}
-	errs = make([]error, len(f))
-	for i := range f {
-		defer f[i].Close()
-		errs[i] = doIO(f[i], m, func(port *os.File) error {
-			var v uint64
-			err := binary.Read(port, binary.LittleEndian, &v)
-			if err != nil {
-				return err
-			}
-			n := v & ^clearMask
-			n |= setMask
-			// We write only if there is a change. This is to avoid
-			// cases where we try to set a lock bit again, but the bit is
-			// already set
-			if n != v && set {
-				return binary.Write(port, binary.LittleEndian, n)
-			}
-			if n != v {
-				return fmt.Errorf("%#x", v)
-			}
-			return nil
-		})
-		if errs[i] != nil {
-			hadErr = true
+	return m.each(c, os.O_RDWR, func(i int, port *os.File) error {
+		var v uint64
+		err := binary.Read(port, binary.LittleEndian, &v)
+		if err != nil {
+			return err
 		}
-	}
-	if hadErr {
-		return errs
-	}
-	return nil
+		n := v & ^clearMask
+		n |= setMask
+		// We write only if there is a change. This is to avoid
+		// cases where we try to set a lock bit again, but the bit is
+		// already set
+		if n != v && set {
+			return binary.Write(port, binary.LittleEndian, n)
+		}
+		if n != v {
+			return fmt.Errorf("%#x", v)
+		}
+		return nil
+	})
 }
 
 // Test takes a mask of bits to clear and to set, and returns an error for those
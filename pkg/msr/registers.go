@@ -0,0 +1,293 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field is a bitfield of an MSR.
+type Field struct {
+	Name string
+	// Lo and Hi are the lowest and highest bits of the field.
+	Lo, Hi uint
+	// Format formats the value of the field. If it is nil, single bits
+	// are shown as 0 or 1 and wider fields in hex.
+	Format func(v uint64) string
+}
+
+// Value returns the field in v.
+func (f Field) Value(v uint64) uint64 {
+	return (v >> f.Lo) & (1<<(f.Hi-f.Lo+1) - 1)
+}
+
+// String formats the field in v.
+func (f Field) String(v uint64) string {
+	fv := f.Value(v)
+	switch {
+	case f.Format != nil:
+		return f.Format(fv)
+	case f.Lo == f.Hi:
+		return strconv.FormatUint(fv, 10)
+	default:
+		return fmt.Sprintf("%#x", fv)
+	}
+}
+
+// Register is a well-known MSR.
+type Register struct {
+	// Name is the name of the register in the Intel SDM.
+	Name   string
+	Addr   MSR
+	Fields []Field
+}
+
+// ratio formats a bus ratio with the frequency it gives at 100 MHz.
+func ratio(v uint64) string {
+	return fmt.Sprintf("%d (%d MHz)", v, v*100)
+}
+
+// pageAddr formats a field holding bits 12 and up of an address.
+func pageAddr(v uint64) string {
+	return fmt.Sprintf("%#x", v<<12)
+}
+
+// Registers are the MSRs known by name. The fields are those of the Intel SDM,
+// volume 4.
+var Registers = []Register{
+	{Name: "IA32_TIME_STAMP_COUNTER", Addr: 0x10},
+	{
+		Name: "IA32_APIC_BASE",
+		Addr: 0x1b,
+		Fields: []Field{
+			{Name: "BSP", Lo: 8, Hi: 8},
+			{Name: "x2APIC mode", Lo: 10, Hi: 10},
+			{Name: "APIC global enable", Lo: 11, Hi: 11},
+			{Name: "APIC base", Lo: 12, Hi: 51, Format: pageAddr},
+		},
+	},
+	{
+		Name: "IA32_FEATURE_CONTROL",
+		Addr: IntelIA32FeatureControl,
+		Fields: []Field{
+			{Name: "Lock", Lo: 0, Hi: 0},
+			{Name: "VMX inside SMX", Lo: 1, Hi: 1},
+			{Name: "VMX outside SMX", Lo: 2, Hi: 2},
+			{Name: "SENTER local functions", Lo: 8, Hi: 14},
+			{Name: "SENTER global enable", Lo: 15, Hi: 15},
+			{Name: "SGX launch control", Lo: 17, Hi: 17},
+			{Name: "SGX global enable", Lo: 18, Hi: 18},
+			{Name: "LMCE", Lo: 20, Hi: 20},
+		},
+	},
+	{
+		Name: "IA32_BIOS_SIGN_ID",
+		Addr: 0x8b,
+		Fields: []Field{
+			{Name: "Microcode revision", Lo: 32, Hi: 63},
+		},
+	},
+	{
+		Name: "MSR_PLATFORM_INFO",
+		Addr: 0xce,
+		Fields: []Field{
+			{Name: "Maximum non-turbo ratio", Lo: 8, Hi: 15, Format: ratio},
+			{Name: "Programmable turbo ratio limit", Lo: 28, Hi: 28},
+			{Name: "Programmable TDP limit", Lo: 29, Hi: 29},
+			{Name: "Programmable TJ offset", Lo: 30, Hi: 30},
+			{Name: "Maximum efficiency ratio", Lo: 40, Hi: 47, Format: ratio},
+			{Name: "Minimum operating ratio", Lo: 48, Hi: 55, Format: ratio},
+		},
+	},
+	{
+		Name: "MSR_PKG_CST_CONFIG_CONTROL",
+		Addr: IntelPkgCstConfigControl,
+		Fields: []Field{
+			{Name: "Package C-state limit", Lo: 0, Hi: 3},
+			{Name: "I/O MWAIT redirection", Lo: 10, Hi: 10},
+			{Name: "CFG lock", Lo: 15, Hi: 15},
+			{Name: "C3 auto demotion", Lo: 25, Hi: 25},
+			{Name: "C1 auto demotion", Lo: 26, Hi: 26},
+		},
+	},
+	{
+		Name: "IA32_MTRRCAP",
+		Addr: 0xfe,
+		Fields: []Field{
+			{Name: "Variable range registers", Lo: 0, Hi: 7},
+			{Name: "Fixed range registers", Lo: 8, Hi: 8},
+			{Name: "Write combining", Lo: 10, Hi: 10},
+			{Name: "SMRR", Lo: 11, Hi: 11},
+		},
+	},
+	{
+		Name: "MSR_FEATURE_CONFIG",
+		Addr: IntelFeatureConfig,
+		Fields: []Field{
+			{Name: "Lock", Lo: 0, Hi: 0},
+			{Name: "AES disable", Lo: 1, Hi: 1},
+		},
+	},
+	{
+		Name: "IA32_PERF_STATUS",
+		Addr: 0x198,
+		Fields: []Field{
+			{Name: "Current ratio", Lo: 8, Hi: 15, Format: ratio},
+		},
+	},
+	{
+		Name: "IA32_PERF_CTL",
+		Addr: 0x199,
+		Fields: []Field{
+			{Name: "Target ratio", Lo: 8, Hi: 15, Format: ratio},
+			{Name: "Turbo disengage", Lo: 32, Hi: 32},
+		},
+	},
+	{
+		Name: "IA32_THERM_STATUS",
+		Addr: 0x19c,
+		Fields: []Field{
+			{Name: "Thermal status", Lo: 0, Hi: 0},
+			{Name: "PROCHOT", Lo: 2, Hi: 2},
+			{Name: "Critical temperature", Lo: 4, Hi: 4},
+			{Name: "Digital readout", Lo: 16, Hi: 22},
+			{Name: "Reading valid", Lo: 31, Hi: 31},
+		},
+	},
+	{
+		Name: "IA32_MISC_ENABLE",
+		Addr: 0x1a0,
+		Fields: []Field{
+			{Name: "Fast strings", Lo: 0, Hi: 0},
+			{Name: "Automatic thermal control", Lo: 3, Hi: 3},
+			{Name: "Performance monitoring available", Lo: 7, Hi: 7},
+			{Name: "BTS unavailable", Lo: 11, Hi: 11},
+			{Name: "PEBS unavailable", Lo: 12, Hi: 12},
+			{Name: "Enhanced SpeedStep", Lo: 16, Hi: 16},
+			{Name: "MONITOR FSM", Lo: 18, Hi: 18},
+			{Name: "Limit CPUID maxval", Lo: 22, Hi: 22},
+			{Name: "xTPR message disable", Lo: 23, Hi: 23},
+			{Name: "XD bit disable", Lo: 34, Hi: 34},
+			{Name: "Turbo mode disable", Lo: 38, Hi: 38},
+		},
+	},
+	{
+		Name: "MSR_TEMPERATURE_TARGET",
+		Addr: 0x1a2,
+		Fields: []Field{
+			{Name: "Temperature target", Lo: 16, Hi: 23},
+			{Name: "TCC activation offset", Lo: 24, Hi: 29},
+		},
+	},
+	{Name: "MSR_TURBO_RATIO_LIMIT", Addr: 0x1ad},
+	{
+		Name: "MSR_RAPL_POWER_UNIT",
+		Addr: 0x606,
+		Fields: []Field{
+			{Name: "Power units", Lo: 0, Hi: 3},
+			{Name: "Energy status units", Lo: 8, Hi: 12},
+			{Name: "Time units", Lo: 16, Hi: 19},
+		},
+	},
+	{
+		Name: "MSR_PKG_POWER_LIMIT",
+		Addr: 0x610,
+		Fields: []Field{
+			{Name: "Power limit 1", Lo: 0, Hi: 14},
+			{Name: "Enable limit 1", Lo: 15, Hi: 15},
+			{Name: "Clamping limit 1", Lo: 16, Hi: 16},
+			{Name: "Power limit 2", Lo: 32, Hi: 46},
+			{Name: "Enable limit 2", Lo: 47, Hi: 47},
+			{Name: "Clamping limit 2", Lo: 48, Hi: 48},
+			{Name: "Lock", Lo: 63, Hi: 63},
+		},
+	},
+	{
+		Name: "MSR_DRAM_POWER_LIMIT",
+		Addr: IntelDramPowerLimit,
+		Fields: []Field{
+			{Name: "Power limit", Lo: 0, Hi: 14},
+			{Name: "Enable limit", Lo: 15, Hi: 15},
+			{Name: "Lock", Lo: 31, Hi: 31},
+		},
+	},
+	{
+		Name: "MSR_CONFIG_TDP_CONTROL",
+		Addr: IntelConfigTDPControl,
+		Fields: []Field{
+			{Name: "TDP level", Lo: 0, Hi: 1},
+			{Name: "Lock", Lo: 31, Hi: 31},
+		},
+	},
+	{
+		Name: "IA32_DEBUG_INTERFACE",
+		Addr: IntelIA32DebugInterface,
+		Fields: []Field{
+			{Name: "Enable", Lo: 0, Hi: 0},
+			{Name: "Lock", Lo: 30, Hi: 30},
+			{Name: "Debug occurred", Lo: 31, Hi: 31},
+		},
+	},
+	{
+		Name: "IA32_EFER",
+		Addr: 0xc0000080,
+		Fields: []Field{
+			{Name: "SYSCALL enable", Lo: 0, Hi: 0},
+			{Name: "IA-32e mode enable", Lo: 8, Hi: 8},
+			{Name: "IA-32e mode active", Lo: 10, Hi: 10},
+			{Name: "Execute disable", Lo: 11, Hi: 11},
+		},
+	},
+}
+
+// LookupRegister returns the register with a name, ignoring case. The
+// prefixes IA32_ and MSR_ may be left out.
+func LookupRegister(name string) (Register, bool) {
+	name = strings.ToUpper(name)
+	for _, r := range Registers {
+		if r.Name == name || strings.TrimPrefix(strings.TrimPrefix(r.Name, "IA32_"), "MSR_") == name {
+			return r, true
+		}
+	}
+	return Register{}, false
+}
+
+// RegisterAt returns the register at an address, if it is known.
+func RegisterAt(m MSR) (Register, bool) {
+	for _, r := range Registers {
+		if r.Addr == m {
+			return r, true
+		}
+	}
+	return Register{}, false
+}
+
+// ParseMSR parses an MSR given by number or by the name of a register.
+func ParseMSR(s string) (MSR, error) {
+	if r, ok := LookupRegister(s); ok {
+		return r.Addr, nil
+	}
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a known register nor a number: %w", s, err)
+	}
+	return MSR(n), nil
+}
+
+// Decode returns the fields of the register in v, one per line.
+func (r Register) Decode(v uint64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%v): 0x%016x\n", r.Name, r.Addr, v)
+	for _, f := range r.Fields {
+		bits := fmt.Sprintf("%d", f.Lo)
+		if f.Hi != f.Lo {
+			bits = fmt.Sprintf("%d:%d", f.Hi, f.Lo)
+		}
+		fmt.Fprintf(&b, "\t[%s] %s: %s\n", bits, f.Name, f.String(v))
+	}
+	return b.String()
+}
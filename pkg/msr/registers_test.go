@@ -0,0 +1,89 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"testing"
+)
+
+func TestParseMSR(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    MSR
+		wantErr bool
+	}{
+		{in: "0x3a", want: 0x3a},
+		{in: "IA32_FEATURE_CONTROL", want: 0x3a},
+		{in: "ia32_feature_control", want: 0x3a},
+		{in: "feature_control", want: 0x3a},
+		{in: "PLATFORM_INFO", want: 0xce},
+		{in: "IA32_DEBUG_INTERFACE", want: 0xc80},
+		{in: "NO_SUCH_MSR", wantErr: true},
+		{in: "0x100000000", wantErr: true},
+	} {
+		got, err := ParseMSR(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMSR(%q) = %v, want error %t", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseMSR(%q) = %#x, want %#x", tt.in, uint32(got), uint32(tt.want))
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		v    uint64
+		want string
+	}{
+		{
+			name: "IA32_FEATURE_CONTROL",
+			v:    0x5,
+			want: `IA32_FEATURE_CONTROL (0x3a): 0x0000000000000005
+	[0] Lock: 1
+	[1] VMX inside SMX: 0
+	[2] VMX outside SMX: 1
+	[14:8] SENTER local functions: 0x0
+	[15] SENTER global enable: 0
+	[17] SGX launch control: 0
+	[18] SGX global enable: 0
+	[20] LMCE: 0
+`,
+		},
+		{
+			name: "MSR_PLATFORM_INFO",
+			v:    0x8080010001800,
+			want: `MSR_PLATFORM_INFO (0xce): 0x0008080010001800
+	[15:8] Maximum non-turbo ratio: 24 (2400 MHz)
+	[28] Programmable turbo ratio limit: 1
+	[29] Programmable TDP limit: 0
+	[30] Programmable TJ offset: 0
+	[47:40] Maximum efficiency ratio: 8 (800 MHz)
+	[55:48] Minimum operating ratio: 8 (800 MHz)
+`,
+		},
+		{
+			name: "IA32_APIC_BASE",
+			v:    0xfee00900,
+			want: `IA32_APIC_BASE (0x1b): 0x00000000fee00900
+	[8] BSP: 1
+	[10] x2APIC mode: 0
+	[11] APIC global enable: 1
+	[51:12] APIC base: 0xfee00000
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := LookupRegister(tt.name)
+			if !ok {
+				t.Fatalf("LookupRegister(%q) = false, want true", tt.name)
+			}
+			if got := r.Decode(tt.v); got != tt.want {
+				t.Errorf("Decode(%#x) =\n%s\nwant\n%s", tt.v, got, tt.want)
+			}
+		})
+	}
+}
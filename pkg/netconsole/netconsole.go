@@ -0,0 +1,223 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package netconsole sends kernel and init logs to a UDP collector, either
+// by configuring the kernel's netconsole module or from userspace, for
+// diagnosing boots of machines without serial access.
+package netconsole
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRemotePort is the port collectors listen on by default.
+const DefaultRemotePort = 6666
+
+// ErrInvalidTarget is returned for malformed netconsole targets.
+var ErrInvalidTarget = errors.New("invalid netconsole target")
+
+// Target is where and how logs are sent. It corresponds to the netconsole
+// module parameter
+//
+//	[+][r][src-port]@[src-ip]/[dev],[tgt-port]@<tgt-ip>/[tgt-macaddr]
+//
+// See https://docs.kernel.org/networking/netconsole.html.
+type Target struct {
+	// Extended sends messages with metadata, like /dev/kmsg records.
+	Extended bool
+	// Release prepends the kernel release to messages.
+	Release bool
+
+	LocalPort int
+	LocalIP   net.IP
+	Dev       string
+
+	RemotePort int
+	RemoteIP   net.IP
+	RemoteMAC  net.HardwareAddr
+}
+
+// String returns t in the syntax of the netconsole module parameter.
+func (t *Target) String() string {
+	var b strings.Builder
+	if t.Extended {
+		b.WriteByte('+')
+	}
+	if t.Release {
+		b.WriteByte('r')
+	}
+	if t.LocalPort != 0 {
+		b.WriteString(strconv.Itoa(t.LocalPort))
+	}
+	b.WriteByte('@')
+	if t.LocalIP != nil {
+		b.WriteString(t.LocalIP.String())
+	}
+	fmt.Fprintf(&b, "/%s,", t.Dev)
+	if t.RemotePort != 0 {
+		b.WriteString(strconv.Itoa(t.RemotePort))
+	}
+	fmt.Fprintf(&b, "@%s/", t.RemoteIP)
+	if t.RemoteMAC != nil {
+		b.WriteString(t.RemoteMAC.String())
+	}
+	return b.String()
+}
+
+// splitEndpoint splits [port]@[ip]/[rest].
+func splitEndpoint(s string) (port int, ip net.IP, rest string, err error) {
+	p, addr, ok := strings.Cut(s, "@")
+	if !ok {
+		return 0, nil, "", fmt.Errorf("%w: %q has no @", ErrInvalidTarget, s)
+	}
+	if p != "" {
+		if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+			return 0, nil, "", fmt.Errorf("%w: port %q", ErrInvalidTarget, p)
+		}
+	}
+	addr, rest, _ = strings.Cut(addr, "/")
+	if addr != "" {
+		// IPv6 addresses may be bracketed.
+		if ip = net.ParseIP(strings.Trim(addr, "[]")); ip == nil {
+			return 0, nil, "", fmt.Errorf("%w: IP address %q", ErrInvalidTarget, addr)
+		}
+	}
+	return port, ip, rest, nil
+}
+
+// ParseTarget parses the netconsole module parameter syntax. Unset ports
+// are left 0; the kernel and Dial use 6665 and DefaultRemotePort.
+func ParseTarget(s string) (*Target, error) {
+	t := &Target{}
+	local, remote, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q has no remote", ErrInvalidTarget, s)
+	}
+	for len(local) > 0 {
+		if local[0] == '+' {
+			t.Extended = true
+		} else if local[0] == 'r' {
+			t.Release = true
+		} else {
+			break
+		}
+		local = local[1:]
+	}
+
+	var err error
+	if t.LocalPort, t.LocalIP, t.Dev, err = splitEndpoint(local); err != nil {
+		return nil, err
+	}
+	var mac string
+	if t.RemotePort, t.RemoteIP, mac, err = splitEndpoint(remote); err != nil {
+		return nil, err
+	}
+	if t.RemoteIP == nil {
+		return nil, fmt.Errorf("%w: %q has no remote IP address", ErrInvalidTarget, s)
+	}
+	if mac != "" {
+		if t.RemoteMAC, err = net.ParseMAC(mac); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+		}
+	}
+	return t, nil
+}
+
+// Record is a kernel log record as read from /dev/kmsg.
+type Record struct {
+	Facility int
+	Level    int
+	Seq      uint64
+	// Time is the time since boot.
+	Time time.Duration
+	Msg  string
+}
+
+// ParseRecord parses a /dev/kmsg record, e.g.
+//
+//	6,339,5140900,-;NET: Registered protocol family 10
+//
+// Dictionary lines following the message are ignored.
+func ParseRecord(b []byte) (*Record, error) {
+	prefix, msg, ok := bytes.Cut(b, []byte{';'})
+	if !ok {
+		return nil, fmt.Errorf("kmsg record %q has no message", b)
+	}
+	fields := strings.Split(string(prefix), ",")
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("kmsg record prefix %q has %d fields, want at least 3", prefix, len(fields))
+	}
+	pri, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("kmsg record priority: %w", err)
+	}
+	seq, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("kmsg record sequence number: %w", err)
+	}
+	usec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("kmsg record time stamp: %w", err)
+	}
+	msg, _, _ = bytes.Cut(msg, []byte{'\n'})
+	return &Record{
+		Facility: pri >> 3,
+		Level:    pri & 7,
+		Seq:      seq,
+		Time:     time.Duration(usec) * time.Microsecond,
+		Msg:      string(msg),
+	}, nil
+}
+
+// Format returns r as the kernel's netconsole sends it: the message in
+// plain form, or with the record prefix in extended form.
+func (r *Record) Format(extended bool) string {
+	if extended {
+		return fmt.Sprintf("%d,%d,%d,-;%s\n", r.Facility<<3|r.Level, r.Seq, r.Time.Microseconds(), r.Msg)
+	}
+	return fmt.Sprintf("[%5d.%06d] %s\n", r.Time/time.Second, (r.Time%time.Second).Microseconds(), r.Msg)
+}
+
+// maxRecord is the largest /dev/kmsg record.
+const maxRecord = 8192
+
+// errRecordsLost is returned by reads of /dev/kmsg when records were
+// overwritten before they were read.
+var errRecordsLost = errors.New("kernel log records lost")
+
+// Forward reads kernel log records, one per Read, from kmsg, and writes
+// those of at most level to w, e.g. a connection from Dial. It returns when
+// kmsg returns io.EOF or an error other than a lost record.
+func Forward(w io.Writer, kmsg io.Reader, level int, extended bool) error {
+	buf := make([]byte, maxRecord)
+	for {
+		n, err := kmsg.Read(buf)
+		if errors.Is(err, errRecordsLost) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r, err := ParseRecord(buf[:n])
+		if err != nil {
+			continue
+		}
+		if r.Level > level {
+			continue
+		}
+		if _, err := io.WriteString(w, r.Format(extended)); err != nil {
+			return err
+		}
+	}
+}
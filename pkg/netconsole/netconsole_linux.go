@@ -0,0 +1,154 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netconsole
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/kmodule"
+	"golang.org/x/sys/unix"
+)
+
+// configfsDir is where netconsole targets are configured at run time.
+var configfsDir = "/sys/kernel/config/netconsole"
+
+// Configure adds the dynamic netconsole target name to the kernel, loading
+// the netconsole module if needed. configfs must be mounted.
+func Configure(name string, t *Target) error {
+	if _, err := os.Stat(configfsDir); os.IsNotExist(err) {
+		if err := kmodule.Probe("netconsole", ""); err != nil {
+			return fmt.Errorf("loading netconsole module: %w", err)
+		}
+	}
+	dir := filepath.Join(configfsDir, name)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return err
+	}
+
+	attrs := []struct {
+		name, value string
+	}{
+		{"dev_name", t.Dev},
+		{"remote_ip", ipString(t.RemoteIP)},
+		{"remote_mac", macString(t.RemoteMAC)},
+		{"local_ip", ipString(t.LocalIP)},
+		{"remote_port", portString(t.RemotePort)},
+		{"local_port", portString(t.LocalPort)},
+		{"extended", boolString(t.Extended)},
+		{"release", boolString(t.Release)},
+		{"enabled", "1"},
+	}
+	for _, a := range attrs {
+		if a.value == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, a.name), []byte(a.value), 0o644); err != nil {
+			return fmt.Errorf("setting netconsole %s to %q: %w", a.name, a.value, err)
+		}
+	}
+	return nil
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func macString(mac net.HardwareAddr) string {
+	if mac == nil {
+		return ""
+	}
+	return mac.String()
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+func boolString(b bool) string {
+	if !b {
+		return ""
+	}
+	return "1"
+}
+
+// Dial returns a UDP connection to the collector of t, sent from the local
+// port, address and device of t if they are set.
+func Dial(t *Target) (net.Conn, error) {
+	d := net.Dialer{}
+	if t.LocalIP != nil || t.LocalPort != 0 {
+		d.LocalAddr = &net.UDPAddr{IP: t.LocalIP, Port: t.LocalPort}
+	}
+	if t.Dev != "" {
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = unix.BindToDevice(int(fd), t.Dev)
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	port := t.RemotePort
+	if port == 0 {
+		port = DefaultRemotePort
+	}
+	return d.Dial("udp", net.JoinHostPort(t.RemoteIP.String(), strconv.Itoa(port)))
+}
+
+// kmsg reads records from /dev/kmsg with read(2), rather than through the
+// runtime poller, so that non-blocking reads return when the log is
+// exhausted.
+type kmsg struct {
+	fd int
+}
+
+// OpenKmsg opens /dev/kmsg for Forward. With follow, reads wait for new
+// records; without, they return io.EOF at the end of the log.
+func OpenKmsg(follow bool) (io.ReadCloser, error) {
+	flags := unix.O_RDONLY | unix.O_CLOEXEC
+	if !follow {
+		flags |= unix.O_NONBLOCK
+	}
+	fd, err := unix.Open("/dev/kmsg", flags, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: "/dev/kmsg", Err: err}
+	}
+	return &kmsg{fd: fd}, nil
+}
+
+func (k *kmsg) Read(b []byte) (int, error) {
+	for {
+		n, err := unix.Read(k.fd, b)
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.EPIPE):
+			return 0, errRecordsLost
+		case errors.Is(err, unix.EAGAIN):
+			return 0, io.EOF
+		case err != nil:
+			return 0, err
+		}
+		return n, nil
+	}
+}
+
+func (k *kmsg) Close() error {
+	return unix.Close(k.fd)
+}
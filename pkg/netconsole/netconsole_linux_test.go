@@ -0,0 +1,57 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netconsole
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	configfsDir = t.TempDir()
+	tgt, err := ParseTarget("@/eth0,@10.0.0.2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure("uroot", tgt); err != nil {
+		t.Fatal(err)
+	}
+	for attr, want := range map[string]string{
+		"dev_name":  "eth0",
+		"remote_ip": "10.0.0.2",
+		"enabled":   "1",
+	} {
+		got, err := os.ReadFile(filepath.Join(configfsDir, "uroot", attr))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", attr, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(configfsDir, "uroot", "remote_port")); !os.IsNotExist(err) {
+		t.Errorf("remote_port was written, want kernel default")
+	}
+}
+
+func TestDial(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("no UDP: %v", err)
+	}
+	defer l.Close()
+	c, err := Dial(&Target{RemoteIP: net.IPv4(127, 0, 0, 1), RemotePort: l.LocalAddr().(*net.UDPAddr).Port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 16)
+	n, _, err := l.ReadFromUDP(b)
+	if err != nil || string(b[:n]) != "hello\n" {
+		t.Errorf("collector got %q, %v, want hello", b[:n], err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netconsole
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	mac, _ := net.ParseMAC("00:09:5b:22:33:44")
+	for _, tt := range []struct {
+		in   string
+		want *Target
+		err  error
+	}{
+		{
+			in: "4444@10.0.0.1/eth1,9353@10.0.0.2/00:09:5b:22:33:44",
+			want: &Target{
+				LocalPort: 4444, LocalIP: net.ParseIP("10.0.0.1"), Dev: "eth1",
+				RemotePort: 9353, RemoteIP: net.ParseIP("10.0.0.2"), RemoteMAC: mac,
+			},
+		},
+		{
+			in:   "+r@/,@2001:db8::1/",
+			want: &Target{Extended: true, Release: true, RemoteIP: net.ParseIP("2001:db8::1")},
+		},
+		{in: "@/eth0,@/", err: ErrInvalidTarget},
+		{in: "@/eth0", err: ErrInvalidTarget},
+		{in: "x@/eth0,@10.0.0.2/", err: ErrInvalidTarget},
+		{in: "@/eth0,@10.0.0.2/zz", err: ErrInvalidTarget},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTarget(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseTarget() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseTarget() = %+v, want %+v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.in {
+				t.Errorf("String() = %q, want %q", got.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	r, err := ParseRecord([]byte("6,339,5140900,-;NET: Registered protocol family 10\n SUBSYSTEM=net\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Record{Level: 6, Seq: 339, Time: 5140900 * time.Microsecond, Msg: "NET: Registered protocol family 10"}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("ParseRecord() = %+v, want %+v", r, want)
+	}
+	if got, want := r.Format(false), "[    5.140900] NET: Registered protocol family 10\n"; got != want {
+		t.Errorf("Format(false) = %q, want %q", got, want)
+	}
+	if got, want := r.Format(true), "6,339,5140900,-;NET: Registered protocol family 10\n"; got != want {
+		t.Errorf("Format(true) = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"no message", "6,1;short", "x,1,2,-;msg"} {
+		if _, err := ParseRecord([]byte(bad)); err == nil {
+			t.Errorf("ParseRecord(%q) = nil, want error", bad)
+		}
+	}
+}
+
+// records returns one record per Read, like /dev/kmsg.
+type records struct {
+	recs []string
+	errs []error
+}
+
+func (r *records) Read(b []byte) (int, error) {
+	if len(r.recs) == 0 {
+		return 0, io.EOF
+	}
+	rec, err := r.recs[0], r.errs[0]
+	r.recs, r.errs = r.recs[1:], r.errs[1:]
+	return copy(b, rec), err
+}
+
+func TestForward(t *testing.T) {
+	r := &records{
+		recs: []string{"3,1,1000000,-;disk failed", "", "7,2,2000000,-;debug", "garbage", "5,3,3000000,-;init: hi"},
+		errs: []error{nil, errRecordsLost, nil, nil, nil},
+	}
+	var b bytes.Buffer
+	if err := Forward(&b, r, 6, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "[    1.000000] disk failed\n[    3.000000] init: hi\n"; got != want {
+		t.Errorf("Forward() sent %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	layoutFile    = "oci-layout"
+	layoutVersion = "1.0.0"
+	indexFile     = "index.json"
+
+	// maxDocument bounds the size of the JSON documents read.
+	maxDocument = 4 << 20
+)
+
+// ErrLayout is returned for directories which are not OCI image layouts.
+var ErrLayout = errors.New("not an OCI image layout")
+
+// Layout is an OCI image layout: a directory of blobs named by their
+// digests, and an index of the manifests among them.
+type Layout struct {
+	Dir string
+}
+
+type layoutMarker struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+// OpenLayout opens the image layout in dir.
+func OpenLayout(dir string) (*Layout, error) {
+	b, err := os.ReadFile(filepath.Join(dir, layoutFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLayout, err)
+	}
+	var m layoutMarker
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrLayout, layoutFile, err)
+	}
+	if !strings.HasPrefix(m.ImageLayoutVersion, "1.") {
+		return nil, fmt.Errorf("%w: version %q", ErrLayout, m.ImageLayoutVersion)
+	}
+	return &Layout{Dir: dir}, nil
+}
+
+// CreateLayout opens the image layout in dir, creating an empty one if
+// there is none.
+func CreateLayout(dir string) (*Layout, error) {
+	if l, err := OpenLayout(dir); err == nil {
+		return l, nil
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "blobs"), 0o755); err != nil {
+		return nil, err
+	}
+	l := &Layout{Dir: dir}
+	if err := l.writeIndex(&Index{SchemaVersion: 2, MediaType: MediaTypeIndex, Manifests: []Descriptor{}}); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(layoutMarker{ImageLayoutVersion: layoutVersion})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, layoutFile), b, 0o644); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// path returns the file name of the blob with the given digest.
+func (l *Layout) path(digest string) (string, error) {
+	_, sum, err := newHash(digest)
+	if err != nil {
+		return "", err
+	}
+	alg, _, _ := strings.Cut(digest, ":")
+	return filepath.Join(l.Dir, "blobs", alg, sum), nil
+}
+
+// Has reports whether the layout holds the blob d refers to.
+func (l *Layout) Has(d Descriptor) bool {
+	p, err := l.path(d.Digest)
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(p)
+	return err == nil && fi.Size() == d.Size
+}
+
+type blob struct {
+	*verifier
+	f *os.File
+}
+
+// Close implements io.Closer.
+func (b blob) Close() error {
+	return b.f.Close()
+}
+
+// Open returns the blob d refers to. Reading it to the end fails with
+// ErrDigest if it does not match d.
+func (l *Layout) Open(d Descriptor) (io.ReadCloser, error) {
+	p, err := l.path(d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	v, err := newVerifier(f, d)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return blob{verifier: v, f: f}, nil
+}
+
+// Write adds the blob d refers to, read from r, to the layout. Nothing is
+// added if it does not match d.
+func (l *Layout) Write(d Descriptor, r io.Reader) error {
+	p, err := l.path(d.Digest)
+	if err != nil {
+		return err
+	}
+	v, err := newVerifier(r, d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, v); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", d.Digest, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// readJSON decodes the document d refers to into v.
+func (l *Layout) readJSON(d Descriptor, v any) error {
+	if d.Size > maxDocument {
+		return fmt.Errorf("%s is too large: %d bytes", d.Digest, d.Size)
+	}
+	r, err := l.Open(d)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %w", d.Digest, err)
+	}
+	return nil
+}
+
+// Index returns the index of the layout.
+func (l *Layout) Index() (*Index, error) {
+	b, err := os.ReadFile(filepath.Join(l.Dir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, fmt.Errorf("%s: %w", indexFile, err)
+	}
+	return &idx, nil
+}
+
+func (l *Layout) writeIndex(idx *Index) error {
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(l.Dir, indexFile)
+	if err := os.WriteFile(name+".tmp", b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// Tag adds d to the index of the layout under the name ref, in place of
+// any manifest which had that name.
+func (l *Layout) Tag(d Descriptor, ref string) error {
+	idx, err := l.Index()
+	if err != nil {
+		return err
+	}
+	d.Annotations = map[string]string{AnnotationRefName: ref}
+	manifests := []Descriptor{d}
+	for _, m := range idx.Manifests {
+		if m.Annotations[AnnotationRefName] != ref {
+			manifests = append(manifests, m)
+		}
+	}
+	idx.Manifests = manifests
+	return l.writeIndex(idx)
+}
+
+// Manifest returns the manifest named ref for DefaultPlatform. If ref is
+// empty, the layout must hold only one manifest for it.
+func (l *Layout) Manifest(ref string) (*Manifest, error) {
+	idx, err := l.Index()
+	if err != nil {
+		return nil, err
+	}
+	d, err := selectManifest(idx.Manifests, ref)
+	if err != nil {
+		return nil, err
+	}
+	// Indexes may nest; each level picks the platform.
+	for depth := 0; isIndex(d.MediaType); depth++ {
+		if depth > 8 {
+			return nil, fmt.Errorf("indexes nested too deeply at %s", d.Digest)
+		}
+		var sub Index
+		if err := l.readJSON(d, &sub); err != nil {
+			return nil, err
+		}
+		if d, err = selectManifest(sub.Manifests, ""); err != nil {
+			return nil, err
+		}
+	}
+	if !isManifest(d.MediaType) {
+		return nil, fmt.Errorf("%w: %q", ErrMediaType, d.MediaType)
+	}
+	var m Manifest
+	if err := l.readJSON(d, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Image returns the image configuration of m.
+func (l *Layout) Image(m *Manifest) (*Image, error) {
+	if m.Config.MediaType != MediaTypeConfig && m.Config.MediaType != MediaTypeDockerConfig {
+		return nil, fmt.Errorf("%w: %q", ErrMediaType, m.Config.MediaType)
+	}
+	var img Image
+	if err := l.readJSON(m.Config, &img); err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package oci reads OCI container images from image layouts, pulls them from
+// registries and runs them.
+//
+// It implements only what running a single image needs: manifests and image
+// configurations, layers unpacked with their whiteouts, and the distribution
+// API with anonymous or basic token authentication.
+package oci
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// Media types of the documents and blobs this package understands.
+const (
+	MediaTypeIndex          = "application/vnd.oci.image.index.v1+json"
+	MediaTypeManifest       = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig         = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer          = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeLayerGzip      = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeLayerZstd      = "application/vnd.oci.image.layer.v1.tar+zstd"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerConfig   = "application/vnd.docker.container.image.v1+json"
+	MediaTypeDockerLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// AnnotationRefName is the annotation naming a manifest in an image index.
+const AnnotationRefName = "org.opencontainers.image.ref.name"
+
+var (
+	// ErrDigest is returned when a blob does not match its descriptor.
+	ErrDigest = errors.New("blob does not match its digest")
+
+	// ErrNotFound is returned when no manifest matches a reference.
+	ErrNotFound = errors.New("no such image")
+
+	// ErrAmbiguous is returned when several manifests match a reference.
+	ErrAmbiguous = errors.New("more than one image matches")
+
+	// ErrMediaType is returned for documents and layers of unknown types.
+	ErrMediaType = errors.New("unsupported media type")
+)
+
+// Platform is the operating system and architecture an image runs on.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// DefaultPlatform is the platform manifests are selected for.
+var DefaultPlatform = Platform{Architecture: runtime.GOARCH, OS: runtime.GOOS}
+
+// Descriptor refers to a blob by its digest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Index lists manifests, for one platform each or under different names.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest is an image for one platform: its configuration and its layers,
+// lowest first.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Config is how an image's process is run.
+type Config struct {
+	User       string   `json:"User,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+	Entrypoint []string `json:"Entrypoint,omitempty"`
+	Cmd        []string `json:"Cmd,omitempty"`
+	WorkingDir string   `json:"WorkingDir,omitempty"`
+}
+
+// Image is an image configuration.
+type Image struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       Config `json:"config"`
+}
+
+// isIndex reports whether mediaType is that of an index.
+func isIndex(mediaType string) bool {
+	return mediaType == MediaTypeIndex || mediaType == MediaTypeDockerList
+}
+
+// isManifest reports whether mediaType is that of a manifest.
+func isManifest(mediaType string) bool {
+	return mediaType == MediaTypeManifest || mediaType == MediaTypeDockerManifest
+}
+
+// isLayer reports whether mediaType is that of a layer.
+func isLayer(mediaType string) bool {
+	switch mediaType {
+	case MediaTypeLayer, MediaTypeLayerGzip, MediaTypeLayerZstd, MediaTypeDockerLayer:
+		return true
+	}
+	return false
+}
+
+// matches reports whether the platform p, if any, is DefaultPlatform.
+func (p *Platform) matches() bool {
+	if p == nil {
+		return true
+	}
+	return p.OS == DefaultPlatform.OS && p.Architecture == DefaultPlatform.Architecture &&
+		(DefaultPlatform.Variant == "" || p.Variant == "" || p.Variant == DefaultPlatform.Variant)
+}
+
+// selectManifest returns the one descriptor of descs named ref, or any if
+// ref is empty, that is for DefaultPlatform.
+func selectManifest(descs []Descriptor, ref string) (Descriptor, error) {
+	var found []Descriptor
+	for _, d := range descs {
+		if ref != "" && d.Annotations[AnnotationRefName] != ref && d.Digest != ref {
+			continue
+		}
+		if d.Platform.matches() {
+			found = append(found, d)
+		}
+	}
+	switch len(found) {
+	case 0:
+		if ref == "" {
+			return Descriptor{}, fmt.Errorf("%w for %s/%s", ErrNotFound, DefaultPlatform.OS, DefaultPlatform.Architecture)
+		}
+		return Descriptor{}, fmt.Errorf("%w: %q", ErrNotFound, ref)
+	case 1:
+		return found[0], nil
+	}
+	return Descriptor{}, fmt.Errorf("%w %q", ErrAmbiguous, ref)
+}
+
+// newHash returns the hash of a digest, which is "ALGORITHM:HEX", and the
+// hex-encoded sum it names.
+func newHash(digest string) (hash.Hash, string, error) {
+	alg, sum, ok := strings.Cut(digest, ":")
+	var h hash.Hash
+	switch alg {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		ok = false
+	}
+	// The sum becomes a file name, so it must really be hex.
+	if b, err := hex.DecodeString(sum); !ok || err != nil || len(b) != h.Size() {
+		return nil, "", fmt.Errorf("%w: bad digest %q", ErrDigest, digest)
+	}
+	return h, sum, nil
+}
+
+// verifier reads a blob and checks it against its descriptor once it has
+// been read to the end.
+type verifier struct {
+	r    io.Reader
+	h    hash.Hash
+	sum  string
+	n    int64
+	desc Descriptor
+}
+
+func newVerifier(r io.Reader, d Descriptor) (*verifier, error) {
+	h, sum, err := newHash(d.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return &verifier{r: r, h: h, sum: sum, desc: d}, nil
+}
+
+// Read implements io.Reader.
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	v.n += int64(n)
+	if v.n > v.desc.Size {
+		return n, fmt.Errorf("%w: %s is larger than %d bytes", ErrDigest, v.desc.Digest, v.desc.Size)
+	}
+	if err == io.EOF {
+		if v.n != v.desc.Size {
+			return n, fmt.Errorf("%w: %s is %d bytes, want %d", ErrDigest, v.desc.Digest, v.n, v.desc.Size)
+		}
+		if hex.EncodeToString(v.h.Sum(nil)) != v.sum {
+			return n, fmt.Errorf("%w: %s", ErrDigest, v.desc.Digest)
+		}
+	}
+	return n, err
+}
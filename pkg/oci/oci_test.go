@@ -0,0 +1,322 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	name, body, link string
+	dir              bool
+}
+
+// layer returns a tar archive of entries, gzipped if zip is set.
+func layer(t *testing.T, zip bool, entries ...entry) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !zip {
+		return b.Bytes()
+	}
+	var z bytes.Buffer
+	zw := gzip.NewWriter(&z)
+	zw.Write(b.Bytes())
+	zw.Close()
+	return z.Bytes()
+}
+
+func descriptor(mediaType string, b []byte) Descriptor {
+	sum := sha256.Sum256(b)
+	return Descriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(b))}
+}
+
+// blobs are what an image is made of, by digest.
+type blobs map[string][]byte
+
+func (bs blobs) add(t *testing.T, mediaType string, v any) Descriptor {
+	t.Helper()
+	b, ok := v.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := descriptor(mediaType, b)
+	bs[d.Digest] = b
+	return d
+}
+
+// image adds an image of the given layers to bs and returns the descriptor
+// of its manifest.
+func (bs blobs) image(t *testing.T, cfg Config, layers ...[]byte) Descriptor {
+	t.Helper()
+	m := Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Config:        bs.add(t, MediaTypeConfig, Image{Architecture: DefaultPlatform.Architecture, OS: DefaultPlatform.OS, Config: cfg}),
+	}
+	for _, l := range layers {
+		m.Layers = append(m.Layers, bs.add(t, MediaTypeLayerGzip, l))
+	}
+	return bs.add(t, MediaTypeManifest, m)
+}
+
+func (bs blobs) layout(t *testing.T) *Layout {
+	t.Helper()
+	l, err := CreateLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for digest, b := range bs {
+		if err := l.Write(descriptor("", b), bytes.NewReader(b)); err != nil {
+			t.Fatalf("Write(%s) = %v", digest, err)
+		}
+	}
+	return l
+}
+
+func TestManifest(t *testing.T) {
+	bs := blobs{}
+	agent := bs.image(t, Config{Entrypoint: []string{"/agent"}}, layer(t, true, entry{name: "agent", body: "#!/bin/sh\n"}))
+	other := bs.image(t, Config{Cmd: []string{"/other"}})
+	p := DefaultPlatform
+	wrong := Platform{OS: "plan9", Architecture: "mips"}
+	agent.Platform, other.Platform = &p, &wrong
+	multi := bs.add(t, MediaTypeIndex, Index{SchemaVersion: 2, MediaType: MediaTypeIndex, Manifests: []Descriptor{other, agent}})
+	agent.Platform, other.Platform = nil, nil
+
+	l := bs.layout(t)
+	for _, tag := range []struct {
+		d   Descriptor
+		ref string
+	}{{multi, "multi"}, {agent, "agent"}, {other, "other"}} {
+		if err := l.Tag(tag.d, tag.ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		ref  string
+		args []string
+		err  error
+	}{
+		{ref: "agent", args: []string{"/agent"}},
+		{ref: "multi", args: []string{"/agent"}},
+		{ref: "other", args: []string{"/other"}},
+		{ref: agent.Digest, args: []string{"/agent"}},
+		{ref: "", err: ErrAmbiguous},
+		{ref: "nope", err: ErrNotFound},
+	} {
+		m, err := l.Manifest(tt.ref)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Manifest(%q) = %v, want %v", tt.ref, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		img, err := l.Image(m)
+		if err != nil {
+			t.Fatalf("Image(%q) = %v", tt.ref, err)
+		}
+		args := append(img.Config.Entrypoint, img.Config.Cmd...)
+		if len(args) != 1 || args[0] != tt.args[0] {
+			t.Errorf("Manifest(%q) runs %q, want %q", tt.ref, args, tt.args)
+		}
+	}
+
+	// A layout opened again sees the same index.
+	l2, err := OpenLayout(l.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx, err := l2.Index(); err != nil || len(idx.Manifests) != 3 {
+		t.Errorf("Index() = %v, %v, want 3 manifests", idx, err)
+	}
+	if _, err := OpenLayout(t.TempDir()); !errors.Is(err, ErrLayout) {
+		t.Errorf("OpenLayout(empty) = %v, want %v", err, ErrLayout)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	bs := blobs{}
+	d := bs.add(t, MediaTypeLayer, []byte("layer"))
+	l := bs.layout(t)
+
+	p, err := l.path(d.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte("LAYER"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := l.Open(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := bytes.NewBuffer(nil).ReadFrom(r); !errors.Is(err, ErrDigest) {
+		t.Errorf("reading a corrupt blob = %v, want %v", err, ErrDigest)
+	}
+
+	if err := l.Write(d, bytes.NewReader([]byte("other"))); !errors.Is(err, ErrDigest) {
+		t.Errorf("Write(wrong blob) = %v, want %v", err, ErrDigest)
+	}
+	for _, digest := range []string{"sha256:../../etc/passwd", "md5:d41d8cd98f00b204e9800998ecf8427e", "sha256"} {
+		if _, err := l.Open(Descriptor{Digest: digest}); !errors.Is(err, ErrDigest) {
+			t.Errorf("Open(%q) = %v, want %v", digest, err, ErrDigest)
+		}
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	bs := blobs{}
+	m := bs.image(t, Config{},
+		layer(t, true,
+			entry{name: "etc/", dir: true},
+			entry{name: "etc/motd", body: "hello"},
+			entry{name: "etc/gone", body: "x"},
+			entry{name: "var/", dir: true},
+			entry{name: "var/cache/", dir: true},
+			entry{name: "var/cache/old", body: "x"},
+			entry{name: "var/keep", body: "x"},
+			entry{name: "bin", dir: true},
+			entry{name: "bin/sh", body: "sh"},
+			entry{name: "lib", link: "usr/lib"},
+			entry{name: "file", body: "file"},
+		),
+		layer(t, false,
+			entry{name: "etc/motd", body: "bye"},
+			entry{name: "etc/.wh.gone"},
+			entry{name: "var/cache/new", body: "new"},
+			entry{name: "var/cache/.wh..wh..opq"},
+			entry{name: "file/", dir: true},
+			entry{name: "file/inside", body: "inside"},
+			entry{name: "lib/", dir: true},
+			entry{name: "lib/.wh.nothing"},
+		),
+	)
+	l := bs.layout(t)
+	if err := l.Tag(m, "test"); err != nil {
+		t.Fatal(err)
+	}
+	man, err := l.Manifest("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	if err := l.Unpack(man, root); err != nil {
+		t.Fatalf("Unpack() = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"etc/motd":      "bye",
+		"etc/gone":      "",
+		"var/cache/old": "",
+		"var/cache/new": "new",
+		"var/keep":      "x",
+		"bin/sh":        "sh",
+		"file/inside":   "inside",
+	} {
+		b, err := os.ReadFile(filepath.Join(root, name))
+		if want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s was not whited out: %v", name, err)
+			}
+			continue
+		}
+		if err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", name, b, err, want)
+		}
+	}
+	if fi, err := os.Lstat(filepath.Join(root, "lib")); err != nil || !fi.IsDir() {
+		t.Errorf("lib was not replaced by a directory: %v", err)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + hex.EncodeToString(make([]byte, 32))
+	for _, tt := range []struct {
+		in   string
+		want Reference
+		err  error
+	}{
+		{in: "alpine", want: Reference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}},
+		{in: "u-root/agent:v2", want: Reference{Registry: "docker.io", Repository: "u-root/agent", Tag: "v2"}},
+		{in: "ghcr.io/u-root/agent", want: Reference{Registry: "ghcr.io", Repository: "u-root/agent", Tag: "latest"}},
+		{in: "localhost:5000/agent:1.0", want: Reference{Registry: "localhost:5000", Repository: "agent", Tag: "1.0"}},
+		{in: "localhost/agent", want: Reference{Registry: "localhost", Repository: "agent", Tag: "latest"}},
+		{in: "quay.io/a/b@" + digest, want: Reference{Registry: "quay.io", Repository: "a/b", Digest: digest}},
+		{in: "Alpine", err: ErrReference},
+		{in: "alpine@sha256:abc", err: ErrReference},
+		{in: "ghcr.io/", err: ErrReference},
+	} {
+		got, err := ParseReference(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseReference(%q) = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && *got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tt.in, *got, tt.want)
+		}
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "etc"), 0o755)
+	os.WriteFile(filepath.Join(root, "etc/passwd"), []byte("root:x:0:0::/root:/bin/sh\nagent:x:100:101::/:/bin/false\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "etc/group"), []byte("root:x:0:\nagent:x:101:\nwheel:x:10:agent\n"), 0o644)
+
+	for _, tt := range []struct {
+		spec     string
+		uid, gid uint32
+		err      error
+	}{
+		{spec: "", uid: 0, gid: 0},
+		{spec: "agent", uid: 100, gid: 101},
+		{spec: "100", uid: 100, gid: 101},
+		{spec: "agent:wheel", uid: 100, gid: 10},
+		{spec: "agent:7", uid: 100, gid: 7},
+		{spec: "1000", uid: 1000, gid: 0},
+		{spec: "1000:1000", uid: 1000, gid: 1000},
+		{spec: "nobody", err: ErrUser},
+		{spec: "agent:nogroup", err: ErrUser},
+	} {
+		uid, gid, err := LookupUser(root, tt.spec)
+		if !errors.Is(err, tt.err) || uid != tt.uid || gid != tt.gid {
+			t.Errorf("LookupUser(%q) = %d, %d, %v, want %d, %d, %v", tt.spec, uid, gid, err, tt.uid, tt.gid, tt.err)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/curl"
+)
+
+const (
+	defaultRegistry = "docker.io"
+	// dockerHub is where docker.io is really served from.
+	dockerHub  = "registry-1.docker.io"
+	defaultTag = "latest"
+)
+
+var (
+	// ErrReference is returned for malformed image references.
+	ErrReference = errors.New("bad image reference")
+
+	// ErrRegistry is returned when a registry refuses a request.
+	ErrRegistry = errors.New("registry error")
+)
+
+// manifestTypes are the documents asked for when pulling a manifest.
+var manifestTypes = []string{MediaTypeIndex, MediaTypeManifest, MediaTypeDockerList, MediaTypeDockerManifest}
+
+// Reference names an image in a registry, as in
+// "registry.example.com:5000/agents/provision:v2" or "alpine@sha256:...".
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference the way docker does: the
+// registry defaults to docker.io, where single names are in "library/",
+// and the tag defaults to "latest".
+func ParseReference(s string) (*Reference, error) {
+	r := &Reference{Registry: defaultRegistry}
+	name, digest, ok := strings.Cut(s, "@")
+	if ok {
+		if _, _, err := newHash(digest); err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrReference, s, err)
+		}
+		r.Digest = digest
+	}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, name = first, rest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:i], name[i+1:]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+	if r.Registry == defaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" || strings.ToLower(name) != name || strings.Contains(name, "//") || strings.Contains(name, "..") {
+		return nil, fmt.Errorf("%w %q", ErrReference, s)
+	}
+	r.Repository = name
+	return r, nil
+}
+
+// String implements fmt.Stringer.
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// ref returns what a manifest is fetched by.
+func (r *Reference) ref() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// Registry pulls images from registries over the distribution API.
+type Registry struct {
+	// Client makes the requests. Its transport decides which
+	// certificates are trusted and which proxy is used.
+	Client *http.Client
+
+	// PlainHTTP talks to registries without TLS.
+	PlainHTTP bool
+
+	// Username and Password are sent to the token service, if any.
+	Username, Password string
+
+	tokens map[string]string
+}
+
+// NewRegistry returns a Registry which makes requests with c. If c is nil,
+// requests go through the proxies of curl.ProxyFromEnvironment.
+func NewRegistry(c *http.Client) *Registry {
+	if c == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = curl.ProxyFromEnvironment
+		c = &http.Client{Transport: t}
+	}
+	return &Registry{Client: c, tokens: map[string]string{}}
+}
+
+func (r *Registry) url(ref *Reference, p string) string {
+	u := url.URL{Scheme: "https", Host: ref.Registry, Path: "/v2/" + ref.Repository + p}
+	if r.PlainHTTP {
+		u.Scheme = "http"
+	}
+	if u.Host == defaultRegistry {
+		u.Host = dockerHub
+	}
+	return u.String()
+}
+
+// get fetches p from the repository of ref, getting a token first if the
+// registry asks for one.
+func (r *Registry) get(ctx context.Context, ref *Reference, p string, accept ...string) (*http.Response, error) {
+	for try := 0; ; try++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url(ref, p), nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if tok, ok := r.tokens[ref.Registry+"/"+ref.Repository]; ok {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if resp.StatusCode != http.StatusUnauthorized || try > 0 || challenge == "" {
+			return nil, fmt.Errorf("%w: %s: %s", ErrRegistry, req.URL, resp.Status)
+		}
+		if err := r.authorize(ctx, ref, challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authorize gets a token for the repository of ref from the token service
+// a Bearer challenge names.
+func (r *Registry) authorize(ctx context.Context, ref *Reference, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("%w: unsupported authentication %q", ErrRegistry, scheme)
+	}
+	p := parseChallenge(params)
+	u, err := url.Parse(p["realm"])
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: bad token realm %q", ErrRegistry, p["realm"])
+	}
+	q := u.Query()
+	if s := p["service"]; s != "" {
+		q.Set("service", s)
+	}
+	scope := p["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: token from %s: %s", ErrRegistry, u.Host, resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocument)).Decode(&tok); err != nil {
+		return fmt.Errorf("%w: token from %s: %w", ErrRegistry, u.Host, err)
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	if r.tokens == nil {
+		r.tokens = map[string]string{}
+	}
+	r.tokens[ref.Registry+"/"+ref.Repository] = tok.Token
+	return nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of a
+// WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	p := map[string]string{}
+	for s != "" {
+		var k, v string
+		k, s, _ = strings.Cut(strings.TrimLeft(s, " ,"), "=")
+		if strings.HasPrefix(s, `"`) {
+			v, s, _ = strings.Cut(s[1:], `"`)
+		} else {
+			v, s, _ = strings.Cut(s, ",")
+		}
+		p[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return p
+}
+
+// fetchManifest fetches the index or manifest ref refers to, and verifies
+// it if it is fetched by digest.
+func (r *Registry) fetchManifest(ctx context.Context, ref *Reference, which string) (Descriptor, []byte, error) {
+	resp, err := r.get(ctx, ref, "/manifests/"+which, manifestTypes...)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxDocument+1))
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	if len(b) > maxDocument {
+		return Descriptor{}, nil, fmt.Errorf("%w: manifest %s is too large", ErrRegistry, which)
+	}
+	sum := sha256.Sum256(b)
+	d := Descriptor{
+		MediaType: strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0]),
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(b)),
+	}
+	var doc struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return Descriptor{}, nil, fmt.Errorf("%w: manifest %s: %w", ErrRegistry, which, err)
+	}
+	if doc.MediaType != "" {
+		d.MediaType = doc.MediaType
+	}
+	if _, _, err := newHash(which); err == nil {
+		d.Digest = which
+		v, _ := newVerifier(bytes.NewReader(b), d)
+		if _, err := io.Copy(io.Discard, v); err != nil {
+			return Descriptor{}, nil, err
+		}
+	}
+	return d, b, nil
+}
+
+// fetchBlob adds the blob d refers to to l unless it is there already.
+func (r *Registry) fetchBlob(ctx context.Context, ref *Reference, d Descriptor, l *Layout) error {
+	if l.Has(d) {
+		return nil
+	}
+	resp, err := r.get(ctx, ref, "/blobs/"+d.Digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return l.Write(d, resp.Body)
+}
+
+// Pull adds the image ref refers to, for DefaultPlatform, to l under the
+// name ref.String(). It returns the descriptor of the image's manifest.
+func (r *Registry) Pull(ctx context.Context, ref *Reference, l *Layout) (Descriptor, error) {
+	d, b, err := r.fetchManifest(ctx, ref, ref.ref())
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if isIndex(d.MediaType) {
+		var idx Index
+		if err := json.Unmarshal(b, &idx); err != nil {
+			return Descriptor{}, fmt.Errorf("%w: index: %w", ErrRegistry, err)
+		}
+		sel, err := selectManifest(idx.Manifests, "")
+		if err != nil {
+			return Descriptor{}, err
+		}
+		if d, b, err = r.fetchManifest(ctx, ref, sel.Digest); err != nil {
+			return Descriptor{}, err
+		}
+	}
+	if !isManifest(d.MediaType) {
+		return Descriptor{}, fmt.Errorf("%w: %q", ErrMediaType, d.MediaType)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return Descriptor{}, fmt.Errorf("%w: manifest: %w", ErrRegistry, err)
+	}
+	for _, blob := range append([]Descriptor{m.Config}, m.Layers...) {
+		if err := r.fetchBlob(ctx, ref, blob, l); err != nil {
+			return Descriptor{}, err
+		}
+	}
+	if err := l.Write(d, bytes.NewReader(b)); err != nil {
+		return Descriptor{}, err
+	}
+	if err := l.Tag(d, ref.String()); err != nil {
+		return Descriptor{}, err
+	}
+	return d, nil
+}
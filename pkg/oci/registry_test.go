@@ -0,0 +1,132 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// registry serves the manifests and blobs of bs for one repository, to
+// those with a token.
+func registry(t *testing.T, bs blobs, tags map[string]Descriptor) *httptest.Server {
+	var s *httptest.Server
+	s = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:agents/provision:pull" {
+				http.Error(w, "bad scope", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"token": "sesame"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sesame" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+s.URL+`/token",service="test",scope="repository:agents/provision:pull"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		p, ok := strings.CutPrefix(r.URL.Path, "/v2/agents/provision/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		kind, ref, _ := strings.Cut(p, "/")
+		d, ok := tags[ref]
+		if !ok {
+			d.Digest = ref
+		}
+		b, ok := bs[d.Digest]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if kind == "manifests" {
+			w.Header().Set("Content-Type", d.MediaType)
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestPull(t *testing.T) {
+	bs := blobs{}
+	agent := bs.image(t, Config{Entrypoint: []string{"/agent"}}, layer(t, true, entry{name: "agent", body: "agent"}))
+	p := DefaultPlatform
+	agent.Platform = &p
+	other := bs.image(t, Config{}, layer(t, true, entry{name: "other", body: "other"}))
+	other.Platform = &Platform{OS: "plan9", Architecture: "mips"}
+	multi := bs.add(t, MediaTypeIndex, Index{SchemaVersion: 2, MediaType: MediaTypeIndex, Manifests: []Descriptor{other, agent}})
+	agent.Platform = nil
+
+	s := registry(t, bs, map[string]Descriptor{"v1": multi, "v2": agent})
+	host := strings.TrimPrefix(s.URL, "https://")
+	l, err := CreateLayout(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry(s.Client())
+
+	for _, tt := range []struct {
+		ref  string
+		want Descriptor
+		err  error
+	}{
+		{ref: host + "/agents/provision:v1", want: agent},
+		{ref: host + "/agents/provision:v2", want: agent},
+		{ref: host + "/agents/provision@" + agent.Digest, want: agent},
+		{ref: host + "/agents/provision:v3", err: ErrRegistry},
+		{ref: host + "/agents/other:v1", err: ErrRegistry},
+	} {
+		ref, err := ParseReference(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := r.Pull(context.Background(), ref, l)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Pull(%s) = %v, want %v", tt.ref, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if d.Digest != tt.want.Digest {
+			t.Errorf("Pull(%s) = %s, want %s", tt.ref, d.Digest, tt.want.Digest)
+		}
+		m, err := l.Manifest(ref.String())
+		if err != nil {
+			t.Fatalf("Manifest(%s) = %v", ref, err)
+		}
+		root := t.TempDir()
+		if err := l.Unpack(m, root); err != nil {
+			t.Fatalf("Unpack(%s) = %v", ref, err)
+		}
+		if b, err := os.ReadFile(filepath.Join(root, "agent")); err != nil || string(b) != "agent" {
+			t.Errorf("Pull(%s) unpacks agent = %q, %v", ref, b, err)
+		}
+	}
+	if l.Has(other) {
+		t.Errorf("Pull() fetched the image for the wrong platform")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`realm="https://auth.docker.io/token",service="registry.docker.io", scope="repository:library/alpine:pull,push"`)
+	for k, v := range map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/alpine:pull,push",
+	} {
+		if got[k] != v {
+			t.Errorf("parseChallenge()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
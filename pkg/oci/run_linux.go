@@ -0,0 +1,283 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/cgroup"
+	"golang.org/x/sys/unix"
+)
+
+// initEnv passes the container to the init started in its namespaces.
+const initEnv = "OCI_CONTAINER_INIT"
+
+// CgroupRoot is where the cgroup v2 hierarchy is mounted.
+var CgroupRoot = cgroup.DefaultRoot
+
+// Container is a process to run in a root file system of its own, in new
+// mount, PID, UTS and IPC namespaces.
+type Container struct {
+	// Rootfs is the directory holding the unpacked image.
+	Rootfs string
+
+	// Args is the command line of the process, and Env its environment.
+	Args []string
+	Env  []string
+
+	// Dir is the working directory of the process, in Rootfs.
+	Dir string
+
+	// UID and GID are who the process runs as.
+	UID, GID uint32
+
+	// Hostname is the name of the container's UTS namespace.
+	Hostname string
+
+	// NewNet gives the container a network namespace of its own, which
+	// has only a loopback interface. Otherwise it shares the host's.
+	NewNet bool
+
+	// Cgroup is the cgroup v2 the container runs in, as a path in the
+	// hierarchy at CgroupRoot. It is created if need be, and removed when
+	// the container exits.
+	Cgroup string
+
+	// Limits are the limits of Cgroup.
+	Limits *cgroup.Limits
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// spec is what the init of the container needs to know.
+type spec struct {
+	Rootfs   string
+	Args     []string
+	Env      []string
+	Dir      string
+	UID, GID uint32
+	Hostname string
+}
+
+// Run runs the container and waits for it to exit. It starts the running
+// program again as the container's init, so that program must call Init
+// first thing when IsInit reports it is one.
+func (c *Container) Run() error {
+	if len(c.Args) == 0 {
+		return fmt.Errorf("no command to run in the container")
+	}
+	rootfs, err := filepath.Abs(c.Rootfs)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(spec{
+		Rootfs:   rootfs,
+		Args:     c.Args,
+		Env:      c.Env,
+		Dir:      c.Dir,
+		UID:      c.UID,
+		GID:      c.GID,
+		Hostname: c.Hostname,
+	})
+	if err != nil {
+		return err
+	}
+	cmd := &exec.Cmd{
+		Path:   "/proc/self/exe",
+		Args:   []string{"oci-init"},
+		Env:    []string{initEnv + "=" + string(b)},
+		Stdin:  c.Stdin,
+		Stdout: c.Stdout,
+		Stderr: c.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC,
+			Pdeathsig:  syscall.SIGKILL,
+		},
+	}
+	if c.NewNet {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	if c.Cgroup != "" {
+		cg, err := c.cgroup()
+		if err != nil {
+			return err
+		}
+		// The cgroup is empty again once the PID namespace's init
+		// has exited.
+		defer cg.Delete(false)
+		f, err := os.Open(cg.Path())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// Starting the container in its cgroup, rather than moving it
+		// there, leaves no time to escape the limits.
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(f.Fd())
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWCGROUP
+	}
+	return cmd.Run()
+}
+
+// cgroup creates the container's cgroup and sets its limits.
+func (c *Container) cgroup() (*cgroup.Cgroup, error) {
+	if err := cgroup.Check(CgroupRoot); err != nil {
+		return nil, err
+	}
+	cg, err := cgroup.Create(CgroupRoot, c.Cgroup)
+	if err != nil {
+		return nil, err
+	}
+	if c.Limits != nil {
+		if err := cg.SetLimits(c.Limits); err != nil {
+			cg.Delete(false)
+			return nil, err
+		}
+	}
+	return cg, nil
+}
+
+// IsInit reports whether this process is the init of a container that Run
+// started.
+func IsInit() bool {
+	_, ok := os.LookupEnv(initEnv)
+	return ok
+}
+
+// Init sets up the mounts of the container whose init this process is,
+// makes its root file system the root and executes its process. It only
+// returns if that fails.
+func Init() error {
+	var s spec
+	if err := json.Unmarshal([]byte(os.Getenv(initEnv)), &s); err != nil {
+		return fmt.Errorf("container init: %w", err)
+	}
+	os.Unsetenv(initEnv)
+	if err := pivot(s.Rootfs); err != nil {
+		return err
+	}
+	if s.Hostname != "" {
+		if err := unix.Sethostname([]byte(s.Hostname)); err != nil {
+			return fmt.Errorf("setting hostname: %w", err)
+		}
+	}
+	if err := syscall.Setgroups(nil); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(int(s.GID)); err != nil {
+		return fmt.Errorf("setgid %d: %w", s.GID, err)
+	}
+	if err := syscall.Setuid(int(s.UID)); err != nil {
+		return fmt.Errorf("setuid %d: %w", s.UID, err)
+	}
+	if s.Dir == "" {
+		s.Dir = "/"
+	}
+	if err := os.Chdir(s.Dir); err != nil {
+		return err
+	}
+	// The command is looked up in the container's PATH.
+	os.Clearenv()
+	for _, e := range s.Env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			os.Setenv(k, v)
+		}
+	}
+	path, err := exec.LookPath(s.Args[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, s.Args, s.Env)
+}
+
+type mountPoint struct {
+	source, target, fstype string
+	flags                  uintptr
+	data                   string
+}
+
+var mounts = []mountPoint{
+	{"proc", "/proc", "proc", unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC, ""},
+	{"sysfs", "/sys", "sysfs", unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC, ""},
+	{"tmpfs", "/dev", "tmpfs", unix.MS_NOSUID | unix.MS_STRICTATIME, "mode=755,size=65536k"},
+	{"devpts", "/dev/pts", "devpts", unix.MS_NOSUID | unix.MS_NOEXEC, "newinstance,ptmxmode=0666,mode=0620"},
+	{"shm", "/dev/shm", "tmpfs", unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC, "mode=1777,size=65536k"},
+}
+
+// devices are bound from the host's /dev.
+var devices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+var devLinks = map[string]string{
+	"ptmx":   "pts/ptmx",
+	"fd":     "/proc/self/fd",
+	"stdin":  "/proc/self/fd/0",
+	"stdout": "/proc/self/fd/1",
+	"stderr": "/proc/self/fd/2",
+}
+
+// mkdir creates the directory name in root, unless a symbolic link is in
+// the way.
+func mkdir(root, name string) (string, error) {
+	p := filepath.Join(root, name)
+	if fi, err := os.Lstat(p); err == nil && !fi.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", name)
+	}
+	return p, os.MkdirAll(p, 0o755)
+}
+
+// pivot mounts what a container needs in root and makes it the root.
+func pivot(root string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	// pivot_root needs the new root to be a mount point.
+	if err := unix.Mount(root, root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("binding %s: %w", root, err)
+	}
+	for _, m := range mounts {
+		p, err := mkdir(root, m.target)
+		if err != nil {
+			return err
+		}
+		if err := unix.Mount(m.source, p, m.fstype, m.flags, m.data); err != nil {
+			return fmt.Errorf("mounting %s: %w", m.target, err)
+		}
+	}
+	for _, d := range devices {
+		p := filepath.Join(root, "dev", d)
+		if err := os.WriteFile(p, nil, 0o666); err != nil {
+			return err
+		}
+		if err := unix.Mount("/dev/"+d, p, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("binding /dev/%s: %w", d, err)
+		}
+	}
+	for name, target := range devLinks {
+		if err := os.Symlink(target, filepath.Join(root, "dev", name)); err != nil {
+			return err
+		}
+	}
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	// Pivoting onto the same directory stacks the old root under the
+	// new one, from where it can be detached.
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("detaching old root: %w", err)
+	}
+	return os.Chdir("/")
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/tarutil"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Unpack applies the layers of m, lowest first, to the directory rootfs.
+// If it fails, rootfs must be thrown away: a layer which does not match its
+// digest is only found out once it has been applied.
+func (l *Layout) Unpack(m *Manifest, rootfs string) error {
+	for _, d := range m.Layers {
+		if !isLayer(d.MediaType) {
+			return fmt.Errorf("%w: layer %s is %q", ErrMediaType, d.Digest, d.MediaType)
+		}
+		r, err := l.Open(d)
+		if err != nil {
+			return err
+		}
+		err = ApplyLayer(r, rootfs)
+		if err == nil {
+			// The end of the archive may come before the end of
+			// the blob.
+			_, err = io.Copy(io.Discard, r)
+		}
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", d.Digest, err)
+		}
+	}
+	return nil
+}
+
+// ApplyLayer extracts the layer read from r, compressed or not, on top of
+// the directory rootfs. The layer's whiteouts remove what lower layers put
+// in rootfs.
+func ApplyLayer(r io.Reader, rootfs string) error {
+	a := &applier{root: rootfs, added: map[string]bool{}}
+	opts := &tarutil.Opts{
+		NumericOwner: true,
+		// Restoring file capabilities needs root.
+		Xattrs:  os.Geteuid() == 0,
+		Filters: []tarutil.Filter{a.filter},
+	}
+	if err := tarutil.ExtractDir(r, rootfs, opts); err != nil {
+		return err
+	}
+	return a.err
+}
+
+// applier carries out whiteouts and replacements for tarutil.ExtractDir.
+type applier struct {
+	root string
+	// added holds the names the layer put in place, which its own
+	// whiteouts leave alone.
+	added map[string]bool
+	err   error
+}
+
+func (a *applier) filter(hdr *tar.Header) bool {
+	if a.err != nil {
+		return false
+	}
+	name := path.Clean("/" + hdr.Name)
+	dir, base := path.Split(name)
+	switch {
+	case base == whiteoutOpaque:
+		a.err = a.opaque(dir)
+		return false
+	case strings.HasPrefix(base, whiteoutPrefix):
+		name = path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+		if !a.added[name] {
+			a.err = a.remove(name)
+		}
+		return false
+	case name == "/":
+		// rootfs is there already, and its owner and mode stay.
+		return false
+	case hdr.Typeflag == tar.TypeChar || hdr.Typeflag == tar.TypeBlock || hdr.Typeflag == tar.TypeFifo:
+		// A container gets its own /dev.
+		return false
+	}
+	a.added[name] = true
+	a.err = a.replace(name, hdr)
+	return a.err == nil
+}
+
+// path returns where name is in the root file system, or false if a
+// symbolic link could lead it elsewhere.
+func (a *applier) path(name string) (string, bool) {
+	p := a.root
+	elems := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for _, e := range elems[:len(elems)-1] {
+		p = filepath.Join(p, e)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			break
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			log.Printf("Warning: Skipping %q, %q is a symbolic link", name, p)
+			return "", false
+		}
+	}
+	return filepath.Join(a.root, name), true
+}
+
+// remove removes name, which a lower layer added.
+func (a *applier) remove(name string) error {
+	p, ok := a.path(name)
+	if !ok || name == "/" {
+		return nil
+	}
+	return os.RemoveAll(p)
+}
+
+// opaque removes what lower layers added to dir.
+func (a *applier) opaque(dir string) error {
+	dir = path.Clean(dir)
+	p, ok := a.path(path.Join(dir, "x"))
+	if !ok {
+		return nil
+	}
+	ents, err := os.ReadDir(filepath.Dir(p))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range ents {
+		if name := path.Join(dir, e.Name()); !a.added[name] {
+			if err := a.remove(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replace removes what a lower layer has at name if it is a directory and
+// hdr is not, or the other way round.
+func (a *applier) replace(name string, hdr *tar.Header) error {
+	p, ok := a.path(name)
+	if !ok || name == "/" {
+		return nil
+	}
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return nil
+	}
+	if fi.IsDir() != (hdr.Typeflag == tar.TypeDir) {
+		return os.RemoveAll(p)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUser is returned for users and groups an image does not have.
+var ErrUser = errors.New("unknown user or group")
+
+// lookup returns the ID and the fourth field of the entry for name, or
+// for the ID name, in the passwd or group file db.
+func lookup(db, name string) (id uint32, field3 string, err error) {
+	f, err := os.Open(db)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) < 4 || (fields[0] != name && fields[2] != name) {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		return uint32(n), fields[3], nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, "", err
+	}
+	return 0, "", fmt.Errorf("%w %q", ErrUser, name)
+}
+
+// LookupUser returns the IDs of the user and group spec names, as
+// "USER[:GROUP]" with names from the passwd and group files of rootfs or
+// with numeric IDs. The group defaults to the user's primary group, or to
+// 0 for a numeric user the image does not know. An empty spec is root.
+func LookupUser(rootfs, spec string) (uid, gid uint32, err error) {
+	u, g, hasGroup := strings.Cut(spec, ":")
+	if u == "" {
+		u = "0"
+	}
+	uid, primary, err := lookup(filepath.Join(rootfs, "etc/passwd"), u)
+	if err != nil {
+		n, perr := strconv.ParseUint(u, 10, 32)
+		if perr != nil {
+			return 0, 0, fmt.Errorf("%w %q", ErrUser, u)
+		}
+		uid, primary = uint32(n), "0"
+	}
+	if !hasGroup {
+		g = primary
+	}
+	if n, err := strconv.ParseUint(g, 10, 32); err == nil {
+		return uid, uint32(n), nil
+	}
+	gid, _, err = lookup(filepath.Join(rootfs, "etc/group"), g)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w %q", ErrUser, g)
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,448 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoCapability is returned when decoding a capability that is not in
+// config space, or was not read.
+var ErrNoCapability = errors.New("capability not present")
+
+// Capability list registers.
+const (
+	// StatusCapList is set in the status register if the device has a
+	// capability list.
+	StatusCapList = 0x10
+	// CapPointer is the offset of the first capability.
+	CapPointer = 0x34
+	// ExtCapStart is the offset of the first PCIe extended capability.
+	ExtCapStart = 0x100
+)
+
+// Capability IDs. Extended capabilities have their own ID space.
+const (
+	CapPM      = 0x01
+	CapAGP     = 0x02
+	CapVPD     = 0x03
+	CapMSI     = 0x05
+	CapPCIX    = 0x07
+	CapHT      = 0x08
+	CapVendor  = 0x09
+	CapDebug   = 0x0a
+	CapSSVID   = 0x0d
+	CapExpress = 0x10
+	CapMSIX    = 0x11
+	CapSATA    = 0x12
+	CapAF      = 0x13
+
+	ExtCapAER    = 0x01
+	ExtCapVC     = 0x02
+	ExtCapDSN    = 0x03
+	ExtCapPB     = 0x04
+	ExtCapVendor = 0x0b
+	ExtCapACS    = 0x0d
+	ExtCapARI    = 0x0e
+	ExtCapATS    = 0x0f
+	ExtCapSRIOV  = 0x10
+	ExtCapPRI    = 0x13
+	ExtCapRebar  = 0x15
+	ExtCapTPH    = 0x17
+	ExtCapLTR    = 0x18
+	ExtCapSecPCI = 0x19
+	ExtCapPASID  = 0x1b
+	ExtCapL1SS   = 0x1e
+	ExtCapDLF    = 0x25
+	ExtCapPL16   = 0x26
+)
+
+var capNames = map[uint16]string{
+	CapPM:      "Power Management",
+	CapAGP:     "AGP",
+	CapVPD:     "Vital Product Data",
+	CapMSI:     "MSI",
+	CapPCIX:    "PCI-X",
+	CapHT:      "HyperTransport",
+	CapVendor:  "Vendor Specific Information",
+	CapDebug:   "Debug port",
+	CapSSVID:   "Subsystem",
+	CapExpress: "Express",
+	CapMSIX:    "MSI-X",
+	CapSATA:    "SATA HBA",
+	CapAF:      "PCI Advanced Features",
+}
+
+var extCapNames = map[uint16]string{
+	ExtCapAER:    "Advanced Error Reporting",
+	ExtCapVC:     "Virtual Channel",
+	ExtCapDSN:    "Device Serial Number",
+	ExtCapPB:     "Power Budgeting",
+	ExtCapVendor: "Vendor Specific Information",
+	ExtCapACS:    "Access Control Services",
+	ExtCapARI:    "Alternative Routing-ID Interpretation (ARI)",
+	ExtCapATS:    "Address Translation Service (ATS)",
+	ExtCapSRIOV:  "Single Root I/O Virtualization (SR-IOV)",
+	ExtCapPRI:    "Page Request Interface (PRI)",
+	ExtCapRebar:  "Physical Resizable BAR",
+	ExtCapTPH:    "Transaction Processing Hints",
+	ExtCapLTR:    "Latency Tolerance Reporting",
+	ExtCapSecPCI: "Secondary PCI Express",
+	ExtCapPASID:  "Process Address Space ID (PASID)",
+	ExtCapL1SS:   "L1 PM Substates",
+	ExtCapDLF:    "Data Link Feature",
+	ExtCapPL16:   "Physical Layer 16.0 GT/s",
+}
+
+// Capability is an entry of the capability list, or of the PCIe extended
+// capability list, in config space.
+type Capability struct {
+	ID       uint16
+	Offset   int
+	Extended bool
+	// Version is the version of an extended capability.
+	Version uint8
+}
+
+// Name returns the name of the capability, as lspci shows it.
+func (c Capability) Name() string {
+	names := capNames
+	if c.Extended {
+		names = extCapNames
+	}
+	if n, ok := names[c.ID]; ok {
+		return n
+	}
+	return fmt.Sprintf("Capability ID %#02x", c.ID)
+}
+
+// String implements fmt.Stringer.
+func (c Capability) String() string {
+	if c.Extended {
+		return fmt.Sprintf("[%03x v%d] %s", c.Offset, c.Version, c.Name())
+	}
+	return fmt.Sprintf("[%02x] %s", c.Offset, c.Name())
+}
+
+// Capabilities walks the capability lists of config, as far as it was read.
+// Standard capabilities need the first 256 bytes and extended capabilities
+// all 4096. Lists that loop or point out of config space are cut short.
+func Capabilities(config []byte) []Capability {
+	var caps []Capability
+	// Functions that are not present read all ones.
+	if len(config) < ConfigSize || binary.LittleEndian.Uint16(config[VID:]) == 0xffff {
+		return nil
+	}
+	if binary.LittleEndian.Uint16(config[6:8])&StatusCapList != 0 {
+		ptr := int(config[CapPointer] &^ 3)
+		// Each capability takes at least 4 bytes, which bounds the list.
+		for i := 0; ptr >= StdConfigSize && ptr+2 <= ConfigSize && i < (ConfigSize-StdConfigSize)/4; i++ {
+			caps = append(caps, Capability{ID: uint16(config[ptr]), Offset: ptr})
+			ptr = int(config[ptr+1] &^ 3)
+		}
+	}
+
+	if len(config) < FullConfigSize {
+		return caps
+	}
+	ptr := ExtCapStart
+	for i := 0; ptr >= ExtCapStart && ptr+4 <= FullConfigSize && i < (FullConfigSize-ExtCapStart)/4; i++ {
+		h := binary.LittleEndian.Uint32(config[ptr:])
+		if h == 0 || h == 0xffffffff {
+			break
+		}
+		caps = append(caps, Capability{
+			ID:       uint16(h),
+			Offset:   ptr,
+			Extended: true,
+			Version:  uint8(h>>16) & 0xf,
+		})
+		ptr = int(h>>20) &^ 3
+	}
+	return caps
+}
+
+// Capabilities returns the capabilities in the config space read by
+// ReadConfig.
+func (p *PCI) Capabilities() []Capability {
+	return Capabilities(p.Config)
+}
+
+// FindCapability returns the first capability id, extended or not, in the
+// config space read by ReadConfig.
+func (p *PCI) FindCapability(id uint16, extended bool) (Capability, bool) {
+	for _, c := range p.Capabilities() {
+		if c.ID == id && c.Extended == extended {
+			return c, true
+		}
+	}
+	return Capability{}, false
+}
+
+// capRegs returns the size bytes of the capability c in config, or
+// ErrNoCapability if they were not read.
+func capRegs(config []byte, c Capability, id uint16, extended bool, size int) ([]byte, error) {
+	if c.ID != id || c.Extended != extended || c.Offset+size > len(config) {
+		return nil, fmt.Errorf("%w: %v", ErrNoCapability, c)
+	}
+	return config[c.Offset : c.Offset+size], nil
+}
+
+func flag(name string, set bool) string {
+	if set {
+		return name + "+"
+	}
+	return name + "-"
+}
+
+// flags formats the named bits of v, lspci style.
+func flags(v uint32, names map[uint]string, order []uint) string {
+	s := make([]string, 0, len(order))
+	for _, b := range order {
+		s = append(s, flag(names[b], v&(1<<b) != 0))
+	}
+	return strings.Join(s, " ")
+}
+
+// MSIX is the decoded MSI-X capability.
+type MSIX struct {
+	Enabled bool
+	// Masked masks all vectors of the function.
+	Masked    bool
+	TableSize int
+
+	// The vector table and pending bit array are at an offset in a BAR.
+	TableBAR    int
+	TableOffset uint32
+	PBABAR      int
+	PBAOffset   uint32
+}
+
+// ParseMSIX decodes the MSI-X capability c of config.
+func ParseMSIX(config []byte, c Capability) (*MSIX, error) {
+	r, err := capRegs(config, c, CapMSIX, false, 12)
+	if err != nil {
+		return nil, err
+	}
+	ctl := binary.LittleEndian.Uint16(r[2:])
+	table := binary.LittleEndian.Uint32(r[4:])
+	pba := binary.LittleEndian.Uint32(r[8:])
+	return &MSIX{
+		Enabled:     ctl&0x8000 != 0,
+		Masked:      ctl&0x4000 != 0,
+		TableSize:   int(ctl&0x7ff) + 1,
+		TableBAR:    int(table & 7),
+		TableOffset: table &^ 7,
+		PBABAR:      int(pba & 7),
+		PBAOffset:   pba &^ 7,
+	}, nil
+}
+
+// String implements fmt.Stringer.
+func (m *MSIX) String() string {
+	return fmt.Sprintf("%s Count=%d %s\nVector table: BAR=%d offset=%08x\nPBA: BAR=%d offset=%08x",
+		flag("Enable", m.Enabled), m.TableSize, flag("Masked", m.Masked), m.TableBAR, m.TableOffset, m.PBABAR, m.PBAOffset)
+}
+
+// AER registers of the Advanced Error Reporting capability.
+type AER struct {
+	UncorrectableStatus   uint32
+	UncorrectableMask     uint32
+	UncorrectableSeverity uint32
+	CorrectableStatus     uint32
+	CorrectableMask       uint32
+	CapControl            uint32
+}
+
+var (
+	aerUncorrectable = map[uint]string{
+		4: "DLP", 5: "SDES", 12: "TLP", 13: "FCP", 14: "CmpltTO", 15: "CmpltAbrt",
+		16: "UnxCmplt", 17: "RxOF", 18: "MalfTLP", 19: "ECRC", 20: "UnsupReq", 21: "ACSViol",
+	}
+	aerUncorrectableOrder = []uint{4, 5, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}
+	aerCorrectable        = map[uint]string{
+		0: "RxErr", 6: "BadTLP", 7: "BadDLLP", 8: "Rollover", 12: "Timeout", 13: "AdvNonFatalErr",
+	}
+	aerCorrectableOrder = []uint{0, 6, 7, 8, 12, 13}
+)
+
+// ParseAER decodes the extended AER capability c of config.
+func ParseAER(config []byte, c Capability) (*AER, error) {
+	r, err := capRegs(config, c, ExtCapAER, true, 0x1c)
+	if err != nil {
+		return nil, err
+	}
+	return &AER{
+		UncorrectableStatus:   binary.LittleEndian.Uint32(r[0x4:]),
+		UncorrectableMask:     binary.LittleEndian.Uint32(r[0x8:]),
+		UncorrectableSeverity: binary.LittleEndian.Uint32(r[0xc:]),
+		CorrectableStatus:     binary.LittleEndian.Uint32(r[0x10:]),
+		CorrectableMask:       binary.LittleEndian.Uint32(r[0x14:]),
+		CapControl:            binary.LittleEndian.Uint32(r[0x18:]),
+	}, nil
+}
+
+// String implements fmt.Stringer.
+func (a *AER) String() string {
+	return strings.Join([]string{
+		"UESta:\t" + flags(a.UncorrectableStatus, aerUncorrectable, aerUncorrectableOrder),
+		"UEMsk:\t" + flags(a.UncorrectableMask, aerUncorrectable, aerUncorrectableOrder),
+		"UESvrt:\t" + flags(a.UncorrectableSeverity, aerUncorrectable, aerUncorrectableOrder),
+		"CESta:\t" + flags(a.CorrectableStatus, aerCorrectable, aerCorrectableOrder),
+		"CEMsk:\t" + flags(a.CorrectableMask, aerCorrectable, aerCorrectableOrder),
+		fmt.Sprintf("AERCap:\tFirst Error Pointer: %02x, %s %s %s %s",
+			a.CapControl&0x1f,
+			flag("ECRCGenCap", a.CapControl&0x20 != 0), flag("ECRCGenEn", a.CapControl&0x40 != 0),
+			flag("ECRCChkCap", a.CapControl&0x80 != 0), flag("ECRCChkEn", a.CapControl&0x100 != 0)),
+	}, "\n")
+}
+
+// SRIOV is the decoded Single Root I/O Virtualization capability.
+type SRIOV struct {
+	// VFEnable and VFMemory are the VF Enable and VF MSE control bits.
+	VFEnable bool
+	VFMemory bool
+
+	InitialVFs uint16
+	TotalVFs   uint16
+	NumVFs     uint16
+	// FuncDepLink is the function dependency link.
+	FuncDepLink uint8
+
+	// The routing ID of VF n, counting from 0, is the ID of the PF plus
+	// VFOffset plus n times VFStride.
+	VFOffset   uint16
+	VFStride   uint16
+	VFDeviceID uint16
+}
+
+// SR-IOV register offsets in the capability.
+const (
+	SRIOVControl  = 0x08
+	SRIOVNumVFs   = 0x10
+	SRIOVVFOffset = 0x14
+	SRIOVVFStride = 0x16
+)
+
+// ParseSRIOV decodes the extended SR-IOV capability c of config.
+func ParseSRIOV(config []byte, c Capability) (*SRIOV, error) {
+	r, err := capRegs(config, c, ExtCapSRIOV, true, 0x1c)
+	if err != nil {
+		return nil, err
+	}
+	ctl := binary.LittleEndian.Uint16(r[SRIOVControl:])
+	return &SRIOV{
+		VFEnable:    ctl&1 != 0,
+		VFMemory:    ctl&8 != 0,
+		InitialVFs:  binary.LittleEndian.Uint16(r[0x0c:]),
+		TotalVFs:    binary.LittleEndian.Uint16(r[0x0e:]),
+		NumVFs:      binary.LittleEndian.Uint16(r[SRIOVNumVFs:]),
+		FuncDepLink: r[0x12],
+		VFOffset:    binary.LittleEndian.Uint16(r[SRIOVVFOffset:]),
+		VFStride:    binary.LittleEndian.Uint16(r[SRIOVVFStride:]),
+		VFDeviceID:  binary.LittleEndian.Uint16(r[0x1a:]),
+	}, nil
+}
+
+// String implements fmt.Stringer.
+func (s *SRIOV) String() string {
+	return fmt.Sprintf("IOVCtl:\t%s %s\nInitial VFs: %d, Total VFs: %d, Number of VFs: %d, Function Dependency Link: %02x\nVF offset: %d, stride: %d, Device ID: %04x",
+		flag("Enable", s.VFEnable), flag("MSE", s.VFMemory),
+		s.InitialVFs, s.TotalVFs, s.NumVFs, s.FuncDepLink, s.VFOffset, s.VFStride, s.VFDeviceID)
+}
+
+// Decode returns the decoded capability c of config, for the capabilities
+// that can be decoded, or nil.
+func Decode(config []byte, c Capability) fmt.Stringer {
+	var s fmt.Stringer
+	var err error
+	switch {
+	case c.ID == CapMSIX && !c.Extended:
+		s, err = ParseMSIX(config, c)
+	case c.ID == ExtCapAER && c.Extended:
+		s, err = ParseAER(config, c)
+	case c.ID == ExtCapSRIOV && c.Extended:
+		s, err = ParseSRIOV(config, c)
+	}
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// Capability names used in register names, as setpci knows them.
+var (
+	capShortNames = map[string]uint16{
+		"PM": CapPM, "AGP": CapAGP, "VPD": CapVPD, "MSI": CapMSI, "PCIX": CapPCIX,
+		"HT": CapHT, "VNDR": CapVendor, "DBG": CapDebug, "SSVID": CapSSVID,
+		"EXP": CapExpress, "MSIX": CapMSIX, "SATA": CapSATA, "AF": CapAF,
+	}
+	extCapShortNames = map[string]uint16{
+		"AER": ExtCapAER, "VC": ExtCapVC, "DSN": ExtCapDSN, "PB": ExtCapPB,
+		"VNDR": ExtCapVendor, "ACS": ExtCapACS, "ARI": ExtCapARI, "ATS": ExtCapATS,
+		"SRIOV": ExtCapSRIOV, "PRI": ExtCapPRI, "REBAR": ExtCapRebar, "TPH": ExtCapTPH,
+		"LTR": ExtCapLTR, "SECPCI": ExtCapSecPCI, "PASID": ExtCapPASID,
+		"L1SS": ExtCapL1SS, "DLF": ExtCapDLF, "PL16": ExtCapPL16,
+	}
+)
+
+// Register is a config space register, at an offset in config space or in
+// a capability.
+type Register struct {
+	// InCap says whether Offset is relative to the capability CapID.
+	InCap    bool
+	CapID    uint16
+	Extended bool
+	Offset   int64
+}
+
+// ParseRegister parses a register name: an offset, e.g. 0x10, or a
+// capability and an offset into it, e.g. CAP_MSIX+2 or ECAP_AER+0x4.
+func ParseRegister(s string) (Register, error) {
+	var r Register
+	name, off, hasOff := strings.Cut(s, "+")
+	switch {
+	case strings.HasPrefix(name, "CAP_"):
+		id, ok := capShortNames[strings.TrimPrefix(name, "CAP_")]
+		if !ok {
+			return r, fmt.Errorf("capability %q: %w", name, ErrNoCapability)
+		}
+		r.InCap, r.CapID = true, id
+	case strings.HasPrefix(name, "ECAP_"):
+		id, ok := extCapShortNames[strings.TrimPrefix(name, "ECAP_")]
+		if !ok {
+			return r, fmt.Errorf("capability %q: %w", name, ErrNoCapability)
+		}
+		r.InCap, r.CapID, r.Extended = true, id, true
+	default:
+		off, hasOff = s, true
+	}
+	if !hasOff {
+		return r, nil
+	}
+	o, err := strconv.ParseUint(off, 0, 12)
+	if err != nil {
+		return r, err
+	}
+	r.Offset = int64(o)
+	return r, nil
+}
+
+// RegisterOffset returns the offset of r in config space. Registers in
+// capabilities need the config space read by ReadConfig.
+func (p *PCI) RegisterOffset(r Register) (int64, error) {
+	if !r.InCap {
+		return r.Offset, nil
+	}
+	c, ok := p.FindCapability(r.CapID, r.Extended)
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", Capability{ID: r.CapID, Extended: r.Extended}.Name(), ErrNoCapability)
+	}
+	return int64(c.Offset) + r.Offset, nil
+}
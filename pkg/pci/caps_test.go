@@ -0,0 +1,175 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// testConfig returns PCIe config space with MSI-X and Express capabilities,
+// and AER and SR-IOV extended capabilities.
+func testConfig() []byte {
+	c := make([]byte, FullConfigSize)
+	le := binary.LittleEndian
+	le.PutUint16(c[VID:], 0x8086)
+	le.PutUint16(c[6:], StatusCapList)
+	c[CapPointer] = 0x50
+
+	// MSI-X, enabled, 64 vectors, table in BAR 0 at 0x2000, PBA at 0x3000.
+	c[0x50], c[0x51] = CapMSIX, 0x70
+	le.PutUint16(c[0x52:], 0x8000|63)
+	le.PutUint32(c[0x54:], 0x2000)
+	le.PutUint32(c[0x58:], 0x3000)
+	c[0x70], c[0x71] = CapExpress, 0
+
+	// AER v2 with a receiver error, then SR-IOV v1 with 8 VFs.
+	le.PutUint32(c[0x100:], 0x140<<20|2<<16|ExtCapAER)
+	le.PutUint32(c[0x104:], 1<<20)
+	le.PutUint32(c[0x110:], 1)
+	le.PutUint32(c[0x140:], 1<<16|ExtCapSRIOV)
+	le.PutUint16(c[0x148:], 9)
+	le.PutUint16(c[0x14c:], 8)
+	le.PutUint16(c[0x14e:], 8)
+	le.PutUint16(c[0x150:], 4)
+	le.PutUint16(c[0x154:], 0x80)
+	le.PutUint16(c[0x156:], 2)
+	le.PutUint16(c[0x15a:], 0x1520)
+	return c
+}
+
+func TestCapabilities(t *testing.T) {
+	loop := testConfig()
+	loop[0x71] = 0x50
+
+	for _, tt := range []struct {
+		name   string
+		config []byte
+		want   []Capability
+	}{
+		{
+			name:   "PCIe",
+			config: testConfig(),
+			want: []Capability{
+				{ID: CapMSIX, Offset: 0x50},
+				{ID: CapExpress, Offset: 0x70},
+				{ID: ExtCapAER, Offset: 0x100, Extended: true, Version: 2},
+				{ID: ExtCapSRIOV, Offset: 0x140, Extended: true, Version: 1},
+			},
+		},
+		{
+			name:   "PCI",
+			config: testConfig()[:ConfigSize],
+			want: []Capability{
+				{ID: CapMSIX, Offset: 0x50},
+				{ID: CapExpress, Offset: 0x70},
+			},
+		},
+		{
+			name:   "not read",
+			config: testConfig()[:StdConfigSize],
+		},
+		{
+			name:   "not present",
+			config: append([]byte{0xff, 0xff}, testConfig()[2:]...),
+		},
+		{
+			name:   "loop",
+			config: loop[:ConfigSize],
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Capabilities(tt.config)
+			if tt.name == "loop" {
+				// Cut short, and not forever.
+				if len(got) != (ConfigSize-StdConfigSize)/4 {
+					t.Errorf("Capabilities returned %d capabilities, want %d", len(got), (ConfigSize-StdConfigSize)/4)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Capabilities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	config := testConfig()
+	for _, tt := range []struct {
+		c    Capability
+		want string
+	}{
+		{
+			c:    Capability{ID: CapMSIX, Offset: 0x50},
+			want: "Enable+ Count=64 Masked-\nVector table: BAR=0 offset=00002000\nPBA: BAR=0 offset=00003000",
+		},
+		{
+			c: Capability{ID: ExtCapAER, Offset: 0x100, Extended: true},
+			want: "UESta:\tDLP- SDES- TLP- FCP- CmpltTO- CmpltAbrt- UnxCmplt- RxOF- MalfTLP- ECRC- UnsupReq+ ACSViol-\n" +
+				"UEMsk:\tDLP- SDES- TLP- FCP- CmpltTO- CmpltAbrt- UnxCmplt- RxOF- MalfTLP- ECRC- UnsupReq- ACSViol-\n" +
+				"UESvrt:\tDLP- SDES- TLP- FCP- CmpltTO- CmpltAbrt- UnxCmplt- RxOF- MalfTLP- ECRC- UnsupReq- ACSViol-\n" +
+				"CESta:\tRxErr+ BadTLP- BadDLLP- Rollover- Timeout- AdvNonFatalErr-\n" +
+				"CEMsk:\tRxErr- BadTLP- BadDLLP- Rollover- Timeout- AdvNonFatalErr-\n" +
+				"AERCap:\tFirst Error Pointer: 00, ECRCGenCap- ECRCGenEn- ECRCChkCap- ECRCChkEn-",
+		},
+		{
+			c:    Capability{ID: ExtCapSRIOV, Offset: 0x140, Extended: true},
+			want: "IOVCtl:\tEnable+ MSE+\nInitial VFs: 8, Total VFs: 8, Number of VFs: 4, Function Dependency Link: 00\nVF offset: 128, stride: 2, Device ID: 1520",
+		},
+	} {
+		t.Run(tt.c.Name(), func(t *testing.T) {
+			d := Decode(config, tt.c)
+			if d == nil {
+				t.Fatalf("Decode(%v) = nil, want %q", tt.c, tt.want)
+			}
+			if got := d.String(); got != tt.want {
+				t.Errorf("Decode(%v) = %q, want %q", tt.c, got, tt.want)
+			}
+		})
+	}
+
+	if d := Decode(config, Capability{ID: CapExpress, Offset: 0x70}); d != nil {
+		t.Errorf("Decode(Express) = %v, want nil", d)
+	}
+	if _, err := ParseSRIOV(config[:ConfigSize], Capability{ID: ExtCapSRIOV, Offset: 0x140, Extended: true}); !errors.Is(err, ErrNoCapability) {
+		t.Errorf("ParseSRIOV of unread capability = %v, want %v", err, ErrNoCapability)
+	}
+}
+
+func TestRegisterOffset(t *testing.T) {
+	p := &PCI{Config: testConfig()}
+	for _, tt := range []struct {
+		reg     string
+		want    int64
+		wantErr error
+	}{
+		{reg: "0x10", want: 0x10},
+		{reg: "CAP_MSIX", want: 0x50},
+		{reg: "CAP_MSIX+2", want: 0x52},
+		{reg: "ECAP_SRIOV+0x10", want: 0x150},
+		{reg: "ECAP_ACS+4", wantErr: ErrNoCapability},
+		{reg: "CAP_FOO", wantErr: ErrNoCapability},
+		{reg: "cmd", wantErr: strconv.ErrSyntax},
+		{reg: "0x1000", wantErr: strconv.ErrRange},
+	} {
+		t.Run(tt.reg, func(t *testing.T) {
+			r, err := ParseRegister(tt.reg)
+			var got int64
+			if err == nil {
+				got, err = p.RegisterOffset(r)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterOffset(%q) = %v, want %v", tt.reg, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RegisterOffset(%q) = %#x, want %#x", tt.reg, got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // Devices contains a slice of one or more PCI devices
@@ -29,6 +30,11 @@ func (d Devices) Print(o io.Writer, verbose, confSize int) error {
 		}
 		if verbose >= 1 {
 			c := pci.Config
+			if pci.SubsystemName != "" {
+				if _, err := fmt.Fprintf(o, "\tSubsystem: %s\n", pci.SubsystemName); err != nil {
+					return err
+				}
+			}
 			if _, err := fmt.Fprintf(o, "\tControl: %s\n\tStatus: %s\n\tLatency: %d", pci.Control.String(), pci.Status.String(), pci.Latency); err != nil {
 				return err
 			}
@@ -77,6 +83,16 @@ func (d Devices) Print(o io.Writer, verbose, confSize int) error {
 					}
 				}
 			}
+			for _, c := range pci.Capabilities() {
+				if _, err := fmt.Fprintf(o, "\tCapabilities: %v\n", c); err != nil {
+					return err
+				}
+				if d := Decode(pci.Config, c); d != nil {
+					if _, err := fmt.Fprintf(o, "\t\t%s\n", strings.ReplaceAll(d.String(), "\n", "\n\t\t")); err != nil {
+						return err
+					}
+				}
+			}
 			extraNL = true
 		}
 
@@ -104,6 +120,13 @@ func (d Devices) SetVendorDeviceName(ids []Vendor) {
 	}
 }
 
+// SetNames sets all names of all the devices using the pci device SetNames.
+func (d Devices) SetNames(db *IDDB) {
+	for _, p := range d {
+		p.SetNames(db)
+	}
+}
+
 // ReadConfig reads the config info for all the devices.
 func (d Devices) ReadConfig() error {
 	for _, p := range d {
@@ -0,0 +1,233 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+//go:generate go run gen.go
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pciIDs is pci.ids, gzipped. See https://pci-ids.ucw.cz/.
+//
+//go:embed pci.ids.gz
+var pciIDs []byte
+
+// IDDB is a database of vendor, device, subsystem and class names, as read
+// from a pci.ids file.
+type IDDB struct {
+	Vendors []Vendor
+	Classes []Class
+}
+
+var defaultIDs = sync.OnceValue(func() *IDDB {
+	db, err := ParseIDs(bytes.NewReader(pciIDs))
+	if err != nil {
+		// The embedded file is checked by the tests.
+		log.Printf("pci: embedded pci.ids: %v", err)
+		return &IDDB{}
+	}
+	return db
+})
+
+// DefaultIDs returns the database embedded in the package. It is parsed on
+// first use.
+func DefaultIDs() *IDDB {
+	return defaultIDs()
+}
+
+// LoadIDs reads a pci.ids file, plain or gzipped, e.g. a more recent
+// /usr/share/misc/pci.ids than the one embedded in the package.
+func LoadIDs(path string) (*IDDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := ParseIDs(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// ParseIDs parses pci.ids, plain or gzipped.
+func ParseIDs(r io.Reader) (*IDDB, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	db := &IDDB{}
+	// Entries are appended to the last vendor, device, class or subclass.
+	var (
+		vendor   *Vendor
+		device   *Device
+		class    *Class
+		subclass *Subclass
+	)
+	s := bufio.NewScanner(br)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		tabs := len(line) - len(strings.TrimLeft(line, "\t"))
+		line = line[tabs:]
+		id, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q: %w", n, line, strconv.ErrSyntax)
+		}
+
+		var err error
+		switch {
+		case tabs == 0 && strings.HasPrefix(id, "C "):
+			var c uint8
+			c, err = parseID8(id[2:])
+			db.Classes = append(db.Classes, Class{ID: c, Name: name})
+			class, subclass, vendor, device = &db.Classes[len(db.Classes)-1], nil, nil, nil
+		case tabs == 0 && len(id) == 4:
+			var v uint16
+			v, err = parseID16(id)
+			db.Vendors = append(db.Vendors, Vendor{ID: v, Name: name})
+			vendor, device, class, subclass = &db.Vendors[len(db.Vendors)-1], nil, nil, nil
+		case tabs == 0:
+			// Other lists, e.g. of USB language IDs, in a combined file.
+			vendor, device, class, subclass = nil, nil, nil, nil
+		case tabs == 1 && vendor != nil:
+			var d uint16
+			d, err = parseID16(id)
+			vendor.Devices = append(vendor.Devices, Device{ID: d, Name: name})
+			device = &vendor.Devices[len(vendor.Devices)-1]
+		case tabs == 1 && class != nil:
+			var c uint8
+			c, err = parseID8(id)
+			class.Subclasses = append(class.Subclasses, Subclass{ID: c, Name: name})
+			subclass = &class.Subclasses[len(class.Subclasses)-1]
+		case tabs == 2 && device != nil:
+			sv, sd, _ := strings.Cut(id, " ")
+			var sub Subsystem
+			if sub.Vendor, err = parseID16(sv); err == nil {
+				sub.Device, err = parseID16(sd)
+			}
+			sub.Name = name
+			device.Subsystems = append(device.Subsystems, sub)
+		case tabs == 2 && subclass != nil:
+			var p uint8
+			p, err = parseID8(id)
+			subclass.ProgIfs = append(subclass.ProgIfs, ProgIf{ID: p, Name: name})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func parseID16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 16, 16)
+	return uint16(v), err
+}
+
+func parseID8(s string) (uint8, error) {
+	v, err := strconv.ParseUint(s, 16, 8)
+	return uint8(v), err
+}
+
+func (db *IDDB) vendor(id uint16) *Vendor {
+	for i := range db.Vendors {
+		if db.Vendors[i].ID == id {
+			return &db.Vendors[i]
+		}
+	}
+	return nil
+}
+
+// Lookup returns the names of vendor and device, or their IDs in hex if
+// they are unknown.
+func (db *IDDB) Lookup(vendor, device uint16) (string, string) {
+	return Lookup(db.Vendors, vendor, device)
+}
+
+// Subsystem returns the name of the subsystem of device, as lspci shows
+// it: the name of the subsystem vendor followed by that of the subsystem,
+// or by the subsystem ID if it is unknown.
+func (db *IDDB) Subsystem(vendor, device, subVendor, subDevice uint16) string {
+	if v := db.vendor(vendor); v != nil {
+		for _, d := range v.Devices {
+			if d.ID != device {
+				continue
+			}
+			for _, s := range d.Subsystems {
+				if s.Vendor == subVendor && s.Device == subDevice {
+					name, _ := db.Lookup(subVendor, 0)
+					return name + " " + s.Name
+				}
+			}
+		}
+	}
+	name, _ := db.Lookup(subVendor, 0)
+	return fmt.Sprintf("%s Device "+venDevFmt, name, subDevice)
+}
+
+// ClassName returns the name of the subclass of class, a 24-bit class,
+// subclass and programming interface as read from sysfs, or of the class if
+// the subclass is unknown. It returns false if the class is unknown.
+func (db *IDDB) ClassName(class uint32) (string, bool) {
+	c, sub := uint8(class>>16), uint8(class>>8)
+	for _, cl := range db.Classes {
+		if cl.ID != c {
+			continue
+		}
+		for _, s := range cl.Subclasses {
+			if s.ID == sub {
+				return s.Name, true
+			}
+		}
+		return cl.Name, true
+	}
+	return "", false
+}
+
+// ProgIfName returns the name of the programming interface of class, a
+// 24-bit class, subclass and programming interface as read from sysfs. It
+// returns false if the programming interface is unknown.
+func (db *IDDB) ProgIfName(class uint32) (string, bool) {
+	c, sub, progIf := uint8(class>>16), uint8(class>>8), uint8(class)
+	for _, cl := range db.Classes {
+		if cl.ID != c {
+			continue
+		}
+		for _, s := range cl.Subclasses {
+			if s.ID != sub {
+				continue
+			}
+			for _, p := range s.ProgIfs {
+				if p.ID == progIf {
+					return p.Name, true
+				}
+			}
+		}
+	}
+	return "", false
+}
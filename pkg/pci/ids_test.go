@@ -0,0 +1,141 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testIDs = `# A comment
+8086  Intel Corporation
+	100e  82540EM Gigabit Ethernet Controller
+		1028 002e  Optiplex GX260
+		8086 001e  PRO/1000 MT Mobile Connection
+	1237  440FX - 82441FX PMC [Natoma]
+1af4  Red Hat, Inc.
+	1000  Virtio network device
+
+# List of known device classes
+
+C 02  Network controller
+	00  Ethernet controller
+C 0c  Serial bus controller
+	03  USB controller
+		30  XHCI
+`
+
+func TestParseIDs(t *testing.T) {
+	want := &IDDB{
+		Vendors: []Vendor{
+			{ID: 0x8086, Name: "Intel Corporation", Devices: []Device{
+				{ID: 0x100e, Name: "82540EM Gigabit Ethernet Controller", Subsystems: []Subsystem{
+					{Vendor: 0x1028, Device: 0x002e, Name: "Optiplex GX260"},
+					{Vendor: 0x8086, Device: 0x001e, Name: "PRO/1000 MT Mobile Connection"},
+				}},
+				{ID: 0x1237, Name: "440FX - 82441FX PMC [Natoma]"},
+			}},
+			{ID: 0x1af4, Name: "Red Hat, Inc.", Devices: []Device{
+				{ID: 0x1000, Name: "Virtio network device"},
+			}},
+		},
+		Classes: []Class{
+			{ID: 0x02, Name: "Network controller", Subclasses: []Subclass{
+				{ID: 0x00, Name: "Ethernet controller"},
+			}},
+			{ID: 0x0c, Name: "Serial bus controller", Subclasses: []Subclass{
+				{ID: 0x03, Name: "USB controller", ProgIfs: []ProgIf{{ID: 0x30, Name: "XHCI"}}},
+			}},
+		},
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testIDs))
+	w.Close()
+
+	for name, b := range map[string][]byte{"plain": []byte(testIDs), "gzip": gz.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseIDs(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseIDs = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseIDs(strings.NewReader("8086 Intel\n")); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("ParseIDs(bad line) = %v, want %v", err, strconv.ErrSyntax)
+	}
+}
+
+func TestIDDBNames(t *testing.T) {
+	db, err := ParseIDs(strings.NewReader(testIDs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &PCI{Vendor: 0x8086, Device: 0x100e, SubVendor: 0x1028, SubDevice: 0x002e, Class: 0x020000, ClassName: "NetworkEthernet"}
+	p.SetNames(db)
+	want := &PCI{
+		Vendor: 0x8086, Device: 0x100e, SubVendor: 0x1028, SubDevice: 0x002e, Class: 0x020000,
+		VendorName:    "Intel Corporation",
+		DeviceName:    "82540EM Gigabit Ethernet Controller",
+		ClassName:     "Ethernet controller",
+		SubsystemName: "1028 Optiplex GX260",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("SetNames = %+v, want %+v", p, want)
+	}
+
+	if got, want := db.Subsystem(0x1af4, 0x1000, 0x1af4, 0x0001), "Red Hat, Inc. Device 0001"; got != want {
+		t.Errorf("Subsystem of unknown subsystem = %q, want %q", got, want)
+	}
+	if got, ok := db.ClassName(0x0c0330); !ok || got != "USB controller" {
+		t.Errorf("ClassName(0x0c0330) = %q, %t, want USB controller, true", got, ok)
+	}
+	if got, ok := db.ProgIfName(0x0c0330); !ok || got != "XHCI" {
+		t.Errorf("ProgIfName(0x0c0330) = %q, %t, want XHCI, true", got, ok)
+	}
+	if _, ok := db.ClassName(0x060400); ok {
+		t.Errorf("ClassName(0x060400) found, want unknown")
+	}
+}
+
+func TestDefaultIDs(t *testing.T) {
+	db := DefaultIDs()
+	if len(db.Vendors) < 1000 {
+		t.Errorf("embedded pci.ids has %d vendors, want more", len(db.Vendors))
+	}
+	if got, ok := db.ClassName(0x060400); !ok || got != "PCI bridge" {
+		t.Errorf("ClassName(0x060400) = %q, %t, want PCI bridge, true", got, ok)
+	}
+}
+
+func TestLoadIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(path, []byte(testIDs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadIDs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, d := db.Lookup(0x1af4, 0x1000); v != "Red Hat, Inc." || d != "Virtio network device" {
+		t.Errorf("Lookup(1af4, 1000) = %q, %q, want Red Hat, Inc., Virtio network device", v, d)
+	}
+	if _, err := LoadIDs(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadIDs(missing) = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -26,9 +26,15 @@ type PCI struct {
 	Device uint16
 	Class  uint32
 
-	VendorName string
-	DeviceName string
-	ClassName  string
+	// SubVendor and SubDevice identify the board or card, and are 0 if
+	// the device has none.
+	SubVendor uint16 `json:",omitempty"`
+	SubDevice uint16 `json:",omitempty"`
+
+	VendorName    string
+	DeviceName    string
+	ClassName     string
+	SubsystemName string `json:",omitempty"`
 
 	Latency   byte
 	IRQPin    byte
@@ -66,6 +72,18 @@ func (p *PCI) SetVendorDeviceName(ids []Vendor) {
 	p.VendorName, p.DeviceName = Lookup(ids, p.Vendor, p.Device)
 }
 
+// SetNames sets the vendor, device and subsystem names from db, and the
+// class name if db knows the class.
+func (p *PCI) SetNames(db *IDDB) {
+	p.SetVendorDeviceName(db.Vendors)
+	if p.SubVendor != 0 {
+		p.SubsystemName = db.Subsystem(p.Vendor, p.Device, p.SubVendor, p.SubDevice)
+	}
+	if n, ok := db.ClassName(p.Class); ok {
+		p.ClassName = n
+	}
+}
+
 // ReadConfig reads the config space.
 func (p *PCI) ReadConfig() error {
 	dev := filepath.Join(p.FullPath, "config")
@@ -105,7 +123,7 @@ func (p *PCI) ReadConfigRegister(offset, size int64) (uint64, error) {
 	r := &barreg{offset: offset, File: f}
 	switch size {
 	default:
-		return 0, fmt.Errorf("ReadConfigRegister@%#x width of %d: only options are 9, 16, 32, 64:%w", offset, size, ErrBadWidth)
+		return 0, fmt.Errorf("ReadConfigRegister@%#x width of %d: only options are 8, 16, 32, 64:%w", offset, size, ErrBadWidth)
 	case 64:
 		err = binary.Read(r, binary.LittleEndian, &reg)
 	case 32:
@@ -185,7 +203,7 @@ iter:
 		if err := p.ReadConfig(); err != nil {
 			return nil, err
 		}
-		p.SetVendorDeviceName(IDs)
+		p.SetNames(DefaultIDs())
 
 		c := p.Config
 		// Fill in whatever random stuff we can, from the base config.
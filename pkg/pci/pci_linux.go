@@ -4,8 +4,6 @@
 
 package pci
 
-//go:generate go run gen.go
-
 import (
 	"fmt"
 	"os"
@@ -66,6 +64,13 @@ func OnePCI(dir string) (*PCI, error) {
 		return nil, err
 	}
 	pci.IRQLine = uint(n)
+	// Not all devices have subsystem IDs, e.g. bridges of older machines.
+	if n, err = readUint(dir, "subsystem_vendor", 16, 16); err == nil {
+		pci.SubVendor = uint16(n)
+	}
+	if n, err = readUint(dir, "subsystem_device", 16, 16); err == nil {
+		pci.SubDevice = uint16(n)
+	}
 	if pci.Resource, err = readString(dir, "resource"); err != nil {
 		return nil, err
 	}
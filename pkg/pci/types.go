@@ -20,9 +20,41 @@ type Vendor struct {
 	Devices []Device
 }
 
-// Device is a PCI Device, with an ID and a Name.
+// Device is a PCI Device, with an ID, a Name, and a possibly empty
+// []Subsystem.
 type Device struct {
-	ID   uint16
+	ID         uint16
+	Name       string
+	Subsystems []Subsystem `json:",omitempty"`
+}
+
+// Subsystem is a board or card built around a Device, identified by the
+// subsystem vendor and device IDs.
+type Subsystem struct {
+	Vendor uint16
+	Device uint16
+	Name   string
+}
+
+// Class is a PCI device class, with an ID, a Name, and a possibly empty
+// []Subclass.
+type Class struct {
+	ID         uint8
+	Name       string
+	Subclasses []Subclass
+}
+
+// Subclass is a PCI device subclass, with an ID, a Name, and a possibly
+// empty []ProgIf.
+type Subclass struct {
+	ID      uint8
+	Name    string
+	ProgIfs []ProgIf
+}
+
+// ProgIf is a programming interface of a Subclass.
+type ProgIf struct {
+	ID   uint8
 	Name string
 }
 
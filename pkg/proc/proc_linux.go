@@ -0,0 +1,416 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package proc reads process, thread and system statistics from the Linux
+// /proc filesystem.
+//
+// The parsers take the contents of the files, so that callers which read
+// them in their own way, like ps, can use them as well.
+package proc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot is where procfs is usually mounted.
+const DefaultRoot = "/proc"
+
+// UserHZ is the unit of the times in /proc, USER_HZ, which is 100 on all
+// architectures Linux supports.
+const UserHZ = 100
+
+// ErrBadStat is returned for stat files which cannot be parsed.
+var ErrBadStat = errors.New("malformed stat")
+
+// Stat is the part of /proc/PID/stat this package decodes.
+type Stat struct {
+	PID     int
+	Comm    string
+	State   string
+	PPID    int
+	PGRP    int
+	Session int
+	TTY     int
+	// UTime and STime are the user and system time, in clock ticks.
+	UTime uint64
+	STime uint64
+	Nice  int
+	// Threads is the number of threads of the process.
+	Threads int
+	// StartTime is the time the process started after boot, in clock
+	// ticks.
+	StartTime uint64
+	// VSize is the virtual memory size, in bytes.
+	VSize uint64
+	// RSSPages is the resident set size, in pages.
+	RSSPages uint64
+	// CPU is the CPU the task last ran on.
+	CPU int
+}
+
+// ParseStat parses the contents of /proc/PID/stat. The command name may
+// contain spaces and parentheses, so fields are counted from the last ).
+func ParseStat(s string) (Stat, error) {
+	var st Stat
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return st, fmt.Errorf("%w: no command name", ErrBadStat)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return st, fmt.Errorf("%w: pid: %v", ErrBadStat, err)
+	}
+	st.PID, st.Comm = pid, s[open+1:end]
+	// f[0] is field 3 of proc(5), the state.
+	f := strings.Fields(s[end+1:])
+	if len(f) < 37 {
+		return st, fmt.Errorf("%w: %d fields", ErrBadStat, len(f)+2)
+	}
+	st.State = f[0]
+	ints := []struct {
+		v     *int
+		field int
+	}{{&st.PPID, 4}, {&st.PGRP, 5}, {&st.Session, 6}, {&st.TTY, 7}, {&st.Nice, 19}, {&st.Threads, 20}, {&st.CPU, 39}}
+	for _, i := range ints {
+		if i.field-3 >= len(f) {
+			continue
+		}
+		if *i.v, err = strconv.Atoi(f[i.field-3]); err != nil {
+			return st, fmt.Errorf("%w: field %d: %v", ErrBadStat, i.field, err)
+		}
+	}
+	uints := []struct {
+		v     *uint64
+		field int
+	}{{&st.UTime, 14}, {&st.STime, 15}, {&st.StartTime, 22}, {&st.VSize, 23}, {&st.RSSPages, 24}}
+	for _, u := range uints {
+		if *u.v, err = strconv.ParseUint(f[u.field-3], 10, 64); err != nil {
+			return st, fmt.Errorf("%w: field %d: %v", ErrBadStat, u.field, err)
+		}
+	}
+	return st, nil
+}
+
+// Status is the part of /proc/PID/status this package decodes. Sizes are
+// in bytes.
+type Status struct {
+	UID int
+	// Size is the virtual memory size.
+	Size uint64
+	// RSS is the resident set size, the sum of Anon, File and Shmem.
+	RSS   uint64
+	Anon  uint64
+	File  uint64
+	Shmem uint64
+	Swap  uint64
+}
+
+// ParseStatus parses the contents of /proc/PID/status. Kernel threads have
+// no memory lines, which are left 0.
+func ParseStatus(s string) (Status, error) {
+	st := Status{UID: -1}
+	sizes := map[string]*uint64{
+		"VmSize":   &st.Size,
+		"VmRSS":    &st.RSS,
+		"RssAnon":  &st.Anon,
+		"RssFile":  &st.File,
+		"RssShmem": &st.Shmem,
+		"VmSwap":   &st.Swap,
+	}
+	for _, line := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(v)
+		if len(f) == 0 {
+			continue
+		}
+		if k == "Uid" {
+			uid, err := strconv.Atoi(f[0])
+			if err != nil {
+				return st, fmt.Errorf("Uid: %w", err)
+			}
+			st.UID = uid
+		}
+		if p, ok := sizes[k]; ok {
+			kb, err := strconv.ParseUint(f[0], 10, 64)
+			if err != nil {
+				return st, fmt.Errorf("%s: %w", k, err)
+			}
+			*p = kb << 10
+		}
+	}
+	if st.UID < 0 {
+		return st, fmt.Errorf("no Uid in status")
+	}
+	return st, nil
+}
+
+// IO is /proc/PID/io.
+type IO struct {
+	// RChar and WChar are the bytes passed to read and write, and
+	// similar syscalls.
+	RChar uint64
+	WChar uint64
+	// SyscR and SyscW are the number of read and write syscalls.
+	SyscR uint64
+	SyscW uint64
+	// ReadBytes and WriteBytes are the bytes read from and written to
+	// storage.
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// ParseIO parses the contents of /proc/PID/io.
+func ParseIO(s string) (IO, error) {
+	var io IO
+	fields := map[string]*uint64{
+		"rchar":       &io.RChar,
+		"wchar":       &io.WChar,
+		"syscr":       &io.SyscR,
+		"syscw":       &io.SyscW,
+		"read_bytes":  &io.ReadBytes,
+		"write_bytes": &io.WriteBytes,
+	}
+	for _, line := range strings.Split(s, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if p, known := fields[k]; ok && known {
+			n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return io, fmt.Errorf("%s: %w", k, err)
+			}
+			*p = n
+		}
+	}
+	return io, nil
+}
+
+// ParseCgroup returns the cgroup path from the contents of /proc/PID/cgroup:
+// the unified hierarchy's if there is one, else the first v1 hierarchy's,
+// prefixed with its controllers.
+func ParseCgroup(s string) string {
+	var v1 string
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		f := strings.SplitN(line, ":", 3)
+		if len(f) != 3 {
+			continue
+		}
+		if f[0] == "0" && f[1] == "" {
+			return f[2]
+		}
+		if v1 == "" {
+			v1 = f[1] + ":" + f[2]
+		}
+	}
+	return v1
+}
+
+// Proc is a process, or a thread of one, as read from its /proc directory.
+type Proc struct {
+	Stat
+	Status
+	// TID is the thread ID, or 0 for processes.
+	TID     int
+	Cmdline []string
+	Cgroup  string
+	IO      IO
+}
+
+// Name returns the command line of the process, or its command name in
+// brackets if it has none, as for kernel threads.
+func (p *Proc) Name() string {
+	if len(p.Cmdline) == 0 {
+		return "[" + p.Comm + "]"
+	}
+	return strings.Join(p.Cmdline, " ")
+}
+
+// FS is a procfs mount point.
+type FS string
+
+func read(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	return string(b), err
+}
+
+// ReadDir reads the process or thread in dir, e.g. /proc/1 or
+// /proc/1/task/2. Only stat and status are required: io can only be
+// read by the owner, and cgroup and cmdline may be missing.
+func ReadDir(dir string) (*Proc, error) {
+	s, err := read(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	p := &Proc{}
+	if p.Stat, err = ParseStat(s); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	if s, err = read(filepath.Join(dir, "status")); err != nil {
+		return nil, err
+	}
+	if p.Status, err = ParseStatus(s); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	if s, err := read(filepath.Join(dir, "cmdline")); err == nil && s != "" {
+		p.Cmdline = strings.Split(strings.TrimRight(s, "\x00"), "\x00")
+	}
+	if s, err := read(filepath.Join(dir, "cgroup")); err == nil {
+		p.Cgroup = ParseCgroup(s)
+	}
+	if s, err := read(filepath.Join(dir, "io")); err == nil {
+		if p.IO, err = ParseIO(s); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	return p, nil
+}
+
+// ids returns the numeric entries of a directory, sorted.
+func ids(dir string) ([]int, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, e := range ents {
+		if id, err := strconv.Atoi(e.Name()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Proc reads process pid.
+func (fs FS) Proc(pid int) (*Proc, error) {
+	return ReadDir(filepath.Join(string(fs), strconv.Itoa(pid)))
+}
+
+// Procs reads all processes. Processes which exit while they are read are
+// skipped.
+func (fs FS) Procs() ([]*Proc, error) {
+	pids, err := ids(string(fs))
+	if err != nil {
+		return nil, err
+	}
+	var procs []*Proc
+	for _, pid := range pids {
+		p, err := fs.Proc(pid)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// Threads reads the threads of process pid.
+func (fs FS) Threads(pid int) ([]*Proc, error) {
+	dir := filepath.Join(string(fs), strconv.Itoa(pid), "task")
+	tids, err := ids(dir)
+	if err != nil {
+		return nil, err
+	}
+	var threads []*Proc
+	for _, tid := range tids {
+		t, err := ReadDir(filepath.Join(dir, strconv.Itoa(tid)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The stat of a thread has its TID in place of the PID.
+		t.TID, t.PID = tid, pid
+		threads = append(threads, t)
+	}
+	return threads, nil
+}
+
+// AllThreads reads the threads of all processes.
+func (fs FS) AllThreads() ([]*Proc, error) {
+	pids, err := ids(string(fs))
+	if err != nil {
+		return nil, err
+	}
+	var threads []*Proc
+	for _, pid := range pids {
+		t, err := fs.Threads(pid)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, t...)
+	}
+	return threads, nil
+}
+
+// MemInfo reads /proc/meminfo, in bytes.
+func (fs FS) MemInfo() (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(string(fs), "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := map[string]uint64{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		fv := strings.Fields(v)
+		if len(fv) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fv[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("meminfo: %s: %w", k, err)
+		}
+		if len(fv) > 1 && fv[1] == "kB" {
+			n <<= 10
+		}
+		m[k] = n
+	}
+	return m, s.Err()
+}
+
+// LoadAvg reads the 1, 5 and 15 minute load averages from /proc/loadavg.
+func (fs FS) LoadAvg() ([3]float64, error) {
+	var l [3]float64
+	s, err := read(filepath.Join(string(fs), "loadavg"))
+	if err != nil {
+		return l, err
+	}
+	if _, err := fmt.Sscan(s, &l[0], &l[1], &l[2]); err != nil {
+		return l, fmt.Errorf("loadavg: %w", err)
+	}
+	return l, nil
+}
+
+// Uptime reads the seconds since boot from /proc/uptime.
+func (fs FS) Uptime() (float64, error) {
+	s, err := read(filepath.Join(string(fs), "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	var up float64
+	if _, err := fmt.Sscan(s, &up); err != nil {
+		return 0, fmt.Errorf("uptime: %w", err)
+	}
+	return up, nil
+}
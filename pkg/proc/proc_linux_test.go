@@ -0,0 +1,182 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	// A process named "a (b) c", to check the command name is found by
+	// its last parenthesis.
+	stat = "42 (a (b) c) S 1 42 42 34816 42 4194560 1000 0 0 0 150 50 0 0 20 0 3 0 12345 10485760 256 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0\n"
+
+	status = "Name:\ta (b) c\nUmask:\t0022\nState:\tS (sleeping)\nUid:\t1000\t1000\t1000\t1000\nVmSize:\t   10240 kB\nVmRSS:\t    1024 kB\nRssAnon:\t     512 kB\nRssFile:\t     448 kB\nRssShmem:\t      64 kB\nVmSwap:\t       8 kB\nThreads:\t3\n"
+
+	io = "rchar: 4096\nwchar: 2048\nsyscr: 10\nsyscw: 5\nread_bytes: 8192\nwrite_bytes: 1024\ncancelled_write_bytes: 0\n"
+)
+
+func TestParseStat(t *testing.T) {
+	got, err := ParseStat(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stat{
+		PID: 42, Comm: "a (b) c", State: "S", PPID: 1, PGRP: 42, Session: 42, TTY: 34816,
+		UTime: 150, STime: 50, Threads: 3, StartTime: 12345, VSize: 10 << 20, RSSPages: 256, CPU: 2,
+	}
+	if got != want {
+		t.Errorf("ParseStat =\n%+v\nwant\n%+v", got, want)
+	}
+
+	for _, bad := range []string{"", "42 a", "42 (a) S 1 2 3", "x (a) S 1 42 42 0 42 0 0 0 0 0 0 0 0 0 20 0 1 0 1 1 1 1 1 1 0 0 0 0 0 0 0 0 0 0 17 2 0 0"} {
+		if _, err := ParseStat(bad); !errors.Is(err, ErrBadStat) {
+			t.Errorf("ParseStat(%q) = %v, want %v", bad, err, ErrBadStat)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	got, err := ParseStatus(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Status{UID: 1000, Size: 10 << 20, RSS: 1 << 20, Anon: 512 << 10, File: 448 << 10, Shmem: 64 << 10, Swap: 8 << 10}
+	if got != want {
+		t.Errorf("ParseStatus =\n%+v\nwant\n%+v", got, want)
+	}
+	if _, err := ParseStatus("Name:\tkthreadd\n"); err == nil {
+		t.Errorf("ParseStatus without Uid succeeded, want error")
+	}
+}
+
+func TestParseIO(t *testing.T) {
+	got, err := ParseIO(io)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IO{RChar: 4096, WChar: 2048, SyscR: 10, SyscW: 5, ReadBytes: 8192, WriteBytes: 1024}
+	if got != want {
+		t.Errorf("ParseIO = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCgroup(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{in: "0::/system.slice/sshd.service\n", want: "/system.slice/sshd.service"},
+		{in: "12:cpu,cpuacct:/user\n1:name=systemd:/user/1000\n0::/user.slice\n", want: "/user.slice"},
+		{in: "12:cpu,cpuacct:/user\n1:name=systemd:/user/1000\n", want: "cpu,cpuacct:/user"},
+		{in: "", want: ""},
+	} {
+		if got := ParseCgroup(tt.in); got != tt.want {
+			t.Errorf("ParseCgroup(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// fakeProc writes a procfs with process 42 and its threads 42 and 43.
+func fakeProc(t *testing.T) FS {
+	root := t.TempDir()
+	files := map[string]string{
+		"42/stat":            stat,
+		"42/status":          status,
+		"42/io":              io,
+		"42/cgroup":          "0::/test.slice\n",
+		"42/cmdline":         "a\x00-b\x00",
+		"42/task/42/stat":    stat,
+		"42/task/42/status":  status,
+		"42/task/43/stat":    "43 (worker) R 1 42 42 34816 42 0 0 0 0 0 7 3 0 0 20 0 3 0 12346 10485760 256 0 1 1 0 0 0 0 0 0 0 0 0 0 17 1 0 0 0 0 0\n",
+		"42/task/43/status":  status,
+		"meminfo":            "MemTotal:        8000 kB\nMemFree:         4000 kB\nHugePages_Total:       0\n",
+		"loadavg":            "0.50 0.25 0.10 1/100 42\n",
+		"uptime":             "1234.56 5000.00\n",
+		"self/stat":          stat,
+		"43-not-a-pid/stat":  stat,
+		"42/task/43/cmdline": "a\x00-b\x00",
+		"42/task/43/io":      io,
+		"42/task/43/cgroup":  "0::/test.slice\n",
+		"42/task/42/cgroup":  "0::/test.slice\n",
+		"42/task/42/cmdline": "a\x00-b\x00",
+		"42/task/42/io":      io,
+	}
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return FS(root)
+}
+
+func TestFS(t *testing.T) {
+	fs := fakeProc(t)
+	procs, err := fs.Procs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 1 {
+		t.Fatalf("Procs = %d processes, want 1", len(procs))
+	}
+	p := procs[0]
+	if p.Name() != "a -b" || p.Cgroup != "/test.slice" || p.IO.ReadBytes != 8192 || p.RSS != 1<<20 {
+		t.Errorf("Procs()[0] = %+v", p)
+	}
+
+	threads, err := fs.AllThreads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]int
+	for _, th := range threads {
+		got = append(got, [2]int{th.PID, th.TID})
+	}
+	if want := [][2]int{{42, 42}, {42, 43}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllThreads = %v, want %v", got, want)
+	}
+	if threads[1].Comm != "worker" || threads[1].UTime != 7 {
+		t.Errorf("thread 43 = %+v", threads[1])
+	}
+
+	m, err := fs.MemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["MemTotal"] != 8000<<10 || m["HugePages_Total"] != 0 {
+		t.Errorf("MemInfo = %v", m)
+	}
+	l, err := fs.LoadAvg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != [3]float64{0.5, 0.25, 0.1} {
+		t.Errorf("LoadAvg = %v", l)
+	}
+	up, err := fs.Uptime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up != 1234.56 {
+		t.Errorf("Uptime = %v, want 1234.56", up)
+	}
+}
+
+func TestSelf(t *testing.T) {
+	p, err := FS(DefaultRoot).Proc(os.Getpid())
+	if err != nil {
+		t.Skipf("no procfs: %v", err)
+	}
+	if p.PID != os.Getpid() || p.UID != os.Getuid() || len(p.Cmdline) == 0 {
+		t.Errorf("Proc(self) = %+v", p)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CPUTimes are the clock ticks, in UserHZ, spent by all CPUs in each
+// state, from the cpu line of /proc/stat. Guest time is part of User.
+type CPUTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+// Total returns the ticks spent in all states.
+func (c CPUTimes) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// SysStat is the part of /proc/stat this package decodes. The counts are
+// since boot.
+type SysStat struct {
+	CPU             CPUTimes
+	Interrupts      uint64
+	ContextSwitches uint64
+	BootTime        uint64
+	Forks           uint64
+	ProcsRunning    uint64
+	ProcsBlocked    uint64
+}
+
+// ParseSysStat parses /proc/stat.
+func ParseSysStat(s string) (SysStat, error) {
+	var st SysStat
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		if f[0] == "cpu" {
+			// Older kernels have fewer fields.
+			ticks := []*uint64{&st.CPU.User, &st.CPU.Nice, &st.CPU.System, &st.CPU.Idle, &st.CPU.IOWait, &st.CPU.IRQ, &st.CPU.SoftIRQ, &st.CPU.Steal}
+			for i, v := range f[1:min(len(f), len(ticks)+1)] {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					return SysStat{}, fmt.Errorf("stat: cpu: %w", err)
+				}
+				*ticks[i] = n
+			}
+			continue
+		}
+		var p *uint64
+		switch f[0] {
+		case "intr":
+			p = &st.Interrupts
+		case "ctxt":
+			p = &st.ContextSwitches
+		case "btime":
+			p = &st.BootTime
+		case "processes":
+			p = &st.Forks
+		case "procs_running":
+			p = &st.ProcsRunning
+		case "procs_blocked":
+			p = &st.ProcsBlocked
+		default:
+			continue
+		}
+		n, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return SysStat{}, fmt.Errorf("stat: %s: %w", f[0], err)
+		}
+		*p = n
+	}
+	return st, nil
+}
+
+// Stat reads /proc/stat.
+func (fs FS) Stat() (SysStat, error) {
+	s, err := read(filepath.Join(string(fs), "stat"))
+	if err != nil {
+		return SysStat{}, err
+	}
+	return ParseSysStat(s)
+}
+
+// ParseVMStat parses /proc/vmstat, which has a counter on each line, like
+// "pgpgin 1234". Most are pages or events since boot.
+func ParseVMStat(s string) (map[string]uint64, error) {
+	m := map[string]uint64{}
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("vmstat: %s: %w", f[0], err)
+		}
+		m[f[0]] = n
+	}
+	return m, nil
+}
+
+// VMStat reads /proc/vmstat.
+func (fs FS) VMStat() (map[string]uint64, error) {
+	s, err := read(filepath.Join(string(fs), "vmstat"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseVMStat(s)
+}
+
+// DiskStat is a line of /proc/diskstats, for a disk or a partition. The
+// counts are since boot, the sectors are 512 bytes, whatever the disk's,
+// and the times are in milliseconds.
+type DiskStat struct {
+	Major          uint32
+	Minor          uint32
+	Name           string
+	Reads          uint64
+	ReadsMerged    uint64
+	SectorsRead    uint64
+	ReadTime       uint64
+	Writes         uint64
+	WritesMerged   uint64
+	SectorsWritten uint64
+	WriteTime      uint64
+	InFlight       uint64
+	IOTime         uint64
+	WeightedIOTime uint64
+}
+
+// ParseDiskStats parses /proc/diskstats. Newer kernels add discard and
+// flush counts, which are ignored.
+func ParseDiskStats(s string) ([]DiskStat, error) {
+	var stats []DiskStat
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 14 {
+			return nil, fmt.Errorf("diskstats: %q: %w", line, ErrBadStat)
+		}
+		var d DiskStat
+		d.Name = f[2]
+		n := make([]uint64, 13)
+		for i, v := range append(f[:2:2], f[3:14]...) {
+			var err error
+			if n[i], err = strconv.ParseUint(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("diskstats: %s: %w", d.Name, err)
+			}
+		}
+		d.Major, d.Minor = uint32(n[0]), uint32(n[1])
+		d.Reads, d.ReadsMerged, d.SectorsRead, d.ReadTime = n[2], n[3], n[4], n[5]
+		d.Writes, d.WritesMerged, d.SectorsWritten, d.WriteTime = n[6], n[7], n[8], n[9]
+		d.InFlight, d.IOTime, d.WeightedIOTime = n[10], n[11], n[12]
+		stats = append(stats, d)
+	}
+	return stats, nil
+}
+
+// DiskStats reads /proc/diskstats.
+func (fs FS) DiskStats() ([]DiskStat, error) {
+	s, err := read(filepath.Join(string(fs), "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiskStats(s)
+}
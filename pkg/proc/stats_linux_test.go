@@ -0,0 +1,78 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSysStat(t *testing.T) {
+	got, err := ParseSysStat(`cpu  100 2 30 800 40 5 6 7 0 0
+cpu0 50 1 15 400 20 2 3 3 0 0
+intr 12345 0 9 0
+ctxt 67890
+btime 1700000000
+processes 4242
+procs_running 3
+procs_blocked 1
+softirq 1 2 3
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SysStat{
+		CPU:        CPUTimes{User: 100, Nice: 2, System: 30, Idle: 800, IOWait: 40, IRQ: 5, SoftIRQ: 6, Steal: 7},
+		Interrupts: 12345, ContextSwitches: 67890, BootTime: 1700000000, Forks: 4242, ProcsRunning: 3, ProcsBlocked: 1,
+	}
+	if got != want {
+		t.Errorf("ParseSysStat =\n%+v\nwant\n%+v", got, want)
+	}
+	if got.CPU.Total() != 990 {
+		t.Errorf("Total = %d, want 990", got.CPU.Total())
+	}
+
+	// Linux 2.4 has only four CPU states.
+	got, err = ParseSysStat("cpu 1 2 3 4\n")
+	if err != nil || got.CPU != (CPUTimes{User: 1, Nice: 2, System: 3, Idle: 4}) {
+		t.Errorf("ParseSysStat(old) = %+v, %v", got, err)
+	}
+	if _, err := ParseSysStat("ctxt many\n"); err == nil {
+		t.Errorf("ParseSysStat(bad) = nil, want error")
+	}
+}
+
+func TestParseVMStat(t *testing.T) {
+	got, err := ParseVMStat("nr_free_pages 1000\npgpgin 20\npswpout 3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]uint64{"nr_free_pages": 1000, "pgpgin": 20, "pswpout": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseVMStat = %v, want %v", got, want)
+	}
+	if _, err := ParseVMStat("pgpgin -1\n"); err == nil {
+		t.Errorf("ParseVMStat(bad) = nil, want error")
+	}
+}
+
+func TestParseDiskStats(t *testing.T) {
+	got, err := ParseDiskStats(`   8       0 sda 100 10 2000 50 200 20 4000 80 1 120 130 0 0 0 0 5 6
+   8       1 sda1 90 9 1800 45 190 19 3800 75 0 110 120
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiskStat{
+		{Major: 8, Minor: 0, Name: "sda", Reads: 100, ReadsMerged: 10, SectorsRead: 2000, ReadTime: 50, Writes: 200, WritesMerged: 20, SectorsWritten: 4000, WriteTime: 80, InFlight: 1, IOTime: 120, WeightedIOTime: 130},
+		{Major: 8, Minor: 1, Name: "sda1", Reads: 90, ReadsMerged: 9, SectorsRead: 1800, ReadTime: 45, Writes: 190, WritesMerged: 19, SectorsWritten: 3800, WriteTime: 75, IOTime: 110, WeightedIOTime: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDiskStats =\n%+v\nwant\n%+v", got, want)
+	}
+	if _, err := ParseDiskStats("8 0 sda 1 2 3\n"); !errors.Is(err, ErrBadStat) {
+		t.Errorf("ParseDiskStats(short) = %v, want %v", err, ErrBadStat)
+	}
+}
@@ -67,6 +67,12 @@ func (p *ProgressData) Begin() {
 
 // End - Ends the progress and send quit signal to the channel
 func (p *ProgressData) End() {
+	p.EndWith()
+}
+
+// EndWith ends the progress like End, but prints lines before the grand
+// total, such as the record counts of dd.
+func (p *ProgressData) EndWith(lines ...string) {
 	if p.mode == "progress" {
 		// Properly synchronize goroutine.
 		p.quit <- struct{}{}
@@ -77,6 +83,13 @@ func (p *ProgressData) End() {
 		p.endTimeMutex.Unlock()
 	}
 	if p.mode == "progress" || p.mode == "xfer" {
+		if p.mode == "progress" && len(lines) > 0 {
+			// Move past the last progress line.
+			fmt.Fprint(p.w, "\n")
+		}
+		for _, l := range lines {
+			fmt.Fprintln(p.w, l)
+		}
 		// Print grand total.
 		p.print("\n")
 	}
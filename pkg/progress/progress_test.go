@@ -6,6 +6,7 @@ package progress
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 )
@@ -90,3 +91,20 @@ func TestProgressEnd(t *testing.T) {
 		})
 	}
 }
+
+func TestProgressEndWith(t *testing.T) {
+	n := int64(1024)
+	b := &bytes.Buffer{}
+	p := New(b, "xfer", &n)
+	p.Begin()
+	p.EndWith("2+0 records in", "2+0 records out")
+
+	got := b.String()
+	want := "2+0 records in\n2+0 records out\n1024 bytes (0.001 MB, 0.001 MiB) copied, "
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("EndWith() printed %q, want prefix %q", got, want)
+	}
+	if !strings.HasSuffix(got, " MB/s\n") {
+		t.Errorf("EndWith() printed %q, want the throughput last", got)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ptp reads, sets and adjusts PTP hardware clocks (PHCs), the
+// clocks of network cards at /dev/ptp*, and the system clock, and has the
+// servo to make one follow the other.
+package ptp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrNoSamples is returned when no offset between two clocks could be
+// measured.
+var ErrNoSamples = errors.New("no clock samples")
+
+// Caps are the capabilities of a PHC.
+type Caps struct {
+	// MaxAdj is the largest frequency adjustment, in parts per billion.
+	MaxAdj int
+	// Alarms is the number of programmable alarms.
+	Alarms int
+	// ExtTimestamps is the number of external timestamp channels.
+	ExtTimestamps int
+	// PeriodicOutputs is the number of programmable periodic signals.
+	PeriodicOutputs int
+	// PPS is whether the clock can deliver a PPS signal to the kernel.
+	PPS bool
+	// Pins is the number of input and output pins.
+	Pins int
+	// CrossTimestamping is whether the clock and system time can be
+	// read at the same instant by the hardware.
+	CrossTimestamping bool
+}
+
+// String implements fmt.Stringer.
+func (c Caps) String() string {
+	return fmt.Sprintf("max adjustment %d ppb, %d alarms, %d external timestamp channels, %d periodic outputs, pps %v, %d pins, cross timestamping %v",
+		c.MaxAdj, c.Alarms, c.ExtTimestamps, c.PeriodicOutputs, c.PPS, c.Pins, c.CrossTimestamping)
+}
+
+// sample is a reading of a clock between two readings of the system clock.
+type sample struct {
+	before, clock, after time.Time
+}
+
+// bestOffset returns the offset of the system clock from the clock in the
+// sample with the shortest delay, which is the one read most precisely,
+// and that delay.
+func bestOffset(samples []sample) (offset, delay time.Duration, err error) {
+	if len(samples) == 0 {
+		return 0, 0, ErrNoSamples
+	}
+	delay = time.Duration(math.MaxInt64)
+	for _, s := range samples {
+		d := s.after.Sub(s.before)
+		if d < 0 || d >= delay {
+			continue
+		}
+		delay = d
+		// The system time at the instant the clock was read is
+		// taken to be halfway between the readings.
+		offset = s.before.Add(d / 2).Sub(s.clock)
+	}
+	if delay == time.Duration(math.MaxInt64) {
+		return 0, 0, ErrNoSamples
+	}
+	return offset, delay, nil
+}
+
+// Servo is a proportional-integral controller that turns the offsets of a
+// clock from its reference into frequency adjustments, like the one of
+// linuxptp's phc2sys.
+type Servo struct {
+	// KP and KI are the proportional and integral constants.
+	KP, KI float64
+	// MaxFreq is the largest frequency adjustment, in parts per billion.
+	MaxFreq float64
+	// Step is the offset above which the clock is stepped rather than
+	// slewed. If it is 0, the clock is only stepped on the first sample.
+	Step time.Duration
+
+	drift   float64
+	started bool
+}
+
+// NewServo returns a Servo with the constants phc2sys uses for the system
+// clock, which the kernel adjusts by up to 500 ppm.
+func NewServo() *Servo {
+	return &Servo{KP: 0.7, KI: 0.3, MaxFreq: 500000}
+}
+
+// Sample takes the offset of the clock from its reference, measured
+// interval after the previous one. It returns the frequency adjustment of
+// the clock, in parts per billion, and whether the clock must first be
+// stepped back by offset.
+func (s *Servo) Sample(offset, interval time.Duration) (freq float64, step bool) {
+	if !s.started || (s.Step > 0 && (offset > s.Step || offset < -s.Step)) {
+		s.started = true
+		return s.drift, true
+	}
+	// An offset of n nanoseconds over a second is an error of n ppb.
+	ppb := float64(offset.Nanoseconds()) / interval.Seconds()
+	s.drift = clamp(s.drift-s.KI*ppb, s.MaxFreq)
+	return clamp(s.drift-s.KP*ppb, s.MaxFreq), false
+}
+
+func clamp(v, limit float64) float64 {
+	return math.Max(-limit, math.Min(v, limit))
+}
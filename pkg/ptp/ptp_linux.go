@@ -0,0 +1,228 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ptp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// maxSamples is PTP_MAX_SAMPLES, the most samples PTP_SYS_OFFSET
+	// takes at once.
+	maxSamples = 25
+
+	// _IOR('=', 1, struct ptp_clock_caps)
+	ptpClockGetcaps = 2<<30 | unsafe.Sizeof(clockCaps{})<<16 | '='<<8 | 1
+	// _IOW('=', 5, struct ptp_sys_offset)
+	ptpSysOffset = 1<<30 | unsafe.Sizeof(sysOffset{})<<16 | '='<<8 | 5
+)
+
+// clockCaps is struct ptp_clock_caps.
+type clockCaps struct {
+	maxAdj            int32
+	nAlarm            int32
+	nExtTs            int32
+	nPerOut           int32
+	pps               int32
+	nPins             int32
+	crossTimestamping int32
+	adjustPhase       int32
+	maxPhaseAdj       int32
+	_                 [11]int32
+}
+
+// clockTime is struct ptp_clock_time.
+type clockTime struct {
+	sec  int64
+	nsec uint32
+	_    uint32
+}
+
+func (t clockTime) time() time.Time {
+	return time.Unix(t.sec, int64(t.nsec))
+}
+
+// sysOffset is struct ptp_sys_offset. ts holds system times and PHC times
+// in turn, starting and ending with a system time.
+type sysOffset struct {
+	nSamples uint32
+	_        [3]uint32
+	ts       [2*maxSamples + 1]clockTime
+}
+
+// Clock is a clock the kernel can read and adjust, a PHC or the system
+// clock.
+type Clock struct {
+	f  *os.File
+	id int32
+}
+
+// Realtime is the system clock.
+var Realtime = &Clock{id: unix.CLOCK_REALTIME}
+
+// Open opens the PHC at path, e.g. /dev/ptp0. It is opened for writing if
+// write is set, which setting and adjusting it needs.
+func Open(path string, write bool) (*Clock, error) {
+	flags := os.O_RDONLY
+	if write {
+		flags = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	// A dynamic clock ID is made from the file descriptor, as the
+	// kernel's FD_TO_CLOCKID does.
+	return &Clock{f: f, id: int32(^f.Fd()<<3 | 3)}, nil
+}
+
+// sysfs is where network interfaces are found, replaced in tests.
+var sysfs = "/sys/class/net"
+
+// InterfaceClock returns the path of the PHC of the network interface
+// iface, e.g. /dev/ptp0 for eth0.
+func InterfaceClock(iface string) (string, error) {
+	m, err := filepath.Glob(filepath.Join(sysfs, iface, "device", "ptp", "ptp*"))
+	if err != nil {
+		return "", err
+	}
+	if len(m) == 0 {
+		return "", fmt.Errorf("%s: %w", iface, os.ErrNotExist)
+	}
+	return filepath.Join("/dev", filepath.Base(m[0])), nil
+}
+
+// Close closes c. Closing Realtime does nothing.
+func (c *Clock) Close() error {
+	if c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
+
+// String returns the name of c.
+func (c *Clock) String() string {
+	if c.f == nil {
+		return "CLOCK_REALTIME"
+	}
+	return c.f.Name()
+}
+
+// Time returns the time of c.
+func (c *Clock) Time() (time.Time, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(c.id, &ts); err != nil {
+		return time.Time{}, fmt.Errorf("clock_gettime %v: %w", c, err)
+	}
+	return time.Unix(ts.Unix()), nil
+}
+
+// Set sets the time of c to t.
+func (c *Clock) Set(t time.Time) error {
+	ts := unix.NsecToTimespec(t.UnixNano())
+	_, _, errno := unix.Syscall(unix.SYS_CLOCK_SETTIME, uintptr(c.id), uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return fmt.Errorf("clock_settime %v: %w", c, errno)
+	}
+	return nil
+}
+
+// Step moves the time of c by d at once.
+func (c *Clock) Step(d time.Duration) error {
+	tx := unix.Timex{Modes: unix.ADJ_SETOFFSET, Time: unix.NsecToTimeval(d.Nanoseconds())}
+	if _, err := unix.ClockAdjtime(c.id, &tx); err != nil {
+		return fmt.Errorf("clock_adjtime %v: %w", c, err)
+	}
+	return nil
+}
+
+// setInt sets a field of unix.Timex, whose type depends on the
+// architecture.
+func setInt[T int32 | int64](p *T, v int64) {
+	*p = T(v)
+}
+
+// AdjustFrequency makes c run faster by ppb parts per billion, or slower
+// if ppb is negative.
+func (c *Clock) AdjustFrequency(ppb float64) error {
+	tx := unix.Timex{Modes: unix.ADJ_FREQUENCY}
+	// The frequency is in parts per million, with a 16 bit fraction.
+	setInt(&tx.Freq, int64(ppb*65.536))
+	if _, err := unix.ClockAdjtime(c.id, &tx); err != nil {
+		return fmt.Errorf("clock_adjtime %v: %w", c, err)
+	}
+	return nil
+}
+
+// Caps returns the capabilities of the PHC c.
+func (c *Clock) Caps() (Caps, error) {
+	if c.f == nil {
+		return Caps{}, fmt.Errorf("%v: %w", c, unix.ENOTTY)
+	}
+	var cc clockCaps
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, c.f.Fd(), ptpClockGetcaps, uintptr(unsafe.Pointer(&cc))); errno != 0 {
+		return Caps{}, fmt.Errorf("PTP_CLOCK_GETCAPS %v: %w", c, errno)
+	}
+	return Caps{
+		MaxAdj:            int(cc.maxAdj),
+		Alarms:            int(cc.nAlarm),
+		ExtTimestamps:     int(cc.nExtTs),
+		PeriodicOutputs:   int(cc.nPerOut),
+		PPS:               cc.pps != 0,
+		Pins:              int(cc.nPins),
+		CrossTimestamping: cc.crossTimestamping != 0,
+	}, nil
+}
+
+// samples reads c and the system clock n times. PHCs are read by the
+// kernel with PTP_SYS_OFFSET, which is most precise; if that fails, they
+// are read with clock_gettime.
+func (c *Clock) samples(n int) ([]sample, error) {
+	n = max(1, min(n, maxSamples))
+	if c.f != nil {
+		so := sysOffset{nSamples: uint32(n)}
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, c.f.Fd(), ptpSysOffset, uintptr(unsafe.Pointer(&so)))
+		if errno == 0 {
+			s := make([]sample, n)
+			for i := range s {
+				s[i] = sample{before: so.ts[2*i].time(), clock: so.ts[2*i+1].time(), after: so.ts[2*i+2].time()}
+			}
+			return s, nil
+		}
+		if !errors.Is(errno, unix.ENOTTY) && !errors.Is(errno, unix.EOPNOTSUPP) {
+			return nil, fmt.Errorf("PTP_SYS_OFFSET %v: %w", c, errno)
+		}
+	}
+
+	s := make([]sample, n)
+	for i := range s {
+		var ts [3]unix.Timespec
+		for j, id := range []int32{unix.CLOCK_REALTIME, c.id, unix.CLOCK_REALTIME} {
+			if err := unix.ClockGettime(id, &ts[j]); err != nil {
+				return nil, fmt.Errorf("clock_gettime %v: %w", c, err)
+			}
+		}
+		s[i] = sample{before: time.Unix(ts[0].Unix()), clock: time.Unix(ts[1].Unix()), after: time.Unix(ts[2].Unix())}
+	}
+	return s, nil
+}
+
+// Offset reads c and the system clock n times, up to 25, and returns by
+// how much the system clock is ahead of c, measured in the reading with
+// the shortest delay, and that delay.
+func (c *Clock) Offset(n int) (offset, delay time.Duration, err error) {
+	s, err := c.samples(n)
+	if err != nil {
+		return 0, 0, err
+	}
+	return bestOffset(s)
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ptp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestStructSizes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"ptp_clock_caps", unsafe.Sizeof(clockCaps{}), 80},
+		{"ptp_sys_offset", unsafe.Sizeof(sysOffset{}), 832},
+		{"PTP_CLOCK_GETCAPS", ptpClockGetcaps, 0x80503d01},
+		{"PTP_SYS_OFFSET", ptpSysOffset, 0x43403d05},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %#x, want %#x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRealtime(t *testing.T) {
+	now, err := Realtime.Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(now); d < 0 || d > time.Minute {
+		t.Errorf("Realtime.Time() = %v, %v from now", now, d)
+	}
+
+	// The system clock is its own reference.
+	offset, delay, err := Realtime.Offset(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay < 0 || delay > time.Second || offset > delay || offset < -delay {
+		t.Errorf("Realtime.Offset(5) = %v, %v, want about 0", offset, delay)
+	}
+	if _, err := Realtime.Caps(); err == nil {
+		t.Errorf("Realtime.Caps() = nil, want an error")
+	}
+}
+
+func TestInterfaceClock(t *testing.T) {
+	sysfs = t.TempDir()
+	defer func() { sysfs = "/sys/class/net" }()
+	if err := os.MkdirAll(filepath.Join(sysfs, "eth0", "device", "ptp", "ptp1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(sysfs, "lo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := InterfaceClock("eth0"); err != nil || got != "/dev/ptp1" {
+		t.Errorf("InterfaceClock(eth0) = %q, %v, want /dev/ptp1, nil", got, err)
+	}
+	if _, err := InterfaceClock("lo"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("InterfaceClock(lo) = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ptp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBestOffset(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(d time.Duration) time.Time { return base.Add(d) }
+	for _, tt := range []struct {
+		name      string
+		samples   []sample
+		offset    time.Duration
+		delay     time.Duration
+		wantError error
+	}{
+		{
+			name:      "none",
+			wantError: ErrNoSamples,
+		},
+		{
+			name:    "one",
+			samples: []sample{{before: at(0), clock: at(-time.Second), after: at(2 * time.Microsecond)}},
+			offset:  time.Second + time.Microsecond,
+			delay:   2 * time.Microsecond,
+		},
+		{
+			name: "shortest delay",
+			samples: []sample{
+				{before: at(0), clock: at(10 * time.Microsecond), after: at(10 * time.Microsecond)},
+				{before: at(20 * time.Microsecond), clock: at(21 * time.Microsecond), after: at(22 * time.Microsecond)},
+				{before: at(30 * time.Microsecond), clock: at(29 * time.Microsecond), after: at(34 * time.Microsecond)},
+			},
+			offset: 0,
+			delay:  2 * time.Microsecond,
+		},
+		{
+			name: "backwards",
+			samples: []sample{
+				{before: at(time.Second), clock: at(0), after: at(0)},
+			},
+			wantError: ErrNoSamples,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, delay, err := bestOffset(tt.samples)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("bestOffset() = %v, want %v", err, tt.wantError)
+			}
+			if offset != tt.offset || delay != tt.delay {
+				t.Errorf("bestOffset() = %v, %v, want %v, %v", offset, delay, tt.offset, tt.delay)
+			}
+		})
+	}
+}
+
+func TestServo(t *testing.T) {
+	s := NewServo()
+	s.Step = time.Millisecond
+
+	if freq, step := s.Sample(3*time.Second, time.Second); !step || freq != 0 {
+		t.Errorf("first Sample() = %v, %v, want 0, true", freq, step)
+	}
+	// 1µs ahead over 1s is 1000 ppb too fast: slow down by
+	// 0.7*1000 + 0.3*1000.
+	if freq, step := s.Sample(time.Microsecond, time.Second); step || freq != -1000 {
+		t.Errorf("Sample(1µs) = %v, %v, want -1000, false", freq, step)
+	}
+	// On time, only the drift is left.
+	if freq, step := s.Sample(0, time.Second); step || freq != -300 {
+		t.Errorf("Sample(0) = %v, %v, want -300, false", freq, step)
+	}
+	// The interval scales the error.
+	if freq, step := s.Sample(-time.Microsecond, 2*time.Second); step || freq != 200 {
+		t.Errorf("Sample(-1µs, 2s) = %v, %v, want 200, false", freq, step)
+	}
+	if freq, step := s.Sample(-2*time.Millisecond, time.Second); !step || freq != -150 {
+		t.Errorf("Sample(-2ms) = %v, %v, want -150, true", freq, step)
+	}
+	if freq, _ := s.Sample(time.Millisecond, time.Second); freq != -s.MaxFreq {
+		t.Errorf("Sample(1ms) = %v, want %v", freq, -s.MaxFreq)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package redfish implements a client for the DMTF Redfish API of BMCs.
+//
+// The client handles sessions and errors; resources are read and written as
+// JSON with Get, Patch and Post, into the schema types of this package or
+// any other type. See https://www.dmtf.org/standards/redfish.
+package redfish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ServiceRootPath is the path of the Redfish service root.
+const ServiceRootPath = "/redfish/v1"
+
+const sessionsPath = ServiceRootPath + "/SessionService/Sessions"
+
+// Error is a Redfish error response.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("redfish: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("redfish: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error struct {
+		Code         string `json:"code"`
+		Message      string `json:"message"`
+		ExtendedInfo []struct {
+			Message string
+		} `json:"@Message.ExtendedInfo"`
+	} `json:"error"`
+}
+
+// Client is a Redfish client. Requests use a session when Login succeeded,
+// and HTTP basic authentication with Username and Password otherwise.
+type Client struct {
+	// Endpoint is the BMC's URL, e.g. https://10.0.0.2.
+	Endpoint *url.URL
+
+	Username string
+	Password string
+
+	// HTTPClient is used for requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	token   string
+	session string
+}
+
+// NewClient returns a client of the Redfish service at endpoint.
+func NewClient(endpoint, username, password string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("redfish endpoint %q: scheme must be http or https", endpoint)
+	}
+	return &Client{Endpoint: u, Username: username, Password: password}, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// url resolves the path of a resource, e.g. /redfish/v1/Systems/1.
+func (c *Client) url(path string) string {
+	return c.Endpoint.ResolveReference(&url.URL{Path: path}).String()
+}
+
+// do sends a request with body as JSON, and decodes the response into v if
+// it is not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, v any) (http.Header, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("OData-Version", "4.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.token != "":
+		req.Header.Set("X-Auth-Token", c.token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		e := &Error{StatusCode: resp.StatusCode}
+		var er errorResponse
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&er) == nil {
+			e.Code, e.Message = er.Error.Code, er.Error.Message
+			if len(er.Error.ExtendedInfo) > 0 {
+				e.Message = er.Error.ExtendedInfo[0].Message
+			}
+		}
+		return nil, fmt.Errorf("%s %s: %w", method, path, e)
+	}
+	if v != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// Get reads the resource at path into v.
+func (c *Client) Get(ctx context.Context, path string, v any) error {
+	_, err := c.do(ctx, http.MethodGet, path, nil, v)
+	return err
+}
+
+// Patch updates the properties of the resource at path from body.
+func (c *Client) Patch(ctx context.Context, path string, body any) error {
+	_, err := c.do(ctx, http.MethodPatch, path, body, nil)
+	return err
+}
+
+// Post posts body to path, e.g. to run an action or create a resource, and
+// decodes the response into v if it is not nil.
+func (c *Client) Post(ctx context.Context, path string, body, v any) error {
+	_, err := c.do(ctx, http.MethodPost, path, body, v)
+	return err
+}
+
+// Login creates a session, which is used instead of basic authentication by
+// later requests until Logout.
+func (c *Client) Login(ctx context.Context) error {
+	if c.token != "" {
+		return nil
+	}
+	h, err := c.do(ctx, http.MethodPost, sessionsPath, map[string]string{
+		"UserName": c.Username,
+		"Password": c.Password,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	token := h.Get("X-Auth-Token")
+	if token == "" {
+		return errors.New("redfish: session has no X-Auth-Token")
+	}
+	c.token = token
+	// The Location is the session to delete on logout. It may be a
+	// full URL or a path.
+	if loc, err := url.Parse(h.Get("Location")); err == nil {
+		c.session = loc.Path
+	}
+	return nil
+}
+
+// Logout deletes the session created by Login.
+func (c *Client) Logout(ctx context.Context) error {
+	if c.token == "" {
+		return nil
+	}
+	var err error
+	if strings.HasPrefix(c.session, sessionsPath+"/") {
+		_, err = c.do(ctx, http.MethodDelete, c.session, nil, nil)
+	}
+	c.token, c.session = "", ""
+	return err
+}
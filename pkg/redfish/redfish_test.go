@@ -0,0 +1,215 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redfish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bmc is a Redfish service with one system and one chassis.
+type bmc struct {
+	mu       sync.Mutex
+	power    string
+	boot     Boot
+	sessions map[string]bool
+	resets   []string
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":                  "Base.1.8.GeneralError",
+			"message":               "A general error has occurred.",
+			"@Message.ExtendedInfo": []map[string]string{{"Message": msg}},
+		},
+	})
+}
+
+func (b *bmc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.Method == http.MethodPost && r.URL.Path == sessionsPath {
+		var creds struct{ UserName, Password string }
+		if json.NewDecoder(r.Body).Decode(&creds) != nil || creds.UserName != "admin" || creds.Password != "secret" {
+			writeError(w, http.StatusUnauthorized, "Invalid credentials.")
+			return
+		}
+		b.sessions["token-1"] = true
+		w.Header().Set("X-Auth-Token", "token-1")
+		w.Header().Set("Location", "http://"+r.Host+sessionsPath+"/1")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	user, pass, basic := r.BasicAuth()
+	if !b.sessions[r.Header.Get("X-Auth-Token")] && !(basic && user == "admin" && pass == "secret") {
+		writeError(w, http.StatusUnauthorized, "Authentication required.")
+		return
+	}
+
+	var v any
+	switch r.Method + " " + r.URL.Path {
+	case "GET /redfish/v1":
+		v = map[string]any{"RedfishVersion": "1.15.0", "Systems": Link{"/redfish/v1/Systems"}}
+	case "GET /redfish/v1/Systems":
+		v = map[string]any{"Members": []Link{{"/redfish/v1/Systems/1"}}}
+	case "GET /redfish/v1/Systems/1":
+		v = map[string]any{
+			"@odata.id":    "/redfish/v1/Systems/1",
+			"Id":           "1",
+			"Name":         "System",
+			"SerialNumber": "S123",
+			"PowerState":   b.power,
+			"Boot": map[string]any{
+				"BootSourceOverrideEnabled":                        b.boot.BootSourceOverrideEnabled,
+				"BootSourceOverrideTarget":                         b.boot.BootSourceOverrideTarget,
+				"BootSourceOverrideTarget@Redfish.AllowableValues": []string{"None", "Pxe", "Hdd"},
+			},
+			"ProcessorSummary": map[string]any{"Count": 2, "Model": "Xeon"},
+			"Actions": map[string]any{
+				"#ComputerSystem.Reset": map[string]any{
+					"target":                            "/redfish/v1/Systems/1/Actions/ComputerSystem.Reset",
+					"ResetType@Redfish.AllowableValues": []string{"On", "ForceOff", "ForceRestart"},
+				},
+			},
+		}
+	case "PATCH /redfish/v1/Systems/1":
+		var p struct{ Boot Boot }
+		if json.NewDecoder(r.Body).Decode(&p) != nil {
+			writeError(w, http.StatusBadRequest, "Malformed JSON.")
+			return
+		}
+		b.boot = p.Boot
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "POST /redfish/v1/Systems/1/Actions/ComputerSystem.Reset":
+		var p struct{ ResetType string }
+		json.NewDecoder(r.Body).Decode(&p)
+		b.resets = append(b.resets, p.ResetType)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "GET /redfish/v1/Chassis":
+		v = map[string]any{"Members": []Link{{"/redfish/v1/Chassis/1"}}}
+	case "GET /redfish/v1/Chassis/1":
+		v = map[string]any{"@odata.id": "/redfish/v1/Chassis/1", "Id": "1", "ChassisType": "RackMount", "PartNumber": "P-1"}
+	case "DELETE " + sessionsPath + "/1":
+		delete(b.sessions, "token-1")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, http.StatusNotFound, "The resource "+r.URL.Path+" was not found.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func newBMC(t *testing.T) (*bmc, *Client) {
+	b := &bmc{power: "Off", sessions: map[string]bool{}}
+	s := httptest.NewServer(b)
+	t.Cleanup(s.Close)
+	c, err := NewClient(s.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, c
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	b, c := newBMC(t)
+
+	// Basic authentication works without a session.
+	root, err := c.ServiceRoot(ctx)
+	if err != nil || root.RedfishVersion != "1.15.0" || root.Systems.ODataID != "/redfish/v1/Systems" {
+		t.Fatalf("ServiceRoot = %+v, %v", root, err)
+	}
+
+	if err := c.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.Password = "forgotten"
+	if _, err := c.ServiceRoot(ctx); err != nil {
+		t.Errorf("ServiceRoot with session = %v", err)
+	}
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.sessions) != 0 {
+		t.Errorf("sessions after Logout = %v", b.sessions)
+	}
+
+	var e *Error
+	if err := c.Login(ctx); !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized || e.Message != "Invalid credentials." {
+		t.Errorf("Login with wrong password = %v, want 401 error", err)
+	}
+	if _, err := c.ServiceRoot(ctx); !errors.As(err, &e) || !strings.Contains(err.Error(), "401 Unauthorized: Authentication required.") {
+		t.Errorf("ServiceRoot with wrong password = %v", err)
+	}
+
+	if _, err := NewClient("ftp://bmc", "", ""); err == nil {
+		t.Errorf("NewClient(ftp://bmc) = nil, want error")
+	}
+}
+
+func TestSystem(t *testing.T) {
+	ctx := context.Background()
+	b, c := newBMC(t)
+	if err := c.Login(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.System(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != "1" || s.SerialNumber != "S123" || s.PowerState != "Off" || s.ProcessorSummary.Count != 2 {
+		t.Errorf("System = %+v", s)
+	}
+
+	if err := c.Reset(ctx, s, ResetForceRestart); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reset(ctx, s, ResetPowerCycle); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Reset(PowerCycle) = %v, want %v", err, ErrNotAllowed)
+	}
+	if !reflect.DeepEqual(b.resets, []string{ResetForceRestart}) {
+		t.Errorf("resets = %q", b.resets)
+	}
+
+	if err := c.SetBoot(ctx, s, BootTargetPxe, BootOnce, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Boot{BootSourceOverrideEnabled: BootOnce, BootSourceOverrideTarget: BootTargetPxe}); !reflect.DeepEqual(b.boot, want) {
+		t.Errorf("boot = %+v, want %+v", b.boot, want)
+	}
+	if err := c.SetBoot(ctx, s, BootTargetUsb, BootOnce, ""); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("SetBoot(Usb) = %v, want %v", err, ErrNotAllowed)
+	}
+	if s, err = c.System(ctx, "1"); err != nil || s.Boot.BootSourceOverrideTarget != BootTargetPxe {
+		t.Errorf("System(1) = %+v, %v", s, err)
+	}
+	if _, err := c.System(ctx, "2"); err == nil {
+		t.Errorf("System(2) = nil, want error")
+	}
+
+	chassis, err := c.Chassis(ctx)
+	if err != nil || len(chassis) != 1 || chassis[0].ChassisType != "RackMount" || chassis[0].PartNumber != "P-1" {
+		t.Errorf("Chassis = %+v, %v", chassis, err)
+	}
+
+	if err := c.Reset(ctx, &ComputerSystem{ODataID: "/x"}, ResetOn); !errors.Is(err, ErrNoResetAction) {
+		t.Errorf("Reset without action = %v, want %v", err, ErrNoResetAction)
+	}
+}
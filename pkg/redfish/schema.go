@@ -0,0 +1,226 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redfish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrNoResetAction is returned for systems which cannot be reset.
+var ErrNoResetAction = errors.New("system has no reset action")
+
+// ErrNotAllowed is returned for values which a property or action does not
+// allow.
+var ErrNotAllowed = errors.New("value not allowed")
+
+// Link is a reference to a resource.
+type Link struct {
+	ODataID string `json:"@odata.id"`
+}
+
+// Collection is a resource collection.
+type Collection struct {
+	Name    string
+	Members []Link
+}
+
+// Status is the status of a resource.
+type Status struct {
+	State  string `json:",omitempty"`
+	Health string `json:",omitempty"`
+}
+
+// ServiceRoot is the root of a Redfish service.
+type ServiceRoot struct {
+	RedfishVersion string
+	UUID           string
+	Systems        Link
+	Chassis        Link
+	Managers       Link
+	SessionService Link
+}
+
+// Reset types of the ComputerSystem.Reset action.
+const (
+	ResetOn               = "On"
+	ResetForceOff         = "ForceOff"
+	ResetGracefulShutdown = "GracefulShutdown"
+	ResetGracefulRestart  = "GracefulRestart"
+	ResetForceRestart     = "ForceRestart"
+	ResetPowerCycle       = "PowerCycle"
+	ResetNmi              = "Nmi"
+)
+
+// Boot source override targets and enablement.
+const (
+	BootTargetNone      = "None"
+	BootTargetPxe       = "Pxe"
+	BootTargetHdd       = "Hdd"
+	BootTargetCd        = "Cd"
+	BootTargetUsb       = "Usb"
+	BootTargetBiosSetup = "BiosSetup"
+	BootTargetUefiHTTP  = "UefiHttp"
+
+	BootOnce       = "Once"
+	BootContinuous = "Continuous"
+	BootDisabled   = "Disabled"
+)
+
+// Boot is the boot override of a system.
+type Boot struct {
+	BootSourceOverrideEnabled string `json:",omitempty"`
+	BootSourceOverrideTarget  string `json:",omitempty"`
+	// BootSourceOverrideMode is Legacy or UEFI.
+	BootSourceOverrideMode string `json:",omitempty"`
+
+	AllowableTargets []string `json:"BootSourceOverrideTarget@Redfish.AllowableValues,omitempty"`
+}
+
+// ComputerSystem is a system, usually the host managed by the BMC.
+type ComputerSystem struct {
+	ODataID      string `json:"@odata.id"`
+	ID           string `json:"Id"`
+	Name         string
+	Manufacturer string
+	Model        string
+	SerialNumber string
+	UUID         string
+	BiosVersion  string
+	PowerState   string
+	Status       Status
+	Boot         Boot
+
+	ProcessorSummary struct {
+		Count int
+		Model string
+	}
+	MemorySummary struct {
+		TotalSystemMemoryGiB float64
+	}
+
+	Actions struct {
+		Reset struct {
+			Target          string   `json:"target"`
+			AllowableValues []string `json:"ResetType@Redfish.AllowableValues"`
+		} `json:"#ComputerSystem.Reset"`
+	}
+}
+
+// Chassis is a physical enclosure.
+type Chassis struct {
+	ODataID      string `json:"@odata.id"`
+	ID           string `json:"Id"`
+	Name         string
+	ChassisType  string
+	Manufacturer string
+	Model        string
+	SerialNumber string
+	PartNumber   string
+	AssetTag     string
+	PowerState   string
+	Status       Status
+}
+
+// Manager is a BMC.
+type Manager struct {
+	ODataID         string `json:"@odata.id"`
+	ID              string `json:"Id"`
+	Name            string
+	ManagerType     string
+	Model           string
+	FirmwareVersion string
+	Status          Status
+}
+
+// members reads the collection at path, and each member into a new T.
+func members[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var coll Collection
+	if err := c.Get(ctx, path, &coll); err != nil {
+		return nil, err
+	}
+	l := make([]T, len(coll.Members))
+	for i, m := range coll.Members {
+		if err := c.Get(ctx, m.ODataID, &l[i]); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// ServiceRoot reads the service root.
+func (c *Client) ServiceRoot(ctx context.Context) (*ServiceRoot, error) {
+	var r ServiceRoot
+	if err := c.Get(ctx, ServiceRootPath, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Systems reads the systems.
+func (c *Client) Systems(ctx context.Context) ([]ComputerSystem, error) {
+	return members[ComputerSystem](ctx, c, ServiceRootPath+"/Systems")
+}
+
+// Chassis reads the chassis.
+func (c *Client) Chassis(ctx context.Context) ([]Chassis, error) {
+	return members[Chassis](ctx, c, ServiceRootPath+"/Chassis")
+}
+
+// Managers reads the managers.
+func (c *Client) Managers(ctx context.Context) ([]Manager, error) {
+	return members[Manager](ctx, c, ServiceRootPath+"/Managers")
+}
+
+// System reads the system with the given ID, or the only system if id is
+// empty.
+func (c *Client) System(ctx context.Context, id string) (*ComputerSystem, error) {
+	if id != "" {
+		var s ComputerSystem
+		if err := c.Get(ctx, ServiceRootPath+"/Systems/"+id, &s); err != nil {
+			return nil, err
+		}
+		return &s, nil
+	}
+	var coll Collection
+	if err := c.Get(ctx, ServiceRootPath+"/Systems", &coll); err != nil {
+		return nil, err
+	}
+	if len(coll.Members) != 1 {
+		return nil, fmt.Errorf("service has %d systems, give one", len(coll.Members))
+	}
+	var s ComputerSystem
+	if err := c.Get(ctx, coll.Members[0].ODataID, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Reset resets the system with a reset type, e.g. ResetForceRestart.
+func (c *Client) Reset(ctx context.Context, s *ComputerSystem, resetType string) error {
+	r := s.Actions.Reset
+	if r.Target == "" {
+		return fmt.Errorf("%s: %w", s.ODataID, ErrNoResetAction)
+	}
+	if len(r.AllowableValues) > 0 && !slices.Contains(r.AllowableValues, resetType) {
+		return fmt.Errorf("reset type %q, allowed are %q: %w", resetType, r.AllowableValues, ErrNotAllowed)
+	}
+	return c.Post(ctx, r.Target, map[string]string{"ResetType": resetType}, nil)
+}
+
+// SetBoot sets the boot source override of the system, e.g. to boot from
+// BootTargetPxe once. An empty mode keeps the current mode.
+func (c *Client) SetBoot(ctx context.Context, s *ComputerSystem, target, enabled, mode string) error {
+	if t := s.Boot.AllowableTargets; len(t) > 0 && !slices.Contains(t, target) {
+		return fmt.Errorf("boot target %q, allowed are %q: %w", target, t, ErrNotAllowed)
+	}
+	return c.Patch(ctx, s.ODataID, struct{ Boot Boot }{Boot{
+		BootSourceOverrideEnabled: enabled,
+		BootSourceOverrideTarget:  target,
+		BootSourceOverrideMode:    mode,
+	}})
+}
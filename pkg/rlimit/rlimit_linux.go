@@ -0,0 +1,162 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rlimit gets and sets the resource limits of processes, and
+// parses and formats them for prlimit and the ulimit shell builtin.
+package rlimit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Infinity is the value of a limit which is not enforced.
+const Infinity = unix.RLIM_INFINITY
+
+// ErrBadLimit is returned for limits which cannot be parsed.
+var ErrBadLimit = errors.New("invalid limit")
+
+// Resource describes a resource limit, RLIMIT_*.
+type Resource struct {
+	// Name is the name used by prlimit, such as nofile.
+	Name string
+	// Resource is the RLIMIT_* value.
+	Resource int
+	// Option is the ulimit option letter.
+	Option byte
+	// Scale is the bytes in a ulimit unit, or 1.
+	Scale uint64
+	// Units is what the limit counts, for prlimit.
+	Units string
+	// Description is what ulimit -a and prlimit call the limit.
+	Description string
+	// ULimitUnits is the unit ulimit -a shows.
+	ULimitUnits string
+}
+
+// Resources are the limits Linux knows of.
+var Resources = []Resource{
+	{"as", unix.RLIMIT_AS, 'v', 1024, "bytes", "address space limit", "kbytes"},
+	{"core", unix.RLIMIT_CORE, 'c', 1024, "bytes", "max core file size", "blocks"},
+	{"cpu", unix.RLIMIT_CPU, 't', 1, "seconds", "CPU time", "seconds"},
+	{"data", unix.RLIMIT_DATA, 'd', 1024, "bytes", "max data size", "kbytes"},
+	{"fsize", unix.RLIMIT_FSIZE, 'f', 1024, "bytes", "max file size", "blocks"},
+	{"locks", unix.RLIMIT_LOCKS, 'x', 1, "locks", "max number of file locks held", ""},
+	{"memlock", unix.RLIMIT_MEMLOCK, 'l', 1024, "bytes", "max locked-in-memory address space", "kbytes"},
+	{"msgqueue", unix.RLIMIT_MSGQUEUE, 'q', 1, "bytes", "max bytes in POSIX mqueues", "bytes"},
+	{"nice", unix.RLIMIT_NICE, 'e', 1, "", "max nice prio allowed to raise", ""},
+	{"nofile", unix.RLIMIT_NOFILE, 'n', 1, "files", "max number of open files", ""},
+	{"nproc", unix.RLIMIT_NPROC, 'u', 1, "processes", "max number of processes", ""},
+	{"rss", unix.RLIMIT_RSS, 'm', 1024, "bytes", "max resident set size", "kbytes"},
+	{"rtprio", unix.RLIMIT_RTPRIO, 'r', 1, "", "max real-time priority", ""},
+	{"rttime", unix.RLIMIT_RTTIME, 'R', 1, "microsecs", "timeout for real-time tasks", "microseconds"},
+	{"sigpending", unix.RLIMIT_SIGPENDING, 'i', 1, "signals", "max number of pending signals", ""},
+	{"stack", unix.RLIMIT_STACK, 's', 1024, "bytes", "max stack size", "kbytes"},
+}
+
+// ByName returns the resource with a prlimit name.
+func ByName(name string) (Resource, bool) {
+	for _, r := range Resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// ByOption returns the resource with a ulimit option letter.
+func ByOption(c byte) (Resource, bool) {
+	for _, r := range Resources {
+		if r.Option == c {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// self returns whether pid is this process.
+func self(pid int) bool {
+	return pid == 0 || pid == os.Getpid()
+}
+
+// Get returns the limit of process pid, or of this one if pid is 0.
+//
+// Note that the Go runtime raises the soft RLIMIT_NOFILE of its own
+// process to the hard limit, and restores it for the processes it starts
+// unless it was set with Set.
+func Get(pid, resource int) (unix.Rlimit, error) {
+	var l unix.Rlimit
+	err := unix.Prlimit(pid, resource, nil, &l)
+	return l, err
+}
+
+// Set sets the limit of process pid, or of this one if pid is 0. Setting
+// another process's limits needs CAP_SYS_RESOURCE, or the same user IDs.
+func Set(pid, resource int, l unix.Rlimit) error {
+	if self(pid) {
+		// syscall.Setrlimit tells the runtime that RLIMIT_NOFILE
+		// must not be restored for new processes.
+		return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: l.Cur, Max: l.Max})
+	}
+	return unix.Prlimit(pid, resource, &l, nil)
+}
+
+// ParseValue parses a limit in units of scale bytes, or "unlimited".
+func ParseValue(s string, scale uint64) (uint64, error) {
+	switch s {
+	case "unlimited", "infinity":
+		return Infinity, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrBadLimit, s)
+	}
+	if scale > 1 && v > (Infinity-1)/scale {
+		return 0, fmt.Errorf("%w %q: too large", ErrBadLimit, s)
+	}
+	return v * scale, nil
+}
+
+// FormatValue formats a limit in units of scale bytes.
+func FormatValue(v, scale uint64) string {
+	if v == Infinity {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v/max(scale, 1), 10)
+}
+
+// ParseLimits parses limits as prlimit does: SOFT:HARD, SOFT: or :HARD to
+// change just one, or a single value for both. The limits not given are
+// those of old.
+func ParseLimits(s string, old unix.Rlimit) (unix.Rlimit, error) {
+	l := old
+	soft, hard, both := strings.Cut(s, ":")
+	if !both {
+		hard = soft
+	}
+	if soft == "" && hard == "" {
+		return l, fmt.Errorf("%w %q", ErrBadLimit, s)
+	}
+	var err error
+	if soft != "" {
+		if l.Cur, err = ParseValue(soft, 1); err != nil {
+			return l, err
+		}
+	}
+	if hard != "" {
+		if l.Max, err = ParseValue(hard, 1); err != nil {
+			return l, err
+		}
+	}
+	if l.Cur > l.Max {
+		return l, fmt.Errorf("%w %q: the soft limit is above the hard one", ErrBadLimit, s)
+	}
+	return l, nil
+}
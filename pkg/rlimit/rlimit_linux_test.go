@@ -0,0 +1,97 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rlimit
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLookup(t *testing.T) {
+	names, options := map[string]bool{}, map[byte]bool{}
+	for _, r := range Resources {
+		if names[r.Name] || options[r.Option] {
+			t.Errorf("%s: duplicate name or option %c", r.Name, r.Option)
+		}
+		names[r.Name], options[r.Option] = true, true
+	}
+	if r, ok := ByName("nofile"); !ok || r.Resource != unix.RLIMIT_NOFILE || r.Option != 'n' {
+		t.Errorf("ByName(nofile) = %+v, %v", r, ok)
+	}
+	if r, ok := ByOption('c'); !ok || r.Name != "core" || r.Scale != 1024 {
+		t.Errorf("ByOption(c) = %+v, %v", r, ok)
+	}
+	if _, ok := ByName("files"); ok {
+		t.Errorf("ByName(files) found a resource")
+	}
+}
+
+func TestValues(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		scale uint64
+		v     uint64
+		out   string
+		err   error
+	}{
+		{in: "1024", scale: 1, v: 1024, out: "1024"},
+		{in: "8", scale: 1024, v: 8192, out: "8"},
+		{in: "unlimited", scale: 1024, v: Infinity, out: "unlimited"},
+		{in: "infinity", scale: 1, v: Infinity, out: "unlimited"},
+		{in: "-1", scale: 1, err: ErrBadLimit},
+		{in: "lots", scale: 1, err: ErrBadLimit},
+		{in: "18446744073709551615", scale: 1024, err: ErrBadLimit},
+	} {
+		v, err := ParseValue(tt.in, tt.scale)
+		if !errors.Is(err, tt.err) || v != tt.v {
+			t.Errorf("ParseValue(%q, %d) = %d, %v, want %d, %v", tt.in, tt.scale, v, err, tt.v, tt.err)
+		}
+		if err == nil {
+			if got := FormatValue(v, tt.scale); got != tt.out {
+				t.Errorf("FormatValue(%d, %d) = %q, want %q", v, tt.scale, got, tt.out)
+			}
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	old := unix.Rlimit{Cur: 1024, Max: 4096}
+	for _, tt := range []struct {
+		in   string
+		want unix.Rlimit
+		err  error
+	}{
+		{in: "2048", want: unix.Rlimit{Cur: 2048, Max: 2048}},
+		{in: "512:", want: unix.Rlimit{Cur: 512, Max: 4096}},
+		{in: ":2048", want: unix.Rlimit{Cur: 1024, Max: 2048}},
+		{in: "10:unlimited", want: unix.Rlimit{Cur: 10, Max: Infinity}},
+		{in: ":", err: ErrBadLimit},
+		{in: "8192:", err: ErrBadLimit},
+		{in: "x:1", err: ErrBadLimit},
+	} {
+		got, err := ParseLimits(tt.in, old)
+		if !errors.Is(err, tt.err) || (err == nil && got != tt.want) {
+			t.Errorf("ParseLimits(%q) = %+v, %v, want %+v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	l, err := Get(0, unix.RLIMIT_CORE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Set(0, unix.RLIMIT_CORE, l)
+	// Lowering the soft limit is always allowed.
+	want := unix.Rlimit{Cur: 0, Max: l.Max}
+	if err := Set(0, unix.RLIMIT_CORE, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Get(0, unix.RLIMIT_CORE); err != nil || got != want {
+		t.Errorf("Get = %+v, %v, want %+v, nil", got, err, want)
+	}
+}
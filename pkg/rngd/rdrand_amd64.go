@@ -0,0 +1,53 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rngd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/cpu"
+)
+
+var errRDRAND = errors.New("RDRAND failed")
+
+func rdrand() (uint64, bool)
+
+// rdrandRetries is how often RDRAND is retried, as Intel recommends.
+const rdrandRetries = 10
+
+type rdrandSource struct{}
+
+// RDRAND returns the CPU's RNG, if it has one.
+func RDRAND() (Source, error) {
+	if !cpu.X86.HasRDRAND {
+		return nil, fmt.Errorf("%w: CPU has no RDRAND", ErrNoSource)
+	}
+	return rdrandSource{}, nil
+}
+
+func (rdrandSource) Read(b []byte) (int, error) {
+	var w [8]byte
+	for n := 0; n < len(b); {
+		v, ok := rdrand()
+		for i := 0; !ok && i < rdrandRetries; i++ {
+			v, ok = rdrand()
+		}
+		if !ok {
+			return n, errRDRAND
+		}
+		binary.LittleEndian.PutUint64(w[:], v)
+		n += copy(b[n:], w[:])
+	}
+	return len(b), nil
+}
+
+func (rdrandSource) Close() error { return nil }
+func (rdrandSource) Name() string { return "rdrand" }
+
+// Credit is half, as RDRAND is the output of a DRBG rather than of the
+// noise source itself.
+func (rdrandSource) Credit() int { return 512 }
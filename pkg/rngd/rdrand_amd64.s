@@ -0,0 +1,12 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func rdrand() (uint64, bool)
+TEXT ·rdrand(SB), NOSPLIT, $0-9
+	RDRANDQ AX
+	MOVQ AX, ret+0(FP)
+	SETCS ret1+8(FP)
+	RET
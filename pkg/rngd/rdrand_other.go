@@ -0,0 +1,14 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !amd64
+
+package rngd
+
+import "fmt"
+
+// RDRAND returns the CPU's RNG, which only x86 CPUs have.
+func RDRAND() (Source, error) {
+	return nil, fmt.Errorf("%w: no RDRAND on this architecture", ErrNoSource)
+}
@@ -0,0 +1,207 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rngd feeds the kernel's entropy pool from hardware random number
+// generators, as rngd from rng-tools does, so that getrandom(2), and with it
+// TLS and SSH, does not block early in boot on machines with little entropy.
+//
+// Sources are RDRAND on x86, hardware RNGs such as virtio-rng behind
+// /dev/hwrng, and TPM 2.0 chips. Each block read is checked for the output
+// of a stuck source before it is added, and sources which keep failing are
+// dropped.
+package rngd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// DefaultBlockSize is how many bytes are added to the pool at once.
+const DefaultBlockSize = 64
+
+// maxFailures is how many blocks in a row a source may fail before it is
+// dropped.
+const maxFailures = 8
+
+var (
+	// ErrNoSource is returned when no source is available, or all failed.
+	ErrNoSource = errors.New("no random number source")
+	// ErrHealth is returned for blocks which fail the health check.
+	ErrHealth = errors.New("source failed health check")
+)
+
+// Source is a source of random bytes.
+type Source interface {
+	io.ReadCloser
+
+	// Name names the source in logs.
+	Name() string
+	// Credit is how many bits of entropy are credited per 1024 bits
+	// read, as the quality of the kernel's hardware RNGs.
+	Credit() int
+}
+
+// Options configures feeding the pool.
+type Options struct {
+	// Sources are read in turn.
+	Sources []Source
+	// BlockSize is how many bytes are added at once, DefaultBlockSize if 0.
+	BlockSize int
+	// Threshold is how many bits of entropy the pool is filled up to, the
+	// kernel's write_wakeup_threshold if 0.
+	Threshold int
+	// Interval is how often the pool is fed even if the kernel does not
+	// ask for entropy, a minute if 0.
+	Interval time.Duration
+	// Logf logs, log.Printf if nil.
+	Logf func(format string, args ...any)
+}
+
+// pool is the kernel's entropy pool.
+type pool interface {
+	// avail returns the bits of entropy in the pool.
+	avail() (int, error)
+	// add adds b to the pool, crediting bits of entropy.
+	add(b []byte, bits int) error
+	// wait waits until the pool wants entropy, or for d.
+	wait(ctx context.Context, d time.Duration) error
+	// ready reports whether getrandom(2) no longer blocks.
+	ready() bool
+	// threshold returns the bits of entropy the kernel wants.
+	threshold() int
+}
+
+// source is a Source with its health.
+type source struct {
+	Source
+	last     []byte
+	failures int
+}
+
+// read reads a block from s, and checks it is not a repeat of the last
+// one, or all one byte, as stuck sources return.
+func (s *source) read(b []byte) error {
+	if _, err := io.ReadFull(s, b); err != nil {
+		return err
+	}
+	if bytes.Equal(b, s.last) || bytes.Count(b, b[:1]) == len(b) {
+		return ErrHealth
+	}
+	s.last = append(s.last[:0], b...)
+	return nil
+}
+
+// feeder feeds a pool from sources in turn.
+type feeder struct {
+	Options
+	pool    pool
+	sources []*source
+	next    int
+	buf     []byte
+}
+
+func newFeeder(o Options, p pool) *feeder {
+	if o.BlockSize <= 0 {
+		o.BlockSize = DefaultBlockSize
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = p.threshold()
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.Logf == nil {
+		o.Logf = log.Printf
+	}
+	f := &feeder{Options: o, pool: p, buf: make([]byte, o.BlockSize)}
+	for _, s := range o.Sources {
+		f.sources = append(f.sources, &source{Source: s})
+	}
+	return f
+}
+
+// feed adds a block from the next source which has one to the pool.
+func (f *feeder) feed() error {
+	for len(f.sources) > 0 {
+		f.next %= len(f.sources)
+		s := f.sources[f.next]
+		err := s.read(f.buf)
+		if err == nil {
+			s.failures = 0
+			f.next++
+			bits := len(f.buf) * 8 * s.Credit() / 1024
+			if err := f.pool.add(f.buf, bits); err != nil {
+				return fmt.Errorf("adding entropy: %w", err)
+			}
+			return nil
+		}
+		s.failures++
+		if s.failures < maxFailures {
+			f.next++
+			continue
+		}
+		f.Logf("rngd: dropping %s after %d failures: %v", s.Name(), s.failures, err)
+		s.Close()
+		f.sources = append(f.sources[:f.next], f.sources[f.next+1:]...)
+	}
+	return ErrNoSource
+}
+
+// fill feeds the pool once, and then until it holds Threshold bits.
+func (f *feeder) fill() error {
+	for i := 0; ; i++ {
+		if i > 0 {
+			avail, err := f.pool.avail()
+			if err != nil {
+				return err
+			}
+			if avail >= f.Threshold {
+				return nil
+			}
+		}
+		if err := f.feed(); err != nil {
+			return err
+		}
+	}
+}
+
+// run fills the pool whenever it wants entropy, and every Interval, until
+// ctx is done.
+func (f *feeder) run(ctx context.Context) error {
+	for {
+		if err := f.fill(); err != nil {
+			return err
+		}
+		if err := f.pool.wait(ctx, f.Interval); err != nil {
+			return err
+		}
+	}
+}
+
+// seed fills the pool until getrandom(2) is ready and the pool holds
+// Threshold bits.
+func (f *feeder) seed(ctx context.Context) error {
+	for {
+		if err := f.fill(); err != nil {
+			return err
+		}
+		if f.pool.ready() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *feeder) close() {
+	for _, s := range f.sources {
+		s.Close()
+	}
+}
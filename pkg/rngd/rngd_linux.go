@@ -0,0 +1,250 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rngd
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"golang.org/x/sys/unix"
+)
+
+const procRandom = "/proc/sys/kernel/random"
+
+// kernelPool is the kernel's pool, fed through /dev/random.
+type kernelPool struct {
+	f *os.File
+}
+
+func openPool() (*kernelPool, error) {
+	f, err := os.OpenFile("/dev/random", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &kernelPool{f: f}, nil
+}
+
+func readInt(name string) (int, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (p *kernelPool) avail() (int, error) {
+	return readInt(procRandom + "/entropy_avail")
+}
+
+func (p *kernelPool) threshold() int {
+	if n, err := readInt(procRandom + "/write_wakeup_threshold"); err == nil && n > 0 {
+		return n
+	}
+	return 256
+}
+
+func (p *kernelPool) add(b []byte, bits int) error {
+	// struct rand_pool_info, from include/uapi/linux/random.h.
+	info := make([]byte, 8+len(b))
+	binary.NativeEndian.PutUint32(info[0:], uint32(bits))
+	binary.NativeEndian.PutUint32(info[4:], uint32(len(b)))
+	copy(info[8:], b)
+	sc, err := p.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno unix.Errno
+	if err := sc.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, unix.RNDADDENTROPY, uintptr(unsafe.Pointer(&info[0])))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return os.NewSyscallError("ioctl RNDADDENTROPY", errno)
+	}
+	return nil
+}
+
+// wait waits for /dev/random to be writable, which it is when the kernel
+// wants entropy.
+func (p *kernelPool) wait(ctx context.Context, d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for {
+		// Poll in short steps, to notice ctx being done.
+		step := min(time.Until(deadline), time.Second)
+		if step <= 0 {
+			return nil
+		}
+		fds := []unix.PollFd{{Fd: int32(p.f.Fd()), Events: unix.POLLOUT}}
+		n, err := unix.Poll(fds, int(step.Milliseconds()))
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return os.NewSyscallError("poll", err)
+		}
+		if n > 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *kernelPool) ready() bool {
+	var b [1]byte
+	_, err := unix.Getrandom(b[:], unix.GRND_NONBLOCK)
+	return !errors.Is(err, unix.EAGAIN)
+}
+
+func (p *kernelPool) close() error {
+	return p.f.Close()
+}
+
+// Run feeds the kernel's pool from o.Sources whenever it wants entropy,
+// until ctx is done or all sources failed. The sources are closed.
+func Run(ctx context.Context, o Options) error {
+	p, err := openPool()
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	f := newFeeder(o, p)
+	defer f.close()
+	return f.run(ctx)
+}
+
+// Seed feeds the kernel's pool from o.Sources until getrandom(2) no longer
+// blocks and the pool holds o.Threshold bits. The sources are closed.
+func Seed(ctx context.Context, o Options) error {
+	p, err := openPool()
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	f := newFeeder(o, p)
+	defer f.close()
+	return f.seed(ctx)
+}
+
+// Key seeds the kernel's pool from o.Sources if getrandom(2) would block,
+// and returns n bytes from getrandom(2), for keys made early in boot.
+func Key(ctx context.Context, n int, o Options) ([]byte, error) {
+	if !(&kernelPool{}).ready() {
+		if err := Seed(ctx, o); err != nil {
+			return nil, fmt.Errorf("seeding: %w", err)
+		}
+	} else {
+		for _, s := range o.Sources {
+			s.Close()
+		}
+	}
+	b := make([]byte, n)
+	for off := 0; off < n; {
+		m, err := unix.Getrandom(b[off:], 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("getrandom", err)
+		}
+		off += m
+	}
+	return b, nil
+}
+
+// hwrng is a hardware RNG behind /dev/hwrng.
+type hwrng struct {
+	*os.File
+	name string
+}
+
+// DefaultHWRNG is the kernel's hardware RNG device.
+const DefaultHWRNG = "/dev/hwrng"
+
+// HWRNG returns the hardware RNG at path, such as DefaultHWRNG, whichever
+// the kernel chose of virtio-rng, TPMs and others.
+func HWRNG(path string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	name := "hwrng"
+	if b, err := os.ReadFile("/sys/class/misc/hw_random/rng_current"); err == nil && path == DefaultHWRNG {
+		name += " " + strings.TrimSpace(string(b))
+	}
+	return &hwrng{File: f, name: name}, nil
+}
+
+func (h *hwrng) Name() string { return h.name }
+func (h *hwrng) Credit() int  { return 1024 }
+
+// tpmSource is a TPM 2.0, asked for random bytes with TPM2_GetRandom.
+type tpmSource struct {
+	f *os.File
+}
+
+// DefaultTPMs are the TPM devices TPM tries.
+var DefaultTPMs = []string{"/dev/tpmrm0", "/dev/tpm0"}
+
+// TPM returns the first TPM 2.0 of paths which opens.
+func TPM(paths ...string) (Source, error) {
+	var errs []error
+	for _, path := range paths {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err == nil {
+			return &tpmSource{f: f}, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// maxTPMRandom is the most bytes TPMs return at once, the size of their
+// largest digest.
+const maxTPMRandom = 32
+
+func (t *tpmSource) Read(b []byte) (int, error) {
+	r, err := tpm2.GetRandom(t.f, uint16(min(len(b), maxTPMRandom)))
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, r), nil
+}
+
+func (t *tpmSource) Close() error { return t.f.Close() }
+func (t *tpmSource) Name() string { return "tpm " + t.f.Name() }
+func (t *tpmSource) Credit() int  { return 1024 }
+
+// Sources returns the sources this machine has: RDRAND, the hardware RNG
+// and a TPM, and errors for those it has not.
+func Sources() ([]Source, error) {
+	var (
+		sources []Source
+		errs    []error
+	)
+	for _, open := range []func() (Source, error){
+		RDRAND,
+		func() (Source, error) { return HWRNG(DefaultHWRNG) },
+		func() (Source, error) { return TPM(DefaultTPMs...) },
+	} {
+		s, err := open()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sources = append(sources, s)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrNoSource, errors.Join(errs...))
+	}
+	return sources, errors.Join(errs...)
+}
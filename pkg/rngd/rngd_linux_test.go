@@ -0,0 +1,64 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rngd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSources(t *testing.T) {
+	sources, err := Sources()
+	if errors.Is(err, ErrNoSource) {
+		t.Skipf("no sources: %v", err)
+	}
+	for _, s := range sources {
+		t.Run(s.Name(), func(t *testing.T) {
+			defer s.Close()
+			src := &source{Source: s}
+			b := make([]byte, DefaultBlockSize)
+			for i := 0; i < 3; i++ {
+				if err := src.read(b); err != nil {
+					t.Errorf("read = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestKernelPool(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("adding entropy needs root")
+	}
+	p, err := openPool()
+	if err != nil {
+		t.Skipf("no /dev/random: %v", err)
+	}
+	defer p.close()
+	if _, err := p.avail(); err != nil {
+		t.Fatalf("avail = %v", err)
+	}
+	if err := p.add([]byte("not very random, and not credited"), 0); err != nil {
+		t.Errorf("add = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := p.wait(ctx, 50*time.Millisecond); err != nil {
+		t.Errorf("wait = %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	k, err := Key(context.Background(), 48, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k) != 48 {
+		t.Errorf("Key returned %d bytes, want 48", len(k))
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rngd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// counter is a source returning bytes counting up, or a stuck byte.
+type counter struct {
+	name   string
+	n      byte
+	stuck  bool
+	credit int
+	closed bool
+}
+
+func (c *counter) Read(b []byte) (int, error) {
+	for i := range b {
+		if !c.stuck {
+			c.n++
+		}
+		b[i] = c.n
+	}
+	return len(b), nil
+}
+
+func (c *counter) Close() error { c.closed = true; return nil }
+func (c *counter) Name() string { return c.name }
+func (c *counter) Credit() int  { return c.credit }
+
+// fakePool counts entropy added, and is ready after readyAfter adds.
+type fakePool struct {
+	bits       int
+	adds       []string
+	readyAfter int
+	waits      int
+}
+
+func (p *fakePool) avail() (int, error) { return p.bits, nil }
+func (p *fakePool) threshold() int      { return 256 }
+func (p *fakePool) ready() bool         { return len(p.adds) >= p.readyAfter }
+
+func (p *fakePool) add(b []byte, bits int) error {
+	p.bits += bits
+	p.adds = append(p.adds, string(b[:1]))
+	return nil
+}
+
+func (p *fakePool) wait(ctx context.Context, d time.Duration) error {
+	p.waits++
+	// The pool drains between waits.
+	p.bits = 0
+	return ctx.Err()
+}
+
+func TestFill(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		sources []*counter
+		adds    int
+		err     error
+	}{
+		{
+			name:    "full credit",
+			sources: []*counter{{name: "a", credit: 1024}},
+			// 256 bits are 4 blocks of 64 bits.
+			adds: 4,
+		},
+		{
+			name:    "half credit",
+			sources: []*counter{{name: "a", credit: 512}},
+			adds:    8,
+		},
+		{
+			name:    "no credit",
+			sources: []*counter{{name: "a", n: 100, credit: 0}, {name: "b", credit: 1024}},
+			adds:    8,
+		},
+		{
+			name:    "stuck",
+			sources: []*counter{{name: "a", stuck: true, credit: 1024}},
+			err:     ErrNoSource,
+		},
+		{
+			name:    "stuck and working",
+			sources: []*counter{{name: "a", stuck: true, credit: 1024}, {name: "b", credit: 1024}},
+			adds:    4,
+		},
+		{
+			name: "none",
+			err:  ErrNoSource,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []Source
+			for _, s := range tt.sources {
+				sources = append(sources, s)
+			}
+			p := &fakePool{}
+			f := newFeeder(Options{Sources: sources, BlockSize: 8, Logf: t.Logf}, p)
+			if err := f.fill(); !errors.Is(err, tt.err) {
+				t.Fatalf("fill = %v, want %v", err, tt.err)
+			}
+			if len(p.adds) != tt.adds {
+				t.Errorf("fill added %d blocks, want %d", len(p.adds), tt.adds)
+			}
+			for _, s := range tt.sources {
+				if s.closed && !s.stuck {
+					t.Errorf("working source %s was closed", s.name)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	a := &counter{name: "a", credit: 1024}
+	b := &counter{name: "b", n: 100, credit: 1024}
+	p := &fakePool{}
+	f := newFeeder(Options{Sources: []Source{a, b}, BlockSize: 8}, p)
+	if err := f.fill(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"\x01", "\x65", "\x09", "\x6d"}
+	if len(p.adds) != len(want) {
+		t.Fatalf("fill added %q, want %q", p.adds, want)
+	}
+	for i := range want {
+		if p.adds[i] != want[i] {
+			t.Errorf("block %d starts %q, want %q", i, p.adds[i], want[i])
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := &source{Source: &counter{}}
+	b := make([]byte, 4)
+	if err := s.read(b); err != nil {
+		t.Fatal(err)
+	}
+	s.Source = &counter{}
+	if err := s.read(b); !errors.Is(err, ErrHealth) {
+		t.Errorf("repeated block: read = %v, want %v", err, ErrHealth)
+	}
+	s.Source = &counter{n: 7, stuck: true}
+	if err := s.read(b); !errors.Is(err, ErrHealth) || !bytes.Equal(b, []byte{7, 7, 7, 7}) {
+		t.Errorf("stuck block %v: read = %v, want %v", b, err, ErrHealth)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	p := &fakePool{readyAfter: 10}
+	f := newFeeder(Options{Sources: []Source{&counter{credit: 1024}}, BlockSize: 8}, p)
+	if err := f.seed(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.adds) < 10 {
+		t.Errorf("seed added %d blocks, want at least 10", len(p.adds))
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &fakePool{}
+	f := newFeeder(Options{Sources: []Source{&counter{credit: 1024}}, BlockSize: 8}, p)
+	f.pool = &cancelPool{fakePool: p, after: 3, cancel: cancel}
+	if err := f.run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("run = %v, want %v", err, context.Canceled)
+	}
+	if p.waits != 3 || len(p.adds) != 12 {
+		t.Errorf("run waited %d times and added %d blocks, want 3 and 12", p.waits, len(p.adds))
+	}
+}
+
+// cancelPool cancels after some waits.
+type cancelPool struct {
+	*fakePool
+	after  int
+	cancel func()
+}
+
+func (p *cancelPool) wait(ctx context.Context, d time.Duration) error {
+	if p.waits+1 == p.after {
+		p.cancel()
+	}
+	return p.fakePool.wait(ctx, d)
+}
@@ -42,6 +42,15 @@ func OpenRTC() (*RTC, error) {
 	return nil, errors.New("no RTC device found")
 }
 
+// OpenRTCDevice opens the RTC at the given device, such as /dev/rtc1.
+func OpenRTCDevice(dev string) (*RTC, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	return &RTC{f, realSyscalls{}}, nil
+}
+
 // Close closes the RTC
 func (r *RTC) Close() error {
 	return r.file.Close()
@@ -0,0 +1,408 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// chunk is the most data read or written by a request. All servers must
+// accept 32 KiB.
+const chunk = 32 << 10
+
+// Client is a client of a server on a connection, such as the channel of an
+// SSH sftp subsystem request. Requests are sent one at a time. Paths are
+// those of the server, with slashes.
+type Client struct {
+	rw io.ReadWriteCloser
+
+	mu sync.Mutex
+	id uint32
+}
+
+// NewClient starts a session on a connection.
+func NewClient(rw io.ReadWriteCloser) (*Client, error) {
+	var b buffer
+	b.byte(fxpInit)
+	b.uint32(Version)
+	if err := writePacket(rw, b); err != nil {
+		return nil, err
+	}
+	typ, d, err := readPacket(rw)
+	if err != nil {
+		return nil, err
+	}
+	if typ != fxpVersion {
+		return nil, fmt.Errorf("%w: type %d instead of VERSION", ErrBadPacket, typ)
+	}
+	// Servers may send extensions after the version, which we ignore.
+	if v := d.uint32(); d.err != nil || v != Version {
+		return nil, fmt.Errorf("%w: version %d", ErrBadPacket, v)
+	}
+	return &Client{rw: rw}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.rw.Close()
+}
+
+// call sends a request, with the fields added by req after the type and ID,
+// and returns the reply if it has the wanted type. Statuses other than OK
+// are returned as *StatusError.
+func (c *Client) call(typ, want byte, req func(b *buffer)) (*decoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id++
+	var b buffer
+	b.byte(typ)
+	b.uint32(c.id)
+	req(&b)
+	if err := writePacket(c.rw, b); err != nil {
+		return nil, err
+	}
+	rtyp, d, err := readPacket(c.rw)
+	if err != nil {
+		return nil, err
+	}
+	if id := d.uint32(); d.err != nil || id != c.id {
+		return nil, fmt.Errorf("%w: reply to %d instead of %d", ErrBadPacket, id, c.id)
+	}
+	if rtyp == fxpStatus {
+		st := &StatusError{Code: d.uint32(), Msg: d.string()}
+		if d.err != nil {
+			return nil, d.err
+		}
+		if st.Code != StatusOK || want != fxpStatus {
+			return nil, st
+		}
+	}
+	if rtyp != want {
+		return nil, fmt.Errorf("%w: reply type %d instead of %d", ErrBadPacket, rtyp, want)
+	}
+	return d, nil
+}
+
+// do sends a request which is answered with a status.
+func (c *Client) do(op, name string, typ byte, req func(b *buffer)) error {
+	if _, err := c.call(typ, fxpStatus, req); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+func (c *Client) stat(op, name string, typ byte, req func(b *buffer)) (fs.FileInfo, error) {
+	d, err := c.call(typ, fxpAttrs, req)
+	if err == nil {
+		a := d.attrs()
+		if err = d.err; err == nil {
+			return &fileInfo{name: path.Base(name), attrs: a}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Stat returns the attributes of a file, following symlinks.
+func (c *Client) Stat(name string) (fs.FileInfo, error) {
+	return c.stat("stat", name, fxpStat, func(b *buffer) { b.string(name) })
+}
+
+// Lstat returns the attributes of a file, or of a symlink itself.
+func (c *Client) Lstat(name string) (fs.FileInfo, error) {
+	return c.stat("lstat", name, fxpLstat, func(b *buffer) { b.string(name) })
+}
+
+// name sends a request answered with one name, such as REALPATH.
+func (c *Client) name(op, name string, typ byte) (string, error) {
+	d, err := c.call(typ, fxpName, func(b *buffer) { b.string(name) })
+	if err == nil {
+		n := d.uint32()
+		s := d.string()
+		if err = d.err; err == nil && n != 1 {
+			err = fmt.Errorf("%w: %d names", ErrBadPacket, n)
+		}
+		if err == nil {
+			return s, nil
+		}
+	}
+	return "", &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// RealPath returns the absolute, clean path of a file. The path of "." is
+// the working directory of the server, usually the home of the user.
+func (c *Client) RealPath(name string) (string, error) {
+	return c.name("realpath", name, fxpRealpath)
+}
+
+// Readlink returns the target of a symlink.
+func (c *Client) Readlink(name string) (string, error) {
+	return c.name("readlink", name, fxpReadlink)
+}
+
+// ReadDir returns the entries of a directory, sorted by name, without . and
+// .., which some servers include.
+func (c *Client) ReadDir(name string) ([]fs.FileInfo, error) {
+	d, err := c.call(fxpOpendir, fxpHandle, func(b *buffer) { b.string(name) })
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	h := d.string()
+	if d.err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: d.err}
+	}
+	defer c.do("close", name, fxpClose, func(b *buffer) { b.string(h) })
+
+	var fis []fs.FileInfo
+	for {
+		d, err := c.call(fxpReaddir, fxpName, func(b *buffer) { b.string(h) })
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			fi := &fileInfo{name: d.string()}
+			d.string()
+			fi.attrs = d.attrs()
+			if fi.name != "." && fi.name != ".." {
+				fis = append(fis, fi)
+			}
+		}
+		if d.err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: d.err}
+		}
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	return fis, nil
+}
+
+// Mkdir creates a directory.
+func (c *Client) Mkdir(name string, perm fs.FileMode) error {
+	return c.do("mkdir", name, fxpMkdir, func(b *buffer) {
+		b.string(name)
+		b.attrs(&Attrs{Flags: attrPermissions, Perm: unixMode(perm.Perm() | fs.ModeDir)})
+	})
+}
+
+// Remove removes a file, but not a directory.
+func (c *Client) Remove(name string) error {
+	return c.do("remove", name, fxpRemove, func(b *buffer) { b.string(name) })
+}
+
+// RemoveDir removes an empty directory.
+func (c *Client) RemoveDir(name string) error {
+	return c.do("rmdir", name, fxpRmdir, func(b *buffer) { b.string(name) })
+}
+
+// Rename renames a file. Most servers do not replace an existing file.
+func (c *Client) Rename(from, to string) error {
+	return c.do("rename", from, fxpRename, func(b *buffer) {
+		b.string(from)
+		b.string(to)
+	})
+}
+
+// Symlink creates a symlink to target. The arguments are sent in the order
+// of OpenSSH, which is the reverse of the draft.
+func (c *Client) Symlink(target, link string) error {
+	return c.do("symlink", link, fxpSymlink, func(b *buffer) {
+		b.string(target)
+		b.string(link)
+	})
+}
+
+// setstat sets attributes of a file.
+func (c *Client) setstat(op, name string, a *Attrs) error {
+	return c.do(op, name, fxpSetstat, func(b *buffer) {
+		b.string(name)
+		b.attrs(a)
+	})
+}
+
+// Chmod changes the permissions of a file.
+func (c *Client) Chmod(name string, mode fs.FileMode) error {
+	return c.setstat("chmod", name, &Attrs{Flags: attrPermissions, Perm: unixMode(mode) &^ modeType})
+}
+
+// Chtimes changes the access and modification times of a file, to the
+// second.
+func (c *Client) Chtimes(name string, atime, mtime time.Time) error {
+	return c.setstat("chtimes", name, &Attrs{Flags: attrACModTime, Atime: uint32(atime.Unix()), Mtime: uint32(mtime.Unix())})
+}
+
+// Truncate changes the size of a file.
+func (c *Client) Truncate(name string, size int64) error {
+	return c.setstat("truncate", name, &Attrs{Flags: attrSize, Size: uint64(size)})
+}
+
+// File is an open file of the server.
+type File struct {
+	c      *Client
+	name   string
+	handle string
+
+	mu  sync.Mutex
+	off int64
+}
+
+// Open opens a file for reading.
+func (c *Client) Open(name string) (*File, error) {
+	return c.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates a file for writing, with mode 0666 before the
+// server's umask.
+func (c *Client) Create(name string) (*File, error) {
+	return c.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile opens a file with the flags of os.OpenFile: O_RDONLY, O_WRONLY
+// or O_RDWR, and O_APPEND, O_CREATE, O_TRUNC and O_EXCL. perm is used
+// when a file is created.
+func (c *Client) OpenFile(name string, flag int, perm fs.FileMode) (*File, error) {
+	var pflags uint32
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_WRONLY:
+		pflags = flagWrite
+	case os.O_RDWR:
+		pflags = flagRead | flagWrite
+	default:
+		pflags = flagRead
+	}
+	for f, p := range map[int]uint32{os.O_APPEND: flagAppend, os.O_CREATE: flagCreat, os.O_TRUNC: flagTrunc, os.O_EXCL: flagExcl} {
+		if flag&f != 0 {
+			pflags |= p
+		}
+	}
+	d, err := c.call(fxpOpen, fxpHandle, func(b *buffer) {
+		b.string(name)
+		b.uint32(pflags)
+		b.attrs(&Attrs{Flags: attrPermissions, Perm: unixMode(perm.Perm())})
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	h := d.string()
+	if d.err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: d.err}
+	}
+	return &File{c: c, name: name, handle: h}, nil
+}
+
+// Name returns the name the file was opened with.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Close closes the file. For files written to, servers may report errors
+// only here.
+func (f *File) Close() error {
+	return f.c.do("close", f.name, fxpClose, func(b *buffer) { b.string(f.handle) })
+}
+
+// Stat returns the attributes of the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return f.c.stat("stat", f.name, fxpFstat, func(b *buffer) { b.string(f.handle) })
+}
+
+// ReadAt reads len(p) bytes at an offset, as io.ReaderAt.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		d, err := f.c.call(fxpRead, fxpData, func(b *buffer) {
+			b.string(f.handle)
+			b.uint64(uint64(off) + uint64(n))
+			b.uint32(uint32(min(len(p)-n, chunk)))
+		})
+		if errors.Is(err, io.EOF) {
+			return n, io.EOF
+		}
+		if err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		s := d.string()
+		if d.err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: d.err}
+		}
+		if len(s) > len(p)-n {
+			return n, &fs.PathError{Op: "read", Path: f.name, Err: fmt.Errorf("%w: %d bytes read", ErrBadPacket, len(s))}
+		}
+		// Servers may return less than asked for before the end.
+		n += copy(p[n:], s)
+	}
+	return n, nil
+}
+
+// Read reads from the current offset.
+func (f *File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// WriteAt writes p at an offset, as io.WriterAt.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	var n int
+	for n < len(p) {
+		m := min(len(p)-n, chunk)
+		err := f.c.do("write", f.name, fxpWrite, func(b *buffer) {
+			b.string(f.handle)
+			b.uint64(uint64(off) + uint64(n))
+			b.string(string(p[n : n+m]))
+		})
+		if err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// Write writes at the current offset.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+// Seek sets the offset of the next Read or Write, as io.Seeker.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += fi.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
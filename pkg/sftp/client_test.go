@@ -0,0 +1,194 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T) *Client {
+	t.Helper()
+	c, srv := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(srv).Serve()
+		srv.Close()
+	}()
+	clt, err := NewClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		clt.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	})
+	return clt
+}
+
+func TestClientFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := newClient(t)
+	name := filepath.Join(dir, "f")
+
+	// Larger than a chunk, to be split.
+	data := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	f, err := c.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.Write(data); err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if _, err := f.Seek(-6, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("ABCDEF")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	copy(data[len(data)-6:], "ABCDEF")
+	if b, err := os.ReadFile(name); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("file has %d bytes, %v, want %d", len(b), err, len(data))
+	}
+
+	f, err = c.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != int64(len(data)) || fi.Name() != "f" {
+		t.Errorf("Stat = %v, %v", fi, err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(b, data) {
+		t.Errorf("ReadAll = %d bytes, %v, want %d", len(b), err, len(data))
+	}
+	p := make([]byte, 4)
+	if n, err := f.ReadAt(p, int64(len(data)-2)); n != 2 || err != io.EOF {
+		t.Errorf("ReadAt at the end = %d, %v, want 2, EOF", n, err)
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Errorf("Write to a file opened for reading succeeded")
+	}
+	f.Close()
+
+	if _, err := c.Open(filepath.Join(dir, "nope")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(nope) = %v, want ErrNotExist", err)
+	}
+	if _, err := c.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); err == nil {
+		t.Errorf("OpenFile with O_EXCL of an existing file succeeded")
+	}
+	f, err = c.OpenFile(filepath.Join(dir, "g"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if fi, err := os.Stat(filepath.Join(dir, "g")); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("created %v, %v, want mode 0600", fi, err)
+	}
+}
+
+func TestClientFS(t *testing.T) {
+	dir := t.TempDir()
+	c := newClient(t)
+	sub := filepath.Join(dir, "sub")
+
+	if err := c.Mkdir(sub, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := c.Stat(sub); err != nil || !fi.IsDir() || fi.Mode().Perm() != 0o700 {
+		t.Errorf("Stat(sub) = %v, %v", fi, err)
+	}
+	for _, n := range []string{"b", "a"} {
+		if err := os.WriteFile(filepath.Join(sub, n), []byte(n), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Symlink("a", filepath.Join(sub, "l")); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := c.Readlink(filepath.Join(sub, "l")); err != nil || target != "a" {
+		t.Errorf("Readlink = %q, %v, want a", target, err)
+	}
+	if fi, err := c.Lstat(filepath.Join(sub, "l")); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat(l) = %v, %v, want a symlink", fi, err)
+	}
+	fis, err := c.ReadDir(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fi := range fis {
+		got = append(got, fi.Name())
+	}
+	if want := []string{"a", "b", "l"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("ReadDir = %v, want %v", got, want)
+	}
+
+	a := filepath.Join(sub, "a")
+	if err := c.Chmod(a, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1700000000, 0)
+	if err := c.Chtimes(a, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(a); err != nil || fi.Mode() != 0o600 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("after Chmod and Chtimes: %v, %v", fi, err)
+	}
+	if err := c.Rename(a, filepath.Join(sub, "b")); err == nil {
+		t.Errorf("Rename over an existing file succeeded")
+	}
+	if err := c.Rename(a, filepath.Join(sub, "c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveDir(sub); err == nil {
+		t.Errorf("RemoveDir of a full directory succeeded")
+	}
+	for _, n := range []string{"b", "c", "l"} {
+		if err := c.Remove(filepath.Join(sub, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Remove(sub); err == nil {
+		t.Errorf("Remove of a directory succeeded")
+	}
+	if err := c.RemoveDir(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	if p, err := c.RealPath("."); err != nil || p != wd {
+		t.Errorf("RealPath(.) = %q, %v, want %q", p, err, wd)
+	}
+}
+
+func TestClientBadVersion(t *testing.T) {
+	c, srv := net.Pipe()
+	defer c.Close()
+	go func() {
+		readPacket(srv)
+		var b buffer
+		b.byte(fxpVersion)
+		b.uint32(2)
+		writePacket(srv, b)
+		srv.Close()
+	}()
+	if _, err := NewClient(c); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("NewClient with version 2 = %v, want ErrBadPacket", err)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package sftp
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// owner returns the owner of a file.
+func owner(fi fs.FileInfo) (uint32, uint32, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return st.Uid, st.Gid, true
+}
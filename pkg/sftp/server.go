@@ -0,0 +1,471 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// maxRead is the most data returned for a READ.
+const maxRead = 128 << 10
+
+// readdirBatch is the number of names returned for a READDIR.
+const readdirBatch = 100
+
+// Server serves the files of the system on a connection, such as the
+// channel of an SSH sftp subsystem request.
+type Server struct {
+	rw io.ReadWriter
+	// ReadOnly makes the server refuse all changes.
+	ReadOnly bool
+
+	files map[string]*os.File
+	dirs  map[string]*dir
+	next  uint64
+}
+
+type dir struct {
+	path    string
+	f       *os.File
+	entries []fs.DirEntry
+	done    bool
+}
+
+// NewServer returns a server for a connection.
+func NewServer(rw io.ReadWriter) *Server {
+	return &Server{rw: rw, files: map[string]*os.File{}, dirs: map[string]*dir{}}
+}
+
+var errReadOnly = &StatusError{StatusPermissionDenied, "read-only server"}
+
+// Serve serves requests until the connection is closed, and then closes all
+// open files.
+func (s *Server) Serve() error {
+	defer s.closeAll()
+
+	typ, d, err := readPacket(s.rw)
+	if err != nil {
+		return err
+	}
+	if typ != fxpInit {
+		return fmt.Errorf("%w: type %d before INIT", ErrBadPacket, typ)
+	}
+	if v := d.uint32(); d.err != nil || v < Version {
+		return fmt.Errorf("%w: version %d", ErrBadPacket, v)
+	}
+	var b buffer
+	b.byte(fxpVersion)
+	b.uint32(Version)
+	if err := writePacket(s.rw, b); err != nil {
+		return err
+	}
+
+	for {
+		typ, d, err := readPacket(s.rw)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := d.uint32()
+		reply := s.handle(typ, d)
+		if err := writePacket(s.rw, reply(id)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) closeAll() {
+	for _, f := range s.files {
+		f.Close()
+	}
+	for _, d := range s.dirs {
+		d.f.Close()
+	}
+}
+
+// reply builds a reply packet for a request ID.
+type reply func(id uint32) buffer
+
+func status(err error) reply {
+	return func(id uint32) buffer {
+		st := statusOf(err)
+		var b buffer
+		b.byte(fxpStatus)
+		b.uint32(id)
+		b.uint32(st.Code)
+		b.string(st.Msg)
+		b.string("")
+		return b
+	}
+}
+
+func handle(h string) reply {
+	return func(id uint32) buffer {
+		var b buffer
+		b.byte(fxpHandle)
+		b.uint32(id)
+		b.string(h)
+		return b
+	}
+}
+
+func data(p []byte) reply {
+	return func(id uint32) buffer {
+		b := make(buffer, 0, len(p)+9)
+		b.byte(fxpData)
+		b.uint32(id)
+		b.string(string(p))
+		return b
+	}
+}
+
+type name struct {
+	name, long string
+	attrs      *Attrs
+}
+
+func names(n ...name) reply {
+	return func(id uint32) buffer {
+		var b buffer
+		b.byte(fxpName)
+		b.uint32(id)
+		b.uint32(uint32(len(n)))
+		for _, e := range n {
+			b.string(e.name)
+			b.string(e.long)
+			b.attrs(e.attrs)
+		}
+		return b
+	}
+}
+
+func attrs(fi fs.FileInfo, err error) reply {
+	if err != nil {
+		return status(err)
+	}
+	return func(id uint32) buffer {
+		var b buffer
+		b.byte(fxpAttrs)
+		b.uint32(id)
+		b.attrs(fileAttrs(fi))
+		return b
+	}
+}
+
+func (s *Server) newHandle() string {
+	s.next++
+	return strconv.FormatUint(s.next, 10)
+}
+
+// openFlags converts the flags of OPEN to those of os.OpenFile.
+func openFlags(pflags uint32) int {
+	var flags int
+	switch {
+	case pflags&flagRead != 0 && pflags&flagWrite != 0:
+		flags = os.O_RDWR
+	case pflags&flagWrite != 0:
+		flags = os.O_WRONLY
+	default:
+		flags = os.O_RDONLY
+	}
+	if pflags&flagAppend != 0 {
+		flags |= os.O_APPEND
+	}
+	if pflags&flagCreat != 0 {
+		flags |= os.O_CREATE
+	}
+	if pflags&flagTrunc != 0 {
+		flags |= os.O_TRUNC
+	}
+	if pflags&flagExcl != 0 {
+		flags |= os.O_EXCL
+	}
+	return flags
+}
+
+// setstat applies attributes to a file.
+func setstat(path string, a *Attrs) error {
+	if a.Flags&attrSize != 0 {
+		if err := os.Truncate(path, int64(a.Size)); err != nil {
+			return err
+		}
+	}
+	if a.Flags&attrPermissions != 0 {
+		if err := os.Chmod(path, a.FileMode()); err != nil {
+			return err
+		}
+	}
+	if a.Flags&attrUIDGID != 0 {
+		if err := os.Chown(path, int(a.UID), int(a.GID)); err != nil {
+			return err
+		}
+	}
+	if a.Flags&attrACModTime != 0 {
+		if err := os.Chtimes(path, time.Unix(int64(a.Atime), 0), time.Unix(int64(a.Mtime), 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle handles a request, and returns its reply.
+func (s *Server) handle(typ byte, d *decoder) reply {
+	switch typ {
+	case fxpOpen:
+		path, pflags, a := d.string(), d.uint32(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		if s.ReadOnly && pflags&^flagRead != 0 {
+			return status(errReadOnly)
+		}
+		perm := fs.FileMode(0o644)
+		if a.Flags&attrPermissions != 0 {
+			perm = a.FileMode().Perm()
+		}
+		f, err := os.OpenFile(path, openFlags(pflags), perm)
+		if err != nil {
+			return status(err)
+		}
+		h := s.newHandle()
+		s.files[h] = f
+		return handle(h)
+
+	case fxpOpendir:
+		path := d.string()
+		if d.err != nil {
+			break
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return status(err)
+		}
+		if fi, err := f.Stat(); err != nil || !fi.IsDir() {
+			f.Close()
+			return status(&StatusError{StatusFailure, path + ": not a directory"})
+		}
+		h := s.newHandle()
+		s.dirs[h] = &dir{path: path, f: f}
+		return handle(h)
+
+	case fxpClose:
+		h := d.string()
+		if d.err != nil {
+			break
+		}
+		if f, ok := s.files[h]; ok {
+			delete(s.files, h)
+			return status(f.Close())
+		}
+		if dd, ok := s.dirs[h]; ok {
+			delete(s.dirs, h)
+			return status(dd.f.Close())
+		}
+		return status(&StatusError{StatusFailure, "bad handle"})
+
+	case fxpRead:
+		h, off, n := d.string(), d.uint64(), d.uint32()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.files[h]
+		if !ok {
+			return status(&StatusError{StatusFailure, "bad handle"})
+		}
+		b := make([]byte, min(n, maxRead))
+		n2, err := f.ReadAt(b, int64(off))
+		if n2 > 0 {
+			return data(b[:n2])
+		}
+		if err == nil {
+			err = io.EOF
+		}
+		return status(err)
+
+	case fxpWrite:
+		h, off, p := d.string(), d.uint64(), d.string()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.files[h]
+		if !ok {
+			return status(&StatusError{StatusFailure, "bad handle"})
+		}
+		_, err := f.WriteAt([]byte(p), int64(off))
+		if errors.Is(err, os.ErrInvalid) {
+			// Files opened with O_APPEND cannot WriteAt.
+			_, err = f.Write([]byte(p))
+		}
+		return status(err)
+
+	case fxpReaddir:
+		h := d.string()
+		if d.err != nil {
+			break
+		}
+		dd, ok := s.dirs[h]
+		if !ok {
+			return status(&StatusError{StatusFailure, "bad handle"})
+		}
+		return s.readdir(dd)
+
+	case fxpStat, fxpLstat:
+		path := d.string()
+		if d.err != nil {
+			break
+		}
+		if typ == fxpStat {
+			return attrs(os.Stat(path))
+		}
+		return attrs(os.Lstat(path))
+
+	case fxpFstat:
+		h := d.string()
+		if d.err != nil {
+			break
+		}
+		f, ok := s.files[h]
+		if !ok {
+			return status(&StatusError{StatusFailure, "bad handle"})
+		}
+		return attrs(f.Stat())
+
+	case fxpSetstat, fxpFsetstat:
+		p, a := d.string(), d.attrs()
+		if d.err != nil {
+			break
+		}
+		if s.ReadOnly {
+			return status(errReadOnly)
+		}
+		if typ == fxpFsetstat {
+			f, ok := s.files[p]
+			if !ok {
+				return status(&StatusError{StatusFailure, "bad handle"})
+			}
+			p = f.Name()
+		}
+		return status(setstat(p, a))
+
+	case fxpRealpath:
+		path := d.string()
+		if d.err != nil {
+			break
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return status(err)
+		}
+		return names(name{name: abs, long: abs, attrs: &Attrs{}})
+
+	case fxpReadlink:
+		path := d.string()
+		if d.err != nil {
+			break
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return status(err)
+		}
+		return names(name{name: target, long: target, attrs: &Attrs{}})
+
+	case fxpRemove, fxpRmdir, fxpMkdir, fxpRename, fxpSymlink:
+		if s.ReadOnly {
+			return status(errReadOnly)
+		}
+		return status(s.change(typ, d))
+	}
+
+	if d.err != nil {
+		return status(&StatusError{StatusBadMessage, d.err.Error()})
+	}
+	return status(&StatusError{StatusOpUnsupported, fmt.Sprintf("unsupported request %d", typ)})
+}
+
+// change handles requests that change the file system.
+func (s *Server) change(typ byte, d *decoder) error {
+	var err error
+	switch typ {
+	case fxpRemove:
+		path := d.string()
+		if d.err == nil {
+			// REMOVE must not remove directories.
+			if fi, serr := os.Lstat(path); serr == nil && fi.IsDir() {
+				return &StatusError{StatusFailure, path + ": is a directory"}
+			}
+			err = os.Remove(path)
+		}
+	case fxpRmdir:
+		path := d.string()
+		if d.err == nil {
+			if fi, serr := os.Lstat(path); serr == nil && !fi.IsDir() {
+				return &StatusError{StatusFailure, path + ": not a directory"}
+			}
+			err = os.Remove(path)
+		}
+	case fxpMkdir:
+		path, a := d.string(), d.attrs()
+		if d.err == nil {
+			perm := fs.FileMode(0o755)
+			if a.Flags&attrPermissions != 0 {
+				perm = a.FileMode().Perm()
+			}
+			err = os.Mkdir(path, perm)
+		}
+	case fxpRename:
+		from, to := d.string(), d.string()
+		if d.err == nil {
+			// Version 3 renames must not overwrite.
+			if _, serr := os.Lstat(to); serr == nil {
+				return &StatusError{StatusFailure, to + ": file exists"}
+			}
+			err = os.Rename(from, to)
+		}
+	case fxpSymlink:
+		// OpenSSH sends the target first, unlike the draft.
+		target, link := d.string(), d.string()
+		if d.err == nil {
+			err = os.Symlink(target, link)
+		}
+	}
+	if d.err != nil {
+		return &StatusError{StatusBadMessage, d.err.Error()}
+	}
+	return err
+}
+
+func (s *Server) readdir(dd *dir) reply {
+	if !dd.done {
+		entries, err := dd.f.ReadDir(-1)
+		if err != nil {
+			return status(err)
+		}
+		dd.entries, dd.done = entries, true
+	}
+	if len(dd.entries) == 0 {
+		return status(io.EOF)
+	}
+	n := min(len(dd.entries), readdirBatch)
+	var ns []name
+	for _, e := range dd.entries[:n] {
+		fi, err := os.Lstat(filepath.Join(dd.path, e.Name()))
+		if err != nil {
+			// Removed while listing.
+			continue
+		}
+		ns = append(ns, name{name: e.Name(), long: lsLine(e.Name(), fi), attrs: fileAttrs(fi)})
+	}
+	dd.entries = dd.entries[n:]
+	return names(ns...)
+}
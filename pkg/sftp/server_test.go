@@ -0,0 +1,241 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testConn speaks to a server, building requests by hand.
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	id   uint32
+}
+
+func startServer(t *testing.T, readOnly bool) *testConn {
+	t.Helper()
+	c, srv := net.Pipe()
+	s := NewServer(srv)
+	s.ReadOnly = readOnly
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve()
+		srv.Close()
+	}()
+	t.Cleanup(func() {
+		c.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	})
+
+	var b buffer
+	b.byte(fxpInit)
+	b.uint32(Version)
+	if err := writePacket(c, b); err != nil {
+		t.Fatal(err)
+	}
+	typ, d, err := readPacket(c)
+	if err != nil || typ != fxpVersion || d.uint32() != Version {
+		t.Fatalf("VERSION: type %d, err %v", typ, err)
+	}
+	return &testConn{t: t, conn: c}
+}
+
+// call sends a request and returns the type and rest of the reply.
+func (c *testConn) call(typ byte, args ...interface{}) (byte, *decoder) {
+	c.t.Helper()
+	c.id++
+	var b buffer
+	b.byte(typ)
+	b.uint32(c.id)
+	for _, a := range args {
+		switch v := a.(type) {
+		case string:
+			b.string(v)
+		case uint32:
+			b.uint32(v)
+		case uint64:
+			b.uint64(v)
+		case *Attrs:
+			b.attrs(v)
+		}
+	}
+	if err := writePacket(c.conn, b); err != nil {
+		c.t.Fatal(err)
+	}
+	rtyp, d, err := readPacket(c.conn)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if id := d.uint32(); id != c.id {
+		c.t.Fatalf("reply ID %d, want %d", id, c.id)
+	}
+	return rtyp, d
+}
+
+// status calls a request which returns a status.
+func (c *testConn) status(typ byte, args ...interface{}) uint32 {
+	c.t.Helper()
+	rtyp, d := c.call(typ, args...)
+	if rtyp != fxpStatus {
+		c.t.Fatalf("request %d: reply type %d, want STATUS", typ, rtyp)
+	}
+	return d.uint32()
+}
+
+func (c *testConn) handle(typ byte, args ...interface{}) string {
+	c.t.Helper()
+	rtyp, d := c.call(typ, args...)
+	if rtyp != fxpHandle {
+		c.t.Fatalf("request %d: reply type %d (status %d), want HANDLE", typ, rtyp, d.uint32())
+	}
+	return d.string()
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	c := startServer(t, false)
+	f := filepath.Join(dir, "f")
+
+	h := c.handle(fxpOpen, f, uint32(flagWrite|flagCreat|flagTrunc), &Attrs{Flags: attrPermissions, Perm: 0o600})
+	if st := c.status(fxpWrite, h, uint64(6), "world"); st != StatusOK {
+		t.Errorf("WRITE: status %d", st)
+	}
+	if st := c.status(fxpWrite, h, uint64(0), "hello "); st != StatusOK {
+		t.Errorf("WRITE: status %d", st)
+	}
+	if st := c.status(fxpClose, h); st != StatusOK {
+		t.Errorf("CLOSE: status %d", st)
+	}
+	if b, err := os.ReadFile(f); err != nil || string(b) != "hello world" {
+		t.Errorf("file %q, %v, want hello world", b, err)
+	}
+
+	typ, d := c.call(fxpStat, f)
+	a := d.attrs()
+	if typ != fxpAttrs || a.Size != 11 || a.Perm != modeRegular|0o600 || a.Flags&attrUIDGID == 0 {
+		t.Errorf("STAT: type %d attrs %+v", typ, a)
+	}
+
+	h = c.handle(fxpOpen, f, uint32(flagRead), &Attrs{})
+	typ, d = c.call(fxpRead, h, uint64(6), uint32(100))
+	if p := d.string(); typ != fxpData || p != "world" {
+		t.Errorf("READ: type %d data %q", typ, p)
+	}
+	if st := c.status(fxpRead, h, uint64(11), uint32(100)); st != StatusEOF {
+		t.Errorf("READ at EOF: status %d, want EOF", st)
+	}
+	if st := c.status(fxpClose, h); st != StatusOK {
+		t.Errorf("CLOSE: status %d", st)
+	}
+	if st := c.status(fxpClose, h); st != StatusFailure {
+		t.Errorf("CLOSE again: status %d, want failure", st)
+	}
+
+	if st := c.status(fxpMkdir, filepath.Join(dir, "d"), &Attrs{}); st != StatusOK {
+		t.Errorf("MKDIR: status %d", st)
+	}
+	if st := c.status(fxpSymlink, "f", filepath.Join(dir, "l")); st != StatusOK {
+		t.Errorf("SYMLINK: status %d", st)
+	}
+	typ, d = c.call(fxpReadlink, filepath.Join(dir, "l"))
+	if d.uint32(); typ != fxpName || d.string() != "f" {
+		t.Errorf("READLINK: type %d", typ)
+	}
+	if st := c.status(fxpRename, f, filepath.Join(dir, "d")); st != StatusFailure {
+		t.Errorf("RENAME over a directory: status %d, want failure", st)
+	}
+	if st := c.status(fxpRename, f, filepath.Join(dir, "g")); st != StatusOK {
+		t.Errorf("RENAME: status %d", st)
+	}
+	if st := c.status(fxpSetstat, filepath.Join(dir, "g"), &Attrs{Flags: attrSize | attrACModTime, Size: 5, Mtime: 1e9, Atime: 1e9}); st != StatusOK {
+		t.Errorf("SETSTAT: status %d", st)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "g")); err != nil || fi.Size() != 5 || fi.ModTime().Unix() != 1e9 {
+		t.Errorf("after SETSTAT: %v, %v", fi, err)
+	}
+
+	h = c.handle(fxpOpendir, dir)
+	var got []string
+	for {
+		typ, d := c.call(fxpReaddir, h)
+		if typ == fxpStatus {
+			if st := d.uint32(); st != StatusEOF {
+				t.Errorf("READDIR: status %d", st)
+			}
+			break
+		}
+		for n := d.uint32(); n > 0; n-- {
+			name, long := d.string(), d.string()
+			d.attrs()
+			got = append(got, name+" "+long[:1])
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"d d", "g -", "l l"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("READDIR = %v, want %v", got, want)
+	}
+	c.status(fxpClose, h)
+
+	if st := c.status(fxpRemove, filepath.Join(dir, "d")); st != StatusFailure {
+		t.Errorf("REMOVE of a directory: status %d, want failure", st)
+	}
+	if st := c.status(fxpRmdir, filepath.Join(dir, "d")); st != StatusOK {
+		t.Errorf("RMDIR: status %d", st)
+	}
+	if st := c.status(fxpRemove, filepath.Join(dir, "nope")); st != StatusNoSuchFile {
+		t.Errorf("REMOVE of nothing: status %d, want no such file", st)
+	}
+	if st := c.status(fxpOpen, "x"); st != StatusBadMessage {
+		t.Errorf("short OPEN: status %d, want bad message", st)
+	}
+	if st := c.status(200); st != StatusOpUnsupported {
+		t.Errorf("EXTENDED: status %d, want unsupported", st)
+	}
+}
+
+func TestServerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	c := startServer(t, true)
+	if st := c.status(fxpOpen, filepath.Join(dir, "f"), uint32(flagWrite|flagCreat), &Attrs{}); st != StatusPermissionDenied {
+		t.Errorf("OPEN for writing: status %d, want permission denied", st)
+	}
+	if st := c.status(fxpMkdir, filepath.Join(dir, "d"), &Attrs{}); st != StatusPermissionDenied {
+		t.Errorf("MKDIR: status %d, want permission denied", st)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "d")); err == nil {
+		t.Errorf("MKDIR of a read-only server made a directory")
+	}
+	h := c.handle(fxpOpendir, dir)
+	if st := c.status(fxpReaddir, h); st != StatusEOF {
+		t.Errorf("READDIR of an empty directory: status %d, want EOF", st)
+	}
+}
+
+func TestBadInit(t *testing.T) {
+	c, srv := net.Pipe()
+	defer c.Close()
+	done := make(chan error, 1)
+	go func() { done <- NewServer(srv).Serve() }()
+	var b buffer
+	b.byte(fxpOpen)
+	b.uint32(1)
+	writePacket(c, b)
+	if err := <-done; err == nil {
+		t.Errorf("Serve without INIT succeeded")
+	}
+	srv.Close()
+	var l [4]byte
+	if _, err := io.ReadFull(c, l[:]); err == nil {
+		t.Errorf("server replied without INIT")
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sftp implements version 3 of the SSH file transfer protocol, as
+// in draft-ietf-secsh-filexfer-02 and spoken by OpenSSH.
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// Version is the protocol version.
+const Version = 3
+
+// Packet types.
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpFsetstat = 10
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpRmdir    = 15
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpReadlink = 19
+	fxpSymlink  = 20
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+// Status codes.
+const (
+	StatusOK               = 0
+	StatusEOF              = 1
+	StatusNoSuchFile       = 2
+	StatusPermissionDenied = 3
+	StatusFailure          = 4
+	StatusBadMessage       = 5
+	StatusOpUnsupported    = 8
+)
+
+// Flags of OPEN.
+const (
+	flagRead   = 0x01
+	flagWrite  = 0x02
+	flagAppend = 0x04
+	flagCreat  = 0x08
+	flagTrunc  = 0x10
+	flagExcl   = 0x20
+)
+
+// Flags of the attributes present.
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+	attrExtended    = 0x80000000
+)
+
+// maxPacket is the largest packet accepted; OpenSSH allows 256 KiB.
+const maxPacket = 256 << 10
+
+// ErrBadPacket is returned for packets which cannot be decoded.
+var ErrBadPacket = errors.New("bad sftp packet")
+
+// StatusError is a failure reported by the other side.
+type StatusError struct {
+	Code uint32
+	Msg  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (status %d)", e.Msg, e.Code)
+}
+
+// Is makes errors.Is(err, fs.ErrNotExist) and fs.ErrPermission work for
+// the matching statuses, and io.EOF for the end of a file.
+func (e *StatusError) Is(target error) bool {
+	switch e.Code {
+	case StatusNoSuchFile:
+		return target == fs.ErrNotExist
+	case StatusPermissionDenied:
+		return target == fs.ErrPermission
+	case StatusEOF:
+		return target == io.EOF
+	}
+	return false
+}
+
+// statusOf returns the status for an error.
+func statusOf(err error) *StatusError {
+	var se *StatusError
+	switch {
+	case err == nil:
+		return &StatusError{StatusOK, "OK"}
+	case errors.As(err, &se):
+		return se
+	case errors.Is(err, io.EOF):
+		return &StatusError{StatusEOF, "EOF"}
+	case errors.Is(err, fs.ErrNotExist):
+		return &StatusError{StatusNoSuchFile, err.Error()}
+	case errors.Is(err, fs.ErrPermission):
+		return &StatusError{StatusPermissionDenied, err.Error()}
+	}
+	return &StatusError{StatusFailure, err.Error()}
+}
+
+// Attrs are the attributes of a file. Flags says which are set.
+type Attrs struct {
+	Flags uint32
+	Size  uint64
+	UID   uint32
+	GID   uint32
+	// Perm has the Unix mode, including the file type, e.g. 0100644.
+	Perm  uint32
+	Atime uint32
+	Mtime uint32
+}
+
+// Unix file type bits of Attrs.Perm.
+const (
+	modeType    = 0o170000
+	modeDir     = 0o040000
+	modeRegular = 0o100000
+	modeSymlink = 0o120000
+	modeFIFO    = 0o010000
+	modeSocket  = 0o140000
+	modeChar    = 0o020000
+	modeBlock   = 0o060000
+)
+
+// FileMode returns the mode of the attributes.
+func (a *Attrs) FileMode() fs.FileMode {
+	m := fs.FileMode(a.Perm & 0o777)
+	if a.Perm&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if a.Perm&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if a.Perm&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	switch a.Perm & modeType {
+	case modeDir:
+		m |= fs.ModeDir
+	case modeSymlink:
+		m |= fs.ModeSymlink
+	case modeFIFO:
+		m |= fs.ModeNamedPipe
+	case modeSocket:
+		m |= fs.ModeSocket
+	case modeChar:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	case modeBlock:
+		m |= fs.ModeDevice
+	}
+	return m
+}
+
+// unixMode returns the Unix mode of a FileMode.
+func unixMode(m fs.FileMode) uint32 {
+	p := uint32(m.Perm())
+	if m&fs.ModeSetuid != 0 {
+		p |= 0o4000
+	}
+	if m&fs.ModeSetgid != 0 {
+		p |= 0o2000
+	}
+	if m&fs.ModeSticky != 0 {
+		p |= 0o1000
+	}
+	switch {
+	case m.IsDir():
+		p |= modeDir
+	case m&fs.ModeSymlink != 0:
+		p |= modeSymlink
+	case m&fs.ModeNamedPipe != 0:
+		p |= modeFIFO
+	case m&fs.ModeSocket != 0:
+		p |= modeSocket
+	case m&fs.ModeCharDevice != 0:
+		p |= modeChar
+	case m&fs.ModeDevice != 0:
+		p |= modeBlock
+	default:
+		p |= modeRegular
+	}
+	return p
+}
+
+// ModTime returns the modification time of the attributes.
+func (a *Attrs) ModTime() time.Time {
+	return time.Unix(int64(a.Mtime), 0)
+}
+
+// fileAttrs returns the attributes of a file.
+func fileAttrs(fi fs.FileInfo) *Attrs {
+	a := &Attrs{
+		Flags: attrSize | attrPermissions | attrACModTime,
+		Size:  uint64(fi.Size()),
+		Perm:  unixMode(fi.Mode()),
+		Mtime: uint32(fi.ModTime().Unix()),
+	}
+	// The access time is not portable, and rarely useful.
+	a.Atime = a.Mtime
+	if uid, gid, ok := owner(fi); ok {
+		a.Flags |= attrUIDGID
+		a.UID, a.GID = uid, gid
+	}
+	return a
+}
+
+// fileInfo is an fs.FileInfo for attributes.
+type fileInfo struct {
+	name  string
+	attrs *Attrs
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return int64(fi.attrs.Size) }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.attrs.FileMode() }
+func (fi *fileInfo) ModTime() time.Time { return fi.attrs.ModTime() }
+func (fi *fileInfo) IsDir() bool        { return fi.Mode().IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return fi.attrs }
+
+// buffer encodes packets.
+type buffer []byte
+
+func (b *buffer) byte(v byte)     { *b = append(*b, v) }
+func (b *buffer) uint32(v uint32) { *b = binary.BigEndian.AppendUint32(*b, v) }
+func (b *buffer) uint64(v uint64) { *b = binary.BigEndian.AppendUint64(*b, v) }
+
+func (b *buffer) string(s string) {
+	b.uint32(uint32(len(s)))
+	*b = append(*b, s...)
+}
+
+func (b *buffer) attrs(a *Attrs) {
+	flags := a.Flags &^ attrExtended
+	b.uint32(flags)
+	if flags&attrSize != 0 {
+		b.uint64(a.Size)
+	}
+	if flags&attrUIDGID != 0 {
+		b.uint32(a.UID)
+		b.uint32(a.GID)
+	}
+	if flags&attrPermissions != 0 {
+		b.uint32(a.Perm)
+	}
+	if flags&attrACModTime != 0 {
+		b.uint32(a.Atime)
+		b.uint32(a.Mtime)
+	}
+}
+
+// decoder decodes packets. Errors are sticky: once a field cannot be read,
+// all further fields are zero and err is set.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.b) < n {
+		d.err = ErrBadPacket
+		return make([]byte, n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte     { return d.next(1)[0] }
+func (d *decoder) uint32() uint32 { return binary.BigEndian.Uint32(d.next(4)) }
+func (d *decoder) uint64() uint64 { return binary.BigEndian.Uint64(d.next(8)) }
+
+func (d *decoder) string() string {
+	n := d.uint32()
+	if d.err != nil || uint64(n) > uint64(len(d.b)) {
+		d.err = ErrBadPacket
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) attrs() *Attrs {
+	a := &Attrs{Flags: d.uint32()}
+	if a.Flags&attrSize != 0 {
+		a.Size = d.uint64()
+	}
+	if a.Flags&attrUIDGID != 0 {
+		a.UID, a.GID = d.uint32(), d.uint32()
+	}
+	if a.Flags&attrPermissions != 0 {
+		a.Perm = d.uint32()
+	}
+	if a.Flags&attrACModTime != 0 {
+		a.Atime, a.Mtime = d.uint32(), d.uint32()
+	}
+	if a.Flags&attrExtended != 0 {
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.string()
+		}
+	}
+	a.Flags &^= attrExtended
+	return a
+}
+
+// writePacket writes a packet with its length.
+func writePacket(w io.Writer, p buffer) error {
+	b := make(buffer, 0, len(p)+4)
+	b.uint32(uint32(len(p)))
+	b = append(b, p...)
+	_, err := w.Write(b)
+	return err
+}
+
+// readPacket reads a packet, returning its type and the rest.
+func readPacket(r io.Reader) (byte, *decoder, error) {
+	var l [4]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(l[:])
+	if n == 0 || n > maxPacket {
+		return 0, nil, fmt.Errorf("%w: length %d", ErrBadPacket, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+	return b[0], &decoder{b: b[1:]}, nil
+}
+
+// lsLine formats a file as ls -l does, for the long names of READDIR.
+func lsLine(name string, fi fs.FileInfo) string {
+	a := fileAttrs(fi)
+	t := fi.ModTime()
+	date := t.Format("Jan _2 15:04")
+	if time.Since(t) > 182*24*time.Hour || time.Until(t) > time.Hour {
+		date = t.Format("Jan _2  2006")
+	}
+	typ := "-"
+	switch m := fi.Mode(); {
+	case m.IsDir():
+		typ = "d"
+	case m&fs.ModeSymlink != 0:
+		typ = "l"
+	case m&fs.ModeCharDevice != 0:
+		typ = "c"
+	case m&fs.ModeDevice != 0:
+		typ = "b"
+	case m&fs.ModeNamedPipe != 0:
+		typ = "p"
+	case m&fs.ModeSocket != 0:
+		typ = "s"
+	}
+	mode := typ + fi.Mode().Perm().String()[1:]
+	return fmt.Sprintf("%s %4d %-8d %-8d %8d %s %s", mode, 1, a.UID, a.GID, a.Size, date, name)
+}
@@ -24,9 +24,12 @@ func (h *Header) Parse(data []byte) error {
 
 // String returns string representation os the header.
 func (h *Header) String() string {
-	return fmt.Sprintf(
-		"Handle 0x%04X, DMI type %d, %d bytes\n%s",
-		h.Handle, h.Type, h.Length, h.Type)
+	return fmt.Sprintf("%s\n%s", h.handleString(), h.Type)
+}
+
+// handleString returns the first line of String, without the table name.
+func (h *Header) handleString() string {
+	return fmt.Sprintf("Handle 0x%04X, DMI type %d, %d bytes", h.Handle, h.Type, h.Length)
 }
 
 // MarshalBinary encodes the Header content into a binary
@@ -15,7 +15,7 @@ import (
 // Table is a generic type of table that does not parsed fields,
 // it only allows access to its contents by offset.
 type Table struct {
-	Header
+	Header  `json:"-"`
 	data    []byte   `smbios:"-"` // Structured part of the table, this includes the raw data in Header.
 	strings []string `smbios:"-"` // Strings section.
 }
@@ -105,17 +105,26 @@ func (t *Table) GetStringAt(offset int) (string, error) {
 	if offset >= len(t.data) {
 		return "", fmt.Errorf("invalid offset %d", offset)
 	}
-	stringIndex := t.data[offset]
+	return t.getString(t.data[offset])
+}
+
+// getString returns the string with the 1-based index in the strings section.
+func (t *Table) getString(index uint8) (string, error) {
 	switch {
-	case stringIndex == 0:
+	case index == 0:
 		return "Not Specified", nil
-	case int(stringIndex) <= len(t.strings):
-		return t.strings[stringIndex-1], nil
+	case int(index) <= len(t.strings):
+		return t.strings[index-1], nil
 	default:
-		return "<BAD INDEX>", fmt.Errorf("invalid string index %d", stringIndex)
+		return "<BAD INDEX>", fmt.Errorf("invalid string index %d", index)
 	}
 }
 
+// Strings returns the strings section of the table.
+func (t *Table) Strings() []string {
+	return t.strings
+}
+
 func (t *Table) String() string {
 	lines := []string{
 		t.Header.String(),
@@ -199,3 +208,19 @@ func kmgt(v uint64) string {
 		return fmt.Sprintf("%d bytes", v)
 	}
 }
+
+// bitList returns the names of the bits set in v as an indented list, one
+// per line, or " None" if no bit is set. It is meant to follow a "Name:"
+// label.
+func bitList(v uint64, names []string) string {
+	var s strings.Builder
+	for i, name := range names {
+		if v&(1<<i) != 0 {
+			s.WriteString("\n\t\t" + name)
+		}
+	}
+	if s.Len() == 0 {
+		return " None"
+	}
+	return s.String()
+}
@@ -14,18 +14,52 @@ type TableType uint8
 
 // Supported table types.
 const (
-	TableTypeBIOSInfo       TableType = 0
-	TableTypeSystemInfo     TableType = 1
-	TableTypeBaseboardInfo  TableType = 2
-	TableTypeChassisInfo    TableType = 3
-	TableTypeProcessorInfo  TableType = 4
-	TableTypeCacheInfo      TableType = 7
-	TableTypeSystemSlots    TableType = 9
-	TableTypeMemoryDevice   TableType = 17
-	TableTypeIPMIDeviceInfo TableType = 38
-	TableTypeTPMDevice      TableType = 43
-	TableTypeInactive       TableType = 126
-	TableTypeEndOfTable     TableType = 127
+	TableTypeBIOSInfo                          TableType = 0
+	TableTypeSystemInfo                        TableType = 1
+	TableTypeBaseboardInfo                     TableType = 2
+	TableTypeChassisInfo                       TableType = 3
+	TableTypeProcessorInfo                     TableType = 4
+	TableTypeMemoryControllerInfo              TableType = 5
+	TableTypeMemoryModuleInfo                  TableType = 6
+	TableTypeCacheInfo                         TableType = 7
+	TableTypePortConnectorInfo                 TableType = 8
+	TableTypeSystemSlots                       TableType = 9
+	TableTypeOnBoardDevicesInfo                TableType = 10
+	TableTypeOEMStrings                        TableType = 11
+	TableTypeSystemConfigOptions               TableType = 12
+	TableTypeBIOSLanguageInfo                  TableType = 13
+	TableTypeGroupAssociations                 TableType = 14
+	TableTypeSystemEventLog                    TableType = 15
+	TableTypePhysicalMemoryArray               TableType = 16
+	TableTypeMemoryDevice                      TableType = 17
+	TableTypeMemoryError32                     TableType = 18
+	TableTypeMemoryArrayMappedAddress          TableType = 19
+	TableTypeMemoryDeviceMappedAddress         TableType = 20
+	TableTypePointingDevice                    TableType = 21
+	TableTypePortableBattery                   TableType = 22
+	TableTypeSystemReset                       TableType = 23
+	TableTypeHardwareSecurity                  TableType = 24
+	TableTypeSystemPowerControls               TableType = 25
+	TableTypeVoltageProbe                      TableType = 26
+	TableTypeCoolingDevice                     TableType = 27
+	TableTypeTemperatureProbe                  TableType = 28
+	TableTypeCurrentProbe                      TableType = 29
+	TableTypeOutOfBandRemoteAccess             TableType = 30
+	TableTypeBootIntegrityServices             TableType = 31
+	TableTypeSystemBootInfo                    TableType = 32
+	TableTypeMemoryError64                     TableType = 33
+	TableTypeManagementDevice                  TableType = 34
+	TableTypeManagementDeviceComponent         TableType = 35
+	TableTypeManagementDeviceThreshold         TableType = 36
+	TableTypeMemoryChannel                     TableType = 37
+	TableTypeIPMIDeviceInfo                    TableType = 38
+	TableTypeSystemPowerSupply                 TableType = 39
+	TableTypeAdditionalInfo                    TableType = 40
+	TableTypeOnboardDevice                     TableType = 41
+	TableTypeManagementControllerHostInterface TableType = 42
+	TableTypeTPMDevice                         TableType = 43
+	TableTypeInactive                          TableType = 126
+	TableTypeEndOfTable                        TableType = 127
 )
 
 func (t TableType) String() string {
@@ -40,14 +74,82 @@ func (t TableType) String() string {
 		return "Chassis Information"
 	case TableTypeProcessorInfo:
 		return "Processor Information"
+	case TableTypeMemoryControllerInfo:
+		return "Memory Controller Information"
+	case TableTypeMemoryModuleInfo:
+		return "Memory Module Information"
 	case TableTypeCacheInfo:
 		return "Cache Information"
+	case TableTypePortConnectorInfo:
+		return "Port Connector Information"
 	case TableTypeSystemSlots:
-		return "System Slots"
+		return "System Slot Information"
+	case TableTypeOnBoardDevicesInfo:
+		return "On Board Devices Information"
+	case TableTypeOEMStrings:
+		return "OEM Strings"
+	case TableTypeSystemConfigOptions:
+		return "System Configuration Options"
+	case TableTypeBIOSLanguageInfo:
+		return "BIOS Language Information"
+	case TableTypeGroupAssociations:
+		return "Group Associations"
+	case TableTypeSystemEventLog:
+		return "System Event Log"
+	case TableTypePhysicalMemoryArray:
+		return "Physical Memory Array"
 	case TableTypeMemoryDevice:
 		return "Memory Device"
+	case TableTypeMemoryError32:
+		return "32-bit Memory Error Information"
+	case TableTypeMemoryArrayMappedAddress:
+		return "Memory Array Mapped Address"
+	case TableTypeMemoryDeviceMappedAddress:
+		return "Memory Device Mapped Address"
+	case TableTypePointingDevice:
+		return "Built-in Pointing Device"
+	case TableTypePortableBattery:
+		return "Portable Battery"
+	case TableTypeSystemReset:
+		return "System Reset"
+	case TableTypeHardwareSecurity:
+		return "Hardware Security"
+	case TableTypeSystemPowerControls:
+		return "System Power Controls"
+	case TableTypeVoltageProbe:
+		return "Voltage Probe"
+	case TableTypeCoolingDevice:
+		return "Cooling Device"
+	case TableTypeTemperatureProbe:
+		return "Temperature Probe"
+	case TableTypeCurrentProbe:
+		return "Electrical Current Probe"
+	case TableTypeOutOfBandRemoteAccess:
+		return "Out-of-band Remote Access"
+	case TableTypeBootIntegrityServices:
+		return "Boot Integrity Services Entry Point"
+	case TableTypeSystemBootInfo:
+		return "System Boot Information"
+	case TableTypeMemoryError64:
+		return "64-bit Memory Error Information"
+	case TableTypeManagementDevice:
+		return "Management Device"
+	case TableTypeManagementDeviceComponent:
+		return "Management Device Component"
+	case TableTypeManagementDeviceThreshold:
+		return "Management Device Threshold Data"
+	case TableTypeMemoryChannel:
+		return "Memory Channel"
 	case TableTypeIPMIDeviceInfo:
 		return "IPMI Device Information"
+	case TableTypeSystemPowerSupply:
+		return "System Power Supply"
+	case TableTypeAdditionalInfo:
+		return "Additional Information"
+	case TableTypeOnboardDevice:
+		return "Onboard Device"
+	case TableTypeManagementControllerHostInterface:
+		return "Management Controller Host Interface"
 	case TableTypeTPMDevice:
 		return "TPM Device"
 	case TableTypeInactive:
@@ -75,14 +177,82 @@ func ParseTypedTable(t *Table) (fmt.Stringer, error) {
 		return ParseChassisInfo(t)
 	case TableTypeProcessorInfo: // 4
 		return ParseProcessorInfo(t)
+	case TableTypeMemoryControllerInfo: // 5
+		return ParseMemoryControllerInfo(t)
+	case TableTypeMemoryModuleInfo: // 6
+		return ParseMemoryModuleInfo(t)
 	case TableTypeCacheInfo: // 7
 		return ParseCacheInfo(t)
+	case TableTypePortConnectorInfo: // 8
+		return ParsePortConnectorInfo(t)
 	case TableTypeSystemSlots: // 9
 		return ParseSystemSlots(t)
+	case TableTypeOnBoardDevicesInfo: // 10
+		return ParseOnBoardDevicesInfo(t)
+	case TableTypeOEMStrings: // 11
+		return ParseOEMStrings(t)
+	case TableTypeSystemConfigOptions: // 12
+		return ParseSystemConfigOptions(t)
+	case TableTypeBIOSLanguageInfo: // 13
+		return ParseBIOSLanguageInfo(t)
+	case TableTypeGroupAssociations: // 14
+		return ParseGroupAssociations(t)
+	case TableTypeSystemEventLog: // 15
+		return ParseSystemEventLog(t)
+	case TableTypePhysicalMemoryArray: // 16
+		return ParsePhysicalMemoryArray(t)
 	case TableTypeMemoryDevice: // 17
 		return NewMemoryDevice(t)
+	case TableTypeMemoryError32: // 18
+		return ParseMemoryError32(t)
+	case TableTypeMemoryArrayMappedAddress: // 19
+		return ParseMemoryArrayMappedAddress(t)
+	case TableTypeMemoryDeviceMappedAddress: // 20
+		return ParseMemoryDeviceMappedAddress(t)
+	case TableTypePointingDevice: // 21
+		return ParsePointingDevice(t)
+	case TableTypePortableBattery: // 22
+		return ParsePortableBattery(t)
+	case TableTypeSystemReset: // 23
+		return ParseSystemReset(t)
+	case TableTypeHardwareSecurity: // 24
+		return ParseHardwareSecurity(t)
+	case TableTypeSystemPowerControls: // 25
+		return ParseSystemPowerControls(t)
+	case TableTypeVoltageProbe: // 26
+		return ParseVoltageProbe(t)
+	case TableTypeCoolingDevice: // 27
+		return ParseCoolingDevice(t)
+	case TableTypeTemperatureProbe: // 28
+		return ParseTemperatureProbe(t)
+	case TableTypeCurrentProbe: // 29
+		return ParseCurrentProbe(t)
+	case TableTypeOutOfBandRemoteAccess: // 30
+		return ParseOutOfBandRemoteAccess(t)
+	case TableTypeBootIntegrityServices: // 31
+		return ParseBootIntegrityServices(t)
+	case TableTypeSystemBootInfo: // 32
+		return ParseSystemBootInfo(t)
+	case TableTypeMemoryError64: // 33
+		return ParseMemoryError64(t)
+	case TableTypeManagementDevice: // 34
+		return ParseManagementDevice(t)
+	case TableTypeManagementDeviceComponent: // 35
+		return ParseManagementDeviceComponent(t)
+	case TableTypeManagementDeviceThreshold: // 36
+		return ParseManagementDeviceThreshold(t)
+	case TableTypeMemoryChannel: // 37
+		return ParseMemoryChannel(t)
 	case TableTypeIPMIDeviceInfo: // 38
 		return ParseIPMIDeviceInfo(t)
+	case TableTypeSystemPowerSupply: // 39
+		return ParseSystemPowerSupply(t)
+	case TableTypeAdditionalInfo: // 40
+		return ParseAdditionalInfo(t)
+	case TableTypeOnboardDevice: // 41
+		return ParseOnboardDevice(t)
+	case TableTypeManagementControllerHostInterface: // 42
+		return ParseManagementControllerHostInterface(t)
 	case TableTypeTPMDevice: // 43
 		return NewTPMDevice(t)
 	case TableTypeInactive: // 126
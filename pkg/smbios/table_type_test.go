@@ -38,7 +38,7 @@ func TestTableTypeString(t *testing.T) {
 		},
 		{
 			tableType: TableTypeSystemSlots,
-			want:      "System Slots",
+			want:      "System Slot Information",
 		},
 		{
 			tableType: TableTypeMemoryDevice,
@@ -61,7 +61,15 @@ func TestTableTypeString(t *testing.T) {
 			want:      "End Of Table",
 		},
 		{
-			tableType: TableType(0x12),
+			tableType: TableTypeMemoryError32,
+			want:      "32-bit Memory Error Information",
+		},
+		{
+			tableType: TableTypeOnboardDevice,
+			want:      "Onboard Device",
+		},
+		{
+			tableType: TableType(0x2c),
 			want:      "Unsupported",
 		},
 		{
@@ -0,0 +1,99 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OnBoardDevicesInfo is defined in DSP0134 7.11. The structure is obsolete
+// since SMBIOS 2.6, Onboard Devices Extended Information (type 41) replaces
+// it.
+type OnBoardDevicesInfo struct {
+	Table
+	Devices []OnBoardDevice `smbios:"-"` // 04h
+}
+
+// OnBoardDevice is a device of OnBoardDevicesInfo.
+type OnBoardDevice struct {
+	Type        OnBoardDeviceType // 00h, bits 0-6
+	Enabled     bool              // 00h, bit 7
+	Description string            // 01h
+}
+
+// ParseOnBoardDevicesInfo parses a generic Table into OnBoardDevicesInfo.
+func ParseOnBoardDevicesInfo(t *Table) (*OnBoardDevicesInfo, error) {
+	if t.Type != TableTypeOnBoardDevicesInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x6 {
+		return nil, errors.New("required fields missing")
+	}
+	di := &OnBoardDevicesInfo{Table: *t}
+	for off := 4; off+1 < t.Len(); off += 2 {
+		typ, _ := t.GetByteAt(off)
+		desc, _ := t.GetStringAt(off + 1)
+		di.Devices = append(di.Devices, OnBoardDevice{
+			Type:        OnBoardDeviceType(typ & 0x7f),
+			Enabled:     typ&0x80 != 0,
+			Description: desc,
+		})
+	}
+	return di, nil
+}
+
+func (di *OnBoardDevicesInfo) String() string {
+	lines := []string{di.Header.handleString()}
+	for i, d := range di.Devices {
+		if len(di.Devices) == 1 {
+			lines = append(lines, "On Board Device Information")
+		} else {
+			lines = append(lines, fmt.Sprintf("On Board Device %d Information", i+1))
+		}
+		lines = append(lines,
+			fmt.Sprintf("\tType: %s", d.Type),
+			fmt.Sprintf("\tStatus: %s", enabledString(d.Enabled)),
+			fmt.Sprintf("\tDescription: %s", d.Description),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "Enabled"
+	}
+	return "Disabled"
+}
+
+// OnBoardDeviceType is defined in DSP0134 7.11.1.
+type OnBoardDeviceType uint8
+
+func (v OnBoardDeviceType) String() string {
+	names := map[OnBoardDeviceType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Video",
+		0x04: "SCSI Controller",
+		0x05: "Ethernet",
+		0x06: "Token Ring",
+		0x07: "Sound",
+		0x08: "PATA Controller",
+		0x09: "SATA Controller",
+		0x0a: "SAS Controller",
+		0x0b: "Wireless LAN",
+		0x0c: "Bluetooth",
+		0x0d: "WWAN",
+		0x0e: "eMMC",
+		0x0f: "NVMe Controller",
+		0x10: "UFS Controller",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OEMStrings is defined in DSP0134 7.12.
+type OEMStrings struct {
+	Table
+	Count  uint8    // 04h
+	Values []string `smbios:"-"`
+}
+
+// ParseOEMStrings parses a generic Table into OEMStrings.
+func ParseOEMStrings(t *Table) (*OEMStrings, error) {
+	if t.Type != TableTypeOEMStrings {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	ot := &OEMStrings{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ot); err != nil {
+		return nil, err
+	}
+	ot.Values = t.stringList(ot.Count)
+	return ot, nil
+}
+
+func (ot *OEMStrings) String() string {
+	lines := []string{ot.Header.String()}
+	for i, s := range ot.Values {
+		lines = append(lines, fmt.Sprintf("String %d: %s", i+1, s))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// stringList returns the first n strings of the table.
+func (t *Table) stringList(n uint8) []string {
+	var l []string
+	for i := uint8(1); i <= n && i != 0; i++ {
+		s, _ := t.getString(i)
+		l = append(l, s)
+	}
+	return l
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemConfigOptions is defined in DSP0134 7.13.
+type SystemConfigOptions struct {
+	Table
+	Count   uint8    // 04h
+	Options []string `smbios:"-"`
+}
+
+// ParseSystemConfigOptions parses a generic Table into SystemConfigOptions.
+func ParseSystemConfigOptions(t *Table) (*SystemConfigOptions, error) {
+	if t.Type != TableTypeSystemConfigOptions {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	co := &SystemConfigOptions{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, co); err != nil {
+		return nil, err
+	}
+	co.Options = t.stringList(co.Count)
+	return co, nil
+}
+
+func (co *SystemConfigOptions) String() string {
+	lines := []string{co.Header.String()}
+	for i, s := range co.Options {
+		lines = append(lines, fmt.Sprintf("Option %d: %s", i+1, s))
+	}
+	return strings.Join(lines, "\n\t")
+}
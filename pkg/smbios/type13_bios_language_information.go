@@ -0,0 +1,54 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BIOSLanguageInfo is defined in DSP0134 7.14.
+type BIOSLanguageInfo struct {
+	Table
+	InstallableLanguages uint8    // 04h
+	Flags                uint8    // 05h
+	CurrentLanguage      string   `smbios:"-"` // 15h
+	Languages            []string `smbios:"-"`
+}
+
+// ParseBIOSLanguageInfo parses a generic Table into BIOSLanguageInfo.
+func ParseBIOSLanguageInfo(t *Table) (*BIOSLanguageInfo, error) {
+	if t.Type != TableTypeBIOSLanguageInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x16 {
+		return nil, errors.New("required fields missing")
+	}
+	li := &BIOSLanguageInfo{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, li); err != nil {
+		return nil, err
+	}
+	li.CurrentLanguage, _ = t.GetStringAt(0x15)
+	li.Languages = t.stringList(li.InstallableLanguages)
+	return li, nil
+}
+
+func (li *BIOSLanguageInfo) String() string {
+	format := "Long"
+	if li.Flags&1 != 0 {
+		format = "Abbreviated"
+	}
+	lines := []string{
+		li.Header.String(),
+		fmt.Sprintf("Language Description Format: %s", format),
+		fmt.Sprintf("Installable Languages: %d", li.InstallableLanguages),
+	}
+	for _, l := range li.Languages {
+		lines = append(lines, "\t"+l)
+	}
+	lines = append(lines, fmt.Sprintf("Currently Installed Language: %s", li.CurrentLanguage))
+	return strings.Join(lines, "\n\t")
+}
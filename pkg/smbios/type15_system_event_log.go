@@ -0,0 +1,194 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemEventLog is defined in DSP0134 7.16.
+type SystemEventLog struct {
+	Table
+	LogAreaLength       uint16          // 04h
+	LogHeaderStart      uint16          // 06h
+	LogDataStart        uint16          // 08h
+	AccessMethod        uint8           // 0Ah
+	LogStatus           uint8           // 0Bh
+	LogChangeToken      uint32          // 0Ch
+	AccessMethodAddress uint32          // 10h
+	LogHeaderFormat     uint8           // 14h
+	NumDescriptors      uint8           // 15h
+	DescriptorLength    uint8           // 16h
+	Descriptors         []LogDescriptor `smbios:"-"` // 17h
+}
+
+// LogDescriptor is defined in DSP0134 7.16.1.
+type LogDescriptor struct {
+	Type       LogType       // 00h
+	DataFormat LogDataFormat // 01h
+}
+
+// ParseSystemEventLog parses a generic Table into SystemEventLog.
+func ParseSystemEventLog(t *Table) (*SystemEventLog, error) {
+	if t.Type != TableTypeSystemEventLog {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x14 {
+		return nil, errors.New("required fields missing")
+	}
+	el := &SystemEventLog{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, el)
+	if err != nil {
+		return nil, err
+	}
+	if el.DescriptorLength >= 2 && t.Len() >= off+int(el.NumDescriptors)*int(el.DescriptorLength) {
+		for i := 0; i < int(el.NumDescriptors); i++ {
+			typ, _ := t.GetByteAt(off)
+			format, _ := t.GetByteAt(off + 1)
+			el.Descriptors = append(el.Descriptors, LogDescriptor{Type: LogType(typ), DataFormat: LogDataFormat(format)})
+			off += int(el.DescriptorLength)
+		}
+	}
+	return el, nil
+}
+
+func (el *SystemEventLog) String() string {
+	valid, full := "Invalid", "Not Full"
+	if el.LogStatus&1 != 0 {
+		valid = "Valid"
+	}
+	if el.LogStatus&2 != 0 {
+		full = "Full"
+	}
+	lines := []string{
+		el.Header.String(),
+		fmt.Sprintf("Area Length: %d bytes", el.LogAreaLength),
+		fmt.Sprintf("Header Start Offset: 0x%04X", el.LogHeaderStart),
+	}
+	if el.LogDataStart != el.LogHeaderStart {
+		lines = append(lines, fmt.Sprintf("Header Length: %d bytes", el.LogDataStart-el.LogHeaderStart))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Data Start Offset: 0x%04X", el.LogDataStart),
+		fmt.Sprintf("Access Method: %s", el.accessMethod()),
+		fmt.Sprintf("Access Address: %s", el.accessAddress()),
+		fmt.Sprintf("Status: %s, %s", valid, full),
+		fmt.Sprintf("Change Token: 0x%08X", el.LogChangeToken),
+	)
+	if el.Len() >= 0x17 {
+		format := outOfSpec
+		switch {
+		case el.LogHeaderFormat == 0:
+			format = "No Header"
+		case el.LogHeaderFormat == 1:
+			format = "Type 1"
+		case el.LogHeaderFormat >= 0x80:
+			format = "OEM-specific"
+		}
+		lines = append(lines,
+			fmt.Sprintf("Header Format: %s", format),
+			fmt.Sprintf("Supported Log Type Descriptors: %d", el.NumDescriptors),
+		)
+		for i, d := range el.Descriptors {
+			lines = append(lines,
+				fmt.Sprintf("Descriptor %d: %s", i+1, d.Type),
+				fmt.Sprintf("Data Format %d: %s", i+1, d.DataFormat),
+			)
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+func (el *SystemEventLog) accessMethod() string {
+	methods := []string{
+		"Byte-indexed I/O, one 8-bit index port, one 8-bit data port",
+		"Byte-indexed I/O, two 8-bit index ports, one 8-bit data port",
+		"Word-indexed I/O, one 16-bit index port, one 8-bit data port",
+		"Memory-mapped physical 32-bit address",
+		"General-purpose non-volatile data functions",
+	}
+	switch {
+	case int(el.AccessMethod) < len(methods):
+		return methods[el.AccessMethod]
+	case el.AccessMethod >= 0x80:
+		return "OEM-specific"
+	}
+	return outOfSpec
+}
+
+func (el *SystemEventLog) accessAddress() string {
+	a := el.AccessMethodAddress
+	switch el.AccessMethod {
+	case 0, 1, 2:
+		return fmt.Sprintf("Index 0x%04X, Data 0x%04X", a&0xffff, a>>16)
+	case 3:
+		return fmt.Sprintf("0x%08X", a)
+	case 4:
+		return fmt.Sprintf("0x%04X", a&0xffff)
+	}
+	return "Unknown"
+}
+
+// LogType is defined in DSP0134 7.16.6.1.
+type LogType uint8
+
+func (v LogType) String() string {
+	names := map[LogType]string{
+		0x01: "Single-bit ECC memory error",
+		0x02: "Multi-bit ECC memory error",
+		0x03: "Parity memory error",
+		0x04: "Bus timeout",
+		0x05: "I/O channel block",
+		0x06: "Software NMI",
+		0x07: "POST memory resize",
+		0x08: "POST error",
+		0x09: "PCI parity error",
+		0x0a: "PCI system error",
+		0x0b: "CPU failure",
+		0x0c: "EISA failsafe timer timeout",
+		0x0d: "Correctable memory log disabled",
+		0x0e: "Logging disabled",
+		0x10: "System limit exceeded",
+		0x11: "Asynchronous hardware timer expired",
+		0x12: "System configuration information",
+		0x13: "Hard disk information",
+		0x14: "System reconfigured",
+		0x15: "Uncorrectable CPU-complex error",
+		0x16: "Log area reset/cleared",
+		0x17: "System boot",
+		0xff: "End of log",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	if v >= 0x80 {
+		return "OEM-specific"
+	}
+	return outOfSpec
+}
+
+// LogDataFormat is defined in DSP0134 7.16.6.2.
+type LogDataFormat uint8
+
+func (v LogDataFormat) String() string {
+	names := []string{
+		"None",
+		"Handle",
+		"Multiple-event",
+		"Multiple-event handle",
+		"POST results bitmap",
+		"System management",
+		"Multiple-event system management",
+	}
+	switch {
+	case int(v) < len(names):
+		return names[v]
+	case v >= 0x80:
+		return "OEM-specific"
+	}
+	return outOfSpec
+}
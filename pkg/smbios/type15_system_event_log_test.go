@@ -0,0 +1,46 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestSystemEventLog(t *testing.T) {
+	table := &Table{
+		Header: Header{Type: TableTypeSystemEventLog, Length: 31, Handle: 0x2e},
+		data: []byte{
+			0x0f, 0x1f, 0x2e, 0x00, 0x32, 0x00, 0x00, 0x00, 0x10, 0x00, 0x04, 0x01, 0x02, 0x00, 0x00, 0x00,
+			0xf0, 0x00, 0x00, 0x00, 0x01, 0x04, 0x02, 0x08, 0x04, 0x0a, 0x00, 0x14, 0x00, 0x16, 0x00,
+		},
+	}
+	el, err := ParseSystemEventLog(table)
+	if err != nil {
+		t.Fatalf("ParseSystemEventLog = %v", err)
+	}
+	want := `Handle 0x002E, DMI type 15, 31 bytes
+System Event Log
+	Area Length: 50 bytes
+	Header Start Offset: 0x0000
+	Header Length: 16 bytes
+	Data Start Offset: 0x0010
+	Access Method: General-purpose non-volatile data functions
+	Access Address: 0x00F0
+	Status: Valid, Not Full
+	Change Token: 0x00000002
+	Header Format: Type 1
+	Supported Log Type Descriptors: 4
+	Descriptor 1: POST error
+	Data Format 1: POST results bitmap
+	Descriptor 2: PCI system error
+	Data Format 2: None
+	Descriptor 3: System reconfigured
+	Data Format 3: None
+	Descriptor 4: Log area reset/cleared
+	Data Format 4: None`
+	if got := el.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PhysicalMemoryArray is defined in DSP0134 7.17.
+type PhysicalMemoryArray struct {
+	Table
+	Location                MemoryArrayLocation        // 04h
+	Use                     MemoryArrayUse             // 05h
+	ErrorCorrection         MemoryArrayErrorCorrection // 06h
+	MaximumCapacity         uint32                     // 07h
+	ErrorInformationHandle  uint16                     // 0Bh
+	NumberOfMemoryDevices   uint16                     // 0Dh
+	ExtendedMaximumCapacity uint64                     // 0Fh
+}
+
+// ParsePhysicalMemoryArray parses a generic Table into PhysicalMemoryArray.
+func ParsePhysicalMemoryArray(t *Table) (*PhysicalMemoryArray, error) {
+	if t.Type != TableTypePhysicalMemoryArray {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	ma := &PhysicalMemoryArray{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ma); err != nil {
+		return nil, err
+	}
+	return ma, nil
+}
+
+// GetMaxCapacityBytes returns the maximum memory capacity of the array, in
+// bytes.
+func (ma *PhysicalMemoryArray) GetMaxCapacityBytes() uint64 {
+	if ma.MaximumCapacity == 0x80000000 {
+		return ma.ExtendedMaximumCapacity
+	}
+	return uint64(ma.MaximumCapacity) * 1024
+}
+
+func (ma *PhysicalMemoryArray) String() string {
+	capacity := "Unknown"
+	if ma.MaximumCapacity != 0x80000000 || ma.Len() >= 0x17 {
+		capacity = kmgt(ma.GetMaxCapacityBytes())
+	}
+	lines := []string{
+		ma.Header.String(),
+		fmt.Sprintf("Location: %s", ma.Location),
+		fmt.Sprintf("Use: %s", ma.Use),
+		fmt.Sprintf("Error Correction Type: %s", ma.ErrorCorrection),
+		fmt.Sprintf("Maximum Capacity: %s", capacity),
+		fmt.Sprintf("Error Information Handle: %s", errorInfoHandle(ma.ErrorInformationHandle)),
+		fmt.Sprintf("Number Of Devices: %d", ma.NumberOfMemoryDevices),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// errorInfoHandle formats the handle of a memory error information
+// structure.
+func errorInfoHandle(h uint16) string {
+	switch h {
+	case 0xfffe:
+		return "Not Provided"
+	case 0xffff:
+		return "No Error"
+	}
+	return fmt.Sprintf("0x%04X", h)
+}
+
+// MemoryArrayLocation is defined in DSP0134 7.17.1.
+type MemoryArrayLocation uint8
+
+func (v MemoryArrayLocation) String() string {
+	names := map[MemoryArrayLocation]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "System Board Or Motherboard",
+		0x04: "ISA Add-on Card",
+		0x05: "EISA Add-on Card",
+		0x06: "PCI Add-on Card",
+		0x07: "MCA Add-on Card",
+		0x08: "PCMCIA Add-on Card",
+		0x09: "Proprietary Add-on Card",
+		0x0a: "NuBus",
+		0xa0: "PC-98/C20 Add-on Card",
+		0xa1: "PC-98/C24 Add-on Card",
+		0xa2: "PC-98/E Add-on Card",
+		0xa3: "PC-98/Local Bus Add-on Card",
+		0xa4: "CXL Add-on Card",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryArrayUse is defined in DSP0134 7.17.2.
+type MemoryArrayUse uint8
+
+func (v MemoryArrayUse) String() string {
+	names := map[MemoryArrayUse]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "System Memory",
+		0x04: "Video Memory",
+		0x05: "Flash Memory",
+		0x06: "Non-volatile RAM",
+		0x07: "Cache Memory",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryArrayErrorCorrection is defined in DSP0134 7.17.3.
+type MemoryArrayErrorCorrection uint8
+
+func (v MemoryArrayErrorCorrection) String() string {
+	names := map[MemoryArrayErrorCorrection]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "None",
+		0x04: "Parity",
+		0x05: "Single-bit ECC",
+		0x06: "Multi-bit ECC",
+		0x07: "CRC",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
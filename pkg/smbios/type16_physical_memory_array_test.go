@@ -0,0 +1,68 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestPhysicalMemoryArray(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		capacity uint64
+		want     string
+	}{
+		{
+			name: "512 GB",
+			data: []byte{
+				0x10, 0x17, 0x09, 0x00, 0x03, 0x03, 0x03, 0x00, 0x00, 0x00, 0x20, 0x08, 0x00, 0x08, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+			capacity: 512 << 30,
+			want: `Handle 0x0009, DMI type 16, 23 bytes
+Physical Memory Array
+	Location: System Board Or Motherboard
+	Use: System Memory
+	Error Correction Type: None
+	Maximum Capacity: 512 GB
+	Error Information Handle: 0x0008
+	Number Of Devices: 8`,
+		},
+		{
+			name: "extended capacity",
+			data: []byte{
+				0x10, 0x17, 0x09, 0x00, 0x03, 0x03, 0x06, 0x00, 0x00, 0x00, 0x80, 0xfe, 0xff, 0x20, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00,
+			},
+			capacity: 16 << 40,
+			want: `Handle 0x0009, DMI type 16, 23 bytes
+Physical Memory Array
+	Location: System Board Or Motherboard
+	Use: System Memory
+	Error Correction Type: Multi-bit ECC
+	Maximum Capacity: 16 TB
+	Error Information Handle: Not Provided
+	Number Of Devices: 32`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &Table{
+				Header: Header{Type: TableTypePhysicalMemoryArray, Length: uint8(len(tt.data)), Handle: 9},
+				data:   tt.data,
+			}
+			ma, err := ParsePhysicalMemoryArray(table)
+			if err != nil {
+				t.Fatalf("ParsePhysicalMemoryArray = %v", err)
+			}
+			if got := ma.GetMaxCapacityBytes(); got != tt.capacity {
+				t.Errorf("GetMaxCapacityBytes = %d, want %d", got, tt.capacity)
+			}
+			if got := ma.String(); got != tt.want {
+				t.Errorf("String = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryError32 is defined in DSP0134 7.19.
+type MemoryError32 struct {
+	Table
+	ErrorType          MemoryErrorType        // 04h
+	ErrorGranularity   MemoryErrorGranularity // 05h
+	ErrorOperation     MemoryErrorOperation   // 06h
+	VendorSyndrome     uint32                 // 07h
+	ArrayErrorAddress  uint32                 // 0Bh
+	DeviceErrorAddress uint32                 // 0Fh
+	ErrorResolution    uint32                 // 13h
+}
+
+// ParseMemoryError32 parses a generic Table into MemoryError32.
+func ParseMemoryError32(t *Table) (*MemoryError32, error) {
+	if t.Type != TableTypeMemoryError32 {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x17 {
+		return nil, errors.New("required fields missing")
+	}
+	me := &MemoryError32{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, me); err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+func (me *MemoryError32) String() string {
+	addr := func(a uint32) string {
+		if a == 0x80000000 {
+			return "Unknown"
+		}
+		return fmt.Sprintf("0x%08X", a)
+	}
+	return memoryErrorString(&me.Header, me.ErrorType, me.ErrorGranularity, me.ErrorOperation,
+		me.VendorSyndrome, addr(me.ArrayErrorAddress), addr(me.DeviceErrorAddress), me.ErrorResolution)
+}
+
+func memoryErrorString(h *Header, typ MemoryErrorType, gran MemoryErrorGranularity, op MemoryErrorOperation, syndrome uint32, arrayAddr, devAddr string, resolution uint32) string {
+	syn, res := "Unknown", "Unknown"
+	if syndrome != 0 {
+		syn = fmt.Sprintf("0x%08X", syndrome)
+	}
+	if resolution != 0x80000000 {
+		res = fmt.Sprintf("%d bytes", resolution)
+	}
+	lines := []string{
+		h.String(),
+		fmt.Sprintf("Type: %s", typ),
+		fmt.Sprintf("Granularity: %s", gran),
+		fmt.Sprintf("Operation: %s", op),
+		fmt.Sprintf("Vendor Syndrome: %s", syn),
+		fmt.Sprintf("Memory Array Address: %s", arrayAddr),
+		fmt.Sprintf("Device Address: %s", devAddr),
+		fmt.Sprintf("Resolution: %s", res),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryErrorType is defined in DSP0134 7.19.1.
+type MemoryErrorType uint8
+
+func (v MemoryErrorType) String() string {
+	names := map[MemoryErrorType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "OK",
+		0x04: "Bad Read",
+		0x05: "Parity Error",
+		0x06: "Single-bit Error",
+		0x07: "Double-bit Error",
+		0x08: "Multi-bit Error",
+		0x09: "Nibble Error",
+		0x0a: "Checksum Error",
+		0x0b: "CRC Error",
+		0x0c: "Corrected Single-bit Error",
+		0x0d: "Corrected Error",
+		0x0e: "Uncorrectable Error",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryErrorGranularity is defined in DSP0134 7.19.2.
+type MemoryErrorGranularity uint8
+
+func (v MemoryErrorGranularity) String() string {
+	names := map[MemoryErrorGranularity]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Device Level",
+		0x04: "Memory Partition Level",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryErrorOperation is defined in DSP0134 7.19.3.
+type MemoryErrorOperation uint8
+
+func (v MemoryErrorOperation) String() string {
+	names := map[MemoryErrorOperation]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Read",
+		0x04: "Write",
+		0x05: "Partial Write",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryArrayMappedAddress is defined in DSP0134 7.20.
+type MemoryArrayMappedAddress struct {
+	Table
+	StartingAddress         uint32 // 04h
+	EndingAddress           uint32 // 08h
+	MemoryArrayHandle       uint16 // 0Ch
+	PartitionWidth          uint8  // 0Eh
+	ExtendedStartingAddress uint64 // 0Fh
+	ExtendedEndingAddress   uint64 // 17h
+}
+
+// ParseMemoryArrayMappedAddress parses a generic Table into MemoryArrayMappedAddress.
+func ParseMemoryArrayMappedAddress(t *Table) (*MemoryArrayMappedAddress, error) {
+	if t.Type != TableTypeMemoryArrayMappedAddress {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	ma := &MemoryArrayMappedAddress{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ma); err != nil {
+		return nil, err
+	}
+	return ma, nil
+}
+
+func (ma *MemoryArrayMappedAddress) String() string {
+	lines := append([]string{ma.Header.String()},
+		mappedAddressLines(ma.Len() >= 0x1f, ma.StartingAddress, ma.EndingAddress, ma.ExtendedStartingAddress, ma.ExtendedEndingAddress)...)
+	lines = append(lines,
+		fmt.Sprintf("Physical Array Handle: 0x%04X", ma.MemoryArrayHandle),
+		fmt.Sprintf("Partition Width: %d", ma.PartitionWidth),
+	)
+	return strings.Join(lines, "\n\t")
+}
+
+// mappedAddressLines formats a range given by start and end, in kB, or by
+// extStart and extEnd, in bytes, if start is 0xFFFFFFFF and hasExt is set.
+func mappedAddressLines(hasExt bool, start, end uint32, extStart, extEnd uint64) []string {
+	if hasExt && start == 0xffffffff {
+		size := "Invalid"
+		if extEnd > extStart {
+			size = kmgt(extEnd - extStart + 1)
+		}
+		return []string{
+			fmt.Sprintf("Starting Address: 0x%016X", extStart),
+			fmt.Sprintf("Ending Address: 0x%016X", extEnd),
+			fmt.Sprintf("Range Size: %s", size),
+		}
+	}
+	size := "Invalid"
+	if end >= start {
+		size = kmgt((uint64(end) - uint64(start) + 1) * 1024)
+	}
+	return []string{
+		fmt.Sprintf("Starting Address: 0x%08X%03X", start>>2, (start&3)<<10),
+		fmt.Sprintf("Ending Address: 0x%08X%03X", end>>2, (end&3)<<10+0x3ff),
+		fmt.Sprintf("Range Size: %s", size),
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryDeviceMappedAddress is defined in DSP0134 7.21.
+type MemoryDeviceMappedAddress struct {
+	Table
+	StartingAddress                uint32 // 04h
+	EndingAddress                  uint32 // 08h
+	MemoryDeviceHandle             uint16 // 0Ch
+	MemoryArrayMappedAddressHandle uint16 // 0Eh
+	PartitionRowPosition           uint8  // 10h
+	InterleavePosition             uint8  // 11h
+	InterleavedDataDepth           uint8  // 12h
+	ExtendedStartingAddress        uint64 // 13h
+	ExtendedEndingAddress          uint64 // 1Bh
+}
+
+// ParseMemoryDeviceMappedAddress parses a generic Table into MemoryDeviceMappedAddress.
+func ParseMemoryDeviceMappedAddress(t *Table) (*MemoryDeviceMappedAddress, error) {
+	if t.Type != TableTypeMemoryDeviceMappedAddress {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x13 {
+		return nil, errors.New("required fields missing")
+	}
+	md := &MemoryDeviceMappedAddress{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+func (md *MemoryDeviceMappedAddress) String() string {
+	lines := append([]string{md.Header.String()},
+		mappedAddressLines(md.Len() >= 0x23, md.StartingAddress, md.EndingAddress, md.ExtendedStartingAddress, md.ExtendedEndingAddress)...)
+	row := "Unknown"
+	switch md.PartitionRowPosition {
+	case 0:
+		row = outOfSpec
+	case 0xff:
+	default:
+		row = fmt.Sprintf("%d", md.PartitionRowPosition)
+	}
+	interleave := func(v uint8) string {
+		if v == 0xff {
+			return "Unknown"
+		}
+		return fmt.Sprintf("%d", v)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Physical Device Handle: 0x%04X", md.MemoryDeviceHandle),
+		fmt.Sprintf("Memory Array Mapped Address Handle: 0x%04X", md.MemoryArrayMappedAddressHandle),
+		fmt.Sprintf("Partition Row Position: %s", row),
+	)
+	// Zero means not interleaved; these are then omitted.
+	if md.InterleavePosition != 0 {
+		lines = append(lines, fmt.Sprintf("Interleave Position: %s", interleave(md.InterleavePosition)))
+	}
+	if md.InterleavedDataDepth != 0 {
+		lines = append(lines, fmt.Sprintf("Interleaved Data Depth: %s", interleave(md.InterleavedDataDepth)))
+	}
+	return strings.Join(lines, "\n\t")
+}
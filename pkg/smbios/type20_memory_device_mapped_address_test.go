@@ -0,0 +1,66 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestMemoryDeviceMappedAddress(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "32-bit",
+			data: []byte{
+				0x14, 0x23, 0x17, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0x3f, 0x00, 0x15, 0x00, 0x16, 0x00,
+				0xff, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00,
+			},
+			want: `Handle 0x0017, DMI type 20, 35 bytes
+Memory Device Mapped Address
+	Starting Address: 0x00000000000
+	Ending Address: 0x000FFFFFFFF
+	Range Size: 4 GB
+	Physical Device Handle: 0x0015
+	Memory Array Mapped Address Handle: 0x0016
+	Partition Row Position: Unknown
+	Interleave Position: 1
+	Interleaved Data Depth: 1`,
+		},
+		{
+			name: "extended, not interleaved",
+			data: []byte{
+				0x14, 0x23, 0x17, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x15, 0x00, 0x16, 0x00,
+				0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x03,
+				0x00, 0x00, 0x00,
+			},
+			want: `Handle 0x0017, DMI type 20, 35 bytes
+Memory Device Mapped Address
+	Starting Address: 0x0000000100000000
+	Ending Address: 0x00000003FFFFFFFF
+	Range Size: 12 GB
+	Physical Device Handle: 0x0015
+	Memory Array Mapped Address Handle: 0x0016
+	Partition Row Position: 1`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &Table{
+				Header: Header{Type: TableTypeMemoryDeviceMappedAddress, Length: uint8(len(tt.data)), Handle: 0x17},
+				data:   tt.data,
+			}
+			md, err := ParseMemoryDeviceMappedAddress(table)
+			if err != nil {
+				t.Fatalf("ParseMemoryDeviceMappedAddress = %v", err)
+			}
+			if got := md.String(); got != tt.want {
+				t.Errorf("String = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
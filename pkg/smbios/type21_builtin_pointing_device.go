@@ -0,0 +1,90 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PointingDevice is defined in DSP0134 7.22.
+type PointingDevice struct {
+	Table
+	Type      PointingDeviceType      // 04h
+	Interface PointingDeviceInterface // 05h
+	Buttons   uint8                   // 06h
+}
+
+// ParsePointingDevice parses a generic Table into PointingDevice.
+func ParsePointingDevice(t *Table) (*PointingDevice, error) {
+	if t.Type != TableTypePointingDevice {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x7 {
+		return nil, errors.New("required fields missing")
+	}
+	pd := &PointingDevice{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, pd); err != nil {
+		return nil, err
+	}
+	return pd, nil
+}
+
+func (pd *PointingDevice) String() string {
+	lines := []string{
+		pd.Header.String(),
+		fmt.Sprintf("Type: %s", pd.Type),
+		fmt.Sprintf("Interface: %s", pd.Interface),
+		fmt.Sprintf("Buttons: %d", pd.Buttons),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// PointingDeviceType is defined in DSP0134 7.22.1.
+type PointingDeviceType uint8
+
+func (v PointingDeviceType) String() string {
+	names := map[PointingDeviceType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Mouse",
+		0x04: "Track Ball",
+		0x05: "Track Point",
+		0x06: "Glide Point",
+		0x07: "Touch Pad",
+		0x08: "Touch Screen",
+		0x09: "Optical Sensor",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// PointingDeviceInterface is defined in DSP0134 7.22.2.
+type PointingDeviceInterface uint8
+
+func (v PointingDeviceInterface) String() string {
+	names := map[PointingDeviceInterface]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Serial",
+		0x04: "PS/2",
+		0x05: "Infrared",
+		0x06: "HIP-HIL",
+		0x07: "Bus Mouse",
+		0x08: "ADB (Apple Desktop Bus)",
+		0xa0: "Bus Mouse DB-9",
+		0xa1: "Bus Mouse Micro DIN",
+		0xa2: "USB",
+		0xa3: "I2C",
+		0xa4: "SPI",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
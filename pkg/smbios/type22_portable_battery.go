@@ -0,0 +1,129 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PortableBattery is defined in DSP0134 7.23.
+type PortableBattery struct {
+	Table
+	Location                 string           // 04h
+	Manufacturer             string           // 05h
+	ManufactureDate          string           // 06h
+	SerialNumber             string           // 07h
+	DeviceName               string           // 08h
+	DeviceChemistry          BatteryChemistry // 09h
+	DesignCapacity           uint16           // 0Ah
+	DesignVoltage            uint16           // 0Ch
+	SBDSVersion              string           // 0Eh
+	MaximumError             uint8            // 0Fh
+	SBDSSerialNumber         uint16           // 10h
+	SBDSManufactureDate      uint16           // 12h
+	SBDSDeviceChemistry      string           // 14h
+	DesignCapacityMultiplier uint8            // 15h
+	OEMInfo                  uint32           // 16h
+}
+
+// ParsePortableBattery parses a generic Table into PortableBattery.
+func ParsePortableBattery(t *Table) (*PortableBattery, error) {
+	if t.Type != TableTypePortableBattery {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	pb := &PortableBattery{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, pb); err != nil {
+		return nil, err
+	}
+	return pb, nil
+}
+
+// GetDesignCapacity returns the design capacity of the battery, in mWh, or
+// 0 if it is unknown.
+func (pb *PortableBattery) GetDesignCapacity() uint32 {
+	mul := uint32(1)
+	if pb.Len() >= 0x16 {
+		mul = uint32(pb.DesignCapacityMultiplier)
+	}
+	return uint32(pb.DesignCapacity) * mul
+}
+
+func (pb *PortableBattery) String() string {
+	// Since 2.2, the SBDS fields replace unset string fields.
+	sbds := pb.Len() >= 0x1a
+	dateIdx, _ := pb.GetByteAt(0x06)
+	serialIdx, _ := pb.GetByteAt(0x07)
+
+	lines := []string{
+		pb.Header.String(),
+		fmt.Sprintf("Location: %s", pb.Location),
+		fmt.Sprintf("Manufacturer: %s", pb.Manufacturer),
+	}
+	if dateIdx != 0 || !sbds {
+		lines = append(lines, fmt.Sprintf("Manufacture Date: %s", pb.ManufactureDate))
+	}
+	if serialIdx != 0 || !sbds {
+		lines = append(lines, fmt.Sprintf("Serial Number: %s", pb.SerialNumber))
+	}
+	lines = append(lines, fmt.Sprintf("Name: %s", pb.DeviceName))
+	if pb.DeviceChemistry != 0x02 || !sbds {
+		lines = append(lines, fmt.Sprintf("Chemistry: %s", pb.DeviceChemistry))
+	}
+	capacity, voltage, maxErr := "Unknown", "Unknown", "Unknown"
+	if c := pb.GetDesignCapacity(); c != 0 {
+		capacity = fmt.Sprintf("%d mWh", c)
+	}
+	if pb.DesignVoltage != 0 {
+		voltage = fmt.Sprintf("%d mV", pb.DesignVoltage)
+	}
+	if pb.MaximumError != 0xff {
+		maxErr = fmt.Sprintf("%d%%", pb.MaximumError)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Design Capacity: %s", capacity),
+		fmt.Sprintf("Design Voltage: %s", voltage),
+		fmt.Sprintf("SBDS Version: %s", pb.SBDSVersion),
+		fmt.Sprintf("Maximum Error: %s", maxErr),
+	)
+	if sbds {
+		if serialIdx == 0 {
+			lines = append(lines, fmt.Sprintf("SBDS Serial Number: %04X", pb.SBDSSerialNumber))
+		}
+		if dateIdx == 0 {
+			d := pb.SBDSManufactureDate
+			lines = append(lines, fmt.Sprintf("SBDS Manufacture Date: %d-%02d-%02d", 1980+int(d>>9), (d>>5)&0xf, d&0x1f))
+		}
+		if pb.DeviceChemistry == 0x02 {
+			lines = append(lines, fmt.Sprintf("SBDS Chemistry: %s", pb.SBDSDeviceChemistry))
+		}
+		lines = append(lines, fmt.Sprintf("OEM-specific Information: 0x%08X", pb.OEMInfo))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// BatteryChemistry is defined in DSP0134 7.23.1.
+type BatteryChemistry uint8
+
+func (v BatteryChemistry) String() string {
+	names := map[BatteryChemistry]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Lead Acid",
+		0x04: "Nickel Cadmium",
+		0x05: "Nickel Metal Hydride",
+		0x06: "Lithium Ion",
+		0x07: "Zinc Air",
+		0x08: "Lithium Polymer",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestPortableBattery(t *testing.T) {
+	table := &Table{
+		Header: Header{Type: TableTypePortableBattery, Length: 26, Handle: 0x24},
+		data: []byte{
+			0x16, 0x1a, 0x24, 0x00, 0x01, 0x02, 0x00, 0x00, 0x03, 0x02, 0x44, 0x16, 0x3c, 0x2d, 0x04, 0xff,
+			0x0b, 0x07, 0x49, 0x4e, 0x05, 0x0a, 0x00, 0x00, 0x00, 0x00,
+		},
+		strings: []string{"Front", "LGC", "01AV478", "03.01", "LiP"},
+	}
+	pb, err := ParsePortableBattery(table)
+	if err != nil {
+		t.Fatalf("ParsePortableBattery = %v", err)
+	}
+	if got := pb.GetDesignCapacity(); got != 57000 {
+		t.Errorf("GetDesignCapacity = %d, want 57000", got)
+	}
+	want := `Handle 0x0024, DMI type 22, 26 bytes
+Portable Battery
+	Location: Front
+	Manufacturer: LGC
+	Name: 01AV478
+	Design Capacity: 57000 mWh
+	Design Voltage: 11580 mV
+	SBDS Version: 03.01
+	Maximum Error: Unknown
+	SBDS Serial Number: 070B
+	SBDS Manufacture Date: 2019-02-09
+	SBDS Chemistry: LiP
+	OEM-specific Information: 0x00000000`
+	if got := pb.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemReset is defined in DSP0134 7.24.
+type SystemReset struct {
+	Table
+	Capabilities  uint8  // 04h
+	ResetCount    uint16 // 05h
+	ResetLimit    uint16 // 07h
+	TimerInterval uint16 // 09h
+	Timeout       uint16 // 0Bh
+}
+
+// ParseSystemReset parses a generic Table into SystemReset.
+func ParseSystemReset(t *Table) (*SystemReset, error) {
+	if t.Type != TableTypeSystemReset {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xd {
+		return nil, errors.New("required fields missing")
+	}
+	sr := &SystemReset{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, sr); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (sr *SystemReset) String() string {
+	watchdog := "Not Present"
+	if sr.Capabilities&0x20 != 0 {
+		watchdog = "Present"
+	}
+	lines := []string{
+		sr.Header.String(),
+		fmt.Sprintf("Status: %s", enabledString(sr.Capabilities&1 != 0)),
+		fmt.Sprintf("Watchdog Timer: %s", watchdog),
+	}
+	if sr.Capabilities&0x20 == 0 {
+		return strings.Join(lines, "\n\t")
+	}
+	bootOption := func(v uint8) string {
+		return []string{outOfSpec, "Operating System", "System Utilities", "Do Not Reboot"}[v&3]
+	}
+	unknown := func(v uint16, unit string) string {
+		if v == 0xffff {
+			return "Unknown"
+		}
+		return fmt.Sprintf("%d%s", v, unit)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Boot Option: %s", bootOption(sr.Capabilities>>1)),
+		fmt.Sprintf("Boot Option On Limit: %s", bootOption(sr.Capabilities>>3)),
+		fmt.Sprintf("Reset Count: %s", unknown(sr.ResetCount, "")),
+		fmt.Sprintf("Reset Limit: %s", unknown(sr.ResetLimit, "")),
+		fmt.Sprintf("Timer Interval: %s", unknown(sr.TimerInterval, " min")),
+		fmt.Sprintf("Timeout: %s", unknown(sr.Timeout, " min")),
+	)
+	return strings.Join(lines, "\n\t")
+}
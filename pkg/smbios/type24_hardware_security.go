@@ -0,0 +1,70 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// HardwareSecurity is defined in DSP0134 7.25.
+type HardwareSecurity struct {
+	Table
+	Settings uint8 // 04h
+}
+
+// ParseHardwareSecurity parses a generic Table into HardwareSecurity.
+func ParseHardwareSecurity(t *Table) (*HardwareSecurity, error) {
+	if t.Type != TableTypeHardwareSecurity {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	hs := &HardwareSecurity{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, hs); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+// PowerOnPasswordStatus returns the power-on password status.
+func (hs *HardwareSecurity) PowerOnPasswordStatus() SecurityStatus {
+	return SecurityStatus(hs.Settings >> 6 & 3)
+}
+
+// KeyboardPasswordStatus returns the keyboard password status.
+func (hs *HardwareSecurity) KeyboardPasswordStatus() SecurityStatus {
+	return SecurityStatus(hs.Settings >> 4 & 3)
+}
+
+// AdministratorPasswordStatus returns the administrator password status.
+func (hs *HardwareSecurity) AdministratorPasswordStatus() SecurityStatus {
+	return SecurityStatus(hs.Settings >> 2 & 3)
+}
+
+// FrontPanelResetStatus returns the front panel reset status.
+func (hs *HardwareSecurity) FrontPanelResetStatus() SecurityStatus {
+	return SecurityStatus(hs.Settings & 3)
+}
+
+func (hs *HardwareSecurity) String() string {
+	lines := []string{
+		hs.Header.String(),
+		fmt.Sprintf("Power-On Password Status: %s", hs.PowerOnPasswordStatus()),
+		fmt.Sprintf("Keyboard Password Status: %s", hs.KeyboardPasswordStatus()),
+		fmt.Sprintf("Administrator Password Status: %s", hs.AdministratorPasswordStatus()),
+		fmt.Sprintf("Front Panel Reset Status: %s", hs.FrontPanelResetStatus()),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// SecurityStatus is defined in DSP0134 7.25.
+type SecurityStatus uint8
+
+func (v SecurityStatus) String() string {
+	return []string{"Disabled", "Enabled", "Not Implemented", "Unknown"}[v&3]
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemPowerControls is defined in DSP0134 7.26. The fields are BCD.
+type SystemPowerControls struct {
+	Table
+	Month  uint8 // 04h
+	Day    uint8 // 05h
+	Hour   uint8 // 06h
+	Minute uint8 // 07h
+	Second uint8 // 08h
+}
+
+// ParseSystemPowerControls parses a generic Table into SystemPowerControls.
+func ParseSystemPowerControls(t *Table) (*SystemPowerControls, error) {
+	if t.Type != TableTypeSystemPowerControls {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x9 {
+		return nil, errors.New("required fields missing")
+	}
+	pc := &SystemPowerControls{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, pc); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+func (pc *SystemPowerControls) String() string {
+	// Fields out of range mean "every", e.g. every day of the month.
+	bcd := func(v, lo, hi uint8) string {
+		if v&0xf > 9 || v < lo || v > hi {
+			return "*"
+		}
+		return fmt.Sprintf("%02X", v)
+	}
+	lines := []string{
+		pc.Header.String(),
+		fmt.Sprintf("Next Scheduled Power-on: %s-%s %s:%s:%s",
+			bcd(pc.Month, 0x01, 0x12), bcd(pc.Day, 0x01, 0x31),
+			bcd(pc.Hour, 0x00, 0x23), bcd(pc.Minute, 0x00, 0x59), bcd(pc.Second, 0x00, 0x59)),
+	}
+	return strings.Join(lines, "\n\t")
+}
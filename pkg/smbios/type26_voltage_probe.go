@@ -0,0 +1,136 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Probe is the layout shared by the voltage, temperature and electrical
+// current probe structures, defined in DSP0134 7.27, 7.29 and 7.30.
+type Probe struct {
+	Table
+	Description       string // 04h
+	LocationAndStatus uint8  // 05h
+	MaximumValue      uint16 // 06h
+	MinimumValue      uint16 // 08h
+	Resolution        uint16 // 0Ah
+	Tolerance         uint16 // 0Ch
+	Accuracy          uint16 // 0Eh
+	OEMInfo           uint32 // 10h
+	NominalValue      uint16 // 14h
+}
+
+// VoltageProbe is defined in DSP0134 7.27. Values are in mV, the resolution
+// in tenths of mV.
+type VoltageProbe struct {
+	Probe
+}
+
+// ParseVoltageProbe parses a generic Table into VoltageProbe.
+func ParseVoltageProbe(t *Table) (*VoltageProbe, error) {
+	if t.Type != TableTypeVoltageProbe {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	p, err := parseProbe(t)
+	if err != nil {
+		return nil, err
+	}
+	return &VoltageProbe{Probe: *p}, nil
+}
+
+func parseProbe(t *Table) (*Probe, error) {
+	if t.Len() < 0x14 {
+		return nil, errors.New("required fields missing")
+	}
+	p := &Probe{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Status returns the status of the probe.
+func (p *Probe) Status() ProbeStatus {
+	return ProbeStatus(p.LocationAndStatus >> 5)
+}
+
+// probeString formats the probe, given its locations and how to format its
+// values, resolution and tolerance.
+func (p *Probe) probeString(locations map[uint8]string, value, resolution, tolerance func(v uint16) string) string {
+	unknown := func(f func(uint16) string) func(uint16) string {
+		return func(v uint16) string {
+			if v == 0x8000 {
+				return "Unknown"
+			}
+			return f(v)
+		}
+	}
+	value, resolution, tolerance = unknown(value), unknown(resolution), unknown(tolerance)
+	accuracy := unknown(func(v uint16) string { return fmt.Sprintf("%.2f%%", float64(v)/100) })
+	location, ok := locations[p.LocationAndStatus&0x1f]
+	if !ok {
+		location = outOfSpec
+	}
+	lines := []string{
+		p.Header.String(),
+		fmt.Sprintf("Description: %s", p.Description),
+		fmt.Sprintf("Location: %s", location),
+		fmt.Sprintf("Status: %s", p.Status()),
+		fmt.Sprintf("Maximum Value: %s", value(p.MaximumValue)),
+		fmt.Sprintf("Minimum Value: %s", value(p.MinimumValue)),
+		fmt.Sprintf("Resolution: %s", resolution(p.Resolution)),
+		fmt.Sprintf("Tolerance: %s", tolerance(p.Tolerance)),
+		fmt.Sprintf("Accuracy: %s", accuracy(p.Accuracy)),
+		fmt.Sprintf("OEM-specific Information: 0x%08X", p.OEMInfo),
+	}
+	if p.Len() > 0x14 {
+		lines = append(lines, fmt.Sprintf("Nominal Value: %s", value(p.NominalValue)))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// voltageProbeLocations are defined in DSP0134 7.27.1.
+var voltageProbeLocations = map[uint8]string{
+	0x01: "Other",
+	0x02: "Unknown",
+	0x03: "Processor",
+	0x04: "Disk",
+	0x05: "Peripheral Bay",
+	0x06: "System Management Module",
+	0x07: "Motherboard",
+	0x08: "Memory Module",
+	0x09: "Processor Module",
+	0x0a: "Power Unit",
+	0x0b: "Add-in Card",
+}
+
+func (vp *VoltageProbe) String() string {
+	return vp.probeString(voltageProbeLocations,
+		func(v uint16) string { return fmt.Sprintf("%.3f V", float64(int16(v))/1000) },
+		func(v uint16) string { return fmt.Sprintf("%.1f mV", float64(v)/10) },
+		func(v uint16) string { return fmt.Sprintf("%.3f V", float64(v)/1000) },
+	)
+}
+
+// ProbeStatus is defined in DSP0134 7.27.1.
+type ProbeStatus uint8
+
+func (v ProbeStatus) String() string {
+	names := map[ProbeStatus]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "OK",
+		0x04: "Non-critical",
+		0x05: "Critical",
+		0x06: "Non-recoverable",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestVoltageProbe(t *testing.T) {
+	table := &Table{
+		Header: Header{Type: TableTypeVoltageProbe, Length: 22, Handle: 0x35},
+		data: []byte{
+			0x1a, 0x16, 0x35, 0x00, 0x01, 0x6a, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x80,
+		},
+		strings: []string{"LM78A"},
+	}
+	vp, err := ParseVoltageProbe(table)
+	if err != nil {
+		t.Fatalf("ParseVoltageProbe = %v", err)
+	}
+	if got := vp.Status(); got != ProbeStatus(3) {
+		t.Errorf("Status = %v, want OK", got)
+	}
+	want := `Handle 0x0035, DMI type 26, 22 bytes
+Voltage Probe
+	Description: LM78A
+	Location: Power Unit
+	Status: OK
+	Maximum Value: Unknown
+	Minimum Value: Unknown
+	Resolution: Unknown
+	Tolerance: Unknown
+	Accuracy: Unknown
+	OEM-specific Information: 0x00000000
+	Nominal Value: Unknown`
+	if got := vp.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+
+	table.Type = TableTypeTemperatureProbe
+	if _, err := ParseVoltageProbe(table); err == nil {
+		t.Errorf("ParseVoltageProbe(type 28) = nil, want error")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CoolingDevice is defined in DSP0134 7.28.
+type CoolingDevice struct {
+	Table
+	TemperatureProbeHandle uint16 // 04h
+	DeviceTypeAndStatus    uint8  // 06h
+	CoolingUnitGroup       uint8  // 07h
+	OEMInfo                uint32 // 08h
+	NominalSpeed           uint16 // 0Ch
+	Description            string // 0Eh
+}
+
+// ParseCoolingDevice parses a generic Table into CoolingDevice.
+func ParseCoolingDevice(t *Table) (*CoolingDevice, error) {
+	if t.Type != TableTypeCoolingDevice {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xc {
+		return nil, errors.New("required fields missing")
+	}
+	cd := &CoolingDevice{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, cd); err != nil {
+		return nil, err
+	}
+	return cd, nil
+}
+
+// DeviceType returns the type of the cooling device.
+func (cd *CoolingDevice) DeviceType() CoolingDeviceType {
+	return CoolingDeviceType(cd.DeviceTypeAndStatus & 0x1f)
+}
+
+// Status returns the status of the cooling device.
+func (cd *CoolingDevice) Status() ProbeStatus {
+	return ProbeStatus(cd.DeviceTypeAndStatus >> 5)
+}
+
+func (cd *CoolingDevice) String() string {
+	lines := []string{cd.Header.String()}
+	if cd.TemperatureProbeHandle != 0xffff {
+		lines = append(lines, fmt.Sprintf("Temperature Probe Handle: 0x%04X", cd.TemperatureProbeHandle))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Type: %s", cd.DeviceType()),
+		fmt.Sprintf("Status: %s", cd.Status()),
+	)
+	if cd.CoolingUnitGroup != 0 {
+		lines = append(lines, fmt.Sprintf("Cooling Unit Group: %d", cd.CoolingUnitGroup))
+	}
+	lines = append(lines, fmt.Sprintf("OEM-specific Information: 0x%08X", cd.OEMInfo))
+	if cd.Len() >= 0xe {
+		speed := "Unknown Or Non-rotating"
+		if cd.NominalSpeed != 0x8000 {
+			speed = fmt.Sprintf("%d rpm", cd.NominalSpeed)
+		}
+		lines = append(lines, fmt.Sprintf("Nominal Speed: %s", speed))
+	}
+	if cd.Len() >= 0xf {
+		lines = append(lines, fmt.Sprintf("Description: %s", cd.Description))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// CoolingDeviceType is defined in DSP0134 7.28.1.
+type CoolingDeviceType uint8
+
+func (v CoolingDeviceType) String() string {
+	names := map[CoolingDeviceType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Fan",
+		0x04: "Centrifugal Blower",
+		0x05: "Chip Fan",
+		0x06: "Cabinet Fan",
+		0x07: "Power Supply Fan",
+		0x08: "Heat Pipe",
+		0x09: "Integrated Refrigeration",
+		0x10: "Active Cooling",
+		0x11: "Passive Cooling",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"fmt"
+)
+
+// TemperatureProbe is defined in DSP0134 7.29. Values are in tenths of
+// degrees C, the resolution in thousandths.
+type TemperatureProbe struct {
+	Probe
+}
+
+// ParseTemperatureProbe parses a generic Table into TemperatureProbe.
+func ParseTemperatureProbe(t *Table) (*TemperatureProbe, error) {
+	if t.Type != TableTypeTemperatureProbe {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	p, err := parseProbe(t)
+	if err != nil {
+		return nil, err
+	}
+	return &TemperatureProbe{Probe: *p}, nil
+}
+
+// temperatureProbeLocations are defined in DSP0134 7.29.1.
+var temperatureProbeLocations = map[uint8]string{
+	0x01: "Other",
+	0x02: "Unknown",
+	0x03: "Processor",
+	0x04: "Disk",
+	0x05: "Peripheral Bay",
+	0x06: "System Management Module",
+	0x07: "Motherboard",
+	0x08: "Memory Module",
+	0x09: "Processor Module",
+	0x0a: "Power Unit",
+	0x0b: "Add-in Card",
+	0x0c: "Front Panel Board",
+	0x0d: "Back Panel Board",
+	0x0e: "Power System Board",
+	0x0f: "Drive Back Plane",
+}
+
+func (tp *TemperatureProbe) String() string {
+	return tp.probeString(temperatureProbeLocations,
+		func(v uint16) string { return fmt.Sprintf("%.1f deg C", float64(int16(v))/10) },
+		func(v uint16) string { return fmt.Sprintf("%.3f deg C", float64(v)/1000) },
+		func(v uint16) string { return fmt.Sprintf("%.1f deg C", float64(v)/10) },
+	)
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"fmt"
+)
+
+// CurrentProbe is defined in DSP0134 7.30. Values are in mA, the resolution
+// in tenths of mA.
+type CurrentProbe struct {
+	Probe
+}
+
+// ParseCurrentProbe parses a generic Table into CurrentProbe.
+func ParseCurrentProbe(t *Table) (*CurrentProbe, error) {
+	if t.Type != TableTypeCurrentProbe {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	p, err := parseProbe(t)
+	if err != nil {
+		return nil, err
+	}
+	return &CurrentProbe{Probe: *p}, nil
+}
+
+func (cp *CurrentProbe) String() string {
+	// The locations are those of voltage probes.
+	return cp.probeString(voltageProbeLocations,
+		func(v uint16) string { return fmt.Sprintf("%.3f A", float64(int16(v))/1000) },
+		func(v uint16) string { return fmt.Sprintf("%.1f mA", float64(v)/10) },
+		func(v uint16) string { return fmt.Sprintf("%.3f A", float64(v)/1000) },
+	)
+}
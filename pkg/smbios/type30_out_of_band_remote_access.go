@@ -0,0 +1,43 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OutOfBandRemoteAccess is defined in DSP0134 7.31.
+type OutOfBandRemoteAccess struct {
+	Table
+	ManufacturerName string // 04h
+	Connections      uint8  // 05h
+}
+
+// ParseOutOfBandRemoteAccess parses a generic Table into OutOfBandRemoteAccess.
+func ParseOutOfBandRemoteAccess(t *Table) (*OutOfBandRemoteAccess, error) {
+	if t.Type != TableTypeOutOfBandRemoteAccess {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x6 {
+		return nil, errors.New("required fields missing")
+	}
+	ra := &OutOfBandRemoteAccess{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ra); err != nil {
+		return nil, err
+	}
+	return ra, nil
+}
+
+func (ra *OutOfBandRemoteAccess) String() string {
+	lines := []string{
+		ra.Header.String(),
+		fmt.Sprintf("Manufacturer Name: %s", ra.ManufacturerName),
+		fmt.Sprintf("Inbound Connection: %s", enabledString(ra.Connections&1 != 0)),
+		fmt.Sprintf("Outbound Connection: %s", enabledString(ra.Connections&2 != 0)),
+	}
+	return strings.Join(lines, "\n\t")
+}
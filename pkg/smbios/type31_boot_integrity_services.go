@@ -0,0 +1,54 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BootIntegrityServices is the Boot Integrity Services (BIS) entry point
+// structure, defined in DSP0134 7.32. Its layout is not part of the
+// specification; this is what dmidecode decodes.
+type BootIntegrityServices struct {
+	Table
+	Checksum     uint8  // 04h
+	EntryPoint16 uint32 `smbios:"skip=3"` // 08h, after 3 reserved bytes
+	EntryPoint32 uint32 // 0Ch
+}
+
+// ParseBootIntegrityServices parses a generic Table into BootIntegrityServices.
+func ParseBootIntegrityServices(t *Table) (*BootIntegrityServices, error) {
+	if t.Type != TableTypeBootIntegrityServices {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	bis := &BootIntegrityServices{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, bis); err != nil {
+		return nil, err
+	}
+	return bis, nil
+}
+
+func (bis *BootIntegrityServices) String() string {
+	var sum uint8
+	for _, b := range bis.data {
+		sum += b
+	}
+	checksum := "OK"
+	if sum != 0 {
+		checksum = "Invalid"
+	}
+	lines := []string{
+		bis.Header.String(),
+		fmt.Sprintf("Checksum: %s", checksum),
+		fmt.Sprintf("16-bit Entry Point Address: %04X:%04X", bis.EntryPoint16>>16, bis.EntryPoint16&0xffff),
+		fmt.Sprintf("32-bit Entry Point Address: 0x%08X", bis.EntryPoint32),
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemBootInfo is defined in DSP0134 7.33.
+type SystemBootInfo struct {
+	Table
+	BootStatus SystemBootStatus `smbios:"skip=6"` // 0Ah, after 6 reserved bytes
+}
+
+// ParseSystemBootInfo parses a generic Table into SystemBootInfo.
+func ParseSystemBootInfo(t *Table) (*SystemBootInfo, error) {
+	if t.Type != TableTypeSystemBootInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xb {
+		return nil, errors.New("required fields missing")
+	}
+	bi := &SystemBootInfo{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, bi); err != nil {
+		return nil, err
+	}
+	return bi, nil
+}
+
+func (bi *SystemBootInfo) String() string {
+	lines := []string{
+		bi.Header.String(),
+		fmt.Sprintf("Status: %s", bi.BootStatus),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// SystemBootStatus is defined in DSP0134 7.33.2. Only the first byte of the
+// status is decoded.
+type SystemBootStatus uint8
+
+func (v SystemBootStatus) String() string {
+	names := []string{
+		"No errors detected",
+		"No bootable media",
+		"Operating system failed to load",
+		"Firmware-detected hardware failure",
+		"Operating system-detected hardware failure",
+		"User-requested boot",
+		"System security violation",
+		"Previously-requested image",
+		"System watchdog timer expired",
+	}
+	switch {
+	case int(v) < len(names):
+		return names[v]
+	case v >= 128 && v <= 191:
+		return "OEM-specific"
+	case v >= 192:
+		return "Product-specific"
+	}
+	return outOfSpec
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MemoryError64 is defined in DSP0134 7.34.
+type MemoryError64 struct {
+	Table
+	ErrorType          MemoryErrorType        // 04h
+	ErrorGranularity   MemoryErrorGranularity // 05h
+	ErrorOperation     MemoryErrorOperation   // 06h
+	VendorSyndrome     uint32                 // 07h
+	ArrayErrorAddress  uint64                 // 0Bh
+	DeviceErrorAddress uint64                 // 13h
+	ErrorResolution    uint32                 // 1Bh
+}
+
+// ParseMemoryError64 parses a generic Table into MemoryError64.
+func ParseMemoryError64(t *Table) (*MemoryError64, error) {
+	if t.Type != TableTypeMemoryError64 {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x1f {
+		return nil, errors.New("required fields missing")
+	}
+	me := &MemoryError64{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, me); err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+func (me *MemoryError64) String() string {
+	addr := func(a uint64) string {
+		if a == 0x8000000000000000 {
+			return "Unknown"
+		}
+		return fmt.Sprintf("0x%016X", a)
+	}
+	return memoryErrorString(&me.Header, me.ErrorType, me.ErrorGranularity, me.ErrorOperation,
+		me.VendorSyndrome, addr(me.ArrayErrorAddress), addr(me.DeviceErrorAddress), me.ErrorResolution)
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ManagementDevice is defined in DSP0134 7.35.
+type ManagementDevice struct {
+	Table
+	Description string                      // 04h
+	Type        ManagementDeviceType        // 05h
+	Address     uint32                      // 06h
+	AddressType ManagementDeviceAddressType // 0Ah
+}
+
+// ParseManagementDevice parses a generic Table into ManagementDevice.
+func ParseManagementDevice(t *Table) (*ManagementDevice, error) {
+	if t.Type != TableTypeManagementDevice {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xb {
+		return nil, errors.New("required fields missing")
+	}
+	t = fixupManagementDevice(t)
+	md := &ManagementDevice{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// fixupManagementDevice works around firmware, seen on Supermicro boards,
+// that declares a length of 16 bytes instead of 11, so that the start of the
+// first string is taken to be part of the structure.
+func fixupManagementDevice(t *Table) *Table {
+	if t.Len() != 0x10 || len(t.strings) == 0 {
+		return t
+	}
+	for _, b := range t.data[0xb:] {
+		if b < 0x20 || b >= 0x7f {
+			return t
+		}
+	}
+	fixed := *t
+	fixed.data = t.data[:0xb]
+	fixed.strings = append([]string{string(t.data[0xb:]) + t.strings[0]}, t.strings[1:]...)
+	return &fixed
+}
+
+func (md *ManagementDevice) String() string {
+	lines := []string{
+		md.Header.String(),
+		fmt.Sprintf("Description: %s", md.Description),
+		fmt.Sprintf("Type: %s", md.Type),
+		fmt.Sprintf("Address: 0x%08X", md.Address),
+		fmt.Sprintf("Address Type: %s", md.AddressType),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// ManagementDeviceType is defined in DSP0134 7.35.1.
+type ManagementDeviceType uint8
+
+func (v ManagementDeviceType) String() string {
+	names := map[ManagementDeviceType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "LM75",
+		0x04: "LM78",
+		0x05: "LM79",
+		0x06: "LM80",
+		0x07: "LM81",
+		0x08: "ADM9240",
+		0x09: "DS1780",
+		0x0a: "MAX1617",
+		0x0b: "GL518SM",
+		0x0c: "W83781D",
+		0x0d: "HT82H791",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// ManagementDeviceAddressType is defined in DSP0134 7.35.2.
+type ManagementDeviceAddressType uint8
+
+func (v ManagementDeviceAddressType) String() string {
+	names := map[ManagementDeviceAddressType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "I/O Port",
+		0x04: "Memory",
+		0x05: "SMBus",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestManagementDevice(t *testing.T) {
+	// Some Supermicro boards declare 16 bytes and put the description
+	// string in the last 5 of them.
+	table := &Table{
+		Header: Header{Type: TableTypeManagementDevice, Length: 16, Handle: 0x4e},
+		data: []byte{
+			0x22, 0x10, 0x4e, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00, 0x03, 0x4c, 0x4d, 0x37, 0x38, 0x2d,
+		},
+		strings: []string{"2"},
+	}
+	md, err := ParseManagementDevice(table)
+	if err != nil {
+		t.Fatalf("ParseManagementDevice = %v", err)
+	}
+	want := `Handle 0x004E, DMI type 34, 16 bytes
+Management Device
+	Description: LM78-2
+	Type: LM78
+	Address: 0x00000000
+	Address Type: I/O Port`
+	if got := md.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+}
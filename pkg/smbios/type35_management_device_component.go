@@ -0,0 +1,48 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ManagementDeviceComponent is defined in DSP0134 7.36.
+type ManagementDeviceComponent struct {
+	Table
+	Description            string // 04h
+	ManagementDeviceHandle uint16 // 05h
+	ComponentHandle        uint16 // 07h
+	ThresholdHandle        uint16 // 09h
+}
+
+// ParseManagementDeviceComponent parses a generic Table into ManagementDeviceComponent.
+func ParseManagementDeviceComponent(t *Table) (*ManagementDeviceComponent, error) {
+	if t.Type != TableTypeManagementDeviceComponent {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x9 {
+		return nil, errors.New("required fields missing")
+	}
+	mc := &ManagementDeviceComponent{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, mc); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+func (mc *ManagementDeviceComponent) String() string {
+	lines := []string{
+		mc.Header.String(),
+		fmt.Sprintf("Description: %s", mc.Description),
+		fmt.Sprintf("Management Device Handle: 0x%04X", mc.ManagementDeviceHandle),
+		fmt.Sprintf("Component Handle: 0x%04X", mc.ComponentHandle),
+	}
+	if mc.Len() >= 0xb && mc.ThresholdHandle != 0xffff {
+		lines = append(lines, fmt.Sprintf("Threshold Handle: 0x%04X", mc.ThresholdHandle))
+	}
+	return strings.Join(lines, "\n\t")
+}
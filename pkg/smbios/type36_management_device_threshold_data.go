@@ -0,0 +1,58 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ManagementDeviceThreshold is defined in DSP0134 7.37. Thresholds of
+// 0x8000 are not available.
+type ManagementDeviceThreshold struct {
+	Table
+	LowerNonCritical    uint16 // 04h
+	UpperNonCritical    uint16 // 06h
+	LowerCritical       uint16 // 08h
+	UpperCritical       uint16 // 0Ah
+	LowerNonRecoverable uint16 // 0Ch
+	UpperNonRecoverable uint16 // 0Eh
+}
+
+// ParseManagementDeviceThreshold parses a generic Table into ManagementDeviceThreshold.
+func ParseManagementDeviceThreshold(t *Table) (*ManagementDeviceThreshold, error) {
+	if t.Type != TableTypeManagementDeviceThreshold {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	td := &ManagementDeviceThreshold{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, td); err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+func (td *ManagementDeviceThreshold) String() string {
+	lines := []string{td.Header.String()}
+	for _, th := range []struct {
+		name string
+		v    uint16
+	}{
+		{"Lower Non-critical", td.LowerNonCritical},
+		{"Upper Non-critical", td.UpperNonCritical},
+		{"Lower Critical", td.LowerCritical},
+		{"Upper Critical", td.UpperCritical},
+		{"Lower Non-recoverable", td.LowerNonRecoverable},
+		{"Upper Non-recoverable", td.UpperNonRecoverable},
+	} {
+		if th.v != 0x8000 {
+			lines = append(lines, fmt.Sprintf("%s Threshold: %d", th.name, int16(th.v)))
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryChannel is defined in DSP0134 7.38.
+type MemoryChannel struct {
+	Table
+	ChannelType    MemoryChannelType     // 04h
+	MaxChannelLoad uint8                 // 05h
+	DeviceCount    uint8                 // 06h
+	Devices        []MemoryChannelDevice `smbios:"-"` // 07h
+}
+
+// MemoryChannelDevice is a device of MemoryChannel.
+type MemoryChannelDevice struct {
+	Load   uint8  // 00h
+	Handle uint16 // 01h
+}
+
+// ParseMemoryChannel parses a generic Table into MemoryChannel.
+func ParseMemoryChannel(t *Table) (*MemoryChannel, error) {
+	if t.Type != TableTypeMemoryChannel {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x7 {
+		return nil, errors.New("required fields missing")
+	}
+	mc := &MemoryChannel{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, mc)
+	if err != nil {
+		return nil, err
+	}
+	if t.Len() < off+3*int(mc.DeviceCount) {
+		return nil, fmt.Errorf("invalid data length %d for %d devices", t.Len(), mc.DeviceCount)
+	}
+	for i := 0; i < int(mc.DeviceCount); i++ {
+		load, _ := t.GetByteAt(off)
+		h, _ := t.GetWordAt(off + 1)
+		mc.Devices = append(mc.Devices, MemoryChannelDevice{Load: load, Handle: h})
+		off += 3
+	}
+	return mc, nil
+}
+
+func (mc *MemoryChannel) String() string {
+	lines := []string{
+		mc.Header.String(),
+		fmt.Sprintf("Type: %s", mc.ChannelType),
+		fmt.Sprintf("Maximal Load: %d", mc.MaxChannelLoad),
+		fmt.Sprintf("Devices: %d", mc.DeviceCount),
+	}
+	for i, d := range mc.Devices {
+		lines = append(lines,
+			fmt.Sprintf("\tDevice %d Load: %d", i+1, d.Load),
+			fmt.Sprintf("\tDevice %d Handle: 0x%04X", i+1, d.Handle),
+		)
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryChannelType is defined in DSP0134 7.38.2.
+type MemoryChannelType uint8
+
+func (v MemoryChannelType) String() string {
+	names := map[MemoryChannelType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "RamBus",
+		0x04: "SyncLink",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
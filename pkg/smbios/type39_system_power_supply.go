@@ -0,0 +1,135 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemPowerSupply is defined in DSP0134 7.40.
+type SystemPowerSupply struct {
+	Table
+	PowerUnitGroup          uint8  // 04h
+	Location                string // 05h
+	DeviceName              string // 06h
+	Manufacturer            string // 07h
+	SerialNumber            string // 08h
+	AssetTagNumber          string // 09h
+	ModelPartNumber         string // 0Ah
+	RevisionLevel           string // 0Bh
+	MaxPowerCapacity        uint16 // 0Ch
+	Characteristics         uint16 // 0Eh
+	InputVoltageProbeHandle uint16 // 10h
+	CoolingDeviceHandle     uint16 // 12h
+	InputCurrentProbeHandle uint16 // 14h
+}
+
+// ParseSystemPowerSupply parses a generic Table into SystemPowerSupply.
+func ParseSystemPowerSupply(t *Table) (*SystemPowerSupply, error) {
+	if t.Type != TableTypeSystemPowerSupply {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	ps := &SystemPowerSupply{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *SystemPowerSupply) String() string {
+	lines := []string{ps.Header.String()}
+	if ps.PowerUnitGroup != 0 {
+		lines = append(lines, fmt.Sprintf("Power Unit Group: %d", ps.PowerUnitGroup))
+	}
+	capacity := "Unknown"
+	if ps.MaxPowerCapacity != 0x8000 {
+		capacity = fmt.Sprintf("%d W", ps.MaxPowerCapacity)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Location: %s", ps.Location),
+		fmt.Sprintf("Name: %s", ps.DeviceName),
+		fmt.Sprintf("Manufacturer: %s", ps.Manufacturer),
+		fmt.Sprintf("Serial Number: %s", ps.SerialNumber),
+		fmt.Sprintf("Asset Tag: %s", ps.AssetTagNumber),
+		fmt.Sprintf("Model Part Number: %s", ps.ModelPartNumber),
+		fmt.Sprintf("Revision: %s", ps.RevisionLevel),
+		fmt.Sprintf("Max Power Capacity: %s", capacity),
+	)
+
+	c := ps.Characteristics
+	if c&0x2 != 0 {
+		status := map[uint16]string{
+			0x01: "Other",
+			0x02: "Unknown",
+			0x03: "OK",
+			0x04: "Non-critical",
+			0x05: "Critical",
+		}[c>>7&0x7]
+		if status == "" {
+			status = outOfSpec
+		}
+		lines = append(lines, fmt.Sprintf("Status: Present, %s", status))
+	} else {
+		lines = append(lines, "Status: Not Present")
+	}
+	typ, ok := map[uint16]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Linear",
+		0x04: "Switching",
+		0x05: "Battery",
+		0x06: "UPS",
+		0x07: "Converter",
+		0x08: "Regulator",
+	}[c>>10&0xf]
+	if !ok {
+		typ = outOfSpec
+	}
+	switching, ok := map[uint16]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Manual",
+		0x04: "Auto-switch",
+		0x05: "Wide Range",
+		0x06: "N/A",
+	}[c>>3&0xf]
+	if !ok {
+		switching = outOfSpec
+	}
+	plugged, hotReplaceable := "Yes", "No"
+	if c&0x4 != 0 {
+		plugged = "No"
+	}
+	if c&0x1 != 0 {
+		hotReplaceable = "Yes"
+	}
+	lines = append(lines,
+		fmt.Sprintf("Type: %s", typ),
+		fmt.Sprintf("Input Voltage Range Switching: %s", switching),
+		fmt.Sprintf("Plugged: %s", plugged),
+		fmt.Sprintf("Hot Replaceable: %s", hotReplaceable),
+	)
+
+	if ps.Len() >= 0x16 {
+		for _, h := range []struct {
+			name string
+			v    uint16
+		}{
+			{"Input Voltage Probe", ps.InputVoltageProbeHandle},
+			{"Cooling Device", ps.CoolingDeviceHandle},
+			{"Input Current Probe", ps.InputCurrentProbeHandle},
+		} {
+			if h.v != 0xffff {
+				lines = append(lines, fmt.Sprintf("%s Handle: 0x%04X", h.name, h.v))
+			}
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
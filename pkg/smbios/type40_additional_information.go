@@ -0,0 +1,80 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AdditionalInfo is defined in DSP0134 7.41.
+type AdditionalInfo struct {
+	Table
+	NumEntries uint8                 // 04h
+	Entries    []AdditionalInfoEntry `smbios:"-"` // 05h
+}
+
+// AdditionalInfoEntry is defined in DSP0134 7.41.1.
+type AdditionalInfoEntry struct {
+	ReferencedHandle uint16 // 01h
+	ReferencedOffset uint8  // 03h
+	String           string // 04h
+	Value            []byte // 05h
+}
+
+// ParseAdditionalInfo parses a generic Table into AdditionalInfo.
+func ParseAdditionalInfo(t *Table) (*AdditionalInfo, error) {
+	if t.Type != TableTypeAdditionalInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	ai := &AdditionalInfo{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, ai)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(ai.NumEntries); i++ {
+		l, err := t.GetByteAt(off)
+		if err != nil || l < 5 || off+int(l) > t.Len() {
+			return nil, fmt.Errorf("invalid entry %d at offset %d", i+1, off)
+		}
+		var e AdditionalInfoEntry
+		e.ReferencedHandle, _ = t.GetWordAt(off + 1)
+		e.ReferencedOffset, _ = t.GetByteAt(off + 3)
+		e.String, _ = t.GetStringAt(off + 4)
+		e.Value, _ = t.GetBytesAt(off+5, int(l)-5)
+		ai.Entries = append(ai.Entries, e)
+		off += int(l)
+	}
+	return ai, nil
+}
+
+func (ai *AdditionalInfo) String() string {
+	lines := []string{ai.Header.handleString()}
+	for i, e := range ai.Entries {
+		var value string
+		switch len(e.Value) {
+		case 1:
+			value = fmt.Sprintf("0x%02x", e.Value[0])
+		case 2:
+			value = fmt.Sprintf("0x%04x", uint16(e.Value[0])|uint16(e.Value[1])<<8)
+		case 4:
+			value = fmt.Sprintf("0x%08x", uint32(e.Value[0])|uint32(e.Value[1])<<8|uint32(e.Value[2])<<16|uint32(e.Value[3])<<24)
+		default:
+			value = "Unexpected size"
+		}
+		lines = append(lines,
+			fmt.Sprintf("Additional Information %d", i+1),
+			fmt.Sprintf("\tReferenced Handle: 0x%04x", e.ReferencedHandle),
+			fmt.Sprintf("\tReferenced Offset: 0x%02x", e.ReferencedOffset),
+			fmt.Sprintf("\tString: %s", e.String),
+			fmt.Sprintf("\tValue: %s", value),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OnboardDevice is defined in DSP0134 7.42.
+type OnboardDevice struct {
+	Table
+	ReferenceDesignation string // 04h
+	DeviceType           uint8  // 05h
+	DeviceTypeInstance   uint8  // 06h
+	SegmentGroupNumber   uint16 // 07h
+	BusNumber            uint8  // 09h
+	DeviceFunctionNumber uint8  // 0Ah
+}
+
+// ParseOnboardDevice parses a generic Table into OnboardDevice.
+func ParseOnboardDevice(t *Table) (*OnboardDevice, error) {
+	if t.Type != TableTypeOnboardDevice {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xb {
+		return nil, errors.New("required fields missing")
+	}
+	od := &OnboardDevice{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, od); err != nil {
+		return nil, err
+	}
+	return od, nil
+}
+
+// Type returns the type of the device.
+func (od *OnboardDevice) Type() OnBoardDeviceType {
+	return OnBoardDeviceType(od.DeviceType & 0x7f)
+}
+
+// Enabled returns whether the device is enabled.
+func (od *OnboardDevice) Enabled() bool {
+	return od.DeviceType&0x80 != 0
+}
+
+func (od *OnboardDevice) String() string {
+	lines := []string{
+		od.Header.String(),
+		fmt.Sprintf("Reference Designation: %s", od.ReferenceDesignation),
+		fmt.Sprintf("Type: %s", od.Type()),
+		fmt.Sprintf("Status: %s", enabledString(od.Enabled())),
+		fmt.Sprintf("Type Instance: %d", od.DeviceTypeInstance),
+	}
+	if a := busAddress(od.SegmentGroupNumber, od.BusNumber, od.DeviceFunctionNumber); a != "" {
+		lines = append(lines, fmt.Sprintf("Bus Address: %s", a))
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestOnboardDevice(t *testing.T) {
+	table := &Table{
+		Header:  Header{Type: TableTypeOnboardDevice, Length: 11, Handle: 0x4b},
+		data:    []byte{0x29, 0x0b, 0x4b, 0x00, 0x01, 0x85, 0x01, 0x00, 0x00, 0x03, 0x00},
+		strings: []string{"Onboard LAN Atheros"},
+	}
+	od, err := ParseOnboardDevice(table)
+	if err != nil {
+		t.Fatalf("ParseOnboardDevice = %v", err)
+	}
+	if !od.Enabled() {
+		t.Errorf("Enabled = false, want true")
+	}
+	if got := od.Type().String(); got != "Ethernet" {
+		t.Errorf("Type = %q, want Ethernet", got)
+	}
+	want := `Handle 0x004B, DMI type 41, 11 bytes
+Onboard Device
+	Reference Designation: Onboard LAN Atheros
+	Type: Ethernet
+	Status: Enabled
+	Type Instance: 1
+	Bus Address: 0000:03:00.0`
+	if got := od.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+
+	table.data = table.data[:10]
+	table.Length = 10
+	if _, err := ParseOnboardDevice(table); err == nil {
+		t.Errorf("ParseOnboardDevice(10 bytes) = nil, want error")
+	}
+}
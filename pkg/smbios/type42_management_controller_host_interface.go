@@ -0,0 +1,136 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ManagementControllerHostInterface is defined in DSP0134 7.43.
+type ManagementControllerHostInterface struct {
+	Table
+	InterfaceType    HostInterfaceType       // 04h
+	InterfaceDataLen uint8                   // 05h
+	InterfaceData    []byte                  `smbios:"-"` // 06h
+	ProtocolRecords  []HostInterfaceProtocol `smbios:"-"` // 07h + InterfaceDataLen
+}
+
+// HostInterfaceProtocol is a protocol record, defined in DSP0134 7.43.2.
+type HostInterfaceProtocol struct {
+	Type HostInterfaceProtocolType // 00h
+	Data []byte                    // 02h
+}
+
+// ParseManagementControllerHostInterface parses a generic Table into
+// ManagementControllerHostInterface.
+func ParseManagementControllerHostInterface(t *Table) (*ManagementControllerHostInterface, error) {
+	if t.Type != TableTypeManagementControllerHostInterface {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x6 {
+		return nil, errors.New("required fields missing")
+	}
+	hi := &ManagementControllerHostInterface{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, hi)
+	if err != nil {
+		return nil, err
+	}
+	if hi.InterfaceData, err = t.GetBytesAt(off, int(hi.InterfaceDataLen)); err != nil {
+		return nil, fmt.Errorf("interface data: %w", err)
+	}
+	off += int(hi.InterfaceDataLen)
+	// Protocol records were added in 3.2.
+	n, err := t.GetByteAt(off)
+	if err != nil {
+		return hi, nil
+	}
+	off++
+	for i := 0; i < int(n); i++ {
+		typ, err := t.GetByteAt(off)
+		if err != nil {
+			return nil, fmt.Errorf("protocol record %d: %w", i+1, err)
+		}
+		l, _ := t.GetByteAt(off + 1)
+		data, err := t.GetBytesAt(off+2, int(l))
+		if err != nil {
+			return nil, fmt.Errorf("protocol record %d: %w", i+1, err)
+		}
+		hi.ProtocolRecords = append(hi.ProtocolRecords, HostInterfaceProtocol{Type: HostInterfaceProtocolType(typ), Data: data})
+		off += 2 + int(l)
+	}
+	return hi, nil
+}
+
+func (hi *ManagementControllerHostInterface) String() string {
+	lines := []string{
+		hi.Header.String(),
+		fmt.Sprintf("Host Interface Type: %s", hi.InterfaceType),
+	}
+	if hi.InterfaceType == 0x40 && len(hi.InterfaceData) > 0 {
+		lines = append(lines, fmt.Sprintf("Device Type: %s", networkDeviceType(hi.InterfaceData[0])))
+	}
+	for _, p := range hi.ProtocolRecords {
+		lines = append(lines, fmt.Sprintf("Protocol ID: %02x (%s)", uint8(p.Type), p.Type))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// HostInterfaceType is defined in DSP0239.
+type HostInterfaceType uint8
+
+func (v HostInterfaceType) String() string {
+	names := map[HostInterfaceType]string{
+		0x02: "KCS: Keyboard Controller Style",
+		0x03: "8250 UART Register Compatible",
+		0x04: "16450 UART Register Compatible",
+		0x05: "16550/16550A UART Register Compatible",
+		0x06: "16650/16650A UART Register Compatible",
+		0x07: "16750/16750A UART Register Compatible",
+		0x08: "16850/16850A UART Register Compatible",
+		0x40: "Network",
+		0xf0: "OEM",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// networkDeviceType decodes the device type of a network host interface, as
+// defined in DSP0270.
+func networkDeviceType(v uint8) string {
+	switch v {
+	case 0x02:
+		return "USB"
+	case 0x03:
+		return "PCI/PCIe"
+	case 0x04:
+		return "USB v2"
+	case 0x05:
+		return "PCI/PCIe v2"
+	}
+	if v >= 0x80 {
+		return "OEM"
+	}
+	return outOfSpec
+}
+
+// HostInterfaceProtocolType is defined in DSP0239.
+type HostInterfaceProtocolType uint8
+
+func (v HostInterfaceProtocolType) String() string {
+	names := map[HostInterfaceProtocolType]string{
+		0x02: "IPMI",
+		0x03: "MCTP",
+		0x04: "Redfish over IP",
+		0xf0: "OEM",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
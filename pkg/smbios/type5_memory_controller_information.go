@@ -0,0 +1,202 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryControllerInfo is defined in DSP0134 7.6. The structure is obsolete
+// since SMBIOS 2.1, but still found on older systems.
+type MemoryControllerInfo struct {
+	Table
+	ErrorDetectingMethod      MemoryErrorDetectingMethod      // 04h
+	ErrorCorrectingCapability MemoryErrorCorrectingCapability // 05h
+	SupportedInterleave       MemoryInterleave                // 06h
+	CurrentInterleave         MemoryInterleave                // 07h
+	MaximumModuleSize         uint8                           // 08h
+	SupportedSpeeds           MemorySpeeds                    // 09h
+	SupportedMemoryTypes      MemoryModuleTypes               // 0Bh
+	ModuleVoltage             MemoryModuleVoltage             // 0Dh
+	NumberOfSlots             uint8                           // 0Eh
+	SlotHandles               []uint16                        `smbios:"-"` // 0Fh
+	EnabledErrorCorrecting    MemoryErrorCorrectingCapability `smbios:"-"` // 0Fh + 2 * NumberOfSlots
+}
+
+// ParseMemoryControllerInfo parses a generic Table into MemoryControllerInfo.
+func ParseMemoryControllerInfo(t *Table) (*MemoryControllerInfo, error) {
+	return parseMemoryControllerInfo(parseStruct, t)
+}
+
+func parseMemoryControllerInfo(parseFn parseStructure, t *Table) (*MemoryControllerInfo, error) {
+	if t.Type != TableTypeMemoryControllerInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	mc := &MemoryControllerInfo{Table: *t}
+	off, err := parseFn(t, 0 /* off */, false /* complete */, mc)
+	if err != nil {
+		return nil, err
+	}
+	if t.Len() < off+2*int(mc.NumberOfSlots) {
+		return nil, fmt.Errorf("invalid data length %d for %d slots", t.Len(), mc.NumberOfSlots)
+	}
+	for i := 0; i < int(mc.NumberOfSlots); i++ {
+		h, _ := t.GetWordAt(off)
+		mc.SlotHandles = append(mc.SlotHandles, h)
+		off += 2
+	}
+	if v, err := t.GetByteAt(off); err == nil { // 2.1+
+		mc.EnabledErrorCorrecting = MemoryErrorCorrectingCapability(v)
+	}
+	return mc, nil
+}
+
+func (mc *MemoryControllerInfo) String() string {
+	lines := []string{
+		mc.Header.String(),
+		fmt.Sprintf("Error Detecting Method: %s", mc.ErrorDetectingMethod),
+		fmt.Sprintf("Error Correcting Capabilities:%s", mc.ErrorCorrectingCapability),
+		fmt.Sprintf("Supported Interleave: %s", mc.SupportedInterleave),
+		fmt.Sprintf("Current Interleave: %s", mc.CurrentInterleave),
+		fmt.Sprintf("Maximum Memory Module Size: %d MB", 1<<mc.MaximumModuleSize),
+		fmt.Sprintf("Maximum Total Memory Size: %d MB", (1<<mc.MaximumModuleSize)*int(mc.NumberOfSlots)),
+		fmt.Sprintf("Supported Speeds:%s", mc.SupportedSpeeds),
+		fmt.Sprintf("Supported Memory Types:%s", mc.SupportedMemoryTypes.list()),
+		fmt.Sprintf("Memory Module Voltage: %s", mc.ModuleVoltage),
+		fmt.Sprintf("Associated Memory Slots: %d", mc.NumberOfSlots),
+	}
+	for _, h := range mc.SlotHandles {
+		lines = append(lines, fmt.Sprintf("\t0x%04X", h))
+	}
+	if mc.Len() > 0xf+2*int(mc.NumberOfSlots) {
+		lines = append(lines, fmt.Sprintf("Enabled Error Correcting Capabilities:%s", mc.EnabledErrorCorrecting))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryErrorDetectingMethod is defined in DSP0134 7.6.1.
+type MemoryErrorDetectingMethod uint8
+
+func (v MemoryErrorDetectingMethod) String() string {
+	names := map[MemoryErrorDetectingMethod]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "None",
+		0x04: "8-bit Parity",
+		0x05: "32-bit ECC",
+		0x06: "64-bit ECC",
+		0x07: "128-bit ECC",
+		0x08: "CRC",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryErrorCorrectingCapability is defined in DSP0134 7.6.2.
+type MemoryErrorCorrectingCapability uint8
+
+// String returns the capabilities as an indented list, one per line.
+func (v MemoryErrorCorrectingCapability) String() string {
+	return bitList(uint64(v&0x3f), []string{
+		"Other",
+		"Unknown",
+		"None",
+		"Single-bit Error Correcting",
+		"Double-bit Error Correcting",
+		"Error Scrubbing",
+	})
+}
+
+// MemoryInterleave is defined in DSP0134 7.6.3.
+type MemoryInterleave uint8
+
+func (v MemoryInterleave) String() string {
+	names := map[MemoryInterleave]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "One-way Interleave",
+		0x04: "Two-way Interleave",
+		0x05: "Four-way Interleave",
+		0x06: "Eight-way Interleave",
+		0x07: "Sixteen-way Interleave",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemorySpeeds is defined in DSP0134 7.6.4.
+type MemorySpeeds uint16
+
+// String returns the speeds as an indented list, one per line.
+func (v MemorySpeeds) String() string {
+	return bitList(uint64(v&0x1f), []string{
+		"Other",
+		"Unknown",
+		"70 ns",
+		"60 ns",
+		"50 ns",
+	})
+}
+
+// MemoryModuleTypes is defined in DSP0134 7.7.1.
+type MemoryModuleTypes uint16
+
+var memoryModuleTypeNames = []string{
+	"Other",
+	"Unknown",
+	"Standard",
+	"FPM",
+	"EDO",
+	"Parity",
+	"ECC",
+	"SIMM",
+	"DIMM",
+	"Burst EDO",
+	"SDRAM",
+}
+
+// String returns the types on one line.
+func (v MemoryModuleTypes) String() string {
+	var names []string
+	for i, name := range memoryModuleTypeNames {
+		if v&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "None"
+	}
+	return strings.Join(names, " ")
+}
+
+// list returns the types as an indented list, one per line.
+func (v MemoryModuleTypes) list() string {
+	return bitList(uint64(v&0x7ff), memoryModuleTypeNames)
+}
+
+// MemoryModuleVoltage is defined in DSP0134 7.6.
+type MemoryModuleVoltage uint8
+
+func (v MemoryModuleVoltage) String() string {
+	var volts []string
+	for i, name := range []string{"5.0 V", "3.3 V", "2.9 V"} {
+		if v&(1<<i) != 0 {
+			volts = append(volts, name)
+		}
+	}
+	if len(volts) == 0 {
+		return "Unknown"
+	}
+	return strings.Join(volts, " ")
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"testing"
+)
+
+func TestMemoryControllerInfo(t *testing.T) {
+	table := &Table{
+		Header: Header{Type: TableTypeMemoryControllerInfo, Length: 24, Handle: 5},
+		data: []byte{
+			0x05, 0x18, 0x05, 0x00, 0x06, 0x04, 0x03, 0x03, 0x0a, 0x0c, 0x00, 0x14, 0x00, 0x02, 0x04, 0x06,
+			0x00, 0x07, 0x00, 0x08, 0x00, 0x09, 0x00, 0x04,
+		},
+	}
+	mc, err := ParseMemoryControllerInfo(table)
+	if err != nil {
+		t.Fatalf("ParseMemoryControllerInfo = %v", err)
+	}
+	want := `Handle 0x0005, DMI type 5, 24 bytes
+Memory Controller Information
+	Error Detecting Method: 64-bit ECC
+	Error Correcting Capabilities:
+		None
+	Supported Interleave: One-way Interleave
+	Current Interleave: One-way Interleave
+	Maximum Memory Module Size: 1024 MB
+	Maximum Total Memory Size: 4096 MB
+	Supported Speeds:
+		70 ns
+		60 ns
+	Supported Memory Types:
+		Standard
+		EDO
+	Memory Module Voltage: 3.3 V
+	Associated Memory Slots: 4
+		0x0006
+		0x0007
+		0x0008
+		0x0009
+	Enabled Error Correcting Capabilities:
+		None`
+	if got := mc.String(); got != want {
+		t.Errorf("String = %s\nwant %s", got, want)
+	}
+
+	table.data = table.data[:0x10]
+	if _, err := ParseMemoryControllerInfo(table); err == nil {
+		t.Errorf("ParseMemoryControllerInfo with truncated slot handles = nil, want error")
+	}
+	table.Type = TableTypeCacheInfo
+	if _, err := ParseMemoryControllerInfo(table); err == nil {
+		t.Errorf("ParseMemoryControllerInfo(type 7) = nil, want error")
+	}
+}
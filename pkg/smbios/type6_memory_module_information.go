@@ -0,0 +1,107 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryModuleInfo is defined in DSP0134 7.7. The structure is obsolete since
+// SMBIOS 2.1, but still found on older systems.
+type MemoryModuleInfo struct {
+	Table
+	SocketDesignation string            // 04h
+	BankConnections   uint8             // 05h
+	CurrentSpeed      uint8             // 06h
+	CurrentMemoryType MemoryModuleTypes // 07h
+	InstalledSize     MemoryModuleSize  // 09h
+	EnabledSize       MemoryModuleSize  // 0Ah
+	ErrorStatus       uint8             // 0Bh
+}
+
+// ParseMemoryModuleInfo parses a generic Table into MemoryModuleInfo.
+func ParseMemoryModuleInfo(t *Table) (*MemoryModuleInfo, error) {
+	return parseMemoryModuleInfo(parseStruct, t)
+}
+
+func parseMemoryModuleInfo(parseFn parseStructure, t *Table) (*MemoryModuleInfo, error) {
+	if t.Type != TableTypeMemoryModuleInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xc {
+		return nil, errors.New("required fields missing")
+	}
+	mm := &MemoryModuleInfo{Table: *t}
+	if _, err := parseFn(t, 0 /* off */, false /* complete */, mm); err != nil {
+		return nil, err
+	}
+	return mm, nil
+}
+
+func (mm *MemoryModuleInfo) String() string {
+	var banks string
+	switch {
+	case mm.BankConnections == 0xff:
+		banks = "None"
+	case mm.BankConnections&0xf0 == 0xf0:
+		banks = fmt.Sprintf("%d", mm.BankConnections&0xf)
+	case mm.BankConnections&0x0f == 0x0f:
+		banks = fmt.Sprintf("%d", mm.BankConnections>>4)
+	default:
+		banks = fmt.Sprintf("%d %d", mm.BankConnections>>4, mm.BankConnections&0xf)
+	}
+	speed := "Unknown"
+	if mm.CurrentSpeed != 0 {
+		speed = fmt.Sprintf("%d ns", mm.CurrentSpeed)
+	}
+	var errStatus string
+	switch {
+	case mm.ErrorStatus&0x4 != 0:
+		errStatus = "See Event Log"
+	case mm.ErrorStatus&0x3 == 0:
+		errStatus = "OK"
+	default:
+		var errs []string
+		if mm.ErrorStatus&0x1 != 0 {
+			errs = append(errs, "Uncorrectable Errors")
+		}
+		if mm.ErrorStatus&0x2 != 0 {
+			errs = append(errs, "Correctable Errors")
+		}
+		errStatus = strings.Join(errs, " ")
+	}
+	lines := []string{
+		mm.Header.String(),
+		fmt.Sprintf("Socket Designation: %s", mm.SocketDesignation),
+		fmt.Sprintf("Bank Connections: %s", banks),
+		fmt.Sprintf("Current Speed: %s", speed),
+		fmt.Sprintf("Type: %s", mm.CurrentMemoryType),
+		fmt.Sprintf("Installed Size: %s", mm.InstalledSize),
+		fmt.Sprintf("Enabled Size: %s", mm.EnabledSize),
+		fmt.Sprintf("Error Status: %s", errStatus),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryModuleSize is defined in DSP0134 7.7.2.
+type MemoryModuleSize uint8
+
+func (v MemoryModuleSize) String() string {
+	switch v & 0x7f {
+	case 0x7d:
+		return "Not Determinable"
+	case 0x7e:
+		return "Disabled"
+	case 0x7f:
+		return "Not Installed"
+	}
+	conn := "Single-bank"
+	if v&0x80 != 0 {
+		conn = "Double-bank"
+	}
+	return fmt.Sprintf("%d MB (%s Connection)", 1<<(v&0x7f), conn)
+}
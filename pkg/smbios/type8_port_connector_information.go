@@ -0,0 +1,157 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PortConnectorInfo is defined in DSP0134 7.9.
+type PortConnectorInfo struct {
+	Table
+	InternalReferenceDesignator string        // 04h
+	InternalConnectorType       ConnectorType // 05h
+	ExternalReferenceDesignator string        // 06h
+	ExternalConnectorType       ConnectorType // 07h
+	PortType                    PortType      // 08h
+}
+
+// ParsePortConnectorInfo parses a generic Table into PortConnectorInfo.
+func ParsePortConnectorInfo(t *Table) (*PortConnectorInfo, error) {
+	return parsePortConnectorInfo(parseStruct, t)
+}
+
+func parsePortConnectorInfo(parseFn parseStructure, t *Table) (*PortConnectorInfo, error) {
+	if t.Type != TableTypePortConnectorInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x9 {
+		return nil, errors.New("required fields missing")
+	}
+	pc := &PortConnectorInfo{Table: *t}
+	if _, err := parseFn(t, 0 /* off */, false /* complete */, pc); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+func (pc *PortConnectorInfo) String() string {
+	lines := []string{
+		pc.Header.String(),
+		fmt.Sprintf("Internal Reference Designator: %s", pc.InternalReferenceDesignator),
+		fmt.Sprintf("Internal Connector Type: %s", pc.InternalConnectorType),
+		fmt.Sprintf("External Reference Designator: %s", pc.ExternalReferenceDesignator),
+		fmt.Sprintf("External Connector Type: %s", pc.ExternalConnectorType),
+		fmt.Sprintf("Port Type: %s", pc.PortType),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// ConnectorType is defined in DSP0134 7.9.2.
+type ConnectorType uint8
+
+func (v ConnectorType) String() string {
+	names := map[ConnectorType]string{
+		0x00: "None",
+		0x01: "Centronics",
+		0x02: "Mini Centronics",
+		0x03: "Proprietary",
+		0x04: "DB-25 male",
+		0x05: "DB-25 female",
+		0x06: "DB-15 male",
+		0x07: "DB-15 female",
+		0x08: "DB-9 male",
+		0x09: "DB-9 female",
+		0x0a: "RJ-11",
+		0x0b: "RJ-45",
+		0x0c: "50 Pin MiniSCSI",
+		0x0d: "Mini DIN",
+		0x0e: "Micro DIN",
+		0x0f: "PS/2",
+		0x10: "Infrared",
+		0x11: "HP-HIL",
+		0x12: "Access Bus (USB)",
+		0x13: "SSA SCSI",
+		0x14: "Circular DIN-8 male",
+		0x15: "Circular DIN-8 female",
+		0x16: "On Board IDE",
+		0x17: "On Board Floppy",
+		0x18: "9 Pin Dual Inline (pin 10 cut)",
+		0x19: "25 Pin Dual Inline (pin 26 cut)",
+		0x1a: "50 Pin Dual Inline",
+		0x1b: "68 Pin Dual Inline",
+		0x1c: "On Board Sound Input From CD-ROM",
+		0x1d: "Mini Centronics Type-14",
+		0x1e: "Mini Centronics Type-26",
+		0x1f: "Mini Jack (headphones)",
+		0x20: "BNC",
+		0x21: "IEEE 1394",
+		0x22: "SAS/SATA Plug Receptacle",
+		0x23: "USB Type-C Receptacle",
+		0xa0: "PC-98",
+		0xa1: "PC-98 Hireso",
+		0xa2: "PC-H98",
+		0xa3: "PC-98 Note",
+		0xa4: "PC-98 Full",
+		0xff: "Other",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// PortType is defined in DSP0134 7.9.3.
+type PortType uint8
+
+func (v PortType) String() string {
+	names := map[PortType]string{
+		0x00: "None",
+		0x01: "Parallel Port XT/AT Compatible",
+		0x02: "Parallel Port PS/2",
+		0x03: "Parallel Port ECP",
+		0x04: "Parallel Port EPP",
+		0x05: "Parallel Port ECP/EPP",
+		0x06: "Serial Port XT/AT Compatible",
+		0x07: "Serial Port 16450 Compatible",
+		0x08: "Serial Port 16550 Compatible",
+		0x09: "Serial Port 16550A Compatible",
+		0x0a: "SCSI Port",
+		0x0b: "MIDI Port",
+		0x0c: "Joystick Port",
+		0x0d: "Keyboard Port",
+		0x0e: "Mouse Port",
+		0x0f: "SSA SCSI",
+		0x10: "USB",
+		0x11: "Firewire (IEEE P1394)",
+		0x12: "PCMCIA Type I",
+		0x13: "PCMCIA Type II",
+		0x14: "PCMCIA Type III",
+		0x15: "Cardbus",
+		0x16: "Access Bus Port",
+		0x17: "SCSI II",
+		0x18: "SCSI Wide",
+		0x19: "PC-98",
+		0x1a: "PC-98 Hireso",
+		0x1b: "PC-H98",
+		0x1c: "Video Port",
+		0x1d: "Audio Port",
+		0x1e: "Modem Port",
+		0x1f: "Network Port",
+		0x20: "SATA",
+		0x21: "SAS",
+		0x22: "MFDP (Multi-Function Display Port)",
+		0x23: "Thunderbolt",
+		0xa0: "8251 Compatible",
+		0xa1: "8251 FIFO Compatible",
+		0xff: "Other",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
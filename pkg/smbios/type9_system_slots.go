@@ -7,6 +7,7 @@ package smbios
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // SystemSlots is defined in DSP0134 7.10.
@@ -36,7 +37,7 @@ func parseSystemSlots(parseFn parseStructure, t *Table) (*SystemSlots, error) {
 	if t.Type != TableTypeSystemSlots {
 		return nil, fmt.Errorf("invalid table type %d", t.Type)
 	}
-	if t.Len() < 0x11 {
+	if t.Len() < 0xc {
 		return nil, errors.New("required fields missing")
 	}
 
@@ -47,3 +48,215 @@ func parseSystemSlots(parseFn parseStructure, t *Table) (*SystemSlots, error) {
 	}
 	return ss, nil
 }
+
+func (ss *SystemSlots) String() string {
+	lines := []string{
+		ss.Header.String(),
+		fmt.Sprintf("Designation: %s", ss.SlotDesignation),
+		fmt.Sprintf("Type: %s%s", SlotDataBusWidth(ss.SlotDataBusWidth), SlotType(ss.SlotType)),
+		fmt.Sprintf("Current Usage: %s", SlotUsage(ss.CurrentUsage)),
+		fmt.Sprintf("Length: %s", SlotLength(ss.SlotLength)),
+	}
+	id, adapter := ss.SlotID&0xff, ss.SlotID>>8
+	switch t := ss.SlotType; {
+	case t == 0x04 || t == 0x05 || t == 0x06 || (t >= 0x0e && t <= 0x13) || (t >= 0x1f && t <= 0x25) || (t >= 0xa5 && t <= 0xc4):
+		lines = append(lines, fmt.Sprintf("ID: %d", id))
+	case t == 0x07:
+		lines = append(lines, fmt.Sprintf("ID: Adapter %d, Socket %d", id, adapter))
+	}
+	c1, c2 := ss.SlotCharacteristics1, ss.SlotCharacteristics2
+	switch {
+	case c1&0x01 != 0:
+		lines = append(lines, "Characteristics: Unknown")
+	case c1&0xfe == 0 && c2&0x07 == 0:
+		lines = append(lines, "Characteristics: None")
+	default:
+		lines = append(lines, "Characteristics:"+bitList(uint64(c1&0xfe)|uint64(c2)<<8, []string{
+			"", // Characteristics unknown, handled above.
+			"5.0 V is provided",
+			"3.3 V is provided",
+			"Opening is shared",
+			"PC Card-16 is supported",
+			"Cardbus is supported",
+			"Zoom Video is supported",
+			"Modem ring resume is supported",
+			"PME signal is supported",
+			"Hot-plug devices are supported",
+			"SMBus signal is supported",
+			"PCIe slot bifurcation is supported",
+			"Async/surprise removal is supported",
+			"Flexbus slot, CXL 1.0 capable",
+			"Flexbus slot, CXL 2.0 capable",
+			"Flexbus slot, CXL 3.0 capable",
+		}))
+	}
+	if ss.Len() >= 0x11 {
+		if a := busAddress(ss.SegmentGroupNumber, ss.BusNumber, ss.DeviceFunctionNumber); a != "" {
+			lines = append(lines, fmt.Sprintf("Bus Address: %s", a))
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// busAddress formats a PCI address as lspci does, or returns an empty string
+// if the address is not provided.
+func busAddress(segment uint16, bus, devFn uint8) string {
+	if segment == 0xffff && bus == 0xff && devFn == 0xff {
+		return ""
+	}
+	return fmt.Sprintf("%04x:%02x:%02x.%x", segment, bus, devFn>>3, devFn&7)
+}
+
+// SlotType is defined in DSP0134 7.10.1.
+type SlotType uint8
+
+func (v SlotType) String() string {
+	names := map[SlotType]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "ISA",
+		0x04: "MCA",
+		0x05: "EISA",
+		0x06: "PCI",
+		0x07: "PC Card (PCMCIA)",
+		0x08: "VLB",
+		0x09: "Proprietary",
+		0x0a: "Processor Card",
+		0x0b: "Proprietary Memory Card",
+		0x0c: "I/O Riser Card",
+		0x0d: "NuBus",
+		0x0e: "PCI-66",
+		0x0f: "AGP",
+		0x10: "AGP 2x",
+		0x11: "AGP 4x",
+		0x12: "PCI-X",
+		0x13: "AGP 8x",
+		0x14: "M.2 Socket 1-DP",
+		0x15: "M.2 Socket 1-SD",
+		0x16: "M.2 Socket 2",
+		0x17: "M.2 Socket 3",
+		0x18: "MXM Type I",
+		0x19: "MXM Type II",
+		0x1a: "MXM Type III",
+		0x1b: "MXM Type III-HE",
+		0x1c: "MXM Type IV",
+		0x1d: "MXM 3.0 Type A",
+		0x1e: "MXM 3.0 Type B",
+		0x1f: "PCI Express 2 SFF-8639 (U.2)",
+		0x20: "PCI Express 3 SFF-8639 (U.2)",
+		0x21: "PCI Express Mini 52-pin with bottom-side keep-outs",
+		0x22: "PCI Express Mini 52-pin without bottom-side keep-outs",
+		0x23: "PCI Express Mini 76-pin",
+		0x24: "PCI Express 4 SFF-8639 (U.2)",
+		0x25: "PCI Express 5 SFF-8639 (U.2)",
+		0x26: "OCP NIC 3.0 Small Form Factor (SFF)",
+		0x27: "OCP NIC 3.0 Large Form Factor (LFF)",
+		0x28: "OCP NIC Prior to 3.0",
+		0x30: "CXL FLexbus 1.0",
+		0xa0: "PC-98/C20",
+		0xa1: "PC-98/C24",
+		0xa2: "PC-98/E",
+		0xa3: "PC-98/Local Bus",
+		0xa4: "PC-98/Card",
+		0xa5: "PCI Express",
+		0xa6: "PCI Express x1",
+		0xa7: "PCI Express x2",
+		0xa8: "PCI Express x4",
+		0xa9: "PCI Express x8",
+		0xaa: "PCI Express x16",
+		0xab: "PCI Express 2",
+		0xac: "PCI Express 2 x1",
+		0xad: "PCI Express 2 x2",
+		0xae: "PCI Express 2 x4",
+		0xaf: "PCI Express 2 x8",
+		0xb0: "PCI Express 2 x16",
+		0xb1: "PCI Express 3",
+		0xb2: "PCI Express 3 x1",
+		0xb3: "PCI Express 3 x2",
+		0xb4: "PCI Express 3 x4",
+		0xb5: "PCI Express 3 x8",
+		0xb6: "PCI Express 3 x16",
+		0xb8: "PCI Express 4",
+		0xb9: "PCI Express 4 x1",
+		0xba: "PCI Express 4 x2",
+		0xbb: "PCI Express 4 x4",
+		0xbc: "PCI Express 4 x8",
+		0xbd: "PCI Express 4 x16",
+		0xbe: "PCI Express 5",
+		0xbf: "PCI Express 5 x1",
+		0xc0: "PCI Express 5 x2",
+		0xc1: "PCI Express 5 x4",
+		0xc2: "PCI Express 5 x8",
+		0xc3: "PCI Express 5 x16",
+		0xc4: "PCI Express 6+",
+		0xc5: "EDSFF E1",
+		0xc6: "EDSFF E3",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// SlotDataBusWidth is defined in DSP0134 7.10.2.
+type SlotDataBusWidth uint8
+
+// String returns the width followed by a space, so that it can prefix the
+// slot type, or nothing if the width is unknown.
+func (v SlotDataBusWidth) String() string {
+	names := map[SlotDataBusWidth]string{
+		0x01: "", // Other
+		0x02: "", // Unknown
+		0x03: "8-bit ",
+		0x04: "16-bit ",
+		0x05: "32-bit ",
+		0x06: "64-bit ",
+		0x07: "128-bit ",
+		0x08: "x1 ",
+		0x09: "x2 ",
+		0x0a: "x4 ",
+		0x0b: "x8 ",
+		0x0c: "x12 ",
+		0x0d: "x16 ",
+		0x0e: "x32 ",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec + " "
+}
+
+// SlotUsage is defined in DSP0134 7.10.3.
+type SlotUsage uint8
+
+func (v SlotUsage) String() string {
+	names := map[SlotUsage]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Available",
+		0x04: "In Use",
+		0x05: "Unavailable",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// SlotLength is defined in DSP0134 7.10.4.
+type SlotLength uint8
+
+func (v SlotLength) String() string {
+	names := map[SlotLength]string{
+		0x01: "Other",
+		0x02: "Unknown",
+		0x03: "Short",
+		0x04: "Long",
+		0x05: "2.5\" drive form factor",
+		0x06: "3.5\" drive form factor",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
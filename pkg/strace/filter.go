@@ -0,0 +1,118 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (linux && arm64) || (linux && amd64) || (linux && riscv64)
+// +build linux,arm64 linux,amd64 linux,riscv64
+
+package strace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownSyscall is returned when a filter names a syscall or class this
+// architecture does not have.
+var ErrUnknownSyscall = errors.New("unknown syscall")
+
+// classes are the syscall classes of strace -e trace=%class, other than
+// %file, which is every syscall taking a path.
+var classes = map[string][]string{
+	"process": {
+		"clone", "clone3", "fork", "vfork", "execve", "execveat", "exit", "exit_group",
+		"wait4", "waitid", "kill", "tkill", "tgkill", "rt_sigqueueinfo", "rt_tgsigqueueinfo",
+		"pidfd_open", "pidfd_send_signal", "unshare", "setns",
+	},
+	"network": {
+		"socket", "socketpair", "bind", "listen", "accept", "accept4", "connect",
+		"getsockname", "getpeername", "sendto", "recvfrom", "sendmsg", "recvmsg",
+		"sendmmsg", "recvmmsg", "shutdown", "setsockopt", "getsockopt",
+	},
+	"signal": {
+		"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "rt_sigpending",
+		"rt_sigtimedwait", "rt_sigsuspend", "sigaltstack", "signalfd", "signalfd4",
+		"kill", "tkill", "tgkill", "pause",
+	},
+	"memory": {
+		"mmap", "munmap", "mprotect", "mremap", "madvise", "brk", "msync", "mlock",
+		"munlock", "mlockall", "munlockall", "mincore", "mbind", "remap_file_pages",
+	},
+}
+
+// Filter selects the syscalls to trace, as strace -e trace= does.
+type Filter struct {
+	negate bool
+	sysnos map[uintptr]bool
+}
+
+// fileSyscalls returns every syscall taking a path.
+func fileSyscalls() []uintptr {
+	var s []uintptr
+	for sysno, info := range syscalls {
+		for _, f := range info.format {
+			if f == Path || f == PostPath {
+				s = append(s, sysno)
+				break
+			}
+		}
+	}
+	return s
+}
+
+// ParseFilter parses a comma separated list of syscall names and classes,
+// e.g. "open,read,%network". A leading ! traces all but the listed syscalls.
+//
+// Syscalls in a class that this architecture does not have, e.g. fork on
+// arm64, are skipped; syscalls named directly must exist.
+func ParseFilter(spec string) (*Filter, error) {
+	f := &Filter{sysnos: map[uintptr]bool{}}
+	if strings.HasPrefix(spec, "!") {
+		f.negate = true
+		spec = spec[1:]
+	}
+	for _, name := range strings.Split(spec, ",") {
+		switch {
+		case name == "":
+			continue
+		case name == "%file":
+			for _, sysno := range fileSyscalls() {
+				f.sysnos[sysno] = true
+			}
+		case strings.HasPrefix(name, "%"):
+			names, ok := classes[name[1:]]
+			if !ok {
+				return nil, fmt.Errorf("%s: %w class", name, ErrUnknownSyscall)
+			}
+			for _, n := range names {
+				if sysno, err := ByName(n); err == nil {
+					f.sysnos[sysno] = true
+				}
+			}
+		default:
+			sysno, err := ByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, ErrUnknownSyscall)
+			}
+			f.sysnos[sysno] = true
+		}
+	}
+	return f, nil
+}
+
+// Match reports whether the syscall sysno is traced.
+func (f *Filter) Match(sysno int) bool {
+	return f.sysnos[uintptr(sysno)] != f.negate
+}
+
+// Callback returns an EventCallback calling cb for the syscalls f matches
+// and for all events other than syscalls.
+func (f *Filter) Callback(cb EventCallback) EventCallback {
+	return func(t Task, record *TraceRecord) error {
+		if (record.Event == SyscallEnter || record.Event == SyscallExit) && !f.Match(record.Syscall.Sysno) {
+			return nil
+		}
+		return cb(t, record)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (linux && arm64) || (linux && amd64) || (linux && riscv64)
+// +build linux,arm64 linux,amd64 linux,riscv64
+
+package strace
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	for _, tt := range []struct {
+		spec  string
+		match []string
+		skip  []string
+	}{
+		{spec: "openat,read", match: []string{"openat", "read"}, skip: []string{"write", "close"}},
+		{spec: "!openat", match: []string{"read", "write"}, skip: []string{"openat"}},
+		{spec: "%file", match: []string{"openat", "execve", "chdir"}, skip: []string{"read", "socket"}},
+		{spec: "%network,close", match: []string{"socket", "connect", "close"}, skip: []string{"openat"}},
+		{spec: "!%memory", match: []string{"read"}, skip: []string{"mmap", "brk"}},
+	} {
+		f, err := ParseFilter(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) = %v", tt.spec, err)
+		}
+		want := map[string]bool{}
+		for _, name := range tt.match {
+			want[name] = true
+		}
+		for _, name := range tt.skip {
+			want[name] = false
+		}
+		for name, w := range want {
+			sysno, err := ByName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Match(int(sysno)); got != w {
+				t.Errorf("ParseFilter(%q).Match(%s) = %v, want %v", tt.spec, name, got, w)
+			}
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, spec := range []string{"nosuchcall", "%nosuchclass", "read,nosuchcall"} {
+		if _, err := ParseFilter(spec); !errors.Is(err, ErrUnknownSyscall) {
+			t.Errorf("ParseFilter(%q) = %v, want %v", spec, err, ErrUnknownSyscall)
+		}
+	}
+}
@@ -327,6 +327,15 @@ func SysCallExit(t Task, s *SyscallEvent) string {
 	return i.printExit(t, s.Duration, s.Args, s.Ret[0], s.Errno)
 }
 
+// errnoString formats errno like strace does, e.g. ENOENT (no such file or
+// directory).
+func errnoString(errno unix.Errno) string {
+	if name := unix.ErrnoName(errno); name != "" {
+		return fmt.Sprintf("%s (%v)", name, errno)
+	}
+	return fmt.Sprintf("errno %d (%v)", int(errno), errno)
+}
+
 // printExit prints the given system call exit.
 func (i *SyscallInfo) printExit(t Task, elapsed time.Duration, args SyscallArguments, retval SyscallArgument, errno unix.Errno) string {
 	// Eventually, we'll be able to cache o and look at the entry record's output.
@@ -337,7 +346,7 @@ func (i *SyscallInfo) printExit(t Task, elapsed time.Duration, args SyscallArgum
 		i.post(t, args, retval, o, LogMaximumSize)
 		rval = fmt.Sprintf("%#x (%v)", retval.Uint64(), elapsed)
 	} else {
-		rval = fmt.Sprintf("%s (%#x) (%v)", errno, errno, elapsed)
+		rval = fmt.Sprintf("-1 %s (%v)", errnoString(errno), elapsed)
 	}
 
 	switch len(o) {
@@ -27,7 +27,9 @@ import (
 
 func wait(pid int) (int, unix.WaitStatus, error) {
 	var w unix.WaitStatus
-	pid, err := unix.Wait4(pid, &w, 0, nil)
+	// __WALL also waits for traced threads, and for processes that
+	// were attached to rather than started.
+	pid, err := unix.Wait4(pid, &w, unix.WALL, nil)
 	return pid, w, err
 }
 
@@ -149,6 +151,39 @@ func (t *tracer) call(p *process, rec *TraceRecord) error {
 
 var traceActive uint32
 
+// Options configure a trace.
+type Options struct {
+	// Follow also traces the children the traced processes fork and the
+	// threads they clone, as strace -f does.
+	Follow bool
+}
+
+func (o Options) ptraceOptions() int {
+	// Make it easy to distinguish syscall-stops from other SIGTRAPS, and
+	// tell ptrace to generate a SIGTRAP signal immediately before a new
+	// program is executed with the execve system call.
+	opts := unix.PTRACE_O_TRACESYSGOOD | unix.PTRACE_O_TRACEEXEC
+	if o.Follow {
+		// Automatically trace fork(2)'d, clone(2)'d, and vfork(2)'d children.
+		opts |= unix.PTRACE_O_TRACECLONE | unix.PTRACE_O_TRACEFORK | unix.PTRACE_O_TRACEVFORK
+	}
+	return opts
+}
+
+// activate makes sure only one trace is active at a time, and returns the
+// function that ends it.
+func activate() (func(), error) {
+	if !atomic.CompareAndSwapUint32(&traceActive, 0, 1) {
+		return nil, fmt.Errorf("a process trace is already active in this process")
+	}
+	// All ptrace requests have to come from the thread that attached.
+	runtime.LockOSThread()
+	return func() {
+		runtime.UnlockOSThread()
+		atomic.StoreUint32(&traceActive, 0)
+	}, nil
+}
+
 // Trace traces `c` and any children c clones.
 //
 // Only one trace can be active per process.
@@ -156,12 +191,16 @@ var traceActive uint32
 // recordCallback is called every time a process event happens with the process
 // in a stopped state.
 func Trace(c *exec.Cmd, recordCallback ...EventCallback) error {
-	if !atomic.CompareAndSwapUint32(&traceActive, 0, 1) {
-		return fmt.Errorf("a process trace is already active in this process")
+	return TraceWith(c, Options{Follow: true}, recordCallback...)
+}
+
+// TraceWith traces `c`, and with o.Follow any children c clones.
+func TraceWith(c *exec.Cmd, o Options, recordCallback ...EventCallback) error {
+	done, err := activate()
+	if err != nil {
+		return err
 	}
-	defer func() {
-		atomic.StoreUint32(&traceActive, 0)
-	}()
+	defer done()
 
 	if c.SysProcAttr == nil {
 		c.SysProcAttr = &syscall.SysProcAttr{}
@@ -169,10 +208,8 @@ func Trace(c *exec.Cmd, recordCallback ...EventCallback) error {
 	c.SysProcAttr.Ptrace = true
 
 	// Because the go runtime forks traced processes with PTRACE_TRACEME
-	// we need to maintain the parent-child relationship for ptrace to work.
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
+	// we need to maintain the parent-child relationship for ptrace to work,
+	// which the locked thread does.
 	if err := c.Start(); err != nil {
 		return err
 	}
@@ -213,15 +250,8 @@ func Trace(c *exec.Cmd, recordCallback ...EventCallback) error {
 	}
 	tracer.addProcess(c.Process.Pid, SyscallExit)
 
-	if err := unix.PtraceSetOptions(c.Process.Pid,
-		// Tells ptrace to generate a SIGTRAP signal immediately before a new program is executed with the execve system call.
-		unix.PTRACE_O_TRACEEXEC|
-			// Make it easy to distinguish syscall-stops from other SIGTRAPS.
-			unix.PTRACE_O_TRACESYSGOOD|
-			// Kill tracee if tracer exits.
-			unix.PTRACE_O_EXITKILL|
-			// Automatically trace fork(2)'d, clone(2)'d, and vfork(2)'d children.
-			unix.PTRACE_O_TRACECLONE|unix.PTRACE_O_TRACEFORK|unix.PTRACE_O_TRACEVFORK); err != nil {
+	// Kill tracee if tracer exits.
+	if err := unix.PtraceSetOptions(c.Process.Pid, o.ptraceOptions()|unix.PTRACE_O_EXITKILL); err != nil {
 		return &TraceError{
 			PID: c.Process.Pid,
 			Err: os.NewSyscallError("ptrace(PTRACE_SETOPTIONS)", err),
@@ -239,6 +269,68 @@ func Trace(c *exec.Cmd, recordCallback ...EventCallback) error {
 	return tracer.runLoop()
 }
 
+// threads returns the threads of a process.
+func threads(pid int) ([]int, error) {
+	ents, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, e := range ents {
+		var tid int
+		if _, err := fmt.Sscan(e.Name(), &tid); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// Attach traces the running process pid until it exits. With o.Follow,
+// all its threads and any children it clones are traced as well.
+//
+// Unlike a process started by Trace, the process is not killed if the
+// tracer exits; it is detached and continues.
+func Attach(pid int, o Options, recordCallback ...EventCallback) error {
+	done, err := activate()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	tids := []int{pid}
+	if o.Follow {
+		if tids, err = threads(pid); err != nil {
+			return &TraceError{PID: pid, Err: err}
+		}
+	}
+
+	tracer := &tracer{
+		processes: make(map[int]*process),
+		callback:  recordCallback,
+	}
+	for _, tid := range tids {
+		if err := unix.PtraceAttach(tid); err != nil {
+			return &TraceError{PID: tid, Err: os.NewSyscallError("ptrace(PTRACE_ATTACH)", err)}
+		}
+		// PTRACE_ATTACH stops the thread with a SIGSTOP.
+		if _, ws, err := wait(tid); err != nil {
+			return &TraceError{PID: tid, Err: os.NewSyscallError("wait4", err)}
+		} else if !ws.Stopped() {
+			return &TraceError{PID: tid, Err: fmt.Errorf("got %v, want stopped thread", ws)}
+		}
+		// The thread may be in the middle of a syscall, whose exit
+		// will then be taken for an enter.
+		tracer.addProcess(tid, SyscallExit)
+		if err := unix.PtraceSetOptions(tid, o.ptraceOptions()); err != nil {
+			return &TraceError{PID: tid, Err: os.NewSyscallError("ptrace(PTRACE_SETOPTIONS)", err)}
+		}
+		if err := unix.PtraceSyscall(tid, 0); err != nil {
+			return &TraceError{PID: tid, Err: fmt.Errorf("failed to resume: %v", err)}
+		}
+	}
+	return tracer.runLoop()
+}
+
 func (t *tracer) addProcess(pid int, event EventType) {
 	t.processes[pid] = &process{
 		pid: pid,
@@ -11,8 +11,13 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/user"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/u-root/u-root/pkg/compress"
 	"github.com/u-root/u-root/pkg/upath"
 )
 
@@ -31,6 +36,39 @@ type Opts struct {
 	// Change to this directory before any operations. This is equivalent
 	// to "tar -C DIR".
 	ChangeDirectory string
+
+	// Exclude lists shell patterns of files to leave out when creating or
+	// extracting an archive. As with GNU tar, a pattern may match any run
+	// of path elements, so "*.o" excludes all object files and "tmp"
+	// excludes every directory named tmp along with its contents.
+	Exclude []string
+
+	// NumericOwner records only user and group IDs when creating an
+	// archive, and ignores user and group names when extracting one.
+	NumericOwner bool
+
+	// Xattrs stores extended attributes, including file capabilities, in
+	// the archive and restores them on extraction, where supported.
+	Xattrs bool
+}
+
+// excluded reports whether name matches any of the Exclude patterns.
+func (o *Opts) excluded(name string) bool {
+	if len(o.Exclude) == 0 {
+		return false
+	}
+	elems := strings.Split(filepath.ToSlash(filepath.Clean(name)), "/")
+	for _, pattern := range o.Exclude {
+		pattern = strings.TrimSuffix(pattern, "/")
+		for i := range elems {
+			for j := i + 1; j <= len(elems); j++ {
+				if ok, _ := path.Match(pattern, strings.Join(elems[i:j], "/")); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
 // passesFilters returns true if the given file passes all filters, false otherwise.
@@ -43,9 +81,15 @@ func passesFilters(hdr *tar.Header, filters []Filter) bool {
 	return true
 }
 
-// applyToArchive applies function f to all files in the given archive
+// applyToArchive applies function f to all files in the given archive,
+// which may be compressed in any of the formats compress.NewReader detects.
 func applyToArchive(tarFile io.Reader, f func(tr *tar.Reader, hdr *tar.Header) error) error {
-	tr := tar.NewReader(tarFile)
+	r, _, err := compress.NewReader(tarFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -90,10 +134,10 @@ func ExtractDir(tarFile io.Reader, dir string, opts *Opts) error {
 	}
 
 	return applyToArchive(tarFile, func(tr *tar.Reader, hdr *tar.Header) error {
-		if !passesFilters(hdr, opts.Filters) {
+		if opts.excluded(hdr.Name) || !passesFilters(hdr, opts.Filters) {
 			return nil
 		}
-		return createFileInRoot(hdr, tr, dir)
+		return createFileInRoot(hdr, tr, dir, opts)
 	})
 }
 
@@ -123,7 +167,10 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 			// This "walk" function does not recurse.
 			walk = func(root string, walkFn filepath.WalkFunc) error {
 				fi, err := os.Lstat(root)
-				return walkFn(root, fi, err)
+				if err := walkFn(root, fi, err); err != filepath.SkipDir {
+					return err
+				}
+				return nil
 			}
 		}
 
@@ -142,6 +189,12 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 				// "cd" does nothing if the file is absolute.
 				bcPath = abcPath
 			}
+			if opts.excluded(bcPath) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
 			var symlink string
 			if info.Mode()&os.ModeSymlink == os.ModeSymlink {
@@ -154,6 +207,14 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 				return err
 			}
 			hdr.Name = bcPath
+			if opts.NumericOwner {
+				hdr.Uname, hdr.Gname = "", ""
+			}
+			if opts.Xattrs {
+				if err := readXattrs(abcPath, hdr); err != nil {
+					return err
+				}
+			}
 			if !passesFilters(hdr, opts.Filters) {
 				return nil
 			}
@@ -199,22 +260,54 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 	return tw.Close()
 }
 
-func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
+func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string, opts *Opts) error {
 	fi := hdr.FileInfo()
 	path, err := upath.SafeFilepathJoin(rootDir, hdr.Name)
+	if err == nil && !inRoot(rootDir, path) {
+		err = fmt.Errorf("%q leads outside of %q through a symbolic link", hdr.Name, rootDir)
+	}
 	if err != nil {
 		// The behavior is to skip files which are unsafe due to
 		// zipslip, but continue extracting everything else.
 		log.Printf("Warning: Skipping file %q due to: %v", hdr.Name, err)
 		return nil
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Replace symbolic links rather than writing through them.
+	if lfi, err := os.Lstat(path); err == nil && lfi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case hdr.Typeflag == tar.TypeLink:
+		target, err := upath.SafeFilepathJoin(rootDir, hdr.Linkname)
+		if err != nil || !inRoot(rootDir, target) {
+			log.Printf("Warning: Skipping hard link %q to %q", hdr.Name, hdr.Linkname)
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// Hard links share the target's metadata.
+		return os.Link(target, path)
 
-	switch fi.Mode() & os.ModeType {
-	case os.ModeSymlink:
-		// TODO: support symlinks
-		return fmt.Errorf("symlinks not yet supported: %q", path)
+	case fi.Mode()&os.ModeType == os.ModeSymlink:
+		if err := os.Symlink(hdr.Linkname, path); err != nil {
+			return err
+		}
+		if err := chown(path, hdr, opts); err != nil {
+			return err
+		}
+		if opts.Xattrs {
+			return writeXattrs(path, hdr)
+		}
+		return nil
 
-	case os.FileMode(0):
+	case fi.Mode()&os.ModeType == os.FileMode(0):
 		f, err := os.Create(path)
 		if err != nil {
 			return err
@@ -227,16 +320,16 @@ func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
 			return err
 		}
 
-	case os.ModeDir:
+	case fi.Mode()&os.ModeType == os.ModeDir:
 		if err := os.MkdirAll(path, fi.Mode()&os.ModePerm); err != nil {
 			return err
 		}
 
-	case os.ModeDevice:
+	case fi.Mode()&os.ModeType == os.ModeDevice:
 		// TODO: support block device
 		return fmt.Errorf("block device not yet supported: %q", path)
 
-	case os.ModeCharDevice:
+	case fi.Mode()&os.ModeType == os.ModeCharDevice:
 		// TODO: support char device
 		return fmt.Errorf("char device not yet supported: %q", path)
 
@@ -244,11 +337,70 @@ func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
 		return fmt.Errorf("%q: Unknown type %#o", path, fi.Mode()&os.ModeType)
 	}
 
-	if err := os.Chmod(path, fi.Mode()&os.ModePerm); err != nil {
-		return fmt.Errorf("error setting mode %#o on %q: %w",
-			fi.Mode()&os.ModePerm, path, err)
+	// Changing the owner clears the set-ID bits and file capabilities, so
+	// it goes first.
+	if err := chown(path, hdr, opts); err != nil {
+		return err
+	}
+	mode := fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("error setting mode %#o on %q: %w", mode, path, err)
+	}
+	if opts.Xattrs {
+		return writeXattrs(path, hdr)
+	}
+	return nil
+}
+
+// inRoot reports whether path stays inside root once the symbolic links
+// among its existing parent directories are followed.
+func inRoot(root, path string) bool {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(realRoot, realDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// chown gives path the owner recorded in hdr, by name unless NumericOwner
+// is set or the name is unknown. Only root can do that, so it does nothing
+// for other users.
+func chown(path string, hdr *tar.Header, opts *Opts) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	uid, gid := hdr.Uid, hdr.Gid
+	if !opts.NumericOwner {
+		if u, err := user.Lookup(hdr.Uname); hdr.Uname != "" && err == nil {
+			if id, err := strconv.Atoi(u.Uid); err == nil {
+				uid = id
+			}
+		}
+		if g, err := user.LookupGroup(hdr.Gname); hdr.Gname != "" && err == nil {
+			if id, err := strconv.Atoi(g.Gid); err == nil {
+				gid = id
+			}
+		}
+	}
+	if err := os.Lchown(path, uid, gid); err != nil {
+		return fmt.Errorf("error setting owner %d:%d on %q: %w", uid, gid, path, err)
 	}
-	// TODO: also set ownership, etc...
 	return nil
 }
 
@@ -5,11 +5,16 @@
 package tarutil
 
 import (
+	"archive/tar"
+	"bytes"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/compress"
 )
 
 func extractAndCompare(t *testing.T, tarFile string, files []struct{ name, body string }) {
@@ -177,3 +182,114 @@ func TestListArchive(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// tree creates a directory with a regular file, a symbolic link and a
+// directory that tests exclude.
+func tree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"src/a.txt":     "hello\n",
+		"src/a.o":       "object\n",
+		"src/tmp/b.txt": "scratch\n",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "src/link")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRoundTripCompressedExclude(t *testing.T) {
+	dir := tree(t)
+	for _, format := range []string{compress.None, compress.Gzip, compress.XZ, compress.Zstd} {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			w, err := compress.NewWriter(&b, format, compress.DefaultLevel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := &Opts{ChangeDirectory: dir, Exclude: []string{"*.o", "tmp"}, NumericOwner: true}
+			if err := CreateTar(w, []string{"src"}, opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := t.TempDir()
+			if err := ExtractDir(&b, out, &Opts{}); err != nil {
+				t.Fatalf("ExtractDir() = %v", err)
+			}
+			var got []string
+			err = filepath.Walk(out, func(p string, _ os.FileInfo, err error) error {
+				rel, _ := filepath.Rel(out, p)
+				got = append(got, rel)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{".", "src", "src/a.txt", "src/link"}
+			if !slices.Equal(got, want) {
+				t.Errorf("extracted %q, want %q", got, want)
+			}
+			if body, err := os.ReadFile(filepath.Join(out, "src/link")); err != nil || string(body) != "hello\n" {
+				t.Errorf("reading through link = %q, %v, want %q", body, err, "hello\n")
+			}
+		})
+	}
+}
+
+func TestNumericOwner(t *testing.T) {
+	var b bytes.Buffer
+	if err := CreateTar(&b, []string{"testdata/test2.txt"}, &Opts{NumericOwner: true}); err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := tar.NewReader(&b).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Uname != "" || hdr.Gname != "" {
+		t.Errorf("header has owner %q:%q, want no names", hdr.Uname, hdr.Gname)
+	}
+}
+
+func TestExtractSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, hdr := range []*tar.Header{
+		{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside},
+		{Name: "evil/pwned", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+		{Name: "hard", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("x"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	if err := ExtractDir(&b, out, nil); err != nil {
+		t.Fatalf("ExtractDir() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+		t.Errorf("file written through a symbolic link out of the root")
+	}
+	if _, err := os.Lstat(filepath.Join(out, "hard")); !os.IsNotExist(err) {
+		t.Errorf("hard link to a file out of the root was created")
+	}
+}
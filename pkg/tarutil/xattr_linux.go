@@ -0,0 +1,66 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tarutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// xattrPrefix marks extended attributes among the PAX records, as GNU tar
+// and star write them.
+const xattrPrefix = "SCHILY.xattr."
+
+// readXattrs records the extended attributes of path in hdr.
+func readXattrs(path string, hdr *tar.Header) error {
+	size, err := unix.Llistxattr(path, nil)
+	if errors.Is(err, unix.ENOTSUP) || size == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("listing extended attributes of %q: %w", path, err)
+	}
+	list := make([]byte, size)
+	if size, err = unix.Llistxattr(path, list); err != nil {
+		return fmt.Errorf("listing extended attributes of %q: %w", path, err)
+	}
+	for _, name := range bytes.Split(list[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			return fmt.Errorf("reading extended attribute %s of %q: %w", name, path, err)
+		}
+		val := make([]byte, n)
+		if n, err = unix.Lgetxattr(path, string(name), val); err != nil {
+			return fmt.Errorf("reading extended attribute %s of %q: %w", name, path, err)
+		}
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = make(map[string]string)
+		}
+		hdr.PAXRecords[xattrPrefix+string(name)] = string(val[:n])
+	}
+	return nil
+}
+
+// writeXattrs sets the extended attributes recorded in hdr on path.
+func writeXattrs(path string, hdr *tar.Header) error {
+	for key, val := range hdr.PAXRecords {
+		name, ok := strings.CutPrefix(key, xattrPrefix)
+		if !ok {
+			continue
+		}
+		if err := unix.Lsetxattr(path, name, []byte(val), 0); err != nil {
+			return fmt.Errorf("setting extended attribute %s on %q: %w", name, path, err)
+		}
+	}
+	return nil
+}
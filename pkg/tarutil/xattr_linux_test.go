@@ -0,0 +1,47 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tarutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestXattrs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(filepath.Join(dir, "f"), "user.test", []byte("value"), 0); err != nil {
+		t.Skipf("file system does not support user xattrs: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := CreateTar(&b, []string{"f"}, &Opts{ChangeDirectory: dir, Xattrs: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	if err := ExtractDir(bytes.NewReader(b.Bytes()), out, &Opts{Xattrs: true}); err != nil {
+		t.Fatal(err)
+	}
+	val := make([]byte, 16)
+	n, err := unix.Getxattr(filepath.Join(out, "f"), "user.test", val)
+	if err != nil || string(val[:n]) != "value" {
+		t.Errorf("user.test = %q, %v, want %q", val[:n], err, "value")
+	}
+
+	// Without Xattrs, the attributes are neither stored nor restored.
+	out = t.TempDir()
+	if err := ExtractDir(bytes.NewReader(b.Bytes()), out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unix.Getxattr(filepath.Join(out, "f"), "user.test", val); err == nil {
+		t.Errorf("user.test restored without Xattrs")
+	}
+}
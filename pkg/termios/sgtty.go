@@ -41,8 +41,7 @@ func GTTY(fd int) (*TTY, error) {
 	// back in the day, you could have different i and o speeds.
 	// since about 1975, this has not been a thing. It's still in POSIX
 	// evidently. WTF?
-	t.Ispeed = int(term.Ispeed)
-	t.Ospeed = int(term.Ospeed)
+	t.Ispeed, t.Ospeed = getSpeed(term)
 	t.Row = int(w.Row)
 	t.Col = int(w.Col)
 
@@ -74,8 +73,9 @@ func (t *TTY) STTY(fd int) (*TTY, error) {
 		term.Cc[c] = t.CC[n]
 	}
 
-	term.Ispeed = speed(t.Ispeed)
-	term.Ospeed = speed(t.Ospeed)
+	if err := setSpeed(term, t.Ispeed, t.Ospeed); err != nil {
+		return nil, err
+	}
 
 	if err := unix.IoctlSetTermios(fd, sets, term); err != nil {
 		return nil, err
@@ -151,9 +151,24 @@ func (t *TTY) SetOpts(opts []string) error {
 			continue
 		case "speed":
 			// 32 may sound crazy but ... baud can be REALLY large
+			t.Ispeed, err = intarg(opts[i:], 32)
+			t.Ospeed = t.Ispeed
+			i++
+			continue
+		case "ispeed":
 			t.Ispeed, err = intarg(opts[i:], 32)
 			i++
 			continue
+		case "ospeed":
+			t.Ospeed, err = intarg(opts[i:], 32)
+			i++
+			continue
+		}
+
+		// As in standard stty, a bare number is a speed.
+		if n, perr := strconv.ParseUint(o, 10, 32); perr == nil {
+			t.Ispeed, t.Ospeed = int(n), int(n)
+			continue
 		}
 
 		// see if it's one of the control char options.
@@ -194,3 +209,39 @@ func Raw(fd int) (*TTY, error) {
 
 	return t.STTY(fd)
 }
+
+// Cooked sets a TTY into cooked mode, undoing Raw, and returns the TTY
+// struct. Input is read by lines which may be edited, signals are
+// generated, and output newlines become carriage return newlines.
+func Cooked(fd int) (*TTY, error) {
+	t, err := GTTY(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.SetOpts([]string{"brkint", "~ignbrk", "~inlcr", "~igncr", "icrnl", "ixon", "opost", "onlcr", "echo", "echoe", "echok", "icanon", "isig", "iexten", "eof", "4", "eol", "0"}); err != nil {
+		return nil, err
+	}
+
+	return t.STTY(fd)
+}
+
+// SetSpeed sets the input and output speed of the tty on fd to baud.
+func SetSpeed(fd int, baud int) (*TTY, error) {
+	t, err := GTTY(fd)
+	if err != nil {
+		return nil, err
+	}
+	t.Ispeed, t.Ospeed = baud, baud
+	return t.STTY(fd)
+}
+
+// SetSize sets the window size of the tty on fd.
+func SetSize(fd int, rows, cols int) (*TTY, error) {
+	t, err := GTTY(fd)
+	if err != nil {
+		return nil, err
+	}
+	t.Row, t.Col = rows, cols
+	return t.STTY(fd)
+}
@@ -0,0 +1,27 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || openbsd || netbsd
+// +build darwin freebsd openbsd netbsd
+
+package termios
+
+import "golang.org/x/sys/unix"
+
+// getSpeed returns the input and output speeds of a termios, which the
+// BSDs keep as plain numbers.
+func getSpeed(term *unix.Termios) (int, int) {
+	return int(term.Ispeed), int(term.Ospeed)
+}
+
+// setSpeed sets the speeds of a termios. A speed of 0 leaves it alone.
+func setSpeed(term *unix.Termios, ispeed, ospeed int) error {
+	if ispeed != 0 {
+		term.Ispeed = speed(ispeed)
+	}
+	if ospeed != 0 {
+		term.Ospeed = speed(ospeed)
+	}
+	return nil
+}
@@ -7,7 +7,11 @@
 
 package termios
 
-import "golang.org/x/sys/unix"
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
 
 const (
 	gets       = unix.TCGETS
@@ -16,6 +20,35 @@ const (
 	setWinSize = unix.TIOCSWINSZ
 )
 
-func speed(speed int) uint32 {
-	return uint32(speed)
+// getSpeed returns the input and output speeds of a termios. Linux keeps
+// them in the CBAUD bits of the control flags, and input follows output.
+func getSpeed(term *unix.Termios) (int, int) {
+	rate := term.Cflag & unix.CBAUD
+	for baud, b := range baud2unixB {
+		if b == rate {
+			return baud, baud
+		}
+	}
+	return 0, 0
+}
+
+// setSpeed sets the speed of a termios. A speed of 0 leaves it alone, and
+// as Linux has one speed for both directions, the output speed wins.
+func setSpeed(term *unix.Termios, ispeed, ospeed int) error {
+	baud := ospeed
+	if baud == 0 {
+		baud = ispeed
+	}
+	if baud == 0 {
+		return nil
+	}
+	rate, ok := baud2unixB[baud]
+	if !ok {
+		return fmt.Errorf("%d: Unrecognized baud rate", baud)
+	}
+	term.Cflag &^= unix.CBAUD
+	term.Cflag |= rate
+	term.Ispeed = rate
+	term.Ospeed = rate
+	return nil
 }
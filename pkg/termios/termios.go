@@ -44,6 +44,19 @@ func (t *TTYIO) Raw() (*Termios, error) {
 	return restorer, nil
 }
 
+// Cooked sets the tty into cooked mode, as after Raw.
+func (t *TTYIO) Cooked() (*Termios, error) {
+	restorer, err := t.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Set(MakeCooked(restorer)); err != nil {
+		return nil, err
+	}
+	return restorer, nil
+}
+
 // Serial configure the serial TTY at given baudrate with ECHO and character conversion (CRNL, ERASE, KILL)
 func (t *TTYIO) Serial(baud int) (*Termios, error) {
 	restorer, err := t.Get()
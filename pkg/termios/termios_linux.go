@@ -122,6 +122,22 @@ func MakeRaw(term *Termios) *Termios {
 	return &raw
 }
 
+// MakeCooked modifies Termio state so, if it used for an fd or tty, it will set it to cooked
+// mode, as a line editor leaving raw mode expects: input is read by lines with ERASE and KILL,
+// it is echoed, signals are generated, and newlines are mapped to carriage return newlines on output.
+func MakeCooked(term *Termios) *Termios {
+	cooked := *term
+	cooked.Iflag &^= unix.IGNBRK | unix.INLCR | unix.IGNCR
+	cooked.Iflag |= unix.BRKINT | unix.ICRNL | unix.IXON
+	cooked.Oflag |= unix.OPOST | unix.ONLCR
+	cooked.Lflag |= unix.ECHO | unix.ECHOE | unix.ECHOK | unix.ICANON | unix.ISIG | unix.IEXTEN
+
+	cooked.Cc[unix.VEOF] = 4
+	cooked.Cc[unix.VEOL] = 0
+
+	return &cooked
+}
+
 // MakeSerialBaud updates the Termios to set the baudrate
 func MakeSerialBaud(term *Termios, baud int) (*Termios, error) {
 	t := *term
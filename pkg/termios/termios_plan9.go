@@ -98,6 +98,12 @@ func MakeRaw(term *Termios) *Termios {
 	return &raw
 }
 
+// MakeCooked modifies Termio state so, if it used for an fd or tty, it will set it to cooked mode.
+func MakeCooked(term *Termios) *Termios {
+	cooked := *term
+	return &cooked
+}
+
 // MakeSerialBaud updates the Termios to set the baudrate
 func MakeSerialBaud(term *Termios, baud int) (*Termios, error) {
 	t := *term
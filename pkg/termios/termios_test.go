@@ -27,6 +27,7 @@ import (
 
 	"github.com/hugelgupf/vmtest/guest"
 	"github.com/u-root/u-root/pkg/testutil"
+	"golang.org/x/sys/unix"
 )
 
 var (
@@ -243,6 +244,9 @@ func TestSet(t *testing.T) {
 		{"time", "0x03"},
 		{"~tostop"},
 		{"werase", "0x17"},
+		{"ispeed", "9600"},
+		{"ospeed", "9600"},
+		{"115200"},
 	}
 
 	if runtime.GOOS == "linux" {
@@ -256,8 +260,13 @@ func TestSet(t *testing.T) {
 			t.Errorf("Setting %q: got %v, want nil", set, err)
 		}
 	}
+	if g.Ispeed != 115200 || g.Ospeed != 115200 {
+		t.Errorf("speeds: got %d/%d, want 115200/115200", g.Ispeed, g.Ospeed)
+	}
 	bad := [][]string{
 		{"hi", "1"},
+		{"speed"},
+		{"ispeed", "fast"},
 		{"rows"},
 		{"rows", "z"},
 		{"erase"},
@@ -272,6 +281,47 @@ func TestSet(t *testing.T) {
 	}
 }
 
+func TestCooked(t *testing.T) {
+	term := &Termios{}
+	term.Lflag = unix.ECHO | unix.ICANON | unix.ISIG
+	term.Oflag = unix.OPOST
+	raw := MakeRaw(term)
+	cooked := MakeCooked(raw)
+	if cooked.Lflag&(unix.ECHO|unix.ICANON|unix.ISIG|unix.IEXTEN) != unix.ECHO|unix.ICANON|unix.ISIG|unix.IEXTEN {
+		t.Errorf("MakeCooked: got lflag %#x, want echo, icanon, isig and iexten set", cooked.Lflag)
+	}
+	if cooked.Oflag&(unix.OPOST|unix.ONLCR) != unix.OPOST|unix.ONLCR {
+		t.Errorf("MakeCooked: got oflag %#x, want opost and onlcr set", cooked.Oflag)
+	}
+	if cooked.Iflag&unix.ICRNL == 0 {
+		t.Errorf("MakeCooked: got iflag %#x, want icrnl set", cooked.Iflag)
+	}
+	if raw.Lflag&unix.ICANON != 0 {
+		t.Errorf("MakeCooked changed its argument")
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	term := &unix.Termios{}
+	if err := setSpeed(term, 0, 115200); err != nil {
+		t.Fatalf("setSpeed(115200): got %v, want nil", err)
+	}
+	if _, o := getSpeed(term); o != 115200 {
+		t.Errorf("getSpeed: got %d, want 115200", o)
+	}
+	if err := setSpeed(term, 0, 0); err != nil {
+		t.Fatalf("setSpeed(0): got %v, want nil", err)
+	}
+	if _, o := getSpeed(term); o != 115200 {
+		t.Errorf("getSpeed after setting 0: got %d, want 115200", o)
+	}
+	if runtime.GOOS == "linux" {
+		if err := setSpeed(term, 0, 12345); err == nil {
+			t.Errorf("setSpeed(12345): got nil, want err")
+		}
+	}
+}
+
 // This test tries to prevent people from breaking other operating systems.
 //
 // Compare:
@@ -0,0 +1,211 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tftp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/metrics"
+	"pack.ag/tftp"
+)
+
+// Limits bounds what a TFTP server hands out, so that one client cannot
+// starve the others. A zero value means no limit.
+type Limits struct {
+	// MaxSessions is the number of transfers served at once.
+	MaxSessions int
+	// MaxClientSessions is the number of transfers served at once to
+	// one client address.
+	MaxClientSessions int
+	// Rate is the number of bytes per second sent to all clients.
+	Rate int64
+	// ClientRate is the number of bytes per second sent to one client
+	// address.
+	ClientRate int64
+}
+
+// Stats counts what a Limiter has served.
+type Stats struct {
+	// Active is the number of transfers being served.
+	Active int
+	// Sessions is the number of transfers started.
+	Sessions uint64
+	// Rejected is the number of transfers refused for going over a
+	// session limit.
+	Rejected uint64
+	// Bytes is the number of bytes sent.
+	Bytes uint64
+}
+
+// String implements fmt.Stringer.
+func (s Stats) String() string {
+	return fmt.Sprintf("%d active sessions, %d sessions, %d rejected, %d bytes sent", s.Active, s.Sessions, s.Rejected, s.Bytes)
+}
+
+// bucket is a token bucket of bytes. Writes may overdraw it, and the
+// writer then sleeps until it is paid back, so writes of any size work.
+type bucket struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate int64, now time.Time) *bucket {
+	return &bucket{rate: rate, tokens: float64(rate), last: now}
+}
+
+// take takes n tokens at now, and returns how long to wait before sending
+// them.
+func (b *bucket) take(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	b.tokens = min(b.tokens, float64(b.rate))
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+// client is the state kept for one client address while it has transfers.
+type client struct {
+	sessions int
+	bucket   *bucket
+}
+
+// Limiter is a tftp.ReadHandler that applies Limits to another ReadHandler,
+// and counts Stats.
+type Limiter struct {
+	handler tftp.ReadHandler
+	limits  Limits
+
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu      sync.Mutex
+	bucket  *bucket
+	clients map[string]*client
+	stats   Stats
+}
+
+// NewLimiter returns a Limiter serving read requests with h within l.
+func NewLimiter(h tftp.ReadHandler, l Limits) *Limiter {
+	lim := &Limiter{
+		handler: h,
+		limits:  l,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		clients: map[string]*client{},
+	}
+	if l.Rate > 0 {
+		lim.bucket = newBucket(l.Rate, lim.now())
+	}
+	return lim
+}
+
+// start admits a transfer to addr, or returns nil if that goes over a
+// session limit.
+func (l *Limiter) start(addr string) *client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.clients[addr]
+	if (l.limits.MaxSessions > 0 && l.stats.Active >= l.limits.MaxSessions) ||
+		(c != nil && l.limits.MaxClientSessions > 0 && c.sessions >= l.limits.MaxClientSessions) {
+		l.stats.Rejected++
+		return nil
+	}
+	if c == nil {
+		c = &client{}
+		if l.limits.ClientRate > 0 {
+			c.bucket = newBucket(l.limits.ClientRate, l.now())
+		}
+		l.clients[addr] = c
+	}
+	c.sessions++
+	l.stats.Active++
+	l.stats.Sessions++
+	return c
+}
+
+func (l *Limiter) done(addr string, c *client) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Active--
+	if c.sessions--; c.sessions == 0 {
+		delete(l.clients, addr)
+	}
+}
+
+// ServeTFTP implements tftp.ReadHandler.
+func (l *Limiter) ServeTFTP(r tftp.ReadRequest) {
+	addr := r.Addr().IP.String()
+	c := l.start(addr)
+	if c == nil {
+		r.WriteError(tftp.ErrCodeNotDefined, "too many sessions, try again later")
+		return
+	}
+	defer l.done(addr, c)
+	l.handler.ServeTFTP(&limitedRequest{ReadRequest: r, l: l, c: c})
+}
+
+// Stats returns what l has served so far.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// Register registers the Stats of l as metrics in r.
+func (l *Limiter) Register(r *metrics.Registry) {
+	r.NewGaugeFunc("tftp_sessions_active", "Number of TFTP transfers being served.", func() float64 {
+		return float64(l.Stats().Active)
+	})
+	r.NewCounterFunc("tftp_sessions_total", "Number of TFTP transfers started.", func() float64 {
+		return float64(l.Stats().Sessions)
+	})
+	r.NewCounterFunc("tftp_sessions_rejected_total", "Number of TFTP transfers refused for going over a session limit.", func() float64 {
+		return float64(l.Stats().Rejected)
+	})
+	r.NewCounterFunc("tftp_sent_bytes_total", "Number of bytes sent over TFTP.", func() float64 {
+		return float64(l.Stats().Bytes)
+	})
+}
+
+// limitedRequest is a tftp.ReadRequest whose writes are held to the
+// Limiter's rates.
+type limitedRequest struct {
+	tftp.ReadRequest
+	l *Limiter
+	c *client
+}
+
+func (r *limitedRequest) Write(p []byte) (int, error) {
+	var wait time.Duration
+	now := r.l.now()
+	if r.c.bucket != nil {
+		wait = r.c.bucket.take(len(p), now)
+	}
+	if r.l.bucket != nil {
+		wait = max(wait, r.l.bucket.take(len(p), now))
+	}
+	if wait > 0 {
+		r.l.sleep(wait)
+	}
+
+	n, err := r.ReadRequest.Write(p)
+	r.l.mu.Lock()
+	r.l.stats.Bytes += uint64(n)
+	r.l.mu.Unlock()
+	return n, err
+}
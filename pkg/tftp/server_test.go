@@ -0,0 +1,206 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tftp
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/metrics"
+	"pack.ag/tftp"
+)
+
+type fakeRequest struct {
+	addr *net.UDPAddr
+	sent int
+	err  string
+}
+
+func (r *fakeRequest) Addr() *net.UDPAddr                    { return r.addr }
+func (r *fakeRequest) Name() string                          { return "pxelinux.0" }
+func (r *fakeRequest) WriteSize(int64)                       {}
+func (r *fakeRequest) TransferMode() tftp.TransferMode       { return tftp.ModeOctet }
+func (r *fakeRequest) WriteError(_ tftp.ErrorCode, s string) { r.err = s }
+
+func (r *fakeRequest) Write(p []byte) (int, error) {
+	r.sent += len(p)
+	return len(p), nil
+}
+
+func request(ip string) *fakeRequest {
+	return &fakeRequest{addr: &net.UDPAddr{IP: net.ParseIP(ip), Port: 1024}}
+}
+
+func TestLimiterSessions(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		limits   Limits
+		ips      []string
+		rejected []bool
+	}{
+		{
+			name:     "no limits",
+			ips:      []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			rejected: []bool{false, false, false},
+		},
+		{
+			name:     "max sessions",
+			limits:   Limits{MaxSessions: 2},
+			ips:      []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			rejected: []bool{false, false, true},
+		},
+		{
+			name:     "max client sessions",
+			limits:   Limits{MaxClientSessions: 1},
+			ips:      []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			rejected: []bool{false, true, false},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			var started sync.WaitGroup
+			l := NewLimiter(tftp.ReadHandlerFunc(func(r tftp.ReadRequest) {
+				started.Done()
+				<-release
+			}), tt.limits)
+
+			var wg sync.WaitGroup
+			reqs := make([]*fakeRequest, len(tt.ips))
+			for i, ip := range tt.ips {
+				reqs[i] = request(ip)
+				if tt.rejected[i] {
+					l.ServeTFTP(reqs[i])
+					continue
+				}
+				started.Add(1)
+				wg.Add(1)
+				go func(r *fakeRequest) {
+					defer wg.Done()
+					l.ServeTFTP(r)
+				}(reqs[i])
+				started.Wait()
+			}
+			close(release)
+			wg.Wait()
+
+			var rejected uint64
+			for i, r := range reqs {
+				if got := r.err != ""; got != tt.rejected[i] {
+					t.Errorf("request %d from %s rejected = %v, want %v", i, tt.ips[i], got, tt.rejected[i])
+				}
+				if r.err != "" {
+					rejected++
+				}
+			}
+			s := l.Stats()
+			want := Stats{Sessions: uint64(len(tt.ips)) - rejected, Rejected: rejected}
+			if s != want {
+				t.Errorf("Stats() = %+v, want %+v", s, want)
+			}
+		})
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		limits Limits
+		writes []int
+		want   time.Duration
+	}{
+		{
+			name:   "no limits",
+			writes: []int{1000, 1000},
+		},
+		{
+			name:   "within burst",
+			limits: Limits{Rate: 1000},
+			writes: []int{500, 500},
+		},
+		{
+			name:   "global",
+			limits: Limits{Rate: 1000},
+			writes: []int{1000, 1000, 500},
+			want:   1500 * time.Millisecond,
+		},
+		{
+			name:   "client",
+			limits: Limits{ClientRate: 100},
+			writes: []int{100, 50},
+			want:   500 * time.Millisecond,
+		},
+		{
+			name:   "slowest wins",
+			limits: Limits{Rate: 1000, ClientRate: 100},
+			writes: []int{300},
+			want:   2 * time.Second,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			var slept time.Duration
+			l := NewLimiter(tftp.ReadHandlerFunc(func(r tftp.ReadRequest) {
+				for _, n := range tt.writes {
+					if _, err := r.Write(make([]byte, n)); err != nil {
+						t.Errorf("Write(%d bytes) = %v", n, err)
+					}
+				}
+			}), tt.limits)
+			l.now = func() time.Time { return now }
+			l.sleep = func(d time.Duration) {
+				slept += d
+				now = now.Add(d)
+			}
+			l.bucket = nil
+			if tt.limits.Rate > 0 {
+				l.bucket = newBucket(tt.limits.Rate, now)
+			}
+
+			r := request("10.0.0.1")
+			l.ServeTFTP(r)
+
+			var total int
+			for _, n := range tt.writes {
+				total += n
+			}
+			if r.sent != total {
+				t.Errorf("sent %d bytes, want %d", r.sent, total)
+			}
+			if got := l.Stats().Bytes; got != uint64(total) {
+				t.Errorf("Stats().Bytes = %d, want %d", got, total)
+			}
+			if slept != tt.want {
+				t.Errorf("slept %v, want %v", slept, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	l := NewLimiter(tftp.ReadHandlerFunc(func(r tftp.ReadRequest) {
+		r.Write(make([]byte, 512))
+	}), Limits{})
+	l.ServeTFTP(request("10.0.0.1"))
+
+	r := metrics.NewRegistry()
+	l.Register(r)
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE tftp_sessions_active gauge\ntftp_sessions_active 0\n",
+		"# TYPE tftp_sessions_total counter\ntftp_sessions_total 1\n",
+		"tftp_sessions_rejected_total 0\n",
+		"tftp_sent_bytes_total 512\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics = %q, want it to contain %q", b.String(), want)
+		}
+	}
+}
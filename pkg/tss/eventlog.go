@@ -0,0 +1,130 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+)
+
+// Event types of the TCG PC Client Platform Firmware Profile.
+const (
+	EventNoAction = 0x3
+	EventIPL      = 0xd
+)
+
+// EventLog is a measurement log in the crypto agile format of the TCG PC
+// Client Platform Firmware Profile, as firmware hands it to the OS and as
+// attestation verifiers replay it.
+type EventLog struct {
+	algs []crypto.Hash
+	buf  bytes.Buffer
+}
+
+func algID(h crypto.Hash) (tpm2.Algorithm, error) {
+	switch h {
+	case crypto.SHA1:
+		return tpm2.AlgSHA1, nil
+	case crypto.SHA256:
+		return tpm2.AlgSHA256, nil
+	case crypto.SHA384:
+		return tpm2.AlgSHA384, nil
+	case crypto.SHA512:
+		return tpm2.AlgSHA512, nil
+	}
+	return 0, fmt.Errorf("hash %v has no TPM algorithm ID", h)
+}
+
+// NewEventLog starts a log whose events carry digests of each of algs. It
+// begins with the Spec ID event describing them.
+func NewEventLog(algs ...crypto.Hash) (*EventLog, error) {
+	if len(algs) == 0 {
+		return nil, fmt.Errorf("event log needs at least one hash algorithm")
+	}
+	l := &EventLog{algs: algs}
+
+	// TCG_EfiSpecIDEvent.
+	var spec bytes.Buffer
+	spec.WriteString("Spec ID Event03\x00")
+	le := binary.LittleEndian
+	// platformClass, specVersionMinor 0, major 2, errata 0, uintnSize 2
+	// (64 bit), numberOfAlgorithms.
+	binary.Write(&spec, le, uint32(0))
+	spec.Write([]byte{0, 2, 0, 2})
+	binary.Write(&spec, le, uint32(len(algs)))
+	for _, h := range algs {
+		id, err := algID(h)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&spec, le, uint16(id))
+		binary.Write(&spec, le, uint16(h.Size()))
+	}
+	// vendorInfoSize.
+	spec.WriteByte(0)
+
+	// The first event is a TCG_PCR_EVENT with a SHA1 sized digest so
+	// that old parsers can skip it.
+	binary.Write(&l.buf, le, uint32(0))
+	binary.Write(&l.buf, le, uint32(EventNoAction))
+	l.buf.Write(make([]byte, 20))
+	binary.Write(&l.buf, le, uint32(spec.Len()))
+	l.buf.Write(spec.Bytes())
+	return l, nil
+}
+
+// Algorithms returns the hash algorithms of the log.
+func (l *EventLog) Algorithms() []crypto.Hash {
+	return l.algs
+}
+
+// Add appends a TCG_PCR_EVENT2 with the given digests, one per algorithm
+// of the log in the same order.
+func (l *EventLog) Add(pcr, eventType uint32, digests [][]byte, data []byte) error {
+	if len(digests) != len(l.algs) {
+		return fmt.Errorf("event has %d digests, log has %d algorithms", len(digests), len(l.algs))
+	}
+	var e bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&e, le, pcr)
+	binary.Write(&e, le, eventType)
+	binary.Write(&e, le, uint32(len(digests)))
+	for i, h := range l.algs {
+		if len(digests[i]) != h.Size() {
+			return fmt.Errorf("%v digest has %d bytes, want %d", h, len(digests[i]), h.Size())
+		}
+		id, _ := algID(h)
+		binary.Write(&e, le, uint16(id))
+		e.Write(digests[i])
+	}
+	binary.Write(&e, le, uint32(len(data)))
+	e.Write(data)
+	l.buf.Write(e.Bytes())
+	return nil
+}
+
+// Bytes returns the binary log.
+func (l *EventLog) Bytes() []byte {
+	return l.buf.Bytes()
+}
+
+// WriteTo writes the binary log to w.
+func (l *EventLog) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(l.buf.Bytes())
+	return int64(n), err
+}
+
+// Bank returns the hash algorithm of the PCR bank Extend and Measure use.
+func (t *TPM) Bank() crypto.Hash {
+	if t.Version == TPMVersion12 {
+		return crypto.SHA1
+	}
+	return crypto.SHA256
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	if _, err := NewEventLog(); err == nil {
+		t.Errorf("NewEventLog() succeeded, want error")
+	}
+	if _, err := NewEventLog(crypto.MD5); err == nil {
+		t.Errorf("NewEventLog(MD5) succeeded, want error")
+	}
+
+	l, err := NewEventLog(crypto.SHA1, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(l.Bytes())
+	// TCG_PCR_EVENT header, Spec ID event with two algorithms.
+	if want := 32 + 16 + 12 + 2*4 + 1; header != want {
+		t.Errorf("header has %d bytes, want %d", header, want)
+	}
+	if !bytes.Contains(l.Bytes(), []byte("Spec ID Event03\x00")) {
+		t.Errorf("header has no Spec ID event signature")
+	}
+
+	s1 := sha1.Sum([]byte("kernel"))
+	s256 := sha256.Sum256([]byte("kernel"))
+	if err := l.Add(9, EventIPL, [][]byte{s1[:], s256[:]}, []byte("kernel")); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	var want bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&want, le, []uint32{9, EventIPL, 2})
+	binary.Write(&want, le, uint16(0x4))
+	want.Write(s1[:])
+	binary.Write(&want, le, uint16(0xb))
+	want.Write(s256[:])
+	binary.Write(&want, le, uint32(6))
+	want.WriteString("kernel")
+	if got := l.Bytes()[header:]; !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("event = %x, want %x", got, want.Bytes())
+	}
+
+	if err := l.Add(9, EventIPL, [][]byte{s1[:]}, nil); err == nil {
+		t.Errorf("Add(one digest) succeeded, want error")
+	}
+	if err := l.Add(9, EventIPL, [][]byte{s256[:], s1[:]}, nil); err == nil {
+		t.Errorf("Add(swapped digests) succeeded, want error")
+	}
+
+	var buf bytes.Buffer
+	if n, err := l.WriteTo(&buf); err != nil || n != int64(len(l.Bytes())) {
+		t.Errorf("WriteTo() = %d, %v; want %d, nil", n, err, len(l.Bytes()))
+	}
+}
@@ -46,3 +46,26 @@ func nvRead12(rwc io.ReadWriteCloser, index, offset, len uint32, auth string) ([
 func nvRead20(rwc io.ReadWriteCloser, index, authHandle tpmutil.Handle, password string, blocksize int) ([]byte, error) {
 	return tpm2.NVReadEx(rwc, index, authHandle, password, blocksize)
 }
+
+// nvWriteBlock is the size of NV writes, which fits the smallest
+// TPM_PT_NV_BUFFER_MAX seen in practice.
+const nvWriteBlock = 512
+
+func nvDefine20(rwc io.ReadWriter, index tpmutil.Handle, size uint16, ownerPassword, authPassword string) error {
+	attrs := tpm2.AttrOwnerRead | tpm2.AttrOwnerWrite | tpm2.AttrAuthRead | tpm2.AttrAuthWrite | tpm2.AttrNoDA
+	return tpm2.NVDefineSpace(rwc, tpm2.HandleOwner, index, ownerPassword, authPassword, nil, attrs, size)
+}
+
+func nvWrite20(rwc io.ReadWriter, index tpmutil.Handle, password string, data []byte) error {
+	for off := 0; off < len(data); off += nvWriteBlock {
+		end := min(off+nvWriteBlock, len(data))
+		if err := tpm2.NVWrite(rwc, index, index, password, data[off:end], uint16(off)); err != nil {
+			return fmt.Errorf("writing NV index %#x at %d: %w", index, off, err)
+		}
+	}
+	return nil
+}
+
+func nvUndefine20(rwc io.ReadWriter, index tpmutil.Handle, ownerPassword string) error {
+	return tpm2.NVUndefineSpace(rwc, ownerPassword, tpm2.HandleOwner, index)
+}
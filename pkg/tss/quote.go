@@ -0,0 +1,177 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// ErrQuote is returned by VerifyQuote for quotes which do not verify.
+var ErrQuote = errors.New("invalid quote")
+
+// akTemplate is the template of the attestation key. The TPM derives the
+// same key from it for as long as the owner seed does not change, so it
+// need not be persisted.
+var akTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagSignerDefault | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Sign:       &tpm2.SigScheme{Alg: tpm2.AlgRSASSA, Hash: tpm2.AlgSHA256},
+		KeyBits:    2048,
+		ModulusRaw: make([]byte, 256),
+	},
+}
+
+// Quote is a quote of SHA-256 PCRs, signed by the attestation key.
+type Quote struct {
+	// PCRs are the quoted PCRs in ascending order, and Values their
+	// values as read after quoting.
+	PCRs   []int    `json:"pcrs"`
+	Values [][]byte `json:"values"`
+	// Attest is the TPMS_ATTEST structure the TPM signed, and
+	// Signature the TPMT_SIGNATURE.
+	Attest    []byte `json:"attest"`
+	Signature []byte `json:"signature"`
+}
+
+// sortPCRs returns a sorted copy of pcrs without duplicates.
+func sortPCRs(pcrs []int) []int {
+	pcrs = slices.Clone(pcrs)
+	slices.Sort(pcrs)
+	return slices.Compact(pcrs)
+}
+
+func createAK(rwc io.ReadWriter, ownerPassword string) (tpmutil.Handle, []byte, error) {
+	ak, pub, _, _, _, _, err := tpm2.CreatePrimaryEx(rwc, tpm2.HandleOwner, tpm2.PCRSelection{}, ownerPassword, "", akTemplate)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating AK: %w", err)
+	}
+	return ak, pub, nil
+}
+
+func akPublic20(rwc io.ReadWriter, ownerPassword string) ([]byte, error) {
+	ak, pub, err := createAK(rwc, ownerPassword)
+	if err != nil {
+		return nil, err
+	}
+	return pub, tpm2.FlushContext(rwc, ak)
+}
+
+func quote20(rwc io.ReadWriter, ownerPassword string, nonce []byte, pcrs []int) (*Quote, error) {
+	ak, _, err := createAK(rwc, ownerPassword)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rwc, ak)
+	attest, sig, err := tpm2.QuoteRaw(rwc, ak, "", "", nonce, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}, tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("quoting: %w", err)
+	}
+	q := &Quote{PCRs: pcrs, Attest: attest, Signature: sig}
+	for _, p := range pcrs {
+		v, err := tpm2.ReadPCR(rwc, p, tpm2.AlgSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("reading PCR %d: %w", p, err)
+		}
+		q.Values = append(q.Values, v)
+	}
+	return q, nil
+}
+
+// AKPublic returns the TPMT_PUBLIC structure of the attestation key, which
+// is derived from the owner seed.
+func (t *TPM) AKPublic(ownerPassword string) ([]byte, error) {
+	switch t.Version {
+	case TPMVersion20:
+		return akPublic20(t.RWC, ownerPassword)
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// Quote quotes the SHA-256 values of pcrs with the attestation key. The
+// nonce, which the verifier should choose, is included in the signed data.
+func (t *TPM) Quote(ownerPassword string, nonce []byte, pcrs []int) (*Quote, error) {
+	if len(pcrs) == 0 {
+		return nil, ErrNoPCRs
+	}
+	switch t.Version {
+	case TPMVersion20:
+		return quote20(t.RWC, ownerPassword, nonce, sortPCRs(pcrs))
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// VerifyQuote checks that q was signed by the key with the TPMT_PUBLIC
+// structure akPublic, includes nonce, and covers q's PCR values.
+func VerifyQuote(akPublic []byte, q *Quote, nonce []byte) error {
+	pub, err := tpm2.DecodePublic(akPublic)
+	if err != nil {
+		return fmt.Errorf("decoding AK: %w", err)
+	}
+	key, err := pub.Key()
+	if err != nil {
+		return fmt.Errorf("decoding AK: %w", err)
+	}
+	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(q.Signature))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrQuote, err)
+	}
+	digest := sha256.Sum256(q.Attest)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if sig.RSA == nil || sig.RSA.HashAlg != tpm2.AlgSHA256 {
+			return fmt.Errorf("%w: signature is not RSA with SHA-256", ErrQuote)
+		}
+		if sig.Alg == tpm2.AlgRSAPSS {
+			err = rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig.RSA.Signature, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig.RSA.Signature)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrQuote, err)
+		}
+	case *ecdsa.PublicKey:
+		if sig.ECC == nil || sig.ECC.HashAlg != tpm2.AlgSHA256 || !ecdsa.Verify(k, digest[:], sig.ECC.R, sig.ECC.S) {
+			return fmt.Errorf("%w: bad ECDSA signature", ErrQuote)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported AK type %T", ErrQuote, key)
+	}
+
+	ad, err := tpm2.DecodeAttestationData(q.Attest)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrQuote, err)
+	}
+	if ad.Type != tpm2.TagAttestQuote {
+		return fmt.Errorf("%w: attestation type %#x", ErrQuote, ad.Type)
+	}
+	if !bytes.Equal(ad.ExtraData, nonce) {
+		return fmt.Errorf("%w: nonce mismatch", ErrQuote)
+	}
+	qi := ad.AttestedQuoteInfo
+	if qi.PCRSelection.Hash != tpm2.AlgSHA256 || !slices.Equal(qi.PCRSelection.PCRs, q.PCRs) {
+		return fmt.Errorf("%w: quoted PCRs %v, want SHA-256 PCRs %v", ErrQuote, qi.PCRSelection.PCRs, q.PCRs)
+	}
+	h := sha256.New()
+	for _, v := range q.Values {
+		h.Write(v)
+	}
+	if !bytes.Equal(h.Sum(nil), qi.PCRDigest) {
+		return fmt.Errorf("%w: PCR values do not match the quoted digest", ErrQuote)
+	}
+	return nil
+}
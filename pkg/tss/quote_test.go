@@ -0,0 +1,207 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+)
+
+func TestPCRPolicy(t *testing.T) {
+	sel, err := pcrSelection([]int{0, 7, 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(sel), "00000001000b03810001"; got != want {
+		t.Errorf("pcrSelection = %s, want %s", got, want)
+	}
+
+	zero := make([]byte, sha256.Size)
+	one := bytes.Repeat([]byte{1}, sha256.Size)
+	p1, err := PCRPolicy([]int{7}, [][]byte{zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := PCRPolicy([]int{7}, [][]byte{one})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p1) != sha256.Size || bytes.Equal(p1, p2) {
+		t.Errorf("policies for different PCR values: %x, %x", p1, p2)
+	}
+
+	for _, tt := range []struct {
+		pcrs   []int
+		values [][]byte
+	}{
+		{pcrs: []int{24}, values: [][]byte{zero}},
+		{pcrs: []int{-1}, values: [][]byte{zero}},
+		{pcrs: []int{0, 1}, values: [][]byte{zero}},
+	} {
+		if _, err := PCRPolicy(tt.pcrs, tt.values); err == nil {
+			t.Errorf("PCRPolicy(%v, %d values) = nil, want error", tt.pcrs, len(tt.values))
+		}
+	}
+}
+
+// softQuote returns the public area of a software AK and a quote of the
+// PCRs with values signed by it.
+func softQuote(t *testing.T, nonce []byte, pcrs []int, values [][]byte) ([]byte, *Quote) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := tpm2.Public{
+		Type:       tpm2.AlgECC,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: tpm2.FlagSignerDefault,
+		ECCParameters: &tpm2.ECCParams{
+			Sign:    &tpm2.SigScheme{Alg: tpm2.AlgECDSA, Hash: tpm2.AlgSHA256},
+			CurveID: tpm2.CurveNISTP256,
+			Point:   tpm2.ECPoint{XRaw: key.X.Bytes(), YRaw: key.Y.Bytes()},
+		},
+	}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := sha256.New()
+	for _, v := range values {
+		h.Write(v)
+	}
+	attest, err := tpm2.AttestationData{
+		Magic:           0xff544347,
+		Type:            tpm2.TagAttestQuote,
+		QualifiedSigner: tpm2.Name{Digest: &tpm2.HashValue{Alg: tpm2.AlgSHA256, Value: make([]byte, sha256.Size)}},
+		ExtraData:       nonce,
+		AttestedQuoteInfo: &tpm2.QuoteInfo{
+			PCRSelection: tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs},
+			PCRDigest:    h.Sum(nil),
+		},
+	}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(attest)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := tpm2.Signature{Alg: tpm2.AlgECDSA, ECC: &tpm2.SignatureECC{HashAlg: tpm2.AlgSHA256, R: r, S: s}}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, &Quote{PCRs: pcrs, Values: values, Attest: attest, Signature: sig}
+}
+
+func TestVerifyQuote(t *testing.T) {
+	nonce := []byte("nonce")
+	values := [][]byte{make([]byte, sha256.Size), bytes.Repeat([]byte{7}, sha256.Size)}
+	pub, q := softQuote(t, nonce, []int{0, 7}, values)
+	if err := VerifyQuote(pub, q, nonce); err != nil {
+		t.Fatalf("VerifyQuote = %v", err)
+	}
+
+	otherPub, _ := softQuote(t, nonce, []int{0, 7}, values)
+	tamper := func(f func(q *Quote)) *Quote {
+		c := *q
+		c.Values = append([][]byte(nil), q.Values...)
+		c.Attest = bytes.Clone(q.Attest)
+		f(&c)
+		return &c
+	}
+	for _, tt := range []struct {
+		name  string
+		pub   []byte
+		q     *Quote
+		nonce []byte
+	}{
+		{name: "nonce", pub: pub, q: q, nonce: []byte("other")},
+		{name: "key", pub: otherPub, q: q, nonce: nonce},
+		{name: "values", pub: pub, q: tamper(func(q *Quote) { q.Values[1] = make([]byte, sha256.Size) }), nonce: nonce},
+		{name: "pcrs", pub: pub, q: tamper(func(q *Quote) { q.PCRs = []int{0, 8} }), nonce: nonce},
+		{name: "attest", pub: pub, q: tamper(func(q *Quote) { q.Attest[len(q.Attest)-1] ^= 1 }), nonce: nonce},
+	} {
+		if err := VerifyQuote(tt.pub, tt.q, tt.nonce); !errors.Is(err, ErrQuote) {
+			t.Errorf("%s: VerifyQuote = %v, want %v", tt.name, err, ErrQuote)
+		}
+	}
+}
+
+func TestSealUnseal(t *testing.T) {
+	tpm := getSimulator(t)
+	secret := []byte("disk key")
+	if _, err := tpm.Seal("", secret, nil); !errors.Is(err, ErrNoPCRs) {
+		t.Errorf("Seal without PCRs = %v, want %v", err, ErrNoPCRs)
+	}
+	b, err := tpm.Seal("", secret, []int{16, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tpm.Unseal("", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Unseal = %q, want %q", got, secret)
+	}
+	if err := tpm.Measure([]byte("evil"), 16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpm.Unseal("", b); err == nil {
+		t.Errorf("Unseal after extending PCR 16 = nil, want error")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tpm := getSimulator(t)
+	pub, err := tpm.AKPublic("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("0123456789abcdef")
+	q, err := tpm.Quote("", nonce, []int{7, 0, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.PCRs) != 2 || len(q.Values) != 2 {
+		t.Errorf("Quote PCRs = %v with %d values, want [0 7]", q.PCRs, len(q.Values))
+	}
+	if err := VerifyQuote(pub, q, nonce); err != nil {
+		t.Errorf("VerifyQuote = %v", err)
+	}
+}
+
+func TestNVWrite(t *testing.T) {
+	tpm := getSimulator(t)
+	const index = 0x1500016
+	data := bytes.Repeat([]byte("u-root"), 200)
+	if err := tpm.NVDefine(index, uint16(len(data)), "", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	defer tpm.NVUndefine(index, "")
+	if err := tpm.NVWriteValue(index, "pw", data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tpm.NVReadValue(index, "pw", 0, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("NVReadValue = %q, want %q", got, data)
+	}
+	if err := tpm.NVWriteValue(index, "wrong", data); err == nil {
+		t.Errorf("NVWriteValue with wrong password = nil, want error")
+	}
+}
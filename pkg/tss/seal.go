@@ -0,0 +1,169 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// numPCRs is the number of PCRs in the SHA-256 bank.
+const numPCRs = 24
+
+// ErrNoPCRs is returned when sealing to an empty PCR selection, which
+// would make the sealed data available to anyone.
+var ErrNoPCRs = errors.New("no PCRs to seal to")
+
+// srkTemplate is the template of the storage root key, which is created
+// from the owner seed whenever it is needed instead of being persisted.
+var srkTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagStorageDefault | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Symmetric:  &tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 128, Mode: tpm2.AlgCFB},
+		KeyBits:    2048,
+		ModulusRaw: make([]byte, 256),
+	},
+}
+
+// SealedBlob is data sealed by the TPM to the SHA-256 values of PCRs. Only
+// the TPM which sealed it can unseal it, and only while the PCRs have the
+// values they had when it was sealed. It is stored as JSON.
+type SealedBlob struct {
+	PCRs    []int  `json:"pcrs"`
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+}
+
+// pcrSelection encodes a TPML_PCR_SELECTION of pcrs in the SHA-256 bank.
+func pcrSelection(pcrs []int) ([]byte, error) {
+	var mask [numPCRs / 8]byte
+	for _, p := range pcrs {
+		if p < 0 || p >= numPCRs {
+			return nil, fmt.Errorf("PCR %d out of range", p)
+		}
+		mask[p/8] |= 1 << (p % 8)
+	}
+	b := binary.BigEndian.AppendUint32(nil, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(tpm2.AlgSHA256))
+	b = append(b, byte(len(mask)))
+	return append(b, mask[:]...), nil
+}
+
+// PCRPolicy computes the digest of a policy session in which only
+// TPM2_PolicyPCR was run for the SHA-256 PCRs with the given values. The
+// values are in the order of the PCRs, which must be sorted.
+func PCRPolicy(pcrs []int, values [][]byte) ([]byte, error) {
+	if len(pcrs) != len(values) {
+		return nil, fmt.Errorf("%d PCRs but %d values", len(pcrs), len(values))
+	}
+	sel, err := pcrSelection(pcrs)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, v := range values {
+		h.Write(v)
+	}
+	pcrDigest := h.Sum(nil)
+
+	h.Reset()
+	h.Write(make([]byte, sha256.Size))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(tpm2.CmdPolicyPCR)))
+	h.Write(sel)
+	h.Write(pcrDigest)
+	return h.Sum(nil), nil
+}
+
+func createSRK(rwc io.ReadWriter, ownerPassword string) (tpmutil.Handle, error) {
+	srk, _, err := tpm2.CreatePrimary(rwc, tpm2.HandleOwner, tpm2.PCRSelection{}, ownerPassword, "", srkTemplate)
+	if err != nil {
+		return 0, fmt.Errorf("creating SRK: %w", err)
+	}
+	return srk, nil
+}
+
+func seal20(rwc io.ReadWriter, ownerPassword string, data []byte, pcrs []int) (*SealedBlob, error) {
+	values := make([][]byte, len(pcrs))
+	for i, p := range pcrs {
+		v, err := tpm2.ReadPCR(rwc, p, tpm2.AlgSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("reading PCR %d: %w", p, err)
+		}
+		values[i] = v
+	}
+	policy, err := PCRPolicy(pcrs, values)
+	if err != nil {
+		return nil, err
+	}
+	srk, err := createSRK(rwc, ownerPassword)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rwc, srk)
+	priv, pub, err := tpm2.Seal(rwc, srk, "", "", policy, data)
+	if err != nil {
+		return nil, fmt.Errorf("sealing: %w", err)
+	}
+	return &SealedBlob{PCRs: pcrs, Public: pub, Private: priv}, nil
+}
+
+func unseal20(rwc io.ReadWriter, ownerPassword string, b *SealedBlob) ([]byte, error) {
+	srk, err := createSRK(rwc, ownerPassword)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rwc, srk)
+	obj, _, err := tpm2.Load(rwc, srk, "", b.Public, b.Private)
+	if err != nil {
+		return nil, fmt.Errorf("loading sealed blob: %w", err)
+	}
+	defer tpm2.FlushContext(rwc, obj)
+
+	session, _, err := tpm2.StartAuthSession(rwc, tpm2.HandleNull, tpm2.HandleNull,
+		make([]byte, sha256.Size), nil, tpm2.SessionPolicy, tpm2.AlgNull, tpm2.AlgSHA256)
+	if err != nil {
+		return nil, fmt.Errorf("starting policy session: %w", err)
+	}
+	defer tpm2.FlushContext(rwc, session)
+	if err := tpm2.PolicyPCR(rwc, session, nil, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: b.PCRs}); err != nil {
+		return nil, fmt.Errorf("PCR policy: %w", err)
+	}
+	data, err := tpm2.UnsealWithSession(rwc, session, obj, "")
+	if err != nil {
+		return nil, fmt.Errorf("unsealing: %w", err)
+	}
+	return data, nil
+}
+
+// Seal seals up to 128 bytes of data to the current SHA-256 values of
+// pcrs, under the storage root key of the owner hierarchy.
+func (t *TPM) Seal(ownerPassword string, data []byte, pcrs []int) (*SealedBlob, error) {
+	if len(pcrs) == 0 {
+		return nil, ErrNoPCRs
+	}
+	switch t.Version {
+	case TPMVersion20:
+		return seal20(t.RWC, ownerPassword, data, sortPCRs(pcrs))
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// Unseal unseals a blob sealed by Seal. It fails if any of the PCRs
+// changed since.
+func (t *TPM) Unseal(ownerPassword string, b *SealedBlob) ([]byte, error) {
+	switch t.Version {
+	case TPMVersion20:
+		return unseal20(t.RWC, ownerPassword, b)
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
@@ -220,3 +220,33 @@ func (t *TPM) NVReadValue(index uint32, ownerPassword string, size, offhandle ui
 	}
 	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
 }
+
+// NVDefine defines a TPM 2.0 NVRAM index of size bytes in the owner
+// hierarchy. It can be read and written with the owner password or with
+// authPassword.
+func (t *TPM) NVDefine(index uint32, size uint16, ownerPassword, authPassword string) error {
+	switch t.Version {
+	case TPMVersion20:
+		return nvDefine20(t.RWC, tpmutil.Handle(index), size, ownerPassword, authPassword)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// NVWriteValue writes data to the start of a TPM 2.0 NVRAM index, using
+// the index's auth password.
+func (t *TPM) NVWriteValue(index uint32, password string, data []byte) error {
+	switch t.Version {
+	case TPMVersion20:
+		return nvWrite20(t.RWC, tpmutil.Handle(index), password, data)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// NVUndefine deletes a TPM 2.0 NVRAM index defined by NVDefine.
+func (t *TPM) NVUndefine(index uint32, ownerPassword string) error {
+	switch t.Version {
+	case TPMVersion20:
+		return nvUndefine20(t.RWC, tpmutil.Handle(index), ownerPassword)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
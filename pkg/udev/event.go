@@ -0,0 +1,96 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package udev is a minimal device manager: it receives kernel uevents,
+// creates the device nodes in /dev, loads the modules of new devices and
+// creates symlinks, such as /dev/disk/by-uuid, from a small set of rules.
+package udev
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBadEvent is returned for messages which are not kernel uevents.
+var ErrBadEvent = errors.New("not a uevent")
+
+// Event is a kernel uevent.
+type Event struct {
+	// Action is add, remove, change, move, online, offline, bind or
+	// unbind.
+	Action string
+	// DevPath is the path of the device in sysfs, without /sys.
+	DevPath string
+	// Env are the variables of the event, such as SUBSYSTEM, DEVNAME or
+	// MODALIAS. Rules and the manager add their own, such as ID_PATH.
+	Env map[string]string
+}
+
+// Get returns a variable of the event, or "".
+func (e *Event) Get(key string) string {
+	return e.Env[key]
+}
+
+// Subsystem returns the subsystem of the device, e.g. block.
+func (e *Event) Subsystem() string {
+	return e.Env["SUBSYSTEM"]
+}
+
+// DevName returns the name of the device node relative to /dev, e.g. sda
+// or input/event0, or "" if the device has no node.
+func (e *Event) DevName() string {
+	return e.Env["DEVNAME"]
+}
+
+// Devnum returns the major and minor numbers of the device node.
+func (e *Event) Devnum() (uint32, uint32, bool) {
+	major, err1 := strconv.ParseUint(e.Env["MAJOR"], 10, 32)
+	minor, err2 := strconv.ParseUint(e.Env["MINOR"], 10, 32)
+	return uint32(major), uint32(minor), err1 == nil && err2 == nil
+}
+
+// String formats the event as udevadm monitor does.
+func (e *Event) String() string {
+	return fmt.Sprintf("%s %s (%s)", e.Action, e.DevPath, e.Subsystem())
+}
+
+// ParseEvent parses a uevent as the kernel sends it:
+//
+//	action@devpath\0KEY=value\0...
+//
+// Messages of libudev, which start with "libudev", are not kernel uevents.
+func ParseEvent(b []byte) (*Event, error) {
+	fields := bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0})
+	action, devpath, ok := strings.Cut(string(fields[0]), "@")
+	if !ok {
+		return nil, fmt.Errorf("%w: header %q", ErrBadEvent, fields[0])
+	}
+	e := &Event{Action: action, DevPath: devpath, Env: map[string]string{}}
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(string(f), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: variable %q", ErrBadEvent, f)
+		}
+		e.Env[k] = v
+	}
+	if a := e.Env["ACTION"]; a != "" && a != action {
+		return nil, fmt.Errorf("%w: ACTION %q does not match %q", ErrBadEvent, a, action)
+	}
+	return e, nil
+}
+
+// parseUevent parses a uevent file in sysfs, which has a KEY=value line for
+// each variable.
+func parseUevent(s string) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		msg  string
+		want string
+		env  map[string]string
+		err  error
+	}{
+		{
+			name: "block",
+			msg:  "add@/devices/virtual/block/loop0\x00ACTION=add\x00DEVPATH=/devices/virtual/block/loop0\x00SUBSYSTEM=block\x00MAJOR=7\x00MINOR=0\x00DEVNAME=loop0\x00DEVTYPE=disk\x00SEQNUM=1234\x00",
+			want: "add /devices/virtual/block/loop0 (block)",
+			env:  map[string]string{"DEVNAME": "loop0", "MAJOR": "7", "SEQNUM": "1234"},
+		},
+		{
+			name: "no variables",
+			msg:  "remove@/devices/foo",
+			want: "remove /devices/foo ()",
+		},
+		{
+			name: "libudev",
+			msg:  "libudev\x00\xfe\xed\xca\xfe",
+			err:  ErrBadEvent,
+		},
+		{
+			name: "bad variable",
+			msg:  "add@/devices/foo\x00SUBSYSTEM\x00",
+			err:  ErrBadEvent,
+		},
+		{
+			name: "action mismatch",
+			msg:  "add@/devices/foo\x00ACTION=remove\x00",
+			err:  ErrBadEvent,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := ParseEvent([]byte(tt.msg))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseEvent = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if e.String() != tt.want {
+				t.Errorf("ParseEvent = %q, want %q", e, tt.want)
+			}
+			for k, v := range tt.env {
+				if e.Get(k) != v {
+					t.Errorf("%s = %q, want %q", k, e.Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestDevnum(t *testing.T) {
+	e := &Event{Env: map[string]string{"MAJOR": "259", "MINOR": "3"}}
+	if major, minor, ok := e.Devnum(); major != 259 || minor != 3 || !ok {
+		t.Errorf("Devnum = %d, %d, %v, want 259, 3, true", major, minor, ok)
+	}
+	e = &Event{Env: map[string]string{"MAJOR": "259"}}
+	if _, _, ok := e.Devnum(); ok {
+		t.Errorf("Devnum without MINOR is ok")
+	}
+}
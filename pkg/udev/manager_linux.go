@@ -0,0 +1,239 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Manager creates and removes device nodes and symlinks for uevents, and
+// loads the modules of new devices.
+type Manager struct {
+	// Dev is where device nodes are created, usually /dev.
+	Dev string
+	// Sys is where sysfs is mounted, usually /sys.
+	Sys string
+	// Rules are applied to every event. If nil, DefaultRules are used.
+	Rules []*Rule
+	// Probe loads the modules for a modalias, e.g. kmodule.Probe. If
+	// nil, no modules are loaded.
+	Probe func(modalias string) error
+	// FSUUID returns the UUID of the filesystem on a block device node,
+	// for by-uuid links. If nil, it is not read.
+	FSUUID func(node string) (string, error)
+	// Log, if set, is called for every action taken and every error
+	// which does not stop an event from being handled.
+	Log func(format string, args ...interface{})
+
+	mu sync.Mutex
+	// links are the symlinks created for each devpath, to be removed
+	// with the device, and owners the devpath each link points to.
+	links  map[string][]string
+	owners map[string]string
+}
+
+// NewManager returns a Manager for /dev and /sys, with the default rules.
+func NewManager() *Manager {
+	return &Manager{Dev: "/dev", Sys: "/sys"}
+}
+
+func (m *Manager) logf(format string, args ...interface{}) {
+	if m.Log != nil {
+		m.Log(format, args...)
+	}
+}
+
+func (m *Manager) rules() []*Rule {
+	if m.Rules == nil {
+		r, err := ParseRules(strings.NewReader(DefaultRules))
+		if err != nil {
+			panic(err)
+		}
+		m.Rules = r
+	}
+	return m.Rules
+}
+
+// Handle handles one event: on add, it loads the device's modules; on add
+// and change, it creates its node and symlinks; on remove, it removes them.
+func (m *Manager) Handle(e *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.links == nil {
+		m.links, m.owners = map[string][]string{}, map[string]string{}
+	}
+
+	if e.Action == "add" && e.Env["MODALIAS"] != "" && m.Probe != nil {
+		// Most devices have no module, or one that is built in.
+		if err := m.Probe(e.Env["MODALIAS"]); err == nil {
+			m.logf("%s: loaded modules for %s", e.DevPath, e.Env["MODALIAS"])
+		}
+	}
+
+	if e.DevName() == "" {
+		return nil
+	}
+	switch e.Action {
+	case "add", "change", "move":
+		return m.add(e)
+	case "remove":
+		return m.remove(e)
+	}
+	return nil
+}
+
+// properties adds the variables the default rules need to a block device
+// event.
+func (m *Manager) properties(e *Event, node string) {
+	if e.Subsystem() != "block" {
+		return
+	}
+	if p := IDPath(e.DevPath); p != "" {
+		e.Env["ID_PATH"] = p
+	}
+	// Partitions have PARTNAME in their events, but PARTUUID only in
+	// newer kernels' uevent files.
+	if _, ok := e.Env["PARTUUID"]; !ok && m.Sys != "" {
+		if b, err := os.ReadFile(filepath.Join(m.Sys, e.DevPath, "uevent")); err == nil {
+			if u := parseUevent(string(b))["PARTUUID"]; u != "" {
+				e.Env["PARTUUID"] = u
+			}
+		}
+	}
+	if m.FSUUID != nil {
+		if u, err := m.FSUUID(node); err == nil && u != "" {
+			e.Env["ID_FS_UUID"] = u
+		}
+	}
+}
+
+func (m *Manager) add(e *Event) error {
+	major, minor, ok := e.Devnum()
+	if !ok {
+		return fmt.Errorf("%s: no MAJOR and MINOR", e.DevPath)
+	}
+	typ := uint32(unix.S_IFCHR)
+	if e.Subsystem() == "block" {
+		typ = unix.S_IFBLK
+	}
+
+	// The kernel's devtmpfs usually has created the node already.
+	kernelNode := filepath.Join(m.Dev, e.DevName())
+	m.properties(e, kernelNode)
+	res := Apply(m.rules(), e)
+	node := filepath.Join(m.Dev, res.Name)
+
+	if err := os.MkdirAll(filepath.Dir(node), 0o755); err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(node, &st); err == nil && (st.Mode&unix.S_IFMT != typ || st.Rdev != unix.Mkdev(major, minor)) {
+		// A stale node of another device.
+		if err := os.Remove(node); err != nil {
+			return err
+		}
+	}
+	if err := unix.Mknod(node, typ|res.Mode, int(unix.Mkdev(major, minor))); err != nil && !errors.Is(err, unix.EEXIST) {
+		return &os.PathError{Op: "mknod", Path: node, Err: err}
+	}
+	if err := os.Chmod(node, fs.FileMode(res.Mode)); err != nil {
+		return err
+	}
+	if res.UID >= 0 || res.GID >= 0 {
+		if err := os.Chown(node, res.UID, res.GID); err != nil {
+			return err
+		}
+	}
+	if node != kernelNode {
+		// Renamed by a rule.
+		os.Remove(kernelNode)
+	}
+
+	m.removeLinks(e.DevPath)
+	for _, l := range res.Symlinks {
+		link := filepath.Join(m.Dev, l)
+		target, err := filepath.Rel(filepath.Dir(link), node)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+			return err
+		}
+		// The last device wins a link, as with udev without link
+		// priorities.
+		os.Remove(link)
+		if err := os.Symlink(target, link); err != nil {
+			return err
+		}
+		m.links[e.DevPath] = append(m.links[e.DevPath], link)
+		m.owners[link] = e.DevPath
+		m.logf("%s: %s -> %s", e.DevPath, link, target)
+	}
+	m.logf("%s: %s %o", e.DevPath, node, res.Mode)
+	return nil
+}
+
+// removeLinks removes the symlinks made for a device, unless another device
+// has taken them over since.
+func (m *Manager) removeLinks(devpath string) {
+	for _, l := range m.links[devpath] {
+		if m.owners[l] == devpath {
+			os.Remove(l)
+			delete(m.owners, l)
+		}
+	}
+	delete(m.links, devpath)
+}
+
+func (m *Manager) remove(e *Event) error {
+	m.removeLinks(e.DevPath)
+	res := Apply(m.rules(), e)
+	node := filepath.Join(m.Dev, res.Name)
+	if err := os.Remove(node); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	m.logf("%s: removed %s", e.DevPath, node)
+	return nil
+}
+
+// Coldplug calls handle with an add event for every device already in
+// sysfs, parents before their children, as if they were just added.
+func (m *Manager) Coldplug(handle func(*Event) error) error {
+	devices := filepath.Join(m.Sys, "devices")
+	return filepath.WalkDir(devices, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Devices may disappear while we walk.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.Name() != "uevent" || d.IsDir() {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		e := &Event{Action: "add", DevPath: strings.TrimPrefix(dir, m.Sys), Env: parseUevent(string(b))}
+		e.Env["ACTION"], e.Env["DEVPATH"] = e.Action, e.DevPath
+		if s, err := os.Readlink(filepath.Join(dir, "subsystem")); err == nil {
+			e.Env["SUBSYSTEM"] = filepath.Base(s)
+		}
+		if err := handle(e); err != nil {
+			m.logf("%v: %v", e, err)
+		}
+		return nil
+	})
+}
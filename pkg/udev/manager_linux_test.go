@@ -0,0 +1,169 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestManager(t *testing.T) {
+	dev, sys := t.TempDir(), t.TempDir()
+	var probed []string
+	m := &Manager{
+		Dev: dev,
+		Sys: sys,
+		Probe: func(modalias string) error {
+			probed = append(probed, modalias)
+			return nil
+		},
+		FSUUID: func(node string) (string, error) {
+			if filepath.Base(node) != "sda1" {
+				return "", errors.New("no filesystem")
+			}
+			return "5c7e-2f1a", nil
+		},
+	}
+
+	e := sda1()
+	e.Env["MAJOR"], e.Env["MINOR"] = "8", "1"
+	e.Env["MODALIAS"] = "scsi:t-0x00"
+	if err := m.Handle(e); errors.Is(err, unix.EPERM) {
+		t.Skipf("mknod: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if len(probed) != 1 || probed[0] != "scsi:t-0x00" {
+		t.Errorf("probed %v, want [scsi:t-0x00]", probed)
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(filepath.Join(dev, "sda1"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode != unix.S_IFBLK|0o600 || st.Rdev != unix.Mkdev(8, 1) {
+		t.Errorf("sda1: mode %o rdev %x, want %o %x", st.Mode, st.Rdev, unix.S_IFBLK|0o600, unix.Mkdev(8, 1))
+	}
+	for _, link := range []string{
+		"disk/by-uuid/5c7e-2f1a",
+		"disk/by-partuuid/0b0b6b2c-01",
+		"disk/by-path/pci-0000:00:1f.2-ata-1-part1",
+	} {
+		target, err := os.Readlink(filepath.Join(dev, link))
+		if err != nil {
+			t.Errorf("link: %v", err)
+		} else if target != "../../sda1" {
+			t.Errorf("%s -> %s, want ../../sda1", link, target)
+		}
+	}
+
+	// A change with another UUID moves the link.
+	m.FSUUID = func(string) (string, error) { return "7f00-0001", nil }
+	e.Action = "change"
+	if err := m.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dev, "disk/by-uuid/5c7e-2f1a")); !os.IsNotExist(err) {
+		t.Errorf("old by-uuid link still exists: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dev, "disk/by-uuid/7f00-0001")); err != nil {
+		t.Errorf("new by-uuid link: %v", err)
+	}
+	if len(probed) != 1 {
+		t.Errorf("change probed modules again: %v", probed)
+	}
+
+	e.Action = "remove"
+	if err := m.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	filepath.Walk(dev, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			left = append(left, strings.TrimPrefix(p, dev))
+		}
+		return nil
+	})
+	if len(left) != 0 {
+		t.Errorf("left after remove: %v", left)
+	}
+}
+
+func TestManagerRename(t *testing.T) {
+	dev := t.TempDir()
+	rules, err := ParseRules(strings.NewReader(`KERNEL=="ttyS0", NAME="console/serial", MODE="0620", GROUP="5"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{Dev: dev, Rules: rules}
+	// As devtmpfs would have made it.
+	if err := unix.Mknod(filepath.Join(dev, "ttyS0"), unix.S_IFCHR|0o600, int(unix.Mkdev(4, 64))); err != nil {
+		t.Skipf("mknod: %v", err)
+	}
+	e := &Event{Action: "add", DevPath: "/devices/platform/serial8250/tty/ttyS0", Env: map[string]string{
+		"SUBSYSTEM": "tty", "DEVNAME": "ttyS0", "MAJOR": "4", "MINOR": "64",
+	}}
+	if err := m.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dev, "ttyS0")); !os.IsNotExist(err) {
+		t.Errorf("kernel node still exists: %v", err)
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(filepath.Join(dev, "console/serial"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode != unix.S_IFCHR|0o620 || st.Gid != 5 {
+		t.Errorf("console/serial: mode %o gid %d, want %o 5", st.Mode, st.Gid, unix.S_IFCHR|0o620)
+	}
+}
+
+func TestColdplug(t *testing.T) {
+	sys := t.TempDir()
+	files := map[string]string{
+		"devices/virtual/block/loop0/uevent":      "MAJOR=7\nMINOR=0\nDEVNAME=loop0\nDEVTYPE=disk\n",
+		"devices/virtual/block/loop0/size":        "0\n",
+		"devices/pci0000:00/0000:00:02.0/uevent":  "DRIVER=virtio-pci\nMODALIAS=pci:v00001AF4d00001001sv00001AF4sd00000002bc01sc00i00\n",
+		"devices/pci0000:00/0000:00:02.0/enable":  "1\n",
+		"devices/pci0000:00/0000:00:02.0/vendor":  "0x1af4\n",
+		"devices/pci0000:00/0000:00:02.0/virtio0": "",
+		"class/block/loop0":                       "",
+	}
+	for name, data := range files {
+		f := filepath.Join(sys, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../../../../class/block", filepath.Join(sys, "devices/virtual/block/loop0/subsystem")); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{Sys: sys}
+	var got []string
+	if err := m.Coldplug(func(e *Event) error {
+		got = append(got, e.String()+" "+e.DevName()+" "+e.Get("MODALIAS"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{
+		"add /devices/pci0000:00/0000:00:02.0 ()  pci:v00001AF4d00001001sv00001AF4sd00000002bc01sc00i00",
+		"add /devices/virtual/block/loop0 (block) loop0 ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Coldplug events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Conn receives uevents from the kernel.
+type Conn struct {
+	fd  int
+	buf []byte
+}
+
+// kernelGroup is the netlink multicast group of kernel uevents; libudev
+// sends its own on group 2.
+const kernelGroup = 1
+
+// Listen opens a netlink socket receiving the kernel's uevents.
+func Listen() (*Conn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	// Coldplug and module loading cause bursts of events, which must
+	// not be dropped. Raising the limit needs CAP_NET_ADMIN, so this
+	// may fail.
+	unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUFFORCE, 8<<20)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: kernelGroup}); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return &Conn{fd: fd, buf: make([]byte, 64<<10)}, nil
+}
+
+// Read blocks until the next uevent. Messages which are not uevents, or
+// which do not come from the kernel, are skipped.
+func (c *Conn) Read() (*Event, error) {
+	for {
+		n, from, err := unix.Recvfrom(c.fd, c.buf, 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		// Only the kernel has port ID 0; anyone else could forge
+		// events.
+		if nl, ok := from.(*unix.SockaddrNetlink); !ok || nl.Pid != 0 {
+			continue
+		}
+		e, err := ParseEvent(c.buf[:n])
+		if errors.Is(err, ErrBadEvent) {
+			continue
+		}
+		return e, err
+	}
+}
+
+// SetTimeout makes Read fail with an error wrapping unix.EAGAIN if no event
+// arrives for the given number of milliseconds; 0 blocks forever.
+func (c *Conn) SetTimeout(ms int) error {
+	tv := unix.NsecToTimeval(int64(ms) * 1e6)
+	return unix.SetsockoptTimeval(c.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+}
+
+// Close closes the socket.
+func (c *Conn) Close() error {
+	return unix.Close(c.fd)
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	pciAddr   = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+	ataPort   = regexp.MustCompile(`^ata([0-9]+)$`)
+	scsiAddr  = regexp.MustCompile(`^[0-9]+:[0-9]+:[0-9]+:[0-9]+$`)
+	usbIntf   = regexp.MustCompile(`^[0-9]+-([0-9.]+:[0-9]+\.[0-9]+)$`)
+	nvmeSpace = regexp.MustCompile(`^nvme[0-9]+n([0-9]+)$`)
+)
+
+// IDPath returns the persistent path of a block device from its devpath in
+// sysfs, like udev's ID_PATH, e.g. pci-0000:00:1f.2-ata-1 or
+// pci-0000:01:00.0-nvme-1. Devices without a bus, like loop devices, have
+// none.
+func IDPath(devpath string) string {
+	comps := strings.Split(strings.Trim(devpath, "/"), "/")
+	var parts []string
+	var ata bool
+	for i, c := range comps {
+		switch {
+		case c == "virtual":
+			return ""
+		case c == "block":
+			// The disk, or the disk of a partition.
+			if i+1 < len(comps) {
+				if m := nvmeSpace.FindStringSubmatch(comps[i+1]); m != nil {
+					parts = append(parts, "nvme-"+m[1])
+				}
+			}
+			return strings.Join(parts, "-")
+		case pciAddr.MatchString(c):
+			// Only the PCI device closest to the disk counts, not
+			// the bridges above it.
+			parts, ata = []string{"pci-" + c}, false
+		case i > 0 && comps[i-1] == "platform":
+			parts, ata = []string{"platform-" + c}, false
+		case ataPort.MatchString(c):
+			parts = append(parts, "ata-"+ataPort.FindStringSubmatch(c)[1])
+			ata = true
+		case usbIntf.MatchString(c):
+			parts = append(parts, "usb-0:"+usbIntf.FindStringSubmatch(c)[1])
+		case scsiAddr.MatchString(c) && !ata:
+			parts = append(parts, "scsi-"+c)
+		}
+	}
+	return strings.Join(parts, "-")
+}
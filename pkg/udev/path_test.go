@@ -0,0 +1,28 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import "testing"
+
+func TestIDPath(t *testing.T) {
+	for _, tt := range []struct {
+		devpath string
+		want    string
+	}{
+		{"/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda", "pci-0000:00:1f.2-ata-1"},
+		{"/devices/pci0000:00/0000:00:1f.2/ata3/host2/target2:0:0/2:0:0:0/block/sdb/sdb2", "pci-0000:00:1f.2-ata-3"},
+		{"/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/nvme/nvme0/nvme0n1", "pci-0000:01:00.0"},
+		{"/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/nvme/nvme0/block/nvme0n1", "pci-0000:01:00.0-nvme-1"},
+		{"/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/nvme/nvme0/block/nvme0n2/nvme0n2p1", "pci-0000:01:00.0-nvme-2"},
+		{"/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host3/target3:0:0/3:0:0:0/block/sdc", "pci-0000:00:14.0-usb-0:1:1.0-scsi-3:0:0:0"},
+		{"/devices/pci0000:00/0000:00:04.0/virtio1/host0/target0:0:0/0:0:0:1/block/sda", "pci-0000:00:04.0-scsi-0:0:0:1"},
+		{"/devices/platform/fe320000.mmc/mmc_host/mmc1/mmc1:0001/block/mmcblk1", "platform-fe320000.mmc"},
+		{"/devices/virtual/block/loop0", ""},
+	} {
+		if got := IDPath(tt.devpath); got != tt.want {
+			t.Errorf("IDPath(%s) = %q, want %q", tt.devpath, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrBadRule is returned for rules which cannot be parsed.
+var ErrBadRule = errors.New("bad rule")
+
+// Rule is a line of comma separated keys, in a subset of the udev rules
+// syntax:
+//
+//	SUBSYSTEM=="block", KERNEL=="sd*", MODE="0660", SYMLINK+="disk/by-path/$env{ID_PATH}"
+//
+// Match keys, with == or !=, take shell glob patterns:
+//
+//	ACTION, SUBSYSTEM, KERNEL (the name of the device node), DEVPATH,
+//	DEVTYPE, DRIVER, ENV{variable}
+//
+// Assignments, with = or += for SYMLINK, are applied if all keys match:
+//
+//	NAME (of the device node), MODE (octal), OWNER, GROUP (numeric),
+//	SYMLINK, ENV{variable}
+//
+// Values may contain $kernel (or %k), $number (%n), the number at the
+// end of the kernel name, and $env{variable} (%E{variable}). Symlinks are
+// not created if a variable they contain is empty.
+type Rule struct {
+	matches []match
+	assigns []assign
+}
+
+type match struct {
+	key, pattern string
+	negate       bool
+}
+
+type assign struct {
+	key, value string
+	add        bool
+}
+
+// DefaultRules are the rules a Manager uses if none are given: the links
+// in /dev/disk that are needed to find boot devices.
+const DefaultRules = `
+SUBSYSTEM=="block", ENV{ID_FS_UUID}=="?*", SYMLINK+="disk/by-uuid/$env{ID_FS_UUID}"
+SUBSYSTEM=="block", ENV{PARTUUID}=="?*", SYMLINK+="disk/by-partuuid/$env{PARTUUID}"
+SUBSYSTEM=="block", ENV{PARTNAME}=="?*", SYMLINK+="disk/by-partlabel/$env{PARTNAME}"
+SUBSYSTEM=="block", DEVTYPE=="disk", ENV{ID_PATH}=="?*", SYMLINK+="disk/by-path/$env{ID_PATH}"
+SUBSYSTEM=="block", DEVTYPE=="partition", ENV{ID_PATH}=="?*", SYMLINK+="disk/by-path/$env{ID_PATH}-part$env{PARTN}"
+`
+
+var (
+	matchKeys  = map[string]bool{"ACTION": true, "SUBSYSTEM": true, "KERNEL": true, "DEVPATH": true, "DEVTYPE": true, "DRIVER": true}
+	assignKeys = map[string]bool{"NAME": true, "MODE": true, "OWNER": true, "GROUP": true, "SYMLINK": true}
+)
+
+// splitKeys splits a rule at the commas outside quotes.
+func splitKeys(line string) ([]string, error) {
+	var keys []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ',' && !quoted:
+			keys = append(keys, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrBadRule)
+	}
+	return append(keys, strings.TrimSpace(cur.String())), nil
+}
+
+// ParseRule parses one rule.
+func ParseRule(line string) (*Rule, error) {
+	keys, err := splitKeys(line)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rule{}
+	for _, k := range keys {
+		i := strings.IndexAny(k, "=!+")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: %q has no operator", ErrBadRule, k)
+		}
+		key, rest := k[:i], k[i:]
+		var op string
+		for _, o := range []string{"==", "!=", "+=", "="} {
+			if strings.HasPrefix(rest, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("%w: %q has no operator", ErrBadRule, k)
+		}
+		v := strings.TrimSpace(rest[len(op):])
+		if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+			return nil, fmt.Errorf("%w: value of %s is not quoted", ErrBadRule, key)
+		}
+		v = v[1 : len(v)-1]
+
+		isEnv := strings.HasPrefix(key, "ENV{") && strings.HasSuffix(key, "}")
+		switch op {
+		case "==", "!=":
+			if !matchKeys[key] && !isEnv {
+				return nil, fmt.Errorf("%w: cannot match %s", ErrBadRule, key)
+			}
+			if _, err := path.Match(v, ""); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrBadRule, key, err)
+			}
+			r.matches = append(r.matches, match{key: key, pattern: v, negate: op == "!="})
+		default:
+			if !assignKeys[key] && !isEnv {
+				return nil, fmt.Errorf("%w: cannot assign %s", ErrBadRule, key)
+			}
+			if op == "+=" && key != "SYMLINK" {
+				return nil, fmt.Errorf("%w: cannot add to %s", ErrBadRule, key)
+			}
+			if key == "MODE" {
+				if _, err := strconv.ParseUint(v, 8, 32); err != nil {
+					return nil, fmt.Errorf("%w: MODE %q is not octal", ErrBadRule, v)
+				}
+			}
+			if key == "OWNER" || key == "GROUP" {
+				if _, err := strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("%w: %s %q is not numeric", ErrBadRule, key, v)
+				}
+			}
+			r.assigns = append(r.assigns, assign{key: key, value: v, add: op == "+="})
+		}
+	}
+	if len(r.assigns) == 0 {
+		return nil, fmt.Errorf("%w: no assignments", ErrBadRule)
+	}
+	return r, nil
+}
+
+// ParseRules parses rules, one per line. Empty lines and lines starting with
+// # are skipped.
+func ParseRules(rd io.Reader) ([]*Rule, error) {
+	var rules []*Rule
+	s := bufio.NewScanner(rd)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, s.Err()
+}
+
+// value returns the value of a match key for an event.
+func value(e *Event, key string) string {
+	switch key {
+	case "ACTION":
+		return e.Action
+	case "DEVPATH":
+		return e.DevPath
+	case "KERNEL":
+		return path.Base(e.DevPath)
+	}
+	if strings.HasPrefix(key, "ENV{") {
+		return e.Env[key[4:len(key)-1]]
+	}
+	return e.Env[key]
+}
+
+// glob matches like fnmatch without FNM_PATHNAME, as udev does: unlike with
+// path.Match, * and ? also match /.
+func glob(pattern, s string) bool {
+	slash := func(s string) string { return strings.ReplaceAll(s, "/", "\x00") }
+	ok, _ := path.Match(slash(pattern), slash(s))
+	return ok
+}
+
+// Match reports whether all match keys of the rule match the event.
+func (r *Rule) Match(e *Event) bool {
+	for _, m := range r.matches {
+		ok := glob(m.pattern, value(e, m.key))
+		if ok == m.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// kernelNumber returns the number at the end of a kernel name, e.g. 1 for
+// sda1.
+func kernelNumber(name string) string {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	return name[i:]
+}
+
+// escapeLink escapes the characters of a variable which cannot be part of
+// a symlink name, as udev does, e.g. / as \x2f.
+func escapeLink(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '/' || c == '\\' || c <= ' ' || c >= 0x7f {
+			fmt.Fprintf(&b, "\\x%02x", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// substitute replaces the variables in s, escaped for symlinks if link is
+// set. It returns false if a variable is empty.
+func substitute(s string, e *Event, link bool) (string, bool) {
+	kernel := path.Base(e.DevPath)
+	complete := true
+	var b strings.Builder
+	for len(s) > 0 {
+		var v string
+		switch {
+		case strings.HasPrefix(s, "$kernel"):
+			v, s = kernel, s[len("$kernel"):]
+		case strings.HasPrefix(s, "%k"):
+			v, s = kernel, s[2:]
+		case strings.HasPrefix(s, "$number"):
+			v, s = kernelNumber(kernel), s[len("$number"):]
+		case strings.HasPrefix(s, "%n"):
+			v, s = kernelNumber(kernel), s[2:]
+		case strings.HasPrefix(s, "$env{") || strings.HasPrefix(s, "%E{"):
+			start := strings.IndexByte(s, '{')
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				b.WriteString(s)
+				return b.String(), complete
+			}
+			v, s = e.Env[s[start+1:end]], s[end+1:]
+		default:
+			b.WriteByte(s[0])
+			s = s[1:]
+			continue
+		}
+		if v == "" {
+			complete = false
+		}
+		if link {
+			v = escapeLink(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String(), complete
+}
+
+// Result is what the rules assign to a device.
+type Result struct {
+	// Name is the name of the device node relative to /dev.
+	Name string
+	// Mode, UID and GID are the permissions of the device node; UID and
+	// GID are -1 to leave them.
+	Mode     uint32
+	UID, GID int
+	// Symlinks are the links to the node, relative to /dev.
+	Symlinks []string
+}
+
+// Apply applies the rules which match an event, in order. ENV assignments
+// change the event, so later rules can match them.
+func Apply(rules []*Rule, e *Event) Result {
+	res := Result{Name: e.DevName(), Mode: 0o600, UID: -1, GID: -1}
+	if m, err := strconv.ParseUint(e.Env["DEVMODE"], 8, 32); err == nil {
+		res.Mode = uint32(m)
+	}
+	for _, r := range rules {
+		if !r.Match(e) {
+			continue
+		}
+		for _, a := range r.assigns {
+			v, complete := substitute(a.value, e, a.key == "SYMLINK")
+			switch {
+			case a.key == "NAME":
+				res.Name = v
+			case a.key == "MODE":
+				m, _ := strconv.ParseUint(v, 8, 32)
+				res.Mode = uint32(m)
+			case a.key == "OWNER":
+				res.UID, _ = strconv.Atoi(v)
+			case a.key == "GROUP":
+				res.GID, _ = strconv.Atoi(v)
+			case a.key == "SYMLINK":
+				if !a.add {
+					res.Symlinks = nil
+				}
+				if complete && v != "" {
+					res.Symlinks = append(res.Symlinks, v)
+				}
+			default:
+				e.Env[a.key[4:len(a.key)-1]] = v
+			}
+		}
+	}
+	return res
+}
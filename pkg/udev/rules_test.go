@@ -0,0 +1,119 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udev
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		`KERNEL=="sda`,
+		`KERNEL`,
+		`KERNEL=sda`,
+		`MAJOR=="8", MODE="0600"`,
+		`KERNEL=="sd[", MODE="0600"`,
+		`KERNEL=="sda", SEQNUM="1"`,
+		`KERNEL=="sda", NAME+="disk"`,
+		`KERNEL=="sda", MODE="0999"`,
+		`KERNEL=="sda", OWNER="root"`,
+		`KERNEL=="sda"`,
+	} {
+		if _, err := ParseRule(rule); !errors.Is(err, ErrBadRule) {
+			t.Errorf("ParseRule(%s) = %v, want %v", rule, err, ErrBadRule)
+		}
+	}
+}
+
+func TestParseRulesLine(t *testing.T) {
+	_, err := ParseRules(strings.NewReader("# disks\n\nKERNEL==\"sda\", MODE=\"0600\"\nKERNEL=\"sdb\"\n"))
+	if !errors.Is(err, ErrBadRule) || !strings.HasPrefix(err.Error(), "line 4:") {
+		t.Errorf("ParseRules = %v, want an %v on line 4", err, ErrBadRule)
+	}
+}
+
+func sda1() *Event {
+	return &Event{
+		Action:  "add",
+		DevPath: "/devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda/sda1",
+		Env: map[string]string{
+			"SUBSYSTEM": "block",
+			"DEVTYPE":   "partition",
+			"DEVNAME":   "sda1",
+			"PARTN":     "1",
+			"PARTNAME":  "EFI System/Partition",
+			"PARTUUID":  "0b0b6b2c-01",
+			"ID_PATH":   "pci-0000:00:1f.2-ata-1",
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rules string
+		event func() *Event
+		want  Result
+	}{
+		{
+			name:  "default",
+			rules: DefaultRules,
+			event: sda1,
+			want: Result{Name: "sda1", Mode: 0o600, UID: -1, GID: -1, Symlinks: []string{
+				"disk/by-partuuid/0b0b6b2c-01",
+				`disk/by-partlabel/EFI\x20System\x2fPartition`,
+				"disk/by-path/pci-0000:00:1f.2-ata-1-part1",
+			}},
+		},
+		{
+			name:  "devmode",
+			rules: DefaultRules,
+			event: func() *Event {
+				return &Event{DevPath: "/devices/virtual/misc/fuse", Env: map[string]string{"DEVNAME": "fuse", "DEVMODE": "0666"}}
+			},
+			want: Result{Name: "fuse", Mode: 0o666, UID: -1, GID: -1},
+		},
+		{
+			name: "assign",
+			rules: `
+KERNEL=="sd*[0-9]", SUBSYSTEM=="block", MODE="0660", GROUP="6"
+KERNEL!="sda*", MODE="0600"
+ENV{PARTN}=="1", ENV{ROLE}="boot"
+ENV{ROLE}=="boot", NAME="boot/%k", SYMLINK+="boot$number"
+DEVTYPE=="disk", SYMLINK+="not/$kernel"
+`,
+			event: sda1,
+			want:  Result{Name: "boot/sda1", Mode: 0o660, UID: -1, GID: 6, Symlinks: []string{"boot1"}},
+		},
+		{
+			name: "replace symlinks",
+			rules: `
+KERNEL=="sda1", SYMLINK+="one", SYMLINK+="two"
+ACTION=="add", SYMLINK="three", OWNER="0"
+`,
+			event: sda1,
+			want:  Result{Name: "sda1", Mode: 0o600, UID: 0, GID: -1, Symlinks: []string{"three"}},
+		},
+		{
+			name:  "empty variable",
+			rules: `KERNEL=="sda1", SYMLINK+="disk/by-id/$env{ID_SERIAL}"`,
+			event: sda1,
+			want:  Result{Name: "sda1", Mode: 0o600, UID: -1, GID: -1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(tt.rules))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Apply(rules, tt.event()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// errNoRing is returned when io_uring cannot be set up, and files are
+// copied without it.
+var errNoRing = errors.New("io_uring not available")
+
+// errInvalid is returned for direct I/O of partial blocks.
+var errInvalid = unix.EINVAL
+
+// io_uring ABI, from include/uapi/linux/io_uring.h.
+const (
+	opReadv  = 1
+	opWritev = 2
+
+	enterGetEvents = 1
+
+	offSQRing = 0
+	offCQRing = 0x8000000
+	offSQEs   = 0x10000000
+)
+
+type sqringOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type cqringOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type params struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  sqringOffsets
+	cqOff                                                                  cqringOffsets
+}
+
+type sqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFDIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type cqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// ring is an io_uring.
+type ring struct {
+	fd         int
+	sqMem      []byte
+	cqMem      []byte
+	sqeMem     []byte
+	sqTail     *uint32
+	sqMask     uint32
+	sqArray    []uint32
+	sqes       []sqe
+	cqHead     *uint32
+	cqTail     *uint32
+	cqMask     uint32
+	cqes       []cqe
+	pending    uint32
+	inFlight   int
+	sqEntries  uint32
+	completion []cqe
+}
+
+// newRing returns an io_uring with room for entries requests.
+func newRing(entries int) (*ring, error) {
+	var p params
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("%w: %v", errNoRing, errno)
+	}
+	r := &ring{fd: int(fd), sqEntries: p.sqEntries}
+	var err error
+	mmap := func(off int64, size uint32) []byte {
+		if err != nil {
+			return nil
+		}
+		var b []byte
+		b, err = unix.Mmap(r.fd, off, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		return b
+	}
+	r.sqMem = mmap(offSQRing, p.sqOff.array+p.sqEntries*4)
+	r.cqMem = mmap(offCQRing, p.cqOff.cqes+p.cqEntries*uint32(unsafe.Sizeof(cqe{})))
+	r.sqeMem = mmap(offSQEs, p.sqEntries*uint32(unsafe.Sizeof(sqe{})))
+	if err != nil {
+		r.close()
+		return nil, fmt.Errorf("%w: %v", errNoRing, err)
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqMem[p.sqOff.array])), p.sqEntries)
+	r.sqes = unsafe.Slice((*sqe)(unsafe.Pointer(&r.sqeMem[0])), p.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqMem[p.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*cqe)(unsafe.Pointer(&r.cqMem[p.cqOff.cqes])), p.cqEntries)
+	return r, nil
+}
+
+func (r *ring) close() {
+	for _, b := range [][]byte{r.sqeMem, r.cqMem, r.sqMem} {
+		if b != nil {
+			unix.Munmap(b)
+		}
+	}
+	unix.Close(r.fd)
+}
+
+// queue queues a vectored read or write of iov at off of fd, to complete
+// with id.
+func (r *ring) queue(op uint8, fd uintptr, iov *unix.Iovec, off int64, id uint64) {
+	tail := *r.sqTail
+	i := tail & r.sqMask
+	r.sqes[i] = sqe{
+		opcode:   op,
+		fd:       int32(fd),
+		off:      uint64(off),
+		addr:     uint64(uintptr(unsafe.Pointer(iov))),
+		len:      1,
+		userData: id,
+	}
+	r.sqArray[i] = i
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.pending++
+	r.inFlight++
+}
+
+// wait submits the queued requests and waits for at least one to complete,
+// and returns those which did.
+func (r *ring) wait() ([]cqe, error) {
+	for {
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(r.pending), 1, enterGetEvents, 0, 0)
+		if errno == unix.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return nil, os.NewSyscallError("io_uring_enter", errno)
+		}
+		r.pending -= uint32(n)
+		break
+	}
+	r.completion = r.completion[:0]
+	head := atomic.LoadUint32(r.cqHead)
+	for tail := atomic.LoadUint32(r.cqTail); head != tail; head++ {
+		r.completion = append(r.completion, r.cqes[head&r.cqMask])
+	}
+	atomic.StoreUint32(r.cqHead, head)
+	r.inFlight -= len(r.completion)
+	return r.completion, nil
+}
+
+// maxDepth bounds the depth of ring copies, so that their iovecs fit in a
+// page.
+const maxDepth = Align / int(unsafe.Sizeof(unix.Iovec{}))
+
+// slot is a buffer of a ring copy, which is read into and then written
+// from.
+type slot struct {
+	buf []byte
+	iov *unix.Iovec
+	// off is the offset of buf from the start of the copy.
+	off int64
+	// want is how many bytes are read into buf.
+	want int
+	// n is how many bytes were read, and done how many were written.
+	n, done int
+	writing bool
+	// retried is set once a request was retried through the page cache.
+	retried bool
+}
+
+// ring copies with depth reads and writes in flight in an io_uring.
+func (t *transfer) ring() (int64, error) {
+	depth := min(t.depth, maxDepth)
+	r, err := newRing(depth)
+	if err != nil {
+		return 0, err
+	}
+	defer r.close()
+	defer runtime.KeepAlive(t.src)
+	defer runtime.KeepAlive(t.dst)
+	src, dst := t.src.Fd(), t.dst.Fd()
+
+	// The buffers and iovecs the kernel uses are outside the Go heap, so
+	// that they outlive requests which cannot be waited for.
+	mem, err := unix.Mmap(-1, 0, Align+depth*t.bs, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errNoRing, err)
+	}
+	iovs := unsafe.Slice((*unix.Iovec)(unsafe.Pointer(&mem[0])), depth)
+	slots := make([]slot, depth)
+	for i := range slots {
+		slots[i].buf = mem[Align+i*t.bs : Align+(i+1)*t.bs]
+		slots[i].iov = &iovs[i]
+	}
+
+	// next is the offset the next read starts at, and end where the copy
+	// ends, which is moved back when src ends.
+	var next int64
+	end := t.n
+	queue := func(i int) {
+		s := &slots[i]
+		if s.writing {
+			s.iov.Base = &s.buf[s.done]
+			s.iov.SetLen(s.n - s.done)
+			r.queue(opWritev, dst, s.iov, t.dstOff+s.off+int64(s.done), uint64(i))
+		} else {
+			s.iov.Base = &s.buf[s.n]
+			s.iov.SetLen(s.want - s.n)
+			r.queue(opReadv, src, s.iov, t.srcOff+s.off+int64(s.n), uint64(i))
+		}
+	}
+	start := func(i int) {
+		if next < end {
+			want := int(min(int64(t.bs), end-next))
+			slots[i] = slot{buf: slots[i].buf, iov: slots[i].iov, off: next, want: want}
+			next += int64(want)
+			queue(i)
+		}
+	}
+	for i := range slots {
+		start(i)
+	}
+
+	var firstErr error
+	for r.inFlight > 0 {
+		done, err := r.wait()
+		if err != nil {
+			// Requests in flight may still use the buffers, so they
+			// are not unmapped.
+			return t.total, err
+		}
+		for _, c := range done {
+			i := int(c.userData)
+			s := &slots[i]
+			f, op := t.src, "read"
+			if s.writing {
+				f, op = t.dst, "write"
+			}
+			switch {
+			case c.res < 0:
+				errno := unix.Errno(-c.res)
+				if errno == unix.EINTR || errno == unix.EAGAIN {
+					break
+				}
+				if !s.retried && t.buffered(f, errno) {
+					s.retried = true
+					break
+				}
+				if firstErr == nil {
+					firstErr = &os.PathError{Op: op, Path: f.Name(), Err: errno}
+				}
+				continue
+			case s.writing && c.res == 0:
+				if firstErr == nil {
+					firstErr = &os.PathError{Op: op, Path: f.Name(), Err: io.ErrShortWrite}
+				}
+				continue
+			case s.writing:
+				s.done += int(c.res)
+				t.wrote(int(c.res))
+				if s.done == s.n {
+					if firstErr == nil {
+						start(i)
+					}
+					continue
+				}
+			case c.res == 0:
+				// src ends here.
+				end = min(end, s.off+int64(s.n))
+				if s.n == 0 {
+					continue
+				}
+				s.writing, s.retried = true, false
+			default:
+				s.n += int(c.res)
+				if s.n == s.want {
+					s.writing, s.retried = true, false
+				}
+			}
+			if firstErr == nil {
+				queue(i)
+			}
+		}
+	}
+	unix.Munmap(mem)
+	return t.total, firstErr
+}
+
+// setDirect turns direct I/O for f on or off, and returns whether it was
+// on.
+func setDirect(f *os.File, on bool) (bool, error) {
+	fl, err := unix.FcntlInt(f.Fd(), unix.F_GETFL, 0)
+	if err != nil {
+		return false, err
+	}
+	was := fl&unix.O_DIRECT != 0
+	if on {
+		fl |= unix.O_DIRECT
+	} else {
+		fl &^= unix.O_DIRECT
+	}
+	_, err = unix.FcntlInt(f.Fd(), unix.F_SETFL, fl)
+	return was, err
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package uio copies files and devices with deep queues of large, aligned
+// requests, to keep such devices as NVMe drives busy while images are
+// written.
+//
+// On Linux, requests go through an io_uring, so that many reads and writes
+// are in flight at once with few system calls. Where io_uring is not
+// available, as on older kernels or where it is disabled, files are copied
+// with positional reads and writes instead.
+//
+// Copies may bypass the page cache with O_DIRECT. Buffers are always
+// aligned as direct I/O requires, and the last, partial block of a copy is
+// written through the page cache.
+//
+// For the general I/O helpers u-root uses, see github.com/u-root/uio/uio.
+package uio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// DefaultBlockSize is the size of requests, large enough that
+	// devices see few of them.
+	DefaultBlockSize = 1 << 20
+
+	// DefaultDepth is how many requests are in flight at once.
+	DefaultDepth = 16
+
+	// Align is the alignment of buffers and of offsets for direct I/O.
+	Align = 4096
+)
+
+// Copier copies between files.
+type Copier struct {
+	// BlockSize is the size of each read and write, DefaultBlockSize if
+	// 0. It is rounded up to a multiple of Align.
+	BlockSize int
+	// Depth is how many reads and writes are in flight at once,
+	// DefaultDepth if 0.
+	Depth int
+	// Direct makes copies bypass the page cache where the files support
+	// it and offsets are aligned. Files opened with O_DIRECT bypass it
+	// regardless.
+	Direct bool
+	// NoRing makes copies use reads and writes even where io_uring is
+	// available.
+	NoRing bool
+	// Written, if set, has the bytes written added to it atomically as
+	// they are written, to report progress.
+	Written *int64
+}
+
+// Copy copies src to dst from their offsets until the end of src, and
+// advances their offsets, with the default Copier.
+func Copy(dst, src *os.File) (int64, error) {
+	return Copier{}.Copy(dst, src)
+}
+
+// Copy copies src to dst from their offsets until the end of src, and
+// advances their offsets past the bytes copied.
+func (c Copier) Copy(dst, src *os.File) (int64, error) {
+	srcOff, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	dstOff, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.CopyAt(dst, dstOff, src, srcOff, -1)
+	if _, serr := src.Seek(srcOff+n, io.SeekStart); err == nil {
+		err = serr
+	}
+	if _, serr := dst.Seek(dstOff+n, io.SeekStart); err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+// CopyAt copies n bytes of src at srcOff to dst at dstOff, or up to the end
+// of src if n is negative, and returns how many were copied. The offsets
+// of the files are not used or changed.
+func (c Copier) CopyAt(dst *os.File, dstOff int64, src *os.File, srcOff int64, n int64) (int64, error) {
+	bs := c.BlockSize
+	if bs <= 0 {
+		bs = DefaultBlockSize
+	}
+	bs = (bs + Align - 1) &^ (Align - 1)
+	depth := c.Depth
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	if n < 0 {
+		n = math.MaxInt64 - srcOff
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if depth > 1 && int64(bs) >= n {
+		// One request does it.
+		depth = 1
+	}
+
+	if c.Direct && srcOff%Align == 0 && dstOff%Align == 0 {
+		for _, f := range []*os.File{src, dst} {
+			if was, err := setDirect(f, true); err == nil && !was {
+				defer setDirect(f, false)
+			}
+		}
+	}
+
+	t := &transfer{
+		dst: dst, dstOff: dstOff,
+		src: src, srcOff: srcOff,
+		n: n, bs: bs, depth: depth,
+		written: c.Written,
+		cleared: map[*os.File]bool{},
+	}
+	if !c.NoRing && depth > 1 {
+		written, err := t.ring()
+		if !errors.Is(err, errNoRing) {
+			return written, err
+		}
+	}
+	return t.readWrite()
+}
+
+// transfer is a copy in progress.
+type transfer struct {
+	dst, src       *os.File
+	dstOff, srcOff int64
+	n              int64
+	bs, depth      int
+	written        *int64
+	// total is the bytes written so far.
+	total int64
+	// cleared are the files direct I/O was turned off for.
+	cleared map[*os.File]bool
+}
+
+// wrote counts n bytes written.
+func (t *transfer) wrote(n int) {
+	t.total += int64(n)
+	if t.written != nil {
+		atomic.AddInt64(t.written, int64(n))
+	}
+}
+
+// buffered turns direct I/O off for f after a request failed with err, and
+// reports whether the request may be retried through the page cache.
+// Direct I/O refuses partial blocks, such as the last of a file. Requests
+// which were in flight when direct I/O was turned off may be retried too.
+func (t *transfer) buffered(f *os.File, err error) bool {
+	if !errors.Is(err, errInvalid) {
+		return false
+	}
+	if was, serr := setDirect(f, false); serr == nil && was {
+		t.cleared[f] = true
+	}
+	return t.cleared[f]
+}
+
+// readWrite copies with positional reads and writes of one buffer.
+func (t *transfer) readWrite() (int64, error) {
+	buf := alignedBuffer(t.bs)
+	for off, retried := int64(0), false; off < t.n; {
+		want := int(min(int64(t.bs), t.n-off))
+		nr, err := t.src.ReadAt(buf[:want], t.srcOff+off)
+		if nr == 0 && err != nil && !retried && t.buffered(t.src, err) {
+			retried = true
+			continue
+		}
+		retried = false
+		for done := 0; done < nr; {
+			nw, werr := t.dst.WriteAt(buf[done:nr], t.dstOff+off+int64(done))
+			done += nw
+			t.wrote(nw)
+			if nw == 0 && werr != nil && !retried && t.buffered(t.dst, werr) {
+				retried = true
+				continue
+			}
+			if werr != nil {
+				return t.total, werr
+			}
+		}
+		off += int64(nr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return t.total, err
+		}
+	}
+	return t.total, nil
+}
+
+// alignedBuffer returns a buffer of size bytes starting at a multiple of
+// Align.
+func alignedBuffer(size int) []byte {
+	b := make([]byte, size+Align)
+	off := Align - int(uintptr(unsafe.Pointer(&b[0]))&(Align-1))
+	if off == Align {
+		off = 0
+	}
+	return b[off : off+size : off+size]
+}
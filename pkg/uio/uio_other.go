@@ -0,0 +1,28 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package uio
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoRing is returned when io_uring cannot be set up, and files are
+// copied without it.
+var errNoRing = errors.New("io_uring not available")
+
+// errInvalid is returned for direct I/O of partial blocks, which is not
+// supported here.
+var errInvalid = errors.ErrUnsupported
+
+func (t *transfer) ring() (int64, error) {
+	return 0, errNoRing
+}
+
+func setDirect(*os.File, bool) (bool, error) {
+	return false, errors.ErrUnsupported
+}
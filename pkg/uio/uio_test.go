@@ -0,0 +1,124 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyAt(t *testing.T) {
+	const bs = 8 * Align
+	for _, tt := range []struct {
+		name   string
+		size   int
+		srcOff int64
+		dstOff int64
+		n      int64
+		want   int64
+	}{
+		{name: "empty", size: 0, n: -1, want: 0},
+		{name: "one byte", size: 1, n: -1, want: 1},
+		{name: "one block", size: bs, n: -1, want: bs},
+		{name: "blocks and a partial one", size: 5*bs + 123, n: -1, want: 5*bs + 123},
+		{name: "more than the depth", size: 40*bs + Align, n: -1, want: 40*bs + Align},
+		{name: "limited", size: 10 * bs, n: 3*bs + 7, want: 3*bs + 7},
+		{name: "offsets", size: 10 * bs, srcOff: Align, dstOff: 2 * Align, n: -1, want: 10*bs - Align},
+		{name: "unaligned offsets", size: 3 * bs, srcOff: 5, dstOff: 3, n: -1, want: 3*bs - 5},
+		{name: "past the end", size: bs, srcOff: 2 * bs, n: -1, want: 0},
+	} {
+		data := make([]byte, tt.size)
+		rand.New(rand.NewSource(int64(tt.size))).Read(data)
+		for _, c := range []struct {
+			name string
+			c    Copier
+		}{
+			{name: "ring", c: Copier{BlockSize: bs, Depth: 4}},
+			{name: "read write", c: Copier{BlockSize: bs, NoRing: true}},
+			{name: "direct ring", c: Copier{BlockSize: bs, Depth: 4, Direct: true}},
+			{name: "direct read write", c: Copier{BlockSize: bs, NoRing: true, Direct: true}},
+		} {
+			t.Run(tt.name+"/"+c.name, func(t *testing.T) {
+				dir := t.TempDir()
+				if err := os.WriteFile(filepath.Join(dir, "src"), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				src, err := os.Open(filepath.Join(dir, "src"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer src.Close()
+				dst, err := os.Create(filepath.Join(dir, "dst"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer dst.Close()
+
+				var written int64
+				c.c.Written = &written
+				n, err := c.c.CopyAt(dst, tt.dstOff, src, tt.srcOff, tt.n)
+				if err != nil || n != tt.want {
+					t.Fatalf("CopyAt = %d, %v, want %d, nil", n, err, tt.want)
+				}
+				if written != tt.want {
+					t.Errorf("Written = %d, want %d", written, tt.want)
+				}
+				got, err := os.ReadFile(dst.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := make([]byte, tt.dstOff+tt.want)
+				if tt.want > 0 {
+					copy(want[tt.dstOff:], data[tt.srcOff:tt.srcOff+tt.want])
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("dst differs from src")
+				}
+			})
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("u-root"), 100000)
+	if err := os.WriteFile(filepath.Join(dir, "src"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if _, err := src.Seek(6, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Write([]byte("header")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Copy(dst, src)
+	if err != nil || n != int64(len(data)-6) {
+		t.Fatalf("Copy = %d, %v, want %d, nil", n, err, len(data)-6)
+	}
+	if off, _ := dst.Seek(0, 1); off != int64(len(data)) {
+		t.Errorf("dst offset = %d, want %d", off, len(data))
+	}
+	got, err := os.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte("header"), data[6:]...); !bytes.Equal(got, want) {
+		t.Errorf("dst differs from src")
+	}
+}
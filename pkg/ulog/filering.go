@@ -0,0 +1,133 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package ulog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileRing is a Sink which keeps the latest entries in a file shared by
+// all processes, as lines of JSON. When the file would grow past Size, the
+// oldest entries are dropped, down to half of Size.
+type FileRing struct {
+	Path string
+	Size int64
+}
+
+// NewFileRing returns a ring in path of size bytes.
+func NewFileRing(path string, size int64) *FileRing {
+	return &FileRing{Path: path, Size: size}
+}
+
+func ringSink(path string) (Sink, error) {
+	return NewFileRing(path, DefaultRingSize), nil
+}
+
+// lastSeq returns the sequence number of the last entry of f.
+func lastSeq(f *os.File, size int64) uint64 {
+	off := size - 64*1024
+	if off < 0 {
+		off = 0
+	}
+	b := make([]byte, size-off)
+	if _, err := f.ReadAt(b, off); err != nil && err != io.EOF {
+		return 0
+	}
+	b = bytes.TrimRight(b, "\n")
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		b = b[i+1:]
+	}
+	var e Entry
+	if json.Unmarshal(b, &e) != nil {
+		return 0
+	}
+	return e.Seq
+}
+
+// Log implements Sink.
+func (r *FileRing) Log(e *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return err
+	}
+	defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	c := *e
+	c.Seq = lastSeq(f, fi.Size()) + 1
+	line, err := json.Marshal(&c)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if fi.Size()+int64(len(line)) <= r.Size {
+		_, err = f.Write(line)
+		return err
+	}
+
+	// Drop the oldest entries.
+	b, err := io.ReadAll(io.NewSectionReader(f, 0, fi.Size()))
+	if err != nil {
+		return err
+	}
+	b = append(b, line...)
+	for int64(len(b)) > r.Size/2 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 || i == len(b)-1 {
+			// Keep the new entry, however long.
+			break
+		}
+		b = b[i+1:]
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	return err
+}
+
+// ReadRing returns the entries of a ring file, oldest first. Lines which
+// are not entries are skipped.
+func ReadRing(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+		return nil, err
+	}
+	defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
+
+	var entries []Entry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var e Entry
+		if json.Unmarshal(s.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, s.Err()
+}
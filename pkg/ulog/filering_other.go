@@ -0,0 +1,13 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+
+package ulog
+
+import "fmt"
+
+func ringSink(string) (Sink, error) {
+	return nil, fmt.Errorf("%w: ring files need flock", ErrUnknownSink)
+}
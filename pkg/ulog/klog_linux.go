@@ -62,6 +62,19 @@ func (k *KLog) Printf(format string, v ...interface{}) {
 	}
 }
 
+// Log implements Sink, printing entries with their level.
+func (k *KLog) Log(e *Entry) error {
+	if k.File == nil {
+		return fmt.Errorf("/dev/kmsg: %w", os.ErrNotExist)
+	}
+	_, err := k.File.WriteString(fmt.Sprintf("<%d>%s: %s", e.Level, e.Tag, e.Text()))
+	return err
+}
+
+func kmsgSink() (Sink, error) {
+	return KernelLog, nil
+}
+
 // KLogLevel are the log levels used by printk.
 type KLogLevel uintptr
 
@@ -7,5 +7,11 @@
 
 package ulog
 
+import "fmt"
+
 // KernelLog prints to stderr log on non-Linux systems.
 var KernelLog = Log
+
+func kmsgSink() (Sink, error) {
+	return nil, fmt.Errorf("%w: kmsg is only on Linux", ErrUnknownSink)
+}
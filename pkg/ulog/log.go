@@ -8,6 +8,12 @@
 // library "log" package Logger, a kernel syslog (dmesg) Logger, and a test
 // Logger that logs via a test's testing.TB.Logf.
 // To use the test logger import "ulog/ulogtest".
+//
+// Structured is a leveled logger of entries with a tag and attributes,
+// which go to sinks: stderr, an in-memory ring, a ring file in /run that
+// the logs command reads, the kernel log, or a local or remote syslog
+// daemon. The sinks of New are set with the ULOG environment variable,
+// e.g. ULOG=stderr,ring,udp://10.0.0.1, and the level with ULOG_LEVEL.
 package ulog
 
 import (
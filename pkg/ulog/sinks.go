@@ -0,0 +1,90 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ulog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ErrUnknownSink is returned for sinks ParseSinks does not know.
+var ErrUnknownSink = errors.New("unknown log sink")
+
+const (
+	// DefaultRingFile is the ring file commands log to by default. /run
+	// is a tmpfs, so the ring is in memory, and outlives the console
+	// output of early boot.
+	DefaultRingFile = "/run/ulog/log"
+	// DefaultRingSize is the default size of a ring file, in bytes.
+	DefaultRingSize = 1 << 20
+	// DefaultSinkSpec is the sink spec when ULOG is not set.
+	DefaultSinkSpec = "stderr,ring"
+)
+
+// ParseSinks parses a comma separated list of sinks:
+//
+//	stderr           lines on stderr
+//	ring             the ring file DefaultRingFile
+//	ring:PATH        a ring file
+//	kmsg             the kernel log
+//	syslog           the local syslog daemon, on /dev/log
+//	udp://HOST[:PORT] a remote syslog daemon, on port 514 by default
+func ParseSinks(spec string) ([]Sink, error) {
+	var sinks []Sink
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		var sink Sink
+		var err error
+		switch {
+		case s == "":
+			continue
+		case s == "stderr":
+			sink = &TextSink{W: os.Stderr}
+		case s == "ring":
+			sink, err = ringSink(DefaultRingFile)
+		case strings.HasPrefix(s, "ring:"):
+			sink, err = ringSink(strings.TrimPrefix(s, "ring:"))
+		case s == "kmsg":
+			sink, err = kmsgSink()
+		case s == "syslog":
+			sink = NewSyslog("unixgram", "/dev/log")
+		case strings.HasPrefix(s, "udp://"):
+			addr := strings.TrimPrefix(s, "udp://")
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(strings.Trim(addr, "[]"), "514")
+			}
+			sink = NewSyslog("udp", addr)
+		default:
+			err = fmt.Errorf("%w: %q", ErrUnknownSink, s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// DefaultSinks returns the sinks of the ULOG environment variable, or of
+// DefaultSinkSpec. Unknown sinks are reported on stderr, and left out.
+func DefaultSinks() []Sink {
+	spec, ok := os.LookupEnv("ULOG")
+	if !ok {
+		spec = DefaultSinkSpec
+	}
+	var sinks []Sink
+	for _, s := range strings.Split(spec, ",") {
+		sink, err := ParseSinks(s)
+		if err != nil {
+			Log.Printf("ULOG: %v", err)
+			continue
+		}
+		sinks = append(sinks, sink...)
+	}
+	return sinks
+}
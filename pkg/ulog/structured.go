@@ -0,0 +1,301 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ulog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBadLevel is returned for unknown level names.
+var ErrBadLevel = errors.New("unknown log level")
+
+// Level is the severity of an entry. Levels are syslog severities: lower
+// levels are more severe.
+type Level int
+
+// These are the levels of entries, with the values of syslog(3).
+const (
+	LevelError   Level = 3
+	LevelWarning Level = 4
+	LevelNotice  Level = 5
+	LevelInfo    Level = 6
+	LevelDebug   Level = 7
+)
+
+var levelNames = map[Level]string{
+	LevelError:   "error",
+	LevelWarning: "warning",
+	LevelNotice:  "notice",
+	LevelInfo:    "info",
+	LevelDebug:   "debug",
+}
+
+// String implements fmt.Stringer.
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("level%d", int(l))
+}
+
+// ParseLevel parses a level name, such as "info", or a syslog severity.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	var l Level
+	if _, err := fmt.Sscanf(s, "%d", &l); err == nil && l >= 0 && l <= LevelDebug {
+		return l, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrBadLevel, s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(b []byte) error {
+	p, err := ParseLevel(strings.TrimPrefix(string(b), "level"))
+	if err != nil {
+		return err
+	}
+	*l = p
+	return nil
+}
+
+// Attr is a key and value of an entry.
+type Attr struct {
+	Key   string `json:"k"`
+	Value string `json:"v"`
+}
+
+// Entry is a structured log message.
+type Entry struct {
+	// Seq numbers entries in a ring, starting at 1.
+	Seq     uint64    `json:"seq,omitempty"`
+	Time    time.Time `json:"time"`
+	Level   Level     `json:"level"`
+	Tag     string    `json:"tag"`
+	Message string    `json:"msg"`
+	Attrs   []Attr    `json:"attrs,omitempty"`
+}
+
+// Text returns the message with its attributes, as key=value pairs.
+// Values with spaces or quotes are quoted.
+func (e *Entry) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, a := range e.Attrs {
+		v := a.Value
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", a.Key, v)
+	}
+	return b.String()
+}
+
+// String returns the entry as a line, without the newline.
+func (e *Entry) String() string {
+	return fmt.Sprintf("%s %-7s %s: %s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.Level, e.Tag, e.Text())
+}
+
+// Sink is where a Structured logger puts entries.
+type Sink interface {
+	Log(e *Entry) error
+}
+
+// TextSink writes entries as lines to W.
+type TextSink struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Log implements Sink.
+func (t *TextSink) Log(e *Entry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := fmt.Fprintln(t.W, e.String())
+	return err
+}
+
+// Ring is a Sink which keeps the latest entries in memory.
+type Ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	// next is the position of the next entry.
+	next int
+	seq  uint64
+}
+
+// NewRing returns a Ring of n entries.
+func NewRing(n int) *Ring {
+	return &Ring{entries: make([]Entry, 0, n)}
+}
+
+// Log implements Sink.
+func (r *Ring) Log(e *Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cap(r.entries) == 0 {
+		return nil
+	}
+	r.seq++
+	c := *e
+	c.Seq = r.seq
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, c)
+	} else {
+		r.entries[r.next] = c
+	}
+	r.next = (r.next + 1) % cap(r.entries)
+	return nil
+}
+
+// Entries returns the entries in the ring, oldest first.
+func (r *Ring) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < cap(r.entries) {
+		return append([]Entry(nil), r.entries...)
+	}
+	return append(append([]Entry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Structured is a leveled logger of entries with a tag, usually the name
+// of the command, and attributes. Entries below its level are dropped;
+// the others go to all sinks. Errors of sinks are ignored, so that a
+// missing syslog daemon does not stop a command.
+//
+// Structured implements Logger, logging at LevelInfo.
+type Structured struct {
+	tag   string
+	level *atomic.Int64
+	sinks []Sink
+	attrs []Attr
+	now   func() time.Time
+}
+
+// New returns a logger with a tag, which logs to sinks, or to the sinks
+// from the ULOG environment variable if none are given (see ParseSinks).
+// The tag defaults to the name of the command. The level is from
+// ULOG_LEVEL, and defaults to LevelInfo.
+func New(tag string, sinks ...Sink) *Structured {
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	if len(sinks) == 0 {
+		sinks = DefaultSinks()
+	}
+	level := LevelInfo
+	if l, err := ParseLevel(os.Getenv("ULOG_LEVEL")); err == nil {
+		level = l
+	}
+	s := &Structured{tag: tag, level: &atomic.Int64{}, sinks: sinks, now: time.Now}
+	s.level.Store(int64(level))
+	return s
+}
+
+// SetLevel sets the least severe level to log, for the logger and all
+// loggers derived from it.
+func (s *Structured) SetLevel(l Level) {
+	s.level.Store(int64(l))
+}
+
+// Enabled returns whether entries of a level are logged.
+func (s *Structured) Enabled(l Level) bool {
+	return int64(l) <= s.level.Load()
+}
+
+// With returns a logger which adds attributes to all entries, given as
+// key and value pairs. Values are formatted with fmt.Sprint.
+func (s *Structured) With(kv ...interface{}) *Structured {
+	n := *s
+	n.attrs = appendAttrs(append([]Attr(nil), s.attrs...), kv)
+	return &n
+}
+
+// WithTag returns a logger with another tag.
+func (s *Structured) WithTag(tag string) *Structured {
+	n := *s
+	n.tag = tag
+	return &n
+}
+
+func appendAttrs(attrs []Attr, kv []interface{}) []Attr {
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			attrs = append(attrs, Attr{Key: "!BADKEY", Value: fmt.Sprint(kv[i])})
+			break
+		}
+		attrs = append(attrs, Attr{Key: fmt.Sprint(kv[i]), Value: fmt.Sprint(kv[i+1])})
+	}
+	return attrs
+}
+
+// Log logs a message with attributes, given as key and value pairs.
+func (s *Structured) Log(level Level, msg string, kv ...interface{}) {
+	if !s.Enabled(level) {
+		return
+	}
+	e := &Entry{
+		Time:    s.now(),
+		Level:   level,
+		Tag:     s.tag,
+		Message: msg,
+		Attrs:   appendAttrs(append([]Attr(nil), s.attrs...), kv),
+	}
+	for _, sink := range s.sinks {
+		_ = sink.Log(e)
+	}
+}
+
+// Debugf logs at LevelDebug.
+func (s *Structured) Debugf(format string, v ...interface{}) {
+	s.logf(LevelDebug, format, v...)
+}
+
+// Infof logs at LevelInfo.
+func (s *Structured) Infof(format string, v ...interface{}) {
+	s.logf(LevelInfo, format, v...)
+}
+
+// Noticef logs at LevelNotice.
+func (s *Structured) Noticef(format string, v ...interface{}) {
+	s.logf(LevelNotice, format, v...)
+}
+
+// Warningf logs at LevelWarning.
+func (s *Structured) Warningf(format string, v ...interface{}) {
+	s.logf(LevelWarning, format, v...)
+}
+
+// Errorf logs at LevelError.
+func (s *Structured) Errorf(format string, v ...interface{}) {
+	s.logf(LevelError, format, v...)
+}
+
+// Printf implements Logger.
+func (s *Structured) Printf(format string, v ...interface{}) {
+	s.logf(LevelInfo, format, v...)
+}
+
+func (s *Structured) logf(level Level, format string, v ...interface{}) {
+	if s.Enabled(level) {
+		s.Log(level, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+	}
+}
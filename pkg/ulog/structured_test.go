@@ -0,0 +1,191 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+
+package ulog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want Level
+		err  error
+	}{
+		{s: "info", want: LevelInfo},
+		{s: "WARNING", want: LevelWarning},
+		{s: "3", want: LevelError},
+		{s: "0", want: 0},
+		{s: "8", err: ErrBadLevel},
+		{s: "loud", err: ErrBadLevel},
+	} {
+		got, err := ParseLevel(tt.s)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v, %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func testLogger(sinks ...Sink) *Structured {
+	l := New("test", sinks...)
+	l.SetLevel(LevelInfo)
+	l.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return l
+}
+
+func TestStructured(t *testing.T) {
+	r := NewRing(10)
+	var b bytes.Buffer
+	l := testLogger(r, &TextSink{W: &b})
+
+	l.Debugf("hidden")
+	l.Infof("hello %s\n", "world")
+	l.With("iface", "eth0", "tries", 3).Warningf("no lease")
+	l.WithTag("dhcp").Log(LevelError, "failed", "err", "timed out", "odd")
+	l.SetLevel(LevelDebug)
+	l.Debugf("shown")
+	var _ Logger = l
+
+	want := []Entry{
+		{Seq: 1, Level: LevelInfo, Tag: "test", Message: "hello world"},
+		{Seq: 2, Level: LevelWarning, Tag: "test", Message: "no lease", Attrs: []Attr{{"iface", "eth0"}, {"tries", "3"}}},
+		{Seq: 3, Level: LevelError, Tag: "dhcp", Message: "failed", Attrs: []Attr{{"err", "timed out"}, {"!BADKEY", "odd"}}},
+		{Seq: 4, Level: LevelDebug, Tag: "test", Message: "shown"},
+	}
+	got := r.Entries()
+	for i := range got {
+		got[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+
+	wantText := `2024-01-02T03:04:05.000Z info    test: hello world
+2024-01-02T03:04:05.000Z warning test: no lease iface=eth0 tries=3
+2024-01-02T03:04:05.000Z error   dhcp: failed err="timed out" !BADKEY=odd
+2024-01-02T03:04:05.000Z debug   test: shown
+`
+	if b.String() != wantText {
+		t.Errorf("text = %q, want %q", b.String(), wantText)
+	}
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(3)
+	for i := 1; i <= 5; i++ {
+		r.Log(&Entry{Message: fmt.Sprint(i)})
+	}
+	var got []string
+	for _, e := range r.Entries() {
+		got = append(got, fmt.Sprintf("%d:%s", e.Seq, e.Message))
+	}
+	if strings.Join(got, " ") != "3:3 4:4 5:5" {
+		t.Errorf("entries = %v, want the last 3", got)
+	}
+}
+
+func TestFileRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ulog", "log")
+	r := NewFileRing(path, 4096)
+	l := testLogger(r)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				l.With("g", g).Infof("entry %d", i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	entries, err := ReadRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) >= 100 {
+		t.Fatalf("got %d entries, want some of the 100 dropped", len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(100-len(entries)+i+1) {
+			t.Fatalf("entry %d has seq %d, want the last %d of 100 in order", i, e.Seq, len(entries))
+		}
+	}
+	if e := entries[0]; e.Level != LevelInfo || e.Tag != "test" || !e.Time.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || e.Attrs[0].Key != "g" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	if _, err := ReadRing(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Errorf("ReadRing of a missing file succeeded")
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	sinks, err := ParseSinks("udp://" + c.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := testLogger(sinks...)
+	l.With("path", `C:\a "b"`).Noticef("boot done")
+
+	b := make([]byte, 1024)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := c.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^<13>1 2024-01-02T03:04:05.000000Z \S+ test \d+ - \[ulog@32473 path="C:\\\\a \\"b\\""\] boot done$`)
+	if !re.Match(b[:n]) {
+		t.Errorf("message = %q, want it to match %s", b[:n], re)
+	}
+}
+
+func TestParseSinks(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		want []Sink
+		err  error
+	}{
+		{spec: ""},
+		{spec: "stderr", want: []Sink{&TextSink{}}},
+		{spec: "ring, ring:/tmp/log", want: []Sink{NewFileRing(DefaultRingFile, DefaultRingSize), NewFileRing("/tmp/log", DefaultRingSize)}},
+		{spec: "syslog", want: []Sink{NewSyslog("unixgram", "/dev/log")}},
+		{spec: "udp://10.0.0.1,udp://[fd00::1]:1514", want: []Sink{NewSyslog("udp", "10.0.0.1:514"), NewSyslog("udp", "[fd00::1]:1514")}},
+		{spec: "stderr,console", err: ErrUnknownSink},
+	} {
+		got, err := ParseSinks(tt.spec)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseSinks(%q) = %v, want %v", tt.spec, err, tt.err)
+			continue
+		}
+		for _, s := range got {
+			if ts, ok := s.(*TextSink); ok {
+				ts.W = nil
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSinks(%q) = %#v, want %#v", tt.spec, got, tt.want)
+		}
+	}
+}
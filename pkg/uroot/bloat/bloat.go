@@ -0,0 +1,204 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bloat reports how much code each command and package adds to a
+// busybox binary, to find what to leave out of images for small flash
+// parts.
+//
+// Sizes are the size of the machine code of the functions of a package,
+// read from the Go line table, which stripped binaries keep. Data, such as
+// tables and strings, is not counted, so the sizes are a lower bound.
+package bloat
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/u-root/gobusybox/src/pkg/golang"
+)
+
+// ErrNoLineTable is returned for binaries without a Go line table.
+var ErrNoLineTable = errors.New("no Go line table")
+
+// Sizes returns the code size of each package of an ELF Go binary.
+func Sizes(r io.ReaderAt) (map[string]uint64, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	pcln, text := f.Section(".gopclntab"), f.Section(".text")
+	if pcln == nil || text == nil {
+		return nil, ErrNoLineTable
+	}
+	b, err := pcln.Data()
+	if err != nil {
+		return nil, err
+	}
+	t, err := gosym.NewTable(nil, gosym.NewLineTable(b, text.Addr))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoLineTable, err)
+	}
+	sizes := map[string]uint64{}
+	for _, fn := range t.Funcs {
+		sizes[fn.PackageName()] += fn.End - fn.Entry
+	}
+	return sizes, nil
+}
+
+// Deps returns the packages each command imports, directly or not,
+// including the command itself.
+func Deps(env *golang.Environ, cmds ...string) (map[string][]string, error) {
+	args := []string{"-deps", "-f", "{{.ImportPath}} {{join .Imports \" \"}}"}
+	if len(env.Context.BuildTags) > 0 {
+		args = append(args, "-tags="+strings.Join(env.Context.BuildTags, ","))
+	}
+	if env.GO111MODULE != "off" && len(env.Mod) > 0 {
+		args = append(args, "-mod", string(env.Mod))
+	}
+	cmd := env.GoCmd("list", append(args, cmds...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	imports := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		f := strings.Fields(line)
+		if len(f) > 0 {
+			imports[f[0]] = f[1:]
+		}
+	}
+	deps := map[string][]string{}
+	for _, c := range cmds {
+		seen := map[string]bool{}
+		var visit func(p string)
+		visit = func(p string) {
+			if seen[p] {
+				return
+			}
+			seen[p] = true
+			for _, i := range imports[p] {
+				visit(i)
+			}
+		}
+		visit(c)
+		var list []string
+		for d := range seen {
+			list = append(list, d)
+		}
+		sort.Strings(list)
+		deps[c] = list
+	}
+	return deps, nil
+}
+
+// Command is the size a command adds to a binary.
+type Command struct {
+	// Package is the package of the command.
+	Package string
+	// Size is the size of the package of the command.
+	Size uint64
+	// Exclusive is the size of the packages no other command uses,
+	// including its own: what leaving the command out saves.
+	Exclusive uint64
+}
+
+// Package is the size of a package in a binary.
+type Package struct {
+	Path string
+	Size uint64
+	// Commands are the commands which import the package.
+	Commands []string
+}
+
+// Report is the size of the commands and packages of a binary.
+type Report struct {
+	// Total is the code size of the binary.
+	Total    uint64
+	Commands []Command
+	Packages []Package
+}
+
+// Analyze returns the report for a binary with package sizes, built from
+// commands with deps, as returned by Deps. Commands and packages are
+// sorted by size, largest first.
+func Analyze(sizes map[string]uint64, deps map[string][]string) *Report {
+	users := map[string][]string{}
+	for cmd, ds := range deps {
+		for _, d := range ds {
+			users[d] = append(users[d], cmd)
+		}
+	}
+
+	r := &Report{}
+	for p, size := range sizes {
+		r.Total += size
+		cmds := users[p]
+		sort.Strings(cmds)
+		r.Packages = append(r.Packages, Package{Path: p, Size: size, Commands: cmds})
+	}
+	for cmd, ds := range deps {
+		c := Command{Package: cmd, Size: sizes[cmd]}
+		for _, d := range ds {
+			if len(users[d]) == 1 {
+				c.Exclusive += sizes[d]
+			}
+		}
+		r.Commands = append(r.Commands, c)
+	}
+	sort.Slice(r.Commands, func(i, j int) bool {
+		a, b := r.Commands[i], r.Commands[j]
+		if a.Exclusive != b.Exclusive {
+			return a.Exclusive > b.Exclusive
+		}
+		return a.Package < b.Package
+	})
+	sort.Slice(r.Packages, func(i, j int) bool {
+		a, b := r.Packages[i], r.Packages[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+	return r
+}
+
+// Write writes the report as tables of commands and of the largest
+// packages. A limit of 0 writes all packages.
+func (r *Report) Write(w io.Writer, limit int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "EXCLUSIVE\tOWN\tCOMMAND\n")
+	for _, c := range r.Commands {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", humanize.IBytes(c.Exclusive), humanize.IBytes(c.Size), c.Package)
+	}
+	fmt.Fprintf(tw, "\nSIZE\tUSERS\tPACKAGE\n")
+	for i, p := range r.Packages {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(tw, "...\t\t%d more packages\n", len(r.Packages)-limit)
+			break
+		}
+		users := "-"
+		switch len(p.Commands) {
+		case 0:
+		case 1:
+			users = path.Base(p.Commands[0])
+		default:
+			users = fmt.Sprint(len(p.Commands))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", humanize.IBytes(p.Size), users, p.Path)
+	}
+	fmt.Fprintf(tw, "%s\t\ttotal\n", humanize.IBytes(r.Total))
+	return tw.Flush()
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bloat
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/u-root/gobusybox/src/pkg/golang"
+)
+
+func TestSizes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test binaries are only ELF on Linux")
+	}
+	f, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sizes, err := Sizes(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"runtime", "testing", "github.com/u-root/u-root/pkg/uroot/bloat"} {
+		if sizes[p] == 0 {
+			t.Errorf("size of %s is 0", p)
+		}
+	}
+	if sizes["runtime"] < sizes["github.com/u-root/u-root/pkg/uroot/bloat"] {
+		t.Errorf("runtime (%d bytes) is smaller than bloat (%d bytes)", sizes["runtime"], sizes["github.com/u-root/u-root/pkg/uroot/bloat"])
+	}
+
+	if _, err := Sizes(strings.NewReader("not an ELF file")); err == nil {
+		t.Errorf("Sizes of a text file succeeded")
+	}
+}
+
+func TestNoLineTable(t *testing.T) {
+	// A minimal 64-bit little endian ELF header, without sections.
+	hdr := make([]byte, 64)
+	copy(hdr, "\x7fELF\x02\x01\x01")
+	hdr[16], hdr[18], hdr[20] = 2, 62, 1
+	hdr[52] = 64
+	if _, err := Sizes(bytes.NewReader(hdr)); !errors.Is(err, ErrNoLineTable) {
+		t.Errorf("Sizes = %v, want ErrNoLineTable", err)
+	}
+}
+
+func TestDeps(t *testing.T) {
+	const self = "github.com/u-root/u-root/pkg/uroot/bloat"
+	deps, err := Deps(golang.Default(), self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{self, "debug/elf", "github.com/dustin/go-humanize", "runtime"} {
+		if !slices.Contains(deps[self], want) {
+			t.Errorf("deps of bloat = %v, want %s in them", deps[self], want)
+		}
+	}
+
+	if _, err := Deps(golang.Default(), "github.com/u-root/u-root/cmds/core/nonexistent"); err == nil {
+		t.Errorf("Deps of a missing package succeeded")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	sizes := map[string]uint64{
+		"runtime":    1000,
+		"cmds/ls":    10,
+		"cmds/sshd":  50,
+		"cmds/scp":   30,
+		"pkg/ls":     20,
+		"crypto/ssh": 400,
+		"pkg/sftp":   100,
+		"unused/pkg": 5,
+	}
+	deps := map[string][]string{
+		"cmds/ls":   {"cmds/ls", "pkg/ls", "runtime"},
+		"cmds/sshd": {"cmds/sshd", "crypto/ssh", "pkg/sftp", "runtime"},
+		"cmds/scp":  {"cmds/scp", "crypto/ssh", "pkg/sftp", "runtime"},
+	}
+	r := Analyze(sizes, deps)
+	if r.Total != 1615 {
+		t.Errorf("Total = %d, want 1615", r.Total)
+	}
+	want := []Command{
+		{Package: "cmds/sshd", Size: 50, Exclusive: 50},
+		{Package: "cmds/ls", Size: 10, Exclusive: 30},
+		{Package: "cmds/scp", Size: 30, Exclusive: 30},
+	}
+	if !reflect.DeepEqual(r.Commands, want) {
+		t.Errorf("Commands = %+v, want %+v", r.Commands, want)
+	}
+	if p := r.Packages[1]; p.Path != "crypto/ssh" || !reflect.DeepEqual(p.Commands, []string{"cmds/scp", "cmds/sshd"}) {
+		t.Errorf("second largest package = %+v, want crypto/ssh used by scp and sshd", p)
+	}
+	if p := r.Packages[len(r.Packages)-1]; p.Path != "unused/pkg" || p.Commands != nil {
+		t.Errorf("smallest package = %+v, want unused/pkg used by no command", p)
+	}
+
+	var b bytes.Buffer
+	if err := r.Write(&b, 3); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, l := range strings.Split(b.String(), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(l), " "))
+	}
+	got := strings.Join(lines, "\n")
+	for _, s := range []string{"50 B 50 B cmds/sshd", "400 B 2 crypto/ssh", "... 5 more packages", "1.6 KiB total"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("report does not contain %q:\n%s", s, b.String())
+		}
+	}
+	b.Reset()
+	if err := r.Write(&b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "pkg/ls") || strings.Contains(b.String(), "more packages") {
+		t.Errorf("report without a limit does not contain all packages:\n%s", b.String())
+	}
+}
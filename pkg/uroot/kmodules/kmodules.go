@@ -0,0 +1,389 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kmodules adds a tree of Linux kernel modules to an initramfs, as
+// lib/modules/RELEASE, along with the modules.dep, modules.alias and
+// modules.builtin indexes modprobe reads. The indexes are generated from
+// the .modinfo sections of the modules, as depmod would.
+//
+// To keep images small, the modules may be limited to those whose aliases
+// match the modaliases of the target hardware, as listed by
+//
+//	cat /sys/bus/*/devices/*/modalias
+//
+// and to those named, along with the modules they depend on. The modules
+// may be recompressed with xz, zstd or gzip, or decompressed.
+package kmodules
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/u-root/mkuimage/cpio"
+	"github.com/u-root/u-root/pkg/compress"
+)
+
+var (
+	// ErrNoModule is returned for modules which are not in the tree.
+	ErrNoModule = errors.New("no such module")
+
+	// ErrModInfo is returned for modules without usable module info.
+	ErrModInfo = errors.New("bad module info")
+)
+
+// extensions are the file extensions of modules in each format modprobe
+// reads.
+var extensions = map[string]string{
+	compress.None: ".ko",
+	compress.Gzip: ".ko.gz",
+	compress.XZ:   ".ko.xz",
+	compress.Zstd: ".ko.zst",
+}
+
+// Module is a kernel module of a Tree.
+type Module struct {
+	// Name is the name of the module, with underscores for dashes, as
+	// the kernel has it.
+	Name string
+	// Path is the path of the module file in the tree.
+	Path string
+	// Depends are the names of the modules it needs.
+	Depends []string
+	// Aliases are the modalias patterns of the devices it drives, and
+	// other names it is loaded by.
+	Aliases []string
+}
+
+// Tree is a directory of kernel modules, such as /lib/modules/RELEASE.
+type Tree struct {
+	Dir string
+	// Release is the kernel release the modules are for.
+	Release string
+	// Modules are the modules by name.
+	Modules map[string]*Module
+	// Builtin are the names of the modules built into the kernel.
+	Builtin map[string]bool
+}
+
+// Name returns the name of a module from its file name, or "".
+func Name(file string) string {
+	base := path.Base(filepath.ToSlash(file))
+	for _, ext := range extensions {
+		if name, ok := strings.CutSuffix(base, ext); ok {
+			return strings.ReplaceAll(name, "-", "_")
+		}
+	}
+	return ""
+}
+
+// ModInfo returns the module info of the module in r, which may be
+// compressed, as key=value pairs.
+func ModInfo(r io.Reader) (map[string][]string, error) {
+	zr, _, err := compress.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrModInfo, err)
+	}
+	s := f.Section(".modinfo")
+	if s == nil {
+		return nil, fmt.Errorf("%w: no .modinfo section", ErrModInfo)
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrModInfo, err)
+	}
+	info := map[string][]string{}
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		k, v, ok := strings.Cut(string(kv), "=")
+		if ok {
+			info[k] = append(info[k], v)
+		}
+	}
+	return info, nil
+}
+
+// Load reads the modules of the tree in dir, whose base name is the kernel
+// release.
+func Load(dir string) (*Tree, error) {
+	t := &Tree{Dir: dir, Release: filepath.Base(dir), Modules: map[string]*Module{}, Builtin: map[string]bool{}}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := Name(p)
+		if d.IsDir() || name == "" {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := ModInfo(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if n := info["name"]; len(n) > 0 && n[0] != "" {
+			name = n[0]
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		m := &Module{Name: name, Path: filepath.ToSlash(rel), Aliases: info["alias"]}
+		for _, d := range info["depends"] {
+			for _, dep := range strings.Split(d, ",") {
+				if dep != "" {
+					m.Depends = append(m.Depends, strings.ReplaceAll(dep, "-", "_"))
+				}
+			}
+		}
+		t.Modules[name] = m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "modules.builtin"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if name := Name(strings.TrimSpace(line)); name != "" {
+			t.Builtin[name] = true
+		}
+	}
+	return t, nil
+}
+
+// ReadModaliases returns the modaliases listed in the file name, one per
+// line. Empty lines and lines starting with # are skipped.
+func ReadModaliases(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var aliases []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			aliases = append(aliases, line)
+		}
+	}
+	return aliases, s.Err()
+}
+
+// lookup returns the module called name, or the one with the alias name.
+func (t *Tree) lookup(name string) *Module {
+	if m, ok := t.Modules[strings.ReplaceAll(name, "-", "_")]; ok {
+		return m
+	}
+	for _, m := range t.sorted() {
+		for _, a := range m.Aliases {
+			if a == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// sorted returns the modules of t sorted by path.
+func (t *Tree) sorted() []*Module {
+	mods := make([]*Module, 0, len(t.Modules))
+	for _, m := range t.Modules {
+		mods = append(mods, m)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	return mods
+}
+
+// Select returns the modules with an alias matching one of modaliases, and
+// those named by names, which may be names or aliases, with the modules
+// they depend on, sorted by path. With neither, all modules are returned.
+func (t *Tree) Select(modaliases, names []string) ([]*Module, error) {
+	if len(modaliases) == 0 && len(names) == 0 {
+		return t.sorted(), nil
+	}
+	selected := map[string]bool{}
+	var add func(m *Module) error
+	add = func(m *Module) error {
+		if selected[m.Name] {
+			return nil
+		}
+		selected[m.Name] = true
+		for _, d := range m.Depends {
+			dep, ok := t.Modules[d]
+			if !ok {
+				if t.Builtin[d] {
+					continue
+				}
+				return fmt.Errorf("%w: %s, which %s depends on", ErrNoModule, d, m.Name)
+			}
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, n := range names {
+		m := t.lookup(n)
+		if m == nil {
+			if t.Builtin[strings.ReplaceAll(n, "-", "_")] {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s", ErrNoModule, n)
+		}
+		if err := add(m); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range t.sorted() {
+		if matches(m.Aliases, modaliases) {
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var mods []*Module
+	for _, m := range t.sorted() {
+		if selected[m.Name] {
+			mods = append(mods, m)
+		}
+	}
+	return mods, nil
+}
+
+// matches reports whether one of the alias patterns matches one of the
+// modaliases.
+func matches(patterns, modaliases []string) bool {
+	for _, p := range patterns {
+		for _, a := range modaliases {
+			if ok, _ := path.Match(p, a); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// outPath returns the path of the module file at p in format, or p as is
+// for the format "".
+func outPath(p, format string) string {
+	if format == "" {
+		return p
+	}
+	for _, ext := range extensions {
+		if base, ok := strings.CutSuffix(p, ext); ok {
+			return base + extensions[format]
+		}
+	}
+	return p
+}
+
+// recompress returns the module at name in format.
+func recompress(name, format string, level int) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if format == "" {
+		return io.ReadAll(f)
+	}
+	zr, _, err := compress.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var b bytes.Buffer
+	zw, err := compress.NewWriter(&b, format, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(zw, zr); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// deps returns the paths of the modules m depends on, directly or not, in
+// the order they must be loaded, last first, as modules.dep lists them.
+func (t *Tree) deps(m *Module, format string) []string {
+	var order []string
+	seen := map[string]bool{m.Name: true}
+	var visit func(m *Module)
+	visit = func(m *Module) {
+		for _, d := range m.Depends {
+			dep, ok := t.Modules[d]
+			if !ok || seen[d] {
+				continue
+			}
+			seen[d] = true
+			order = append(order, outPath(dep.Path, format))
+			visit(dep)
+		}
+	}
+	visit(m)
+	return order
+}
+
+// Records returns the records of the files of mods and of the indexes of
+// lib/modules/RELEASE. format is the format the modules are compressed
+// with, one of none, gzip, xz or zstd, or "" to keep them as they are.
+func (t *Tree) Records(mods []*Module, format string, level int) ([]cpio.Record, error) {
+	if _, ok := extensions[format]; !ok && format != "" {
+		return nil, fmt.Errorf("%w: %q for modules", compress.ErrUnknownFormat, format)
+	}
+	dir := path.Join("lib/modules", t.Release)
+	var records []cpio.Record
+	var dep, alias bytes.Buffer
+	for _, m := range mods {
+		b, err := recompress(filepath.Join(t.Dir, filepath.FromSlash(m.Path)), format, level)
+		if err != nil {
+			return nil, err
+		}
+		p := outPath(m.Path, format)
+		records = append(records, cpio.StaticRecord(b, cpio.Info{Name: path.Join(dir, p), Mode: cpio.S_IFREG | 0o644}))
+		fmt.Fprintf(&dep, "%s:", p)
+		for _, d := range t.deps(m, format) {
+			fmt.Fprintf(&dep, " %s", d)
+		}
+		dep.WriteString("\n")
+		for _, a := range m.Aliases {
+			fmt.Fprintf(&alias, "alias %s %s\n", a, m.Name)
+		}
+	}
+	records = append(records,
+		cpio.StaticFile(path.Join(dir, "modules.dep"), dep.String(), 0o644),
+		cpio.StaticFile(path.Join(dir, "modules.alias"), alias.String(), 0o644))
+	if b, err := os.ReadFile(filepath.Join(t.Dir, "modules.builtin")); err == nil {
+		records = append(records, cpio.StaticRecord(b, cpio.Info{Name: path.Join(dir, "modules.builtin"), Mode: cpio.S_IFREG | 0o644}))
+	}
+	return records, nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kmodules
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/mkuimage/cpio"
+	"github.com/u-root/u-root/pkg/compress"
+)
+
+// module returns a relocatable ELF file with a .modinfo section holding
+// info, as modules have.
+func module(t *testing.T, info ...string) []byte {
+	t.Helper()
+	modinfo := []byte(strings.Join(info, "\x00") + "\x00")
+	shstrtab := []byte("\x00.modinfo\x00.shstrtab\x00")
+	const ehsize, shentsize = 64, 64
+	shoff := ehsize + len(modinfo) + len(shstrtab)
+
+	var b bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(shoff),
+		Ehsize:    ehsize,
+		Shentsize: shentsize,
+		Shnum:     3,
+		Shstrndx:  2,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&b, binary.LittleEndian, hdr)
+	b.Write(modinfo)
+	b.Write(shstrtab)
+	for _, s := range []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Off: ehsize, Size: uint64(len(modinfo)), Addralign: 1},
+		{Name: 10, Type: uint32(elf.SHT_STRTAB), Off: uint64(ehsize + len(modinfo)), Size: uint64(len(shstrtab)), Addralign: 1},
+	} {
+		binary.Write(&b, binary.LittleEndian, s)
+	}
+	return b.Bytes()
+}
+
+func write(t *testing.T, name string, b []byte, format string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w, err := compress.NewWriter(&out, format, compress.DefaultLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(b)
+	w.Close()
+	if err := os.WriteFile(name, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tree returns a module tree for release 6.1.0-test.
+func tree(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "6.1.0-test")
+	write(t, filepath.Join(dir, "kernel/drivers/net/e1000e.ko.xz"), module(t,
+		"alias=pci:v00008086d000010D3sv*sd*bc*sc*i*", "depends=ptp", "name=e1000e"), compress.XZ)
+	write(t, filepath.Join(dir, "kernel/drivers/ptp/ptp.ko.xz"), module(t, "depends=pps_core,crc32c", "name=ptp"), compress.XZ)
+	write(t, filepath.Join(dir, "kernel/drivers/pps/pps_core.ko.xz"), module(t, "depends=", "name=pps_core"), compress.XZ)
+	write(t, filepath.Join(dir, "kernel/drivers/net/igb.ko.zst"), module(t,
+		"alias=pci:v00008086d00001533sv*sd*bc*sc*i*", "depends=ptp,dca", "name=igb"), compress.Zstd)
+	write(t, filepath.Join(dir, "kernel/drivers/dca/dca.ko"), module(t, "name=dca"), compress.None)
+	write(t, filepath.Join(dir, "kernel/fs/vfat.ko.gz"), module(t, "alias=fs-vfat", "depends=fat", "name=vfat"), compress.Gzip)
+	write(t, filepath.Join(dir, "kernel/fs/fat.ko"), module(t, "name=fat"), compress.None)
+	write(t, filepath.Join(dir, "modules.builtin"), []byte("kernel/lib/crc32c.ko\n"), compress.None)
+	write(t, filepath.Join(dir, "modules.order"), []byte("kernel/fs/fat.ko\n"), compress.None)
+	return dir
+}
+
+func names(mods []*Module) []string {
+	var n []string
+	for _, m := range mods {
+		n = append(n, m.Name)
+	}
+	return n
+}
+
+func TestLoad(t *testing.T) {
+	tr, err := Load(tree(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Release != "6.1.0-test" || len(tr.Modules) != 7 || !tr.Builtin["crc32c"] {
+		t.Errorf("Load() = %+v", tr)
+	}
+	want := &Module{Name: "ptp", Path: "kernel/drivers/ptp/ptp.ko.xz", Depends: []string{"pps_core", "crc32c"}}
+	if got := tr.Modules["ptp"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("ptp = %+v, want %+v", got, want)
+	}
+
+	bad := t.TempDir()
+	write(t, filepath.Join(bad, "junk.ko"), []byte("not ELF"), compress.None)
+	if _, err := Load(bad); !errors.Is(err, ErrModInfo) {
+		t.Errorf("Load(junk) = %v, want %v", err, ErrModInfo)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tr, err := Load(tree(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name       string
+		modaliases []string
+		names      []string
+		want       []string
+		err        error
+	}{
+		{name: "all", want: []string{"dca", "e1000e", "igb", "pps_core", "ptp", "fat", "vfat"}},
+		{name: "modalias", modaliases: []string{"pci:v00008086d000010D3sv00008086sd00000000bc02sc00i00"}, want: []string{"e1000e", "pps_core", "ptp"}},
+		{name: "unmatched modalias", modaliases: []string{"usb:v1D6Bp0002d0510dc09dsc00dp01ic09isc00ip00in00"}},
+		{name: "name", names: []string{"igb"}, want: []string{"dca", "igb", "pps_core", "ptp"}},
+		{name: "alias name", names: []string{"fs-vfat"}, want: []string{"fat", "vfat"}},
+		{name: "dashes", names: []string{"pps-core"}, want: []string{"pps_core"}},
+		{name: "builtin", names: []string{"crc32c"}},
+		{name: "unknown", names: []string{"nvidia"}, err: ErrNoModule},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mods, err := tr.Select(tt.modaliases, tt.names)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Select() = %v, want %v", err, tt.err)
+			}
+			if got := names(mods); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	delete(tr.Modules, "pps_core")
+	if _, err := tr.Select(nil, []string{"ptp"}); !errors.Is(err, ErrNoModule) {
+		t.Errorf("Select() with a missing dependency = %v, want %v", err, ErrNoModule)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tr, err := Load(tree(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mods, err := tr.Select(nil, []string{"e1000e", "vfat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Records(mods, "lz4", compress.DefaultLevel); !errors.Is(err, compress.ErrUnknownFormat) {
+		t.Errorf("Records(lz4) = %v, want %v", err, compress.ErrUnknownFormat)
+	}
+	records, err := tr.Records(mods, compress.Zstd, compress.DefaultLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]cpio.Record{}
+	var got []string
+	for _, r := range records {
+		files[r.Name] = r
+		got = append(got, r.Name)
+	}
+	want := []string{
+		"lib/modules/6.1.0-test/kernel/drivers/net/e1000e.ko.zst",
+		"lib/modules/6.1.0-test/kernel/drivers/pps/pps_core.ko.zst",
+		"lib/modules/6.1.0-test/kernel/drivers/ptp/ptp.ko.zst",
+		"lib/modules/6.1.0-test/kernel/fs/fat.ko.zst",
+		"lib/modules/6.1.0-test/kernel/fs/vfat.ko.zst",
+		"lib/modules/6.1.0-test/modules.dep",
+		"lib/modules/6.1.0-test/modules.alias",
+		"lib/modules/6.1.0-test/modules.builtin",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Records() = %q, want %q", got, want)
+	}
+
+	read := func(name string) []byte {
+		t.Helper()
+		b, err := io.ReadAll(io.NewSectionReader(files[name], 0, int64(files[name].FileSize)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if b := read("lib/modules/6.1.0-test/kernel/fs/fat.ko.zst"); compress.Detect(b) != compress.Zstd {
+		t.Errorf("fat.ko.zst is not zstd compressed")
+	}
+	info, err := ModInfo(bytes.NewReader(read("lib/modules/6.1.0-test/kernel/drivers/net/e1000e.ko.zst")))
+	if err != nil || info["name"][0] != "e1000e" {
+		t.Errorf("ModInfo(e1000e.ko.zst) = %v, %v", info, err)
+	}
+	wantDep := `kernel/drivers/net/e1000e.ko.zst: kernel/drivers/ptp/ptp.ko.zst kernel/drivers/pps/pps_core.ko.zst
+kernel/drivers/pps/pps_core.ko.zst:
+kernel/drivers/ptp/ptp.ko.zst: kernel/drivers/pps/pps_core.ko.zst
+kernel/fs/fat.ko.zst:
+kernel/fs/vfat.ko.zst: kernel/fs/fat.ko.zst
+`
+	if got := string(read("lib/modules/6.1.0-test/modules.dep")); got != wantDep {
+		t.Errorf("modules.dep =\n%s\nwant\n%s", got, wantDep)
+	}
+	wantAlias := "alias pci:v00008086d000010D3sv*sd*bc*sc*i* e1000e\nalias fs-vfat vfat\n"
+	if got := string(read("lib/modules/6.1.0-test/modules.alias")); got != wantAlias {
+		t.Errorf("modules.alias =\n%s\nwant\n%s", got, wantAlias)
+	}
+
+	kept, err := tr.Records(mods[:1], "", compress.DefaultLevel)
+	if err != nil || kept[0].Name != "lib/modules/6.1.0-test/kernel/drivers/net/e1000e.ko.xz" {
+		t.Errorf("Records() keeping the format = %v, %v", kept, err)
+	}
+}
+
+func TestReadModaliases(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "modaliases")
+	os.WriteFile(name, []byte("# board\npci:v00008086d000010D3\n\n  acpi:PNP0501:\n"), 0o644)
+	got, err := ReadModaliases(name)
+	if want := []string{"pci:v00008086d000010D3", "acpi:PNP0501:"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadModaliases() = %q, %v, want %q", got, err, want)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package manifest adds files, directories, symlinks and device nodes
+// described in a JSON manifest to an initramfs, with the ownership and
+// modes given, for images which cannot be described with -files alone:
+//
+//	{
+//		"entries": [
+//			{"path": "etc/motd", "source": "motd.txt"},
+//			{"path": "etc/hostname", "contents": "node1\n", "mode": "0644"},
+//			{"path": "home/user", "type": "dir", "mode": "0700", "uid": 1000, "gid": 1000},
+//			{"path": "bin/sh", "type": "symlink", "target": "gosh"},
+//			{"path": "dev/ttyS0", "type": "char", "major": 4, "minor": 64, "mode": "0620", "gid": 5}
+//		]
+//	}
+//
+// Types are file (the default), dir, symlink, char, block and fifo. Files
+// take their contents from a source file, relative to the manifest, or
+// from contents. Modes are octal strings, and default to 0644 for files,
+// devices and fifos, and 0755 for directories. Modification times are
+// zero, so images are reproducible.
+//
+// Entries replace files of the same name in the image, such as files from
+// -files or the base archive.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/mkuimage/cpio"
+	"github.com/u-root/mkuimage/uimage/initramfs"
+)
+
+// ErrBadEntry is returned for entries which are invalid.
+var ErrBadEntry = errors.New("bad manifest entry")
+
+// Entry is a file of the manifest.
+type Entry struct {
+	Path     string  `json:"path"`
+	Type     string  `json:"type,omitempty"`
+	Source   string  `json:"source,omitempty"`
+	Contents *string `json:"contents,omitempty"`
+	Target   string  `json:"target,omitempty"`
+	Mode     string  `json:"mode,omitempty"`
+	UID      uint64  `json:"uid,omitempty"`
+	GID      uint64  `json:"gid,omitempty"`
+	Major    *uint64 `json:"major,omitempty"`
+	Minor    *uint64 `json:"minor,omitempty"`
+}
+
+// Manifest describes files to add to an initramfs.
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+
+	// dir is the directory sources are relative to.
+	dir string
+}
+
+// Parse parses a manifest. Sources are relative to dir.
+func Parse(r io.Reader, dir string) (*Manifest, error) {
+	m := &Manifest{dir: dir}
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Load reads a manifest file.
+func Load(file string) (*Manifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := Parse(f, filepath.Dir(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return m, nil
+}
+
+func (e *Entry) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ErrBadEntry, e.Path, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) mode(def uint64) (uint64, error) {
+	if e.Mode == "" {
+		return def, nil
+	}
+	m, err := strconv.ParseUint(e.Mode, 8, 32)
+	if err != nil || m&^0o7777 != 0 {
+		return 0, e.errorf("mode %q is not an octal permission", e.Mode)
+	}
+	return m, nil
+}
+
+// record returns the cpio record of an entry.
+func (m *Manifest) record(e *Entry) (cpio.Record, error) {
+	var r cpio.Record
+	name := cpio.Normalize(e.Path)
+	if e.Path == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return r, e.errorf("path is outside the image")
+	}
+
+	typ := e.Type
+	if typ == "" {
+		typ = "file"
+	}
+	def := uint64(0o644)
+	if typ == "dir" {
+		def = 0o755
+	}
+	perm, err := e.mode(def)
+	if err != nil {
+		return r, err
+	}
+	if (e.Source != "" || e.Contents != nil) && typ != "file" {
+		return r, e.errorf("a %s has no contents", typ)
+	}
+	if e.Target != "" && typ != "symlink" {
+		return r, e.errorf("a %s has no target", typ)
+	}
+	dev := typ == "char" || typ == "block"
+	if dev && (e.Major == nil || e.Minor == nil) {
+		return r, e.errorf("a %s needs a major and minor number", typ)
+	}
+	if !dev && (e.Major != nil || e.Minor != nil) {
+		return r, e.errorf("a %s has no major and minor number", typ)
+	}
+
+	switch typ {
+	case "file":
+		var b []byte
+		switch {
+		case e.Source != "" && e.Contents != nil:
+			return r, e.errorf("both source and contents given")
+		case e.Source != "":
+			src := e.Source
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(m.dir, src)
+			}
+			if b, err = os.ReadFile(src); err != nil {
+				return r, fmt.Errorf("%w: %s: %v", ErrBadEntry, e.Path, err)
+			}
+		case e.Contents != nil:
+			b = []byte(*e.Contents)
+		default:
+			return r, e.errorf("no source or contents")
+		}
+		r = cpio.StaticRecord(b, cpio.Info{Name: name, Mode: cpio.S_IFREG | perm})
+	case "dir":
+		r = cpio.Directory(name, perm)
+	case "symlink":
+		if e.Target == "" {
+			return r, e.errorf("no target")
+		}
+		r = cpio.Symlink(name, e.Target)
+	case "char":
+		r = cpio.CharDev(name, perm, *e.Major, *e.Minor)
+	case "block":
+		r = cpio.CharDev(name, perm, *e.Major, *e.Minor)
+		r.Mode = cpio.S_IFBLK | perm
+	case "fifo":
+		r = cpio.Record{Info: cpio.Info{Name: name, Mode: cpio.S_IFIFO | perm}}
+	default:
+		return r, e.errorf("unknown type %q", typ)
+	}
+	r.UID, r.GID = e.UID, e.GID
+	return r, nil
+}
+
+// Records returns the records of all entries, in order.
+func (m *Manifest) Records() ([]cpio.Record, error) {
+	var records []cpio.Record
+	seen := map[string]bool{}
+	for i := range m.Entries {
+		r, err := m.record(&m.Entries[i])
+		if err != nil {
+			return nil, err
+		}
+		if seen[r.Name] {
+			return nil, m.Entries[i].errorf("listed twice")
+		}
+		seen[r.Name] = true
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Writer writes an archive with the records of a manifest. Records of the
+// same name written to it are replaced, and Flush writes the other manifest
+// records, after any missing parent directories.
+type Writer struct {
+	cpio.RecordWriter
+
+	records []cpio.Record
+	// index has the position of each record not written yet.
+	index   map[string]int
+	written map[string]bool
+}
+
+// NewWriter returns a Writer for records, which writes to w.
+func NewWriter(w cpio.RecordWriter, records []cpio.Record) *Writer {
+	mw := &Writer{RecordWriter: w, records: records, index: map[string]int{}, written: map[string]bool{}}
+	for i, r := range records {
+		mw.index[r.Name] = i
+	}
+	return mw
+}
+
+// WriteRecord writes a record, or the manifest record which replaces it.
+func (w *Writer) WriteRecord(r cpio.Record) error {
+	name := cpio.Normalize(r.Name)
+	if w.written[name] {
+		// Replaced by a manifest record written earlier.
+		return nil
+	}
+	if i, ok := w.index[name]; ok {
+		r = w.records[i]
+		delete(w.index, name)
+	}
+	w.written[name] = true
+	return w.RecordWriter.WriteRecord(r)
+}
+
+// flush writes a manifest record, after its parents.
+func (w *Writer) flush(name string) error {
+	if name == "." || w.written[name] {
+		return nil
+	}
+	if err := w.flush(path.Dir(name)); err != nil {
+		return err
+	}
+	if i, ok := w.index[name]; ok {
+		return w.WriteRecord(w.records[i])
+	}
+	return w.WriteRecord(cpio.Directory(name, 0o755))
+}
+
+// Flush writes the manifest records which have not replaced another record.
+func (w *Writer) Flush() error {
+	for _, r := range w.records {
+		if err := w.flush(r.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Output is an initramfs output which adds the records of a manifest, as
+// a Writer does.
+type Output struct {
+	initramfs.WriteOpener
+	Records []cpio.Record
+}
+
+// OpenWriter implements initramfs.WriteOpener.
+func (o *Output) OpenWriter() (initramfs.Writer, error) {
+	w, err := o.WriteOpener.OpenWriter()
+	if err != nil {
+		return nil, err
+	}
+	return &outputWriter{Writer: NewWriter(w, o.Records), out: w}, nil
+}
+
+type outputWriter struct {
+	*Writer
+	out initramfs.Writer
+}
+
+// Finish implements initramfs.Writer.
+func (w *outputWriter) Finish() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.out.Finish()
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package manifest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/mkuimage/cpio"
+)
+
+func TestRecords(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "motd"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(strings.NewReader(`{"entries": [
+		{"path": "/etc/motd", "source": "motd", "mode": "0600", "uid": 1000},
+		{"path": "etc/hostname", "contents": ""},
+		{"path": "home/user", "type": "dir", "mode": "0700", "uid": 1000, "gid": 100},
+		{"path": "bin/sh", "type": "symlink", "target": "gosh"},
+		{"path": "dev/ttyS0", "type": "char", "major": 4, "minor": 64, "mode": "0620", "gid": 5},
+		{"path": "dev/sda", "type": "block", "major": 8, "minor": 0},
+		{"path": "run/initctl", "type": "fifo", "mode": "0600"}
+	]}`), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := m.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name     string
+		mode     uint64
+		uid, gid uint64
+		data     string
+		rdev     [2]uint64
+	}{
+		{name: "etc/motd", mode: cpio.S_IFREG | 0o600, uid: 1000, data: "hello\n"},
+		{name: "etc/hostname", mode: cpio.S_IFREG | 0o644},
+		{name: "home/user", mode: cpio.S_IFDIR | 0o700, uid: 1000, gid: 100},
+		{name: "bin/sh", mode: cpio.S_IFLNK | 0o777, data: "gosh"},
+		{name: "dev/ttyS0", mode: cpio.S_IFCHR | 0o620, gid: 5, rdev: [2]uint64{4, 64}},
+		{name: "dev/sda", mode: cpio.S_IFBLK | 0o644, rdev: [2]uint64{8, 0}},
+		{name: "run/initctl", mode: cpio.S_IFIFO | 0o600},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, r := range records {
+		w := want[i]
+		var data string
+		if r.ReaderAt != nil {
+			b, _ := io.ReadAll(io.NewSectionReader(r.ReaderAt, 0, int64(r.FileSize)))
+			data = string(b)
+		}
+		if r.Name != w.name || r.Mode != w.mode || r.UID != w.uid || r.GID != w.gid || data != w.data || r.Rmajor != w.rdev[0] || r.Rminor != w.rdev[1] || r.MTime != 0 {
+			t.Errorf("record %d = %v %q, want %+v", i, r.Info, data, w)
+		}
+	}
+}
+
+func TestBadEntries(t *testing.T) {
+	for _, tt := range []struct {
+		name, entries string
+	}{
+		{name: "no path", entries: `{"contents": "x"}`},
+		{name: "outside", entries: `{"path": "../etc/passwd", "contents": "x"}`},
+		{name: "root", entries: `{"path": "/", "type": "dir"}`},
+		{name: "no contents", entries: `{"path": "a"}`},
+		{name: "both contents", entries: `{"path": "a", "source": "a", "contents": "x"}`},
+		{name: "missing source", entries: `{"path": "a", "source": "nope"}`},
+		{name: "dir contents", entries: `{"path": "a", "type": "dir", "contents": "x"}`},
+		{name: "no target", entries: `{"path": "a", "type": "symlink"}`},
+		{name: "file target", entries: `{"path": "a", "contents": "", "target": "b"}`},
+		{name: "no minor", entries: `{"path": "a", "type": "char", "major": 1}`},
+		{name: "file major", entries: `{"path": "a", "contents": "", "major": 1, "minor": 1}`},
+		{name: "bad mode", entries: `{"path": "a", "contents": "", "mode": "rw"}`},
+		{name: "type in mode", entries: `{"path": "a", "contents": "", "mode": "0100644"}`},
+		{name: "bad type", entries: `{"path": "a", "type": "socket"}`},
+		{name: "twice", entries: `{"path": "a", "contents": ""}, {"path": "/a", "contents": ""}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(strings.NewReader(`{"entries": [`+tt.entries+`]}`), t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Records(); !errors.Is(err, ErrBadEntry) {
+				t.Errorf("Records = %v, want ErrBadEntry", err)
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader(`{"entries": [{"path": "a", "owner": "root"}]}`), ""); err == nil {
+		t.Errorf("Parse with an unknown field succeeded")
+	}
+}
+
+// recorder records the records written.
+type recorder []cpio.Record
+
+func (r *recorder) WriteRecord(rec cpio.Record) error {
+	*r = append(*r, rec)
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	var out recorder
+	w := NewWriter(&out, []cpio.Record{
+		cpio.StaticFile("etc/hostname", "node1\n", 0o644),
+		cpio.StaticFile("var/lib/x/file", "x", 0o600),
+		cpio.Directory("var/lib", 0o700),
+		cpio.Directory("etc", 0o750),
+	})
+	for _, r := range []cpio.Record{
+		cpio.Directory("etc", 0o755),
+		cpio.StaticFile("etc/hostname", "localhost\n", 0o644),
+		cpio.StaticFile("etc/passwd", "", 0o644),
+		cpio.Directory("var", 0o755),
+	} {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range out {
+		got = append(got, r.Name)
+	}
+	want := []string{"etc", "etc/hostname", "etc/passwd", "var", "var/lib", "var/lib/x", "var/lib/x/file"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+	// Replaced in place.
+	if out[0].Mode != cpio.S_IFDIR|0o750 || out[1].FileSize != 6 {
+		t.Errorf("etc and etc/hostname not replaced: %v, %v", out[0].Info, out[1].Info)
+	}
+	if out[4].Mode != cpio.S_IFDIR|0o700 || out[5].Mode != cpio.S_IFDIR|0o755 {
+		t.Errorf("parents of var/lib/x/file: %v, %v", out[4].Info, out[5].Info)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package templates composes uimage templates from command groups.
+//
+// A command group of a template file may name other groups, which expand
+// to their commands, so that a set like "appliance" is built from
+// "minimal" and a few more commands instead of a copy of its list.
+// Extension files, e.g. of a downstream distribution, are merged into the
+// templates of .mkuimage.yaml: their groups and configs replace those of
+// the same name, and a group which names itself extends the group it
+// replaces:
+//
+//	commands:
+//	  minimal:
+//	    - minimal
+//	    - example.com/distro/cmds/installer
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/u-root/mkuimage/uimage/mkuimage"
+	"github.com/u-root/mkuimage/uimage/templates"
+)
+
+// ErrCycle is returned for command groups which name themselves through
+// other groups.
+var ErrCycle = errors.New("command group cycle")
+
+// Extend merges the command groups and configs of ext into t.
+func Extend(t, ext *templates.Templates) {
+	if t.Commands == nil {
+		t.Commands = map[string][]string{}
+	}
+	if t.Configs == nil {
+		t.Configs = map[string]templates.Config{}
+	}
+	for name, cmds := range ext.Commands {
+		var l []string
+		for _, c := range cmds {
+			if c == name {
+				l = append(l, t.Commands[name]...)
+			} else {
+				l = append(l, c)
+			}
+		}
+		t.Commands[name] = l
+	}
+	maps.Copy(t.Configs, ext.Configs)
+}
+
+// Expand replaces the names of groups in command groups by the commands
+// of those groups, so that Templates.CommandsFor expands groups fully.
+// Commands a group reaches more than once are kept once.
+func Expand(t *templates.Templates) error {
+	expanded := map[string][]string{}
+	visiting := map[string]bool{}
+	var expand func(name string) ([]string, error)
+	expand = func(name string) ([]string, error) {
+		if l, ok := expanded[name]; ok {
+			return l, nil
+		}
+		if visiting[name] {
+			return nil, fmt.Errorf("%w: %q", ErrCycle, name)
+		}
+		visiting[name] = true
+		var l []string
+		for _, c := range t.Commands[name] {
+			if _, ok := t.Commands[c]; !ok {
+				if !slices.Contains(l, c) {
+					l = append(l, c)
+				}
+				continue
+			}
+			sub, err := expand(c)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range sub {
+				if !slices.Contains(l, s) {
+					l = append(l, s)
+				}
+			}
+		}
+		expanded[name] = l
+		return l, nil
+	}
+	for name := range t.Commands {
+		if _, err := expand(name); err != nil {
+			return err
+		}
+	}
+	t.Commands = expanded
+	return nil
+}
+
+// Load reads the templates selected by tf, extends them with the files in
+// order, and expands their command groups. It returns nil if there are
+// neither templates nor files.
+func Load(tf *mkuimage.TemplateFlags, files ...string) (*templates.Templates, error) {
+	t, err := tf.Get()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		if len(files) == 0 {
+			return nil, nil
+		}
+		t = &templates.Templates{}
+	}
+	for _, f := range files {
+		ext, err := templates.TemplateFromFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("template extension %s: %w", f, err)
+		}
+		Extend(t, ext)
+	}
+	if err := Expand(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
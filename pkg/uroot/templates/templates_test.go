@@ -0,0 +1,147 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/u-root/mkuimage/uimage/mkuimage"
+	"github.com/u-root/mkuimage/uimage/templates"
+)
+
+const base = `
+commands:
+  core: [cmds/core/*]
+  minimal: [cmds/core/init, cmds/core/ls]
+  boot: [cmds/boot/boot]
+  appliance: [minimal, boot, cmds/exp/tpm, cmds/core/ls]
+configs:
+  default:
+    commands:
+      - commands: [core]
+  appliance:
+    commands:
+      - commands: [appliance]
+`
+
+const distro = `
+commands:
+  minimal: [minimal, distro/installer]
+  boot: [distro/boot]
+configs:
+  appliance:
+    shell: gosh
+    commands:
+      - commands: [appliance]
+      - builder: binary
+        commands: [distro/big]
+`
+
+func write(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	tf := &mkuimage.TemplateFlags{File: write(t, "base.yaml", base)}
+	for _, tt := range []struct {
+		name   string
+		files  []string
+		config string
+		want   []string
+	}{
+		{
+			name: "base",
+			want: []string{"cmds/core/init", "cmds/core/ls", "cmds/boot/boot", "cmds/exp/tpm"},
+		},
+		{
+			name:  "extended",
+			files: []string{write(t, "distro.yaml", distro)},
+			want:  []string{"cmds/core/init", "cmds/core/ls", "distro/installer", "distro/boot", "cmds/exp/tpm"},
+		},
+	} {
+		tpl, err := Load(tf, tt.files...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := tpl.CommandsFor("appliance"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: appliance = %q, want %q", tt.name, got, tt.want)
+		}
+		if got := tpl.CommandsFor("core", "cmds/exp/x"); !slices.Equal(got, []string{"cmds/core/*", "cmds/exp/x"}) {
+			t.Errorf("%s: core = %q", tt.name, got)
+		}
+		if _, err := tpl.Uimage("appliance"); err != nil {
+			t.Errorf("%s: Uimage(appliance) = %v", tt.name, err)
+		}
+	}
+
+	tpl, err := Load(tf, write(t, "distro.yaml", distro))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := tpl.Configs["appliance"]
+	if c.Shell == nil || *c.Shell != "gosh" || len(c.Commands) != 2 || c.Commands[1].Builder != "binary" {
+		t.Errorf("appliance config = %+v, want the distro's", c)
+	}
+	if _, ok := tpl.Configs["default"]; !ok {
+		t.Errorf("default config was dropped")
+	}
+
+	if _, err := Load(tf, filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load with missing file = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestExpandCycle(t *testing.T) {
+	tpl := &templates.Templates{Commands: map[string][]string{
+		"a": {"b", "cmds/a"},
+		"b": {"c"},
+		"c": {"a"},
+	}}
+	if err := Expand(tpl); !errors.Is(err, ErrCycle) {
+		t.Errorf("Expand = %v, want %v", err, ErrCycle)
+	}
+}
+
+func TestNoTemplates(t *testing.T) {
+	tpl, err := Load(&mkuimage.TemplateFlags{File: write(t, "base.yaml", base)})
+	if err != nil || tpl == nil {
+		t.Fatalf("Load = %v, %v", tpl, err)
+	}
+
+	ext := &templates.Templates{}
+	Extend(ext, &templates.Templates{Commands: map[string][]string{"x": {"x", "cmds/x"}}})
+	if got := ext.CommandsFor("x"); !slices.Equal(got, []string{"cmds/x"}) {
+		t.Errorf("extending a missing group = %q, want [cmds/x]", got)
+	}
+}
+
+// TestRepoTemplates checks that the groups of u-root's own template file
+// expand.
+func TestRepoTemplates(t *testing.T) {
+	tpl, err := Load(&mkuimage.TemplateFlags{File: "../../../.mkuimage.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tpl.CommandsFor("appliance")
+	for _, c := range []string{"github.com/u-root/u-root/cmds/core/init", "github.com/u-root/u-root/cmds/boot/*boot*", "github.com/u-root/u-root/cmds/exp/tpm"} {
+		if !slices.Contains(got, c) {
+			t.Errorf("appliance is missing %s", c)
+		}
+	}
+	for _, c := range got {
+		if _, ok := tpl.Commands[c]; ok {
+			t.Errorf("appliance has unexpanded group %s", c)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package unixflag
 
 import (
+	"flag"
 	"os"
 	"strings"
 )
@@ -35,6 +36,52 @@ func ArgsToGoArgs(args []string) []string {
 	return out
 }
 
+// FlagSetArgsToGoArgs is like ArgsToGoArgs, but uses f to tell which
+// switches take a value. A value may be attached to its switch, so
+// sort -nk2,2 turns into sort -n -k 2,2, and switches after the value of a
+// switch are converted as well, so grep -A 1 -in turns into
+// grep -A 1 -i -n.
+func FlagSetArgsToGoArgs(f *flag.FlagSet, args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || a == "-" || !strings.HasPrefix(a, "-") {
+			return append(out, args[i:]...)
+		}
+		if strings.HasPrefix(a, "--") {
+			out = append(out, a[1:])
+			if !strings.Contains(a, "=") && takesValue(f, a[2:]) && i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			continue
+		}
+		for j := 1; j < len(a); j++ {
+			out = append(out, "-"+a[j:j+1])
+			if !takesValue(f, a[j:j+1]) {
+				continue
+			}
+			if j+1 < len(a) {
+				out = append(out, a[j+1:])
+			} else if i+1 < len(args) {
+				i++
+				out = append(out, args[i])
+			}
+			break
+		}
+	}
+	return out
+}
+
+func takesValue(f *flag.FlagSet, name string) bool {
+	fl := f.Lookup(name)
+	if fl == nil {
+		return false
+	}
+	b, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !b.IsBoolFlag()
+}
+
 // OSArgsToGoArgs converts os.Args to Unix-style args.
 // The first argument, i.e. the executable name, is removed.
 // ArgsToGoArgs is called with the rest of the args
@@ -5,6 +5,7 @@
 package unixflag_test
 
 import (
+	"flag"
 	"os"
 	"slices"
 	"testing"
@@ -36,6 +37,39 @@ func TestArgsToGoArgs(t *testing.T) {
 
 }
 
+func TestFlagSetArgsToGoArgs(t *testing.T) {
+	f := flag.NewFlagSet("sort", flag.ContinueOnError)
+	f.Bool("n", false, "")
+	f.Bool("r", false, "")
+	f.Bool("reverse", false, "")
+	f.String("k", "", "")
+	f.String("key", "", "")
+	for _, tt := range []struct {
+		name string
+		args []string
+		out  []string
+	}{
+		{name: "no args", args: []string{}, out: []string{}},
+		{name: "-nr", args: []string{"-nr", "f"}, out: []string{"-n", "-r", "f"}},
+		{name: "-k2n", args: []string{"-k2n", "f"}, out: []string{"-k", "2n", "f"}},
+		{name: "-nk2,2", args: []string{"-nk2,2"}, out: []string{"-n", "-k", "2,2"}},
+		{name: "-k 2 -nr", args: []string{"-k", "2", "-nr", "f"}, out: []string{"-k", "2", "-n", "-r", "f"}},
+		{name: "--key 2 -r", args: []string{"--key", "2", "-r"}, out: []string{"-key", "2", "-r"}},
+		{name: "--key=2 -r", args: []string{"--key=2", "-r"}, out: []string{"-key=2", "-r"}},
+		{name: "--reverse f", args: []string{"--reverse", "f"}, out: []string{"-reverse", "f"}},
+		{name: "-k -x", args: []string{"-k", "-x", "-n"}, out: []string{"-k", "-x", "-n"}},
+		{name: "-- -n", args: []string{"-r", "--", "-n"}, out: []string{"-r", "--", "-n"}},
+		{name: "- -n", args: []string{"-", "-n"}, out: []string{"-", "-n"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := unixflag.FlagSetArgsToGoArgs(f, tt.args)
+			if !slices.Equal(out, tt.out) {
+				t.Fatalf("%v: got %v, want %v", tt.args, out, tt.out)
+			}
+		})
+	}
+}
+
 func TestOSArgsToGoArgs(t *testing.T) {
 	// because this test has to set os.Args, it is racy, so either only
 	// do one case or don't run the tests concurrently.
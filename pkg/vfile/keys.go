@@ -0,0 +1,156 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+)
+
+// ErrUnsupportedKey is returned for public keys of algorithms that cannot
+// be used to verify signatures.
+var ErrUnsupportedKey = errors.New("unsupported public key")
+
+// armorHeader starts ASCII-armored OpenPGP data.
+const armorHeader = "-----BEGIN PGP"
+
+// Dearmor returns the binary OpenPGP data of r, decoding it if it is
+// ASCII-armored.
+func Dearmor(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	b, _ := br.Peek(len(armorHeader))
+	if !bytes.Equal(b, []byte(armorHeader)) {
+		return br, nil
+	}
+	block, err := armor.Decode(br)
+	if err != nil {
+		return nil, err
+	}
+	return block.Body, nil
+}
+
+// GetKeysFromRing iterates a PGP keyring and extracts all RSA, ECDSA and
+// Ed25519 public keys, as *rsa.PublicKey, *ecdsa.PublicKey and
+// ed25519.PublicKey.
+//
+// Revoked keys are skipped, so that keys are rolled over by adding the new
+// key to the ring and revoking the old one. Expired keys are not, as the
+// clock is often not set when booting.
+//
+// An error is returned iff the keyring is not found or no usable public keys
+// were found on it.
+func GetKeysFromRing(ring openpgp.KeyRing) ([]crypto.PublicKey, error) {
+	el, ok := ring.(openpgp.EntityList)
+	if !ok {
+		return nil, fmt.Errorf("failed to assert KeyRing as EntityList to read keys")
+	}
+
+	now := time.Now()
+	var keys []crypto.PublicKey
+	for _, entity := range el {
+		if entity.Revoked(now) {
+			continue
+		}
+		if entity.PrimaryKey != nil {
+			if key, err := PublicKey(entity.PrimaryKey.PublicKey); err == nil {
+				keys = append(keys, key)
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.Revoked(now) {
+				continue
+			}
+			if key, err := PublicKey(subkey.PublicKey.PublicKey); err == nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no usable public keys found on keyring")
+	}
+	return keys, nil
+}
+
+// PublicKey converts the key of an OpenPGP public key packet to the key
+// type of the standard library.
+func PublicKey(pk crypto.PublicKey) (crypto.PublicKey, error) {
+	switch k := pk.(type) {
+	case *rsa.PublicKey:
+		return k, nil
+	case *pgpecdsa.PublicKey:
+		var c elliptic.Curve
+		switch name := k.GetCurve().GetCurveName(); name {
+		case "P-256":
+			c = elliptic.P256()
+		case "P-384":
+			c = elliptic.P384()
+		case "P-521":
+			c = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: ECDSA curve %s", ErrUnsupportedKey, name)
+		}
+		return &ecdsa.PublicKey{Curve: c, X: k.X, Y: k.Y}, nil
+	case *eddsa.PublicKey:
+		if name := k.GetCurve().GetCurveName(); name != "ed25519" || len(k.X) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: EdDSA curve %s", ErrUnsupportedKey, name)
+		}
+		return ed25519.PublicKey(k.X), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pk)
+}
+
+// VerifyHashSignature checks a raw signature over the digest hashed,
+// computed with h, against keys and returns nil if any of them made it.
+//
+// RSA signatures are PKCS1v15. ECDSA signatures are either ASN.1 or the
+// concatenated, zero-padded r and s as in FIT images. Ed25519 signatures
+// sign the digest.
+func VerifyHashSignature(keys []crypto.PublicKey, h crypto.Hash, hashed, sig []byte) error {
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, h, hashed, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if verifyECDSA(k, hashed, sig) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, hashed, sig) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature does not match any key")
+}
+
+func verifyECDSA(k *ecdsa.PublicKey, hashed, sig []byte) bool {
+	size := (k.Curve.Params().BitSize + 7) / 8
+	if len(sig) == 2*size {
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if ecdsa.Verify(k, hashed, r, s) {
+			return true
+		}
+	}
+	return ecdsa.VerifyASN1(k, hashed, sig)
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/eddsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newEntity(t *testing.T, algo packet.PublicKeyAlgorithm, curve packet.Curve) *openpgp.Entity {
+	t.Helper()
+	e, err := openpgp.NewEntity("goog", "goog", "goog@goog", &packet.Config{Algorithm: algo, Curve: curve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestPublicKey(t *testing.T) {
+	digest := sha256.Sum256([]byte("kernel"))
+
+	t.Run("ECDSA", func(t *testing.T) {
+		e := newEntity(t, packet.PubKeyAlgoECDSA, packet.CurveNistP256)
+		key, err := PublicKey(e.PrimaryKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			t.Fatalf("PublicKey = %T, want *ecdsa.PublicKey", key)
+		}
+		priv := &ecdsa.PrivateKey{PublicKey: *pub, D: e.PrivateKey.PrivateKey.(*pgpecdsa.PrivateKey).D}
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyHashSignature([]crypto.PublicKey{pub}, crypto.SHA256, digest[:], sig); err != nil {
+			t.Errorf("VerifyHashSignature = %v, want nil", err)
+		}
+	})
+
+	t.Run("Ed25519", func(t *testing.T) {
+		e := newEntity(t, packet.PubKeyAlgoEdDSA, packet.Curve25519)
+		key, err := PublicKey(e.PrimaryKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv := ed25519.NewKeyFromSeed(e.PrivateKey.PrivateKey.(*eddsa.PrivateKey).D)
+		if !priv.Public().(ed25519.PublicKey).Equal(key) {
+			t.Errorf("PublicKey = %x, want %x", key, priv.Public())
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		e := newEntity(t, packet.PubKeyAlgoEdDSA, packet.Curve25519)
+		// The encryption subkey is ECDH.
+		if _, err := PublicKey(e.Subkeys[0].PublicKey.PublicKey); !errors.Is(err, ErrUnsupportedKey) {
+			t.Errorf("PublicKey(ECDH key) = %v, want %v", err, ErrUnsupportedKey)
+		}
+	})
+}
+
+func TestGetKeysFromRing(t *testing.T) {
+	oldKey := newEntity(t, packet.PubKeyAlgoEdDSA, packet.Curve25519)
+	newKey := newEntity(t, packet.PubKeyAlgoECDSA, packet.CurveNistP384)
+	revoked := newEntity(t, packet.PubKeyAlgoEdDSA, packet.Curve25519)
+	if err := revoked.RevokeKey(packet.KeyCompromised, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc       string
+		ring       openpgp.EntityList
+		wantKeyCnt int
+		wantErr    bool
+	}{
+		{
+			desc:       "rollover",
+			ring:       openpgp.EntityList{oldKey, newKey},
+			wantKeyCnt: 2,
+		},
+		{
+			desc:       "revoked key skipped",
+			ring:       openpgp.EntityList{revoked, newKey},
+			wantKeyCnt: 1,
+		},
+		{
+			desc:    "only revoked key",
+			ring:    openpgp.EntityList{revoked},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			keys, err := GetKeysFromRing(tt.ring)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetKeysFromRing = %v, want error %t", err, tt.wantErr)
+			}
+			if len(keys) != tt.wantKeyCnt {
+				t.Errorf("GetKeysFromRing returned %d keys, want %d", len(keys), tt.wantKeyCnt)
+			}
+		})
+	}
+
+	ring, err := GetKeyRing("testdata/keyring0+1+dsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := GetKeysFromRing(ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The RSA keys and subkeys of key0 and key1; DSA is not supported.
+	if len(keys) != 4 {
+		t.Errorf("GetKeysFromRing(keyring0+1+dsa) returned %d keys, want 4", len(keys))
+	}
+}
+
+func TestVerifyHashSignature(t *testing.T) {
+	digest := sha256.Sum256([]byte("kernel"))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecRawSig := make([]byte, 64)
+	r.FillBytes(ecRawSig[:32])
+	s.FillBytes(ecRawSig[32:])
+	ecASN1Sig, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSig := ed25519.Sign(edKey, digest[:])
+
+	all := []crypto.PublicKey{&rsaKey.PublicKey, &ecKey.PublicKey, edPub}
+	for _, tt := range []struct {
+		desc    string
+		keys    []crypto.PublicKey
+		sig     []byte
+		wantErr bool
+	}{
+		{desc: "RSA", keys: all, sig: rsaSig},
+		{desc: "raw ECDSA", keys: all, sig: ecRawSig},
+		{desc: "ASN.1 ECDSA", keys: all, sig: ecASN1Sig},
+		{desc: "Ed25519", keys: all, sig: edSig},
+		{desc: "wrong key", keys: []crypto.PublicKey{&rsaKey.PublicKey, edPub}, sig: ecRawSig, wantErr: true},
+		{desc: "no keys", sig: edSig, wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := VerifyHashSignature(tt.keys, crypto.SHA256, digest[:], tt.sig); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyHashSignature = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArmoredSignature(t *testing.T) {
+	key := newEntity(t, packet.PubKeyAlgoEdDSA, packet.Curve25519)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kernel")
+	const content = "kernel"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, key, strings.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", sig.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the public key armored, too.
+	var pub bytes.Buffer
+	w, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	keyPath := filepath.Join(dir, "key.asc")
+	if err := os.WriteFile(keyPath, pub.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := GetKeyRing(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenSignedSigFile(ring, path)
+	if err != nil {
+		t.Fatalf("OpenSignedSigFile = %v, want nil", err)
+	}
+	defer f.Close()
+	if b, _ := io.ReadAll(f); string(b) != content {
+		t.Errorf("OpenSignedSigFile read %q, want %q", b, content)
+	}
+}
@@ -57,7 +57,8 @@ func (e ErrWrongSigner) Error() string {
 	return fmt.Sprintf("signed by a key not present in keyring %s", e.KeyRing)
 }
 
-// GetKeyRing returns an OpenPGP KeyRing loaded from the specified path.
+// GetKeyRing returns an OpenPGP KeyRing loaded from the specified path. The
+// key ring may be binary or ASCII-armored.
 //
 // keyPath must be an already trusted path, e.g. keys are included in the initramfs.
 func GetKeyRing(keyPath string) (openpgp.KeyRing, error) {
@@ -67,7 +68,11 @@ func GetKeyRing(keyPath string) (openpgp.KeyRing, error) {
 	}
 	defer key.Close()
 
-	ring, err := openpgp.ReadKeyRing(key)
+	r, err := Dearmor(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pub key: %v", err)
+	}
+	ring, err := openpgp.ReadKeyRing(r)
 	if err != nil {
 		return nil, fmt.Errorf("could not read pub key: %v", err)
 	}
@@ -77,6 +82,8 @@ func GetKeyRing(keyPath string) (openpgp.KeyRing, error) {
 // GetRSAKeysFromRing iterates a PGP Keyring and extracts all rsa.PublicKey.
 // An error is returned iff the keyring is not found or no RSA public keys were
 // found on it.
+//
+// Deprecated: use GetKeysFromRing, which also returns ECDSA and Ed25519 keys.
 func GetRSAKeysFromRing(ring openpgp.KeyRing) ([]*rsa.PublicKey, error) {
 	el, ok := ring.(openpgp.EntityList)
 	if !ok {
@@ -139,7 +146,8 @@ func getEndOfTime() time.Time {
 // WARNING! Unlike many Go functions, this may return both the file and an
 // error.
 //
-// It expects pathSig to be available.
+// It expects pathSig to be available, as a binary or ASCII-armored detached
+// signature.
 //
 // If the signature does not exist or does not match the keyring, both the file
 // and a signature error will be returned.
@@ -159,6 +167,10 @@ func OpenSignedFile(keyring openpgp.KeyRing, path, pathSig string, opts ...OpenS
 		return f, ErrUnsigned{Path: path, Err: err}
 	}
 	defer signaturef.Close()
+	sig, err := Dearmor(signaturef)
+	if err != nil {
+		return f, ErrUnsigned{Path: path, Err: err}
+	}
 
 	var config packet.Config
 	if o.ignoreTimeConflict {
@@ -170,7 +182,7 @@ func OpenSignedFile(keyring openpgp.KeyRing, path, pathSig string, opts ...OpenS
 
 	if keyring == nil {
 		return f, ErrUnsigned{Path: path, Err: ErrNoKeyRing}
-	} else if signer, err := openpgp.CheckDetachedSignature(keyring, f, signaturef, &config); err != nil {
+	} else if signer, err := openpgp.CheckDetachedSignature(keyring, f, sig, &config); err != nil {
 		return f, ErrUnsigned{Path: path, Err: err}
 	} else if signer == nil {
 		return f, ErrUnsigned{Path: path, Err: ErrWrongSigner{keyring}}
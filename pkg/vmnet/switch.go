@@ -0,0 +1,193 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vmnet connects the QEMU VMs of integration tests with virtual
+// Ethernet networks. Unlike qnetwork.InterVM, which links two VMs, a
+// network may have any number of VMs, and a VM may have NICs on any number
+// of networks, to test such topologies as a DHCP and TFTP server VM on two
+// networks with PXE clients on each.
+//
+// Each network is a Switch in the test process, to which QEMU connects with
+// its stream network backend.
+package vmnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxFrame is the largest frame QEMU sends, with room for offloads.
+const maxFrame = 65536 + 4096
+
+// ErrFrame is returned for frames which are too large or too small.
+var ErrFrame = errors.New("bad frame")
+
+// Switch is a learning Ethernet switch whose ports are the connections to
+// a unix socket, as QEMU's stream network backend makes them. Frames go to
+// the port their destination address was last seen on, or to all other
+// ports.
+type Switch struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	ports map[*port]bool
+	macs  map[[6]byte]*port
+	// frames counts the frames received.
+	frames uint64
+
+	wg sync.WaitGroup
+}
+
+// port is a connection to a Switch.
+type port struct {
+	conn net.Conn
+	// mu serializes frames written to the port.
+	mu sync.Mutex
+}
+
+// Listen returns a Switch whose ports connect to the unix socket path.
+func Listen(path string) (*Switch, error) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Switch{ln: ln, ports: map[*port]bool{}, macs: map[[6]byte]*port{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the path of the socket of s.
+func (s *Switch) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Frames returns how many frames s received.
+func (s *Switch) Frames() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+// Close disconnects all ports of s and stops it.
+func (s *Switch) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for p := range s.ports {
+		p.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Switch) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		p := &port{conn: c}
+		s.mu.Lock()
+		s.ports[p] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(p)
+	}
+}
+
+// handle forwards the frames of p until it disconnects.
+func (s *Switch) handle(p *port) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.ports, p)
+		for mac, q := range s.macs {
+			if q == p {
+				delete(s.macs, mac)
+			}
+		}
+		s.mu.Unlock()
+		p.conn.Close()
+	}()
+
+	buf := make([]byte, maxFrame)
+	for {
+		frame, err := ReadFrame(p.conn, buf)
+		if err != nil {
+			return
+		}
+		for _, q := range s.route(p, frame) {
+			// A port which cannot be written to is gone, and
+			// its reader cleans up.
+			q.write(frame)
+		}
+	}
+}
+
+// route learns the source of a frame from p and returns the ports it goes
+// to.
+func (s *Switch) route(p *port, frame []byte) []*port {
+	var dst, src [6]byte
+	copy(dst[:], frame[0:6])
+	copy(src[:], frame[6:12])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames++
+	// Group addresses are never a source.
+	if src[0]&1 == 0 {
+		s.macs[src] = p
+	}
+	if q, ok := s.macs[dst]; ok && dst[0]&1 == 0 {
+		if q == p {
+			return nil
+		}
+		return []*port{q}
+	}
+	var flood []*port
+	for q := range s.ports {
+		if q != p {
+			flood = append(flood, q)
+		}
+	}
+	return flood
+}
+
+func (p *port) write(frame []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return WriteFrame(p.conn, frame)
+}
+
+// ReadFrame reads a frame as QEMU's stream backend sends it, preceded by
+// its length as a big-endian uint32, into buf and returns it.
+func ReadFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < 14 || int(n) > len(buf) {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrame, n)
+	}
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// WriteFrame writes a frame as QEMU's stream backend expects it.
+func WriteFrame(w io.Writer, frame []byte) error {
+	b := make([]byte, 4+len(frame))
+	binary.BigEndian.PutUint32(b, uint32(len(frame)))
+	copy(b[4:], frame)
+	_, err := w.Write(b)
+	return err
+}
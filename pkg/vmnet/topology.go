@@ -0,0 +1,123 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vmnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hugelgupf/vmtest/qemu"
+	"github.com/hugelgupf/vmtest/qemu/qnetwork"
+)
+
+// Topology is the networks of the VMs of a test, each named, and the NICs
+// of the VMs on them.
+//
+//	topo := vmnet.New(t)
+//	server := scriptvm.Start(t, "server", serverScript, scriptvm.WithQEMUFn(
+//		topo.NIC("server", "lan-a"), // eth0
+//		topo.NIC("server", "lan-b"), // eth1
+//	))
+//	client := scriptvm.Start(t, "client", clientScript, scriptvm.WithQEMUFn(
+//		topo.NIC("client", "lan-b"),
+//	))
+//
+// NICs appear in a VM in the order they are given, as eth0, eth1 and so on.
+type Topology struct {
+	dir string
+
+	mu       sync.Mutex
+	networks map[string]*Switch
+	// netIndex and vmIndex number networks and VMs for their MAC
+	// addresses.
+	netIndex map[string]int
+	vmIndex  map[string]int
+	err      error
+}
+
+// New returns a Topology without networks, which are closed at the end of
+// the test.
+func New(t testing.TB) *Topology {
+	// t.TempDir may be too long a path for a unix socket.
+	dir, err := os.MkdirTemp("", "vmnet-")
+	topo := &Topology{dir: dir, err: err, networks: map[string]*Switch{}, netIndex: map[string]int{}, vmIndex: map[string]int{}}
+	t.Cleanup(func() {
+		if err := topo.Close(); err != nil {
+			t.Errorf("closing networks: %v", err)
+		}
+	})
+	return topo
+}
+
+// Network returns the switch of the network called name, which is created
+// on first use.
+func (t *Topology) Network(name string) (*Switch, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return nil, t.err
+	}
+	if s, ok := t.networks[name]; ok {
+		return s, nil
+	}
+	if _, ok := t.netIndex[name]; !ok {
+		t.netIndex[name] = len(t.netIndex)
+	}
+	s, err := Listen(filepath.Join(t.dir, fmt.Sprintf("net%d", t.netIndex[name])))
+	if err != nil {
+		return nil, err
+	}
+	t.networks[name] = s
+	return s, nil
+}
+
+// MAC returns the MAC address of the NIC of vm on network. MAC addresses
+// are locally administered, and differ for each VM and network.
+func (t *Topology) MAC(vm, network string) net.HardwareAddr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.vmIndex[vm]; !ok {
+		t.vmIndex[vm] = len(t.vmIndex)
+	}
+	if _, ok := t.netIndex[network]; !ok {
+		t.netIndex[network] = len(t.netIndex)
+	}
+	return net.HardwareAddr{0x0e, 0x01, 0, byte(t.netIndex[network]), 0, byte(t.vmIndex[vm])}
+}
+
+// NIC returns a qemu.Fn which gives the VM called vm a NIC on network.
+func (t *Topology) NIC(vm, network string, mods ...qnetwork.NetDevModifier[qnetwork.SocketBackend]) qemu.Fn {
+	mac := t.MAC(vm, network)
+	s, err := t.Network(network)
+	if err != nil {
+		return func(*qemu.IDAllocator, *qemu.Options) error {
+			return err
+		}
+	}
+	return qnetwork.New[qnetwork.SocketBackend](append([]qnetwork.NetDevModifier[qnetwork.SocketBackend]{
+		qnetwork.WithDevice[qnetwork.SocketBackend](qnetwork.WithMAC(mac)),
+		qnetwork.WithSocket(qnetwork.IsServer(false), qnetwork.WithUnixSocket(s.Addr())),
+	}, mods...)...)
+}
+
+// Close stops all networks.
+func (t *Topology) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for _, s := range t.networks {
+		errs = append(errs, s.Close())
+	}
+	t.networks = map[string]*Switch{}
+	if t.dir != "" {
+		errs = append(errs, os.RemoveAll(t.dir))
+	}
+	return errors.Join(errs...)
+}
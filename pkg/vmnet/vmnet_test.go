@@ -0,0 +1,167 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vmnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/vmtest/qemu"
+)
+
+func frame(dst, src net.HardwareAddr, payload string) []byte {
+	b := append(append(append([]byte{}, dst...), src...), 0x88, 0xb5)
+	return append(b, payload...)
+}
+
+// recv returns the next frame c receives, or nil if there is none soon.
+func recv(t *testing.T, c net.Conn) []byte {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	f, err := ReadFrame(c, make([]byte, maxFrame))
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return nil
+		}
+		t.Fatalf("ReadFrame = %v", err)
+	}
+	return f
+}
+
+func TestSwitch(t *testing.T) {
+	topo := New(t)
+	s, err := topo.Network("lan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("unix", s.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		conns = append(conns, c)
+	}
+	macs := []net.HardwareAddr{topo.MAC("a", "lan"), topo.MAC("b", "lan"), topo.MAC("c", "lan")}
+	bcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	// Wait for the switch to accept all ports.
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		n := len(s.ports)
+		s.mu.Unlock()
+		if n == len(conns) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		from  int
+		frame []byte
+		// want is whether each port receives the frame.
+		want []bool
+	}{
+		{
+			name:  "broadcast floods",
+			from:  0,
+			frame: frame(bcast, macs[0], "who has"),
+			want:  []bool{false, true, true},
+		},
+		{
+			name:  "learned unicast",
+			from:  1,
+			frame: frame(macs[0], macs[1], "is at"),
+			want:  []bool{true, false, false},
+		},
+		{
+			name:  "unknown unicast floods",
+			from:  1,
+			frame: frame(macs[2], macs[1], "hello"),
+			want:  []bool{true, false, true},
+		},
+		{
+			name:  "broadcast from another port",
+			from:  2,
+			frame: frame(bcast, macs[2], "here"),
+			want:  []bool{true, true, false},
+		},
+		{
+			name:  "learned from broadcast",
+			from:  0,
+			frame: frame(macs[2], macs[0], "hello again"),
+			want:  []bool{false, false, true},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := WriteFrame(conns[tt.from], tt.frame); err != nil {
+				t.Fatal(err)
+			}
+			for i, c := range conns {
+				got := recv(t, c)
+				if tt.want[i] && !bytes.Equal(got, tt.frame) {
+					t.Errorf("port %d got %x, want %x", i, got, tt.frame)
+				}
+				if !tt.want[i] && got != nil {
+					t.Errorf("port %d got %x, want nothing", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   []byte
+		err  error
+	}{
+		{name: "short", in: []byte{0, 0, 0, 2, 1, 2}, err: ErrFrame},
+		{name: "too large", in: []byte{0, 0x10, 0, 0}, err: ErrFrame},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadFrame(bytes.NewReader(tt.in), make([]byte, maxFrame)); !errors.Is(err, tt.err) {
+				t.Errorf("ReadFrame = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNIC(t *testing.T) {
+	topo := New(t)
+	if a, b := topo.MAC("server", "lan-a"), topo.MAC("server", "lan-b"); a.String() == b.String() {
+		t.Errorf("NICs of a VM on two networks have MAC %s", a)
+	}
+	if a, b := topo.MAC("server", "lan-a"), topo.MAC("client", "lan-a"); a.String() == b.String() {
+		t.Errorf("NICs of two VMs on a network have MAC %s", a)
+	}
+
+	opts := &qemu.Options{}
+	alloc := qemu.NewIDAllocator()
+	for _, fn := range []qemu.Fn{topo.NIC("server", "lan-a"), topo.NIC("server", "lan-b")} {
+		if err := fn(alloc, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	args := strings.Join(opts.QEMUArgs, " ")
+	for _, network := range []string{"lan-a", "lan-b"} {
+		s, err := topo.Network(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{s.Addr(), topo.MAC("server", network).String()} {
+			if !strings.Contains(args, want) {
+				t.Errorf("QEMU args %q do not contain %q", args, want)
+			}
+		}
+	}
+}
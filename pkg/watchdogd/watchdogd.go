@@ -15,6 +15,9 @@
 //     Watchdog | a hang will not       | a hang will not reboot
 //     Disarmed | reboot the machine    | the machine
 //
+// A hang of the daemon itself is caught by the watchdog. To catch a hang of
+// other processes, such as init, they send heartbeats (see SendHeartbeats),
+// and with DaemonOpts.Heartbeat set the daemon stops petting when they stop.
 
 package watchdogd
 
@@ -27,19 +30,26 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/u-root/u-root/pkg/watchdog"
 	"golang.org/x/sys/unix"
 )
 
-const defaultUDS = "/tmp/watchdogd"
+// DefaultUDS is the default unix domain socket of the daemon.
+const DefaultUDS = "/tmp/watchdogd"
+
+// HeartbeatInterval is how often SendHeartbeats sends heartbeats by default.
+const HeartbeatInterval = 5 * time.Second
 
 const (
 	OpStop     = 'S' // Stop the watchdogd petting.
 	OpContinue = 'C' // Continue the watchdogd petting.
 	OpDisarm   = 'D' // Disarm the watchdog.
 	OpArm      = 'A' // Arm the watchdog.
+	// OpHeartbeat tells the daemon a process is alive.
+	OpHeartbeat = 'H'
 )
 
 const (
@@ -67,6 +77,10 @@ type Daemon struct {
 
 	// PettingOn indicate if there is an active petting session.
 	PettingOn bool
+
+	mu            sync.Mutex
+	lastHeartbeat time.Time
+	now           func() time.Time
 }
 
 // DaemonOpts contain operating parameters for bootstrapping a watchdog daemon.
@@ -86,6 +100,11 @@ type DaemonOpts struct {
 
 	// UDS is the name of daemon's unix domain socket.
 	UDS string
+
+	// Heartbeat, if not 0, is how long the daemon keeps petting without
+	// an OpHeartbeat, e.g. from init. It should be a few times the
+	// heartbeat interval, and at most the timeout of the watchdog.
+	Heartbeat time.Duration
 }
 
 // MonitorOops return an error if the kernel logs contain an oops.
@@ -105,6 +124,9 @@ func MonitorOops() error {
 func (d *Daemon) StartServing(l *net.UnixListener) {
 	for { // All requests are processed sequentially.
 		c, err := l.AcceptUnix()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			log.Printf("Failed to accept new request: %v", err)
 			continue
@@ -125,6 +147,8 @@ func (d *Daemon) StartServing(l *net.UnixListener) {
 			r = d.ArmWatchdog()
 		case OpDisarm:
 			r = d.DisarmWatchdog()
+		case OpHeartbeat:
+			r = d.HeartbeatReceived()
 		default:
 			r = OpResultInvalidOp
 		}
@@ -137,7 +161,7 @@ func (d *Daemon) StartServing(l *net.UnixListener) {
 func setupListener(uds string) (*net.UnixListener, func(), error) {
 	os.Remove(uds)
 
-	l, err := net.ListenUnix("unix", &net.UnixAddr{uds, "unix"})
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: uds, Net: "unix"})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -163,14 +187,14 @@ func (d *Daemon) ArmWatchdog() rune {
 	}
 	if d.CurrentOpts.Timeout != nil {
 		if err := wd.SetTimeout(*d.CurrentOpts.Timeout); err != nil {
-			d.CurrentWd.Close()
+			wd.Close()
 			log.Printf("Failed to set timeout: %v", err)
 			return OpResultError
 		}
 	}
 	if d.CurrentOpts.PreTimeout != nil {
 		if err := wd.SetPreTimeout(*d.CurrentOpts.PreTimeout); err != nil {
-			d.CurrentWd.Close()
+			wd.Close()
 			log.Printf("Failed to set pretimeout: %v", err)
 			return OpResultError
 		}
@@ -194,6 +218,28 @@ func (d *Daemon) DisarmWatchdog() rune {
 	return OpResultOk
 }
 
+// HeartbeatReceived records a heartbeat.
+func (d *Daemon) HeartbeatReceived() rune {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastHeartbeat = d.now()
+	return OpResultOk
+}
+
+// checkHeartbeat returns an error if heartbeats are required, and the last
+// one, or the start of the daemon, was too long ago.
+func (d *Daemon) checkHeartbeat() error {
+	if d.CurrentOpts.Heartbeat <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if since := d.now().Sub(d.lastHeartbeat); since > d.CurrentOpts.Heartbeat {
+		return fmt.Errorf("no heartbeat for %v", since.Round(time.Second))
+	}
+	return nil
+}
+
 // doPetting sends keepalive signal to Watchdog when necessary.
 //
 // If at least one of the custom monitors failed check(s), it won't send a keepalive
@@ -202,6 +248,9 @@ func (d *Daemon) DoPetting() error {
 	if d.CurrentWd == nil {
 		return fmt.Errorf("no reference to any Watchdog")
 	}
+	if err := d.checkHeartbeat(); err != nil {
+		return fmt.Errorf("won't keepalive: %w", err)
+	}
 	if err := doMonitors(d.CurrentOpts.Monitors); err != nil {
 		return fmt.Errorf("won't keepalive since at least one of the custom monitors failed: %v", err)
 	}
@@ -287,12 +336,10 @@ func Run(ctx context.Context, opts *DaemonOpts) error {
 		return fmt.Errorf("start petting failed")
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			cleanup()
-		}
-	}
+	<-ctx.Done()
+	l.Close()
+	cleanup()
+	return nil
 }
 
 // doMonitors is a helper function to run the monitors.
@@ -314,7 +361,10 @@ func New(opts *DaemonOpts) *Daemon {
 		CurrentOpts: opts,
 		PettingOp:   make(chan int),
 		PettingOn:   false,
+		now:         time.Now,
 	}
+	// The daemon starting counts as the first heartbeat.
+	d.lastHeartbeat = d.now()
 	return d
 }
 
@@ -338,6 +388,11 @@ func (c *client) Arm() error {
 	return sendAndCheckResult(c.Conn, OpArm)
 }
 
+// Heartbeat tells the daemon this process is alive.
+func (c *client) Heartbeat() error {
+	return sendAndCheckResult(c.Conn, OpHeartbeat)
+}
+
 // sendAndCheckResult sends operation bit and evaluates result.
 func sendAndCheckResult(c *net.UnixConn, op int) error {
 	n, err := c.Write([]byte{byte(op)})
@@ -359,7 +414,7 @@ func sendAndCheckResult(c *net.UnixConn, op int) error {
 }
 
 func NewClientFromUDS(uds string) (*client, error) {
-	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{uds, "unix"})
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: uds, Net: "unix"})
 	if err != nil {
 		return nil, err
 	}
@@ -367,5 +422,28 @@ func NewClientFromUDS(uds string) (*client, error) {
 }
 
 func NewClient() (*client, error) {
-	return NewClientFromUDS(defaultUDS)
+	return NewClientFromUDS(DefaultUDS)
+}
+
+// SendHeartbeats sends a heartbeat to the daemon listening on uds every
+// interval, until ctx is done. If healthy is not nil, heartbeats are only
+// sent while it returns nil. A missing daemon is not an error: it may
+// start later.
+func SendHeartbeats(ctx context.Context, uds string, interval time.Duration, healthy func() error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if healthy == nil || healthy() == nil {
+			if c, err := NewClientFromUDS(uds); err == nil {
+				c.Conn.SetDeadline(time.Now().Add(interval))
+				c.Heartbeat()
+				c.Conn.Close()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
 }
@@ -0,0 +1,113 @@
+// Copyright 2024 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package watchdogd
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestCheckHeartbeat(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	d := New(&DaemonOpts{Heartbeat: 15 * time.Second})
+	d.now = clock.now
+	d.lastHeartbeat = clock.now()
+
+	for _, step := range []struct {
+		advance   time.Duration
+		heartbeat bool
+		ok        bool
+	}{
+		{advance: 10 * time.Second, ok: true},
+		{advance: 10 * time.Second, ok: false},
+		{heartbeat: true, ok: true},
+		{advance: 15 * time.Second, ok: true},
+		{advance: time.Second, ok: false},
+	} {
+		clock.add(step.advance)
+		if step.heartbeat {
+			if r := d.HeartbeatReceived(); r != OpResultOk {
+				t.Fatalf("HeartbeatReceived = %c", r)
+			}
+		}
+		if err := d.checkHeartbeat(); (err == nil) != step.ok {
+			t.Errorf("after %v: checkHeartbeat = %v, want ok %t", step.advance, err, step.ok)
+		}
+	}
+
+	// Heartbeats are not required by default.
+	d = New(&DaemonOpts{})
+	d.now = clock.now
+	clock.add(time.Hour)
+	if err := d.checkHeartbeat(); err != nil {
+		t.Errorf("checkHeartbeat without Heartbeat = %v", err)
+	}
+}
+
+func TestSendHeartbeats(t *testing.T) {
+	uds := filepath.Join(t.TempDir(), "watchdogd")
+	l, cleanup, err := setupListener(uds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	d := New(&DaemonOpts{Heartbeat: time.Second})
+	d.now = clock.now
+	// New started the heartbeat timeout on the real clock.
+	d.lastHeartbeat = clock.now()
+	done := make(chan struct{})
+	go func() {
+		d.StartServing(l)
+		close(done)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var checks int
+	var mu sync.Mutex
+	go SendHeartbeats(ctx, uds, 10*time.Millisecond, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		checks++
+		return nil
+	})
+	clock.add(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for d.checkHeartbeat() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("no heartbeat received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	l.Close()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if checks == 0 {
+		t.Errorf("health check was not called")
+	}
+}
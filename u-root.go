@@ -9,21 +9,152 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/u-root/gobusybox/src/pkg/bb/findpkg"
 	"github.com/u-root/gobusybox/src/pkg/golang"
+	"github.com/u-root/mkuimage/cpio"
 	"github.com/u-root/mkuimage/uimage"
+	"github.com/u-root/mkuimage/uimage/builder"
+	"github.com/u-root/mkuimage/uimage/initramfs"
 	"github.com/u-root/mkuimage/uimage/mkuimage"
+	"github.com/u-root/u-root/pkg/compress"
+	"github.com/u-root/u-root/pkg/uroot/bloat"
+	"github.com/u-root/u-root/pkg/uroot/kmodules"
+	"github.com/u-root/u-root/pkg/uroot/manifest"
+	"github.com/u-root/u-root/pkg/uroot/templates"
 	"github.com/u-root/uio/llog"
 )
 
 var (
 	errEmptyFilesArg = errors.New("empty argument to -files")
+	errBadTarget     = errors.New("bad build target, want GOARCH or GOOS/GOARCH")
+	errNoSuchCommand = errors.New("no such command to skip")
+	errNoBusybox     = errors.New("no busybox binary was built")
 )
 
+var (
+	compressFormat = flag.String("compress", "", "Compress the cpio output with one of none, gzip, xz, zstd or lz4 (default: from the extension of -o)")
+	compressLevel  = flag.Int("compress-level", compress.DefaultLevel, "Compression level (default: the format's default)")
+	compressJobs   = flag.Int("compress-jobs", 0, "Number of blocks of the cpio output to compress in parallel, shared by all targets (default: the number of CPUs)")
+	arch           = flag.String("arch", "", "Comma separated list of GOARCH or GOOS/GOARCH targets to build for, e.g. amd64,arm64,riscv64. Each target gets its own output file, named after -o with GOOS_GOARCH inserted")
+	manifestFile   = flag.String("manifest", "", "JSON manifest of files, directories, symlinks and device nodes to add, with their modes and owners")
+	skipCommands   = flag.String("skip-commands", "", "Comma separated list of commands to leave out, by name or package path, e.g. to prune a template")
+	bloatReport    = flag.Bool("bloat", false, "Print how much code each command and package adds to the busybox binary")
+	bloatPackages  = flag.Int("bloat-packages", 40, "Number of the largest packages in the -bloat report, or 0 for all")
+	configExtend   = flag.String("config-extend", "", "Comma separated list of template files whose command groups and configs extend or replace those of the config file")
+
+	modulesDir      = flag.String("modules", "", "Directory of kernel modules, such as /lib/modules/RELEASE, to add as lib/modules/RELEASE with a modules.dep and modules.alias")
+	modulesModalias = flag.String("modules-modalias", "", "File of the modaliases of the target hardware, one per line: only -modules matching them are added, with their dependencies")
+	modulesAdd      = flag.String("modules-add", "", "Comma separated list of -modules to add, by name or alias, with their dependencies (default: all, unless -modules-modalias is given)")
+	modulesCompress = flag.String("modules-compress", "", "Compress -modules with one of none, gzip, xz or zstd (default: as they are)")
+)
+
+// compressedCPIO is an initramfs.WriteOpener that streams a cpio archive
+// through a compressor into Path, so that no uncompressed copy of the
+// archive is ever written.
+type compressedCPIO struct {
+	Path   string
+	Format string
+	Level  int
+	// Pool compresses the archive in parallel blocks.
+	Pool *compress.Pool
+}
+
+// OpenWriter implements initramfs.WriteOpener.
+func (c *compressedCPIO) OpenWriter() (initramfs.Writer, error) {
+	f, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	zw, err := compress.NewParallelWriter(f, c.Format, c.Level, compress.DefaultBlockSize, c.Pool)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &compressedWriter{RecordWriter: cpio.Newc.Writer(zw), zw: zw, f: f}, nil
+}
+
+type compressedWriter struct {
+	cpio.RecordWriter
+
+	zw io.WriteCloser
+	f  *os.File
+}
+
+// Finish implements initramfs.Writer.
+func (w *compressedWriter) Finish() error {
+	err := cpio.WriteTrailer(w)
+	if cerr := w.zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// createUimage is mkuimage.CreateUimage with templates extended by the
+// -config-extend files, and with modifiers applied after the flag
+// modifiers, such as a compressed cpio output: the flag modifiers always
+// select an uncompressed output file.
+func createUimage(l *llog.Logger, base []uimage.Modifier, tf *mkuimage.TemplateFlags, f *mkuimage.Flags, args []string, after ...uimage.Modifier) error {
+	extend := strings.FieldsFunc(*configExtend, func(r rune) bool { return r == ',' })
+	tpl, err := templates.Load(tf, extend...)
+	if err != nil {
+		return fmt.Errorf("failed to get template: %w", err)
+	}
+	keepTempDir := f.KeepTempDir
+	if f.TempDir == nil {
+		tempDir, err := os.MkdirTemp("", "u-root")
+		if err != nil {
+			return err
+		}
+		f.TempDir = &tempDir
+		defer func() {
+			if keepTempDir {
+				l.Infof("Keeping temp dir %s", tempDir)
+			} else {
+				os.RemoveAll(tempDir)
+			}
+		}()
+	} else if err := os.MkdirAll(*f.TempDir, 0o755); err != nil {
+		return err
+	}
+
+	m := base
+	if tf.Config != "" {
+		mods, err := tpl.Uimage(tf.Config)
+		if err != nil {
+			return err
+		}
+		m = append(m, mods...)
+	}
+	if tpl != nil {
+		args = tpl.CommandsFor(args...)
+	}
+	more, err := f.Modifiers(args...)
+	if err != nil {
+		return err
+	}
+	m = append(m, more...)
+	m = append(m, after...)
+	err = uimage.Create(l, m...)
+	if errors.Is(err, builder.ErrBusyboxFailed) {
+		l.Errorf("Preserving temp dir due to busybox build error")
+		keepTempDir = true
+	}
+	return err
+}
+
 // checkArgs checks for common mistakes that cause confusion.
 //  1. -files as the last argument
 //  2. -files followed by any switch, indicating a shell expansion problem
@@ -54,6 +185,248 @@ func checkArgs(args ...string) error {
 	return nil
 }
 
+// target is a GOOS/GOARCH pair to build for.
+type target struct {
+	GOOS   string
+	GOARCH string
+}
+
+// parseTargets parses a comma separated list of GOARCH or GOOS/GOARCH
+// values. GOOS defaults to defaultOS.
+func parseTargets(s, defaultOS string) ([]target, error) {
+	var targets []target
+	seen := make(map[target]bool)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		goos, goarch, ok := strings.Cut(t, "/")
+		if !ok {
+			goos, goarch = defaultOS, t
+		}
+		if goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return nil, fmt.Errorf("%w: %q", errBadTarget, t)
+		}
+		tg := target{GOOS: goos, GOARCH: goarch}
+		if !seen[tg] {
+			seen[tg] = true
+			targets = append(targets, tg)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: %q", errBadTarget, s)
+	}
+	return targets, nil
+}
+
+// targetFile inserts GOOS_GOARCH into path before the first extension,
+// e.g. /tmp/initramfs.cpio.xz becomes /tmp/initramfs.linux_arm64.cpio.xz.
+func targetFile(path string, t target) string {
+	dir, base := filepath.Split(path)
+	name, ext, _ := strings.Cut(base, ".")
+	if ext != "" {
+		ext = "." + ext
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.%s_%s%s", name, t.GOOS, t.GOARCH, ext))
+}
+
+// withManifest adds the records of a manifest to the output.
+func withManifest(records []cpio.Record) uimage.Modifier {
+	return func(o *uimage.Opts) error {
+		o.OutputFile = &manifest.Output{WriteOpener: o.OutputFile, Records: records}
+		return nil
+	}
+}
+
+// moduleRecords returns the records of the kernel modules to add.
+func moduleRecords() ([]cpio.Record, error) {
+	t, err := kmodules.Load(*modulesDir)
+	if err != nil {
+		return nil, err
+	}
+	var modaliases []string
+	if *modulesModalias != "" {
+		if modaliases, err = kmodules.ReadModaliases(*modulesModalias); err != nil {
+			return nil, err
+		}
+		if len(modaliases) == 0 {
+			return nil, fmt.Errorf("no modaliases in %s", *modulesModalias)
+		}
+	}
+	names := strings.FieldsFunc(*modulesAdd, func(r rune) bool { return r == ',' })
+	mods, err := t.Select(modaliases, names)
+	if err != nil {
+		return nil, err
+	}
+	return t.Records(mods, *modulesCompress, compress.DefaultLevel)
+}
+
+// skipped reports whether a command package is one of skip, by name or
+// package path.
+func skipped(pkg string, skip []string) (string, bool) {
+	for _, s := range skip {
+		if s == pkg || s == path.Base(pkg) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// busybox is the Go environment and the commands of a busybox binary.
+type busybox struct {
+	env  *golang.Environ
+	cmds []string
+}
+
+// withCommands resolves the command packages, leaves out those in skip,
+// and records the busybox commands in bb.
+func withCommands(l *llog.Logger, skip []string, bb *busybox) uimage.Modifier {
+	return func(o *uimage.Opts) error {
+		env := o.Env
+		if env == nil {
+			env = golang.Default()
+		}
+		bb.env = env
+		lookupEnv := findpkg.DefaultEnv()
+		if o.UrootSource != "" {
+			lookupEnv.URootSource = o.UrootSource
+		}
+		used := map[string]bool{}
+		for i, c := range o.Commands {
+			if len(c.Packages) == 0 {
+				continue
+			}
+			paths, err := findpkg.ResolveGlobs(l.AtLevel(slog.LevelDebug), env, lookupEnv, c.Packages)
+			if err != nil {
+				return err
+			}
+			var pkgs []string
+			for _, p := range paths {
+				if s, ok := skipped(p, skip); ok {
+					l.Infof("Skipping %s", p)
+					used[s] = true
+					continue
+				}
+				pkgs = append(pkgs, p)
+			}
+			o.Commands[i].Packages = pkgs
+			if _, ok := c.Builder.(*builder.GBBBuilder); ok {
+				bb.cmds = append(bb.cmds, pkgs...)
+			}
+		}
+		for _, s := range skip {
+			if !used[s] {
+				return fmt.Errorf("%w: %s", errNoSuchCommand, s)
+			}
+		}
+		return nil
+	}
+}
+
+// findBusybox returns the busybox binary most recently built in dir.
+func findBusybox(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "builder*", "bb"))
+	if err != nil {
+		return "", err
+	}
+	var bb string
+	var newest time.Time
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(newest) {
+			bb, newest = f, fi.ModTime()
+		}
+	}
+	if bb == "" {
+		return "", errNoBusybox
+	}
+	return bb, nil
+}
+
+// writeBloat writes the bloat report of the busybox binary built in dir.
+func writeBloat(w io.Writer, dir string, b *busybox) error {
+	if len(b.cmds) == 0 {
+		return errNoBusybox
+	}
+	bb, err := findBusybox(dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(bb)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sizes, err := bloat.Sizes(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", bb, err)
+	}
+	deps, err := bloat.Deps(b.env, b.cmds...)
+	if err != nil {
+		return err
+	}
+	return bloat.Analyze(sizes, deps).Write(w, *bloatPackages)
+}
+
+// build builds one initramfs for env, with the records of a manifest, if
+// any.
+func build(l *llog.Logger, env *golang.Environ, tf *mkuimage.TemplateFlags, f *mkuimage.Flags, pkgs []string, pool *compress.Pool, records []cpio.Record) error {
+	// Set defaults.
+	m := []uimage.Modifier{
+		uimage.WithReplaceEnv(env),
+		uimage.WithBaseArchive(uimage.DefaultRamfs()),
+		uimage.WithCPIOOutput(defaultFile(env)),
+		uimage.WithInit("init"),
+	}
+	if env.GOOS != "plan9" {
+		m = append(m, uimage.WithShell("gosh"))
+	}
+
+	format := *compressFormat
+	if format == "" {
+		format = compress.FormatFromName(f.OutputFile)
+	}
+	var after []uimage.Modifier
+	if format != compress.None && f.ArchiveFormat == "cpio" {
+		after = append(after, uimage.WithOutput(&compressedCPIO{Path: f.OutputFile, Format: format, Level: *compressLevel, Pool: pool}))
+	}
+	if records != nil {
+		after = append(after, withManifest(records))
+	}
+	skip := strings.FieldsFunc(*skipCommands, func(r rune) bool { return r == ',' })
+	var bb busybox
+	if len(skip) > 0 || *bloatReport {
+		after = append(after, withCommands(l, skip, &bb))
+	}
+	if *bloatReport && f.TempDir == nil {
+		// Keep the busybox binary around for the report.
+		tempDir, err := os.MkdirTemp("", "u-root")
+		if err != nil {
+			return err
+		}
+		f.TempDir = &tempDir
+		defer func() {
+			f.TempDir = nil
+			if f.KeepTempDir {
+				l.Infof("Keeping temp dir %s", tempDir)
+			} else {
+				os.RemoveAll(tempDir)
+			}
+		}()
+	}
+	if err := createUimage(l, m, tf, f, pkgs, after...); err != nil {
+		return err
+	}
+
+	if stat, err := os.Stat(f.OutputFile); err == nil && f.ArchiveFormat == "cpio" {
+		l.Infof("Successfully built %q (size %d bytes -- %s).", f.OutputFile, stat.Size(), humanize.IBytes(uint64(stat.Size())))
+	}
+	if *bloatReport {
+		return writeBloat(os.Stdout, *f.TempDir, &bb)
+	}
+	return nil
+}
+
 func main() {
 	log.SetFlags(log.Ltime)
 	if err := checkArgs(os.Args...); err != nil {
@@ -75,17 +448,6 @@ func main() {
 	tf.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
-	// Set defaults.
-	m := []uimage.Modifier{
-		uimage.WithReplaceEnv(env),
-		uimage.WithBaseArchive(uimage.DefaultRamfs()),
-		uimage.WithCPIOOutput(defaultFile(env)),
-		uimage.WithInit("init"),
-	}
-	if golang.Default().GOOS != "plan9" {
-		m = append(m, uimage.WithShell("gosh"))
-	}
-
 	pkgs := flag.Args()
 	// Only add default packages if no config template was given.
 	//
@@ -94,13 +456,70 @@ func main() {
 	if len(pkgs) == 0 && tf.Config == "" {
 		pkgs = []string{"github.com/u-root/u-root/cmds/core/*"}
 	}
-	if err := mkuimage.CreateUimage(l, m, tf, f, pkgs); err != nil {
-		l.Errorf("mkuimage error: %v", err)
-		os.Exit(1)
+
+	// Catch bad formats and levels before spending time on the build.
+	if *compressFormat != "" {
+		if _, err := compress.NewWriter(io.Discard, *compressFormat, *compressLevel); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if stat, err := os.Stat(f.OutputFile); err == nil && f.ArchiveFormat == "cpio" {
-		l.Infof("Successfully built %q (size %d bytes -- %s).", f.OutputFile, stat.Size(), humanize.IBytes(uint64(stat.Size())))
+	var records []cpio.Record
+	if *manifestFile != "" {
+		m, err := manifest.Load(*manifestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if records, err = m.Records(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *modulesDir != "" {
+		mr, err := moduleRecords()
+		if err != nil {
+			log.Fatal(err)
+		}
+		records = append(records, mr...)
+	}
+
+	pool := compress.NewPool(*compressJobs)
+	if *arch == "" {
+		if err := build(l, env, tf, f, pkgs, pool, records); err != nil {
+			l.Errorf("mkuimage error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	targets, err := parseTargets(*arch, env.GOOS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputSet := false
+	flag.Visit(func(fl *flag.Flag) {
+		outputSet = outputSet || fl.Name == "o"
+	})
+	output, tempDir := f.OutputFile, f.TempDir
+	var failed []string
+	for _, t := range targets {
+		tenv := golang.Default(golang.DisableCGO(), golang.WithGOOS(t.GOOS), golang.WithGOARCH(t.GOARCH))
+		f.OutputFile = defaultFile(tenv)
+		if outputSet {
+			f.OutputFile = targetFile(output, t)
+		}
+		// CreateUimage sets and deletes a temporary directory if
+		// none was given; don't reuse it for the next target.
+		f.TempDir = tempDir
+
+		l.Infof("Building %s/%s", t.GOOS, t.GOARCH)
+		if err := build(l, tenv, tf, f, pkgs, pool, records); err != nil {
+			l.Errorf("mkuimage error for %s/%s: %v", t.GOOS, t.GOARCH, err)
+			failed = append(failed, t.GOOS+"/"+t.GOARCH)
+		}
+	}
+	if len(failed) > 0 {
+		l.Errorf("Failed to build for %s", strings.Join(failed, ", "))
+		os.Exit(1)
 	}
 }
 
@@ -13,9 +13,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	gbbgolang "github.com/u-root/gobusybox/src/pkg/golang"
 	"github.com/u-root/u-root/pkg/cpio"
@@ -101,6 +103,14 @@ func TestUrootCmdline(t *testing.T) {
 	if err = os.WriteFile(filepath.Join(sampledir, "bar"), nil, 0o644); err != nil {
 		t.Fatal(err)
 	}
+	extension := filepath.Join(sampledir, "templates.yaml")
+	if err = os.WriteFile(extension, []byte(`
+commands:
+  tiny: [github.com/u-root/u-root/cmds/core/init]
+  tiny2: [tiny, github.com/u-root/u-root/cmds/core/echo]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
 
 	type testCase struct {
 		name       string
@@ -172,6 +182,14 @@ func TestUrootCmdline(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "config extension",
+			args: []string{"-defaultsh=", "-config-extend=" + extension, "tiny2"},
+			validators: []itest.ArchiveValidator{
+				itest.HasFile{Path: "bbin/init"},
+				itest.HasFile{Path: "bbin/echo"},
+			},
+		},
 		{
 			name: "dead_code_elimination",
 			args: []string{
@@ -367,3 +385,79 @@ func TestCheckArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTargets(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []target
+		err  error
+	}{
+		{in: "amd64", want: []target{{"linux", "amd64"}}},
+		{in: "amd64, arm64,riscv64,amd64", want: []target{{"linux", "amd64"}, {"linux", "arm64"}, {"linux", "riscv64"}}},
+		{in: "plan9/amd64,arm64", want: []target{{"plan9", "amd64"}, {"linux", "arm64"}}},
+		{in: "", err: errBadTarget},
+		{in: "/arm64", err: errBadTarget},
+		{in: "linux/arm/v7", err: errBadTarget},
+	} {
+		got, err := parseTargets(tt.in, "linux")
+		if !errors.Is(err, tt.err) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTargets(%q) = %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestTargetFile(t *testing.T) {
+	arm := target{GOOS: "linux", GOARCH: "arm64"}
+	for in, want := range map[string]string{
+		"/tmp/initramfs.cpio":    "/tmp/initramfs.linux_arm64.cpio",
+		"/tmp/initramfs.cpio.xz": "/tmp/initramfs.linux_arm64.cpio.xz",
+		"out/initramfs":          "out/initramfs.linux_arm64",
+	} {
+		if got := targetFile(in, arm); got != want {
+			t.Errorf("targetFile(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	skip := []string{"ls", "github.com/u-root/u-root/cmds/exp/ed"}
+	for _, tt := range []struct {
+		pkg  string
+		want bool
+	}{
+		{pkg: "github.com/u-root/u-root/cmds/core/ls", want: true},
+		{pkg: "github.com/u-root/u-root/cmds/exp/ed", want: true},
+		{pkg: "github.com/u-root/u-root/cmds/core/lsfd"},
+		{pkg: "github.com/u-root/u-root/cmds/core/ed"},
+	} {
+		if _, got := skipped(tt.pkg, skip); got != tt.want {
+			t.Errorf("skipped(%q) = %t, want %t", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestFindBusybox(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := findBusybox(dir); !errors.Is(err, errNoBusybox) {
+		t.Errorf("findBusybox of an empty dir = %v, want %v", err, errNoBusybox)
+	}
+	old, now := time.Unix(1700000000, 0), time.Now()
+	for _, b := range []struct {
+		dir   string
+		mtime time.Time
+	}{{"builder1", now}, {"builder2", old}} {
+		p := filepath.Join(dir, b.dir, "bb")
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, b.mtime, b.mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := findBusybox(dir); err != nil || got != filepath.Join(dir, "builder1", "bb") {
+		t.Errorf("findBusybox = %q, %v, want the newest, in builder1", got, err)
+	}
+}